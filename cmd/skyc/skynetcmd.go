@@ -23,7 +23,6 @@ import (
 	"gitlab.com/SkynetLabs/skyd/node/api"
 	"gitlab.com/SkynetLabs/skyd/siatest"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
-	"gitlab.com/SkynetLabs/skyd/skymodules/renter"
 )
 
 var (
@@ -489,7 +488,8 @@ func skynetPin(skylink string, siaPath skymodules.SiaPath) (string, error) {
 			Root:    skynetUploadRoot,
 		}
 		fmt.Println("Pinning Skyfile ...")
-		return skylink, httpClient.SkynetSkylinkPinPost(skylink, spp)
+		_, err := httpClient.SkynetSkylinkPinPost(skylink, spp)
+		return skylink, err
 	}
 
 	// Download skyfile from the Portal
@@ -944,17 +944,16 @@ func skynetUploadDirectory(sourcePath, destSiaPath string) {
 	}()
 
 	sup := skymodules.SkyfileMultipartUploadParameters{
-		SiaPath:             skyfilePath,
-		Force:               false,
-		Root:                false,
-		BaseChunkRedundancy: renter.SkyfileDefaultBaseChunkRedundancy,
-		Reader:              pr,
-		Filename:            skyfilePath.Name(),
-		DefaultPath:         skynetUploadDefaultPath,
-		DisableDefaultPath:  skynetUploadDisableDefaultPath,
-		TryFiles:            tryfiles,
-		ErrorPages:          errPages,
-		ContentType:         writer.FormDataContentType(),
+		SiaPath:            skyfilePath,
+		Force:              false,
+		Root:               false,
+		Reader:             pr,
+		Filename:           skyfilePath.Name(),
+		DefaultPath:        skynetUploadDefaultPath,
+		DisableDefaultPath: skynetUploadDisableDefaultPath,
+		TryFiles:           tryfiles,
+		ErrorPages:         errPages,
+		ContentType:        writer.FormDataContentType(),
 	}
 	skylink, _, err := httpClient.SkynetSkyfileMultiPartPost(sup)
 	if err != nil {
@@ -49,6 +49,13 @@ type Error struct {
 	// be valid or invalid depending on the current state of a module.
 }
 
+// tracedError is the JSON representation of an Error returned by a skynet
+// request. It includes the trace ID of the request.
+type tracedError struct {
+	Message string `json:"message"`
+	TraceID string `json:"traceid"`
+}
+
 // Error implements the error interface for the Error type. It returns only the
 // Message field.
 func (err Error) Error() string {
@@ -144,7 +151,10 @@ type (
 		Shutdown          func() error
 		siadConfig        *skymodules.SiadConfig
 
-		staticStartTime time.Time
+		staticSkynetPartStaging   *skynetPartStaging
+		staticSkynetStats         *skynetPerformanceStats
+		staticSkynetUploadLimiter *skynetUploadRateLimiter
+		staticStartTime           time.Time
 
 		staticDeps modules.Dependencies
 	}
@@ -233,8 +243,11 @@ func NewCustom(cfg *skymodules.SiadConfig, requiredUserAgent string, requiredPas
 		requiredPassword:  requiredPassword,
 		siadConfig:        cfg,
 
-		staticDeps:      deps,
-		staticStartTime: time.Now(),
+		staticDeps:                deps,
+		staticSkynetPartStaging:   newSkynetPartStaging(skynetPartStagingMaxSize, skynetPartStagingTTL),
+		staticSkynetStats:         newSkynetPerformanceStats(),
+		staticSkynetUploadLimiter: newSkynetUploadRateLimiter(),
+		staticStartTime:           time.Now(),
 	}
 
 	// Register API handlers
@@ -263,9 +276,18 @@ func WriteError(w http.ResponseWriter, err Error, code int) {
 		build.Critical("ErrSkynetBlocked should always be returned with http.StatusUnavailableForLegalReasons")
 	}
 
+	// Include the trace ID of skynet requests.
+	var body interface{} = err
+	if traceID := w.Header().Get(SkynetTraceIDHeader); traceID != "" {
+		body = tracedError{
+			Message: err.Message,
+			TraceID: traceID,
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(code)
-	encodingErr := json.NewEncoder(w).Encode(err)
+	encodingErr := json.NewEncoder(w).Encode(body)
 	if _, isJsonErr := encodingErr.(*json.SyntaxError); isJsonErr {
 		// Marshalling should only fail in the event of a developer error.
 		// Specifically, only non-marshallable types should cause an error here.
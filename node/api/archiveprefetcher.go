@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+type (
+	// archiveSource returns the content of the subfiles of an archive one
+	// after another in the order in which they are archived.
+	archiveSource interface {
+		// Next returns a reader for the content of the next subfile. The
+		// reader is only valid until the next call to Next.
+		Next() (io.Reader, error)
+	}
+
+	// sequentialArchiveSource is an archiveSource that seeks to and reads
+	// every subfile from the underlying skyfile when it is requested.
+	sequentialArchiveSource struct {
+		staticFiles []skymodules.SkyfileSubfileMetadata
+		staticSrc   io.ReadSeeker
+
+		next int
+	}
+
+	// archivePrefetcher is an archiveSource that reads the content of up to
+	// prefetch depth subfiles ahead of the subfile which is currently being
+	// archived. Subfiles larger than maxArchivePrefetchSize are not
+	// prefetched. Instead, the prefetcher pauses and the subfile is read from
+	// the underlying skyfile by the caller of Next. That way the skyfile is
+	// never accessed concurrently.
+	archivePrefetcher struct {
+		staticFiles    []skymodules.SkyfileSubfileMetadata
+		staticSrc      io.ReadSeeker
+		staticFetched  chan prefetchedSubfile
+		staticStopChan chan struct{}
+		staticDoneChan chan struct{}
+		staticStopOnce sync.Once
+
+		// resume is closed to resume prefetching after the caller of Next is
+		// done reading a subfile from the underlying skyfile.
+		resume chan struct{}
+	}
+
+	// prefetchedSubfile is the result of prefetching a subfile. If resume is
+	// set, the subfile wasn't prefetched and needs to be read from the
+	// underlying skyfile.
+	prefetchedSubfile struct {
+		data   []byte
+		err    error
+		file   skymodules.SkyfileSubfileMetadata
+		resume chan struct{}
+	}
+)
+
+// newArchiveSource returns an archiveSource for the given files of src. If
+// prefetch is 0, the files are read sequentially. Otherwise up to prefetch
+// files are read ahead. The returned function must be called once the source
+// is no longer needed.
+func newArchiveSource(src io.ReadSeeker, files []skymodules.SkyfileSubfileMetadata, prefetch uint64) (archiveSource, func()) {
+	if prefetch == 0 {
+		return &sequentialArchiveSource{
+			staticFiles: files,
+			staticSrc:   src,
+		}, func() {}
+	}
+	ap := &archivePrefetcher{
+		staticFiles:    files,
+		staticSrc:      src,
+		staticFetched:  make(chan prefetchedSubfile, prefetch),
+		staticStopChan: make(chan struct{}),
+		staticDoneChan: make(chan struct{}),
+	}
+	go ap.threadedPrefetch()
+	return ap, ap.stop
+}
+
+// Next implements the archiveSource interface.
+func (s *sequentialArchiveSource) Next() (io.Reader, error) {
+	if s.next >= len(s.staticFiles) {
+		return nil, io.EOF
+	}
+	file := s.staticFiles[s.next]
+	s.next++
+	return readSubfile(s.staticSrc, file)
+}
+
+// Next implements the archiveSource interface.
+func (ap *archivePrefetcher) Next() (io.Reader, error) {
+	// If the caller read the previous subfile from the skyfile, the
+	// prefetcher may continue.
+	if ap.resume != nil {
+		close(ap.resume)
+		ap.resume = nil
+	}
+	fetched, ok := <-ap.staticFetched
+	if !ok {
+		return nil, io.EOF
+	}
+	if fetched.err != nil {
+		return nil, fetched.err
+	}
+	if fetched.resume == nil {
+		return bytes.NewReader(fetched.data), nil
+	}
+	// The subfile wasn't prefetched. Read it from the skyfile while the
+	// prefetcher is paused.
+	ap.resume = fetched.resume
+	return readSubfile(ap.staticSrc, fetched.file)
+}
+
+// stop stops the prefetcher and waits for it to return.
+func (ap *archivePrefetcher) stop() {
+	ap.staticStopOnce.Do(func() {
+		close(ap.staticStopChan)
+	})
+	<-ap.staticDoneChan
+}
+
+// threadedPrefetch reads the subfiles one after another and passes them to
+// Next in order.
+func (ap *archivePrefetcher) threadedPrefetch() {
+	defer close(ap.staticDoneChan)
+	defer close(ap.staticFetched)
+
+	for _, file := range ap.staticFiles {
+		fetched := prefetchedSubfile{file: file}
+		if file.Len > maxArchivePrefetchSize {
+			fetched.resume = make(chan struct{})
+		} else {
+			fetched.data, fetched.err = prefetchSubfile(ap.staticSrc, file)
+		}
+		select {
+		case ap.staticFetched <- fetched:
+		case <-ap.staticStopChan:
+			return
+		}
+		if fetched.err != nil {
+			return
+		}
+		// Wait for the caller to be done with the skyfile.
+		if fetched.resume != nil {
+			select {
+			case <-fetched.resume:
+			case <-ap.staticStopChan:
+				return
+			}
+		}
+	}
+}
+
+// prefetchSubfile reads the full content of the given subfile from src.
+func prefetchSubfile(src io.ReadSeeker, file skymodules.SkyfileSubfileMetadata) ([]byte, error) {
+	r, err := readSubfile(src, file)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, file.Len)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to prefetch subfile")
+	}
+	return data, nil
+}
+
+// readSubfile seeks to the given subfile within src and returns a reader for
+// its content.
+func readSubfile(src io.ReadSeeker, file skymodules.SkyfileSubfileMetadata) (io.Reader, error) {
+	_, err := src.Seek(int64(file.Offset), io.SeekStart)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to seek to the subfile")
+	}
+	return io.LimitReader(src, int64(file.Len)), nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// exclusiveReadSeeker is a helper that wraps an io.ReadSeeker and fails the
+// test if it is accessed concurrently. Reads beyond failAt return an error.
+type exclusiveReadSeeker struct {
+	staticT *testing.T
+	src     io.ReadSeeker
+	offset  int64
+	failAt  int64
+	active  int32
+}
+
+// Read implements io.Reader.
+func (ers *exclusiveReadSeeker) Read(p []byte) (int, error) {
+	ers.enter()
+	defer ers.exit()
+	if ers.failAt > 0 && ers.offset+int64(len(p)) > ers.failAt {
+		return 0, errors.New("read failed")
+	}
+	// Slow down reads to give concurrent access a chance to happen.
+	time.Sleep(time.Millisecond)
+	n, err := ers.src.Read(p)
+	ers.offset += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (ers *exclusiveReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	ers.enter()
+	defer ers.exit()
+	n, err := ers.src.Seek(offset, whence)
+	ers.offset = n
+	return n, err
+}
+
+// enter marks the start of an access.
+func (ers *exclusiveReadSeeker) enter() {
+	if !atomic.CompareAndSwapInt32(&ers.active, 0, 1) {
+		ers.staticT.Error("concurrent access to the skyfile")
+	}
+}
+
+// exit marks the end of an access.
+func (ers *exclusiveReadSeeker) exit() {
+	atomic.StoreInt32(&ers.active, 0)
+}
+
+// TestServeArchivePrefetch verifies that prefetching subfiles produces the same
+// archives as reading them sequentially.
+func TestServeArchivePrefetch(t *testing.T) {
+	t.Parallel()
+
+	// Create a skyfile with subfiles that are smaller and larger than
+	// maxArchivePrefetchSize, including an empty one.
+	sizes := []uint64{
+		10,
+		maxArchivePrefetchSize + 1,
+		0,
+		maxArchivePrefetchSize,
+		3 * maxArchivePrefetchSize,
+		1,
+		maxArchivePrefetchSize / 2,
+	}
+	md := skymodules.SkyfileMetadata{
+		Filename: "dir",
+		Subfiles: make(skymodules.SkyfileSubfiles),
+	}
+	var offset uint64
+	for i, size := range sizes {
+		name := string(rune('a' + i))
+		md.Subfiles[name] = skymodules.SkyfileSubfileMetadata{
+			Filename: name,
+			FileMode: 0644,
+			Offset:   offset,
+			Len:      size,
+		}
+		offset += size
+	}
+	md.Length = offset
+	data := fastrand.Bytes(int(offset))
+
+	// serve is a helper to serve the skyfile as an archive.
+	serve := func(format skymodules.SkyfileFormat, prefetch uint64, failAt int64) ([]byte, error) {
+		src := &exclusiveReadSeeker{
+			staticT: t,
+			src:     bytes.NewReader(data),
+			failAt:  failAt,
+		}
+		w := httptest.NewRecorder()
+		err := serveArchive(w, src, format, md, prefetch)
+		return w.Body.Bytes(), err
+	}
+
+	formats := []skymodules.SkyfileFormat{
+		skymodules.SkyfileFormatTar,
+		skymodules.SkyfileFormatTarGz,
+		skymodules.SkyfileFormatZip,
+	}
+	for _, format := range formats {
+		expected, err := serve(format, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, prefetch := range []uint64{1, 3, maxArchivePrefetchDepth} {
+			archive, err := serve(format, prefetch, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(archive, expected) {
+				t.Fatalf("%v archive with prefetch %v doesn't match", format, prefetch)
+			}
+		}
+
+		// Errors reading prefetched and streamed subfiles are returned.
+		for _, failAt := range []int64{5, int64(maxArchivePrefetchSize) + 20} {
+			if _, err := serve(format, 3, failAt); err == nil {
+				t.Fatal("expected error", format, failAt)
+			}
+		}
+	}
+}
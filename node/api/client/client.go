@@ -206,6 +206,43 @@ func (c *Client) getReaderResponse(resource string) (_ http.Header, _ io.ReadClo
 	return res.Header, res.Body, nil
 }
 
+// getRawResponseWithTrailer requests the specified resource and allows to
+// pass custom headers. The response, if provided, will be returned in a byte
+// slice together with the header and the trailer of the response.
+func (c *Client) getRawResponseWithTrailer(resource string, headers http.Header) (http.Header, http.Header, []byte, error) {
+	req, err := c.NewRequest("GET", resource, nil)
+	if err != nil {
+		return nil, nil, nil, errors.AddContext(err, "failed to construct GET request")
+	}
+
+	// Decorate the headers on the request object
+	for k, v := range headers {
+		for _, vv := range v {
+			req.Header.Add(k, vv)
+		}
+	}
+	httpClient := http.Client{CheckRedirect: c.CheckRedirect}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, nil, errors.AddContext(err, "GET request failed")
+	}
+	defer drainAndClose(res.Body)
+
+	// If the status code is not 2xx, decode and return the accompanying
+	// api.Error.
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err := readAPIError(res.Body)
+		return nil, nil, nil, errors.AddContext(err, "GET request error")
+	}
+
+	// The trailer is only populated once the body was read.
+	d, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, nil, errors.AddContext(err, "failed to read all bytes from reader")
+	}
+	return res.Header, res.Trailer, d, nil
+}
+
 // getRawResponse requests part of the specified resource. The response, if
 // provided, will be returned in a byte slice
 func (c *Client) getRawPartialResponse(resource string, from, to uint64) ([]byte, error) {
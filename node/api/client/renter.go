@@ -506,6 +506,127 @@ func (c *Client) RenterRateLimitPost(readBPS, writeBPS int64) (err error) {
 	return
 }
 
+// RenterSkynetMaxUploadSizePost uses the /renter endpoint to set the maximum
+// size of skyfile uploads. A size of 0 means unlimited.
+func (c *Client) RenterSkynetMaxUploadSizePost(maxUploadSize uint64) (err error) {
+	values := url.Values{}
+	values.Set("skynetmaxuploadsize", strconv.FormatUint(maxUploadSize, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetForceTokenKeyPost uses the /renter endpoint to set the public
+// key force tokens are verified against. An empty key disables force tokens.
+func (c *Client) RenterSkynetForceTokenKeyPost(key types.SiaPublicKey) (err error) {
+	var keyStr string
+	if len(key.Key) > 0 {
+		keyStr = key.String()
+	}
+	values := url.Values{}
+	values.Set("skynetforcetokenkey", keyStr)
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetMultipartLimitsPost uses the /renter endpoint to set the limits
+// of multipart uploads. Limits of 0 reset them to their defaults.
+func (c *Client) RenterSkynetMultipartLimitsPost(limits skymodules.SkynetMultipartLimits) (err error) {
+	values := url.Values{}
+	values.Set("skynetmultipartmaxfilenamelength", strconv.FormatUint(limits.MaxFilenameLength, 10))
+	values.Set("skynetmultipartmaxheaderbytes", strconv.FormatUint(limits.MaxHeaderBytes, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterDefaultBaseChunkRedundancyPost uses the /renter endpoint to set the
+// base chunk redundancy of skyfiles which don't specify one. A redundancy of 0
+// resets it to the default.
+func (c *Client) RenterDefaultBaseChunkRedundancyPost(redundancy uint8) (err error) {
+	values := url.Values{}
+	values.Set("defaultbasechunkredundancy", fmt.Sprint(redundancy))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetStorageCapPost uses the /renter endpoint to set the maximum
+// number of bytes the skyfiles in the skynet folder may use. A cap of 0 means
+// unlimited.
+func (c *Client) RenterSkynetStorageCapPost(storageCap uint64) (err error) {
+	values := url.Values{}
+	values.Set("skynetstoragecap", strconv.FormatUint(storageCap, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetAllowlistEnforcedPost uses the /renter endpoint to set whether
+// only skylinks on the allowlist are served.
+func (c *Client) RenterSkynetAllowlistEnforcedPost(enforced bool) (err error) {
+	values := url.Values{}
+	values.Set("skynetallowlistenforced", strconv.FormatBool(enforced))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetDegradedDownloadsPost uses the /renter endpoint to set whether
+// skynet downloads may proceed with fewer usable workers than pieces needed to
+// recover the data.
+func (c *Client) RenterSkynetDegradedDownloadsPost(degraded bool) (err error) {
+	values := url.Values{}
+	values.Set("skynetdegradeddownloads", strconv.FormatBool(degraded))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetMaxDataURISizePost uses the /renter endpoint to set the maximum
+// size of content that can be downloaded as a data URI. 0 resets it to the
+// default.
+func (c *Client) RenterSkynetMaxDataURISizePost(size uint64) (err error) {
+	values := url.Values{}
+	values.Set("skynetmaxdataurisize", fmt.Sprint(size))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetWeakETagsPost uses the /renter endpoint to set whether skylink
+// responses use weak ETags.
+func (c *Client) RenterSkynetWeakETagsPost(weak bool) (err error) {
+	values := url.Values{}
+	values.Set("skynetweaketags", strconv.FormatBool(weak))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetCORSOriginsPost uses the /renter endpoint to set the origins
+// which are allowed to access skyfiles cross-origin. No origins disable the
+// CORS headers.
+func (c *Client) RenterSkynetCORSOriginsPost(origins []string) (err error) {
+	values := url.Values{}
+	values.Set("skynetcorsorigins", strings.Join(origins, ","))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetUploadAlertThresholdPost uses the /renter endpoint to set the
+// p99 threshold in milliseconds for the base sector upload alert. A threshold
+// of 0 means that the default is used.
+func (c *Client) RenterSkynetUploadAlertThresholdPost(thresholdMS uint64) (err error) {
+	values := url.Values{}
+	values.Set("skynetuploadalertthresholdms", strconv.FormatUint(thresholdMS, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSkynetRequestTimeoutsPost uses the /renter endpoint to set the default
+// and maximum timeout in seconds for skynet requests. A timeout of 0 means
+// that the built-in default is used.
+func (c *Client) RenterSkynetRequestTimeoutsPost(defaultTimeout, maxTimeout uint64) (err error) {
+	values := url.Values{}
+	values.Set("skynetdefaultrequesttimeout", strconv.FormatUint(defaultTimeout, 10))
+	values.Set("skynetmaxrequesttimeout", strconv.FormatUint(maxTimeout, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterRenamePost uses the /renter/rename/:siapath endpoint to rename a file.
 func (c *Client) RenterRenamePost(siaPathOld, siaPathNew skymodules.SiaPath, root bool) (err error) {
 	spo := escapeSiaPath(siaPathOld)
@@ -9,6 +9,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"net/url"
 	"path/filepath"
 	"sort"
@@ -73,6 +75,36 @@ func (c *Client) SkynetBaseSectorGet(skylink string) (io.ReadCloser, error) {
 	return reader, err
 }
 
+// SkynetBaseSectorHead uses the /skynet/basesector endpoint to check whether
+// the base sector of a skylink is available without downloading it.
+func (c *Client) SkynetBaseSectorHead(skylink string, timeout int) (int, http.Header, error) {
+	values := url.Values{}
+	values.Set("timeout", fmt.Sprintf("%d", timeout))
+	return c.head(fmt.Sprintf("/skynet/basesector/%s?%s", skylink, values.Encode()))
+}
+
+// SkynetBaseSectorGetDecrypted uses the /skynet/basesector endpoint to fetch
+// the base sector of a skylink and to decrypt it on the server if it is
+// encrypted. If skykeyName is empty, all of the renter's skykeys are tried.
+func (c *Client) SkynetBaseSectorGetDecrypted(skylink, skykeyName string) ([]byte, error) {
+	values := url.Values{}
+	if skykeyName != "" {
+		values.Set("skykeyname", skykeyName)
+	} else {
+		values.Set("trykeys", "true")
+	}
+	return c.SkynetBaseSectorGetDecryptedWithParameters(skylink, values)
+}
+
+// SkynetBaseSectorGetDecryptedWithParameters uses the /skynet/basesector
+// endpoint to fetch the decrypted base sector of a skylink with the given
+// query string parameters, e.g. 'skykeyid'.
+func (c *Client) SkynetBaseSectorGetDecryptedWithParameters(skylink string, values url.Values) ([]byte, error) {
+	values.Set("decrypt", "true")
+	_, baseSector, err := c.getRawResponse(fmt.Sprintf("/skynet/basesector/%s?%s", skylink, values.Encode()))
+	return baseSector, err
+}
+
 // SkynetDownloadByRootGet uses the /skynet/root endpoint to fetch a reader of
 // a sector.
 func (c *Client) SkynetDownloadByRootGet(root crypto.Hash, offset, length uint64, timeout time.Duration) (io.ReadCloser, error) {
@@ -88,6 +120,40 @@ func (c *Client) SkynetDownloadByRootGet(root crypto.Hash, offset, length uint64
 	return reader, err
 }
 
+// SkynetRootHead uses the /skynet/root endpoint to check whether a sector is
+// available without downloading it.
+func (c *Client) SkynetRootHead(root crypto.Hash, offset, length uint64, timeout int) (int, http.Header, error) {
+	values := url.Values{}
+	values.Set("root", root.String())
+	values.Set("offset", fmt.Sprint(offset))
+	values.Set("length", fmt.Sprint(length))
+	values.Set("timeout", fmt.Sprintf("%d", timeout))
+	return c.head(fmt.Sprintf("/skynet/root?%v", values.Encode()))
+}
+
+// SkynetDownloadByRootGetWithHostStats uses the /skynet/root endpoint to fetch
+// a sector together with the stats of the hosts that served it.
+func (c *Client) SkynetDownloadByRootGetWithHostStats(root crypto.Hash, offset, length uint64, timeout time.Duration) ([]byte, []skymodules.SkynetHostStats, error) {
+	values := url.Values{}
+	values.Set("root", root.String())
+	values.Set("offset", fmt.Sprint(offset))
+	values.Set("length", fmt.Sprint(length))
+	values.Set("include-hosts", "true")
+	if timeout >= 0 {
+		values.Set("timeout", fmt.Sprintf("%s", timeout))
+	}
+	getQuery := fmt.Sprintf("/skynet/root?%v", values.Encode())
+	header, data, err := c.getRawResponse(getQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostStats, err := parseHostStats(header.Get(api.SkynetHostStatsTrailer))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, hostStats, nil
+}
+
 // SkynetTUSClient creates a ready-to-use TUS client assuming the default upload
 // params.
 func (c *Client) SkynetTUSClient(chunkSize int64) (*tus.Client, error) {
@@ -190,6 +256,30 @@ func (uc *UnsafeClient) SkynetSkylinkGetWithETag(skylink string, eTag string) (*
 	return uc.GetWithHeaders(skylinkQueryWithValues(skylink, url.Values{}), http.Header{"If-None-Match": []string{eTag}})
 }
 
+// SkynetSkylinkGetWithSkykey uses the /skynet/skylink endpoint to download a
+// skylink file which is decrypted using the given skykey. The skykey is passed
+// in the X-Skynet-Skykey request header.
+func (uc *UnsafeClient) SkynetSkylinkGetWithSkykey(skylink string, sk skykey.Skykey) (*http.Response, error) {
+	return uc.SkynetSkylinkGetWithSkykeyAndValues(skylink, sk, url.Values{})
+}
+
+// SkynetSkylinkGetWithSkykeyAndValues is the same as
+// SkynetSkylinkGetWithSkykey but it also sets the given query values.
+func (uc *UnsafeClient) SkynetSkylinkGetWithSkykeyAndValues(skylink string, sk skykey.Skykey, values url.Values) (*http.Response, error) {
+	skStr, err := sk.ToString()
+	if err != nil {
+		return nil, err
+	}
+	return uc.GetWithHeaders(skylinkQueryWithValues(skylink, values), http.Header{api.SkynetSkykeyHeader: []string{skStr}})
+}
+
+// SkynetSkylinkGetWithTraceID uses the /skynet/skylink endpoint to download a
+// skylink file with the given query values. The trace ID is passed in the
+// Skynet-Trace-Id request header.
+func (uc *UnsafeClient) SkynetSkylinkGetWithTraceID(skylink, traceID string, values url.Values) (*http.Response, error) {
+	return uc.GetWithHeaders(skylinkQueryWithValues(skylink, values), http.Header{api.SkynetTraceIDHeader: []string{traceID}})
+}
+
 // SkynetSkyfilePostRawResponse uses the /skynet/skyfile endpoint to upload a
 // skyfile.  This function is unsafe as it returns the raw response alongside
 // the http headers.
@@ -231,7 +321,13 @@ func (c *Client) SkynetSkylinkGet(skylink string) ([]byte, error) {
 // SkynetMetadataGet uses the /skynet/metadata endpoint to fetch a skylink's
 // metadata.
 func (c *Client) SkynetMetadataGet(skylink string) (_ http.Header, sm skymodules.SkyfileMetadata, _ error) {
-	header, body, err := c.getRawResponse(fmt.Sprintf("/skynet/metadata/%s", skylink))
+	return c.SkynetMetadataGetWithParameters(skylink, url.Values{})
+}
+
+// SkynetMetadataGetWithParameters uses the /skynet/metadata endpoint to fetch
+// a skylink's metadata with the given query string parameters.
+func (c *Client) SkynetMetadataGetWithParameters(skylink string, values url.Values) (_ http.Header, sm skymodules.SkyfileMetadata, _ error) {
+	header, body, err := c.getRawResponse(fmt.Sprintf("/skynet/metadata/%s?%s", skylink, values.Encode()))
 	if err != nil {
 		return nil, skymodules.SkyfileMetadata{}, err
 	}
@@ -269,6 +365,59 @@ func (c *Client) SkynetSkylinkGetWithTimeout(skylink string, timeout int) ([]byt
 	return c.skynetSkylinkGetWithParameters(skylink, params)
 }
 
+// SkynetSkylinkGetWithNoRedirect uses the /skynet/skylink endpoint to
+// download a skylink file with the 'no-redirect' parameter set. It returns the
+// response headers as well as the data.
+func (c *Client) SkynetSkylinkGetWithNoRedirect(skylink string) (http.Header, []byte, error) {
+	return c.skynetSkylinkGetWithParametersRaw(skylink, map[string]string{
+		"no-redirect": fmt.Sprintf("%t", true),
+	})
+}
+
+// SkynetSkylinkGetWithSubfileIndex uses the /skynet/skylink endpoint to
+// download the subfile at the given index of a skyfile. It returns the
+// response headers as well as the data.
+func (c *Client) SkynetSkylinkGetWithSubfileIndex(skylink string, index uint64) (http.Header, []byte, error) {
+	return c.skynetSkylinkGetWithParametersRaw(skylink, map[string]string{
+		"subfileindex": fmt.Sprint(index),
+	})
+}
+
+// SkynetSkylinkPreviewGet uses the /skynet/skylink endpoint to download only
+// the first n bytes of a skylink file. It returns the response headers as well
+// as the data.
+func (c *Client) SkynetSkylinkPreviewGet(skylink string, n uint64) (http.Header, []byte, error) {
+	return c.skynetSkylinkGetWithParametersRaw(skylink, map[string]string{
+		"preview": fmt.Sprint(n),
+	})
+}
+
+// SkynetSkylinkRangeWithSubfileIndex uses the /skynet/skylink endpoint to
+// download a range from the subfile at the given index of a skyfile.
+func (c *Client) SkynetSkylinkRangeWithSubfileIndex(skylink string, index, from, to uint64) ([]byte, error) {
+	values := url.Values{}
+	values.Set("subfileindex", fmt.Sprint(index))
+	getQuery := skylinkQueryWithValues(skylink, values)
+	return c.getRawPartialResponse(getQuery, from, to)
+}
+
+// SkynetSkylinkGetWithVerify uses the /skynet/skylink endpoint to download a
+// skylink file with the 'verify' parameter set.
+func (c *Client) SkynetSkylinkGetWithVerify(skylink string) ([]byte, error) {
+	return c.skynetSkylinkGetWithParameters(skylink, map[string]string{
+		"verify": fmt.Sprintf("%t", true),
+	})
+}
+
+// SkynetSkylinkRangeWithVerify uses the /skynet/skylink endpoint to download
+// a range from a skylink file with the 'verify' parameter set.
+func (c *Client) SkynetSkylinkRangeWithVerify(skylink string, from, to uint64) ([]byte, error) {
+	values := url.Values{}
+	values.Set("verify", fmt.Sprintf("%t", true))
+	getQuery := skylinkQueryWithValues(skylink, values)
+	return c.getRawPartialResponse(getQuery, from, to)
+}
+
 // SkynetSkylinkGetWithLayout uses the /skynet/skylink endpoint to download
 // a skylink file, specifying the given value for the 'include-layout'
 // parameter.
@@ -293,6 +442,114 @@ func (c *Client) SkynetSkylinkGetWithLayout(skylink string, incLayout bool) ([]b
 	return fileData, layout, nil
 }
 
+// SkynetSkylinkGetWithHostStats uses the /skynet/skylink endpoint to download
+// a skylink file together with the stats of the hosts that served the data.
+func (c *Client) SkynetSkylinkGetWithHostStats(skylink string) ([]byte, []skymodules.SkynetHostStats, error) {
+	values := url.Values{}
+	values.Set("include-hosts", "true")
+	getQuery := skylinkQueryWithValues(skylink, values)
+	_, trailer, fileData, err := c.getRawResponseWithTrailer(getQuery, nil)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to download skylink with host stats")
+	}
+	hostStats, err := parseHostStats(trailer.Get(api.SkynetHostStatsTrailer))
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileData, hostStats, nil
+}
+
+// SkynetSkylinkGetWithContentHash uses the /skynet/skylink endpoint to
+// download a skylink with the given query string parameters. It indicates that
+// it accepts trailers and verifies the downloaded data against the content
+// hash trailer if one was attached. The hash is returned together with the
+// data and is empty if the trailer was missing.
+func (c *Client) SkynetSkylinkGetWithContentHash(skylink string, values url.Values) ([]byte, string, error) {
+	getQuery := skylinkQueryWithValues(skylink, values)
+	headers := http.Header{}
+	headers.Set("TE", "trailers")
+	_, trailer, fileData, err := c.getRawResponseWithTrailer(getQuery, headers)
+	if err != nil {
+		return nil, "", errors.AddContext(err, "unable to download skylink with content hash")
+	}
+	contentHash := trailer.Get(api.SkynetContentHashTrailer)
+	if contentHash == "" {
+		return fileData, "", nil
+	}
+	h := crypto.NewHash()
+	_, _ = h.Write(fileData)
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != contentHash {
+		return nil, "", fmt.Errorf("content hash mismatch, expected %v but got %v", contentHash, actual)
+	}
+	return fileData, contentHash, nil
+}
+
+// SkynetSkylinkGetWithServingProof uses the /skynet/skylink endpoint to
+// download a skylink with the 'sign-response' parameter set. The given headers
+// are added to the request. It returns the data together with the serving
+// proof, which is either attached as a header or as a trailer.
+func (c *Client) SkynetSkylinkGetWithServingProof(skylink string, headers http.Header) ([]byte, skymodules.SkynetServingProof, error) {
+	values := url.Values{}
+	values.Set("sign-response", "true")
+	getQuery := skylinkQueryWithValues(skylink, values)
+	header, trailer, fileData, err := c.getRawResponseWithTrailer(getQuery, headers)
+	if err != nil {
+		return nil, skymodules.SkynetServingProof{}, errors.AddContext(err, "unable to download skylink with serving proof")
+	}
+	proofStr := header.Get(api.SkynetServingProofHeader)
+	if proofStr == "" {
+		proofStr = trailer.Get(api.SkynetServingProofHeader)
+	}
+	var proof skymodules.SkynetServingProof
+	err = json.Unmarshal([]byte(proofStr), &proof)
+	if err != nil {
+		return nil, skymodules.SkynetServingProof{}, errors.AddContext(err, "unable to parse serving proof")
+	}
+	return fileData, proof, nil
+}
+
+// SkynetSkylinkGetWithBandwidth uses the /skynet/skylink endpoint to download
+// a skylink file together with the host bandwidth that was consumed to serve
+// it.
+func (c *Client) SkynetSkylinkGetWithBandwidth(skylink string) ([]byte, uint64, error) {
+	values := url.Values{}
+	values.Set("include-bandwidth", "true")
+	getQuery := skylinkQueryWithValues(skylink, values)
+	_, trailer, fileData, err := c.getRawResponseWithTrailer(getQuery, nil)
+	if err != nil {
+		return nil, 0, errors.AddContext(err, "unable to download skylink with bandwidth")
+	}
+	bandwidth, err := strconv.ParseUint(trailer.Get(api.SkynetBandwidthUsedTrailer), 10, 64)
+	if err != nil {
+		return nil, 0, errors.AddContext(err, "unable to parse bandwidth trailer")
+	}
+	return fileData, bandwidth, nil
+}
+
+// SkynetSkylinkPartialGet uses the /skynet/skylink endpoint to download a
+// skylink file in the given format while allowing for a partial response. The
+// ranges which couldn't be recovered are returned together with the data.
+func (c *Client) SkynetSkylinkPartialGet(skylink string, format skymodules.SkyfileFormat) ([]byte, []skymodules.SkynetMissingRange, error) {
+	values := url.Values{}
+	values.Set("allow-partial", "true")
+	if format != skymodules.SkyfileFormatNotSpecified {
+		values.Set("format", string(format))
+	}
+	getQuery := skylinkQueryWithValues(skylink, values)
+	header, fileData, err := c.getRawResponse(getQuery)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to download skylink with allow-partial")
+	}
+	var missing []skymodules.SkynetMissingRange
+	if str := header.Get(api.SkynetMissingRangesHeader); str != "" {
+		err = json.Unmarshal([]byte(str), &missing)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to decode missing ranges")
+		}
+	}
+	return fileData, missing, nil
+}
+
 // skynetSkylinkGetWithParameters uses the /skynet/skylink endpoint to download
 // a skylink file, specifying the given parameters.
 // The caller of this function is responsible for validating the parameters!
@@ -361,6 +618,15 @@ func (c *Client) SkynetSkylinkHeadWithParameters(skylink string, values url.Valu
 	return c.head(getQuery)
 }
 
+// SkynetSkylinkIndexGet uses the /skynet/skylink endpoint to fetch a listing
+// of the files of a skylink with the 'index' format specified.
+func (c *Client) SkynetSkylinkIndexGet(skylink string) (index api.SkynetSkylinkIndexGET, err error) {
+	values := url.Values{}
+	values.Set("format", string(skymodules.SkyfileFormatIndex))
+	err = c.get(skylinkQueryWithValues(skylink, values), &index)
+	return
+}
+
 // SkynetSkylinkConcatGet uses the /skynet/skylink endpoint to download a
 // skylink file with the 'concat' format specified.
 func (c *Client) SkynetSkylinkConcatGet(skylink string) ([]byte, error) {
@@ -385,6 +651,26 @@ func (c *Client) SkynetSkylinkConcatGet(skylink string) ([]byte, error) {
 	return fileData, errors.AddContext(err, "unable to fetch skylink data")
 }
 
+// SkynetSkylinkDataURIGet uses the /skynet/skylink endpoint to download a
+// skylink's content as a data URI.
+func (c *Client) SkynetSkylinkDataURIGet(skylink string) (_ string, err error) {
+	values := url.Values{}
+	values.Set("format", string(skymodules.SkyfileFormatDataURI))
+	getQuery := skylinkQueryWithValues(skylink, values)
+	_, body, err := c.getReaderResponse(getQuery)
+	if err != nil {
+		return "", errors.AddContext(err, "error fetching api response for GET with format=datauri")
+	}
+	defer func() {
+		err = errors.Compose(err, body.Close())
+	}()
+	dataURI, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", errors.AddContext(err, "unable to read data URI from reader")
+	}
+	return string(dataURI), nil
+}
+
 // SkynetSkylinkBackup uses the /skynet/skylink endpoint to fetch the Skyfile's
 // basesector, and reader for large Skyfiles, and writes it to the backupDst
 // writer.
@@ -512,6 +798,20 @@ func (c *Client) SkynetSkylinkRestorePost(backup io.Reader) (string, error) {
 	return srp.Skylink, nil
 }
 
+// SkynetBackupArchiveGet uses the /skynet/backup endpoint to fetch a reader of
+// the backup archive of a skyfile.
+func (c *Client) SkynetBackupArchiveGet(skylink string) (io.ReadCloser, error) {
+	_, reader, err := c.getReaderResponse(fmt.Sprintf("/skynet/backup/%s", skylink))
+	return reader, errors.AddContext(err, "unable to fetch backup archive")
+}
+
+// SkynetBackupArchiveRangeGet uses the /skynet/backup endpoint to fetch the
+// given range of the backup archive of a skyfile.
+func (c *Client) SkynetBackupArchiveRangeGet(skylink string, from, to uint64) ([]byte, error) {
+	data, err := c.getRawPartialResponse(fmt.Sprintf("/skynet/backup/%s", skylink), from, to)
+	return data, errors.AddContext(err, "unable to fetch backup archive range")
+}
+
 // SkynetSkylinkReaderGet uses the /skynet/skylink endpoint to fetch a reader of
 // the file data.
 func (c *Client) SkynetSkylinkReaderGet(skylink string) (io.ReadCloser, error) {
@@ -557,22 +857,119 @@ func (c *Client) SkynetSkylinkZipReaderGet(skylink string) (http.Header, io.Read
 
 // SkynetSkylinkPinPost uses the /skynet/pin endpoint to pin the file at the
 // given skylink.
-func (c *Client) SkynetSkylinkPinPost(skylink string, spp skymodules.SkyfilePinParameters) error {
+func (c *Client) SkynetSkylinkPinPost(skylink string, spp skymodules.SkyfilePinParameters) (api.SkynetPinHandlerPOST, error) {
 	return c.SkynetSkylinkPinPostWithTimeout(skylink, spp, api.DefaultSkynetRequestTimeout)
 }
 
 // SkynetSkylinkPinPostWithTimeout uses the /skynet/pin endpoint to pin the file
 // at the given skylink, specifying the given timeout.
-func (c *Client) SkynetSkylinkPinPostWithTimeout(skylink string, spp skymodules.SkyfilePinParameters, timeout time.Duration) error {
+func (c *Client) SkynetSkylinkPinPostWithTimeout(skylink string, spp skymodules.SkyfilePinParameters, timeout time.Duration) (api.SkynetPinHandlerPOST, error) {
 	values := urlValuesFromSkyfilePinParameters(spp)
 	values.Set("timeout", fmt.Sprintf("%d", uint64(timeout.Seconds())))
 
 	query := fmt.Sprintf("/skynet/pin/%s?%s", skylink, values.Encode())
-	_, _, err := c.postRawResponse(query, nil)
+	_, resp, err := c.postRawResponse(query, nil)
 	if err != nil {
-		return errors.AddContext(err, "post call to "+query+" failed")
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
 	}
-	return nil
+
+	// Parse the response.
+	var sphp api.SkynetPinHandlerPOST
+	err = json.Unmarshal(resp, &sphp)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "unable to parse the pin response")
+	}
+	return sphp, nil
+}
+
+// SkynetSkylinkPinPostWithForceToken uses the /skynet/pin endpoint to pin the
+// file at the given skylink with the Skynet-Disable-Force header set. The given
+// force token allows for forcing the pin anyway.
+func (c *Client) SkynetSkylinkPinPostWithForceToken(skylink string, spp skymodules.SkyfilePinParameters, token skymodules.SkynetForceToken) (api.SkynetPinHandlerPOST, error) {
+	tokenStr, err := json.Marshal(token)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "failed to marshal force token")
+	}
+	headers := http.Header{
+		api.SkynetDisableForceHeader: []string{strconv.FormatBool(true)},
+		api.SkynetForceTokenHeader:   []string{string(tokenStr)},
+	}
+	values := urlValuesFromSkyfilePinParameters(spp)
+	query := fmt.Sprintf("/skynet/pin/%s?%s", skylink, values.Encode())
+	_, resp, err := c.postRawResponseWithHeaders(query, nil, headers)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response.
+	var sphp api.SkynetPinHandlerPOST
+	err = json.Unmarshal(resp, &sphp)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "unable to parse the pin response")
+	}
+	return sphp, nil
+}
+
+// SkynetPinFromPost uses the /skynet/pinfrom endpoint to pin the file at the
+// given skylink by fetching it from the given portal.
+func (c *Client) SkynetPinFromPost(skylink, portal string, spp skymodules.SkyfilePinParameters) (api.SkynetPinHandlerPOST, error) {
+	values := urlValuesFromSkyfilePinParameters(spp)
+	values.Set("portal", portal)
+
+	query := fmt.Sprintf("/skynet/pinfrom/%s?%s", skylink, values.Encode())
+	_, resp, err := c.postRawResponse(query, nil)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response.
+	var sphp api.SkynetPinHandlerPOST
+	err = json.Unmarshal(resp, &sphp)
+	if err != nil {
+		return api.SkynetPinHandlerPOST{}, errors.AddContext(err, "unable to parse the pin response")
+	}
+	return sphp, nil
+}
+
+// SkynetPinManifestPost uses the /skynet/pin/manifest endpoint to pin all the
+// skylinks listed in the manifest.
+func (c *Client) SkynetPinManifestPost(manifest []byte) (api.SkynetPinManifestSummary, []api.SkynetPinManifestProgress, error) {
+	return c.skynetPinManifestPost("/skynet/pin/manifest", bytes.NewReader(manifest))
+}
+
+// SkynetPinManifestSkylinkPost uses the /skynet/pin/manifest endpoint to pin
+// all the skylinks listed in the manifest stored at the given skylink.
+func (c *Client) SkynetPinManifestSkylinkPost(skylink string) (api.SkynetPinManifestSummary, []api.SkynetPinManifestProgress, error) {
+	values := url.Values{}
+	values.Set("skylink", skylink)
+	return c.skynetPinManifestPost(fmt.Sprintf("/skynet/pin/manifest?%s", values.Encode()), nil)
+}
+
+// skynetPinManifestPost is a helper for pinning manifests which parses the
+// streamed response.
+func (c *Client) skynetPinManifestPost(query string, body io.Reader) (summary api.SkynetPinManifestSummary, progress []api.SkynetPinManifestProgress, err error) {
+	headers := http.Header{"Content-Type": []string{"text/plain"}}
+	_, resp, err := c.postRawResponseWithHeaders(query, body, headers)
+	if err != nil {
+		return api.SkynetPinManifestSummary{}, nil, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// The response starts with the summary followed by the progress of every
+	// pin.
+	dec := json.NewDecoder(bytes.NewReader(resp))
+	err = dec.Decode(&summary)
+	if err != nil {
+		return api.SkynetPinManifestSummary{}, nil, errors.AddContext(err, "unable to parse the summary")
+	}
+	for dec.More() {
+		var p api.SkynetPinManifestProgress
+		err = dec.Decode(&p)
+		if err != nil {
+			return api.SkynetPinManifestSummary{}, nil, errors.AddContext(err, "unable to parse the pin progress")
+		}
+		progress = append(progress, p)
+	}
+	return summary, progress, nil
 }
 
 // SkynetSkyfilePost uses the /skynet/skyfile endpoint to upload a skyfile.  The
@@ -598,23 +995,17 @@ func (c *Client) SkynetSkyfilePost(sup skymodules.SkyfileUploadParameters) (stri
 	return rshp.Skylink, rshp, err
 }
 
-// SkynetSkyfilePostDisableForce uses the /skynet/skyfile endpoint to upload a
-// skyfile. This method allows to set the Disable-Force header. The resulting
-// skylink is returned along with an error.
-func (c *Client) SkynetSkyfilePostDisableForce(sup skymodules.SkyfileUploadParameters, disableForce bool) (string, api.SkynetSkyfileHandlerPOST, error) {
-	// Set the headers
-	headers := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
-	if disableForce {
-		headers.Add(api.SkynetDisableForceHeader, strconv.FormatBool(disableForce))
-	}
-
-	// Make the call to upload the file.
-	encodedValues, err := urlEncodeSkyfileUploadParameters(sup)
+// SkynetSkyfileRedirectPost uses the /skynet/skyfile endpoint to upload a
+// skyfile which doesn't contain any data but redirects to the given skylink.
+// The reader of the upload parameters is ignored.
+func (c *Client) SkynetSkyfileRedirectPost(sup skymodules.SkyfileUploadParameters, skylink string) (string, api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
 	if err != nil {
 		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
 	}
-	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), encodedValues)
-	_, resp, err := c.postRawResponseWithHeaders(query, sup.Reader, headers)
+	values.Set("redirect", strconv.FormatBool(true))
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
+	_, resp, err := c.postRawResponse(query, strings.NewReader(skylink))
 	if err != nil {
 		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
 	}
@@ -625,29 +1016,21 @@ func (c *Client) SkynetSkyfilePostDisableForce(sup skymodules.SkyfileUploadParam
 	if err != nil {
 		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
 	}
-	return rshp.Skylink, rshp, err
-}
-
-// SkynetSkyfileMultiPartPost uses the /skynet/skyfile endpoint to upload a
-// skyfile using multipart form data.  The resulting skylink is returned along
-// with an error.
-func (c *Client) SkynetSkyfileMultiPartPost(smup skymodules.SkyfileMultipartUploadParameters) (string, api.SkynetSkyfileHandlerPOST, error) {
-	return c.SkynetSkyfileMultiPartEncryptedPost(smup, "", skykey.SkykeyID{})
+	return rshp.Skylink, rshp, nil
 }
 
-// SkynetSkyfileMultiPartEncryptedPost uses the /skynet/skyfile endpoint to
-// upload a skyfile using multipart form data with Skykey params.  The resulting
-// skylink is returned along with an error.
-func (c *Client) SkynetSkyfileMultiPartEncryptedPost(smup skymodules.SkyfileMultipartUploadParameters, skykeyName string, skykeyID skykey.SkykeyID) (string, api.SkynetSkyfileHandlerPOST, error) {
-	// Make the call to upload the file.
-	values, err := urlValuesFromSkyfileMultipartUploadParameters(smup)
+// SkynetSkyfilePostIfExists uses the /skynet/skyfile endpoint to upload a
+// skyfile with 'ifexists=return'. If there already is a skyfile with the same
+// content at the siapath, its skylink is returned instead of an error.
+func (c *Client) SkynetSkyfilePostIfExists(sup skymodules.SkyfileUploadParameters) (string, api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
 	if err != nil {
-		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to get url values")
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
 	}
-	query := fmt.Sprintf("/skynet/skyfile/%s?%s", smup.SiaPath.String(), values.Encode())
-
-	headers := http.Header{"Content-Type": []string{smup.ContentType}}
-	_, resp, err := c.postRawResponseWithHeaders(query, smup.Reader, headers)
+	values.Del("force")
+	values.Set("ifexists", "return")
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
+	_, resp, err := c.postRawResponse(query, sup.Reader)
 	if err != nil {
 		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
 	}
@@ -658,61 +1041,508 @@ func (c *Client) SkynetSkyfileMultiPartEncryptedPost(smup skymodules.SkyfileMult
 	if err != nil {
 		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
 	}
-	return rshp.Skylink, rshp, err
+	return rshp.Skylink, rshp, nil
 }
 
-// SkynetConvertSiafileToSkyfilePost uses the /skynet/skyfile endpoint to
-// convert an existing siafile to a skyfile. The input SiaPath 'convert' is the
-// siapath of the siafile that should be converted. The siapath provided inside
-// of the upload params is the name that will be used for the base sector of the
-// skyfile.
-func (c *Client) SkynetConvertSiafileToSkyfilePost(sup skymodules.SkyfileUploadParameters, convert skymodules.SiaPath) (api.SkynetSkyfileHandlerPOST, error) {
+// SkynetSkyfilePostWithHint uses the /skynet/skyfile endpoint to upload a
+// skyfile and requests an early skylink hint. The hinted skylink is returned
+// together with the final response. If no hint was received, the hint is
+// empty.
+func (c *Client) SkynetSkyfilePostWithHint(sup skymodules.SkyfileUploadParameters) (string, api.SkynetSkyfileHandlerPOST, error) {
 	values, err := urlValuesFromSkyfileUploadParameters(sup)
 	if err != nil {
-		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to get url values")
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
 	}
-	values.Add("convertpath", convert.String())
-
-	// Make the call to upload the file.
+	values.Set("skylinkhint", strconv.FormatBool(true))
 	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
-	_, resp, err := c.postRawResponse(query, sup.Reader)
+	req, err := c.NewRequest("POST", query, sup.Reader)
 	if err != nil {
-		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to construct POST request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Capture the hint from the informational response.
+	var hint string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hint = header.Get(api.SkynetSkylinkHintHeader)
+			}
+			return nil
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	httpClient := http.Client{CheckRedirect: c.CheckRedirect}
+	// nolint:bodyclose // body is closed by drainAndClose
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "POST request failed")
+	}
+	defer drainAndClose(res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return hint, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(readAPIError(res.Body), "POST request error")
 	}
 
 	// Parse the response to get the skylink.
 	var rshp api.SkynetSkyfileHandlerPOST
-	err = json.Unmarshal(resp, &rshp)
+	err = json.NewDecoder(res.Body).Decode(&rshp)
 	if err != nil {
-		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+		return hint, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
 	}
-	return rshp, nil
-}
-
-// SkynetBlocklistGet requests the /skynet/blocklist Get endpoint
-func (c *Client) SkynetBlocklistGet() (blocklist api.SkynetBlocklistGET, err error) {
-	err = c.get("/skynet/blocklist", &blocklist)
-	return
+	return hint, rshp, nil
 }
 
-// SkynetBlocklistHashPost requests the /skynet/blocklist Post endpoint
-func (c *Client) SkynetBlocklistHashPost(additions, removals []string, isHash bool) (err error) {
-	sbp := api.SkynetBlocklistPOST{
-		Add:    additions,
-		Remove: removals,
-		IsHash: isHash,
+// SkynetSkyfilePostExpectContinue uses the /skynet/skyfile endpoint to upload
+// a skyfile with the 'Expect: 100-continue' header set. Apart from the upload
+// response it returns whether the server responded with '100 Continue' which
+// means that the body was transmitted.
+func (c *Client) SkynetSkyfilePostExpectContinue(sup skymodules.SkyfileUploadParameters) (bool, api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
+	if err != nil {
+		return false, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
 	}
-	data, err := json.Marshal(sbp)
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
+	req, err := c.NewRequest("POST", query, sup.Reader)
 	if err != nil {
-		return err
+		return false, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to construct POST request")
 	}
-	err = c.post("/skynet/blocklist", string(data), nil)
-	return
-}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Expect", "100-continue")
 
-// SkynetBlocklistPost requests the /skynet/blocklist Post endpoint
-func (c *Client) SkynetBlocklistPost(additions, removals []string) (err error) {
-	err = c.SkynetBlocklistHashPost(additions, removals, false)
+	// Keep track of whether the server asked for the body.
+	var continued bool
+	trace := &httptrace.ClientTrace{
+		Got100Continue: func() {
+			continued = true
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	// Use a transport which waits for the server's response before sending
+	// the body.
+	transport := &http.Transport{
+		ExpectContinueTimeout: time.Minute,
+	}
+	defer transport.CloseIdleConnections()
+	httpClient := http.Client{CheckRedirect: c.CheckRedirect, Transport: transport}
+	// nolint:bodyclose // body is closed by drainAndClose
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return continued, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "POST request failed")
+	}
+	defer drainAndClose(res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return continued, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(readAPIError(res.Body), "POST request error")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.NewDecoder(res.Body).Decode(&rshp)
+	if err != nil {
+		return continued, api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return continued, rshp, nil
+}
+
+// SkynetSkyfilePostDisableForce uses the /skynet/skyfile endpoint to upload a
+// skyfile. This method allows to set the Disable-Force header. The resulting
+// skylink is returned along with an error.
+func (c *Client) SkynetSkyfilePostDisableForce(sup skymodules.SkyfileUploadParameters, disableForce bool) (string, api.SkynetSkyfileHandlerPOST, error) {
+	// Set the headers
+	headers := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+	if disableForce {
+		headers.Add(api.SkynetDisableForceHeader, strconv.FormatBool(disableForce))
+	}
+
+	// Make the call to upload the file.
+	encodedValues, err := urlEncodeSkyfileUploadParameters(sup)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
+	}
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), encodedValues)
+	_, resp, err := c.postRawResponseWithHeaders(query, sup.Reader, headers)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return rshp.Skylink, rshp, err
+}
+
+// SkynetSkyfilePostWithForceToken uses the /skynet/skyfile endpoint to upload
+// a skyfile with the Skynet-Disable-Force header set. The given force token
+// allows for forcing the upload anyway.
+func (c *Client) SkynetSkyfilePostWithForceToken(sup skymodules.SkyfileUploadParameters, token skymodules.SkynetForceToken) (string, api.SkynetSkyfileHandlerPOST, error) {
+	tokenStr, err := json.Marshal(token)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to marshal force token")
+	}
+	headers := http.Header{
+		"Content-Type":               []string{"application/x-www-form-urlencoded"},
+		api.SkynetDisableForceHeader: []string{strconv.FormatBool(true)},
+		api.SkynetForceTokenHeader:   []string{string(tokenStr)},
+	}
+
+	// Make the call to upload the file.
+	encodedValues, err := urlEncodeSkyfileUploadParameters(sup)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
+	}
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), encodedValues)
+	_, resp, err := c.postRawResponseWithHeaders(query, sup.Reader, headers)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return rshp.Skylink, rshp, nil
+}
+
+// SkynetSkyfileMultiPartPost uses the /skynet/skyfile endpoint to upload a
+// skyfile using multipart form data.  The resulting skylink is returned along
+// with an error.
+func (c *Client) SkynetSkyfileMultiPartPost(smup skymodules.SkyfileMultipartUploadParameters) (string, api.SkynetSkyfileHandlerPOST, error) {
+	return c.SkynetSkyfileMultiPartEncryptedPost(smup, "", skykey.SkykeyID{})
+}
+
+// SkynetSkyfileMultiPartEncryptedPost uses the /skynet/skyfile endpoint to
+// upload a skyfile using multipart form data with Skykey params.  The resulting
+// skylink is returned along with an error.
+func (c *Client) SkynetSkyfileMultiPartEncryptedPost(smup skymodules.SkyfileMultipartUploadParameters, skykeyName string, skykeyID skykey.SkykeyID) (string, api.SkynetSkyfileHandlerPOST, error) {
+	// Make the call to upload the file.
+	values, err := urlValuesFromSkyfileMultipartUploadParameters(smup)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to get url values")
+	}
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", smup.SiaPath.String(), values.Encode())
+
+	headers := http.Header{"Content-Type": []string{smup.ContentType}}
+	_, resp, err := c.postRawResponseWithHeaders(query, smup.Reader, headers)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return rshp.Skylink, rshp, err
+}
+
+// SkynetSkyfileMultiPartPostResumable uses the /skynet/skyfile endpoint to
+// upload a skyfile using multipart form data which can be resumed under the
+// given session if it fails. The hashes of the parts are declared with the
+// given part hashes.
+func (c *Client) SkynetSkyfileMultiPartPostResumable(smup skymodules.SkyfileMultipartUploadParameters, session string, partHashes map[string]crypto.Hash) (string, api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileMultipartUploadParameters(smup)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to get url values")
+	}
+	hashes, err := json.Marshal(partHashes)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to marshal part hashes")
+	}
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", smup.SiaPath.String(), values.Encode())
+	headers := http.Header{
+		"Content-Type":                []string{smup.ContentType},
+		api.SkynetUploadSessionHeader: []string{session},
+		api.SkynetPartHashesHeader:    []string{string(hashes)},
+	}
+	_, resp, err := c.postRawResponseWithHeaders(query, smup.Reader, headers)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return "", api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return rshp.Skylink, rshp, nil
+}
+
+// SkynetSkyfilePostWithMetadata uses the /skynet/skyfile endpoint to upload
+// a skyfile with 'include-metadata'. The response contains the metadata and
+// layout of the skyfile.
+func (c *Client) SkynetSkyfilePostWithMetadata(sup skymodules.SkyfileUploadParameters) (api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to encode url values")
+	}
+	values.Set("include-metadata", strconv.FormatBool(true))
+	headers := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+	return c.skynetSkyfilePostWithMetadata(sup.SiaPath, values, sup.Reader, headers)
+}
+
+// SkynetSkyfileMultiPartPostWithMetadata uses the /skynet/skyfile endpoint to
+// upload a skyfile using multipart form data with 'include-metadata'. The
+// response contains the metadata and layout of the skyfile.
+func (c *Client) SkynetSkyfileMultiPartPostWithMetadata(smup skymodules.SkyfileMultipartUploadParameters) (api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileMultipartUploadParameters(smup)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to get url values")
+	}
+	values.Set("include-metadata", strconv.FormatBool(true))
+	headers := http.Header{"Content-Type": []string{smup.ContentType}}
+	return c.skynetSkyfilePostWithMetadata(smup.SiaPath, values, smup.Reader, headers)
+}
+
+// skynetSkyfilePostWithMetadata is a helper for uploads with
+// 'include-metadata' which verifies that the response contains the metadata.
+func (c *Client) skynetSkyfilePostWithMetadata(siaPath skymodules.SiaPath, values url.Values, body io.Reader, headers http.Header) (api.SkynetSkyfileHandlerPOST, error) {
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", siaPath.String(), values.Encode())
+	_, resp, err := c.postRawResponseWithHeaders(query, body, headers)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	if rshp.Metadata == nil || rshp.Layout == nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.New("response is missing the metadata")
+	}
+	return rshp, nil
+}
+
+// SkynetConvertSiafileToSkyfilePost uses the /skynet/skyfile endpoint to
+// convert an existing siafile to a skyfile. The input SiaPath 'convert' is the
+// siapath of the siafile that should be converted. The siapath provided inside
+// of the upload params is the name that will be used for the base sector of the
+// skyfile.
+func (c *Client) SkynetConvertSiafileToSkyfilePost(sup skymodules.SkyfileUploadParameters, convert skymodules.SiaPath) (api.SkynetSkyfileHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to get url values")
+	}
+	values.Add("convertpath", convert.String())
+
+	// Make the call to upload the file.
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
+	_, resp, err := c.postRawResponse(query, sup.Reader)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the skylink.
+	var rshp api.SkynetSkyfileHandlerPOST
+	err = json.Unmarshal(resp, &rshp)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to parse the skylink upload response")
+	}
+	return rshp, nil
+}
+
+// SkynetConvertSiafileToSkyfileAsyncPost uses the /skynet/skyfile endpoint to
+// start an asynchronous conversion of an existing siafile to a skyfile. The
+// returned ID can be used to query the conversion's status or to cancel it.
+func (c *Client) SkynetConvertSiafileToSkyfileAsyncPost(sup skymodules.SkyfileUploadParameters, convert skymodules.SiaPath) (api.SkynetConvertHandlerPOST, error) {
+	values, err := urlValuesFromSkyfileUploadParameters(sup)
+	if err != nil {
+		return api.SkynetConvertHandlerPOST{}, errors.AddContext(err, "unable to get url values")
+	}
+	values.Add("convertpath", convert.String())
+	values.Add("async", "true")
+
+	// Make the call to start the conversion.
+	query := fmt.Sprintf("/skynet/skyfile/%s?%s", sup.SiaPath.String(), values.Encode())
+	_, resp, err := c.postRawResponse(query, sup.Reader)
+	if err != nil {
+		return api.SkynetConvertHandlerPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+
+	// Parse the response to get the conversion ID.
+	var schp api.SkynetConvertHandlerPOST
+	err = json.Unmarshal(resp, &schp)
+	if err != nil {
+		return api.SkynetConvertHandlerPOST{}, errors.AddContext(err, "unable to parse the conversion response")
+	}
+	return schp, nil
+}
+
+// SkynetConvertStatusGet requests the /skynet/convert/status/:id GET endpoint.
+func (c *Client) SkynetConvertStatusGet(id string) (status skymodules.SkyfileConversionStatus, err error) {
+	err = c.get("/skynet/convert/status/"+id, &status)
+	return
+}
+
+// SkynetConvertCancelPost requests the /skynet/convert/cancel/:id POST
+// endpoint.
+func (c *Client) SkynetConvertCancelPost(id string) error {
+	return c.post("/skynet/convert/cancel/"+id, "", nil)
+}
+
+// SkynetUploadBeginPost requests the /skynet/upload/begin POST endpoint. It
+// uploads the data of a skyfile and returns the ID of the pending upload. The
+// siapath, root, force and erasure coding settings of the upload parameters are
+// passed along.
+func (c *Client) SkynetUploadBeginPost(sup skymodules.SkyfileUploadParameters, data io.Reader) (subp api.SkynetUploadBeginPOST, err error) {
+	values := url.Values{}
+	if !sup.SiaPath.IsEmpty() {
+		values.Set("siapath", sup.SiaPath.String())
+		values.Set("root", "true")
+	}
+	values.Set("force", fmt.Sprint(sup.Force))
+	if sup.BaseChunkRedundancy > 0 {
+		values.Set("basechunkredundancy", fmt.Sprint(sup.BaseChunkRedundancy))
+	}
+	if sup.DataPieces > 0 {
+		values.Set("datapieces", fmt.Sprint(sup.DataPieces))
+	}
+	if sup.ParityPieces > 0 {
+		values.Set("paritypieces", fmt.Sprint(sup.ParityPieces))
+	}
+	query := fmt.Sprintf("/skynet/upload/begin?%s", values.Encode())
+	_, resp, err := c.postRawResponse(query, data)
+	if err != nil {
+		return api.SkynetUploadBeginPOST{}, errors.AddContext(err, "post call to "+query+" failed")
+	}
+	err = json.Unmarshal(resp, &subp)
+	if err != nil {
+		return api.SkynetUploadBeginPOST{}, errors.AddContext(err, "unable to parse the upload begin response")
+	}
+	return
+}
+
+// SkynetUploadFinalizePost requests the /skynet/upload/finalize/:id POST
+// endpoint to create the skyfile of a pending upload with the given metadata.
+func (c *Client) SkynetUploadFinalizePost(id string, md skymodules.SkyfileMetadata) (rshp api.SkynetSkyfileHandlerPOST, err error) {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return api.SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "unable to marshal metadata")
+	}
+	err = c.post("/skynet/upload/finalize/"+id, string(data), &rshp)
+	return
+}
+
+// SkynetPrefetchPost requests the /skynet/prefetch/:skylink POST endpoint. The
+// depth is either "base" or "full".
+func (c *Client) SkynetPrefetchPost(skylink string, depth string) (sphp api.SkynetPrefetchHandlerPOST, err error) {
+	values := url.Values{}
+	values.Set("depth", depth)
+	query := fmt.Sprintf("/skynet/prefetch/%s?%s", skylink, values.Encode())
+	err = c.post(query, "", &sphp)
+	return
+}
+
+// SkynetPrefetchStatusGet requests the /skynet/prefetch/status/:id GET
+// endpoint.
+func (c *Client) SkynetPrefetchStatusGet(id string) (status skymodules.SkylinkPrefetchStatus, err error) {
+	err = c.get("/skynet/prefetch/status/"+id, &status)
+	return
+}
+
+// SkynetAllowlistGet requests the /skynet/allowlist Get endpoint
+func (c *Client) SkynetAllowlistGet() (allowlist api.SkynetAllowlistGET, err error) {
+	err = c.get("/skynet/allowlist", &allowlist)
+	return
+}
+
+// SkynetAllowlistHashPost requests the /skynet/allowlist Post endpoint
+func (c *Client) SkynetAllowlistHashPost(additions, removals []string, isHash bool) (err error) {
+	sap := api.SkynetAllowlistPOST{
+		Add:    additions,
+		Remove: removals,
+		IsHash: isHash,
+	}
+	data, err := json.Marshal(sap)
+	if err != nil {
+		return err
+	}
+	err = c.post("/skynet/allowlist", string(data), nil)
+	return
+}
+
+// SkynetAllowlistPost requests the /skynet/allowlist Post endpoint
+func (c *Client) SkynetAllowlistPost(additions, removals []string) (err error) {
+	err = c.SkynetAllowlistHashPost(additions, removals, false)
+	return
+}
+
+// SkynetCanonicalizeGet requests the /skynet/canonicalize Get endpoint.
+func (c *Client) SkynetCanonicalizeGet(skylink string) (sc api.SkynetCanonicalizeGET, err error) {
+	err = c.get("/skynet/canonicalize/"+skylink, &sc)
+	return
+}
+
+// SkynetBlocklistGet requests the /skynet/blocklist Get endpoint
+func (c *Client) SkynetBlocklistGet() (blocklist api.SkynetBlocklistGET, err error) {
+	err = c.get("/skynet/blocklist", &blocklist)
+	return
+}
+
+// SkynetBlocklistCompatGet requests the /skynet/blocklist Get endpoint with
+// the given compat version.
+func (c *Client) SkynetBlocklistCompatGet(compat string) (blocklist api.SkynetBlocklistGET, err error) {
+	values := url.Values{}
+	values.Set("compat", compat)
+	err = c.get("/skynet/blocklist?"+values.Encode(), &blocklist)
+	return
+}
+
+// SkynetBlacklistGet requests the deprecated /skynet/blacklist Get endpoint.
+func (c *Client) SkynetBlacklistGet() (header http.Header, blocklist api.SkynetBlocklistGET, err error) {
+	header, body, err := c.getRawResponse("/skynet/blacklist")
+	if err != nil {
+		return nil, api.SkynetBlocklistGET{}, err
+	}
+	err = json.Unmarshal(body, &blocklist)
+	return
+}
+
+// SkynetBlocklistHitsGet requests the /skynet/blocklist/hits Get endpoint
+func (c *Client) SkynetBlocklistHitsGet() (hits api.SkynetBlocklistHitsGET, err error) {
+	err = c.get("/skynet/blocklist/hits", &hits)
+	return
+}
+
+// SkynetTraceGet requests the /skynet/trace Get endpoint
+func (c *Client) SkynetTraceGet(traceID string) (stg api.SkynetTraceGET, err error) {
+	err = c.get("/skynet/trace/"+traceID, &stg)
+	return
+}
+
+// SkynetBlocklistHashPost requests the /skynet/blocklist Post endpoint
+func (c *Client) SkynetBlocklistHashPost(additions, removals []string, isHash bool) (err error) {
+	sbp := api.SkynetBlocklistPOST{
+		Add:    additions,
+		Remove: removals,
+		IsHash: isHash,
+	}
+	data, err := json.Marshal(sbp)
+	if err != nil {
+		return err
+	}
+	err = c.post("/skynet/blocklist", string(data), nil)
+	return
+}
+
+// SkynetBlocklistPost requests the /skynet/blocklist Post endpoint
+func (c *Client) SkynetBlocklistPost(additions, removals []string) (err error) {
+	err = c.SkynetBlocklistHashPost(additions, removals, false)
+	return
+}
+
+// SkynetPortalKeyGet requests the /skynet/portalkey GET endpoint.
+func (c *Client) SkynetPortalKeyGet() (spkg api.SkynetPortalKeyGET, err error) {
+	err = c.get("/skynet/portalkey", &spkg)
 	return
 }
 
@@ -736,12 +1566,105 @@ func (c *Client) SkynetPortalsPost(additions []skymodules.SkynetPortal, removals
 	return
 }
 
+// SkynetPinnedGet requests the /skynet/pinned Get endpoint
+func (c *Client) SkynetPinnedGet() (spg api.SkynetPinnedGET, err error) {
+	err = c.get("/skynet/pinned", &spg)
+	return
+}
+
 // SkynetStatsGet requests the /skynet/stats Get endpoint
 func (c *Client) SkynetStatsGet() (stats api.SkynetStatsGET, err error) {
 	err = c.get("/skynet/stats", &stats)
 	return
 }
 
+// SkynetUploadEstimatePost requests the /skynet/upload/estimate POST endpoint
+// to estimate the cost of uploading a skyfile of the given size. Zero values
+// for the redundancy use the renter's defaults.
+func (c *Client) SkynetUploadEstimatePost(size uint64, baseChunkRedundancy uint8, dataPieces, parityPieces int) (estimate skymodules.SkyfileUploadCostEstimate, err error) {
+	values := url.Values{}
+	values.Set("size", fmt.Sprint(size))
+	if baseChunkRedundancy > 0 {
+		values.Set("basechunkredundancy", fmt.Sprint(baseChunkRedundancy))
+	}
+	if dataPieces > 0 || parityPieces > 0 {
+		values.Set("datapieces", fmt.Sprint(dataPieces))
+		values.Set("paritypieces", fmt.Sprint(parityPieces))
+	}
+	err = c.post("/skynet/upload/estimate?"+values.Encode(), "", &estimate)
+	return
+}
+
+// SkynetPinEstimateGet requests the /skynet/pin/estimate GET endpoint to
+// estimate the cost of pinning the given skylink. A zero base chunk redundancy
+// uses the renter's default.
+func (c *Client) SkynetPinEstimateGet(skylink string, baseChunkRedundancy uint8) (estimate skymodules.SkylinkPinCostEstimate, err error) {
+	values := url.Values{}
+	if baseChunkRedundancy > 0 {
+		values.Set("basechunkredundancy", fmt.Sprint(baseChunkRedundancy))
+	}
+	err = c.get(fmt.Sprintf("/skynet/pin/estimate/%s?%s", skylink, values.Encode()), &estimate)
+	return
+}
+
+// SkynetMaintenanceGet requests the /skynet/maintenance GET endpoint.
+func (c *Client) SkynetMaintenanceGet() (maintenance skymodules.SkynetMaintenance, err error) {
+	err = c.get("/skynet/maintenance", &maintenance)
+	return
+}
+
+// SkynetMaintenancePost requests the /skynet/maintenance POST endpoint.
+func (c *Client) SkynetMaintenancePost(enabled bool, message string) (err error) {
+	data, err := json.Marshal(skymodules.SkynetMaintenance{
+		Enabled: enabled,
+		Message: message,
+	})
+	if err != nil {
+		return err
+	}
+	err = c.post("/skynet/maintenance", string(data), nil)
+	return
+}
+
+// SkynetUploadPolicyGet requests the /skynet/uploadpolicy GET endpoint.
+func (c *Client) SkynetUploadPolicyGet() (policy skymodules.SkynetUploadPolicy, err error) {
+	err = c.get("/skynet/uploadpolicy", &policy)
+	return
+}
+
+// SkynetUploadPolicyPost requests the /skynet/uploadpolicy POST endpoint.
+func (c *Client) SkynetUploadPolicyPost(policy skymodules.SkynetUploadPolicy) (err error) {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	err = c.post("/skynet/uploadpolicy", string(data), nil)
+	return
+}
+
+// SkynetUploadRateLimitGet requests the /skynet/uploadratelimit GET endpoint.
+func (c *Client) SkynetUploadRateLimitGet() (limit skymodules.SkynetUploadRateLimit, err error) {
+	err = c.get("/skynet/uploadratelimit", &limit)
+	return
+}
+
+// SkynetUploadRateLimitPost requests the /skynet/uploadratelimit POST
+// endpoint.
+func (c *Client) SkynetUploadRateLimitPost(limit skymodules.SkynetUploadRateLimit) (err error) {
+	data, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	err = c.post("/skynet/uploadratelimit", string(data), nil)
+	return
+}
+
+// SkynetWorkersGet requests the /skynet/workers GET endpoint.
+func (c *Client) SkynetWorkersGet() (swg api.SkynetWorkersGET, err error) {
+	err = c.get("/skynet/workers", &swg)
+	return
+}
+
 // SkykeyGetByName requests the /skynet/skykey Get endpoint using the key name.
 func (c *Client) SkykeyGetByName(name string) (skykey.Skykey, error) {
 	values := url.Values{}
@@ -799,6 +1722,89 @@ func (c *Client) SkykeyDeleteByNamePost(name string) error {
 	return c.post("/skynet/deleteskykey", values.Encode(), nil)
 }
 
+// SkynetBundlePost requests the /skynet/bundle POST endpoint to download the
+// given skylinks as a single archive of the given format.
+func (c *Client) SkynetBundlePost(entries []api.SkynetBundleEntry, format skymodules.SkyfileFormat) ([]byte, error) {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("format", string(format))
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	_, resp, err := c.postRawResponseWithHeaders("/skynet/bundle?"+values.Encode(), bytes.NewReader(body), headers)
+	return resp, err
+}
+
+// SkynetZipPost requests the /skynet/zip POST endpoint to download the given
+// skylinks as a single zip archive with inline error members.
+func (c *Client) SkynetZipPost(entries []api.SkynetBundleEntry) ([]byte, error) {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	_, resp, err := c.postRawResponseWithHeaders("/skynet/zip", bytes.NewReader(body), headers)
+	return resp, err
+}
+
+// SkynetDiffPost requests the /skynet/diff POST endpoint to compare the
+// subfiles of two skyfiles.
+func (c *Client) SkynetDiffPost(from, to string) (api.SkynetDiffPOST, error) {
+	values := url.Values{}
+	values.Set("from", from)
+	values.Set("to", to)
+
+	var sdp api.SkynetDiffPOST
+	err := c.post("/skynet/diff", values.Encode(), &sdp)
+	if err != nil {
+		return api.SkynetDiffPOST{}, err
+	}
+	return sdp, nil
+}
+
+// SkykeyExportPost requests the /skynet/skykey/export POST endpoint.
+func (c *Client) SkykeyExportPost(name, password string) (api.SkykeyExportPOST, error) {
+	values := url.Values{}
+	values.Set("name", name)
+	values.Set("password", password)
+
+	var skep api.SkykeyExportPOST
+	err := c.post("/skynet/skykey/export", values.Encode(), &skep)
+	if err != nil {
+		return api.SkykeyExportPOST{}, err
+	}
+	return skep, nil
+}
+
+// SkykeyImportPost requests the /skynet/skykey/import POST endpoint.
+func (c *Client) SkykeyImportPost(export, password string) (skykey.Skykey, error) {
+	values := url.Values{}
+	values.Set("export", export)
+	values.Set("password", password)
+
+	var skykeyGet api.SkykeyGET
+	err := c.post("/skynet/skykey/import", values.Encode(), &skykeyGet)
+	if err != nil {
+		return skykey.Skykey{}, err
+	}
+
+	var sk skykey.Skykey
+	err = sk.FromString(skykeyGet.Skykey)
+	if err != nil {
+		return skykey.Skykey{}, err
+	}
+	return sk, nil
+}
+
+// SkykeyRenamePost requests the /skynet/skykeys/rename POST endpoint.
+func (c *Client) SkykeyRenamePost(oldName, newName string) error {
+	values := url.Values{}
+	values.Set("oldname", oldName)
+	values.Set("newname", newName)
+	return c.post("/skynet/skykeys/rename", values.Encode(), nil)
+}
+
 // SkykeyCreateKeyPost requests the /skynet/createskykey POST endpoint.
 func (c *Client) SkykeyCreateKeyPost(name string, skType skykey.SkykeyType) (skykey.Skykey, error) {
 	// Set the url values.
@@ -855,12 +1861,51 @@ func (c *Client) SkykeySkykeysGet() ([]skykey.Skykey, error) {
 	return res, nil
 }
 
+// SkykeysMatchGet requests the /skynet/skykeys/match/:skylink GET endpoint.
+func (c *Client) SkykeysMatchGet(skylink string) (api.SkykeyMatchGET, error) {
+	var smg api.SkykeyMatchGET
+	err := c.get(fmt.Sprintf("/skynet/skykeys/match/%s", skylink), &smg)
+	return smg, err
+}
+
 // SkylinkHealthGET queries the /skynet/health/skylink/:skylink endpoint.
 func (c *Client) SkylinkHealthGET(sl skymodules.Skylink) (sh skymodules.SkylinkHealth, err error) {
 	err = c.get(fmt.Sprintf("/skynet/health/skylink/%s", sl.String()), &sh)
 	return
 }
 
+// SkynetManifestGet queries the /skynet/manifest/:skylink endpoint.
+func (c *Client) SkynetManifestGet(skylink string, hashes bool) (smg api.SkynetManifestGET, err error) {
+	values := url.Values{}
+	values.Set("hashes", fmt.Sprint(hashes))
+	err = c.get(fmt.Sprintf("/skynet/manifest/%s?%s", skylink, values.Encode()), &smg)
+	return
+}
+
+// SkylinkEncodingGET queries the /skynet/debug/encoding/:skylink endpoint.
+func (c *Client) SkylinkEncodingGET(sl skymodules.Skylink) (se skymodules.SkylinkEncoding, err error) {
+	err = c.get(fmt.Sprintf("/skynet/debug/encoding/%s", sl.String()), &se)
+	return
+}
+
+// SkylinkChunkGET queries the /skynet/debug/chunk/:skylink endpoint. If a
+// skykey name is provided, that skykey is used to decrypt the chunk.
+func (c *Client) SkylinkChunkGET(sl skymodules.Skylink, index uint64, skykeyName string) ([]byte, error) {
+	values := url.Values{}
+	values.Set("index", fmt.Sprint(index))
+	if skykeyName != "" {
+		values.Set("skykeyname", skykeyName)
+	}
+	_, chunk, err := c.getRawResponse(fmt.Sprintf("/skynet/debug/chunk/%s?%s", sl.String(), values.Encode()))
+	return chunk, err
+}
+
+// SkyfileVerifyGET queries the /skynet/skyfile/verify/:skylink endpoint.
+func (c *Client) SkyfileVerifyGET(sl skymodules.Skylink) (sc skymodules.SkyfileConsistency, err error) {
+	err = c.get(fmt.Sprintf("/skynet/skyfile/verify/%s", sl.String()), &sc)
+	return
+}
+
 // RegistryRead queries the /skynet/registry [GET] endpoint.
 func (c *Client) RegistryRead(spk types.SiaPublicKey, dataKey crypto.Hash) (modules.SignedRegistryValue, error) {
 	return c.RegistryReadWithTimeout(spk, dataKey, 0)
@@ -994,6 +2039,27 @@ func (c *Client) RegistryUpdateMulti(srvs map[string]skymodules.RegistryEntry) e
 	return c.post("/skynet/registrymulti", string(reqBytes), nil)
 }
 
+// RegistryUpdateBatch queries the /skynet/registry/batch [POST] endpoint.
+func (c *Client) RegistryUpdateBatch(entries []skymodules.RegistryEntry) (rhbp api.RegistryHandlerBatchPOST, err error) {
+	req := make([]api.RegistryHandlerRequestPOST, 0, len(entries))
+	for _, entry := range entries {
+		req = append(req, api.RegistryHandlerRequestPOST{
+			PublicKey: entry.PubKey,
+			DataKey:   entry.Tweak,
+			Revision:  entry.Revision,
+			Signature: entry.Signature,
+			Data:      entry.Data,
+			Type:      entry.Type,
+		})
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return api.RegistryHandlerBatchPOST{}, err
+	}
+	err = c.post("/skynet/registry/batch", string(reqBytes), &rhbp)
+	return
+}
+
 // RegistryUpdateWithEntry queries the /skynet/registry [POST] endpoint.
 func (c *Client) RegistryUpdateWithEntry(spk types.SiaPublicKey, srv modules.SignedRegistryValue) error {
 	req := api.RegistryHandlerRequestPOST{
@@ -1011,6 +2077,188 @@ func (c *Client) RegistryUpdateWithEntry(spk types.SiaPublicKey, srv modules.Sig
 	return c.post("/skynet/registry", string(reqBytes), nil)
 }
 
+// RegistryUpdateWithKeyName queries the /skynet/registry [POST] endpoint to
+// update the registry with a value that is signed by the renter using the
+// stored registry key with the given name.
+func (c *Client) RegistryUpdateWithKeyName(keyName string, rv modules.RegistryValue) error {
+	req := api.RegistryHandlerRequestPOST{
+		DataKey:  rv.Tweak,
+		Revision: rv.Revision,
+		Data:     rv.Data,
+		Type:     rv.Type,
+		KeyName:  keyName,
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.post("/skynet/registry", string(reqBytes), nil)
+}
+
+// SkynetPublishPost requests the /skynet/publish [POST] endpoint to point the
+// registry entry of the stored registry key with the given name at a V1
+// skylink.
+func (c *Client) SkynetPublishPost(keyName string, dataKey crypto.Hash, skylink skymodules.Skylink) (spp api.SkynetPublishPOST, err error) {
+	req := api.SkynetPublishRequestPOST{
+		KeyName: keyName,
+		DataKey: dataKey,
+		Skylink: skylink.String(),
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return api.SkynetPublishPOST{}, err
+	}
+	err = c.post("/skynet/publish", string(reqBytes), &spp)
+	return
+}
+
+// RegistryLargeGet requests the /skynet/registry/large [GET] endpoint to
+// read data which was split across multiple registry entries.
+func (c *Client) RegistryLargeGet(spk types.SiaPublicKey, dataKey crypto.Hash) ([]byte, uint64, error) {
+	values := url.Values{}
+	values.Set("publickey", spk.String())
+	values.Set("datakey", dataKey.String())
+	var rlg api.RegistryLargeGET
+	err := c.get("/skynet/registry/large?"+values.Encode(), &rlg)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := hex.DecodeString(rlg.Data)
+	if err != nil {
+		return nil, 0, errors.AddContext(err, "failed to decode data")
+	}
+	return data, rlg.Revision, nil
+}
+
+// RegistryLargePost requests the /skynet/registry/large [POST] endpoint to
+// store data across multiple registry entries signed by the stored registry
+// key with the given name.
+func (c *Client) RegistryLargePost(keyName string, dataKey crypto.Hash, data []byte) (rlp api.RegistryLargePOST, err error) {
+	req := api.RegistryLargeRequestPOST{
+		KeyName: keyName,
+		DataKey: dataKey,
+		Data:    data,
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return api.RegistryLargePOST{}, err
+	}
+	err = c.post("/skynet/registry/large", string(reqBytes), &rlp)
+	return
+}
+
+// SkynetGCPost requests the /skynet/gc [POST] endpoint.
+func (c *Client) SkynetGCPost(olderThan time.Duration, dryRun bool, excludePrefix string) (sgp api.SkynetGCPOST, err error) {
+	req := api.SkynetGCRequestPOST{
+		DryRun:        dryRun,
+		ExcludePrefix: excludePrefix,
+		OlderThan:     uint64(olderThan.Seconds()),
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return api.SkynetGCPOST{}, err
+	}
+	err = c.post("/skynet/gc", string(reqBytes), &sgp)
+	return
+}
+
+// SkynetSnapshotPost requests the /skynet/snapshot [POST] endpoint to capture
+// the skylinks pinned under the given directory, relative to the skynet
+// folder, in a snapshot with the given name.
+func (c *Client) SkynetSnapshotPost(name, dir string) (ssp api.SkynetSnapshotPOST, err error) {
+	values := url.Values{}
+	values.Set("name", name)
+	values.Set("siapath", dir)
+	err = c.post("/skynet/snapshot", values.Encode(), &ssp)
+	return
+}
+
+// SkynetSnapshotDiffGet requests the /skynet/snapshot/diff [GET] endpoint to
+// diff two snapshots. An empty 'to' diffs against the current state.
+func (c *Client) SkynetSnapshotDiffGet(from, to string) (ssdg api.SkynetSnapshotDiffGET, err error) {
+	values := url.Values{}
+	values.Set("from", from)
+	values.Set("to", to)
+	err = c.get("/skynet/snapshot/diff?"+values.Encode(), &ssdg)
+	return
+}
+
+// SkynetSearchGet requests the /skynet/search [GET] endpoint to search the
+// metadata of the skyfiles pinned under a directory relative to the skynet
+// folder. Zero values of the parameters are omitted.
+func (c *Client) SkynetSearchGet(params skymodules.SkynetSearchParameters) (ssg api.SkynetSearchGET, err error) {
+	values := url.Values{}
+	if !params.SiaPath.IsRoot() {
+		values.Set("siapath", params.SiaPath.String())
+	}
+	if params.Filename != "" {
+		values.Set("filename", params.Filename)
+	}
+	if params.ContentType != "" {
+		values.Set("contenttype", params.ContentType)
+	}
+	if params.MinSize > 0 {
+		values.Set("minsize", fmt.Sprint(params.MinSize))
+	}
+	if params.MaxSize > 0 {
+		values.Set("maxsize", fmt.Sprint(params.MaxSize))
+	}
+	if params.Offset > 0 {
+		values.Set("offset", fmt.Sprint(params.Offset))
+	}
+	if params.Limit > 0 {
+		values.Set("limit", fmt.Sprint(params.Limit))
+	}
+	err = c.get("/skynet/search?"+values.Encode(), &ssg)
+	return
+}
+
+// RegistryKeyDeletePost requests the /skynet/registry/key/delete [POST]
+// endpoint.
+func (c *Client) RegistryKeyDeletePost(name string, confirm bool) error {
+	values := url.Values{}
+	values.Set("name", name)
+	values.Set("confirm", fmt.Sprint(confirm))
+	return c.post("/skynet/registry/key/delete", values.Encode(), nil)
+}
+
+// RegistryKeyPost requests the /skynet/registry/key [POST] endpoint to create
+// a new registry key.
+func (c *Client) RegistryKeyPost(name string) (key skymodules.RegistryKey, err error) {
+	values := url.Values{}
+	values.Set("name", name)
+	err = c.post("/skynet/registry/key", values.Encode(), &key)
+	return
+}
+
+// RegistryKeysGet requests the /skynet/registry/key [GET] endpoint.
+func (c *Client) RegistryKeysGet() (rkg api.RegistryKeysGET, err error) {
+	err = c.get("/skynet/registry/key", &rkg)
+	return
+}
+
+// SkynetAPIKeyDeletePost requests the /skynet/apikeys/delete [POST] endpoint.
+func (c *Client) SkynetAPIKeyDeletePost(id crypto.Hash) error {
+	values := url.Values{}
+	values.Set("id", id.String())
+	return c.post("/skynet/apikeys/delete", values.Encode(), nil)
+}
+
+// SkynetAPIKeyPost requests the /skynet/apikeys [POST] endpoint to create a
+// new API key with the given scope.
+func (c *Client) SkynetAPIKeyPost(scope skymodules.SkynetAPIKeyScope) (sakp api.SkynetAPIKeyPOST, err error) {
+	values := url.Values{}
+	values.Set("scope", string(scope))
+	err = c.post("/skynet/apikeys", values.Encode(), &sakp)
+	return
+}
+
+// SkynetAPIKeysGet requests the /skynet/apikeys [GET] endpoint.
+func (c *Client) SkynetAPIKeysGet() (sakg api.SkynetAPIKeysGET, err error) {
+	err = c.get("/skynet/apikeys", &sakg)
+	return
+}
+
 // SkylinkFromTUSURL is a helper to fetch the skylink of a finished upload.
 func SkylinkFromTUSURL(tc *tus.Client, url string) (_ string, err error) {
 	// After the upload, fetch the skylink from the metadata.
@@ -1099,6 +2347,19 @@ func (c *Client) SkylinkFromTUSID(id string) (string, error) {
 	return headerSkylink, nil
 }
 
+// parseHostStats decodes the value of the host stats header or trailer.
+func parseHostStats(str string) ([]skymodules.SkynetHostStats, error) {
+	if str == "" {
+		return nil, errors.New("host stats are missing from the response")
+	}
+	var hostStats []skymodules.SkynetHostStats
+	err := json.Unmarshal([]byte(str), &hostStats)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to decode host stats")
+	}
+	return hostStats, nil
+}
+
 // skylinkQueryWithValues returns a skylink query based on the given skylink and
 // values. If the values are empty it will not append a `?` to the query.
 func skylinkQueryWithValues(skylink string, values url.Values) string {
@@ -1150,6 +2411,11 @@ func urlValuesFromSkyfilePinParameters(sup skymodules.SkyfilePinParameters) url.
 	values.Set("force", fmt.Sprintf("%t", sup.Force))
 	values.Set("root", fmt.Sprintf("%t", sup.Root))
 	values.Set("basechunkredundancy", fmt.Sprintf("%v", sup.BaseChunkRedundancy))
+	values.Set("basesectoronly", fmt.Sprintf("%t", sup.BaseSectorOnly))
+	values.Set("wait-healthy", fmt.Sprintf("%t", sup.WaitHealthy))
+	if sup.TTL > 0 {
+		values.Set("ttl", fmt.Sprintf("%d", uint64(sup.TTL.Seconds())))
+	}
 	return values
 }
 
@@ -1178,6 +2444,23 @@ func urlValuesFromSkyfileUploadParameters(sup skymodules.SkyfileUploadParameters
 	}
 	values.Set("errorpages", string(b))
 
+	// encode the modtime, if it's not set the node uses the time of the
+	// upload
+	if sup.ModTime != 0 {
+		values.Set("modtime", fmt.Sprint(sup.ModTime))
+	}
+
+	// encode erasure coding parameters
+	if sup.DataPieces != 0 || sup.ParityPieces != 0 {
+		values.Set("datapieces", fmt.Sprint(sup.DataPieces))
+		values.Set("paritypieces", fmt.Sprint(sup.ParityPieces))
+	}
+
+	// encode the sparse flag
+	if sup.Sparse {
+		values.Set("sparse", "true")
+	}
+
 	// encode encryption parameters
 	if sup.SkykeyName != "" {
 		values.Set("skykeyname", sup.SkykeyName)
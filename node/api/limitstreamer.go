@@ -16,6 +16,11 @@ import (
 // it from different threads, you are going to have unexpected behavior.
 // Further more, it is advised to only wrap a skymodules.Streamer once, wrapping it
 // multiple times might lead to unexpected behavior and was not tested.
+//
+// If the wrapped streamer supports it, it is seeked lazily. That way wrapping
+// the streamer and the seeks performed by http.ServeContent to determine the
+// size of the content don't cause the wrapped streamer to fetch data which is
+// never served, while invalid seeks still fail before any headers are written.
 type limitStreamer struct {
 	stream        skymodules.SkyfileStreamer
 	base          uint64
@@ -103,7 +108,12 @@ func (ls *limitStreamer) Seek(offset int64, whence int) (int64, error) {
 	}
 
 	ls.off = uint64(offset)
-	_, err := ls.stream.Seek(int64(ls.off), io.SeekStart)
+	var err error
+	if lazySeeker, ok := ls.stream.(skymodules.SkyfileLazySeekStreamer); ok {
+		_, err = lazySeeker.SeekLazy(int64(ls.off), io.SeekStart)
+	} else {
+		_, err = ls.stream.Seek(int64(ls.off), io.SeekStart)
+	}
 	if err != nil {
 		return offset - int64(ls.base), err
 	}
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter"
 	"go.sia.tech/siad/crypto"
@@ -123,6 +128,134 @@ func TestLimitStreamer(t *testing.T) {
 	}
 }
 
+// TestLimitStreamerLazySeek verifies that the limit streamer seeks streamers
+// which support it lazily so that serving a range doesn't fetch any data
+// before the first Read.
+func TestLimitStreamerLazySeek(t *testing.T) {
+	data := []byte("Hello, this is some not so random text")
+	streamer := &lazySeekStreamer{
+		SkyfileStreamer: renter.SkylinkStreamerFromSlice(data, skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, skymodules.SkyfileLayout{}),
+		badOffset:       -1,
+	}
+	ls, err := NewLimitStreamer(streamer, skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, skymodules.SkyfileLayout{}, 20, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serving a HEAD request doesn't fetch any data.
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "text/plain")
+	http.ServeContent(w, httptest.NewRequest(http.MethodHead, "/", nil), "", time.Time{}, ls)
+	if w.Code != http.StatusOK {
+		t.Fatal("unexpected status", w.Code)
+	}
+	if streamer.fetches != 0 {
+		t.Fatal("HEAD request shouldn't fetch any data", streamer.fetches)
+	}
+
+	// Serving a range only fetches data once it is read.
+	w = httptest.NewRecorder()
+	w.Header().Set("Content-Type", "text/plain")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=7-12")
+	http.ServeContent(w, req, "", time.Time{}, ls)
+	if w.Code != http.StatusPartialContent {
+		t.Fatal("unexpected status", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), []byte("random")) {
+		t.Fatal("unexpected data", w.Body.String())
+	}
+	if streamer.fetches != 1 {
+		t.Fatal("range should be fetched once", streamer.fetches)
+	}
+}
+
+// TestLimitStreamerSeekError verifies that a failed seek of the wrapped
+// streamer is returned by Seek so that http.ServeContent responds with an
+// error status instead of a truncated body.
+func TestLimitStreamerSeekError(t *testing.T) {
+	data := []byte("Hello, this is some not so random text")
+	newStreamer := func(badOffset int64) *lazySeekStreamer {
+		return &lazySeekStreamer{
+			SkyfileStreamer: renter.SkylinkStreamerFromSlice(data, skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, skymodules.SkyfileLayout{}),
+			badOffset:       badOffset,
+		}
+	}
+
+	// Wrapping fails if the initial seek fails.
+	_, err := NewLimitStreamer(newStreamer(20), skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, skymodules.SkyfileLayout{}, 20, 13)
+	if !errors.Contains(err, errLazySeekFailed) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Fail the seek to the start of the range.
+	streamer := newStreamer(27)
+	ls, err := NewLimitStreamer(streamer, skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, skymodules.SkyfileLayout{}, 20, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ls.Seek(7, io.SeekStart)
+	if !errors.Contains(err, errLazySeekFailed) {
+		t.Fatal("unexpected error", err)
+	}
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "text/plain")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=7-12")
+	http.ServeContent(w, req, "", time.Time{}, ls)
+	if w.Code < http.StatusBadRequest {
+		t.Fatal("unexpected status", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), errLazySeekFailed.Error()) {
+		t.Fatal("unexpected body", w.Body.String())
+	}
+	if streamer.fetches != 0 {
+		t.Fatal("failed request shouldn't fetch any data", streamer.fetches)
+	}
+}
+
+// errLazySeekFailed is returned by a lazySeekStreamer when seeking to its bad
+// offset.
+var errLazySeekFailed = errors.New("seek failed")
+
+// lazySeekStreamer wraps a skymodules.SkyfileStreamer and counts the fetches a
+// streamer which supports lazy seeks would start. Seek fetches right away,
+// SeekLazy on the next Read. Seeking to badOffset fails.
+type lazySeekStreamer struct {
+	skymodules.SkyfileStreamer
+	badOffset int64
+	fetches   int
+	pending   bool
+}
+
+// Read implements the io.Reader interface.
+func (s *lazySeekStreamer) Read(b []byte) (int, error) {
+	if s.pending {
+		s.fetches++
+		s.pending = false
+	}
+	return s.SkyfileStreamer.Read(b)
+}
+
+// Seek implements the io.Seeker interface.
+func (s *lazySeekStreamer) Seek(offset int64, whence int) (int64, error) {
+	if offset == s.badOffset {
+		return 0, errLazySeekFailed
+	}
+	s.fetches++
+	s.pending = false
+	return s.SkyfileStreamer.Seek(offset, whence)
+}
+
+// SeekLazy implements the skymodules.SkyfileLazySeekStreamer interface.
+func (s *lazySeekStreamer) SeekLazy(offset int64, whence int) (int64, error) {
+	if offset == s.badOffset {
+		return 0, errLazySeekFailed
+	}
+	s.pending = true
+	return s.SkyfileStreamer.Seek(offset, whence)
+}
+
 // streamerFromReader is wraps a bytes.Reader to give it a Close() method, which
 // allows it to satisfy the skymodules.Streamer interface.
 type streamerFromReader struct {
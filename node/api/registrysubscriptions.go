@@ -80,15 +80,8 @@ func (queue *notificationQueue) Pop() *queuedNotification {
 
 // skynetRegistrySubscriptionHandler handles websocket subscriptions to the registry.
 func (api *API) skynetRegistrySubscriptionHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Upgrade connection to use websocket.
-	c, err := upgrader.Upgrade(w, req, nil)
-	if err != nil {
-		handleSkynetError(w, "failed to upgrade connection to websocket connection", err)
-		return
-	}
-	defer c.Close()
-
-	// Make sure the limit and delay are set.
+	// Make sure the limit and delay are set. They are parsed before upgrading
+	// the connection for the errors to reach the client.
 	bandwidthLimitStr := req.FormValue("bandwidthlimit")
 	if bandwidthLimitStr == "" {
 		WriteError(w, Error{"bandwidthlimit param not specified"}, http.StatusBadRequest)
@@ -102,19 +95,27 @@ func (api *API) skynetRegistrySubscriptionHandler(w http.ResponseWriter, req *ht
 
 	// Parse them.
 	var bandwidthLimit uint64
-	_, err = fmt.Sscan(bandwidthLimitStr, &bandwidthLimit)
+	_, err := fmt.Sscan(bandwidthLimitStr, &bandwidthLimit)
 	if err != nil {
-		WriteError(w, Error{"failed to parse bandwidthlimit" + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"failed to parse bandwidthlimit: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 	var notificationDelayMS uint64
 	_, err = fmt.Sscan(notificationDelayStr, &notificationDelayMS)
 	if err != nil {
-		WriteError(w, Error{"failed to parse notificationdelay" + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"failed to parse notificationdelay: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 	notificationDelay := time.Millisecond * time.Duration(notificationDelayMS)
 
+	// Upgrade connection to use websocket.
+	c, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		handleSkynetError(w, "failed to upgrade connection to websocket connection", err)
+		return
+	}
+	defer c.Close()
+
 	// Compute how many notifications per second we want to serve.
 	notificationsPerSecond := float64(bandwidthLimit) / RegistrySubscriptionNotificationSize
 
@@ -203,6 +204,7 @@ func (api *API) skynetRegistrySubscriptionHandler(w http.ResponseWriter, req *ht
 	subscriber, err := api.renter.NewRegistrySubscriber(queueNotification)
 	if err != nil {
 		c.WriteJSON(RegistrySubscriptionResponse{Error: fmt.Sprintf("failed to create subscriber: %v", err)})
+		return
 	}
 
 	// Unsubscribe when the connection is closed.
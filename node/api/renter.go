@@ -929,6 +929,15 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		}
 	}
 
+	// Scan the default base chunk redundancy. (optional parameter)
+	if s := req.FormValue("defaultbasechunkredundancy"); s != "" {
+		var redundancy uint8
+		if _, err := fmt.Sscan(s, &redundancy); err != nil {
+			WriteError(w, Error{"unable to parse defaultbasechunkredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.DefaultBaseChunkRedundancy = redundancy
+	}
 	// Scan the download speed limit. (optional parameter)
 	if d := req.FormValue("maxdownloadspeed"); d != "" {
 		var downloadSpeed int64
@@ -947,6 +956,139 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		}
 		settings.MaxUploadSpeed = uploadSpeed
 	}
+	// Scan whether the skynet allowlist is enforced. (optional parameter)
+	if s := req.FormValue("skynetallowlistenforced"); s != "" {
+		enforced, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse skynetallowlistenforced: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetAllowlistEnforced = enforced
+	}
+	// Scan the skynet cors origins. An empty value disables the CORS headers.
+	// (optional parameter)
+	if _, ok := req.Form["skynetcorsorigins"]; ok {
+		var origins []string
+		for _, origin := range strings.Split(req.FormValue("skynetcorsorigins"), ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				origins = append(origins, origin)
+			}
+		}
+		settings.SkynetCORSOrigins = origins
+	}
+	// Scan whether skynet downloads may proceed in degraded mode. (optional
+	// parameter)
+	if s := req.FormValue("skynetdegradeddownloads"); s != "" {
+		degraded, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse skynetdegradeddownloads: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetDegradedDownloads = degraded
+	}
+	// Scan the public key force tokens are verified against. An empty value
+	// disables force tokens. (optional parameter)
+	if _, ok := req.Form["skynetforcetokenkey"]; ok {
+		var key types.SiaPublicKey
+		if s := req.FormValue("skynetforcetokenkey"); s != "" {
+			if err := key.LoadString(s); err != nil {
+				WriteError(w, Error{"unable to parse skynetforcetokenkey: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			if key.Algorithm != types.SignatureEd25519 || len(key.Key) != crypto.PublicKeySize {
+				WriteError(w, Error{"skynetforcetokenkey needs to be an ed25519 public key"}, http.StatusBadRequest)
+				return
+			}
+		}
+		settings.SkynetForceTokenKey = key
+	}
+	// Scan the skynet max upload size. (optional parameter)
+	if s := req.FormValue("skynetmaxuploadsize"); s != "" {
+		var maxUploadSize uint64
+		if _, err := fmt.Sscan(s, &maxUploadSize); err != nil {
+			WriteError(w, Error{"unable to parse skynetmaxuploadsize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetMaxUploadSize = maxUploadSize
+	}
+	// Scan the maximum filename length of a multipart upload. (optional parameter)
+	if s := req.FormValue("skynetmultipartmaxfilenamelength"); s != "" {
+		var limit uint64
+		if _, err := fmt.Sscan(s, &limit); err != nil {
+			WriteError(w, Error{"unable to parse skynetmultipartmaxfilenamelength: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetMultipartLimits.MaxFilenameLength = limit
+	}
+	// Scan the maximum header bytes of a multipart upload. (optional parameter)
+	if s := req.FormValue("skynetmultipartmaxheaderbytes"); s != "" {
+		var limit uint64
+		if _, err := fmt.Sscan(s, &limit); err != nil {
+			WriteError(w, Error{"unable to parse skynetmultipartmaxheaderbytes: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetMultipartLimits.MaxHeaderBytes = limit
+	}
+	// Scan the skynet storage cap. (optional parameter)
+	if s := req.FormValue("skynetstoragecap"); s != "" {
+		var storageCap uint64
+		if _, err := fmt.Sscan(s, &storageCap); err != nil {
+			WriteError(w, Error{"unable to parse skynetstoragecap: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetStorageCap = storageCap
+	}
+	// Scan the skynet upload alert threshold. (optional parameter)
+	if s := req.FormValue("skynetuploadalertthresholdms"); s != "" {
+		var threshold uint64
+		if _, err := fmt.Sscan(s, &threshold); err != nil {
+			WriteError(w, Error{"unable to parse skynetuploadalertthresholdms: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetUploadAlertThresholdMS = threshold
+	}
+	// Scan the skynet default request timeout. (optional parameter)
+	if s := req.FormValue("skynetdefaultrequesttimeout"); s != "" {
+		var timeout uint64
+		if _, err := fmt.Sscan(s, &timeout); err != nil {
+			WriteError(w, Error{"unable to parse skynetdefaultrequesttimeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetDefaultRequestTimeout = timeout
+	}
+	// Scan the skynet max data URI size. (optional parameter)
+	if s := req.FormValue("skynetmaxdataurisize"); s != "" {
+		var size uint64
+		if _, err := fmt.Sscan(s, &size); err != nil {
+			WriteError(w, Error{"unable to parse skynetmaxdataurisize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetMaxDataURISize = size
+	}
+	// Scan the skynet max request timeout. (optional parameter)
+	if s := req.FormValue("skynetmaxrequesttimeout"); s != "" {
+		var timeout uint64
+		if _, err := fmt.Sscan(s, &timeout); err != nil {
+			WriteError(w, Error{"unable to parse skynetmaxrequesttimeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetMaxRequestTimeout = timeout
+	}
+	// Scan whether skylink responses use weak ETags. (optional parameter)
+	if s := req.FormValue("skynetweaketags"); s != "" {
+		weak, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse skynetweaketags: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkynetWeakETags = weak
+	}
+	// Validate the resulting request timeouts. Unset values fall back to
+	// the defaults so they need to be taken into account as well.
+	if defaultTimeout, maxTimeout := skynetRequestTimeouts(settings); defaultTimeout > maxTimeout {
+		WriteError(w, Error{fmt.Sprintf("default skynet request timeout %v cannot exceed the max skynet request timeout %v", defaultTimeout, maxTimeout)}, http.StatusBadRequest)
+		return
+	}
 
 	// Scan the checkforipviolation flag.
 	if ipc := req.FormValue("checkforipviolation"); ipc != "" {
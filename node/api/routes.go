@@ -13,7 +13,7 @@ import (
 
 	"gitlab.com/NebulousLabs/log"
 	"gitlab.com/SkynetLabs/skyd/build"
-	"gitlab.com/SkynetLabs/skyd/skymodules/renter"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
 )
 
 var (
@@ -126,34 +126,87 @@ func (api *API) buildHTTPRoutes() {
 
 		// Skynet endpoints
 		router.GET("/skynet/basesector/*skylink", api.skynetBaseSectorHandlerGET)
+		router.HEAD("/skynet/basesector/*skylink", api.skynetBaseSectorHandlerHEAD)
+		router.GET("/skynet/allowlist", api.skynetAllowlistHandlerGET)
+		router.GET("/skynet/backup/:skylink", api.skynetBackupHandlerGET)
+		router.GET("/skynet/apikeys", api.requireSkynetScope(api.skynetAPIKeysHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/apikeys", api.requireSkynetScope(api.skynetAPIKeysHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/apikeys/delete", api.requireSkynetScope(api.skynetAPIKeysDeleteHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/allowlist", api.requireSkynetScope(api.skynetAllowlistHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 		router.GET("/skynet/blocklist", api.skynetBlocklistHandlerGET)
-		router.POST("/skynet/blocklist", RequirePassword(api.skynetBlocklistHandlerPOST, requiredPassword))
+		router.POST("/skynet/blocklist", api.requireSkynetScope(api.skynetBlocklistHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/blocklist/hits", api.requireSkynetScope(api.skynetBlocklistHitsHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/trace/:id", api.requireSkynetScope(api.skynetTraceHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/bundle", api.requireSkynetScope(api.skynetBundleHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.GET("/skynet/canonicalize/*skylink", api.skynetCanonicalizeHandlerGET)
+		router.POST("/skynet/diff", api.requireSkynetScope(api.skynetDiffHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.POST("/skynet/gc", api.requireSkynetScope(api.skynetGCHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 		router.GET("/skynet/health/entry", api.registryEntryHealthHandlerGET)
+		router.GET("/skynet/maintenance", api.skynetMaintenanceHandlerGET)
+		router.POST("/skynet/maintenance", api.requireSkynetScope(api.skynetMaintenanceHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 		router.GET("/skynet/metadata/:skylink", api.skynetMetadataHandlerGET)
-		router.POST("/skynet/pin/:skylink", RequirePassword(api.skynetSkylinkPinHandlerPOST, requiredPassword))
+		router.POST("/skynet/pin/:skylink", api.rejectDuringMaintenance(api.requireSkynetScope(api.skynetSkylinkPinHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopePin)))
+		router.GET("/skynet/pin/estimate/:skylink", api.requireSkynetScope(api.skynetPinEstimateHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.GET("/skynet/pinned", api.requireSkynetScope(api.skynetPinnedHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.POST("/skynet/pinfrom/:skylink", api.rejectDuringMaintenance(api.limitSkynetUploads(api.requireSkynetScope(api.skynetPinFromHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopePin))))
+		router.GET("/skynet/portalkey", api.skynetPortalKeyHandlerGET)
 		router.GET("/skynet/portals", api.skynetPortalsHandlerGET)
-		router.POST("/skynet/portals", RequirePassword(api.skynetPortalsHandlerPOST, requiredPassword))
-		router.POST("/skynet/registry", RequirePassword(api.registryHandlerPOST, requiredPassword))
-		router.POST("/skynet/registrymulti", RequirePassword(api.registryMultiHandlerPOST, requiredPassword))
+		router.POST("/skynet/portals", api.requireSkynetScope(api.skynetPortalsHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/publish", api.rejectDuringMaintenance(api.requireSkynetScope(api.skynetPublishHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.POST("/skynet/registry", api.rejectDuringMaintenance(api.requireSkynetScope(api.registryHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.POST("/skynet/registrymulti", api.rejectDuringMaintenance(api.requireSkynetScope(api.registryMultiHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.POST("/skynet/registry/batch", api.rejectDuringMaintenance(api.requireSkynetScope(api.registryBatchHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
 		router.GET("/skynet/registry", api.registryHandlerGET)
+		router.GET("/skynet/registry/large", api.registryLargeHandlerGET)
 		router.GET("/skynet/registry/hosts", api.skynetHostsForRegistryUpdateGET)
+		router.POST("/skynet/registry/large", api.rejectDuringMaintenance(api.requireSkynetScope(api.registryLargeHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.GET("/skynet/registry/key", api.requireSkynetScope(api.registryKeyHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/registry/key", api.requireSkynetScope(api.registryKeyHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/registry/key/delete", api.requireSkynetScope(api.registryKeyDeleteHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 		router.GET("/skynet/registry/subscription", api.skynetRegistrySubscriptionHandler)
+		router.GET("/skynet/registry/subscribe", api.skynetRegistrySubscriptionHandler)
 		router.GET("/skynet/resolve/:skylink", api.skylinkResolveGET)
-		router.POST("/skynet/restore", RequirePassword(api.skynetRestoreHandlerPOST, requiredPassword))
+		router.POST("/skynet/prefetch/:skylink", api.requireSkynetScope(api.skynetPrefetchHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.GET("/skynet/prefetch/status/:id", api.skynetPrefetchStatusHandlerGET)
+		router.POST("/skynet/restore", api.rejectDuringMaintenance(api.limitSkynetUploads(api.requireSkynetScope(api.skynetRestoreHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload))))
 		router.GET("/skynet/root", api.skynetRootHandlerGET)
+		router.HEAD("/skynet/root", api.skynetRootHandlerHEAD)
 		router.GET("/skynet/skylink/*skylink", api.skynetSkylinkHandlerGET)
 		router.HEAD("/skynet/skylink/*skylink", api.skynetSkylinkHandlerGET)
-		router.POST("/skynet/skyfile/*siapath", RequirePassword(api.skynetSkyfileHandlerPOST, requiredPassword))
+		router.OPTIONS("/skynet/skylink/*skylink", api.skynetSkylinkHandlerOPTIONS)
+		router.POST("/skynet/skyfile/*siapath", api.rejectDuringMaintenance(api.limitSkynetUploads(api.requireSkynetScope(api.skynetSkyfileHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload))))
+		router.POST("/skynet/snapshot", api.rejectDuringMaintenance(api.requireSkynetScope(api.skynetSnapshotHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.GET("/skynet/snapshot/diff", api.requireSkynetScope(api.skynetSnapshotDiffHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.GET("/skynet/search", api.requireSkynetScope(api.skynetSearchHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.GET("/skynet/convert/status/:id", api.requireSkynetScope(api.skynetConvertStatusHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeUpload))
+		router.POST("/skynet/convert/cancel/:id", api.requireSkynetScope(api.skynetConvertCancelHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload))
 		router.GET("/skynet/stats", api.skynetStatsHandlerGET)
-		router.POST("/skynet/unpin/:skylink", RequirePassword(api.skynetSkylinkUnpinHandlerPOST, requiredPassword))
+		router.POST("/skynet/unpin/:skylink", api.requireSkynetScope(api.skynetSkylinkUnpinHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopePin))
+		router.POST("/skynet/upload/begin", api.rejectDuringMaintenance(api.limitSkynetUploads(api.requireSkynetScope(api.skynetUploadBeginHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload))))
+		router.POST("/skynet/upload/estimate", api.requireSkynetScope(api.skynetUploadEstimateHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
+		router.POST("/skynet/upload/finalize/:id", api.rejectDuringMaintenance(api.requireSkynetScope(api.skynetUploadFinalizeHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeUpload)))
+		router.GET("/skynet/uploadpolicy", api.skynetUploadPolicyHandlerGET)
+		router.POST("/skynet/uploadpolicy", api.requireSkynetScope(api.skynetUploadPolicyHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/uploadratelimit", api.skynetUploadRateLimitHandlerGET)
+		router.POST("/skynet/uploadratelimit", api.requireSkynetScope(api.skynetUploadRateLimitHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 		router.GET("/skynet/health/skylink/:skylink", api.skynetSkylinkHealthGET)
+		router.GET("/skynet/manifest/:skylink", api.skynetManifestHandlerGET)
+		router.GET("/skynet/debug/chunk/:skylink", api.requireSkynetScope(api.skynetSkylinkChunkGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/debug/encoding/:skylink", api.requireSkynetScope(api.skynetSkylinkEncodingGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/skyfile/verify/:skylink", api.skynetSkyfileVerifyHandlerGET)
+		router.GET("/skynet/workers", api.requireSkynetScope(api.skynetWorkersHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/zip", api.requireSkynetScope(api.skynetBundleHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeRead))
 
 		// Skykey endpoints
-		router.GET("/skynet/skykey", RequirePassword(api.skykeyHandlerGET, requiredPassword))
-		router.POST("/skynet/addskykey", RequirePassword(api.skykeyAddKeyHandlerPOST, requiredPassword))
-		router.POST("/skynet/createskykey", RequirePassword(api.skykeyCreateKeyHandlerPOST, requiredPassword))
-		router.POST("/skynet/deleteskykey", RequirePassword(api.skykeyDeleteHandlerPOST, requiredPassword))
-		router.GET("/skynet/skykeys", RequirePassword(api.skykeysHandlerGET, requiredPassword))
+		router.GET("/skynet/skykey", api.requireSkynetScope(api.skykeyHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/addskykey", api.requireSkynetScope(api.skykeyAddKeyHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/createskykey", api.requireSkynetScope(api.skykeyCreateKeyHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/deleteskykey", api.requireSkynetScope(api.skykeyDeleteHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/skykey/export", api.requireSkynetScope(api.skykeyExportHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/skykey/import", api.requireSkynetScope(api.skykeyImportHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/skykeys", api.requireSkynetScope(api.skykeysHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.GET("/skynet/skykeys/match/:skylink", api.requireSkynetScope(api.skykeysMatchHandlerGET, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
+		router.POST("/skynet/skykeys/rename", api.requireSkynetScope(api.skykeysRenameHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 
 		// Create the store composer.
 		storeComposer := handler.NewStoreComposer()
@@ -178,7 +231,7 @@ func (api *API) buildHTTPRoutes() {
 
 		// Create the TUS handler and register its routes.
 		tusHandler, err := handler.NewUnroutedHandler(handler.Config{
-			PreUploadCreateCallback: renter.TUSPreUploadCreateCallback,
+			PreUploadCreateCallback: api.tusPreUploadCreateCallback,
 			BasePath:                "/skynet/tus",
 			MaxSize:                 maxSize,
 			RespectForwardedHeaders: true,
@@ -192,10 +245,10 @@ func (api *API) buildHTTPRoutes() {
 			return
 		}
 		optionsHandler := func(w http.ResponseWriter, req *http.Request) {}
-		router.POST("/skynet/tus", RequireTUSMiddleware(tusHandler.PostFile, tusHandler))
+		router.POST("/skynet/tus", api.rejectDuringMaintenance(api.limitSkynetUploads(RequireTUSMiddleware(tusHandler.PostFile, tusHandler))))
 		router.OPTIONS("/skynet/tus", RequireTUSMiddleware(optionsHandler, tusHandler))
 		router.HEAD("/skynet/tus/:id", RequireTUSMiddleware(tusHandler.HeadFile, tusHandler))
-		router.PATCH("/skynet/tus/:id", RequireTUSMiddleware(tusHandler.PatchFile, tusHandler))
+		router.PATCH("/skynet/tus/:id", api.rejectDuringMaintenance(api.limitSkynetUploadBytes(RequireTUSMiddleware(tusHandler.PatchFile, tusHandler))))
 		router.GET("/skynet/tus/:id", RequireTUSMiddleware(tusHandler.GetFile, tusHandler))
 		router.OPTIONS("/skynet/tus/:id", RequireTUSMiddleware(optionsHandler, tusHandler))
 		router.GET("/skynet/upload/tus/:id", api.skynetTUSUploadSkylinkGET)
@@ -218,8 +271,8 @@ func (api *API) buildHTTPRoutes() {
 		// Deprecated endpoints.
 		router.POST("/renter/backup", RequirePassword(api.renterBackupHandlerPOST, requiredPassword))
 		router.POST("/renter/recoverbackup", RequirePassword(api.renterLoadBackupHandlerPOST, requiredPassword))
-		router.GET("/skynet/blacklist", api.skynetBlocklistHandlerGET)
-		router.POST("/skynet/blacklist", RequirePassword(api.skynetBlocklistHandlerPOST, requiredPassword))
+		router.GET("/skynet/blacklist", api.skynetBlacklistHandlerGET)
+		router.POST("/skynet/blacklist", api.requireSkynetScope(api.skynetBlocklistHandlerPOST, requiredPassword, skymodules.SkynetAPIKeyScopeAdmin))
 	}
 
 	// Transaction pool API Calls
@@ -234,7 +287,7 @@ func (api *API) buildHTTPRoutes() {
 
 	// Apply UserAgent middleware and return the Router
 	api.routerMu.Lock()
-	api.router = TimeoutHandler(RequireUserAgent(router, requiredUserAgent), httpServerTimeout)
+	api.router = TimeoutHandler(SkynetTraceHandler(SkynetRequesterHandler(RequireUserAgent(router, requiredUserAgent))), httpServerTimeout)
 	api.routerMu.Unlock()
 	return
 }
@@ -252,6 +305,42 @@ func TimeoutHandler(h http.Handler, timeout time.Duration) http.Handler {
 	})
 }
 
+// SkynetTraceHandler is middleware that attaches a trace ID to skynet
+// requests. The ID is taken from the Skynet-Trace-Id header of the request or
+// generated if the header is missing. It is echoed in the response header and
+// attached to the request's context so that the renter can tag its log lines
+// with it.
+func SkynetTraceHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/skynet/") {
+			h.ServeHTTP(w, req)
+			return
+		}
+		traceID := req.Header.Get(SkynetTraceIDHeader)
+		if traceID == "" {
+			traceID = skymodules.NewTraceID()
+		} else if err := skymodules.ValidateTraceID(traceID); err != nil {
+			WriteError(w, Error{fmt.Sprintf("invalid %v header: %v", SkynetTraceIDHeader, err)}, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(SkynetTraceIDHeader, traceID)
+		h.ServeHTTP(w, req.WithContext(skymodules.ContextWithTraceID(req.Context(), traceID)))
+	})
+}
+
+// SkynetRequesterHandler is middleware that attaches the source IP of skynet
+// requests to the request's context so that the renter can attribute
+// attempts to download blocked content to a requester.
+func SkynetRequesterHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/skynet/") {
+			h.ServeHTTP(w, req)
+			return
+		}
+		h.ServeHTTP(w, req.WithContext(skymodules.ContextWithRequester(req.Context(), sourceIP(req))))
+	})
+}
+
 // RequireUserAgent is middleware that requires all requests to set a
 // UserAgent that contains the specified string.
 func RequireUserAgent(h http.Handler, ua string) http.Handler {
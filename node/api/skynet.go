@@ -3,19 +3,27 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/tus/tusd/pkg/handler"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/SkynetLabs/skyd/build"
 	"gitlab.com/SkynetLabs/skyd/skykey"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
@@ -28,6 +36,11 @@ import (
 )
 
 const (
+	// BlocklistCompatV143 is the value of the compat query string parameter
+	// of /skynet/blocklist which populates the deprecated blacklist field for
+	// tooling written against v1.4.3.
+	BlocklistCompatV143 = "v143"
+
 	// DefaultSkynetRequestTimeout is the default request timeout for routes
 	// that have a timeout query string parameter. If the request can not be
 	// resolved within the given amount of time, it times out. This is used for
@@ -41,30 +54,174 @@ const (
 	// high timeouts.
 	MaxSkynetRequestTimeout = 15 * time.Minute
 
+	// DefaultSkynetMaxDataURISize is the default maximum size of content that
+	// can be downloaded as a data URI if the renter's SkynetMaxDataURISize
+	// setting is 0.
+	DefaultSkynetMaxDataURISize = 1 << 18 // 256 KiB
+
+	// DefaultSkynetSearchLimit is the default number of matches returned by
+	// /skynet/search.
+	DefaultSkynetSearchLimit = 100
+
+	// MaxSkynetSearchLimit is the maximum number of matches that can be
+	// requested from /skynet/search at once.
+	MaxSkynetSearchLimit = 1000
+
+	// MaxRegistryBatchSize is the maximum number of entries that can be
+	// updated with a single call to /skynet/registry/batch.
+	MaxRegistryBatchSize = 50
+
+	// RegistryBatchErrorDataTooBig is the error code for an entry whose data
+	// exceeds the maximum registry data size.
+	RegistryBatchErrorDataTooBig = "datatoobig"
+
+	// RegistryBatchErrorInsufficientWork is the error code for an entry that
+	// can't replace the existing entry due to insufficient work.
+	RegistryBatchErrorInsufficientWork = "insufficientwork"
+
+	// RegistryBatchErrorLowerRevNum is the error code for an entry with a
+	// lower revision number than the existing entry.
+	RegistryBatchErrorLowerRevNum = "lowerrevnum"
+
+	// RegistryBatchErrorSameRevNum is the error code for an entry with the
+	// same revision number as the existing entry.
+	RegistryBatchErrorSameRevNum = "samerevnum"
+
+	// RegistryBatchErrorTimeout is the error code for an entry that couldn't
+	// be updated before the deadline.
+	RegistryBatchErrorTimeout = "timeout"
+
+	// RegistryBatchErrorUnknown is the error code for an entry that failed to
+	// be updated for any other reason.
+	RegistryBatchErrorUnknown = "unknown"
+
+	// RegistryBatchErrorUnknownType is the error code for an entry with an
+	// unknown entry type.
+	RegistryBatchErrorUnknownType = "unknowntype"
+
+	// RegistryBatchErrorMalformedData is the error code for an entry whose
+	// data doesn't match the format required by its entry type.
+	RegistryBatchErrorMalformedData = "malformeddata"
+
+	// RegistryEntryTypeInvalid, RegistryEntryTypeWithoutPubkey,
+	// RegistryEntryTypeWithPubkey and RegistryEntryTypeUnknown are the
+	// detected types of a registry entry returned by /skynet/registry [GET].
+	RegistryEntryTypeInvalid       = "invalid"
+	RegistryEntryTypeWithoutPubkey = "withoutpubkey"
+	RegistryEntryTypeWithPubkey    = "withpubkey"
+	RegistryEntryTypeUnknown       = "unknown"
+
 	// RegistrySubscriptionNotificationSize is the estimated bandwidth
 	// involved when receiving a subscription notification from the hosts on
 	// the network. It's a result of the size of a single notification and
 	// the expected number of hosts on the network getting updated.
 	RegistrySubscriptionNotificationSize = 1 << 16 // 64 kib
 
+	// SkynetBaseHrefHeader holds the location which relative paths within
+	// the served content should be resolved against. It is only set if the
+	// trailing-slash redirect was suppressed with the 'no-redirect'
+	// parameter.
+	SkynetBaseHrefHeader = "Skynet-Base-Href"
+
+	// SkynetDefaultPathResolvedHeader holds the path of a skyfile which was
+	// served after resolving the defaultpath and tryfiles.
+	SkynetDefaultPathResolvedHeader = "Skynet-Default-Path-Resolved"
+
+	// SkynetDefaultPathReasonHeader describes why the path in the
+	// Skynet-Default-Path-Resolved header was served.
+	SkynetDefaultPathReasonHeader = "Skynet-Default-Path-Reason"
+
+	// SkynetBandwidthUsedTrailer holds the total host bandwidth in bytes,
+	// including overhead, which was consumed to serve the downloaded data if
+	// it was requested.
+	SkynetBandwidthUsedTrailer = "Skynet-Bandwidth-Used"
+
+	// SkynetChecksumTrailer holds the hex encoded checksum of the served data
+	// if a checksum was requested.
+	SkynetChecksumTrailer = "Skynet-Checksum"
+
+	// SkynetContentHashTrailer holds the hex encoded blake2b hash of the
+	// exact bytes of the response body. It is attached if the client accepts
+	// trailers or requested a blake2b checksum.
+	SkynetContentHashTrailer = "Skynet-Content-Hash"
+
+	// SkynetDegradedHeader is set to true if the served data was downloaded
+	// with fewer usable workers than pieces needed to recover it. This is
+	// only possible if degraded downloads are enabled in the renter settings.
+	SkynetDegradedHeader = "Skynet-Degraded"
+
 	// SkynetDisableForceHeader allows disabling the force-update feature.
 	SkynetDisableForceHeader = "Skynet-Disable-Force"
 
+	// SkynetDownloadRetriesHeader holds the number of times the node
+	// internally retried a download because its worker pool was temporarily
+	// degraded.
+	SkynetDownloadRetriesHeader = "Skynet-Download-Retries"
+
+	// SkynetForceTokenHeader holds an encoded JSON object with a force token
+	// signed with the key of the renter's 'skynetforcetokenkey' setting. It
+	// allows forcing a request even if 'Skynet-Disable-Force' is set.
+	SkynetForceTokenHeader = "Skynet-Force-Token"
+
 	// SkynetFileLayoutHeader holds the layout of this skyfile.
 	SkynetFileLayoutHeader = "Skynet-File-Layout"
 
+	// SkynetHostStatsTrailer holds an encoded JSON array with the stats of
+	// the hosts which served the downloaded data if they were requested. For
+	// HEAD requests and downloads by root it is sent as a regular header.
+	SkynetHostStatsTrailer = "Skynet-Host-Stats"
+
 	// SkynetFileMetadataHeader holds an encoded JSON object with the metadata
 	// of the skyfile *or* the subdirectory of the skyfile that has been
 	// requested.
 	SkynetFileMetadataHeader = "Skynet-File-Metadata"
 
+	// SkynetMissingRangesHeader holds an encoded JSON array with the ranges
+	// of the requested content which couldn't be recovered and were omitted
+	// from a partial response.
+	SkynetMissingRangesHeader = "Skynet-Missing-Ranges"
+
+	// SkynetPartHashesHeader holds an encoded JSON object which maps the
+	// filenames of the parts of a resumable multipart upload to the hex
+	// encoded blake2b hashes of their data.
+	SkynetPartHashesHeader = "Skynet-Part-Hashes"
+
+	// SkynetPreviewHeader holds the full size of the requested content if
+	// only a preview of it was served.
+	SkynetPreviewHeader = "Skynet-Preview"
+
 	// SkynetProofHeader holds an encoded JSON object with the registry proofs
 	// for this skylink.
 	SkynetProofHeader = "Skynet-Proof"
 
+	// SkynetServingProofHeader holds an encoded JSON object with a proof
+	// signed by the portal that it served the response's content. It is sent
+	// as a trailer if the proof covers the hash of the response body.
+	SkynetServingProofHeader = "Skynet-Serving-Proof"
+
+	// SkynetSkykeyHeader holds a skykey in its base64 string representation
+	// which is used to decrypt a single download. The skykey is never
+	// persisted by the node.
+	SkynetSkykeyHeader = "X-Skynet-Skykey"
+
 	// SkynetSkylinkHeader is a string representation of the base64 encoded
 	// v1 Skylink that was served.
 	SkynetSkylinkHeader = "Skynet-Skylink"
+
+	// SkynetSkylinkHintHeader holds the skylink of an upload before the
+	// upload is complete. It is sent within a 103 Early Hints response if
+	// requested.
+	SkynetSkylinkHintHeader = "Skynet-Skylink-Hint"
+
+	// SkynetTraceIDHeader holds the trace ID of a request. It can be set by
+	// the caller and is generated by the node otherwise. The node echoes it
+	// in the response and tags the log lines of the request with it.
+	SkynetTraceIDHeader = "Skynet-Trace-Id"
+
+	// SkynetUploadSessionHeader holds the id of a resumable multipart upload.
+	// The parts of a failed upload are staged under that id and can be
+	// replaced by zero-length placeholders when the upload is retried.
+	SkynetUploadSessionHeader = "Skynet-Upload-Session"
 )
 
 type (
@@ -74,12 +231,211 @@ type (
 		Hosts []skymodules.HostForRegistryUpdate `json:"hosts"`
 	}
 
+	// SkynetBaseSectorEncryptedError is the error the api returns together
+	// with a 403 if an encrypted base sector can't be decrypted. SkykeyID is
+	// the key ID from the layout of the base sector. For skykeys of type
+	// private-id it is an encrypted identifier which can only be matched by
+	// the owner of the skykey.
+	SkynetBaseSectorEncryptedError struct {
+		Message   string `json:"message"`
+		Encrypted bool   `json:"encrypted"`
+		SkykeyID  string `json:"skykeyid"`
+		TraceID   string `json:"traceid,omitempty"`
+	}
+
+	// SkynetSkyfileUploadError is the error the api returns if a skyfile
+	// upload failed after its skylink was already computed. Resumable is set
+	// if the failure wasn't caused by the upload itself, in which case
+	// retrying the upload will result in the same skylink.
+	SkynetSkyfileUploadError struct {
+		Message   string `json:"message"`
+		Skylink   string `json:"skylink"`
+		ModTime   int64  `json:"modtime,omitempty"`
+		Resumable bool   `json:"resumable"`
+		TraceID   string `json:"traceid,omitempty"`
+	}
+
+	// SkynetStorageCapExceededError is the error the api returns together
+	// with a 507 if an upload or pin is rejected because it would exceed the
+	// skynet storage cap. Incoming is 0 if the size of the upload wasn't
+	// known in advance.
+	SkynetStorageCapExceededError struct {
+		Message  string `json:"message"`
+		Cap      uint64 `json:"cap"`
+		Used     uint64 `json:"used"`
+		Incoming uint64 `json:"incoming"`
+		TraceID  string `json:"traceid,omitempty"`
+	}
+
 	// SkynetSkyfileHandlerPOST is the response that the api returns after the
 	// /skynet/ POST endpoint has been used.
 	SkynetSkyfileHandlerPOST struct {
 		Skylink    string      `json:"skylink"`
 		MerkleRoot crypto.Hash `json:"merkleroot"`
 		Bitfield   uint16      `json:"bitfield"`
+
+		// Version is the version of the skylink. FetchSize is the amount of
+		// data the skylink points to and is only set for V1 skylinks.
+		Version   uint16 `json:"version"`
+		FetchSize uint64 `json:"fetchsize,omitempty"`
+
+		// ModTime is the modification time stored in the metadata of the
+		// skyfile. If the uploader didn't specify one, it's the time of the
+		// upload. Passing it to another upload of the same data, e.g. after
+		// a dry run, results in the same skylink.
+		ModTime int64 `json:"modtime,omitempty"`
+
+		// Layout and Metadata are only set if the upload was requested with
+		// 'include-metadata'.
+		Layout   *SkyfileLayoutSummary       `json:"layout,omitempty"`
+		Metadata *skymodules.SkyfileMetadata `json:"metadata,omitempty"`
+	}
+
+	// SkyfileLayoutSummary summarizes the layout of a skyfile.
+	SkyfileLayoutSummary struct {
+		CipherType         string `json:"ciphertype"`
+		FanoutDataPieces   uint8  `json:"fanoutdatapieces"`
+		FanoutParityPieces uint8  `json:"fanoutparitypieces"`
+		FanoutSize         uint64 `json:"fanoutsize"`
+		Filesize           uint64 `json:"filesize"`
+		MetadataSize       uint64 `json:"metadatasize"`
+	}
+
+	// SkynetPinHandlerPOST is the response that the api returns after the
+	// /skynet/pin/:skylink POST endpoint has been used. ExtendedSiaPath is
+	// only set if the fanout of the skyfile was pinned as well.
+	SkynetPinHandlerPOST struct {
+		Skylink         string              `json:"skylink"`
+		SiaPath         skymodules.SiaPath  `json:"siapath"`
+		ExtendedSiaPath *skymodules.SiaPath `json:"extendedsiapath,omitempty"`
+		Size            uint64              `json:"size"`
+		Redundancy      float64             `json:"redundancy"`
+		PinExpiry       time.Time           `json:"pinexpiry"`
+	}
+
+	// SkynetPinnedGET is the response of the /skynet/pinned GET endpoint. It
+	// lists the skyfiles pinned to the node.
+	SkynetPinnedGET struct {
+		Pins []skymodules.SkynetPin `json:"pins"`
+	}
+
+	// SkynetPinManifestSummary is the first line of the response of the
+	// /skynet/pin/manifest POST endpoint. It summarizes the skylinks listed
+	// in the manifest.
+	SkynetPinManifestSummary struct {
+		ToPin         uint64 `json:"topin"`
+		AlreadyPinned uint64 `json:"alreadypinned"`
+		Blocked       uint64 `json:"blocked"`
+		Invalid       uint64 `json:"invalid"`
+	}
+
+	// SkynetPinManifestProgress is streamed by the /skynet/pin/manifest POST
+	// endpoint for every skylink of the manifest once it was pinned or
+	// failed to be pinned.
+	SkynetPinManifestProgress struct {
+		Skylink string             `json:"skylink"`
+		SiaPath skymodules.SiaPath `json:"siapath"`
+		Error   string             `json:"error,omitempty"`
+	}
+
+	// SkynetManifestGET is the response that the api returns for the
+	// /skynet/manifest/:skylink GET endpoint. It lists all the subfiles of a
+	// skyfile sorted by offset.
+	SkynetManifestGET struct {
+		Skylink  string                  `json:"skylink"`
+		Subfiles []SkynetManifestSubfile `json:"subfiles"`
+	}
+
+	// SkynetManifestSubfile describes a single subfile within a skyfile
+	// manifest. The hash is the blake2b hash of the subfile's content and is
+	// only set if hashes were requested.
+	SkynetManifestSubfile struct {
+		Path        string       `json:"path"`
+		Length      uint64       `json:"length"`
+		Offset      uint64       `json:"offset"`
+		ContentType string       `json:"contenttype"`
+		Mode        os.FileMode  `json:"mode"`
+		Hash        *crypto.Hash `json:"hash,omitempty"`
+	}
+
+	// SkynetDiffPOST is the response that the api returns after the
+	// /skynet/diff POST endpoint has been used. It lists the subfiles which
+	// were added, removed or changed between two skyfiles.
+	SkynetDiffPOST struct {
+		Added   []skymodules.SkyfileSubfileMetadata `json:"added"`
+		Removed []skymodules.SkyfileSubfileMetadata `json:"removed"`
+		Changed []SkynetSubfileDiff                 `json:"changed"`
+	}
+
+	// SkynetSubfileDiff describes how a subfile that exists in both skyfiles
+	// of a diff changed.
+	SkynetSubfileDiff struct {
+		Filename       string `json:"filename"`
+		OldLen         uint64 `json:"oldlen"`
+		NewLen         uint64 `json:"newlen"`
+		OldContentType string `json:"oldcontenttype"`
+		NewContentType string `json:"newcontenttype"`
+	}
+
+	// SkynetCanonicalizeGET contains the canonical form of a skylink returned
+	// by the /skynet/canonicalize GET endpoint.
+	SkynetCanonicalizeGET struct {
+		Skylink string `json:"skylink"`
+	}
+
+	// SkynetConvertHandlerPOST is the response that the api returns after
+	// the /skynet/skyfile POST endpoint has been used to start an asynchronous
+	// siafile conversion.
+	SkynetConvertHandlerPOST struct {
+		ID string `json:"id"`
+	}
+
+	// SkynetUploadBeginPOST is the response that the api returns after the
+	// /skynet/upload/begin POST endpoint has been used to upload the data of
+	// a skyfile which is still waiting for its metadata.
+	SkynetUploadBeginPOST struct {
+		ID string `json:"id"`
+	}
+
+	// SkynetPrefetchHandlerPOST is the response that the api returns after
+	// the /skynet/prefetch/:skylink POST endpoint has been used to start a
+	// prefetch.
+	SkynetPrefetchHandlerPOST struct {
+		ID string `json:"id"`
+	}
+
+	// SkynetSkylinkIndexGET is the response that the api returns when a skylink
+	// is requested with the 'index' format.
+	SkynetSkylinkIndexGET struct {
+		Path     string                    `json:"path"`
+		Subfiles []SkynetSkylinkIndexEntry `json:"subfiles"`
+	}
+
+	// SkynetSkylinkIndexEntry describes a single subfile within a skylink
+	// index listing.
+	SkynetSkylinkIndexEntry struct {
+		Filename    string `json:"filename"`
+		Size        uint64 `json:"size"`
+		ContentType string `json:"contenttype"`
+		Link        string `json:"link"`
+	}
+
+	// SkynetAllowlistGET contains the information queried for the
+	// /skynet/allowlist GET endpoint. The allowlist contains the hashes of the
+	// approved MerkleRoots.
+	SkynetAllowlistGET struct {
+		Allowlist []crypto.Hash `json:"allowlist"`
+	}
+
+	// SkynetAllowlistPOST contains the information needed for the
+	// /skynet/allowlist POST endpoint to be called
+	SkynetAllowlistPOST struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+
+		// IsHash indicates if the supplied Add and Remove strings are already
+		// hashes of Skylinks
+		IsHash bool `json:"ishash"`
 	}
 
 	// SkynetBlocklistGET contains the information queried for the
@@ -89,10 +445,25 @@ type (
 	// the []crypto.Hash was a slice of MerkleRoots. Post v1.5.0 the []crypto.Hash
 	// is a slice of the Hashes of the MerkleRoots
 	SkynetBlocklistGET struct {
-		Blacklist []crypto.Hash `json:"blacklist"` // Deprecated, kept for backwards compatibility
+		// Deprecated, kept for backwards compatibility. It is only populated
+		// for requests to /skynet/blacklist or with compat=v143 set.
+		Blacklist []crypto.Hash `json:"blacklist"`
 		Blocklist []crypto.Hash `json:"blocklist"`
 	}
 
+	// SkynetBlocklistHitsGET contains the most recent attempts to download
+	// blocked content.
+	SkynetBlocklistHitsGET struct {
+		Hits []skymodules.SkynetBlocklistHit `json:"hits"`
+	}
+
+	// SkynetTraceGET contains the most recent log lines which were tagged
+	// with a trace ID.
+	SkynetTraceGET struct {
+		TraceID string                        `json:"traceid"`
+		Entries []skymodules.SkynetTraceEntry `json:"entries"`
+	}
+
 	// SkynetBlocklistPOST contains the information needed for the
 	// /skynet/blocklist POST endpoint to be called
 	SkynetBlocklistPOST struct {
@@ -104,6 +475,12 @@ type (
 		IsHash bool `json:"ishash"`
 	}
 
+	// SkynetPortalKeyGET contains the public key which the portal signs
+	// serving proofs with.
+	SkynetPortalKeyGET struct {
+		PublicKey types.SiaPublicKey `json:"publickey"`
+	}
+
 	// SkynetPortalsGET contains the information queried for the /skynet/portals
 	// GET endpoint.
 	SkynetPortalsGET struct {
@@ -162,22 +539,27 @@ type (
 		StreamBufferRead15mP999ms     float64 `json:"streambufferread15mp999ms"`
 		StreamBufferRead15mP9999ms    float64 `json:"streambufferread15mp9999ms"`
 
+		// Download stats per download format.
+		Formats SkynetFormatsStats `json:"formats"`
+
 		// The amount of computational time that it takes the health loop to
 		// scan the entire filesystem. Unit is given in hours.
 		SystemHealthScanDurationHours float64 `json:"systemhealthscandurationhours"`
 
 		// General Statuses
-		AllowanceStatus     string         `json:"allowancestatus"` // 'low', 'good', 'high'
-		ContractStorage     uint64         `json:"contractstorage"` // bytes
-		MaxHealthPercentage float64        `json:"maxhealthpercentage"`
-		MaxStoragePrice     types.Currency `json:"maxstorageprice"` // Hastings per byte per block
-		NumCritAlerts       int            `json:"numcritalerts"`
-		NumFiles            uint64         `json:"numfiles"`
-		PortalMode          bool           `json:"portalmode"`
-		Repair              uint64         `json:"repair"`  // bytes
-		Storage             uint64         `json:"storage"` // bytes
-		StuckChunks         uint64         `json:"stuckchunks"`
-		WalletStatus        string         `json:"walletstatus"` // 'low', 'good', 'high'
+		AllowanceStatus     string                       `json:"allowancestatus"` // 'low', 'good', 'high'
+		ContractStorage     uint64                       `json:"contractstorage"` // bytes
+		Maintenance         skymodules.SkynetMaintenance `json:"maintenance"`
+		MaxHealthPercentage float64                      `json:"maxhealthpercentage"`
+		MaxStoragePrice     types.Currency               `json:"maxstorageprice"` // Hastings per byte per block
+		NumCritAlerts       int                          `json:"numcritalerts"`
+		NumFiles            uint64                       `json:"numfiles"`
+		PortalMode          bool                         `json:"portalmode"`
+		RawStorage          uint64                       `json:"rawstorage"` // bytes
+		Repair              uint64                       `json:"repair"`     // bytes
+		Storage             uint64                       `json:"storage"`    // bytes
+		StuckChunks         uint64                       `json:"stuckchunks"`
+		WalletStatus        string                       `json:"walletstatus"` // 'low', 'good', 'high'
 
 		// Update and version information.
 		Uptime      int64         `json:"uptime"`
@@ -190,6 +572,37 @@ type (
 		GitRevision string `json:"gitrevision"`
 	}
 
+	// SkynetWorkersGET contains a skynet focused summary of the renter's
+	// worker pool.
+	SkynetWorkersGET struct {
+		NumWorkers               int `json:"numworkers"`
+		NumDownloadWorkers       int `json:"numdownloadworkers"`
+		NumUploadWorkers         int `json:"numuploadworkers"`
+		TotalDownloadCoolDown    int `json:"totaldownloadcooldown"`
+		TotalMaintenanceCoolDown int `json:"totalmaintenancecooldown"`
+		TotalUploadCoolDown      int `json:"totaluploadcooldown"`
+
+		TotalAvailableBalance types.Currency `json:"totalavailablebalance"`
+		TotalNegativeBalance  types.Currency `json:"totalnegativebalance"`
+
+		Workers []SkynetWorkerGET `json:"workers"`
+	}
+
+	// SkynetWorkerGET contains the skynet relevant status of a single worker.
+	SkynetWorkerGET struct {
+		HostPubKey types.SiaPublicKey `json:"hostpubkey"`
+
+		DownloadUsable bool `json:"downloadusable"`
+		UploadUsable   bool `json:"uploadusable"`
+
+		DownloadOnCoolDown    bool `json:"downloadoncooldown"`
+		MaintenanceOnCoolDown bool `json:"maintenanceoncooldown"`
+		UploadOnCoolDown      bool `json:"uploadoncooldown"`
+
+		AvailableBalance types.Currency `json:"availablebalance"`
+		NegativeBalance  types.Currency `json:"negativebalance"`
+	}
+
 	// SkykeyGET contains a base64 encoded Skykey.
 	SkykeyGET struct {
 		Skykey string `json:"skykey"` // base64 encoded Skykey
@@ -198,24 +611,46 @@ type (
 		Type   string `json:"type"` // human-readable Skykey Type
 	}
 
+	// SkykeyExportPOST contains a password protected skykey.
+	SkykeyExportPOST struct {
+		Export string `json:"export"` // base64 encoded password protected Skykey
+		Name   string `json:"name"`
+		ID     string `json:"id"` // base64 encoded Skykey ID
+	}
+
+	// SkykeyMatchGET contains the name, ID and type of the skykey that a
+	// skyfile is encrypted with.
+	SkykeyMatchGET struct {
+		Name string `json:"name"`
+		ID   string `json:"id"`   // base64 encoded Skykey ID
+		Type string `json:"type"` // human-readable Skykey Type
+	}
+
 	// SkykeysGET contains a slice of Skykeys.
 	SkykeysGET struct {
 		Skykeys []SkykeyGET `json:"skykeys"`
 	}
 
 	// RegistryHandlerGET is the response returned by the registryHandlerGET
-	// handler.
+	// handler. PrimaryWork is set for entries which start with the partial
+	// hash of a host's pubkey. Their work is computed without the hash and
+	// they take precedence over entries with the same revision and work on
+	// the host they were intended for.
 	RegistryHandlerGET struct {
-		Data      string                    `json:"data"`
-		Revision  uint64                    `json:"revision"`
-		DataKey   crypto.Hash               `json:"datakey"`
-		PublicKey types.SiaPublicKey        `json:"publickey"`
-		Signature string                    `json:"signature"`
-		Type      modules.RegistryEntryType `json:"type"`
+		Data         string                    `json:"data"`
+		Revision     uint64                    `json:"revision"`
+		DataKey      crypto.Hash               `json:"datakey"`
+		PublicKey    types.SiaPublicKey        `json:"publickey"`
+		Signature    string                    `json:"signature"`
+		Type         modules.RegistryEntryType `json:"type"`
+		DetectedType string                    `json:"detectedtype"`
+		PrimaryWork  bool                      `json:"primarywork"`
 	}
 
 	// RegistryHandlerRequestPOST is the expected format of the json request for
-	// /skynet/registry [POST].
+	// /skynet/registry [POST]. If KeyName is set, the renter signs the update
+	// with the stored registry key of that name and neither the signature nor
+	// the public key need to be provided.
 	RegistryHandlerRequestPOST struct {
 		PublicKey types.SiaPublicKey        `json:"publickey"`
 		DataKey   crypto.Hash               `json:"datakey"`
@@ -223,6 +658,100 @@ type (
 		Signature crypto.Signature          `json:"signature"`
 		Data      []byte                    `json:"data"`
 		Type      modules.RegistryEntryType `json:"type"`
+		KeyName   string                    `json:"keyname,omitempty"`
+	}
+
+	// SkynetPublishRequestPOST is the expected format of the json request for
+	// /skynet/publish [POST].
+	SkynetPublishRequestPOST struct {
+		KeyName   string             `json:"keyname"`
+		PublicKey types.SiaPublicKey `json:"publickey"`
+		DataKey   crypto.Hash        `json:"datakey"`
+		Skylink   string             `json:"skylink"`
+	}
+
+	// SkynetPublishPOST is the response returned by the /skynet/publish
+	// [POST] endpoint.
+	SkynetPublishPOST struct {
+		Skylink  string `json:"skylink"`
+		Revision uint64 `json:"revision"`
+	}
+
+	// SkynetGCRequestPOST is the expected format of the json request for
+	// /skynet/gc [POST].
+	SkynetGCRequestPOST struct {
+		DryRun        bool   `json:"dryrun"`
+		ExcludePrefix string `json:"excludeprefix"`
+		OlderThan     uint64 `json:"olderthan"` // in seconds
+	}
+
+	// SkynetGCPOST is the response returned by the /skynet/gc [POST]
+	// endpoint.
+	SkynetGCPOST struct {
+		skymodules.SkynetGCResult
+	}
+
+	// SkynetSnapshotPOST is the response returned by the /skynet/snapshot
+	// [POST] endpoint.
+	SkynetSnapshotPOST struct {
+		skymodules.SkynetSnapshot
+	}
+
+	// SkynetSnapshotDiffGET is the response returned by the
+	// /skynet/snapshot/diff [GET] endpoint.
+	SkynetSnapshotDiffGET struct {
+		skymodules.SkynetSnapshotDiff
+	}
+
+	// SkynetSearchGET is the response returned by the /skynet/search [GET]
+	// endpoint.
+	SkynetSearchGET struct {
+		Results []skymodules.SkynetSearchResult `json:"results"`
+		Total   uint64                          `json:"total"`
+	}
+
+	// RegistryLargeGET is the response returned by the
+	// /skynet/registry/large [GET] endpoint. The data is hex encoded.
+	RegistryLargeGET struct {
+		Data     string `json:"data"`
+		Revision uint64 `json:"revision"`
+	}
+
+	// RegistryLargeRequestPOST is the expected format of the json request
+	// for /skynet/registry/large [POST].
+	RegistryLargeRequestPOST struct {
+		KeyName string      `json:"keyname"`
+		DataKey crypto.Hash `json:"datakey"`
+		Data    []byte      `json:"data"`
+	}
+
+	// RegistryLargePOST is the response returned by the
+	// /skynet/registry/large [POST] endpoint.
+	RegistryLargePOST struct {
+		Revision uint64 `json:"revision"`
+	}
+
+	// RegistryKeysGET is the response returned by the /skynet/registry/key
+	// [GET] endpoint.
+	RegistryKeysGET struct {
+		Keys []skymodules.RegistryKey `json:"keys"`
+	}
+
+	// RegistryHandlerBatchPOST is the response returned by the
+	// /skynet/registry/batch [POST] endpoint. It contains one result per
+	// requested entry in the same order as the request.
+	RegistryHandlerBatchPOST struct {
+		Results []RegistryBatchResult `json:"results"`
+	}
+
+	// RegistryBatchResult is the result of a single update within a batch of
+	// registry updates. Failed updates contain an error code and the http
+	// status code a single update would have returned.
+	RegistryBatchResult struct {
+		Success    bool   `json:"success"`
+		StatusCode int    `json:"statuscode"`
+		ErrorCode  string `json:"errorcode,omitempty"`
+		Error      string `json:"error,omitempty"`
 	}
 
 	// RegistryHandlerMultiRequestPOST is the expected format of the json request for
@@ -239,13 +768,16 @@ type (
 	}
 
 	// archiveFunc is a function that serves subfiles from src to dst and
-	// archives them using a certain algorithm.
-	archiveFunc func(dst io.Writer, src io.Reader, files []skymodules.SkyfileSubfileMetadata) error
+	// archives them using a certain algorithm. Every entry of the archive
+	// gets the given modification time.
+	archiveFunc func(dst io.Writer, src archiveSource, files []skymodules.SkyfileSubfileMetadata, modTime time.Time) error
 )
 
 // skynetBaseSectorHandlerGET accepts a skylink as input and will return the
 // encoded basesector.
 func (api *API) skynetBaseSectorHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	start := time.Now()
+
 	// Parse the skylink from the raw URL of the request. Any special characters
 	// in the raw URL are encoded, allowing us to differentiate e.g. the '?'
 	// that begins query parameters from the encoded version '%3F'.
@@ -263,7 +795,8 @@ func (api *API) skynetBaseSectorHandlerGET(w http.ResponseWriter, req *http.Requ
 	}
 
 	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -280,8 +813,27 @@ func (api *API) skynetBaseSectorHandlerGET(w http.ResponseWriter, req *http.Requ
 		}
 	}
 
+	// Parse the 'decrypt' param.
+	var decrypt bool
+	decryptStr := queryForm.Get("decrypt")
+	if decryptStr != "" {
+		decrypt, err = strconv.ParseBool(decryptStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'decrypt' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	sk, tryKeys, err := api.parseBaseSectorDecryptionParams(queryForm)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
 	// Fetch the skyfile's streamer to serve the basesector of the file
-	streamer, srvs, _, err := api.renter.DownloadSkylinkBaseSector(skylink, timeout, pricePerMS)
+	renterRetries := new(skymodules.DownloadRetries)
+	ctx := skymodules.ContextWithDownloadRetries(req.Context(), renterRetries)
+	streamer, srvs, _, err := api.renter.DownloadSkylinkBaseSector(ctx, skylink, timeout, pricePerMS)
+	w.Header().Set(SkynetDownloadRetriesHeader, fmt.Sprint(renterRetries.Load()))
 	if err != nil {
 		handleSkynetError(w, "failed to fetch base sector", err)
 		return
@@ -299,14 +851,128 @@ func (api *API) skynetBaseSectorHandlerGET(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	// Decrypt the basesector if requested.
+	var content io.ReadSeeker = streamer
+	if decrypt {
+		baseSector, err := ioutil.ReadAll(streamer)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("failed to read base sector: %v", err)}, http.StatusInternalServerError)
+			return
+		}
+		if skymodules.IsEncryptedBaseSector(baseSector) {
+			err = api.decryptBaseSector(baseSector, sk, tryKeys)
+			if errors.Contains(err, ErrBaseSectorEncrypted) && sk != nil {
+				// The caller chose a skykey which doesn't match.
+				writeBaseSectorEncryptedError(w, baseSector, http.StatusBadRequest, err)
+				return
+			}
+			if errors.Contains(err, ErrBaseSectorEncrypted) {
+				writeBaseSectorEncryptedError(w, baseSector, http.StatusForbidden, err)
+				return
+			}
+			if err != nil {
+				WriteError(w, Error{fmt.Sprintf("failed to decrypt base sector: %v", err)}, http.StatusInternalServerError)
+				return
+			}
+		}
+		content = bytes.NewReader(baseSector)
+	}
+
 	// Serve the basesector
-	http.ServeContent(w, req, "", time.Time{}, streamer)
+	w, addToStats := api.trackSkynetDownload(w, req, skynetStatsFormatBaseSector, start)
+	defer addToStats()
+	http.ServeContent(w, req, "", time.Time{}, content)
 	return
 }
 
+// skynetBaseSectorHandlerHEAD accepts a skylink as input and checks whether its
+// basesector is available without downloading it.
+func (api *API) skynetBaseSectorHandlerHEAD(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the skylink from the raw URL of the request.
+	skylink, _, _, err := parseSkylinkURL(req.URL.String(), "/skynet/basesector/")
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check whether the basesector is available.
+	srvs, resolved, err := api.renter.HasSkylinkBaseSector(req.Context(), skylink, timeout)
+	if err != nil {
+		handleSkynetError(w, "failed to fetch base sector", err)
+		return
+	}
+	_, fetchSize, err := resolved.OffsetAndFetchSize()
+	if err != nil {
+		WriteError(w, Error{"unable to get fetch size: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// Attach proof.
+	err = attachRegistryEntryProof(w, srvs)
+	if err != nil {
+		WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(SkynetSkylinkHeader, resolved.String())
+	w.Header().Set("Content-Length", strconv.FormatUint(fetchSize, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
 // skynetBlocklistHandlerGET handles the API call to get the list of blocked
 // skylinks.
-func (api *API) skynetBlocklistHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+func (api *API) skynetBlocklistHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Check whether the legacy blacklist should be populated.
+	var compat bool
+	switch c := queryForm.Get("compat"); c {
+	case "":
+	case BlocklistCompatV143:
+		compat = true
+	default:
+		WriteError(w, Error{fmt.Sprintf("unknown compat version '%v'", c)}, http.StatusBadRequest)
+		return
+	}
+	api.writeBlocklist(w, compat)
+}
+
+// skynetBlacklistHandlerGET handles the deprecated /skynet/blacklist endpoint.
+// It returns the blocklist in the legacy response shape which populates the
+// blacklist field as well.
+func (api *API) skynetBlacklistHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.Header().Set("Deprecation", "true")
+	api.writeBlocklist(w, true)
+}
+
+// writeBlocklist writes the blocklist to the response. If compat is set, the
+// deprecated blacklist field is populated as well.
+//
+// NOTE: the blocklist only contains the hashes of the merkleroots, so the
+// merkleroots that pre v1.5.0 nodes returned can't be recovered. The hashes
+// are returned instead, which is what the blocklist persistence was upgraded
+// to.
+func (api *API) writeBlocklist(w http.ResponseWriter, compat bool) {
 	// Get the Blocklist
 	blocklist, err := api.renter.Blocklist()
 	if err != nil {
@@ -314,13 +980,32 @@ func (api *API) skynetBlocklistHandlerGET(w http.ResponseWriter, _ *http.Request
 		return
 	}
 
-	WriteJSON(w, SkynetBlocklistGET{
+	sbg := SkynetBlocklistGET{
 		Blocklist: blocklist,
+	}
+	if compat {
+		sbg.Blacklist = blocklist
+	}
+	WriteJSON(w, sbg)
+}
+
+// skynetAllowlistHandlerGET handles the API call to get the list of approved
+// skylinks.
+func (api *API) skynetAllowlistHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	allowlist, err := api.renter.Allowlist()
+	if err != nil {
+		WriteError(w, Error{"unable to get the allowlist: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSON(w, SkynetAllowlistGET{
+		Allowlist: allowlist,
 	})
 }
 
-// skynetBlocklistHandlerPOST handles the API call to block certain skylinks.
-func (api *API) skynetBlocklistHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+// skynetAllowlistHandlerPOST handles the API call to approve or disapprove
+// certain skylinks.
+func (api *API) skynetAllowlistHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Parse the query params.
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
@@ -329,7 +1014,7 @@ func (api *API) skynetBlocklistHandlerPOST(w http.ResponseWriter, req *http.Requ
 	}
 
 	// Parse parameters
-	var params SkynetBlocklistPOST
+	var params SkynetAllowlistPOST
 	err = json.NewDecoder(req.Body).Decode(&params)
 	if err != nil {
 		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
@@ -343,7 +1028,8 @@ func (api *API) skynetBlocklistHandlerPOST(w http.ResponseWriter, req *http.Requ
 	}
 
 	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -353,290 +1039,315 @@ func (api *API) skynetBlocklistHandlerPOST(w http.ResponseWriter, req *http.Requ
 	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 
-	// Update the Skynet Blocklist
-	err = api.renter.UpdateSkynetBlocklist(ctx, params.Add, params.Remove, params.IsHash)
+	// Update the Skynet Allowlist
+	err = api.renter.UpdateSkynetAllowlist(ctx, params.Add, params.Remove, params.IsHash)
 	if err != nil {
-		WriteError(w, Error{"unable to update the skynet blocklist: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{"unable to update the skynet allowlist: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
 
 	WriteSuccess(w)
 }
 
-// skynetPortalsHandlerGET handles the API call to get the list of known skynet
-// portals.
-func (api *API) skynetPortalsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
-	// Get the list of portals.
-	portals, err := api.renter.Portals()
+// skynetCanonicalizeHandlerGET handles the API call to convert a skylink into
+// its canonical form.
+func (api *API) skynetCanonicalizeHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	skylink, _, _, err := parseSkylinkURL(req.URL.String(), "/skynet/canonicalize/")
 	if err != nil {
-		WriteError(w, Error{"unable to get the portals list: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
-
-	WriteJSON(w, SkynetPortalsGET{
-		Portals: portals,
+	WriteJSON(w, SkynetCanonicalizeGET{
+		Skylink: skylink.String(),
 	})
 }
 
-// skynetPortalsHandlerPOST handles the API call to add and remove portals from
-// the list of known skynet portals.
-func (api *API) skynetPortalsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse parameters.
-	var params SkynetPortalsPOST
-	err := json.NewDecoder(req.Body).Decode(&params)
-	if err != nil {
-		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
-		return
-	}
-
-	// Update the list of known skynet portals.
-	err = api.renter.UpdateSkynetPortals(params.Add, params.Remove)
+// skynetBlocklistHitsHandlerGET handles the API call to get the most recent
+// attempts to download blocked content.
+func (api *API) skynetBlocklistHitsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	hits, err := api.renter.BlocklistHits()
 	if err != nil {
-		// If validation fails, return a bad request status.
-		errStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), skynetportals.ErrSkynetPortalsValidation.Error()) {
-			errStatus = http.StatusBadRequest
-		}
-		WriteError(w, Error{"unable to update the list of known skynet portals: " + err.Error()}, errStatus)
+		WriteError(w, Error{"unable to get the blocklist hits: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	WriteSuccess(w)
+	WriteJSON(w, SkynetBlocklistHitsGET{
+		Hits: hits,
+	})
 }
 
-// skynetRootHandlerGET handles the api call for a download by root request.
-// This call returns the encoded sector.
-func (api *API) skynetRootHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse the query params.
-	queryForm, err := url.ParseQuery(req.URL.RawQuery)
-	if err != nil {
-		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+// skynetTraceHandlerGET handles the API call to get the most recent log lines
+// which were tagged with a trace ID.
+func (api *API) skynetTraceHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	traceID := ps.ByName("id")
+	if err := skymodules.ValidateTraceID(traceID); err != nil {
+		WriteError(w, Error{"invalid trace id: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-
-	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	entries, err := api.renter.SkynetTrace(traceID)
 	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"unable to get the trace: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse the root.
-	rootStr := queryForm.Get("root")
-	if rootStr == "" {
-		WriteError(w, Error{"no root hash provided"}, http.StatusBadRequest)
-		return
-	}
-	var root crypto.Hash
-	err = root.LoadString(rootStr)
+	WriteJSON(w, SkynetTraceGET{
+		TraceID: traceID,
+		Entries: entries,
+	})
+}
+
+// skynetGCHandlerPOST handles the API call to delete the skyfiles which
+// weren't downloaded within a retention window.
+func (api *API) skynetGCHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var sgr SkynetGCRequestPOST
+	err := dec.Decode(&sgr)
 	if err != nil {
-		WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-
-	// Parse the offset.
-	offsetStr := queryForm.Get("offset")
-	if offsetStr == "" {
-		WriteError(w, Error{"no offset provided"}, http.StatusBadRequest)
+	if sgr.OlderThan == 0 {
+		WriteError(w, Error{"'olderthan' needs to be greater than 0"}, http.StatusBadRequest)
 		return
 	}
-	offset, err := strconv.ParseUint(offsetStr, 10, 64)
-	if err != nil {
-		WriteError(w, Error{"unable to parse 'offset' parameter: " + err.Error()}, http.StatusBadRequest)
+	if sgr.OlderThan > uint64(math.MaxInt64/time.Second) {
+		WriteError(w, Error{"'olderthan' is too large"}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse the length.
-	lengthStr := queryForm.Get("length")
-	if lengthStr == "" {
-		WriteError(w, Error{"no length provided"}, http.StatusBadRequest)
-		return
+	// The excluded prefix is relative to the skynet folder.
+	var excludePrefix skymodules.SiaPath
+	if sgr.ExcludePrefix != "" {
+		excludePrefix, err = skymodules.SkynetFolder.Join(sgr.ExcludePrefix)
+		if err != nil {
+			WriteError(w, Error{"invalid 'excludeprefix': " + err.Error()}, http.StatusBadRequest)
+			return
+		}
 	}
-	length, err := strconv.ParseUint(lengthStr, 10, 64)
+
+	olderThan := time.Duration(sgr.OlderThan) * time.Second
+	result, err := api.renter.SkynetGC(olderThan, sgr.DryRun, excludePrefix)
 	if err != nil {
-		WriteError(w, Error{"unable to parse 'length' parameter: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"failed to garbage collect skyfiles: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
+	WriteJSON(w, SkynetGCPOST{result})
+}
 
-	// Parse pricePerMS.
-	pricePerMS := skymodules.DefaultSkynetPricePerMS
-	pricePerMSStr := queryForm.Get("priceperms")
-	if pricePerMSStr != "" {
-		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+// skynetSnapshotHandlerPOST handles the API call to capture the skylinks
+// pinned under a directory in a named snapshot.
+func (api *API) skynetSnapshotHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	name := req.FormValue("name")
+	if name == "" {
+		WriteError(w, Error{"'name' needs to be specified"}, http.StatusBadRequest)
+		return
+	}
+
+	// The directory is relative to the skynet folder.
+	dir := skymodules.SkynetFolder
+	if siaPathStr := req.FormValue("siapath"); siaPathStr != "" {
+		var err error
+		dir, err = skymodules.SkynetFolder.Join(siaPathStr)
 		if err != nil {
-			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			WriteError(w, Error{"invalid 'siapath': " + err.Error()}, http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Fetch the skyfile's  streamer to serve the basesector of the file
-	sector, err := api.renter.DownloadByRoot(root, offset, length, timeout, pricePerMS)
+	snapshot, err := api.renter.SkynetSnapshotCreate(name, dir)
 	if err != nil {
-		handleSkynetError(w, "failed to fetch root", err)
+		handleSkynetError(w, "failed to create skynet snapshot", err)
 		return
 	}
-
-	streamer := renter.StreamerFromSlice(sector)
-	defer func() {
-		// At this point we have already responded so we can't write a potential
-		// error here.
-		_ = streamer.Close()
-	}()
-
-	// Serve the basesector
-	http.ServeContent(w, req, "", time.Time{}, streamer)
-	return
+	WriteJSON(w, SkynetSnapshotPOST{snapshot})
 }
 
-// skynetSkylinkHandlerGET accepts a skylink as input and will stream the data
-// from the skylink out of the response body as output.
-func (api *API) skynetSkylinkHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse the request parameters
-	params, err := parseDownloadRequestParameters(req)
-	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+// skynetSnapshotDiffHandlerGET handles the API call to diff two skynet
+// snapshots. If 'to' is not specified, 'from' is compared against the current
+// state of its directory.
+func (api *API) skynetSnapshotDiffHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	from := req.FormValue("from")
+	if from == "" {
+		WriteError(w, Error{"'from' needs to be specified"}, http.StatusBadRequest)
 		return
 	}
-	path := params.path
-	format := params.format
+	to := req.FormValue("to")
+	if to == "" {
+		to = skymodules.SkynetSnapshotNow
+	}
 
-	// Fetch the skyfile's metadata and a streamer to download the file
-	streamer, srvs, err := api.renter.DownloadSkylink(params.skylink, params.timeout, params.pricePerMS)
+	diff, err := api.renter.SkynetSnapshotDiff(from, to)
 	if err != nil {
-		handleSkynetError(w, "failed to fetch skylink", err)
+		handleSkynetError(w, "failed to diff skynet snapshots", err)
 		return
 	}
-	defer func() {
-		// At this point we have already responded so we can't write a potential
-		// error here.
-		_ = streamer.Close()
-	}()
-
-	metadata := streamer.Metadata()
-	ew := newCustomErrorWriter(metadata, streamer)
+	WriteJSON(w, SkynetSnapshotDiffGET{diff})
+}
 
-	// Attach proof.
-	err = attachRegistryEntryProof(w, srvs)
-	if err != nil {
-		ew.WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
-		return
+// skynetSearchHandlerGET handles the API call to search the metadata of the
+// skyfiles pinned under a directory.
+func (api *API) skynetSearchHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	params := skymodules.SkynetSearchParameters{
+		SiaPath:     skymodules.SkynetFolder,
+		Filename:    req.FormValue("filename"),
+		ContentType: req.FormValue("contenttype"),
+		Limit:       DefaultSkynetSearchLimit,
 	}
 
-	// Only validate default path and tryfiles if the format is not specified,
-	// this way the file can still be downloaded should it have been uploaded
-	// with incorrect metadata, which is possible seeing as it may have been
-	// uploaded by a private portal.
-	if format == skymodules.SkyfileFormatNotSpecified {
-		// The path we actually want to serve based on defaultpath and tryfiles.
-		servePath := metadata.ServePath(path)
-		isMulti := len(metadata.Subfiles) > 1
-		// If we don't have a subPath and the skylink doesn't end with a
-		// trailing slash we need to redirect in order to add the trailing
-		// slash. This is only true for skapps - they need it in order to
-		// properly work with relative paths. We also don't need to redirect if
-		// this is a HEAD request or if it's a download as attachment.
-		if isMulti && path == "/" && servePath != path && !params.attachment && req.Method == http.MethodGet && !strings.HasSuffix(params.skylinkStringNoQuery, "/") {
-			location := params.skylinkStringNoQuery + "/"
-			if req.URL.RawQuery != "" {
-				location += "?" + req.URL.RawQuery
-			}
-			w.Header().Set("Location", location)
-			w.WriteHeader(http.StatusPermanentRedirect)
+	// The directory is relative to the skynet folder.
+	if siaPathStr := req.FormValue("siapath"); siaPathStr != "" {
+		var err error
+		params.SiaPath, err = skymodules.SkynetFolder.Join(siaPathStr)
+		if err != nil {
+			WriteError(w, Error{"invalid 'siapath': " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-		path = servePath
 	}
-	var isSubfile bool
-	// Serve the contents of the skyfile at path if one is set
-	if path != "/" {
-		metadataForPath, isFile, offset, size := metadata.ForPath(path)
-		if len(metadataForPath.Subfiles) == 0 {
-			ew.WriteError(w, Error{fmt.Sprintf("failed to download contents for path: %v", path)}, http.StatusNotFound)
-			return
-		}
-		// NOTE: we don't have an actual raw metadata for the subpath. So we are
-		// marshaling the temporary metadata. This should be good enough since
-		// the metadata can't be used to create a skylink anyway.
-		rawMetadataForPath, err := json.Marshal(metadataForPath)
-		if err != nil {
-			ew.WriteError(w, Error{fmt.Sprintf("failed to marshal subfile metadata for path %v", path)}, http.StatusNotFound)
-			return
+
+	// Parse the numeric parameters.
+	for _, param := range []struct {
+		name  string
+		value *uint64
+	}{
+		{"minsize", &params.MinSize},
+		{"maxsize", &params.MaxSize},
+		{"offset", &params.Offset},
+		{"limit", &params.Limit},
+	} {
+		str := req.FormValue(param.name)
+		if str == "" {
+			continue
 		}
-		streamer, err = NewLimitStreamer(streamer, metadataForPath, rawMetadataForPath, streamer.Skylink(), streamer.Layout(), offset, size)
+		value, err := strconv.ParseUint(str, 10, 64)
 		if err != nil {
-			ew.WriteError(w, Error{fmt.Sprintf("failed to download contents for path: %v, could not create limit streamer", path)}, http.StatusInternalServerError)
+			WriteError(w, Error{fmt.Sprintf("unable to parse '%v' parameter: %v", param.name, err)}, http.StatusBadRequest)
 			return
 		}
+		*param.value = value
+	}
+	if params.Limit == 0 || params.Limit > MaxSkynetSearchLimit {
+		WriteError(w, Error{fmt.Sprintf("'limit' needs to be between 1 and %v", MaxSkynetSearchLimit)}, http.StatusBadRequest)
+		return
+	}
+	if params.MaxSize > 0 && params.MaxSize < params.MinSize {
+		WriteError(w, Error{"'maxsize' can't be smaller than 'minsize'"}, http.StatusBadRequest)
+		return
+	}
 
-		isSubfile = isFile
-		metadata = metadataForPath
+	results, total, err := api.renter.SkynetSearch(params)
+	if err != nil {
+		handleSkynetError(w, "failed to search skyfiles", err)
+		return
 	}
-	// If we are serving more than one file, and the format is not
-	// specified, default to downloading it as a zip archive.
-	if !isSubfile && metadata.IsDirectory() && format == skymodules.SkyfileFormatNotSpecified {
-		format = skymodules.SkyfileFormatZip
+	WriteJSON(w, SkynetSearchGET{
+		Results: results,
+		Total:   total,
+	})
+}
+
+// skynetBlocklistHandlerPOST handles the API call to block certain skylinks.
+func (api *API) skynetBlocklistHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
 	}
 
-	// Encode the Layout
-	encLayout := streamer.Layout().Encode()
+	// Parse parameters
+	var params SkynetBlocklistPOST
+	err = json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-	// Set the common Header fields
-	//
-	// Set the Skylink response header
-	if !streamer.Skylink().IsSkylinkV1() {
-		build.Critical("skylink attached in skynet-skylink header is not v1")
+	// Check for nil input
+	if len(append(params.Add, params.Remove...)) == 0 {
+		WriteError(w, Error{"no skylinks submitted"}, http.StatusBadRequest)
+		return
 	}
-	w.Header().Set(SkynetSkylinkHeader, streamer.Skylink().String())
 
-	// Set the ETag response header
-	//
-	// NOTE: we use the Skylink returned by the streamer to build the ETag with,
-	// this is very important as the incoming skylink might have been a V2
-	// skylink that got resolved to a v1 skylink. We don't want to build the
-	// ETag on the V2 skylink as that is constant, even though the data might
-	// change.
-	eTag := buildETag(streamer.Skylink(), path, format)
-	w.Header().Set("ETag", fmt.Sprintf("\"%v\"", eTag))
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-	// Set the Layout
-	if params.includeLayout {
-		w.Header().Set(SkynetFileLayoutHeader, hex.EncodeToString(encLayout))
+	// Generate context
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	// Update the Skynet Blocklist
+	err = api.renter.UpdateSkynetBlocklist(ctx, params.Add, params.Remove, params.IsHash)
+	if err != nil {
+		WriteError(w, Error{"unable to update the skynet blocklist: " + err.Error()}, http.StatusInternalServerError)
+		return
 	}
 
-	// Set an appropriate Content-Disposition header
-	var cdh string
-	filename := filepath.Base(metadata.Filename)
-	if format.IsArchive() {
-		cdh = fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename+format.Extension()))
-	} else if params.attachment {
-		cdh = fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename))
-	} else {
-		cdh = fmt.Sprintf("inline; filename=%s", strconv.Quote(filename))
+	WriteSuccess(w)
+}
+
+// skynetPortalKeyHandlerGET handles the API call to get the public key which
+// serving proofs are signed with.
+func (api *API) skynetPortalKeyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	pk, err := api.renter.SkynetPortalKey()
+	if err != nil {
+		WriteError(w, Error{"unable to get the portal key: " + err.Error()}, http.StatusInternalServerError)
+		return
 	}
-	w.Header().Set("Content-Disposition", cdh)
+	WriteJSON(w, SkynetPortalKeyGET{
+		PublicKey: pk,
+	})
+}
 
-	// If requested, serve the content as a tar archive, compressed tar
-	// archive or zip archive.
-	if format.IsArchive() {
-		err = serveArchive(w, streamer, format, metadata)
-		if err != nil {
-			ew.WriteError(w, Error{fmt.Sprintf("failed to serve skyfile as %v archive: %v", format, err)}, http.StatusInternalServerError)
-		}
+// skynetPortalsHandlerGET handles the API call to get the list of known skynet
+// portals.
+func (api *API) skynetPortalsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	// Get the list of portals.
+	portals, err := api.renter.Portals()
+	if err != nil {
+		WriteError(w, Error{"unable to get the portals list: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteJSON(w, SkynetPortalsGET{
+		Portals: portals,
+	})
+}
+
+// skynetPortalsHandlerPOST handles the API call to add and remove portals from
+// the list of known skynet portals.
+func (api *API) skynetPortalsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse parameters.
+	var params SkynetPortalsPOST
+	err := json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// Only set the Content-Type header when the metadata defines one, if we
-	// were to set the header to an empty string, it would prevent the http
-	// library from sniffing the file's content type.
-	if metadata.ContentType() != "" {
-		w.Header().Set("Content-Type", metadata.ContentType())
+	// Update the list of known skynet portals.
+	err = api.renter.UpdateSkynetPortals(params.Add, params.Remove)
+	if err != nil {
+		// If validation fails, return a bad request status.
+		errStatus := http.StatusInternalServerError
+		if strings.Contains(err.Error(), skynetportals.ErrSkynetPortalsValidation.Error()) {
+			errStatus = http.StatusBadRequest
+		}
+		WriteError(w, Error{"unable to update the list of known skynet portals: " + err.Error()}, errStatus)
+		return
 	}
-	http.ServeContent(w, req, metadata.Filename, time.Time{}, streamer)
+
+	WriteSuccess(w)
 }
 
-// skynetSkylinkPinHandlerPOST will pin a skylink to this Sia node, ensuring
-// uptime even if the original uploader stops paying for the file.
-func (api *API) skynetSkylinkPinHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+// skynetUploadEstimateHandlerPOST handles the API call to estimate the cost of
+// uploading a skyfile without uploading anything.
+func (api *API) skynetUploadEstimateHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Parse the query params.
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
@@ -644,40 +1355,72 @@ func (api *API) skynetSkylinkPinHandlerPOST(w http.ResponseWriter, req *http.Req
 		return
 	}
 
-	strLink := ps.ByName("skylink")
-	var skylink skymodules.Skylink
-	err = skylink.LoadString(strLink)
+	// Parse the size.
+	sizeStr := queryForm.Get("size")
+	if sizeStr == "" {
+		WriteError(w, Error{"'size' parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseUint(sizeStr, 10, 64)
 	if err != nil {
-		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		WriteError(w, Error{"unable to parse 'size' parameter: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse whether the siapath should be from root or from the skynet folder.
-	var root bool
-	rootStr := queryForm.Get("root")
-	if rootStr != "" {
-		root, err = strconv.ParseBool(rootStr)
-		if err != nil {
-			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+	// Parse the redundancy.
+	var baseChunkRedundancy uint8
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &baseChunkRedundancy); err != nil {
+			WriteError(w, Error{"unable to parse 'basechunkredundancy' parameter: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
 	}
+	dataPieces, parityPieces, err := ParseDataAndParityPieces(queryForm.Get("datapieces"), queryForm.Get("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-	// Parse out the intended siapath.
-	var siaPath skymodules.SiaPath
-	siaPathStr := queryForm.Get("siapath")
-	if root {
-		siaPath, err = skymodules.NewSiaPath(siaPathStr)
-	} else {
-		siaPath, err = skymodules.SkynetFolder.Join(siaPathStr)
+	estimate, err := api.renter.EstimateSkyfileUploadCost(size, skymodules.SkyfileUploadParameters{
+		BaseChunkRedundancy: baseChunkRedundancy,
+		DataPieces:          dataPieces,
+		ParityPieces:        parityPieces,
+	})
+	if err != nil {
+		handleSkynetError(w, "failed to estimate upload cost", err)
+		return
+	}
+	WriteJSON(w, estimate)
+}
+
+// skynetPinEstimateHandlerGET handles the API call to estimate the cost of
+// pinning a skylink. Only the base sector of the skylink is downloaded.
+func (api *API) skynetPinEstimateHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
 	}
+
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
-		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
+	// Parse the redundancy.
+	var baseChunkRedundancy uint8
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &baseChunkRedundancy); err != nil {
+			WriteError(w, Error{"unable to parse 'basechunkredundancy' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -685,8 +1428,7 @@ func (api *API) skynetSkylinkPinHandlerPOST(w http.ResponseWriter, req *http.Req
 
 	// Parse pricePerMS.
 	pricePerMS := skymodules.DefaultSkynetPricePerMS
-	pricePerMSStr := queryForm.Get("priceperms")
-	if pricePerMSStr != "" {
+	if pricePerMSStr := queryForm.Get("priceperms"); pricePerMSStr != "" {
 		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
 		if err != nil {
 			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
@@ -694,717 +1436,2836 @@ func (api *API) skynetSkylinkPinHandlerPOST(w http.ResponseWriter, req *http.Req
 		}
 	}
 
-	// Check whether force upload is allowed. Skynet portals might disallow
-	// passing the force flag, if they want to they can set overrule the force
-	// flag by passing in the 'Skynet-Disable-Force' header
-	allowForce := true
-	strDisableForce := req.Header.Get(SkynetDisableForceHeader)
-	if strDisableForce != "" {
-		disableForce, err := strconv.ParseBool(strDisableForce)
-		if err != nil {
-			WriteError(w, Error{"unable to parse 'Skynet-Disable-Force' header: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-		allowForce = !disableForce
+	estimate, err := api.renter.EstimateSkylinkPinCost(skylink, skymodules.SkyfileUploadParameters{
+		BaseChunkRedundancy: baseChunkRedundancy,
+	}, timeout, pricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to estimate pin cost", err)
+		return
 	}
+	WriteJSON(w, estimate)
+}
 
-	// Check whether existing file should be overwritten
-	force := false
-	if strForce := queryForm.Get("force"); strForce != "" {
-		force, err = strconv.ParseBool(strForce)
-		if err != nil {
-			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
+// skynetUploadPolicyHandlerGET handles the API call to get the upload policy
+// which is enforced for skyfile uploads.
+func (api *API) skynetUploadPolicyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
 	}
+	WriteJSON(w, settings.SkynetUploadPolicy)
+}
 
-	// Notify the caller force has been disabled
-	if !allowForce && force {
-		WriteError(w, Error{"'force' has been disabled on this node: " + err.Error()}, http.StatusBadRequest)
+// skynetUploadPolicyHandlerPOST handles the API call to set the upload policy
+// which is enforced for skyfile uploads.
+func (api *API) skynetUploadPolicyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the policy.
+	var policy skymodules.SkynetUploadPolicy
+	err := json.NewDecoder(req.Body).Decode(&policy)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-
-	// Check whether the redundancy has been set.
-	redundancy := uint8(0)
-	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
-		if _, err := fmt.Sscan(rStr, &redundancy); err != nil {
-			WriteError(w, Error{"unable to parse basechunkredundancy: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
+	err = policy.Validate()
+	if err != nil {
+		WriteError(w, Error{"invalid upload policy: " + err.Error()}, http.StatusBadRequest)
+		return
 	}
 
-	// Create the upload parameters. Notably, the fanout redundancy, the file
-	// metadata and the filename are not included. Changing those would change
-	// the skylink, which is not the goal.
-	lup := skymodules.SkyfileUploadParameters{
-		SiaPath:             siaPath,
-		Force:               force,
-		BaseChunkRedundancy: redundancy,
+	// Update the policy in the renter's settings.
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
 	}
-
-	err = api.renter.PinSkylink(skylink, lup, timeout, pricePerMS)
+	settings.SkynetUploadPolicy = policy
+	err = api.renter.SetSettings(settings)
 	if err != nil {
-		handleSkynetError(w, "failed to pin file to skynet", err)
+		WriteError(w, Error{"failed to set upload policy: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-	w.Header().Set(SkynetSkylinkHeader, skylink.String())
 	WriteSuccess(w)
 }
 
-// skynetTUSUploadSkylinkGET is the handler for the /skynet/tus/skylink/:id
-// endpoint.
-func (api *API) skynetTUSUploadSkylinkGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
-	// Get id from path.
-	id := ps.ByName("id")
-
-	// Get the uploader from the renter.
-	tusUploader := api.renter.SkynetTUSUploader()
+// skynetRootHandlerGET handles the api call for a download by root request.
+// This call returns the encoded sector.
+func (api *API) skynetRootHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	start := time.Now()
 
-	// Fetch the skylink.
-	skylink, found := tusUploader.Skylink(id)
-	if !found {
-		WriteError(w, Error{"failed to fetch skylink for upload"}, http.StatusNotFound)
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
 
-	// Set the Skylink response header
-	w.Header().Set(SkynetSkylinkHeader, skylink.String())
-
-	// Respond with the skylink in the body as well.
-	WriteJSON(w, SkynetSkyfileHandlerPOST{
-		Bitfield:   skylink.Bitfield(),
-		MerkleRoot: skylink.MerkleRoot(),
-		Skylink:    skylink.String(),
-	})
-}
-
-// skynetSkyfileHandlerPOST is a dual purpose endpoint. If the 'convertpath'
-// field is set, this endpoint will create a skyfile using an existing siafile.
-// The original siafile and the skyfile will both need to be kept in order for
-// the file to remain available on Skynet. If the 'convertpath' field is not
-// set, this is essentially an upload streaming endpoint for Skynet which
-// returns a skylink.
-func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	// parse the request headers and parameters
-	headers, params, err := parseUploadHeadersAndRequestParameters(req, ps)
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// build the upload parameters
-	sup := skymodules.SkyfileUploadParameters{
-		BaseChunkRedundancy: params.baseChunkRedundancy,
-		DryRun:              params.dryRun,
-		Force:               params.force,
-		SiaPath:             params.siaPath,
-
-		// Set filename and mode
-		Filename: params.filename,
-		Mode:     params.mode,
-
-		// Set the default path params
-		DefaultPath:        params.defaultPath,
-		DisableDefaultPath: params.disableDefaultPath,
+	// Parse the root, offset and length.
+	root, offset, length, err := parseRootParams(queryForm)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-		// Set encryption key details
-		SkykeyName: params.skyKeyName,
-		SkykeyID:   params.skyKeyID,
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 
-		TryFiles:   params.tryFiles,
-		ErrorPages: params.errorPages,
+	// Parse the 'include-hosts' query string parameter.
+	var includeHosts bool
+	includeHostsStr := queryForm.Get("include-hosts")
+	if includeHostsStr != "" {
+		includeHosts, err = strconv.ParseBool(includeHostsStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'include-hosts' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
 	}
 
-	// set the reader
-	var reader skymodules.SkyfileUploadReader
-	if isMultipartRequest(headers.mediaType) {
-		reader, err = skymodules.NewSkyfileMultipartReaderFromRequest(req, sup)
+	// Fetch the skyfile's  streamer to serve the basesector of the file
+	var sector []byte
+	var hostStats []skymodules.SkynetHostStats
+	if includeHosts {
+		sector, hostStats, err = api.renter.DownloadByRootWithHostStats(req.Context(), root, offset, length, timeout, pricePerMS)
 	} else {
-		reader = skymodules.NewSkyfileReader(req.Body, sup)
+		sector, err = api.renter.DownloadByRoot(req.Context(), root, offset, length, timeout, pricePerMS)
 	}
 	if err != nil {
-		WriteError(w, Error{fmt.Sprintf("unable to create multipart reader: %v", err)}, http.StatusBadRequest)
+		handleSkynetError(w, "failed to fetch root", err)
 		return
 	}
 
-	// Check whether this is a streaming upload or a siafile conversion. If no
-	// convert path is provided, assume that the req.Body will be used as a
-	// streaming upload.
-	if params.convertPath == "" {
-		skylink, err := api.renter.UploadSkyfile(req.Context(), sup, reader)
+	// Attach the host stats if requested. The sector has already been
+	// downloaded at this point so we can set them as a regular header.
+	if includeHosts {
+		err = attachHostStats(w.Header(), hostStats)
 		if err != nil {
-			handleSkynetError(w, "failed to upload file to skynet", err)
+			WriteError(w, Error{"unable to attach host stats: " + err.Error()}, http.StatusInternalServerError)
 			return
 		}
+	}
 
-		// Determine whether the file is large or not, and update the
-		// appropriate bucket.
-		//
-		// The way we have to count is a bit gross, because there are two files
-		// that we need to consider when looking for the size of the final
-		// upload. The first is the siapath, and then the second is the siapath
-		// of the extended file, which needs to be separated out because it can
-		// have different erasure code settings. To get the full filesize we add
-		// the size of the normal file, and then the size of the extended file.
-		// But the extended file may not exist, so we have to be careful with
-		// how we consider extending it. And then just in general the error
-		// handling here is a bit messy.
-		//
-		// It seems that in practice, all files report a size of 4 MB,
-		// regardless of how big the actual upload was. I didn't think this was
-		// the case, but to handle it correctly we consider anything that is
-		// smaller than 4300e3 bytes to be "small". Just a little fudging to
-		// match the performance bucket to the thing we are actually trying to
-		// measure.
-		file, err := api.renter.File(sup.SiaPath)
-		extendedPath := sup.SiaPath
-		extendedPath.Path = extendedPath.Path + ".extended"
-		file2, err2 := api.renter.File(extendedPath)
-		var filesize uint64
-		if err == nil {
-			filesize = file.Filesize
-		}
-		if err == nil && err2 == nil {
-			filesize += file2.Filesize
-		}
+	streamer := renter.StreamerFromSlice(sector)
+	defer func() {
+		// At this point we have already responded so we can't write a potential
+		// error here.
+		_ = streamer.Close()
+	}()
 
-		// Set the Skylink response header
-		w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	// Serve the sector
+	w, addToStats := api.trackSkynetDownload(w, req, skynetStatsFormatRoot, start)
+	defer addToStats()
+	http.ServeContent(w, req, "", time.Time{}, streamer)
+	return
+}
 
-		WriteJSON(w, SkynetSkyfileHandlerPOST{
-			Skylink:    skylink.String(),
-			MerkleRoot: skylink.MerkleRoot(),
-			Bitfield:   skylink.Bitfield(),
-		})
+// skynetRootHandlerHEAD handles the api call for a HEAD request on a root. It
+// checks whether the sector is available without downloading it.
+func (api *API) skynetRootHandlerHEAD(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
 
-	// There is a convert path.
-	convertPath, err := skymodules.NewSiaPath(params.convertPath)
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"invalid convertpath provided: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	convertPath, err = rebaseInputSiaPath(convertPath)
+
+	// Parse the root, offset and length.
+	root, _, length, err := parseRootParams(queryForm)
 	if err != nil {
-		WriteError(w, Error{"invalid convertpath provided - can't rebase: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	skylink, err := api.renter.CreateSkylinkFromSiafile(sup, convertPath)
+
+	// Check whether the root is available.
+	err = api.renter.HasRoot(req.Context(), root, timeout)
 	if err != nil {
-		handleSkynetError(w, "failed to convert siafile to skyfile", err)
+		handleSkynetError(w, "failed to fetch root", err)
 		return
 	}
 
-	// Set the Skylink response header
-	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	w.Header().Set("Content-Length", strconv.FormatUint(length, 10))
+	w.WriteHeader(http.StatusOK)
+}
 
-	WriteJSON(w, SkynetSkyfileHandlerPOST{
-		Skylink:    skylink.String(),
-		MerkleRoot: skylink.MerkleRoot(),
-		Bitfield:   skylink.Bitfield(),
-	})
+// skynetSkylinkHandlerOPTIONS handles CORS preflight requests for the
+// /skynet/skylink endpoint.
+func (api *API) skynetSkylinkHandlerOPTIONS(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	allowedMethods := "GET, HEAD, OPTIONS"
+	if api.setSkylinkCORSHeaders(w, req) {
+		w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+		if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", "86400")
+	}
+	w.Header().Set("Allow", allowedMethods)
+	WriteSuccess(w)
 }
 
-// skynetStatsHandlerGET responds with a JSON with statistical data about
-// skynet, e.g. number of files uploaded, total size, etc.
-func (api *API) skynetStatsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
-	// Pull the skynet stats from the root directory
-	dirs, err := api.renter.DirList(skymodules.RootSiaPath())
+// skynetSkylinkHandlerGET accepts a skylink as input and will stream the data
+// from the skylink out of the response body as output.
+func (api *API) skynetSkylinkHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	start := time.Now()
+
+	// Set the CORS headers first to allow cross-origin requests to read
+	// errors as well.
+	api.setSkylinkCORSHeaders(w, req)
+
+	// Parse the request parameters
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	params, err := parseDownloadRequestParameters(req, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"unable to get root directory status: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	rootDir := dirs[0]
+	path := params.path
+	format := params.format
 
-	// get version
-	version := build.NodeVersion
-	if build.ReleaseTag != "" {
-		version += "-" + build.ReleaseTag
+	// If the caller already has the requested content of a V1 skylink, there
+	// is no need to fetch the skyfile. That's only possible if the format is
+	// specified since the default path, tryfiles and the default format of
+	// directories are resolved from the metadata and change the ETag. The
+	// path of a subfile requested by index is only known once the metadata
+	// was fetched as well. Previews don't have an ETag.
+	if format != skymodules.SkyfileFormatNotSpecified && params.subfileIndex == nil && params.preview == 0 && api.serveSkylinkNotModified(w, req, params.skylink, path, format) {
+		return
 	}
 
-	// Grab the siad uptime
-	uptime := time.Since(api.StartTime()).Seconds()
-
-	// Get the registry stats.
-	renterPerf, err := api.renter.Performance()
+	// Fetch the skyfile's metadata and a streamer to download the file. If
+	// requested, transient failures are retried. The renter retries
+	// failures caused by a degraded worker pool on its own and counts them.
+	//
+	// NOTE: fetching the streamer only downloads the base sector. No fanout
+	// data is requested until the streamer is read from or seeked without
+	// SeekLazy. That way all the validation, redirects and conditional
+	// responses below happen before any fanout data is fetched and only the
+	// served range of the skyfile is requested.
+	var streamer skymodules.SkyfileStreamer
+	var srvs []skymodules.RegistryEntry
+	renterRetries := new(skymodules.DownloadRetries)
+	ctx := skymodules.ContextWithDownloadRetries(req.Context(), renterRetries)
+	err = downloadWithRetries(ctx, params.retries, func() (err error) {
+		if params.skykey != nil {
+			streamer, srvs, err = api.renter.DownloadSkylinkWithSkykey(ctx, params.skylink, *params.skykey, params.timeout, params.pricePerMS)
+			return err
+		}
+		streamer, srvs, err = api.renter.DownloadSkylink(ctx, params.skylink, params.timeout, params.pricePerMS)
+		return err
+	})
+	w.Header().Set(SkynetDownloadRetriesHeader, fmt.Sprint(renterRetries.Load()))
 	if err != nil {
-		WriteError(w, Error{"unable to get renter registry status: " + err.Error()}, http.StatusBadRequest)
+		handleSkynetError(w, "failed to fetch skylink", err)
 		return
 	}
-
-	// Check for any critical alerts.
-	numCritAlerts := 0
-	if api.gateway != nil {
-		a, _, _ := api.gateway.Alerts()
-		numCritAlerts += len(a)
+	// If the skyfile redirects to another skylink, serve the content of that
+	// skylink under the filename of the requested skyfile.
+	var redirectFilename string
+	if streamer.Metadata().Redirect != "" {
+		redirectFilename = streamer.Metadata().Filename
+		streamer, err = api.followSkylinkRedirects(ctx, params, streamer)
+		w.Header().Set(SkynetDownloadRetriesHeader, fmt.Sprint(renterRetries.Load()))
+		if err != nil {
+			handleSkynetError(w, "failed to follow skylink redirect", err)
+			return
+		}
 	}
-	if api.cs != nil {
-		a, _, _ := api.cs.Alerts()
-		numCritAlerts += len(a)
+	// Remember the streamer before it might be wrapped in a limit streamer
+	// for a subfile. Previews are cut from the whole skyfile.
+	baseStreamer := streamer
+	hostStatsStreamer, hasHostStats := streamer.(skymodules.SkyfileHostStatsStreamer)
+	fetchLimitStreamer, hasFetchLimit := streamer.(skymodules.SkyfileFetchLimitStreamer)
+	partialStreamer, hasPartial := streamer.(skymodules.SkyfilePartialStreamer)
+	if degradedStreamer, ok := streamer.(skymodules.SkyfileDegradedStreamer); ok {
+		w = newDegradedResponseWriter(w, degradedStreamer)
 	}
-	if api.tpool != nil {
-		a, _, _ := api.tpool.Alerts()
-		numCritAlerts += len(a)
+	defer func() {
+		// At this point we have already responded so we can't write a potential
+		// error here.
+		_ = streamer.Close()
+	}()
+
+	// If requested, verify the base sector against the skylink's merkle root
+	// before serving anything and the rest of the skyfile chunk by chunk
+	// while serving it.
+	if params.verify {
+		sv, err := api.renter.VerifySkyfile(ctx, streamer.Skylink(), params.skykey, params.timeout, params.pricePerMS)
+		if err != nil {
+			handleSkynetError(w, "failed to verify skyfile", err)
+			return
+		}
+		streamer = newVerifiedStreamer(streamer, sv)
+		baseStreamer = streamer
 	}
-	if api.wallet != nil {
-		a, _, _ := api.wallet.Alerts()
-		numCritAlerts += len(a)
+
+	metadata := streamer.Metadata()
+	if redirectFilename != "" {
+		metadata.Filename = redirectFilename
+	}
+	ew := newCustomErrorWriter(metadata, streamer)
+
+	// Attach proof.
+	err = attachRegistryEntryProof(w, srvs)
+	if err != nil {
+		ew.WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// Only validate default path and tryfiles if the format is not specified,
+	// this way the file can still be downloaded should it have been uploaded
+	// with incorrect metadata, which is possible seeing as it may have been
+	// uploaded by a private portal. Subfiles requested by index are served
+	// as they are.
+	if format == skymodules.SkyfileFormatNotSpecified && params.subfileIndex == nil {
+		// The path we actually want to serve based on defaultpath and tryfiles.
+		servePath, reason := metadata.ServePathWithReason(path)
+		if reason != "" && len(metadata.Subfiles) > 0 {
+			w.Header().Set(SkynetDefaultPathResolvedHeader, servePath)
+			w.Header().Set(SkynetDefaultPathReasonHeader, string(reason))
+		}
+		isMulti := len(metadata.Subfiles) > 1
+		// If we don't have a subPath and the skylink doesn't end with a
+		// trailing slash we need to redirect in order to add the trailing
+		// slash. This is only true for skapps - they need it in order to
+		// properly work with relative paths. We also don't need to redirect if
+		// this is a HEAD request or if it's a download as attachment.
+		needsSlash := isMulti && path == "/" && servePath != path && !params.attachment && !strings.HasSuffix(params.skylinkStringNoQuery, "/")
+		if needsSlash && params.noRedirect {
+			// The caller doesn't want to be redirected. Serve the content
+			// directly and let them know where relative paths resolve to.
+			w.Header().Set(SkynetBaseHrefHeader, params.skylinkStringNoQuery+"/")
+		} else if needsSlash && req.Method == http.MethodGet {
+			location := params.skylinkStringNoQuery + "/"
+			if req.URL.RawQuery != "" {
+				location += "?" + req.URL.RawQuery
+			}
+			w.Header().Set("Location", location)
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		path = servePath
+	}
+	var isSubfile bool
+	// Keep track of which part of the skyfile is served.
+	contentOffset, contentSize := uint64(0), streamer.Layout().Filesize
+	// Serve the contents of the skyfile at path or index if one is set
+	if path != "/" || params.subfileIndex != nil {
+		var metadataForPath skymodules.SkyfileMetadata
+		var isFile bool
+		var offset, size uint64
+		if params.subfileIndex != nil {
+			metadataForPath, offset, size, err = metadata.ForIndex(*params.subfileIndex)
+			if err != nil {
+				ew.WriteError(w, Error{fmt.Sprintf("failed to download contents for subfile index %v: %v", *params.subfileIndex, err)}, http.StatusNotFound)
+				return
+			}
+			// The subfile is served as if it was requested by its path.
+			isFile = true
+			if len(metadata.Subfiles) > 0 {
+				path = metadataForPath.Filename
+			}
+		} else {
+			metadataForPath, isFile, offset, size = metadata.ForPath(path)
+			if len(metadataForPath.Subfiles) == 0 {
+				ew.WriteError(w, Error{fmt.Sprintf("failed to download contents for path: %v", path)}, http.StatusNotFound)
+				return
+			}
+		}
+		// NOTE: we don't have an actual raw metadata for the subpath. So we are
+		// marshaling the temporary metadata. This should be good enough since
+		// the metadata can't be used to create a skylink anyway.
+		rawMetadataForPath, err := json.Marshal(metadataForPath)
+		if err != nil {
+			ew.WriteError(w, Error{fmt.Sprintf("failed to marshal subfile metadata for path %v", path)}, http.StatusNotFound)
+			return
+		}
+		streamer, err = NewLimitStreamer(streamer, metadataForPath, rawMetadataForPath, streamer.Skylink(), streamer.Layout(), offset, size)
+		if err != nil {
+			ew.WriteError(w, Error{fmt.Sprintf("failed to download contents for path: %v, could not create limit streamer", path)}, http.StatusInternalServerError)
+			return
+		}
+
+		isSubfile = isFile
+		metadata = metadataForPath
+		contentOffset, contentSize = offset, size
+	}
+	// If we are serving more than one file, and the format is not
+	// specified, default to downloading it as a zip archive.
+	if !isSubfile && metadata.IsDirectory() && format == skymodules.SkyfileFormatNotSpecified {
+		format = skymodules.SkyfileFormatZip
+	}
+
+	// If requested, only serve the first bytes of the content. The streamer
+	// is prevented from fetching any data beyond the preview which stops the
+	// download early.
+	if params.preview > 0 {
+		if format != skymodules.SkyfileFormatNotSpecified {
+			ew.WriteError(w, Error{"'preview' parameter can't be used to download a directory"}, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(SkynetPreviewHeader, fmt.Sprint(contentSize))
+		if params.preview < contentSize {
+			streamer, err = NewLimitStreamer(baseStreamer, metadata, streamer.RawMetadata(), streamer.Skylink(), streamer.Layout(), contentOffset, params.preview)
+			if err != nil {
+				ew.WriteError(w, Error{"failed to create preview streamer: " + err.Error()}, http.StatusInternalServerError)
+				return
+			}
+			contentSize = params.preview
+		}
+		if hasFetchLimit {
+			fetchLimitStreamer.SetFetchLimit(contentOffset + contentSize)
+		}
+	}
+
+	// Track the download in the performance stats. Listings aren't
+	// downloads so they are not tracked.
+	if format != skymodules.SkyfileFormatIndex {
+		var addToStats func()
+		w, addToStats = api.trackSkynetDownload(w, req, format, start)
+		defer addToStats()
+	}
+
+	// Encode the Layout
+	encLayout := streamer.Layout().Encode()
+
+	// Set the common Header fields
+	//
+	// Set the Skylink response header
+	if !streamer.Skylink().IsSkylinkV1() {
+		build.Critical("skylink attached in skynet-skylink header is not v1")
+	}
+	w.Header().Set(SkynetSkylinkHeader, streamer.Skylink().String())
+
+	// Set the ETag response header
+	//
+	// NOTE: we use the Skylink returned by the streamer to build the ETag with,
+	// this is very important as the incoming skylink might have been a V2
+	// skylink that got resolved to a v1 skylink. We don't want to build the
+	// ETag on the V2 skylink as that is constant, even though the data might
+	// change.
+	//
+	// Portals behind proxies which transform the content, e.g. by compressing
+	// it, can configure the renter to use weak ETags instead.
+	settings, err := api.renter.Settings()
+	if err != nil {
+		ew.WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	if params.preview == 0 {
+		eTag := buildETag(streamer.Skylink(), path, format)
+		w.Header().Set("ETag", formatETag(eTag, settings.SkynetWeakETags))
+
+		// Now that the path and format are resolved, a matching
+		// If-None-Match header is answered before any fanout data is
+		// fetched. Archives aren't served using http.ServeContent so this
+		// is the only place they are checked against the ETag.
+		if (req.Method == http.MethodGet || req.Method == http.MethodHead) && eTagMatches(req.Header.Get("If-None-Match"), eTag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Set the Layout
+	if params.includeLayout {
+		w.Header().Set(SkynetFileLayoutHeader, hex.EncodeToString(encLayout))
+	}
+
+	// If requested, serve a listing of the files at the path.
+	if format == skymodules.SkyfileFormatIndex {
+		err = serveIndex(w, req, params.skylink, path, metadata)
+		if err != nil {
+			ew.WriteError(w, Error{fmt.Sprintf("failed to serve skyfile index: %v", err)}, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// If requested, serve the content as a data URI. Only small files can be
+	// served that way since the whole content is held in memory to encode it.
+	if format == skymodules.SkyfileFormatDataURI {
+		if !isSubfile && metadata.IsDirectory() {
+			ew.WriteError(w, Error{"'datauri' format can't be used to download a directory"}, http.StatusBadRequest)
+			return
+		}
+		maxSize := uint64(DefaultSkynetMaxDataURISize)
+		if settings.SkynetMaxDataURISize > 0 {
+			maxSize = settings.SkynetMaxDataURISize
+		}
+		if contentSize > maxSize {
+			ew.WriteError(w, Error{fmt.Sprintf("content of size %v exceeds the maximum size of %v for data URIs", contentSize, maxSize)}, http.StatusBadRequest)
+			return
+		}
+		contentType := metadata.ContentType()
+		if params.contentType != "" {
+			contentType = params.contentType
+		}
+		dataURI, err := skyfileDataURI(streamer, contentType, contentSize)
+		if err != nil {
+			ew.WriteError(w, Error{"failed to encode skyfile as data URI: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", fmt.Sprint(len(dataURI)))
+		if req.Method == http.MethodGet {
+			// At this point we have already responded so we can't write a
+			// potential error here.
+			_, _ = io.WriteString(w, dataURI)
+		}
+		return
+	}
+
+	// Set an appropriate Content-Disposition header
+	var cdh string
+	filename := filepath.Base(metadata.Filename)
+	if format.IsArchive() {
+		cdh = fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename+format.Extension()))
+	} else if params.attachment {
+		cdh = fmt.Sprintf("attachment; filename=%s", strconv.Quote(filename))
+	} else {
+		cdh = fmt.Sprintf("inline; filename=%s", strconv.Quote(filename))
+	}
+	w.Header().Set("Content-Disposition", cdh)
+
+	// Archives are not served using http.ServeContent so conditional requests
+	// based on the modification time need to be handled manually.
+	if format.IsArchive() && serveNotModified(w, req, metadata.LastModified()) {
+		return
+	}
+
+	// If requested, compute the checksum of the served data and attach it as
+	// a trailer.
+	if params.checksum == checksumSHA256 && req.Method == http.MethodGet {
+		cw := newChecksumResponseWriter(w, sha256.New(), SkynetChecksumTrailer)
+		defer cw.AttachChecksum()
+		w = cw
+	}
+
+	// If the client accepts trailers, hash the bytes of the response body and
+	// attach the hash as a trailer. For range requests that is only the
+	// served range.
+	var contentHasher *checksumResponseWriter
+	if params.contentHash && req.Method == http.MethodGet {
+		contentHasher = newChecksumResponseWriter(w, crypto.NewHash(), SkynetContentHashTrailer)
+		defer contentHasher.AttachChecksum()
+		w = contentHasher
+	}
+
+	// If requested, attach a proof signed by the portal that it served the
+	// content. It covers the hash of the response body if that is computed
+	// anyway and the base sector otherwise.
+	if params.signResponse && req.Method == http.MethodGet {
+		pw := newServingProofResponseWriter(w, api.renter, streamer.Skylink(), contentHasher)
+		defer pw.AttachServingProof()
+		w = pw
+	}
+
+	// If requested, attach the stats of the hosts that served the data. For
+	// GET requests they are only known after the body was written so they
+	// are attached as a trailer.
+	if params.includeHosts && hasHostStats {
+		if req.Method == http.MethodGet {
+			hw := newHostStatsResponseWriter(w, hostStatsStreamer)
+			defer hw.AttachHostStats()
+			w = hw
+		} else {
+			err = attachHostStats(w.Header(), hostStatsStreamer.HostStats())
+			if err != nil {
+				ew.WriteError(w, Error{"unable to attach host stats: " + err.Error()}, http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	// If requested, attach the bandwidth that was used by the hosts to serve
+	// the data. It is only known after the body was written so it is
+	// attached as a trailer.
+	if params.includeBandwidth && hasHostStats && req.Method == http.MethodGet {
+		bw := newBandwidthResponseWriter(w, hostStatsStreamer)
+		defer bw.AttachBandwidthUsed()
+		w = bw
+	}
+
+	// If requested, check whether parts of the content can't be recovered
+	// and serve as much of it as possible instead of failing the download.
+	var missing []skymodules.SkynetMissingRange
+	if params.allowPartial && hasPartial {
+		unrecoverable, err := partialStreamer.UnrecoverableRanges()
+		if err != nil {
+			ew.WriteError(w, Error{"unable to determine unrecoverable ranges: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		missing = relativeMissingRanges(unrecoverable, contentOffset, contentSize)
+	}
+
+	// Archives skip the subfiles which can't be recovered.
+	if format.IsArchive() && len(missing) > 0 {
+		var skipped []skymodules.SkynetMissingRange
+		metadata, skipped = omitMissingSubfiles(metadata, missing)
+		if len(metadata.Subfiles) == 0 {
+			ew.WriteError(w, Error{"none of the requested files can be recovered"}, http.StatusInternalServerError)
+			return
+		}
+		err = attachMissingRanges(w.Header(), skipped)
+		if err != nil {
+			ew.WriteError(w, Error{"unable to attach missing ranges: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		w = newPartialResponseWriter(w)
+	}
+
+	// If requested, serve the content as a tar archive, compressed tar
+	// archive or zip archive.
+	if format.IsArchive() {
+		err = serveArchive(w, streamer, format, metadata, params.prefetch)
+		if err != nil {
+			ew.WriteError(w, Error{fmt.Sprintf("failed to serve skyfile as %v archive: %v", format, err)}, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Only set the Content-Type header when the metadata defines one or the
+	// caller overrides it, if we were to set the header to an empty string,
+	// it would prevent the http library from sniffing the file's content
+	// type.
+	responseContentType := metadata.ContentType()
+	if params.contentType != "" {
+		responseContentType = params.contentType
+	}
+	if responseContentType != "" {
+		w.Header().Set("Content-Type", responseContentType)
+	}
+
+	// If parts of the content can't be recovered, only serve the recoverable
+	// prefix.
+	if len(missing) > 0 {
+		prefix := missing[0].Offset
+		if prefix == 0 {
+			ew.WriteError(w, Error{"the beginning of the requested content can't be recovered"}, http.StatusInternalServerError)
+			return
+		}
+		err = attachMissingRanges(w.Header(), missing)
+		if err != nil {
+			ew.WriteError(w, Error{"unable to attach missing ranges: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", prefix-1, contentSize))
+		w.Header().Set("Content-Length", fmt.Sprint(prefix))
+		w.WriteHeader(http.StatusPartialContent)
+		if req.Method == http.MethodGet {
+			// At this point we have already responded so we can't write a
+			// potential error here.
+			_, _ = io.CopyN(w, streamer, int64(prefix))
+		}
+		return
+	}
+	http.ServeContent(w, req, metadata.Filename, metadata.LastModified(), streamer)
+}
+
+// followSkylinkRedirects follows the redirects of skyfiles which point to
+// another skylink and returns a streamer for the first skyfile without a
+// redirect. Every streamer that is replaced is closed. A skykey provided with
+// the request is used to decrypt the redirect targets as well.
+func (api *API) followSkylinkRedirects(ctx context.Context, params *skyfileDownloadParams, streamer skymodules.SkyfileStreamer) (skymodules.SkyfileStreamer, error) {
+	visited := map[skymodules.Skylink]struct{}{
+		streamer.Skylink(): {},
+	}
+	for streamer.Metadata().Redirect != "" {
+		var target skymodules.Skylink
+		err := target.LoadString(streamer.Metadata().Redirect)
+		_ = streamer.Close()
+		if err != nil {
+			return nil, errors.AddContext(err, "invalid redirect")
+		}
+		if len(visited) > maxSkylinkRedirects {
+			return nil, ErrSkylinkRedirectLoop
+		}
+
+		// Fetch the skyfile the redirect points to.
+		err = downloadWithRetries(ctx, params.retries, func() (err error) {
+			if params.skykey != nil {
+				streamer, _, err = api.renter.DownloadSkylinkWithSkykey(ctx, target, *params.skykey, params.timeout, params.pricePerMS)
+				return err
+			}
+			streamer, _, err = api.renter.DownloadSkylink(ctx, target, params.timeout, params.pricePerMS)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := visited[streamer.Skylink()]; exists {
+			_ = streamer.Close()
+			return nil, ErrSkylinkRedirectLoop
+		}
+		visited[streamer.Skylink()] = struct{}{}
+	}
+	return streamer, nil
+}
+
+// skynetSkylinkPinHandlerPOST will pin a skylink to this Sia node, ensuring
+// uptime even if the original uploader stops paying for the file.
+func (api *API) skynetSkylinkPinHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// The manifest endpoint shares its route with the skylink since
+	// httprouter doesn't allow for a static segment next to a parameter.
+	if ps.ByName("skylink") == "manifest" {
+		api.skynetPinManifestHandlerPOST(w, req, ps)
+		return
+	}
+
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse whether the siapath should be from root or from the skynet folder.
+	var root bool
+	rootStr := queryForm.Get("root")
+	if rootStr != "" {
+		root, err = strconv.ParseBool(rootStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse out the intended siapath.
+	var siaPath skymodules.SiaPath
+	siaPathStr := queryForm.Get("siapath")
+	if root {
+		siaPath, err = skymodules.NewSiaPath(siaPathStr)
+	} else {
+		siaPath, err = skymodules.SkynetFolder.Join(siaPathStr)
+	}
+	if err != nil {
+		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Check whether force upload is allowed. Skynet portals might disallow
+	// passing the force flag, if they want to they can set overrule the force
+	// flag by passing in the 'Skynet-Disable-Force' header
+	allowForce := true
+	strDisableForce := req.Header.Get(SkynetDisableForceHeader)
+	if strDisableForce != "" {
+		disableForce, err := strconv.ParseBool(strDisableForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'Skynet-Disable-Force' header: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		allowForce = !disableForce
+	}
+
+	// Check whether existing file should be overwritten
+	force := false
+	if strForce := queryForm.Get("force"); strForce != "" {
+		force, err = strconv.ParseBool(strForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Notify the caller force has been disabled, unless the request carries
+	// a valid force token
+	if !allowForce && force {
+		forceToken, err := parseSkynetForceToken(req)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.verifySkynetForceToken(forceToken, siaPath)
+		if err != nil {
+			handleSkynetError(w, "unable to force pin", err)
+			return
+		}
+	}
+
+	// Check whether the redundancy has been set.
+	redundancy := uint8(0)
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &redundancy); err != nil {
+			WriteError(w, Error{"unable to parse basechunkredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Check whether only the base sector should be pinned.
+	baseSectorOnly := false
+	if strBaseSectorOnly := queryForm.Get("basesectoronly"); strBaseSectorOnly != "" {
+		baseSectorOnly, err = strconv.ParseBool(strBaseSectorOnly)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'basesectoronly' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Check whether the response should wait for the file to become healthy.
+	waitHealthy := false
+	if strWaitHealthy := queryForm.Get("wait-healthy"); strWaitHealthy != "" {
+		waitHealthy, err = strconv.ParseBool(strWaitHealthy)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'wait-healthy' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Check whether the pin should expire after a ttl.
+	var ttl time.Duration
+	if ttlStr := queryForm.Get("ttl"); ttlStr != "" {
+		ttlSeconds, err := strconv.ParseUint(ttlStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'ttl' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if ttlSeconds == 0 || ttlSeconds > uint64(math.MaxInt64/int64(time.Second)) {
+			WriteError(w, Error{"'ttl' parameter is out of range"}, http.StatusBadRequest)
+			return
+		}
+		ttl = time.Duration(ttlSeconds) * time.Second
+	}
+
+	// Create the upload parameters. Notably, the fanout redundancy, the file
+	// metadata and the filename are not included. Changing those would change
+	// the skylink, which is not the goal.
+	lup := skymodules.SkyfileUploadParameters{
+		SiaPath:             siaPath,
+		Force:               force,
+		BaseChunkRedundancy: redundancy,
+	}
+
+	// The timeout covers both the pin and waiting for the file to become
+	// healthy.
+	deadline := time.Now().Add(timeout)
+	err = api.renter.PinSkylink(skylink, lup, baseSectorOnly, timeout, pricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to pin file to skynet", err)
+		return
+	}
+	if ttl > 0 {
+		err = api.renter.SetSkynetPinExpiry(siaPath, time.Now().Add(ttl))
+		if err != nil {
+			WriteError(w, Error{"failed to set pin expiry: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+	}
+	pin, err := api.managedSkynetPinInfo(skylink, siaPath)
+	if err != nil {
+		WriteError(w, Error{"failed to fetch pinned file: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// If requested, wait until the file reaches a redundancy of 1x. If the
+	// deadline is reached first, the current snapshot is returned.
+	if waitHealthy && pin.Redundancy < 1 {
+		ticker := time.NewTicker(pinHealthCheckInterval)
+		defer ticker.Stop()
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+	LOOP:
+		for pin.Redundancy < 1 {
+			select {
+			case <-req.Context().Done():
+				break LOOP
+			case <-timer.C:
+				break LOOP
+			case <-ticker.C:
+			}
+			pin, err = api.managedSkynetPinInfo(skylink, siaPath)
+			if err != nil {
+				WriteError(w, Error{"failed to fetch pinned file: " + err.Error()}, http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	WriteJSON(w, pin)
+}
+
+// skynetPinManifestHandlerPOST handles the API call to pin all the skylinks
+// listed in a manifest of newline-separated skylinks. The manifest is either
+// the body of the request or the skyfile referenced by the 'skylink' query
+// string parameter. Every skylink is pinned to a siapath named after the
+// skylink which allows for skipping skylinks that are already pinned. The
+// response is streamed as JSON lines, starting with a summary followed by the
+// result of every pin.
+func (api *API) skynetPinManifestHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse whether the siapath should be from root or from the skynet folder.
+	var root bool
+	rootStr := queryForm.Get("root")
+	if rootStr != "" {
+		root, err = strconv.ParseBool(rootStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse out the directory the skylinks are pinned to.
+	dir := skymodules.SkynetFolder
+	siaPathStr := queryForm.Get("siapath")
+	if root {
+		dir, err = skymodules.NewSiaPath(siaPathStr)
+	} else if siaPathStr != "" {
+		dir, err = skymodules.SkynetFolder.Join(siaPathStr)
+	}
+	if err != nil {
+		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Read the manifest either from the body or from the skyfile.
+	var manifest io.Reader = req.Body
+	if manifestLinkStr := queryForm.Get("skylink"); manifestLinkStr != "" {
+		var manifestLink skymodules.Skylink
+		err = manifestLink.LoadString(manifestLinkStr)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("error parsing manifest skylink: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		streamer, _, err := api.renter.DownloadSkylink(req.Context(), manifestLink, timeout, pricePerMS)
+		if err != nil {
+			handleSkynetError(w, "failed to fetch manifest", err)
+			return
+		}
+		defer func() {
+			_ = streamer.Close()
+		}()
+		manifest = streamer
+	}
+	lines, err := readPinManifest(manifest)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Get the blocklist to skip blocked skylinks.
+	blocklist, err := api.renter.Blocklist()
+	if err != nil {
+		WriteError(w, Error{"unable to get the blocklist: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	blocked := make(map[crypto.Hash]struct{}, len(blocklist))
+	for _, hash := range blocklist {
+		blocked[hash] = struct{}{}
+	}
+
+	// Figure out which skylinks need to be pinned.
+	var summary SkynetPinManifestSummary
+	var toPin []SkynetPinManifestProgress
+	seen := make(map[skymodules.Skylink]struct{})
+	for _, line := range lines {
+		var skylink skymodules.Skylink
+		if err := skylink.LoadString(line); err != nil || !skylink.IsSkylinkV1() {
+			summary.Invalid++
+			continue
+		}
+		if _, exists := seen[skylink]; exists {
+			continue
+		}
+		seen[skylink] = struct{}{}
+		if _, isBlocked := blocked[crypto.HashObject(skylink.MerkleRoot())]; isBlocked {
+			summary.Blocked++
+			continue
+		}
+		siaPath, err := dir.Join(skylink.String())
+		if err != nil {
+			summary.Invalid++
+			continue
+		}
+		if _, err := api.renter.File(siaPath); err == nil {
+			summary.AlreadyPinned++
+			continue
+		}
+		toPin = append(toPin, SkynetPinManifestProgress{
+			Skylink: skylink.String(),
+			SiaPath: siaPath,
+		})
+	}
+	summary.ToPin = uint64(len(toPin))
+
+	// Stream the summary followed by the result of every pin. Errors are
+	// ignored since we have already responded.
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	_ = enc.Encode(summary)
+	flush()
+
+	// Pin the skylinks with bounded concurrency.
+	jobs := make(chan SkynetPinManifestProgress)
+	results := make(chan SkynetPinManifestProgress)
+	var wg sync.WaitGroup
+	for i := 0; i < pinManifestConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pin := range jobs {
+				var skylink skymodules.Skylink
+				err := skylink.LoadString(pin.Skylink)
+				if err == nil {
+					lup := skymodules.SkyfileUploadParameters{
+						SiaPath: pin.SiaPath,
+					}
+					err = api.renter.PinSkylink(skylink, lup, false, timeout, pricePerMS)
+				}
+				if err != nil {
+					pin.Error = err.Error()
+				}
+				results <- pin
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, pin := range toPin {
+			select {
+			case jobs <- pin:
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for pin := range results {
+		_ = enc.Encode(pin)
+		flush()
+	}
+}
+
+// managedSkynetPinInfo returns the siapaths, size and redundancy of the
+// skyfile that was pinned at the given siapath. The size and redundancy take
+// the extended file into account if it exists.
+func (api *API) managedSkynetPinInfo(skylink skymodules.Skylink, siaPath skymodules.SiaPath) (SkynetPinHandlerPOST, error) {
+	file, err := api.renter.File(siaPath)
+	if err != nil {
+		return SkynetPinHandlerPOST{}, err
+	}
+	pin := SkynetPinHandlerPOST{
+		Skylink:    skylink.String(),
+		SiaPath:    siaPath,
+		Size:       file.Filesize,
+		Redundancy: file.Redundancy,
+		PinExpiry:  file.PinExpiry,
+	}
+
+	// Check for the extended file.
+	extendedSiaPath, err := siaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		return SkynetPinHandlerPOST{}, err
+	}
+	extendedFile, err := api.renter.File(extendedSiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		return pin, nil
+	}
+	if err != nil {
+		return SkynetPinHandlerPOST{}, err
+	}
+	pin.ExtendedSiaPath = &extendedSiaPath
+	pin.Size += extendedFile.Filesize
+	if extendedFile.Redundancy < pin.Redundancy {
+		pin.Redundancy = extendedFile.Redundancy
+	}
+	return pin, nil
+}
+
+// tusPreUploadCreateCallback is called before creating a TUS upload. It
+// rejects uploads which exceed the renter's maximum upload size before running
+// the renter's own checks.
+func (api *API) tusPreUploadCreateCallback(hook handler.HookEvent) error {
+	settings, err := api.renter.Settings()
+	if err != nil {
+		err = errors.AddContext(err, "failed to get renter settings")
+		return handler.NewHTTPError(err, http.StatusInternalServerError)
+	}
+	maxSize := settings.SkynetMaxUploadSize
+	if maxSize > 0 && hook.Upload.Size > 0 && uint64(hook.Upload.Size) > maxSize {
+		return handler.NewHTTPError(errMaxUploadSizeExceeded(maxSize), http.StatusRequestEntityTooLarge)
+	}
+	return renter.TUSPreUploadCreateCallback(hook)
+}
+
+// skynetTUSUploadSkylinkGET is the handler for the /skynet/tus/skylink/:id
+// endpoint.
+func (api *API) skynetTUSUploadSkylinkGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	// Get id from path.
+	id := ps.ByName("id")
+
+	// Get the uploader from the renter.
+	tusUploader := api.renter.SkynetTUSUploader()
+
+	// Fetch the skylink.
+	skylink, found := tusUploader.Skylink(id)
+	if !found {
+		WriteError(w, Error{"failed to fetch skylink for upload"}, http.StatusNotFound)
+		return
+	}
+
+	// Set the Skylink response header
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+
+	// Respond with the skylink in the body as well.
+	WriteJSON(w, newSkynetSkyfileHandlerPOST(skylink))
+}
+
+// skynetSkyfileHandlerPOST is a dual purpose endpoint. If the 'convertpath'
+// field is set, this endpoint will create a skyfile using an existing siafile.
+// The original siafile and the skyfile will both need to be kept in order for
+// the file to remain available on Skynet. If the 'convertpath' field is not
+// set, this is essentially an upload streaming endpoint for Skynet which
+// returns a skylink.
+func (api *API) skynetSkyfileHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// parse the request headers and parameters
+	headers, params, err := parseUploadHeadersAndRequestParameters(req, ps)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// if force was disabled, it can only be used with a valid force token
+	if headers.disableForce && params.force {
+		err = api.verifySkynetForceToken(headers.forceToken, params.siaPath)
+		if err != nil {
+			handleSkynetError(w, "unable to force upload", err)
+			return
+		}
+	}
+
+	// validate the parameters which depend on the node's state. This needs
+	// to happen before the body is read since the server only responds with
+	// '100 Continue' to requests with an 'Expect: 100-continue' header once
+	// the body is read. That way rejected uploads don't transmit the body.
+	err = api.validateSkyfileUploadParameters(params)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// enforce the maximum upload size, the upload policy and the multipart
+	// limits for streaming uploads
+	var uploadPolicy skymodules.SkynetUploadPolicy
+	var multipartLimits skymodules.SkynetMultipartLimits
+	if params.convertPath == "" {
+		if maxSize := settings.SkynetMaxUploadSize; maxSize > 0 {
+			if req.ContentLength > 0 && uint64(req.ContentLength) > maxSize {
+				WriteError(w, Error{errMaxUploadSizeExceeded(maxSize).Error()}, http.StatusRequestEntityTooLarge)
+				return
+			}
+			req.Body = newMaxUploadSizeReader(req.Body, maxSize)
+		}
+		uploadPolicy = settings.SkynetUploadPolicy
+		multipartLimits = settings.SkynetMultipartLimits
+
+		// the parts of multipart uploads are checked while they are read,
+		// single file uploads can be checked right away
+		if !isMultipartRequest(headers.mediaType) {
+			err = uploadPolicy.CheckFile(params.filename, headers.mediaType)
+			if err != nil {
+				handleSkynetError(w, "failed to upload file to skynet", err)
+				return
+			}
+		}
+	}
+
+	// build the upload parameters
+	sup := skymodules.SkyfileUploadParameters{
+		BaseChunkRedundancy: params.baseChunkRedundancy,
+		DryRun:              params.dryRun,
+		Force:               params.force,
+		SiaPath:             params.siaPath,
+
+		// Set filename, mode and modtime
+		Filename: params.filename,
+		Mode:     params.mode,
+		ModTime:  params.modTime,
+
+		// Set the default path params
+		DefaultPath:        params.defaultPath,
+		DisableDefaultPath: params.disableDefaultPath,
+
+		// Set encryption key details
+		SkykeyName: params.skyKeyName,
+		SkykeyID:   params.skyKeyID,
+
+		TryFiles:   params.tryFiles,
+		ErrorPages: params.errorPages,
+
+		// Set the erasure coding of the fanout
+		DataPieces:   params.dataPieces,
+		ParityPieces: params.parityPieces,
+
+		UploadPolicy:    uploadPolicy,
+		MultipartLimits: multipartLimits,
+		Sparse:          params.sparse,
+	}
+
+	// count the size of streaming uploads against the skynet storage cap if
+	// the request specifies it
+	if params.convertPath == "" && !params.redirect && req.ContentLength > 0 {
+		sup.ExpectedSize = uint64(req.ContentLength)
+	}
+
+	// stage the parts of resumable multipart uploads
+	if headers.uploadSession != "" {
+		sup.PartStager = api.staticSkynetPartStaging.Stager(headers.uploadSession, headers.partHashes)
+	}
+
+	// if the upload should return an existing skyfile, check whether there is
+	// one at the siapath. If so, the upload is only a dry run which computes
+	// the skylink of the uploaded content. The dry run uses a temporary
+	// siapath since its siafile is deleted afterwards.
+	var existingSkylink skymodules.Skylink
+	var exists bool
+	if params.ifExists == skyfileIfExistsReturn {
+		existingSkylink, exists, err = api.managedExistingSkyfile(req.Context(), &sup)
+		if err != nil {
+			handleSkynetError(w, "failed to check for an existing skyfile", err)
+			return
+		}
+	}
+	if exists {
+		sup.DryRun = true
+		sup.SiaPath, err = sup.SiaPath.AddSuffixStr("-ifexists-" + hex.EncodeToString(fastrand.Bytes(8)))
+		if err != nil {
+			WriteError(w, Error{"failed to create temporary siapath: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// if the uploader didn't specify a modtime, use the time of the upload.
+	// The modtime is returned in the response since uploading the same data
+	// again only results in the same skylink with the same modtime.
+	if sup.ModTime == 0 {
+		sup.ModTime = time.Now().Unix()
+	}
+
+	// set the reader
+	var reader skymodules.SkyfileUploadReader
+	if params.redirect {
+		// The body only contains the skylink the skyfile points to, the
+		// skyfile itself doesn't contain any data.
+		sup.Redirect, err = readRedirectSkylink(req.Body)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		reader = skymodules.NewSkyfileReader(bytes.NewReader(nil), sup)
+	} else if isMultipartRequest(headers.mediaType) {
+		reader, err = skymodules.NewSkyfileMultipartReaderFromRequest(req, sup)
+	} else {
+		reader = skymodules.NewSkyfileReader(req.Body, sup)
+	}
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("unable to create multipart reader: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// If requested, remember the layout and metadata of the base sector to
+	// return them in the response.
+	var baseSectorLayout skymodules.SkyfileLayout
+	var baseSectorMetadata []byte
+	if params.includeMetadata {
+		sup.BaseSectorHint = func(sl skymodules.SkyfileLayout, md []byte) {
+			baseSectorLayout = sl
+			baseSectorMetadata = md
+		}
+	}
+
+	// If requested, send the skylink as an early hint before the upload is
+	// complete.
+	if params.skylinkHint {
+		sup.SkylinkHint = func(skylink skymodules.Skylink) {
+			w.Header().Set(SkynetSkylinkHintHeader, skylink.String())
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Header().Del(SkynetSkylinkHintHeader)
+		}
+	}
+
+	// Check whether this is a streaming upload or a siafile conversion. If no
+	// convert path is provided, assume that the req.Body will be used as a
+	// streaming upload.
+	if params.convertPath == "" {
+		skylink, err := api.renter.UploadSkyfile(req.Context(), sup, reader)
+		if failedSkylink, ok := renter.SkylinkFromUploadError(err); ok {
+			writeSkyfileUploadError(w, "failed to upload file to skynet", failedSkylink, sup.ModTime, err)
+			return
+		}
+		if err != nil {
+			handleSkynetError(w, "failed to upload file to skynet", err)
+			return
+		}
+
+		// The staged parts are no longer needed once the upload succeeded.
+		if headers.uploadSession != "" {
+			api.staticSkynetPartStaging.Delete(headers.uploadSession)
+		}
+
+		// The existing skyfile is only returned if the content matches.
+		if exists && skylink != existingSkylink {
+			WriteError(w, Error{fmt.Sprintf("unable to upload to siapath %v: %v with different content, the uploaded content has skylink %v but the existing skyfile has skylink %v", params.siaPath, filesystem.ErrExists, skylink, existingSkylink)}, http.StatusBadRequest)
+			return
+		}
+
+		// Determine whether the file is large or not, and update the
+		// appropriate bucket.
+		//
+		// The way we have to count is a bit gross, because there are two files
+		// that we need to consider when looking for the size of the final
+		// upload. The first is the siapath, and then the second is the siapath
+		// of the extended file, which needs to be separated out because it can
+		// have different erasure code settings. To get the full filesize we add
+		// the size of the normal file, and then the size of the extended file.
+		// But the extended file may not exist, so we have to be careful with
+		// how we consider extending it. And then just in general the error
+		// handling here is a bit messy.
+		//
+		// It seems that in practice, all files report a size of 4 MB,
+		// regardless of how big the actual upload was. I didn't think this was
+		// the case, but to handle it correctly we consider anything that is
+		// smaller than 4300e3 bytes to be "small". Just a little fudging to
+		// match the performance bucket to the thing we are actually trying to
+		// measure.
+		file, err := api.renter.File(sup.SiaPath)
+		extendedPath := sup.SiaPath
+		extendedPath.Path = extendedPath.Path + ".extended"
+		file2, err2 := api.renter.File(extendedPath)
+		var filesize uint64
+		if err == nil {
+			filesize = file.Filesize
+		}
+		if err == nil && err2 == nil {
+			filesize += file2.Filesize
+		}
+
+		resp, err := skyfileUploadResponse(skylink, sup.ModTime, params.includeMetadata, baseSectorLayout, baseSectorMetadata)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		// Set the Skylink response header
+		w.Header().Set(SkynetSkylinkHeader, skylink.String())
+
+		WriteJSON(w, resp)
+		return
+	}
+
+	// There is a convert path.
+	convertPath, err := skymodules.NewSiaPath(params.convertPath)
+	if err != nil {
+		WriteError(w, Error{"invalid convertpath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	convertPath, err = rebaseInputSiaPath(convertPath)
+	if err != nil {
+		WriteError(w, Error{"invalid convertpath provided - can't rebase: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// If the conversion is async, start it in the background and return the
+	// conversion's ID.
+	if params.async {
+		id, err := api.renter.CreateSkylinkFromSiafileAsync(sup, convertPath)
+		if err != nil {
+			handleSkynetError(w, "failed to start converting siafile to skyfile", err)
+			return
+		}
+		WriteJSON(w, SkynetConvertHandlerPOST{
+			ID: id,
+		})
+		return
+	}
+
+	skylink, err := api.renter.CreateSkylinkFromSiafile(sup, convertPath)
+	if err != nil {
+		handleSkynetError(w, "failed to convert siafile to skyfile", err)
+		return
+	}
+	resp, err := skyfileUploadResponse(skylink, sup.ModTime, params.includeMetadata, baseSectorLayout, baseSectorMetadata)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// Set the Skylink response header
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+
+	WriteJSON(w, resp)
+}
+
+// verifySkynetForceToken verifies that a force token grants forcing a request
+// for the given siapath even though force was disabled for the request.
+func (api *API) verifySkynetForceToken(token *skymodules.SkynetForceToken, siaPath skymodules.SiaPath) error {
+	if token == nil {
+		return errSkynetForceDisabled
+	}
+	settings, err := api.renter.Settings()
+	if err != nil {
+		return errors.AddContext(err, "failed to get renter settings")
+	}
+	return token.Verify(settings.SkynetForceTokenKey, siaPath, time.Now())
+}
+
+// validateSkyfileUploadParameters checks that the skykey of an upload exists
+// and that the upload doesn't overwrite an existing file unless forced.
+func (api *API) validateSkyfileUploadParameters(params *skyfileUploadParams) error {
+	if params.skyKeyName != "" {
+		_, err := api.renter.SkykeyByName(params.skyKeyName)
+		if err != nil {
+			return errors.AddContext(err, "invalid 'skykeyname'")
+		}
+	}
+	if params.skyKeyID != (skykey.SkykeyID{}) {
+		_, err := api.renter.SkykeyByID(params.skyKeyID)
+		if err != nil {
+			return errors.AddContext(err, "invalid 'skykeyid'")
+		}
+	}
+	if params.convertPath == "" && !params.force && !params.dryRun && params.ifExists == "" {
+		_, err := api.renter.File(params.siaPath)
+		if err == nil {
+			return errors.AddContext(filesystem.ErrExists, fmt.Sprintf("unable to upload to siapath %v", params.siaPath))
+		}
+	}
+	return nil
+}
+
+// managedExistingSkyfile returns the skylink of the skyfile at the siapath of
+// an upload and whether there is one. Unless the uploader specified a modtime,
+// the modtime of the upload is set to the one of the existing skyfile since the
+// skylinks could never match otherwise.
+func (api *API) managedExistingSkyfile(ctx context.Context, sup *skymodules.SkyfileUploadParameters) (skymodules.Skylink, bool, error) {
+	file, err := api.renter.File(sup.SiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		return skymodules.Skylink{}, false, nil
+	}
+	if err != nil {
+		return skymodules.Skylink{}, false, errors.AddContext(err, "failed to fetch existing file")
+	}
+	if len(file.Skylinks) == 0 {
+		return skymodules.Skylink{}, false, errors.AddContext(filesystem.ErrExists, fmt.Sprintf("unable to upload to siapath %v, the existing file is not a skyfile", sup.SiaPath))
+	}
+	var skylink skymodules.Skylink
+	err = skylink.LoadString(file.Skylinks[0])
+	if err != nil {
+		return skymodules.Skylink{}, false, errors.AddContext(err, "failed to parse skylink of existing file")
+	}
+	if sup.ModTime != 0 {
+		return skylink, true, nil
+	}
+
+	// Fetch the modtime from the metadata of the existing skyfile.
+	timeout, _ := api.skynetRequestTimeouts()
+	streamer, _, err := api.renter.DownloadSkylink(ctx, skylink, timeout, skymodules.DefaultSkynetPricePerMS)
+	if err != nil {
+		return skymodules.Skylink{}, false, errors.AddContext(err, "failed to fetch metadata of existing skyfile")
+	}
+	sup.ModTime = streamer.Metadata().ModTime
+	return skylink, true, streamer.Close()
+}
+
+// skynetStatsHandlerGET responds with a JSON with statistical data about
+// skynet, e.g. number of files uploaded, total size, etc.
+func (api *API) skynetStatsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	// Pull the skynet stats from the root directory
+	dirs, err := api.renter.DirList(skymodules.RootSiaPath())
+	if err != nil {
+		WriteError(w, Error{"unable to get root directory status: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	rootDir := dirs[0]
+
+	// get version
+	version := build.NodeVersion
+	if build.ReleaseTag != "" {
+		version += "-" + build.ReleaseTag
+	}
+
+	// Grab the siad uptime
+	uptime := time.Since(api.StartTime()).Seconds()
+
+	// Get the registry stats.
+	renterPerf, err := api.renter.Performance()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter registry status: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check for any critical alerts.
+	numCritAlerts := 0
+	if api.gateway != nil {
+		a, _, _ := api.gateway.Alerts()
+		numCritAlerts += len(a)
+	}
+	if api.cs != nil {
+		a, _, _ := api.cs.Alerts()
+		numCritAlerts += len(a)
+	}
+	if api.tpool != nil {
+		a, _, _ := api.tpool.Alerts()
+		numCritAlerts += len(a)
+	}
+	if api.wallet != nil {
+		a, _, _ := api.wallet.Alerts()
+		numCritAlerts += len(a)
 	}
 	if api.renter != nil {
 		a, _, _ := api.renter.Alerts()
 		numCritAlerts += len(a)
 	}
-	if api.host != nil {
-		a, _, _ := api.host.Alerts()
-		numCritAlerts += len(a)
+	if api.host != nil {
+		a, _, _ := api.host.Alerts()
+		numCritAlerts += len(a)
+	}
+
+	// Determine the wallet status.
+	var walletStatus string
+	var allowance skymodules.Allowance
+	unlocked, err := api.wallet.Unlocked()
+	if err != nil {
+		WriteError(w, Error{"unable to get wallet lock status: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	walletFunds, _, _, err := api.wallet.ConfirmedBalance()
+	if err != nil {
+		WriteError(w, Error{"unable to get wallet balance: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	renterSettings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter settings: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	allowance = renterSettings.Allowance
+	if !unlocked {
+		walletStatus = "locked"
+	} else if walletFunds.Cmp(allowance.Funds.Div64(3)) < 0 {
+		walletStatus = "low"
+	} else if walletFunds.Cmp(allowance.Funds.Mul64(3)) > 0 {
+		walletStatus = "high"
+	} else {
+		walletStatus = "healthy"
+	}
+
+	// Determine the allowance status.
+	financialMetrics, err := api.renter.PeriodSpending()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter financial breakdonw: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	_, _, unspentUnallocated := financialMetrics.SpendingBreakdown()
+	var allowanceStatus string
+	if unspentUnallocated.Cmp(types.NewCurrency64(10e3)) < 0 {
+		allowanceStatus = "low"
+	} else if unspentUnallocated.Cmp(allowance.Funds.Div64(5)) < 0 {
+		allowanceStatus = "low"
+	} else if unspentUnallocated.Cmp(allowance.Funds.Mul64(3).Div64(4)) > 0 && allowance.Funds.Cmp(types.NewCurrency64(50e3)) > 0 {
+		allowanceStatus = "high"
+	} else {
+		allowanceStatus = "healthy"
+	}
+
+	// Get information about the total contracts size.
+	var totalStorage uint64
+	for _, c := range api.renter.Contracts() {
+		totalStorage += c.Size()
+	}
+
+	// Get the sector stats
+	baseSectorStats := renterPerf.BaseSectorDownloadOverdriveStats
+	fanoutSectorStats := renterPerf.FanoutSectorDownloadOverdriveStats
+
+	WriteJSON(w, &SkynetStatsGET{
+		BaseSectorUpload15mDataPoints: renterPerf.BaseSectorUploadStats.DataPoints[0],
+		BaseSectorUpload15mP99ms:      float64(renterPerf.BaseSectorUploadStats.Nines[0][1]) / float64(time.Millisecond),
+		BaseSectorUpload15mP999ms:     float64(renterPerf.BaseSectorUploadStats.Nines[0][2]) / float64(time.Millisecond),
+		BaseSectorUpload15mP9999ms:    float64(renterPerf.BaseSectorUploadStats.Nines[0][3]) / float64(time.Millisecond),
+
+		BaseSectorOverdrivePct:   baseSectorStats.OverdrivePct(),
+		BaseSectorOverdriveAvg:   baseSectorStats.NumOverdriveWorkersAvg(),
+		FanoutSectorOverdrivePct: fanoutSectorStats.OverdrivePct(),
+		FanoutSectorOverdriveAvg: fanoutSectorStats.NumOverdriveWorkersAvg(),
+
+		ChunkUpload15mDataPoints: renterPerf.ChunkUploadStats.DataPoints[0],
+		ChunkUpload15mP99ms:      float64(renterPerf.ChunkUploadStats.Nines[0][1]) / float64(time.Millisecond),
+		ChunkUpload15mP999ms:     float64(renterPerf.ChunkUploadStats.Nines[0][2]) / float64(time.Millisecond),
+		ChunkUpload15mP9999ms:    float64(renterPerf.ChunkUploadStats.Nines[0][3]) / float64(time.Millisecond),
+
+		RegistryRead15mDataPoints: renterPerf.RegistryReadStats.DataPoints[0],
+		RegistryRead15mP99ms:      float64(renterPerf.RegistryReadStats.Nines[0][1]) / float64(time.Millisecond),
+		RegistryRead15mP999ms:     float64(renterPerf.RegistryReadStats.Nines[0][2]) / float64(time.Millisecond),
+		RegistryRead15mP9999ms:    float64(renterPerf.RegistryReadStats.Nines[0][3]) / float64(time.Millisecond),
+
+		RegistryWrite15mDataPoints: renterPerf.RegistryWriteStats.DataPoints[0],
+		RegistryWrite15mP99ms:      float64(renterPerf.RegistryWriteStats.Nines[0][1]) / float64(time.Millisecond),
+		RegistryWrite15mP999ms:     float64(renterPerf.RegistryWriteStats.Nines[0][2]) / float64(time.Millisecond),
+		RegistryWrite15mP9999ms:    float64(renterPerf.RegistryWriteStats.Nines[0][3]) / float64(time.Millisecond),
+
+		StreamBufferRead15mDataPoints: renterPerf.StreamBufferReadStats.DataPoints[0],
+		StreamBufferRead15mP99ms:      float64(renterPerf.StreamBufferReadStats.Nines[0][1]) / float64(time.Millisecond),
+		StreamBufferRead15mP999ms:     float64(renterPerf.StreamBufferReadStats.Nines[0][2]) / float64(time.Millisecond),
+		StreamBufferRead15mP9999ms:    float64(renterPerf.StreamBufferReadStats.Nines[0][3]) / float64(time.Millisecond),
+
+		Formats: api.staticSkynetStats.Formats(),
+
+		SystemHealthScanDurationHours: float64(renterPerf.SystemHealthScanDuration) / float64(time.Hour),
+
+		AllowanceStatus:     allowanceStatus,
+		ContractStorage:     totalStorage,
+		Maintenance:         renterSettings.SkynetMaintenance,
+		MaxHealthPercentage: rootDir.AggregateMaxHealthPercentage,
+		MaxStoragePrice:     allowance.MaxStoragePrice,
+		NumCritAlerts:       numCritAlerts,
+		NumFiles:            rootDir.AggregateSkynetFiles,
+		PortalMode:          allowance.PortalMode(),
+		RawStorage:          rootDir.AggregateSkynetRawSize,
+		Repair:              rootDir.AggregateRepairSize,
+		Storage:             rootDir.AggregateSkynetSize,
+		StuckChunks:         rootDir.AggregateNumStuckChunks,
+		WalletStatus:        walletStatus,
+
+		Uptime: int64(uptime),
+		VersionInfo: SkynetVersion{
+			Version:     version,
+			GitRevision: build.GitRevision,
+		},
+	})
+}
+
+// skynetWorkersHandlerGET responds with a summary of the worker pool which is
+// relevant for skynet downloads and uploads.
+func (api *API) skynetWorkersHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	wps, err := api.renter.WorkerPoolStatus()
+	if err != nil {
+		WriteError(w, Error{"unable to get worker pool status: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, newSkynetWorkersGET(wps))
+}
+
+// skykeyHandlerGET handles the API call to get a Skykey and its ID using its
+// name or ID.
+func (api *API) skykeyHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse Skykey id and name.
+	name := req.FormValue("name")
+	idString := req.FormValue("id")
+
+	if idString == "" && name == "" {
+		WriteError(w, Error{"you must specify the name or ID of the skykey"}, http.StatusInternalServerError)
+		return
+	}
+	if idString != "" && name != "" {
+		WriteError(w, Error{"you must specify either the name or ID of the skykey, not both"}, http.StatusInternalServerError)
+		return
+	}
+
+	var sk skykey.Skykey
+	var err error
+	if name != "" {
+		sk, err = api.renter.SkykeyByName(name)
+	} else if idString != "" {
+		var id skykey.SkykeyID
+		err = id.FromString(idString)
+		if err != nil {
+			WriteError(w, Error{"failed to decode ID string: "}, http.StatusInternalServerError)
+			return
+		}
+		sk, err = api.renter.SkykeyByID(id)
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to retrieve skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	skString, err := sk.ToString()
+	if err != nil {
+		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkykeyGET{
+		Skykey: skString,
+		Name:   sk.Name,
+		ID:     sk.ID().ToString(),
+		Type:   sk.Type.ToString(),
+	})
+}
+
+// skykeyDeleteHandlerGET handles the API call to delete a Skykey using its name
+// or ID.
+func (api *API) skykeyDeleteHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse Skykey id and name.
+	name := req.FormValue("name")
+	idString := req.FormValue("id")
+
+	if idString == "" && name == "" {
+		WriteError(w, Error{"you must specify the name or ID of the skykey"}, http.StatusBadRequest)
+		return
+	}
+	if idString != "" && name != "" {
+		WriteError(w, Error{"you must specify either the name or ID of the skykey, not both"}, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if name != "" {
+		err = api.renter.DeleteSkykeyByName(name)
+	} else if idString != "" {
+		var id skykey.SkykeyID
+		err = id.FromString(idString)
+		if err != nil {
+			WriteError(w, Error{"Invalid skykey ID: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.renter.DeleteSkykeyByID(id)
+	}
+
+	if err != nil {
+		WriteError(w, Error{"failed to delete skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// skykeyCreateKeyHandlerPost handles the API call to create a skykey using the renter's
+// skykey manager.
+func (api *API) skykeyCreateKeyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse skykey name and type
+	name := req.FormValue("name")
+	skykeyTypeString := req.FormValue("type")
+
+	if name == "" {
+		WriteError(w, Error{"you must specify the name of the skykey"}, http.StatusInternalServerError)
+		return
+	}
+
+	if skykeyTypeString == "" {
+		WriteError(w, Error{"you must specify the type of the skykey"}, http.StatusInternalServerError)
+		return
+	}
+
+	var skykeyType skykey.SkykeyType
+	err := skykeyType.FromString(skykeyTypeString)
+	if err != nil {
+		WriteError(w, Error{"failed to decode skykey type: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	sk, err := api.renter.CreateSkykey(name, skykeyType)
+	if err != nil {
+		WriteError(w, Error{"failed to create skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	keyString, err := sk.ToString()
+	if err != nil {
+		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSON(w, SkykeyGET{
+		Skykey: keyString,
+		Name:   name,
+		ID:     sk.ID().ToString(),
+		Type:   skykeyTypeString,
+	})
+}
+
+// skykeyAddKeyHandlerPost handles the API call to add a skykey to the renter's
+// skykey manager.
+func (api *API) skykeyAddKeyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse skykey.
+	skString := req.FormValue("skykey")
+	if skString == "" {
+		WriteError(w, Error{"you must specify the name of the skykey"}, http.StatusInternalServerError)
+		return
+	}
+
+	var sk skykey.Skykey
+	err := sk.FromString(skString)
+	if err != nil {
+		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	err = api.renter.AddSkykey(sk)
+	if err != nil {
+		WriteError(w, Error{"failed to add skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteSuccess(w)
+}
+
+// skykeyExportHandlerPOST handles the API call to export a skykey protected
+// by a password.
+func (api *API) skykeyExportHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse name and password.
+	name := req.FormValue("name")
+	if name == "" {
+		WriteError(w, Error{"you must specify the name of the skykey"}, http.StatusBadRequest)
+		return
+	}
+	password := req.FormValue("password")
+	if password == "" {
+		WriteError(w, Error{"you must specify a password"}, http.StatusBadRequest)
+		return
+	}
+
+	sk, err := api.renter.SkykeyByName(name)
+	if errors.Contains(err, skykey.ErrNoSkykeysWithThatName) {
+		WriteError(w, Error{"failed to retrieve skykey: " + err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to retrieve skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	export, err := sk.ExportWithPassword(password)
+	if err != nil {
+		WriteError(w, Error{"failed to export skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkykeyExportPOST{
+		Export: base64.URLEncoding.EncodeToString(export),
+		Name:   sk.Name,
+		ID:     sk.ID().ToString(),
+	})
+}
+
+// skykeyImportHandlerPOST handles the API call to import a password protected
+// skykey into the renter's skykey manager.
+func (api *API) skykeyImportHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse export and password.
+	exportStr := req.FormValue("export")
+	if exportStr == "" {
+		WriteError(w, Error{"you must specify the exported skykey"}, http.StatusBadRequest)
+		return
+	}
+	password := req.FormValue("password")
+	if password == "" {
+		WriteError(w, Error{"you must specify a password"}, http.StatusBadRequest)
+		return
+	}
+	export, err := base64.URLEncoding.DecodeString(exportStr)
+	if err != nil {
+		WriteError(w, Error{"failed to decode exported skykey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	sk, err := skykey.ImportWithPassword(export, password)
+	if err != nil {
+		WriteError(w, Error{"failed to import skykey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	err = api.renter.AddSkykey(sk)
+	if errors.Contains(err, skykey.ErrSkykeyWithIDAlreadyExists) {
+		WriteError(w, Error{"failed to add skykey: " + err.Error()}, http.StatusConflict)
+		return
+	}
+	if errors.Contains(err, skykey.ErrSkykeyWithNameAlreadyExists) {
+		WriteError(w, Error{"failed to add skykey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to add skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	skString, err := sk.ToString()
+	if err != nil {
+		WriteError(w, Error{"failed to encode skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkykeyGET{
+		Skykey: skString,
+		Name:   sk.Name,
+		ID:     sk.ID().ToString(),
+		Type:   sk.Type.ToString(),
+	})
+}
+
+// skykeysHandlerGET handles the API call to get all of the renter's skykeys.
+func (api *API) skykeysHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	skykeys, err := api.renter.Skykeys()
+	if err != nil {
+		WriteError(w, Error{"Unable to get skykeys: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	res := SkykeysGET{
+		Skykeys: make([]SkykeyGET, len(skykeys)),
+	}
+	for i, sk := range skykeys {
+		skStr, err := sk.ToString()
+		if err != nil {
+			WriteError(w, Error{"failed to write skykey string: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		res.Skykeys[i] = SkykeyGET{
+			Skykey: skStr,
+			Name:   sk.Name,
+			ID:     sk.ID().ToString(),
+			Type:   sk.Type.ToString(),
+		}
+	}
+	WriteJSON(w, res)
+}
+
+// skykeysMatchHandlerGET handles the API call to find the skykey that the
+// skyfile behind a skylink is encrypted with.
+func (api *API) skykeysMatchHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the skylink.
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch the base sector.
+	streamer, _, _, err := api.renter.DownloadSkylinkBaseSector(req.Context(), skylink, timeout, pricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to fetch base sector", err)
+		return
+	}
+	defer func() {
+		_ = streamer.Close()
+	}()
+	baseSector, err := ioutil.ReadAll(streamer)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("failed to read base sector: %v", err)}, http.StatusInternalServerError)
+		return
+	}
+	if !skymodules.IsEncryptedBaseSector(baseSector) {
+		WriteError(w, Error{"skyfile is not encrypted"}, http.StatusBadRequest)
+		return
 	}
 
-	// Determine the wallet status.
-	var walletStatus string
-	var allowance skymodules.Allowance
-	unlocked, err := api.wallet.Unlocked()
+	// Try to decrypt it. The returned key is the file-specific subkey which
+	// shares its name with the skykey it was derived from.
+	fileKey, err := api.renter.DecryptBaseSector(baseSector)
+	if errors.Contains(err, renter.ErrNoSkykeyMatchesSkyfileEncryptionID) {
+		WriteError(w, Error{"none of the node's skykeys match the skyfile"}, http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		WriteError(w, Error{"unable to get wallet lock status: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("failed to decrypt base sector: %v", err)}, http.StatusInternalServerError)
 		return
 	}
-	walletFunds, _, _, err := api.wallet.ConfirmedBalance()
+	sk, err := api.renter.SkykeyByName(fileKey.Name)
 	if err != nil {
-		WriteError(w, Error{"unable to get wallet balance: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"failed to get matching skykey: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkykeyMatchGET{
+		Name: sk.Name,
+		ID:   sk.ID().ToString(),
+		Type: sk.Type.ToString(),
+	})
+}
+
+// skykeysRenameHandlerPOST handles the API call to rename a skykey in the
+// renter's skykey manager.
+func (api *API) skykeysRenameHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the old and new name.
+	oldName := req.FormValue("oldname")
+	newName := req.FormValue("newname")
+	if oldName == "" || newName == "" {
+		WriteError(w, Error{"you must specify both the old and the new name of the skykey"}, http.StatusBadRequest)
+		return
+	}
+
+	err := api.renter.RenameSkykey(oldName, newName)
+	if errors.Contains(err, skykey.ErrNoSkykeysWithThatName) {
+		WriteError(w, Error{"failed to rename skykey: " + err.Error()}, http.StatusNotFound)
+		return
+	}
+	if errors.Contains(err, skykey.ErrSkykeyWithNameAlreadyExists) {
+		WriteError(w, Error{"failed to rename skykey: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-	renterSettings, err := api.renter.Settings()
 	if err != nil {
-		WriteError(w, Error{"unable to get renter settings: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{"failed to rename skykey: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
-	allowance = renterSettings.Allowance
-	if !unlocked {
-		walletStatus = "locked"
-	} else if walletFunds.Cmp(allowance.Funds.Div64(3)) < 0 {
-		walletStatus = "low"
-	} else if walletFunds.Cmp(allowance.Funds.Mul64(3)) > 0 {
-		walletStatus = "high"
-	} else {
-		walletStatus = "healthy"
+
+	WriteSuccess(w)
+}
+
+// registryHandlerPOST handles the POST calls to /skynet/registry.
+func (api *API) registryHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var rhp RegistryHandlerRequestPOST
+	err := dec.Decode(&rhp)
+	if err != nil {
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
 	}
 
-	// Determine the allowance status.
-	financialMetrics, err := api.renter.PeriodSpending()
+	// If the type wasn't set, default to no pubkey to preserve
+	// compatibility.
+	if rhp.Type == modules.RegistryTypeInvalid {
+		rhp.Type = modules.RegistryTypeWithoutPubkey
+	}
+
+	// Check the type and data here to be able to offer a better and faster
+	// error message than when the hosts return it.
+	if _, err := validateRegistryEntryData(rhp.Type, rhp.Data); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// If a key name was provided, the update is signed with the stored key.
+	// Otherwise the client needs to provide the signature.
+	spk := rhp.PublicKey
+	srv := modules.NewSignedRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Signature, rhp.Type)
+	if rhp.KeyName != "" {
+		if rhp.Signature != (crypto.Signature{}) {
+			WriteError(w, Error{"'signature' can't be provided when signing with a stored key"}, http.StatusBadRequest)
+			return
+		}
+		rv := modules.NewRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Type)
+		spk, srv, err = api.renter.SignRegistryValue(rhp.KeyName, rv)
+		if errors.Contains(err, renter.ErrRegistryKeyNotFound) {
+			WriteError(w, Error{"unable to sign registry update: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			WriteError(w, Error{"unable to sign registry update: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if len(rhp.PublicKey.Key) > 0 && !rhp.PublicKey.Equals(spk) {
+			WriteError(w, Error{"'publickey' doesn't match the stored key"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Prepare a context for the timeout.
+	ctx, cancel := context.WithTimeout(req.Context(), renter.DefaultRegistryUpdateTimeout)
+	defer cancel()
+
+	// Update the registry.
+	err = api.renter.UpdateRegistry(ctx, spk, srv)
 	if err != nil {
-		WriteError(w, Error{"unable to get renter financial breakdonw: " + err.Error()}, http.StatusBadRequest)
+		handleSkynetError(w, "Unable to update the registry", err)
 		return
 	}
-	_, _, unspentUnallocated := financialMetrics.SpendingBreakdown()
-	var allowanceStatus string
-	if unspentUnallocated.Cmp(types.NewCurrency64(10e3)) < 0 {
-		allowanceStatus = "low"
-	} else if unspentUnallocated.Cmp(allowance.Funds.Div64(5)) < 0 {
-		allowanceStatus = "low"
-	} else if unspentUnallocated.Cmp(allowance.Funds.Mul64(3).Div64(4)) > 0 && allowance.Funds.Cmp(types.NewCurrency64(50e3)) > 0 {
-		allowanceStatus = "high"
-	} else {
-		allowanceStatus = "healthy"
+	WriteSuccess(w)
+}
+
+// registryKeyHandlerGET handles the GET calls to /skynet/registry/key.
+func (api *API) registryKeyHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	keys, err := api.renter.RegistryKeys()
+	if err != nil {
+		WriteError(w, Error{"unable to get registry keys: " + err.Error()}, http.StatusInternalServerError)
+		return
 	}
+	WriteJSON(w, RegistryKeysGET{
+		Keys: keys,
+	})
+}
 
-	// Get information about the total contracts size.
-	var totalStorage uint64
-	for _, c := range api.renter.Contracts() {
-		totalStorage += c.Size()
+// registryKeyHandlerPOST handles the POST calls to /skynet/registry/key.
+func (api *API) registryKeyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	name := req.FormValue("name")
+	if name == "" {
+		WriteError(w, Error{"you must specify the name of the registry key"}, http.StatusBadRequest)
+		return
 	}
+	key, err := api.renter.CreateRegistryKey(name)
+	if errors.Contains(err, renter.ErrRegistryKeyExists) {
+		WriteError(w, Error{"failed to create registry key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to create registry key: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, key)
+}
 
-	// Get the sector stats
-	baseSectorStats := renterPerf.BaseSectorDownloadOverdriveStats
-	fanoutSectorStats := renterPerf.FanoutSectorDownloadOverdriveStats
+// registryKeyDeleteHandlerPOST handles the POST calls to
+// /skynet/registry/key/delete.
+func (api *API) registryKeyDeleteHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	name := req.FormValue("name")
+	if name == "" {
+		WriteError(w, Error{"you must specify the name of the registry key"}, http.StatusBadRequest)
+		return
+	}
 
-	WriteJSON(w, &SkynetStatsGET{
-		BaseSectorUpload15mDataPoints: renterPerf.BaseSectorUploadStats.DataPoints[0],
-		BaseSectorUpload15mP99ms:      float64(renterPerf.BaseSectorUploadStats.Nines[0][1]) / float64(time.Millisecond),
-		BaseSectorUpload15mP999ms:     float64(renterPerf.BaseSectorUploadStats.Nines[0][2]) / float64(time.Millisecond),
-		BaseSectorUpload15mP9999ms:    float64(renterPerf.BaseSectorUploadStats.Nines[0][3]) / float64(time.Millisecond),
+	// Deleting a key makes all entries signed with it immutable, so the
+	// caller needs to confirm it.
+	var confirm bool
+	confirmStr := req.FormValue("confirm")
+	if confirmStr != "" {
+		var err error
+		confirm, err = strconv.ParseBool(confirmStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'confirm' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if !confirm {
+		WriteError(w, Error{"deleting a registry key requires 'confirm' to be set to true"}, http.StatusBadRequest)
+		return
+	}
 
-		BaseSectorOverdrivePct:   baseSectorStats.OverdrivePct(),
-		BaseSectorOverdriveAvg:   baseSectorStats.NumOverdriveWorkersAvg(),
-		FanoutSectorOverdrivePct: fanoutSectorStats.OverdrivePct(),
-		FanoutSectorOverdriveAvg: fanoutSectorStats.NumOverdriveWorkersAvg(),
+	err := api.renter.DeleteRegistryKey(name)
+	if errors.Contains(err, renter.ErrRegistryKeyNotFound) {
+		WriteError(w, Error{"failed to delete registry key: " + err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to delete registry key: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
 
-		ChunkUpload15mDataPoints: renterPerf.ChunkUploadStats.DataPoints[0],
-		ChunkUpload15mP99ms:      float64(renterPerf.ChunkUploadStats.Nines[0][1]) / float64(time.Millisecond),
-		ChunkUpload15mP999ms:     float64(renterPerf.ChunkUploadStats.Nines[0][2]) / float64(time.Millisecond),
-		ChunkUpload15mP9999ms:    float64(renterPerf.ChunkUploadStats.Nines[0][3]) / float64(time.Millisecond),
+// registryLargeHandlerGET handles the GET calls to /skynet/registry/large.
+func (api *API) registryLargeHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+	var spk types.SiaPublicKey
+	err = spk.LoadString(queryForm.Get("publickey"))
+	if err != nil {
+		WriteError(w, Error{"Unable to parse publickey param: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var dataKey crypto.Hash
+	err = dataKey.LoadString(queryForm.Get("datakey"))
+	if err != nil {
+		WriteError(w, Error{"Unable to decode dataKey param: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	timeout, err := parseRegistryTimeout(queryForm)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Read the data.
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	data, revision, err := api.renter.ReadRegistryLarge(ctx, spk, dataKey)
+	if err != nil {
+		handleSkynetError(w, "unable to read large registry data", err)
+		return
+	}
+	WriteJSON(w, RegistryLargeGET{
+		Data:     hex.EncodeToString(data),
+		Revision: revision,
+	})
+}
+
+// registryLargeHandlerPOST handles the POST calls to /skynet/registry/large.
+// The data is split across multiple registry entries which are signed with a
+// stored registry key.
+func (api *API) registryLargeHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var rlp RegistryLargeRequestPOST
+	err := dec.Decode(&rlp)
+	if err != nil {
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if rlp.KeyName == "" {
+		WriteError(w, Error{"'keyname' needs to be provided"}, http.StatusBadRequest)
+		return
+	}
+
+	// Store the data.
+	revision, err := api.renter.UpdateRegistryLarge(req.Context(), rlp.KeyName, rlp.DataKey, rlp.Data)
+	if err != nil {
+		handleSkynetError(w, "Unable to store large registry data", err)
+		return
+	}
+	WriteJSON(w, RegistryLargePOST{
+		Revision: revision,
+	})
+}
+
+// skynetPublishHandlerPOST handles the POST calls to /skynet/publish. It
+// points the registry entry of a stored registry key at a V1 skylink and
+// returns the V2 skylink which resolves to it.
+func (api *API) skynetPublishHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var spr SkynetPublishRequestPOST
+	err := dec.Decode(&spr)
+	if err != nil {
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// If the public key was provided, look up the name of the stored key.
+	keyName := spr.KeyName
+	if len(spr.PublicKey.Key) > 0 {
+		keys, err := api.renter.RegistryKeys()
+		if err != nil {
+			WriteError(w, Error{"unable to get registry keys: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		var pkName string
+		for _, key := range keys {
+			if key.PublicKey.Equals(spr.PublicKey) {
+				pkName = key.Name
+				break
+			}
+		}
+		if pkName == "" {
+			WriteError(w, Error{"no registry key stored for 'publickey'"}, http.StatusBadRequest)
+			return
+		}
+		if keyName != "" && keyName != pkName {
+			WriteError(w, Error{"'publickey' doesn't match the stored key"}, http.StatusBadRequest)
+			return
+		}
+		keyName = pkName
+	}
+	if keyName == "" {
+		WriteError(w, Error{"either 'keyname' or 'publickey' needs to be provided"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the skylink.
+	var skylink skymodules.Skylink
+	err = skylink.LoadString(spr.Skylink)
+	if err != nil {
+		WriteError(w, Error{"Unable to parse skylink: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if !skylink.IsSkylinkV1() {
+		WriteError(w, Error{"'skylink' needs to be a V1 skylink"}, http.StatusBadRequest)
+		return
+	}
+
+	// Publish the skylink.
+	slV2, revision, err := api.renter.PublishSkylink(req.Context(), keyName, spr.DataKey, skylink)
+	if err != nil {
+		handleSkynetError(w, "Unable to publish skylink", err)
+		return
+	}
+	WriteJSON(w, SkynetPublishPOST{
+		Skylink:  slV2.String(),
+		Revision: revision,
+	})
+}
+
+// registryMultiHandlerPOST handles the POST calls to /skynet/registrymulti.
+func (api *API) registryMultiHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var rhps []RegistryHandlerMultiRequestPOST
+	err := dec.Decode(&rhps)
+	if err != nil {
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check request and combine them into a map from host to entry.
+	srvs := make(map[string]skymodules.RegistryEntry, len(rhps))
+	for i, rhp := range rhps {
+		// If the type wasn't set, default to no pubkey to preserve
+		// compatibility.
+		if rhp.Type == modules.RegistryTypeInvalid {
+			rhp.Type = modules.RegistryTypeWithoutPubkey
+			rhps[i].Type = modules.RegistryTypeWithoutPubkey
+		}
+
+		// Check the type and data here to be able to offer a better and
+		// faster error message than when the hosts return it.
+		if _, err := validateRegistryEntryData(rhp.Type, rhp.Data); err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		srv := modules.NewSignedRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Signature, rhp.Type)
+
+		// Each update should be for a different host.
+		_, exists := srvs[rhp.HostKey.String()]
+		if exists {
+			WriteError(w, Error{"Updating multiple entries on one host is not supported"}, http.StatusBadRequest)
+			return
+		}
+		srvs[rhp.HostKey.String()] = skymodules.NewRegistryEntry(rhp.PublicKey, srv)
+	}
+
+	// Prepare a context for the timeout.
+	ctx, cancel := context.WithTimeout(req.Context(), renter.DefaultRegistryUpdateTimeout)
+	defer cancel()
 
-		RegistryRead15mDataPoints: renterPerf.RegistryReadStats.DataPoints[0],
-		RegistryRead15mP99ms:      float64(renterPerf.RegistryReadStats.Nines[0][1]) / float64(time.Millisecond),
-		RegistryRead15mP999ms:     float64(renterPerf.RegistryReadStats.Nines[0][2]) / float64(time.Millisecond),
-		RegistryRead15mP9999ms:    float64(renterPerf.RegistryReadStats.Nines[0][3]) / float64(time.Millisecond),
+	// Update the registry.
+	err = api.renter.UpdateRegistryMulti(ctx, srvs)
+	if err != nil {
+		handleSkynetError(w, "Unable to update the registry", err)
+		return
+	}
+	WriteSuccess(w)
+}
 
-		RegistryWrite15mDataPoints: renterPerf.RegistryWriteStats.DataPoints[0],
-		RegistryWrite15mP99ms:      float64(renterPerf.RegistryWriteStats.Nines[0][1]) / float64(time.Millisecond),
-		RegistryWrite15mP999ms:     float64(renterPerf.RegistryWriteStats.Nines[0][2]) / float64(time.Millisecond),
-		RegistryWrite15mP9999ms:    float64(renterPerf.RegistryWriteStats.Nines[0][3]) / float64(time.Millisecond),
+// registryBatchHandlerPOST handles the POST calls to /skynet/registry/batch.
+func (api *API) registryBatchHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode request.
+	dec := json.NewDecoder(req.Body)
+	var rhps []RegistryHandlerRequestPOST
+	err := dec.Decode(&rhps)
+	if err != nil {
+		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if len(rhps) == 0 {
+		WriteError(w, Error{"No registry entries provided"}, http.StatusBadRequest)
+		return
+	}
+	if len(rhps) > MaxRegistryBatchSize {
+		WriteError(w, Error{fmt.Sprintf("Too many registry entries: %v > %v", len(rhps), MaxRegistryBatchSize)}, http.StatusBadRequest)
+		return
+	}
 
-		StreamBufferRead15mDataPoints: renterPerf.StreamBufferReadStats.DataPoints[0],
-		StreamBufferRead15mP99ms:      float64(renterPerf.StreamBufferReadStats.Nines[0][1]) / float64(time.Millisecond),
-		StreamBufferRead15mP999ms:     float64(renterPerf.StreamBufferReadStats.Nines[0][2]) / float64(time.Millisecond),
-		StreamBufferRead15mP9999ms:    float64(renterPerf.StreamBufferReadStats.Nines[0][3]) / float64(time.Millisecond),
+	// Check the entries. Entries that are invalid are reported right away
+	// without being passed on to the renter.
+	results := make([]RegistryBatchResult, len(rhps))
+	entries := make([]skymodules.RegistryEntry, 0, len(rhps))
+	indices := make([]int, 0, len(rhps))
+	for i, rhp := range rhps {
+		// If the type wasn't set, default to no pubkey to preserve
+		// compatibility.
+		if rhp.Type == modules.RegistryTypeInvalid {
+			rhp.Type = modules.RegistryTypeWithoutPubkey
+		}
 
-		SystemHealthScanDurationHours: float64(renterPerf.SystemHealthScanDuration) / float64(time.Hour),
+		// Check the type and data here to be able to offer a better and
+		// faster error message than when the hosts return it.
+		if errorCode, err := validateRegistryEntryData(rhp.Type, rhp.Data); err != nil {
+			results[i] = RegistryBatchResult{
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  errorCode,
+				Error:      err.Error(),
+			}
+			continue
+		}
+		srv := modules.NewSignedRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Signature, rhp.Type)
+		entries = append(entries, skymodules.NewRegistryEntry(rhp.PublicKey, srv))
+		indices = append(indices, i)
+	}
 
-		AllowanceStatus:     allowanceStatus,
-		ContractStorage:     totalStorage,
-		MaxHealthPercentage: rootDir.AggregateMaxHealthPercentage,
-		MaxStoragePrice:     allowance.MaxStoragePrice,
-		NumCritAlerts:       numCritAlerts,
-		NumFiles:            rootDir.AggregateSkynetFiles,
-		PortalMode:          allowance.PortalMode(),
-		Repair:              rootDir.AggregateRepairSize,
-		Storage:             rootDir.AggregateSkynetSize,
-		StuckChunks:         rootDir.AggregateNumStuckChunks,
-		WalletStatus:        walletStatus,
+	// Prepare a context for the shared deadline.
+	ctx, cancel := context.WithTimeout(req.Context(), renter.DefaultRegistryUpdateTimeout)
+	defer cancel()
 
-		Uptime: int64(uptime),
-		VersionInfo: SkynetVersion{
-			Version:     version,
-			GitRevision: build.GitRevision,
-		},
+	// Update the registry.
+	errs := api.renter.UpdateRegistryBatch(ctx, entries)
+	for i, err := range errs {
+		results[indices[i]] = registryBatchResult(err)
+	}
+	WriteJSON(w, RegistryHandlerBatchPOST{
+		Results: results,
 	})
 }
 
-// skykeyHandlerGET handles the API call to get a Skykey and its ID using its
-// name or ID.
-func (api *API) skykeyHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse Skykey id and name.
-	name := req.FormValue("name")
-	idString := req.FormValue("id")
-
-	if idString == "" && name == "" {
-		WriteError(w, Error{"you must specify the name or ID of the skykey"}, http.StatusInternalServerError)
+// registryHandlerGET handles the GET calls to /skynet/registry.
+func (api *API) registryHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
-	if idString != "" && name != "" {
-		WriteError(w, Error{"you must specify either the name or ID of the skykey, not both"}, http.StatusInternalServerError)
+	// Parse public key
+	var spk types.SiaPublicKey
+	err = spk.LoadString(queryForm.Get("publickey"))
+	if err != nil {
+		WriteError(w, Error{"Unable to parse publickey param: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	var sk skykey.Skykey
-	var err error
-	if name != "" {
-		sk, err = api.renter.SkykeyByName(name)
-	} else if idString != "" {
-		var id skykey.SkykeyID
-		err = id.FromString(idString)
-		if err != nil {
-			WriteError(w, Error{"failed to decode ID string: "}, http.StatusInternalServerError)
-			return
-		}
-		sk, err = api.renter.SkykeyByID(id)
+	// Parse datakey.
+	var dataKey crypto.Hash
+	err = dataKey.LoadString(queryForm.Get("datakey"))
+	if err != nil {
+		WriteError(w, Error{"Unable to decode dataKey param: " + err.Error()}, http.StatusBadRequest)
+		return
 	}
+
+	// Parse the timeout.
+	timeout, err := parseRegistryTimeout(queryForm)
 	if err != nil {
-		WriteError(w, Error{"failed to retrieve skykey: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	skString, err := sk.ToString()
+	// Read registry.
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	srv, err := api.renter.ReadRegistry(ctx, spk, dataKey)
 	if err != nil {
-		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		handleSkynetError(w, "unable to read from the registry", err)
 		return
 	}
-	WriteJSON(w, SkykeyGET{
-		Skykey: skString,
-		Name:   sk.Name,
-		ID:     sk.ID().ToString(),
-		Type:   sk.Type.ToString(),
-	})
-}
 
-// skykeyDeleteHandlerGET handles the API call to delete a Skykey using its name
-// or ID.
-func (api *API) skykeyDeleteHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse Skykey id and name.
-	name := req.FormValue("name")
-	idString := req.FormValue("id")
+	// Send response.
+	WriteJSON(w, newRegistryHandlerGET(srv))
+}
 
-	if idString == "" && name == "" {
-		WriteError(w, Error{"you must specify the name or ID of the skykey"}, http.StatusBadRequest)
+// registryEntryHealthHandlerGET is the handler for the /skynet/registry/health
+// endpoint.
+func (api *API) registryEntryHealthHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
-	if idString != "" && name != "" {
-		WriteError(w, Error{"you must specify either the name or ID of the skykey, not both"}, http.StatusBadRequest)
+	spkStr := queryForm.Get("publickey")
+	tweakStr := queryForm.Get("datakey")
+	ridStr := queryForm.Get("entryid")
+
+	// Parse the timeout.
+	timeout, err := parseRegistryTimeout(queryForm)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
 
-	var err error
-	if name != "" {
-		err = api.renter.DeleteSkykeyByName(name)
-	} else if idString != "" {
-		var id skykey.SkykeyID
-		err = id.FromString(idString)
+	// If the publickey and datakey are set we use them. Otherwise we look
+	// for the entryid.
+	var reh skymodules.RegistryEntryHealth
+	if spkStr != "" && tweakStr != "" {
+		// Parse public key
+		var spk types.SiaPublicKey
+		err := spk.LoadString(spkStr)
 		if err != nil {
-			WriteError(w, Error{"Invalid skykey ID: " + err.Error()}, http.StatusBadRequest)
+			WriteError(w, Error{"Unable to parse 'publickey' param: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-		err = api.renter.DeleteSkykeyByID(id)
-	}
 
+		// Parse datakey.
+		var dataKey crypto.Hash
+		err = dataKey.LoadString(tweakStr)
+		if err != nil {
+			WriteError(w, Error{"Unable to decode 'dataKey' param: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		reh, err = api.renter.RegistryEntryHealth(ctx, spk, dataKey)
+	} else if ridStr != "" {
+		// Parse rid.
+		var rid crypto.Hash
+		err := rid.LoadString(ridStr)
+		if err != nil {
+			WriteError(w, Error{"Unable to decode 'entryid' param: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		reh, err = api.renter.RegistryEntryHealthRID(ctx, modules.RegistryEntryID(rid))
+	} else {
+		WriteError(w, Error{"Need to specify either publickey and datakey or entryid"}, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		WriteError(w, Error{"failed to delete skykey: " + err.Error()}, http.StatusInternalServerError)
+		handleSkynetError(w, "unable to read from the registry", err)
 		return
 	}
 
-	WriteSuccess(w)
+	// Send response.
+	WriteJSON(w, reh)
 }
 
-// skykeyCreateKeyHandlerPost handles the API call to create a skykey using the renter's
-// skykey manager.
-func (api *API) skykeyCreateKeyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse skykey name and type
-	name := req.FormValue("name")
-	skykeyTypeString := req.FormValue("type")
-
-	if name == "" {
-		WriteError(w, Error{"you must specify the name of the skykey"}, http.StatusInternalServerError)
+// skylinkResolveGET handles the GET calls to /skylink/resolve/:skylink.
+func (api *API) skylinkResolveGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
 
-	if skykeyTypeString == "" {
-		WriteError(w, Error{"you must specify the type of the skykey"}, http.StatusInternalServerError)
+	// Parse Skylink
+	sl, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{"Unable to parse skylink" + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	if !sl.IsSkylinkV2() {
+		if err != nil {
+			WriteError(w, Error{"Can only resolve v2 skylinks" + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 
-	var skykeyType skykey.SkykeyType
-	err := skykeyType.FromString(skykeyTypeString)
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"failed to decode skykey type: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	sk, err := api.renter.CreateSkykey(name, skykeyType)
+	// Resolve skylink.
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	slV1, srv, err := api.renter.ResolveSkylinkV2(ctx, sl)
 	if err != nil {
-		WriteError(w, Error{"failed to create skykey: " + err.Error()}, http.StatusInternalServerError)
+		handleSkynetError(w, "Failed to resolve skylink", err)
 		return
 	}
 
-	keyString, err := sk.ToString()
+	// Attach proof.
+	var proof []skymodules.RegistryEntry
+	if srv != nil {
+		proof = append(proof, srv...)
+	}
+	err = attachRegistryEntryProof(w, proof)
 	if err != nil {
-		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
 
-	WriteJSON(w, SkykeyGET{
-		Skykey: keyString,
-		Name:   name,
-		ID:     sk.ID().ToString(),
-		Type:   skykeyTypeString,
+	// Set the Skylink response headers.
+	if !slV1.IsSkylinkV1() {
+		build.Critical("skylink attached in skynet-skylink header is not v1")
+	}
+	w.Header().Set(SkynetSkylinkHeader, slV1.String())
+
+	// Send response.
+	WriteJSON(w, SkylinkResolveGET{
+		Skylink: slV1.String(),
 	})
 }
 
-// skykeyAddKeyHandlerPost handles the API call to add a skykey to the renter's
-// skykey manager.
-func (api *API) skykeyAddKeyHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse skykey.
-	skString := req.FormValue("skykey")
-	if skString == "" {
-		WriteError(w, Error{"you must specify the name of the skykey"}, http.StatusInternalServerError)
+// skynetRestoreHandlerPOST handles the POST calls to /skynet/restore.
+func (api *API) skynetRestoreHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Restore Skyfile
+	skylink, err := api.renter.RestoreSkyfile(req.Body)
+	if err != nil {
+		WriteError(w, Error{"unable to restore skyfile: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	var sk skykey.Skykey
-	err := sk.FromString(skString)
+	WriteJSON(w, SkynetRestorePOST{
+		Skylink: skylink.String(),
+	})
+}
+
+// skynetMetadataHandlerGET is the handler for the /skynet/metadata endpoint.
+func (api *API) skynetMetadataHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the skylink from the raw URL of the request. Any special characters
+	// in the raw URL are encoded, allowing us to differentiate e.g. the '?'
+	// that begins query parameters from the encoded version '%3F'.
+	skylink, _, _, err := parseSkylinkURL(req.URL.String(), "/skynet/metadata/")
 	if err != nil {
-		WriteError(w, Error{"failed to decode skykey: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	err = api.renter.AddSkykey(sk)
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
-		WriteError(w, Error{"failed to add skykey: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
 		return
 	}
 
-	WriteSuccess(w)
-}
-
-// skykeysHandlerGET handles the API call to get all of the renter's skykeys.
-func (api *API) skykeysHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
-	skykeys, err := api.renter.Skykeys()
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"Unable to get skykeys: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	res := SkykeysGET{
-		Skykeys: make([]SkykeyGET, len(skykeys)),
-	}
-	for i, sk := range skykeys {
-		skStr, err := sk.ToString()
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
 		if err != nil {
-			WriteError(w, Error{"failed to write skykey string: " + err.Error()}, http.StatusInternalServerError)
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
-		res.Skykeys[i] = SkykeyGET{
-			Skykey: skStr,
-			Name:   sk.Name,
-			ID:     sk.ID().ToString(),
-			Type:   sk.Type.ToString(),
-		}
 	}
-	WriteJSON(w, res)
-}
 
-// registryHandlerPOST handles the POST calls to /skynet/registry.
-func (api *API) registryHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Decode request.
-	dec := json.NewDecoder(req.Body)
-	var rhp RegistryHandlerRequestPOST
-	err := dec.Decode(&rhp)
+	// Parse the decryption params.
+	sk, tryKeys, err := api.parseBaseSectorDecryptionParams(queryForm)
 	if err != nil {
-		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// If the type wasn't set, default to no pubkey to preserve
-	// compatibility.
-	if rhp.Type == modules.RegistryTypeInvalid {
-		rhp.Type = modules.RegistryTypeWithoutPubkey
-	}
-
-	// Check data length here to be able to offer a better and faster error
-	// message than when the hosts return it.
-	if len(rhp.Data) > modules.RegistryDataSize {
-		WriteError(w, Error{fmt.Sprintf("Registry data is too big: %v > %v", len(rhp.Data), modules.RegistryDataSize)}, http.StatusBadRequest)
+	// Fetch the skyfile's streamer to serve the basesector of the file
+	streamer, srvs, resolvedLink, err := api.renter.DownloadSkylinkBaseSector(req.Context(), skylink, timeout, pricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to fetch base sector", err)
 		return
 	}
+	defer func() {
+		// At this point we have already responded so we can't write a potential
+		// error here.
+		_ = streamer.Close()
+	}()
 
-	// Prepare a context for the timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), renter.DefaultRegistryUpdateTimeout)
-	defer cancel()
+	// Set the Skylink response header
+	if !resolvedLink.IsSkylinkV1() {
+		build.Critical("skylink attached in skynet-skylink header is not v1")
+	}
+	w.Header().Set(SkynetSkylinkHeader, resolvedLink.String())
 
-	// Update the registry.
-	srv := modules.NewSignedRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Signature, rhp.Type)
-	err = api.renter.UpdateRegistry(ctx, rhp.PublicKey, srv)
+	// Attach proof.
+	err = attachRegistryEntryProof(w, srvs)
 	if err != nil {
-		handleSkynetError(w, "Unable to update the registry", err)
+		WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
-	WriteSuccess(w)
-}
 
-// registryMultiHandlerPOST handles the POST calls to /skynet/registrymulti.
-func (api *API) registryMultiHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Decode request.
-	dec := json.NewDecoder(req.Body)
-	var rhps []RegistryHandlerMultiRequestPOST
-	err := dec.Decode(&rhps)
+	// Read base sector.
+	baseSector, err := ioutil.ReadAll(streamer)
 	if err != nil {
-		WriteError(w, Error{"Failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("failed to read base sector: %v", err)}, http.StatusInternalServerError)
 		return
 	}
 
-	// Check request and combine them into a map from host to entry.
-	srvs := make(map[string]skymodules.RegistryEntry, len(rhps))
-	for i, rhp := range rhps {
-		// If the type wasn't set, default to no pubkey to preserve
-		// compatibility.
-		if rhp.Type == modules.RegistryTypeInvalid {
-			rhps[i].Type = modules.RegistryTypeWithoutPubkey
-		}
-
-		// Check data length here to be able to offer a better and faster error
-		// message than when the hosts return it.
-		if len(rhp.Data) > modules.RegistryDataSize {
-			WriteError(w, Error{fmt.Sprintf("Registry data is too big: %v > %v", len(rhp.Data), modules.RegistryDataSize)}, http.StatusBadRequest)
+	// Decrypt it if necessary.
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		err = api.decryptBaseSector(baseSector, sk, tryKeys)
+		if errors.Contains(err, ErrBaseSectorEncrypted) {
+			writeBaseSectorEncryptedError(w, baseSector, http.StatusForbidden, err)
 			return
 		}
-		srv := modules.NewSignedRegistryValue(rhp.DataKey, rhp.Data, rhp.Revision, rhp.Signature, rhp.Type)
-
-		// Each update should be for a different host.
-		_, exists := srvs[rhp.HostKey.String()]
-		if exists {
-			WriteError(w, Error{"Updating multiple entries on one host is not supported"}, http.StatusBadRequest)
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("failed to decrypt base sector: %v", err)}, http.StatusInternalServerError)
 			return
 		}
-		srvs[rhp.HostKey.String()] = skymodules.NewRegistryEntry(rhp.PublicKey, srv)
 	}
 
-	// Prepare a context for the timeout.
-	ctx, cancel := context.WithTimeout(req.Context(), renter.DefaultRegistryUpdateTimeout)
-	defer cancel()
-
-	// Update the registry.
-	err = api.renter.UpdateRegistryMulti(ctx, srvs)
+	// Parse it.
+	_, _, _, rawMD, _, _, err := api.renter.ParseSkyfileMetadata(baseSector)
 	if err != nil {
-		handleSkynetError(w, "Unable to update the registry", err)
+		WriteError(w, Error{fmt.Sprintf("failed to fetch skylink: %v", err)}, http.StatusInternalServerError)
 		return
 	}
-	WriteSuccess(w)
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(rawMD))
 }
 
-// registryHandlerGET handles the GET calls to /skynet/registry.
-func (api *API) registryHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse the query params.
-	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+// skynetDiffHandlerPOST is the handler for the /skynet/diff endpoint. It
+// compares the subfiles of two skyfiles without downloading their payload.
+func (api *API) skynetDiffHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the skylinks.
+	var from, to skymodules.Skylink
+	err := from.LoadString(req.FormValue("from"))
 	if err != nil {
-		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("error parsing 'from' skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
-	// Parse public key
-	var spk types.SiaPublicKey
-	err = spk.LoadString(queryForm.Get("publickey"))
+	err = to.LoadString(req.FormValue("to"))
 	if err != nil {
-		WriteError(w, Error{"Unable to parse publickey param: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("error parsing 'to' skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse datakey.
-	var dataKey crypto.Hash
-	err = dataKey.LoadString(queryForm.Get("datakey"))
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(req.Form, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"Unable to decode dataKey param: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse the timeout.
-	timeout, err := parseRegistryTimeout(queryForm)
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := req.FormValue("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch the metadata of both skyfiles.
+	fromMD, err := api.managedSkyfileMetadata(req.Context(), from, timeout, pricePerMS)
 	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		handleSkynetError(w, "failed to fetch metadata of 'from' skylink", err)
 		return
 	}
-
-	// Read registry.
-	ctx, cancel := context.WithTimeout(req.Context(), timeout)
-	defer cancel()
-	srv, err := api.renter.ReadRegistry(ctx, spk, dataKey)
+	toMD, err := api.managedSkyfileMetadata(req.Context(), to, timeout, pricePerMS)
 	if err != nil {
-		handleSkynetError(w, "unable to read from the registry", err)
+		handleSkynetError(w, "failed to fetch metadata of 'to' skylink", err)
 		return
 	}
+	WriteJSON(w, diffSkyfileSubfiles(skyfileSubfiles(fromMD), skyfileSubfiles(toMD)))
+}
 
-	// Send response.
-	WriteJSON(w, RegistryHandlerGET{
-		Data:      hex.EncodeToString(srv.Data),
-		DataKey:   srv.Tweak,
-		Revision:  srv.Revision,
-		PublicKey: srv.PubKey,
-		Signature: hex.EncodeToString(srv.Signature[:]),
-		Type:      srv.Type,
-	})
+// managedSkyfileMetadata fetches and decrypts the base sector of a skylink and
+// returns the metadata of the skyfile.
+func (api *API) managedSkyfileMetadata(ctx context.Context, skylink skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileMetadata, error) {
+	streamer, _, _, err := api.renter.DownloadSkylinkBaseSector(ctx, skylink, timeout, pricePerMS)
+	if err != nil {
+		return skymodules.SkyfileMetadata{}, err
+	}
+	defer func() {
+		_ = streamer.Close()
+	}()
+
+	// Read base sector.
+	baseSector, err := ioutil.ReadAll(streamer)
+	if err != nil {
+		return skymodules.SkyfileMetadata{}, errors.AddContext(err, "failed to read base sector")
+	}
+
+	// Decrypt it if necessary.
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		_, err = api.renter.DecryptBaseSector(baseSector)
+		if err != nil {
+			return skymodules.SkyfileMetadata{}, errors.AddContext(err, "failed to decrypt base sector")
+		}
+	}
+
+	// Parse it.
+	_, _, md, _, _, _, err := api.renter.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		return skymodules.SkyfileMetadata{}, errors.AddContext(err, "failed to parse metadata")
+	}
+	return md, nil
 }
 
-// registryEntryHealthHandlerGET is the handler for the /skynet/registry/health
+// skynetSkylinkHealthGET is the handler for the /skynet/health/:skylink
 // endpoint.
-func (api *API) registryEntryHealthHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+func (api *API) skynetSkylinkHealthGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
 	// Parse the query params.
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
-		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("failed to parse query params: %v", err)}, http.StatusBadRequest)
 		return
 	}
-	spkStr := queryForm.Get("publickey")
-	tweakStr := queryForm.Get("datakey")
-	ridStr := queryForm.Get("entryid")
 
-	// Parse the timeout.
-	timeout, err := parseRegistryTimeout(queryForm)
+	// Parse timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -1412,50 +4273,25 @@ func (api *API) registryEntryHealthHandlerGET(w http.ResponseWriter, req *http.R
 	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 
-	// If the publickey and datakey are set we use them. Otherwise we look
-	// for the entryid.
-	var reh skymodules.RegistryEntryHealth
-	if spkStr != "" && tweakStr != "" {
-		// Parse public key
-		var spk types.SiaPublicKey
-		err := spk.LoadString(spkStr)
-		if err != nil {
-			WriteError(w, Error{"Unable to parse 'publickey' param: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-
-		// Parse datakey.
-		var dataKey crypto.Hash
-		err = dataKey.LoadString(tweakStr)
-		if err != nil {
-			WriteError(w, Error{"Unable to decode 'dataKey' param: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-		reh, err = api.renter.RegistryEntryHealth(ctx, spk, dataKey)
-	} else if ridStr != "" {
-		// Parse rid.
-		var rid crypto.Hash
-		err := rid.LoadString(ridStr)
-		if err != nil {
-			WriteError(w, Error{"Unable to decode 'entryid' param: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-		reh, err = api.renter.RegistryEntryHealthRID(ctx, modules.RegistryEntryID(rid))
-	} else {
-		WriteError(w, Error{"Need to specify either publickey and datakey or entryid"}, http.StatusBadRequest)
+	// Get health.
+	sh, err := api.renter.SkylinkHealth(ctx, skylink, skymodules.DefaultSkynetPricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to get skylink health", err)
 		return
 	}
+	WriteJSON(w, sh)
+}
+
+// skynetManifestHandlerGET is the handler for the /skynet/manifest/:skylink
+// GET endpoint. It returns a manifest of all the subfiles of a skyfile which
+// optionally contains the hash of every subfile.
+func (api *API) skynetManifestHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
-		handleSkynetError(w, "unable to read from the registry", err)
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	// Send response.
-	WriteJSON(w, reh)
-}
-
-// skylinkResolveGET handles the GET calls to /skylink/resolve/:skylink.
-func (api *API) skylinkResolveGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	// Parse the query params.
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
@@ -1463,79 +4299,93 @@ func (api *API) skylinkResolveGET(w http.ResponseWriter, req *http.Request, ps h
 		return
 	}
 
-	// Parse Skylink
-	var sl skymodules.Skylink
-	err = sl.LoadString(ps.ByName("skylink"))
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"Unable to parse skylink" + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
-	if !sl.IsSkylinkV2() {
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	if pricePerMSStr := queryForm.Get("priceperms"); pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
 		if err != nil {
-			WriteError(w, Error{"Can only resolve v2 skylinks" + err.Error()}, http.StatusBadRequest)
+			WriteError(w, Error{"unable to parse 'priceperms' parameter: " + err.Error()}, http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	// Parse hashes.
+	hashes := true
+	if hashesStr := queryForm.Get("hashes"); hashesStr != "" {
+		hashes, err = strconv.ParseBool(hashesStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'hashes' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch the skyfile.
+	streamer, _, err := api.renter.DownloadSkylink(req.Context(), skylink, timeout, pricePerMS)
 	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		handleSkynetError(w, "failed to fetch skylink", err)
 		return
 	}
+	defer func() {
+		_ = streamer.Close()
+	}()
 
-	// Resolve skylink.
-	ctx, cancel := context.WithTimeout(req.Context(), timeout)
-	defer cancel()
-	slV1, srv, err := api.renter.ResolveSkylinkV2(ctx, sl)
+	manifest, err := skyfileManifest(streamer, hashes)
 	if err != nil {
-		handleSkynetError(w, "Failed to resolve skylink", err)
+		WriteError(w, Error{"failed to create manifest: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
+	WriteJSON(w, manifest)
+}
 
-	// Attach proof.
-	var proof []skymodules.RegistryEntry
-	if srv != nil {
-		proof = append(proof, srv...)
-	}
-	err = attachRegistryEntryProof(w, proof)
+// skynetSkylinkEncodingGET is the handler for the
+// /skynet/debug/encoding/:skylink GET endpoint. It returns information about
+// how efficiently the fanout of a skylink is encoded.
+func (api *API) skynetSkylinkEncodingGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
-		WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	// Set the Skylink response headers.
-	if !slV1.IsSkylinkV1() {
-		build.Critical("skylink attached in skynet-skylink header is not v1")
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("failed to parse query params: %v", err)}, http.StatusBadRequest)
+		return
 	}
-	w.Header().Set(SkynetSkylinkHeader, slV1.String())
-
-	// Send response.
-	WriteJSON(w, SkylinkResolveGET{
-		Skylink: slV1.String(),
-	})
-}
 
-// skynetRestoreHandlerPOST handles the POST calls to /skynet/restore.
-func (api *API) skynetRestoreHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Restore Skyfile
-	skylink, err := api.renter.RestoreSkyfile(req.Body)
+	// Parse timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
-		WriteError(w, Error{"unable to restore skyfile: " + err.Error()}, http.StatusBadRequest)
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
 
-	WriteJSON(w, SkynetRestorePOST{
-		Skylink: skylink.String(),
-	})
+	// Get encoding.
+	encoding, err := api.renter.SkylinkEncoding(ctx, skylink, skymodules.DefaultSkynetPricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to get skylink encoding", err)
+		return
+	}
+	WriteJSON(w, encoding)
 }
 
-// skynetMetadataHandlerGET is the handler for the /skynet/metadata endpoint.
-func (api *API) skynetMetadataHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	// Parse the skylink from the raw URL of the request. Any special characters
-	// in the raw URL are encoded, allowing us to differentiate e.g. the '?'
-	// that begins query parameters from the encoded version '%3F'.
-	skylink, _, _, err := parseSkylinkURL(req.URL.String(), "/skynet/metadata/")
+// skynetSkylinkChunkGET is the handler for the /skynet/debug/chunk/:skylink
+// GET endpoint. It downloads a single chunk of the fanout of a skylink and
+// returns its raw data.
+func (api *API) skynetSkylinkChunkGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
 		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
@@ -1544,16 +4394,31 @@ func (api *API) skynetMetadataHandlerGET(w http.ResponseWriter, req *http.Reques
 	// Parse the query params.
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
-		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		WriteError(w, Error{fmt.Sprintf("failed to parse query params: %v", err)}, http.StatusBadRequest)
 		return
 	}
 
-	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	// Parse the chunk index.
+	indexStr := queryForm.Get("index")
+	if indexStr == "" {
+		WriteError(w, Error{"'index' parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	index, err := strconv.ParseUint(indexStr, 10, 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'index' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
 	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
 
 	// Parse pricePerMS.
 	pricePerMS := skymodules.DefaultSkynetPricePerMS
@@ -1566,64 +4431,52 @@ func (api *API) skynetMetadataHandlerGET(w http.ResponseWriter, req *http.Reques
 		}
 	}
 
-	// Fetch the skyfile's streamer to serve the basesector of the file
-	streamer, srvs, resolvedLink, err := api.renter.DownloadSkylinkBaseSector(skylink, timeout, pricePerMS)
-	if err != nil {
-		handleSkynetError(w, "failed to fetch base sector", err)
-		return
-	}
-	defer func() {
-		// At this point we have already responded so we can't write a potential
-		// error here.
-		_ = streamer.Close()
-	}()
-
-	// Set the Skylink response header
-	if !resolvedLink.IsSkylinkV1() {
-		build.Critical("skylink attached in skynet-skylink header is not v1")
-	}
-	w.Header().Set(SkynetSkylinkHeader, resolvedLink.String())
-
-	// Attach proof.
-	err = attachRegistryEntryProof(w, srvs)
-	if err != nil {
-		WriteError(w, Error{"unable to attach proof: " + err.Error()}, http.StatusInternalServerError)
+	// Parse the skykey. It can either be the name of a local skykey or a
+	// skykey in the header which is only used for this request.
+	var sk *skykey.Skykey
+	skykeyName := queryForm.Get("skykeyname")
+	skStr := req.Header.Get(SkynetSkykeyHeader)
+	if skykeyName != "" && skStr != "" {
+		WriteError(w, Error{fmt.Sprintf("cannot set both a 'skykeyname' and the '%v' header", SkynetSkykeyHeader)}, http.StatusBadRequest)
 		return
 	}
-
-	// Read base sector.
-	baseSector, err := ioutil.ReadAll(streamer)
-	if err != nil {
-		WriteError(w, Error{fmt.Sprintf("failed to read base sector: %v", err)}, http.StatusInternalServerError)
-		return
+	if skykeyName != "" {
+		key, err := api.renter.SkykeyByName(skykeyName)
+		if err != nil {
+			WriteError(w, Error{"unable to get skykey: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		sk = &key
 	}
-
-	// Decrypt it if necessary.
-	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
-	if encrypted {
-		_, err = api.renter.DecryptBaseSector(baseSector)
+	if skStr != "" {
+		sk = new(skykey.Skykey)
+		err = sk.FromString(skStr)
 		if err != nil {
-			WriteError(w, Error{fmt.Sprintf("failed to decrypt base sector: %v", err)}, http.StatusInternalServerError)
+			WriteError(w, Error{fmt.Sprintf("unable to parse '%v' header: %v", SkynetSkykeyHeader, err)}, http.StatusBadRequest)
+			return
+		}
+		err = sk.IsValid()
+		if err != nil {
+			WriteError(w, Error{fmt.Sprintf("invalid skykey in '%v' header: %v", SkynetSkykeyHeader, err)}, http.StatusBadRequest)
 			return
 		}
 	}
 
-	// Parse it.
-	_, _, _, rawMD, _, _, err := api.renter.ParseSkyfileMetadata(baseSector)
+	// Download the chunk.
+	chunk, err := api.renter.DownloadSkylinkChunk(ctx, skylink, index, sk, pricePerMS)
 	if err != nil {
-		WriteError(w, Error{fmt.Sprintf("failed to fetch skylink: %v", err)}, http.StatusInternalServerError)
+		handleSkynetError(w, fmt.Sprintf("failed to download chunk %v", index), err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(rawMD))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(chunk))
 }
 
-// skynetSkylinkHealthGET is the handler for the /skynet/health/:skylink
-// endpoint.
-func (api *API) skynetSkylinkHealthGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	strLink := ps.ByName("skylink")
-	var skylink skymodules.Skylink
-	err := skylink.LoadString(strLink)
+// skynetSkyfileVerifyHandlerGET is the handler for the
+// /skynet/skyfile/verify/:skylink GET endpoint. It checks whether the layout,
+// fanout and metadata of a skyfile are consistent with each other.
+func (api *API) skynetSkyfileVerifyHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
 		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
@@ -1637,7 +4490,8 @@ func (api *API) skynetSkylinkHealthGET(w http.ResponseWriter, req *http.Request,
 	}
 
 	// Parse timeout.
-	timeout, err := parseTimeout(queryForm)
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -1645,20 +4499,18 @@ func (api *API) skynetSkylinkHealthGET(w http.ResponseWriter, req *http.Request,
 	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 
-	// Get health.
-	sh, err := api.renter.SkylinkHealth(ctx, skylink, skymodules.DefaultSkynetPricePerMS)
+	// Check the skyfile.
+	consistency, err := api.renter.SkyfileConsistency(ctx, skylink, skymodules.DefaultSkynetPricePerMS)
 	if err != nil {
-		handleSkynetError(w, "failed to get skylink health", err)
+		handleSkynetError(w, "failed to verify skyfile", err)
 		return
 	}
-	WriteJSON(w, sh)
+	WriteJSON(w, consistency)
 }
 
 // skynetSkylinkUnpinHandlerPOST will unpin a skylink from this Sia node.
 func (api *API) skynetSkylinkUnpinHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-	strLink := ps.ByName("skylink")
-	var skylink skymodules.Skylink
-	err := skylink.LoadString(strLink)
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
 	if err != nil {
 		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
 		return
@@ -1712,6 +4564,19 @@ func (api *API) skynetSkylinkUnpinHandlerPOST(w http.ResponseWriter, req *http.R
 	WriteSuccess(w)
 }
 
+// skynetPinnedHandlerGET is the handler for the /skynet/pinned GET endpoint.
+// It lists the skyfiles pinned to the node together with their pin expiry.
+func (api *API) skynetPinnedHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	pins, err := api.renter.SkynetPins()
+	if err != nil {
+		handleSkynetError(w, "failed to list pinned skyfiles", err)
+		return
+	}
+	WriteJSON(w, SkynetPinnedGET{
+		Pins: pins,
+	})
+}
+
 // skynetHostsForRegistryUpdateGET is the handler for the /skynet/registry/hosts
 // GET endpoint.
 func (api *API) skynetHostsForRegistryUpdateGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -1724,3 +4589,208 @@ func (api *API) skynetHostsForRegistryUpdateGET(w http.ResponseWriter, req *http
 		Hosts: hosts,
 	})
 }
+
+// skynetConvertStatusHandlerGET is the handler for the
+// /skynet/convert/status/:id GET endpoint.
+func (api *API) skynetConvertStatusHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	status, err := api.renter.SkyfileConversionStatus(ps.ByName("id"))
+	if err != nil {
+		handleSkynetError(w, "failed to get skyfile conversion status", err)
+		return
+	}
+	WriteJSON(w, status)
+}
+
+// skynetConvertCancelHandlerPOST is the handler for the
+// /skynet/convert/cancel/:id POST endpoint.
+func (api *API) skynetConvertCancelHandlerPOST(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	err := api.renter.CancelSkyfileConversion(ps.ByName("id"))
+	if err != nil {
+		handleSkynetError(w, "failed to cancel skyfile conversion", err)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// skynetUploadBeginHandlerPOST is the handler for the /skynet/upload/begin
+// POST endpoint. It uploads the body of the request as the data of a skyfile
+// without creating the skyfile. The skyfile is created once its metadata is
+// provided to the /skynet/upload/finalize/:id POST endpoint.
+func (api *API) skynetUploadBeginHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the siapath.
+	siaPath := skymodules.RandomSkynetFilePath()
+	if siaPathStr := queryForm.Get("siapath"); siaPathStr != "" {
+		var root bool
+		if rootStr := queryForm.Get("root"); rootStr != "" {
+			root, err = strconv.ParseBool(rootStr)
+			if err != nil {
+				WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+		if root {
+			siaPath, err = skymodules.NewSiaPath(siaPathStr)
+		} else {
+			siaPath, err = skymodules.SkynetFolder.Join(siaPathStr)
+		}
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'siapath' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse force.
+	var force bool
+	if forceStr := queryForm.Get("force"); forceStr != "" {
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the redundancy.
+	var baseChunkRedundancy uint8
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &baseChunkRedundancy); err != nil {
+			WriteError(w, Error{"unable to parse 'basechunkredundancy' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	dataPieces, parityPieces, err := ParseDataAndParityPieces(queryForm.Get("datapieces"), queryForm.Get("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Enforce the maximum upload size.
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	if maxSize := settings.SkynetMaxUploadSize; maxSize > 0 {
+		if req.ContentLength > 0 && uint64(req.ContentLength) > maxSize {
+			WriteError(w, Error{errMaxUploadSizeExceeded(maxSize).Error()}, http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Body = newMaxUploadSizeReader(req.Body, maxSize)
+	}
+
+	id, err := api.renter.BeginSkyfileUpload(req.Context(), skymodules.SkyfileUploadParameters{
+		BaseChunkRedundancy: baseChunkRedundancy,
+		DataPieces:          dataPieces,
+		Force:               force,
+		ParityPieces:        parityPieces,
+		SiaPath:             siaPath,
+	}, req.Body)
+	if err != nil {
+		handleSkynetError(w, "failed to begin skyfile upload", err)
+		return
+	}
+	WriteJSON(w, SkynetUploadBeginPOST{ID: id})
+}
+
+// skynetUploadFinalizeHandlerPOST is the handler for the
+// /skynet/upload/finalize/:id POST endpoint. It creates the skyfile of a
+// pending upload from the metadata in the body of the request.
+func (api *API) skynetUploadFinalizeHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var md skymodules.SkyfileMetadata
+	err := json.NewDecoder(req.Body).Decode(&md)
+	if err != nil {
+		WriteError(w, Error{"unable to decode skyfile metadata: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if md.ModTime == 0 {
+		md.ModTime = time.Now().Unix()
+	}
+
+	skylink, err := api.renter.FinalizeSkyfileUpload(req.Context(), ps.ByName("id"), md)
+	if errors.Contains(err, renter.ErrInvalidMetadata) {
+		WriteError(w, Error{"failed to finalize skyfile upload: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		handleSkynetError(w, "failed to finalize skyfile upload", err)
+		return
+	}
+	resp := newSkynetSkyfileHandlerPOST(skylink)
+	resp.ModTime = md.ModTime
+	WriteJSON(w, resp)
+}
+
+// skynetPrefetchHandlerPOST is the handler for the /skynet/prefetch/:skylink
+// POST endpoint. It starts fetching the skylink in the background to warm up
+// the renter's caches.
+func (api *API) skynetPrefetchHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the skylink.
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the depth.
+	var full bool
+	switch depth := queryForm.Get("depth"); depth {
+	case "", "base":
+	case "full":
+		full = true
+	default:
+		WriteError(w, Error{fmt.Sprintf("invalid 'depth' parameter '%v', must be 'base' or 'full'", depth)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	id, err := api.renter.PrefetchSkylink(skylink, full, timeout, pricePerMS)
+	if err != nil {
+		handleSkynetError(w, "failed to start prefetching skylink", err)
+		return
+	}
+	WriteJSON(w, SkynetPrefetchHandlerPOST{
+		ID: id,
+	})
+}
+
+// skynetPrefetchStatusHandlerGET is the handler for the
+// /skynet/prefetch/status/:id GET endpoint.
+func (api *API) skynetPrefetchStatusHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	status, err := api.renter.SkylinkPrefetchStatus(ps.ByName("id"))
+	if err != nil {
+		handleSkynetError(w, "failed to get skylink prefetch status", err)
+		return
+	}
+	WriteJSON(w, status)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// skynetapikeys.go contains the /skynet/apikeys endpoints and the middleware
+// which enforces the scopes of API keys on the skynet endpoints. An API key is
+// provided in place of the API password. The API password itself has the admin
+// scope.
+
+type (
+	// SkynetAPIKeysGET is the response returned by the /skynet/apikeys [GET]
+	// endpoint.
+	SkynetAPIKeysGET struct {
+		Keys []skymodules.SkynetAPIKey `json:"keys"`
+	}
+
+	// SkynetAPIKeyPOST is the response returned by the /skynet/apikeys [POST]
+	// endpoint. It contains the only copy of the created key.
+	SkynetAPIKeyPOST struct {
+		Key string `json:"key"`
+		skymodules.SkynetAPIKey
+	}
+)
+
+// skynetAPIKeysHandlerGET handles the GET calls to /skynet/apikeys.
+func (api *API) skynetAPIKeysHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	keys, err := api.renter.SkynetAPIKeys()
+	if err != nil {
+		WriteError(w, Error{"unable to get api keys: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkynetAPIKeysGET{
+		Keys: keys,
+	})
+}
+
+// skynetAPIKeysHandlerPOST handles the POST calls to /skynet/apikeys.
+func (api *API) skynetAPIKeysHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	scope, err := skymodules.ParseSkynetAPIKeyScope(req.FormValue("scope"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'scope' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	key, info, err := api.renter.CreateSkynetAPIKey(scope)
+	if err != nil {
+		WriteError(w, Error{"failed to create api key: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, SkynetAPIKeyPOST{
+		Key:          key,
+		SkynetAPIKey: info,
+	})
+}
+
+// skynetAPIKeysDeleteHandlerPOST handles the POST calls to
+// /skynet/apikeys/delete.
+func (api *API) skynetAPIKeysDeleteHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	idStr := req.FormValue("id")
+	if idStr == "" {
+		WriteError(w, Error{"you must specify the id of the api key"}, http.StatusBadRequest)
+		return
+	}
+	var id crypto.Hash
+	if err := id.LoadString(idStr); err != nil {
+		WriteError(w, Error{"unable to parse 'id' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err := api.renter.DeleteSkynetAPIKey(id)
+	if errors.Contains(err, skymodules.ErrSkynetAPIKeyNotFound) {
+		WriteError(w, Error{"failed to delete api key: " + err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to delete api key: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// requireSkynetScope works like RequirePassword but also accepts API keys in
+// place of the password. Requests with a known key which lacks the required
+// scope are rejected with a 403 status code.
+func (api *API) requireSkynetScope(h httprouter.Handle, password string, scope skymodules.SkynetAPIKeyScope) httprouter.Handle {
+	// An empty password is equivalent to no password.
+	if password == "" {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		_, pass, ok := req.BasicAuth()
+		if ok && pass == password {
+			h(w, req, ps)
+			return
+		}
+		var keyScope skymodules.SkynetAPIKeyScope
+		var err error
+		if ok && pass != "" {
+			keyScope, err = api.renter.SkynetAPIKeyScope(pass)
+		}
+		if !ok || pass == "" || err != nil {
+			w.Header().Set("WWW-Authenticate", "Basic realm=\"SiaAPI\"")
+			WriteError(w, Error{"API authentication failed."}, http.StatusUnauthorized)
+			return
+		}
+		if !keyScope.Allows(scope) {
+			WriteError(w, Error{"API key with scope '" + string(keyScope) + "' is not allowed to access this endpoint, requires scope '" + string(scope) + "'"}, http.StatusForbidden)
+			return
+		}
+		h(w, req, ps)
+	}
+}
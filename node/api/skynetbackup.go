@@ -0,0 +1,302 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// skynetbackup.go contains the endpoint which streams backup archives of
+// skyfiles. The archive contains the raw sectors of the skyfile which allows
+// for restoring the skyfile via /skynet/restore without downloading anything
+// from the hosts. See skymodules/skynetbackuparchive.go for the format.
+
+var (
+	// errArchiveSeekOutOfBounds is returned when seeking to a negative offset
+	// within a backup archive.
+	errArchiveSeekOutOfBounds = errors.New("seek offset out of bounds")
+)
+
+type (
+	// skyfileArchiveSegment is a contiguous part of a backup archive. It
+	// either contains static data like a tar header or the manifest, or a
+	// sector which is downloaded once it is read.
+	skyfileArchiveSegment struct {
+		staticData []byte
+		staticRoot crypto.Hash
+		staticSize int64
+	}
+
+	// skyfileArchiveReader is an io.ReadSeeker for a backup archive. The
+	// layout of the archive is known upfront which allows for serving range
+	// requests while only downloading the sectors which are actually read.
+	skyfileArchiveReader struct {
+		staticFetch    func(root crypto.Hash) ([]byte, error)
+		staticSegments []skyfileArchiveSegment
+		staticSize     int64
+
+		offset int64
+
+		// The last downloaded sector is cached since it is usually read in
+		// multiple calls to Read.
+		cachedRoot   crypto.Hash
+		cachedSector []byte
+	}
+)
+
+// newSkyfileArchiveReader creates the reader for the backup archive of a
+// skyfile from its manifest and full base sector. Fanout sectors are
+// downloaded using fetch.
+func newSkyfileArchiveReader(manifest skymodules.SkyfileArchiveManifest, baseSector []byte, fetch func(root crypto.Hash) ([]byte, error)) (*skyfileArchiveReader, error) {
+	ar := &skyfileArchiveReader{
+		staticFetch: fetch,
+	}
+	addEntry := func(name string, size int64) error {
+		hdr, err := skymodules.SkyfileArchiveEntryHeader(name, size)
+		if err != nil {
+			return err
+		}
+		ar.addData(hdr)
+		return nil
+	}
+
+	// Add the manifest.
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to marshal manifest")
+	}
+	err = addEntry(skymodules.SkyfileArchiveManifestName, int64(len(manifestBytes)))
+	if err != nil {
+		return nil, err
+	}
+	ar.addData(manifestBytes)
+	ar.addPadding()
+
+	// Add the base sector.
+	err = addEntry(skymodules.SkyfileArchiveBaseSectorName, int64(len(baseSector)))
+	if err != nil {
+		return nil, err
+	}
+	ar.addData(baseSector)
+	ar.addPadding()
+
+	// Add the fanout sectors.
+	for _, root := range manifest.Sectors {
+		err = addEntry(skymodules.SkyfileArchiveSectorName(root), int64(modules.SectorSize))
+		if err != nil {
+			return nil, err
+		}
+		ar.staticSegments = append(ar.staticSegments, skyfileArchiveSegment{
+			staticRoot: root,
+			staticSize: int64(modules.SectorSize),
+		})
+		ar.staticSize += int64(modules.SectorSize)
+		ar.addPadding()
+	}
+
+	// A tar archive ends with two zero blocks.
+	ar.addData(make([]byte, 2*512))
+	return ar, nil
+}
+
+// addData adds a static segment to the archive.
+func (ar *skyfileArchiveReader) addData(data []byte) {
+	ar.staticSegments = append(ar.staticSegments, skyfileArchiveSegment{
+		staticData: data,
+		staticSize: int64(len(data)),
+	})
+	ar.staticSize += int64(len(data))
+}
+
+// addPadding pads the archive to the next tar block.
+func (ar *skyfileArchiveReader) addPadding() {
+	if padding := (512 - ar.staticSize%512) % 512; padding > 0 {
+		ar.addData(make([]byte, padding))
+	}
+}
+
+// Read implements io.Reader.
+func (ar *skyfileArchiveReader) Read(b []byte) (int, error) {
+	if ar.offset >= ar.staticSize {
+		return 0, io.EOF
+	}
+
+	// Find the segment at the current offset.
+	var segmentOffset int64
+	var segment skyfileArchiveSegment
+	for _, segment = range ar.staticSegments {
+		if ar.offset < segmentOffset+segment.staticSize {
+			break
+		}
+		segmentOffset += segment.staticSize
+	}
+
+	// Download the sector if necessary.
+	data := segment.staticData
+	if data == nil {
+		if ar.cachedSector == nil || ar.cachedRoot != segment.staticRoot {
+			sector, err := ar.staticFetch(segment.staticRoot)
+			if err != nil {
+				return 0, errors.AddContext(err, fmt.Sprintf("failed to fetch sector %v", segment.staticRoot))
+			}
+			ar.cachedRoot = segment.staticRoot
+			ar.cachedSector = sector
+		}
+		data = ar.cachedSector
+	}
+	n := copy(b, data[ar.offset-segmentOffset:])
+	ar.offset += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (ar *skyfileArchiveReader) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = ar.offset + offset
+	case io.SeekEnd:
+		newOffset = ar.staticSize + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %v", whence)
+	}
+	if newOffset < 0 {
+		return 0, errArchiveSeekOutOfBounds
+	}
+	ar.offset = newOffset
+	return newOffset, nil
+}
+
+// skynetBackupHandlerGET is the handler for the /skynet/backup/:skylink GET
+// endpoint. It streams a backup archive containing the raw sectors of a
+// skyfile which can be restored using /skynet/restore.
+func (api *API) skynetBackupHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	if pricePerMSStr := queryForm.Get("priceperms"); pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'priceperms' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Resolve V2 skylinks and check whether the skylink can be served.
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	if skylink.IsSkylinkV2() {
+		skylink, _, err = api.renter.ResolveSkylinkV2(ctx, skylink)
+		if err != nil {
+			handleSkynetError(w, "failed to resolve skylink", err)
+			return
+		}
+	}
+	err = api.renter.CheckSkylinkAccess(ctx, skylink)
+	if err != nil {
+		handleSkynetError(w, "unable to back up skylink", err)
+		return
+	}
+
+	// Fetch the full sector of the base sector.
+	fetch := func(root crypto.Hash) ([]byte, error) {
+		return fetchArchiveSector(ctx, api.renter, root, timeout, pricePerMS)
+	}
+	sector, err := fetch(skylink.MerkleRoot())
+	if err != nil {
+		handleSkynetError(w, "failed to fetch base sector", err)
+		return
+	}
+	offset, fetchSize, err := skylink.OffsetAndFetchSize()
+	if err != nil {
+		WriteError(w, Error{"failed to get offset and fetch size: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse a decrypted copy of the base sector to get the fanout.
+	baseSector := append([]byte(nil), sector[offset:offset+fetchSize]...)
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		_, err = api.renter.DecryptBaseSector(baseSector)
+		if err != nil {
+			handleSkynetError(w, "failed to decrypt base sector", err)
+			return
+		}
+	}
+	layout, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(baseSector)
+	if errors.Contains(err, skymodules.ErrRecursiveBaseSector) {
+		WriteError(w, Error{"skyfiles with an extended base sector can't be backed up as an archive"}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		WriteError(w, Error{"failed to parse base sector: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		WriteError(w, Error{"failed to decode fanout: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// Build the archive.
+	manifest := skymodules.NewSkyfileArchiveManifest(skylink, layout, chunks)
+	archive, err := newSkyfileArchiveReader(manifest, sector, fetch)
+	if err != nil {
+		WriteError(w, Error{"failed to create archive: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// The archive only depends on the skylink which allows for resuming
+	// downloads with range requests.
+	eTag := crypto.HashAll("skyfilearchive", manifest.Version, skylink.String())
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	w.Header().Set("ETag", formatETag(eTag.String(), false))
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", strconv.Quote(skylink.String()+".tar")))
+	http.ServeContent(w, req, "", time.Time{}, archive)
+}
+
+// fetchArchiveSector downloads the full sector with the given root and
+// verifies it.
+func fetchArchiveSector(ctx context.Context, r skymodules.Renter, root crypto.Hash, timeout time.Duration, pricePerMS types.Currency) ([]byte, error) {
+	sector, err := r.DownloadByRoot(ctx, root, 0, modules.SectorSize, timeout, pricePerMS)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(sector)) != modules.SectorSize || crypto.MerkleRoot(sector) != root {
+		return nil, errors.New("downloaded sector doesn't match its root")
+	}
+	return sector, nil
+}
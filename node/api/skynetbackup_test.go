@@ -0,0 +1,112 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestSkyfileArchiveReader probes reading and seeking within a backup archive.
+func TestSkyfileArchiveReader(t *testing.T) {
+	t.Parallel()
+
+	// Create a base sector and two fanout sectors.
+	baseSector := fastrand.Bytes(int(modules.SectorSize))
+	skylink, err := skymodules.NewSkylinkV1(crypto.MerkleRoot(baseSector), 0, modules.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectors := make(map[crypto.Hash][]byte)
+	var chunks [][]crypto.Hash
+	for i := 0; i < 2; i++ {
+		sector := fastrand.Bytes(int(modules.SectorSize))
+		root := crypto.MerkleRoot(sector)
+		sectors[root] = sector
+		chunks = append(chunks, []crypto.Hash{root})
+	}
+	layout := skymodules.SkyfileLayout{
+		Filesize:           2 * modules.SectorSize,
+		FanoutDataPieces:   1,
+		FanoutParityPieces: 9,
+		CipherType:         crypto.TypePlain,
+	}
+	manifest := skymodules.NewSkyfileArchiveManifest(skylink, layout, chunks)
+
+	// Create the reader.
+	var fetches int
+	ar, err := newSkyfileArchiveReader(manifest, baseSector, func(root crypto.Hash) ([]byte, error) {
+		fetches++
+		return sectors[root], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the whole archive. Every sector should only be fetched once.
+	archive, err := ioutil.ReadAll(ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(archive)) != ar.staticSize || len(archive)%512 != 0 {
+		t.Fatal("unexpected archive size", len(archive), ar.staticSize)
+	}
+	if fetches != len(sectors) {
+		t.Fatal("unexpected number of fetches", fetches)
+	}
+
+	// The archive should be a valid tar archive.
+	tr := tar.NewReader(bytes.NewReader(archive))
+	_, sl, sector, err := skymodules.ReadSkyfileArchiveHeader(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sl != skylink || !bytes.Equal(sector, baseSector) {
+		t.Fatal("wrong skylink or base sector")
+	}
+	for _, root := range manifest.Sectors {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name != skymodules.SkyfileArchiveSectorName(root) {
+			t.Fatal("unexpected entry", hdr.Name)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, sectors[root]) {
+			t.Fatal("wrong sector data")
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatal("expected end of archive", err)
+	}
+
+	// Seeking to a random offset should return the same data.
+	offset := fastrand.Intn(len(archive))
+	_, err = ar.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, archive[offset:]) {
+		t.Fatal("data after seek doesn't match")
+	}
+
+	// Seeking to a negative offset should fail.
+	_, err = ar.Seek(-1, io.SeekStart)
+	if err != errArchiveSeekOutOfBounds {
+		t.Fatal("unexpected error", err)
+	}
+}
@@ -0,0 +1,512 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/types"
+)
+
+// skynetbundle.go contains the /skynet/bundle endpoint which downloads
+// multiple skylinks as a single archive. The skylinks are fetched in parallel
+// but written to the archive one after another. Once the response headers are
+// sent, failures of single entries can't be reported with a status code
+// anymore. Instead they are collected and added to the end of the archive as
+// a separate member or, if requested, as a '<path>.error' member in place of
+// every entry that failed.
+//
+// The /skynet/zip endpoint for "download all" buttons is served by the same
+// handler. It only differs in its default for how errors are reported.
+
+const (
+	// MaxSkynetBundleEntries is the maximum number of skylinks that can be
+	// bundled with a single call to /skynet/bundle.
+	MaxSkynetBundleEntries = 100
+
+	// SkynetBundleErrorsFile is the name of the archive member which lists
+	// the entries that couldn't be added to a bundle.
+	SkynetBundleErrorsFile = "_errors.json"
+
+	// SkynetBundleErrorSuffix is appended to the path of an entry to name the
+	// member which contains the error of the entry if it couldn't be added
+	// and errors are reported inline.
+	SkynetBundleErrorSuffix = ".error"
+
+	// skynetBundleMaxRequestSize is the maximum size of the body of a
+	// /skynet/bundle request.
+	skynetBundleMaxRequestSize = 1 << 20 // 1 MiB
+
+	// skynetBundleFetchThreads is the number of skylinks of a bundle that are
+	// fetched in parallel.
+	skynetBundleFetchThreads = 10
+)
+
+var (
+	// MaxSkynetBundleSize is the maximum combined size of the skyfiles within
+	// a bundle.
+	MaxSkynetBundleSize = build.Select(build.Var{
+		Dev:      uint64(1 << 30), // 1 GiB
+		Standard: uint64(1 << 30), // 1 GiB
+		Testing:  uint64(1 << 22), // 4 MiB
+	}).(uint64)
+)
+
+type (
+	// SkynetBundleEntry is a skylink that is added to a bundle under the
+	// given path.
+	SkynetBundleEntry struct {
+		Skylink string `json:"skylink"`
+		Path    string `json:"path"`
+	}
+
+	// SkynetBundleError describes why an entry is missing from a bundle or is
+	// incomplete.
+	SkynetBundleError struct {
+		Skylink string `json:"skylink"`
+		Path    string `json:"path"`
+		Error   string `json:"error"`
+	}
+
+	// bundleItem is an entry of a bundle together with the streamer of its
+	// skyfile.
+	bundleItem struct {
+		entry    SkynetBundleEntry
+		streamer skymodules.SkyfileStreamer
+		err      error
+	}
+
+	// bundleArchiver adds files to an archive.
+	bundleArchiver interface {
+		// AddFile adds a file with the given modification time to the
+		// archive and copies its content from src. An error reading from src
+		// is returned as readErr. Since it only affects a single file, the
+		// archive stays valid. Any other error is returned as err.
+		AddFile(file skymodules.SkyfileSubfileMetadata, modTime time.Time, src io.Reader) (readErr error, err error)
+
+		// Close finishes the archive.
+		Close() error
+	}
+
+	// tarBundleArchiver is a bundleArchiver for tar archives.
+	tarBundleArchiver struct {
+		staticTW *tar.Writer
+	}
+
+	// zipBundleArchiver is a bundleArchiver for zip archives.
+	zipBundleArchiver struct {
+		staticZW *zip.Writer
+	}
+
+	// readErrRecorder is a reader which remembers the last error returned by
+	// the underlying reader.
+	readErrRecorder struct {
+		r   io.Reader
+		err error
+	}
+
+	// zeroReader is a reader which returns an infinite amount of zeros.
+	zeroReader struct{}
+)
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts 'filename' as an
+// alias for 'path' since that's what /skynet/zip requests use.
+func (e *SkynetBundleEntry) UnmarshalJSON(b []byte) error {
+	var entry struct {
+		Skylink  string `json:"skylink"`
+		Path     string `json:"path"`
+		Filename string `json:"filename"`
+	}
+	err := json.Unmarshal(b, &entry)
+	if err != nil {
+		return err
+	}
+	if entry.Path != "" && entry.Filename != "" {
+		return errors.New("entry can't contain both a 'path' and a 'filename'")
+	}
+	e.Skylink = entry.Skylink
+	e.Path = entry.Path
+	if e.Path == "" {
+		e.Path = entry.Filename
+	}
+	return nil
+}
+
+// Read implements io.Reader.
+func (r *readErrRecorder) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// Read implements io.Reader.
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+// copyBundleFile copies size bytes from src to dst. Errors reading from src are
+// returned as readErr and errors writing to dst as writeErr.
+func copyBundleFile(dst io.Writer, src io.Reader, size uint64) (n int64, readErr error, writeErr error) {
+	r := &readErrRecorder{r: src}
+	n, err := io.CopyN(dst, r, int64(size))
+	if err == nil {
+		return n, nil, nil
+	}
+	if r.err != nil {
+		if errors.Contains(r.err, io.EOF) {
+			return n, io.ErrUnexpectedEOF, nil
+		}
+		return n, r.err, nil
+	}
+	return n, nil, err
+}
+
+// AddFile implements bundleArchiver. If reading from src fails, the rest of
+// the file is padded with zeros since the size of a file is part of its tar
+// header.
+func (ta *tarBundleArchiver) AddFile(file skymodules.SkyfileSubfileMetadata, modTime time.Time, src io.Reader) (error, error) {
+	header, err := tar.FileInfoHeader(file, file.Name())
+	if err != nil {
+		return nil, err
+	}
+	header.Name = file.Filename
+	header.ModTime = modTime
+	if err := ta.staticTW.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	n, readErr, err := copyBundleFile(ta.staticTW, src, file.Len)
+	if readErr != nil {
+		_, err = io.CopyN(ta.staticTW, zeroReader{}, int64(file.Len)-n)
+	}
+	return readErr, err
+}
+
+// Close implements bundleArchiver.
+func (ta *tarBundleArchiver) Close() error {
+	return ta.staticTW.Close()
+}
+
+// AddFile implements bundleArchiver.
+func (za *zipBundleArchiver) AddFile(file skymodules.SkyfileSubfileMetadata, modTime time.Time, src io.Reader) (error, error) {
+	f, err := za.staticZW.CreateHeader(zipFileHeader(file.Filename, modTime))
+	if err != nil {
+		return nil, err
+	}
+	_, readErr, err := copyBundleFile(f, src, file.Len)
+	return readErr, err
+}
+
+// Close implements bundleArchiver.
+func (za *zipBundleArchiver) Close() error {
+	return za.staticZW.Close()
+}
+
+// parseBundleEntries validates the entries of a bundle request and parses
+// their skylinks. If errors are reported inline, it also makes sure that the
+// error members of the entries don't collide with other entries.
+func parseBundleEntries(entries []SkynetBundleEntry, inlineErrors bool) ([]skymodules.Skylink, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("no entries provided")
+	}
+	if len(entries) > MaxSkynetBundleEntries {
+		return nil, fmt.Errorf("too many entries: %v > %v", len(entries), MaxSkynetBundleEntries)
+	}
+	skylinks := make([]skymodules.Skylink, 0, len(entries))
+	paths := make(map[string]struct{})
+	for _, entry := range entries {
+		var skylink skymodules.Skylink
+		err := skylink.LoadString(entry.Skylink)
+		if err != nil {
+			return nil, errors.AddContext(err, fmt.Sprintf("invalid skylink '%v'", entry.Skylink))
+		}
+		err = skymodules.ValidatePathString(entry.Path, false)
+		if err != nil {
+			return nil, errors.AddContext(err, fmt.Sprintf("invalid path '%v'", entry.Path))
+		}
+		if entry.Path == SkynetBundleErrorsFile {
+			return nil, fmt.Errorf("path '%v' is reserved", SkynetBundleErrorsFile)
+		}
+		if _, exists := paths[entry.Path]; exists {
+			return nil, fmt.Errorf("path '%v' is used more than once", entry.Path)
+		}
+		paths[entry.Path] = struct{}{}
+		skylinks = append(skylinks, skylink)
+	}
+	if !inlineErrors {
+		return skylinks, nil
+	}
+	for _, entry := range entries {
+		if _, exists := paths[entry.Path+SkynetBundleErrorSuffix]; exists {
+			return nil, fmt.Errorf("path '%v' collides with the error file of '%v'", entry.Path+SkynetBundleErrorSuffix, entry.Path)
+		}
+	}
+	return skylinks, nil
+}
+
+// bundleFiles returns the files of a skyfile sorted by offset. Their filenames
+// are prefixed with the path of the skyfile within the bundle. Skyfiles
+// without subfiles are placed at the path itself.
+func bundleFiles(path string, md skymodules.SkyfileMetadata, filesize uint64) []skymodules.SkyfileSubfileMetadata {
+	if len(md.Subfiles) == 0 {
+		length := md.Length
+		if length == 0 {
+			length = filesize
+		}
+		return []skymodules.SkyfileSubfileMetadata{{
+			FileMode: md.Mode,
+			Filename: path,
+			Offset:   0,
+			Len:      length,
+		}}
+	}
+	files := make([]skymodules.SkyfileSubfileMetadata, 0, len(md.Subfiles))
+	for _, sf := range md.Subfiles {
+		sf.Filename = path + "/" + strings.TrimPrefix(sf.Filename, "/")
+		files = append(files, sf)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Offset < files[j].Offset
+	})
+	return files
+}
+
+// managedFetchBundle fetches the skyfiles of a bundle in parallel. Failures
+// are reported through the err field of the returned items.
+func (api *API) managedFetchBundle(ctx context.Context, entries []SkynetBundleEntry, skylinks []skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) []bundleItem {
+	items := make([]bundleItem, len(entries))
+	itemChan := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < skynetBundleFetchThreads && i < len(entries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range itemChan {
+				streamer, _, err := api.renter.DownloadSkylink(ctx, skylinks[i], timeout, pricePerMS)
+				items[i] = bundleItem{
+					entry:    entries[i],
+					streamer: streamer,
+					err:      err,
+				}
+			}
+		}()
+	}
+	for i := range entries {
+		itemChan <- i
+	}
+	close(itemChan)
+	wg.Wait()
+	return items
+}
+
+// skynetBundleHandlerPOST is the handler for the /skynet/bundle and
+// /skynet/zip endpoints. It downloads multiple skylinks as a single archive.
+func (api *API) skynetBundleHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Decode the entries. The body is JSON so the parameters are parsed from
+	// the query string.
+	var entries []SkynetBundleEntry
+	err := json.NewDecoder(io.LimitReader(req.Body, skynetBundleMaxRequestSize)).Decode(&entries)
+	if err != nil {
+		WriteError(w, Error{"failed to decode request: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	queryForm := req.URL.Query()
+
+	// Parse how errors are reported. /skynet/zip reports them inline by
+	// default.
+	inlineErrors := req.URL.Path == "/skynet/zip"
+	switch queryForm.Get("errors") {
+	case "":
+	case "file":
+		inlineErrors = false
+	case "inline":
+		inlineErrors = true
+	default:
+		WriteError(w, Error{"unable to parse 'errors' parameter, allowed values are: 'file' and 'inline'"}, http.StatusBadRequest)
+		return
+	}
+	skylinks, err := parseBundleEntries(entries, inlineErrors)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the format. Bundles default to zip.
+	format := skymodules.SkyfileFormat(strings.ToLower(queryForm.Get("format")))
+	switch format {
+	case skymodules.SkyfileFormatNotSpecified:
+		format = skymodules.SkyfileFormatZip
+	case skymodules.SkyfileFormatTar:
+	case skymodules.SkyfileFormatTarGz:
+	case skymodules.SkyfileFormatZip:
+	default:
+		WriteError(w, Error{"unable to parse 'format' parameter, allowed values are: 'tar', 'targz' and 'zip'"}, http.StatusBadRequest)
+		return
+	}
+
+	api.serveBundle(w, req, entries, skylinks, format, inlineErrors)
+}
+
+// serveBundle fetches the skyfiles of a bundle and writes them to an archive
+// of the given format. If inlineErrors is set, the error of an entry which
+// can't be added is written to a '<path>.error' member. Otherwise the errors
+// are collected in SkynetBundleErrorsFile at the end of the archive.
+func (api *API) serveBundle(w http.ResponseWriter, req *http.Request, entries []SkynetBundleEntry, skylinks []skymodules.Skylink, format skymodules.SkyfileFormat, inlineErrors bool) {
+	queryForm := req.URL.Query()
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse pricePerMS.
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := queryForm.Get("priceperms")
+	if pricePerMSStr != "" {
+		_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pricePerMS' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Fetch the skyfiles.
+	items := api.managedFetchBundle(req.Context(), entries, skylinks, timeout, pricePerMS)
+	defer func() {
+		for _, item := range items {
+			if item.streamer != nil {
+				_ = item.streamer.Close()
+			}
+		}
+	}()
+
+	// Check the total size before sending any headers.
+	var totalSize uint64
+	files := make([][]skymodules.SkyfileSubfileMetadata, len(items))
+	for i, item := range items {
+		if item.err != nil {
+			continue
+		}
+		files[i] = bundleFiles(item.entry.Path, item.streamer.Metadata(), item.streamer.Layout().Filesize)
+		for _, file := range files[i] {
+			totalSize += file.Len
+		}
+	}
+	if totalSize > MaxSkynetBundleSize {
+		WriteError(w, Error{fmt.Sprintf("bundle is too large: %v > %v", totalSize, MaxSkynetBundleSize)}, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Create the archive.
+	var archiver bundleArchiver
+	switch format {
+	case skymodules.SkyfileFormatTar:
+		w.Header().Set("Content-Type", "application/x-tar")
+		archiver = &tarBundleArchiver{staticTW: tar.NewWriter(w)}
+	case skymodules.SkyfileFormatTarGz:
+		w.Header().Set("Content-Type", "application/gzip")
+		gzw := gzip.NewWriter(w)
+		defer func() {
+			_ = gzw.Close()
+		}()
+		archiver = &tarBundleArchiver{staticTW: tar.NewWriter(gzw)}
+	case skymodules.SkyfileFormatZip:
+		w.Header().Set("Content-Type", "application/zip")
+		archiver = &zipBundleArchiver{staticZW: zip.NewWriter(w)}
+	}
+
+	// Write the skyfiles one after another. At this point we can't respond
+	// with an error anymore so errors are either collected or, if the
+	// archive can't be written anymore, we give up.
+	bundleErrs := []SkynetBundleError{}
+	addErr := func(bundleErr SkynetBundleError) error {
+		if !inlineErrors {
+			bundleErrs = append(bundleErrs, bundleErr)
+			return nil
+		}
+		return addBundleErrorFile(archiver, bundleErr.Path+SkynetBundleErrorSuffix, []byte(bundleErr.Error))
+	}
+	for i, item := range items {
+		if item.err != nil {
+			err := addErr(SkynetBundleError{
+				Skylink: item.entry.Skylink,
+				Path:    item.entry.Path,
+				Error:   item.err.Error(),
+			})
+			if err != nil {
+				return
+			}
+			continue
+		}
+		for _, file := range files[i] {
+			var readErr error
+			_, err = item.streamer.Seek(int64(file.Offset), io.SeekStart)
+			if err != nil {
+				readErr = errors.AddContext(err, "failed to seek to file")
+			} else {
+				readErr, err = archiver.AddFile(file, item.streamer.Metadata().LastModified(), item.streamer)
+				if err != nil {
+					return
+				}
+			}
+			if readErr != nil {
+				err = addErr(SkynetBundleError{
+					Skylink: item.entry.Skylink,
+					Path:    file.Filename,
+					Error:   readErr.Error(),
+				})
+				if err != nil {
+					return
+				}
+				break
+			}
+		}
+	}
+
+	// Add the errors to the end of the archive.
+	if len(bundleErrs) > 0 {
+		errsJSON, err := json.Marshal(bundleErrs)
+		if err != nil {
+			build.Critical("failed to marshal bundle errors", err)
+			return
+		}
+		err = addBundleErrorFile(archiver, SkynetBundleErrorsFile, errsJSON)
+		if err != nil {
+			return
+		}
+	}
+	_ = archiver.Close()
+}
+
+// addBundleErrorFile adds a member with the given name and content to the
+// archive of a bundle to report errors.
+func addBundleErrorFile(archiver bundleArchiver, name string, content []byte) error {
+	errsFile := skymodules.SkyfileSubfileMetadata{
+		FileMode: 0644,
+		Filename: name,
+		Len:      uint64(len(content)),
+	}
+	_, err := archiver.AddFile(errsFile, time.Now(), bytes.NewReader(content))
+	return err
+}
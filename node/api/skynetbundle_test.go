@@ -0,0 +1,249 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestParseBundleEntries is a unit test for parseBundleEntries.
+func TestParseBundleEntries(t *testing.T) {
+	t.Parallel()
+
+	var mr crypto.Hash
+	fastrand.Read(mr[:])
+	skylink, err := skymodules.NewSkylinkV1(mr, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl := skylink.String()
+
+	tooMany := make([]SkynetBundleEntry, MaxSkynetBundleEntries+1)
+	for i := range tooMany {
+		tooMany[i] = SkynetBundleEntry{Skylink: sl, Path: fmt.Sprint(i)}
+	}
+
+	tests := []struct {
+		name         string
+		entries      []SkynetBundleEntry
+		inlineErrors bool
+		valid        bool
+	}{
+		{"Valid", []SkynetBundleEntry{{sl, "a"}, {sl, "b/c"}}, false, true},
+		{"NoEntries", nil, false, false},
+		{"TooMany", tooMany, false, false},
+		{"InvalidSkylink", []SkynetBundleEntry{{"invalid", "a"}}, false, false},
+		{"EmptyPath", []SkynetBundleEntry{{sl, ""}}, false, false},
+		{"AbsolutePath", []SkynetBundleEntry{{sl, "/a"}}, false, false},
+		{"Traversal", []SkynetBundleEntry{{sl, "a/../../b"}}, false, false},
+		{"Reserved", []SkynetBundleEntry{{sl, SkynetBundleErrorsFile}}, false, false},
+		{"Duplicate", []SkynetBundleEntry{{sl, "a"}, {sl, "a"}}, false, false},
+		{"ErrorSuffix", []SkynetBundleEntry{{sl, "a"}, {sl, "a" + SkynetBundleErrorSuffix}}, false, true},
+		{"InlineValid", []SkynetBundleEntry{{sl, "a"}, {sl, "b" + SkynetBundleErrorSuffix}}, true, true},
+		{"InlineErrorCollision", []SkynetBundleEntry{{sl, "a"}, {sl, "a" + SkynetBundleErrorSuffix}}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			skylinks, err := parseBundleEntries(test.entries, test.inlineErrors)
+			if test.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected error")
+			}
+			if test.valid && len(skylinks) != len(test.entries) {
+				t.Fatal("wrong number of skylinks", len(skylinks))
+			}
+		})
+	}
+}
+
+// TestSkynetBundleEntryUnmarshalJSON verifies that bundle entries accept
+// 'filename' as an alias for 'path'.
+func TestSkynetBundleEntryUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		json  string
+		entry SkynetBundleEntry
+		valid bool
+	}{
+		{"Path", `{"skylink":"sl","path":"a"}`, SkynetBundleEntry{"sl", "a"}, true},
+		{"Filename", `{"skylink":"sl","filename":"a"}`, SkynetBundleEntry{"sl", "a"}, true},
+		{"Both", `{"skylink":"sl","path":"a","filename":"b"}`, SkynetBundleEntry{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var entry SkynetBundleEntry
+			err := json.Unmarshal([]byte(test.json), &entry)
+			if test.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("expected error")
+			}
+			if entry != test.entry {
+				t.Fatal("unexpected entry", entry)
+			}
+		})
+	}
+}
+
+// TestBundleFiles is a unit test for bundleFiles.
+func TestBundleFiles(t *testing.T) {
+	t.Parallel()
+
+	// Single file.
+	md := skymodules.SkyfileMetadata{Filename: "file", Mode: 0640, Length: 10}
+	files := bundleFiles("dir/single", md, 10)
+	if len(files) != 1 || files[0].Filename != "dir/single" || files[0].Len != 10 || files[0].FileMode != 0640 {
+		t.Fatal("unexpected files", files)
+	}
+
+	// Single file without length falls back to the filesize.
+	md.Length = 0
+	files = bundleFiles("single", md, 20)
+	if len(files) != 1 || files[0].Len != 20 {
+		t.Fatal("unexpected files", files)
+	}
+
+	// Multiple files are nested under the path and sorted by offset.
+	md = skymodules.SkyfileMetadata{
+		Subfiles: skymodules.SkyfileSubfiles{
+			"b.txt":      {Filename: "b.txt", Offset: 5, Len: 5},
+			"dir/a.txt":  {Filename: "dir/a.txt", Offset: 0, Len: 5},
+			"index.html": {Filename: "index.html", Offset: 10, Len: 1},
+		},
+	}
+	files = bundleFiles("multi", md, 11)
+	expected := []string{"multi/dir/a.txt", "multi/b.txt", "multi/index.html"}
+	if len(files) != len(expected) {
+		t.Fatal("wrong number of files", len(files))
+	}
+	for i, file := range files {
+		if file.Filename != expected[i] {
+			t.Fatal("unexpected filename", file.Filename, expected[i])
+		}
+	}
+}
+
+// TestBundleArchivers is a unit test for the bundleArchiver implementations.
+// It makes sure that a failing reader doesn't corrupt the archive.
+func TestBundleArchivers(t *testing.T) {
+	t.Parallel()
+
+	data := fastrand.Bytes(100)
+	errRead := errors.New("read failed")
+	good := skymodules.SkyfileSubfileMetadata{FileMode: 0644, Filename: "good", Len: uint64(len(data))}
+	bad := skymodules.SkyfileSubfileMetadata{FileMode: 0644, Filename: "bad", Len: uint64(len(data))}
+	badReader := io.MultiReader(bytes.NewReader(data[:50]), errReader{errRead})
+	modTime := time.Unix(1600000000, 0)
+
+	// addFiles adds a good file, a bad file and another good file.
+	addFiles := func(a bundleArchiver) {
+		readErr, err := a.AddFile(good, modTime, bytes.NewReader(data))
+		if err != nil || readErr != nil {
+			t.Fatal(err, readErr)
+		}
+		readErr, err = a.AddFile(bad, modTime, badReader)
+		if err != nil || !errors.Contains(readErr, errRead) {
+			t.Fatal(err, readErr)
+		}
+		readErr, err = a.AddFile(good, modTime, bytes.NewReader(data))
+		if err != nil || readErr != nil {
+			t.Fatal(err, readErr)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tar
+	var buf bytes.Buffer
+	addFiles(&tarBundleArchiver{staticTW: tar.NewWriter(&buf)})
+	tr := tar.NewReader(&buf)
+	for _, name := range []string{"good", "bad", "good"} {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name != name || header.Size != int64(len(data)) || !header.ModTime.Equal(modTime) {
+			t.Fatal("unexpected header", header.Name, header.Size, header.ModTime)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "good" && !bytes.Equal(b, data) {
+			t.Fatal("data mismatch")
+		}
+		if name == "bad" && (!bytes.Equal(b[:50], data[:50]) || !bytes.Equal(b[50:], make([]byte, 50))) {
+			t.Fatal("bad file wasn't padded")
+		}
+	}
+	if _, err := tr.Next(); !errors.Contains(err, io.EOF) {
+		t.Fatal("expected end of archive", err)
+	}
+
+	// Zip
+	buf.Reset()
+	badReader = io.MultiReader(bytes.NewReader(data[:50]), errReader{errRead})
+	addFiles(&zipBundleArchiver{staticZW: zip.NewWriter(&buf)})
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 3 {
+		t.Fatal("wrong number of files", len(zr.File))
+	}
+	for _, f := range zr.File {
+		if !f.Modified.Equal(modTime) {
+			t.Fatal("unexpected modtime", f.Modified, modTime)
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name == "good" && !bytes.Equal(b, data) {
+			t.Fatal("data mismatch")
+		}
+		if f.Name == "bad" && !bytes.Equal(b, data[:50]) {
+			t.Fatal("bad file should be truncated")
+		}
+	}
+
+	// A short reader results in an unexpected EOF.
+	buf.Reset()
+	za := &zipBundleArchiver{staticZW: zip.NewWriter(&buf)}
+	readErr, err := za.AddFile(good, modTime, strings.NewReader("short"))
+	if err != nil || !errors.Contains(readErr, io.ErrUnexpectedEOF) {
+		t.Fatal(err, readErr)
+	}
+}
+
+// errReader is a reader that always returns the same error.
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
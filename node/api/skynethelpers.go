@@ -3,13 +3,20 @@ package api
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"html/template"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"sort"
@@ -41,44 +48,260 @@ var (
 	// once in the Header and once in the query params
 	errRangeSetTwice = errors.New("range request should use either the Header or the query params but not both")
 
+	// errSkynetForceDisabled is returned if a request uses force after force
+	// was disabled for it without providing a force token.
+	errSkynetForceDisabled = errors.New("'force' has been disabled on this node")
+
 	// errTimeoutTooHigh is returned when a parsed timeout exceeds the max.
 	errTimeoutTooHigh = errors.New("'timeout' parameter too high")
 
 	// errZeroTimeout is returned if the timeout is explicitly set to 0.
 	errZeroTimeout = errors.New("can't specify a zero timeout")
+
+	// errTooManyRetries is returned if the 'retries' parameter exceeds
+	// maxDownloadRetries.
+	errTooManyRetries = fmt.Errorf("'retries' parameter can't be greater than %v", maxDownloadRetries)
+
+	// errPreviewIncompatible is returned if the 'preview' parameter is
+	// combined with a parameter which requires the full content.
+	errPreviewIncompatible = errors.New("'preview' parameter can't be combined with a range request or the 'allow-partial', 'format' or 'verify' parameters")
+
+	// errPrefetchTooDeep is returned if the 'prefetch' parameter exceeds
+	// maxArchivePrefetchDepth.
+	errPrefetchTooDeep = fmt.Errorf("'prefetch' parameter can't be greater than %v", maxArchivePrefetchDepth)
+
+	// maxArchivePrefetchSize is the maximum size of a subfile that is
+	// prefetched when serving a skyfile as an archive. Larger subfiles are
+	// streamed directly.
+	maxArchivePrefetchSize = build.Select(build.Var{
+		Dev:      uint64(1 << 20),
+		Standard: uint64(1 << 22),
+		Testing:  uint64(1 << 10),
+	}).(uint64)
+
+	// downloadRetryBaseBackoff is the time to wait before the first retry of
+	// a failed skylink download. It doubles with every retry.
+	downloadRetryBaseBackoff = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: time.Second,
+		Testing:  10 * time.Millisecond,
+	}).(time.Duration)
+
+	// pinHealthCheckInterval is the interval at which the redundancy of a
+	// pinned skyfile is checked when the caller waits for it to become
+	// healthy.
+	pinHealthCheckInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// ErrSkyfileUploadTooLarge is returned if a skyfile upload exceeds the
+	// renter's configured maximum upload size.
+	ErrSkyfileUploadTooLarge = errors.New("upload exceeds the maximum upload size")
+
+	// ErrBaseSectorEncrypted is returned if an encrypted base sector can't be
+	// decrypted with any of the renter's skykeys.
+	ErrBaseSectorEncrypted = errors.New("base sector is encrypted and no matching skykey was found")
+
+	// ErrSkylinkRedirectLoop is returned if following the redirects of a
+	// skylink leads to a loop or exceeds maxSkylinkRedirects.
+	ErrSkylinkRedirectLoop = errors.New("skylink redirects loop or are nested too deeply")
+
+	// skylinkCORSExposedHeaders are the response headers of a skylink
+	// download which can be read by cross-origin requests.
+	skylinkCORSExposedHeaders = []string{
+		"Accept-Ranges",
+		"Content-Disposition",
+		"Content-Length",
+		"Content-Range",
+		"ETag",
+		SkynetBaseHrefHeader,
+		SkynetDefaultPathReasonHeader,
+		SkynetDefaultPathResolvedHeader,
+		SkynetDegradedHeader,
+		SkynetDownloadRetriesHeader,
+		SkynetFileLayoutHeader,
+		SkynetFileMetadataHeader,
+		SkynetHostStatsTrailer,
+		SkynetMissingRangesHeader,
+		SkynetPreviewHeader,
+		SkynetProofHeader,
+		SkynetServingProofHeader,
+		SkynetSkylinkHeader,
+	}
+
+	// skylinkIndexTemplate is the template used to render the HTML listing
+	// of the files within a skyfile.
+	skylinkIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Filename</th><th>Size</th><th>Content Type</th></tr>
+{{range .Subfiles}}<tr><td><a href="{{.Link}}">{{.Filename}}</a></td><td>{{.Size}}</td><td>{{.ContentType}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+)
+
+const (
+	// checksumBlake2b is the value of the 'checksum' query string parameter
+	// to request a blake2b hash of the downloaded data. The hash is attached
+	// as the content hash trailer.
+	checksumBlake2b = "blake2b"
+
+	// checksumSHA256 is the value of the 'checksum' query string parameter
+	// to request a sha256 checksum of the downloaded data.
+	checksumSHA256 = "sha256"
+
+	// maxDownloadRetries is the maximum number of times a failed skylink
+	// download can be retried.
+	maxDownloadRetries = 5
+
+	// maxArchivePrefetchDepth is the maximum number of subfiles that can be
+	// prefetched when serving a skyfile as an archive.
+	maxArchivePrefetchDepth = 8
+
+	// maxRedirectSkylinkSize is the maximum size of the body of an upload
+	// which creates a redirect to a skylink.
+	maxRedirectSkylinkSize = 1 << 10
+
+	// maxSkylinkRedirects is the maximum number of redirects which are
+	// followed when downloading a skylink.
+	maxSkylinkRedirects = 5
+
+	// maxPinManifestLineSize is the maximum size of a single line within a
+	// manifest of skylinks to pin, including the newline.
+	maxPinManifestLineSize = 1 << 10
+
+	// maxPinManifestEntries is the maximum number of non-empty lines within a
+	// manifest of skylinks to pin. Together with maxPinManifestLineSize it
+	// bounds the memory a single manifest can occupy.
+	maxPinManifestEntries = 1 << 14
+
+	// pinManifestConcurrency is the number of skylinks from a manifest that
+	// are pinned in parallel.
+	pinManifestConcurrency = 4
+
+	// skyfileIfExistsReturn is the value of the 'ifexists' upload parameter
+	// which returns the skylink of the skyfile at the upload's siapath
+	// instead of failing, as long as the uploaded content has the same
+	// skylink.
+	skyfileIfExistsReturn = "return"
 )
 
 type (
+	// bandwidthResponseWriter is a http.ResponseWriter which attaches the
+	// host bandwidth that was consumed to serve the written data as a
+	// trailer.
+	bandwidthResponseWriter struct {
+		http.ResponseWriter
+		staticStreamer skymodules.SkyfileHostStatsStreamer
+		wroteHeader    bool
+	}
+
+	// checksumResponseWriter is a http.ResponseWriter which feeds all the
+	// data written to it into a hasher.
+	checksumResponseWriter struct {
+		http.ResponseWriter
+		staticHasher  hash.Hash
+		staticTrailer string
+		wroteHeader   bool
+	}
+
+	// degradedResponseWriter is a http.ResponseWriter which sets the
+	// degraded header if any of the data read from the streamer before the
+	// header is written was downloaded in degraded mode.
+	degradedResponseWriter struct {
+		http.ResponseWriter
+		staticStreamer skymodules.SkyfileDegradedStreamer
+		wroteHeader    bool
+	}
+
+	// hostStatsResponseWriter is a http.ResponseWriter which attaches the
+	// stats of the hosts that served the written data as a trailer.
+	hostStatsResponseWriter struct {
+		http.ResponseWriter
+		staticStreamer skymodules.SkyfileHostStatsStreamer
+		wroteHeader    bool
+	}
+
+	// partialResponseWriter is a http.ResponseWriter which responds with
+	// http.StatusPartialContent instead of http.StatusOK.
+	partialResponseWriter struct {
+		http.ResponseWriter
+		wroteHeader bool
+	}
+
+	// servingProofResponseWriter is a http.ResponseWriter which attaches a
+	// proof signed by the portal that it served the written data.
+	servingProofResponseWriter struct {
+		http.ResponseWriter
+		staticContentHasher *checksumResponseWriter
+		staticRenter        skymodules.Renter
+		staticSkylink       skymodules.Skylink
+
+		bytesServed uint64
+		trailer     bool
+		wroteHeader bool
+	}
+
 	// skyfileUploadParams is a helper struct that contains all of the query
 	// string parameters on download
 	skyfileDownloadParams struct {
+		allowPartial         bool
 		attachment           bool
+		checksum             string
+		contentHash          bool
+		contentType          string
 		format               skymodules.SkyfileFormat
+		includeBandwidth     bool
+		includeHosts         bool
 		includeLayout        bool
+		noRedirect           bool
 		path                 string
+		prefetch             uint64
+		preview              uint64
 		pricePerMS           types.Currency
+		retries              uint64
+		signResponse         bool
+		skykey               *skykey.Skykey
 		skylink              skymodules.Skylink
 		skylinkStringNoQuery string
+		subfileIndex         *uint64
 		timeout              time.Duration
+		verify               bool
 	}
 
 	// skyfileUploadParams is a helper struct that contains all of the query
 	// string parameters on upload
 	skyfileUploadParams struct {
+		async               bool
 		baseChunkRedundancy uint8
 		defaultPath         string
 		convertPath         string
+		dataPieces          int
 		disableDefaultPath  bool
 		tryFiles            []string
 		errorPages          map[int]string
 		dryRun              bool
 		filename            string
 		force               bool
+		ifExists            string
+		includeMetadata     bool
 		mode                os.FileMode
+		modTime             int64
+		parityPieces        int
+		redirect            bool
 		root                bool
 		siaPath             skymodules.SiaPath
 		skyKeyID            skykey.SkykeyID
 		skyKeyName          string
+		skylinkHint         bool
+		sparse              bool
 	}
 
 	// skyfileUploadHeaders is a helper struct that contains all of the request
@@ -86,6 +309,12 @@ type (
 	skyfileUploadHeaders struct {
 		mediaType    string
 		disableForce bool
+		forceToken   *skymodules.SkynetForceToken
+
+		// uploadSession and partHashes are set for resumable multipart
+		// uploads.
+		uploadSession string
+		partHashes    map[string]crypto.Hash
 	}
 )
 
@@ -100,6 +329,49 @@ func (wr *writeReader) Read(_ []byte) (int, error) {
 	return 0, io.EOF
 }
 
+// maxUploadSizeReader is a helper type that wraps the body of an upload request
+// and returns ErrSkyfileUploadTooLarge as soon as more than the maximum upload
+// size is read from it.
+type maxUploadSizeReader struct {
+	io.ReadCloser
+	staticMaxSize uint64
+	remaining     uint64
+}
+
+// newMaxUploadSizeReader creates a new maxUploadSizeReader.
+func newMaxUploadSizeReader(body io.ReadCloser, maxSize uint64) *maxUploadSizeReader {
+	return &maxUploadSizeReader{
+		ReadCloser:    body,
+		staticMaxSize: maxSize,
+		remaining:     maxSize,
+	}
+}
+
+// Read implements the io.Reader interface.
+func (r *maxUploadSizeReader) Read(b []byte) (int, error) {
+	// If we already read the maximum size, check whether there is more data.
+	if r.remaining == 0 {
+		var buf [1]byte
+		n, err := r.ReadCloser.Read(buf[:])
+		if n > 0 {
+			return 0, errMaxUploadSizeExceeded(r.staticMaxSize)
+		}
+		return 0, err
+	}
+	if uint64(len(b)) > r.remaining {
+		b = b[:r.remaining]
+	}
+	n, err := r.ReadCloser.Read(b)
+	r.remaining -= uint64(n)
+	return n, err
+}
+
+// errMaxUploadSizeExceeded returns ErrSkyfileUploadTooLarge with the provided
+// limit as context.
+func errMaxUploadSizeExceeded(maxSize uint64) error {
+	return errors.AddContext(ErrSkyfileUploadTooLarge, fmt.Sprintf("limit is %v bytes", maxSize))
+}
+
 // newCustomErrorWriter creates a new customErrorWriter.
 func newCustomErrorWriter(meta skymodules.SkyfileMetadata, streamer io.ReadSeeker) *customErrorWriter {
 	if meta.ErrorPages == nil {
@@ -171,6 +443,15 @@ func buildETag(skylink skymodules.Skylink, path string, format skymodules.Skyfil
 	).String()
 }
 
+// formatETag returns the value of the ETag header for the given ETag. Weak
+// ETags are prefixed with 'W/'.
+func formatETag(eTag string, weak bool) string {
+	if weak {
+		return fmt.Sprintf("W/\"%v\"", eTag)
+	}
+	return fmt.Sprintf("\"%v\"", eTag)
+}
+
 // isMultipartRequest is a helper method that checks if the given media type
 // matches that of a multipart form.
 func isMultipartRequest(mediaType string) bool {
@@ -185,6 +466,7 @@ func isMultipartRequest(mediaType string) bool {
 func parseSkylinkURL(skylinkURL, apiRoute string) (skylink skymodules.Skylink, skylinkStringNoQuery, path string, err error) {
 	s := strings.TrimPrefix(skylinkURL, apiRoute)
 	s = strings.TrimPrefix(s, "/")
+	s = strings.TrimPrefix(s, skymodules.SkylinkScheme)
 	// Parse out optional path to a subfile
 	path = "/" // default to root
 	splits := strings.SplitN(s, "?", 2)
@@ -201,15 +483,57 @@ func parseSkylinkURL(skylinkURL, apiRoute string) (skylink skymodules.Skylink, s
 	}
 	// Parse skylink
 	err = skylink.LoadString(s)
+	if err != nil {
+		return
+	}
+	// Normalize the skylink to its canonical representation.
+	splits[0] = skylink.String()
+	skylinkStringNoQuery = strings.Join(splits, "/")
 	return
 }
 
+// parseRootParams parses the 'root', 'offset' and 'length' query string
+// parameters of a download by root.
+func parseRootParams(queryForm url.Values) (root crypto.Hash, offset, length uint64, err error) {
+	// Parse the root.
+	rootStr := queryForm.Get("root")
+	if rootStr == "" {
+		return crypto.Hash{}, 0, 0, errors.New("no root hash provided")
+	}
+	err = root.LoadString(rootStr)
+	if err != nil {
+		return crypto.Hash{}, 0, 0, errors.New("unable to parse 'root' parameter: " + err.Error())
+	}
+
+	// Parse the offset.
+	offsetStr := queryForm.Get("offset")
+	if offsetStr == "" {
+		return crypto.Hash{}, 0, 0, errors.New("no offset provided")
+	}
+	offset, err = strconv.ParseUint(offsetStr, 10, 64)
+	if err != nil {
+		return crypto.Hash{}, 0, 0, errors.New("unable to parse 'offset' parameter: " + err.Error())
+	}
+
+	// Parse the length.
+	lengthStr := queryForm.Get("length")
+	if lengthStr == "" {
+		return crypto.Hash{}, 0, 0, errors.New("no length provided")
+	}
+	length, err = strconv.ParseUint(lengthStr, 10, 64)
+	if err != nil {
+		return crypto.Hash{}, 0, 0, errors.New("unable to parse 'length' parameter: " + err.Error())
+	}
+	return root, offset, length, nil
+}
+
 // parseTimeout tries to parse the timeout from the query string and validate
-// it. If not present, it will default to DefaultSkynetRequestTimeout.
-func parseTimeout(queryForm url.Values) (time.Duration, error) {
+// it against the given maximum. If not present, it will default to the given
+// default timeout.
+func parseTimeout(queryForm url.Values, defaultTimeout, maxTimeout time.Duration) (time.Duration, error) {
 	timeoutStr := queryForm.Get("timeout")
 	if timeoutStr == "" {
-		return DefaultSkynetRequestTimeout, nil
+		return defaultTimeout, nil
 	}
 
 	var timeoutInt uint64
@@ -217,8 +541,8 @@ func parseTimeout(queryForm url.Values) (time.Duration, error) {
 	if err != nil {
 		return 0, errors.AddContext(err, "unable to parse 'timeout'")
 	}
-	if timeoutInt > uint64(MaxSkynetRequestTimeout.Seconds()) {
-		return 0, errors.AddContext(errTimeoutTooHigh, fmt.Sprintf("maximum allowed timeout is %ds", MaxSkynetRequestTimeout))
+	if timeoutInt > uint64(maxTimeout.Seconds()) {
+		return 0, errors.AddContext(errTimeoutTooHigh, fmt.Sprintf("maximum allowed timeout is %ds", uint64(maxTimeout.Seconds())))
 	}
 	if timeoutInt == 0 {
 		return 0, errZeroTimeout
@@ -226,6 +550,81 @@ func parseTimeout(queryForm url.Values) (time.Duration, error) {
 	return time.Duration(timeoutInt) * time.Second, nil
 }
 
+// skynetRequestTimeouts returns the default and maximum timeout for skynet
+// requests. Unless they are configured in the renter's settings,
+// DefaultSkynetRequestTimeout and MaxSkynetRequestTimeout are used.
+func (api *API) skynetRequestTimeouts() (time.Duration, time.Duration) {
+	defaultTimeout, maxTimeout := DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout
+	settings, err := api.renter.Settings()
+	if err != nil {
+		return defaultTimeout, maxTimeout
+	}
+	return skynetRequestTimeouts(settings)
+}
+
+// skynetRequestTimeouts returns the default and maximum timeout for skynet
+// requests from the given settings.
+func skynetRequestTimeouts(settings skymodules.RenterSettings) (time.Duration, time.Duration) {
+	defaultTimeout, maxTimeout := DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout
+	if settings.SkynetDefaultRequestTimeout > 0 {
+		defaultTimeout = time.Duration(settings.SkynetDefaultRequestTimeout) * time.Second
+	}
+	if settings.SkynetMaxRequestTimeout > 0 {
+		maxTimeout = time.Duration(settings.SkynetMaxRequestTimeout) * time.Second
+	}
+	return defaultTimeout, maxTimeout
+}
+
+// validateRegistryEntryData checks that the type of a registry entry is known
+// and that its data is valid for that type. Entries without pubkey can contain
+// arbitrary data while entries with pubkey need to start with the partial hash
+// of a host's pubkey. On failure, the batch error code is returned together
+// with the error.
+func validateRegistryEntryData(entryType modules.RegistryEntryType, data []byte) (string, error) {
+	switch entryType {
+	case modules.RegistryTypeWithoutPubkey:
+	case modules.RegistryTypeWithPubkey:
+		if len(data) < modules.RegistryPubKeyHashSize {
+			return RegistryBatchErrorMalformedData, fmt.Errorf("Registry data of an entry with pubkey needs to start with a %v byte pubkey hash: %v < %v", modules.RegistryPubKeyHashSize, len(data), modules.RegistryPubKeyHashSize)
+		}
+	default:
+		return RegistryBatchErrorUnknownType, fmt.Errorf("Unknown registry entry type %v, expected %v (without pubkey) or %v (with pubkey)", entryType, modules.RegistryTypeWithoutPubkey, modules.RegistryTypeWithPubkey)
+	}
+	if len(data) > modules.RegistryDataSize {
+		return RegistryBatchErrorDataTooBig, fmt.Errorf("Registry data is too big: %v > %v", len(data), modules.RegistryDataSize)
+	}
+	return "", nil
+}
+
+// registryEntryTypeName returns the name of the given registry entry type
+// which is reported by the /skynet/registry GET endpoint.
+func registryEntryTypeName(entryType modules.RegistryEntryType) string {
+	switch entryType {
+	case modules.RegistryTypeInvalid:
+		return RegistryEntryTypeInvalid
+	case modules.RegistryTypeWithoutPubkey:
+		return RegistryEntryTypeWithoutPubkey
+	case modules.RegistryTypeWithPubkey:
+		return RegistryEntryTypeWithPubkey
+	default:
+		return RegistryEntryTypeUnknown
+	}
+}
+
+// newRegistryHandlerGET creates the API representation of a registry entry.
+func newRegistryHandlerGET(srv skymodules.RegistryEntry) RegistryHandlerGET {
+	return RegistryHandlerGET{
+		Data:         hex.EncodeToString(srv.Data),
+		DataKey:      srv.Tweak,
+		Revision:     srv.Revision,
+		PublicKey:    srv.PubKey,
+		Signature:    hex.EncodeToString(srv.Signature[:]),
+		Type:         srv.Type,
+		DetectedType: registryEntryTypeName(srv.Type),
+		PrimaryWork:  srv.Type == modules.RegistryTypeWithPubkey && len(srv.Data) >= modules.RegistryPubKeyHashSize,
+	}
+}
+
 // parseRegistryTimeout tries to parse the timeout from the query string and
 // validate it. If not present, it will default to the max allowed value.
 func parseRegistryTimeout(queryForm url.Values) (time.Duration, error) {
@@ -249,8 +648,9 @@ func parseRegistryTimeout(queryForm url.Values) (time.Duration, error) {
 }
 
 // parseDownloadRequestParameters is a helper function that parses all of the
-// query parameters from a download request
-func parseDownloadRequestParameters(req *http.Request) (*skyfileDownloadParams, error) {
+// query parameters from a download request. The timeout is validated against
+// the given default and maximum timeouts.
+func parseDownloadRequestParameters(req *http.Request, defaultTimeout, maxTimeout time.Duration) (*skyfileDownloadParams, error) {
 	// Parse the skylink from the raw URL of the request. Any special characters
 	// in the raw URL are encoded, allowing us to differentiate e.g. the '?'
 	// that begins query parameters from the encoded version '%3F'.
@@ -280,11 +680,62 @@ func parseDownloadRequestParameters(req *http.Request) (*skyfileDownloadParams,
 	switch format {
 	case skymodules.SkyfileFormatNotSpecified:
 	case skymodules.SkyfileFormatConcat:
+	case skymodules.SkyfileFormatDataURI:
+	case skymodules.SkyfileFormatIndex:
 	case skymodules.SkyfileFormatTar:
 	case skymodules.SkyfileFormatTarGz:
 	case skymodules.SkyfileFormatZip:
 	default:
-		return nil, errors.New("unable to parse 'format' parameter, allowed values are: 'concat', 'tar', 'targz' and 'zip'")
+		return nil, errors.New("unable to parse 'format' parameter, allowed values are: 'concat', 'datauri', 'index', 'tar', 'targz' and 'zip'")
+	}
+
+	// Parse the 'checksum' query string parameter.
+	checksum := strings.ToLower(queryForm.Get("checksum"))
+	if checksum != "" && checksum != checksumSHA256 && checksum != checksumBlake2b {
+		return nil, errors.New("unable to parse 'checksum' parameter, allowed values are: 'blake2b' and 'sha256'")
+	}
+
+	// The hash of the content is attached as a trailer if it was requested
+	// explicitly or if the client accepts trailers.
+	contentHash := checksum == checksumBlake2b || acceptsTrailers(req.Header)
+
+	// Parse the 'contenttype' query string parameter.
+	var contentType string
+	if contentTypeStr := queryForm.Get("contenttype"); contentTypeStr != "" {
+		contentType, err = parseContentTypeOverride(contentTypeStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'contenttype' parameter: %v", err)
+		}
+	}
+
+	// Parse the `allow-partial` query string parameter.
+	var allowPartial bool
+	allowPartialStr := queryForm.Get("allow-partial")
+	if allowPartialStr != "" {
+		allowPartial, err = strconv.ParseBool(allowPartialStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'allow-partial' parameter: %v", err)
+		}
+	}
+
+	// Parse the `include-bandwidth` query string parameter.
+	var includeBandwidth bool
+	includeBandwidthStr := queryForm.Get("include-bandwidth")
+	if includeBandwidthStr != "" {
+		includeBandwidth, err = strconv.ParseBool(includeBandwidthStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'include-bandwidth' parameter: %v", err)
+		}
+	}
+
+	// Parse the `include-hosts` query string parameter.
+	var includeHosts bool
+	includeHostsStr := queryForm.Get("include-hosts")
+	if includeHostsStr != "" {
+		includeHosts, err = strconv.ParseBool(includeHostsStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'include-hosts' parameter: %v", err)
+		}
 	}
 
 	// Parse the `include-layout` query string parameter.
@@ -297,8 +748,38 @@ func parseDownloadRequestParameters(req *http.Request) (*skyfileDownloadParams,
 		}
 	}
 
+	// Parse the 'no-redirect' query string parameter.
+	var noRedirect bool
+	noRedirectStr := queryForm.Get("no-redirect")
+	if noRedirectStr != "" {
+		noRedirect, err = strconv.ParseBool(noRedirectStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'no-redirect' parameter: %v", err)
+		}
+	}
+
+	// Parse the 'sign-response' query string parameter.
+	var signResponse bool
+	signResponseStr := queryForm.Get("sign-response")
+	if signResponseStr != "" {
+		signResponse, err = strconv.ParseBool(signResponseStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'sign-response' parameter: %v", err)
+		}
+	}
+
+	// Parse the 'verify' query string parameter.
+	var verify bool
+	verifyStr := queryForm.Get("verify")
+	if verifyStr != "" {
+		verify, err = strconv.ParseBool(verifyStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'verify' parameter: %v", err)
+		}
+	}
+
 	// Parse the timeout.
-	timeout, err := parseTimeout(queryForm)
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -313,6 +794,77 @@ func parseDownloadRequestParameters(req *http.Request) (*skyfileDownloadParams,
 		}
 	}
 
+	// Parse the 'retries' query string parameter.
+	var retries uint64
+	retriesStr := queryForm.Get("retries")
+	if retriesStr != "" {
+		retries, err = strconv.ParseUint(retriesStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'retries' parameter: %v", err)
+		}
+		if retries > maxDownloadRetries {
+			return nil, errTooManyRetries
+		}
+	}
+
+	// Parse the 'prefetch' query string parameter.
+	var prefetch uint64
+	prefetchStr := queryForm.Get("prefetch")
+	if prefetchStr != "" {
+		prefetch, err = strconv.ParseUint(prefetchStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'prefetch' parameter: %v", err)
+		}
+		if prefetch > maxArchivePrefetchDepth {
+			return nil, errPrefetchTooDeep
+		}
+	}
+
+	// Parse the 'preview' query string parameter.
+	var preview uint64
+	previewStr := queryForm.Get("preview")
+	if previewStr != "" {
+		preview, err = strconv.ParseUint(previewStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'preview' parameter: %v", err)
+		}
+		if preview == 0 {
+			return nil, errors.New("'preview' parameter must be greater than 0")
+		}
+		if allowPartial || format != skymodules.SkyfileFormatNotSpecified || verify {
+			return nil, errPreviewIncompatible
+		}
+	}
+
+	// Parse the 'subfileindex' query string parameter. It selects a subfile
+	// by its position instead of its path so it can't be combined with one.
+	var subfileIndex *uint64
+	subfileIndexStr := queryForm.Get("subfileindex")
+	if subfileIndexStr != "" {
+		if path != "/" {
+			return nil, errors.New("'subfileindex' parameter can't be combined with a path")
+		}
+		index, err := strconv.ParseUint(subfileIndexStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'subfileindex' parameter: %v", err)
+		}
+		subfileIndex = &index
+	}
+
+	// Parse the skykey from the header. It's only used for this download.
+	var sk *skykey.Skykey
+	if skStr := req.Header.Get(SkynetSkykeyHeader); skStr != "" {
+		sk = new(skykey.Skykey)
+		err = sk.FromString(skStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse '%v' header: %v", SkynetSkykeyHeader, err)
+		}
+		err = sk.IsValid()
+		if err != nil {
+			return nil, fmt.Errorf("invalid skykey in '%v' header: %v", SkynetSkykeyHeader, err)
+		}
+	}
+
 	// Parse a range request from the query form
 	startStr := queryForm.Get("start")
 	endStr := queryForm.Get("end")
@@ -346,19 +898,70 @@ func parseDownloadRequestParameters(req *http.Request) (*skyfileDownloadParams,
 	} else if startStr != "" || endStr != "" {
 		return nil, errIncompleteRangeRequest
 	}
+	if preview > 0 && req.Header.Get("Range") != "" {
+		return nil, errPreviewIncompatible
+	}
 
 	return &skyfileDownloadParams{
+		allowPartial:         allowPartial,
 		attachment:           attachment,
+		checksum:             checksum,
+		contentHash:          contentHash,
+		contentType:          contentType,
 		format:               format,
+		includeBandwidth:     includeBandwidth,
+		includeHosts:         includeHosts,
 		includeLayout:        includeLayout,
+		noRedirect:           noRedirect,
 		path:                 path,
+		prefetch:             prefetch,
+		preview:              preview,
 		pricePerMS:           pricePerMS,
+		retries:              retries,
+		signResponse:         signResponse,
+		skykey:               sk,
 		skylink:              skylink,
 		skylinkStringNoQuery: skylinkStringNoQuery,
+		subfileIndex:         subfileIndex,
 		timeout:              timeout,
+		verify:               verify,
 	}, nil
 }
 
+// parseContentTypeOverride validates a content type that overrides the one of
+// a skyfile's metadata and returns it in its canonical form. Only full media
+// types of the form 'type/subtype' with optional parameters are accepted.
+func parseContentTypeOverride(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(mediaType, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("'%v' is not of the form 'type/subtype'", mediaType)
+	}
+	formatted := mime.FormatMediaType(mediaType, params)
+	if formatted == "" {
+		return "", fmt.Errorf("'%v' is not a valid media type", contentType)
+	}
+	return formatted, nil
+}
+
+// parseSkynetForceToken parses the 'Skynet-Force-Token' request header. It
+// returns nil if the header isn't set.
+func parseSkynetForceToken(req *http.Request) (*skymodules.SkynetForceToken, error) {
+	tokenStr := req.Header.Get(SkynetForceTokenHeader)
+	if tokenStr == "" {
+		return nil, nil
+	}
+	var token skymodules.SkynetForceToken
+	err := json.Unmarshal([]byte(tokenStr), &token)
+	if err != nil {
+		return nil, errors.AddContext(err, fmt.Sprintf("unable to parse '%v' header", SkynetForceTokenHeader))
+	}
+	return &token, nil
+}
+
 // parseUploadHeadersAndRequestParameters is a helper function that parses all
 // the query parameters and headers from an upload request
 func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Params) (*skyfileUploadHeaders, *skyfileUploadParams, error) {
@@ -374,6 +977,12 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 		}
 	}
 
+	// parse 'Skynet-Force-Token' request header
+	forceToken, err := parseSkynetForceToken(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// parse 'Content-Type' request header
 	ct := req.Header.Get("Content-Type")
 	mediaType, _, err := mime.ParseMediaType(ct)
@@ -381,12 +990,31 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 		return nil, nil, errors.AddContext(err, "failed parsing 'Content-Type' header")
 	}
 
+	// parse 'Skynet-Upload-Session' and 'Skynet-Part-Hashes' request headers
+	uploadSession, partHashes, err := parsePartHashes(req.Header.Get(SkynetUploadSessionHeader), req.Header.Get(SkynetPartHashesHeader))
+	if err != nil {
+		return nil, nil, err
+	}
+	if uploadSession != "" && !isMultipartRequest(mediaType) {
+		return nil, nil, fmt.Errorf("'%v' header is only supported for multipart uploads", SkynetUploadSessionHeader)
+	}
+
 	// parse query
 	queryForm, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
 		return nil, nil, errors.AddContext(err, "failed to parse query")
 	}
 
+	// parse 'async' query parameter
+	var async bool
+	asyncStr := queryForm.Get("async")
+	if asyncStr != "" {
+		async, err = strconv.ParseBool(asyncStr)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'async' parameter")
+		}
+	}
+
 	// parse 'basechunkredundancy' query parameter
 	baseChunkRedundancy := uint8(0)
 	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
@@ -398,10 +1026,16 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 	// parse 'convertpath' query parameter
 	convertPath := queryForm.Get("convertpath")
 
+	// parse 'datapieces' and 'paritypieces' query parameters
+	dataPieces, parityPieces, err := ParseDataAndParityPieces(queryForm.Get("datapieces"), queryForm.Get("paritypieces"))
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// parse 'defaultpath' query parameter
-	defaultPath := queryForm.Get("defaultpath")
-	if defaultPath != "" {
-		defaultPath = skymodules.EnsurePrefix(defaultPath, "/")
+	defaultPath, err := skymodules.NormalizeDefaultPath(queryForm.Get("defaultpath"))
+	if err != nil {
+		return nil, nil, errors.Compose(skymodules.ErrInvalidDefaultPath, err)
 	}
 
 	// parse 'disabledefaultpath' query parameter
@@ -464,6 +1098,12 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 		}
 	}
 
+	// parse 'ifexists' query parameter
+	ifExists := queryForm.Get("ifexists")
+	if ifExists != "" && ifExists != skyfileIfExistsReturn {
+		return nil, nil, fmt.Errorf("unable to parse 'ifexists' parameter: unknown value '%v'", ifExists)
+	}
+
 	// parse 'mode' query parameter
 	modeStr := queryForm.Get("mode")
 	var mode os.FileMode
@@ -474,6 +1114,29 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 		}
 	}
 
+	// parse 'modtime' query parameter
+	var modTime int64
+	modTimeStr := queryForm.Get("modtime")
+	if modTimeStr != "" {
+		modTime, err = strconv.ParseInt(modTimeStr, 10, 64)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'modtime' parameter")
+		}
+		if modTime <= 0 {
+			return nil, nil, errors.New("'modtime' must be a positive unix timestamp")
+		}
+	}
+
+	// parse 'redirect' query parameter
+	var redirect bool
+	redirectStr := queryForm.Get("redirect")
+	if redirectStr != "" {
+		redirect, err = strconv.ParseBool(redirectStr)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'redirect' parameter")
+		}
+	}
+
 	// parse 'root' query parameter
 	var root bool
 	rootStr := queryForm.Get("root")
@@ -499,6 +1162,36 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 	// parse 'skykeyname' query parameter
 	skykeyName := queryForm.Get("skykeyname")
 
+	// parse 'skylinkhint' query parameter
+	var skylinkHint bool
+	skylinkHintStr := queryForm.Get("skylinkhint")
+	if skylinkHintStr != "" {
+		skylinkHint, err = strconv.ParseBool(skylinkHintStr)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'skylinkhint' parameter")
+		}
+	}
+
+	// parse 'sparse' query parameter
+	var sparse bool
+	sparseStr := queryForm.Get("sparse")
+	if sparseStr != "" {
+		sparse, err = strconv.ParseBool(sparseStr)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'sparse' parameter")
+		}
+	}
+
+	// parse 'include-metadata' query parameter
+	var includeMetadata bool
+	includeMetadataStr := queryForm.Get("include-metadata")
+	if includeMetadataStr != "" {
+		includeMetadata, err = strconv.ParseBool(includeMetadataStr)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to parse 'include-metadata' parameter")
+		}
+	}
+
 	// parse 'skykeyid' query parameter
 	var skykeyID skykey.SkykeyID
 	skykeyIDStr := queryForm.Get("skykeyid")
@@ -511,13 +1204,14 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 
 	// validate parameter combos
 
-	// verify force is not set if disable force header was set
-	if disableForce && force {
-		return nil, nil, errors.New("'force' has been disabled on this node")
+	// verify force is not set if disable force header was set, unless there
+	// is a force token which is verified by the handler
+	if disableForce && force && forceToken == nil {
+		return nil, nil, errSkynetForceDisabled
 	}
 
 	// verify the dry-run and force parameter are not combined
-	if !disableForce && force && dryRun {
+	if force && dryRun {
 		return nil, nil, errors.New("'dryRun' and 'force' can not be combined")
 	}
 
@@ -536,6 +1230,46 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 		return nil, nil, errors.New("cannot set both a 'convertpath' and a 'filename'")
 	}
 
+	// verify the erasure coding is not set together with a convertpath, the
+	// converted siafile keeps its erasure coding
+	if convertPath != "" && dataPieces != 0 {
+		return nil, nil, errors.New("cannot set 'datapieces' and 'paritypieces' together with a 'convertpath'")
+	}
+
+	// verify async is only set together with a convertpath
+	if async && convertPath == "" {
+		return nil, nil, errors.New("'async' can only be set together with a 'convertpath'")
+	}
+
+	// verify skylinkhint is not set together with a convertpath
+	if skylinkHint && convertPath != "" {
+		return nil, nil, errors.New("'skylinkhint' can't be set together with a 'convertpath'")
+	}
+
+	// verify include-metadata is not set together with async, the skyfile
+	// of an async conversion is only built after the response was sent
+	if includeMetadata && async {
+		return nil, nil, errors.New("'include-metadata' can't be set together with 'async'")
+	}
+
+	// verify redirect is neither set together with a convertpath nor on
+	// multipart uploads, the body of a redirect only contains the skylink
+	if redirect && (convertPath != "" || isMultipartRequest(mediaType)) {
+		return nil, nil, errors.New("'redirect' can't be set together with a 'convertpath' or on multipart uploads")
+	}
+
+	// verify ifexists is only set on streaming uploads which are neither
+	// forced nor encrypted, encrypted skyfiles never have the same skylink
+	if ifExists != "" && (convertPath != "" || force || skykeyName != "" || skykeyIDStr != "") {
+		return nil, nil, errors.New("'ifexists' can't be set together with a 'convertpath', 'force', 'skykeyname' or 'skykeyid'")
+	}
+
+	// verify sparse is only set on streaming uploads which are not
+	// encrypted, the pieces of an encrypted zero chunk aren't zero
+	if sparse && (convertPath != "" || skykeyName != "" || skykeyIDStr != "") {
+		return nil, nil, errors.New("'sparse' can't be set together with a 'convertpath', 'skykeyname' or 'skykeyid'")
+	}
+
 	// verify skykeyname and skykeyid are not combined
 	if skykeyName != "" && skykeyIDStr != "" {
 		return nil, nil, errors.New("cannot set both a 'skykeyname' and 'skykeyid'")
@@ -543,31 +1277,677 @@ func parseUploadHeadersAndRequestParameters(req *http.Request, ps httprouter.Par
 
 	// create headers and parameters
 	headers := &skyfileUploadHeaders{
-		disableForce: disableForce,
-		mediaType:    mediaType,
+		disableForce:  disableForce,
+		forceToken:    forceToken,
+		mediaType:     mediaType,
+		uploadSession: uploadSession,
+		partHashes:    partHashes,
 	}
 	params := &skyfileUploadParams{
+		async:               async,
 		baseChunkRedundancy: baseChunkRedundancy,
 		convertPath:         convertPath,
+		dataPieces:          dataPieces,
 		defaultPath:         defaultPath,
 		disableDefaultPath:  disableDefaultPath,
 		dryRun:              dryRun,
 		errorPages:          errPages,
 		filename:            filename,
 		force:               force,
+		ifExists:            ifExists,
+		includeMetadata:     includeMetadata,
 		mode:                mode,
+		modTime:             modTime,
+		parityPieces:        parityPieces,
+		redirect:            redirect,
 		root:                root,
 		siaPath:             siaPath,
 		skyKeyID:            skykeyID,
 		skyKeyName:          skykeyName,
+		skylinkHint:         skylinkHint,
+		sparse:              sparse,
 		tryFiles:            tryFiles,
 	}
 	return headers, params, nil
 }
 
+// newSkynetSkyfileHandlerPOST creates the response of a skyfile upload for
+// the given skylink.
+func newSkynetSkyfileHandlerPOST(skylink skymodules.Skylink) SkynetSkyfileHandlerPOST {
+	resp := SkynetSkyfileHandlerPOST{
+		Skylink:    skylink.String(),
+		MerkleRoot: skylink.MerkleRoot(),
+		Bitfield:   skylink.Bitfield(),
+		Version:    skylink.Version(),
+	}
+	if skylink.IsSkylinkV1() {
+		_, resp.FetchSize, _ = skylink.OffsetAndFetchSize()
+	}
+	return resp
+}
+
+// skyfileUploadResponse builds the response of a skyfile upload with the
+// modtime that was used for the skyfile. If includeMetadata is set, the
+// response contains the provided layout and metadata of the skyfile's base
+// sector.
+func skyfileUploadResponse(skylink skymodules.Skylink, modTime int64, includeMetadata bool, layout skymodules.SkyfileLayout, metadataBytes []byte) (SkynetSkyfileHandlerPOST, error) {
+	resp := newSkynetSkyfileHandlerPOST(skylink)
+	resp.ModTime = modTime
+	if !includeMetadata {
+		return resp, nil
+	}
+	if metadataBytes == nil {
+		return SkynetSkyfileHandlerPOST{}, errors.New("metadata of the skyfile is not available")
+	}
+	var md skymodules.SkyfileMetadata
+	err := json.Unmarshal(metadataBytes, &md)
+	if err != nil {
+		return SkynetSkyfileHandlerPOST{}, errors.AddContext(err, "failed to decode metadata of the skyfile")
+	}
+	resp.Metadata = &md
+	resp.Layout = &SkyfileLayoutSummary{
+		CipherType:         layout.CipherType.String(),
+		FanoutDataPieces:   layout.FanoutDataPieces,
+		FanoutParityPieces: layout.FanoutParityPieces,
+		FanoutSize:         layout.FanoutSize,
+		Filesize:           layout.Filesize,
+		MetadataSize:       layout.MetadataSize,
+	}
+	return resp, nil
+}
+
+// readRedirectSkylink reads the skylink a redirect points to from the body of
+// an upload request.
+func readRedirectSkylink(body io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxRedirectSkylinkSize+1))
+	if err != nil {
+		return "", errors.AddContext(err, "failed to read skylink from body")
+	}
+	if len(b) > maxRedirectSkylinkSize {
+		return "", errors.New("body of a redirect can only contain a skylink")
+	}
+	var sl skymodules.Skylink
+	err = sl.LoadString(string(b))
+	if err != nil {
+		return "", errors.AddContext(err, "failed to parse skylink from body")
+	}
+	return sl.String(), nil
+}
+
+// setSkylinkCORSHeaders sets the CORS headers of a response to a skylink
+// request if the origin of the request is allowed by the renter's
+// SkynetCORSOrigins setting. No headers are set by default.
+func (api *API) setSkylinkCORSHeaders(w http.ResponseWriter, req *http.Request) bool {
+	settings, err := api.renter.Settings()
+	if err != nil || len(settings.SkynetCORSOrigins) == 0 {
+		return false
+	}
+	h := w.Header()
+	allowedOrigin, allowed := skymodules.AllowedCORSOrigin(settings.SkynetCORSOrigins, req.Header.Get("Origin"))
+	if allowedOrigin != skymodules.CORSWildcardOrigin {
+		h.Add("Vary", "Origin")
+	}
+	if !allowed {
+		return false
+	}
+	h.Set("Access-Control-Allow-Origin", allowedOrigin)
+	h.Set("Access-Control-Expose-Headers", strings.Join(skylinkCORSExposedHeaders, ", "))
+	return true
+}
+
+// readPinManifest reads the lines of a manifest of newline-separated
+// skylinks. Surrounding whitespace is trimmed and empty lines are skipped.
+// Manifests with more than maxPinManifestEntries lines are rejected.
+func readPinManifest(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, maxPinManifestLineSize), maxPinManifestLineSize)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(lines) == maxPinManifestEntries {
+			return nil, fmt.Errorf("manifest contains more than %v skylinks", maxPinManifestEntries)
+		}
+		lines = append(lines, line)
+	}
+	if errors.Contains(scanner.Err(), bufio.ErrTooLong) {
+		return nil, fmt.Errorf("manifest contains a line longer than %v bytes", maxPinManifestLineSize)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.AddContext(err, "failed to read manifest")
+	}
+	return lines, nil
+}
+
+// parseBaseSectorDecryptionParams parses the 'skykeyname', 'skykeyid' and
+// 'trykeys' query string parameters which control the server-side decryption
+// of encrypted base sectors. The chosen skykey is looked up right away and nil
+// is returned if neither a name nor an ID was provided. Trying all local
+// skykeys is opt-in, so 'trykeys' defaults to false.
+func (api *API) parseBaseSectorDecryptionParams(queryForm url.Values) (*skykey.Skykey, bool, error) {
+	var tryKeys bool
+	if tryKeysStr := queryForm.Get("trykeys"); tryKeysStr != "" {
+		var err error
+		tryKeys, err = strconv.ParseBool(tryKeysStr)
+		if err != nil {
+			return nil, false, errors.AddContext(err, "unable to parse 'trykeys' parameter")
+		}
+	}
+	skykeyName := queryForm.Get("skykeyname")
+	skykeyIDStr := queryForm.Get("skykeyid")
+	if skykeyName == "" && skykeyIDStr == "" {
+		return nil, tryKeys, nil
+	}
+	if skykeyName != "" && skykeyIDStr != "" {
+		return nil, false, errors.New("cannot set both a 'skykeyname' and 'skykeyid'")
+	}
+	if tryKeys {
+		return nil, false, errors.New("cannot set 'trykeys' together with a 'skykeyname' or 'skykeyid'")
+	}
+	var sk skykey.Skykey
+	var err error
+	if skykeyName != "" {
+		sk, err = api.renter.SkykeyByName(skykeyName)
+	} else {
+		var skykeyID skykey.SkykeyID
+		err = skykeyID.FromString(skykeyIDStr)
+		if err != nil {
+			return nil, false, errors.AddContext(err, "unable to parse 'skykeyid'")
+		}
+		sk, err = api.renter.SkykeyByID(skykeyID)
+	}
+	if err != nil {
+		return nil, false, errors.AddContext(err, "unable to get skykey")
+	}
+	return &sk, false, nil
+}
+
+// decryptBaseSector decrypts an encrypted base sector in-place. If a skykey is
+// provided, only that skykey is used. Otherwise the skykey with the ID from
+// the base sector's layout is used and, if tryKeys is set, all skykeys of type
+// TypePrivateID are tried as well. ErrBaseSectorEncrypted is returned if none
+// of the skykeys match the base sector.
+func (api *API) decryptBaseSector(baseSector []byte, sk *skykey.Skykey, tryKeys bool) error {
+	if sk == nil && tryKeys {
+		_, err := api.renter.DecryptBaseSector(baseSector)
+		if errors.Contains(err, skykey.ErrNoSkykeysWithThatID) || errors.Contains(err, renter.ErrNoSkykeyMatchesSkyfileEncryptionID) {
+			return errors.Compose(err, ErrBaseSectorEncrypted)
+		}
+		return err
+	}
+	if sk == nil {
+		key, err := api.renter.SkykeyByID(skymodules.EncryptedBaseSectorKeyID(baseSector))
+		if errors.Contains(err, skykey.ErrNoSkykeysWithThatID) {
+			return errors.Compose(err, ErrBaseSectorEncrypted)
+		} else if err != nil {
+			return err
+		}
+		sk = &key
+	}
+	matches, err := skymodules.SkykeyMatchesBaseSector(baseSector, *sk)
+	if err != nil {
+		return err
+	}
+	if !matches {
+		return errors.AddContext(ErrBaseSectorEncrypted, fmt.Sprintf("skykey '%v' doesn't match the base sector", sk.Name))
+	}
+	_, err = skymodules.DecryptBaseSector(baseSector, *sk)
+	return err
+}
+
+// writeBaseSectorEncryptedError responds with the given status code and a
+// SkynetBaseSectorEncryptedError which contains the key ID from the layout of
+// the encrypted base sector.
+func writeBaseSectorEncryptedError(w http.ResponseWriter, baseSector []byte, code int, err error) {
+	keyID := skymodules.EncryptedBaseSectorKeyID(baseSector)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	WriteJSON(w, SkynetBaseSectorEncryptedError{
+		Message:   err.Error(),
+		Encrypted: true,
+		SkykeyID:  keyID.ToString(),
+		TraceID:   w.Header().Get(SkynetTraceIDHeader),
+	})
+}
+
+// writeSkyfileUploadError writes the error of a skyfile upload which failed
+// after its skylink was computed together with the modtime a retry needs to
+// use to get the same skylink. The status code is the same as for other
+// skynet errors.
+func writeSkyfileUploadError(w http.ResponseWriter, prefix string, skylink skymodules.Skylink, modTime int64, err error) {
+	code := skynetErrorStatusCode(err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	WriteJSON(w, SkynetSkyfileUploadError{
+		Message:   fmt.Sprintf("%v: %v", prefix, err),
+		Skylink:   skylink.String(),
+		ModTime:   modTime,
+		Resumable: code >= http.StatusInternalServerError,
+		TraceID:   w.Header().Get(SkynetTraceIDHeader),
+	})
+}
+
+// writeSkynetStorageCapError writes the error of an upload or pin which was
+// rejected because it would exceed the skynet storage cap.
+func writeSkynetStorageCapError(w http.ResponseWriter, prefix string, sce renter.SkynetStorageCapError, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(skynetErrorStatusCode(err))
+	WriteJSON(w, SkynetStorageCapExceededError{
+		Message:  fmt.Sprintf("%v: %v", prefix, err),
+		Cap:      sce.Cap,
+		Used:     sce.Used,
+		Incoming: sce.Incoming,
+		TraceID:  w.Header().Get(SkynetTraceIDHeader),
+	})
+}
+
+// newSkynetWorkersGET summarizes the worker pool status. A worker is considered
+// usable for downloads if it is neither on a download nor a maintenance
+// cooldown. For uploads its contract also needs to be good for upload.
+func newSkynetWorkersGET(wps skymodules.WorkerPoolStatus) SkynetWorkersGET {
+	swg := SkynetWorkersGET{
+		NumWorkers:               wps.NumWorkers,
+		TotalDownloadCoolDown:    wps.TotalDownloadCoolDown,
+		TotalMaintenanceCoolDown: wps.TotalMaintenanceCoolDown,
+		TotalUploadCoolDown:      wps.TotalUploadCoolDown,
+		Workers:                  make([]SkynetWorkerGET, 0, len(wps.Workers)),
+	}
+	for _, ws := range wps.Workers {
+		downloadUsable := !ws.DownloadOnCoolDown && !ws.DownloadTerminated && !ws.MaintenanceOnCooldown
+		uploadUsable := ws.ContractUtility.GoodForUpload && !ws.UploadOnCoolDown && !ws.UploadTerminated && !ws.MaintenanceOnCooldown
+		if downloadUsable {
+			swg.NumDownloadWorkers++
+		}
+		if uploadUsable {
+			swg.NumUploadWorkers++
+		}
+		swg.TotalAvailableBalance = swg.TotalAvailableBalance.Add(ws.AccountStatus.AvailableBalance)
+		swg.TotalNegativeBalance = swg.TotalNegativeBalance.Add(ws.AccountStatus.NegativeBalance)
+		swg.Workers = append(swg.Workers, SkynetWorkerGET{
+			HostPubKey: ws.HostPubKey,
+
+			DownloadUsable: downloadUsable,
+			UploadUsable:   uploadUsable,
+
+			DownloadOnCoolDown:    ws.DownloadOnCoolDown,
+			MaintenanceOnCoolDown: ws.MaintenanceOnCooldown,
+			UploadOnCoolDown:      ws.UploadOnCoolDown,
+
+			AvailableBalance: ws.AccountStatus.AvailableBalance,
+			NegativeBalance:  ws.AccountStatus.NegativeBalance,
+		})
+	}
+	return swg
+}
+
+// newBandwidthResponseWriter creates a new bandwidthResponseWriter and
+// declares the bandwidth trailer on the wrapped writer.
+func newBandwidthResponseWriter(w http.ResponseWriter, s skymodules.SkyfileHostStatsStreamer) *bandwidthResponseWriter {
+	w.Header().Add("Trailer", SkynetBandwidthUsedTrailer)
+	return &bandwidthResponseWriter{
+		ResponseWriter: w,
+		staticStreamer: s,
+	}
+}
+
+// AttachBandwidthUsed sets the bandwidth trailer to the total bandwidth that
+// was consumed by the hosts to serve the data read from the streamer. It
+// needs to be called after the body was written.
+func (bw *bandwidthResponseWriter) AttachBandwidthUsed() {
+	bw.Header().Set(SkynetBandwidthUsedTrailer, fmt.Sprint(bandwidthUsed(bw.staticStreamer.HostStats())))
+}
+
+// Write implements the io.Writer interface.
+func (bw *bandwidthResponseWriter) Write(b []byte) (int, error) {
+	if !bw.wroteHeader {
+		bw.WriteHeader(http.StatusOK)
+	}
+	return bw.ResponseWriter.Write(b)
+}
+
+// WriteHeader implements the http.ResponseWriter interface. It removes the
+// Content-Length header before writing the header since trailers are only
+// sent with chunked responses.
+func (bw *bandwidthResponseWriter) WriteHeader(statusCode int) {
+	bw.wroteHeader = true
+	bw.Header().Del("Content-Length")
+	bw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// bandwidthUsed returns the total bandwidth consumed by the given hosts.
+func bandwidthUsed(hostStats []skymodules.SkynetHostStats) uint64 {
+	var bandwidth uint64
+	for _, hs := range hostStats {
+		bandwidth += hs.Bandwidth
+	}
+	return bandwidth
+}
+
+// newChecksumResponseWriter creates a new checksumResponseWriter and declares
+// the given trailer on the wrapped writer.
+func newChecksumResponseWriter(w http.ResponseWriter, hasher hash.Hash, trailer string) *checksumResponseWriter {
+	w.Header().Add("Trailer", trailer)
+	return &checksumResponseWriter{
+		ResponseWriter: w,
+		staticHasher:   hasher,
+		staticTrailer:  trailer,
+	}
+}
+
+// AttachChecksum sets the trailer to the checksum of all the data written so
+// far. It needs to be called after the body was written.
+func (cw *checksumResponseWriter) AttachChecksum() {
+	cw.Header().Set(cw.staticTrailer, hex.EncodeToString(cw.staticHasher.Sum(nil)))
+}
+
+// acceptsTrailers returns whether the client indicated that it accepts
+// trailers by sending 'TE: trailers'.
+func acceptsTrailers(h http.Header) bool {
+	for _, te := range h[textproto.CanonicalMIMEHeaderKey("TE")] {
+		for _, value := range strings.Split(te, ",") {
+			// Ignore a potential quality value.
+			value = strings.TrimSpace(strings.Split(value, ";")[0])
+			if strings.EqualFold(value, "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Write implements the io.Writer interface by writing the data to both the
+// wrapped writer and the hasher.
+func (cw *checksumResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	n, err := cw.ResponseWriter.Write(b)
+	_, _ = cw.staticHasher.Write(b[:n])
+	return n, err
+}
+
+// WriteHeader implements the http.ResponseWriter interface. It removes the
+// Content-Length header before writing the header since trailers are only
+// sent with chunked responses.
+func (cw *checksumResponseWriter) WriteHeader(statusCode int) {
+	cw.wroteHeader = true
+	cw.Header().Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// attachHostStats encodes the host stats and sets them as the host stats
+// header.
+func attachHostStats(h http.Header, hostStats []skymodules.SkynetHostStats) error {
+	b, err := json.Marshal(hostStats)
+	if err != nil {
+		return err
+	}
+	h.Set(SkynetHostStatsTrailer, string(b))
+	return nil
+}
+
+// newHostStatsResponseWriter creates a new hostStatsResponseWriter and declares
+// the host stats trailer on the wrapped writer.
+func newHostStatsResponseWriter(w http.ResponseWriter, s skymodules.SkyfileHostStatsStreamer) *hostStatsResponseWriter {
+	w.Header().Add("Trailer", SkynetHostStatsTrailer)
+	return &hostStatsResponseWriter{
+		ResponseWriter: w,
+		staticStreamer: s,
+	}
+}
+
+// AttachHostStats sets the host stats trailer to the stats of the hosts that
+// served the data read from the streamer. It needs to be called after the body
+// was written.
+func (hw *hostStatsResponseWriter) AttachHostStats() {
+	// At this point we have already responded so we can't write a potential
+	// error here.
+	_ = attachHostStats(hw.Header(), hw.staticStreamer.HostStats())
+}
+
+// Write implements the io.Writer interface.
+func (hw *hostStatsResponseWriter) Write(b []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(b)
+}
+
+// WriteHeader implements the http.ResponseWriter interface. It removes the
+// Content-Length header before writing the header since trailers are only
+// sent with chunked responses.
+func (hw *hostStatsResponseWriter) WriteHeader(statusCode int) {
+	hw.wroteHeader = true
+	hw.Header().Del("Content-Length")
+	hw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// newDegradedResponseWriter creates a new degradedResponseWriter.
+func newDegradedResponseWriter(w http.ResponseWriter, streamer skymodules.SkyfileDegradedStreamer) *degradedResponseWriter {
+	return &degradedResponseWriter{
+		ResponseWriter: w,
+		staticStreamer: streamer,
+	}
+}
+
+// Write implements the io.Writer interface.
+func (dw *degradedResponseWriter) Write(b []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(b)
+}
+
+// WriteHeader implements the http.ResponseWriter interface. It sets the
+// degraded header before writing the header.
+func (dw *degradedResponseWriter) WriteHeader(statusCode int) {
+	dw.wroteHeader = true
+	if dw.staticStreamer.Degraded() {
+		dw.Header().Set(SkynetDegradedHeader, "true")
+	}
+	dw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// attachMissingRanges encodes the missing ranges and sets them as the missing
+// ranges header.
+func attachMissingRanges(h http.Header, ranges []skymodules.SkynetMissingRange) error {
+	b, err := json.Marshal(ranges)
+	if err != nil {
+		return err
+	}
+	h.Set(SkynetMissingRangesHeader, string(b))
+	return nil
+}
+
+// newPartialResponseWriter creates a new partialResponseWriter.
+func newPartialResponseWriter(w http.ResponseWriter) *partialResponseWriter {
+	return &partialResponseWriter{
+		ResponseWriter: w,
+	}
+}
+
+// Write implements the io.Writer interface.
+func (pw *partialResponseWriter) Write(b []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	return pw.ResponseWriter.Write(b)
+}
+
+// WriteHeader implements the http.ResponseWriter interface. It replaces
+// http.StatusOK with http.StatusPartialContent.
+func (pw *partialResponseWriter) WriteHeader(statusCode int) {
+	pw.wroteHeader = true
+	if statusCode == http.StatusOK {
+		statusCode = http.StatusPartialContent
+	}
+	pw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// newServingProofResponseWriter creates a new servingProofResponseWriter. If a
+// content hasher is given, the proof covers the hash of the bytes written to
+// it. Otherwise it covers the Merkle root of the skylink's base sector.
+func newServingProofResponseWriter(w http.ResponseWriter, r skymodules.Renter, skylink skymodules.Skylink, contentHasher *checksumResponseWriter) *servingProofResponseWriter {
+	return &servingProofResponseWriter{
+		ResponseWriter:      w,
+		staticContentHasher: contentHasher,
+		staticRenter:        r,
+		staticSkylink:       skylink,
+	}
+}
+
+// AttachServingProof sets the serving proof trailer if the proof wasn't
+// attached as a header. It needs to be called after the body was written.
+func (pw *servingProofResponseWriter) AttachServingProof() {
+	if !pw.trailer {
+		return
+	}
+	contentHash := pw.staticSkylink.MerkleRoot()
+	if pw.staticContentHasher != nil {
+		copy(contentHash[:], pw.staticContentHasher.staticHasher.Sum(nil))
+	}
+	// At this point we have already responded so we can't write a potential
+	// error here.
+	_ = pw.attachServingProof(contentHash, pw.bytesServed)
+}
+
+// attachServingProof signs a serving proof for the given content hash and
+// number of bytes and sets it as the serving proof header.
+func (pw *servingProofResponseWriter) attachServingProof(contentHash crypto.Hash, bytesServed uint64) error {
+	proof, err := pw.staticRenter.SignSkynetServingProof(skymodules.SkynetServingProof{
+		Skylink:     pw.staticSkylink.String(),
+		ContentHash: contentHash,
+		Timestamp:   time.Now().Unix(),
+		BytesServed: bytesServed,
+	})
+	if err != nil {
+		return errors.AddContext(err, "failed to sign serving proof")
+	}
+	b, err := json.Marshal(proof)
+	if err != nil {
+		return err
+	}
+	pw.Header().Set(SkynetServingProofHeader, string(b))
+	return nil
+}
+
+// Write implements the io.Writer interface.
+func (pw *servingProofResponseWriter) Write(b []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	n, err := pw.ResponseWriter.Write(b)
+	pw.bytesServed += uint64(n)
+	return n, err
+}
+
+// WriteHeader implements the http.ResponseWriter interface. If the proof
+// covers the base sector and the length of the body is known, the proof is
+// attached as a header. Otherwise it is declared as a trailer which is only
+// sent with chunked responses. Error responses don't get a proof.
+func (pw *servingProofResponseWriter) WriteHeader(statusCode int) {
+	pw.wroteHeader = true
+	if statusCode != http.StatusOK && statusCode != http.StatusPartialContent {
+		pw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	contentLength, err := strconv.ParseUint(pw.Header().Get("Content-Length"), 10, 64)
+	if pw.staticContentHasher == nil && err == nil {
+		// We haven't responded yet but WriteHeader can't return an error
+		// either, the proof is omitted if it can't be signed.
+		_ = pw.attachServingProof(pw.staticSkylink.MerkleRoot(), contentLength)
+		pw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	pw.trailer = true
+	pw.Header().Add("Trailer", SkynetServingProofHeader)
+	pw.Header().Del("Content-Length")
+	pw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// relativeMissingRanges returns the parts of the missing ranges which overlap
+// with the content at the given offset and of the given size. The returned
+// ranges are relative to the offset of the content.
+func relativeMissingRanges(ranges []skymodules.SkynetMissingRange, offset, size uint64) []skymodules.SkynetMissingRange {
+	var relative []skymodules.SkynetMissingRange
+	for _, r := range ranges {
+		start, end := r.Offset, r.Offset+r.Length
+		if start < offset {
+			start = offset
+		}
+		if end > offset+size {
+			end = offset + size
+		}
+		if start >= end {
+			continue
+		}
+		relative = append(relative, skymodules.SkynetMissingRange{
+			Offset: start - offset,
+			Length: end - start,
+		})
+	}
+	return relative
+}
+
+// omitMissingSubfiles removes the subfiles which overlap with any of the
+// missing ranges from the metadata. The removed subfiles are returned as
+// missing ranges.
+func omitMissingSubfiles(md skymodules.SkyfileMetadata, ranges []skymodules.SkynetMissingRange) (skymodules.SkyfileMetadata, []skymodules.SkynetMissingRange) {
+	subfiles := make(skymodules.SkyfileSubfiles)
+	var omitted []skymodules.SkynetMissingRange
+	for name, sf := range skyfileSubfiles(md) {
+		overlaps := false
+		for _, r := range ranges {
+			if sf.Offset < r.Offset+r.Length && r.Offset < sf.Offset+sf.Len {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			subfiles[name] = sf
+			continue
+		}
+		omitted = append(omitted, skymodules.SkynetMissingRange{
+			Offset:   sf.Offset,
+			Length:   sf.Len,
+			Filename: sf.Filename,
+		})
+	}
+	sort.Slice(omitted, func(i, j int) bool {
+		return omitted[i].Offset < omitted[j].Offset
+	})
+	md.Subfiles = subfiles
+	return md, omitted
+}
+
+// skyfileDataURI reads size bytes of content from src and encodes them as a
+// base64 data URI. If no content type is provided, it is detected from the
+// content. Only the charset parameter of the content type is preserved.
+func skyfileDataURI(src io.Reader, contentType string, size uint64) (string, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(src, data)
+	if err != nil {
+		return "", errors.AddContext(err, "failed to read content")
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	if charset, ok := params["charset"]; ok && charset != "" {
+		mediaType += ";charset=" + charset
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
 // serveArchive serves skyfiles as an archive by reading them from r and writing
-// the archive to dst using the given archiveFunc.
-func serveArchive(w http.ResponseWriter, src io.ReadSeeker, format skymodules.SkyfileFormat, md skymodules.SkyfileMetadata) (err error) {
+// the archive to dst using the given archiveFunc. If prefetch is not 0, up to
+// prefetch subfiles are read ahead while the current one is being archived.
+func serveArchive(w http.ResponseWriter, src io.ReadSeeker, format skymodules.SkyfileFormat, md skymodules.SkyfileMetadata, prefetch uint64) (err error) {
 	// Based upon the given format, set the Content-Type header, wrap the writer
 	// and select an archive function.
 	var dst io.Writer
@@ -591,13 +1971,18 @@ func serveArchive(w http.ResponseWriter, src io.ReadSeeker, format skymodules.Sk
 		dst = w
 	}
 
-	// Get the files to archive.
+	// Get the files to archive. They are sorted by offset and filename to make
+	// sure the archive is the same for every download. Empty files share their
+	// offset with the next file.
 	var files []skymodules.SkyfileSubfileMetadata
 	for _, file := range md.Subfiles {
 		files = append(files, file)
 	}
 	sort.Slice(files, func(i, j int) bool {
-		return files[i].Offset < files[j].Offset
+		if files[i].Offset != files[j].Offset {
+			return files[i].Offset < files[j].Offset
+		}
+		return files[i].Filename < files[j].Filename
 	})
 	// If there are no files, it's a single file download. Manually construct a
 	// SkyfileSubfileMetadata from the SkyfileMetadata.
@@ -632,28 +2017,84 @@ func serveArchive(w http.ResponseWriter, src io.ReadSeeker, format skymodules.Sk
 			Len:      length,
 		})
 	}
-	err = archiveFunc(dst, src, files)
+	as, stop := newArchiveSource(src, files, prefetch)
+	defer stop()
+	err = archiveFunc(dst, as, files, md.LastModified())
 	return err
 }
 
+// serveIndex serves a listing of the files within md. The listing is served as
+// HTML if the client accepts it and as JSON otherwise.
+func serveIndex(w http.ResponseWriter, req *http.Request, skylink skymodules.Skylink, path string, md skymodules.SkyfileMetadata) error {
+	index := SkynetSkylinkIndexGET{
+		Path:     skymodules.EnsurePrefix(path, "/"),
+		Subfiles: make([]SkynetSkylinkIndexEntry, 0, len(md.Subfiles)),
+	}
+	for _, sf := range md.Subfiles {
+		index.Subfiles = append(index.Subfiles, SkynetSkylinkIndexEntry{
+			Filename:    sf.Filename,
+			Size:        sf.Len,
+			ContentType: sf.ContentType,
+			Link:        skylinkSubfileLink(skylink, sf.Filename),
+		})
+	}
+	// If there are no subfiles, it's a single file skyfile.
+	if len(md.Subfiles) == 0 {
+		index.Subfiles = append(index.Subfiles, SkynetSkylinkIndexEntry{
+			Filename: md.Filename,
+			Size:     md.Length,
+			Link:     skylinkSubfileLink(skylink, ""),
+		})
+	}
+	sort.Slice(index.Subfiles, func(i, j int) bool {
+		return index.Subfiles[i].Filename < index.Subfiles[j].Filename
+	})
+
+	if !strings.Contains(req.Header.Get("Accept"), "text/html") {
+		WriteJSON(w, index)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return skylinkIndexTemplate.Execute(w, index)
+}
+
+// skylinkSubfileLink returns the link to the subfile with the given filename
+// within the skyfile at the given skylink.
+func skylinkSubfileLink(skylink skymodules.Skylink, filename string) string {
+	link := "/skynet/skylink/" + skylink.String()
+	for _, segment := range strings.Split(filename, "/") {
+		if segment == "" {
+			continue
+		}
+		link += "/" + url.PathEscape(segment)
+	}
+	return link
+}
+
 // serveTar is an archiveFunc that implements serving the files from src to dst
 // as a tar.
-func serveTar(dst io.Writer, src io.Reader, files []skymodules.SkyfileSubfileMetadata) error {
+func serveTar(dst io.Writer, src archiveSource, files []skymodules.SkyfileSubfileMetadata, modTime time.Time) error {
 	tw := tar.NewWriter(dst)
 	for _, file := range files {
-		// Create header.
-		header, err := tar.FileInfoHeader(file, file.Name())
+		// Get the file content.
+		r, err := src.Next()
+		if err != nil {
+			return err
+		}
+		// Create header. Symlinks are added with their target.
+		header, err := tar.FileInfoHeader(file, file.SymlinkTarget)
 		if err != nil {
 			return err
 		}
 		// Modify name to match path within skyfile.
 		header.Name = file.Filename
+		header.ModTime = modTime
 		// Write header.
 		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
 		// Write file content.
-		if _, err := io.CopyN(tw, src, header.Size); err != nil {
+		if _, err := io.CopyN(tw, r, header.Size); err != nil {
 			return err
 		}
 	}
@@ -662,16 +2103,22 @@ func serveTar(dst io.Writer, src io.Reader, files []skymodules.SkyfileSubfileMet
 
 // serveZip is an archiveFunc that implements serving the files from src to dst
 // as a zip.
-func serveZip(dst io.Writer, src io.Reader, files []skymodules.SkyfileSubfileMetadata) error {
+func serveZip(dst io.Writer, src archiveSource, files []skymodules.SkyfileSubfileMetadata, modTime time.Time) error {
 	zw := zip.NewWriter(dst)
 	for _, file := range files {
-		f, err := zw.Create(file.Filename)
+		// Get the file content.
+		r, err := src.Next()
+		if err != nil {
+			return errors.AddContext(err, "serveZip: failed to read the file")
+		}
+
+		f, err := zw.CreateHeader(zipFileHeader(file.Filename, modTime))
 		if err != nil {
 			return errors.AddContext(err, "serveZip: failed to add the file to the zip")
 		}
 
 		// Write file content.
-		_, err = io.CopyN(f, src, int64(file.Len))
+		_, err = io.CopyN(f, r, int64(file.Len))
 		if err != nil {
 			return errors.AddContext(err, "serveZip: failed to write file contents to the zip")
 		}
@@ -679,55 +2126,361 @@ func serveZip(dst io.Writer, src io.Reader, files []skymodules.SkyfileSubfileMet
 	return zw.Close()
 }
 
-// handleSkynetError is a handler that returns the correct status code for a
-// given error returned by a skynet related method.
-func handleSkynetError(w http.ResponseWriter, prefix string, err error) {
-	httpErr := Error{fmt.Sprintf("%v: %v", prefix, err)}
+// zipFileHeader returns the header of a compressed zip entry with the given
+// name and modification time. The modification time is omitted if it's zero.
+func zipFileHeader(name string, modTime time.Time) *zip.FileHeader {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	if !modTime.IsZero() {
+		header.Modified = modTime
+	}
+	return header
+}
 
-	if errors.Contains(err, renter.ErrSkylinkBlocked) {
-		WriteError(w, httpErr, http.StatusUnavailableForLegalReasons)
-		return
+// serveNotModified sets the Last-Modified header of the response to modTime
+// and responds with a 304 if the request is a conditional GET or HEAD request
+// whose If-Modified-Since header is not older than modTime. It returns true if
+// the response was written. Nothing is done if modTime is zero.
+//
+// NOTE: the check is skipped if the request contains an If-None-Match header
+// since that takes precedence over If-Modified-Since.
+func serveNotModified(w http.ResponseWriter, req *http.Request, modTime time.Time) bool {
+	if modTime.IsZero() {
+		return false
+	}
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
 	}
-	if errors.Contains(err, renter.ErrRootNotFound) {
-		WriteError(w, httpErr, http.StatusNotFound)
-		return
+	if modTime.Truncate(time.Second).After(ims) {
+		return false
 	}
-	if errors.Contains(err, renter.ErrRegistryEntryNotFound) {
-		WriteError(w, httpErr, http.StatusNotFound)
-		return
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// eTagMatches returns whether the value of an If-None-Match header contains
+// the given ETag. As required for If-None-Match, the weak comparison is used
+// which ignores a 'W/' prefix. A '*' is not considered a match since it
+// requires knowing whether the skyfile exists.
+func eTagMatches(ifNoneMatch, eTag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == formatETag(eTag, false) {
+			return true
+		}
 	}
-	if errors.Contains(err, renter.ErrRegistryUpdateTimeout) {
-		WriteError(w, httpErr, http.StatusRequestTimeout)
-		return
+	return false
+}
+
+// serveSkylinkNotModified responds with a 304 if the request is a conditional
+// GET or HEAD request for a V1 skylink and its If-None-Match header matches
+// the ETag of the requested content. V1 skylinks are immutable so the ETag is
+// known without fetching the skyfile. It must only be used if the path and
+// format don't need to be resolved from the skyfile's metadata. It returns true
+// if the response was written.
+func (api *API) serveSkylinkNotModified(w http.ResponseWriter, req *http.Request, skylink skymodules.Skylink, path string, format skymodules.SkyfileFormat) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
 	}
-	if errors.Contains(err, renter.ErrRegistryLookupTimeout) {
-		WriteError(w, httpErr, http.StatusNotFound)
-		return
+	ifNoneMatch := req.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !skylink.IsSkylinkV1() {
+		return false
 	}
-	if errors.Contains(err, skymodules.ErrMalformedSkylink) {
-		WriteError(w, httpErr, http.StatusBadRequest)
-		return
+	eTag := buildETag(skylink, path, format)
+	if !eTagMatches(ifNoneMatch, eTag) {
+		return false
 	}
-	if errors.Contains(err, renter.ErrInvalidSkylinkVersion) {
-		WriteError(w, httpErr, http.StatusBadRequest)
-		return
+
+	// Cached copies of blocked skylinks must not be confirmed either. If the
+	// skylink can't be served, the regular download reports the error.
+	if err := api.renter.CheckSkylinkAccess(req.Context(), skylink); err != nil {
+		return false
 	}
-	if errors.Contains(err, modules.ErrLowerRevNum) {
-		WriteError(w, httpErr, http.StatusBadRequest)
-		return
+	settings, err := api.renter.Settings()
+	if err != nil {
+		return false
 	}
-	if errors.Contains(err, modules.ErrInsufficientWork) {
-		WriteError(w, httpErr, http.StatusBadRequest)
-		return
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	w.Header().Set("ETag", formatETag(eTag, settings.SkynetWeakETags))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// skyfileSubfiles returns the subfiles of a skyfile. Skyfiles without
+// subfiles are treated as a skyfile with a single subfile.
+func skyfileSubfiles(md skymodules.SkyfileMetadata) skymodules.SkyfileSubfiles {
+	if len(md.Subfiles) > 0 {
+		return md.Subfiles
+	}
+	return skymodules.SkyfileSubfiles{
+		md.Filename: skymodules.SkyfileSubfileMetadata{
+			FileMode: md.Mode,
+			Filename: md.Filename,
+			Len:      md.Length,
+		},
+	}
+}
+
+// skyfileManifest creates the manifest of the skyfile behind the given
+// streamer. The subfiles are sorted by offset which allows for hashing all of
+// them by reading the skyfile's data only once.
+func skyfileManifest(streamer skymodules.SkyfileStreamer, hashes bool) (SkynetManifestGET, error) {
+	md := streamer.Metadata()
+	manifest := SkynetManifestGET{
+		Skylink:  streamer.Skylink().String(),
+		Subfiles: []SkynetManifestSubfile{},
+	}
+	for path, sf := range skyfileSubfiles(md) {
+		manifest.Subfiles = append(manifest.Subfiles, SkynetManifestSubfile{
+			Path:        path,
+			Length:      sf.Len,
+			Offset:      sf.Offset,
+			ContentType: sf.ContentType,
+			Mode:        sf.FileMode,
+		})
+	}
+	sort.Slice(manifest.Subfiles, func(i, j int) bool {
+		if manifest.Subfiles[i].Offset != manifest.Subfiles[j].Offset {
+			return manifest.Subfiles[i].Offset < manifest.Subfiles[j].Offset
+		}
+		return manifest.Subfiles[i].Path < manifest.Subfiles[j].Path
+	})
+	if !hashes {
+		return manifest, nil
+	}
+
+	// Hash the subfiles.
+	for i := range manifest.Subfiles {
+		sf := &manifest.Subfiles[i]
+		ls, err := NewLimitStreamer(streamer, md, streamer.RawMetadata(), streamer.Skylink(), streamer.Layout(), sf.Offset, sf.Length)
+		if err != nil {
+			return SkynetManifestGET{}, errors.AddContext(err, fmt.Sprintf("failed to seek to subfile '%v'", sf.Path))
+		}
+		h := crypto.NewHash()
+		n, err := io.Copy(h, ls)
+		if err != nil {
+			return SkynetManifestGET{}, errors.AddContext(err, fmt.Sprintf("failed to read subfile '%v'", sf.Path))
+		}
+		if uint64(n) != sf.Length {
+			return SkynetManifestGET{}, fmt.Errorf("failed to read subfile '%v', expected %v bytes but got %v", sf.Path, sf.Length, n)
+		}
+		var hash crypto.Hash
+		copy(hash[:], h.Sum(nil))
+		sf.Hash = &hash
+	}
+	return manifest, nil
+}
+
+// diffSkyfileSubfiles returns the subfiles which were added, removed or
+// changed in size or content type between two sets of subfiles. The results
+// are sorted by filename.
+func diffSkyfileSubfiles(from, to skymodules.SkyfileSubfiles) SkynetDiffPOST {
+	diff := SkynetDiffPOST{
+		Added:   []skymodules.SkyfileSubfileMetadata{},
+		Removed: []skymodules.SkyfileSubfileMetadata{},
+		Changed: []SkynetSubfileDiff{},
+	}
+	for name, fromSF := range from {
+		toSF, exists := to[name]
+		if !exists {
+			diff.Removed = append(diff.Removed, fromSF)
+			continue
+		}
+		if fromSF.Len != toSF.Len || fromSF.ContentType != toSF.ContentType {
+			diff.Changed = append(diff.Changed, SkynetSubfileDiff{
+				Filename:       name,
+				OldLen:         fromSF.Len,
+				NewLen:         toSF.Len,
+				OldContentType: fromSF.ContentType,
+				NewContentType: toSF.ContentType,
+			})
+		}
+	}
+	for name, toSF := range to {
+		if _, exists := from[name]; !exists {
+			diff.Added = append(diff.Added, toSF)
+		}
 	}
-	if errors.Contains(err, modules.ErrSameRevNum) {
-		WriteError(w, httpErr, http.StatusBadRequest)
+	sort.Slice(diff.Added, func(i, j int) bool {
+		return diff.Added[i].Filename < diff.Added[j].Filename
+	})
+	sort.Slice(diff.Removed, func(i, j int) bool {
+		return diff.Removed[i].Filename < diff.Removed[j].Filename
+	})
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Filename < diff.Changed[j].Filename
+	})
+	return diff
+}
+
+// handleSkynetError is a handler that returns the correct status code for a
+// given error returned by a skynet related method.
+func handleSkynetError(w http.ResponseWriter, prefix string, err error) {
+	if err == nil {
 		return
 	}
-	if err != nil {
-		WriteError(w, httpErr, http.StatusInternalServerError)
+	if sce, ok := renter.SkynetStorageCapFromError(err); ok {
+		writeSkynetStorageCapError(w, prefix, sce, err)
 		return
 	}
+	WriteError(w, Error{fmt.Sprintf("%v: %v", prefix, err)}, skynetErrorStatusCode(err))
+}
+
+// isTransientDownloadError returns whether a failed skylink download might
+// succeed if it is retried. Errors caused by the skylink itself, e.g. because
+// it is blocked or malformed, are permanent.
+func isTransientDownloadError(err error) bool {
+	return !errors.Contains(err, renter.ErrSkylinkBlocked) &&
+		!errors.Contains(err, renter.ErrSkylinkNotAllowed) &&
+		!errors.Contains(err, renter.ErrSkylinkUnpinned) &&
+		!errors.Contains(err, renter.ErrInvalidMetadata) &&
+		!errors.Contains(err, renter.ErrInvalidSkylinkVersion) &&
+		!errors.Contains(err, renter.ErrSkylinkNesting) &&
+		!errors.Contains(err, skymodules.ErrMalformedSkylink)
+}
+
+// downloadWithRetries calls download until it succeeds, fails with a
+// permanent error or has been retried 'retries' times. The time between the
+// attempts grows exponentially starting at downloadRetryBaseBackoff.
+func downloadWithRetries(ctx context.Context, retries uint64, download func() error) error {
+	backoff := downloadRetryBaseBackoff
+	for attempt := uint64(0); ; attempt++ {
+		err := download()
+		if err == nil || attempt >= retries || !isTransientDownloadError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Compose(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// skynetErrorStatusCode returns the http status code for a given error
+// returned by a skynet related method.
+func skynetErrorStatusCode(err error) int {
+	switch {
+	case errors.Contains(err, renter.ErrSkylinkBlocked):
+		return http.StatusUnavailableForLegalReasons
+	case errors.Contains(err, renter.ErrSkylinkNotAllowed):
+		return http.StatusForbidden
+	case errors.Contains(err, renter.ErrSkykeyMismatch):
+		return http.StatusForbidden
+	case errors.Contains(err, renter.ErrRootNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrRegistryEntryNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrRegistryUpdateTimeout):
+		return http.StatusRequestTimeout
+	case errors.Contains(err, renter.ErrSkyfileConversionNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrSkyfileConversionFinished):
+		return http.StatusBadRequest
+	case errors.Contains(err, renter.ErrSkyfilePendingUploadNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrSkylinkPrefetchNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrSkylinkPrefetchLimitReached):
+		return http.StatusTooManyRequests
+	case errors.Contains(err, renter.ErrSkynetSnapshotNotFound):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrSkynetSnapshotInvalidName):
+		return http.StatusBadRequest
+	case errors.Contains(err, renter.ErrRegistryLookupTimeout):
+		return http.StatusNotFound
+	case errors.Contains(err, renter.ErrInvalidFanoutPieces):
+		return http.StatusBadRequest
+	case errors.Contains(err, ErrSkyfileUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Contains(err, renter.ErrSkynetStorageCapExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Contains(err, ErrSkylinkRedirectLoop):
+		return http.StatusLoopDetected
+	case errors.Contains(err, skymodules.ErrSkyfileVerificationFailed):
+		return http.StatusBadGateway
+	case errors.Contains(err, skymodules.ErrMalformedSkylink):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrMultipartSizeMismatch):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrTooManySubfiles):
+		return http.StatusRequestEntityTooLarge
+	case errors.Contains(err, skymodules.ErrMultipartHeadersTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Contains(err, skymodules.ErrSubfileNameTooLong):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrDuplicateSubfile):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrUploadPolicyViolation):
+		return http.StatusUnsupportedMediaType
+	case errors.Contains(err, errSkynetPartHashMismatch):
+		return http.StatusBadRequest
+	case errors.Contains(err, errSkynetForceDisabled):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrInvalidSkynetForceToken):
+		return http.StatusForbidden
+	case errors.Contains(err, renter.ErrInvalidSkylinkVersion):
+		return http.StatusBadRequest
+	case errors.Contains(err, renter.ErrSparseEncrypted):
+		return http.StatusBadRequest
+	case errors.Contains(err, renter.ErrPublishInProgress):
+		return http.StatusConflict
+	case errors.Contains(err, renter.ErrRegistryKeyNotFound):
+		return http.StatusBadRequest
+	case errors.Contains(err, skymodules.ErrLargeRegistryDataTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Contains(err, skymodules.ErrInvalidLargeRegistryManifest):
+		return http.StatusBadRequest
+	case errors.Contains(err, renter.ErrChunkIndexOutOfBounds):
+		return http.StatusBadRequest
+	case errors.Contains(err, modules.ErrLowerRevNum):
+		return http.StatusBadRequest
+	case errors.Contains(err, modules.ErrInsufficientWork):
+		return http.StatusBadRequest
+	case errors.Contains(err, modules.ErrSameRevNum):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// registryBatchResult turns the result of a single update within a batch of
+// registry updates into a RegistryBatchResult.
+func registryBatchResult(err error) RegistryBatchResult {
+	if err == nil {
+		return RegistryBatchResult{
+			Success:    true,
+			StatusCode: http.StatusOK,
+		}
+	}
+	var errorCode string
+	switch {
+	case errors.Contains(err, modules.ErrLowerRevNum):
+		errorCode = RegistryBatchErrorLowerRevNum
+	case errors.Contains(err, modules.ErrSameRevNum):
+		errorCode = RegistryBatchErrorSameRevNum
+	case errors.Contains(err, modules.ErrInsufficientWork):
+		errorCode = RegistryBatchErrorInsufficientWork
+	case errors.Contains(err, renter.ErrRegistryUpdateTimeout):
+		errorCode = RegistryBatchErrorTimeout
+	default:
+		errorCode = RegistryBatchErrorUnknown
+	}
+	return RegistryBatchResult{
+		StatusCode: skynetErrorStatusCode(err),
+		ErrorCode:  errorCode,
+		Error:      err.Error(),
+	}
 }
 
 // attachRegistryEntryProof takes a number of registry entries and parses them.
@@ -736,14 +2489,7 @@ func handleSkynetError(w http.ResponseWriter, prefix string, err error) {
 func attachRegistryEntryProof(w http.ResponseWriter, srvs []skymodules.RegistryEntry) error {
 	proofChain := make([]RegistryHandlerGET, 0, len(srvs))
 	for _, srv := range srvs {
-		proofChain = append(proofChain, RegistryHandlerGET{
-			Data:      hex.EncodeToString(srv.Data),
-			DataKey:   srv.Tweak,
-			Revision:  srv.Revision,
-			PublicKey: srv.PubKey,
-			Signature: hex.EncodeToString(srv.Signature[:]),
-			Type:      srv.Type,
-		})
+		proofChain = append(proofChain, newRegistryHandlerGET(srv))
 	}
 	// If the proof is empty, don't set the header.
 	if len(proofChain) == 0 {
@@ -2,10 +2,14 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
@@ -21,6 +25,7 @@ import (
 	"gitlab.com/SkynetLabs/skyd/skykey"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
 
 	"gitlab.com/SkynetLabs/skyd/skymodules"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter"
@@ -73,6 +78,10 @@ func TestHandleSkynetError(t *testing.T) {
 			err:        renter.ErrSkylinkBlocked,
 			statusCode: http.StatusUnavailableForLegalReasons,
 		},
+		{
+			err:        renter.ErrSkylinkNotAllowed,
+			statusCode: http.StatusForbidden,
+		},
 		{
 			err:        renter.ErrRootNotFound,
 			statusCode: http.StatusNotFound,
@@ -85,10 +94,74 @@ func TestHandleSkynetError(t *testing.T) {
 			err:        renter.ErrRegistryLookupTimeout,
 			statusCode: http.StatusNotFound,
 		},
+		{
+			err:        renter.ErrSkylinkPrefetchNotFound,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			err:        renter.ErrSkylinkPrefetchLimitReached,
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			err:        renter.ErrSkynetSnapshotNotFound,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			err:        renter.ErrSkynetSnapshotInvalidName,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			err:        renter.ErrInvalidFanoutPieces,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			err:        ErrSkyfileUploadTooLarge,
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			err:        renter.ErrSkynetStorageCapExceeded,
+			statusCode: http.StatusInsufficientStorage,
+		},
 		{
 			err:        skymodules.ErrMalformedSkylink,
 			statusCode: http.StatusBadRequest,
 		},
+		{
+			err:        skymodules.ErrMultipartSizeMismatch,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			err:        skymodules.ErrUploadPolicyViolation,
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			err:        errors.Compose(skymodules.ErrTooManySubfiles, skymodules.ErrUploadPolicyViolation),
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			err:        skymodules.ErrTooManySubfiles,
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			err:        skymodules.ErrMultipartHeadersTooLarge,
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			err:        skymodules.ErrSubfileNameTooLong,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			err:        skymodules.ErrDuplicateSubfile,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			err:        ErrSkylinkRedirectLoop,
+			statusCode: http.StatusLoopDetected,
+		},
+		{
+			err:        skymodules.ErrSkyfileVerificationFailed,
+			statusCode: http.StatusBadGateway,
+		},
 		{
 			err:        renter.ErrInvalidSkylinkVersion,
 			statusCode: http.StatusBadRequest,
@@ -275,6 +348,30 @@ func testParseSkylinkURL(t *testing.T) {
 			path:                 "/foo?bar",
 			errMsg:               "",
 		},
+		{
+			name:                 "with scheme and path",
+			strToParse:           "/skynet/skylink/sia://IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w/foo/bar?foobar=nope",
+			skylink:              "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w",
+			skylinkStringNoQuery: "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w/foo/bar",
+			path:                 "/foo/bar",
+			errMsg:               "",
+		},
+		{
+			name:                 "base32 with trailing slash",
+			strToParse:           "/skynet/skylink/400bk2i89lheb6d8olltc2grqgfaqfge1im134ed6q1ro0g0fbnk1to/",
+			skylink:              "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w",
+			skylinkStringNoQuery: "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w/",
+			path:                 "/",
+			errMsg:               "",
+		},
+		{
+			name:                 "percent-encoded skylink",
+			strToParse:           "/skynet/skylink/%49AC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w",
+			skylink:              "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w",
+			skylinkStringNoQuery: "IAC6CkhNYuWZqMVr1gob1B6tPg4MrBGRzTaDvAIAeu9A9w",
+			path:                 "/",
+			errMsg:               "",
+		},
 		{
 			name:                 "invalid skylink",
 			strToParse:           "invalid_skylink/foo/bar?foobar=nope",
@@ -387,6 +484,27 @@ func testParseUploadRequestParameters(t *testing.T) {
 		t.Fatal("Unexpected")
 	}
 
+	// verify 'Skynet-Force-Token' - combo with 'Skynet-Disable-Force' and
+	// 'force'
+	token := skymodules.SkynetForceToken{SiaPath: siapath.String(), Expiry: 1}
+	tokenStr, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = buildRequest(url.Values{"force": trueStr}, http.Header{SkynetDisableForceHeader: trueStr, SkynetForceTokenHeader: []string{string(tokenStr)}})
+	headers, _, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if headers.forceToken == nil || *headers.forceToken != token {
+		t.Fatal("Unexpected")
+	}
+	req = buildRequest(url.Values{}, http.Header{SkynetForceTokenHeader: []string{"invalid"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+
 	// verify 'Content-Type'
 	req = buildRequest(url.Values{}, http.Header{"Content-Type": []string{"text/html"}})
 	headers, _, err = parseRequest(req, defaultParams)
@@ -574,6 +692,21 @@ func testParseUploadRequestParameters(t *testing.T) {
 		t.Fatal("Unexpected")
 	}
 
+	// verify 'modtime'
+	req = buildRequest(url.Values{"modtime": []string{"1600000000"}}, http.Header{"Content-type": []string{"text/html"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if params.modTime != 1600000000 {
+		t.Fatal("Unexpected")
+	}
+	req = buildRequest(url.Values{"modtime": []string{"-1"}}, http.Header{"Content-type": []string{"text/html"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+
 	// verify 'root'
 	req = buildRequest(url.Values{"root": trueStr}, http.Header{"Content-type": []string{"text/html"}})
 	_, params, err = parseRequest(req, defaultParams)
@@ -605,6 +738,108 @@ func testParseUploadRequestParameters(t *testing.T) {
 		t.Fatal("Unexpected")
 	}
 
+	// verify 'redirect'
+	req = buildRequest(url.Values{"redirect": trueStr}, http.Header{"Content-type": []string{"text/plain"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !params.redirect {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'redirect' - combo with 'convertpath' and multipart uploads
+	req = buildRequest(url.Values{"redirect": trueStr, "convertpath": []string{"foo/bar"}}, http.Header{"Content-type": []string{"text/plain"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+	req = buildRequest(url.Values{"redirect": trueStr}, http.Header{"Content-type": []string{"multipart/form-data"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'skylinkhint'
+	req = buildRequest(url.Values{"skylinkhint": trueStr}, http.Header{"Content-type": []string{"text/html"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !params.skylinkHint {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'skylinkhint' - combo with 'convertpath'
+	req = buildRequest(url.Values{"skylinkhint": trueStr, "convertpath": []string{"foo/bar"}}, http.Header{"Content-type": []string{"text/html"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'sparse'
+	req = buildRequest(url.Values{"sparse": trueStr}, http.Header{"Content-type": []string{"text/html"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !params.sparse {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'sparse' - combos with 'convertpath' and 'skykeyname'
+	for _, values := range []url.Values{
+		{"sparse": trueStr, "convertpath": []string{"foo/bar"}},
+		{"sparse": trueStr, "skykeyname": []string{"foo"}},
+	} {
+		req = buildRequest(values, http.Header{"Content-type": []string{"text/html"}})
+		_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+		if err == nil {
+			t.Fatal("Unexpected", values)
+		}
+	}
+
+	// verify 'include-metadata'
+	req = buildRequest(url.Values{"include-metadata": trueStr}, http.Header{"Content-type": []string{"text/html"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if !params.includeMetadata {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'include-metadata' - combo with 'async'
+	req = buildRequest(url.Values{"include-metadata": trueStr, "async": trueStr, "convertpath": []string{"foo/bar"}}, http.Header{"Content-type": []string{"text/html"}})
+	_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+	if err == nil {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'ifexists'
+	req = buildRequest(url.Values{"ifexists": []string{"return"}}, http.Header{"Content-type": []string{"text/html"}})
+	_, params, err = parseRequest(req, defaultParams)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if params.ifExists != skyfileIfExistsReturn {
+		t.Fatal("Unexpected")
+	}
+
+	// verify 'ifexists' - unknown value and combos with 'force' and
+	// 'convertpath'
+	for _, values := range []url.Values{
+		{"ifexists": []string{"overwrite"}},
+		{"ifexists": []string{"return"}, "force": trueStr},
+		{"ifexists": []string{"return"}, "convertpath": []string{"foo/bar"}},
+	} {
+		req = buildRequest(values, http.Header{"Content-type": []string{"text/html"}})
+		_, _, err = parseUploadHeadersAndRequestParameters(req, defaultParams)
+		if err == nil {
+			t.Fatal("Unexpected", values)
+		}
+	}
+
 	// create a test skykey
 	km, err := skykey.NewSkykeyManager(build.TempDir("skykey", t.Name()))
 	if err != nil {
@@ -687,7 +922,7 @@ func testParseDownloadRequestParameters(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	sdp, err := parseDownloadRequestParameters(req)
+	sdp, err := parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -704,7 +939,7 @@ func testParseDownloadRequestParameters(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	sdp, err = parseDownloadRequestParameters(req)
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -722,7 +957,7 @@ func testParseDownloadRequestParameters(t *testing.T) {
 		if err != nil {
 			return err
 		}
-		sdp, err = parseDownloadRequestParameters(req)
+		sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 		if err != nil {
 			return err
 		}
@@ -735,7 +970,7 @@ func testParseDownloadRequestParameters(t *testing.T) {
 		}
 		return nil
 	}
-	formats := []skymodules.SkyfileFormat{skymodules.SkyfileFormatNotSpecified, skymodules.SkyfileFormatConcat, skymodules.SkyfileFormatTar, skymodules.SkyfileFormatTarGz, skymodules.SkyfileFormatZip}
+	formats := []skymodules.SkyfileFormat{skymodules.SkyfileFormatNotSpecified, skymodules.SkyfileFormatConcat, skymodules.SkyfileFormatDataURI, skymodules.SkyfileFormatTar, skymodules.SkyfileFormatTarGz, skymodules.SkyfileFormatZip}
 	for _, format := range formats {
 		err = formatTest(format)
 		if err != nil {
@@ -748,7 +983,7 @@ func testParseDownloadRequestParameters(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	sdp, err = parseDownloadRequestParameters(req)
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -760,130 +995,358 @@ func testParseDownloadRequestParameters(t *testing.T) {
 		t.Fatal("unexpected")
 	}
 
-	// Test timeout
-	var timeoutInt int = 100
-	timeout := time.Duration(timeoutInt) * time.Second
-	timeoutStr := []string{fmt.Sprintf("%d", timeoutInt)}
-	req, err = buildRequest(url.Values{"timeout": timeoutStr}, http.Header{"Content-type": []string{"text/html"}})
+	// Test include bandwidth
+	req, err = buildRequest(url.Values{"include-bandwidth": trueStr}, http.Header{"Content-type": []string{"text/html"}})
 	if err != nil {
 		t.Fatal(err)
 	}
-	sdp, err = parseDownloadRequestParameters(req)
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
 	expected = baseParams()
-	expected.timeout = timeout
+	expected.includeBandwidth = true
 	if !reflect.DeepEqual(sdp, expected) {
 		t.Log("skyfileDownloadParams", sdp)
 		t.Log("expected", expected)
 		t.Fatal("unexpected")
 	}
-
-	// Test pricePerMS
-	pricePerMS := skymodules.DefaultSkynetPricePerMS
-	pricePerMSStr := "1000"
-	_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+	req, err = buildRequest(url.Values{"include-bandwidth": []string{"maybe"}}, http.Header{"Content-type": []string{"text/html"}})
 	if err != nil {
 		t.Fatal(err)
 	}
-	req, err = buildRequest(url.Values{"priceperms": []string{pricePerMSStr}}, http.Header{"Content-type": []string{"text/html"}})
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'include-bandwidth' parameter") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Test no-redirect
+	req, err = buildRequest(url.Values{"no-redirect": trueStr}, http.Header{"Content-type": []string{"text/html"}})
 	if err != nil {
 		t.Fatal(err)
 	}
-	sdp, err = parseDownloadRequestParameters(req)
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
 	expected = baseParams()
-	expected.pricePerMS = pricePerMS
+	expected.noRedirect = true
 	if !reflect.DeepEqual(sdp, expected) {
 		t.Log("skyfileDownloadParams", sdp)
 		t.Log("expected", expected)
 		t.Fatal("unexpected")
 	}
+	req, err = buildRequest(url.Values{"no-redirect": []string{"maybe"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'no-redirect' parameter") {
+		t.Fatal("unexpected error", err)
+	}
 
-	// Test range params
-	var rangeTests = []struct {
-		start     string
-		end       string
-		setHeader bool
-		err       error
-	}{
-		// Happy Cases
-		{"0", "0", false, nil}, // start = end, no header set
-		{"1", "1", false, nil}, // start = end, non zero, no header set
-		{"1", "5", false, nil}, // start < end,  no header set
+	// Test verify
+	req, err = buildRequest(url.Values{"verify": trueStr}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.verify = true
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	req, err = buildRequest(url.Values{"verify": []string{"maybe"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'verify' parameter") {
+		t.Fatal("unexpected error", err)
+	}
 
-		// Error Cases
-		{"0", "0", true, errRangeSetTwice},          // start = end, header set
-		{"1", "1", true, errRangeSetTwice},          // start = end, non zero, header set
-		{"1", "5", true, errRangeSetTwice},          // start < end,  header set
-		{"1", "0", false, errInvalidRangeParams},    // start > end, no header set
-		{"1", "0", true, errRangeSetTwice},          // start > end, header set
-		{"", "0", false, errIncompleteRangeRequest}, // start not set, header not set
-		{"", "0", true, errIncompleteRangeRequest},  // start not set, header set
-		{"0", "", false, errIncompleteRangeRequest}, // end not set, header not set
-		{"0", "", true, errIncompleteRangeRequest},  // end not set, header set
+	// Test preview
+	req, err = buildRequest(url.Values{"preview": []string{"100"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, rt := range rangeTests {
-		// Set url values
-		values := url.Values{
-			"start": []string{rt.start},
-			"end":   []string{rt.end},
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.preview = 100
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	for _, invalid := range []string{"0", "-1", "maybe"} {
+		req, err = buildRequest(url.Values{"preview": []string{invalid}}, http.Header{"Content-type": []string{"text/html"}})
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		// Check if Header should be set
-		var headers http.Header
-		if rt.setHeader {
-			rangeStr := fmt.Sprintf("bytes=%s-%s", rt.start, rt.end)
-			headers = http.Header{"Range": []string{rangeStr}}
+		_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+		if err == nil || !strings.Contains(err.Error(), "'preview' parameter") {
+			t.Fatal("unexpected error", invalid, err)
 		}
-
-		req, err = buildRequest(values, headers)
+	}
+	for _, values := range []url.Values{
+		{"preview": []string{"100"}, "format": []string{"zip"}},
+		{"preview": []string{"100"}, "verify": trueStr},
+		{"preview": []string{"100"}, "allow-partial": trueStr},
+		{"preview": []string{"100"}, "start": []string{"0"}, "end": []string{"10"}},
+	} {
+		req, err = buildRequest(values, http.Header{"Content-type": []string{"text/html"}})
 		if err != nil {
 			t.Fatal(err)
 		}
-		sdp, err = parseDownloadRequestParameters(req)
-		if err != rt.err {
-			t.Log("Test Case: ", rt)
-			t.Fatalf("Expected error '%v' but got '%v'", rt.err, err)
+		_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+		if !errors.Contains(err, errPreviewIncompatible) {
+			t.Fatal("unexpected error", values, err)
 		}
 	}
-}
-
-// TestAttachRegistryEntryProof is a unit test for attachRegistryEntryProof.
-func TestAttachRegistryEntryProof(t *testing.T) {
-	var h1 crypto.Hash
-	fastrand.Read(h1[:])
-	var h2 crypto.Hash
-	fastrand.Read(h2[:])
-	entries := []skymodules.RegistryEntry{
-		{
-			SignedRegistryValue: modules.NewSignedRegistryValue(h1, fastrand.Bytes(10), fastrand.Uint64n(100), crypto.Signature{}, modules.RegistryTypeWithoutPubkey),
-		},
-		{
-			SignedRegistryValue: modules.NewSignedRegistryValue(h2, fastrand.Bytes(10), fastrand.Uint64n(100), crypto.Signature{}, modules.RegistryTypeWithoutPubkey),
-		},
+	req, err = buildRequest(url.Values{"preview": []string{"100"}}, http.Header{"Range": []string{"bytes=0-10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if !errors.Contains(err, errPreviewIncompatible) {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Create the proof manually.
-	proofChain := make([]RegistryHandlerGET, 0, len(entries))
-	for _, srv := range entries {
-		proofChain = append(proofChain, RegistryHandlerGET{
-			Data:      hex.EncodeToString(srv.Data),
-			DataKey:   srv.Tweak,
-			Revision:  srv.Revision,
-			PublicKey: srv.PubKey,
-			Signature: hex.EncodeToString(srv.Signature[:]),
-			Type:      srv.Type,
-		})
+	// Test sign-response
+	req, err = buildRequest(url.Values{"sign-response": trueStr}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
 	}
-	expectedProof, err := json.Marshal(proofChain)
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
-
+	expected = baseParams()
+	expected.signResponse = true
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	req, err = buildRequest(url.Values{"sign-response": []string{"maybe"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'sign-response' parameter") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Test contenttype
+	req, err = buildRequest(url.Values{"contenttype": []string{"Text/Plain; Charset=utf-8"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.contentType = "text/plain; charset=utf-8"
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	for _, invalid := range []string{"text", "text/", "/plain", "text/plain/html", "text/plain; charset", "<script>"} {
+		req, err = buildRequest(url.Values{"contenttype": []string{invalid}}, http.Header{"Content-type": []string{"text/html"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+		if err == nil || !strings.Contains(err.Error(), "unable to parse 'contenttype' parameter") {
+			t.Fatal("unexpected error", invalid, err)
+		}
+	}
+
+	// Test timeout
+	var timeoutInt int = 100
+	timeout := time.Duration(timeoutInt) * time.Second
+	timeoutStr := []string{fmt.Sprintf("%d", timeoutInt)}
+	req, err = buildRequest(url.Values{"timeout": timeoutStr}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.timeout = timeout
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+
+	// Test pricePerMS
+	pricePerMS := skymodules.DefaultSkynetPricePerMS
+	pricePerMSStr := "1000"
+	_, err = fmt.Sscan(pricePerMSStr, &pricePerMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = buildRequest(url.Values{"priceperms": []string{pricePerMSStr}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.pricePerMS = pricePerMS
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+
+	// Test retries
+	req, err = buildRequest(url.Values{"retries": []string{"3"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.retries = 3
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	req, err = buildRequest(url.Values{"retries": []string{fmt.Sprint(maxDownloadRetries + 1)}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if !errors.Contains(err, errTooManyRetries) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Test allow-partial
+	req, err = buildRequest(url.Values{"allow-partial": []string{"true"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = baseParams()
+	expected.allowPartial = true
+	if !reflect.DeepEqual(sdp, expected) {
+		t.Log("skyfileDownloadParams", sdp)
+		t.Log("expected", expected)
+		t.Fatal("unexpected")
+	}
+	req, err = buildRequest(url.Values{"allow-partial": []string{"maybe"}}, http.Header{"Content-type": []string{"text/html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'allow-partial' parameter") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Test range params
+	var rangeTests = []struct {
+		start     string
+		end       string
+		setHeader bool
+		err       error
+	}{
+		// Happy Cases
+		{"0", "0", false, nil}, // start = end, no header set
+		{"1", "1", false, nil}, // start = end, non zero, no header set
+		{"1", "5", false, nil}, // start < end,  no header set
+
+		// Error Cases
+		{"0", "0", true, errRangeSetTwice},          // start = end, header set
+		{"1", "1", true, errRangeSetTwice},          // start = end, non zero, header set
+		{"1", "5", true, errRangeSetTwice},          // start < end,  header set
+		{"1", "0", false, errInvalidRangeParams},    // start > end, no header set
+		{"1", "0", true, errRangeSetTwice},          // start > end, header set
+		{"", "0", false, errIncompleteRangeRequest}, // start not set, header not set
+		{"", "0", true, errIncompleteRangeRequest},  // start not set, header set
+		{"0", "", false, errIncompleteRangeRequest}, // end not set, header not set
+		{"0", "", true, errIncompleteRangeRequest},  // end not set, header set
+	}
+	for _, rt := range rangeTests {
+		// Set url values
+		values := url.Values{
+			"start": []string{rt.start},
+			"end":   []string{rt.end},
+		}
+
+		// Check if Header should be set
+		var headers http.Header
+		if rt.setHeader {
+			rangeStr := fmt.Sprintf("bytes=%s-%s", rt.start, rt.end)
+			headers = http.Header{"Range": []string{rangeStr}}
+		}
+
+		req, err = buildRequest(values, headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sdp, err = parseDownloadRequestParameters(req, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+		if err != rt.err {
+			t.Log("Test Case: ", rt)
+			t.Fatalf("Expected error '%v' but got '%v'", rt.err, err)
+		}
+	}
+}
+
+// TestAttachRegistryEntryProof is a unit test for attachRegistryEntryProof.
+func TestAttachRegistryEntryProof(t *testing.T) {
+	var h1 crypto.Hash
+	fastrand.Read(h1[:])
+	var h2 crypto.Hash
+	fastrand.Read(h2[:])
+	entries := []skymodules.RegistryEntry{
+		{
+			SignedRegistryValue: modules.NewSignedRegistryValue(h1, fastrand.Bytes(10), fastrand.Uint64n(100), crypto.Signature{}, modules.RegistryTypeWithoutPubkey),
+		},
+		{
+			SignedRegistryValue: modules.NewSignedRegistryValue(h2, fastrand.Bytes(10), fastrand.Uint64n(100), crypto.Signature{}, modules.RegistryTypeWithoutPubkey),
+		},
+	}
+
+	// Create the proof manually.
+	proofChain := make([]RegistryHandlerGET, 0, len(entries))
+	for _, srv := range entries {
+		proofChain = append(proofChain, RegistryHandlerGET{
+			Data:         hex.EncodeToString(srv.Data),
+			DataKey:      srv.Tweak,
+			Revision:     srv.Revision,
+			PublicKey:    srv.PubKey,
+			Signature:    hex.EncodeToString(srv.Signature[:]),
+			Type:         srv.Type,
+			DetectedType: RegistryEntryTypeWithoutPubkey,
+		})
+	}
+	expectedProof, err := json.Marshal(proofChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Attach the proof
 	w := newTestHTTPWriter()
 	header := w.Header()
@@ -1116,6 +1579,16 @@ func TestCustomErrorWriter(t *testing.T) {
 func TestParseTimeout(t *testing.T) {
 	t.Parallel()
 
+	// parseSkynetTimeout uses the default skynet timeouts while
+	// parseCustomTimeout uses custom ones.
+	customDefault, customMax := 10*time.Second, time.Minute
+	parseSkynetTimeout := func(values url.Values) (time.Duration, error) {
+		return parseTimeout(values, DefaultSkynetRequestTimeout, MaxSkynetRequestTimeout)
+	}
+	parseCustomTimeout := func(values url.Values) (time.Duration, error) {
+		return parseTimeout(values, customDefault, customMax)
+	}
+
 	tests := []struct {
 		name        string
 		timeout     string
@@ -1127,7 +1600,7 @@ func TestParseTimeout(t *testing.T) {
 		{
 			name:        "SkynetTimeout/Default",
 			timeout:     "",
-			timeoutFunc: parseTimeout,
+			timeoutFunc: parseSkynetTimeout,
 
 			result: DefaultSkynetRequestTimeout,
 			err:    nil,
@@ -1135,7 +1608,7 @@ func TestParseTimeout(t *testing.T) {
 		{
 			name:        "SkynetTimeout/Zero",
 			timeout:     "0",
-			timeoutFunc: parseTimeout,
+			timeoutFunc: parseSkynetTimeout,
 
 			result: 0,
 			err:    errZeroTimeout,
@@ -1143,7 +1616,7 @@ func TestParseTimeout(t *testing.T) {
 		{
 			name:        "SkynetTimeout/Max",
 			timeout:     fmt.Sprint(MaxSkynetRequestTimeout.Seconds()),
-			timeoutFunc: parseTimeout,
+			timeoutFunc: parseSkynetTimeout,
 
 			result: MaxSkynetRequestTimeout,
 			err:    nil,
@@ -1151,7 +1624,31 @@ func TestParseTimeout(t *testing.T) {
 		{
 			name:        "SkynetTimeout/AboveMax",
 			timeout:     fmt.Sprint(MaxSkynetRequestTimeout.Seconds() + 1),
-			timeoutFunc: parseTimeout,
+			timeoutFunc: parseSkynetTimeout,
+
+			result: 0,
+			err:    errTimeoutTooHigh,
+		},
+		{
+			name:        "CustomTimeout/Default",
+			timeout:     "",
+			timeoutFunc: parseCustomTimeout,
+
+			result: customDefault,
+			err:    nil,
+		},
+		{
+			name:        "CustomTimeout/Max",
+			timeout:     fmt.Sprint(customMax.Seconds()),
+			timeoutFunc: parseCustomTimeout,
+
+			result: customMax,
+			err:    nil,
+		},
+		{
+			name:        "CustomTimeout/AboveMax",
+			timeout:     fmt.Sprint(customMax.Seconds() + 1),
+			timeoutFunc: parseCustomTimeout,
 
 			result: 0,
 			err:    errTimeoutTooHigh,
@@ -1207,3 +1704,763 @@ func TestParseTimeout(t *testing.T) {
 		})
 	}
 }
+
+// TestChecksumResponseWriter is a unit test for the checksumResponseWriter.
+func TestChecksumResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	w := newTestHTTPWriter()
+	w.Header().Set("Content-Length", "100")
+	cw := newChecksumResponseWriter(w, sha256.New(), SkynetChecksumTrailer)
+	if w.Header().Get("Trailer") != SkynetChecksumTrailer {
+		t.Fatal("trailer wasn't declared", w.Header())
+	}
+
+	// Write some data.
+	data := fastrand.Bytes(100)
+	n, err := cw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Fatal("wrong number of bytes written", n)
+	}
+	if w.statusCode != http.StatusOK {
+		t.Fatal("unexpected status code", w.statusCode)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Fatal("content length should have been removed")
+	}
+
+	// Attach the checksum.
+	cw.AttachChecksum()
+	checksum := sha256.Sum256(data)
+	if w.Header().Get(SkynetChecksumTrailer) != hex.EncodeToString(checksum[:]) {
+		t.Fatal("wrong checksum", w.Header().Get(SkynetChecksumTrailer))
+	}
+}
+
+// testHostStatsStreamer is a helper type which implements the
+// SkyfileHostStatsStreamer interface.
+type testHostStatsStreamer []skymodules.SkynetHostStats
+
+// HostStats implements the SkyfileHostStatsStreamer interface.
+func (s testHostStatsStreamer) HostStats() []skymodules.SkynetHostStats {
+	return s
+}
+
+// TestBandwidthResponseWriter is a unit test for the bandwidthResponseWriter.
+func TestBandwidthResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	w := newTestHTTPWriter()
+	w.Header().Set("Content-Length", "100")
+	streamer := testHostStatsStreamer{{Bandwidth: 100}, {Bandwidth: 50}, {}}
+	bw := newBandwidthResponseWriter(w, streamer)
+	if w.Header().Get("Trailer") != SkynetBandwidthUsedTrailer {
+		t.Fatal("trailer wasn't declared", w.Header())
+	}
+
+	// Write some data.
+	data := fastrand.Bytes(100)
+	n, err := bw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Fatal("wrong number of bytes written", n)
+	}
+	if w.statusCode != http.StatusOK {
+		t.Fatal("unexpected status code", w.statusCode)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Fatal("content length should have been removed")
+	}
+
+	// Attach the bandwidth.
+	bw.AttachBandwidthUsed()
+	if w.Header().Get(SkynetBandwidthUsedTrailer) != "150" {
+		t.Fatal("wrong bandwidth", w.Header().Get(SkynetBandwidthUsedTrailer))
+	}
+}
+
+// testDegradedStreamer is a helper type which implements the
+// SkyfileDegradedStreamer interface.
+type testDegradedStreamer bool
+
+// Degraded implements the SkyfileDegradedStreamer interface.
+func (s testDegradedStreamer) Degraded() bool {
+	return bool(s)
+}
+
+// TestDegradedResponseWriter is a unit test for the degradedResponseWriter.
+func TestDegradedResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	for _, degraded := range []bool{false, true} {
+		w := newTestHTTPWriter()
+		dw := newDegradedResponseWriter(w, testDegradedStreamer(degraded))
+		if w.Header().Get(SkynetDegradedHeader) != "" {
+			t.Fatal("header shouldn't be set before writing")
+		}
+
+		// Write some data.
+		data := fastrand.Bytes(100)
+		n, err := dw.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(data) {
+			t.Fatal("wrong number of bytes written", n)
+		}
+		if w.statusCode != http.StatusOK {
+			t.Fatal("unexpected status code", w.statusCode)
+		}
+		if isSet := w.Header().Get(SkynetDegradedHeader) == "true"; isSet != degraded {
+			t.Fatal("unexpected header", degraded, w.Header())
+		}
+	}
+}
+
+// TestMaxUploadSizeReader is a unit test for the maxUploadSizeReader.
+func TestMaxUploadSizeReader(t *testing.T) {
+	t.Parallel()
+
+	data := fastrand.Bytes(100)
+
+	// Reading data up to the max size should work.
+	for _, maxSize := range []uint64{100, 101, 1000} {
+		r := newMaxUploadSizeReader(ioutil.NopCloser(bytes.NewReader(data)), maxSize)
+		read, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(read, data) {
+			t.Fatal("data mismatch")
+		}
+	}
+
+	// Reading more than the max size should fail.
+	for _, maxSize := range []uint64{1, 50, 99} {
+		r := newMaxUploadSizeReader(ioutil.NopCloser(bytes.NewReader(data)), maxSize)
+		read, err := ioutil.ReadAll(r)
+		if !errors.Contains(err, ErrSkyfileUploadTooLarge) {
+			t.Fatal("unexpected error", err)
+		}
+		if uint64(len(read)) != maxSize {
+			t.Fatal("wrong number of bytes read", len(read))
+		}
+		if !strings.Contains(err.Error(), fmt.Sprint(maxSize)) {
+			t.Fatal("error should contain the limit", err)
+		}
+	}
+}
+
+// TestReadRedirectSkylink is a unit test for readRedirectSkylink.
+func TestReadRedirectSkylink(t *testing.T) {
+	t.Parallel()
+
+	skylink := "_B19BtlWtjjR7AD0DDzxYanvIhZ7cxXrva5tNNxDht1kaA"
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{skylink, true},
+		{"sia://" + skylink, true},
+		{" " + skylink + "\n", true},
+		{"", false},
+		{"notaskylink", false},
+		{skylink + strings.Repeat(" ", maxRedirectSkylinkSize), false},
+	}
+	for _, test := range tests {
+		sl, err := readRedirectSkylink(strings.NewReader(test.body))
+		if test.valid && (err != nil || sl != skylink) {
+			t.Fatalf("%q: unexpected result %v %v", test.body, sl, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatalf("%q: expected error", test.body)
+		}
+	}
+}
+
+// TestReadPinManifest is a unit test for readPinManifest.
+func TestReadPinManifest(t *testing.T) {
+	t.Parallel()
+
+	// manifestLines is a helper to create the expected lines of a manifest
+	// with n lines of "a".
+	manifestLines := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = "a"
+		}
+		return lines
+	}
+
+	tests := []struct {
+		manifest string
+		lines    []string
+		valid    bool
+	}{
+		{"", nil, true},
+		{"a\nb", []string{"a", "b"}, true},
+		{" a \r\n\n\tb\n\n", []string{"a", "b"}, true},
+		{"a\na\n", []string{"a", "a"}, true},
+		{strings.Repeat("a", maxPinManifestLineSize-1) + "\n", []string{strings.Repeat("a", maxPinManifestLineSize-1)}, true},
+		{"a\n" + strings.Repeat("a", maxPinManifestLineSize), nil, false},
+		{strings.Repeat("a\n\n", maxPinManifestEntries), manifestLines(maxPinManifestEntries), true},
+		{strings.Repeat("a\n", maxPinManifestEntries+1), nil, false},
+	}
+	for i, test := range tests {
+		lines, err := readPinManifest(strings.NewReader(test.manifest))
+		if test.valid && err != nil {
+			t.Fatal(i, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatal(i, "expected error")
+		}
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Fatal(i, "wrong lines", lines, test.lines)
+		}
+	}
+}
+
+// TestRegistryBatchResult is a unit test for registryBatchResult.
+func TestRegistryBatchResult(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err        error
+		success    bool
+		statusCode int
+		errorCode  string
+	}{
+		{nil, true, http.StatusOK, ""},
+		{modules.ErrLowerRevNum, false, http.StatusBadRequest, RegistryBatchErrorLowerRevNum},
+		{modules.ErrSameRevNum, false, http.StatusBadRequest, RegistryBatchErrorSameRevNum},
+		{modules.ErrInsufficientWork, false, http.StatusBadRequest, RegistryBatchErrorInsufficientWork},
+		{renter.ErrRegistryUpdateTimeout, false, http.StatusRequestTimeout, RegistryBatchErrorTimeout},
+		{errors.New("unknown"), false, http.StatusInternalServerError, RegistryBatchErrorUnknown},
+	}
+	for _, test := range tests {
+		result := registryBatchResult(errors.AddContext(test.err, "context"))
+		if result.Success != test.success || result.StatusCode != test.statusCode || result.ErrorCode != test.errorCode {
+			t.Fatalf("unexpected result for '%v': %+v", test.err, result)
+		}
+		if test.err != nil && !strings.Contains(result.Error, test.err.Error()) {
+			t.Fatalf("unexpected error '%v'", result.Error)
+		}
+	}
+}
+
+// TestValidateRegistryEntryData is a unit test for
+// validateRegistryEntryData.
+func TestValidateRegistryEntryData(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		entryType modules.RegistryEntryType
+		dataLen   int
+		errorCode string
+	}{
+		// Entries without pubkey can contain any data up to the max size.
+		{modules.RegistryTypeWithoutPubkey, 0, ""},
+		{modules.RegistryTypeWithoutPubkey, modules.RegistryPubKeyHashSize - 1, ""},
+		{modules.RegistryTypeWithoutPubkey, modules.RegistryDataSize, ""},
+		{modules.RegistryTypeWithoutPubkey, modules.RegistryDataSize + 1, RegistryBatchErrorDataTooBig},
+
+		// Entries with pubkey need to contain at least the pubkey hash.
+		{modules.RegistryTypeWithPubkey, 0, RegistryBatchErrorMalformedData},
+		{modules.RegistryTypeWithPubkey, modules.RegistryPubKeyHashSize - 1, RegistryBatchErrorMalformedData},
+		{modules.RegistryTypeWithPubkey, modules.RegistryPubKeyHashSize, ""},
+		{modules.RegistryTypeWithPubkey, modules.RegistryDataSize, ""},
+		{modules.RegistryTypeWithPubkey, modules.RegistryDataSize + 1, RegistryBatchErrorDataTooBig},
+
+		// Unknown types are rejected regardless of the data.
+		{modules.RegistryTypeInvalid, 10, RegistryBatchErrorUnknownType},
+		{modules.RegistryTypeWithPubkey + 1, 10, RegistryBatchErrorUnknownType},
+		{modules.RegistryEntryType(255), modules.RegistryDataSize + 1, RegistryBatchErrorUnknownType},
+	}
+	for _, test := range tests {
+		errorCode, err := validateRegistryEntryData(test.entryType, fastrand.Bytes(test.dataLen))
+		if errorCode != test.errorCode || (err == nil) != (test.errorCode == "") {
+			t.Fatalf("unexpected result for type %v and %v bytes: %v %v", test.entryType, test.dataLen, errorCode, err)
+		}
+	}
+
+	// The error for data that is too big contains the allowed size.
+	_, err := validateRegistryEntryData(modules.RegistryTypeWithoutPubkey, fastrand.Bytes(modules.RegistryDataSize+1))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%v > %v", modules.RegistryDataSize+1, modules.RegistryDataSize)) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestNewRegistryHandlerGET is a unit test for newRegistryHandlerGET.
+func TestNewRegistryHandlerGET(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		entryType    modules.RegistryEntryType
+		dataLen      int
+		detectedType string
+		primaryWork  bool
+	}{
+		{modules.RegistryTypeInvalid, 10, RegistryEntryTypeInvalid, false},
+		{modules.RegistryTypeWithoutPubkey, 30, RegistryEntryTypeWithoutPubkey, false},
+		{modules.RegistryTypeWithPubkey, 30, RegistryEntryTypeWithPubkey, true},
+		{modules.RegistryTypeWithPubkey, 10, RegistryEntryTypeWithPubkey, false},
+		{modules.RegistryTypeWithPubkey + 1, 30, RegistryEntryTypeUnknown, false},
+	}
+	for _, test := range tests {
+		var dataKey crypto.Hash
+		fastrand.Read(dataKey[:])
+		data := fastrand.Bytes(test.dataLen)
+		srv := skymodules.RegistryEntry{
+			SignedRegistryValue: modules.NewSignedRegistryValue(dataKey, data, 1, crypto.Signature{}, test.entryType),
+		}
+		rhg := newRegistryHandlerGET(srv)
+		if rhg.DetectedType != test.detectedType || rhg.PrimaryWork != test.primaryWork {
+			t.Fatalf("unexpected result for type %v: %+v", test.entryType, rhg)
+		}
+		if rhg.Data != hex.EncodeToString(data) || rhg.DataKey != dataKey || rhg.Type != test.entryType {
+			t.Fatal("unexpected entry", rhg)
+		}
+	}
+}
+
+// TestNewSkynetWorkersGET is a unit test for newSkynetWorkersGET.
+func TestNewSkynetWorkersGET(t *testing.T) {
+	t.Parallel()
+
+	// Create a pool with one usable worker and two workers which are on a
+	// cooldown.
+	usable := skymodules.WorkerStatus{
+		ContractUtility: skymodules.ContractUtility{GoodForUpload: true},
+		AccountStatus: skymodules.WorkerAccountStatus{
+			AvailableBalance: types.NewCurrency64(10),
+		},
+	}
+	downloadCD := skymodules.WorkerStatus{
+		ContractUtility:    skymodules.ContractUtility{GoodForUpload: true},
+		DownloadOnCoolDown: true,
+		AccountStatus: skymodules.WorkerAccountStatus{
+			AvailableBalance: types.NewCurrency64(5),
+			NegativeBalance:  types.NewCurrency64(1),
+		},
+	}
+	maintenanceCD := skymodules.WorkerStatus{
+		ContractUtility:       skymodules.ContractUtility{GoodForUpload: true},
+		MaintenanceOnCooldown: true,
+	}
+	wps := skymodules.WorkerPoolStatus{
+		NumWorkers:               3,
+		TotalDownloadCoolDown:    1,
+		TotalMaintenanceCoolDown: 1,
+		Workers:                  []skymodules.WorkerStatus{usable, downloadCD, maintenanceCD},
+	}
+
+	swg := newSkynetWorkersGET(wps)
+	if swg.NumWorkers != 3 || swg.TotalDownloadCoolDown != 1 || swg.TotalMaintenanceCoolDown != 1 || swg.TotalUploadCoolDown != 0 {
+		t.Fatal("unexpected totals", swg)
+	}
+	if swg.NumDownloadWorkers != 1 {
+		t.Fatal("wrong number of download workers", swg.NumDownloadWorkers)
+	}
+	if swg.NumUploadWorkers != 2 {
+		t.Fatal("wrong number of upload workers", swg.NumUploadWorkers)
+	}
+	if !swg.TotalAvailableBalance.Equals64(15) || !swg.TotalNegativeBalance.Equals64(1) {
+		t.Fatal("wrong balances", swg.TotalAvailableBalance, swg.TotalNegativeBalance)
+	}
+	if len(swg.Workers) != 3 {
+		t.Fatal("wrong number of workers", len(swg.Workers))
+	}
+	if !swg.Workers[0].DownloadUsable || !swg.Workers[0].UploadUsable {
+		t.Fatal("first worker should be usable")
+	}
+	if swg.Workers[1].DownloadUsable || !swg.Workers[1].UploadUsable {
+		t.Fatal("second worker should only be usable for uploads")
+	}
+	if swg.Workers[2].DownloadUsable || swg.Workers[2].UploadUsable {
+		t.Fatal("third worker shouldn't be usable")
+	}
+}
+
+// TestDownloadWithRetries is a unit test for downloadWithRetries.
+func TestDownloadWithRetries(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("host unavailable")
+
+	// newDownload returns a download func which fails with the given errors
+	// before succeeding and a pointer to the number of attempts.
+	newDownload := func(errs ...error) (func() error, *int) {
+		var attempts int
+		return func() error {
+			attempts++
+			if len(errs) == 0 {
+				return nil
+			}
+			err := errs[0]
+			errs = errs[1:]
+			return err
+		}, &attempts
+	}
+
+	// No retries.
+	download, attempts := newDownload(errTransient)
+	err := downloadWithRetries(context.Background(), 0, download)
+	if !errors.Contains(err, errTransient) || *attempts != 1 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+
+	// Succeed after two transient errors.
+	download, attempts = newDownload(errTransient, errTransient)
+	err = downloadWithRetries(context.Background(), 2, download)
+	if err != nil || *attempts != 3 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+
+	// Give up after running out of retries.
+	download, attempts = newDownload(errTransient, errTransient, errTransient)
+	err = downloadWithRetries(context.Background(), 1, download)
+	if !errors.Contains(err, errTransient) || *attempts != 2 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+
+	// Permanent errors are not retried.
+	download, attempts = newDownload(renter.ErrSkylinkBlocked)
+	err = downloadWithRetries(context.Background(), 5, download)
+	if !errors.Contains(err, renter.ErrSkylinkBlocked) || *attempts != 1 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+
+	// A closed context stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	download, attempts = newDownload(errTransient, errTransient)
+	err = downloadWithRetries(ctx, 5, download)
+	if !errors.Contains(err, context.Canceled) || *attempts != 1 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+}
+
+// TestDiffSkyfileSubfiles is a unit test for diffSkyfileSubfiles.
+func TestDiffSkyfileSubfiles(t *testing.T) {
+	t.Parallel()
+
+	from := skymodules.SkyfileSubfiles{
+		"index.html": {Filename: "index.html", ContentType: "text/html", Len: 10},
+		"app.js":     {Filename: "app.js", ContentType: "text/javascript", Len: 20},
+		"logo.png":   {Filename: "logo.png", ContentType: "image/png", Len: 30},
+		"about.html": {Filename: "about.html", ContentType: "text/html", Len: 40},
+	}
+	to := skymodules.SkyfileSubfiles{
+		"index.html": {Filename: "index.html", ContentType: "text/html", Len: 10, Offset: 100},
+		"app.js":     {Filename: "app.js", ContentType: "text/javascript", Len: 25},
+		"logo.png":   {Filename: "logo.png", ContentType: "image/svg+xml", Len: 30},
+		"b.css":      {Filename: "b.css", ContentType: "text/css", Len: 50},
+		"a.css":      {Filename: "a.css", ContentType: "text/css", Len: 60},
+	}
+
+	diff := diffSkyfileSubfiles(from, to)
+	if len(diff.Added) != 2 || diff.Added[0].Filename != "a.css" || diff.Added[1].Filename != "b.css" {
+		t.Fatal("unexpected added files", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Filename != "about.html" {
+		t.Fatal("unexpected removed files", diff.Removed)
+	}
+	expectedChanged := []SkynetSubfileDiff{
+		{Filename: "app.js", OldLen: 20, NewLen: 25, OldContentType: "text/javascript", NewContentType: "text/javascript"},
+		{Filename: "logo.png", OldLen: 30, NewLen: 30, OldContentType: "image/png", NewContentType: "image/svg+xml"},
+	}
+	if !reflect.DeepEqual(diff.Changed, expectedChanged) {
+		t.Fatal("unexpected changed files", diff.Changed)
+	}
+
+	// Diffing a set of subfiles with itself should result in an empty diff.
+	diff = diffSkyfileSubfiles(from, from)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Fatal("expected empty diff", diff)
+	}
+
+	// Skyfiles without subfiles are treated as a single subfile.
+	md := skymodules.SkyfileMetadata{Filename: "file", Length: 100}
+	diff = diffSkyfileSubfiles(skyfileSubfiles(md), to)
+	if len(diff.Removed) != 1 || diff.Removed[0].Filename != "file" || diff.Removed[0].Len != 100 {
+		t.Fatal("unexpected removed files", diff.Removed)
+	}
+	if len(diff.Added) != len(to) {
+		t.Fatal("unexpected added files", diff.Added)
+	}
+}
+
+// TestSkyfileManifest is a unit test for skyfileManifest.
+func TestSkyfileManifest(t *testing.T) {
+	t.Parallel()
+
+	data := fastrand.Bytes(100)
+	md := skymodules.SkyfileMetadata{
+		Filename: "dir",
+		Length:   uint64(len(data)),
+		Subfiles: skymodules.SkyfileSubfiles{
+			"b.html": skymodules.SkyfileSubfileMetadata{Filename: "b.html", ContentType: "text/html", Offset: 10, Len: 90, FileMode: 0644},
+			"a.txt":  skymodules.SkyfileSubfileMetadata{Filename: "a.txt", ContentType: "text/plain", Offset: 0, Len: 10},
+			"empty":  skymodules.SkyfileSubfileMetadata{Filename: "empty", Offset: 100},
+		},
+	}
+	sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(data), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamer := renter.SkylinkStreamerFromSlice(data, md, nil, sl, skymodules.SkyfileLayout{})
+
+	// Without hashes.
+	manifest, err := skyfileManifest(streamer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Skylink != sl.String() || len(manifest.Subfiles) != 3 {
+		t.Fatal("unexpected manifest", manifest)
+	}
+	expected := []SkynetManifestSubfile{
+		{Path: "a.txt", Length: 10, Offset: 0, ContentType: "text/plain"},
+		{Path: "b.html", Length: 90, Offset: 10, ContentType: "text/html", Mode: 0644},
+		{Path: "empty", Length: 0, Offset: 100},
+	}
+	if !reflect.DeepEqual(manifest.Subfiles, expected) {
+		t.Fatal("unexpected subfiles", manifest.Subfiles)
+	}
+
+	// With hashes.
+	manifest, err = skyfileManifest(streamer, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := []crypto.Hash{crypto.HashBytes(data[:10]), crypto.HashBytes(data[10:]), crypto.HashBytes(nil)}
+	for i, sf := range manifest.Subfiles {
+		if sf.Hash == nil || *sf.Hash != hashes[i] {
+			t.Fatalf("%v: wrong hash %v", sf.Path, sf.Hash)
+		}
+	}
+
+	// A single file without subfiles is listed as a single subfile.
+	md = skymodules.SkyfileMetadata{Filename: "file", Length: uint64(len(data))}
+	streamer = renter.SkylinkStreamerFromSlice(data, md, nil, sl, skymodules.SkyfileLayout{})
+	manifest, err = skyfileManifest(streamer, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Subfiles) != 1 || manifest.Subfiles[0].Path != "file" || *manifest.Subfiles[0].Hash != crypto.HashBytes(data) {
+		t.Fatal("unexpected manifest", manifest)
+	}
+
+	// A subfile which exceeds the data results in an error.
+	md.Subfiles = skymodules.SkyfileSubfiles{
+		"file": skymodules.SkyfileSubfileMetadata{Filename: "file", Len: 200},
+	}
+	streamer = renter.SkylinkStreamerFromSlice(data, md, nil, sl, skymodules.SkyfileLayout{})
+	_, err = skyfileManifest(streamer, true)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestMissingRanges is a unit test for relativeMissingRanges and
+// omitMissingSubfiles.
+func TestMissingRanges(t *testing.T) {
+	t.Parallel()
+
+	// Two missing ranges, one before and one within the content at offset
+	// 100 with a size of 100.
+	ranges := []skymodules.SkynetMissingRange{
+		{Offset: 0, Length: 110},
+		{Offset: 150, Length: 10},
+		{Offset: 300, Length: 10},
+	}
+	relative := relativeMissingRanges(ranges, 100, 100)
+	expected := []skymodules.SkynetMissingRange{
+		{Offset: 0, Length: 10},
+		{Offset: 50, Length: 10},
+	}
+	if !reflect.DeepEqual(relative, expected) {
+		t.Fatal("unexpected ranges", relative)
+	}
+	if relative := relativeMissingRanges(ranges, 200, 100); relative != nil {
+		t.Fatal("expected no ranges", relative)
+	}
+
+	// Omit the subfiles which overlap the relative ranges.
+	md := skymodules.SkyfileMetadata{
+		Filename: "dir",
+		Subfiles: skymodules.SkyfileSubfiles{
+			"a": {Filename: "a", Offset: 0, Len: 5},
+			"b": {Filename: "b", Offset: 5, Len: 40},
+			"c": {Filename: "c", Offset: 45, Len: 5},
+			"d": {Filename: "d", Offset: 50, Len: 50},
+		},
+	}
+	md, omitted := omitMissingSubfiles(md, relative)
+	expected = []skymodules.SkynetMissingRange{
+		{Offset: 0, Length: 5, Filename: "a"},
+		{Offset: 5, Length: 40, Filename: "b"},
+		{Offset: 50, Length: 50, Filename: "d"},
+	}
+	if !reflect.DeepEqual(omitted, expected) {
+		t.Fatal("unexpected omitted subfiles", omitted)
+	}
+	if _, exists := md.Subfiles["c"]; len(md.Subfiles) != 1 || !exists {
+		t.Fatal("unexpected subfiles", md.Subfiles)
+	}
+
+	// A single file is omitted entirely.
+	md, omitted = omitMissingSubfiles(skymodules.SkyfileMetadata{Filename: "file", Length: 100}, relative)
+	if len(md.Subfiles) != 0 || len(omitted) != 1 || omitted[0].Filename != "file" || omitted[0].Length != 100 {
+		t.Fatal("unexpected result", md.Subfiles, omitted)
+	}
+}
+
+// TestServeNotModified is a unit test for serveNotModified.
+func TestServeNotModified(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Unix(1600000000, 0)
+	lastModified := modTime.UTC().Format(http.TimeFormat)
+
+	// serve is a helper which calls serveNotModified with a request that has
+	// the given method and headers.
+	serve := func(method string, header http.Header, modTime time.Time) (*httptest.ResponseRecorder, bool) {
+		req := httptest.NewRequest(method, "/", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		return w, serveNotModified(w, req, modTime)
+	}
+
+	// Without a modtime nothing happens.
+	w, done := serve(http.MethodGet, http.Header{"If-Modified-Since": {lastModified}}, time.Time{})
+	if done || w.Header().Get("Last-Modified") != "" {
+		t.Fatal("unexpected", done, w.Header())
+	}
+
+	// Without a condition only the header is set.
+	w, done = serve(http.MethodGet, http.Header{}, modTime)
+	if done || w.Header().Get("Last-Modified") != lastModified {
+		t.Fatal("unexpected", done, w.Header())
+	}
+
+	// Unmodified content results in a 304.
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w, done = serve(method, http.Header{"If-Modified-Since": {lastModified}}, modTime)
+		if !done || w.Code != http.StatusNotModified {
+			t.Fatal("expected 304", method, done, w.Code)
+		}
+	}
+
+	// Modified content is served.
+	older := modTime.Add(-time.Second).UTC().Format(http.TimeFormat)
+	_, done = serve(http.MethodGet, http.Header{"If-Modified-Since": {older}}, modTime)
+	if done {
+		t.Fatal("modified content should be served")
+	}
+
+	// If-None-Match takes precedence.
+	_, done = serve(http.MethodGet, http.Header{"If-Modified-Since": {lastModified}, "If-None-Match": {"\"etag\""}}, modTime)
+	if done {
+		t.Fatal("If-None-Match should take precedence")
+	}
+
+	// Invalid dates are ignored.
+	_, done = serve(http.MethodGet, http.Header{"If-Modified-Since": {"invalid"}}, modTime)
+	if done {
+		t.Fatal("invalid date should be ignored")
+	}
+}
+
+// TestETagMatches is a unit test for eTagMatches.
+func TestETagMatches(t *testing.T) {
+	t.Parallel()
+
+	eTag := "etag"
+	tests := []struct {
+		ifNoneMatch string
+		match       bool
+	}{
+		{`"etag"`, true},
+		{`W/"etag"`, true},
+		{`"other", W/"etag"`, true},
+		{`"other","etag"`, true},
+		{`"other"`, false},
+		{`etag`, false},
+		{`"etag`, false},
+		{`*`, false},
+		{``, false},
+	}
+	for _, test := range tests {
+		if match := eTagMatches(test.ifNoneMatch, eTag); match != test.match {
+			t.Errorf("%v: expected %v but got %v", test.ifNoneMatch, test.match, match)
+		}
+	}
+}
+
+// TestAcceptsTrailers is a unit test for acceptsTrailers.
+func TestAcceptsTrailers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		values   []string
+		accepted bool
+	}{
+		{nil, false},
+		{[]string{""}, false},
+		{[]string{"trailers"}, true},
+		{[]string{"Trailers"}, true},
+		{[]string{"deflate"}, false},
+		{[]string{"deflate;q=0.5, trailers"}, true},
+		{[]string{"deflate", "trailers"}, true},
+		{[]string{"trailers;q=1"}, true},
+		{[]string{"notrailers"}, false},
+	}
+	for i, test := range tests {
+		h := http.Header{}
+		for _, v := range test.values {
+			h.Add("TE", v)
+		}
+		if accepted := acceptsTrailers(h); accepted != test.accepted {
+			t.Errorf("%v: expected %v but got %v", i, test.accepted, accepted)
+		}
+	}
+}
+
+// TestSkyfileDataURI is a unit test for skyfileDataURI.
+func TestSkyfileDataURI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		data        string
+		contentType string
+		expected    string
+	}{
+		{"hello", "text/plain", "data:text/plain;base64,aGVsbG8="},
+		{"hello", "Text/Plain; charset=UTF-8; foo=bar", "data:text/plain;charset=UTF-8;base64,aGVsbG8="},
+		{"<html></html>", "", "data:text/html;charset=utf-8;base64,PGh0bWw+PC9odG1sPg=="},
+		{"hello", "not a content type", "data:application/octet-stream;base64,aGVsbG8="},
+		{"", "image/png", "data:image/png;base64,"},
+	}
+	for i, test := range tests {
+		dataURI, err := skyfileDataURI(strings.NewReader(test.data), test.contentType, uint64(len(test.data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dataURI != test.expected {
+			t.Errorf("%v: expected %v but got %v", i, test.expected, dataURI)
+		}
+	}
+
+	// Content shorter than the expected size is an error.
+	_, err := skyfileDataURI(strings.NewReader("hi"), "text/plain", 3)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// skynetmaintenance.go contains the /skynet/maintenance endpoints. While the
+// maintenance mode is enabled, endpoints which add new data to the portal are
+// rejected with a 503 status code. Downloads and other reads keep working.
+
+const (
+	// DefaultSkynetMaintenanceMessage is the message returned to rejected
+	// requests if the operator didn't specify one.
+	DefaultSkynetMaintenanceMessage = "portal is in maintenance mode, uploads are temporarily disabled"
+
+	// skynetMaintenanceRetryAfter is the duration sent in the Retry-After
+	// header of rejected requests.
+	skynetMaintenanceRetryAfter = 5 * time.Minute
+)
+
+// skynetMaintenanceHandlerGET handles the API call to get the maintenance mode
+// of the portal.
+func (api *API) skynetMaintenanceHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, settings.SkynetMaintenance)
+}
+
+// skynetMaintenanceHandlerPOST handles the API call to enable or disable the
+// maintenance mode of the portal.
+func (api *API) skynetMaintenanceHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the maintenance mode.
+	var maintenance skymodules.SkynetMaintenance
+	err := json.NewDecoder(req.Body).Decode(&maintenance)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Update the maintenance mode in the renter's settings.
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	settings.SkynetMaintenance = maintenance
+	err = api.renter.SetSettings(settings)
+	if err != nil {
+		WriteError(w, Error{"failed to set maintenance mode: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// rejectDuringMaintenance wraps a handler which adds new data to the portal.
+// While the maintenance mode is enabled, requests are rejected with a 503
+// status code, the operator's message and a Retry-After header.
+func (api *API) rejectDuringMaintenance(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		settings, err := api.renter.Settings()
+		if err != nil {
+			WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		if settings.SkynetMaintenance.Enabled {
+			writeSkynetMaintenanceError(w, settings.SkynetMaintenance)
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
+// writeSkynetMaintenanceError writes the response for a request which was
+// rejected due to the maintenance mode.
+func writeSkynetMaintenanceError(w http.ResponseWriter, maintenance skymodules.SkynetMaintenance) {
+	msg := maintenance.Message
+	if msg == "" {
+		msg = DefaultSkynetMaintenanceMessage
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(int64(skynetMaintenanceRetryAfter.Seconds())))
+	WriteError(w, Error{msg}, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// skynetpartstaging.go contains the staging area for resumable multipart
+// uploads. A client which declares the blake2b hashes of its parts in the
+// Skynet-Part-Hashes header and an id for the upload in the
+// Skynet-Upload-Session header gets every completely received part staged
+// under that id. If the upload fails, the client can retry it and send
+// zero-length placeholders for the parts which were staged. The placeholders
+// are replaced with the staged data. Staged parts expire once their session
+// wasn't used for skynetPartStagingTTL.
+
+const (
+	// skynetUploadSessionMaxLen is the maximum length of an upload session
+	// id.
+	skynetUploadSessionMaxLen = 64
+)
+
+var (
+	// skynetPartStagingMaxSize is the maximum number of bytes staged across
+	// all upload sessions. Parts which don't fit are not staged.
+	skynetPartStagingMaxSize = build.Select(build.Var{
+		Dev:      uint64(1 << 26), // 64 MiB
+		Standard: uint64(1 << 28), // 256 MiB
+		Testing:  uint64(1 << 24), // 16 MiB
+	}).(uint64)
+
+	// skynetPartStagingTTL is the time after which the staged parts of an
+	// upload session expire if the session isn't used.
+	skynetPartStagingTTL = build.Select(build.Var{
+		Dev:      10 * time.Minute,
+		Standard: time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+)
+
+var (
+	// errSkynetPartHashMismatch is returned if the data of a part doesn't
+	// match the hash declared for it.
+	errSkynetPartHashMismatch = errors.New("data of part doesn't match its hash")
+)
+
+type (
+	// skynetPartStaging is the staging area for the parts of resumable
+	// multipart uploads.
+	skynetPartStaging struct {
+		sessions map[string]*skynetStagedSession
+		size     uint64
+		mu       sync.Mutex
+
+		staticMaxSize uint64
+		staticTTL     time.Duration
+	}
+
+	// skynetStagedSession contains the staged parts of a single upload
+	// session by their hashes.
+	skynetStagedSession struct {
+		expiry time.Time
+		parts  map[crypto.Hash][]byte
+	}
+
+	// skynetPartStager implements skymodules.SkyfilePartStager for a single
+	// upload.
+	skynetPartStager struct {
+		staticHashes  map[string]crypto.Hash
+		staticSession string
+		staticStaging *skynetPartStaging
+	}
+
+	// skynetPartStagingWriter hashes the data of a part and buffers it to
+	// stage it once it is closed.
+	skynetPartStagingWriter struct {
+		buf      []byte
+		h        hash.Hash
+		tooLarge bool
+
+		staticFilename string
+		staticHash     crypto.Hash
+		staticStager   *skynetPartStager
+	}
+)
+
+// newSkynetPartStaging creates a new staging area.
+func newSkynetPartStaging(maxSize uint64, ttl time.Duration) *skynetPartStaging {
+	return &skynetPartStaging{
+		sessions:      make(map[string]*skynetStagedSession),
+		staticMaxSize: maxSize,
+		staticTTL:     ttl,
+	}
+}
+
+// parsePartHashes parses the values of the Skynet-Upload-Session and
+// Skynet-Part-Hashes headers. Both of them need to be set for an upload to be
+// resumable.
+func parsePartHashes(session, hashesStr string) (string, map[string]crypto.Hash, error) {
+	if session == "" && hashesStr == "" {
+		return "", nil, nil
+	}
+	if session == "" || hashesStr == "" {
+		return "", nil, fmt.Errorf("'%v' and '%v' headers need to be set together", SkynetUploadSessionHeader, SkynetPartHashesHeader)
+	}
+	if len(session) > skynetUploadSessionMaxLen {
+		return "", nil, fmt.Errorf("'%v' header can't exceed %v characters", SkynetUploadSessionHeader, skynetUploadSessionMaxLen)
+	}
+	var hashes map[string]crypto.Hash
+	err := json.Unmarshal([]byte(hashesStr), &hashes)
+	if err != nil {
+		return "", nil, errors.AddContext(err, fmt.Sprintf("unable to parse '%v' header", SkynetPartHashesHeader))
+	}
+	return session, hashes, nil
+}
+
+// Stager returns a stager for an upload of the given session with the given
+// part hashes.
+func (s *skynetPartStaging) Stager(session string, hashes map[string]crypto.Hash) skymodules.SkyfilePartStager {
+	return &skynetPartStager{
+		staticHashes:  hashes,
+		staticSession: session,
+		staticStaging: s,
+	}
+}
+
+// Delete removes the staged parts of a session.
+func (s *skynetPartStaging) Delete(session string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteSession(session)
+}
+
+// Part returns the staged part with the given hash of a session.
+func (s *skynetPartStaging) Part(session string, h crypto.Hash, now time.Time) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	ss, exists := s.sessions[session]
+	if !exists {
+		return nil, false
+	}
+	data, exists := ss.parts[h]
+	if exists {
+		ss.expiry = now.Add(s.staticTTL)
+	}
+	return data, exists
+}
+
+// Stage stages a part of a session. Parts which exceed the maximum size of
+// the staging area are not staged.
+func (s *skynetPartStaging) Stage(session string, h crypto.Hash, data []byte, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	ss, exists := s.sessions[session]
+	if !exists {
+		ss = &skynetStagedSession{
+			parts: make(map[crypto.Hash][]byte),
+		}
+		s.sessions[session] = ss
+	}
+	ss.expiry = now.Add(s.staticTTL)
+	if _, exists := ss.parts[h]; exists || s.size+uint64(len(data)) > s.staticMaxSize {
+		return
+	}
+	ss.parts[h] = data
+	s.size += uint64(len(data))
+}
+
+// deleteSession removes the staged parts of a session.
+func (s *skynetPartStaging) deleteSession(session string) {
+	ss, exists := s.sessions[session]
+	if !exists {
+		return
+	}
+	for _, data := range ss.parts {
+		s.size -= uint64(len(data))
+	}
+	delete(s.sessions, session)
+}
+
+// prune removes the sessions which expired.
+func (s *skynetPartStaging) prune(now time.Time) {
+	for session, ss := range s.sessions {
+		if now.After(ss.expiry) {
+			s.deleteSession(session)
+		}
+	}
+}
+
+// StagedPart implements skymodules.SkyfilePartStager.
+func (s *skynetPartStager) StagedPart(filename string) ([]byte, bool) {
+	h, exists := s.staticHashes[filename]
+	if !exists {
+		return nil, false
+	}
+	return s.staticStaging.Part(s.staticSession, h, time.Now())
+}
+
+// StagePart implements skymodules.SkyfilePartStager. Only the parts with a
+// declared hash are staged.
+func (s *skynetPartStager) StagePart(filename string) io.WriteCloser {
+	h, exists := s.staticHashes[filename]
+	if !exists {
+		return nil
+	}
+	return &skynetPartStagingWriter{
+		h:              crypto.NewHash(),
+		staticFilename: filename,
+		staticHash:     h,
+		staticStager:   s,
+	}
+}
+
+// Write implements io.Writer. The data is only buffered while it fits in the
+// staging area.
+func (w *skynetPartStagingWriter) Write(p []byte) (int, error) {
+	_, _ = w.h.Write(p)
+	if !w.tooLarge && uint64(len(w.buf)+len(p)) > w.staticStager.staticStaging.staticMaxSize {
+		w.tooLarge = true
+		w.buf = nil
+	}
+	if !w.tooLarge {
+		w.buf = append(w.buf, p...)
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer. It verifies the data of the part against its
+// declared hash and stages it.
+func (w *skynetPartStagingWriter) Close() error {
+	var h crypto.Hash
+	w.h.Sum(h[:0])
+	if h != w.staticHash {
+		return errors.AddContext(errSkynetPartHashMismatch, fmt.Sprintf("part '%v' has hash %v but %v was declared, a placeholder is only replaced while its part is staged", w.staticFilename, h, w.staticHash))
+	}
+	if !w.tooLarge {
+		w.staticStager.staticStaging.Stage(w.staticStager.staticSession, h, w.buf, time.Now())
+	}
+	return nil
+}
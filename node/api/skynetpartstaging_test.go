@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetPartStaging probes the staging area of resumable multipart
+// uploads.
+func TestSkynetPartStaging(t *testing.T) {
+	t.Parallel()
+
+	s := newSkynetPartStaging(100, time.Minute)
+	now := time.Now()
+	data := fastrand.Bytes(60)
+	h := crypto.HashBytes(data)
+
+	// Stage a part and fetch it.
+	s.Stage("a", h, data, now)
+	staged, ok := s.Part("a", h, now)
+	if !ok || !bytes.Equal(staged, data) {
+		t.Fatal("part should be staged")
+	}
+	if _, ok := s.Part("b", h, now); ok {
+		t.Fatal("part shouldn't be staged for another session")
+	}
+
+	// A part which doesn't fit isn't staged.
+	other := fastrand.Bytes(60)
+	s.Stage("b", crypto.HashBytes(other), other, now)
+	if _, ok := s.Part("b", crypto.HashBytes(other), now); ok {
+		t.Fatal("part shouldn't fit")
+	}
+
+	// Using a session extends its expiry.
+	if _, ok := s.Part("a", h, now.Add(50*time.Second)); !ok {
+		t.Fatal("part shouldn't have expired yet")
+	}
+	if _, ok := s.Part("a", h, now.Add(100*time.Second)); !ok {
+		t.Fatal("part shouldn't have expired yet")
+	}
+
+	// Once the session expired, its space is freed.
+	if _, ok := s.Part("a", h, now.Add(200*time.Second)); ok {
+		t.Fatal("part should have expired")
+	}
+	s.Stage("b", crypto.HashBytes(other), other, now.Add(200*time.Second))
+	if _, ok := s.Part("b", crypto.HashBytes(other), now.Add(200*time.Second)); !ok {
+		t.Fatal("part should be staged")
+	}
+
+	// Deleting a session frees its space too.
+	s.Delete("b")
+	if s.size != 0 || len(s.sessions) != 0 {
+		t.Fatal("session wasn't deleted", s.size, len(s.sessions))
+	}
+}
+
+// TestSkynetPartStager verifies that the stager only stages parts with a
+// declared hash and rejects parts which don't match it.
+func TestSkynetPartStager(t *testing.T) {
+	t.Parallel()
+
+	s := newSkynetPartStaging(100, time.Minute)
+	data := fastrand.Bytes(10)
+	stager := s.Stager("session", map[string]crypto.Hash{
+		"a": crypto.HashBytes(data),
+	})
+
+	// Parts without a hash aren't staged.
+	if w := stager.StagePart("b"); w != nil {
+		t.Fatal("part without hash shouldn't be staged")
+	}
+
+	// Data which doesn't match the hash is rejected.
+	w := stager.StagePart("a")
+	_, _ = w.Write(data[1:])
+	if err := w.Close(); !errors.Contains(err, errSkynetPartHashMismatch) {
+		t.Fatal("unexpected error", err)
+	}
+	if _, ok := stager.StagedPart("a"); ok {
+		t.Fatal("part shouldn't be staged")
+	}
+
+	// Matching data is staged.
+	w = stager.StagePart("a")
+	_, _ = w.Write(data[:5])
+	_, _ = w.Write(data[5:])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	staged, ok := stager.StagedPart("a")
+	if !ok || !bytes.Equal(staged, data) {
+		t.Fatal("part should be staged")
+	}
+}
+
+// TestParsePartHashes tests parsing the headers of resumable multipart
+// uploads.
+func TestParsePartHashes(t *testing.T) {
+	t.Parallel()
+
+	hashes := map[string]crypto.Hash{
+		"a": crypto.HashBytes([]byte("a")),
+	}
+	hashesStr, err := json.Marshal(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both headers are parsed.
+	session, parsed, err := parsePartHashes("session", string(hashesStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session != "session" || len(parsed) != 1 || parsed["a"] != hashes["a"] {
+		t.Fatal("unexpected result", session, parsed)
+	}
+
+	// No headers is fine.
+	session, parsed, err = parsePartHashes("", "")
+	if err != nil || session != "" || parsed != nil {
+		t.Fatal("unexpected result", session, parsed, err)
+	}
+
+	// Invalid headers are rejected.
+	invalid := [][2]string{
+		{"session", ""},
+		{"", string(hashesStr)},
+		{string(fastrand.Bytes(skynetUploadSessionMaxLen + 1)), string(hashesStr)},
+		{"session", `{"a":"nohash"}`},
+	}
+	for _, headers := range invalid {
+		if _, _, err := parsePartHashes(headers[0], headers[1]); err == nil {
+			t.Fatal("headers should be invalid", headers)
+		}
+	}
+}
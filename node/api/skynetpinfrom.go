@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// skynetpinfrom.go contains the /skynet/pinfrom endpoint which pins a skylink
+// by fetching its base sector and fanout from another portal instead of the
+// renter's hosts. The remote portal is only used as a source of sectors, every
+// sector is verified against its merkle root before it is re-uploaded.
+
+// portalSectorFetcher is a skymodules.SkynetSectorFetcher which fetches
+// sectors from the /skynet/basesector and /skynet/root endpoints of a remote
+// portal.
+type portalSectorFetcher struct {
+	staticClient *http.Client
+	staticPortal string
+}
+
+// newPortalSectorFetcher creates a fetcher for the portal at the given url.
+func newPortalSectorFetcher(portal string) (*portalSectorFetcher, error) {
+	u, err := url.Parse(portal)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme '%v'", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("no host provided")
+	}
+	return &portalSectorFetcher{
+		staticClient: http.DefaultClient,
+		staticPortal: strings.TrimSuffix(u.String(), "/"),
+	}, nil
+}
+
+// BaseSector implements skymodules.SkynetSectorFetcher.
+func (pf *portalSectorFetcher) BaseSector(ctx context.Context, link skymodules.Skylink) ([]byte, error) {
+	return pf.managedGet(ctx, fmt.Sprintf("/skynet/basesector/%v", link))
+}
+
+// Sector implements skymodules.SkynetSectorFetcher.
+func (pf *portalSectorFetcher) Sector(ctx context.Context, root crypto.Hash) ([]byte, error) {
+	values := url.Values{}
+	values.Set("root", root.String())
+	values.Set("offset", "0")
+	values.Set("length", fmt.Sprint(modules.SectorSize))
+	return pf.managedGet(ctx, fmt.Sprintf("/skynet/root?%v", values.Encode()))
+}
+
+// managedGet performs a GET request against the given resource of the remote
+// portal and returns the response body. Bodies larger than a sector are
+// rejected.
+func (pf *portalSectorFetcher) managedGet(ctx context.Context, resource string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pf.staticPortal+resource, nil)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create request")
+	}
+	req.Header.Set("User-Agent", "Sia-Agent")
+	resp, err := pf.staticClient.Do(req)
+	if err != nil {
+		return nil, errors.AddContext(err, "request to remote portal failed")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(modules.SectorSize)+1))
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read response from remote portal")
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr Error
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("remote portal responded with status %v: %v", resp.StatusCode, apiErr.Message)
+		}
+		return nil, fmt.Errorf("remote portal responded with status %v", resp.StatusCode)
+	}
+	if uint64(len(body)) > modules.SectorSize {
+		return nil, errors.New("response of remote portal exceeds the sector size")
+	}
+	return body, nil
+}
+
+// skynetPinFromHandlerPOST pins a skylink to this Sia node by fetching its
+// base sector and fanout from the portal provided with the 'portal' query
+// string parameter.
+func (api *API) skynetPinFromHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the query params.
+	queryForm, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		WriteError(w, Error{"failed to parse query params"}, http.StatusBadRequest)
+		return
+	}
+
+	skylink, err := skymodules.ParseSkylink(ps.ByName("skylink"))
+	if err != nil {
+		WriteError(w, Error{fmt.Sprintf("error parsing skylink: %v", err)}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the portal to pin from.
+	portal := queryForm.Get("portal")
+	if portal == "" {
+		WriteError(w, Error{"no portal provided"}, http.StatusBadRequest)
+		return
+	}
+	fetcher, err := newPortalSectorFetcher(portal)
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'portal' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse whether the siapath should be from root or from the skynet folder.
+	var root bool
+	rootStr := queryForm.Get("root")
+	if rootStr != "" {
+		root, err = strconv.ParseBool(rootStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'root' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse out the intended siapath.
+	var siaPath skymodules.SiaPath
+	siaPathStr := queryForm.Get("siapath")
+	if root {
+		siaPath, err = skymodules.NewSiaPath(siaPathStr)
+	} else {
+		siaPath, err = skymodules.SkynetFolder.Join(siaPathStr)
+	}
+	if err != nil {
+		WriteError(w, Error{"invalid siapath provided: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Parse the timeout.
+	defaultTimeout, maxTimeout := api.skynetRequestTimeouts()
+	timeout, err := parseTimeout(queryForm, defaultTimeout, maxTimeout)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Check whether force upload is allowed. Skynet portals might disallow
+	// passing the force flag by passing in the 'Skynet-Disable-Force' header.
+	allowForce := true
+	strDisableForce := req.Header.Get(SkynetDisableForceHeader)
+	if strDisableForce != "" {
+		disableForce, err := strconv.ParseBool(strDisableForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'Skynet-Disable-Force' header: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		allowForce = !disableForce
+	}
+
+	// Check whether existing file should be overwritten
+	force := false
+	if strForce := queryForm.Get("force"); strForce != "" {
+		force, err = strconv.ParseBool(strForce)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'force' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Notify the caller force has been disabled
+	if !allowForce && force {
+		forceToken, err := parseSkynetForceToken(req)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		err = api.verifySkynetForceToken(forceToken, siaPath)
+		if err != nil {
+			handleSkynetError(w, "unable to force pin", err)
+			return
+		}
+	}
+
+	// Check whether the redundancy has been set.
+	redundancy := uint8(0)
+	if rStr := queryForm.Get("basechunkredundancy"); rStr != "" {
+		if _, err := fmt.Sscan(rStr, &redundancy); err != nil {
+			WriteError(w, Error{"unable to parse basechunkredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Create the upload parameters. Just like for /skynet/pin, only the
+	// parameters which don't change the skylink are included.
+	lup := skymodules.SkyfileUploadParameters{
+		SiaPath:             siaPath,
+		Force:               force,
+		BaseChunkRedundancy: redundancy,
+	}
+	err = api.renter.PinSkylinkFrom(skylink, lup, fetcher, timeout)
+	if err != nil {
+		handleSkynetError(w, "failed to pin file from "+fetcher.staticPortal, err)
+		return
+	}
+	pin, err := api.managedSkynetPinInfo(skylink, siaPath)
+	if err != nil {
+		WriteError(w, Error{"failed to fetch pinned file: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(SkynetSkylinkHeader, skylink.String())
+	WriteJSON(w, pin)
+}
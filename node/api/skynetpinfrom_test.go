@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestNewPortalSectorFetcher tests parsing the portal of a
+// portalSectorFetcher.
+func TestNewPortalSectorFetcher(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		portal   string
+		expected string
+		valid    bool
+	}{
+		{portal: "https://siasky.net", expected: "https://siasky.net", valid: true},
+		{portal: "https://siasky.net/", expected: "https://siasky.net", valid: true},
+		{portal: "http://127.0.0.1:9980", expected: "http://127.0.0.1:9980", valid: true},
+		{portal: "siasky.net"},
+		{portal: "ftp://siasky.net"},
+		{portal: "https://"},
+	}
+	for _, test := range tests {
+		pf, err := newPortalSectorFetcher(test.portal)
+		if test.valid && err != nil {
+			t.Fatal(test.portal, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatal("expected error for", test.portal)
+		}
+		if test.valid && pf.staticPortal != test.expected {
+			t.Fatal("wrong portal", pf.staticPortal, test.expected)
+		}
+	}
+}
+
+// TestPortalSectorFetcher tests fetching sectors from a remote portal.
+func TestPortalSectorFetcher(t *testing.T) {
+	t.Parallel()
+
+	sector := fastrand.Bytes(int(modules.SectorSize))
+	root := crypto.MerkleRoot(sector)
+	skylink, err := skymodules.NewSkylinkV1(root, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unknownRoot crypto.Hash
+	fastrand.Read(unknownRoot[:])
+	var oversizedRoot crypto.Hash
+	fastrand.Read(oversizedRoot[:])
+
+	// Create a portal which serves the sector.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/skynet/basesector/", func(w http.ResponseWriter, req *http.Request) {
+		if req.UserAgent() != "Sia-Agent" {
+			WriteError(w, Error{"wrong user agent"}, http.StatusBadRequest)
+			return
+		}
+		if strings.TrimPrefix(req.URL.Path, "/skynet/basesector/") != skylink.String() {
+			WriteError(w, Error{"unknown skylink"}, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(sector[:100])
+	})
+	mux.HandleFunc("/skynet/root", func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		switch query.Get("root") {
+		case root.String():
+			_, _ = w.Write(sector)
+		case oversizedRoot.String():
+			_, _ = w.Write(append(sector, 0))
+		default:
+			WriteError(w, Error{"unknown root"}, http.StatusNotFound)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pf, err := newPortalSectorFetcher(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Fetch the base sector.
+	baseSector, err := pf.BaseSector(ctx, skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(baseSector, sector[:100]) {
+		t.Fatal("wrong base sector")
+	}
+
+	// Fetch the sector.
+	fetched, err := pf.Sector(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetched, sector) {
+		t.Fatal("wrong sector")
+	}
+
+	// Unknown sectors should return the remote portal's error.
+	_, err = pf.Sector(ctx, unknownRoot)
+	if err == nil || !strings.Contains(err.Error(), "unknown root") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Responses larger than a sector should be rejected.
+	_, err = pf.Sector(ctx, oversizedRoot)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the sector size") {
+		t.Fatal("unexpected error", err)
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// skynetratelimit.go contains the per-IP rate limit for skyfile uploads. The
+// uploads of every source IP are tracked within a sliding window. Once an IP
+// exceeded the number of uploads or bytes allowed within the window, its
+// uploads are rejected with a 429 status code until enough of its previous
+// uploads left the window. Requests which continue an upload, like the PATCH
+// requests of TUS uploads, only count towards the bytes of the IP.
+
+var (
+	// errUploadRateLimitExceeded is returned if an IP exceeded the upload
+	// rate limit.
+	errUploadRateLimitExceeded = errors.New("upload rate limit exceeded")
+)
+
+type (
+	// skynetUploadRateLimiter keeps track of the uploads of every source IP
+	// within the sliding window of the upload rate limit.
+	skynetUploadRateLimiter struct {
+		lastPrune time.Time
+		uploads   map[string][]*skynetUploadRecord
+		mu        sync.Mutex
+	}
+
+	// skynetUploadRecord is a single request of an IP. Its size grows while
+	// the body of the request is read. Only records of requests which start
+	// an upload count towards the number of uploads.
+	skynetUploadRecord struct {
+		size        uint64
+		staticStart bool
+		timestamp   time.Time
+	}
+
+	// rateLimitedBody is the body of a rate limited upload. It adds the bytes
+	// read from the body to the size of the upload's record.
+	rateLimitedBody struct {
+		io.ReadCloser
+		staticLimiter *skynetUploadRateLimiter
+		staticRecord  *skynetUploadRecord
+	}
+)
+
+// newSkynetUploadRateLimiter creates a new upload rate limiter.
+func newSkynetUploadRateLimiter() *skynetUploadRateLimiter {
+	return &skynetUploadRateLimiter{
+		uploads: make(map[string][]*skynetUploadRecord),
+	}
+}
+
+// Read implements io.Reader.
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.staticLimiter.addBytes(b.staticRecord, uint64(n))
+	return n, err
+}
+
+// addBytes adds the given number of bytes to an upload's record.
+func (l *skynetUploadRateLimiter) addBytes(record *skynetUploadRecord, n uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record.size += n
+}
+
+// callAddUpload adds a request of the given size to the uploads of ip if the
+// limit allows for it. If start is true, the request starts a new upload and
+// counts towards the number of uploads. Otherwise it continues an upload and
+// only counts towards the bytes. If the size is unknown, it is expected to be
+// -1 and only the bytes already uploaded within the window are checked. If the
+// request isn't allowed, the returned duration is the time after which the IP
+// should try again.
+func (l *skynetUploadRateLimiter) callAddUpload(ip string, limit skymodules.SkynetUploadRateLimit, size int64, start bool, now time.Time) (*skynetUploadRecord, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	window := limit.Window()
+	l.prune(now, window)
+
+	// Drop the uploads which left the window.
+	records := l.uploads[ip]
+	for len(records) > 0 && !now.Before(records[0].timestamp.Add(window)) {
+		records = records[1:]
+	}
+	l.uploads[ip] = records
+
+	// The IP can try again once its oldest upload left the window.
+	var retryAfter time.Duration
+	if len(records) > 0 {
+		retryAfter = records[0].timestamp.Add(window).Sub(now)
+	}
+	if limit.MaxUploads > 0 && start {
+		var numUploads uint64
+		var oldestUpload *skynetUploadRecord
+		for _, record := range records {
+			if !record.staticStart {
+				continue
+			}
+			if oldestUpload == nil {
+				oldestUpload = record
+			}
+			numUploads++
+		}
+		if numUploads >= limit.MaxUploads {
+			return nil, oldestUpload.timestamp.Add(window).Sub(now), errors.AddContext(errUploadRateLimitExceeded, fmt.Sprintf("at most %v uploads are allowed per %v", limit.MaxUploads, window))
+		}
+	}
+	if limit.MaxBytes > 0 {
+		var total uint64
+		for _, record := range records {
+			total += record.size
+		}
+		exceeded := total >= limit.MaxBytes
+		if size > 0 && total+uint64(size) > limit.MaxBytes {
+			exceeded = true
+			// An upload larger than the limit is never allowed, let the IP
+			// try again after a full window.
+			if uint64(size) > limit.MaxBytes {
+				retryAfter = window
+			}
+		}
+		if exceeded {
+			return nil, retryAfter, errors.AddContext(errUploadRateLimitExceeded, fmt.Sprintf("at most %v bytes can be uploaded per %v", limit.MaxBytes, window))
+		}
+	}
+	record := &skynetUploadRecord{staticStart: start, timestamp: now}
+	l.uploads[ip] = append(records, record)
+	return record, 0, nil
+}
+
+// prune removes the IPs without uploads in the window from the limiter. To
+// avoid iterating over all IPs for every upload, it only does so once per
+// window.
+func (l *skynetUploadRateLimiter) prune(now time.Time, window time.Duration) {
+	if now.Sub(l.lastPrune) < window {
+		return
+	}
+	l.lastPrune = now
+	for ip, records := range l.uploads {
+		if len(records) == 0 || !now.Before(records[len(records)-1].timestamp.Add(window)) {
+			delete(l.uploads, ip)
+		}
+	}
+}
+
+// limitSkynetUploads wraps a handler which starts the upload of a skyfile. If
+// the upload rate limit is enabled, requests of IPs which exceeded it are
+// rejected with a 429 status code and a Retry-After header.
+func (api *API) limitSkynetUploads(h httprouter.Handle) httprouter.Handle {
+	return api.limitSkynetUploadRequests(h, true)
+}
+
+// limitSkynetUploadBytes wraps a handler which continues the upload of a
+// skyfile. It works like limitSkynetUploads but the requests only count
+// towards the bytes of an IP.
+func (api *API) limitSkynetUploadBytes(h httprouter.Handle) httprouter.Handle {
+	return api.limitSkynetUploadRequests(h, false)
+}
+
+// limitSkynetUploadRequests wraps a handler which starts or continues the
+// upload of a skyfile and enforces the upload rate limit.
+func (api *API) limitSkynetUploadRequests(h httprouter.Handle, start bool) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		settings, err := api.renter.Settings()
+		if err != nil {
+			WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		limit := settings.SkynetUploadRateLimit
+		if !limit.Enabled() {
+			h(w, req, ps)
+			return
+		}
+		ip := sourceIP(req)
+		if limit.TrustForwardedFor {
+			ip = forwardedSourceIP(req)
+		}
+		record, retryAfter, err := api.staticSkynetUploadLimiter.callAddUpload(ip, limit, req.ContentLength, start, time.Now())
+		if err != nil {
+			w.Header().Set("Retry-After", fmt.Sprint(int64(math.Ceil(retryAfter.Seconds()))))
+			WriteError(w, Error{err.Error()}, http.StatusTooManyRequests)
+			return
+		}
+		req.Body = &rateLimitedBody{
+			ReadCloser:    req.Body,
+			staticLimiter: api.staticSkynetUploadLimiter,
+			staticRecord:  record,
+		}
+		h(w, req, ps)
+	}
+}
+
+// skynetUploadRateLimitHandlerGET handles the API call to get the upload rate
+// limit which is enforced for skyfile uploads.
+func (api *API) skynetUploadRateLimitHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, settings.SkynetUploadRateLimit)
+}
+
+// skynetUploadRateLimitHandlerPOST handles the API call to set the upload
+// rate limit which is enforced for skyfile uploads.
+func (api *API) skynetUploadRateLimitHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the limit.
+	var limit skymodules.SkynetUploadRateLimit
+	err := json.NewDecoder(req.Body).Decode(&limit)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = limit.Validate()
+	if err != nil {
+		WriteError(w, Error{"invalid upload rate limit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Update the limit in the renter's settings.
+	settings, err := api.renter.Settings()
+	if err != nil {
+		WriteError(w, Error{"failed to get renter settings: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	settings.SkynetUploadRateLimit = limit
+	err = api.renter.SetSettings(settings)
+	if err != nil {
+		WriteError(w, Error{"failed to set upload rate limit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// sourceIP returns the IP the request was sent from. Headers set by proxies
+// are ignored since they can be forged by the client.
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedSourceIP returns the last IP of the X-Forwarded-For header of the
+// request. That's the IP which the reverse proxy in front of the node appended
+// while the previous ones might have been forged by the client. If the header
+// doesn't contain a valid IP, the IP of the connection is returned instead.
+func forwardedSourceIP(req *http.Request) string {
+	values := req.Header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return sourceIP(req)
+	}
+	ips := strings.Split(values[len(values)-1], ",")
+	ip := net.ParseIP(strings.TrimSpace(ips[len(ips)-1]))
+	if ip == nil {
+		return sourceIP(req)
+	}
+	return ip.String()
+}
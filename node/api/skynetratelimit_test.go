@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// TestSkynetUploadRateLimiter probes the sliding window of the upload rate
+// limiter.
+func TestSkynetUploadRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	l := newSkynetUploadRateLimiter()
+	limit := skymodules.SkynetUploadRateLimit{
+		MaxUploads:    2,
+		MaxBytes:      100,
+		WindowSeconds: 10,
+	}
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	// Two uploads are allowed, the third one is rejected until the first one
+	// left the window.
+	_, _, err := l.callAddUpload("a", limit, -1, true, at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = l.callAddUpload("a", limit, 10, true, at(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, retryAfter, err := l.callAddUpload("a", limit, 10, true, at(5))
+	if !errors.Contains(err, errUploadRateLimitExceeded) {
+		t.Fatal("unexpected error", err)
+	}
+	if retryAfter != 5*time.Second {
+		t.Fatal("unexpected retry after", retryAfter)
+	}
+
+	// Other IPs are not affected.
+	_, _, err = l.callAddUpload("b", limit, -1, true, at(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the first upload left the window, the IP can upload again.
+	_, _, err = l.callAddUpload("a", limit, -1, true, at(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uploads are rejected once the bytes in the window reach the limit.
+	l = newSkynetUploadRateLimiter()
+	limit.MaxUploads = 0
+	r1, _, err := l.callAddUpload("a", limit, -1, true, at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.addBytes(r1, 60)
+	_, _, err = l.callAddUpload("a", limit, 50, true, at(1))
+	if !errors.Contains(err, errUploadRateLimitExceeded) {
+		t.Fatal("expected upload exceeding the limit to be rejected", err)
+	}
+	r2, _, err := l.callAddUpload("a", limit, -1, true, at(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.addBytes(r2, 40)
+	_, retryAfter, err = l.callAddUpload("a", limit, -1, true, at(2))
+	if !errors.Contains(err, errUploadRateLimitExceeded) || retryAfter != 8*time.Second {
+		t.Fatal("unexpected result", retryAfter, err)
+	}
+
+	// An upload larger than the limit can't be retried within the window.
+	_, retryAfter, err = l.callAddUpload("b", limit, 101, true, at(2))
+	if !errors.Contains(err, errUploadRateLimitExceeded) || retryAfter != limit.Window() {
+		t.Fatal("unexpected result", retryAfter, err)
+	}
+
+	// Requests which continue an upload only count towards the bytes.
+	l = newSkynetUploadRateLimiter()
+	limit = skymodules.SkynetUploadRateLimit{
+		MaxUploads:    1,
+		MaxBytes:      100,
+		WindowSeconds: 10,
+	}
+	_, _, err = l.callAddUpload("a", limit, 0, true, at(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r3, _, err := l.callAddUpload("a", limit, 50, false, at(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, retryAfter, err = l.callAddUpload("a", limit, 0, true, at(2))
+	if !errors.Contains(err, errUploadRateLimitExceeded) || retryAfter != 8*time.Second {
+		t.Fatal("unexpected result", retryAfter, err)
+	}
+	l.addBytes(r3, 50)
+	_, _, err = l.callAddUpload("a", limit, 60, false, at(2))
+	if !errors.Contains(err, errUploadRateLimitExceeded) {
+		t.Fatal("expected continuation exceeding the bytes to be rejected", err)
+	}
+
+	// The number of uploads only depends on the requests which start an
+	// upload.
+	_, _, err = l.callAddUpload("a", limit, 0, true, at(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// IPs without uploads in the window are pruned.
+	_, _, err = l.callAddUpload("c", limit, -1, true, at(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := l.uploads["a"]; exists || len(l.uploads) != 1 {
+		t.Fatal("expected old IPs to be pruned", l.uploads)
+	}
+}
+
+// TestSourceIP is a unit test for sourceIP.
+func TestSourceIP(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("POST", "/skynet/skyfile", nil)
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	for addr, ip := range map[string]string{
+		"10.0.0.1:1234": "10.0.0.1",
+		"[::1]:1234":    "::1",
+		"10.0.0.1":      "10.0.0.1",
+	} {
+		req.RemoteAddr = addr
+		if sourceIP(req) != ip {
+			t.Fatal("unexpected ip", addr, sourceIP(req))
+		}
+	}
+}
+
+// TestForwardedSourceIP is a unit test for forwardedSourceIP.
+func TestForwardedSourceIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		headers []string
+		ip      string
+	}{
+		{nil, "10.0.0.1"},
+		{[]string{"1.2.3.4"}, "1.2.3.4"},
+		{[]string{"5.6.7.8, 1.2.3.4"}, "1.2.3.4"},
+		{[]string{"5.6.7.8", "1.2.3.4 "}, "1.2.3.4"},
+		{[]string{"2001:db8::1"}, "2001:db8::1"},
+		{[]string{"1.2.3.4, notanip"}, "10.0.0.1"},
+		{[]string{""}, "10.0.0.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/skynet/skyfile", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		for _, header := range test.headers {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		if ip := forwardedSourceIP(req); ip != test.ip {
+			t.Fatal("unexpected ip", test.headers, ip)
+		}
+	}
+}
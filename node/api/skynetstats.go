@@ -0,0 +1,197 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+const (
+	// skynetStatsFormatBaseSector is the format used to track downloads from
+	// the /skynet/basesector endpoint.
+	skynetStatsFormatBaseSector = skymodules.SkyfileFormat("basesector")
+
+	// skynetStatsFormatRaw is the format used to track skylink downloads
+	// which don't specify a format.
+	skynetStatsFormatRaw = skymodules.SkyfileFormat("raw")
+
+	// skynetStatsFormatRoot is the format used to track downloads from the
+	// /skynet/root endpoint.
+	skynetStatsFormatRoot = skymodules.SkyfileFormat("root")
+)
+
+var (
+	// SkynetStatsTTFBBucketsMS are the upper bounds of the TTFB buckets in
+	// milliseconds. A TTFB above the last bound is counted in an additional
+	// overflow bucket.
+	SkynetStatsTTFBBucketsMS = [...]uint64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+	// SkynetStatsThroughputBuckets are the upper bounds of the throughput
+	// buckets in bytes per second. A throughput above the last bound is
+	// counted in an additional overflow bucket.
+	SkynetStatsThroughputBuckets = [...]uint64{1 << 16, 1 << 18, 1 << 20, 1 << 22, 1 << 24, 1 << 26, 1 << 28}
+)
+
+type (
+	// SkynetFormatStats contains the number of downloads for a download
+	// format as well as the distributions of their TTFB and throughput. The
+	// distributions contain the number of downloads which fall into the
+	// corresponding bucket of SkynetStatsTTFBBucketsMS and
+	// SkynetStatsThroughputBuckets respectively.
+	SkynetFormatStats struct {
+		Requests   uint64                                        `json:"requests"`
+		TTFB       [len(SkynetStatsTTFBBucketsMS) + 1]uint64     `json:"ttfb"`
+		Throughput [len(SkynetStatsThroughputBuckets) + 1]uint64 `json:"throughput"`
+	}
+
+	// SkynetFormatsStats contains the download stats for every download
+	// format.
+	SkynetFormatsStats struct {
+		Raw        SkynetFormatStats `json:"raw"`
+		Concat     SkynetFormatStats `json:"concat"`
+		Tar        SkynetFormatStats `json:"tar"`
+		TarGz      SkynetFormatStats `json:"targz"`
+		Zip        SkynetFormatStats `json:"zip"`
+		BaseSector SkynetFormatStats `json:"basesector"`
+		Root       SkynetFormatStats `json:"root"`
+	}
+
+	// skynetPerformanceStats tracks the performance of skynet downloads.
+	skynetPerformanceStats struct {
+		formats SkynetFormatsStats
+		mu      sync.Mutex
+	}
+
+	// statsResponseWriter is a http.ResponseWriter which tracks the TTFB and
+	// throughput of a download and adds them to the skynet performance stats
+	// once the download is done.
+	statsResponseWriter struct {
+		http.ResponseWriter
+		staticFormat skymodules.SkyfileFormat
+		staticStart  time.Time
+		staticStats  *skynetPerformanceStats
+
+		firstByte  time.Time
+		statusCode int
+		written    uint64
+	}
+)
+
+// newSkynetPerformanceStats creates new skynet performance stats.
+func newSkynetPerformanceStats() *skynetPerformanceStats {
+	return &skynetPerformanceStats{}
+}
+
+// AddRequest adds a download of the given format to the stats.
+func (s *skynetPerformanceStats) AddRequest(format skymodules.SkyfileFormat, ttfb time.Duration, size uint64, total time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fs := s.formats.format(format)
+	if fs == nil {
+		return
+	}
+	fs.Requests++
+	if size == 0 {
+		return
+	}
+	ttfbMS := uint64(ttfb / time.Millisecond)
+	fs.TTFB[bucketIndex(SkynetStatsTTFBBucketsMS[:], ttfbMS)]++
+	if total <= 0 {
+		total = time.Nanosecond
+	}
+	throughput := uint64(float64(size) / total.Seconds())
+	fs.Throughput[bucketIndex(SkynetStatsThroughputBuckets[:], throughput)]++
+}
+
+// Formats returns a copy of the per-format stats.
+func (s *skynetPerformanceStats) Formats() SkynetFormatsStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.formats
+}
+
+// format returns the stats for the given format or nil if the format isn't
+// tracked.
+func (fs *SkynetFormatsStats) format(format skymodules.SkyfileFormat) *SkynetFormatStats {
+	switch format {
+	case skymodules.SkyfileFormatNotSpecified, skynetStatsFormatRaw:
+		return &fs.Raw
+	case skymodules.SkyfileFormatConcat:
+		return &fs.Concat
+	case skymodules.SkyfileFormatTar:
+		return &fs.Tar
+	case skymodules.SkyfileFormatTarGz:
+		return &fs.TarGz
+	case skymodules.SkyfileFormatZip:
+		return &fs.Zip
+	case skynetStatsFormatBaseSector:
+		return &fs.BaseSector
+	case skynetStatsFormatRoot:
+		return &fs.Root
+	}
+	return nil
+}
+
+// bucketIndex returns the index of the first bucket with an upper bound
+// greater than or equal to the value. Values exceeding the last bound are
+// assigned to the overflow bucket at index len(bounds).
+func bucketIndex(bounds []uint64, value uint64) int {
+	for i, bound := range bounds {
+		if value <= bound {
+			return i
+		}
+	}
+	return len(bounds)
+}
+
+// newStatsResponseWriter creates a new statsResponseWriter for a download of
+// the given format which was started at the given time.
+func newStatsResponseWriter(w http.ResponseWriter, stats *skynetPerformanceStats, format skymodules.SkyfileFormat, start time.Time) *statsResponseWriter {
+	return &statsResponseWriter{
+		ResponseWriter: w,
+		staticFormat:   format,
+		staticStart:    start,
+		staticStats:    stats,
+	}
+}
+
+// AddToStats adds the download to the skynet performance stats. It needs to
+// be called after the body was written. Failed downloads are ignored.
+func (sw *statsResponseWriter) AddToStats() {
+	if sw.statusCode >= http.StatusBadRequest {
+		return
+	}
+	now := time.Now()
+	sw.staticStats.AddRequest(sw.staticFormat, sw.firstByte.Sub(sw.staticStart), sw.written, now.Sub(sw.staticStart))
+}
+
+// Write implements the io.Writer interface.
+func (sw *statsResponseWriter) Write(b []byte) (int, error) {
+	if sw.firstByte.IsZero() && len(b) > 0 {
+		sw.firstByte = time.Now()
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.written += uint64(n)
+	return n, err
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (sw *statsResponseWriter) WriteHeader(statusCode int) {
+	if sw.statusCode == 0 {
+		sw.statusCode = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// trackSkynetDownload wraps the writer of a GET request in a
+// statsResponseWriter. The returned function adds the download to the stats
+// and needs to be called once the body was written.
+func (api *API) trackSkynetDownload(w http.ResponseWriter, req *http.Request, format skymodules.SkyfileFormat, start time.Time) (http.ResponseWriter, func()) {
+	if req.Method != http.MethodGet {
+		return w, func() {}
+	}
+	sw := newStatsResponseWriter(w, api.staticSkynetStats, format, start)
+	return sw, sw.AddToStats
+}
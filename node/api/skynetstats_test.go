@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// TestSkynetPerformanceStats is a unit test for skynetPerformanceStats.
+func TestSkynetPerformanceStats(t *testing.T) {
+	t.Parallel()
+
+	s := newSkynetPerformanceStats()
+
+	// 1 MiB in 1 second with a TTFB of 75ms.
+	s.AddRequest(skymodules.SkyfileFormatNotSpecified, 75*time.Millisecond, 1<<20, time.Second)
+	// 1 GiB in 1 second with a TTFB of 20s.
+	s.AddRequest(skymodules.SkyfileFormatZip, 20*time.Second, 1<<30, time.Second)
+	// Empty download.
+	s.AddRequest(skynetStatsFormatBaseSector, 0, 0, time.Second)
+	// Untracked format.
+	s.AddRequest(skymodules.SkyfileFormatIndex, time.Millisecond, 100, time.Second)
+
+	formats := s.Formats()
+	raw := formats.Raw
+	if raw.Requests != 1 || raw.TTFB[2] != 1 || raw.Throughput[2] != 1 {
+		t.Fatal("unexpected raw stats", raw)
+	}
+	zip := formats.Zip
+	if zip.Requests != 1 || zip.TTFB[len(SkynetStatsTTFBBucketsMS)] != 1 || zip.Throughput[len(SkynetStatsThroughputBuckets)] != 1 {
+		t.Fatal("unexpected zip stats", zip)
+	}
+	bs := formats.BaseSector
+	if bs.Requests != 1 || bs.TTFB != [len(SkynetStatsTTFBBucketsMS) + 1]uint64{} || bs.Throughput != [len(SkynetStatsThroughputBuckets) + 1]uint64{} {
+		t.Fatal("unexpected basesector stats", bs)
+	}
+	if formats.Concat.Requests+formats.Tar.Requests+formats.TarGz.Requests+formats.Root.Requests != 0 {
+		t.Fatal("unexpected requests", formats)
+	}
+
+	// The returned stats are a copy.
+	formats.Raw.Requests++
+	formats.Raw.TTFB[0]++
+	if s.Formats().Raw != raw {
+		t.Fatal("stats were modified through copy")
+	}
+}
+
+// TestBucketIndex is a unit test for bucketIndex.
+func TestBucketIndex(t *testing.T) {
+	t.Parallel()
+
+	bounds := []uint64{10, 20, 30}
+	tests := []struct {
+		value uint64
+		index int
+	}{
+		{0, 0},
+		{10, 0},
+		{11, 1},
+		{20, 1},
+		{30, 2},
+		{31, 3},
+		{1000, 3},
+	}
+	for _, test := range tests {
+		if index := bucketIndex(bounds, test.value); index != test.index {
+			t.Fatalf("%v: expected %v but got %v", test.value, test.index, index)
+		}
+	}
+}
+
+// TestStatsResponseWriter is a unit test for the statsResponseWriter.
+func TestStatsResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	// Write some data.
+	s := newSkynetPerformanceStats()
+	w := newTestHTTPWriter()
+	sw := newStatsResponseWriter(w, s, skymodules.SkyfileFormatTar, time.Now())
+	data := fastrand.Bytes(100)
+	_, err := sw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw.written != uint64(2*len(data)) {
+		t.Fatal("wrong number of bytes tracked", sw.written)
+	}
+	if sw.firstByte.IsZero() {
+		t.Fatal("first byte wasn't tracked")
+	}
+	sw.AddToStats()
+	if tar := s.Formats().Tar; tar.Requests != 1 || tar.TTFB[0] != 1 {
+		t.Fatal("unexpected tar stats", tar)
+	}
+
+	// Failed downloads are ignored.
+	sw = newStatsResponseWriter(newTestHTTPWriter(), s, skymodules.SkyfileFormatTar, time.Now())
+	sw.WriteHeader(http.StatusNotFound)
+	_, err = sw.Write([]byte("not found"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.AddToStats()
+	if tar := s.Formats().Tar; tar.Requests != 1 {
+		t.Fatal("failed download was tracked", tar)
+	}
+}
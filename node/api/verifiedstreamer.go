@@ -0,0 +1,103 @@
+package api
+
+import (
+	"io"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// verifiedStreamer is a helper struct that wraps a skymodules.SkyfileStreamer
+// and verifies its data one chunk at a time before serving it. Only the chunk
+// which is currently read from is held in memory and only the chunks
+// overlapping the read data are fetched, which allows for serving ranges of
+// large skyfiles. The metadata is taken from the wrapped streamer.
+//
+// Seeking is lazy. The wrapped streamer is only seeked once a chunk is read,
+// so the seeks performed by http.ServeContent to determine the size of the
+// content don't cause any data to be fetched.
+//
+// Note that the verifiedStreamer is not thread safe.
+type verifiedStreamer struct {
+	skymodules.SkyfileStreamer
+
+	off        uint64
+	chunk      []byte
+	chunkIndex uint64
+
+	staticFilesize uint64
+	staticVerifier *skymodules.SkyfileVerifier
+}
+
+// newVerifiedStreamer wraps the given streamer to verify its data using the
+// given verifier.
+func newVerifiedStreamer(s skymodules.SkyfileStreamer, sv *skymodules.SkyfileVerifier) skymodules.SkyfileStreamer {
+	return &verifiedStreamer{
+		SkyfileStreamer: s,
+		staticFilesize:  s.Layout().Filesize,
+		staticVerifier:  sv,
+	}
+}
+
+// Read implements the io.Reader interface
+func (vs *verifiedStreamer) Read(p []byte) (int, error) {
+	if vs.off >= vs.staticFilesize {
+		return 0, io.EOF
+	}
+	chunkSize := vs.staticVerifier.ChunkSize()
+	chunkIndex := vs.off / chunkSize
+	if vs.chunk == nil || vs.chunkIndex != chunkIndex {
+		err := vs.loadChunk(chunkIndex)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, vs.chunk[vs.off-chunkIndex*chunkSize:])
+	vs.off += uint64(n)
+	return n, nil
+}
+
+// Seek implements the io.Seeker interface
+func (vs *verifiedStreamer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += int64(vs.off)
+	case io.SeekEnd:
+		offset += int64(vs.staticFilesize)
+	default:
+		return 0, errors.New("invalid value for 'whence' in call to seek")
+	}
+	if offset < 0 {
+		return 0, errors.New("invalid offset")
+	}
+	vs.off = uint64(offset)
+	return offset, nil
+}
+
+// loadChunk reads the chunk with the given index from the wrapped streamer
+// and verifies it. On success, it replaces the currently loaded chunk.
+func (vs *verifiedStreamer) loadChunk(chunkIndex uint64) error {
+	chunkSize := vs.staticVerifier.ChunkSize()
+	start := chunkIndex * chunkSize
+	size := vs.staticFilesize - start
+	if size > chunkSize {
+		size = chunkSize
+	}
+	_, err := vs.SkyfileStreamer.Seek(int64(start), io.SeekStart)
+	if err != nil {
+		return errors.AddContext(err, "failed to seek to chunk")
+	}
+	chunk := make([]byte, size)
+	_, err = io.ReadFull(vs.SkyfileStreamer, chunk)
+	if err != nil {
+		return errors.AddContext(err, "failed to read chunk")
+	}
+	err = vs.staticVerifier.VerifyChunk(chunkIndex, chunk)
+	if err != nil {
+		return err
+	}
+	vs.chunk = chunk
+	vs.chunkIndex = chunkIndex
+	return nil
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestVerifiedStreamer tests that the verifiedStreamer serves the verified
+// data of a skyfile chunk by chunk.
+func TestVerifiedStreamer(t *testing.T) {
+	t.Parallel()
+
+	// Create a payload of two and a half chunks and a 1-of-N fanout for it.
+	ec, err := skymodules.NewRSSubCode(1, 1, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkSize := modules.SectorSize
+	data := fastrand.Bytes(int(2*chunkSize + chunkSize/2))
+	var fanout []byte
+	for start := uint64(0); start < uint64(len(data)); start += chunkSize {
+		chunk := make([]byte, chunkSize)
+		copy(chunk, data[start:])
+		root := crypto.MerkleRoot(chunk)
+		fanout = append(fanout, root[:]...)
+	}
+	layout := skymodules.NewSkyfileLayout(uint64(len(data)), 0, uint64(len(fanout)), ec, crypto.TypePlain)
+
+	// newStreamer is a helper to create a verified streamer for the given
+	// data.
+	newStreamer := func(data []byte) skymodules.SkyfileStreamer {
+		sv, err := skymodules.NewSkyfileVerifier(layout, fanout, crypto.GenerateSiaKey(crypto.TypePlain), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := renter.SkylinkStreamerFromSlice(data, skymodules.SkyfileMetadata{}, []byte{}, skymodules.Skylink{}, layout)
+		return newVerifiedStreamer(s, sv)
+	}
+
+	// The whole payload should be served.
+	allData, err := ioutil.ReadAll(newStreamer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(allData, data) {
+		t.Fatal("wrong data")
+	}
+
+	// Seek across a chunk boundary.
+	vs := newStreamer(data)
+	off := int64(chunkSize - 5)
+	n, err := vs.Seek(off, io.SeekStart)
+	if err != nil || n != off {
+		t.Fatal("unexpected seek result", n, err)
+	}
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(vs, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[off:off+10]) {
+		t.Fatal("wrong data after seek")
+	}
+
+	// Serve a range.
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=100-199")
+	http.ServeContent(rw, req, "", time.Time{}, newStreamer(data))
+	if rw.Code != http.StatusPartialContent {
+		t.Fatal("unexpected status", rw.Code)
+	}
+	if !bytes.Equal(rw.Body.Bytes(), data[100:200]) {
+		t.Fatal("wrong range")
+	}
+
+	// Corrupt the last chunk. The other chunks are still served while
+	// reading the last one fails.
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1]++
+	vs = newStreamer(corrupted)
+	_, err = io.ReadFull(vs, make([]byte, 2*chunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = vs.Read(buf)
+	if !errors.Contains(err, skymodules.ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+}
@@ -1,11 +1,124 @@
 package dependencies
 
+import (
+	"sync"
+	"sync/atomic"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// DependencyCountReadSector counts the number of read sector jobs that are
+// executed by the renter's workers.
+type DependencyCountReadSector struct {
+	atomicCount uint64
+	skymodules.SkynetDependencies
+}
+
+// NewDependencyCountReadSector creates a new DependencyCountReadSector.
+func NewDependencyCountReadSector() *DependencyCountReadSector {
+	return &DependencyCountReadSector{}
+}
+
+// Count returns the number of read sector jobs that were executed so far.
+func (d *DependencyCountReadSector) Count() uint64 {
+	return atomic.LoadUint64(&d.atomicCount)
+}
+
+// Disrupt increments the counter if the correct string is provided. It never
+// disrupts.
+func (d *DependencyCountReadSector) Disrupt(s string) bool {
+	if s == "CountReadSector" {
+		atomic.AddUint64(&d.atomicCount, 1)
+	}
+	return false
+}
+
+// DependencyCountSectors counts the number of sectors that are read and
+// uploaded by the renter's workers.
+type DependencyCountSectors struct {
+	atomicReads   uint64
+	atomicUploads uint64
+	skymodules.SkynetDependencies
+}
+
+// NewDependencyCountSectors creates a new DependencyCountSectors.
+func NewDependencyCountSectors() *DependencyCountSectors {
+	return &DependencyCountSectors{}
+}
+
+// Reads returns the number of sectors that were read so far.
+func (d *DependencyCountSectors) Reads() uint64 {
+	return atomic.LoadUint64(&d.atomicReads)
+}
+
+// Uploads returns the number of sectors that were uploaded so far.
+func (d *DependencyCountSectors) Uploads() uint64 {
+	return atomic.LoadUint64(&d.atomicUploads)
+}
+
+// Disrupt increments the counters if the correct string is provided. It never
+// disrupts.
+func (d *DependencyCountSectors) Disrupt(s string) bool {
+	switch s {
+	case "CountReadSector":
+		atomic.AddUint64(&d.atomicReads, 1)
+	case "CountUploadSector":
+		atomic.AddUint64(&d.atomicUploads, 1)
+	}
+	return false
+}
+
+// DependencyBlockSectors makes the renter's workers report sectors with the
+// blocked roots as unavailable to simulate lost data.
+type DependencyBlockSectors struct {
+	blocked map[crypto.Hash]struct{}
+	mu      sync.Mutex
+	skymodules.SkynetDependencies
+}
+
+// NewDependencyBlockSectors creates a new DependencyBlockSectors.
+func NewDependencyBlockSectors() *DependencyBlockSectors {
+	return &DependencyBlockSectors{
+		blocked: make(map[crypto.Hash]struct{}),
+	}
+}
+
+// Block blocks the sectors with the given roots.
+func (d *DependencyBlockSectors) Block(roots ...crypto.Hash) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, root := range roots {
+		d.blocked[root] = struct{}{}
+	}
+}
+
+// SectorUnavailable returns true if the sector with the given root is blocked.
+func (d *DependencyBlockSectors) SectorUnavailable(root crypto.Hash) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, blocked := d.blocked[root]
+	return blocked
+}
+
 // NewDependencySkipUnpinRequest skips submitting the unpin request.
 func NewDependencySkipUnpinRequest() *DependencyWithDisableAndEnable {
 	return newDependencywithDisableAndEnable("SkipUnpinRequest")
 }
 
+// NewDependencySkynetPersistReadOnly simulates a read-only persist dir for
+// updates of the skynet blocklist and portals list.
+func NewDependencySkynetPersistReadOnly() *DependencyWithDisableAndEnable {
+	return newDependencywithDisableAndEnable("SkynetPersistReadOnly")
+}
+
 // NewDependencyDoNotUploadFanout skips submitting the unpin request.
 func NewDependencyDoNotUploadFanout() *DependencyWithDisableAndEnable {
 	return newDependencywithDisableAndEnable("DoNotUploadFanout")
 }
+
+// NewDependencyBlockSkyfileConversion blocks asynchronous siafile conversions
+// after every converted chunk for as long as it is enabled.
+func NewDependencyBlockSkyfileConversion() *DependencyWithDisableAndEnable {
+	return newDependencywithDisableAndEnable("BlockSkyfileConversion")
+}
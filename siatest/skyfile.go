@@ -3,6 +3,7 @@ package siatest
 import (
 	"bytes"
 	"mime/multipart"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -121,6 +122,10 @@ func (tn *TestNode) UploadSkyfileCustom(filename string, filedata []byte, skykey
 		Force:               force,
 		Root:                false,
 		SkykeyName:          skykeyName,
+
+		// Set the modtime explicitly to make sure uploading the same
+		// parameters again results in the same skylink.
+		ModTime: time.Now().Unix(),
 	}
 
 	// upload a skyfile
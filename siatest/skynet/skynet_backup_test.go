@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"reflect"
 	"strings"
 	"sync"
@@ -42,6 +43,7 @@ func TestSkynetBackupAndRestore(t *testing.T) {
 		{Name: "DirectoryBasic", Test: testDirectoryBasic},
 		{Name: "DirectoryNested", Test: testDirectoryNested},
 		{Name: "ConvertedSiafile", Test: testConvertedSiaFile},
+		{Name: "Archive", Test: testArchive},
 	}
 
 	// Run tests
@@ -353,6 +355,95 @@ func testConvertedSiaFile(t *testing.T, tg *siatest.TestGroup) {
 	convertTest("largeSiafile_Encryption", sk.Name, largeSize)
 }
 
+// testArchive verifies that skyfiles can be backed up as an archive of their
+// raw sectors and then restored from that archive.
+func testArchive(t *testing.T, tg *siatest.TestGroup) {
+	portal := tg.Portals()[0]
+
+	// Add a SkyKey to the portal
+	sk, err := portal.SkykeyCreateKeyPost("archive", skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Define test function
+	archiveTest := func(t *testing.T, filename, skykeyName string, data []byte) {
+		skylink, sup, _, err := portal.UploadNewEncryptedSkyfileBlocking(filename, data, skykeyName, true)
+		if err != nil {
+			t.Fatalf("Failed to upload: %v", err)
+		}
+
+		// Fetch the archive.
+		reader, err := portal.SkynetBackupArchiveGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive, err := ioutil.ReadAll(reader)
+		if err := errors.Compose(err, reader.Close()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Fetching a range of the archive should return the same data.
+		from, to := uint64(len(archive)/3), uint64(len(archive)/2)
+		part, err := portal.SkynetBackupArchiveRangeGet(skylink, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(part, archive[from:to]) {
+			t.Fatal("range of archive doesn't match")
+		}
+
+		// Delete the skyfile.
+		skySiaPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = portal.RenterFileDeleteRootPost(skySiaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skySiaPathExtended, err := skySiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = portal.RenterFileDeleteRootPost(skySiaPathExtended)
+		if err != nil && !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+			t.Fatal(err)
+		}
+
+		// Restore the skyfile from the archive.
+		restoredSkylink, err := portal.SkynetSkylinkRestorePost(bytes.NewReader(archive))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restoredSkylink != skylink {
+			t.Fatalf("Skylinks not equal\nOriginal: %v\nRestored %v\n", skylink, restoredSkylink)
+		}
+
+		// The restored skyfile should contain the original data.
+		downloaded, err := portal.SkynetSkylinkGet(restoredSkylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded, data) {
+			t.Fatal("restored data doesn't match")
+		}
+	}
+
+	smallData := fastrand.Bytes(100)
+	largeData := fastrand.Bytes(3*int(modules.SectorSize) + siatest.Fuzz())
+	parentTestName := t.Name()
+	t.Run("Small", func(t *testing.T) {
+		archiveTest(t, fmt.Sprintf("%s-%s", parentTestName, t.Name()), "", smallData)
+	})
+	t.Run("Large", func(t *testing.T) {
+		archiveTest(t, fmt.Sprintf("%s-%s", parentTestName, t.Name()), "", largeData)
+	})
+	t.Run("Large_encrypted", func(t *testing.T) {
+		archiveTest(t, fmt.Sprintf("%s-%s", parentTestName, t.Name()), sk.Name, largeData)
+	})
+}
+
 // verifyBackupAndRestore verifies the backup and restore functionality of
 // skynet for the provided skylink
 func verifyBackupAndRestore(tg *siatest.TestGroup, portal1, portal2 *siatest.TestNode, skylink, siaPath string) error {
@@ -50,6 +50,7 @@ func TestSkynetDownloads(t *testing.T) {
 		{Name: "ContentDisposition", Test: testDownloadContentDisposition},
 		{Name: "SkynetSkylinkHeader", Test: testSkynetSkylinkHeader},
 		{Name: "ETag", Test: testETag},
+		{Name: "DeterministicArchive", Test: testDownloadDeterministicArchive},
 	}
 
 	// Run tests
@@ -218,6 +219,11 @@ func testDownloadDirectoryBasic(t *testing.T, tg *siatest.TestGroup) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// The modtime is set by the node at upload.
+	if md.ModTime == 0 {
+		t.Fatal("modtime not set")
+	}
+	expectedMetadata.ModTime = md.ModTime
 	if !reflect.DeepEqual(md, expectedMetadata) {
 		t.Fatal("mismatch")
 	}
@@ -318,7 +324,7 @@ func testDownloadDirectoryBasic(t *testing.T, tg *siatest.TestGroup) {
 	}
 
 	// verify some errors on upload
-	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking("DirectoryBasic", files, "notexists.html", false, false)
+	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking("DirectoryBasic", files, "notexists.html", false, true)
 	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidDefaultPath.Error()) {
 		t.Errorf("Expected '%v' instead error was '%v'", skymodules.ErrInvalidDefaultPath, err)
 	}
@@ -399,6 +405,11 @@ func testDownloadDirectoryNested(t *testing.T, tg *siatest.TestGroup) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// The modtime is set by the node at upload.
+	if md.ModTime == 0 {
+		t.Fatal("modtime not set")
+	}
+	expectedMetadata.ModTime = md.ModTime
 	if !reflect.DeepEqual(md, expectedMetadata) {
 		t.Fatal("mismatch")
 	}
@@ -542,7 +553,7 @@ func testDownloadContentDisposition(t *testing.T, tg *siatest.TestGroup) {
 		{Name: "index.html", Data: []byte("index.html_contents")},
 		{Name: "about.html", Data: []byte("about.html_contents")},
 	}
-	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking("DirectoryBasic", files, "", false, false)
+	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking(t.Name(), files, "", false, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -747,6 +758,96 @@ func testETag(t *testing.T, tg *siatest.TestGroup) {
 	if eTagForV2Skylink != eTag {
 		t.Fatal("Unexpected ETag")
 	}
+
+	// enable weak ETags
+	err = r.RenterSkynetWeakETagsPost(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.RenterSkynetWeakETagsPost(false); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rg.Settings.SkynetWeakETags {
+		t.Fatal("weak ETags should be enabled")
+	}
+
+	// verify the ETag is the weak version of the strong one
+	resp, err = uc.SkynetSkylinkGetWithETag(skylink, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	weakETag := resp.Header.Get("ETag")
+	if weakETag != "W/"+eTag {
+		t.Fatal("Unexpected weak ETag", weakETag)
+	}
+
+	// verify both the weak and the strong ETag hit the cache since
+	// If-None-Match uses the weak comparison
+	for _, et := range []string{weakETag, eTag} {
+		resp, err = uc.SkynetSkylinkGetWithETag(skylink, et)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatal("Unexpected status code", resp.StatusCode)
+		}
+	}
+}
+
+// testDownloadDeterministicArchive verifies that downloading the same skylink
+// as an archive multiple times always results in the same bytes.
+func testDownloadDeterministicArchive(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a directory which contains empty files since those share their
+	// offset with the following file.
+	files := []siatest.TestFile{
+		{Name: "a.txt", Data: []byte("a.txt_contents")},
+		{Name: "empty1", Data: []byte{}},
+		{Name: "empty2", Data: []byte{}},
+		{Name: "empty3", Data: []byte{}},
+		{Name: "dir/b.txt", Data: []byte("b.txt_contents")},
+		{Name: "dir/empty", Data: []byte{}},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(t.Name(), files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formats := []skymodules.SkyfileFormat{skymodules.SkyfileFormatZip, skymodules.SkyfileFormatTar, skymodules.SkyfileFormatTarGz}
+	for _, format := range formats {
+		var archive []byte
+		for i := 0; i < 5; i++ {
+			_, reader, err := r.SkynetSkylinkFormatGet(skylink, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadAll(reader)
+			err = errors.Compose(err, reader.Close())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if archive == nil {
+				archive = data
+			} else if !bytes.Equal(archive, data) {
+				t.Fatalf("%v archive changed between downloads", format)
+			}
+		}
+	}
 }
 
 // testSkynetSkylinkHeader tests that the 'Skynet-Skylink' is set both on the
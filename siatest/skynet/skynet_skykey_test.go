@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 
 	"gitlab.com/NebulousLabs/fastrand"
@@ -36,6 +37,9 @@ func TestSkykey(t *testing.T) {
 		{Name: "AddSkykey", Test: testAddSkykey},
 		{Name: "CreateSkykey", Test: testCreateSkykey},
 		{Name: "DeleteSkykey", Test: testDeleteSkykey},
+		{Name: "RenameSkykey", Test: testRenameSkykey},
+		{Name: "ExportImportSkykey", Test: testExportImportSkykey},
+		{Name: "MatchSkykey", Test: testMatchSkykey},
 		{Name: "EncryptionTypePrivateID", Test: testSkynetEncryptionWithType(skykey.TypePrivateID)},
 		{Name: "EncryptionTypePublicID", Test: testSkynetEncryptionWithType(skykey.TypePublicID)},
 		{Name: "LargeFilePrivateID", Test: testSkynetEncryptionLargeFileWithType(skykey.TypePrivateID)},
@@ -372,6 +376,177 @@ func testDeleteSkykey(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testRenameSkykey tests the rename functionality of the Skykey manager.
+func testRenameSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create two skykeys.
+	sk1, err := r.SkykeyCreateKeyPost(t.Name()+"-1", skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk2, err := r.SkykeyCreateKeyPost(t.Name()+"-2", skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renaming a key to an existing name should fail.
+	err = r.SkykeyRenamePost(sk1.Name, sk2.Name)
+	if err == nil || !strings.Contains(err.Error(), skykey.ErrSkykeyWithNameAlreadyExists.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Renaming an unknown key should fail.
+	err = r.SkykeyRenamePost(t.Name()+"-unknown", t.Name()+"-new")
+	if err == nil || !strings.Contains(err.Error(), skykey.ErrNoSkykeysWithThatName.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Rename the first key.
+	newName := t.Name() + "-renamed"
+	err = r.SkykeyRenamePost(sk1.Name, newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key should be available under the new name with the same ID and
+	// entropy.
+	renamed, err := r.SkykeyGetByName(newName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.ID() != sk1.ID() || renamed.Type != sk1.Type || !bytes.Equal(renamed.Entropy, sk1.Entropy) {
+		t.Fatal("renamed key doesn't match original key")
+	}
+	renamed, err = r.SkykeyGetByID(sk1.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != newName {
+		t.Fatal("wrong name", renamed.Name)
+	}
+
+	// The old name should no longer be in use.
+	_, err = r.SkykeyGetByName(sk1.Name)
+	if err == nil || !strings.Contains(err.Error(), skykey.ErrNoSkykeysWithThatName.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testExportImportSkykey tests exporting and importing password protected
+// skykeys.
+func testExportImportSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	for _, skykeyType := range []skykey.SkykeyType{skykey.TypePublicID, skykey.TypePrivateID} {
+		name := t.Name() + "-" + skykeyType.ToString()
+		sk, err := r.SkykeyCreateKeyPost(name, skykeyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Export the key.
+		password := "password"
+		export, err := r.SkykeyExportPost(name, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if export.Name != name || export.ID != sk.ID().ToString() {
+			t.Fatal("unexpected export", export)
+		}
+
+		// Exporting an unknown key should fail.
+		_, err = r.SkykeyExportPost(name+"-unknown", password)
+		if err == nil || !strings.Contains(err.Error(), skykey.ErrNoSkykeysWithThatName.Error()) {
+			t.Fatal("unexpected error", err)
+		}
+
+		// Importing the key while it still exists should fail.
+		_, err = r.SkykeyImportPost(export.Export, password)
+		if err == nil || !strings.Contains(err.Error(), skykey.ErrSkykeyWithIDAlreadyExists.Error()) {
+			t.Fatal("unexpected error", err)
+		}
+
+		// Delete the key and import it with the wrong password.
+		err = r.SkykeyDeleteByNamePost(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.SkykeyImportPost(export.Export, "wrong password")
+		if err == nil || !strings.Contains(err.Error(), skykey.ErrInvalidExportPassword.Error()) {
+			t.Fatal("unexpected error", err)
+		}
+
+		// Import it with the right password.
+		imported, err := r.SkykeyImportPost(export.Export, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported.ID() != sk.ID() || imported.Name != name {
+			t.Fatal("imported key doesn't match original key")
+		}
+		fetched, err := r.SkykeyGetByID(sk.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fetched.Type != sk.Type || !bytes.Equal(fetched.Entropy, sk.Entropy) {
+			t.Fatal("imported key doesn't match original key")
+		}
+	}
+}
+
+// testMatchSkykey tests the /skynet/skykeys/match/:skylink endpoint.
+func testMatchSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	for _, skykeyType := range []skykey.SkykeyType{skykey.TypePublicID, skykey.TypePrivateID} {
+		name := t.Name() + "-" + skykeyType.ToString()
+		sk, err := r.SkykeyCreateKeyPost(name, skykeyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Upload an encrypted file and match it.
+		skylink, _, _, err := r.UploadNewEncryptedSkyfileBlocking(name, fastrand.Bytes(100), name, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match, err := r.SkykeysMatchGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match.Name != name || match.ID != sk.ID().ToString() || match.Type != skykeyType.ToString() {
+			t.Fatal("unexpected match", match)
+		}
+
+		// Delete the key. There shouldn't be a match anymore.
+		err = r.SkykeyDeleteByNamePost(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.SkykeysMatchGet(skylink)
+		if err == nil || !strings.Contains(err.Error(), "none of the node's skykeys match the skyfile") {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	// Unencrypted files can't be matched.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name()+"-unencrypted", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkykeysMatchGet(skylink)
+	if err == nil || !strings.Contains(err.Error(), "skyfile is not encrypted") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Invalid skylinks are rejected.
+	_, err = r.SkykeysMatchGet("invalid")
+	if err == nil || !strings.Contains(err.Error(), "error parsing skylink") {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 // testUnsafeClient tests the Skykey manager functionality using an unsafe
 // client.
 func testUnsafeClient(t *testing.T, tg *siatest.TestGroup) {
@@ -570,7 +745,7 @@ func testSkynetEncryption(t *testing.T, tg *siatest.TestGroup, skykeyType skykey
 		Root:                false,
 		BaseChunkRedundancy: 3,
 	}
-	err = r.SkynetSkylinkPinPost(skylink, pinLUP)
+	_, err = r.SkynetSkylinkPinPost(skylink, pinLUP)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -647,7 +822,7 @@ func testSkynetEncryptionLargeFile(t *testing.T, tg *siatest.TestGroup, skykeyTy
 		Root:                false,
 		BaseChunkRedundancy: 2,
 	}
-	err = r.SkynetSkylinkPinPost(skylink, pinLUP)
+	_, err = r.SkynetSkylinkPinPost(skylink, pinLUP)
 	if err != nil {
 		t.Fatal(err)
 	}
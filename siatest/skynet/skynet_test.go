@@ -2,16 +2,21 @@ package skynet
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -64,10 +69,46 @@ func TestSkynetSuiteOne(t *testing.T) {
 		{Name: "InvalidFilename", Test: testSkynetInvalidFilename},
 		{Name: "SubDirDownload", Test: testSkynetSubDirDownload},
 		{Name: "DisableForce", Test: testSkynetDisableForce},
+		{Name: "UploadIfExists", Test: testSkynetUploadIfExists},
+		{Name: "Canonicalize", Test: testSkynetCanonicalize},
+		{Name: "TwoPhaseUpload", Test: testSkynetTwoPhaseUpload},
 		{Name: "Portals", Test: testSkynetPortals},
 		{Name: "IncludeLayout", Test: testSkynetIncludeLayout},
+		{Name: "UploadIncludeMetadata", Test: testSkynetUploadIncludeMetadata},
+		{Name: "Symlinks", Test: testSkynetSymlinks},
 		{Name: "RequestTimeout", Test: testSkynetRequestTimeout},
 		{Name: "DryRunUpload", Test: testSkynetDryRunUpload},
+		{Name: "SkylinkHint", Test: testSkynetSkylinkHint},
+		{Name: "ExpectContinue", Test: testSkynetExpectContinue},
+		{Name: "FanoutPieces", Test: testSkynetFanoutPieces},
+		{Name: "SkylinkEncoding", Test: testSkynetSkylinkEncoding},
+		{Name: "SkylinkChunk", Test: testSkynetSkylinkChunk},
+		{Name: "Manifest", Test: testSkynetManifest},
+		{Name: "SkyfileVerify", Test: testSkynetSkyfileVerify},
+		{Name: "Trace", Test: testSkynetTrace},
+		{Name: "GC", Test: testSkynetGC},
+		{Name: "MaxUploadSize", Test: testSkynetMaxUploadSize},
+		{Name: "MultipartSizeMismatch", Test: testSkynetMultipartSizeMismatch},
+		{Name: "UploadPolicy", Test: testSkynetUploadPolicy},
+		{Name: "MultipartLimits", Test: testSkynetMultipartLimits},
+		{Name: "ResumableMultipartUpload", Test: testSkynetResumableMultipartUpload},
+		{Name: "UploadEstimate", Test: testSkynetUploadEstimate},
+		{Name: "PinEstimate", Test: testSkynetPinEstimate},
+		{Name: "PinTTL", Test: testSkynetPinTTL},
+		{Name: "StorageCap", Test: testSkynetStorageCap},
+		{Name: "Snapshot", Test: testSkynetSnapshot},
+		{Name: "Search", Test: testSkynetSearch},
+		{Name: "DataURI", Test: testSkynetDataURI},
+		{Name: "DefaultBaseChunkRedundancy", Test: testSkynetDefaultBaseChunkRedundancy},
+		{Name: "Maintenance", Test: testSkynetMaintenance},
+		{Name: "UploadRateLimit", Test: testSkynetUploadRateLimit},
+		{Name: "APIKeys", Test: testSkynetAPIKeys},
+		{Name: "CORS", Test: testSkynetCORS},
+		{Name: "Verify", Test: testSkynetVerify},
+		{Name: "LastModified", Test: testSkynetLastModified},
+		{Name: "ContentTypeOverride", Test: testSkynetContentTypeOverride},
+		{Name: "Preview", Test: testSkynetPreview},
+		{Name: "ServingProof", Test: testSkynetServingProof},
 		{Name: "RegressionTimeoutPanic", Test: testRegressionTimeoutPanic},
 		{Name: "RenameSiaPath", Test: testRenameSiaPath},
 		{Name: "NoWorkers", Test: testSkynetNoWorkers},
@@ -105,14 +146,29 @@ func TestSkynetSuiteTwo(t *testing.T) {
 		{Name: "DownloadFormats", Test: testSkynetDownloadFormats},
 		{Name: "DownloadBaseSector", Test: testSkynetDownloadBaseSectorNoEncryption},
 		{Name: "DownloadBaseSectorEncrypted", Test: testSkynetDownloadBaseSectorEncrypted},
+		{Name: "MetadataEncrypted", Test: testSkynetMetadataEncrypted},
+		{Name: "DownloadWithSkykey", Test: testSkynetDownloadWithSkykey},
 		{Name: "FanoutRegression", Test: testSkynetFanoutRegression},
 		{Name: "DownloadRange", Test: testSkynetDownloadRange},
 		{Name: "DownloadRangeEncrypted", Test: testSkynetDownloadRangeEncrypted},
+		{Name: "DownloadChecksum", Test: testSkynetDownloadChecksum},
+		{Name: "DownloadHostStats", Test: testSkynetDownloadHostStats},
+		{Name: "DownloadRetries", Test: testSkynetDownloadRetries},
 		{Name: "Registry", Test: testSkynetRegistryReadWrite},
 		{Name: "Stats", Test: testSkynetStats},
 		{Name: "RegistryUpdateMulti", Test: testUpdateRegistryMulti},
+		{Name: "RegistryUpdateBatch", Test: testUpdateRegistryBatch},
+		{Name: "RegistryKeys", Test: testRegistryKeys},
+		{Name: "Publish", Test: testSkynetPublish},
+		{Name: "RegistryLarge", Test: testSkynetRegistryLarge},
+		{Name: "Redirect", Test: testSkynetRedirect},
+		{Name: "PinManifest", Test: testSkynetPinManifest},
+		{Name: "PinFrom", Test: testSkynetPinFrom},
+		{Name: "Allowlist", Test: testSkynetAllowlist},
 		{Name: "HostsForRegistryUpdate", Test: testHostsForRegistryUpdate},
 		{Name: "RecursiveBaseSector", Test: testRecursiveBaseSector},
+		{Name: "Diff", Test: testSkynetDiff},
+		{Name: "Bundle", Test: testSkynetBundle},
 	}
 
 	// Run tests
@@ -166,6 +222,16 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 	if rshp.Bitfield != realSkylink.Bitfield() {
 		t.Fatal("mismatch")
 	}
+	_, fetchSize, err := realSkylink.OffsetAndFetchSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rshp.Version != realSkylink.Version() || rshp.Version != 1 {
+		t.Fatal("unexpected version", rshp.Version)
+	}
+	if rshp.FetchSize != fetchSize {
+		t.Fatal("unexpected fetch size", rshp.FetchSize, fetchSize)
+	}
 
 	// Check the redundancy on the file.
 	skynetUploadPath, err := skymodules.SkynetFolder.Join(uploadSiaPath.String())
@@ -565,16 +631,31 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 		Root:                false,
 		BaseChunkRedundancy: 2,
 	}
-	err = r.SkynetSkylinkPinPost(skylink, pinLUP)
+	pinLUP.WaitHealthy = true
+	pin, err := r.SkynetSkylinkPinPost(skylink, pinLUP)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Get the list of files in the skynet directory and see if the file is
-	// present.
+	// The response should point to the pinned file in the skynet folder.
 	fullPinSiaPath, err := skymodules.SkynetFolder.Join(pinSiaPath.String())
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !pin.SiaPath.Equals(fullPinSiaPath) {
+		t.Fatal("siapath mismatch", pin.SiaPath, fullPinSiaPath)
+	}
+	if pin.ExtendedSiaPath != nil {
+		t.Fatal("small file shouldn't have an extended siapath", pin.ExtendedSiaPath)
+	}
+	if pin.Skylink != skylink {
+		t.Fatal("skylink mismatch")
+	}
+	if pin.Size != modules.SectorSize {
+		t.Fatal("unexpected size", pin.Size)
+	}
+	if pin.Redundancy < 1 {
+		t.Fatal("file should be healthy", pin.Redundancy)
+	}
 	// See if the file is present.
 	pinnedFile, err := r.RenterFileRootGet(fullPinSiaPath)
 	if err != nil {
@@ -611,7 +692,7 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 		Root:                false,
 		BaseChunkRedundancy: 2,
 	}
-	err = r.SkynetSkylinkPinPost(largeSkylink, largePinLUP)
+	_, err = r.SkynetSkylinkPinPost(largeSkylink, largePinLUP)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -626,7 +707,7 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 		Force:   force,
 		Root:    false,
 	}
-	err = r.SkynetSkylinkPinPost(largeSkylink, largePinLUP)
+	pin, err = r.SkynetSkylinkPinPost(largeSkylink, largePinLUP)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -635,6 +716,19 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !pin.SiaPath.Equals(fullLargePinSiaPath) {
+		t.Fatal("siapath mismatch", pin.SiaPath, fullLargePinSiaPath)
+	}
+	extendedLargePinSiaPath, err := fullLargePinSiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ExtendedSiaPath == nil || !pin.ExtendedSiaPath.Equals(extendedLargePinSiaPath) {
+		t.Fatal("extended siapath mismatch", pin.ExtendedSiaPath)
+	}
+	if pin.Size <= modules.SectorSize {
+		t.Fatal("unexpected size", pin.Size)
+	}
 	pinnedFile, err = r.RenterFileRootGet(fullLargePinSiaPath)
 	if err != nil {
 		t.Fatal(err)
@@ -656,6 +750,49 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 		t.Fatal("skyfile still present after deletion")
 	}
 
+	// Pin only the base sector of the large skylink. This should not create
+	// an extended siafile for the fanout.
+	largePinSiaPath, err = skymodules.NewSiaPath("testLargePinPathBaseSectorOnly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	largePinLUP = skymodules.SkyfilePinParameters{
+		SiaPath:        largePinSiaPath,
+		Force:          force,
+		Root:           false,
+		BaseSectorOnly: true,
+	}
+	pin, err = r.SkynetSkylinkPinPost(largeSkylink, largePinLUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ExtendedSiaPath != nil {
+		t.Fatal("base sector only pin shouldn't have an extended siapath", pin.ExtendedSiaPath)
+	}
+	fullLargePinSiaPath, err = skymodules.SkynetFolder.Join(largePinSiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinnedFile, err = r.RenterFileRootGet(fullLargePinSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pinnedFile.File.Skylinks) != 1 || pinnedFile.File.Skylinks[0] != largeSkylink {
+		t.Fatal("skylink mismatch", pinnedFile.File.Skylinks)
+	}
+	extendedPinSiaPath, err := fullLargePinSiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(extendedPinSiaPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("extended siafile shouldn't exist", err)
+	}
+	err = r.RenterFileDeleteRootPost(fullLargePinSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// TODO: We don't actually check at all whether the presence of the new
 	// skylinks is going to keep the file online. We could do that by deleting
 	// the old files and then churning the hosts over, and checking that the
@@ -674,3646 +811,9654 @@ func testSkynetBasic(t *testing.T, tg *siatest.TestGroup) {
 	// easier way.
 }
 
-// testConvertSiaFile tests converting a siafile to a skyfile. This test checks
-// for 1-of-N redundancies and N-of-M redundancies.
-func testConvertSiaFile(t *testing.T, tg *siatest.TestGroup) {
-	t.Run("1-of-N Conversion", func(t *testing.T) {
-		testConversion(t, tg, 1, 2, t.Name())
-	})
-	t.Run("N-of-M Conversion", func(t *testing.T) {
-		testConversion(t, tg, 2, 1, t.Name())
-	})
+// testSkynetSkyfileVerify tests the /skynet/skyfile/verify endpoint.
+func testSkynetSkyfileVerify(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// A regular upload is consistent.
+	files := []siatest.TestFile{
+		{Name: "a.txt", Data: fastrand.Bytes(10)},
+		{Name: "empty", Data: []byte{}},
+		{Name: "b.txt", Data: fastrand.Bytes(20)},
+	}
+	skylinkStr, _, _, err := r.UploadNewMultipartSkyfileBlocking(t.Name(), files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skylink skymodules.Skylink
+	err = skylink.LoadString(skylinkStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := r.SkyfileVerifyGET(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Consistent || len(sc.Inconsistencies) != 0 {
+		t.Fatal("unexpected result", sc)
+	}
+
 }
 
-// testConversion is a subtest for testConvertSiaFile
-func testConversion(t *testing.T, tg *siatest.TestGroup, dp, pp uint64, skykeyName string) {
+// testSkynetGC tests the /skynet/gc endpoint without deleting any of the
+// skyfiles the other subtests rely on.
+func testSkynetGC(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
-	// Upload a siafile that will then be converted to a skyfile.
-	filesize := int(modules.SectorSize) + siatest.Fuzz()
-	localFile, remoteFile, err := r.UploadNewFileBlocking(filesize, dp, pp, false)
+
+	// isCandidate is a helper to check whether a skylink is a gc candidate.
+	isCandidate := func(olderThan time.Duration, skylink string) bool {
+		sgp, err := r.SkynetGCPost(olderThan, true, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sgp.NumFiles != uint64(len(sgp.Candidates)) {
+			t.Fatal("wrong number of files", sgp.NumFiles, len(sgp.Candidates))
+		}
+		for _, candidate := range sgp.Candidates {
+			for _, sl := range candidate.Skylinks {
+				if sl == skylink {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// A retention window of 0 is rejected.
+	_, err := r.SkynetGCPost(0, true, "")
+	if err == nil || !strings.Contains(err.Error(), "'olderthan' needs to be greater than 0") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Upload a skyfile. It was just uploaded so it's not a candidate.
+	skylink, sup, _, err := r.UploadNewSkyfileBlocking("gc", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if isCandidate(time.Hour, skylink) {
+		t.Fatal("skyfile shouldn't be a candidate")
+	}
 
-	// Get the local and remote data for comparison
-	localData, err := localFile.Data()
+	// Wait for the skyfile to become older than the retention window.
+	time.Sleep(2 * time.Second)
+	if !isCandidate(time.Second, skylink) {
+		t.Fatal("skyfile should be a candidate")
+	}
+
+	// Download the skyfile. It is no longer a candidate.
+	_, err = r.SkynetSkylinkGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, remoteData, err := r.DownloadByStream(remoteFile)
+	if isCandidate(time.Second, skylink) {
+		t.Fatal("skyfile shouldn't be a candidate after downloading it")
+	}
+
+	// The dry runs didn't delete the skyfile.
+	sp, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(sp)
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	// Create Skyfile Upload Parameters
-	sup := skymodules.SkyfileUploadParameters{
-		SiaPath: skymodules.RandomSiaPath(),
+// testSkynetTrace tests that the trace ID of a skynet request is echoed in the
+// response and can be used to fetch the log lines of the request.
+func testSkynetTrace(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	uc := client.NewUnsafeClient(r.Client)
+
+	// Requests without a trace ID get a generated one.
+	resp, err := uc.GetWithHeaders("/skynet/blocklist/hits", http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if err := skymodules.ValidateTraceID(resp.Header.Get(api.SkynetTraceIDHeader)); err != nil {
+		t.Fatal("invalid generated trace id", err)
 	}
 
-	// Try and convert to a Skyfile
-	sshp, err := r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
+	// Requests with an invalid trace ID are rejected.
+	resp, err = uc.GetWithHeaders("/skynet/blocklist/hits", http.Header{api.SkynetTraceIDHeader: []string{"invalid id"}})
 	if err != nil {
-		t.Fatal("Expected conversion from Siafile to Skyfile Post to succeed.")
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("unexpected status code", resp.StatusCode)
 	}
 
-	// Try to download the skylink.
-	skylink := sshp.Skylink
-	fetchedData, err := r.SkynetSkylinkGet(skylink)
+	// Download a skylink that doesn't exist.
+	traceID := skymodules.NewTraceID()
+	skylink, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = uc.SkynetSkylinkGetWithTraceID(skylink.String(), traceID, url.Values{"timeout": []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+		TraceID string `json:"traceid"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&apiErr)
+	err = errors.Compose(err, resp.Body.Close())
 	if err != nil {
 		t.Fatal(err)
 	}
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("download should fail")
+	}
 
-	// Compare the data fetched from the Skylink to the local data and the
-	// previously uploaded data
-	if !bytes.Equal(fetchedData, localData) {
-		t.Error("converted skylink data doesn't match local data")
+	// The trace ID is echoed in the header and the error.
+	if resp.Header.Get(api.SkynetTraceIDHeader) != traceID {
+		t.Fatal("wrong trace id in header", resp.Header.Get(api.SkynetTraceIDHeader))
 	}
-	if !bytes.Equal(fetchedData, remoteData) {
-		t.Error("converted skylink data doesn't match remote data")
+	if apiErr.TraceID != traceID || apiErr.Message == "" {
+		t.Fatal("wrong error", apiErr)
 	}
 
-	// Converting with encryption is not supported. Call the convert method to
-	// ensure we do not panic and we return the expected error
-	//
-	// Add SkyKey
-	sk, err := r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+	// The trace contains the start and the failure of the download.
+	stg, err := r.SkynetTraceGet(traceID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if stg.TraceID != traceID || len(stg.Entries) < 2 {
+		t.Fatal("unexpected trace", stg)
+	}
+	first, last := stg.Entries[0], stg.Entries[len(stg.Entries)-1]
+	if !strings.Contains(first.Message, "started") || !strings.Contains(first.Message, skylink.String()) {
+		t.Fatal("unexpected first entry", first.Message)
+	}
+	if !strings.Contains(last.Message, "failed") || !strings.Contains(last.Message, skylink.String()) {
+		t.Fatal("unexpected last entry", last.Message)
+	}
+	if last.Time.Before(first.Time) {
+		t.Fatal("entries are out of order")
+	}
 
-	// Convert file again
-	sup.SkykeyName = sk.Name
-	sup.Force = true
+	// Other traces are empty.
+	stg, err = r.SkynetTraceGet(skymodules.NewTraceID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stg.Entries) != 0 {
+		t.Fatal("unexpected entries", stg.Entries)
+	}
 
-	// Convert to a Skyfile
-	_, err = r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
-	if err == nil || !strings.Contains(err.Error(), renter.ErrEncryptionNotSupported.Error()) {
-		t.Fatalf("Expected error %v, but got %v", renter.ErrEncryptionNotSupported, err)
+	// Traces contain internal log lines so they require authentication.
+	c := r.Client
+	c.Password = "wrong"
+	_, err = c.SkynetTraceGet(traceID)
+	if err == nil || !strings.Contains(err.Error(), "API authentication failed") {
+		t.Fatal("expected unauthenticated request to fail", err)
 	}
 }
 
-// testSkynetMultipartUpload tests you can perform a multipart upload. It will
-// verify the upload without any subfiles, with small subfiles and with large
-// subfiles. Small files are files which are smaller than one sector, and thus
-// don't need a fanout. Large files are files what span multiple sectors
-func testSkynetMultipartUpload(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetManifest tests the /skynet/manifest/:skylink endpoint.
+func testSkynetManifest(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
-	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
+
+	// Upload a multipart skyfile with a large subfile.
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index")},
+		{Name: "dir/large", Data: fastrand.Bytes(int(modules.SectorSize) + siatest.Fuzz())},
+		{Name: "empty", Data: []byte{}},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(t.Name(), files, "", false, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Test no files provided
-	fileName := "TestNoFileUpload"
-	_, _, _, err = r.UploadNewMultipartSkyfileBlocking(fileName, nil, "", false, false)
-	if err == nil || !strings.Contains(err.Error(), "could not find multipart file") {
-		t.Fatal("Expected upload to fail because no files are given, err:", err)
+	// The manifest should contain all files with the correct hashes.
+	manifest, err := r.SkynetManifestGet(skylink, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Skylink != skylink || len(manifest.Subfiles) != len(files) {
+		t.Fatal("unexpected manifest", manifest)
+	}
+	subfiles := make(map[string]api.SkynetManifestSubfile)
+	for _, sf := range manifest.Subfiles {
+		subfiles[sf.Path] = sf
+	}
+	for _, file := range files {
+		sf, exists := subfiles[file.Name]
+		if !exists {
+			t.Fatal("missing subfile", file.Name)
+		}
+		if sf.Length != uint64(len(file.Data)) {
+			t.Fatalf("%v: expected length %v but got %v", file.Name, len(file.Data), sf.Length)
+		}
+		if sf.Hash == nil || *sf.Hash != crypto.HashBytes(file.Data) {
+			t.Fatalf("%v: hash mismatch", file.Name)
+		}
+	}
+	if subfiles["index.html"].ContentType != "text/html; charset=utf-8" {
+		t.Fatal("unexpected content type", subfiles["index.html"].ContentType)
 	}
 
-	// TEST EMPTY FILE
-	fileName = "TestEmptyFileUpload"
-	emptyFile := siatest.TestFile{Name: "file", Data: []byte{}}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(fileName, []siatest.TestFile{emptyFile}, "", false, false)
+	// Without hashes the manifest should contain the same subfiles.
+	noHashes, err := r.SkynetManifestGet(skylink, false)
 	if err != nil {
-		t.Fatal("Expected upload of empty file to succeed", err)
+		t.Fatal(err)
 	}
-	data, err := r.SkynetSkylinkGet(skylink)
+	for i, sf := range noHashes.Subfiles {
+		if sf.Hash != nil {
+			t.Fatal("unexpected hash", sf.Path)
+		}
+		sf.Hash = manifest.Subfiles[i].Hash
+		if !reflect.DeepEqual(sf, manifest.Subfiles[i]) {
+			t.Fatal("subfile mismatch", sf, manifest.Subfiles[i])
+		}
+	}
+}
+
+// testSkynetSkylinkEncoding tests the /skynet/debug/encoding/:skylink
+// endpoint.
+func testSkynetSkylinkEncoding(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	numHosts := len(tg.Hosts())
+
+	// upload is a helper to upload a skyfile with the given size and pieces.
+	upload := func(size uint64, dataPieces, parityPieces int) skymodules.Skylink {
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:      skymodules.RandomSiaPath(),
+			Filename:     "encoding",
+			Reader:       bytes.NewReader(fastrand.Bytes(int(size))),
+			DataPieces:   dataPieces,
+			ParityPieces: parityPieces,
+		}
+		skylink, _, err := r.SkynetSkyfilePost(sup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sl skymodules.Skylink
+		err = sl.LoadString(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sl
+	}
+
+	// A small skyfile doesn't have a fanout.
+	se, err := r.SkylinkEncodingGET(upload(100, 0, 0))
 	if err != nil {
-		t.Fatal("Expected download of empty file to succeed", err)
+		t.Fatal(err)
 	}
-	_, md, err := r.SkynetMetadataGet(skylink)
+	if se.Filesize != 100 || se.NumChunks != 0 || se.ActualSize != 0 || se.DedupOptimization {
+		t.Fatal("unexpected encoding", se)
+	}
+
+	// A large 1-of-N skyfile only stores a single root per chunk.
+	size := modules.SectorSize + 1
+	se, err = r.SkylinkEncodingGET(upload(size, 1, numHosts-1))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(data) != 0 {
-		t.Fatal("Unexpected data")
+	numPieces := uint64(numHosts)
+	if se.CipherType != crypto.TypePlain.String() || se.DataPieces != 1 || se.ParityPieces != uint8(numHosts-1) {
+		t.Fatal("unexpected encoding", se)
 	}
-	if md.Length != 0 {
-		t.Fatal("Unexpected metadata length", md.Length)
+	if !se.DedupOptimization || se.NumChunks != 2 {
+		t.Fatal("unexpected encoding", se)
+	}
+	if se.TheoreticalSize != size*numPieces || se.ActualSize != 2*numPieces*modules.SectorSize {
+		t.Fatal("unexpected sizes", se)
 	}
 
-	// TEST SMALL SUBFILE
-	//
-	// Define test func
-	testSmallFunc := func(files []siatest.TestFile, fileName, skykeyName string) {
-		skylink, _, _, err := r.UploadNewMultipartSkyfileEncryptedBlocking(fileName, files, "", false, []string{}, nil, false, skykeyName, skykey.SkykeyID{})
+	// A skyfile with multiple data pieces stores every root.
+	se, err = r.SkylinkEncodingGET(upload(size, 2, numHosts-2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if se.DedupOptimization || se.NumChunks != 1 || se.DataPieces != 2 {
+		t.Fatal("unexpected encoding", se)
+	}
+	if se.TheoreticalSize != size*numPieces/2 || se.ActualSize != numPieces*modules.SectorSize {
+		t.Fatal("unexpected sizes", se)
+	}
+
+	// An unknown skylink returns a 404.
+	sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkylinkEncodingGET(sl)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
+		t.Fatal("expected ErrRootNotFound", err)
+	}
+
+	// A key without the admin scope can't access the endpoint.
+	key, err := r.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyClient := r.Client
+	keyClient.Password = key.Key
+	_, err = keyClient.SkylinkEncodingGET(upload(100, 0, 0))
+	if err == nil || !strings.Contains(err.Error(), "is not allowed to access this endpoint") {
+		t.Fatal("expected request to be forbidden", err)
+	}
+	if err := r.SkynetAPIKeyDeletePost(key.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkynetSkylinkChunk tests the /skynet/debug/chunk/:skylink endpoint.
+func testSkynetSkylinkChunk(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	numHosts := len(tg.Hosts())
+
+	// Create a skykey for the encrypted upload.
+	skykeyName := "chunk" + persist.RandomSuffix()
+	_, err := r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkChunks is a helper that uploads a skyfile and compares every chunk
+	// downloaded through the endpoint to the uploaded data.
+	checkChunks := func(dataPieces, parityPieces int, skykeyName string) {
+		data := fastrand.Bytes(2*int(modules.SectorSize) + siatest.Fuzz())
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:      skymodules.RandomSiaPath(),
+			Filename:     "chunk",
+			Reader:       bytes.NewReader(data),
+			DataPieces:   dataPieces,
+			ParityPieces: parityPieces,
+			SkykeyName:   skykeyName,
+		}
+		skylink, _, err := r.SkynetSkyfilePost(sup)
 		if err != nil {
 			t.Fatal(err)
 		}
-		var realSkylink skymodules.Skylink
-		err = realSkylink.LoadString(skylink)
+		var sl skymodules.Skylink
+		err = sl.LoadString(skylink)
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		// Try to download the file behind the skylink.
-		_, fileMetadata, err := r.SkynetMetadataGet(skylink)
+		se, err := r.SkylinkEncodingGET(sl)
 		if err != nil {
 			t.Fatal(err)
 		}
+		cipherType := crypto.TypePlain
+		if skykeyName != "" {
+			cipherType = crypto.TypeXChaCha20
+		}
+		chunkSize := skymodules.ChunkSize(cipherType, uint64(se.DataPieces))
 
-		// Check the metadata
-		rootFile := files[0]
-		nestedFile := files[1]
-		expected := skymodules.SkyfileMetadata{
-			Filename: fileName,
-			Subfiles: map[string]skymodules.SkyfileSubfileMetadata{
-				rootFile.Name: {
-					FileMode:    os.FileMode(0644),
-					Filename:    rootFile.Name,
-					ContentType: "application/octet-stream",
-					Offset:      0,
-					Len:         uint64(len(rootFile.Data)),
-				},
-				nestedFile.Name: {
-					FileMode:    os.FileMode(0644),
-					Filename:    nestedFile.Name,
-					ContentType: "text/html; charset=utf-8",
-					Offset:      uint64(len(rootFile.Data)),
-					Len:         uint64(len(nestedFile.Data)),
-				},
-			},
-			Length:   uint64(len(rootFile.Data) + len(nestedFile.Data)),
-			TryFiles: skymodules.DefaultTryFilesValue,
-		}
-		if !reflect.DeepEqual(expected, fileMetadata) {
-			t.Log("Expected:", expected)
-			t.Log("Actual:  ", fileMetadata)
-			t.Fatal("Metadata mismatch")
+		// Pad the data to a full chunk.
+		padded := make([]byte, se.NumChunks*chunkSize)
+		copy(padded, data)
+		for i := uint64(0); i < se.NumChunks; i++ {
+			chunk, err := r.SkylinkChunkGET(sl, i, skykeyName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(chunk, padded[i*chunkSize:(i+1)*chunkSize]) {
+				t.Fatalf("chunk %v doesn't match", i)
+			}
 		}
 
-		// Download the second file
-		nestedfile, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, nestedFile.Name))
-		if err != nil {
-			t.Fatal(err)
-		}
-		if !bytes.Equal(nestedfile, nestedFile.Data) {
-			t.Fatal("Expected only second file to be downloaded")
+		// A chunk that doesn't exist returns an error.
+		_, err = r.SkylinkChunkGET(sl, se.NumChunks, skykeyName)
+		if err == nil || !strings.Contains(err.Error(), renter.ErrChunkIndexOutOfBounds.Error()) {
+			t.Fatal("expected ErrChunkIndexOutOfBounds", err)
 		}
 	}
 
-	// Add a file at root level and a nested file
-	rootFile := siatest.TestFile{Name: "file1", Data: []byte("File1Contents")}
-	nestedFile := siatest.TestFile{Name: "nested/file2.html", Data: []byte("File2Contents")}
-	files := []siatest.TestFile{rootFile, nestedFile}
-	fileName = "TestFolderUpload"
-	testSmallFunc(files, fileName, "")
-
-	// Test Encryption
-	fileName = "TestFolderUpload_Encrypted"
-	testSmallFunc(files, fileName, sk.Name)
+	// Check a 1-of-N skyfile, a skyfile with multiple data pieces and an
+	// encrypted skyfile.
+	checkChunks(1, numHosts-1, "")
+	checkChunks(2, numHosts-2, "")
+	checkChunks(0, 0, skykeyName)
 
-	// LARGE SUBFILES
-	//
-	// Define test function
-	largeTestFunc := func(files []siatest.TestFile, fileName, skykeyName string) {
-		// Upload the skyfile
-		skylink, sup, _, err := r.UploadNewMultipartSkyfileEncryptedBlocking(fileName, files, "", false, skymodules.DefaultTryFilesValue, nil, false, skykeyName, skykey.SkykeyID{})
-		if err != nil {
-			t.Fatal(err)
-		}
+	// A small skyfile doesn't have any chunks.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("small", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sl skymodules.Skylink
+	err = sl.LoadString(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkylinkChunkGET(sl, 0, "")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrChunkIndexOutOfBounds.Error()) {
+		t.Fatal("expected ErrChunkIndexOutOfBounds", err)
+	}
 
-		// Define files
-		rootFile := files[0]
-		nestedFile := files[1]
+	// A key without the admin scope can't access the endpoint.
+	key, err := r.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyClient := r.Client
+	keyClient.Password = key.Key
+	_, err = keyClient.SkylinkChunkGET(sl, 0, "")
+	if err == nil || !strings.Contains(err.Error(), "is not allowed to access this endpoint") {
+		t.Fatal("expected request to be forbidden", err)
+	}
+	if err := r.SkynetAPIKeyDeletePost(key.ID); err != nil {
+		t.Fatal(err)
+	}
+}
 
-		// Download the data
-		largeFetchedData, err := r.SkynetSkylinkConcatGet(skylink)
-		if err != nil {
-			t.Fatal(err)
-		}
-		allData := append(rootFile.Data, nestedFile.Data...)
-		if !bytes.Equal(largeFetchedData, allData) {
-			t.Fatal("upload and download data does not match for large siafiles", len(largeFetchedData), len(allData))
-		}
+// testSkynetFanoutPieces verifies that the erasure coding of the fanout of a
+// large skyfile can be set on upload.
+func testSkynetFanoutPieces(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	numHosts := len(tg.Hosts())
 
-		// Check the metadata of the siafile, see that the metadata of the siafile
-		// has the skylink referenced.
-		largeSkyfilePath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
-		if err != nil {
-			t.Fatal(err)
-		}
-		largeRenterFile, err := r.RenterFileRootGet(largeSkyfilePath)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(largeRenterFile.File.Skylinks) != 1 {
-			t.Fatal("expecting one skylink:", len(largeRenterFile.File.Skylinks))
-		}
-		if largeRenterFile.File.Skylinks[0] != skylink {
-			t.Log(largeRenterFile.File.Skylinks[0])
-			t.Log(skylink)
-			t.Fatal("skylinks should match")
+	// upload is a helper to upload a large skyfile with the given pieces.
+	data := fastrand.Bytes(int(modules.SectorSize*2) + siatest.Fuzz())
+	upload := func(dataPieces, parityPieces int) (string, error) {
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:      skymodules.RandomSiaPath(),
+			Filename:     "fanoutpieces",
+			Reader:       bytes.NewReader(data),
+			DataPieces:   dataPieces,
+			ParityPieces: parityPieces,
 		}
+		skylink, _, err := r.SkynetSkyfilePost(sup)
+		return skylink, err
+	}
 
-		// Test the small root file download
-		smallFetchedData, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, rootFile.Name))
-		if err != nil {
-			t.Fatal(err)
-		}
-		if !bytes.Equal(smallFetchedData, rootFile.Data) {
-			t.Fatal("upload and download data does not match for large siafiles with subfiles", len(smallFetchedData), len(rootFile.Data))
-		}
+	// Upload a skyfile with custom pieces that use all hosts.
+	dataPieces, parityPieces := 2, numHosts-2
+	skylink, err := upload(dataPieces, parityPieces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedData, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Fatal("data mismatch")
+	}
 
-		// Test the large nested file download
-		largeFetchedData, err = r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, nestedFile.Name))
-		if err != nil {
-			t.Fatal(err)
-		}
-		if !bytes.Equal(largeFetchedData, nestedFile.Data) {
-			t.Fatal("upload and download data does not match for large siafiles with subfiles", len(largeFetchedData), len(nestedFile.Data))
-		}
+	// The layout should reflect the custom pieces.
+	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseSector, err := ioutil.ReadAll(baseSectorReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var layout skymodules.SkyfileLayout
+	layout.Decode(baseSector)
+	if int(layout.FanoutDataPieces) != dataPieces || int(layout.FanoutParityPieces) != parityPieces {
+		t.Fatalf("expected %v data and %v parity pieces but got %v and %v", dataPieces, parityPieces, layout.FanoutDataPieces, layout.FanoutParityPieces)
 	}
 
-	// Add a small file at root level and a large nested file
-	rootFile = siatest.TestFile{Name: "smallFile1.txt", Data: []byte("File1Contents")}
-	largeData := fastrand.Bytes(2 * int(modules.SectorSize))
-	nestedFile = siatest.TestFile{Name: "nested/largefile2.txt", Data: largeData}
-	files = []siatest.TestFile{rootFile, nestedFile}
-	fileName = "TestFolderUploadLarge"
-	largeTestFunc(files, fileName, "")
+	// Requesting more pieces than there are hosts should fail.
+	_, err = upload(dataPieces, numHosts)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrInvalidFanoutPieces.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 
-	// Test Encryption
-	fileName = "TestFolderUploadLarge_Encrypted"
-	largeTestFunc(files, fileName, sk.Name)
+	// Specifying only the data pieces should fail.
+	_, err = upload(dataPieces, 0)
+	if err == nil || !strings.Contains(err.Error(), "must provide both the datapieces parameter and the paritypieces parameter") {
+		t.Fatal("unexpected error", err)
+	}
 }
 
-// testSkynetStats tests the validity of the response of /skynet/stats endpoint
-// by uploading some test files and verifying that the reported statistics
-// change proportionally
-func testSkynetStats(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetPinEstimate tests estimating the cost of pinning a skylink.
+func testSkynetPinEstimate(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// This test relies on state from the previous tests. Make sure we are
-	// starting from a place of updated metadata
-	err := r.RenterBubblePost(skymodules.RootSiaPath(), true)
+	// Upload a 3-sector file.
+	size := 3 * modules.SectorSize
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("pinestimate", size, false)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	// Enable portal mode.
-	rg, err := r.RenterGet()
+	// Estimate the cost of pinning it.
+	estimate, err := r.SkynetPinEstimateGet(skylink, 0)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	a := rg.Settings.Allowance
-	a.PaymentContractInitialFunding = types.SiacoinPrecision
-	err = r.RenterPostAllowance(a)
+
+	// Determine the actual sectors from the fanout.
+	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-
-	// Sleep for a few seconds to give all of the health and repair loops time
-	// to get settled.
-	time.Sleep(time.Second * 3)
-
-	// Get the stats
-	stats, err := r.SkynetStatsGet()
+	baseSector, err := ioutil.ReadAll(baseSectorReader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Check portal mode.
-	if !stats.PortalMode {
-		t.Fatal("renter should be in portal mode")
+	layout, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		t.Fatal(err)
 	}
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := uint64(len(chunks))
+	dataPieces := uint64(layout.FanoutDataPieces)
+	numPieces := dataPieces + uint64(layout.FanoutParityPieces)
+	fetchSectors := 1 + numChunks*dataPieces
+	uploadSectors := uint64(renter.SkyfileDefaultBaseChunkRedundancy) + numChunks*numPieces
 
-	// Disable portal mode.
-	a.PaymentContractInitialFunding = types.ZeroCurrency
-	err = r.RenterPostAllowance(a)
+	// Compare them to the estimate.
+	if estimate.Filesize != size {
+		t.Fatal("wrong filesize", estimate.Filesize, size)
+	}
+	if estimate.NumFetchSectors != fetchSectors {
+		t.Fatal("wrong number of fetched sectors", estimate.NumFetchSectors, fetchSectors)
+	}
+	if estimate.NumUploadSectors != uploadSectors {
+		t.Fatal("wrong number of uploaded sectors", estimate.NumUploadSectors, uploadSectors)
+	}
+	if estimate.StorageSize != uploadSectors*modules.SectorSize {
+		t.Fatal("wrong storage size", estimate.StorageSize)
+	}
+	if estimate.NumContracts == 0 || estimate.NumContracts > numPieces {
+		t.Fatal("wrong number of contracts", estimate.NumContracts)
+	}
+	if estimate.TotalBandwidthCost.IsZero() {
+		t.Fatal("estimate shouldn't be zero")
+	}
+
+	// The pin estimate should match the upload estimate of the same file.
+	uploadEstimate, err := r.SkynetUploadEstimatePost(size, 0, 0, 0)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if uploadEstimate.NumSectors != estimate.NumUploadSectors {
+		t.Fatal("estimates don't match", uploadEstimate.NumSectors, estimate.NumUploadSectors)
 	}
 
-	// Get the stats again.
-	stats, err = r.SkynetStatsGet()
+	// More base chunk redundancy uploads more sectors.
+	redundant, err := r.SkynetPinEstimateGet(skylink, renter.SkyfileDefaultBaseChunkRedundancy+1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Check portal mode.
-	if stats.PortalMode {
-		t.Fatal("renter should not be in portal mode")
+	if redundant.NumUploadSectors != estimate.NumUploadSectors+1 || redundant.NumFetchSectors != estimate.NumFetchSectors {
+		t.Fatal("unexpected estimate", siatest.PrintJSON(estimate), siatest.PrintJSON(redundant))
 	}
+}
 
-	// Check that there are files in the filesystem.
-	if stats.NumFiles == 0 {
-		t.Fatal("test prereq requires files to exist")
+// testSkynetPinTTL verifies that pins with a ttl are listed with their expiry
+// and automatically unpinned once they expire.
+func testSkynetPinTTL(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile.
+	skylink, sup, _, err := r.UploadNewSkyfileBlocking("pinttl", 100, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Check that the system scan duration has been set.
-	if stats.SystemHealthScanDurationHours == 0 {
-		t.Fatal("system health scan duration is not set")
+	uploadPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// verify it contains the node's version information
-	expected := build.NodeVersion
-	if build.ReleaseTag != "" {
-		expected += "-" + build.ReleaseTag
+	// Pin it with a ttl.
+	spp := skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+		TTL:     2 * time.Second,
 	}
-	if stats.VersionInfo.Version != expected {
-		t.Fatalf("Unexpected version return, expected '%v', actual '%v'", expected, stats.VersionInfo.Version)
+	before := time.Now()
+	pin, err := r.SkynetSkylinkPinPost(skylink, spp)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if stats.VersionInfo.GitRevision != build.GitRevision {
-		t.Fatalf("Unexpected git revision return, expected '%v', actual '%v'", build.GitRevision, stats.VersionInfo.GitRevision)
+	if pin.PinExpiry.Before(before.Add(spp.TTL)) || pin.PinExpiry.After(time.Now().Add(spp.TTL)) {
+		t.Fatal("unexpected pin expiry", pin.PinExpiry)
 	}
 
-	// Uptime should be non zero
-	if stats.Uptime == 0 {
-		t.Error("Uptime is zero")
+	// Both the upload and the pin should be listed. Only the pin expires.
+	expiries := func() (map[skymodules.SiaPath]time.Time, error) {
+		spg, err := r.SkynetPinnedGet()
+		if err != nil {
+			return nil, err
+		}
+		expiries := make(map[skymodules.SiaPath]time.Time)
+		for _, p := range spg.Pins {
+			expiries[p.SiaPath] = p.PinExpiry
+		}
+		return expiries, nil
 	}
-
-	// Check registry stats are set
-	if stats.RegistryRead15mP99ms == 0 {
-		t.Error("readregistry p99 is zero")
+	pins, err := expiries()
+	if err != nil {
+		t.Fatal(err)
 	}
-	if stats.RegistryRead15mP999ms == 0 {
-		t.Error("readregistry p999 is zero")
+	if expiry, ok := pins[uploadPath]; !ok || !expiry.IsZero() {
+		t.Fatal("upload should be pinned without expiry", pins)
 	}
-	if stats.RegistryRead15mP9999ms == 0 {
-		t.Error("readregistry p9999 is zero")
+	if expiry, ok := pins[pin.SiaPath]; !ok || !expiry.Equal(pin.PinExpiry) {
+		t.Fatal("pin should be listed with its expiry", pins)
 	}
 
-	// create two test files with sizes below and above the sector size
-	files := make(map[string]uint64)
-	files["statfile1"] = 2033
-	files["statfile2"] = 2*modules.SectorSize + 123
-
-	// upload the files and keep track of their expected impact on the stats
-	var uploadedFilesSize, uploadedFilesCount uint64
-	var sps []skymodules.SiaPath
-	for name, size := range files {
-		_, sup, _, err := r.UploadNewSkyfileBlocking(name, size, false)
+	// Wait for the pin to expire. The upload should remain.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		pins, err := expiries()
 		if err != nil {
-			t.Fatal(err)
+			return err
 		}
-
-		sp, err := sup.SiaPath.Rebase(skymodules.RootSiaPath(), skymodules.SkynetFolder)
-		if err != nil {
-			t.Fatal(err)
+		if _, ok := pins[pin.SiaPath]; ok {
+			return errors.New("pin wasn't removed yet")
 		}
-		sps = append(sps, sp)
-
-		uploadedFilesCount++
-		if size < modules.SectorSize {
-			// small files get padded up to a full sector
-			uploadedFilesSize += modules.SectorSize
-		} else {
-			// large files have an extra sector with header data
-			uploadedFilesSize += size + modules.SectorSize
+		if _, ok := pins[uploadPath]; !ok {
+			return errors.New("upload was removed")
 		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(pin.SiaPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("expected the pinned siafile to be deleted", err)
 	}
+}
 
-	// Create a siafile and convert it
-	size := 100
-	_, rf, err := r.UploadNewFileBlocking(size, 1, 2, false)
+// testSkynetStorageCap verifies that uploads and pins are rejected once the
+// skynet storage cap is reached and that deleting skyfiles frees up headroom.
+func testSkynetStorageCap(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile to make sure the skynet folder isn't empty.
+	skylink, sup, _, err := r.UploadNewSkyfileBlocking("storagecap", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	sup := skymodules.SkyfileUploadParameters{
-		SiaPath: rf.SiaPath(),
-		Mode:    skymodules.DefaultFilePerm,
-		Force:   false,
-		Root:    false,
-	}
-	_, err = r.SkynetConvertSiafileToSkyfilePost(sup, rf.SiaPath())
+	uploadPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Increment the file count once for the converted file
-	uploadedFilesCount++
-	// Increment the file size for the basesector that is uploaded during the
-	// conversion as well as the file size of the siafile.
-	uploadedFilesSize += modules.SectorSize
-	uploadedFilesSize += uint64(size)
 
-	// Check that the right stats were returned.
-	statsBefore := stats
-	tries := 1
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// Make sure that the filesystem is being updated
-		if tries%10 == 0 {
-			err = r.RenterBubblePost(skymodules.RootSiaPath(), true)
-			if err != nil {
-				return err
-			}
-		}
-		tries++
-		statsAfter, err := r.SkynetStatsGet()
+	// upload is a helper to upload a small skyfile and return the status code
+	// and body of the response.
+	upload := func() (int, []byte) {
+		query := fmt.Sprintf("/skynet/skyfile/%v?filename=storagecap", skymodules.RandomSiaPath())
+		req, err := r.NewRequest("POST", query, bytes.NewReader(fastrand.Bytes(100)))
 		if err != nil {
-			return err
-		}
-		var countErr, sizeErr, healthErr error
-		if uint64(statsBefore.NumFiles)+uploadedFilesCount != uint64(statsAfter.NumFiles) {
-			countErr = fmt.Errorf("stats did not report the correct number of files. expected %d, found %d", uint64(statsBefore.NumFiles)+uploadedFilesCount, statsAfter.NumFiles)
-		}
-		if statsBefore.Storage+uploadedFilesSize != statsAfter.Storage {
-			sizeErr = fmt.Errorf("stats did not report the correct size. expected %d, found %d", statsBefore.Storage+uploadedFilesSize, statsAfter.Storage)
-		}
-		// Just make sure that a health is returned
-		if statsAfter.MaxHealthPercentage == 0 {
-			healthErr = errors.New("no MaxHealthPercentage retuned")
+			t.Fatal(err)
 		}
-		return errors.Compose(countErr, sizeErr, healthErr)
-	})
-	if err != nil {
-		t.Error(err)
-	}
-
-	// Delete the files.
-	for _, sp := range sps {
-		err = r.RenterFileDeleteRootPost(sp)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		res, err := http.DefaultClient.Do(req)
 		if err != nil {
 			t.Fatal(err)
 		}
-		extSP, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+		body, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
 		if err != nil {
 			t.Fatal(err)
 		}
-		// This might not always succeed which is fine. We know how many files
-		// we expect afterwards.
-		_ = r.RenterFileDeleteRootPost(extSP)
+		return res.StatusCode, body
+	}
+	// rejected is a helper to assert that an upload is rejected and return
+	// the error.
+	rejected := func() api.SkynetStorageCapExceededError {
+		code, body := upload()
+		if code != http.StatusInsufficientStorage {
+			t.Fatal("unexpected status code", code, string(body))
+		}
+		var apiErr api.SkynetStorageCapExceededError
+		if err := json.Unmarshal(body, &apiErr); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(apiErr.Message, renter.ErrSkynetStorageCapExceeded.Error()) {
+			t.Fatal("unexpected message", apiErr.Message)
+		}
+		return apiErr
 	}
 
-	// Delete the converted file
-	err = r.RenterFileDeletePost(rf.SiaPath())
+	// Set a cap of a single byte which is already exceeded.
+	err = r.RenterSkynetStorageCapPost(1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	convertSP, err := rf.SiaPath().Rebase(skymodules.RootSiaPath(), skymodules.SkynetFolder)
+	defer func() {
+		if err := r.RenterSkynetStorageCapPost(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = r.RenterFileDeleteRootPost(convertSP)
-	if err != nil {
-		t.Fatal(err)
+	if rg.Settings.SkynetStorageCap != 1 {
+		t.Fatal("unexpected storage cap", rg.Settings.SkynetStorageCap)
 	}
 
-	// Check the stats after the delete operation. Do it in a retry to account
-	// for the bubble.
-	tries = 1
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// Make sure that the filesystem is being updated
-		if tries%10 == 0 {
-			err = r.RenterBubblePost(skymodules.RootSiaPath(), true)
-			if err != nil {
-				return err
-			}
-		}
-		tries++
-		statsAfter, err := r.SkynetStatsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		var countErr, sizeErr, healthErr error
-		if statsAfter.NumFiles != statsBefore.NumFiles {
-			countErr = fmt.Errorf("stats did not report the correct number of files. expected %d, found %d", uint64(statsBefore.NumFiles), statsAfter.NumFiles)
-		}
-		if statsAfter.Storage != statsBefore.Storage {
-			sizeErr = fmt.Errorf("stats did not report the correct size. expected %d, found %d", statsBefore.Storage, statsAfter.Storage)
-		}
-		// Just make sure that a health is returned
-		if statsAfter.MaxHealthPercentage == 0 {
-			healthErr = errors.New("no MaxHealthPercentage retuned")
-		}
-		return errors.Compose(countErr, sizeErr, healthErr)
-	})
-	if err != nil {
-		t.Error(err)
+	// Uploads and pins are rejected.
+	apiErr := rejected()
+	if apiErr.Cap != 1 || apiErr.Used <= 1 || apiErr.Incoming != 100 {
+		t.Fatal("unexpected error", apiErr)
+	}
+	_, err = r.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{SiaPath: skymodules.RandomSiaPath()})
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkynetStorageCapExceeded.Error()) {
+		t.Fatal("expected pin to be rejected", err)
 	}
 
-	// Check that the throughput information for the various throughput fields
-	// is not blank.
-	//
-	// NOTE: this test depends on other tests to have performed each type of
-	// activity already, this test does not do the activity itself.
-	stats, err = r.SkynetStatsGet()
+	// Set the cap to the used storage. Uploads are still rejected.
+	err = r.RenterSkynetStorageCapPost(apiErr.Used)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if stats.BaseSectorUpload15mDataPoints <= 1 {
-		t.Error("throughput is being recorded at or below baseline:", stats.BaseSectorUpload15mDataPoints)
-	}
-	if stats.ChunkUpload15mDataPoints <= 1 {
-		t.Error("throughput is being recorded at or below baseline:", stats.ChunkUpload15mDataPoints)
+	rejected()
+
+	// Uploads which would exceed the cap with their Content-Length are
+	// rejected before the cap is reached.
+	err = r.RenterSkynetStorageCapPost(apiErr.Used + 50)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if stats.RegistryRead15mDataPoints <= 1 {
-		t.Error("throughput is being recorded at or below baseline:", stats.RegistryRead15mDataPoints)
+	if e := rejected(); e.Cap != apiErr.Used+50 || e.Used != apiErr.Used || e.Incoming != 100 {
+		t.Fatal("unexpected error", e)
 	}
-	if stats.RegistryWrite15mDataPoints <= 1 {
-		t.Error("throughput is being recorded at or below baseline:", stats.RegistryWrite15mDataPoints)
+
+	// Deleting the skyfile frees up headroom for another upload.
+	err = r.RenterFileDeleteRootPost(uploadPath)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if stats.StreamBufferRead15mDataPoints <= 1 {
-		t.Error("throughput is being recorded at or below baseline:", stats.StreamBufferRead15mDataPoints)
+	if code, body := upload(); code != http.StatusOK {
+		t.Fatal("unexpected status code", code, string(body))
 	}
 }
 
-// TestSkynetInvalidFilename verifies that posting a Skyfile with invalid
-// filenames such as empty filenames, names containing ./ or ../ or names
-// starting with a forward-slash fails.
-func testSkynetInvalidFilename(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetSnapshot verifies that diffing a skynet snapshot against the
+// current state of its directory returns the skylinks that were added, removed
+// and moved since the snapshot was taken.
+func testSkynetSnapshot(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Create some data to upload as a skyfile.
-	data := fastrand.Bytes(100 + siatest.Fuzz())
-
-	filenames := []string{
-		"",
-		"../test",
-		"./test",
-		"/test",
-		"foo//bar",
-		"test/./test",
-		"test/../test",
-		"/test//foo/../bar/",
-	}
-
-	for _, filename := range filenames {
-		uploadSiaPath, err := skymodules.NewSiaPath("testInvalidFilename" + persist.RandomSuffix())
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		sup := skymodules.SkyfileUploadParameters{
-			SiaPath:             uploadSiaPath,
-			Force:               false,
-			Root:                false,
-			BaseChunkRedundancy: 2,
-			Filename:            filename,
-			Mode:                0640, // Intentionally does not match any defaults.
-			Reader:              bytes.NewReader(data),
-		}
-
-		// Try posting the skyfile with an invalid filename
-		_, _, err = r.SkynetSkyfilePost(sup)
-		if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidPathString.Error()) {
-			t.Log("Error:", err)
-			t.Fatal("Expected SkynetSkyfilePost to fail due to invalid filename")
-		}
-
-		// Do the same for a multipart upload
-		body := new(bytes.Buffer)
-		writer := multipart.NewWriter(body)
-		data = []byte("File1Contents")
-		subfile, err := skymodules.AddMultipartFile(writer, data, "files[]", filename, 0600, nil)
+	// Upload skyfiles and pin them into a dedicated directory.
+	dir := "snapshot" + persist.RandomSuffix()
+	pin := func(name string) api.SkynetPinHandlerPOST {
+		t.Helper()
+		skylink, _, _, err := r.UploadNewSkyfileBlocking(name, 100, false)
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = writer.Close()
+		sp, err := skymodules.NewSiaPath(dir + "/" + name)
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		// Call the upload skyfile client call.
-		uploadSiaPath, err = skymodules.NewSiaPath("testInvalidFilenameMultipart" + persist.RandomSuffix())
+		p, err := r.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{SiaPath: sp})
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		subfiles := make(skymodules.SkyfileSubfiles)
-		subfiles[subfile.Filename] = subfile
-		mup := skymodules.SkyfileMultipartUploadParameters{
-			SiaPath:             uploadSiaPath,
-			Force:               false,
-			Root:                false,
-			BaseChunkRedundancy: 2,
-			Reader:              bytes.NewReader(body.Bytes()),
-			ContentType:         writer.FormDataContentType(),
-			Filename:            "testInvalidFilenameMultipart",
-		}
-
-		_, _, err = r.SkynetSkyfileMultiPartPost(mup)
-		if err == nil || (!strings.Contains(err.Error(), skymodules.ErrInvalidPathString.Error()) && !strings.Contains(err.Error(), skymodules.ErrEmptyFilename.Error())) {
-			t.Log("Error:", err)
-			t.Fatal("Expected SkynetSkyfileMultiPartPost to fail due to invalid filename")
-		}
+		return p
 	}
+	pin("unchanged")
+	removed := pin("removed")
+	moved := pin("moved")
 
-	// These cases should succeed.
-	uploadSiaPath, err := skymodules.NewSiaPath("testInvalidFilename")
+	// Take a snapshot.
+	ssp, err := r.SkynetSnapshotPost("backup", dir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	sup := skymodules.SkyfileUploadParameters{
-		SiaPath:             uploadSiaPath,
-		Force:               false,
-		Root:                false,
-		BaseChunkRedundancy: 2,
-		Filename:            "testInvalidFilename",
-		Mode:                0640, // Intentionally does not match any defaults.
-		Reader:              bytes.NewReader(data),
+	if ssp.Name != "backup" || ssp.NumEntries != 3 {
+		t.Fatal("unexpected snapshot", ssp)
 	}
-	_, _, err = r.SkynetSkyfilePost(sup)
+
+	// Add, remove and move a skyfile.
+	added := pin("added")
+	err = r.RenterFileDeleteRootPost(removed.SiaPath)
 	if err != nil {
-		t.Log("Error:", err)
-		t.Fatal("Expected SkynetSkyfilePost to succeed if valid filename is provided")
+		t.Fatal(err)
 	}
-
-	// recreate the reader
-	body := new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-
-	subfile, err := skymodules.AddMultipartFile(writer, []byte("File1Contents"), "files[]", "testInvalidFilenameMultipart", 0600, nil)
+	movedTo, err := skymodules.SkynetFolder.Join(dir + "/moved-renamed")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = writer.Close()
+	err = r.RenterRenamePost(moved.SiaPath, movedTo, true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	subfiles := make(skymodules.SkyfileSubfiles)
-	subfiles[subfile.Filename] = subfile
-	uploadSiaPath, err = skymodules.NewSiaPath("testInvalidFilenameMultipart")
+	// Diff against the current state.
+	diff, err := r.SkynetSnapshotDiffGet("backup", "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	mup := skymodules.SkyfileMultipartUploadParameters{
-		SiaPath:             uploadSiaPath,
-		Force:               false,
-		Root:                false,
-		BaseChunkRedundancy: 2,
-		Reader:              bytes.NewReader(body.Bytes()),
-		ContentType:         writer.FormDataContentType(),
-		Filename:            "testInvalidFilenameMultipart",
+	if len(diff.Added) != 1 || diff.Added[0].Skylink != added.Skylink || !diff.Added[0].SiaPath.Equals(added.SiaPath) {
+		t.Fatal("unexpected added skylinks", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Skylink != removed.Skylink || !diff.Removed[0].SiaPath.Equals(removed.SiaPath) {
+		t.Fatal("unexpected removed skylinks", diff.Removed)
+	}
+	if len(diff.Moved) != 1 || diff.Moved[0].Skylink != moved.Skylink || !diff.Moved[0].From.Equals(moved.SiaPath) || !diff.Moved[0].To.Equals(movedTo) {
+		t.Fatal("unexpected moved skylinks", diff.Moved)
 	}
 
-	_, _, err = r.SkynetSkyfileMultiPartPost(mup)
+	// Diffing a new snapshot against the old one returns the same result.
+	_, err = r.SkynetSnapshotPost("backup2", dir)
 	if err != nil {
-		t.Log("Error:", err)
-		t.Fatal("Expected SkynetSkyfileMultiPartPost to succeed if filename is provided")
+		t.Fatal(err)
 	}
-}
-
-// testSkynetDownloadFormats verifies downloading data in different formats
-func testSkynetDownloadFormats(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-
-	body := new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-	dataFile1 := []byte("file1.txt")
-	dataFile2 := []byte("file2.txt")
-	dataFile3 := []byte("file3.txt")
-	filePath1 := "a/5.f4f8b583.chunk.js"
-	filePath2 := "a/5.f4f.chunk.js.map"
-	filePath3 := "b/file3.txt"
-	_, err := skymodules.AddMultipartFile(writer, dataFile1, "files[]", filePath1, 0600, nil)
+	diff2, err := r.SkynetSnapshotDiffGet("backup", "backup2")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = skymodules.AddMultipartFile(writer, dataFile2, "files[]", filePath2, 0600, nil)
+	if !reflect.DeepEqual(diff, diff2) {
+		t.Fatal("diffs don't match", diff, diff2)
+	}
+
+	// Unknown snapshots and invalid names are rejected.
+	_, err = r.SkynetSnapshotDiffGet("unknown", "")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkynetSnapshotNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = r.SkynetSnapshotPost(skymodules.SkynetSnapshotNow, dir)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkynetSnapshotInvalidName.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Snapshots are rejected during maintenance.
+	message := "snapshot maintenance"
+	err = r.SkynetMaintenancePost(true, message)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = skymodules.AddMultipartFile(writer, dataFile3, "files[]", filePath3, 0640, nil)
+	_, snapshotErr := r.SkynetSnapshotPost("backup3", dir)
+	err = r.SkynetMaintenancePost(false, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := writer.Close(); err != nil {
-		t.Fatal(err)
+	if snapshotErr == nil || !strings.Contains(snapshotErr.Error(), message) {
+		t.Fatal("expected snapshot to be rejected", snapshotErr)
 	}
+}
 
-	uploadSiaPath, err := skymodules.NewSiaPath("testSkynetDownloadFormats")
-	if err != nil {
-		t.Fatal(err)
-	}
+// testSkynetSearch verifies that the metadata of uploaded and pinned skyfiles
+// can be searched and that the results can be paged through.
+func testSkynetSearch(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	reader := bytes.NewReader(body.Bytes())
-	mup := skymodules.SkyfileMultipartUploadParameters{
-		SiaPath:             uploadSiaPath,
-		Force:               false,
-		Root:                false,
-		BaseChunkRedundancy: 2,
-		Reader:              reader,
-		ContentType:         writer.FormDataContentType(),
-		Filename:            "testSkynetSubfileDownload",
+	// Upload a few skyfiles into a dedicated directory.
+	dir := "search" + persist.RandomSuffix()
+	upload := func(name string, files ...siatest.TestFile) string {
+		t.Helper()
+		_, _, sshp, err := r.UploadNewMultipartSkyfileBlocking(dir+"/"+name, files, "", false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sshp.Skylink
 	}
+	upload("cat", siatest.TestFile{Name: "cat.png", Data: fastrand.Bytes(100)})
+	upload("dog", siatest.TestFile{Name: "dog.jpg", Data: fastrand.Bytes(200)})
+	site := upload("site",
+		siatest.TestFile{Name: "index.html", Data: []byte("<html>cats</html>")},
+		siatest.TestFile{Name: "Cat.gif", Data: fastrand.Bytes(300)},
+	)
 
-	skylink, _, err := r.SkynetSkyfileMultiPartPost(mup)
+	// Pin the site to another siapath in the directory.
+	pinned, err := skymodules.NewSiaPath(dir + "/pinned")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// download the data specifying the 'concat' format
-	allData, err := r.SkynetSkylinkConcatGet(skylink)
+	_, err = r.SkynetSkylinkPinPost(site, skymodules.SkyfilePinParameters{SiaPath: pinned})
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected := append(dataFile1, dataFile2...)
-	expected = append(expected, dataFile3...)
-	if !bytes.Equal(expected, allData) {
-		t.Log("expected:", expected)
-		t.Log("actual:", allData)
-		t.Fatal("Unexpected data for dir A")
-	}
 
-	// now specify the zip format
-	_, skyfileReader, err := r.SkynetSkylinkZipReaderGet(skylink)
+	// Convert a siafile into a skyfile in the directory. Converted skyfiles
+	// are indexed when they are first searched.
+	_, rf, err := r.UploadNewFileBlocking(100, 1, 1, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// read the zip archive
-	files, err := readZipArchive(skyfileReader)
+	converted, err := skymodules.NewSiaPath(dir + "/converted")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = skyfileReader.Close()
+	_, err = r.SkynetConvertSiafileToSkyfilePost(skymodules.SkyfileUploadParameters{SiaPath: converted}, rf.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// verify the contents
-	dataFile1Received, exists := files[filePath1]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath1)
-	}
-	if !bytes.Equal(dataFile1Received, dataFile1) {
-		t.Fatal("file data doesn't match expected content")
-	}
-	dataFile2Received, exists := files[filePath2]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath2)
+	// search searches the directory and returns the names of the matches.
+	dirSiaPath, err := skymodules.NewSiaPath(dir)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile2Received, dataFile2) {
-		t.Fatal("file data doesn't match expected content")
+	search := func(params skymodules.SkynetSearchParameters) ([]string, uint64) {
+		t.Helper()
+		params.SiaPath = dirSiaPath
+		ssg, err := r.SkynetSearchGet(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, result := range ssg.Results {
+			names = append(names, result.SiaPath.Name())
+		}
+		return names, ssg.Total
 	}
-	dataFile3Received, exists := files[filePath3]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath3)
+	tests := []struct {
+		params   skymodules.SkynetSearchParameters
+		expected []string
+	}{
+		{skymodules.SkynetSearchParameters{}, []string{"cat", "converted", "dog", "pinned", "site"}},
+		{skymodules.SkynetSearchParameters{Filename: rf.SiaPath().Name()}, []string{"converted"}},
+		{skymodules.SkynetSearchParameters{Filename: "cat"}, []string{"cat", "pinned", "site"}},
+		{skymodules.SkynetSearchParameters{Filename: "dog.JPG"}, []string{"dog"}},
+		{skymodules.SkynetSearchParameters{ContentType: "text/html"}, []string{"pinned", "site"}},
+		{skymodules.SkynetSearchParameters{ContentType: "image/"}, []string{"cat", "dog", "pinned", "site"}},
+		{skymodules.SkynetSearchParameters{MinSize: 150, MaxSize: 250}, []string{"dog"}},
+		{skymodules.SkynetSearchParameters{Filename: "index", ContentType: "image/gif"}, []string{"pinned", "site"}},
+		{skymodules.SkynetSearchParameters{Filename: "unknown"}, nil},
 	}
-	if !bytes.Equal(dataFile3Received, dataFile3) {
-		t.Log(dataFile3Received)
-		t.Log(dataFile3)
-		t.Fatal("file data doesn't match expected content")
+	for _, test := range tests {
+		names, total := search(test.params)
+		if !reflect.DeepEqual(names, test.expected) || total != uint64(len(test.expected)) {
+			t.Fatalf("unexpected results for %+v: %v %v", test.params, names, total)
+		}
 	}
 
-	// now specify the tar format
-	_, skyfileReader, err = r.SkynetSkylinkTarReaderGet(skylink)
+	// The matched fields are returned.
+	ssg, err := r.SkynetSearchGet(skymodules.SkynetSearchParameters{SiaPath: dirSiaPath, Filename: "cat", MaxSize: 150})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(ssg.Results) != 1 || ssg.Results[0].Size != 100 || !reflect.DeepEqual(ssg.Results[0].Matched, []string{"filename", "subfiles", "size"}) {
+		t.Fatal("unexpected results", ssg.Results)
+	}
 
-	// read the tar archive
-	files, err = readTarArchive(skyfileReader)
+	// Page through the results.
+	var paged []string
+	for offset := uint64(0); offset < 5; offset += 2 {
+		names, total := search(skymodules.SkynetSearchParameters{Offset: offset, Limit: 2})
+		if total != 5 {
+			t.Fatal("unexpected total", total)
+		}
+		paged = append(paged, names...)
+	}
+	if !reflect.DeepEqual(paged, []string{"cat", "converted", "dog", "pinned", "site"}) {
+		t.Fatal("unexpected pages", paged)
+	}
+
+	// Deleted skyfiles don't match anymore.
+	pinnedRoot, err := skymodules.SkynetFolder.Join(pinned.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = skyfileReader.Close()
+	err = r.RenterFileDeleteRootPost(pinnedRoot)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// verify the contents
-	dataFile1Received, exists = files[filePath1]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath1)
-	}
-	if !bytes.Equal(dataFile1Received, dataFile1) {
-		t.Fatal("file data doesn't match expected content")
+	if names, _ := search(skymodules.SkynetSearchParameters{ContentType: "text/html"}); !reflect.DeepEqual(names, []string{"site"}) {
+		t.Fatal("unexpected results after delete", names)
 	}
-	dataFile2Received, exists = files[filePath2]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath2)
+
+	// Invalid parameters are rejected.
+	for _, params := range []skymodules.SkynetSearchParameters{
+		{Limit: api.MaxSkynetSearchLimit + 1},
+		{MinSize: 2, MaxSize: 1},
+	} {
+		if _, err := r.SkynetSearchGet(params); err == nil {
+			t.Fatal("parameters should be invalid", params)
+		}
 	}
-	if !bytes.Equal(dataFile2Received, dataFile2) {
-		t.Fatal("file data doesn't match expected content")
+}
+
+// testSkynetDataURI verifies that small skyfiles can be downloaded as data
+// URIs and that the size cap is enforced.
+func testSkynetDataURI(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a directory with a small text file and a larger binary file.
+	text := []byte("hello data uri")
+	binary := fastrand.Bytes(1000)
+	files := []siatest.TestFile{
+		{Name: "hello.txt", Data: text},
+		{Name: "data.bin", Data: binary},
 	}
-	dataFile3Received, exists = files[filePath3]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath3)
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking("datauri"+persist.RandomSuffix(), files, "", true, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile3Received, dataFile3) {
-		t.Log(dataFile3Received)
-		t.Log(dataFile3)
-		t.Fatal("file data doesn't match expected content")
+
+	// decode checks that the data URI has the expected media type and
+	// content.
+	decode := func(dataURI, mediaType string, data []byte) {
+		t.Helper()
+		prefix := "data:" + mediaType
+		if !strings.HasPrefix(dataURI, prefix) {
+			t.Fatalf("expected prefix %v but got %v", prefix, dataURI)
+		}
+		i := strings.Index(dataURI, ";base64,")
+		if i == -1 {
+			t.Fatal("data URI isn't base64 encoded", dataURI)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(dataURI[i+len(";base64,"):])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatal("decoded data doesn't match")
+		}
 	}
 
-	// now specify the targz format
-	_, skyfileReader, err = r.SkynetSkylinkTarGzReaderGet(skylink)
+	// Download the subfiles as data URIs.
+	dataURI, err := r.SkynetSkylinkDataURIGet(skylink + "/hello.txt")
 	if err != nil {
 		t.Fatal(err)
 	}
-	gzr, err := gzip.NewReader(skyfileReader)
+	decode(dataURI, "text/plain", text)
+	dataURI, err = r.SkynetSkylinkDataURIGet(skylink + "/data.bin")
 	if err != nil {
 		t.Fatal(err)
 	}
-	files, err = readTarArchive(gzr)
+	decode(dataURI, "application/octet-stream", binary)
+
+	// A directory can't be downloaded as a data URI.
+	_, err = r.SkynetSkylinkDataURIGet(skylink)
+	if err == nil || !strings.Contains(err.Error(), "can't be used to download a directory") {
+		t.Fatal("expected directory to be rejected", err)
+	}
+
+	// Lower the size cap below the size of the binary file.
+	err = r.RenterSkynetMaxDataURISizePost(uint64(len(binary) - 1))
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = errors.Compose(skyfileReader.Close(), gzr.Close())
+	defer func() {
+		if err := r.RenterSkynetMaxDataURISizePost(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = r.SkynetSkylinkDataURIGet(skylink + "/data.bin")
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Fatal("expected content to exceed the size cap", err)
+	}
+	dataURI, err = r.SkynetSkylinkDataURIGet(skylink + "/hello.txt")
 	if err != nil {
 		t.Fatal(err)
 	}
+	decode(dataURI, "text/plain", text)
+}
 
-	// verify the contents
-	dataFile1Received, exists = files[filePath1]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath1)
+// testSkynetDefaultBaseChunkRedundancy verifies that uploads, pins and
+// estimates which don't specify a base chunk redundancy use the renter's
+// default.
+func testSkynetDefaultBaseChunkRedundancy(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// The default is reported by the settings.
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile1Received, dataFile1) {
-		t.Fatal("file data doesn't match expected content")
+	if rg.Settings.DefaultBaseChunkRedundancy != renter.SkyfileDefaultBaseChunkRedundancy {
+		t.Fatal("unexpected default", rg.Settings.DefaultBaseChunkRedundancy)
 	}
-	dataFile2Received, exists = files[filePath2]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath2)
+
+	// Change it.
+	redundancy := renter.SkyfileDefaultBaseChunkRedundancy + 1
+	err = r.RenterDefaultBaseChunkRedundancyPost(redundancy)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile2Received, dataFile2) {
-		t.Fatal("file data doesn't match expected content")
+	defer func() {
+		if err := r.RenterDefaultBaseChunkRedundancyPost(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err = r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	dataFile3Received, exists = files[filePath3]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath3)
+	if rg.Settings.DefaultBaseChunkRedundancy != redundancy {
+		t.Fatal("unexpected default", rg.Settings.DefaultBaseChunkRedundancy)
 	}
-	if !bytes.Equal(dataFile3Received, dataFile3) {
-		t.Log(dataFile3Received)
-		t.Log(dataFile3)
-		t.Fatal("file data doesn't match expected content")
+
+	// checkRedundancy is a helper to check the redundancy of the siafile at
+	// the given path within the skynet folder.
+	checkRedundancy := func(siaPath skymodules.SiaPath) {
+		t.Helper()
+		sp, err := skymodules.SkynetFolder.Join(siaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = build.Retry(100, 100*time.Millisecond, func() error {
+			rf, err := r.RenterFileRootGet(sp)
+			if err != nil {
+				return err
+			}
+			if rf.File.Redundancy != float64(redundancy) {
+				return fmt.Errorf("bad redundancy: %v", rf.File.Redundancy)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	// get all data for path "a" using the concat format
-	dataDirA, err := r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/a", skylink))
+	// Upload a skyfile without specifying a redundancy.
+	uploadPath := skymodules.RandomSiaPath()
+	skylink, _, err := r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
+		SiaPath:  uploadPath,
+		Filename: "file",
+		Reader:   bytes.NewReader(fastrand.Bytes(100)),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = append(dataFile1, dataFile2...)
-	if !bytes.Equal(expected, dataDirA) {
-		t.Log("expected:", expected)
-		t.Log("actual:", dataDirA)
-		t.Fatal("Unexpected data for dir A")
-	}
+	checkRedundancy(uploadPath)
 
-	// now specify the tar format
-	_, skyfileReader, err = r.SkynetSkylinkTarReaderGet(fmt.Sprintf("%s/a", skylink))
+	// Pin it without specifying a redundancy.
+	pinPath := skymodules.RandomSiaPath()
+	_, err = r.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{SiaPath: pinPath})
 	if err != nil {
 		t.Fatal(err)
 	}
+	checkRedundancy(pinPath)
 
-	// read the tar archive
-	files, err = readTarArchive(skyfileReader)
+	// Pin it through a manifest. The manifest pins the skylink at a path
+	// named after the skylink.
+	_, progress, err := r.SkynetPinManifestPost([]byte(skylink))
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = skyfileReader.Close()
+	if len(progress) != 1 || progress[0].Error != "" {
+		t.Fatal("unexpected progress", progress)
+	}
+	manifestPath, err := skymodules.NewSiaPath(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	checkRedundancy(manifestPath)
 
-	// verify the contents
-	dataFile1Received, exists = files[filePath1]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath1)
+	// Estimates use the default as well.
+	estimate, err := r.SkynetUploadEstimatePost(100, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile1Received, dataFile1) {
-		t.Fatal("file data doesn't match expected content")
+	if estimate.NumSectors != uint64(redundancy) {
+		t.Fatal("unexpected number of sectors", estimate.NumSectors)
 	}
-	dataFile2Received, exists = files[filePath2]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath2)
+}
+
+// testSkynetMaintenance verifies that the maintenance mode rejects new data
+// while downloads keep working.
+func testSkynetMaintenance(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile to download during maintenance.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("maintenance", 100, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile2Received, dataFile2) {
-		t.Fatal("file data doesn't match expected content")
+
+	// upload is a helper to upload a small skyfile and return the response.
+	upload := func() (*http.Response, []byte) {
+		t.Helper()
+		query := fmt.Sprintf("/skynet/skyfile/%v?filename=maintenance", skymodules.RandomSiaPath())
+		req, err := r.NewRequest("POST", query, bytes.NewReader(fastrand.Bytes(100)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, body
 	}
-	if len(files) != 2 {
-		t.Fatal("unexpected amount of files")
+	// pin is a helper to pin the skylink.
+	pin := func() error {
+		_, err := r.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{SiaPath: skymodules.RandomSiaPath()})
+		return err
+	}
+	// updateRegistry is a helper to update a new registry entry.
+	updateRegistry := func() error {
+		sk, pk := crypto.GenerateKeyPair()
+		spk := types.SiaPublicKey{
+			Algorithm: types.SignatureEd25519,
+			Key:       pk[:],
+		}
+		srv := modules.NewRegistryValue(crypto.HashBytes(fastrand.Bytes(10)), fastrand.Bytes(10), 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+		return r.RegistryUpdateWithEntry(spk, srv)
+	}
+	// tusUpload is a helper to upload a small skyfile using TUS.
+	tusUpload := func() error {
+		chunkSize := int64(skymodules.ChunkSize(crypto.TypePlain, uint64(skymodules.RenterDefaultDataPieces)))
+		_, err := r.SkynetTUSUploadFromBytes(fastrand.Bytes(100), chunkSize, "maintenance", "")
+		return err
 	}
 
-	// now specify the targz format
-	_, skyfileReader, err = r.SkynetSkylinkTarGzReaderGet(fmt.Sprintf("%s/a", skylink))
+	// Enable the maintenance mode.
+	message := "maintenance test"
+	err = r.SkynetMaintenancePost(true, message)
 	if err != nil {
 		t.Fatal(err)
 	}
-	gzr, err = gzip.NewReader(skyfileReader)
+	defer func() {
+		if err := r.SkynetMaintenancePost(false, ""); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	maintenance, err := r.SkynetMaintenanceGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	files, err = readTarArchive(gzr)
-	if err != nil {
-		t.Fatal(err)
+	if !maintenance.Enabled || maintenance.Message != message {
+		t.Fatal("unexpected maintenance mode", maintenance)
 	}
-	err = errors.Compose(skyfileReader.Close(), gzr.Close())
+	stats, err := r.SkynetStatsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if stats.Maintenance != maintenance {
+		t.Fatal("unexpected maintenance mode in stats", stats.Maintenance)
+	}
 
-	// verify the contents
-	dataFile1Received, exists = files[filePath1]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath1)
+	// New data is rejected.
+	res, body := upload()
+	if res.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), message) {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
 	}
-	if !bytes.Equal(dataFile1Received, dataFile1) {
-		t.Fatal("file data doesn't match expected content")
+	if res.Header.Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After header")
 	}
-	dataFile2Received, exists = files[filePath2]
-	if !exists {
-		t.Fatalf("file at path '%v' not present in zip", filePath2)
+	if err := pin(); err == nil || !strings.Contains(err.Error(), message) {
+		t.Fatal("expected pin to be rejected", err)
 	}
-	if !bytes.Equal(dataFile2Received, dataFile2) {
-		t.Fatal("file data doesn't match expected content")
+	if err := updateRegistry(); err == nil || !strings.Contains(err.Error(), message) {
+		t.Fatal("expected registry update to be rejected", err)
 	}
-	if len(files) != 2 {
-		t.Fatal("unexpected amount of files")
+	if err := tusUpload(); err == nil {
+		t.Fatal("expected TUS upload to be rejected")
 	}
 
-	// now specify the zip format
-	_, skyfileReader, err = r.SkynetSkylinkZipReaderGet(fmt.Sprintf("%s/a", skylink))
+	// Downloads keep working.
+	_, err = r.SkynetSkylinkGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// verify we get a 400 if we supply an unsupported format parameter
-	_, err = r.SkynetSkylinkGet(fmt.Sprintf("%s/b?format=raw", skylink))
-	if err == nil || !strings.Contains(err.Error(), "unable to parse 'format'") {
-		t.Fatal("Expected download to fail because we are downloading a directory and an invalid format was provided, err:", err)
-	}
-
-	// verify we default to the `zip` format if it is a directory and we have
-	// not specified it (use a HEAD call as that returns the response headers)
-	_, header, err := r.SkynetSkylinkHead(skylink)
+	// Disable the maintenance mode again. New data is accepted.
+	err = r.SkynetMaintenancePost(false, "")
 	if err != nil {
-		t.Fatal("unexpected error")
+		t.Fatal(err)
 	}
-	ct := header.Get("Content-Type")
-	if ct != "application/zip" {
-		t.Fatal("unexpected content type: ", ct)
+	if res, body := upload(); res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
 	}
-}
-
-// testSkynetDownloadRangeEncrypted verifies we can download a certain range
-// within an encrypted skyfile. This large file part of this test was added to
-// verify whether `DecryptBytesInPlace` was properly decrypting the fanout bytes
-// for offsets other than 0.
-func testSkynetDownloadRangeEncrypted(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-
-	// add a skykey
-	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
-	if err != nil {
+	if err := pin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tusUpload(); err != nil {
 		t.Fatal(err)
 	}
-	t.Run("SmallFile", func(t *testing.T) {
-		skynetDownloadRangeTest(t, tg, sk.Name, false)
-	})
-	t.Run("LargeFile", func(t *testing.T) {
-		skynetDownloadRangeTest(t, tg, sk.Name, true)
-	})
-}
-
-// testSkynetDownloadRange verifies we can download a certain range within a
-// skyfile.
-func testSkynetDownloadRange(t *testing.T, tg *siatest.TestGroup) {
-	t.Run("SmallFile", func(t *testing.T) {
-		skynetDownloadRangeTest(t, tg, "", false)
-	})
-	t.Run("LargeFile", func(t *testing.T) {
-		skynetDownloadRangeTest(t, tg, "", true)
-	})
 }
 
-// skynetDownloadRangeTest verifies different conditions of skynet downloads
-// with range requests.
-func skynetDownloadRangeTest(t *testing.T, tg *siatest.TestGroup, skykeyName string, largeFile bool) {
+// testSkynetAPIKeys verifies that API keys can only access the skynet
+// endpoints allowed by their scope.
+func testSkynetAPIKeys(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// generate file params
-	name := t.Name() + persist.RandomSuffix()
-	var size uint64
-	if largeFile {
-		size = uint64(4 * int(modules.SectorSize))
-	} else {
-		size = 100
-	}
-	data := fastrand.Bytes(int(size))
-
-	// upload a skyfile
-	_, _, sshp, err := r.UploadNewEncryptedSkyfileBlocking(name, data, skykeyName, false)
+	// Upload a skyfile to download with the key.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("apikeys", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// calculate random range parameters
-	segment := uint64(crypto.SegmentSize)
-	var offset, length uint64
-	if largeFile {
-		offset = fastrand.Uint64n(size-modules.SectorSize) + 1
-		length = fastrand.Uint64n(size-offset-segment) + 1
-	} else {
-		offset = fastrand.Uint64n(size-segment) + 1
-		length = fastrand.Uint64n(size-offset) + 1
-	}
-
-	// fetch the data at given range using the Header range request
-	result, err := r.SkynetSkylinkRange(sshp.Skylink, offset, offset+length)
+	// Create a read-only key.
+	key, err := r.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeRead)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(result, data[offset:offset+length]) {
-		t.Logf("range %v-%v\n", offset, offset+length)
-		t.Log("expected:", data[offset:offset+length], len(data[offset:offset+length]))
-		t.Log("actual:", result, len(result))
-		t.Fatal("unexpected")
+	if key.Key == "" || key.Scope != skymodules.SkynetAPIKeyScopeRead || key.ID != skymodules.SkynetAPIKeyID(key.Key) {
+		t.Fatal("unexpected key", key)
 	}
-
-	// fetch the data at given range using the params range request
-	result, err = r.SkynetSkylinkRangeParams(sshp.Skylink, offset, offset+length)
+	akg, err := r.SkynetAPIKeysGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(result, data[offset:offset+length]) {
-		t.Logf("range %v-%v\n", offset, offset+length)
-		t.Log("expected:", data[offset:offset+length], len(data[offset:offset+length]))
-		t.Log("actual:", result, len(result))
-		t.Fatal("unexpected")
+	var found bool
+	for _, k := range akg.Keys {
+		found = found || k.ID == key.ID
 	}
-
-	// Test some specific cases
-	// Verify some specific cases
-	rand := fastrand.Uint64n(size)
-	var tests = []struct {
-		from uint64
-		to   uint64
-	}{
-		{0, 0},       // Requesting the first byte of a file
-		{0, 1},       // Request the first byte of a file
-		{rand, rand}, // Requesting a random single byte of the file
+	if !found {
+		t.Fatal("key not found", akg.Keys)
 	}
-	for _, test := range tests {
-		_, err = r.SkynetSkylinkRange(sshp.Skylink, test.from, test.to)
+
+	// Create a client which uses the key instead of the password.
+	keyClient := r.Client
+	keyClient.Password = key.Key
+
+	// status is a helper to perform a request with the key and return the
+	// status code.
+	status := func(method, resource string, body io.Reader) int {
+		t.Helper()
+		req, err := keyClient.NewRequest(method, resource, body)
 		if err != nil {
-			t.Log("Failed Test Case:", test)
 			t.Fatal(err)
 		}
-		_, err = r.SkynetSkylinkRangeParams(sshp.Skylink, test.from, test.to)
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		res, err := http.DefaultClient.Do(req)
 		if err != nil {
-			t.Log("Failed Test Case:", test)
 			t.Fatal(err)
 		}
-	}
-}
-
-// testSkynetDownloadBaseSectorEncrypted tests downloading a skylink's encrypted
-// baseSector
-func testSkynetDownloadBaseSectorEncrypted(t *testing.T, tg *siatest.TestGroup) {
-	testSkynetDownloadBaseSector(t, tg, "basesectorkey")
-}
-
-// testSkynetDownloadBaseSectorNoEncryption tests downloading a skylink's
-// baseSector
-func testSkynetDownloadBaseSectorNoEncryption(t *testing.T, tg *siatest.TestGroup) {
-	testSkynetDownloadBaseSector(t, tg, "")
-}
-
-// testSkynetDownloadBaseSector tests downloading a skylink's baseSector
-func testSkynetDownloadBaseSector(t *testing.T, tg *siatest.TestGroup, skykeyName string) {
-	r := tg.Renters()[0]
-
-	// Add the SkyKey
-	var sk skykey.Skykey
-	var err error
-	if skykeyName != "" {
-		sk, err = r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+		_, err = io.Copy(ioutil.Discard, res.Body)
+		err = errors.Compose(err, res.Body.Close())
 		if err != nil {
 			t.Fatal(err)
 		}
+		return res.StatusCode
 	}
 
-	// Upload a small skyfile
-	filename := "onlyBaseSector" + persist.RandomSuffix()
-	size := 100 + siatest.Fuzz()
-	smallFileData := fastrand.Bytes(size)
-	skylink, _, sshp, err := r.UploadNewEncryptedSkyfileBlocking(filename, smallFileData, skykeyName, false)
-	if err != nil {
+	// The key can download the skylink and access read endpoints.
+	if _, err := keyClient.SkynetSkylinkGet(skylink); err != nil {
 		t.Fatal(err)
 	}
-
-	// Create a v2 skylink from it.
-	var skylinkV1 skymodules.Skylink
-	err = skylinkV1.LoadString(skylink)
-	if err != nil {
+	if _, err := keyClient.SkynetPinnedGet(); err != nil {
 		t.Fatal(err)
 	}
-	skylinkV2, err := r.NewSkylinkV2(skylinkV1)
-	if err != nil {
+	bundleEntries := []api.SkynetBundleEntry{{Skylink: skylink, Path: "apikeys"}}
+	if _, err := keyClient.SkynetBundlePost(bundleEntries, skymodules.SkyfileFormatZip); err != nil {
 		t.Fatal(err)
 	}
-
-	// Download the BaseSector reader
-	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
-	if err != nil {
+	if _, err := keyClient.SkynetZipPost(bundleEntries); err != nil {
 		t.Fatal(err)
 	}
 
-	// Read the baseSector
-	baseSector, err := ioutil.ReadAll(baseSectorReader)
-	if err != nil {
-		t.Fatal(err)
+	// The key can't upload, update the blocklist or delete skykeys.
+	uploadQuery := fmt.Sprintf("/skynet/skyfile/%v?filename=apikeys", skymodules.RandomSiaPath())
+	if code := status("POST", uploadQuery, bytes.NewReader(fastrand.Bytes(100))); code != http.StatusForbidden {
+		t.Fatal("expected upload to be forbidden", code)
+	}
+	blocklist := url.Values{}
+	blocklist.Set("add", skylink)
+	if code := status("POST", "/skynet/blocklist", strings.NewReader(blocklist.Encode())); code != http.StatusForbidden {
+		t.Fatal("expected blocklist update to be forbidden", code)
+	}
+	skykeyDelete := url.Values{}
+	skykeyDelete.Set("name", "apikeys")
+	if code := status("POST", "/skynet/deleteskykey", strings.NewReader(skykeyDelete.Encode())); code != http.StatusForbidden {
+		t.Fatal("expected skykey deletion to be forbidden", code)
+	}
+	_, err = keyClient.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeAdmin)
+	if err == nil || !strings.Contains(err.Error(), "is not allowed to access this endpoint") {
+		t.Fatal("expected key creation to be forbidden", err)
+	}
+	if code := status("GET", "/skynet/workers", nil); code != http.StatusForbidden {
+		t.Fatal("expected workers summary to be forbidden", code)
 	}
 
-	// Download the BaseSector reader for the V2 link.
-	baseSectorReaderV2, err := r.SkynetBaseSectorGet(skylinkV2.String())
+	// An upload key can upload but not update the blocklist.
+	uploadKey, err := r.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeUpload)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Read the baseSector
-	baseSectorV2, err := ioutil.ReadAll(baseSectorReaderV2)
+	uploadClient := r.Client
+	uploadClient.Password = uploadKey.Key
+	_, _, err = uploadClient.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
+		SiaPath:  skymodules.RandomSiaPath(),
+		Filename: "apikeys",
+		Reader:   bytes.NewReader(fastrand.Bytes(100)),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = uploadClient.SkynetBlocklistPost([]string{skylink}, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not allowed to access this endpoint") {
+		t.Fatal("expected blocklist update to be forbidden", err)
+	}
 
-	// They should be the same.
-	if !bytes.Equal(baseSector, baseSectorV2) {
-		t.Fatal("base sectors don't match")
+	// Unknown keys are rejected as unauthenticated.
+	keyClient.Password = "unknown"
+	if code := status("POST", "/skynet/blocklist", strings.NewReader(blocklist.Encode())); code != http.StatusUnauthorized {
+		t.Fatal("expected unknown key to be unauthorized", code)
 	}
 
-	// Check for encryption
-	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
-	if encrypted != (skykeyName != "") {
-		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	// Requests without a key are rejected as unauthenticated.
+	keyClient.Password = ""
+	if code := status("GET", "/skynet/workers", nil); code != http.StatusUnauthorized {
+		t.Fatal("expected unauthenticated workers request to be unauthorized", code)
 	}
-	if encrypted {
-		_, err = skymodules.DecryptBaseSector(baseSector, sk)
+
+	// Delete the keys. Afterwards the read key is rejected.
+	if err := r.SkynetAPIKeyDeletePost(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SkynetAPIKeyDeletePost(uploadKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	keyClient.Password = key.Key
+	if code := status("GET", "/skynet/pinned", nil); code != http.StatusUnauthorized {
+		t.Fatal("expected deleted key to be unauthorized", code)
+	}
+	err = r.SkynetAPIKeyDeletePost(key.ID)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrSkynetAPIKeyNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testSkynetUploadRateLimit verifies that the renter rejects the uploads of
+// IPs which exceed the upload rate limit.
+func testSkynetUploadRateLimit(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// upload is a helper to upload a small skyfile and return the response.
+	// If forwardedFor is set, it is sent as the X-Forwarded-For header.
+	upload := func(forwardedFor string) (*http.Response, []byte) {
+		t.Helper()
+		query := fmt.Sprintf("/skynet/skyfile/%v?filename=ratelimit", skymodules.RandomSiaPath())
+		req, err := r.NewRequest("POST", query, bytes.NewReader(fastrand.Bytes(100)))
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, body
 	}
 
-	// Parse the skyfile metadata from the baseSector
-	_, fanoutBytes, metadata, _, baseSectorPayload, err := skymodules.ParseSkyfileMetadata(baseSector)
+	// A limit without a window is invalid.
+	err := r.SkynetUploadRateLimitPost(skymodules.SkynetUploadRateLimit{MaxUploads: 1})
+	if err == nil || !strings.Contains(err.Error(), "requires a window") {
+		t.Fatal("expected invalid limit to be rejected", err)
+	}
+
+	// Allow for two uploads per window.
+	limit := skymodules.SkynetUploadRateLimit{
+		MaxUploads:    2,
+		WindowSeconds: 3600,
+	}
+	err = r.SkynetUploadRateLimitPost(limit)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Verify the metadata
-	expected := skymodules.SkyfileMetadata{
-		Filename: filename,
-		Length:   uint64(size),
-		Mode:     os.FileMode(skymodules.DefaultFilePerm),
+	defer func() {
+		if err := r.SkynetUploadRateLimitPost(skymodules.SkynetUploadRateLimit{}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rl, err := r.SkynetUploadRateLimitGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	if !reflect.DeepEqual(expected, metadata) {
-		siatest.PrintJSON(expected)
-		siatest.PrintJSON(metadata)
-		t.Error("Metadata not equal")
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rl != limit || rg.Settings.SkynetUploadRateLimit != limit {
+		t.Fatal("unexpected limit", rl, rg.Settings.SkynetUploadRateLimit)
 	}
 
-	// Verify the file data
-	if !bytes.Equal(smallFileData, baseSectorPayload) {
-		t.Log("FileData bytes:", smallFileData)
-		t.Log("BaseSectorPayload bytes:", baseSectorPayload)
-		t.Errorf("Bytes not equal")
+	// The third upload is rejected.
+	for i := 0; i < 2; i++ {
+		if res, body := upload(""); res.StatusCode != http.StatusOK {
+			t.Fatal("unexpected response", res.StatusCode, string(body))
+		}
+	}
+	res, body := upload("")
+	if res.StatusCode != http.StatusTooManyRequests || !strings.Contains(string(body), "upload rate limit exceeded") {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
+	}
+	if res.Header.Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After header")
 	}
 
-	// Since this was a small file upload there should be no fanout bytes
-	if len(fanoutBytes) != 0 {
-		t.Error("Expected 0 fanout bytes:", fanoutBytes)
+	// The other upload endpoints share the limit.
+	_, err = r.SkynetUploadBeginPost(skymodules.SkyfileUploadParameters{SiaPath: skymodules.RandomSiaPath()}, bytes.NewReader(fastrand.Bytes(100)))
+	if err == nil || !strings.Contains(err.Error(), "upload rate limit exceeded") {
+		t.Fatal("expected two-phase upload to be rejected", err)
+	}
+	chunkSize := int64(skymodules.ChunkSize(crypto.TypePlain, uint64(skymodules.RenterDefaultDataPieces)))
+	_, err = r.SkynetTUSUploadFromBytes(fastrand.Bytes(100), chunkSize, "ratelimit", "")
+	if err == nil {
+		t.Fatal("expected TUS upload to be rejected")
 	}
 
-	// Verify DownloadByRoot gives the same information
-	rootSectorReader, err := r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
+	// Limit the bytes instead. The uploads of the window count towards it.
+	err = r.SkynetUploadRateLimitPost(skymodules.SkynetUploadRateLimit{
+		MaxBytes:      250,
+		WindowSeconds: 3600,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if res, body := upload(""); res.StatusCode != http.StatusTooManyRequests {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
+	}
 
-	// Read the rootSector
-	rootSector, err := ioutil.ReadAll(rootSectorReader)
+	// The header of the proxy is ignored unless it is trusted.
+	if res, body := upload("1.2.3.4"); res.StatusCode != http.StatusTooManyRequests {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
+	}
+
+	// Trust the header. Every forwarded IP has its own limit and only the
+	// last IP of the header, which is appended by the proxy, counts.
+	err = r.SkynetUploadRateLimitPost(skymodules.SkynetUploadRateLimit{
+		MaxUploads:        1,
+		WindowSeconds:     3600,
+		TrustForwardedFor: true,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Check for encryption
-	encrypted = skymodules.IsEncryptedBaseSector(rootSector)
-	if encrypted != (skykeyName != "") {
-		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	if res, body := upload("1.2.3.4"); res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
 	}
-	if encrypted {
-		_, err = skymodules.DecryptBaseSector(rootSector, sk)
-		if err != nil {
-			t.Fatal(err)
-		}
+	if res, body := upload("5.6.7.8, 1.2.3.4"); res.StatusCode != http.StatusTooManyRequests {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
+	}
+	if res, body := upload("5.6.7.8"); res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
 	}
 
-	// Parse the skyfile metadata from the rootSector
-	_, fanoutBytes, metadata, _, rootSectorPayload, err := skymodules.ParseSkyfileMetadata(rootSector)
+	// Disabling the limit allows for uploads again.
+	err = r.SkynetUploadRateLimitPost(skymodules.SkynetUploadRateLimit{})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Verify the metadata
-	if !reflect.DeepEqual(expected, metadata) {
-		siatest.PrintJSON(expected)
-		siatest.PrintJSON(metadata)
-		t.Error("Metadata not equal")
-	}
-
-	// Verify the file data
-	if !bytes.Equal(smallFileData, rootSectorPayload) {
-		t.Log("FileData bytes:", smallFileData)
-		t.Log("rootSectorPayload bytes:", rootSectorPayload)
-		t.Errorf("Bytes not equal")
-	}
-
-	// Since this was a small file upload there should be no fanout bytes
-	if len(fanoutBytes) != 0 {
-		t.Error("Expected 0 fanout bytes:", fanoutBytes)
+	if res, body := upload(""); res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected response", res.StatusCode, string(body))
 	}
 }
 
-// TestSkynetDownloadByRoot verifies the functionality of the download by root
-// routes. It is separate as it requires an amount of hosts equal to the total
-// amount of pieces per chunk.
-func TestSkynetDownloadByRoot(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
-
-	// Define the parameters.
-	numHosts := 6
-	groupParams := siatest.GroupParams{
-		Hosts:  numHosts,
-		Miners: 1,
-	}
-	groupDir := skynetTestDir(t.Name())
+// testSkynetMaxUploadSize verifies that the renter rejects skyfile uploads
+// which exceed the configured maximum upload size.
+func testSkynetMaxUploadSize(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	// Create a testgroup.
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	// Set a max upload size of 2 sectors.
+	maxSize := 2 * modules.SectorSize
+	err := r.RenterSkynetMaxUploadSizePost(maxSize)
 	if err != nil {
-		t.Fatal(errors.AddContext(err, "failed to create group"))
+		t.Fatal(err)
 	}
 	defer func() {
-		if err := tg.Close(); err != nil {
+		if err := r.RenterSkynetMaxUploadSizePost(0); err != nil {
 			t.Fatal(err)
 		}
 	}()
-
-	// Update the renter's allowance to support 6 hosts
-	renterParams := node.Renter(filepath.Join(groupDir, "renter"))
-	renterParams.Allowance = siatest.DefaultAllowance
-	renterParams.Allowance.Hosts = uint64(numHosts)
-	_, err = tg.AddNodes(renterParams)
+	rg, err := r.RenterGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if rg.Settings.SkynetMaxUploadSize != maxSize {
+		t.Fatalf("expected max upload size %v but got %v", maxSize, rg.Settings.SkynetMaxUploadSize)
+	}
 
-	// Test the standard flow.
-	t.Run("NoEncryption", func(t *testing.T) {
-		testSkynetDownloadByRoot(t, tg, "")
-	})
-	t.Run("Encrypted", func(t *testing.T) {
-		testSkynetDownloadByRoot(t, tg, "rootkey")
-	})
-}
+	// upload is a helper to upload the data. If 'streaming' is set, the
+	// length of the data is hidden from the client which results in a request
+	// without a Content-Length.
+	upload := func(data []byte, streaming bool) (skymodules.SiaPath, error) {
+		var reader io.Reader = bytes.NewReader(data)
+		if streaming {
+			reader = io.MultiReader(reader)
+		}
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:  skymodules.RandomSiaPath(),
+			Filename: "maxuploadsize",
+			Reader:   reader,
+		}
+		_, _, err := r.SkynetSkyfilePost(sup)
+		return sup.SiaPath, err
+	}
 
-// testSkynetDownloadByRoot tests downloading by root
-func testSkynetDownloadByRoot(t *testing.T, tg *siatest.TestGroup, skykeyName string) {
-	r := tg.Renters()[0]
+	// Uploads up to the max size should work.
+	data := fastrand.Bytes(int(maxSize))
+	for _, streaming := range []bool{false, true} {
+		if _, err := upload(data, streaming); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	// Add the SkyKey
-	var sk skykey.Skykey
-	var err error
-	if skykeyName != "" {
-		sk, err = r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+	// Uploads exceeding the max size should fail.
+	data = fastrand.Bytes(int(maxSize) + 1)
+	for _, streaming := range []bool{false, true} {
+		siaPath, err := upload(data, streaming)
+		if err == nil || !strings.Contains(err.Error(), api.ErrSkyfileUploadTooLarge.Error()) {
+			t.Fatal("unexpected error", streaming, err)
+		}
+		if !strings.Contains(err.Error(), fmt.Sprint(maxSize)) {
+			t.Fatal("error should contain the limit", err)
+		}
+
+		// No siafiles should remain.
+		fullSiaPath, err := skymodules.SkynetFolder.Join(siaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		extendedSiaPath, err := fullSiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
 		if err != nil {
 			t.Fatal(err)
 		}
+		for _, sp := range []skymodules.SiaPath{fullSiaPath, extendedSiaPath} {
+			_, err = r.RenterFileRootGet(sp)
+			if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+				t.Fatal("siafile shouldn't exist", err)
+			}
+		}
 	}
+}
 
-	// Upload a skyfile that will have a fanout
-	filename := "byRootLargeFile" + persist.RandomSuffix()
-	size := 2*int(modules.SectorSize) + siatest.Fuzz()
-	fileData := fastrand.Bytes(size)
-	_, _, sshp, err := r.UploadNewEncryptedSkyfileBlocking(filename, fileData, skykeyName, false)
+// testSkynetMultipartSizeMismatch verifies that a multipart upload fails if a
+// part declares a Content-Length that doesn't match its data.
+func testSkynetMultipartSizeMismatch(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create a multipart body with a part lying about its length.
+	data := fastrand.Bytes(100)
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="files[]"; filename="file1"`)
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Length", fmt.Sprint(len(data)+1))
+	part, err := writer.CreatePart(h)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err = part.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
 
-	// Download the base sector
-	reader, err := r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
+	// Upload it.
+	smup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:     skymodules.RandomSiaPath(),
+		Filename:    "sizemismatch",
+		Reader:      body,
+		ContentType: writer.FormDataContentType(),
+	}
+	_, _, err = r.SkynetSkyfileMultiPartPost(smup)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrMultipartSizeMismatch.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testSkynetUploadPolicy verifies that uploads which violate the upload policy
+// are rejected without leaving any siafiles behind.
+func testSkynetUploadPolicy(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// The default policy allows everything.
+	policy, err := r.SkynetUploadPolicyGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !reflect.DeepEqual(policy, skymodules.SkynetUploadPolicy{}) {
+		t.Fatal("unexpected default policy", policy)
+	}
 
-	// Read the baseSector
-	baseSector, err := ioutil.ReadAll(reader)
+	// Block executables.
+	policy = skymodules.SkynetUploadPolicy{
+		BlockedMIMEPrefixes: []string{"application/x-msdownload"},
+	}
+	err = r.SkynetUploadPolicyPost(policy)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Check for encryption
-	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
-	if encrypted != (skykeyName != "") {
-		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	defer func() {
+		if err := r.SkynetUploadPolicyPost(skymodules.SkynetUploadPolicy{}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rpg, err := r.SkynetUploadPolicyGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	var fileKey skykey.Skykey
-	if encrypted {
-		fileKey, err = skymodules.DecryptBaseSector(baseSector, sk)
+	if !reflect.DeepEqual(rpg, policy) {
+		t.Fatal("unexpected policy", rpg)
+	}
+
+	// Create a multipart body with a large allowed part followed by an
+	// executable.
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	parts := []struct {
+		filename    string
+		contentType string
+		size        int
+	}{
+		{"file.txt", "text/plain", int(modules.SectorSize) + siatest.Fuzz()},
+		{"virus.exe", "application/x-msdownload", 100},
+	}
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[]"; filename="%v"`, p.filename))
+		h.Set("Content-Type", p.contentType)
+		part, err := writer.CreatePart(h)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if _, err = part.Write(fastrand.Bytes(p.size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
 	}
 
-	// Parse the information from the BaseSector
-	layout, fanoutBytes, metadata, _, baseSectorPayload, err := skymodules.ParseSkyfileMetadata(baseSector)
+	// Upload it.
+	siaPath, err := skymodules.NewSiaPath(t.Name())
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Verify the metadata
-	expected := skymodules.SkyfileMetadata{
-		Filename: filename,
-		Length:   uint64(size),
-		Mode:     os.FileMode(skymodules.DefaultFilePerm),
+	req, err := r.NewRequest("POST", fmt.Sprintf("/skynet/skyfile/%v?filename=policy", siaPath), body)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(expected, metadata) {
-		siatest.PrintJSON(expected)
-		siatest.PrintJSON(metadata)
-		t.Error("Metadata not equal")
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// The baseSector should be empty since there is a fanout
-	if len(baseSectorPayload) != 0 {
-		t.Error("baseSectorPayload should be empty:", baseSectorPayload)
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// For large files there should be fanout bytes
-	if len(fanoutBytes) == 0 {
-		t.Fatal("no fanout bytes")
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatal("unexpected status", res.StatusCode, string(resBody))
+	}
+	if !strings.Contains(string(resBody), "virus.exe") {
+		t.Fatal("error doesn't name the offending file", string(resBody))
 	}
 
-	// Decode Fanout
-	piecesPerChunk, chunkRootsSize, numChunks, err := skymodules.DecodeFanout(layout, fanoutBytes)
+	// No siafiles should be left behind.
+	skynetPath, err := skymodules.SkynetFolder.Join(siaPath.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Calculate the expected pieces per chunk, and keep track of the original
-	// pieces per chunk. If there's no encryption and there's only 1 data piece,
-	// the fanout bytes will only contain a single piece (as the other pieces
-	// will be identical, so that would be wasting space). We need to take this
-	// into account when recovering the data as the EC will expect the original
-	// amount of pieces.
-	expectedPPC := layout.FanoutDataPieces + layout.FanoutParityPieces
-	originalPPC := expectedPPC
-	if layout.FanoutDataPieces == 1 && layout.CipherType == crypto.TypePlain {
-		expectedPPC = 1
+	extendedPath, err := skynetPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sp := range []skymodules.SiaPath{skynetPath, extendedPath} {
+		_, err = r.RenterFileRootGet(sp)
+		if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+			t.Fatal("expected siafile to not exist", sp, err)
+		}
 	}
 
-	// Verify fanout information
-	if piecesPerChunk != uint64(expectedPPC) {
-		t.Fatal("piecesPerChunk incorrect", piecesPerChunk)
+	// Single file uploads are checked against the request's content type.
+	smup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:     skymodules.RandomSiaPath(),
+		Filename:    "virus.exe",
+		Reader:      bytes.NewReader(fastrand.Bytes(100)),
+		ContentType: "application/x-msdownload",
 	}
-	if chunkRootsSize != crypto.HashSize*piecesPerChunk {
-		t.Fatal("chunkRootsSize incorrect", chunkRootsSize)
+	_, _, err = r.SkynetSkyfileMultiPartPost(smup)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrUploadPolicyViolation.Error()) {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Derive the fanout key
-	var fanoutKey crypto.CipherKey
-	if encrypted {
-		fanoutKey, err = skymodules.DeriveFanoutKey(&layout, fileKey)
+	// Limit the number of subfiles.
+	err = r.SkynetUploadPolicyPost(skymodules.SkynetUploadPolicy{MaxSubfiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a multipart body with one file more than allowed.
+	body = new(bytes.Buffer)
+	writer = multipart.NewWriter(body)
+	for i := 0; i < 3; i++ {
+		part, err := writer.CreateFormFile("files[]", fmt.Sprintf("file%v.txt", i))
 		if err != nil {
 			t.Fatal(err)
 		}
+		if _, err = part.Write(fastrand.Bytes(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
 	}
 
-	// Create the erasure coder
-	ec, err := skymodules.NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	// Upload it.
+	req, err = r.NewRequest("POST", fmt.Sprintf("/skynet/skyfile/%v?filename=subfiles", skymodules.RandomSiaPath()), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resBody, err = ioutil.ReadAll(res.Body)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatal("unexpected status", res.StatusCode, string(resBody))
+	}
+	if !strings.Contains(string(resBody), skymodules.ErrTooManySubfiles.Error()) {
+		t.Fatal("unexpected error", string(resBody))
+	}
+}
 
-	chunkSize := (modules.SectorSize - layout.CipherType.Overhead()) * uint64(layout.FanoutDataPieces)
-	// Create list of chunk roots
-	chunkRoots := make([][]crypto.Hash, 0, numChunks)
-	for i := uint64(0); i < numChunks; i++ {
-		root := make([]crypto.Hash, piecesPerChunk)
-		for j := uint64(0); j < piecesPerChunk; j++ {
-			fanoutOffset := (i * chunkRootsSize) + (j * crypto.HashSize)
-			copy(root[j][:], fanoutBytes[fanoutOffset:])
+// testSkynetMultipartLimits verifies that multipart uploads exceeding the
+// multipart limits or the number of subfiles of the upload policy are rejected
+// without leaving a siafile behind.
+func testSkynetMultipartLimits(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Set the limits.
+	limits := skymodules.SkynetMultipartLimits{
+		MaxFilenameLength: 20,
+	}
+	err := r.RenterSkynetMultipartLimitsPost(limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.RenterSkynetMultipartLimitsPost(skymodules.SkynetMultipartLimits{}); err != nil {
+			t.Fatal(err)
 		}
-		chunkRoots = append(chunkRoots, root)
+	}()
+	err = r.SkynetUploadPolicyPost(skymodules.SkynetUploadPolicy{MaxSubfiles: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.SkynetUploadPolicyPost(skymodules.SkynetUploadPolicy{}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rg.Settings.SkynetMultipartLimits, limits) {
+		t.Fatal("unexpected limits", rg.Settings.SkynetMultipartLimits)
 	}
 
-	// Download roots
-	var rootBytes []byte
-	var blankHash crypto.Hash
-	for i := uint64(0); i < numChunks; i++ {
-		// Create the pieces for this chunk
-		pieces := make([][]byte, len(chunkRoots[i]))
-		for j := uint64(0); j < piecesPerChunk; j++ {
-			// Ignore null roots
-			if chunkRoots[i][j] == blankHash {
-				continue
+	// upload uploads a multipart body with a large first part followed by
+	// the given filenames and checks the response.
+	upload := func(name string, filenames []string, status int, errStr string) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		filenames = append([]string{"large"}, filenames...)
+		for i, filename := range filenames {
+			size := 100
+			if i == 0 {
+				size = int(modules.SectorSize) + siatest.Fuzz()
 			}
-
-			// Download the sector
-			reader, err := r.SkynetDownloadByRootGet(chunkRoots[i][j], 0, modules.SectorSize, -1)
+			part, err := writer.CreateFormFile("files[]", filename)
 			if err != nil {
-				t.Log("root", chunkRoots[i][j])
 				t.Fatal(err)
 			}
-
-			// Read the sector
-			sector, err := ioutil.ReadAll(reader)
-			if err != nil {
+			if _, err = part.Write(fastrand.Bytes(size)); err != nil {
 				t.Fatal(err)
 			}
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		siaPath, err := skymodules.NewSiaPath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := r.NewRequest("POST", fmt.Sprintf("/skynet/skyfile/%v?filename=limits", siaPath), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resBody, err := ioutil.ReadAll(res.Body)
+		if err := errors.Compose(err, res.Body.Close()); err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != status || !strings.Contains(string(resBody), errStr) {
+			t.Fatal("unexpected response", res.StatusCode, string(resBody))
+		}
 
-			// Decrypt to data if needed
-			if encrypted {
-				key := fanoutKey.Derive(i, j)
-				_, err = key.DecryptBytesInPlace(sector, 0)
-				if err != nil {
-					t.Fatal(err)
-				}
+		// No siafiles should be left behind.
+		skynetPath, err := skymodules.SkynetFolder.Join(siaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		extendedPath, err := skynetPath.AddSuffixStr(skymodules.ExtendedSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sp := range []skymodules.SiaPath{skynetPath, extendedPath} {
+			_, err = r.RenterFileRootGet(sp)
+			if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+				t.Fatal("expected siafile to not exist", sp, err)
 			}
-
-			// Add the sector to the list of pieces
-			pieces[j] = sector
 		}
+	}
+	upload("duplicate", []string{"dir/file", "dir//file"}, http.StatusBadRequest, "dir//file")
+	upload("longname", []string{strings.Repeat("a", 21)}, http.StatusBadRequest, skymodules.ErrSubfileNameTooLong.Error())
+	upload("toomany", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}, http.StatusRequestEntityTooLarge, skymodules.ErrTooManySubfiles.Error())
+}
 
-		// Decode the erasure coded chunk
-		var chunkBytes []byte
-		if ec != nil {
-			buf := bytes.NewBuffer(nil)
-			if len(pieces) == 1 && originalPPC > 1 {
-				deduped := make([][]byte, originalPPC)
-				for i := range deduped {
-					deduped[i] = make([]byte, len(pieces[0]))
-					copy(deduped[i], pieces[0])
-				}
-				pieces = deduped
-			}
-			err = ec.Recover(pieces, chunkSize, buf)
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	io.Reader
+	n uint64
+}
+
+// Read implements io.Reader.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.n += uint64(n)
+	return n, err
+}
+
+// testSkynetResumableMultipartUpload tests that a multipart upload which
+// failed after some of its parts were received can be resumed without
+// transferring those parts again.
+func testSkynetResumableMultipartUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Prepare 4 subfiles.
+	filenames := []string{"file1.txt", "file2.txt", "file3.txt", "file4.txt"}
+	var datas [][]byte
+	hashes := make(map[string]crypto.Hash)
+	for i, filename := range filenames {
+		data := fastrand.Bytes(1000 * (i + 1))
+		datas = append(datas, data)
+		hashes[filename] = crypto.HashBytes(data)
+	}
+	session := hex.EncodeToString(fastrand.Bytes(16))
+
+	// upload uploads the subfiles within a resumable upload. The parts for
+	// which data returns nil are sent as placeholders. It returns the number
+	// of bytes which were sent.
+	upload := func(name string, data func(int) []byte) (string, uint64, error) {
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		for i, filename := range filenames {
+			_, err := skymodules.AddMultipartFile(writer, data(i), "files[]", filename, skymodules.DefaultFilePerm, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
-			chunkBytes = buf.Bytes()
-		} else {
-			// The unencrypted file is not erasure coded so just read the piece
-			// data directly
-			for _, p := range pieces {
-				chunkBytes = append(chunkBytes, p...)
-			}
 		}
-		rootBytes = append(rootBytes, chunkBytes...)
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		siaPath, err := skymodules.NewSiaPath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cr := &countingReader{Reader: body}
+		smup := skymodules.SkyfileMultipartUploadParameters{
+			SiaPath:     siaPath,
+			Filename:    name,
+			Reader:      cr,
+			ContentType: writer.FormDataContentType(),
+		}
+		skylink, _, err := r.SkynetSkyfileMultiPartPostResumable(smup, session, hashes)
+		return skylink, cr.n, err
 	}
 
-	// Truncate download data to the file length
-	rootBytes = rootBytes[:size]
+	// The first attempt fails on the third part since its data was
+	// corrupted. The first 2 parts are staged.
+	_, failedBytes, err := upload("resumable-failed", func(i int) []byte {
+		if i == 2 {
+			corrupted := append([]byte{}, datas[i]...)
+			corrupted[0]++
+			return corrupted
+		}
+		return datas[i]
+	})
+	if err == nil || !strings.Contains(err.Error(), "doesn't match its hash") {
+		t.Fatal("expected the upload to fail", err)
+	}
 
-	// Verify bytes
-	if !reflect.DeepEqual(fileData, rootBytes) {
-		t.Log("FileData bytes:", fileData)
-		t.Log("root bytes:", rootBytes)
-		t.Error("Bytes not equal")
+	// The retry only sends the remaining 2 parts.
+	skylink, retryBytes, err := upload("resumable", func(i int) []byte {
+		if i < 2 {
+			return nil
+		}
+		return datas[i]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := failedBytes - uint64(len(datas[0])+len(datas[1])); retryBytes != expected {
+		t.Fatalf("expected retry to send %v bytes but sent %v", expected, retryBytes)
+	}
+
+	// The skyfile contains all subfiles.
+	_, md, err := r.SkynetMetadataGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset uint64
+	for i, filename := range filenames {
+		sf := md.Subfiles[filename]
+		if sf.Offset != offset || sf.Len != uint64(len(datas[i])) {
+			t.Fatal("unexpected subfile metadata", sf)
+		}
+		offset += sf.Len
+		data, err := r.SkynetSkylinkGet(skylink + "/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("unexpected data", filename)
+		}
+	}
+
+	// The staged parts are removed once the upload succeeded.
+	_, _, err = upload("resumable-again", func(int) []byte {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "doesn't match its hash") {
+		t.Fatal("expected the upload to fail", err)
 	}
 }
 
-// testSkynetFanoutRegression is a regression test that ensures the fanout bytes
-// of a skyfile don't contain any empty hashes
-func testSkynetFanoutRegression(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetUploadEstimate tests estimating the cost of skyfile uploads.
+func testSkynetUploadEstimate(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Add a skykey to the renter
-	sk, err := r.SkykeyCreateKeyPost("fanout", skykey.TypePrivateID)
+	// Estimate a small upload.
+	small, err := r.SkynetUploadEstimatePost(100, 0, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if small.NumSectors != uint64(renter.SkyfileDefaultBaseChunkRedundancy) {
+		t.Fatal("unexpected number of sectors", small.NumSectors)
+	}
+	if small.TotalCost.IsZero() {
+		t.Fatal("estimate shouldn't be zero")
+	}
 
-	// Upload a file with a large number of parity pieces to we can be reasonable
-	// confident that the upload will not fully complete before the fanout needs
-	// to be generated.
-	size := 2*int(modules.SectorSize) + siatest.Fuzz()
-	data := fastrand.Bytes(size)
-	skylink, _, _, _, err := r.UploadSkyfileCustom("regression", data, sk.Name, 20, false)
+	// A large upload costs more.
+	large, err := r.SkynetUploadEstimatePost(modules.SectorSize*10, 0, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if large.NumSectors <= small.NumSectors || large.TotalCost.Cmp(small.TotalCost) <= 0 {
+		t.Fatal("large upload should cost more", siatest.PrintJSON(small), siatest.PrintJSON(large))
+	}
 
-	// Download the basesector to check the fanout bytes
-	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+	// More redundancy costs more.
+	redundant, err := r.SkynetUploadEstimatePost(100, renter.SkyfileDefaultBaseChunkRedundancy+1, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	baseSector, err := ioutil.ReadAll(baseSectorReader)
+	if redundant.NumSectors != small.NumSectors+1 || redundant.TotalCost.Cmp(small.TotalCost) <= 0 {
+		t.Fatal("redundant upload should cost more", siatest.PrintJSON(small), siatest.PrintJSON(redundant))
+	}
+
+	// Estimating doesn't upload anything.
+	rf, err := r.RenterFilesGet(false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = skymodules.DecryptBaseSector(baseSector, sk)
+	numFiles := len(rf.Files)
+	_, err = r.SkynetUploadEstimatePost(modules.SectorSize*10, 0, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(baseSector)
+	rf, err = r.RenterFilesGet(false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(rf.Files) != numFiles {
+		t.Fatal("estimate shouldn't upload files")
+	}
 
-	// FanoutBytes should not contain any empty hashes
-	for i := 0; i < len(fanoutBytes); {
-		end := i + crypto.HashSize
-		var emptyHash crypto.Hash
-		root := fanoutBytes[i:end]
-		if bytes.Equal(root, emptyHash[:]) {
-			t.Fatal("empty hash found in fanout")
-		}
-		i = end
+	// Requesting more pieces than there are hosts fails.
+	_, err = r.SkynetUploadEstimatePost(100, 0, 100, 100)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrInvalidFanoutPieces.Error()) {
+		t.Fatal("unexpected error", err)
 	}
 }
 
-// testSkynetSubDirDownload verifies downloading data from a skyfile using a
-// path to download single subfiles or subdirectories
-func testSkynetSubDirDownload(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetCORS tests the CORS headers of skylink downloads.
+func testSkynetCORS(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	body := new(bytes.Buffer)
-	writer := multipart.NewWriter(body)
-
-	dataFile1 := []byte("file1.txt")
-	dataFile2 := []byte("file2.txt")
-	dataFile3 := []byte("file3.txt")
-	filePath1 := "a/5.f4f8b583.chunk.js"
-	filePath2 := "a/5.f4f.chunk.js.map"
-	filePath3 := "b/file3.txt"
-	_, err := skymodules.AddMultipartFile(writer, dataFile1, "files[]", filePath1, 0600, nil)
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("cors", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = skymodules.AddMultipartFile(writer, dataFile2, "files[]", filePath2, 0600, nil)
+
+	// request is a helper to send a request from the given origin and
+	// return the response headers.
+	origin := "https://skapp.hns.siasky.net"
+	request := func(method, origin string) http.Header {
+		req, err := r.NewRequest(method, fmt.Sprintf("/skynet/skylink/%v", skylink), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Headers", "range")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(ioutil.Discard, res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+			t.Fatal("unexpected status code", method, res.StatusCode)
+		}
+		return res.Header
+	}
+
+	// By default no CORS headers are set.
+	if h := request("GET", origin); h.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("unexpected CORS header", h)
+	}
+	if h := request("OPTIONS", origin); h.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("unexpected CORS header", h)
+	}
+
+	// Allow the origin.
+	err = r.RenterSkynetCORSOriginsPost([]string{"https://siasky.net", origin})
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = skymodules.AddMultipartFile(writer, dataFile3, "files[]", filePath3, 0640, nil)
+	defer func() {
+		if err := r.RenterSkynetCORSOriginsPost(nil); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(rg.Settings.SkynetCORSOrigins) != 2 {
+		t.Fatal("wrong origins", rg.Settings.SkynetCORSOrigins)
+	}
+	h := request("GET", origin)
+	if h.Get("Access-Control-Allow-Origin") != origin {
+		t.Fatal("wrong allowed origin", h)
+	}
+	if !strings.Contains(h.Get("Access-Control-Expose-Headers"), api.SkynetSkylinkHeader) {
+		t.Fatal("skylink header should be exposed", h)
+	}
+	h = request("OPTIONS", origin)
+	if h.Get("Access-Control-Allow-Origin") != origin || h.Get("Access-Control-Allow-Methods") == "" || h.Get("Access-Control-Allow-Headers") != "range" {
+		t.Fatal("wrong preflight headers", h)
+	}
 
-	if err := writer.Close(); err != nil {
-		t.Fatal(err)
+	// Other origins are not allowed.
+	if h := request("GET", "https://evil.com"); h.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("unexpected CORS header", h)
 	}
-	reader := bytes.NewReader(body.Bytes())
 
-	name := "testSkynetSubfileDownload"
-	uploadSiaPath, err := skymodules.NewSiaPath(name)
+	// The wildcard allows all origins.
+	err = r.RenterSkynetCORSOriginsPost([]string{"*"})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if h := request("GET", "https://evil.com"); h.Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatal("wrong allowed origin", h)
+	}
 
-	mup := skymodules.SkyfileMultipartUploadParameters{
-		SiaPath:             uploadSiaPath,
-		Force:               false,
-		Root:                false,
-		BaseChunkRedundancy: 2,
-		Reader:              reader,
-		ContentType:         writer.FormDataContentType(),
-		Filename:            name,
+	// Invalid origins are rejected.
+	err = r.RenterSkynetCORSOriginsPost([]string{"*", origin})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	err = r.RenterSkynetCORSOriginsPost([]string{"notanorigin"})
+	if err == nil {
+		t.Fatal("expected error")
 	}
+}
 
-	skylink, _, err := r.SkynetSkyfileMultiPartPost(mup)
-	if err != nil {
-		t.Fatal(err)
+// testSkynetLastModified tests that skylink downloads support conditional
+// requests based on the upload time of the skyfile.
+func testSkynetLastModified(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// request is a helper to send a GET request for the given skylink path
+	// with the given If-Modified-Since header. It returns the status code,
+	// the headers and the body of the response.
+	request := func(path, ifModifiedSince string) (int, http.Header, []byte) {
+		req, err := r.NewRequest("GET", "/skynet/skylink/"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, res.Header, body
 	}
 
-	// get all the data
-	data, err := r.SkynetSkylinkConcatGet(skylink)
+	// Upload a single file and a multipart skyfile.
+	before := time.Now().Truncate(time.Second)
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("lastmodified", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, metadata, err := r.SkynetMetadataGet(skylink)
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index")},
+		{Name: "dir/file", Data: []byte("file")},
+	}
+	multiSkylink, _, _, err := r.UploadNewMultipartSkyfileBlocking("lastmodifiedmulti", files, "", false, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if metadata.Filename != name {
-		t.Fatal("Unexpected filename", metadata.Filename, name)
+	after := time.Now()
+
+	// checkModTime is a helper to check that a modification time lies within
+	// the time of the uploads.
+	checkModTime := func(modTime time.Time) {
+		t.Helper()
+		if modTime.Before(before) || modTime.After(after) {
+			t.Fatalf("modtime %v not between %v and %v", modTime, before, after)
+		}
 	}
-	expected := append(dataFile1, dataFile2...)
-	expected = append(expected, dataFile3...)
-	if !bytes.Equal(data, expected) {
-		t.Fatal("Unexpected data")
+
+	// The file, the default path and a subfile are served with a
+	// Last-Modified header and are not served again if they weren't
+	// modified since.
+	for _, path := range []string{skylink, multiSkylink + "/", multiSkylink + "/dir/file"} {
+		status, header, _ := request(path, "")
+		if status != http.StatusOK {
+			t.Fatal("unexpected status", path, status)
+		}
+		lastModified := header.Get("Last-Modified")
+		modTime, err := http.ParseTime(lastModified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkModTime(modTime)
+		status, _, body := request(path, lastModified)
+		if status != http.StatusNotModified || len(body) != 0 {
+			t.Fatal("expected 304", path, status, len(body))
+		}
+		status, _, _ = request(path, modTime.Add(-time.Second).UTC().Format(http.TimeFormat))
+		if status != http.StatusOK {
+			t.Fatal("expected 200 for older If-Modified-Since", path, status)
+		}
 	}
 
-	// get all data for path "a"
-	data, err = r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/a", skylink))
+	// The same applies to archives.
+	archivePath := multiSkylink + "?format=tar"
+	status, header, body := request(archivePath, "")
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	lastModified := header.Get("Last-Modified")
+	modTime, err := http.ParseTime(lastModified)
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = append(dataFile1, dataFile2...)
-	if !bytes.Equal(data, expected) {
-		t.Fatal("Unexpected data")
+	checkModTime(modTime)
+	status, _, _ = request(archivePath, lastModified)
+	if status != http.StatusNotModified {
+		t.Fatal("expected 304 for archive", status)
 	}
 
-	// get all data for path "b"
-	data, err = r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/b", skylink))
-	if err != nil {
-		t.Fatal(err)
+	// Every entry of the archive has the modification time of the skyfile.
+	tr := tar.NewReader(bytes.NewReader(body))
+	var numEntries int
+	for {
+		header, err := tr.Next()
+		if errors.Contains(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !header.ModTime.Equal(modTime) {
+			t.Fatal("unexpected modtime of archive entry", header.Name, header.ModTime, modTime)
+		}
+		numEntries++
 	}
-	expected = dataFile3
-	if !bytes.Equal(expected, data) {
-		t.Fatal("Unexpected data")
-	}
-	mdF3, ok := metadata.Subfiles["b/file3.txt"]
-	if !ok {
-		t.Fatal("Expected subfile metadata of file3 to be present")
+	if numEntries != len(files) {
+		t.Fatal("unexpected number of archive entries", numEntries)
 	}
 
-	mdF3Expected := skymodules.SkyfileSubfileMetadata{
-		FileMode:    os.FileMode(0640),
-		Filename:    "b/file3.txt",
-		ContentType: "text/plain; charset=utf-8",
-		Offset:      uint64(len(dataFile1) + len(dataFile2)),
-		Len:         uint64(len(dataFile3)),
+	// Zip archives also contain the modification time.
+	status, _, body = request(multiSkylink+"?format=zip", "")
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
 	}
-	if !reflect.DeepEqual(mdF3, mdF3Expected) {
-		t.Log("expected: ", mdF3Expected)
-		t.Log("actual: ", mdF3)
-		t.Fatal("Unexpected subfile metadata for file 3")
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if !f.Modified.Equal(modTime) {
+			t.Fatal("unexpected modtime of zip entry", f.Name, f.Modified, modTime)
+		}
 	}
 
-	// get a single sub file
-	downloadFile2, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/a/5.f4f.chunk.js.map", skylink))
+	// Uploading with an explicit modtime results in the same skylink for
+	// the same data.
+	data := fastrand.Bytes(100)
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:  skymodules.RandomSiaPath(),
+		Filename: "explicitmodtime",
+		ModTime:  1600000000,
+		Reader:   bytes.NewReader(data),
+	}
+	skylink1, _, err := r.SkynetSkyfilePost(sup)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(dataFile2, downloadFile2) {
-		t.Log("expected:", dataFile2)
-		t.Log("actual:", downloadFile2)
-		t.Fatal("Unexpected data for file 2")
+	sup.SiaPath = skymodules.RandomSiaPath()
+	sup.Reader = bytes.NewReader(data)
+	skylink2, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skylink1 != skylink2 {
+		t.Fatal("skylinks should match", skylink1, skylink2)
+	}
+	_, header, _ = request(skylink1, "")
+	if lm := header.Get("Last-Modified"); lm != time.Unix(1600000000, 0).UTC().Format(http.TimeFormat) {
+		t.Fatal("unexpected Last-Modified header", lm)
 	}
 }
 
-// testSkynetDisableForce verifies the behavior of force and the header that
-// allows disabling forcefully uploading a Skyfile
-func testSkynetDisableForce(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetPreview tests downloading only the first bytes of a skyfile with
+// the 'preview' parameter.
+func testSkynetPreview(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Upload Skyfile
-	_, sup, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	// Upload a skyfile spanning multiple sectors.
+	size := 3 * modules.SectorSize
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("preview", size, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Upload at same path without force, assert this fails
-	sup = skymodules.SkyfileUploadParameters{
-		Filename: t.Name(),
-		Reader:   bytes.NewReader([]byte{1, 2, 3}),
-		SiaPath:  sup.SiaPath,
-		Force:    false,
-	}
-	_, _, err = r.SkynetSkyfilePost(sup)
-	if err == nil {
-		t.Fatal("Expected the upload without force to fail but it didn't.")
-	}
-	if !strings.Contains(err.Error(), "already exists") {
+	data, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Upload once more, but now use force. It should allow us to
-	// overwrite the file at the existing path
-	sup.Force = true
-	_, _, err = r.SkynetSkyfilePost(sup)
+	// Download a preview.
+	header, preview, err := r.SkynetSkylinkPreviewGet(skylink, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Upload using the force flag again, however now we set the
-	// Skynet-Disable-Force to true, which should prevent us from uploading.
-	// Because we have to pass in a custom header, we have to setup the request
-	// ourselves and can not use the client.
-	_, _, err = r.SkynetSkyfilePostDisableForce(sup, true)
-	if err == nil {
-		t.Fatal("Unexpected response")
+	if !bytes.Equal(preview, data[:100]) {
+		t.Fatal("wrong preview", len(preview))
 	}
-	if !strings.Contains(err.Error(), "'force' has been disabled") {
-		t.Log(err)
-		t.Fatalf("Unexpected response, expected error to contain a mention of the force flag but instaed received: %v", err.Error())
+	if header.Get(api.SkynetPreviewHeader) != fmt.Sprint(size) {
+		t.Fatal("wrong preview header", header.Get(api.SkynetPreviewHeader))
 	}
-}
-
-// TestSkynetDownloadStats is a test that verifies whether overdrive downloads
-// base sectors and fanout sectors are properly reflected in the stats. This is
-// separate test using a custom dependency because this was causing an NDF in
-// the TestSkynetStats test.
-func TestSkynetDownloadStats(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+	if header.Get("ETag") != "" {
+		t.Fatal("previews shouldn't have an ETag")
 	}
-	t.Parallel()
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  3,
-		Miners: 1,
-	}
-	groupDir := skynetTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	// A preview larger than the file returns the whole file.
+	_, preview, err = r.SkynetSkylinkPreviewGet(skylink, size+1)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
+	if !bytes.Equal(preview, data) {
+		t.Fatal("wrong preview", len(preview))
+	}
 
-	// Define a portal with a dependency that forces overdrive on downloads
-	renterParams := node.Renter(filepath.Join(groupDir, t.Name()))
-	renterParams.CreatePortal = true
-	deps := dependencies.NewDependencyOverdriveDownload()
-	renterParams.RenterDeps = deps
-	nodes, err := tg.AddNodes(renterParams)
+	// Previews of subfiles are supported but not of directories.
+	files := []siatest.TestFile{
+		{Name: "a.txt", Data: fastrand.Bytes(100)},
+		{Name: "dir/b.txt", Data: fastrand.Bytes(200)},
+	}
+	multiSkylink, _, _, err := r.UploadNewMultipartSkyfileBlocking("preview-multi", files, "", true, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
-
-	// Check all stats are 0 still
-	ss, err := r.SkynetStatsGet()
-	if ss.BaseSectorOverdriveAvg != 0 {
-		t.Fatal(err, ss.BaseSectorOverdriveAvg)
+	header, preview, err = r.SkynetSkylinkPreviewGet(multiSkylink+"/dir/b.txt", 10)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if ss.BaseSectorOverdrivePct != 0 {
-		t.Fatal(err, ss.BaseSectorOverdrivePct)
+	if !bytes.Equal(preview, files[1].Data[:10]) {
+		t.Fatal("wrong preview", len(preview))
 	}
-	if ss.FanoutSectorOverdriveAvg != 0 {
-		t.Fatal(err, ss.FanoutSectorOverdriveAvg)
+	if header.Get(api.SkynetPreviewHeader) != "200" {
+		t.Fatal("wrong preview header", header.Get(api.SkynetPreviewHeader))
 	}
-	if ss.FanoutSectorOverdrivePct != 0 {
-		t.Fatal(err, ss.FanoutSectorOverdrivePct)
+	_, _, err = r.SkynetSkylinkPreviewGet(multiSkylink+"/dir", 10)
+	if err == nil || !strings.Contains(err.Error(), "'preview' parameter can't be used to download a directory") {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Upload a small and large file with N-M redundancy
-	skylinkSmall, _, _, err := r.UploadNewSkyfileBlocking("small", modules.SectorSize/2, false)
+	// Previews can't be verified since verification requires the whole
+	// skyfile.
+	req, err := r.NewRequest("GET", fmt.Sprintf("/skynet/skylink/%v?preview=100&verify=true", skylink), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, remoteFile, err := r.UploadNewFileBlocking(int(modules.SectorSize)*2, 2, 1, false)
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
-	sup := skymodules.SkyfileUploadParameters{
-		SiaPath: skymodules.RandomSiaPath(),
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err := errors.Compose(err, res.Body.Close()); err != nil {
+		t.Fatal(err)
 	}
-	rhsp, err := r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
-	if err != nil {
-		t.Fatal("Expected conversion from Siafile to Skyfile Post to succeed.")
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(string(resBody), "'preview' parameter can't be combined") {
+		t.Fatal("unexpected response", res.StatusCode, string(resBody))
 	}
-	skylinkLarge := rhsp.Skylink
+}
 
-	// Enable the dependency
-	deps.Enable()
+// testSkynetServingProof tests downloading a skyfile together with a proof
+// signed by the portal that it served the skyfile.
+func testSkynetServingProof(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	// Download both skylinks
-	_, err = r.SkynetSkylinkGet(skylinkSmall)
+	// Upload a skyfile.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("servingproof", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.SkynetSkylinkGet(skylinkLarge)
+	var sl skymodules.Skylink
+	if err := sl.LoadString(skylink); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the portal key.
+	spkg, err := r.SkynetPortalKeyGet()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Check all download stats are not 0
-	ss, err = r.SkynetStatsGet()
-	if ss.BaseSectorOverdriveAvg == 0 {
-		t.Fatal(err, ss.BaseSectorOverdriveAvg)
+	// Without trailers the proof covers the base sector.
+	start := time.Now().Unix()
+	data, proof, err := r.SkynetSkylinkGetWithServingProof(skylink, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if ss.BaseSectorOverdrivePct == 0 {
-		t.Fatal(err, ss.BaseSectorOverdrivePct)
+	if err := proof.Verify(spkg.PublicKey); err != nil {
+		t.Fatal(err)
 	}
-	if ss.FanoutSectorOverdriveAvg == 0 {
-		t.Fatal(err, ss.FanoutSectorOverdriveAvg)
+	if proof.Skylink != skylink || proof.ContentHash != sl.MerkleRoot() || proof.BytesServed != uint64(len(data)) {
+		t.Fatal("unexpected proof", proof)
 	}
-	if ss.FanoutSectorOverdrivePct == 0 {
-		t.Fatal(err, ss.FanoutSectorOverdrivePct)
+	if proof.Timestamp < start || proof.Timestamp > time.Now().Unix() {
+		t.Fatal("unexpected timestamp", proof.Timestamp)
 	}
-}
 
-// TestSkynetBlocklist verifies the functionality of the Skynet blocklist.
-func TestSkynetBlocklist(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+	// With trailers the proof covers the hash of the served data.
+	headers := http.Header{}
+	headers.Set("TE", "trailers")
+	data, proof, err = r.SkynetSkylinkGetWithServingProof(skylink, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(spkg.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if proof.ContentHash != crypto.HashBytes(data) || proof.BytesServed != uint64(len(data)) {
+		t.Fatal("unexpected proof", proof)
 	}
-	t.Parallel()
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  3,
-		Miners: 1,
+	// A modified proof shouldn't verify.
+	proof.BytesServed++
+	if err := proof.Verify(spkg.PublicKey); !errors.Contains(err, skymodules.ErrInvalidSkynetServingProof) {
+		t.Fatal("unexpected error", err)
 	}
-	groupDir := skynetTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+}
+
+// testSkynetContentTypeOverride tests overriding the Content-Type of a skylink
+// download with the 'contenttype' parameter.
+func testSkynetContentTypeOverride(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("contenttype", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Define a portal with dependency
-	portalDir := filepath.Join(groupDir, "portal")
-	portalParams := node.Renter(portalDir)
-	portalParams.CreatePortal = true
-	deps := &dependencies.DependencyToggleDisableDeleteBlockedFiles{}
-	portalParams.RenterDeps = deps
-	_, err = tg.AddNodes(portalParams)
+	_, header, err := r.SkynetSkylinkHead(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	original := header.Get("Content-Type")
 
-	// Run subtests
-	t.Run("BlocklistHash", func(t *testing.T) {
-		testSkynetBlocklistHash(t, tg, deps)
-	})
-	t.Run("BlocklistSkylinkV1", func(t *testing.T) {
-		testSkynetBlocklistSkylink(t, tg, deps, false)
-	})
-	t.Run("BlocklistSkylinkV2", func(t *testing.T) {
-		testSkynetBlocklistSkylink(t, tg, deps, true)
-	})
-	t.Run("BlocklistUpgrade", func(t *testing.T) {
-		testSkynetBlocklistUpgrade(t, tg)
-	})
-}
-
-// testSkynetBlocklistHash tests the skynet blocklist module when submitting
-// hashes of the skylink's merkleroot
-func testSkynetBlocklistHash(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles) {
-	testSkynetBlocklist(t, tg, deps, true, false)
-}
-
-// testSkynetBlocklistSkylink tests the skynet blocklist module when submitting
-// skylinks
-func testSkynetBlocklistSkylink(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles, isV2Skylink bool) {
-	testSkynetBlocklist(t, tg, deps, false, isV2Skylink)
-}
+	// The override should be canonicalized and returned.
+	values := url.Values{}
+	values.Set("contenttype", "Text/Plain; Charset=utf-8")
+	status, header, err := r.SkynetSkylinkHeadWithParameters(skylink, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	if ct := header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Fatal("unexpected content type", ct)
+	}
 
-// testSkynetBlocklist tests the skynet blocklist module
-func testSkynetBlocklist(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles, isHash, isV2Skylink bool) {
-	r := tg.Renters()[0]
-	deps.DisableDeleteBlockedFiles(true)
+	// The override only applies to that response.
+	_, header, err = r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := header.Get("Content-Type"); ct != original {
+		t.Fatal("unexpected content type", ct, original)
+	}
 
-	// Create skyfile upload params, data should be larger than a sector size to
-	// test large file uploads and the deletion of their extended data.
-	size := modules.SectorSize + uint64(100+siatest.Fuzz())
-	skylink, sup, sshp, err := r.UploadNewSkyfileBlocking(t.Name(), size, false)
+	// Invalid content types are rejected.
+	values.Set("contenttype", "text")
+	status, _, err = r.SkynetSkylinkHeadWithParameters(skylink, values)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if status != http.StatusBadRequest {
+		t.Fatal("expected 400 for invalid content type", status)
+	}
+}
 
-	// Convert to V2 Skylink
-	if isV2Skylink {
-		skylinkV2, err := r.NewSkylinkV2FromString(skylink)
+// testSkynetVerify tests downloading skyfiles with the 'verify' parameter set.
+func testSkynetVerify(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// verify is a helper to download the skylink with and without
+	// verification and compare the data.
+	verify := func(skylink string, data []byte) {
+		verified, err := r.SkynetSkylinkGetWithVerify(skylink)
 		if err != nil {
 			t.Fatal(err)
 		}
-		skylink = skylinkV2.Skylink.String()
+		if !bytes.Equal(verified, data) {
+			t.Fatal("verified data doesn't match uploaded data")
+		}
 	}
 
-	// Remember the siaPaths of the blocked files
-	var blockedSiaPaths []skymodules.SiaPath
-
-	// Confirm that the skyfile and its extended info are registered with the
-	// renter
-	sp, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	// Verify a small skyfile which is stored within its base sector.
+	data := fastrand.Bytes(100)
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking("verify_small", data, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterFileRootGet(sp)
+	verify(skylink, data)
+
+	// Verify a large skyfile with a fanout.
+	data = fastrand.Bytes(int(2*modules.SectorSize) + 123)
+	skylink, _, _, err = r.UploadNewSkyfileWithDataBlocking("verify_large", data, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	spExtended, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+	verify(skylink, data)
+
+	// Verify a range across a chunk boundary of the large skyfile.
+	from, to := modules.SectorSize-10, modules.SectorSize+10
+	verified, err := r.SkynetSkylinkRangeWithVerify(skylink, from, to)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterFileRootGet(spExtended)
-	if err != nil {
-		t.Fatal(err)
+	if !bytes.Equal(verified, data[from:to]) {
+		t.Fatal("verified range doesn't match uploaded data")
 	}
-	blockedSiaPaths = append(blockedSiaPaths, sp, spExtended)
 
-	// Download the data
-	data, err := r.SkynetSkylinkGet(skylink)
+	// Verify an encrypted large skyfile.
+	sk, err := r.SkykeyCreateKeyPost("verify", skykey.TypePrivateID)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Blocklist the skylink
-	var add, remove []string
-	hash := crypto.HashObject(sshp.MerkleRoot)
-	if isHash {
-		add = []string{hash.String()}
-	} else {
-		add = []string{skylink}
-	}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	data = fastrand.Bytes(int(2*modules.SectorSize) + 123)
+	skylink, _, _, err = r.UploadNewEncryptedSkyfileBlocking("verify_encrypted", data, "verify", false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	verify(skylink, data)
 
-	// Confirm that the Skylink is blocked by verifying the hash of the V1
-	// skylink is in the blocklist
-	sbg, err := r.SkynetBlocklistGet()
+	// Remove the skykey from the node. The skyfile can still be verified
+	// with the skykey provided in the request.
+	err = r.SkykeyDeleteByNamePost(sk.Name)
 	if err != nil {
 		t.Fatal(err)
 	}
-	var found bool
-	for _, blocked := range sbg.Blocklist {
-		if blocked == hash {
-			found = true
-			break
-		}
-	}
-	if !found {
-		t.Fatal("Hash not found in blocklist")
-	}
-
-	// Try to download the file behind the skylink, this should fail because of
-	// the blocklist.
-	_, err = r.SkynetSkylinkGet(skylink)
-	if err == nil {
-		t.Fatal("Download should have failed")
-	}
-	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
-	}
-
-	// Try to download the BaseSector
-	_, err = r.SkynetBaseSectorGet(skylink)
-	if err == nil {
-		t.Fatal("BaseSector request should have failed")
-	}
-	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
-	}
-
-	// Try to download the BaseSector by Root
-	_, err = r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
-	if err == nil {
-		t.Fatal("DownloadByRoot request should have failed")
-	}
-	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
-	}
-
-	// Try and upload again with force as true to avoid error of path already
-	// existing. Additionally need to recreate the reader again from the file
-	// data. This should also fail due to the blocklist
-	sup.Force = true
-	sup.Reader = bytes.NewReader(data)
-	_, _, err = r.SkynetSkyfilePost(sup)
+	_, err = r.SkynetSkylinkGetWithVerify(skylink)
 	if err == nil {
-		t.Fatal("Expected upload to fail")
-	}
-	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+		t.Fatal("verification without skykey should fail")
 	}
-
-	// Verify that the SiaPath and Extended SiaPath were removed from the renter
-	// due to the upload seeing the blocklist
-	_, err = r.RenterFileGet(sp)
-	if err == nil {
-		t.Fatal("expected error for file not found")
+	uc := client.NewUnsafeClient(r.Client)
+	resp, err := uc.SkynetSkylinkGetWithSkykeyAndValues(skylink, sk, url.Values{"verify": []string{"true"}})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	verified, err = ioutil.ReadAll(resp.Body)
+	err = errors.Compose(err, resp.Body.Close())
+	if err != nil {
+		t.Fatal(err)
 	}
-	_, err = r.RenterFileGet(spExtended)
-	if err == nil {
-		t.Fatal("expected error for file not found")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status code", resp.StatusCode, string(verified))
 	}
-	if !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	if !bytes.Equal(verified, data) {
+		t.Fatal("verified data doesn't match uploaded data")
 	}
+}
 
-	// Try Pinning the file, this should fail due to the blocklist
-	pinlup := skymodules.SkyfilePinParameters{
-		SiaPath:             sup.SiaPath,
-		BaseChunkRedundancy: 2,
-		Force:               true,
-	}
-	// Pinning is only supported for V1 Skylink
-	if !isV2Skylink {
-		err = r.SkynetSkylinkPinPost(skylink, pinlup)
-		if err == nil {
-			t.Fatal("Expected pin to fail")
-		}
-		if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-			t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
-		}
-	}
+// testConvertSiaFile tests converting a siafile to a skyfile. This test checks
+// for 1-of-N redundancies and N-of-M redundancies.
+func testConvertSiaFile(t *testing.T, tg *siatest.TestGroup) {
+	t.Run("1-of-N Conversion", func(t *testing.T) {
+		testConversion(t, tg, 1, 2, t.Name())
+	})
+	t.Run("N-of-M Conversion", func(t *testing.T) {
+		testConversion(t, tg, 2, 1, t.Name())
+	})
+}
 
-	// Remove skylink from blocklist
-	add = []string{}
-	if isHash {
-		remove = []string{hash.String()}
-	} else {
-		remove = []string{skylink}
-	}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+// testConversion is a subtest for testConvertSiaFile
+func testConversion(t *testing.T, tg *siatest.TestGroup, dp, pp uint64, skykeyName string) {
+	r := tg.Renters()[0]
+	// Upload a siafile that will then be converted to a skyfile.
+	filesize := int(modules.SectorSize) + siatest.Fuzz()
+	localFile, remoteFile, err := r.UploadNewFileBlocking(filesize, dp, pp, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Verify that removing the same skylink twice is a noop
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	// Get the local and remote data for comparison
+	localData, err := localFile.Data()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Verify that the skylink is removed from the Blocklist
-	sbg, err = r.SkynetBlocklistGet()
+	_, remoteData, err := r.DownloadByStream(remoteFile)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(sbg.Blocklist) != 0 {
-		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+
+	// Create Skyfile Upload Parameters
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath: skymodules.RandomSiaPath(),
 	}
 
-	// Try to download the file behind the skylink. Even though the file was
-	// removed from the renter node that uploaded it, it should still be
-	// downloadable.
+	// Try and convert to a Skyfile
+	sshp, err := r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
+	if err != nil {
+		t.Fatal("Expected conversion from Siafile to Skyfile Post to succeed.")
+	}
+
+	// Try to download the skylink.
+	skylink := sshp.Skylink
 	fetchedData, err := r.SkynetSkylinkGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(fetchedData, data) {
-		t.Error("upload and download doesn't match")
-		t.Log(data)
-		t.Log(fetchedData)
-	}
 
-	// Pinning is only supported for V1 Skylink
-	if !isV2Skylink {
-		// Pinning the skylink should also work now
-		err = r.SkynetSkylinkPinPost(skylink, pinlup)
-		if err != nil {
-			t.Fatal(err)
-		}
+	// Compare the data fetched from the Skylink to the local data and the
+	// previously uploaded data
+	if !bytes.Equal(fetchedData, localData) {
+		t.Error("converted skylink data doesn't match local data")
+	}
+	if !bytes.Equal(fetchedData, remoteData) {
+		t.Error("converted skylink data doesn't match remote data")
 	}
 
-	// Upload a normal siafile with 1-of-N redundancy
-	_, rf, err := r.UploadNewFileBlocking(int(size), 1, 2, false)
+	// Converting with encryption is not supported. Call the convert method to
+	// ensure we do not panic and we return the expected error
+	//
+	// Add SkyKey
+	sk, err := r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Convert to a skyfile
-	convertUP := skymodules.SkyfileUploadParameters{
-		SiaPath: rf.SiaPath(),
+	// Convert file again
+	sup.SkykeyName = sk.Name
+	sup.Force = true
+
+	// Convert to a Skyfile
+	_, err = r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
+	if err == nil || !strings.Contains(err.Error(), renter.ErrEncryptionNotSupported.Error()) {
+		t.Fatalf("Expected error %v, but got %v", renter.ErrEncryptionNotSupported, err)
 	}
-	convertSSHP, err := r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
+}
+
+// testSkynetMultipartUpload tests you can perform a multipart upload. It will
+// verify the upload without any subfiles, with small subfiles and with large
+// subfiles. Small files are files which are smaller than one sector, and thus
+// don't need a fanout. Large files are files what span multiple sectors
+func testSkynetMultipartUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	convertSkylink := convertSSHP.Skylink
 
-	// Convert to V2 Skylink
-	if isV2Skylink {
-		skylinkV2, err := r.NewSkylinkV2FromString(convertSkylink)
-		if err != nil {
-			t.Fatal(err)
-		}
-		convertSkylink = skylinkV2.Skylink.String()
+	// Test no files provided
+	fileName := "TestNoFileUpload"
+	_, _, _, err = r.UploadNewMultipartSkyfileBlocking(fileName, nil, "", false, false)
+	if err == nil || !strings.Contains(err.Error(), "could not find multipart file") {
+		t.Fatal("Expected upload to fail because no files are given, err:", err)
 	}
 
-	// Confirm there is a siafile and a skyfile
-	_, err = r.RenterFileGet(rf.SiaPath())
+	// TEST EMPTY FILE
+	fileName = "TestEmptyFileUpload"
+	emptyFile := siatest.TestFile{Name: "file", Data: []byte{}}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(fileName, []siatest.TestFile{emptyFile}, "", false, false)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Expected upload of empty file to succeed", err)
 	}
-	skyfilePath, err := skymodules.SkynetFolder.Join(rf.SiaPath().String())
+	data, err := r.SkynetSkylinkGet(skylink)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Expected download of empty file to succeed", err)
 	}
-	_, err = r.RenterFileRootGet(skyfilePath)
+	_, md, err := r.SkynetMetadataGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// For V2 Skylinks reset the blocklist to account for pinning not being supported for V2 Skylinks.
-	if isV2Skylink {
-		blockedSiaPaths = []skymodules.SiaPath{}
+	if len(data) != 0 {
+		t.Fatal("Unexpected data")
 	}
-
-	// Make sure all blockedSiaPaths are root paths
-	sp, err = skymodules.UserFolder.Join(rf.SiaPath().String())
-	if err != nil {
-		t.Fatal(err)
+	if md.Length != 0 {
+		t.Fatal("Unexpected metadata length", md.Length)
 	}
-	blockedSiaPaths = append(blockedSiaPaths, sp, skyfilePath)
 
-	// Blocklist the skylink
-	remove = []string{}
-	convertHash := crypto.HashObject(convertSSHP.MerkleRoot)
-	if isHash {
-		add = []string{convertHash.String()}
-	} else {
-		add = []string{convertSkylink}
+	// TEST EMPTY DIRECTORY
+	//
+	// A skyfile consisting only of empty subfiles acts as a placeholder for a
+	// directory structure.
+	fileName = "TestEmptyDirUpload"
+	emptyFiles := []siatest.TestFile{
+		{Name: "index.html", Data: []byte{}},
+		{Name: "dir/a", Data: []byte{}},
+		{Name: "dir/b", Data: []byte{}},
 	}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking(fileName, emptyFiles, "", true, false)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Expected upload of empty directory to succeed", err)
 	}
-
-	// Verify that adding the same skylink twice is a noop
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	_, md, err = r.SkynetMetadataGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	sbg, err = r.SkynetBlocklistGet()
+	if md.Length != 0 {
+		t.Fatal("Unexpected metadata length", md.Length)
+	}
+	if len(md.Subfiles) != len(emptyFiles) {
+		t.Fatal("Unexpected number of subfiles", len(md.Subfiles))
+	}
+	for _, file := range emptyFiles {
+		sf, exists := md.Subfiles[file.Name]
+		if !exists {
+			t.Fatal("Subfile missing from metadata", file.Name)
+		}
+		if sf.Offset != 0 || sf.Len != 0 {
+			t.Fatal("Unexpected subfile offset or length", sf.Offset, sf.Len)
+		}
+		data, err = r.SkynetSkylinkGet(skylink + "/" + file.Name)
+		if err != nil {
+			t.Fatal("Expected download of empty subfile to succeed", err)
+		}
+		if len(data) != 0 {
+			t.Fatal("Unexpected data")
+		}
+	}
+	_, reader, err := r.SkynetSkylinkTarReaderGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(sbg.Blocklist) != 1 {
-		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 1, len(sbg.Blocklist))
+	tr := tar.NewReader(reader)
+	var numFiles int
+	for {
+		header, err := tr.Next()
+		if errors.Contains(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Size != 0 {
+			t.Fatal("Unexpected size in tar header", header.Name, header.Size)
+		}
+		numFiles++
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numFiles != len(emptyFiles) {
+		t.Fatal("Unexpected number of files in tar archive", numFiles)
 	}
 
-	// Confirm skyfile download returns blocklisted error
+	// TEST SMALL SUBFILE
 	//
-	// NOTE: Calling DownloadSkylink doesn't attempt to delete any underlying file
-	_, err = r.SkynetSkylinkGet(convertSkylink)
-	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	// Define test func
+	testSmallFunc := func(files []siatest.TestFile, fileName, skykeyName string) {
+		skylink, _, _, err := r.UploadNewMultipartSkyfileEncryptedBlocking(fileName, files, "", false, []string{}, nil, false, skykeyName, skykey.SkykeyID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var realSkylink skymodules.Skylink
+		err = realSkylink.LoadString(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Try to download the file behind the skylink.
+		_, fileMetadata, err := r.SkynetMetadataGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Check the metadata. The modtime is set by the node.
+		if fileMetadata.ModTime == 0 {
+			t.Fatal("modtime not set")
+		}
+		rootFile := files[0]
+		nestedFile := files[1]
+		expected := skymodules.SkyfileMetadata{
+			Filename: fileName,
+			Subfiles: map[string]skymodules.SkyfileSubfileMetadata{
+				rootFile.Name: {
+					FileMode:    os.FileMode(0644),
+					Filename:    rootFile.Name,
+					ContentType: "application/octet-stream",
+					Offset:      0,
+					Len:         uint64(len(rootFile.Data)),
+				},
+				nestedFile.Name: {
+					FileMode:    os.FileMode(0644),
+					Filename:    nestedFile.Name,
+					ContentType: "text/html; charset=utf-8",
+					Offset:      uint64(len(rootFile.Data)),
+					Len:         uint64(len(nestedFile.Data)),
+				},
+			},
+			Length:   uint64(len(rootFile.Data) + len(nestedFile.Data)),
+			TryFiles: skymodules.DefaultTryFilesValue,
+			ModTime:  fileMetadata.ModTime,
+		}
+		if !reflect.DeepEqual(expected, fileMetadata) {
+			t.Log("Expected:", expected)
+			t.Log("Actual:  ", fileMetadata)
+			t.Fatal("Metadata mismatch")
+		}
+
+		// Download the second file
+		nestedfile, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, nestedFile.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(nestedfile, nestedFile.Data) {
+			t.Fatal("Expected only second file to be downloaded")
+		}
 	}
 
-	// Try and convert to skylink again, should fail. Set the Force Flag to true
-	// to avoid error for file already existing
-	convertUP.Force = true
-	_, err = r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
-	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	// Add a file at root level and a nested file
+	rootFile := siatest.TestFile{Name: "file1", Data: []byte("File1Contents")}
+	nestedFile := siatest.TestFile{Name: "nested/file2.html", Data: []byte("File2Contents")}
+	files := []siatest.TestFile{rootFile, nestedFile}
+	fileName = "TestFolderUpload"
+	testSmallFunc(files, fileName, "")
+
+	// Test Encryption
+	fileName = "TestFolderUpload_Encrypted"
+	testSmallFunc(files, fileName, sk.Name)
+
+	// LARGE SUBFILES
+	//
+	// Define test function
+	largeTestFunc := func(files []siatest.TestFile, fileName, skykeyName string) {
+		// Upload the skyfile
+		skylink, sup, _, err := r.UploadNewMultipartSkyfileEncryptedBlocking(fileName, files, "", false, skymodules.DefaultTryFilesValue, nil, false, skykeyName, skykey.SkykeyID{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Define files
+		rootFile := files[0]
+		nestedFile := files[1]
+
+		// Download the data
+		largeFetchedData, err := r.SkynetSkylinkConcatGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allData := append(rootFile.Data, nestedFile.Data...)
+		if !bytes.Equal(largeFetchedData, allData) {
+			t.Fatal("upload and download data does not match for large siafiles", len(largeFetchedData), len(allData))
+		}
+
+		// Check the metadata of the siafile, see that the metadata of the siafile
+		// has the skylink referenced.
+		largeSkyfilePath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		largeRenterFile, err := r.RenterFileRootGet(largeSkyfilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(largeRenterFile.File.Skylinks) != 1 {
+			t.Fatal("expecting one skylink:", len(largeRenterFile.File.Skylinks))
+		}
+		if largeRenterFile.File.Skylinks[0] != skylink {
+			t.Log(largeRenterFile.File.Skylinks[0])
+			t.Log(skylink)
+			t.Fatal("skylinks should match")
+		}
+
+		// Test the small root file download
+		smallFetchedData, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, rootFile.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(smallFetchedData, rootFile.Data) {
+			t.Fatal("upload and download data does not match for large siafiles with subfiles", len(smallFetchedData), len(rootFile.Data))
+		}
+
+		// Test the large nested file download
+		largeFetchedData, err = r.SkynetSkylinkGet(fmt.Sprintf("%s/%s", skylink, nestedFile.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(largeFetchedData, nestedFile.Data) {
+			t.Fatal("upload and download data does not match for large siafiles with subfiles", len(largeFetchedData), len(nestedFile.Data))
+		}
 	}
 
-	// This should delete the skyfile but not the siafile
-	_, err = r.RenterFileGet(rf.SiaPath())
+	// Add a small file at root level and a large nested file
+	rootFile = siatest.TestFile{Name: "smallFile1.txt", Data: []byte("File1Contents")}
+	largeData := fastrand.Bytes(2 * int(modules.SectorSize))
+	nestedFile = siatest.TestFile{Name: "nested/largefile2.txt", Data: largeData}
+	files = []siatest.TestFile{rootFile, nestedFile}
+	fileName = "TestFolderUploadLarge"
+	largeTestFunc(files, fileName, "")
+
+	// Test Encryption
+	fileName = "TestFolderUploadLarge_Encrypted"
+	largeTestFunc(files, fileName, sk.Name)
+}
+
+// testSkynetStats tests the validity of the response of /skynet/stats endpoint
+// by uploading some test files and verifying that the reported statistics
+// change proportionally
+func testSkynetStats(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// This test relies on state from the previous tests. Make sure we are
+	// starting from a place of updated metadata
+	err := r.RenterBubblePost(skymodules.RootSiaPath(), true)
 	if err != nil {
-		t.Fatal(err)
-	}
-	_, err = r.RenterFileRootGet(skyfilePath)
-	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+		t.Error(err)
 	}
 
-	// remove from blocklist
-	add = []string{}
-	if isHash {
-		remove = []string{convertHash.String()}
-	} else {
-		remove = []string{convertSkylink}
-	}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	// Enable portal mode.
+	rg, err := r.RenterGet()
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	sbg, err = r.SkynetBlocklistGet()
+	a := rg.Settings.Allowance
+	a.PaymentContractInitialFunding = types.SiacoinPrecision
+	err = r.RenterPostAllowance(a)
 	if err != nil {
-		t.Fatal(err)
-	}
-	if len(sbg.Blocklist) != 0 {
-		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+		t.Error(err)
 	}
 
-	// Convert should succeed
-	_, err = r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
-	if err != nil {
-		t.Fatal(err)
-	}
-	_, err = r.RenterFileRootGet(skyfilePath)
+	// Sleep for a few seconds to give all of the health and repair loops time
+	// to get settled.
+	time.Sleep(time.Second * 3)
+
+	// Get the stats
+	stats, err := r.SkynetStatsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Adding links to the block list does not immediately delete the files, but
-	// the health/bubble loops should eventually delete the files.
-	//
-	// First verify the test assumptions and confirm that the files still exist
-	// in the renter.
-	for _, siaPath := range blockedSiaPaths {
-		_, err = r.RenterFileRootGet(siaPath)
-		if err != nil {
-			t.Log(siaPath)
-			t.Fatal(err)
-		}
+	// Check portal mode.
+	if !stats.PortalMode {
+		t.Fatal("renter should be in portal mode")
 	}
 
-	// Disable the dependency
-	deps.DisableDeleteBlockedFiles(false)
-
-	// Add both skylinks back to the blocklist
-	remove = []string{}
-	if isHash {
-		add = []string{hash.String(), convertHash.String()}
-	} else {
-		add = []string{skylink, convertSkylink}
-	}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	// Disable portal mode.
+	a.PaymentContractInitialFunding = types.ZeroCurrency
+	err = r.RenterPostAllowance(a)
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	sbg, err = r.SkynetBlocklistGet()
+
+	// Get the stats again.
+	stats, err = r.SkynetStatsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(sbg.Blocklist) != 2 {
-		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 2, len(sbg.Blocklist))
+	// Check portal mode.
+	if stats.PortalMode {
+		t.Fatal("renter should not be in portal mode")
 	}
 
-	// Wait until all the files have been deleted
-	//
-	// Using 15 checks at 1 second intervals because the health loop check
-	// interval in testing is 5s and there are potential error sleeps of 3s.
-	if err := build.Retry(15, time.Second, func() error {
-		for _, siaPath := range blockedSiaPaths {
-			_, err = r.RenterFileRootGet(siaPath)
-			if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-				return fmt.Errorf("File %v, not deleted; error: %v", siaPath, err)
-			}
-		}
-		return nil
-	}); err != nil {
-		t.Error(err)
+	// Check that there are files in the filesystem.
+	if stats.NumFiles == 0 {
+		t.Fatal("test prereq requires files to exist")
+	}
+	// Check that the system scan duration has been set.
+	if stats.SystemHealthScanDurationHours == 0 {
+		t.Fatal("system health scan duration is not set")
 	}
 
-	// Reset the blocklist for other tests
-	remove = add
-	add = []string{}
-	err = r.SkynetBlocklistHashPost(add, remove, isHash)
-	if err != nil {
-		t.Fatal(err)
+	// verify it contains the node's version information
+	expected := build.NodeVersion
+	if build.ReleaseTag != "" {
+		expected += "-" + build.ReleaseTag
 	}
-	sbg, err = r.SkynetBlocklistGet()
-	if err != nil {
-		t.Fatal(err)
+	if stats.VersionInfo.Version != expected {
+		t.Fatalf("Unexpected version return, expected '%v', actual '%v'", expected, stats.VersionInfo.Version)
 	}
-	if len(sbg.Blocklist) != 0 {
-		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+	if stats.VersionInfo.GitRevision != build.GitRevision {
+		t.Fatalf("Unexpected git revision return, expected '%v', actual '%v'", build.GitRevision, stats.VersionInfo.GitRevision)
 	}
-}
 
-// testSkynetBlocklistUpgrade tests the skynet blocklist module when submitting
-// skylinks
-func testSkynetBlocklistUpgrade(t *testing.T, tg *siatest.TestGroup) {
-	// Create renterDir and renter params
-	testDir := skynetTestDir(t.Name())
-	renterDir := filepath.Join(testDir, "renter")
-	err := os.MkdirAll(renterDir, persist.DefaultDiskPermissionsTest)
-	if err != nil {
-		t.Fatal(err)
+	// Uptime should be non zero
+	if stats.Uptime == 0 {
+		t.Error("Uptime is zero")
 	}
-	params := node.Renter(testDir)
 
-	// Load compatibility blacklist persistence
-	blacklistCompatFile, err := os.Open("../../compatibility/skynetblacklistv143_siatest")
-	if err != nil {
-		t.Fatal(err)
+	// Check registry stats are set
+	if stats.RegistryRead15mP99ms == 0 {
+		t.Error("readregistry p99 is zero")
 	}
-	blacklistPersist, err := os.Create(filepath.Join(renterDir, "skynetblacklist"))
-	if err != nil {
-		t.Fatal(err)
+	if stats.RegistryRead15mP999ms == 0 {
+		t.Error("readregistry p999 is zero")
 	}
-	_, err = io.Copy(blacklistPersist, blacklistCompatFile)
-	if err != nil {
-		t.Fatal(err)
+	if stats.RegistryRead15mP9999ms == 0 {
+		t.Error("readregistry p9999 is zero")
 	}
-	err = errors.Compose(blacklistCompatFile.Close(), blacklistPersist.Close())
-	if err != nil {
-		t.Fatal(err)
+
+	// create two test files with sizes below and above the sector size
+	files := make(map[string]uint64)
+	files["statfile1"] = 2033
+	files["statfile2"] = 2*modules.SectorSize + 123
+
+	// upload the files and keep track of their expected impact on the stats
+	var uploadedFilesSize, uploadedFilesRawSize, uploadedFilesCount uint64
+	var sps []skymodules.SiaPath
+	var skylinks []string
+	for name, size := range files {
+		skylink, sup, _, err := r.UploadNewSkyfileBlocking(name, size, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skylinks = append(skylinks, skylink)
+
+		sp, err := sup.SiaPath.Rebase(skymodules.RootSiaPath(), skymodules.SkynetFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sps = append(sps, sp)
+
+		uploadedFilesCount++
+		// the raw size only tracks the actual file data
+		uploadedFilesRawSize += size
+		if size < modules.SectorSize {
+			// small files get padded up to a full sector
+			uploadedFilesSize += modules.SectorSize
+		} else {
+			// large files have an extra sector with header data
+			uploadedFilesSize += size + modules.SectorSize
+		}
 	}
 
-	// Grab the Skylink that is associated with the blacklist persistence
-	skylinkFile, err := os.Open("../../compatibility/skylinkv143_siatest")
+	// Create a siafile and convert it
+	size := 100
+	_, rf, err := r.UploadNewFileBlocking(size, 1, 2, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	scanner := bufio.NewScanner(skylinkFile)
-	scanner.Scan()
-	skylinkStr := scanner.Text()
-	var skylink skymodules.Skylink
-	err = skylink.LoadString(skylinkStr)
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath: rf.SiaPath(),
+		Mode:    skymodules.DefaultFilePerm,
+		Force:   false,
+		Root:    false,
+	}
+	_, err = r.SkynetConvertSiafileToSkyfilePost(sup, rf.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
+	// Increment the file count once for the converted file
+	uploadedFilesCount++
+	// Increment the file size for the basesector that is uploaded during the
+	// conversion as well as the file size of the siafile.
+	uploadedFilesSize += modules.SectorSize
+	uploadedFilesSize += uint64(size)
+	uploadedFilesRawSize += uint64(size)
 
-	// Add the renter to the group.
-	nodes, err := tg.AddNodes(params)
+	// Check that the right stats were returned.
+	statsBefore := stats
+	tries := 1
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// Make sure that the filesystem is being updated
+		if tries%10 == 0 {
+			err = r.RenterBubblePost(skymodules.RootSiaPath(), true)
+			if err != nil {
+				return err
+			}
+		}
+		tries++
+		statsAfter, err := r.SkynetStatsGet()
+		if err != nil {
+			return err
+		}
+		var countErr, sizeErr, rawSizeErr, healthErr error
+		if uint64(statsBefore.NumFiles)+uploadedFilesCount != uint64(statsAfter.NumFiles) {
+			countErr = fmt.Errorf("stats did not report the correct number of files. expected %d, found %d", uint64(statsBefore.NumFiles)+uploadedFilesCount, statsAfter.NumFiles)
+		}
+		if statsBefore.Storage+uploadedFilesSize != statsAfter.Storage {
+			sizeErr = fmt.Errorf("stats did not report the correct size. expected %d, found %d", statsBefore.Storage+uploadedFilesSize, statsAfter.Storage)
+		}
+		if statsBefore.RawStorage+uploadedFilesRawSize != statsAfter.RawStorage {
+			rawSizeErr = fmt.Errorf("stats did not report the correct raw size. expected %d, found %d", statsBefore.RawStorage+uploadedFilesRawSize, statsAfter.RawStorage)
+		}
+		// Just make sure that a health is returned
+		if statsAfter.MaxHealthPercentage == 0 {
+			healthErr = errors.New("no MaxHealthPercentage retuned")
+		}
+		return errors.Compose(countErr, sizeErr, rawSizeErr, healthErr)
+	})
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	r := nodes[0]
 
-	// Verify there is a skylink in the now blocklist and it is the one from the
-	// compatibility file
-	sbg, err := r.SkynetBlocklistGet()
+	// Download the files in different formats and check that the downloads
+	// are tracked.
+	statsBefore, err = r.SkynetStatsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(sbg.Blocklist) != 1 {
-		t.Fatal("blocklist should have 1 link, found:", len(sbg.Blocklist))
+	for _, skylink := range skylinks {
+		_, err = r.SkynetSkylinkGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.SkynetSkylinkConcatGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := r.SkynetBaseSectorGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
-	hash := crypto.HashObject(skylink.MerkleRoot())
-	if sbg.Blocklist[0] != hash {
-		t.Fatal("unexpected hash")
+	statsAfter, err := r.SkynetStatsGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Verify trying to download the skylink fails due to it being blocked
-	//
-	// NOTE: It doesn't matter if there is a file associated with this Skylink
-	// since the blocklist check should cause the download to fail before any look
-	// ups occur.
-	_, err = r.SkynetSkylinkGet(skylinkStr)
-	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
-		t.Fatal("unexpected error:", err)
+	n := uint64(len(skylinks))
+	formatsBefore, formatsAfter := statsBefore.Formats, statsAfter.Formats
+	if formatsAfter.Raw.Requests != formatsBefore.Raw.Requests+n {
+		t.Error("raw downloads weren't tracked", formatsBefore.Raw, formatsAfter.Raw)
 	}
-}
-
-// testSkynetPortals tests the skynet portals module.
-func testSkynetPortals(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-
-	portal1 := skymodules.SkynetPortal{
-		Address: modules.NetAddress("siasky.net:9980"),
-		Public:  true,
+	if formatsAfter.Concat.Requests != formatsBefore.Concat.Requests+n {
+		t.Error("concat downloads weren't tracked", formatsBefore.Concat, formatsAfter.Concat)
 	}
-	// loopback address
-	portal2 := skymodules.SkynetPortal{
-		Address: "localhost:9980",
-		Public:  true,
+	if formatsAfter.BaseSector.Requests != formatsBefore.BaseSector.Requests+n {
+		t.Error("basesector downloads weren't tracked", formatsBefore.BaseSector, formatsAfter.BaseSector)
 	}
-	// address without a port
-	portal3 := skymodules.SkynetPortal{
-		Address: modules.NetAddress("siasky.net"),
-		Public:  true,
+	if formatsAfter.Zip != formatsBefore.Zip {
+		t.Error("unexpected zip downloads", formatsBefore.Zip, formatsAfter.Zip)
 	}
-
-	// Add portal.
-	add := []skymodules.SkynetPortal{portal1}
-	remove := []modules.NetAddress{}
-	err := r.SkynetPortalsPost(add, remove)
-	if err != nil {
-		t.Fatal(err)
+	var ttfb, throughput uint64
+	for i := range formatsAfter.Raw.TTFB {
+		ttfb += formatsAfter.Raw.TTFB[i] - formatsBefore.Raw.TTFB[i]
 	}
-
-	// Confirm that the portal has been added.
-	spg, err := r.SkynetPortalsGet()
-	if err != nil {
-		t.Fatal(err)
+	for i := range formatsAfter.Raw.Throughput {
+		throughput += formatsAfter.Raw.Throughput[i] - formatsBefore.Raw.Throughput[i]
 	}
-	if len(spg.Portals) != 1 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
+	if ttfb != n || throughput != n {
+		t.Error("raw distributions weren't updated", ttfb, throughput)
 	}
-	if !reflect.DeepEqual(spg.Portals[0], portal1) {
-		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+
+	// Delete the files.
+	for _, sp := range sps {
+		err = r.RenterFileDeleteRootPost(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		extSP, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// This might not always succeed which is fine. We know how many files
+		// we expect afterwards.
+		_ = r.RenterFileDeleteRootPost(extSP)
 	}
 
-	// Remove the portal.
-	add = []skymodules.SkynetPortal{}
-	remove = []modules.NetAddress{portal1.Address}
-	err = r.SkynetPortalsPost(add, remove)
+	// Delete the converted file
+	err = r.RenterFileDeletePost(rf.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Confirm that the portal has been removed.
-	spg, err = r.SkynetPortalsGet()
+	convertSP, err := rf.SiaPath().Rebase(skymodules.RootSiaPath(), skymodules.SkynetFolder)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(spg.Portals) != 0 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 0, len(spg.Portals))
+	err = r.RenterFileDeleteRootPost(convertSP)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Try removing a portal that's not there.
-	add = []skymodules.SkynetPortal{}
-	remove = []modules.NetAddress{portal1.Address}
-	err = r.SkynetPortalsPost(add, remove)
-	if err == nil || !strings.Contains(err.Error(), "address "+string(portal1.Address)+" not already present in list of portals or being added") {
-		t.Fatal("portal should fail to be removed")
-	}
-
-	// Try to add and remove a portal at the same time.
-	add = []skymodules.SkynetPortal{portal2}
-	remove = []modules.NetAddress{portal2.Address}
-	err = r.SkynetPortalsPost(add, remove)
+	// Check the stats after the delete operation. Do it in a retry to account
+	// for the bubble.
+	tries = 1
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// Make sure that the filesystem is being updated
+		if tries%10 == 0 {
+			err = r.RenterBubblePost(skymodules.RootSiaPath(), true)
+			if err != nil {
+				return err
+			}
+		}
+		tries++
+		statsAfter, err := r.SkynetStatsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var countErr, sizeErr, rawSizeErr, healthErr error
+		if statsAfter.NumFiles != statsBefore.NumFiles {
+			countErr = fmt.Errorf("stats did not report the correct number of files. expected %d, found %d", uint64(statsBefore.NumFiles), statsAfter.NumFiles)
+		}
+		if statsAfter.Storage != statsBefore.Storage {
+			sizeErr = fmt.Errorf("stats did not report the correct size. expected %d, found %d", statsBefore.Storage, statsAfter.Storage)
+		}
+		if statsAfter.RawStorage != statsBefore.RawStorage {
+			rawSizeErr = fmt.Errorf("stats did not report the correct raw size. expected %d, found %d", statsBefore.RawStorage, statsAfter.RawStorage)
+		}
+		// Just make sure that a health is returned
+		if statsAfter.MaxHealthPercentage == 0 {
+			healthErr = errors.New("no MaxHealthPercentage retuned")
+		}
+		return errors.Compose(countErr, sizeErr, rawSizeErr, healthErr)
+	})
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
 
-	// Verify that the portal was not added.
-	spg, err = r.SkynetPortalsGet()
+	// Check that the throughput information for the various throughput fields
+	// is not blank.
+	//
+	// NOTE: this test depends on other tests to have performed each type of
+	// activity already, this test does not do the activity itself.
+	stats, err = r.SkynetStatsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(spg.Portals) != 0 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 0, len(spg.Portals))
+	if stats.BaseSectorUpload15mDataPoints <= 1 {
+		t.Error("throughput is being recorded at or below baseline:", stats.BaseSectorUpload15mDataPoints)
 	}
-
-	// Test updating a portal's public status.
-	portal1.Public = false
-	add = []skymodules.SkynetPortal{portal1}
-	remove = []modules.NetAddress{}
-	err = r.SkynetPortalsPost(add, remove)
-	if err != nil {
-		t.Fatal(err)
+	if stats.ChunkUpload15mDataPoints <= 1 {
+		t.Error("throughput is being recorded at or below baseline:", stats.ChunkUpload15mDataPoints)
 	}
-
-	spg, err = r.SkynetPortalsGet()
-	if err != nil {
-		t.Fatal(err)
+	if stats.RegistryRead15mDataPoints <= 1 {
+		t.Error("throughput is being recorded at or below baseline:", stats.RegistryRead15mDataPoints)
 	}
-	if len(spg.Portals) != 1 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
+	if stats.RegistryWrite15mDataPoints <= 1 {
+		t.Error("throughput is being recorded at or below baseline:", stats.RegistryWrite15mDataPoints)
 	}
-	if !reflect.DeepEqual(spg.Portals[0], portal1) {
-		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+	if stats.StreamBufferRead15mDataPoints <= 1 {
+		t.Error("throughput is being recorded at or below baseline:", stats.StreamBufferRead15mDataPoints)
 	}
 
-	portal1.Public = true
-	add = []skymodules.SkynetPortal{portal1}
-	remove = []modules.NetAddress{}
-	err = r.SkynetPortalsPost(add, remove)
+	// Upload a small file to a directory and a large file to a nested
+	// directory within it to check the per-directory skynet rollups.
+	statsDir, err := skymodules.NewSiaPath("statsdir" + persist.RandomSuffix())
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	spg, err = r.SkynetPortalsGet()
+	nestedDir, err := statsDir.Join("nested")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(spg.Portals) != 1 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
-	}
-	if !reflect.DeepEqual(spg.Portals[0], portal1) {
-		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+	smallSize, largeSize := uint64(100), 2*modules.SectorSize+123
+	for _, upload := range []struct {
+		dir  skymodules.SiaPath
+		size uint64
+	}{{statsDir, smallSize}, {nestedDir, largeSize}} {
+		sp, err := upload.dir.Join("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
+			SiaPath:  sp,
+			Filename: "file",
+			Mode:     skymodules.DefaultFilePerm,
+			Reader:   bytes.NewReader(fastrand.Bytes(int(upload.size))),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
+	defer func() {
+		sp, err := skymodules.SkynetFolder.Join(statsDir.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.RenterDirDeleteRootPost(sp); err != nil {
+			t.Fatal(err)
+		}
+	}()
 
-	// Test an invalid network address.
-	add = []skymodules.SkynetPortal{portal3}
-	remove = []modules.NetAddress{}
-	err = r.SkynetPortalsPost(add, remove)
-	if err == nil || !strings.Contains(err.Error(), "missing port in address") {
-		t.Fatal("expected 'missing port' error")
+	// dirInfo is a helper to fetch the directory info of a directory within
+	// the skynet folder.
+	dirInfo := func(dir skymodules.SiaPath) (skymodules.DirectoryInfo, error) {
+		sp, err := skymodules.SkynetFolder.Join(dir.String())
+		if err != nil {
+			return skymodules.DirectoryInfo{}, err
+		}
+		rd, err := r.RenterDirRootGet(sp)
+		if err != nil {
+			return skymodules.DirectoryInfo{}, err
+		}
+		return rd.Directories[0], nil
 	}
 
-	// Test adding an existing portal with an uppercase address.
-	portalUpper := portal1
-	portalUpper.Address = modules.NetAddress(strings.ToUpper(string(portalUpper.Address)))
-	add = []skymodules.SkynetPortal{portalUpper}
-	remove = []modules.NetAddress{}
-	err = r.SkynetPortalsPost(add, remove)
-	// This does not currently return an error.
+	// The nested directory contains one skyfile with an extended file and the
+	// parent directory contains the small skyfile.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		err = r.RenterBubblePost(skymodules.RootSiaPath(), true)
+		if err != nil {
+			return err
+		}
+		nested, err := dirInfo(nestedDir)
+		if err != nil {
+			return err
+		}
+		if nested.SkynetFiles != 1 || nested.SkynetExtendedFiles != 1 || nested.SkynetSkylinks != 1 {
+			return fmt.Errorf("wrong nested counts: %v files, %v extended files, %v skylinks", nested.SkynetFiles, nested.SkynetExtendedFiles, nested.SkynetSkylinks)
+		}
+		if nested.SkynetSize != largeSize+modules.SectorSize || nested.SkynetRawSize != largeSize {
+			return fmt.Errorf("wrong nested sizes: %v size, %v raw size", nested.SkynetSize, nested.SkynetRawSize)
+		}
+		parent, err := dirInfo(statsDir)
+		if err != nil {
+			return err
+		}
+		if parent.SkynetFiles != 1 || parent.SkynetExtendedFiles != 0 || parent.SkynetSkylinks != 1 {
+			return fmt.Errorf("wrong parent counts: %v files, %v extended files, %v skylinks", parent.SkynetFiles, parent.SkynetExtendedFiles, parent.SkynetSkylinks)
+		}
+		if parent.SkynetSize != modules.SectorSize || parent.SkynetRawSize != smallSize {
+			return fmt.Errorf("wrong parent sizes: %v size, %v raw size", parent.SkynetSize, parent.SkynetRawSize)
+		}
+		if parent.AggregateSkynetFiles != 2 || parent.AggregateSkynetExtendedFiles != 1 || parent.AggregateSkynetSkylinks != 2 {
+			return fmt.Errorf("wrong aggregate counts: %v files, %v extended files, %v skylinks", parent.AggregateSkynetFiles, parent.AggregateSkynetExtendedFiles, parent.AggregateSkynetSkylinks)
+		}
+		if parent.AggregateSkynetSize != largeSize+2*modules.SectorSize || parent.AggregateSkynetRawSize != largeSize+smallSize {
+			return fmt.Errorf("wrong aggregate sizes: %v size, %v raw size", parent.AggregateSkynetSize, parent.AggregateSkynetRawSize)
+		}
+		return nil
+	})
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
+}
 
-	spg, err = r.SkynetPortalsGet()
+// TestSkynetInvalidFilename verifies that posting a Skyfile with invalid
+// filenames such as empty filenames, names containing ./ or ../ or names
+// starting with a forward-slash fails.
+func testSkynetInvalidFilename(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create some data to upload as a skyfile.
+	data := fastrand.Bytes(100 + siatest.Fuzz())
+
+	filenames := []string{
+		"",
+		"../test",
+		"./test",
+		"/test",
+		"foo//bar",
+		"test/./test",
+		"test/../test",
+		"/test//foo/../bar/",
+	}
+
+	for _, filename := range filenames {
+		uploadSiaPath, err := skymodules.NewSiaPath("testInvalidFilename" + persist.RandomSuffix())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:             uploadSiaPath,
+			Force:               false,
+			Root:                false,
+			BaseChunkRedundancy: 2,
+			Filename:            filename,
+			Mode:                0640, // Intentionally does not match any defaults.
+			Reader:              bytes.NewReader(data),
+		}
+
+		// Try posting the skyfile with an invalid filename
+		_, _, err = r.SkynetSkyfilePost(sup)
+		if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidPathString.Error()) {
+			t.Log("Error:", err)
+			t.Fatal("Expected SkynetSkyfilePost to fail due to invalid filename")
+		}
+
+		// Do the same for a multipart upload
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		data = []byte("File1Contents")
+		subfile, err := skymodules.AddMultipartFile(writer, data, "files[]", filename, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Call the upload skyfile client call.
+		uploadSiaPath, err = skymodules.NewSiaPath("testInvalidFilenameMultipart" + persist.RandomSuffix())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subfiles := make(skymodules.SkyfileSubfiles)
+		subfiles[subfile.Filename] = subfile
+		mup := skymodules.SkyfileMultipartUploadParameters{
+			SiaPath:             uploadSiaPath,
+			Force:               false,
+			Root:                false,
+			BaseChunkRedundancy: 2,
+			Reader:              bytes.NewReader(body.Bytes()),
+			ContentType:         writer.FormDataContentType(),
+			Filename:            "testInvalidFilenameMultipart",
+		}
+
+		_, _, err = r.SkynetSkyfileMultiPartPost(mup)
+		if err == nil || (!strings.Contains(err.Error(), skymodules.ErrInvalidPathString.Error()) && !strings.Contains(err.Error(), skymodules.ErrEmptyFilename.Error())) {
+			t.Log("Error:", err)
+			t.Fatal("Expected SkynetSkyfileMultiPartPost to fail due to invalid filename")
+		}
+	}
+
+	// These cases should succeed.
+	uploadSiaPath, err := skymodules.NewSiaPath("testInvalidFilename")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(spg.Portals) != 2 {
-		t.Fatalf("Incorrect number of portals, expected %v got %v", 2, len(spg.Portals))
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:             uploadSiaPath,
+		Force:               false,
+		Root:                false,
+		BaseChunkRedundancy: 2,
+		Filename:            "testInvalidFilename",
+		Mode:                0640, // Intentionally does not match any defaults.
+		Reader:              bytes.NewReader(data),
+	}
+	_, _, err = r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Log("Error:", err)
+		t.Fatal("Expected SkynetSkyfilePost to succeed if valid filename is provided")
 	}
-}
 
-// testSkynetIncludeLayout verifies the functionality of sending
-// a 'include-layout' query string parameter to the skylink GET route.
-func testSkynetIncludeLayout(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+	// recreate the reader
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
 
-	// Upload a skyfile
-	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	subfile, err := skymodules.AddMultipartFile(writer, []byte("File1Contents"), "files[]", "testInvalidFilenameMultipart", 0600, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// GET without specifying the 'include-layout' query string parameter
-	_, layout, err := r.SkynetSkylinkGetWithLayout(skylink, false)
+	err = writer.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(layout, skymodules.SkyfileLayout{}) {
-		t.Fatal("unexpected")
-	}
 
-	// GET with specifying the 'include-layout' query string parameter
-	_, layout, err = r.SkynetSkylinkGetWithLayout(skylink, true)
+	subfiles := make(skymodules.SkyfileSubfiles)
+	subfiles[subfile.Filename] = subfile
+	uploadSiaPath, err = skymodules.NewSiaPath("testInvalidFilenameMultipart")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if reflect.DeepEqual(layout, skymodules.SkyfileLayout{}) {
-		t.Fatal("unexpected")
+	mup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:             uploadSiaPath,
+		Force:               false,
+		Root:                false,
+		BaseChunkRedundancy: 2,
+		Reader:              bytes.NewReader(body.Bytes()),
+		ContentType:         writer.FormDataContentType(),
+		Filename:            "testInvalidFilenameMultipart",
 	}
 
-	// Perform a HEAD call to verify the same thing in the headers directly
-	params := url.Values{}
-	params.Set("include-layout", fmt.Sprintf("%t", true))
-	status, header, err := r.SkynetSkylinkHeadWithParameters(skylink, params)
+	_, _, err = r.SkynetSkyfileMultiPartPost(mup)
 	if err != nil {
-		t.Fatal(err)
-	}
-	if status != http.StatusOK {
-		t.Fatalf("Unexpected status for HEAD request, expected %v but received %v", http.StatusOK, status)
+		t.Log("Error:", err)
+		t.Fatal("Expected SkynetSkyfileMultiPartPost to succeed if filename is provided")
 	}
+}
 
-	strSkynetFileLayout := header.Get(api.SkynetFileLayoutHeader)
-	if strSkynetFileLayout == "" {
-		t.Fatal("unexpected")
-	}
-	var layout2 skymodules.SkyfileLayout
-	layoutBytes, err := hex.DecodeString(strSkynetFileLayout)
+// testSkynetDownloadFormats verifies downloading data in different formats
+func testSkynetDownloadFormats(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	dataFile1 := []byte("file1.txt")
+	dataFile2 := []byte("file2.txt")
+	dataFile3 := []byte("file3.txt")
+	filePath1 := "a/5.f4f8b583.chunk.js"
+	filePath2 := "a/5.f4f.chunk.js.map"
+	filePath3 := "b/file3.txt"
+	_, err := skymodules.AddMultipartFile(writer, dataFile1, "files[]", filePath1, 0600, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	layout2.Decode(layoutBytes)
-	if !reflect.DeepEqual(layout, layout2) {
-		t.Fatal("unexpected")
-	}
-}
-
-// testSkynetNoWorkers verifies that SkynetSkylinkGet returns an error and does
-// not deadlock if there are no workers.
-func testSkynetNoWorkers(t *testing.T, tg *siatest.TestGroup) {
-	// Create renter, skip setting the allowance so that we can ensure there are
-	// no contracts created and therefore no workers in the worker pool
-	testDir := skynetTestDir(t.Name())
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.SkipSetAllowance = true
-	nodes, err := tg.AddNodes(renterParams)
+	_, err = skymodules.AddMultipartFile(writer, dataFile2, "files[]", filePath2, 0600, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
-	defer func() {
-		err = tg.RemoveNode(r)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Since the renter doesn't have an allowance, we know the renter doesn't
-	// have any contracts and therefore the worker pool will be empty. Confirm
-	// that attempting to download a skylink will return an error and not dead
-	// lock.
-	skylink, err := skymodules.NewSkylinkV1(crypto.Hash{1, 2, 3}, 0, modules.SectorSize)
+	_, err = skymodules.AddMultipartFile(writer, dataFile3, "files[]", filePath3, 0640, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.SkynetSkylinkGet(skylink.String())
-	if err == nil {
-		t.Fatal("Error is nil, expected error due to not enough workers")
-	} else if !(strings.Contains(err.Error(), skymodules.ErrNotEnoughWorkersInWorkerPool.Error()) || strings.Contains(err.Error(), "not enough workers to complete download")) {
-		t.Errorf("Expected error containing '%v' but got %v", skymodules.ErrNotEnoughWorkersInWorkerPool, err)
-	}
-}
-
-// testSkynetDryRunUpload verifies the --dry-run flag when uploading a Skyfile.
-func testSkynetDryRunUpload(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	siaPath, err := skymodules.NewSiaPath(t.Name())
-	if err != nil {
+	if err := writer.Close(); err != nil {
 		t.Fatal(err)
 	}
 
-	// verify basic skyfile upload
-	//
-	// NOTE: this ensure there's workers in the pool, if we remove this the test
-	// fails further down the line because there are no workers
-	_, _, err = r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
-		SiaPath:             siaPath,
-		BaseChunkRedundancy: 2,
-		Filename:            "testSkynetDryRun",
-		Mode:                0640,
-		Reader:              bytes.NewReader(fastrand.Bytes(100)),
-	})
+	uploadSiaPath, err := skymodules.NewSiaPath("testSkynetDownloadFormats")
 	if err != nil {
-		t.Fatal("Expected skynet upload to be successful, instead received err:", err)
+		t.Fatal(err)
 	}
 
-	// verify you can't perform a dry-run using the force parameter
-	_, _, err = r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
-		SiaPath:             siaPath,
+	reader := bytes.NewReader(body.Bytes())
+	mup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:             uploadSiaPath,
+		Force:               false,
+		Root:                false,
 		BaseChunkRedundancy: 2,
-		Reader:              bytes.NewReader(fastrand.Bytes(100)),
-		Filename:            "testSkynetDryRun",
-		Mode:                0640,
-		Force:               true,
-		DryRun:              true,
-	})
-	if err == nil {
-		t.Fatal("Expected failure when both 'force' and 'dryrun' parameter are given")
-	}
-
-	verifyDryRun := func(sup skymodules.SkyfileUploadParameters, dataSize int) {
-		data := fastrand.Bytes(dataSize)
-
-		sup.DryRun = true
-		sup.Reader = bytes.NewReader(data)
-		skylinkDry, _, err := r.SkynetSkyfilePost(sup)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		// verify the skylink can't be found after a dry run
-		status, _, err := r.SkynetSkylinkHead(skylinkDry)
-		if status != http.StatusNotFound {
-			t.Fatal(fmt.Errorf("expected 404 not found when trying to fetch a skylink retrieved from a dry run, instead received status %d and err %v", status, err))
-		}
-
-		// verify the skfyile got deleted properly
-		skyfilePath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
-		if err != nil {
-			t.Fatal(err)
-		}
-		_, err = r.RenterFileRootGet(skyfilePath)
-		if err == nil || !strings.Contains(err.Error(), "path does not exist") {
-			t.Fatal(errors.New("skyfile not deleted after dry run"))
-		}
-
-		sup.DryRun = false
-		sup.Reader = bytes.NewReader(data)
-		skylink, _, err := r.SkynetSkyfilePost(sup)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		if skylinkDry != skylink {
-			t.Log("Expected:", skylink)
-			t.Log("Actual:  ", skylinkDry)
-			t.Fatalf("VerifyDryRun failed for data size %db, skylink received during the dry-run is not identical to the skylink received when performing the actual upload.", dataSize)
-		}
+		Reader:              reader,
+		ContentType:         writer.FormDataContentType(),
+		Filename:            "testSkynetSubfileDownload",
 	}
 
-	// verify dry-run of small file
-	uploadSiaPath, err := skymodules.NewSiaPath(fmt.Sprintf("%s%s", t.Name(), "S"))
+	skylink, _, err := r.SkynetSkyfileMultiPartPost(mup)
 	if err != nil {
 		t.Fatal(err)
 	}
-	verifyDryRun(skymodules.SkyfileUploadParameters{
-		SiaPath:             uploadSiaPath,
-		BaseChunkRedundancy: 2,
-		Filename:            "testSkynetDryRunUploadSmall",
-		Mode:                0640,
-	}, 100)
 
-	// verify dry-run of large file
-	uploadSiaPath, err = skymodules.NewSiaPath(fmt.Sprintf("%s%s", t.Name(), "L"))
+	// download the data specifying the 'concat' format
+	allData, err := r.SkynetSkylinkConcatGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	verifyDryRun(skymodules.SkyfileUploadParameters{
-		SiaPath:             uploadSiaPath,
-		BaseChunkRedundancy: 2,
-		Filename:            "testSkynetDryRunUploadLarge",
-		Mode:                0640,
-	}, int(modules.SectorSize*2)+siatest.Fuzz())
-}
-
-// testSkynetRequestTimeout verifies that the Skylink routes timeout when a
-// timeout query string parameter has been passed.
-func testSkynetRequestTimeout(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+	expected := append(dataFile1, dataFile2...)
+	expected = append(expected, dataFile3...)
+	if !bytes.Equal(expected, allData) {
+		t.Log("expected:", expected)
+		t.Log("actual:", allData)
+		t.Fatal("Unexpected data for dir A")
+	}
 
-	// Upload a skyfile
-	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	// now specify the zip format
+	_, skyfileReader, err := r.SkynetSkylinkZipReaderGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Verify we can pin it
-	pinSiaPath, err := skymodules.NewSiaPath(t.Name())
+	// read the zip archive
+	files, err := readZipArchive(skyfileReader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	pinLUP := skymodules.SkyfilePinParameters{
-		SiaPath:             pinSiaPath,
-		Force:               true,
-		Root:                false,
-		BaseChunkRedundancy: 2,
-	}
-	err = r.SkynetSkylinkPinPost(skylink, pinLUP)
+	err = skyfileReader.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Create a renter with a timeout dependency injected
-	testDir := skynetTestDir(t.Name())
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.RenterDeps = &dependencies.DependencyTimeoutProjectDownloadByRoot{}
-	nodes, err := tg.AddNodes(renterParams)
-	if err != nil {
-		t.Fatal(err)
+	// verify the contents
+	dataFile1Received, exists := files[filePath1]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath1)
 	}
-	r = nodes[0]
-	defer func() {
-		if err := tg.RemoveNode(r); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Verify timeout on head request
-	status, _, err := r.SkynetSkylinkHeadWithTimeout(skylink, 1)
-	if status != http.StatusNotFound {
-		t.Fatalf("Expected http.StatusNotFound for random skylink but received %v", status)
+	if !bytes.Equal(dataFile1Received, dataFile1) {
+		t.Fatal("file data doesn't match expected content")
 	}
-
-	// Verify timeout on download request
-	_, err = r.SkynetSkylinkGetWithTimeout(skylink, 1)
-	if errors.Contains(err, renter.ErrProjectTimedOut) {
-		t.Fatal("Expected download request to time out")
+	dataFile2Received, exists := files[filePath2]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath2)
 	}
-	if !strings.Contains(err.Error(), "timed out after 1s") {
-		t.Log(err)
-		t.Fatal("Expected error to specify the timeout")
+	if !bytes.Equal(dataFile2Received, dataFile2) {
+		t.Fatal("file data doesn't match expected content")
 	}
-
-	// Verify timeout on pin request
-	err = r.SkynetSkylinkPinPostWithTimeout(skylink, pinLUP, 2*time.Second)
-	if errors.Contains(err, renter.ErrProjectTimedOut) {
-		t.Fatal("Expected pin request to time out")
+	dataFile3Received, exists := files[filePath3]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath3)
 	}
-	if err == nil || !strings.Contains(err.Error(), "timed out after 2s") {
-		t.Log(err)
-		t.Fatal("Expected error to specify the timeout")
+	if !bytes.Equal(dataFile3Received, dataFile3) {
+		t.Log(dataFile3Received)
+		t.Log(dataFile3)
+		t.Fatal("file data doesn't match expected content")
 	}
-}
 
-// testRegressionTimeoutPanic is a regression test for a double channel close
-// which happened when a timeout was hit right before a download project was
-// resumed.
-func testRegressionTimeoutPanic(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-
-	// Upload a skyfile
-	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	// now specify the tar format
+	_, skyfileReader, err = r.SkynetSkylinkTarReaderGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Create a renter with a BlockResumeJobDownloadUntilTimeout dependency.
-	testDir := skynetTestDir(t.Name())
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.RenterDeps = dependencies.NewDependencyBlockResumeJobDownloadUntilTimeout()
-	nodes, err := tg.AddNodes(renterParams)
+	// read the tar archive
+	files, err = readTarArchive(skyfileReader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r = nodes[0]
-	defer func() {
-		if err := tg.RemoveNode(r); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Verify timeout on download request doesn't panic.
-	_, err = r.SkynetSkylinkGetWithTimeout(skylink, 1)
-	if errors.Contains(err, renter.ErrProjectTimedOut) {
-		t.Fatal("Expected download request to time out")
-	}
-}
-
-// testRenameSiaPath verifies that the siapath to the skyfile can be renamed.
-func testRenameSiaPath(t *testing.T, tg *siatest.TestGroup) {
-	// Grab Renter
-	r := tg.Renters()[0]
-
-	// Create a skyfile
-	skylink, sup, _, err := r.UploadNewSkyfileBlocking("testRenameFile", 100, false)
+	err = skyfileReader.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	siaPath := sup.SiaPath
 
-	// Rename Skyfile with root set to false should fail
-	err = r.RenterRenamePost(siaPath, skymodules.RandomSiaPath(), false)
-	if err == nil {
-		t.Error("Rename should have failed if the root flag is false")
+	// verify the contents
+	dataFile1Received, exists = files[filePath1]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath1)
 	}
-	if err != nil && !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Errorf("Expected error to contain %v but got %v", filesystem.ErrNotExist, err)
+	if !bytes.Equal(dataFile1Received, dataFile1) {
+		t.Fatal("file data doesn't match expected content")
+	}
+	dataFile2Received, exists = files[filePath2]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath2)
+	}
+	if !bytes.Equal(dataFile2Received, dataFile2) {
+		t.Fatal("file data doesn't match expected content")
+	}
+	dataFile3Received, exists = files[filePath3]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath3)
+	}
+	if !bytes.Equal(dataFile3Received, dataFile3) {
+		t.Log(dataFile3Received)
+		t.Log(dataFile3)
+		t.Fatal("file data doesn't match expected content")
 	}
 
-	// Rename Skyfile with root set to true should be successful
-	siaPath, err = skymodules.SkynetFolder.Join(siaPath.String())
+	// now specify the targz format
+	_, skyfileReader, err = r.SkynetSkylinkTarGzReaderGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	newSiaPath, err := skymodules.SkynetFolder.Join(persist.RandomSuffix())
+	gzr, err := gzip.NewReader(skyfileReader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = r.RenterRenamePost(siaPath, newSiaPath, true)
+	files, err = readTarArchive(gzr)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Verify the skyfile can still be downloaded
-	_, err = r.SkynetSkylinkGet(skylink)
+	err = errors.Compose(skyfileReader.Close(), gzr.Close())
 	if err != nil {
 		t.Fatal(err)
 	}
-}
 
-// testSkynetDefaultPath tests whether defaultPath metadata parameter works
-// correctly
-func testSkynetDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	// Specify subtests to run
-	subTests := []siatest.SubTest{
-		{Name: "HasIndexNoDefaultPath", Test: testHasIndexNoDefaultPath},
-		{Name: "HasIndexDisabledDefaultPath", Test: testHasIndexDisabledDefaultPath},
-		{Name: "HasIndexDifferentDefaultPath", Test: testHasIndexDifferentDefaultPath},
-		{Name: "HasIndexInvalidDefaultPath", Test: testHasIndexInvalidDefaultPath},
-		{Name: "NoIndexDifferentDefaultPath", Test: testNoIndexDifferentDefaultPath},
-		{Name: "NoIndexInvalidDefaultPath", Test: testNoIndexInvalidDefaultPath},
-		{Name: "NoIndexNoDefaultPath", Test: testNoIndexNoDefaultPath},
-		{Name: "NoIndexSingleFileDisabledDefaultPath", Test: testNoIndexSingleFileDisabledDefaultPath},
-		{Name: "NoIndexSingleFileNoDefaultPath", Test: testNoIndexSingleFileNoDefaultPath},
+	// verify the contents
+	dataFile1Received, exists = files[filePath1]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath1)
 	}
-
-	// Run subtests
-	for _, test := range subTests {
-		t.Run(test.Name, func(t *testing.T) {
-			test.Test(t, tg)
-		})
+	if !bytes.Equal(dataFile1Received, dataFile1) {
+		t.Fatal("file data doesn't match expected content")
 	}
-}
-
-// testHasIndexNoDefaultPath Contains index.html but doesn't specify a default
-// path (not disabled).
-// It should return the content of index.html.
-func testHasIndexNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	filename := "index.html_nil"
-	files := []siatest.TestFile{
-		{Name: "index.html", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
+	dataFile2Received, exists = files[filePath2]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath2)
 	}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
-	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
+	if !bytes.Equal(dataFile2Received, dataFile2) {
+		t.Fatal("file data doesn't match expected content")
 	}
-	content, err := r.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
+	dataFile3Received, exists = files[filePath3]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath3)
 	}
-	if !bytes.Equal(content, files[0].Data) {
-		t.Fatalf("Expected to get content '%s', instead got '%s'", files[0].Data, string(content))
+	if !bytes.Equal(dataFile3Received, dataFile3) {
+		t.Log(dataFile3Received)
+		t.Log(dataFile3)
+		t.Fatal("file data doesn't match expected content")
 	}
-}
 
-// testHasIndexDisabledDefaultPath Contains index.html but specifies an empty
-// default path (disabled).
-// It should not return an error and download the file as zip
-func testHasIndexDisabledDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	filename := "index.html_empty"
-	files := []siatest.TestFile{
-		{Name: "index.html", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
-	}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", true, false)
-	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
-	}
-	_, header, err := r.SkynetSkylinkHead(skylink)
+	// get all data for path "a" using the concat format
+	dataDirA, err := r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/a", skylink))
 	if err != nil {
 		t.Fatal(err)
 	}
-	ct := header.Get("Content-Type")
-	if ct != "application/zip" {
-		t.Fatal("expected zip archive")
+	expected = append(dataFile1, dataFile2...)
+	if !bytes.Equal(expected, dataDirA) {
+		t.Log("expected:", expected)
+		t.Log("actual:", dataDirA)
+		t.Fatal("Unexpected data for dir A")
 	}
-}
 
-// testHasIndexDifferentDefaultPath Contains index.html but specifies a
-// different default, existing path.
-// It should return the content of about.html.
-func testHasIndexDifferentDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	aboutHtml := "about.html"
-	filename := "index.html_about.html"
-	files := []siatest.TestFile{
-		{Name: "index.html", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
-	}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, aboutHtml, false, false)
+	// now specify the tar format
+	_, skyfileReader, err = r.SkynetSkylinkTarReaderGet(fmt.Sprintf("%s/a", skylink))
 	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
+		t.Fatal(err)
 	}
-	content, err := r.SkynetSkylinkGet(skylink)
+
+	// read the tar archive
+	files, err = readTarArchive(skyfileReader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(content, files[1].Data) {
-		t.Fatalf("Expected to get content '%s', instead got '%s'", files[1].Data, string(content))
+	err = skyfileReader.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-// testHasIndexInvalidDefaultPath Contains index.html but specifies a different
-// INVALID default path.
-// This should fail on upload with "invalid default path provided".
-func testHasIndexInvalidDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	invalidPath := "invalid.js"
-	filename := "index.html_invalid"
-	files := []siatest.TestFile{
-		{Name: "index.html", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
+	// verify the contents
+	dataFile1Received, exists = files[filePath1]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath1)
 	}
-	_, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, invalidPath, false, false)
-	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidDefaultPath.Error()) {
-		t.Fatalf("Expected error 'invalid default path provided', got '%+v'", err)
+	if !bytes.Equal(dataFile1Received, dataFile1) {
+		t.Fatal("file data doesn't match expected content")
+	}
+	dataFile2Received, exists = files[filePath2]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath2)
+	}
+	if !bytes.Equal(dataFile2Received, dataFile2) {
+		t.Fatal("file data doesn't match expected content")
+	}
+	if len(files) != 2 {
+		t.Fatal("unexpected amount of files")
 	}
-}
 
-// testNoIndexDifferentDefaultPath Does not contain "index.html".
-// Contains about.html and specifies it as default path.
-// It should return the content of about.html.
-func testNoIndexDifferentDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	aboutHtml := "about.html"
-	filename := "index.js_about.html"
-	files := []siatest.TestFile{
-		{Name: "index.js", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
+	// now specify the targz format
+	_, skyfileReader, err = r.SkynetSkylinkTarGzReaderGet(fmt.Sprintf("%s/a", skylink))
+	if err != nil {
+		t.Fatal(err)
 	}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, aboutHtml, false, false)
+	gzr, err = gzip.NewReader(skyfileReader)
 	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
+		t.Fatal(err)
 	}
-	content, err := r.SkynetSkylinkGet(skylink)
+	files, err = readTarArchive(gzr)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(content, files[1].Data) {
-		t.Fatalf("Expected to get content '%s', instead got '%s'", files[1].Data, string(content))
+	err = errors.Compose(skyfileReader.Close(), gzr.Close())
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-// testNoIndexInvalidDefaultPath  Does not contain index.html and specifies an
-// INVALID default path.
-// This should fail on upload with "invalid default path provided".
-func testNoIndexInvalidDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	invalidPath := "invalid.js"
-	files := []siatest.TestFile{
-		{Name: "index.js", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
+	// verify the contents
+	dataFile1Received, exists = files[filePath1]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath1)
 	}
-	filename := "index.js_invalid"
-	_, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, invalidPath, false, false)
-	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidDefaultPath.Error()) {
-		t.Fatalf("Expected error 'invalid default path provided', got '%+v'", err)
+	if !bytes.Equal(dataFile1Received, dataFile1) {
+		t.Fatal("file data doesn't match expected content")
 	}
-}
-
-// testNoIndexNoDefaultPath Does not contain index.html and doesn't specify
-// default path (not disabled).
-// It should not return an error and download the file as zip
-func testNoIndexNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	fc2 := "File2Contents"
-	files := []siatest.TestFile{
-		{Name: "index.js", Data: []byte(fc1)},
-		{Name: "about.html", Data: []byte(fc2)},
+	dataFile2Received, exists = files[filePath2]
+	if !exists {
+		t.Fatalf("file at path '%v' not present in zip", filePath2)
 	}
-	filename := "index.js_nil"
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
-	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
+	if !bytes.Equal(dataFile2Received, dataFile2) {
+		t.Fatal("file data doesn't match expected content")
 	}
-	_, header, err := r.SkynetSkylinkHead(skylink)
+	if len(files) != 2 {
+		t.Fatal("unexpected amount of files")
+	}
+
+	// now specify the zip format
+	_, skyfileReader, err = r.SkynetSkylinkZipReaderGet(fmt.Sprintf("%s/a", skylink))
 	if err != nil {
 		t.Fatal(err)
 	}
-	ct := header.Get("Content-Type")
-	if ct != "application/zip" {
-		t.Fatalf("expected zip archive, got '%s'\n", ct)
-	}
-}
 
-// testNoIndexSingleFileDisabledDefaultPath Does not contain "index.html".
-// Contains a single file and specifies an empty default path (disabled).
-// It should not return an error and download the file as zip.
-func testNoIndexSingleFileDisabledDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	filename := "index.js_empty"
-	files := []siatest.TestFile{
-		{Name: "index.js", Data: []byte(fc1)},
-	}
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", true, false)
-	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
+	// verify we get a 400 if we supply an unsupported format parameter
+	_, err = r.SkynetSkylinkGet(fmt.Sprintf("%s/b?format=raw", skylink))
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'format'") {
+		t.Fatal("Expected download to fail because we are downloading a directory and an invalid format was provided, err:", err)
 	}
+
+	// verify we default to the `zip` format if it is a directory and we have
+	// not specified it (use a HEAD call as that returns the response headers)
 	_, header, err := r.SkynetSkylinkHead(skylink)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("unexpected error")
 	}
 	ct := header.Get("Content-Type")
 	if ct != "application/zip" {
-		t.Fatal("expected zip archive")
+		t.Fatal("unexpected content type: ", ct)
 	}
-}
 
-// testNoIndexSingleFileNoDefaultPath Does not contain "index.html".
-// Contains a single file and doesn't specify a default path (not disabled).
-// It should serve the only file's content.
-func testNoIndexSingleFileNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
-	fc1 := "File1Contents"
-	files := []siatest.TestFile{
-		{Name: "index.js", Data: []byte(fc1)},
-	}
-	filename := "index.js"
-	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
-	if err != nil {
-		t.Fatal("Failed to upload multipart file.", err)
-	}
-	content, err := r.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
+	// verify prefetching subfiles doesn't change the archives
+	for _, format := range []skymodules.SkyfileFormat{skymodules.SkyfileFormatZip, skymodules.SkyfileFormatTar} {
+		archive, err := r.SkynetSkylinkGet(fmt.Sprintf("%s?format=%s", skylink, format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefetched, err := r.SkynetSkylinkGet(fmt.Sprintf("%s?format=%s&prefetch=2", skylink, format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(archive, prefetched) {
+			t.Fatal("prefetched archive doesn't match", format)
+		}
 	}
-	if !bytes.Equal(content, files[0].Data) {
-		t.Fatalf("Expected to get content '%s', instead got '%s'", files[0].Data, string(content))
+
+	// verify a prefetch depth that is too deep is rejected
+	_, err = r.SkynetSkylinkGet(fmt.Sprintf("%s?format=zip&prefetch=100", skylink))
+	if err == nil || !strings.Contains(err.Error(), "'prefetch' parameter can't be greater than") {
+		t.Fatal("unexpected error", err)
 	}
 }
 
-// testSkynetRegistryReadWrite does some basic reads and writes on the registry
-// to build out a buffer of stats.
-func testSkynetRegistryReadWrite(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetDownloadRangeEncrypted verifies we can download a certain range
+// within an encrypted skyfile. This large file part of this test was added to
+// verify whether `DecryptBytesInPlace` was properly decrypting the fanout bytes
+// for offsets other than 0.
+func testSkynetDownloadRangeEncrypted(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Create some random skylinks to use later.
-	skylink1, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
-	if err != nil {
-		t.Fatal(err)
-	}
-	skylink2, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	// add a skykey
+	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
 	if err != nil {
 		t.Fatal(err)
 	}
+	t.Run("SmallFile", func(t *testing.T) {
+		skynetDownloadRangeTest(t, tg, sk.Name, false)
+	})
+	t.Run("LargeFile", func(t *testing.T) {
+		skynetDownloadRangeTest(t, tg, sk.Name, true)
+	})
+}
 
-	// Create a signed registry value.
-	sk, pk := crypto.GenerateKeyPair()
-	var dataKey crypto.Hash
-	fastrand.Read(dataKey[:])
-	data1 := skylink1.Bytes()
-	data2 := skylink2.Bytes()
-	srv1 := modules.NewRegistryValue(dataKey, data1, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
-	srv2 := modules.NewRegistryValue(dataKey, data2, 1, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 1
-	spk := types.SiaPublicKey{
-		Algorithm: types.SignatureEd25519,
-		Key:       pk[:],
-	}
+// testSkynetDownloadRange verifies we can download a certain range within a
+// skyfile.
+func testSkynetDownloadRange(t *testing.T, tg *siatest.TestGroup) {
+	t.Run("SmallFile", func(t *testing.T) {
+		skynetDownloadRangeTest(t, tg, "", false)
+	})
+	t.Run("LargeFile", func(t *testing.T) {
+		skynetDownloadRangeTest(t, tg, "", true)
+	})
+}
 
-	// Force a refresh of the worker pool for testing.
-	_, err = r.RenterWorkersGet()
-	if err != nil {
-		t.Fatal(err)
-	}
+// skynetDownloadRangeTest verifies different conditions of skynet downloads
+// with range requests.
+func skynetDownloadRangeTest(t *testing.T, tg *siatest.TestGroup, skykeyName string, largeFile bool) {
+	r := tg.Renters()[0]
 
-	// Update the regisry.
-	err = r.RegistryUpdate(spk, dataKey, srv1.Revision, srv1.Signature, skylink1)
-	if err != nil {
-		t.Fatal(err)
+	// generate file params
+	name := t.Name() + persist.RandomSuffix()
+	var size uint64
+	if largeFile {
+		size = uint64(4 * int(modules.SectorSize))
+	} else {
+		size = 100
 	}
+	data := fastrand.Bytes(int(size))
 
-	// Read it.
-	readSRV, err := r.RegistryRead(spk, dataKey)
+	// upload a skyfile
+	_, _, sshp, err := r.UploadNewEncryptedSkyfileBlocking(name, data, skykeyName, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(srv1, readSRV) {
-		t.Log(srv1)
-		t.Log(readSRV)
-		t.Fatal("srvs don't match")
+
+	// calculate random range parameters
+	segment := uint64(crypto.SegmentSize)
+	var offset, length uint64
+	if largeFile {
+		offset = fastrand.Uint64n(size-modules.SectorSize) + 1
+		length = fastrand.Uint64n(size-offset-segment) + 1
+	} else {
+		offset = fastrand.Uint64n(size-segment) + 1
+		length = fastrand.Uint64n(size-offset) + 1
 	}
 
-	// Update the registry again, with a higher revision.
-	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
+	// fetch the data at given range using the Header range request
+	result, err := r.SkynetSkylinkRange(sshp.Skylink, offset, offset+length)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !bytes.Equal(result, data[offset:offset+length]) {
+		t.Logf("range %v-%v\n", offset, offset+length)
+		t.Log("expected:", data[offset:offset+length], len(data[offset:offset+length]))
+		t.Log("actual:", result, len(result))
+		t.Fatal("unexpected")
+	}
 
-	// Read it.
-	readSRV, err = r.RegistryRead(spk, dataKey)
+	// fetch the data at given range using the params range request
+	result, err = r.SkynetSkylinkRangeParams(sshp.Skylink, offset, offset+length)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(srv2, readSRV) {
-		t.Log(srv2)
-		t.Log(readSRV)
-		t.Fatal("srvs don't match")
+	if !bytes.Equal(result, data[offset:offset+length]) {
+		t.Logf("range %v-%v\n", offset, offset+length)
+		t.Log("expected:", data[offset:offset+length], len(data[offset:offset+length]))
+		t.Log("actual:", result, len(result))
+		t.Fatal("unexpected")
 	}
 
-	// Update the registry again using the same revision but higher pow.
-	srvHigherPoW := srv2
-	slHigherPow := skylink2
-	for !srvHigherPoW.HasMoreWork(srv2.RegistryValue) {
-		sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	// Test some specific cases
+	// Verify some specific cases
+	rand := fastrand.Uint64n(size)
+	var tests = []struct {
+		from uint64
+		to   uint64
+	}{
+		{0, 0},       // Requesting the first byte of a file
+		{0, 1},       // Request the first byte of a file
+		{rand, rand}, // Requesting a random single byte of the file
+	}
+	for _, test := range tests {
+		_, err = r.SkynetSkylinkRange(sshp.Skylink, test.from, test.to)
 		if err != nil {
+			t.Log("Failed Test Case:", test)
+			t.Fatal(err)
+		}
+		_, err = r.SkynetSkylinkRangeParams(sshp.Skylink, test.from, test.to)
+		if err != nil {
+			t.Log("Failed Test Case:", test)
 			t.Fatal(err)
 		}
-		srvHigherPoW.Data = sl.Bytes()
-		srvHigherPoW = srvHigherPoW.Sign(sk)
-		slHigherPow = sl
-	}
-	err = r.RegistryUpdate(spk, dataKey, srvHigherPoW.Revision, srvHigherPoW.Signature, slHigherPow)
-	if err != nil {
-		t.Fatal(err)
 	}
 }
 
-// testSkynetDefaultPath_TableTest tests all combinations of inputs in relation
-// to default path.
-func testSkynetDefaultPath_TableTest(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+// testSkynetDownloadBaseSectorEncrypted tests downloading a skylink's encrypted
+// baseSector
+func testSkynetDownloadBaseSectorEncrypted(t *testing.T, tg *siatest.TestGroup) {
+	testSkynetDownloadBaseSector(t, tg, "basesectorkey")
+}
 
-	fc1 := []byte("File1Contents")
-	fc2 := []byte("File2Contents. This one is longer.")
+// testSkynetDownloadBaseSectorNoEncryption tests downloading a skylink's
+// baseSector
+func testSkynetDownloadBaseSectorNoEncryption(t *testing.T, tg *siatest.TestGroup) {
+	testSkynetDownloadBaseSector(t, tg, "")
+}
 
-	singleFile := []siatest.TestFile{
-		{Name: "about.html", Data: fc1},
+// testSkynetDownloadBaseSector tests downloading a skylink's baseSector
+func testSkynetDownloadBaseSector(t *testing.T, tg *siatest.TestGroup, skykeyName string) {
+	r := tg.Renters()[0]
+
+	// Add the SkyKey
+	var sk skykey.Skykey
+	var err error
+	if skykeyName != "" {
+		sk, err = r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
-	singleDir := []siatest.TestFile{
-		{Name: "dir/about.html", Data: fc1},
+
+	// Upload a small skyfile
+	filename := "onlyBaseSector" + persist.RandomSuffix()
+	size := 100 + siatest.Fuzz()
+	smallFileData := fastrand.Bytes(size)
+	skylink, sup, sshp, err := r.UploadNewEncryptedSkyfileBlocking(filename, smallFileData, skykeyName, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	multiHasIndex := []siatest.TestFile{
-		{Name: "index.html", Data: fc1},
-		{Name: "about.html", Data: fc2},
+
+	// Create a v2 skylink from it.
+	var skylinkV1 skymodules.Skylink
+	err = skylinkV1.LoadString(skylink)
+	if err != nil {
+		t.Fatal(err)
 	}
-	multiHasIndexIndexJs := []siatest.TestFile{
-		{Name: "index.html", Data: fc1},
-		{Name: "index.js", Data: fc1},
-		{Name: "about.html", Data: fc2},
+	skylinkV2, err := r.NewSkylinkV2(skylinkV1)
+	if err != nil {
+		t.Fatal(err)
 	}
-	multiNoIndex := []siatest.TestFile{
-		{Name: "hello.html", Data: fc1},
-		{Name: "about.html", Data: fc2},
-		{Name: "dir/about.html", Data: fc2},
+
+	// Download the BaseSector reader
+	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	about := "/about.html"
-	bad := "/bad.html"
-	index := "/index.html"
-	hello := "/hello.html"
-	nonHTML := "/index.js"
-	dirAbout := "/dir/about.html"
-	tests := []struct {
-		name                   string
-		files                  []siatest.TestFile
-		defaultPath            string
-		disableDefaultPath     bool
-		expectedContent        []byte
-		expectedErrStrDownload string
-		expectedErrStrUpload   string
-		expectedZipArchive     bool
-	}{
-		{
-			// Single files with valid default path.
-			// OK
-			name:            "single_correct",
-			files:           singleFile,
-			defaultPath:     about,
-			expectedContent: fc1,
-		},
-		{
-			// Single files without default path.
-			// OK
-			name:            "single_nil",
-			files:           singleFile,
-			defaultPath:     "",
-			expectedContent: fc1,
-		},
-		{
-			// Single files with default, empty default path (disabled).
-			// Expect a zip archive
-			name:               "single_def_empty",
-			files:              singleFile,
-			defaultPath:        "",
-			disableDefaultPath: true,
-			expectedZipArchive: true,
-		},
-		{
-			// Single files with default, bad default path.
-			// Error on upload: invalid default path
-			name:                 "single_def_bad",
-			files:                singleFile,
-			defaultPath:          bad,
-			expectedContent:      nil,
-			expectedErrStrUpload: "invalid default path provided",
-		},
+	// Read the baseSector
+	baseSector, err := ioutil.ReadAll(baseSectorReader)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		{
-			// Single dir with default path set to a nested file.
-			// Error: invalid default path.
-			name:                 "single_dir_nested",
-			files:                singleDir,
-			defaultPath:          dirAbout,
-			expectedContent:      nil,
-			expectedErrStrUpload: "invalid default path provided",
-		},
-		{
-			// Single dir without default path (not disabled).
-			// OK
-			name:               "single_dir_nil",
-			files:              singleDir,
-			defaultPath:        "",
-			disableDefaultPath: false,
-			expectedContent:    fc1,
-		},
-		{
-			// Single dir with empty default path (disabled).
-			// Expect a zip archive
-			name:               "single_dir_def_empty",
-			files:              singleDir,
-			defaultPath:        "",
-			disableDefaultPath: true,
-			expectedZipArchive: true,
-		},
-		{
-			// Single dir with bad default path.
-			// Error on upload: invalid default path
-			name:                 "single_def_bad",
-			files:                singleDir,
-			defaultPath:          bad,
-			expectedContent:      nil,
-			expectedErrStrUpload: "invalid default path provided",
-		},
+	// Download the BaseSector reader for the V2 link.
+	baseSectorReaderV2, err := r.SkynetBaseSectorGet(skylinkV2.String())
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		{
-			// Multi dir with index, correct default path.
-			// OK
-			name:            "multi_idx_correct",
-			files:           multiHasIndex,
-			defaultPath:     index,
-			expectedContent: fc1,
-		},
-		{
-			// Multi dir with index, no default path (not disabled).
-			// OK
-			name:               "multi_idx_nil",
-			files:              multiHasIndex,
-			defaultPath:        "",
-			disableDefaultPath: false,
-			expectedContent:    fc1,
-		},
-		{
-			// Multi dir with index, empty default path (disabled).
-			// Expect a zip archive
-			name:               "multi_idx_empty",
-			files:              multiHasIndex,
-			defaultPath:        "",
-			disableDefaultPath: true,
-			expectedZipArchive: true,
-		},
-		{
-			// Multi dir with index, non-html default path.
-			// OK
-			name:               "multi_idx_non_html",
-			files:              multiHasIndexIndexJs,
-			defaultPath:        nonHTML,
-			disableDefaultPath: false,
-			expectedContent:    fc1,
-		},
-		{
-			// Multi dir with index, bad default path.
-			// Error on upload: invalid default path.
-			name:                 "multi_idx_bad",
-			files:                multiHasIndex,
-			defaultPath:          bad,
-			expectedContent:      nil,
-			expectedErrStrUpload: "invalid default path provided",
-		},
+	// Read the baseSector
+	baseSectorV2, err := ioutil.ReadAll(baseSectorReaderV2)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		{
-			// Multi dir with no index, correct default path.
-			// OK
-			name:            "multi_noidx_correct",
-			files:           multiNoIndex,
-			defaultPath:     hello,
-			expectedContent: fc1,
-		},
-		{
-			// Multi dir with no index, no default path (not disabled).
-			// Expect a zip archive
-			name:               "multi_noidx_nil",
-			files:              multiNoIndex,
-			defaultPath:        "",
-			disableDefaultPath: false,
-			expectedZipArchive: true,
-		},
-		{
-			// Multi dir with no index, empty default path (disabled).
-			// Expect a zip archive
-			name:               "multi_noidx_empty",
-			files:              multiNoIndex,
-			defaultPath:        "",
-			disableDefaultPath: true,
-			expectedZipArchive: true,
-		},
+	// They should be the same.
+	if !bytes.Equal(baseSector, baseSectorV2) {
+		t.Fatal("base sectors don't match")
+	}
 
-		{
-			// Multi dir with no index, bad default path.
-			// Error on upload: invalid default path.
-			name:                 "multi_noidx_bad",
-			files:                multiNoIndex,
-			defaultPath:          bad,
-			expectedContent:      nil,
-			expectedErrStrUpload: "invalid default path provided",
-		},
-		{
-			// Multi dir with both defaultPath and disableDefaultPath set.
-			// Error on upload.
-			name:                 "multi_defpath_disabledefpath",
-			files:                multiHasIndex,
-			defaultPath:          index,
-			disableDefaultPath:   true,
-			expectedContent:      nil,
-			expectedErrStrUpload: "DefaultPath and DisableDefaultPath are mutually exclusive and cannot be set together",
-		},
-		{
-			// Multi dir with defaultPath pointing to a non-root file..
-			// Error on upload.
-			name:                 "multi_nonroot_defpath",
-			files:                multiNoIndex,
-			defaultPath:          dirAbout,
-			expectedContent:      nil,
-			expectedErrStrUpload: "skyfile has invalid default path which refers to a non-root file",
+	// Check for encryption
+	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
+	if encrypted != (skykeyName != "") {
+		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	}
+	if encrypted {
+		_, err = skymodules.DecryptBaseSector(baseSector, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Parse the skyfile metadata from the baseSector
+	_, fanoutBytes, metadata, _, baseSectorPayload, err := skymodules.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the metadata
+	expected := skymodules.SkyfileMetadata{
+		Filename: filename,
+		Length:   uint64(size),
+		Mode:     os.FileMode(skymodules.DefaultFilePerm),
+		ModTime:  sup.ModTime,
+	}
+
+	if !reflect.DeepEqual(expected, metadata) {
+		siatest.PrintJSON(expected)
+		siatest.PrintJSON(metadata)
+		t.Error("Metadata not equal")
+	}
+
+	// Verify the file data
+	if !bytes.Equal(smallFileData, baseSectorPayload) {
+		t.Log("FileData bytes:", smallFileData)
+		t.Log("BaseSectorPayload bytes:", baseSectorPayload)
+		t.Errorf("Bytes not equal")
+	}
+
+	// Since this was a small file upload there should be no fanout bytes
+	if len(fanoutBytes) != 0 {
+		t.Error("Expected 0 fanout bytes:", fanoutBytes)
+	}
+
+	// Verify DownloadByRoot gives the same information
+	rootSectorReader, err := r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the rootSector
+	rootSector, err := ioutil.ReadAll(rootSectorReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check for encryption
+	encrypted = skymodules.IsEncryptedBaseSector(rootSector)
+	if encrypted != (skykeyName != "") {
+		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	}
+	if encrypted {
+		_, err = skymodules.DecryptBaseSector(rootSector, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Parse the skyfile metadata from the rootSector
+	_, fanoutBytes, metadata, _, rootSectorPayload, err := skymodules.ParseSkyfileMetadata(rootSector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the metadata
+	if !reflect.DeepEqual(expected, metadata) {
+		siatest.PrintJSON(expected)
+		siatest.PrintJSON(metadata)
+		t.Error("Metadata not equal")
+	}
+
+	// Verify the file data
+	if !bytes.Equal(smallFileData, rootSectorPayload) {
+		t.Log("FileData bytes:", smallFileData)
+		t.Log("rootSectorPayload bytes:", rootSectorPayload)
+		t.Errorf("Bytes not equal")
+	}
+
+	// Since this was a small file upload there should be no fanout bytes
+	if len(fanoutBytes) != 0 {
+		t.Error("Expected 0 fanout bytes:", fanoutBytes)
+	}
+}
+
+// testSkynetDownloadWithSkykey tests downloading an encrypted skyfile with a
+// skykey which is provided with the request instead of being added to the
+// node.
+func testSkynetDownloadWithSkykey(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	uc := client.NewUnsafeClient(r.Client)
+
+	// Create two skykeys and upload an encrypted small and large file with the
+	// first one.
+	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSk, err := r.SkykeyCreateKeyPost(t.Name()+"_other", skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallData := fastrand.Bytes(100)
+	small, _, _, err := r.UploadNewEncryptedSkyfileBlocking(t.Name()+"_small", smallData, sk.Name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largeData := fastrand.Bytes(int(2 * modules.SectorSize))
+	large, _, _, err := r.UploadNewEncryptedSkyfileBlocking(t.Name()+"_large", largeData, sk.Name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the skykeys from the node.
+	err = r.SkykeyDeleteByNamePost(sk.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.SkykeyDeleteByNamePost(otherSk.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		skylink string
+		data    []byte
+	}{
+		{small, smallData},
+		{large, largeData},
+	} {
+		// Without the skykey the download fails.
+		_, err = r.SkynetSkylinkGet(test.skylink)
+		if err == nil {
+			t.Fatal("download without skykey should fail")
+		}
+
+		// With the wrong skykey the download is forbidden.
+		resp, err := uc.SkynetSkylinkGetWithSkykey(test.skylink, otherSk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatal("unexpected status code", resp.StatusCode)
+		}
+
+		// With the right skykey the download succeeds.
+		resp, err = uc.SkynetSkylinkGetWithSkykey(test.skylink, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		err = errors.Compose(err, resp.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status code", resp.StatusCode, string(data))
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Fatal("wrong data")
+		}
+
+		// The decrypted data source is not shared with downloads that don't
+		// provide the skykey.
+		_, err = r.SkynetSkylinkGet(test.skylink)
+		if err == nil {
+			t.Fatal("download without skykey should fail")
+		}
+	}
+
+	// The skykey is also used to decrypt the target of a redirect.
+	siaPath, err := skymodules.NewSiaPath(t.Name() + "_redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, _, err := r.SkynetSkyfileRedirectPost(skymodules.SkyfileUploadParameters{
+		SiaPath:  siaPath,
+		Filename: "redirect.bin",
+	}, large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetSkylinkGet(redirect)
+	if err == nil {
+		t.Fatal("download of redirect without skykey should fail")
+	}
+	resp, err := uc.SkynetSkylinkGetWithSkykey(redirect, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	err = errors.Compose(err, resp.Body.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status code", resp.StatusCode, string(data))
+	}
+	if !bytes.Equal(data, largeData) {
+		t.Fatal("wrong data")
+	}
+	if sl := resp.Header.Get(api.SkynetSkylinkHeader); sl != large {
+		t.Fatalf("expected skylink of redirect target %v but got %v", large, sl)
+	}
+
+	// The skykey was never added to the node.
+	_, err = r.SkykeyGetByID(sk.ID())
+	if err == nil {
+		t.Fatal("skykey shouldn't be known to the node")
+	}
+
+	// An invalid skykey is rejected.
+	resp, err = uc.GetWithHeaders("/skynet/skylink/"+small, http.Header{api.SkynetSkykeyHeader: []string{"invalid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+}
+
+// testSkynetMetadataEncrypted tests fetching the metadata and the decrypted
+// base sector of an encrypted skyfile.
+func testSkynetMetadataEncrypted(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Add the skykeys.
+	skykeyName := "metadatakey"
+	sk, err := r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSkykeyName := "othermetadatakey"
+	otherSk, err := r.SkykeyCreateKeyPost(otherSkykeyName, skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a small encrypted skyfile.
+	filename := "encryptedMetadata" + persist.RandomSuffix()
+	size := 100 + siatest.Fuzz()
+	skylink, sup, _, err := r.UploadNewEncryptedSkyfileBlocking(filename, fastrand.Bytes(size), skykeyName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the encrypted base sector and decrypt it locally.
+	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedBaseSector, err := ioutil.ReadAll(baseSectorReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skymodules.IsEncryptedBaseSector(encryptedBaseSector) {
+		t.Fatal("base sector should be encrypted")
+	}
+	baseSector := append([]byte{}, encryptedBaseSector...)
+	_, err = skymodules.DecryptBaseSector(baseSector, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metadata should be decrypted when trying all keys and when
+	// specifying the right skykey.
+	expected := skymodules.SkyfileMetadata{
+		Filename: filename,
+		Length:   uint64(size),
+		Mode:     os.FileMode(skymodules.DefaultFilePerm),
+		ModTime:  sup.ModTime,
+	}
+	for _, values := range []url.Values{
+		{"trykeys": []string{"true"}},
+		{"skykeyname": []string{skykeyName}},
+	} {
+		_, md, err := r.SkynetMetadataGetWithParameters(skylink, values)
+		if err != nil {
+			t.Fatal(values, err)
+		}
+		if !reflect.DeepEqual(md, expected) {
+			siatest.PrintJSON(md)
+			siatest.PrintJSON(expected)
+			t.Fatal("metadata mismatch", values)
+		}
+	}
+
+	// The basesector endpoint should decrypt the base sector on request.
+	decrypted, err := r.SkynetBaseSectorGetDecrypted(skylink, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, baseSector) {
+		t.Fatal("base sector wasn't decrypted correctly")
+	}
+	decrypted, err = r.SkynetBaseSectorGetDecrypted(skylink, skykeyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, baseSector) {
+		t.Fatal("base sector wasn't decrypted correctly")
+	}
+	decrypted, err = r.SkynetBaseSectorGetDecryptedWithParameters(skylink, url.Values{"skykeyid": []string{sk.ID().ToString()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, baseSector) {
+		t.Fatal("base sector wasn't decrypted correctly")
+	}
+
+	// Choosing a skykey by both name and ID is not allowed.
+	_, err = r.SkynetBaseSectorGetDecryptedWithParameters(skylink, url.Values{
+		"skykeyname": []string{skykeyName},
+		"skykeyid":   []string{sk.ID().ToString()},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Using an unknown skykey should fail with a 400.
+	_, _, err = r.SkynetMetadataGetWithParameters(skylink, url.Values{"skykeyname": []string{"unknown"}})
+	if err == nil || !strings.Contains(err.Error(), skykey.ErrNoSkykeysWithThatName.Error()) {
+		t.Fatal("expected unknown skykey error", err)
+	}
+
+	// Using the wrong skykey or not trying all the keys should fail with a
+	// 403 which tells the caller which key is required. Trying all the keys
+	// is opt-in so that is also the case by default. Decrypting the base
+	// sector with the wrong skykey is a 400 instead.
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{fmt.Sprintf("/skynet/metadata/%v?skykeyname=%v", skylink, otherSkykeyName), http.StatusForbidden},
+		{fmt.Sprintf("/skynet/metadata/%v", skylink), http.StatusForbidden},
+		{fmt.Sprintf("/skynet/metadata/%v?trykeys=false", skylink), http.StatusForbidden},
+		{fmt.Sprintf("/skynet/basesector/%v?decrypt=true&skykeyname=%v", skylink, otherSkykeyName), http.StatusBadRequest},
+		{fmt.Sprintf("/skynet/basesector/%v?decrypt=true&skykeyid=%v", skylink, url.QueryEscape(otherSk.ID().ToString())), http.StatusBadRequest},
+		{fmt.Sprintf("/skynet/basesector/%v?decrypt=true", skylink), http.StatusForbidden},
+		{fmt.Sprintf("/skynet/basesector/%v?decrypt=true&trykeys=false", skylink), http.StatusForbidden},
+	}
+	for _, test := range tests {
+		query := test.query
+		req, err := r.NewRequest("GET", query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var apiErr api.SkynetBaseSectorEncryptedError
+		err = errors.Compose(json.NewDecoder(res.Body).Decode(&apiErr), res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.code {
+			t.Fatal("wrong status code", query, res.StatusCode)
+		}
+		if !apiErr.Encrypted {
+			t.Fatal("error should indicate an encrypted base sector")
+		}
+		if apiErr.SkykeyID != skymodules.EncryptedBaseSectorKeyID(encryptedBaseSector).ToString() {
+			t.Fatal("wrong skykey id", apiErr.SkykeyID)
+		}
+		if !strings.Contains(apiErr.Message, api.ErrBaseSectorEncrypted.Error()) {
+			t.Fatal("wrong message", apiErr.Message)
+		}
+	}
+}
+
+// TestSkynetDownloadByRoot verifies the functionality of the download by root
+// routes. It is separate as it requires an amount of hosts equal to the total
+// amount of pieces per chunk.
+func TestSkynetDownloadByRoot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Define the parameters.
+	numHosts := 6
+	groupParams := siatest.GroupParams{
+		Hosts:  numHosts,
+		Miners: 1,
+	}
+	groupDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	if err != nil {
+		t.Fatal(errors.AddContext(err, "failed to create group"))
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Update the renter's allowance to support 6 hosts
+	renterParams := node.Renter(filepath.Join(groupDir, "renter"))
+	renterParams.Allowance = siatest.DefaultAllowance
+	renterParams.Allowance.Hosts = uint64(numHosts)
+	_, err = tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Test the standard flow.
+	t.Run("NoEncryption", func(t *testing.T) {
+		testSkynetDownloadByRoot(t, tg, "")
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		testSkynetDownloadByRoot(t, tg, "rootkey")
+	})
+}
+
+// testSkynetDownloadByRoot tests downloading by root
+func testSkynetDownloadByRoot(t *testing.T, tg *siatest.TestGroup, skykeyName string) {
+	r := tg.Renters()[0]
+
+	// Add the SkyKey
+	var sk skykey.Skykey
+	var err error
+	if skykeyName != "" {
+		sk, err = r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Upload a skyfile that will have a fanout
+	filename := "byRootLargeFile" + persist.RandomSuffix()
+	size := 2*int(modules.SectorSize) + siatest.Fuzz()
+	fileData := fastrand.Bytes(size)
+	_, sup, sshp, err := r.UploadNewEncryptedSkyfileBlocking(filename, fileData, skykeyName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download the base sector
+	reader, err := r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the baseSector
+	baseSector, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check for encryption
+	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
+	if encrypted != (skykeyName != "") {
+		t.Fatal("wrong encrypted state", encrypted, skykeyName)
+	}
+	var fileKey skykey.Skykey
+	if encrypted {
+		fileKey, err = skymodules.DecryptBaseSector(baseSector, sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Parse the information from the BaseSector
+	layout, fanoutBytes, metadata, _, baseSectorPayload, err := skymodules.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the metadata
+	expected := skymodules.SkyfileMetadata{
+		Filename: filename,
+		Length:   uint64(size),
+		Mode:     os.FileMode(skymodules.DefaultFilePerm),
+		ModTime:  sup.ModTime,
+	}
+	if !reflect.DeepEqual(expected, metadata) {
+		siatest.PrintJSON(expected)
+		siatest.PrintJSON(metadata)
+		t.Error("Metadata not equal")
+	}
+
+	// The baseSector should be empty since there is a fanout
+	if len(baseSectorPayload) != 0 {
+		t.Error("baseSectorPayload should be empty:", baseSectorPayload)
+	}
+
+	// For large files there should be fanout bytes
+	if len(fanoutBytes) == 0 {
+		t.Fatal("no fanout bytes")
+	}
+
+	// Decode Fanout
+	piecesPerChunk, chunkRootsSize, numChunks, err := skymodules.DecodeFanout(layout, fanoutBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Calculate the expected pieces per chunk, and keep track of the original
+	// pieces per chunk. If there's no encryption and there's only 1 data piece,
+	// the fanout bytes will only contain a single piece (as the other pieces
+	// will be identical, so that would be wasting space). We need to take this
+	// into account when recovering the data as the EC will expect the original
+	// amount of pieces.
+	expectedPPC := layout.FanoutDataPieces + layout.FanoutParityPieces
+	originalPPC := expectedPPC
+	if layout.FanoutDataPieces == 1 && layout.CipherType == crypto.TypePlain {
+		expectedPPC = 1
+	}
+
+	// Verify fanout information
+	if piecesPerChunk != uint64(expectedPPC) {
+		t.Fatal("piecesPerChunk incorrect", piecesPerChunk)
+	}
+	if chunkRootsSize != crypto.HashSize*piecesPerChunk {
+		t.Fatal("chunkRootsSize incorrect", chunkRootsSize)
+	}
+
+	// Derive the fanout key
+	var fanoutKey crypto.CipherKey
+	if encrypted {
+		fanoutKey, err = skymodules.DeriveFanoutKey(&layout, fileKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create the erasure coder
+	ec, err := skymodules.NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunkSize := (modules.SectorSize - layout.CipherType.Overhead()) * uint64(layout.FanoutDataPieces)
+	// Create list of chunk roots
+	chunkRoots := make([][]crypto.Hash, 0, numChunks)
+	for i := uint64(0); i < numChunks; i++ {
+		root := make([]crypto.Hash, piecesPerChunk)
+		for j := uint64(0); j < piecesPerChunk; j++ {
+			fanoutOffset := (i * chunkRootsSize) + (j * crypto.HashSize)
+			copy(root[j][:], fanoutBytes[fanoutOffset:])
+		}
+		chunkRoots = append(chunkRoots, root)
+	}
+
+	// Download roots
+	var rootBytes []byte
+	var blankHash crypto.Hash
+	for i := uint64(0); i < numChunks; i++ {
+		// Create the pieces for this chunk
+		pieces := make([][]byte, len(chunkRoots[i]))
+		for j := uint64(0); j < piecesPerChunk; j++ {
+			// Ignore null roots
+			if chunkRoots[i][j] == blankHash {
+				continue
+			}
+
+			// Download the sector
+			reader, err := r.SkynetDownloadByRootGet(chunkRoots[i][j], 0, modules.SectorSize, -1)
+			if err != nil {
+				t.Log("root", chunkRoots[i][j])
+				t.Fatal(err)
+			}
+
+			// Read the sector
+			sector, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Decrypt to data if needed
+			if encrypted {
+				key := fanoutKey.Derive(i, j)
+				_, err = key.DecryptBytesInPlace(sector, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Add the sector to the list of pieces
+			pieces[j] = sector
+		}
+
+		// Decode the erasure coded chunk
+		var chunkBytes []byte
+		if ec != nil {
+			buf := bytes.NewBuffer(nil)
+			if len(pieces) == 1 && originalPPC > 1 {
+				deduped := make([][]byte, originalPPC)
+				for i := range deduped {
+					deduped[i] = make([]byte, len(pieces[0]))
+					copy(deduped[i], pieces[0])
+				}
+				pieces = deduped
+			}
+			err = ec.Recover(pieces, chunkSize, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunkBytes = buf.Bytes()
+		} else {
+			// The unencrypted file is not erasure coded so just read the piece
+			// data directly
+			for _, p := range pieces {
+				chunkBytes = append(chunkBytes, p...)
+			}
+		}
+		rootBytes = append(rootBytes, chunkBytes...)
+	}
+
+	// Truncate download data to the file length
+	rootBytes = rootBytes[:size]
+
+	// Verify bytes
+	if !reflect.DeepEqual(fileData, rootBytes) {
+		t.Log("FileData bytes:", fileData)
+		t.Log("root bytes:", rootBytes)
+		t.Error("Bytes not equal")
+	}
+}
+
+// testSkynetFanoutRegression is a regression test that ensures the fanout bytes
+// of a skyfile don't contain any empty hashes
+func testSkynetFanoutRegression(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Add a skykey to the renter
+	sk, err := r.SkykeyCreateKeyPost("fanout", skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a file with a large number of parity pieces to we can be reasonable
+	// confident that the upload will not fully complete before the fanout needs
+	// to be generated.
+	size := 2*int(modules.SectorSize) + siatest.Fuzz()
+	data := fastrand.Bytes(size)
+	skylink, _, _, _, err := r.UploadSkyfileCustom("regression", data, sk.Name, 20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download the basesector to check the fanout bytes
+	baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseSector, err := ioutil.ReadAll(baseSectorReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = skymodules.DecryptBaseSector(baseSector, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// FanoutBytes should not contain any empty hashes
+	for i := 0; i < len(fanoutBytes); {
+		end := i + crypto.HashSize
+		var emptyHash crypto.Hash
+		root := fanoutBytes[i:end]
+		if bytes.Equal(root, emptyHash[:]) {
+			t.Fatal("empty hash found in fanout")
+		}
+		i = end
+	}
+}
+
+// testSkynetSubDirDownload verifies downloading data from a skyfile using a
+// path to download single subfiles or subdirectories
+func testSkynetSubDirDownload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	dataFile1 := []byte("file1.txt")
+	dataFile2 := []byte("file2.txt")
+	dataFile3 := []byte("file3.txt")
+	filePath1 := "a/5.f4f8b583.chunk.js"
+	filePath2 := "a/5.f4f.chunk.js.map"
+	filePath3 := "b/file3.txt"
+	_, err := skymodules.AddMultipartFile(writer, dataFile1, "files[]", filePath1, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = skymodules.AddMultipartFile(writer, dataFile2, "files[]", filePath2, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = skymodules.AddMultipartFile(writer, dataFile3, "files[]", filePath3, 0640, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader := bytes.NewReader(body.Bytes())
+
+	name := "testSkynetSubfileDownload"
+	uploadSiaPath, err := skymodules.NewSiaPath(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:             uploadSiaPath,
+		Force:               false,
+		Root:                false,
+		BaseChunkRedundancy: 2,
+		Reader:              reader,
+		ContentType:         writer.FormDataContentType(),
+		Filename:            name,
+	}
+
+	skylink, _, err := r.SkynetSkyfileMultiPartPost(mup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get all the data
+	data, err := r.SkynetSkylinkConcatGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metadata, err := r.SkynetMetadataGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Filename != name {
+		t.Fatal("Unexpected filename", metadata.Filename, name)
+	}
+	expected := append(dataFile1, dataFile2...)
+	expected = append(expected, dataFile3...)
+	if !bytes.Equal(data, expected) {
+		t.Fatal("Unexpected data")
+	}
+
+	// get all data for path "a"
+	data, err = r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/a", skylink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(dataFile1, dataFile2...)
+	if !bytes.Equal(data, expected) {
+		t.Fatal("Unexpected data")
+	}
+
+	// get all data for path "b"
+	data, err = r.SkynetSkylinkConcatGet(fmt.Sprintf("%s/b", skylink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = dataFile3
+	if !bytes.Equal(expected, data) {
+		t.Fatal("Unexpected data")
+	}
+	mdF3, ok := metadata.Subfiles["b/file3.txt"]
+	if !ok {
+		t.Fatal("Expected subfile metadata of file3 to be present")
+	}
+
+	mdF3Expected := skymodules.SkyfileSubfileMetadata{
+		FileMode:    os.FileMode(0640),
+		Filename:    "b/file3.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Offset:      uint64(len(dataFile1) + len(dataFile2)),
+		Len:         uint64(len(dataFile3)),
+	}
+	if !reflect.DeepEqual(mdF3, mdF3Expected) {
+		t.Log("expected: ", mdF3Expected)
+		t.Log("actual: ", mdF3)
+		t.Fatal("Unexpected subfile metadata for file 3")
+	}
+
+	// get a single sub file
+	downloadFile2, err := r.SkynetSkylinkGet(fmt.Sprintf("%s/a/5.f4f.chunk.js.map", skylink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dataFile2, downloadFile2) {
+		t.Log("expected:", dataFile2)
+		t.Log("actual:", downloadFile2)
+		t.Fatal("Unexpected data for file 2")
+	}
+
+	// get the sub files by their index, they are ordered by offset and
+	// served just like when they are requested by path
+	for i, test := range []struct {
+		path string
+		data []byte
+	}{
+		{filePath1, dataFile1},
+		{filePath2, dataFile2},
+		{filePath3, dataFile3},
+	} {
+		header, data, err := r.SkynetSkylinkGetWithSubfileIndex(skylink, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Fatalf("Unexpected data for index %v", i)
+		}
+		_, pathHeader, err := r.SkynetSkylinkHead(fmt.Sprintf("%s/%s", skylink, test.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"Content-Type", "Content-Length", "Content-Disposition", "ETag"} {
+			if header.Get(key) != pathHeader.Get(key) {
+				t.Fatalf("Unexpected %v header for index %v: %v != %v", key, i, header.Get(key), pathHeader.Get(key))
+			}
+		}
+	}
+	data, err = r.SkynetSkylinkRangeWithSubfileIndex(skylink, 2, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, dataFile3[1:4]) {
+		t.Fatal("Unexpected data for range of index 2", string(data))
+	}
+	_, _, err = r.SkynetSkylinkGetWithSubfileIndex(skylink, 3)
+	if err == nil || !strings.Contains(err.Error(), "valid indices are 0 to 2") {
+		t.Fatal("Expected out of range error", err)
+	}
+	_, _, err = r.SkynetSkylinkGetWithSubfileIndex(skylink+"/a", 0)
+	if err == nil || !strings.Contains(err.Error(), "can't be combined with a path") {
+		t.Fatal("Expected error when combining an index with a path", err)
+	}
+
+	// get the index listings for the root and the sub directories
+	link := func(filename string) string {
+		return fmt.Sprintf("/skynet/skylink/%s/%s", skylink, filename)
+	}
+	entry1 := api.SkynetSkylinkIndexEntry{
+		Filename:    filePath1,
+		Size:        uint64(len(dataFile1)),
+		ContentType: metadata.Subfiles[filePath1].ContentType,
+		Link:        link(filePath1),
+	}
+	entry2 := api.SkynetSkylinkIndexEntry{
+		Filename:    filePath2,
+		Size:        uint64(len(dataFile2)),
+		ContentType: metadata.Subfiles[filePath2].ContentType,
+		Link:        link(filePath2),
+	}
+	entry3 := api.SkynetSkylinkIndexEntry{
+		Filename:    filePath3,
+		Size:        uint64(len(dataFile3)),
+		ContentType: mdF3.ContentType,
+		Link:        link(filePath3),
+	}
+	tests := []struct {
+		path    string
+		entries []api.SkynetSkylinkIndexEntry
+	}{
+		{"/", []api.SkynetSkylinkIndexEntry{entry2, entry1, entry3}},
+		{"/a", []api.SkynetSkylinkIndexEntry{entry2, entry1}},
+		{"/b", []api.SkynetSkylinkIndexEntry{entry3}},
+	}
+	for _, test := range tests {
+		index, err := r.SkynetSkylinkIndexGet(skylink + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index.Path != test.path {
+			t.Fatalf("unexpected path %v != %v", index.Path, test.path)
+		}
+		if !reflect.DeepEqual(index.Subfiles, test.entries) {
+			t.Log("expected: ", test.entries)
+			t.Log("actual: ", index.Subfiles)
+			t.Fatal("Unexpected index for path", test.path)
+		}
+	}
+
+	// get the index listing for "/a" as html
+	req, err := r.NewRequest("GET", fmt.Sprintf("/skynet/skylink/%s/a?format=index", skylink), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Fatal("unexpected response", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(html), link(filePath1)) || !strings.Contains(string(html), link(filePath2)) {
+		t.Fatal("html index is missing subfiles", string(html))
+	}
+	if strings.Contains(string(html), filePath3) {
+		t.Fatal("html index contains sibling directory", string(html))
+	}
+}
+
+// testSkynetDownloadChecksum verifies that a skyfile download returns the
+// checksum of the downloaded data in a trailer if requested.
+func testSkynetDownloadChecksum(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile.
+	data := fastrand.Bytes(int(modules.SectorSize) + siatest.Fuzz())
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download it with a checksum.
+	req, err := r.NewRequest("GET", fmt.Sprintf("/skynet/skylink/%s?checksum=sha256", skylink), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("unexpected data")
+	}
+
+	// The trailer should contain the checksum.
+	checksum := sha256.Sum256(data)
+	if res.Trailer.Get(api.SkynetChecksumTrailer) != hex.EncodeToString(checksum[:]) {
+		t.Fatal("unexpected checksum", res.Trailer)
+	}
+
+	// Unknown checksum types should be rejected.
+	_, err = r.SkynetSkylinkGet(skylink + "?checksum=md5")
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'checksum' parameter") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// contentHash is a helper to compute the expected content hash.
+	contentHash := func(b []byte) string {
+		h := crypto.HashBytes(b)
+		return hex.EncodeToString(h[:])
+	}
+
+	// A client that accepts trailers receives the content hash.
+	downloaded, hash, err := r.SkynetSkylinkGetWithContentHash(skylink, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) || hash != contentHash(data) {
+		t.Fatal("unexpected data or hash", hash)
+	}
+
+	// For range requests only the served range is hashed.
+	values := url.Values{}
+	values.Set("start", "10")
+	values.Set("end", "100")
+	downloaded, hash, err = r.SkynetSkylinkGetWithContentHash(skylink, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data[10:100]) || hash != contentHash(data[10:100]) {
+		t.Fatal("unexpected data or hash", hash)
+	}
+
+	// Archives are hashed as they are served.
+	files := []siatest.TestFile{
+		{Name: "a.txt", Data: fastrand.Bytes(100)},
+		{Name: "b.txt", Data: fastrand.Bytes(200)},
+	}
+	multiSkylink, _, _, err := r.UploadNewMultipartSkyfileBlocking("contenthash", files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = url.Values{}
+	values.Set("format", string(skymodules.SkyfileFormatZip))
+	downloaded, hash, err = r.SkynetSkylinkGetWithContentHash(multiSkylink, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != contentHash(downloaded) {
+		t.Fatal("unexpected hash", hash)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(downloaded), int64(len(downloaded)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatal("unexpected number of files", len(zr.File))
+	}
+
+	// The content hash can also be requested explicitly.
+	req, err = r.NewRequest("GET", fmt.Sprintf("/skynet/skylink/%s?checksum=blake2b", skylink), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) || res.Trailer.Get(api.SkynetContentHashTrailer) != contentHash(data) {
+		t.Fatal("unexpected data or hash", res.Trailer)
+	}
+}
+
+// testSkynetDownloadHostStats verifies that a skyfile download returns the
+// stats of the hosts which served the data if requested.
+func testSkynetDownloadHostStats(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a large skyfile.
+	data := fastrand.Bytes(2*int(modules.SectorSize) + siatest.Fuzz())
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkHostStats is a helper to verify the host stats.
+	checkHostStats := func(hostStats []skymodules.SkynetHostStats, expectedBytes uint64) {
+		t.Helper()
+		if len(hostStats) == 0 || len(hostStats) > len(tg.Hosts()) {
+			t.Fatal("unexpected number of hosts", len(hostStats))
+		}
+		var bytes, bandwidth uint64
+		for _, hs := range hostStats {
+			bytes += hs.Bytes
+			bandwidth += hs.Bandwidth
+		}
+		if bytes != expectedBytes {
+			t.Fatalf("expected hosts to serve %v bytes but got %v", expectedBytes, bytes)
+		}
+		if bandwidth < bytes {
+			t.Fatalf("expected at least %v bytes of bandwidth but got %v", bytes, bandwidth)
+		}
+	}
+
+	// Download it with the host stats.
+	downloaded, hostStats, err := r.SkynetSkylinkGetWithHostStats(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("unexpected data")
+	}
+	checkHostStats(hostStats, uint64(len(data)))
+
+	// Download it with the used bandwidth.
+	downloaded, bandwidth, err := r.SkynetSkylinkGetWithBandwidth(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("unexpected data")
+	}
+	if bandwidth < uint64(len(data)) {
+		t.Fatalf("expected at least %v bytes of bandwidth but got %v", len(data), bandwidth)
+	}
+
+	// A HEAD request should return the host stats as a header.
+	values := url.Values{}
+	values.Set("include-hosts", "true")
+	status, header, err := r.SkynetSkylinkHeadWithParameters(skylink, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	if header.Get(api.SkynetHostStatsTrailer) == "" {
+		t.Fatal("host stats header is missing")
+	}
+
+	// The host stats should not be returned by default.
+	status, header, err = r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	if header.Get(api.SkynetHostStatsTrailer) != "" {
+		t.Fatal("host stats header shouldn't be set")
+	}
+
+	// Download the base sector by root with the host stats.
+	var sl skymodules.Skylink
+	err = sl.LoadString(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sector, hostStats, err := r.SkynetDownloadByRootGetWithHostStats(sl.MerkleRoot(), 0, modules.SectorSize, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(sector)) != modules.SectorSize {
+		t.Fatal("unexpected sector length", len(sector))
+	}
+	checkHostStats(hostStats, modules.SectorSize)
+
+	// Invalid values should be rejected.
+	_, err = r.SkynetSkylinkGet(skylink + "?include-hosts=maybe")
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'include-hosts' parameter") {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testSkynetDownloadRetries verifies the 'retries' parameter of a skyfile
+// download.
+func testSkynetDownloadRetries(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile.
+	data := fastrand.Bytes(100 + siatest.Fuzz())
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download it with retries.
+	downloaded, err := r.SkynetSkylinkGet(skylink + "?retries=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("unexpected data")
+	}
+
+	// A skylink which doesn't exist should still fail after retrying.
+	unknown, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetSkylinkGet(unknown.String() + "?retries=1&timeout=1")
+	if err == nil {
+		t.Fatal("expected download to fail")
+	}
+
+	// Too many retries should be rejected.
+	_, err = r.SkynetSkylinkGet(skylink + "?retries=100")
+	if err == nil || !strings.Contains(err.Error(), "'retries' parameter can't be greater than") {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testSkynetDisableForce verifies the behavior of force and the header that
+// allows disabling forcefully uploading a Skyfile
+func testSkynetDisableForce(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload Skyfile
+	_, sup, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload at same path without force, assert this fails
+	sup = skymodules.SkyfileUploadParameters{
+		Filename: t.Name(),
+		Reader:   bytes.NewReader([]byte{1, 2, 3}),
+		SiaPath:  sup.SiaPath,
+		Force:    false,
+	}
+	_, _, err = r.SkynetSkyfilePost(sup)
+	if err == nil {
+		t.Fatal("Expected the upload without force to fail but it didn't.")
+	}
+	if !strings.Contains(err.Error(), "already exists") {
+		t.Fatal(err)
+	}
+
+	// Upload once more, but now use force. It should allow us to
+	// overwrite the file at the existing path
+	sup.Force = true
+	_, _, err = r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload using the force flag again, however now we set the
+	// Skynet-Disable-Force to true, which should prevent us from uploading.
+	// Because we have to pass in a custom header, we have to setup the request
+	// ourselves and can not use the client.
+	_, _, err = r.SkynetSkyfilePostDisableForce(sup, true)
+	if err == nil {
+		t.Fatal("Unexpected response")
+	}
+	if !strings.Contains(err.Error(), "'force' has been disabled") {
+		t.Log(err)
+		t.Fatalf("Unexpected response, expected error to contain a mention of the force flag but instaed received: %v", err.Error())
+	}
+
+	// A force token is rejected as long as no force token key is configured.
+	sk, pk := crypto.GenerateKeyPair()
+	siaPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().Add(time.Hour).Unix()
+	token := skymodules.NewSkynetForceToken(siaPath, expiry, sk)
+	_, _, err = r.SkynetSkyfilePostWithForceToken(sup, token)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidSkynetForceToken.Error()) {
+		t.Fatal("expected the force token to be rejected", err)
+	}
+
+	// Configure the force token key.
+	err = r.RenterSkynetForceTokenKeyPost(types.Ed25519PublicKey(pk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.RenterSkynetForceTokenKeyPost(types.SiaPublicKey{}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rg.Settings.SkynetForceTokenKey.Equals(types.Ed25519PublicKey(pk)) {
+		t.Fatal("unexpected force token key", rg.Settings.SkynetForceTokenKey)
+	}
+
+	// Now the token allows overwriting the file.
+	_, _, err = r.SkynetSkyfilePostWithForceToken(sup, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens for another siapath, expired tokens and tokens signed by another
+	// key are rejected.
+	otherSK, _ := crypto.GenerateKeyPair()
+	invalidTokens := []skymodules.SkynetForceToken{
+		skymodules.NewSkynetForceToken(skymodules.RandomSiaPath(), expiry, sk),
+		skymodules.NewSkynetForceToken(siaPath, time.Now().Add(-time.Minute).Unix(), sk),
+		skymodules.NewSkynetForceToken(siaPath, expiry, otherSK),
+	}
+	for _, invalid := range invalidTokens {
+		_, _, err = r.SkynetSkyfilePostWithForceToken(sup, invalid)
+		if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidSkynetForceToken.Error()) {
+			t.Fatal("expected the force token to be rejected", err)
+		}
+	}
+
+	// Force tokens work for pins too.
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("forcetokenpin", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spp := skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	}
+	_, err = r.SkynetSkylinkPinPost(skylink, spp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spp.Force = true
+	pinSiaPath, err := skymodules.SkynetFolder.Join(spp.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetSkylinkPinPostWithForceToken(skylink, spp, skymodules.NewSkynetForceToken(spp.SiaPath, expiry, sk))
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidSkynetForceToken.Error()) {
+		t.Fatal("expected the force token to be rejected", err)
+	}
+	_, err = r.SkynetSkylinkPinPostWithForceToken(skylink, spp, skymodules.NewSkynetForceToken(pinSiaPath, expiry, sk))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkynetCanonicalize verifies that the /skynet/canonicalize endpoint
+// returns the same canonical skylink for different representations of it.
+func testSkynetCanonicalize(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := sl.String()
+
+	inputs := []string{
+		canonical,
+		canonical + "/",
+		skymodules.SkylinkScheme + canonical,
+		canonical + "/foo/bar?foo=bar",
+		sl.Base32EncodedString(),
+	}
+	for _, input := range inputs {
+		sc, err := r.SkynetCanonicalizeGet(input)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", input, err)
+		}
+		if sc.Skylink != canonical {
+			t.Fatalf("%v: expected %v but got %v", input, canonical, sc.Skylink)
+		}
+	}
+
+	// An invalid skylink should be rejected.
+	_, err = r.SkynetCanonicalizeGet(canonical[1:])
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrMalformedSkylink.Error()) {
+		t.Fatal("expected ErrMalformedSkylink", err)
+	}
+}
+
+// testSkynetTwoPhaseUpload verifies that the data of a skyfile can be uploaded
+// before its metadata.
+func testSkynetTwoPhaseUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload data that doesn't fit into the base sector.
+	data := fastrand.Bytes(int(modules.SectorSize) + 100)
+	siaPath, err := skymodules.SkynetFolder.Join(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin, err := r.SkynetUploadBeginPost(skymodules.SkyfileUploadParameters{SiaPath: siaPath}, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Finalizing the upload with invalid metadata should fail without
+	// dropping the pending upload.
+	md := skymodules.SkyfileMetadata{
+		Filename: "file",
+		Subfiles: skymodules.SkyfileSubfiles{
+			"a": skymodules.SkyfileSubfileMetadata{Filename: "a", Len: 1},
+		},
+	}
+	_, err = r.SkynetUploadFinalizePost(begin.ID, md)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrInvalidMetadata.Error()) {
+		t.Fatal("expected ErrInvalidMetadata", err)
+	}
+
+	// Finalize the upload with two subfiles.
+	md.Subfiles = skymodules.SkyfileSubfiles{
+		"a": skymodules.SkyfileSubfileMetadata{Filename: "a", Len: 100},
+		"b": skymodules.SkyfileSubfileMetadata{Filename: "b", Offset: 100, Len: modules.SectorSize},
+	}
+	md.DefaultPath = "a"
+	rshp, err := r.SkynetUploadFinalizePost(begin.ID, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pending upload is gone after finalizing it.
+	_, err = r.SkynetUploadFinalizePost(begin.ID, md)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkyfilePendingUploadNotFound.Error()) {
+		t.Fatal("expected ErrSkyfilePendingUploadNotFound", err)
+	}
+
+	// Check the metadata.
+	_, sm, err := r.SkynetMetadataGet(rshp.Skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.Filename != md.Filename || sm.Length != uint64(len(data)) || sm.DefaultPath != "/a" || len(sm.Subfiles) != 2 {
+		t.Fatal("unexpected metadata", sm)
+	}
+
+	// Check the data.
+	downloaded, err := r.SkynetSkylinkGet(rshp.Skylink + "/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data[100:]) {
+		t.Fatal("data mismatch")
+	}
+	downloaded, err = r.SkynetSkylinkGet(rshp.Skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data[:100]) {
+		t.Fatal("data mismatch")
+	}
+
+	// Both the base sector and the fanout should have been uploaded to the
+	// siapath.
+	_, err = r.RenterFileRootGet(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extendedSiaPath, err := siaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(extendedSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkynetUploadIfExists verifies that uploads with 'ifexists=return' return
+// the skylink of an existing skyfile with the same content.
+func testSkynetUploadIfExists(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile without specifying a modtime.
+	data := fastrand.Bytes(100)
+	siaPath, err := skymodules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := skymodules.SkyfileUploadParameters{
+		Filename: t.Name(),
+		Reader:   bytes.NewReader(data),
+		SiaPath:  siaPath,
+	}
+	skylink, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Uploading the same content again should return the existing skylink
+	// even though no modtime was specified.
+	sup.Reader = bytes.NewReader(data)
+	existing, _, err := r.SkynetSkyfilePostIfExists(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing != skylink {
+		t.Fatalf("expected skylink %v but got %v", skylink, existing)
+	}
+
+	// Uploading different content should fail.
+	sup.Reader = bytes.NewReader(fastrand.Bytes(100))
+	_, _, err = r.SkynetSkyfilePostIfExists(sup)
+	if err == nil || !strings.Contains(err.Error(), "with different content") {
+		t.Fatal("expected upload with different content to fail", err)
+	}
+
+	// checkSkylinks is a helper to check the skylinks of the skyfile at the
+	// given siapath.
+	checkSkylinks := func(siaPath skymodules.SiaPath, skylink string) {
+		skyfilePath, err := skymodules.SkynetFolder.Join(siaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rf, err := r.RenterFileRootGet(skyfilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rf.File.Skylinks) != 1 || rf.File.Skylinks[0] != skylink {
+			t.Fatal("unexpected skylinks", rf.File.Skylinks, skylink)
+		}
+	}
+
+	// The existing skyfile should be unchanged.
+	checkSkylinks(sup.SiaPath, skylink)
+
+	// Uploading to a new siapath should upload the file.
+	sup.SiaPath, err = skymodules.NewSiaPath(t.Name() + "_new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup.Reader = bytes.NewReader(data)
+	newSkylink, _, err := r.SkynetSkyfilePostIfExists(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSkylinks(sup.SiaPath, newSkylink)
+}
+
+// TestSkynetDownloadStats is a test that verifies whether overdrive downloads
+// base sectors and fanout sectors are properly reflected in the stats. This is
+// separate test using a custom dependency because this was causing an NDF in
+// the TestSkynetStats test.
+func TestSkynetDownloadStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	groupDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Define a portal with a dependency that forces overdrive on downloads
+	renterParams := node.Renter(filepath.Join(groupDir, t.Name()))
+	renterParams.CreatePortal = true
+	deps := dependencies.NewDependencyOverdriveDownload()
+	renterParams.RenterDeps = deps
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Check all stats are 0 still
+	ss, err := r.SkynetStatsGet()
+	if ss.BaseSectorOverdriveAvg != 0 {
+		t.Fatal(err, ss.BaseSectorOverdriveAvg)
+	}
+	if ss.BaseSectorOverdrivePct != 0 {
+		t.Fatal(err, ss.BaseSectorOverdrivePct)
+	}
+	if ss.FanoutSectorOverdriveAvg != 0 {
+		t.Fatal(err, ss.FanoutSectorOverdriveAvg)
+	}
+	if ss.FanoutSectorOverdrivePct != 0 {
+		t.Fatal(err, ss.FanoutSectorOverdrivePct)
+	}
+
+	// Upload a small and large file with N-M redundancy
+	skylinkSmall, _, _, err := r.UploadNewSkyfileBlocking("small", modules.SectorSize/2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, remoteFile, err := r.UploadNewFileBlocking(int(modules.SectorSize)*2, 2, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	}
+	rhsp, err := r.SkynetConvertSiafileToSkyfilePost(sup, remoteFile.SiaPath())
+	if err != nil {
+		t.Fatal("Expected conversion from Siafile to Skyfile Post to succeed.")
+	}
+	skylinkLarge := rhsp.Skylink
+
+	// Enable the dependency
+	deps.Enable()
+
+	// Download both skylinks
+	_, err = r.SkynetSkylinkGet(skylinkSmall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetSkylinkGet(skylinkLarge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check all download stats are not 0
+	ss, err = r.SkynetStatsGet()
+	if ss.BaseSectorOverdriveAvg == 0 {
+		t.Fatal(err, ss.BaseSectorOverdriveAvg)
+	}
+	if ss.BaseSectorOverdrivePct == 0 {
+		t.Fatal(err, ss.BaseSectorOverdrivePct)
+	}
+	if ss.FanoutSectorOverdriveAvg == 0 {
+		t.Fatal(err, ss.FanoutSectorOverdriveAvg)
+	}
+	if ss.FanoutSectorOverdrivePct == 0 {
+		t.Fatal(err, ss.FanoutSectorOverdrivePct)
+	}
+}
+
+// TestSkynetBlocklist verifies the functionality of the Skynet blocklist.
+func TestSkynetBlocklist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	groupDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Define a portal with dependency
+	portalDir := filepath.Join(groupDir, "portal")
+	portalParams := node.Renter(portalDir)
+	portalParams.CreatePortal = true
+	deps := &dependencies.DependencyToggleDisableDeleteBlockedFiles{}
+	portalParams.RenterDeps = deps
+	_, err = tg.AddNodes(portalParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run subtests
+	t.Run("BlocklistHash", func(t *testing.T) {
+		testSkynetBlocklistHash(t, tg, deps)
+	})
+	t.Run("BlocklistSkylinkV1", func(t *testing.T) {
+		testSkynetBlocklistSkylink(t, tg, deps, false)
+	})
+	t.Run("BlocklistSkylinkV2", func(t *testing.T) {
+		testSkynetBlocklistSkylink(t, tg, deps, true)
+	})
+	t.Run("BlocklistUpgrade", func(t *testing.T) {
+		testSkynetBlocklistUpgrade(t, tg)
+	})
+}
+
+// testSkynetBlocklistHash tests the skynet blocklist module when submitting
+// hashes of the skylink's merkleroot
+func testSkynetBlocklistHash(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles) {
+	testSkynetBlocklist(t, tg, deps, true, false)
+}
+
+// testSkynetBlocklistSkylink tests the skynet blocklist module when submitting
+// skylinks
+func testSkynetBlocklistSkylink(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles, isV2Skylink bool) {
+	testSkynetBlocklist(t, tg, deps, false, isV2Skylink)
+}
+
+// testSkynetBlocklist tests the skynet blocklist module
+func testSkynetBlocklist(t *testing.T, tg *siatest.TestGroup, deps *dependencies.DependencyToggleDisableDeleteBlockedFiles, isHash, isV2Skylink bool) {
+	r := tg.Renters()[0]
+	deps.DisableDeleteBlockedFiles(true)
+
+	// Create skyfile upload params, data should be larger than a sector size to
+	// test large file uploads and the deletion of their extended data.
+	size := modules.SectorSize + uint64(100+siatest.Fuzz())
+	skylink, sup, sshp, err := r.UploadNewSkyfileBlocking(t.Name(), size, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert to V2 Skylink
+	if isV2Skylink {
+		skylinkV2, err := r.NewSkylinkV2FromString(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skylink = skylinkV2.Skylink.String()
+	}
+
+	// Remember the siaPaths of the blocked files
+	var blockedSiaPaths []skymodules.SiaPath
+
+	// Confirm that the skyfile and its extended info are registered with the
+	// renter
+	sp, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spExtended, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(spExtended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockedSiaPaths = append(blockedSiaPaths, sp, spExtended)
+
+	// Download the data
+	data, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocklist the skylink
+	var add, remove []string
+	hash := crypto.HashObject(sshp.MerkleRoot)
+	if isHash {
+		add = []string{hash.String()}
+	} else {
+		add = []string{skylink}
+	}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm that the Skylink is blocked by verifying the hash of the V1
+	// skylink is in the blocklist
+	sbg, err := r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, blocked := range sbg.Blocklist {
+		if blocked == hash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("Hash not found in blocklist")
+	}
+
+	// Try to download the file behind the skylink, this should fail because of
+	// the blocklist.
+	_, err = r.SkynetSkylinkGet(skylink)
+	if err == nil {
+		t.Fatal("Download should have failed")
+	}
+	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// Try to download the BaseSector
+	_, err = r.SkynetBaseSectorGet(skylink)
+	if err == nil {
+		t.Fatal("BaseSector request should have failed")
+	}
+	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// Try to download the BaseSector by Root
+	_, err = r.SkynetDownloadByRootGet(sshp.MerkleRoot, 0, modules.SectorSize, -1)
+	if err == nil {
+		t.Fatal("DownloadByRoot request should have failed")
+	}
+	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// The three download attempts should have been logged as blocklist hits.
+	sbhg, err := r.SkynetBlocklistHitsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbhg.Hits) < 3 {
+		t.Fatalf("Expected at least 3 hits but got %v", len(sbhg.Hits))
+	}
+	hits := sbhg.Hits[len(sbhg.Hits)-3:]
+	for i, hit := range hits {
+		if hit.Hash != hash {
+			t.Fatalf("hit %v: expected hash %v but got %v", i, hash, hit.Hash)
+		}
+		if hit.Timestamp.IsZero() {
+			t.Fatalf("hit %v: timestamp not set", i)
+		}
+		if ip := net.ParseIP(hit.Requester); ip == nil || !ip.IsLoopback() {
+			t.Fatalf("hit %v: expected loopback requester but got %v", i, hit.Requester)
+		}
+	}
+	if hits[0].Skylink != skylink || hits[1].Skylink != skylink {
+		t.Fatalf("Expected skylink %v but got %v and %v", skylink, hits[0].Skylink, hits[1].Skylink)
+	}
+	if hits[2].Skylink != "" {
+		t.Fatalf("Expected no skylink for download by root but got %v", hits[2].Skylink)
+	}
+
+	// The hits contain the IPs of requesters so they require authentication.
+	c := r.Client
+	c.Password = "wrong"
+	_, err = c.SkynetBlocklistHitsGet()
+	if err == nil || !strings.Contains(err.Error(), "API authentication failed") {
+		t.Fatal("expected unauthenticated request to fail", err)
+	}
+
+	// Try and upload again with force as true to avoid error of path already
+	// existing. Additionally need to recreate the reader again from the file
+	// data. This should also fail due to the blocklist
+	sup.Force = true
+	sup.Reader = bytes.NewReader(data)
+	_, _, err = r.SkynetSkyfilePost(sup)
+	if err == nil {
+		t.Fatal("Expected upload to fail")
+	}
+	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// Verify that the SiaPath and Extended SiaPath were removed from the renter
+	// due to the upload seeing the blocklist
+	_, err = r.RenterFileGet(sp)
+	if err == nil {
+		t.Fatal("expected error for file not found")
+	}
+	if !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	}
+	_, err = r.RenterFileGet(spExtended)
+	if err == nil {
+		t.Fatal("expected error for file not found")
+	}
+	if !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	}
+
+	// Try Pinning the file, this should fail due to the blocklist
+	pinlup := skymodules.SkyfilePinParameters{
+		SiaPath:             sup.SiaPath,
+		BaseChunkRedundancy: 2,
+		Force:               true,
+	}
+	// Pinning is only supported for V1 Skylink
+	if !isV2Skylink {
+		_, err = r.SkynetSkylinkPinPost(skylink, pinlup)
+		if err == nil {
+			t.Fatal("Expected pin to fail")
+		}
+		if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+			t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+		}
+	}
+
+	// Remove skylink from blocklist
+	add = []string{}
+	if isHash {
+		remove = []string{hash.String()}
+	} else {
+		remove = []string{skylink}
+	}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that removing the same skylink twice is a noop
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that the skylink is removed from the Blocklist
+	sbg, err = r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 0 {
+		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+	}
+
+	// Try to download the file behind the skylink. Even though the file was
+	// removed from the renter node that uploaded it, it should still be
+	// downloadable.
+	fetchedData, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedData, data) {
+		t.Error("upload and download doesn't match")
+		t.Log(data)
+		t.Log(fetchedData)
+	}
+
+	// Pinning is only supported for V1 Skylink
+	if !isV2Skylink {
+		// Pinning the skylink should also work now
+		_, err = r.SkynetSkylinkPinPost(skylink, pinlup)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Upload a normal siafile with 1-of-N redundancy
+	_, rf, err := r.UploadNewFileBlocking(int(size), 1, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert to a skyfile
+	convertUP := skymodules.SkyfileUploadParameters{
+		SiaPath: rf.SiaPath(),
+	}
+	convertSSHP, err := r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	convertSkylink := convertSSHP.Skylink
+
+	// Converting the file again only results in the same skylink with the
+	// modtime of the first conversion.
+	convertUP.ModTime = convertSSHP.ModTime
+
+	// Convert to V2 Skylink
+	if isV2Skylink {
+		skylinkV2, err := r.NewSkylinkV2FromString(convertSkylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		convertSkylink = skylinkV2.Skylink.String()
+	}
+
+	// Confirm there is a siafile and a skyfile
+	_, err = r.RenterFileGet(rf.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	skyfilePath, err := skymodules.SkynetFolder.Join(rf.SiaPath().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(skyfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// For V2 Skylinks reset the blocklist to account for pinning not being supported for V2 Skylinks.
+	if isV2Skylink {
+		blockedSiaPaths = []skymodules.SiaPath{}
+	}
+
+	// Make sure all blockedSiaPaths are root paths
+	sp, err = skymodules.UserFolder.Join(rf.SiaPath().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockedSiaPaths = append(blockedSiaPaths, sp, skyfilePath)
+
+	// Blocklist the skylink
+	remove = []string{}
+	convertHash := crypto.HashObject(convertSSHP.MerkleRoot)
+	if isHash {
+		add = []string{convertHash.String()}
+	} else {
+		add = []string{convertSkylink}
+	}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that adding the same skylink twice is a noop
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err = r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 1 {
+		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 1, len(sbg.Blocklist))
+	}
+
+	// Confirm skyfile download returns blocklisted error
+	//
+	// NOTE: Calling DownloadSkylink doesn't attempt to delete any underlying file
+	_, err = r.SkynetSkylinkGet(convertSkylink)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// Try and convert to skylink again, should fail. Set the Force Flag to true
+	// to avoid error for file already existing
+	convertUP.Force = true
+	_, err = r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatalf("Expected error %v but got %v", renter.ErrSkylinkBlocked, err)
+	}
+
+	// This should delete the skyfile but not the siafile
+	_, err = r.RenterFileGet(rf.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(skyfilePath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatalf("Expected error %v but got %v", filesystem.ErrNotExist, err)
+	}
+
+	// remove from blocklist
+	add = []string{}
+	if isHash {
+		remove = []string{convertHash.String()}
+	} else {
+		remove = []string{convertSkylink}
+	}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err = r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 0 {
+		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+	}
+
+	// Convert should succeed
+	_, err = r.SkynetConvertSiafileToSkyfilePost(convertUP, rf.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(skyfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding links to the block list does not immediately delete the files, but
+	// the health/bubble loops should eventually delete the files.
+	//
+	// First verify the test assumptions and confirm that the files still exist
+	// in the renter.
+	for _, siaPath := range blockedSiaPaths {
+		_, err = r.RenterFileRootGet(siaPath)
+		if err != nil {
+			t.Log(siaPath)
+			t.Fatal(err)
+		}
+	}
+
+	// Disable the dependency
+	deps.DisableDeleteBlockedFiles(false)
+
+	// Add both skylinks back to the blocklist
+	remove = []string{}
+	if isHash {
+		add = []string{hash.String(), convertHash.String()}
+	} else {
+		add = []string{skylink, convertSkylink}
+	}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err = r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 2 {
+		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 2, len(sbg.Blocklist))
+	}
+
+	// Wait until all the files have been deleted
+	//
+	// Using 15 checks at 1 second intervals because the health loop check
+	// interval in testing is 5s and there are potential error sleeps of 3s.
+	if err := build.Retry(15, time.Second, func() error {
+		for _, siaPath := range blockedSiaPaths {
+			_, err = r.RenterFileRootGet(siaPath)
+			if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+				return fmt.Errorf("File %v, not deleted; error: %v", siaPath, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	// Reset the blocklist for other tests
+	remove = add
+	add = []string{}
+	err = r.SkynetBlocklistHashPost(add, remove, isHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sbg, err = r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 0 {
+		t.Fatalf("Incorrect number of blocklisted merkleroots, expected %v got %v", 0, len(sbg.Blocklist))
+	}
+}
+
+// testSkynetBlocklistUpgrade tests the skynet blocklist module when submitting
+// skylinks
+func testSkynetBlocklistUpgrade(t *testing.T, tg *siatest.TestGroup) {
+	// Create renterDir and renter params
+	testDir := skynetTestDir(t.Name())
+	renterDir := filepath.Join(testDir, "renter")
+	err := os.MkdirAll(renterDir, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := node.Renter(testDir)
+
+	// Load compatibility blacklist persistence
+	blacklistCompatFile, err := os.Open("../../compatibility/skynetblacklistv143_siatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blacklistPersist, err := os.Create(filepath.Join(renterDir, "skynetblacklist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(blacklistPersist, blacklistCompatFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = errors.Compose(blacklistCompatFile.Close(), blacklistPersist.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Grab the Skylink that is associated with the blacklist persistence
+	skylinkFile, err := os.Open("../../compatibility/skylinkv143_siatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(skylinkFile)
+	scanner.Scan()
+	skylinkStr := scanner.Text()
+	var skylink skymodules.Skylink
+	err = skylink.LoadString(skylinkStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add the renter to the group.
+	nodes, err := tg.AddNodes(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Verify there is a skylink in the now blocklist and it is the one from the
+	// compatibility file
+	sbg, err := r.SkynetBlocklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blocklist) != 1 {
+		t.Fatal("blocklist should have 1 link, found:", len(sbg.Blocklist))
+	}
+	hash := crypto.HashObject(skylink.MerkleRoot())
+	if sbg.Blocklist[0] != hash {
+		t.Fatal("unexpected hash")
+	}
+	if len(sbg.Blacklist) != 0 {
+		t.Fatal("blacklist should only be populated for legacy requests")
+	}
+
+	// Verify the legacy blacklist is populated when requested.
+	sbg, err = r.SkynetBlocklistCompatGet(api.BlocklistCompatV143)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sbg.Blacklist) != 1 || sbg.Blacklist[0] != hash {
+		t.Fatal("unexpected blacklist", sbg.Blacklist)
+	}
+	if len(sbg.Blocklist) != 1 || sbg.Blocklist[0] != hash {
+		t.Fatal("unexpected blocklist", sbg.Blocklist)
+	}
+	_, err = r.SkynetBlocklistCompatGet("v999")
+	if err == nil || !strings.Contains(err.Error(), "unknown compat version") {
+		t.Fatal("expected unknown compat version error, got", err)
+	}
+
+	// Verify the deprecated endpoint serves the legacy shape.
+	header, sbg, err := r.SkynetBlacklistGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Deprecation") != "true" {
+		t.Fatal("missing deprecation header")
+	}
+	if len(sbg.Blacklist) != 1 || sbg.Blacklist[0] != hash {
+		t.Fatal("unexpected blacklist", sbg.Blacklist)
+	}
+	if len(sbg.Blocklist) != 1 || sbg.Blocklist[0] != hash {
+		t.Fatal("unexpected blocklist", sbg.Blocklist)
+	}
+
+	// Verify trying to download the skylink fails due to it being blocked
+	//
+	// NOTE: It doesn't matter if there is a file associated with this Skylink
+	// since the blocklist check should cause the download to fail before any look
+	// ups occur.
+	_, err = r.SkynetSkylinkGet(skylinkStr)
+	if !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+// testSkynetPortals tests the skynet portals module.
+func testSkynetPortals(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	portal1 := skymodules.SkynetPortal{
+		Address: modules.NetAddress("siasky.net:9980"),
+		Public:  true,
+	}
+	// loopback address
+	portal2 := skymodules.SkynetPortal{
+		Address: "localhost:9980",
+		Public:  true,
+	}
+	// address without a port
+	portal3 := skymodules.SkynetPortal{
+		Address: modules.NetAddress("siasky.net"),
+		Public:  true,
+	}
+
+	// Add portal.
+	add := []skymodules.SkynetPortal{portal1}
+	remove := []modules.NetAddress{}
+	err := r.SkynetPortalsPost(add, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm that the portal has been added.
+	spg, err := r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 1 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
+	}
+	if !reflect.DeepEqual(spg.Portals[0], portal1) {
+		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+	}
+
+	// Remove the portal.
+	add = []skymodules.SkynetPortal{}
+	remove = []modules.NetAddress{portal1.Address}
+	err = r.SkynetPortalsPost(add, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm that the portal has been removed.
+	spg, err = r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 0 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 0, len(spg.Portals))
+	}
+
+	// Try removing a portal that's not there.
+	add = []skymodules.SkynetPortal{}
+	remove = []modules.NetAddress{portal1.Address}
+	err = r.SkynetPortalsPost(add, remove)
+	if err == nil || !strings.Contains(err.Error(), "address "+string(portal1.Address)+" not already present in list of portals or being added") {
+		t.Fatal("portal should fail to be removed")
+	}
+
+	// Try to add and remove a portal at the same time.
+	add = []skymodules.SkynetPortal{portal2}
+	remove = []modules.NetAddress{portal2.Address}
+	err = r.SkynetPortalsPost(add, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify that the portal was not added.
+	spg, err = r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 0 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 0, len(spg.Portals))
+	}
+
+	// Test updating a portal's public status.
+	portal1.Public = false
+	add = []skymodules.SkynetPortal{portal1}
+	remove = []modules.NetAddress{}
+	err = r.SkynetPortalsPost(add, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spg, err = r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 1 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
+	}
+	if !reflect.DeepEqual(spg.Portals[0], portal1) {
+		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+	}
+
+	portal1.Public = true
+	add = []skymodules.SkynetPortal{portal1}
+	remove = []modules.NetAddress{}
+	err = r.SkynetPortalsPost(add, remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spg, err = r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 1 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 1, len(spg.Portals))
+	}
+	if !reflect.DeepEqual(spg.Portals[0], portal1) {
+		t.Fatalf("Portals don't match, expected %v got %v", portal1, spg.Portals[0])
+	}
+
+	// Test an invalid network address.
+	add = []skymodules.SkynetPortal{portal3}
+	remove = []modules.NetAddress{}
+	err = r.SkynetPortalsPost(add, remove)
+	if err == nil || !strings.Contains(err.Error(), "missing port in address") {
+		t.Fatal("expected 'missing port' error")
+	}
+
+	// Test adding an existing portal with an uppercase address.
+	portalUpper := portal1
+	portalUpper.Address = modules.NetAddress(strings.ToUpper(string(portalUpper.Address)))
+	add = []skymodules.SkynetPortal{portalUpper}
+	remove = []modules.NetAddress{}
+	err = r.SkynetPortalsPost(add, remove)
+	// This does not currently return an error.
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spg, err = r.SkynetPortalsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spg.Portals) != 2 {
+		t.Fatalf("Incorrect number of portals, expected %v got %v", 2, len(spg.Portals))
+	}
+}
+
+// testSkynetIncludeLayout verifies the functionality of sending
+// a 'include-layout' query string parameter to the skylink GET route.
+func testSkynetIncludeLayout(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile
+	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GET without specifying the 'include-layout' query string parameter
+	_, layout, err := r.SkynetSkylinkGetWithLayout(skylink, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(layout, skymodules.SkyfileLayout{}) {
+		t.Fatal("unexpected")
+	}
+
+	// GET with specifying the 'include-layout' query string parameter
+	_, layout, err = r.SkynetSkylinkGetWithLayout(skylink, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(layout, skymodules.SkyfileLayout{}) {
+		t.Fatal("unexpected")
+	}
+
+	// Perform a HEAD call to verify the same thing in the headers directly
+	params := url.Values{}
+	params.Set("include-layout", fmt.Sprintf("%t", true))
+	status, header, err := r.SkynetSkylinkHeadWithParameters(skylink, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("Unexpected status for HEAD request, expected %v but received %v", http.StatusOK, status)
+	}
+
+	strSkynetFileLayout := header.Get(api.SkynetFileLayoutHeader)
+	if strSkynetFileLayout == "" {
+		t.Fatal("unexpected")
+	}
+	var layout2 skymodules.SkyfileLayout
+	layoutBytes, err := hex.DecodeString(strSkynetFileLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout2.Decode(layoutBytes)
+	if !reflect.DeepEqual(layout, layout2) {
+		t.Fatal("unexpected")
+	}
+}
+
+// testSkynetUploadIncludeMetadata tests that uploads with 'include-metadata'
+// return the same metadata and layout that downloads of the skyfile report.
+func testSkynetUploadIncludeMetadata(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	numHosts := len(tg.Hosts())
+
+	// checkResponse is a helper that compares the metadata and layout of an
+	// upload response to the ones of the uploaded skyfile.
+	checkResponse := func(sshp api.SkynetSkyfileHandlerPOST) {
+		_, md, err := r.SkynetMetadataGet(sshp.Skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*sshp.Metadata, md) {
+			t.Fatalf("metadata mismatch\n%+v\n%+v", *sshp.Metadata, md)
+		}
+		_, layout, err := r.SkynetSkylinkGetWithLayout(sshp.Skylink, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := api.SkyfileLayoutSummary{
+			CipherType:         layout.CipherType.String(),
+			FanoutDataPieces:   layout.FanoutDataPieces,
+			FanoutParityPieces: layout.FanoutParityPieces,
+			FanoutSize:         layout.FanoutSize,
+			Filesize:           layout.Filesize,
+			MetadataSize:       layout.MetadataSize,
+		}
+		if *sshp.Layout != expected {
+			t.Fatalf("layout mismatch\n%+v\n%+v", *sshp.Layout, expected)
+		}
+	}
+
+	// Upload a small skyfile.
+	data := fastrand.Bytes(100)
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:  skymodules.RandomSiaPath(),
+		Filename: "small",
+		Mode:     0640,
+		Reader:   bytes.NewReader(data),
+	}
+	sshp, err := r.SkynetSkyfilePostWithMetadata(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshp.Metadata.Filename != "small" || sshp.Metadata.Length != uint64(len(data)) || sshp.Metadata.ModTime == 0 {
+		t.Fatal("unexpected metadata", sshp.Metadata)
+	}
+	if sshp.Layout.Filesize != uint64(len(data)) || sshp.Layout.FanoutSize != 0 {
+		t.Fatal("unexpected layout", sshp.Layout)
+	}
+	checkResponse(sshp)
+
+	// Upload a large skyfile with a custom fanout erasure coding.
+	data = fastrand.Bytes(int(modules.SectorSize) + siatest.Fuzz())
+	sup = skymodules.SkyfileUploadParameters{
+		SiaPath:      skymodules.RandomSiaPath(),
+		Filename:     "large",
+		Reader:       bytes.NewReader(data),
+		DataPieces:   1,
+		ParityPieces: numHosts - 1,
+	}
+	sshp, err = r.SkynetSkyfilePostWithMetadata(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshp.Layout.Filesize != uint64(len(data)) || sshp.Layout.FanoutDataPieces != 1 || int(sshp.Layout.FanoutParityPieces) != numHosts-1 {
+		t.Fatal("unexpected layout", sshp.Layout)
+	}
+	checkResponse(sshp)
+
+	// Upload a multipart skyfile. The metadata contains the subfiles and the
+	// default path the node picked.
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	var offset uint64
+	for _, tf := range []siatest.TestFile{{Name: "index.html", Data: []byte("index")}, {Name: "about.html", Data: []byte("about")}} {
+		_, err = skymodules.AddMultipartFile(writer, tf.Data, "files[]", tf.Name, skymodules.DefaultFilePerm, &offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	smup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:     skymodules.RandomSiaPath(),
+		Reader:      bytes.NewReader(body.Bytes()),
+		ContentType: writer.FormDataContentType(),
+		Filename:    "multipart",
+	}
+	sshp, err = r.SkynetSkyfileMultiPartPostWithMetadata(smup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sshp.Metadata.Subfiles) != 2 || sshp.Metadata.Subfiles["about.html"].Offset != 5 {
+		t.Fatal("unexpected subfiles", sshp.Metadata.Subfiles)
+	}
+	checkResponse(sshp)
+
+	// Uploads without 'include-metadata' don't contain the metadata.
+	sup.SiaPath = skymodules.RandomSiaPath()
+	sup.Reader = bytes.NewReader(data)
+	_, sshp, err = r.SkynetSkyfilePost(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sshp.Metadata != nil || sshp.Layout != nil {
+		t.Fatal("metadata shouldn't be included")
+	}
+}
+
+// testSkynetSymlinks verifies that symlinks and file modes of a multipart
+// upload are preserved when downloading it as a tar archive.
+func testSkynetSymlinks(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a directory with a file and a symlink pointing to it.
+	data := fastrand.Bytes(100)
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	var offset uint64
+	_, err1 := skymodules.AddMultipartFile(writer, data, "files[]", "dir/file", 0750, &offset)
+	_, err2 := skymodules.AddMultipartSymlink(writer, "files[]", "link", "dir/file", 0777, &offset)
+	if err := errors.Compose(err1, err2, writer.Close()); err != nil {
+		t.Fatal(err)
+	}
+	smup := skymodules.SkyfileMultipartUploadParameters{
+		SiaPath:     skymodules.RandomSiaPath(),
+		Reader:      bytes.NewReader(body.Bytes()),
+		ContentType: writer.FormDataContentType(),
+		Filename:    "symlinks",
+	}
+	skylink, _, err := r.SkynetSkyfileMultiPartPost(smup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The symlink is part of the metadata.
+	_, md, err := r.SkynetMetadataGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, ok := md.Subfiles["link"]; !ok || link.SymlinkTarget != "dir/file" || link.Len != 0 {
+		t.Fatal("unexpected symlink metadata", md.Subfiles)
+	}
+
+	// Download the directory as a tar archive.
+	_, reader, err := r.SkynetSkylinkTarReaderGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	tr := tar.NewReader(reader)
+	headers := make(map[string]*tar.Header)
+	for {
+		header, err := tr.Next()
+		if errors.Contains(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == "dir/file" {
+			fileData, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(fileData, data) {
+				t.Fatal("file data doesn't match")
+			}
+		}
+		headers[header.Name] = header
+	}
+	if len(headers) != 2 {
+		t.Fatal("unexpected number of entries", len(headers))
+	}
+	file, link := headers["dir/file"], headers["link"]
+	if file == nil || file.Typeflag != tar.TypeReg || file.Mode != 0750 {
+		t.Fatal("unexpected file header", file)
+	}
+	if link == nil || link.Typeflag != tar.TypeSymlink || link.Linkname != "dir/file" || link.Mode != 0777 || link.Size != 0 {
+		t.Fatal("unexpected symlink header", link)
+	}
+}
+
+// testSkynetNoWorkers verifies that SkynetSkylinkGet returns an error and does
+// not deadlock if there are no workers.
+func testSkynetNoWorkers(t *testing.T, tg *siatest.TestGroup) {
+	// Remember a renter with contracts before the renter without workers is
+	// added to the group.
+	portal := tg.Renters()[0]
+
+	// Create renter, skip setting the allowance so that we can ensure there are
+	// no contracts created and therefore no workers in the worker pool
+	testDir := skynetTestDir(t.Name())
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.SkipSetAllowance = true
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+	defer func() {
+		err = tg.RemoveNode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Since the renter doesn't have an allowance, we know the renter doesn't
+	// have any contracts and therefore the worker pool will be empty. Confirm
+	// that attempting to download a skylink will return an error and not dead
+	// lock.
+	skylink, err := skymodules.NewSkylinkV1(crypto.Hash{1, 2, 3}, 0, modules.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.SkynetSkylinkGet(skylink.String())
+	if err == nil {
+		t.Fatal("Error is nil, expected error due to not enough workers")
+	} else if !(strings.Contains(err.Error(), skymodules.ErrNotEnoughWorkersInWorkerPool.Error()) || strings.Contains(err.Error(), "not enough workers to complete download")) {
+		t.Errorf("Expected error containing '%v' but got %v", skymodules.ErrNotEnoughWorkersInWorkerPool, err)
+	}
+
+	// Even with degraded downloads enabled, the download can't proceed
+	// without any usable workers.
+	err = r.RenterSkynetDegradedDownloadsPost(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rg.Settings.SkynetDegradedDownloads {
+		t.Fatal("degraded downloads should be enabled")
+	}
+	_, err = r.SkynetSkylinkGet(skylink.String())
+	if err == nil || !strings.Contains(err.Error(), "no usable workers to complete download") {
+		t.Fatal("expected download to fail without usable workers", err)
+	}
+
+	// Without any workers, the node shouldn't retry the download internally.
+	status, header, err := r.SkynetSkylinkHead(skylink.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == http.StatusOK || header.Get(api.SkynetDownloadRetriesHeader) != "0" {
+		t.Fatal("unexpected response", status, header.Get(api.SkynetDownloadRetriesHeader))
+	}
+
+	// The skynet workers endpoint should report the empty worker pool.
+	swg, err := r.SkynetWorkersGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swg.NumWorkers != 0 || swg.NumDownloadWorkers != 0 || swg.NumUploadWorkers != 0 || len(swg.Workers) != 0 {
+		t.Fatal("expected no workers", swg)
+	}
+
+	// A renter with contracts should report usable workers.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		swg, err := portal.SkynetWorkersGet()
+		if err != nil {
+			return err
+		}
+		if swg.NumWorkers == 0 || swg.NumDownloadWorkers == 0 || swg.NumUploadWorkers == 0 {
+			return fmt.Errorf("expected usable workers, got %v", swg)
+		}
+		if len(swg.Workers) != swg.NumWorkers {
+			return fmt.Errorf("expected %v workers, got %v", swg.NumWorkers, len(swg.Workers))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the ETag of a skyfile from a renter with contracts. The format
+	// is specified since the skyfile doesn't need to be fetched to resolve it
+	// that way.
+	skylinkStr, _, _, err := portal.UploadNewSkyfileBlocking("noworkersetag", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(n *siatest.TestNode, link, eTag string) *http.Response {
+		resp, err := client.NewUnsafeClient(n.Client).SkynetSkylinkGetWithETag(link, eTag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	concatLink := skylinkStr + "?format=" + string(skymodules.SkyfileFormatConcat)
+	resp := get(portal, concatLink, "")
+	eTag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || eTag == "" {
+		t.Fatal("unexpected response", resp.StatusCode, eTag)
+	}
+
+	// The renter without workers can't download the skyfile but it can
+	// confirm that a caller's copy of a V1 skylink is up-to-date.
+	if resp := get(r, concatLink, ""); resp.StatusCode == http.StatusOK {
+		t.Fatal("download shouldn't succeed without workers")
+	}
+	resp = get(r, concatLink, eTag)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != eTag {
+		t.Fatal("unexpected response", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// A directory is downloaded as a zip archive if no format is specified.
+	// The portal answers the ETag of that download once it resolved the
+	// format and the renter without workers answers it for the explicitly
+	// requested zip archive.
+	files := []siatest.TestFile{
+		{Name: "a.txt", Data: []byte("a.txt_contents")},
+		{Name: "b.txt", Data: []byte("b.txt_contents")},
+	}
+	dirLink, _, _, err := portal.UploadNewMultipartSkyfileBlocking("noworkersetagdir", files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = get(portal, dirLink, "")
+	dirETag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || dirETag == "" {
+		t.Fatal("unexpected response", resp.StatusCode, dirETag)
+	}
+	resp = get(portal, dirLink, dirETag)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != dirETag {
+		t.Fatal("unexpected response", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	resp = get(r, dirLink+"?format="+string(skymodules.SkyfileFormatZip), dirETag)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != dirETag {
+		t.Fatal("unexpected response", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	// The default path of a skapp is resolved from its metadata so the
+	// renter without workers can't confirm the ETag of the served index.html.
+	// The portal can.
+	files = []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index.html_contents")},
+		{Name: "about.html", Data: []byte("about.html_contents")},
+	}
+	skappLink, _, _, err := portal.UploadNewMultipartSkyfileBlocking("noworkersetagskapp", files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skappLink += "/"
+	resp = get(portal, skappLink, "")
+	skappETag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || skappETag == "" {
+		t.Fatal("unexpected response", resp.StatusCode, skappETag)
+	}
+	resp = get(portal, skappLink, skappETag)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != skappETag {
+		t.Fatal("unexpected response", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp := get(r, skappLink, skappETag); resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusOK {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+
+	// Once the skylink is blocked, cached copies aren't confirmed anymore.
+	err = r.SkynetBlocklistPost([]string{skylinkStr}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(r, concatLink, eTag); resp.StatusCode != http.StatusUnavailableForLegalReasons {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+}
+
+// testSkynetDryRunUpload verifies the --dry-run flag when uploading a Skyfile.
+func testSkynetDryRunUpload(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	siaPath, err := skymodules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify basic skyfile upload
+	//
+	// NOTE: this ensure there's workers in the pool, if we remove this the test
+	// fails further down the line because there are no workers
+	_, _, err = r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		Filename:            "testSkynetDryRun",
+		Mode:                0640,
+		Reader:              bytes.NewReader(fastrand.Bytes(100)),
+	})
+	if err != nil {
+		t.Fatal("Expected skynet upload to be successful, instead received err:", err)
+	}
+
+	// verify you can't perform a dry-run using the force parameter
+	_, _, err = r.SkynetSkyfilePost(skymodules.SkyfileUploadParameters{
+		SiaPath:             siaPath,
+		BaseChunkRedundancy: 2,
+		Reader:              bytes.NewReader(fastrand.Bytes(100)),
+		Filename:            "testSkynetDryRun",
+		Mode:                0640,
+		Force:               true,
+		DryRun:              true,
+	})
+	if err == nil {
+		t.Fatal("Expected failure when both 'force' and 'dryrun' parameter are given")
+	}
+
+	verifyDryRun := func(sup skymodules.SkyfileUploadParameters, dataSize int) {
+		data := fastrand.Bytes(dataSize)
+
+		sup.DryRun = true
+		sup.Reader = bytes.NewReader(data)
+		skylinkDry, rshp, err := r.SkynetSkyfilePost(sup)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the dry run returns the modtime it used since the modtime is part
+		// of the skylink
+		if rshp.ModTime == 0 {
+			t.Fatal("dry run didn't return a modtime")
+		}
+		sup.ModTime = rshp.ModTime
+
+		// verify the skylink can't be found after a dry run
+		status, _, err := r.SkynetSkylinkHead(skylinkDry)
+		if status != http.StatusNotFound {
+			t.Fatal(fmt.Errorf("expected 404 not found when trying to fetch a skylink retrieved from a dry run, instead received status %d and err %v", status, err))
+		}
+
+		// verify the skfyile got deleted properly
+		skyfilePath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.RenterFileRootGet(skyfilePath)
+		if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+			t.Fatal(errors.New("skyfile not deleted after dry run"))
+		}
+
+		sup.DryRun = false
+		sup.Reader = bytes.NewReader(data)
+		skylink, _, err := r.SkynetSkyfilePost(sup)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if skylinkDry != skylink {
+			t.Log("Expected:", skylink)
+			t.Log("Actual:  ", skylinkDry)
+			t.Fatalf("VerifyDryRun failed for data size %db, skylink received during the dry-run is not identical to the skylink received when performing the actual upload.", dataSize)
+		}
+	}
+
+	// verify dry-run of small file
+	uploadSiaPath, err := skymodules.NewSiaPath(fmt.Sprintf("%s%s", t.Name(), "S"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyDryRun(skymodules.SkyfileUploadParameters{
+		SiaPath:             uploadSiaPath,
+		BaseChunkRedundancy: 2,
+		Filename:            "testSkynetDryRunUploadSmall",
+		Mode:                0640,
+	}, 100)
+
+	// verify dry-run of large file
+	uploadSiaPath, err = skymodules.NewSiaPath(fmt.Sprintf("%s%s", t.Name(), "L"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyDryRun(skymodules.SkyfileUploadParameters{
+		SiaPath:             uploadSiaPath,
+		BaseChunkRedundancy: 2,
+		Filename:            "testSkynetDryRunUploadLarge",
+		Mode:                0640,
+	}, int(modules.SectorSize*2)+siatest.Fuzz())
+}
+
+// testSkynetExpectContinue verifies that uploads with an 'Expect:
+// 100-continue' header are rejected before the body is sent if the upload
+// parameters are invalid.
+func testSkynetExpectContinue(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	siaPath, err := skymodules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:  siaPath,
+		Filename: "continue",
+		Reader:   bytes.NewReader(fastrand.Bytes(100)),
+	}
+
+	// A valid upload receives a '100 Continue'.
+	continued, _, err := r.SkynetSkyfilePostExpectContinue(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !continued {
+		t.Fatal("expected server to request the body")
+	}
+
+	// Uploading to the same siapath without force is rejected.
+	sup.Reader = bytes.NewReader(fastrand.Bytes(100))
+	continued, _, err = r.SkynetSkyfilePostExpectContinue(sup)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatal("unexpected error", err)
+	}
+	if continued {
+		t.Fatal("server shouldn't have requested the body")
+	}
+
+	// Forcing the upload works.
+	sup.Force = true
+	sup.Reader = bytes.NewReader(fastrand.Bytes(100))
+	continued, _, err = r.SkynetSkyfilePostExpectContinue(sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !continued {
+		t.Fatal("expected server to request the body")
+	}
+
+	// Uploading with an unknown skykey is rejected.
+	sup.Force = false
+	sup.SiaPath, err = skymodules.NewSiaPath(t.Name() + "-skykey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup.SkykeyName = "unknown"
+	sup.Reader = bytes.NewReader(fastrand.Bytes(100))
+	continued, _, err = r.SkynetSkyfilePostExpectContinue(sup)
+	if err == nil || !strings.Contains(err.Error(), "invalid 'skykeyname'") {
+		t.Fatal("unexpected error", err)
+	}
+	if continued {
+		t.Fatal("server shouldn't have requested the body")
+	}
+}
+
+// testSkynetSkylinkHint verifies that an upload can hint its skylink before it
+// is complete.
+func testSkynetSkylinkHint(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a small and a large file and request a hint.
+	for _, size := range []int{100, int(modules.SectorSize) + siatest.Fuzz()} {
+		siaPath, err := skymodules.NewSiaPath(fmt.Sprintf("%v-%v", t.Name(), size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hint, rshp, err := r.SkynetSkyfilePostWithHint(skymodules.SkyfileUploadParameters{
+			SiaPath:             siaPath,
+			BaseChunkRedundancy: 2,
+			Filename:            "hint",
+			Mode:                0640,
+			Reader:              bytes.NewReader(fastrand.Bytes(size)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hint == "" {
+			t.Fatal("no hint received", size)
+		}
+		if hint != rshp.Skylink {
+			t.Fatalf("hint doesn't match skylink: %v != %v", hint, rshp.Skylink)
+		}
+	}
+
+	// Dry-runs don't hint.
+	siaPath, err := skymodules.NewSiaPath(t.Name() + "-dryrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint, _, err := r.SkynetSkyfilePostWithHint(skymodules.SkyfileUploadParameters{
+		SiaPath:  siaPath,
+		DryRun:   true,
+		Filename: "hint",
+		Reader:   bytes.NewReader(fastrand.Bytes(100)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint != "" {
+		t.Fatal("dry-run shouldn't hint", hint)
+	}
+}
+
+// testSkynetRequestTimeout verifies that the Skylink routes timeout when a
+// timeout query string parameter has been passed.
+func testSkynetRequestTimeout(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile
+	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify we can pin it
+	pinSiaPath, err := skymodules.NewSiaPath(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinLUP := skymodules.SkyfilePinParameters{
+		SiaPath:             pinSiaPath,
+		Force:               true,
+		Root:                false,
+		BaseChunkRedundancy: 2,
+	}
+	_, err = r.SkynetSkylinkPinPost(skylink, pinLUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a renter with a timeout dependency injected
+	testDir := skynetTestDir(t.Name())
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.RenterDeps = &dependencies.DependencyTimeoutProjectDownloadByRoot{}
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = nodes[0]
+	defer func() {
+		if err := tg.RemoveNode(r); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Verify timeout on head request
+	status, _, err := r.SkynetSkylinkHeadWithTimeout(skylink, 1)
+	if status != http.StatusNotFound {
+		t.Fatalf("Expected http.StatusNotFound for random skylink but received %v", status)
+	}
+
+	// Verify timeout on download request
+	_, err = r.SkynetSkylinkGetWithTimeout(skylink, 1)
+	if errors.Contains(err, renter.ErrProjectTimedOut) {
+		t.Fatal("Expected download request to time out")
+	}
+	if !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Log(err)
+		t.Fatal("Expected error to specify the timeout")
+	}
+
+	// Verify timeout on pin request
+	_, err = r.SkynetSkylinkPinPostWithTimeout(skylink, pinLUP, 2*time.Second)
+	if errors.Contains(err, renter.ErrProjectTimedOut) {
+		t.Fatal("Expected pin request to time out")
+	}
+	if err == nil || !strings.Contains(err.Error(), "timed out after 2s") {
+		t.Log(err)
+		t.Fatal("Expected error to specify the timeout")
+	}
+
+	// The default timeout can't exceed the max timeout.
+	err = r.RenterSkynetRequestTimeoutsPost(2, 1)
+	if err == nil {
+		t.Fatal("Expected invalid timeouts to be rejected")
+	}
+	err = r.RenterSkynetRequestTimeoutsPost(0, 1)
+	if err == nil {
+		t.Fatal("Expected max timeout below the default timeout to be rejected")
+	}
+
+	// Lower the max timeout and verify it is enforced.
+	err = r.RenterSkynetRequestTimeoutsPost(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.Settings.SkynetDefaultRequestTimeout != 1 || rg.Settings.SkynetMaxRequestTimeout != 1 {
+		t.Fatal("timeouts weren't updated", rg.Settings)
+	}
+	_, err = r.SkynetSkylinkGetWithTimeout(skylink, 2)
+	if err == nil || !strings.Contains(err.Error(), "maximum allowed timeout is 1s") {
+		t.Fatal("Expected timeout above the configured max to be rejected", err)
+	}
+}
+
+// testRegressionTimeoutPanic is a regression test for a double channel close
+// which happened when a timeout was hit right before a download project was
+// resumed.
+func testRegressionTimeoutPanic(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a skyfile
+	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a renter with a BlockResumeJobDownloadUntilTimeout dependency.
+	testDir := skynetTestDir(t.Name())
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.RenterDeps = dependencies.NewDependencyBlockResumeJobDownloadUntilTimeout()
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = nodes[0]
+	defer func() {
+		if err := tg.RemoveNode(r); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Verify timeout on download request doesn't panic.
+	_, err = r.SkynetSkylinkGetWithTimeout(skylink, 1)
+	if errors.Contains(err, renter.ErrProjectTimedOut) {
+		t.Fatal("Expected download request to time out")
+	}
+}
+
+// testRenameSiaPath verifies that the siapath to the skyfile can be renamed.
+func testRenameSiaPath(t *testing.T, tg *siatest.TestGroup) {
+	// Grab Renter
+	r := tg.Renters()[0]
+
+	// Create a skyfile
+	skylink, sup, _, err := r.UploadNewSkyfileBlocking("testRenameFile", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath := sup.SiaPath
+
+	// Rename Skyfile with root set to false should fail
+	err = r.RenterRenamePost(siaPath, skymodules.RandomSiaPath(), false)
+	if err == nil {
+		t.Error("Rename should have failed if the root flag is false")
+	}
+	if err != nil && !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Errorf("Expected error to contain %v but got %v", filesystem.ErrNotExist, err)
+	}
+
+	// Rename Skyfile with root set to true should be successful
+	siaPath, err = skymodules.SkynetFolder.Join(siaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSiaPath, err := skymodules.SkynetFolder.Join(persist.RandomSuffix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.RenterRenamePost(siaPath, newSiaPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the skyfile can still be downloaded
+	_, err = r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkynetDefaultPath tests whether defaultPath metadata parameter works
+// correctly
+func testSkynetDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	// Specify subtests to run
+	subTests := []siatest.SubTest{
+		{Name: "HasIndexNoDefaultPath", Test: testHasIndexNoDefaultPath},
+		{Name: "HasIndexDisabledDefaultPath", Test: testHasIndexDisabledDefaultPath},
+		{Name: "HasIndexDifferentDefaultPath", Test: testHasIndexDifferentDefaultPath},
+		{Name: "HasIndexInvalidDefaultPath", Test: testHasIndexInvalidDefaultPath},
+		{Name: "NoIndexDifferentDefaultPath", Test: testNoIndexDifferentDefaultPath},
+		{Name: "NoIndexInvalidDefaultPath", Test: testNoIndexInvalidDefaultPath},
+		{Name: "NoIndexNoDefaultPath", Test: testNoIndexNoDefaultPath},
+		{Name: "NoIndexSingleFileDisabledDefaultPath", Test: testNoIndexSingleFileDisabledDefaultPath},
+		{Name: "NoIndexSingleFileNoDefaultPath", Test: testNoIndexSingleFileNoDefaultPath},
+	}
+
+	// Run subtests
+	for _, test := range subTests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Test(t, tg)
+		})
+	}
+}
+
+// checkDefaultPathHeaders is a helper which checks the headers describing how
+// the root of a skylink was resolved.
+func checkDefaultPathHeaders(t *testing.T, r *siatest.TestNode, skylink, resolved string, reason skymodules.DefaultPathReason) {
+	t.Helper()
+	_, header, err := r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := header.Get(api.SkynetDefaultPathResolvedHeader); path != resolved {
+		t.Fatalf("expected resolved path '%v', got '%v'", resolved, path)
+	}
+	if r := header.Get(api.SkynetDefaultPathReasonHeader); r != string(reason) {
+		t.Fatalf("expected reason '%v', got '%v'", reason, r)
+	}
+}
+
+// testHasIndexNoDefaultPath Contains index.html but doesn't specify a default
+// path (not disabled).
+// It should return the content of index.html.
+func testHasIndexNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	filename := "index.html_nil"
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	// Uploads without a defaultpath get index.html as their default tryfile.
+	checkDefaultPathHeaders(t, r, skylink, "/index.html", skymodules.DefaultPathReasonTryFiles)
+	content, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, files[0].Data) {
+		t.Fatalf("Expected to get content '%s', instead got '%s'", files[0].Data, string(content))
+	}
+}
+
+// testHasIndexDisabledDefaultPath Contains index.html but specifies an empty
+// default path (disabled).
+// It should not return an error and download the file as zip
+func testHasIndexDisabledDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	filename := "index.html_empty"
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", true, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	checkDefaultPathHeaders(t, r, skylink, "/", skymodules.DefaultPathReasonDisabled)
+	_, header, err := r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := header.Get("Content-Type")
+	if ct != "application/zip" {
+		t.Fatal("expected zip archive")
+	}
+}
+
+// testHasIndexDifferentDefaultPath Contains index.html but specifies a
+// different default, existing path.
+// It should return the content of about.html.
+func testHasIndexDifferentDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	aboutHtml := "about.html"
+	filename := "index.html_about.html"
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, aboutHtml, false, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	checkDefaultPathHeaders(t, r, skylink, "/about.html", skymodules.DefaultPathReasonExplicit)
+	content, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, files[1].Data) {
+		t.Fatalf("Expected to get content '%s', instead got '%s'", files[1].Data, string(content))
+	}
+}
+
+// testHasIndexInvalidDefaultPath Contains index.html but specifies a different
+// INVALID default path.
+// This should fail on upload with "invalid default path provided".
+func testHasIndexInvalidDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	invalidPath := "invalid.js"
+	filename := "index.html_invalid"
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	_, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, invalidPath, false, false)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidDefaultPath.Error()) {
+		t.Fatalf("Expected error 'invalid default path provided', got '%+v'", err)
+	}
+}
+
+// testNoIndexDifferentDefaultPath Does not contain "index.html".
+// Contains about.html and specifies it as default path.
+// It should return the content of about.html.
+func testNoIndexDifferentDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	aboutHtml := "about.html"
+	filename := "index.js_about.html"
+	files := []siatest.TestFile{
+		{Name: "index.js", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, aboutHtml, false, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	content, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, files[1].Data) {
+		t.Fatalf("Expected to get content '%s', instead got '%s'", files[1].Data, string(content))
+	}
+}
+
+// testNoIndexInvalidDefaultPath  Does not contain index.html and specifies an
+// INVALID default path.
+// This should fail on upload with "invalid default path provided".
+func testNoIndexInvalidDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	invalidPath := "invalid.js"
+	files := []siatest.TestFile{
+		{Name: "index.js", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	filename := "index.js_invalid"
+	_, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, invalidPath, false, false)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidDefaultPath.Error()) {
+		t.Fatalf("Expected error 'invalid default path provided', got '%+v'", err)
+	}
+}
+
+// testNoIndexNoDefaultPath Does not contain index.html and doesn't specify
+// default path (not disabled).
+// It should not return an error and download the file as zip
+func testNoIndexNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	fc2 := "File2Contents"
+	files := []siatest.TestFile{
+		{Name: "index.js", Data: []byte(fc1)},
+		{Name: "about.html", Data: []byte(fc2)},
+	}
+	filename := "index.js_nil"
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	checkDefaultPathHeaders(t, r, skylink, "/", skymodules.DefaultPathReasonNone)
+	_, header, err := r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := header.Get("Content-Type")
+	if ct != "application/zip" {
+		t.Fatalf("expected zip archive, got '%s'\n", ct)
+	}
+}
+
+// testNoIndexSingleFileDisabledDefaultPath Does not contain "index.html".
+// Contains a single file and specifies an empty default path (disabled).
+// It should not return an error and download the file as zip.
+func testNoIndexSingleFileDisabledDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	filename := "index.js_empty"
+	files := []siatest.TestFile{
+		{Name: "index.js", Data: []byte(fc1)},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", true, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	_, header, err := r.SkynetSkylinkHead(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := header.Get("Content-Type")
+	if ct != "application/zip" {
+		t.Fatal("expected zip archive")
+	}
+}
+
+// testNoIndexSingleFileNoDefaultPath Does not contain "index.html".
+// Contains a single file and doesn't specify a default path (not disabled).
+// It should serve the only file's content.
+func testNoIndexSingleFileNoDefaultPath(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	fc1 := "File1Contents"
+	files := []siatest.TestFile{
+		{Name: "index.js", Data: []byte(fc1)},
+	}
+	filename := "index.js"
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(filename, files, "", false, false)
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+	checkDefaultPathHeaders(t, r, skylink, "/index.js", skymodules.DefaultPathReasonAutoSingle)
+	content, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, files[0].Data) {
+		t.Fatalf("Expected to get content '%s', instead got '%s'", files[0].Data, string(content))
+	}
+}
+
+// testSkynetRegistryReadWrite does some basic reads and writes on the registry
+// to build out a buffer of stats.
+func testSkynetRegistryReadWrite(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create some random skylinks to use later.
+	skylink1, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skylink2, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a signed registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	data1 := skylink1.Bytes()
+	data2 := skylink2.Bytes()
+	srv1 := modules.NewRegistryValue(dataKey, data1, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
+	srv2 := modules.NewRegistryValue(dataKey, data2, 1, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 1
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+
+	// Force a refresh of the worker pool for testing.
+	_, err = r.RenterWorkersGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the regisry.
+	err = r.RegistryUpdate(spk, dataKey, srv1.Revision, srv1.Signature, skylink1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read it.
+	readSRV, err := r.RegistryRead(spk, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(srv1, readSRV) {
+		t.Log(srv1)
+		t.Log(readSRV)
+		t.Fatal("srvs don't match")
+	}
+
+	// Update the registry again, with a higher revision.
+	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read it.
+	readSRV, err = r.RegistryRead(spk, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(srv2, readSRV) {
+		t.Log(srv2)
+		t.Log(readSRV)
+		t.Fatal("srvs don't match")
+	}
+
+	// Update the registry again using the same revision but higher pow.
+	srvHigherPoW := srv2
+	slHigherPow := skylink2
+	for !srvHigherPoW.HasMoreWork(srv2.RegistryValue) {
+		sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srvHigherPoW.Data = sl.Bytes()
+		srvHigherPoW = srvHigherPoW.Sign(sk)
+		slHigherPow = sl
+	}
+	err = r.RegistryUpdate(spk, dataKey, srvHigherPoW.Revision, srvHigherPoW.Signature, slHigherPow)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkynetDefaultPath_TableTest tests all combinations of inputs in relation
+// to default path.
+func testSkynetDefaultPath_TableTest(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	fc1 := []byte("File1Contents")
+	fc2 := []byte("File2Contents. This one is longer.")
+
+	singleFile := []siatest.TestFile{
+		{Name: "about.html", Data: fc1},
+	}
+	singleDir := []siatest.TestFile{
+		{Name: "dir/about.html", Data: fc1},
+	}
+	multiHasIndex := []siatest.TestFile{
+		{Name: "index.html", Data: fc1},
+		{Name: "about.html", Data: fc2},
+	}
+	multiHasIndexIndexJs := []siatest.TestFile{
+		{Name: "index.html", Data: fc1},
+		{Name: "index.js", Data: fc1},
+		{Name: "about.html", Data: fc2},
+	}
+	multiNoIndex := []siatest.TestFile{
+		{Name: "hello.html", Data: fc1},
+		{Name: "about.html", Data: fc2},
+		{Name: "dir/about.html", Data: fc2},
+	}
+
+	about := "/about.html"
+	bad := "/bad.html"
+	index := "/index.html"
+	hello := "/hello.html"
+	nonHTML := "/index.js"
+	dirAbout := "/dir/about.html"
+	tests := []struct {
+		name                   string
+		files                  []siatest.TestFile
+		defaultPath            string
+		disableDefaultPath     bool
+		expectedContent        []byte
+		expectedDefaultPath    string
+		expectedErrStrDownload string
+		expectedErrStrUpload   string
+		expectedZipArchive     bool
+		noRedirect             bool
+	}{
+		{
+			// Single files with valid default path.
+			// OK
+			name:            "single_correct",
+			files:           singleFile,
+			defaultPath:     about,
+			expectedContent: fc1,
+		},
+		{
+			// Single files without default path.
+			// OK
+			name:            "single_nil",
+			files:           singleFile,
+			defaultPath:     "",
+			expectedContent: fc1,
+		},
+		{
+			// Single files with default, empty default path (disabled).
+			// Expect a zip archive
+			name:               "single_def_empty",
+			files:              singleFile,
+			defaultPath:        "",
+			disableDefaultPath: true,
+			expectedZipArchive: true,
+		},
+		{
+			// Single files with default, bad default path.
+			// Error on upload: invalid default path
+			name:                 "single_def_bad",
+			files:                singleFile,
+			defaultPath:          bad,
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+
+		{
+			// Single dir with default path set to a nested file.
+			// Error: invalid default path.
+			name:                 "single_dir_nested",
+			files:                singleDir,
+			defaultPath:          dirAbout,
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+		{
+			// Single dir without default path (not disabled).
+			// OK
+			name:               "single_dir_nil",
+			files:              singleDir,
+			defaultPath:        "",
+			disableDefaultPath: false,
+			expectedContent:    fc1,
+		},
+		{
+			// Single dir with empty default path (disabled).
+			// Expect a zip archive
+			name:               "single_dir_def_empty",
+			files:              singleDir,
+			defaultPath:        "",
+			disableDefaultPath: true,
+			expectedZipArchive: true,
+		},
+		{
+			// Single dir with bad default path.
+			// Error on upload: invalid default path
+			name:                 "single_def_bad",
+			files:                singleDir,
+			defaultPath:          bad,
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+
+		{
+			// Multi dir with index, correct default path.
+			// OK
+			name:            "multi_idx_correct",
+			files:           multiHasIndex,
+			defaultPath:     index,
+			expectedContent: fc1,
+		},
+		{
+			// Multi dir with index, no default path (not disabled).
+			// OK
+			name:               "multi_idx_nil",
+			files:              multiHasIndex,
+			defaultPath:        "",
+			disableDefaultPath: false,
+			expectedContent:    fc1,
+		},
+		{
+			// Multi dir with index, correct default path, no redirect.
+			// OK
+			name:            "multi_idx_correct_noredirect",
+			files:           multiHasIndex,
+			defaultPath:     index,
+			expectedContent: fc1,
+			noRedirect:      true,
+		},
+		{
+			// Multi dir with index, no default path (not disabled), no
+			// redirect.
+			// OK
+			name:               "multi_idx_nil_noredirect",
+			files:              multiHasIndex,
+			defaultPath:        "",
+			disableDefaultPath: false,
+			expectedContent:    fc1,
+			noRedirect:         true,
+		},
+		{
+			// Multi dir with index, empty default path (disabled).
+			// Expect a zip archive
+			name:               "multi_idx_empty",
+			files:              multiHasIndex,
+			defaultPath:        "",
+			disableDefaultPath: true,
+			expectedZipArchive: true,
+		},
+		{
+			// Multi dir with index, non-html default path.
+			// OK
+			name:               "multi_idx_non_html",
+			files:              multiHasIndexIndexJs,
+			defaultPath:        nonHTML,
+			disableDefaultPath: false,
+			expectedContent:    fc1,
+		},
+		{
+			// Multi dir with index, default path with a different case.
+			// Error on upload: invalid default path.
+			name:                 "multi_idx_case_mismatch",
+			files:                multiHasIndex,
+			defaultPath:          strings.ToUpper(index),
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+		{
+			// Multi dir with index, percent-encoded default path.
+			// OK
+			name:                "multi_idx_encoded",
+			files:               multiHasIndex,
+			defaultPath:         "/index%2Ehtml",
+			expectedContent:     fc1,
+			expectedDefaultPath: index,
+		},
+		{
+			// Multi dir with index, default path with a trailing slash.
+			// OK
+			name:                "multi_idx_trailing_slash",
+			files:               multiHasIndex,
+			defaultPath:         index + "/",
+			expectedContent:     fc1,
+			expectedDefaultPath: index,
+		},
+		{
+			// Multi dir with index, bad default path.
+			// Error on upload: invalid default path.
+			name:                 "multi_idx_bad",
+			files:                multiHasIndex,
+			defaultPath:          bad,
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+
+		{
+			// Multi dir with no index, correct default path.
+			// OK
+			name:            "multi_noidx_correct",
+			files:           multiNoIndex,
+			defaultPath:     hello,
+			expectedContent: fc1,
+		},
+		{
+			// Multi dir with no index, no default path (not disabled).
+			// Expect a zip archive
+			name:               "multi_noidx_nil",
+			files:              multiNoIndex,
+			defaultPath:        "",
+			disableDefaultPath: false,
+			expectedZipArchive: true,
+		},
+		{
+			// Multi dir with no index, empty default path (disabled).
+			// Expect a zip archive
+			name:               "multi_noidx_empty",
+			files:              multiNoIndex,
+			defaultPath:        "",
+			disableDefaultPath: true,
+			expectedZipArchive: true,
+		},
+
+		{
+			// Multi dir with no index, bad default path.
+			// Error on upload: invalid default path.
+			name:                 "multi_noidx_bad",
+			files:                multiNoIndex,
+			defaultPath:          bad,
+			expectedContent:      nil,
+			expectedErrStrUpload: "invalid default path provided",
+		},
+		{
+			// Multi dir with both defaultPath and disableDefaultPath set.
+			// Error on upload.
+			name:                 "multi_defpath_disabledefpath",
+			files:                multiHasIndex,
+			defaultPath:          index,
+			disableDefaultPath:   true,
+			expectedContent:      nil,
+			expectedErrStrUpload: "DefaultPath and DisableDefaultPath are mutually exclusive and cannot be set together",
+		},
+		{
+			// Multi dir with defaultPath pointing to a non-root file..
+			// Error on upload.
+			name:                 "multi_nonroot_defpath",
+			files:                multiNoIndex,
+			defaultPath:          dirAbout,
+			expectedContent:      nil,
+			expectedErrStrUpload: "skyfile has invalid default path which refers to a non-root file",
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(tt.name, tt.files, tt.defaultPath, tt.disableDefaultPath, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking(tt.name, tt.files, tt.defaultPath, tt.disableDefaultPath, false)
+
+			// verify the returned error
+			if err == nil && tt.expectedErrStrUpload != "" {
+				t.Fatalf("Expected error '%s', got <nil>", tt.expectedErrStrUpload)
+			}
+			if err != nil && (tt.expectedErrStrUpload == "" || !strings.Contains(err.Error(), tt.expectedErrStrUpload)) {
+				t.Fatalf("Expected error '%s', got '%s'", tt.expectedErrStrUpload, err.Error())
+			}
+			if tt.expectedErrStrUpload != "" {
+				return
+			}
+
+			// verify the default path was stored in its canonical form
+			if tt.expectedDefaultPath != "" {
+				_, md, err := r.SkynetMetadataGet(skylink)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if md.DefaultPath != tt.expectedDefaultPath {
+					t.Fatalf("Expected default path '%v', got '%v'", tt.expectedDefaultPath, md.DefaultPath)
+				}
+			}
+
+			// verify if it returned an archive if we expected it to
+			if tt.expectedZipArchive {
+				_, header, err := r.SkynetSkylinkHead(skylink)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if header.Get("Content-Type") != "application/zip" {
+					t.Fatalf("Expected Content-Type to be 'application/zip', but received '%v'", header.Get("Content-Type"))
+				}
+				return
+			}
+
+			// verify that the content is served without a redirect if
+			// requested
+			if tt.noRedirect {
+				c := r.Client
+				c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+					return errors.New("unexpected redirect")
+				}
+				_, err = c.SkynetSkylinkGet(skylink)
+				if err == nil || !strings.Contains(err.Error(), "unexpected redirect") {
+					t.Fatal("expected redirect without 'no-redirect'", err)
+				}
+				header, content, err := c.SkynetSkylinkGetWithNoRedirect(skylink)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(content, tt.expectedContent) {
+					t.Fatalf("Content mismatch! Expected %d bytes, got %d bytes.", len(tt.expectedContent), len(content))
+				}
+				if baseHref := header.Get(api.SkynetBaseHrefHeader); baseHref != skylink+"/" {
+					t.Fatalf("unexpected base href '%v'", baseHref)
+				}
+				status, header, err := c.SkynetSkylinkHeadWithParameters(skylink, url.Values{"no-redirect": []string{"true"}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status != http.StatusOK {
+					t.Fatal("unexpected status", status)
+				}
+				if baseHref := header.Get(api.SkynetBaseHrefHeader); baseHref != skylink+"/" {
+					t.Fatalf("unexpected base href '%v'", baseHref)
+				}
+			}
+
+			// verify the contents of the skylink
+			content, err := r.SkynetSkylinkGet(skylink)
+			if err == nil && tt.expectedErrStrDownload != "" {
+				t.Fatalf("Expected error '%s', got <nil>", tt.expectedErrStrDownload)
+			}
+			if err != nil && (tt.expectedErrStrDownload == "" || !strings.Contains(err.Error(), tt.expectedErrStrDownload)) {
+				t.Fatalf("Expected error '%s', got '%s'", tt.expectedErrStrDownload, err.Error())
+			}
+			if tt.expectedErrStrDownload == "" && !bytes.Equal(content, tt.expectedContent) {
+				t.Fatalf("Content mismatch! Expected %d bytes, got %d bytes.", len(tt.expectedContent), len(content))
+			}
+		})
+	}
+}
+
+// testSkynetSingleFileNoSubfiles ensures that a single file uploaded as a
+// skyfile will not have `subfiles` defined in its metadata. This is required by
+// the `defaultPath` logic.
+func testSkynetSingleFileNoSubfiles(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("testSkynetSingleFileNoSubfiles", modules.SectorSize, false)
+	if err != nil {
+		t.Fatal("Failed to upload a single file.", err)
+	}
+	_, metadata, err := r.SkynetMetadataGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Subfiles != nil {
+		t.Fatal("Expected empty subfiles on download, got", metadata.Subfiles)
+	}
+}
+
+// BenchmarkSkynet verifies the functionality of Skynet, a decentralized CDN and
+// sharing platform.
+// i9 - 51.01 MB/s - dbe75c8436cea64f2664e52f9489e9ac761bc058
+func BenchmarkSkynetSingleSector(b *testing.B) {
+	testDir := skynetTestDir(b.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:   3,
+		Miners:  1,
+		Portals: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	// Upload a file that is a single sector big.
+	r := tg.Renters()[0]
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("foo", modules.SectorSize, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Sleep a bit to give the workers time to get set up.
+	time.Sleep(time.Second * 5)
+
+	// Reset the timer once the setup is done.
+	b.ResetTimer()
+	b.SetBytes(int64(b.N) * int64(modules.SectorSize))
+
+	// Download the file.
+	for i := 0; i < b.N; i++ {
+		_, err := r.SkynetSkylinkGet(skylink)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSkynetSubfile measures the time to first byte when requesting a
+// small subfile from the end of a large skyfile.
+func BenchmarkSkynetSubfile(b *testing.B) {
+	testDir := skynetTestDir(b.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:   3,
+		Miners:  1,
+		Portals: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}()
+
+	// Upload a skyfile with a large file spanning many sectors followed by a
+	// small file.
+	r := tg.Renters()[0]
+	small := fastrand.Bytes(100)
+	files := []siatest.TestFile{
+		{Name: "large", Data: fastrand.Bytes(int(20 * modules.SectorSize))},
+		{Name: "small", Data: small},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileBlocking("subfile", files, "", true, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// Sleep a bit to give the workers time to get set up.
+	time.Sleep(time.Second * 5)
+
+	// Reset the timer once the setup is done.
+	b.ResetTimer()
+	b.SetBytes(int64(len(small)))
+
+	// Download the small file and keep track of the time to first byte.
+	var ttfb time.Duration
+	for i := 0; i < b.N; i++ {
+		req, err := r.NewRequest("GET", "/skynet/skylink/"+skylink+"/small", nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		start := time.Now()
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		first := make([]byte, 1)
+		_, err = io.ReadFull(res.Body, first)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ttfb += time.Since(start)
+		data, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			b.Fatal(err)
+		}
+		data = append(first, data...)
+		if res.StatusCode != http.StatusOK || !bytes.Equal(data, small) {
+			b.Fatal("unexpected response", res.StatusCode)
+		}
+	}
+	b.ReportMetric(float64(ttfb.Nanoseconds())/float64(b.N), "ttfb-ns/op")
+}
+
+// TestFormContractBadScore makes sure that a portal won't form a contract with
+// a dead score host.
+func TestFormContractBadScore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set one host to have a bad max duration.
+	h := tg.Hosts()[0]
+	a := siatest.DefaultAllowance
+	err = h.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a new renter.
+	rt := node.RenterTemplate
+	rt.SkipSetAllowance = true
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Set the allowance.
+	err = r.RenterPostAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait to give the renter some time to form contracts. Only 1 contract
+	// should be formed.
+	time.Sleep(time.Second * 5)
+	err = siatest.CheckExpectedNumberOfContracts(r, 1, 0, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enable portal mode and wait again. We should still only see 1 contract.
+	a.PaymentContractInitialFunding = a.Funds.Div64(10)
+	err = r.RenterPostAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second * 5)
+	err = siatest.CheckExpectedNumberOfContracts(r, 1, 0, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenewContractBadScore tests that a portal won't renew a contract with a
+// host that has a dead score.
+func TestRenewContractBadScore(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a new renter.
+	rt := node.RenterTemplate
+	rt.SkipSetAllowance = true
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Set the allowance. The renter should act as a portal but only form a
+	// regular contract with 1 host and form the other contract with the portal.
+	a := siatest.DefaultAllowance
+	a.PaymentContractInitialFunding = a.Funds.Div64(10)
+	a.Hosts = 1
+	err = r.RenterPostAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Should have 2 contracts now. 1 active (regular) and 1 passive (portal).
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return siatest.CheckExpectedNumberOfContracts(r, 2, 0, 0, 0, 0, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set both hosts to have a bad max duration.
+	hosts := tg.Hosts()
+	h1, h2 := hosts[0], hosts[1]
+	err = h1.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h2.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine through a full period and renew window.
+	for i := types.BlockHeight(0); i < a.Period+a.RenewWindow; i++ {
+		err = tg.Miners()[0].MineBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// There should only be 2 expired contracts.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return siatest.CheckExpectedNumberOfContracts(r, 0, 0, 0, 0, 2, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRegistryHealth is an integration test for the /skynet/health/entry
+// endpoint.
+func TestRegistryHealth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Miners: 1,
+		Hosts:  renter.MinUpdateRegistrySuccesses,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a renter with dependency.
+	params := node.RenterTemplate
+	deps := dependencies.NewDependencyDelayRegistryHealthResponses()
+	deps.Disable()
+	params.RenterDeps = deps
+	nodes, err := tg.AddNodes(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Get one of the hosts' pubkey and choose one of the existing hosts to
+	// be stopped later. They can't be the same host.
+	allHosts := tg.Hosts()
+	stoppedHost := allHosts[0]
+	hpk, err := allHosts[1].HostPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hpkh := crypto.HashObject(hpk)
+
+	// Create an entry that's a primary entry on one host but not the other.
+	sk, pk := crypto.GenerateKeyPair()
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	spk := types.Ed25519PublicKey(pk)
+	rid := modules.DeriveRegistryEntryID(spk, dataKey)
+
+	// Helper function to check the health.
+	assertHealth := func(expected skymodules.RegistryEntryHealth) error {
+		// Randomly decide what endpoint to use.
+		var reh skymodules.RegistryEntryHealth
+		var err error
+		choice := fastrand.Intn(2)
+		if choice == 0 {
+			reh, err = r.RegistryEntryHealth(spk, dataKey)
+		} else {
+			reh, err = r.RegistryEntryHealthRID(rid)
+		}
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(reh, expected) {
+			got := siatest.PrintJSON(reh)
+			expected := siatest.PrintJSON(expected)
+			return fmt.Errorf("health doesn't match expected (choice %v) \n got: %v \n expected: %v", choice, got, expected)
+		}
+		return nil
+	}
+
+	// Update the registry with an entry that's a primary entry on one host but not
+	// on the others.
+	revision := fastrand.Uint64n(1000)
+	srv := modules.NewRegistryValue(dataKey, hpkh[:], revision, modules.RegistryTypeWithPubkey).Sign(sk)
+
+	// Update the registry.
+	err = r.RegistryUpdateWithEntry(spk, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the health.
+	err = assertHealth(skymodules.RegistryEntryHealth{
+		NumBestEntries:             3,
+		NumBestEntriesBeforeCutoff: 2, // cutoff is at 2 of 3 hosts
+		NumEntries:                 3,
+		NumBestPrimaryEntries:      1,
+		RevisionNumber:             srv.Revision,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a new host.
+	_, err = tg.AddNodeN(node.HostTemplate, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop the existing host.
+	if err := tg.StopNode(stoppedHost); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the hosts again.
+	revision++
+	srv.Revision = revision
+	srv = srv.Sign(sk)
+	err = r.RegistryUpdateWithEntry(spk, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the health.
+	err = assertHealth(skymodules.RegistryEntryHealth{
+		RevisionNumber:             revision,
+		NumEntries:                 uint64(len(tg.Hosts())) - 1,
+		NumBestEntries:             uint64(len(tg.Hosts())) - 1,
+		NumBestEntriesBeforeCutoff: 2, // cutoff is at 2 of 3 hosts
+		NumBestPrimaryEntries:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the stopped host.
+	if err := tg.StartNode(stoppedHost); err != nil {
+		t.Fatal(err)
+	}
+
+	// Use a retry since the node might take a while to start.
+	err = build.Retry(60, time.Second, func() error {
+		// Force a refresh of the worker pool for testing.
+		_, err = r.RenterWorkersGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Check the health against the expectation.
+		// We expect len(hosts)-1 best entries since one entry is
+		// outdated and does therefore not count towards the health.
+		return assertHealth(skymodules.RegistryEntryHealth{
+			RevisionNumber:             revision,
+			NumEntries:                 uint64(len(tg.Hosts())),
+			NumBestEntries:             uint64(len(tg.Hosts())) - 1,
+			NumBestEntriesBeforeCutoff: 2, // cutoff is at 3 of 4 hosts
+			NumBestPrimaryEntries:      1,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enable the delay dependency and test again. None of the lookups
+	// should return before the timeout.
+	deps.Enable()
+	err = assertHealth(skymodules.RegistryEntryHealth{
+		RevisionNumber:             revision,
+		NumEntries:                 uint64(len(tg.Hosts())),
+		NumBestEntries:             uint64(len(tg.Hosts())) - 1,
+		NumBestEntriesBeforeCutoff: 0,
+		NumBestPrimaryEntries:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRegistryUpdateRead tests setting a registry entry and reading in through
+// the API.
+func TestRegistryUpdateRead(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Renters: 1,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := tg.Renters()[0]
+
+	// Add hosts with a latency dependency.
+	deps := dependencies.NewDependencyHostBlockRPC()
+	deps.Disable()
+	host := node.HostTemplate
+	host.HostDeps = deps
+	_, err = tg.AddNodeN(host, renter.MinUpdateRegistrySuccesses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create some random skylinks to use later.
+	skylink1, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skylink2, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skylink3, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a signed registry value.
+	sk, pk := crypto.GenerateKeyPair()
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	data1 := skylink1.Bytes()
+	data2 := skylink2.Bytes()
+	data3 := skylink3.Bytes()
+	srv1 := modules.NewRegistryValue(dataKey, data1, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
+	srv2 := modules.NewRegistryValue(dataKey, data2, 1, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 1
+	srv3 := modules.NewRegistryValue(dataKey, data3, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
+	spk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       pk[:],
+	}
+
+	// Force a refresh of the worker pool for testing.
+	_, err = r.RenterWorkersGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Try to read it from the host. Shouldn't work.
+	_, err = r.RegistryRead(spk, dataKey)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
+		t.Fatal(err)
+	}
+
+	// Update the regisry.
+	err = r.RegistryUpdate(spk, dataKey, srv1.Revision, srv1.Signature, skylink1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read it again. This should work.
+	readSRV, err := r.RegistryRead(spk, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(srv1, readSRV) {
+		t.Log(srv1)
+		t.Log(readSRV)
+		t.Fatal("srvs don't match")
+	}
+
+	// Update the registry again, with a higher revision.
+	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read it again. This should work.
+	readSRV, err = r.RegistryRead(spk, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(srv2, readSRV) {
+		t.Log(srv2)
+		t.Log(readSRV)
+		t.Fatal("srvs don't match")
+	}
+
+	// Read it again with a almost zero timeout. This should time out.
+	deps.Enable()
+	start := time.Now()
+	readSRV, err = r.RegistryReadWithTimeout(spk, dataKey, time.Second)
+	deps.Disable()
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryLookupTimeout.Error()) {
+		t.Fatal(err)
+	}
+
+	// Make sure it didn't take too long and timed out.
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("read took too long to time out %v > %v", time.Since(start), 2*time.Second)
+	}
+
+	// Update the registry again, with the same revision and same PoW. Should
+	// work.
+	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the registry again. With the same revision but higher PoW. Should work.
+	srvHigherPoW := srv2
+	slHigherPow := skylink2
+	for !srvHigherPoW.HasMoreWork(srv2.RegistryValue) {
+		sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srvHigherPoW.Data = sl.Bytes()
+		srvHigherPoW = srvHigherPoW.Sign(sk)
+		slHigherPow = sl
+	}
+	err = r.RegistryUpdate(spk, dataKey, srvHigherPoW.Revision, srvHigherPoW.Signature, slHigherPow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the registry again, with a lower revision. Shouldn't work.
+	err = r.RegistryUpdate(spk, dataKey, srv3.Revision, srv3.Signature, skylink3)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrLowerRevNum.Error()) {
+		t.Fatal(err)
+	}
+
+	// Update the registry again, with an invalid sig. Shouldn't work.
+	var invalidSig crypto.Signature
+	fastrand.Read(invalidSig[:])
+	err = r.RegistryUpdate(spk, dataKey, srv3.Revision, invalidSig, skylink3)
+	if err == nil || !strings.Contains(err.Error(), crypto.ErrInvalidSignature.Error()) {
+		t.Fatal(err)
+	}
+}
+
+// TestSkynetSkyfileStandardUploadRedundancy is a regression test that verifies
+// the race that occurred in the overdrive code is properly fixed by ensuring
+// the PDC is not accessed from more than one thread. This is a custom test
+// seeing as it requires a 10-30 EC schema for the overdrive code to launch
+// extra overdrive workers immediatelely after launching the initial workers.
+// This requires a test group with at least 10 hosts and a portal with a custom
+// dependency seeing as we don't allow (yet) to configure the fanout redundancy.
+func TestSkynetSkyfileStandardUploadRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  10,
+		Miners: 1,
+	}
+	testDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a new renter with a dependency that uploads using a 10-30 EC schema.
+	rt := node.RenterTemplate
+	rt.Allowance = siatest.DefaultAllowance
+	rt.Allowance.Hosts = 10
+	rt.Allowance.PaymentContractInitialFunding = siatest.DefaultPaymentContractInitialFunding
+	rt.RenterDeps = &dependencies.DependencyStandardUploadRedundancy{}
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Upload a large file
+	ss := modules.SectorSize
+	skylink, _, _, err := r.UploadNewSkyfileBlocking("largefile", ss*2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download the file, this used to trigger a race to be detected.
+	_, err = r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSkynetCleanupOnError verifies files are cleaned up on upload error
+func TestSkynetCleanupOnError(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	testDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a dependency that interrupts uploads.
+	deps := dependencies.NewDependencySkyfileUploadFail()
+
+	// Add a new renter with that dependency to interrupt skyfile uploads.
+	rt := node.RenterTemplate
+	rt.Allowance = siatest.DefaultAllowance
+	rt.Allowance.PaymentContractInitialFunding = siatest.DefaultPaymentContractInitialFunding
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Create a helper function that returns true if the upload failed
+	uploadFailed := func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), "SkyfileUploadFail")
+	}
+
+	// Create a helper function that returns true if the siapath does not exist.
+	skyfileDeleted := func(err error) bool {
+		return err != nil && strings.Contains(err.Error(), filesystem.ErrNotExist.Error())
+	}
+
+	// Upload a small file
+	_, small, _, err := r.UploadNewSkyfileBlocking("smallfile", 100, false)
+	if !uploadFailed(err) {
+		t.Fatal("unexpected error on uploading a small file", err)
+	}
+	smallPath, err := skymodules.SkynetFolder.Join(small.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(smallPath)
+	if !skyfileDeleted(err) {
+		t.Fatal("unexpected error on getting root for a small file", err)
+	}
+
+	// Upload a large file
+	ss := modules.SectorSize
+	_, large, _, err := r.UploadNewSkyfileBlocking("largefile", ss*2, false)
+	if !uploadFailed(err) {
+		t.Fatal("unexpected error on uploading a large file", err)
+	}
+	largePath, err := skymodules.SkynetFolder.Join(large.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(largePath)
+	if !skyfileDeleted(err) {
+		t.Fatal("unexpected error on getting root for a large file", err)
+	}
+	largePathExtended, err := largePath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileRootGet(largePathExtended)
+	if !skyfileDeleted(err) {
+		t.Fatal("unexpected error on getting root for a large file extended", err)
+	}
+
+	// uploadRaw is a helper to upload the data and return the status code and
+	// body of the response. The modtime is only set if it's not zero.
+	uploadRaw := func(name string, data []byte, modTime int64) (int, []byte) {
+		query := fmt.Sprintf("/skynet/skyfile/%v?filename=%v&force=true", name, name)
+		if modTime != 0 {
+			query += fmt.Sprintf("&modtime=%v", modTime)
+		}
+		req, err := r.NewRequest("POST", query, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, body
+	}
+
+	// Failed uploads should return the skylink they would have resulted in.
+	smallData := fastrand.Bytes(100)
+	largeData := fastrand.Bytes(int(2 * ss))
+	failedUploads := make(map[string]api.SkynetSkyfileUploadError)
+	for name, data := range map[string][]byte{"smallraw": smallData, "largeraw": largeData} {
+		code, body := uploadRaw(name, data, 0)
+		if code != http.StatusInternalServerError {
+			t.Fatal("unexpected status code", name, code, string(body))
+		}
+		var uploadErr api.SkynetSkyfileUploadError
+		err = json.Unmarshal(body, &uploadErr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(uploadErr.Message, "SkyfileUploadFail") {
+			t.Fatal("unexpected message", uploadErr.Message)
+		}
+		if uploadErr.Skylink == "" || uploadErr.ModTime == 0 || !uploadErr.Resumable {
+			t.Fatal("error should contain skylink and modtime and be resumable", uploadErr)
+		}
+		failedUploads[name] = uploadErr
+	}
+
+	// Disable the dependency and verify the files are not removed
+	deps.Disable()
+
+	// Retrying the failed uploads with the returned modtime should result in
+	// the same skylinks.
+	for name, data := range map[string][]byte{"smallraw": smallData, "largeraw": largeData} {
+		code, body := uploadRaw(name, data, failedUploads[name].ModTime)
+		if code != http.StatusOK {
+			t.Fatal("unexpected status code", name, code, string(body))
+		}
+		var sshp api.SkynetSkyfileHandlerPOST
+		err = json.Unmarshal(body, &sshp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sshp.Skylink != failedUploads[name].Skylink {
+			t.Fatal("skylink mismatch", name, sshp.Skylink, failedUploads[name].Skylink)
+		}
+	}
+
+	// Re-upload the small file and re-test
+	_, small, _, err = r.UploadNewSkyfileBlocking("smallfile", 100, true)
+	if err != nil {
+		t.Fatal("re-uploading a small file should succeed", err)
+	}
+	_, err = r.RenterSkyfileGet(small.SiaPath, small.Root)
+	if err != nil {
+		t.Fatal("unexpected error on getting root for a small file", err)
+	}
+
+	// Re-upload the large file and re-test
+	_, large, _, err = r.UploadNewSkyfileBlocking("largefile", ss*2, true)
+	if err != nil {
+		t.Fatal("re-uploading a large file should succeed", err)
+	}
+	_, err = r.RenterSkyfileGet(large.SiaPath, large.Root)
+	if err != nil {
+		t.Fatal("unexpected error on getting root for a large file", err)
+	}
+	largePathExtended, err = large.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterSkyfileGet(largePathExtended, large.Root)
+	if err != nil {
+		t.Fatal("unexpected error on getting root for a large file extended", err)
+	}
+}
+
+// TestReadUnknownRegistryEntry makes sure that reading an unknown entry takes
+// the appropriate amount of time.
+func TestReadUnknownRegistryEntry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  1,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rt := node.RenterTemplate
+	rt.RenterDeps = &dependencies.DependencyReadRegistryBlocking{}
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Get a random pubkey.
+	var spk types.SiaPublicKey
+	fastrand.Read(spk.Key)
+
+	// Look it up.
+	start := time.Now()
+	_, err = r.RegistryRead(spk, crypto.Hash{})
+	passed := time.Since(start)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
+		t.Fatal(err)
+	}
+
+	// The time should have been less than MaxRegistryReadTimeout but greater
+	// than readRegistryBackgroundTimeout.
+	if passed >= renter.MaxRegistryReadTimeout || passed <= renter.ReadRegistryBackgroundTimeout {
+		t.Fatalf("%v not between %v and %v", passed, renter.ReadRegistryBackgroundTimeout, renter.MaxRegistryReadTimeout)
+	}
+
+	// The remainder of the test might take a while. Only execute it in vlong
+	// tests.
+	if !build.VLONG {
+		t.SkipNow()
+	}
+
+	// Run 200 reads to lower the p99 below the seed. Do it in batches of 10
+	// reads.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := r.RegistryRead(spk, crypto.Hash{})
+				if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Verify that the estimate is lower than the timeout after multiple reads
+	// with slow hosts.
+	err = build.Retry(60, time.Second, func() error {
+		ss, err := r.SkynetStatsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ss.RegistryRead15mP99ms >= float64(renter.ReadRegistryBackgroundTimeout)/float64(time.Millisecond) {
+			return fmt.Errorf("%v >= %v", ss.RegistryRead15mP99ms, renter.ReadRegistryBackgroundTimeout)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSkynetFeePaid tests that the renter calls paySkynetFee. The various edge
+// cases of paySkynetFee and the exact amounts are tested within its unit test.
+func TestSkynetFeePaid(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Create an independent wallet node.
+	wallet, err := siatest.NewCleanNode(node.Wallet(testDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wallet.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connect it to the group.
+	err = wallet.GatewayConnectPost(tg.Hosts()[0].GatewayAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// It should start with a 0 balance.
+	balance, err := wallet.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !balance.IsZero() {
+		t.Fatal("balance should be 0")
+	}
+
+	// Get an address.
+	wag, err := wallet.WalletAddressGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a new renter that thinks the wallet's address is the fee address.
+	rt := node.RenterTemplate
+	deps := &dependencies.DependencyCustomSkynetAddress{}
+	deps.SetAddress(wag.Address)
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Upload a file.
+	_, _, err = r.UploadNewFileBlocking(100, 1, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the next payout.
+	time.Sleep(skymodules.SkynetFeePayoutInterval)
+
+	// Mine a block to confirm the txn.
+	err = tg.Miners()[0].MineBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for some money to show up on the address.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		balance, err := wallet.ConfirmedBalance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.IsZero() {
+			return errors.New("balance is zero")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSkynetDownloadPinnedSkyfile is a custom test to verify whether a portal
+// can still download a skyfile that was uploaded on portal A, pinned on portal
+// B and then manually removed from both portal's local filesystems.
+func TestSkynetDownloadPinnedSkyfile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create test group with two portals
+	groupParams := siatest.GroupParams{
+		Hosts:  5,
+		Miners: 1,
+	}
+	groupDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = tg.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	_, err = tg.AddNodeN(rt, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Grab both portals
+	portals := tg.Portals()
+	p1 := portals[0]
+	p2 := portals[1]
+
+	// Upload a file to portal 1
+	filename := t.Name()
+	filedata := fastrand.Bytes(100)
+	skylink, sup, _, err := p1.UploadNewSkyfileWithDataBlocking(filename, filedata, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the file is on the filesystem
+	originalFullPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalFile, err := p1.RenterFileRootGet(originalFullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(originalFile.File.Skylinks) != 1 {
+		t.Fatal("expecting 1 skylink")
+	}
+	if originalFile.File.Skylinks[0] != skylink {
+		t.Fatal("skylink mismatch")
+	}
+
+	// Pin the file on portal 2
+	pinPath := skymodules.RandomSiaPath()
+	p2.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{
+		SiaPath: pinPath,
+	})
+
+	// Assert we can download the file from portal 2
+	data, err := p2.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, filedata) {
+		t.Fatal(err)
+	}
+
+	// Check the file is on the filesystem
+	pinnedFullPath, err := skymodules.SkynetFolder.Join(pinPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinnedFile, err := p2.RenterFileRootGet(pinnedFullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pinnedFile.File.Skylinks) != 1 {
+		t.Fatal("expecting 1 skylink")
+	}
+	if pinnedFile.File.Skylinks[0] != skylink {
+		t.Fatal("skylink mismatch")
+	}
+
+	// Delete the file and assert it is gone
+	err = p2.RenterFileDeleteRootPost(pinnedFullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the file is no longer present.
+	_, err = p2.RenterFileRootGet(pinnedFullPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("skyfile still present after deletion")
+	}
+
+	// Assert we can download the file from both portals still
+	data, err = p1.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, filedata) {
+		t.Fatal(err)
+	}
+	data, err = p2.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, filedata) {
+		t.Fatal(err)
+	}
+
+	// Delete the original file on portal 1
+	err = p1.RenterFileDeleteRootPost(originalFullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the file is no longer present.
+	_, err = p1.RenterFileRootGet(originalFullPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("skyfile still present after deletion")
+	}
+
+	// Assert we can download the file from both portals still
+	data, err = p1.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, filedata) {
+		t.Fatal(err)
+	}
+	data, err = p2.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, filedata) {
+		t.Fatal(err)
+	}
+}
+
+// TestSkynetPinUnpin tests pinning and unpinning a skylink
+func TestSkynetPinUnpin(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create group
+	// Create a testgroup with 2 portals.
+	groupParams := siatest.GroupParams{
+		Hosts:  5,
+		Miners: 1,
+	}
+	groupDir := skynetTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = tg.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add portals with custom dependency
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencySkipUnpinRequest()
+	rt.RenterDeps = deps
+	_, err = tg.AddNodeN(rt, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Grab the portals
+	portals := tg.Portals()
+	p1 := portals[0]
+	p2 := portals[1]
+
+	// Test small Skyfile
+	t.Run("SmallFile", func(t *testing.T) {
+		testSkynetPinUnpin(t, p1, p2, 100, deps)
+	})
+	// Test Large Skyfile
+	t.Run("LargeFile", func(t *testing.T) {
+		testSkynetPinUnpin(t, p1, p2, 2*modules.SectorSize, deps)
+	})
+}
+
+// testSkynetPinUnpin tests pinning and unpinning a skylink
+func testSkynetPinUnpin(t *testing.T, p1, p2 *siatest.TestNode, fileSize uint64, deps *dependencies.DependencyWithDisableAndEnable) {
+	// Define helper function for checking the number of files
+	fileCheck := func(p1Expected, p2Expected uint64) error {
+		return build.Retry(100, 100*time.Millisecond, func() error {
+			rdg, err := p1.RenterDirRootGet(skymodules.RootSiaPath())
+			if err != nil {
+				return err
+			}
+			if rdg.Directories[0].AggregateNumFiles != p1Expected {
+				return fmt.Errorf("Portal 1 should have %v files but has %v", p1Expected, rdg.Directories[0].AggregateNumFiles)
+			}
+			rdg, err = p2.RenterDirRootGet(skymodules.RootSiaPath())
+			if err != nil {
+				return err
+			}
+			if rdg.Directories[0].AggregateNumFiles != p2Expected {
+				return fmt.Errorf("Portal 2 should have %v files but has %v", p2Expected, rdg.Directories[0].AggregateNumFiles)
+			}
+			return nil
+		})
+	}
+
+	// Makes sure the test is starting with the dependency enabled.
+	deps.Enable()
+
+	// Verify that the portals are starting with 0 files
+	err := fileCheck(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload from one portal
+	fileName := "pinnedFile"
+	skylink, sup, _, err := p1.UploadNewSkyfileBlocking(fileName, fileSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath := sup.SiaPath
+
+	// A call to unpin with a random siapath should be a no-op with the dependency enabled.
+	err = p1.SkynetSkylinkUnpinCustomPost(skylink, skymodules.RandomSiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1Expected := uint64(1)
+	isLargeFile := fileSize > modules.SectorSize
+	if isLargeFile {
+		p1Expected *= 2
+	}
+	err = fileCheck(p1Expected, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A call to unpin with the correct siaPath should delete the file.
+	fullSiaPath, err := skymodules.SkynetFolder.Join(siaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p1.SkynetSkylinkUnpinCustomPost(skylink, fullSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fileCheck(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Disable the dependecy and re-upload the siafile
+	deps.Disable()
+	skylink, sup, _, err = p1.UploadNewSkyfileBlocking(fileName, fileSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath = sup.SiaPath
+	fullSiaPath, err = skymodules.SkynetFolder.Join(siaPath.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Try pinning the link as v2. Shouldn't work.
+	var slV1 skymodules.Skylink
+	err = slV1.LoadString(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slV2, err := p1.NewSkylinkV2(slV1)
+	_, err = p1.SkynetSkylinkPinPost(slV2.String(), skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "can't pin version 2 skylink") {
+		t.Fatal(err)
+	}
 
-			// verify the returned error
-			if err == nil && tt.expectedErrStrUpload != "" {
-				t.Fatalf("Expected error '%s', got <nil>", tt.expectedErrStrUpload)
-			}
-			if err != nil && (tt.expectedErrStrUpload == "" || !strings.Contains(err.Error(), tt.expectedErrStrUpload)) {
-				t.Fatalf("Expected error '%s', got '%s'", tt.expectedErrStrUpload, err.Error())
+	// Pin to the other portal a random number of times.
+	//
+	// This will test the case of the skylink being associated with multiple
+	// files.
+	numPins := 1 + fastrand.Intn(3)
+	var wg sync.WaitGroup
+	for i := 0; i < numPins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spp := skymodules.SkyfilePinParameters{
+				SiaPath: skymodules.RandomSiaPath(),
 			}
-			if tt.expectedErrStrUpload != "" {
+			_, err := p2.SkynetSkylinkPinPost(skylink, spp)
+			if err != nil {
+				t.Error(err)
 				return
 			}
+		}()
+	}
+	wg.Wait()
 
-			// verify if it returned an archive if we expected it to
-			if tt.expectedZipArchive {
-				_, header, err := r.SkynetSkylinkHead(skylink)
-				if err != nil {
-					t.Fatal(err)
-				}
-				if header.Get("Content-Type") != "application/zip" {
-					t.Fatalf("Expected Content-Type to be 'application/zip', but received '%v'", header.Get("Content-Type"))
-				}
-				return
-			}
+	// Rename the file on Portal 1
+	//
+	// This will test the case of the siapath changing after upload. This also
+	// pulls the siafile out of the skynet folder.
+	err = p1.RenterRenamePost(fullSiaPath, skymodules.RandomSiaPath(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-			// verify the contents of the skylink
-			content, err := r.SkynetSkylinkGet(skylink)
-			if err == nil && tt.expectedErrStrDownload != "" {
-				t.Fatalf("Expected error '%s', got <nil>", tt.expectedErrStrDownload)
-			}
-			if err != nil && (tt.expectedErrStrDownload == "" || !strings.Contains(err.Error(), tt.expectedErrStrDownload)) {
-				t.Fatalf("Expected error '%s', got '%s'", tt.expectedErrStrDownload, err.Error())
-			}
-			if tt.expectedErrStrDownload == "" && !bytes.Equal(content, tt.expectedContent) {
-				t.Fatalf("Content mismatch! Expected %d bytes, got %d bytes.", len(tt.expectedContent), len(content))
-			}
-		})
+	// Download from both
+	//
+	// NOTE: This helper also verified the bytes are equal
+	err = verifyDownloadByAll(p1, p2, skylink)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-// testSkynetSingleFileNoSubfiles ensures that a single file uploaded as a
-// skyfile will not have `subfiles` defined in its metadata. This is required by
-// the `defaultPath` logic.
-func testSkynetSingleFileNoSubfiles(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+	// Verify that each portal has the expected number of files.
+	p1Expected = uint64(1)
+	p2Expected := uint64(numPins)
+	if isLargeFile {
+		p1Expected *= 2
+		p2Expected *= 2
+	}
+	err = fileCheck(p1Expected, p2Expected)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	skylink, _, _, err := r.UploadNewSkyfileBlocking("testSkynetSingleFileNoSubfiles", modules.SectorSize, false)
+	// Unpin from both portals
+	err = p1.SkynetSkylinkUnpinPost(skylink)
 	if err != nil {
-		t.Fatal("Failed to upload a single file.", err)
+		t.Fatal(err)
 	}
-	_, metadata, err := r.SkynetMetadataGet(skylink)
+	err = p2.SkynetSkylinkUnpinPost(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if metadata.Subfiles != nil {
-		t.Fatal("Expected empty subfiles on download, got", metadata.Subfiles)
+
+	// Unpin the v2 skylink. Shouldn't work.
+	err = p1.SkynetSkylinkUnpinPost(slV2.String())
+	if err == nil || !strings.Contains(err.Error(), "can't unpin version 2 skylink") {
+		t.Fatal(err)
+	}
+	// Verify that all the siafiles have been deleted on both portals
+	err = fileCheck(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download from all. This still works because the data is still on the hosts.
+	err = verifyDownloadByAll(p1, p2, skylink)
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-// BenchmarkSkynet verifies the functionality of Skynet, a decentralized CDN and
-// sharing platform.
-// i9 - 51.01 MB/s - dbe75c8436cea64f2664e52f9489e9ac761bc058
-func BenchmarkSkynetSingleSector(b *testing.B) {
-	testDir := skynetTestDir(b.Name())
+// testSkylinkV2Download tests downloading a file by its version 2 skylink.
+func testSkylinkV2Download(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:   3,
-		Miners:  1,
-		Portals: 1,
+	// Upload some data.
+	data := fastrand.Bytes(100)
+	slStr, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+
+	var skylink skymodules.Skylink
+	err = skylink.LoadString(slStr)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			b.Fatal(err)
-		}
-	}()
 
-	// Upload a file that is a single sector big.
-	r := tg.Renters()[0]
-	skylink, _, _, err := r.UploadNewSkyfileBlocking("foo", modules.SectorSize, false)
+	// Create a v2 link.
+	skylinkV2, err := r.NewSkylinkV2(skylink)
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
 	}
 
-	// Sleep a bit to give the workers time to get set up.
-	time.Sleep(time.Second * 5)
+	// Download the file using that link.
+	downloadedDataV2, err := r.SkynetSkylinkGet(skylinkV2.String())
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	// Reset the timer once the setup is done.
-	b.ResetTimer()
-	b.SetBytes(int64(b.N) * int64(modules.SectorSize))
+	// Download it using the v1 link.
+	downloadedDataV1, err := r.SkynetSkylinkGet(slStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Data should match.
+	if !bytes.Equal(downloadedDataV1, downloadedDataV2) {
+		t.Fatal("data doesn't match")
+	}
+	if !bytes.Equal(downloadedDataV1, data) {
+		t.Fatal("data doesn't match")
+	}
 
-	// Download the file.
-	for i := 0; i < b.N; i++ {
-		_, err := r.SkynetSkylinkGet(skylink)
+	// Create a recursive v2 link of the max depth.
+	recursiveLink := skylink
+	var links []skymodules.Skylink
+	for i := 0; i < int(renter.MaxSkylinkV2ResolvingDepth); i++ {
+		slv2, err := r.NewSkylinkV2(recursiveLink)
 		if err != nil {
-			b.Fatal(err)
+			t.Fatal(err)
 		}
+		recursiveLink = slv2.Skylink
+		links = append(links, slv2.Skylink)
 	}
-}
 
-// TestFormContractBadScore makes sure that a portal won't form a contract with
-// a dead score host.
-func TestFormContractBadScore(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+	// Download the file using that link.
+	downloadedDataRecursive, err := r.SkynetSkylinkGet(recursiveLink.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloadedDataV1, downloadedDataRecursive) {
+		t.Fatal("data doesn't match")
 	}
-	t.Parallel()
-	testDir := skynetTestDir(t.Name())
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
+	// Fetch the metadata and confirm the headers.
+	mdH, _, err := r.SkynetMetadataGet(recursiveLink.String())
+	if err != nil {
+		t.Fatal(err)
 	}
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	// The skynet-skylink header should report the v1 skylink.
+	if sl := mdH.Get(api.SkynetSkylinkHeader); sl != skylink.String() {
+		t.Fatalf("wrong skylink %v != %v", sl, skylink.String())
+	}
+
+	// Fetch the header.
+	_, h, err := r.SkynetSkylinkHead(recursiveLink.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
+	// The skynet-skylink header should report the v1 skylink.
+	if sl := h.Get(api.SkynetSkylinkHeader); sl != skylink.String() {
+		t.Fatalf("wrong skylink %v != %v", sl, skylink.String())
+	}
+	// It should contain a valid proof.
+	var proof []api.RegistryHandlerGET
+	err = json.Unmarshal([]byte(h.Get(api.SkynetProofHeader)), &proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rhg := range proof {
+		// Parse the element into a skymodules.RegistryEntry and verify
+		// it.
+		data, _ := hex.DecodeString(rhg.Data)
+		sigBytes, _ := hex.DecodeString(rhg.Signature)
+		var sig crypto.Signature
+		copy(sig[:], sigBytes)
+		v := modules.NewSignedRegistryValue(rhg.DataKey, data, rhg.Revision, sig, rhg.Type)
+		entry := skymodules.NewRegistryEntry(rhg.PublicKey, v)
+		if err := entry.Verify(); err != nil {
+			t.Fatal("verification failed", err)
+		}
+
+		// Get the current v2 link that points to the parsed entry as well as the next
+		// link which is contained within its entry payload.
+		currentLink := skymodules.NewSkylinkV2(entry.PubKey, entry.Tweak)
+		var nextLink skymodules.Skylink
+		err = nextLink.LoadBytes(data)
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
-
-	// Set one host to have a bad max duration.
-	h := tg.Hosts()[0]
-	a := siatest.DefaultAllowance
-	err = h.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
-	if err != nil {
-		t.Fatal(err)
+		// The current link should match the link at the opposite end of
+		// the links slice.
+		if currentLink.String() != links[len(links)-i-1].String() {
+			t.Fatal("wrong link", currentLink, links[len(links)-i-1])
+		}
+		// The nextLink should match the next one. Except for the last
+		// one which should match the origin v1 skylink.
+		if i == len(proof)-1 && nextLink != skylink {
+			t.Fatal("wrong link", nextLink, skylink)
+		} else if i < len(proof)-1 && nextLink.String() != links[len(links)-i-2].String() {
+			t.Fatal("wrong link", nextLink, links[len(links)-i-2])
+		}
 	}
 
-	// Add a new renter.
-	rt := node.RenterTemplate
-	rt.SkipSetAllowance = true
-	nodes, err := tg.AddNodes(rt)
+	// Add another level of recursion.
+	slv2, err := r.NewSkylinkV2(recursiveLink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
+	// Download the file using that link. Should fail.
+	_, err = r.SkynetSkylinkGet(slv2.String())
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNesting.Error()) {
+		t.Fatal("should fail to resolve v2 skylink with more than 2 layers of recursion", err)
+	}
 
-	// Set the allowance.
-	err = r.RenterPostAllowance(a)
+	// Resolve using resolve endpoint.
+	resolvedSkylink, h, err := r.ResolveSkylinkV2Custom(skylinkV2.String(), api.DefaultSkynetRequestTimeout)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if resolvedSkylink != skylink.String() {
+		t.Fatal("skylink resolved wrong", resolvedSkylink, skylink.String())
+	}
+	skynetSkylink := h.Get(api.SkynetSkylinkHeader)
+	if skynetSkylink != resolvedSkylink {
+		t.Fatalf("wrong skylink header %v != %v", skynetSkylink, resolvedSkylink)
+	}
 
-	// Wait to give the renter some time to form contracts. Only 1 contract
-	// should be formed.
-	time.Sleep(time.Second * 5)
-	err = siatest.CheckExpectedNumberOfContracts(r, 1, 0, 0, 0, 0, 0)
+	// Update entry to empty skylink.
+	err = r.DeleteSkylinkV2(&skylinkV2)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Enable portal mode and wait again. We should still only see 1 contract.
-	a.PaymentContractInitialFunding = a.Funds.Div64(10)
-	err = r.RenterPostAllowance(a)
-	if err != nil {
+	// Download the file using v2 link again.
+	_, err = r.SkynetSkylinkGet(skylinkV2.String())
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
 		t.Fatal(err)
 	}
-	time.Sleep(time.Second * 5)
-	err = siatest.CheckExpectedNumberOfContracts(r, 1, 0, 0, 0, 0, 0)
-	if err != nil {
+	// Resolve using resolve endpoint.
+	_, err = r.ResolveSkylinkV2(skylinkV2.String())
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
 		t.Fatal(err)
 	}
 }
 
-// TestRenewContractBadScore tests that a portal won't renew a contract with a
-// host that has a dead score.
-func TestRenewContractBadScore(t *testing.T) {
+// TestSkynetSkylinkHealth tests the /skynet/health/skylink endpoint.
+func TestSkynetSkylinkHealth(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	testDir := skynetTestDir(t.Name())
 
-	// Create a testgroup.
+	// Define the parameters.
 	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
+		Hosts:   modules.RenterDefaultDataPieces + modules.RenterDefaultParityPieces,
+		Miners:  1,
+		Renters: 1,
 	}
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	groupDir := skynetTestDir(t.Name())
+
+	// Create a testgroup.
+	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal(errors.AddContext(err, "failed to create group"))
 	}
 	defer func() {
 		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
+	r := tg.Renters()[0]
 
-	// Add a new renter.
-	rt := node.RenterTemplate
-	rt.SkipSetAllowance = true
-	nodes, err := tg.AddNodes(rt)
-	if err != nil {
-		t.Fatal(err)
+	// helper function for asserting health
+	assertHealth := func(skylink string, baseSectorRedundancy uint64, fanoutHealth float64) error {
+		// Get the health.
+		var sl skymodules.Skylink
+		if err := sl.LoadString(skylink); err != nil {
+			t.Fatal(err)
+		}
+		sh, err := r.SkylinkHealthGET(sl)
+		if err != nil {
+			return err
+		}
+		if sh.BaseSectorRedundancy != baseSectorRedundancy {
+			return fmt.Errorf("wrong base sector redundancy %v != %v", sh.BaseSectorRedundancy, baseSectorRedundancy)
+		}
+		if sh.FanoutEffectiveRedundancy != fanoutHealth {
+			return fmt.Errorf("fanout not healthy %v != %v", sh.FanoutEffectiveRedundancy, fanoutHealth)
+		}
+		dataPieces := uint8(skymodules.RenterDefaultDataPieces)
+		parityPieces := uint8(skymodules.RenterDefaultParityPieces)
+		if sh.FanoutDataPieces != dataPieces {
+			return fmt.Errorf("invalid datapieces %v != %v", sh.FanoutDataPieces, dataPieces)
+		}
+		if sh.FanoutParityPieces != parityPieces {
+			return fmt.Errorf("invalid paritypieces %v != %v", sh.FanoutParityPieces, parityPieces)
+		}
+		for i, health := range sh.FanoutRedundancy {
+			if health != fanoutHealth {
+				return fmt.Errorf("chunk %v not healthy %v != %v", i, health, fanoutHealth)
+			}
+		}
+		return nil
 	}
-	r := nodes[0]
 
-	// Set the allowance. The renter should act as a portal but only form a
-	// regular contract with 1 host and form the other contract with the portal.
-	a := siatest.DefaultAllowance
-	a.PaymentContractInitialFunding = a.Funds.Div64(10)
-	a.Hosts = 1
-	err = r.RenterPostAllowance(a)
+	// Upload a file with multiple chunks.
+	size := modules.SectorSize * 3
+	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), size, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Should have 2 contracts now. 1 active (regular) and 1 passive (portal).
+	// Assert its health. The piece should be on 5 hosts and only 1
+	// datapiece is needed so the health should be 5.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
-		return siatest.CheckExpectedNumberOfContracts(r, 2, 0, 0, 0, 0, 0)
+		return assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy, 5)
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Set both hosts to have a bad max duration.
-	hosts := tg.Hosts()
-	h1, h2 := hosts[0], hosts[1]
-	err = h1.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
+	// Upload another file but encrypted. This makes sure we test encrypted
+	// skylinks as well as fanouts that can't be compressed.
+	_, err = r.SkykeyCreateKeyPost("key", skykey.TypePrivateID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = h2.HostModifySettingPost(client.HostParamMaxDuration, a.Period+a.RenewWindow-1)
+	skylink2BaseSectorRedundancy := uint64(5)
+	skylink2, _, _, err := r.UploadSkyfileBlockingCustom(t.Name()+"2", fastrand.Bytes(int(size)), "key", uint8(skylink2BaseSectorRedundancy), false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Mine through a full period and renew window.
-	for i := types.BlockHeight(0); i < a.Period+a.RenewWindow; i++ {
-		err = tg.Miners()[0].MineBlock()
+	// Assert its health. Same as before.
+	err = assertHealth(skylink2, skylink2BaseSectorRedundancy, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Take two hosts offline.
+	hosts := tg.Hosts()
+	for i := 0; i < 2; i++ {
+		err = tg.RemoveNode(hosts[i])
 		if err != nil {
 			t.Fatal(err)
 		}
-		time.Sleep(time.Millisecond * 10)
 	}
 
-	// There should only be 2 expired contracts.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		return siatest.CheckExpectedNumberOfContracts(r, 0, 0, 0, 0, 2, 0)
-	})
+	// Check health again.
+	// The first file should either have a base sector redundancy of 0 to 2
+	// depending on whether the hosts we took offline had a piece. 2 of the
+	// fanout pieces are missing so the health is 3 instead of 5.
+	err1 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy-2, 3)
+	err2 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy-1, 3)
+	err3 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy, 3)
+	if err1 != nil && err2 != nil && err3 != nil {
+		t.Fatal(errors.Compose(err1, err2))
+	}
+	// The second file should have a base sector redundancy of 3. 2 of the
+	// fanout pieces are missing so the health is 3 again.
+	err = assertHealth(skylink2, skylink2BaseSectorRedundancy-2, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-// TestRegistryHealth is an integration test for the /skynet/health/entry
-// endpoint.
-func TestRegistryHealth(t *testing.T) {
+// TestHostLosingRegistryEntry tests the edge case where a host forgets about a
+// registry entry that we have fetched before.
+func TestHostLosingRegistryEntry(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
@@ -4335,156 +10480,124 @@ func TestRegistryHealth(t *testing.T) {
 		}
 	}()
 
-	// Add a renter with dependency.
-	params := node.RenterTemplate
-	deps := dependencies.NewDependencyDelayRegistryHealthResponses()
-	deps.Disable()
-	params.RenterDeps = deps
-	nodes, err := tg.AddNodes(params)
-	if err != nil {
-		t.Fatal(err)
-	}
-	r := nodes[0]
-
-	// Get one of the hosts' pubkey and choose one of the existing hosts to
-	// be stopped later. They can't be the same host.
-	allHosts := tg.Hosts()
-	stoppedHost := allHosts[0]
-	hpk, err := allHosts[1].HostPublicKey()
-	if err != nil {
-		t.Fatal(err)
-	}
-	hpkh := crypto.HashObject(hpk)
-
-	// Create an entry that's a primary entry on one host but not the other.
-	sk, pk := crypto.GenerateKeyPair()
-	var dataKey crypto.Hash
-	fastrand.Read(dataKey[:])
-	spk := types.Ed25519PublicKey(pk)
-	rid := modules.DeriveRegistryEntryID(spk, dataKey)
-
-	// Helper function to check the health.
-	assertHealth := func(expected skymodules.RegistryEntryHealth) error {
-		// Randomly decide what endpoint to use.
-		var reh skymodules.RegistryEntryHealth
-		var err error
-		choice := fastrand.Intn(2)
-		if choice == 0 {
-			reh, err = r.RegistryEntryHealth(spk, dataKey)
-		} else {
-			reh, err = r.RegistryEntryHealthRID(rid)
-		}
-		if err != nil {
-			return err
-		}
-		if !reflect.DeepEqual(reh, expected) {
-			got := siatest.PrintJSON(reh)
-			expected := siatest.PrintJSON(expected)
-			return fmt.Errorf("health doesn't match expected (choice %v) \n got: %v \n expected: %v", choice, got, expected)
-		}
-		return nil
-	}
-
-	// Update the registry with an entry that's a primary entry on one host but not
-	// on the others.
-	revision := fastrand.Uint64n(1000)
-	srv := modules.NewRegistryValue(dataKey, hpkh[:], revision, modules.RegistryTypeWithPubkey).Sign(sk)
-
-	// Update the registry.
-	err = r.RegistryUpdateWithEntry(spk, srv)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Check the health.
-	err = assertHealth(skymodules.RegistryEntryHealth{
-		NumBestEntries:             3,
-		NumBestEntriesBeforeCutoff: 2, // cutoff is at 2 of 3 hosts
-		NumEntries:                 3,
-		NumBestPrimaryEntries:      1,
-		RevisionNumber:             srv.Revision,
-	})
+	// Add renter with a special dependency.
+	deps := dependencies.NewDependencyReadRegistryNoEntry()
+	deps.Disable()
+	params := node.RenterTemplate
+	params.RenterDeps = deps
+	_, err = tg.AddNodes(params)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Add a new host.
-	_, err = tg.AddNodeN(node.HostTemplate, 1)
+	// Set an entry.
+	r := tg.Renters()[0]
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	srv := modules.NewRegistryValue(crypto.Hash{}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	err = r.RegistryUpdateWithEntry(spk, srv)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Stop the existing host.
-	if err := tg.StopNode(stoppedHost); err != nil {
+	// Enable the dependency and try to read it. Should fail for all
+	// workers and therefore error out.
+	deps.Enable()
+	_, err = r.RegistryRead(spk, srv.Tweak)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
 		t.Fatal(err)
 	}
+}
 
-	// Update the hosts again.
-	revision++
-	srv.Revision = revision
-	srv = srv.Sign(sk)
-	err = r.RegistryUpdateWithEntry(spk, srv)
+// testUpdateRegistryMulti tests the endpoint for updating multiple host with
+// different entries.
+func testUpdateRegistryMulti(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Make sure we got at least 3 hosts.
+	// One to update with a primary key.
+	// One to update with a secondary key.
+	// Two to not update at all.
+	hosts := tg.Hosts()
+	if len(hosts) < 3 {
+		t.Fatal("not enough hosts for test")
+	}
+
+	// Ignore the first host. We don't use it.
+	hosts = hosts[1:]
+
+	// Prepare an entry that's a primary entry on the second host.
+	hpk, err := hosts[0].HostPublicKey()
 	if err != nil {
 		t.Fatal(err)
 	}
+	hpkh := crypto.HashObject(hpk)
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	data := hpkh[:modules.RegistryPubKeyHashSize]
+	srv := modules.NewRegistryValue(dataKey, data, 0, modules.RegistryTypeWithPubkey).Sign(sk)
+	entry := skymodules.NewRegistryEntry(spk, srv)
 
-	// Check the health.
-	err = assertHealth(skymodules.RegistryEntryHealth{
-		RevisionNumber:             revision,
-		NumEntries:                 uint64(len(tg.Hosts())) - 1,
-		NumBestEntries:             uint64(len(tg.Hosts())) - 1,
-		NumBestEntriesBeforeCutoff: 2, // cutoff is at 2 of 3 hosts
-		NumBestPrimaryEntries:      1,
-	})
+	// Set the entry on all hosts from the second till the last.
+	srvs := make(map[string]skymodules.RegistryEntry)
+	for _, h := range hosts {
+		hostKey, err := h.HostPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		srvs[hostKey.String()] = entry
+	}
+	err = r.RegistryUpdateMulti(srvs)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Restart the stopped host.
-	if err := tg.StartNode(stoppedHost); err != nil {
+	// Check the health. We should find the entry on every host but one and
+	// one host should be considered a primary entry.
+	reh, err := r.RegistryEntryHealth(spk, dataKey)
+	if err != nil {
 		t.Fatal(err)
 	}
+	fmt.Println(siatest.PrintJSON(reh))
+}
 
-	// Use a retry since the node might take a while to start.
-	err = build.Retry(60, time.Second, func() error {
-		// Force a refresh of the worker pool for testing.
-		_, err = r.RenterWorkersGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		// Check the health against the expectation.
-		// We expect len(hosts)-1 best entries since one entry is
-		// outdated and does therefore not count towards the health.
-		return assertHealth(skymodules.RegistryEntryHealth{
-			RevisionNumber:             revision,
-			NumEntries:                 uint64(len(tg.Hosts())),
-			NumBestEntries:             uint64(len(tg.Hosts())) - 1,
-			NumBestEntriesBeforeCutoff: 2, // cutoff is at 3 of 4 hosts
-			NumBestPrimaryEntries:      1,
-		})
-	})
+// testHostsForRegistryUpdate is a basic smoke test for /skynet/registry/hosts.
+func testHostsForRegistryUpdate(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Get the hosts for updating the registry.
+	hg, err := r.HostsForRegistryUpdateGET()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Enable the delay dependency and test again. None of the lookups
-	// should return before the timeout.
-	deps.Enable()
-	err = assertHealth(skymodules.RegistryEntryHealth{
-		RevisionNumber:             revision,
-		NumEntries:                 uint64(len(tg.Hosts())),
-		NumBestEntries:             uint64(len(tg.Hosts())) - 1,
-		NumBestEntriesBeforeCutoff: 0,
-		NumBestPrimaryEntries:      1,
-	})
+	// Store them in a map for easier lookup.
+	hostMap := make(map[string]struct{})
+	for _, host := range hg.Hosts {
+		hostMap[host.Pubkey.String()] = struct{}{}
+	}
+
+	// Compare them to the active contracts.
+	rc, err := r.RenterAllContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(rc.ActiveContracts) != len(hg.Hosts) {
+		t.Fatal("wrong length", len(rc.ActiveContracts), len(hg.Hosts))
+	}
+	for _, c := range rc.ActiveContracts {
+		_, ok := hostMap[c.HostPublicKey.String()]
+		if !ok {
+			t.Fatal("wrong host")
+		}
+	}
 }
 
-// TestRegistryUpdateRead tests setting a registry entry and reading in through
-// the API.
-func TestRegistryUpdateRead(t *testing.T) {
+// TestRegistryReadRepair tests if reading a registry entry repairs the entry on
+// the network.
+func TestRegistryReadRepair(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
@@ -4493,6 +10606,7 @@ func TestRegistryUpdateRead(t *testing.T) {
 
 	// Create a testgroup.
 	groupParams := siatest.GroupParams{
+		Hosts:   renter.MinUpdateRegistrySuccesses,
 		Renters: 1,
 		Miners:  1,
 	}
@@ -4507,26 +10621,8 @@ func TestRegistryUpdateRead(t *testing.T) {
 	}()
 	r := tg.Renters()[0]
 
-	// Add hosts with a latency dependency.
-	deps := dependencies.NewDependencyHostBlockRPC()
-	deps.Disable()
-	host := node.HostTemplate
-	host.HostDeps = deps
-	_, err = tg.AddNodeN(host, renter.MinUpdateRegistrySuccesses)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Create some random skylinks to use later.
-	skylink1, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
-	if err != nil {
-		t.Fatal(err)
-	}
-	skylink2, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
-	if err != nil {
-		t.Fatal(err)
-	}
-	skylink3, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	// Create a random skylink.
+	skylink, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -4535,135 +10631,75 @@ func TestRegistryUpdateRead(t *testing.T) {
 	sk, pk := crypto.GenerateKeyPair()
 	var dataKey crypto.Hash
 	fastrand.Read(dataKey[:])
-	data1 := skylink1.Bytes()
-	data2 := skylink2.Bytes()
-	data3 := skylink3.Bytes()
-	srv1 := modules.NewRegistryValue(dataKey, data1, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
-	srv2 := modules.NewRegistryValue(dataKey, data2, 1, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 1
-	srv3 := modules.NewRegistryValue(dataKey, data3, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
+	data := skylink.Bytes()
+	srv := modules.NewRegistryValue(dataKey, data, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
 	spk := types.SiaPublicKey{
 		Algorithm: types.SignatureEd25519,
 		Key:       pk[:],
 	}
 
-	// Force a refresh of the worker pool for testing.
-	_, err = r.RenterWorkersGet()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Try to read it from the host. Shouldn't work.
-	_, err = r.RegistryRead(spk, dataKey)
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
-		t.Fatal(err)
-	}
-
 	// Update the regisry.
-	err = r.RegistryUpdate(spk, dataKey, srv1.Revision, srv1.Signature, skylink1)
+	err = r.RegistryUpdate(spk, dataKey, srv.Revision, srv.Signature, skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Read it again. This should work.
-	readSRV, err := r.RegistryRead(spk, dataKey)
+	// Check the health of the entry.
+	reh, err := r.RegistryEntryHealth(spk, dataKey)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(srv1, readSRV) {
-		t.Log(srv1)
-		t.Log(readSRV)
-		t.Fatal("srvs don't match")
+	if reh.NumBestEntries != uint64(renter.MinUpdateRegistrySuccesses) {
+		t.Fatal("wrong number of entries", reh.NumBestEntries, renter.MinUpdateRegistrySuccesses)
 	}
 
-	// Update the registry again, with a higher revision.
-	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
+	// Add another host. This host won't have the entry.
+	_, err = tg.AddNodeN(node.HostTemplate, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Read it again. This should work.
-	readSRV, err = r.RegistryRead(spk, dataKey)
+	// Read the entry. This should work and also repair the new host.
+	readSRV, err := r.RegistryRead(spk, dataKey)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(srv2, readSRV) {
-		t.Log(srv2)
+	if !reflect.DeepEqual(srv, readSRV) {
+		t.Log(srv)
 		t.Log(readSRV)
 		t.Fatal("srvs don't match")
 	}
 
-	// Read it again with a almost zero timeout. This should time out.
-	deps.Enable()
-	start := time.Now()
-	readSRV, err = r.RegistryReadWithTimeout(spk, dataKey, time.Second)
-	deps.Disable()
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryLookupTimeout.Error()) {
-		t.Fatal(err)
-	}
-
-	// Make sure it didn't take too long and timed out.
-	if time.Since(start) > 2*time.Second {
-		t.Fatalf("read took too long to time out %v > %v", time.Since(start), 2*time.Second)
-	}
-
-	// Update the registry again, with the same revision and same PoW. Should
-	// work.
-	err = r.RegistryUpdate(spk, dataKey, srv2.Revision, srv2.Signature, skylink2)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Update the registry again. With the same revision but higher PoW. Should work.
-	srvHigherPoW := srv2
-	slHigherPow := skylink2
-	for !srvHigherPoW.HasMoreWork(srv2.RegistryValue) {
-		sl, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	// Check the health of the entry again. Should find 1 more entry.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		reh, err = r.RegistryEntryHealth(spk, dataKey)
 		if err != nil {
-			t.Fatal(err)
+			return err
 		}
-		srvHigherPoW.Data = sl.Bytes()
-		srvHigherPoW = srvHigherPoW.Sign(sk)
-		slHigherPow = sl
-	}
-	err = r.RegistryUpdate(spk, dataKey, srvHigherPoW.Revision, srvHigherPoW.Signature, slHigherPow)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Update the registry again, with a lower revision. Shouldn't work.
-	err = r.RegistryUpdate(spk, dataKey, srv3.Revision, srv3.Signature, skylink3)
-	if err == nil || !strings.Contains(err.Error(), modules.ErrLowerRevNum.Error()) {
-		t.Fatal(err)
-	}
-
-	// Update the registry again, with an invalid sig. Shouldn't work.
-	var invalidSig crypto.Signature
-	fastrand.Read(invalidSig[:])
-	err = r.RegistryUpdate(spk, dataKey, srv3.Revision, invalidSig, skylink3)
-	if err == nil || !strings.Contains(err.Error(), crypto.ErrInvalidSignature.Error()) {
+		if reh.NumBestEntries != uint64(renter.MinUpdateRegistrySuccesses)+1 {
+			return fmt.Errorf("wrong number of entries %v != %v", reh.NumBestEntries, renter.MinUpdateRegistrySuccesses+1)
+		}
+		return nil
+	})
+	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-// TestSkynetSkyfileStandardUploadRedundancy is a regression test that verifies
-// the race that occurred in the overdrive code is properly fixed by ensuring
-// the PDC is not accessed from more than one thread. This is a custom test
-// seeing as it requires a 10-30 EC schema for the overdrive code to launch
-// extra overdrive workers immediatelely after launching the initial workers.
-// This requires a test group with at least 10 hosts and a portal with a custom
-// dependency seeing as we don't allow (yet) to configure the fanout redundancy.
-func TestSkynetSkyfileStandardUploadRedundancy(t *testing.T) {
+// TestRegistrySubscription runs registry subscription related tests.
+func TestRegistrySubscription(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
+	testDir := skynetTestDir(t.Name())
 
 	// Create a testgroup.
 	groupParams := siatest.GroupParams{
-		Hosts:  10,
-		Miners: 1,
+		Hosts:   renter.MinUpdateRegistrySuccesses,
+		Portals: 1,
+		Miners:  1,
 	}
-	testDir := skynetTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
 		t.Fatal(err)
@@ -4673,251 +10709,410 @@ func TestSkynetSkyfileStandardUploadRedundancy(t *testing.T) {
 			t.Fatal(err)
 		}
 	}()
+	p := tg.Portals()[0]
 
-	// Add a new renter with a dependency that uploads using a 10-30 EC schema.
-	rt := node.RenterTemplate
-	rt.Allowance = siatest.DefaultAllowance
-	rt.Allowance.Hosts = 10
-	rt.Allowance.PaymentContractInitialFunding = siatest.DefaultPaymentContractInitialFunding
-	rt.RenterDeps = &dependencies.DependencyStandardUploadRedundancy{}
-	nodes, err := tg.AddNodes(rt)
-	if err != nil {
-		t.Fatal(err)
-	}
-	r := nodes[0]
-
-	// Upload a large file
-	ss := modules.SectorSize
-	skylink, _, _, err := r.UploadNewSkyfileBlocking("largefile", ss*2, false)
-	if err != nil {
-		t.Fatal(err)
-	}
+	// Run basic test.
+	t.Run("Basic", func(t *testing.T) {
+		testRegistrySubscriptionBasic(t, p)
+	})
+	t.Run("Delays", func(t *testing.T) {
+		testRegistrySubscriptionDelays(t, p)
+	})
+	t.Run("Params", func(t *testing.T) {
+		testRegistrySubscriptionParams(t, p)
+	})
+}
 
-	// Download the file, this used to trigger a race to be detected.
-	_, err = r.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
+// testRegistrySubscriptionParams tests that invalid parameters are rejected
+// before the connection is upgraded to a websocket connection.
+func testRegistrySubscriptionParams(t *testing.T, p *siatest.TestNode) {
+	for _, path := range []string{"/skynet/registry/subscription", "/skynet/registry/subscribe"} {
+		req, err := http.NewRequest(http.MethodGet, "http://"+p.Address+path+"?notificationdelay=0", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", "Sia-Agent")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var apiErr api.Error
+		err = json.NewDecoder(res.Body).Decode(&apiErr)
+		err = errors.Compose(err, res.Body.Close())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusBadRequest || !strings.Contains(apiErr.Message, "bandwidthlimit param not specified") {
+			t.Fatal("unexpected response", path, res.StatusCode, apiErr.Message)
+		}
 	}
 }
 
-// TestSkynetCleanupOnError verifies files are cleaned up on upload error
-func TestSkynetCleanupOnError(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+// testRegistrySubscriptionBasic tests the basic case of subscribing to
+// an entry and then updating it.
+func testRegistrySubscriptionBasic(t *testing.T, p *siatest.TestNode) {
+	// Collect notifications in a slice.
+	var notifications []skymodules.RegistryEntry
+	var notificationMu sync.Mutex
+	notifyFunc := func(entry skymodules.RegistryEntry) {
+		if err := entry.Verify(); err != nil {
+			t.Fatal("failed to verify entry", err)
+		}
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		notifications = append(notifications, entry)
 	}
-	t.Parallel()
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  3,
-		Miners: 1,
+	// Start the subscription.
+	closeHandler := func(_ int, msg string) error {
+		// We are going to close the subscription gracefully so the
+		// close handler shouldn't be called on our end.
+		t.Fatal("Close handler called:", msg)
+		return nil
 	}
-	testDir := skynetTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	subscription, err := p.BeginRegistrySubscription(notifyFunc, closeHandler)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err := tg.Close(); err != nil {
+		if err := subscription.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	// Create a dependency that interrupts uploads.
-	deps := dependencies.NewDependencySkyfileUploadFail()
+	// Set an entry.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	srv1 := modules.NewRegistryValue(tweak, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	err = p.RegistryUpdateWithEntry(spk, srv1)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	// Add a new renter with that dependency to interrupt skyfile uploads.
-	rt := node.RenterTemplate
-	rt.Allowance = siatest.DefaultAllowance
-	rt.Allowance.PaymentContractInitialFunding = siatest.DefaultPaymentContractInitialFunding
-	rt.RenterDeps = deps
-	nodes, err := tg.AddNodes(rt)
+	// Subscribe to that entry.
+	err = subscription.Subscribe(spk, srv1.Tweak)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
 
-	// Create a helper function that returns true if the upload failed
-	uploadFailed := func(err error) bool {
-		return err != nil && strings.Contains(err.Error(), "SkyfileUploadFail")
+	// There should be 1 notification now.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		if len(notifications) != 1 {
+			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Create a helper function that returns true if the siapath does not exist.
-	skyfileDeleted := func(err error) bool {
-		return err != nil && strings.Contains(err.Error(), filesystem.ErrNotExist.Error())
+	// Subscribe again to the same entry.
+	err = subscription.Subscribe(spk, srv1.Tweak)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Upload a small file
-	_, small, _, err := r.UploadNewSkyfileBlocking("smallfile", 100, false)
-	if !uploadFailed(err) {
-		t.Fatal("unexpected error on uploading a small file", err)
+	// There should be 2 notifications now.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		if len(notifications) != 1 {
+			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	smallPath, err := skymodules.SkynetFolder.Join(small.SiaPath.String())
+
+	// Increase the revision number and set again.
+	srv2 := srv1
+	srv2.Revision++
+	srv2 = srv2.Sign(sk)
+	err = p.RegistryUpdateWithEntry(spk, srv2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterFileRootGet(smallPath)
-	if !skyfileDeleted(err) {
-		t.Fatal("unexpected error on getting root for a small file", err)
+
+	// There should be 3 notifications now.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		if len(notifications) != 3 {
+			return fmt.Errorf("notifications: %v != %v", len(notifications), 3)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Upload a large file
-	ss := modules.SectorSize
-	_, large, _, err := r.UploadNewSkyfileBlocking("largefile", ss*2, false)
-	if !uploadFailed(err) {
-		t.Fatal("unexpected error on uploading a large file", err)
+	// Unsubscribe
+	err = subscription.Unsubscribe(spk, srv2.Tweak)
+	if err != nil {
+		t.Fatal(err)
 	}
-	largePath, err := skymodules.SkynetFolder.Join(large.SiaPath.String())
+
+	// Increase the revision number and set again.
+	srv3 := srv2
+	srv3.Revision++
+	srv3 = srv3.Sign(sk)
+	err = p.RegistryUpdateWithEntry(spk, srv3)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterFileRootGet(largePath)
-	if !skyfileDeleted(err) {
-		t.Fatal("unexpected error on getting root for a large file", err)
+
+	// Wait for a bit to give the notification some time.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// There should still be 3 notifications.
+		notificationMu.Lock()
+		nNotifications := len(notifications)
+		notificationMu.Unlock()
+		if nNotifications != 3 {
+			return fmt.Errorf("notifications: %v != %v", len(notifications), 3)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	largePathExtended, err := largePath.AddSuffixStr(skymodules.ExtendedSuffix)
+
+	// Make sure first notification matches.
+	if !reflect.DeepEqual(srv1, notifications[0].SignedRegistryValue) {
+		t.Log(srv1)
+		t.Log(notifications[0].SignedRegistryValue)
+		t.Fatal("notification mismatch")
+	}
+	// Make sure second notification matches.
+	if !reflect.DeepEqual(srv1, notifications[1].SignedRegistryValue) {
+		t.Log(srv1)
+		t.Log(notifications[1].SignedRegistryValue)
+		t.Fatal("notification mismatch")
+	}
+	// Make sure third notification matches.
+	if !reflect.DeepEqual(srv2, *&notifications[2].SignedRegistryValue) {
+		t.Log(srv2)
+		t.Log(notifications[2].SignedRegistryValue)
+		t.Fatal("notification mismatch")
+	}
+}
+
+// testRegistrySubscriptionDelays tests that the bandwidth limit and general
+// delays for notifications are working as expected.
+func testRegistrySubscriptionDelays(t *testing.T, p *siatest.TestNode) {
+	// Collect notifications in a slice.
+	var notifications []time.Time
+	var notificationMu sync.Mutex
+	notifyFunc := func(entry skymodules.RegistryEntry) {
+		if err := entry.Verify(); err != nil {
+			t.Fatal("failed to verify entry", err)
+		}
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		notifications = append(notifications, time.Now())
+	}
+
+	// Start the subscription.
+	closeHandler := func(_ int, msg string) error {
+		// We are going to close the subscription gracefully so the
+		// close handler shouldn't be called on our end.
+		t.Fatal("Close handler called:", msg)
+		return nil
+	}
+	delay := 500 * time.Millisecond                                 // 0.5 seconds per notification
+	limit := uint64(api.RegistrySubscriptionNotificationSize * 0.5) // 0.5 notifications per second
+	subscription, err := p.BeginRegistrySubscriptionCustom(limit, delay, notifyFunc, closeHandler)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterFileRootGet(largePathExtended)
-	if !skyfileDeleted(err) {
-		t.Fatal("unexpected error on getting root for a large file extended", err)
-	}
-
-	// Disable the dependency and verify the files are not removed
-	deps.Disable()
+	defer func() {
+		if err := subscription.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
 
-	// Re-upload the small file and re-test
-	_, small, _, err = r.UploadNewSkyfileBlocking("smallfile", 100, true)
+	// Set two entries.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	srv1 := modules.NewRegistryValue(crypto.Hash{1}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	srv2 := modules.NewRegistryValue(crypto.Hash{2}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	err = p.RegistryUpdateWithEntry(spk, srv1)
 	if err != nil {
-		t.Fatal("re-uploading a small file should succeed", err)
+		t.Fatal(err)
 	}
-	_, err = r.RenterSkyfileGet(small.SiaPath, small.Root)
+	err = p.RegistryUpdateWithEntry(spk, srv2)
 	if err != nil {
-		t.Fatal("unexpected error on getting root for a small file", err)
+		t.Fatal(err)
 	}
 
-	// Re-upload the large file and re-test
-	_, large, _, err = r.UploadNewSkyfileBlocking("largefile", ss*2, true)
-	if err != nil {
-		t.Fatal("re-uploading a large file should succeed", err)
-	}
-	_, err = r.RenterSkyfileGet(large.SiaPath, large.Root)
+	// Subscribe to those entries and measure the time. This will trigger a
+	// notification with a delay and another notification with a delay + an
+	// extended delay since the notifications arrived at about the same time.
+	start1 := time.Now()
+	err = subscription.Subscribe(spk, srv1.Tweak)
 	if err != nil {
-		t.Fatal("unexpected error on getting root for a large file", err)
+		t.Fatal(err)
 	}
-	largePathExtended, err = large.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	start2 := time.Now()
+	err = subscription.Subscribe(spk, srv2.Tweak)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = r.RenterSkyfileGet(largePathExtended, large.Root)
+
+	// There should be 2 notifications now.
+	err = build.Retry(1000, 10*time.Millisecond, func() error {
+		notificationMu.Lock()
+		defer notificationMu.Unlock()
+		if len(notifications) != 2 {
+			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
+		}
+		// The first notification should have taken 500 ms to arrive. To
+		// account for inaccuracies we check for 500ms < d < 1s.
+		d := notifications[0].Sub(start1)
+		if d < delay || d >= time.Second {
+			t.Fatalf("1: wrong delay: %v < %v < %v", delay, d, time.Second)
+		}
+		// The second notification should have taken 500ms to arrive +
+		// the time between notifications specified by the bandwidth
+		// limit which is 2s since we are doing 0.5 notifications per
+		// second. So 2.5 seconds should have passed because we already
+		// waited 500ms for the first notification and then the second
+		// notification is delayed by another 500ms + 1.5s to get to
+		// 2 seconds between notifications.
+		d = notifications[1].Sub(start2)
+		if d < time.Second*5/2 || d >= time.Second*3 {
+			t.Fatalf("2: wrong delay: %v < %v < %v", time.Second*5/2, d, time.Second*3)
+		}
+		return nil
+	})
 	if err != nil {
-		t.Fatal("unexpected error on getting root for a large file extended", err)
+		t.Fatal(err)
 	}
 }
 
-// TestReadUnknownRegistryEntry makes sure that reading an unknown entry takes
-// the appropriate amount of time.
-func TestReadUnknownRegistryEntry(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
-	testDir := skynetTestDir(t.Name())
+// testRecursiveBaseSector is a integration test for uploading a file which has
+// more metadata + fanout than would fit into the base sector without the
+// recursive extension.
+func testRecursiveBaseSector(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  1,
-		Miners: 1,
+	// Choose a filename that is >SectorSize to guarantee we exceed the base
+	// sector. Keep the content small to make it a small upload.
+	data := fastrand.Bytes(10)
+	largeName := hex.EncodeToString(fastrand.Bytes(int(modules.SectorSize) + 1))
+	sp := skymodules.RandomSkynetFilePath()
+
+	// Add a skykey. That way we also confirm that the encryption is
+	// working.
+	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
 	}
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+
+	// Add another renter.
+	nodes, err := tg.AddNodes(node.RenterTemplate)
 	if err != nil {
 		t.Fatal(err)
 	}
+	r2 := nodes[0]
 	defer func() {
-		if err := tg.Close(); err != nil {
+		if err := tg.RemoveNode(r2); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	rt := node.RenterTemplate
-	rt.RenterDeps = &dependencies.DependencyReadRegistryBlocking{}
-	nodes, err := tg.AddNodes(rt)
-	if err != nil {
+	if err := r2.SkykeyAddKeyPost(sk); err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
 
-	// Get a random pubkey.
-	var spk types.SiaPublicKey
-	fastrand.Read(spk.Key)
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:             sp,
+		BaseChunkRedundancy: 2,
+		Filename:            largeName,
+		Mode:                skymodules.DefaultFilePerm,
+		Reader:              bytes.NewReader(data),
+		Force:               false,
+		Root:                true,
+		SkykeyName:          t.Name(),
+	}
 
-	// Look it up.
-	start := time.Now()
-	_, err = r.RegistryRead(spk, crypto.Hash{})
-	passed := time.Since(start)
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
+	// upload a skyfile
+	skylink, _, err := r.SkynetSkyfilePost(sup)
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	// The time should have been less than MaxRegistryReadTimeout but greater
-	// than readRegistryBackgroundTimeout.
-	if passed >= renter.MaxRegistryReadTimeout || passed <= renter.ReadRegistryBackgroundTimeout {
-		t.Fatalf("%v not between %v and %v", passed, renter.ReadRegistryBackgroundTimeout, renter.MaxRegistryReadTimeout)
+	downloadedData, err := r.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// The remainder of the test might take a while. Only execute it in vlong
-	// tests.
-	if !build.VLONG {
-		t.SkipNow()
+	if !bytes.Equal(data, downloadedData) {
+		t.Fatalf("data mismatch %v %v", len(data), len(downloadedData))
 	}
 
-	// Run 200 reads to lower the p99 below the seed. Do it in batches of 10
-	// reads.
-	var wg sync.WaitGroup
-	for i := 0; i < 20; i++ {
-		for j := 0; j < 10; j++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				_, err := r.RegistryRead(spk, crypto.Hash{})
-				if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
-					t.Error(err)
-				}
-			}()
-		}
-		wg.Wait()
+	// Fetch the metadata.
+	_, md, err := r.SkynetMetadataGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(md.Length) != len(data) {
+		t.Fatal("md.Length is wrong", md.Length)
+	}
+	if md.Filename != largeName {
+		t.Fatal("name is wrong")
 	}
 
-	// Verify that the estimate is lower than the timeout after multiple reads
-	// with slow hosts.
-	err = build.Retry(60, time.Second, func() error {
-		ss, err := r.SkynetStatsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if ss.RegistryRead15mP99ms >= float64(renter.ReadRegistryBackgroundTimeout)/float64(time.Millisecond) {
-			return fmt.Errorf("%v >= %v", ss.RegistryRead15mP99ms, renter.ReadRegistryBackgroundTimeout)
-		}
-		return nil
+	// Try pinning the file with the new renter.
+	_, err = r2.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{
+		SiaPath: sp,
+		Root:    true,
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// Both base sector .sia files should have the same size. This verifies
+	// that we added the baseSectorExtension when we pinned the file.
+	f1, err := r.RenterFileRootGet(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := r2.RenterFileRootGet(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f1.File.Size() != f2.File.Size() {
+		t.Fatal("size mismatch", f1.File.Size(), f2.File.Size())
+	}
+
+	// Check the actual size. With the settings of this test, raw metadata
+	// and fanout should have a length of 8413 bytes. Uploading that results
+	// in 3 merkle roots which all fit into the original base sector. So the
+	// resulting size is one sector plus 8413 bytes.
+	extensionSize := int64(8413)
+	if f1.File.Size() != int64(modules.SectorSize)+extensionSize {
+		t.Fatal("wrong size", f1.File.Size())
+	}
 }
 
-// TestSkynetFeePaid tests that the renter calls paySkynetFee. The various edge
-// cases of paySkynetFee and the exact amounts are tested within its unit test.
-func TestSkynetFeePaid(t *testing.T) {
+// TestSkynetFailedPin is a regression test that catches an edge case in the pin
+// code where the Pin is successful even if the portal is unable to download the
+// skylink.
+func TestSkynetFailedPin(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	testDir := skynetTestDir(t.Name())
 
-	// Create a testgroup.
+	// Create test group
+	testDir := skynetTestDir(t.Name())
 	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
+		Hosts:   3,
+		Portals: 1,
+		Miners:  1,
 	}
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
@@ -4928,892 +11123,1278 @@ func TestSkynetFeePaid(t *testing.T) {
 			t.Fatal(err)
 		}
 	}()
-	// Create an independent wallet node.
-	wallet, err := siatest.NewCleanNode(node.Wallet(testDir))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer func() {
-		if err := wallet.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Connect it to the group.
-	err = wallet.GatewayConnectPost(tg.Hosts()[0].GatewayAddress())
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// It should start with a 0 balance.
-	balance, err := wallet.ConfirmedBalance()
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !balance.IsZero() {
-		t.Fatal("balance should be 0")
-	}
 
-	// Get an address.
-	wag, err := wallet.WalletAddressGet()
-	if err != nil {
-		t.Fatal(err)
-	}
+	// Grab clean portals
+	cleanPortal := tg.Portals()[0]
 
-	// Create a new renter that thinks the wallet's address is the fee address.
+	// Add a portal with a dependency to not upload the fanout
 	rt := node.RenterTemplate
-	deps := &dependencies.DependencyCustomSkynetAddress{}
-	deps.SetAddress(wag.Address)
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencyDoNotUploadFanout()
 	rt.RenterDeps = deps
 	nodes, err := tg.AddNodes(rt)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
 
-	// Upload a file.
-	_, _, err = r.UploadNewFileBlocking(100, 1, 1, false)
+	// Grab dependency portal
+	depPortal := nodes[0]
+	deps.Disable()
+
+	// Upload large file to depPortal and download and pin on clean portal
+	skylink, _, _, err := depPortal.UploadNewSkyfileBlocking("largeFile", 2*modules.SectorSize, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Wait for the next payout.
-	time.Sleep(skymodules.SkynetFeePayoutInterval)
-
-	// Mine a block to confirm the txn.
-	err = tg.Miners()[0].MineBlock()
+	_, err = cleanPortal.SkynetSkylinkGet(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spp := skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	}
+	_, err = cleanPortal.SkynetSkylinkPinPost(skylink, spp)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Wait for some money to show up on the address.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		balance, err := wallet.ConfirmedBalance()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if balance.IsZero() {
-			return errors.New("balance is zero")
-		}
-		return nil
-	})
+	// Upload a large file that doesn't get it's fanout uploaded
+	deps.Enable()
+	skylink, _, _, err = depPortal.UploadNewSkyfileBlocking("badLargeFile", 2*modules.SectorSize, false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	// Download should fail
+	_, err = cleanPortal.SkynetSkylinkGet(skylink)
+	if err == nil {
+		t.Fatal("Download should fail")
+	}
+	// Pin should fail
+	siaPath := skymodules.RandomSiaPath()
+	spp = skymodules.SkyfilePinParameters{
+		SiaPath: siaPath,
+	}
+	_, err = cleanPortal.SkynetSkylinkPinPost(skylink, spp)
+	if err == nil {
+		t.Fatal("Pin should fail")
+	}
+
+	// Confirm siafile is deleted
+	_, err = cleanPortal.SkyfileGet(siaPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 }
 
-// TestSkynetDownloadPinnedSkyfile is a custom test to verify whether a portal
-// can still download a skyfile that was uploaded on portal A, pinned on portal
-// B and then manually removed from both portal's local filesystems.
-func TestSkynetDownloadPinnedSkyfile(t *testing.T) {
+// TestSkynetConvertSiafileAsync tests converting a siafile to a skyfile
+// asynchronously as well as cancelling an asynchronous conversion.
+func TestSkynetConvertSiafileAsync(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Create test group with two portals
+	// Create test group
+	testDir := skynetTestDir(t.Name())
 	groupParams := siatest.GroupParams{
-		Hosts:  5,
+		Hosts:  3,
 		Miners: 1,
 	}
-	groupDir := skynetTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = tg.Close()
-		if err != nil {
+		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
+
+	// Add a portal with a dependency to block the conversion.
 	rt := node.RenterTemplate
 	rt.CreatePortal = true
-	_, err = tg.AddNodeN(rt, 2)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Grab both portals
-	portals := tg.Portals()
-	p1 := portals[0]
-	p2 := portals[1]
-
-	// Upload a file to portal 1
-	filename := t.Name()
-	filedata := fastrand.Bytes(100)
-	skylink, sup, _, err := p1.UploadNewSkyfileWithDataBlocking(filename, filedata, false)
+	deps := dependencies.NewDependencyBlockSkyfileConversion()
+	deps.Disable()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
 	if err != nil {
 		t.Fatal(err)
 	}
+	r := nodes[0]
 
-	// Check the file is on the filesystem
-	originalFullPath, err := skymodules.SkynetFolder.Join(sup.SiaPath.String())
+	// Upload a multi-chunk siafile.
+	numChunks := uint64(3)
+	_, remoteFile, err := r.UploadNewFileBlocking(int(numChunks*modules.SectorSize), 1, 2, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	originalFile, err := p1.RenterFileRootGet(originalFullPath)
+	_, remoteData, err := r.DownloadByStream(remoteFile)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(originalFile.File.Skylinks) != 1 {
-		t.Fatal("expecting 1 skylink")
-	}
-	if originalFile.File.Skylinks[0] != skylink {
-		t.Fatal("skylink mismatch")
-	}
-
-	// Pin the file on portal 2
-	pinPath := skymodules.RandomSiaPath()
-	p2.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{
-		SiaPath: pinPath,
-	})
 
-	// Assert we can download the file from portal 2
-	data, err := p2.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !bytes.Equal(data, filedata) {
-		t.Fatal(err)
+	// Convert the file asynchronously and wait for the conversion to finish.
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath: skymodules.RandomSiaPath(),
 	}
-
-	// Check the file is on the filesystem
-	pinnedFullPath, err := skymodules.SkynetFolder.Join(pinPath.String())
+	schp, err := r.SkynetConvertSiafileToSkyfileAsyncPost(sup, remoteFile.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
-	pinnedFile, err := p2.RenterFileRootGet(pinnedFullPath)
+	var status skymodules.SkyfileConversionStatus
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status, err = r.SkynetConvertStatusGet(schp.ID)
+		if err != nil {
+			return err
+		}
+		if !status.Completed {
+			return errors.New("conversion not completed")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(pinnedFile.File.Skylinks) != 1 {
-		t.Fatal("expecting 1 skylink")
+	if status.Cancelled || status.Error != "" {
+		t.Fatal("unexpected status", status)
 	}
-	if pinnedFile.File.Skylinks[0] != skylink {
-		t.Fatal("skylink mismatch")
+	if status.ChunksConverted != numChunks || status.TotalChunks != numChunks {
+		t.Fatalf("expected %v of %v chunks to be converted but got %v of %v", numChunks, numChunks, status.ChunksConverted, status.TotalChunks)
 	}
 
-	// Delete the file and assert it is gone
-	err = p2.RenterFileDeleteRootPost(pinnedFullPath)
+	// The skylink should be downloadable.
+	fetchedData, err := r.SkynetSkylinkGet(status.Skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Make sure the file is no longer present.
-	_, err = p2.RenterFileRootGet(pinnedFullPath)
-	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatal("skyfile still present after deletion")
+	if !bytes.Equal(fetchedData, remoteData) {
+		t.Fatal("converted skylink data doesn't match remote data")
 	}
+	convertedSiaPath := sup.SiaPath
+	convertedSkylink := status.Skylink
 
-	// Assert we can download the file from both portals still
-	data, err = p1.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !bytes.Equal(data, filedata) {
-		t.Fatal(err)
-	}
-	data, err = p2.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if !bytes.Equal(data, filedata) {
-		t.Fatal(err)
+	// Cancelling a finished conversion should fail.
+	err = r.SkynetConvertCancelPost(schp.ID)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkyfileConversionFinished.Error()) {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Delete the original file on portal 1
-	err = p1.RenterFileDeleteRootPost(originalFullPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	// Make sure the file is no longer present.
-	_, err = p1.RenterFileRootGet(originalFullPath)
-	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatal("skyfile still present after deletion")
+	// Unknown conversions should return an error.
+	_, err = r.SkynetConvertStatusGet("unknown")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkyfileConversionNotFound.Error()) {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Assert we can download the file from both portals still
-	data, err = p1.SkynetSkylinkGet(skylink)
+	// A key without the upload scope can't fetch the status.
+	readKey, err := r.SkynetAPIKeyPost(skymodules.SkynetAPIKeyScopeRead)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(data, filedata) {
-		t.Fatal(err)
-	}
-	data, err = p2.SkynetSkylinkGet(skylink)
-	if err != nil {
-		t.Fatal(err)
+	readClient := r.Client
+	readClient.Password = readKey.Key
+	_, err = readClient.SkynetConvertStatusGet(schp.ID)
+	if err == nil || !strings.Contains(err.Error(), "is not allowed to access this endpoint") {
+		t.Fatal("expected status request to be forbidden", err)
 	}
-	if !bytes.Equal(data, filedata) {
+	if err := r.SkynetAPIKeyDeletePost(readKey.ID); err != nil {
 		t.Fatal(err)
 	}
-}
-
-// TestSkynetPinUnpin tests pinning and unpinning a skylink
-func TestSkynetPinUnpin(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
 
-	// Create group
-	// Create a testgroup with 2 portals.
-	groupParams := siatest.GroupParams{
-		Hosts:  5,
-		Miners: 1,
-	}
-	groupDir := skynetTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	// Start another conversion but block it after the first chunk.
+	deps.Enable()
+	sup.SiaPath = skymodules.RandomSiaPath()
+	schp, err = r.SkynetConvertSiafileToSkyfileAsyncPost(sup, remoteFile.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = tg.Close()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status, err = r.SkynetConvertStatusGet(schp.ID)
 		if err != nil {
-			t.Fatal(err)
+			return err
 		}
-	}()
-
-	// Add portals with custom dependency
-	rt := node.RenterTemplate
-	rt.CreatePortal = true
-	deps := dependencies.NewDependencySkipUnpinRequest()
-	rt.RenterDeps = deps
-	_, err = tg.AddNodeN(rt, 2)
+		if status.ChunksConverted == 0 {
+			return errors.New("no chunks converted yet")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Grab the portals
-	portals := tg.Portals()
-	p1 := portals[0]
-	p2 := portals[1]
-
-	// Test small Skyfile
-	t.Run("SmallFile", func(t *testing.T) {
-		testSkynetPinUnpin(t, p1, p2, 100, deps)
-	})
-	// Test Large Skyfile
-	t.Run("LargeFile", func(t *testing.T) {
-		testSkynetPinUnpin(t, p1, p2, 2*modules.SectorSize, deps)
-	})
-}
-
-// testSkynetPinUnpin tests pinning and unpinning a skylink
-func testSkynetPinUnpin(t *testing.T, p1, p2 *siatest.TestNode, fileSize uint64, deps *dependencies.DependencyWithDisableAndEnable) {
-	// Define helper function for checking the number of files
-	fileCheck := func(p1Expected, p2Expected uint64) error {
-		return build.Retry(100, 100*time.Millisecond, func() error {
-			rdg, err := p1.RenterDirRootGet(skymodules.RootSiaPath())
-			if err != nil {
-				return err
-			}
-			if rdg.Directories[0].AggregateNumFiles != p1Expected {
-				return fmt.Errorf("Portal 1 should have %v files but has %v", p1Expected, rdg.Directories[0].AggregateNumFiles)
-			}
-			rdg, err = p2.RenterDirRootGet(skymodules.RootSiaPath())
-			if err != nil {
-				return err
-			}
-			if rdg.Directories[0].AggregateNumFiles != p2Expected {
-				return fmt.Errorf("Portal 2 should have %v files but has %v", p2Expected, rdg.Directories[0].AggregateNumFiles)
-			}
-			return nil
-		})
+	if status.Completed || status.ChunksConverted == numChunks {
+		t.Fatal("conversion shouldn't be done", status)
 	}
 
-	// Makes sure the test is starting with the dependency enabled.
-	deps.Enable()
-
-	// Verify that the portals are starting with 0 files
-	err := fileCheck(0, 0)
+	// Cancel the conversion.
+	err = r.SkynetConvertCancelPost(schp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status, err = r.SkynetConvertStatusGet(schp.ID)
+		if err != nil {
+			return err
+		}
+		if !status.Cancelled {
+			return errors.New("conversion not cancelled")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if status.Completed || status.Skylink != "" {
+		t.Fatal("unexpected status", status)
+	}
 
-	// Upload from one portal
-	fileName := "pinnedFile"
-	skylink, sup, _, err := p1.UploadNewSkyfileBlocking(fileName, fileSize, false)
+	// No skyfile should remain.
+	_, err = r.SkyfileGet(sup.SiaPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	extendedPath, err := sup.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
 	if err != nil {
 		t.Fatal(err)
 	}
-	siaPath := sup.SiaPath
+	_, err = r.SkyfileGet(extendedPath)
+	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 
-	// A call to unpin with a random siapath should be a no-op with the dependency enabled.
-	err = p1.SkynetSkylinkUnpinCustomPost(skylink, skymodules.RandomSiaPath())
+	// Force another conversion over the skyfile of the first conversion and
+	// cancel it. The existing skyfile wasn't replaced yet so it should
+	// remain.
+	sup.SiaPath = convertedSiaPath
+	sup.Force = true
+	schp, err = r.SkynetConvertSiafileToSkyfileAsyncPost(sup, remoteFile.SiaPath())
 	if err != nil {
 		t.Fatal(err)
 	}
-	p1Expected := uint64(1)
-	isLargeFile := fileSize > modules.SectorSize
-	if isLargeFile {
-		p1Expected *= 2
-	}
-	err = fileCheck(p1Expected, 0)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status, err = r.SkynetConvertStatusGet(schp.ID)
+		if err != nil {
+			return err
+		}
+		if status.ChunksConverted == 0 {
+			return errors.New("no chunks converted yet")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// A call to unpin with the correct siaPath should delete the file.
-	fullSiaPath, err := skymodules.SkynetFolder.Join(siaPath.String())
+	err = r.SkynetConvertCancelPost(schp.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = p1.SkynetSkylinkUnpinCustomPost(skylink, fullSiaPath)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status, err = r.SkynetConvertStatusGet(schp.ID)
+		if err != nil {
+			return err
+		}
+		if !status.Cancelled {
+			return errors.New("conversion not cancelled")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = fileCheck(0, 0)
+	rf, err := r.SkyfileGet(convertedSiaPath)
+	if err != nil {
+		t.Fatal("existing skyfile was deleted", err)
+	}
+	if len(rf.File.Skylinks) != 1 || rf.File.Skylinks[0] != convertedSkylink {
+		t.Fatal("unexpected skylinks", rf.File.Skylinks, convertedSkylink)
+	}
+	fetchedData, err = r.SkynetSkylinkGet(convertedSkylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !bytes.Equal(fetchedData, remoteData) {
+		t.Fatal("existing skylink data doesn't match remote data")
+	}
+}
 
-	// Disable the dependecy and re-upload the siafile
-	deps.Disable()
-	skylink, sup, _, err = p1.UploadNewSkyfileBlocking(fileName, fileSize, false)
+// testUpdateRegistryBatch tests updating multiple registry entries with a
+// single call to /skynet/registry/batch.
+func testUpdateRegistryBatch(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// newEntry is a helper to create a signed registry entry.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	newEntry := func(dataKey crypto.Hash, data []byte, revision uint64) skymodules.RegistryEntry {
+		srv := modules.NewRegistryValue(dataKey, data, revision, modules.RegistryTypeWithoutPubkey).Sign(sk)
+		return skymodules.NewRegistryEntry(spk, srv)
+	}
+
+	// Set a few entries to revision 1.
+	dataKeys := make([]crypto.Hash, 3)
+	existing := make([]skymodules.RegistryEntry, len(dataKeys))
+	for i := range dataKeys {
+		fastrand.Read(dataKeys[i][:])
+		existing[i] = newEntry(dataKeys[i], fastrand.Bytes(10), 1)
+		err := r.RegistryUpdateWithEntry(spk, existing[i].SignedRegistryValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create an entry with the same revision as an existing one which can't
+	// replace the existing one due to insufficient work.
+	var sameRevEntry skymodules.RegistryEntry
+	for {
+		sameRevEntry = newEntry(dataKeys[2], fastrand.Bytes(10), 1)
+		if existing[2].HasMoreWork(sameRevEntry.RegistryValue) {
+			break
+		}
+	}
+
+	// Prepare a batch that contains a valid update, an update with a lower
+	// revision, an update with the same revision, an update with too much
+	// data, an update for a new entry, an update with an unknown type and an
+	// update with pubkey that is too short to contain the pubkey hash.
+	var newDataKey crypto.Hash
+	fastrand.Read(newDataKey[:])
+	entries := []skymodules.RegistryEntry{
+		newEntry(dataKeys[0], fastrand.Bytes(10), 2),
+		newEntry(dataKeys[1], fastrand.Bytes(10), 0),
+		sameRevEntry,
+		newEntry(newDataKey, fastrand.Bytes(modules.RegistryDataSize+1), 0),
+		newEntry(newDataKey, fastrand.Bytes(10), 0),
+		skymodules.NewRegistryEntry(spk, modules.NewRegistryValue(newDataKey, fastrand.Bytes(10), 1, modules.RegistryTypeWithPubkey+1).Sign(sk)),
+		skymodules.NewRegistryEntry(spk, modules.NewRegistryValue(newDataKey, fastrand.Bytes(10), 1, modules.RegistryTypeWithPubkey).Sign(sk)),
+	}
+	rhbp, err := r.RegistryUpdateBatch(entries)
 	if err != nil {
 		t.Fatal(err)
 	}
-	siaPath = sup.SiaPath
-	fullSiaPath, err = skymodules.SkynetFolder.Join(siaPath.String())
+	if len(rhbp.Results) != len(entries) {
+		t.Fatalf("expected %v results but got %v", len(entries), len(rhbp.Results))
+	}
+
+	// Check the results.
+	expected := []struct {
+		success    bool
+		statusCode int
+		errorCode  string
+	}{
+		{true, http.StatusOK, ""},
+		{false, http.StatusBadRequest, api.RegistryBatchErrorLowerRevNum},
+		{false, http.StatusBadRequest, api.RegistryBatchErrorInsufficientWork},
+		{false, http.StatusBadRequest, api.RegistryBatchErrorDataTooBig},
+		{true, http.StatusOK, ""},
+		{false, http.StatusBadRequest, api.RegistryBatchErrorUnknownType},
+		{false, http.StatusBadRequest, api.RegistryBatchErrorMalformedData},
+	}
+	for i, result := range rhbp.Results {
+		if result.Success != expected[i].success || result.StatusCode != expected[i].statusCode || result.ErrorCode != expected[i].errorCode {
+			t.Fatalf("%v: unexpected result %v", i, siatest.PrintJSON(result))
+		}
+		if result.Success != (result.Error == "") {
+			t.Fatalf("%v: unexpected error '%v'", i, result.Error)
+		}
+	}
+
+	// The successful updates should be readable.
+	for _, i := range []int{0, 4} {
+		srv, err := r.RegistryRead(spk, entries[i].Tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if srv.Revision != entries[i].Revision || !bytes.Equal(srv.Data, entries[i].Data) {
+			t.Fatalf("%v: unexpected entry %v", i, srv)
+		}
+	}
+
+	// Batches that are too large are rejected.
+	batch := make([]skymodules.RegistryEntry, api.MaxRegistryBatchSize+1)
+	for i := range batch {
+		batch[i] = entries[0]
+	}
+	_, err = r.RegistryUpdateBatch(batch)
+	if err == nil || !strings.Contains(err.Error(), "Too many registry entries") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// The single update endpoint validates the type as well.
+	err = r.RegistryUpdateWithEntry(spk, entries[5].SignedRegistryValue)
+	if err == nil || !strings.Contains(err.Error(), "Unknown registry entry type") {
+		t.Fatal("unexpected error", err)
+	}
+	err = r.RegistryUpdateWithEntry(spk, entries[6].SignedRegistryValue)
+	if err == nil || !strings.Contains(err.Error(), "needs to start with a") {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testRegistryKeys tests updating the registry with values that are signed by
+// the renter using a stored registry key.
+func testRegistryKeys(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Create a key.
+	key, err := r.RegistryKeyPost("testkey")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if key.Name != "testkey" {
+		t.Fatal("unexpected name", key.Name)
+	}
+	_, err = r.RegistryKeyPost("testkey")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryKeyExists.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 
-	// Try pinning the link as v2. Shouldn't work.
-	var slV1 skymodules.Skylink
-	err = slV1.LoadString(skylink)
+	// The key should be listed.
+	rkg, err := r.RegistryKeysGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	slV2, err := p1.NewSkylinkV2(slV1)
-	err = p1.SkynetSkylinkPinPost(slV2.String(), skymodules.SkyfilePinParameters{
-		SiaPath: skymodules.RandomSiaPath(),
-	})
-	if err == nil || !strings.Contains(err.Error(), "can't pin version 2 skylink") {
-		t.Fatal(err)
+	found := false
+	for _, k := range rkg.Keys {
+		if k.Name == key.Name && k.PublicKey.Equals(key.PublicKey) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("key wasn't listed", rkg.Keys)
 	}
+	var pk crypto.PublicKey
+	copy(pk[:], key.PublicKey.Key)
 
-	// Pin to the other portal a random number of times.
-	//
-	// This will test the case of the skylink being associated with multiple
-	// files.
-	numPins := 1 + fastrand.Intn(3)
-	var wg sync.WaitGroup
-	for i := 0; i < numPins; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			spp := skymodules.SkyfilePinParameters{
-				SiaPath: skymodules.RandomSiaPath(),
-			}
-			err := p2.SkynetSkylinkPinPost(skylink, spp)
-			if err != nil {
-				t.Error(err)
-				return
-			}
-		}()
+	// Publish two revisions of an entry and read them back.
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	for revision := uint64(0); revision < 2; revision++ {
+		rv := modules.NewRegistryValue(dataKey, fastrand.Bytes(10), revision, modules.RegistryTypeWithoutPubkey)
+		err = r.RegistryUpdateWithKeyName(key.Name, rv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv, err := r.RegistryRead(key.PublicKey, dataKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if srv.Revision != revision || !bytes.Equal(srv.Data, rv.Data) {
+			t.Fatal("unexpected entry", srv)
+		}
+		if err := srv.Verify(pk); err != nil {
+			t.Fatal(err)
+		}
 	}
-	wg.Wait()
 
-	// Rename the file on Portal 1
-	//
-	// This will test the case of the siapath changing after upload. This also
-	// pulls the siafile out of the skynet folder.
-	err = p1.RenterRenamePost(fullSiaPath, skymodules.RandomSiaPath(), true)
+	// Unknown keys are rejected.
+	rv := modules.NewRegistryValue(dataKey, fastrand.Bytes(10), 2, modules.RegistryTypeWithoutPubkey)
+	err = r.RegistryUpdateWithKeyName("unknown", rv)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryKeyNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Deleting the key requires confirmation.
+	err = r.RegistryKeyDeletePost(key.Name, false)
+	if err == nil || !strings.Contains(err.Error(), "requires 'confirm'") {
+		t.Fatal("unexpected error", err)
+	}
+	err = r.RegistryKeyDeletePost(key.Name, true)
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = r.RegistryUpdateWithKeyName(key.Name, rv)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryKeyNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
 
-	// Download from both
-	//
-	// NOTE: This helper also verified the bytes are equal
-	err = verifyDownloadByAll(p1, p2, skylink)
+// testSkynetPublish tests pointing a registry entry at new skylinks using the
+// /skynet/publish endpoint.
+func testSkynetPublish(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	key, err := r.RegistryKeyPost("publishkey")
 	if err != nil {
 		t.Fatal(err)
 	}
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	expectedV2 := skymodules.NewSkylinkV2(key.PublicKey, dataKey)
 
-	// Verify that each portal has the expected number of files.
-	p1Expected = uint64(1)
-	p2Expected := uint64(numPins)
-	if isLargeFile {
-		p1Expected *= 2
-		p2Expected *= 2
+	// publish is a helper to publish a skylink and check that the returned
+	// V2 skylink resolves to it.
+	publish := func(sl skymodules.Skylink, expectedRevision uint64) {
+		t.Helper()
+		spp, err := r.SkynetPublishPost(key.Name, dataKey, sl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spp.Skylink != expectedV2.String() {
+			t.Fatalf("expected %v but got %v", expectedV2, spp.Skylink)
+		}
+		if spp.Revision != expectedRevision {
+			t.Fatalf("expected revision %v but got %v", expectedRevision, spp.Revision)
+		}
+		resolved, err := r.ResolveSkylinkV2(spp.Skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved != sl.String() {
+			t.Fatalf("expected %v to resolve to %v but got %v", spp.Skylink, sl, resolved)
+		}
 	}
-	err = fileCheck(p1Expected, p2Expected)
+
+	// The first publish creates the entry, the following ones bump it.
+	sl1, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Unpin from both portals
-	err = p1.SkynetSkylinkUnpinPost(skylink)
+	sl2, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = p2.SkynetSkylinkUnpinPost(skylink)
+	publish(sl1, 0)
+	publish(sl2, 1)
+
+	// Publishing the current skylink again doesn't bump the revision.
+	publish(sl2, 1)
+
+	// V2 skylinks and unknown keys are rejected.
+	_, err = r.SkynetPublishPost(key.Name, dataKey, expectedV2)
+	if err == nil || !strings.Contains(err.Error(), "needs to be a V1 skylink") {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = r.SkynetPublishPost("unknown", dataKey, sl1)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryKeyNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// testSkynetRegistryLarge tests storing data larger than a single registry
+// entry using the /skynet/registry/large endpoint.
+func testSkynetRegistryLarge(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	key, err := r.RegistryKeyPost("largekey")
 	if err != nil {
 		t.Fatal(err)
 	}
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
 
-	// Unpin the v2 skylink. Shouldn't work.
-	err = p1.SkynetSkylinkUnpinPost(slV2.String())
-	if err == nil || !strings.Contains(err.Error(), "can't unpin version 2 skylink") {
+	// Reading data that was never stored should fail.
+	_, _, err = r.RegistryLargeGet(key.PublicKey, dataKey)
+	if err == nil {
+		t.Fatal("expected reading missing data to fail")
+	}
+
+	// store is a helper to store data and read it back.
+	store := func(data []byte, expectedRevision uint64) {
+		t.Helper()
+		rlp, err := r.RegistryLargePost(key.Name, dataKey, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rlp.Revision != expectedRevision {
+			t.Fatalf("expected revision %v but got %v", expectedRevision, rlp.Revision)
+		}
+		read, revision, err := r.RegistryLargeGet(key.PublicKey, dataKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(read, data) || revision != expectedRevision {
+			t.Fatal("unexpected data", len(read), revision)
+		}
+	}
+
+	// Store data spanning multiple entries, then replace it with data
+	// spanning fewer entries.
+	data1 := fastrand.Bytes(3*skymodules.LargeRegistryChunkSize + 10)
+	data2 := fastrand.Bytes(skymodules.LargeRegistryChunkSize + 1)
+	store(data1, 0)
+	store(data2, 1)
+
+	// Storing the same data again doesn't bump the revision.
+	store(data2, 1)
+
+	// The manifest entry can also be read as a regular entry.
+	srv, err := r.RegistryRead(key.PublicKey, dataKey)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Verify that all the siafiles have been deleted on both portals
-	err = fileCheck(0, 0)
+	manifest, err := skymodules.DecodeLargeRegistryManifest(srv.Data)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if manifest != skymodules.NewLargeRegistryManifest(data2) || srv.Revision != 1 {
+		t.Fatal("unexpected manifest entry", manifest, srv.Revision)
+	}
 
-	// Download from all. This still works because the data is still on the hosts.
-	err = verifyDownloadByAll(p1, p2, skylink)
+	// Regular entries aren't large registry data.
+	var otherDataKey crypto.Hash
+	fastrand.Read(otherDataKey[:])
+	rv := modules.NewRegistryValue(otherDataKey, fastrand.Bytes(10), 0, modules.RegistryTypeWithoutPubkey)
+	err = r.RegistryUpdateWithKeyName(key.Name, rv)
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, _, err = r.RegistryLargeGet(key.PublicKey, otherDataKey)
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrInvalidLargeRegistryManifest.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Data exceeding the max size and unknown keys are rejected.
+	_, err = r.RegistryLargePost(key.Name, dataKey, make([]byte, skymodules.LargeRegistryMaxDataSize+1))
+	if err == nil || !strings.Contains(err.Error(), skymodules.ErrLargeRegistryDataTooLarge.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = r.RegistryLargePost("unknown", dataKey, data1)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryKeyNotFound.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 }
 
-// testSkylinkV2Download tests downloading a file by its version 2 skylink.
-func testSkylinkV2Download(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+// TestSkynetBaseSectorAndRootHead verifies that HEAD requests on the
+// /skynet/basesector and /skynet/root endpoints check the availability of a
+// sector without downloading it.
+func TestSkynetBaseSectorAndRootHead(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
 
-	// Upload some data.
-	data := fastrand.Bytes(100)
-	slStr, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
+	// Create test group
+	testDir := skynetTestDir(t.Name())
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a portal with a dependency to count the sector reads.
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencyCountReadSector()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
 	if err != nil {
 		t.Fatal(err)
 	}
+	r := nodes[0]
 
-	var skylink skymodules.Skylink
-	err = skylink.LoadString(slStr)
+	// Upload a small skyfile.
+	skylinkStr, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), fastrand.Bytes(100), false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Create a v2 link.
-	skylinkV2, err := r.NewSkylinkV2(skylink)
+	skylink, err := skymodules.ParseSkylink(skylinkStr)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Download the file using that link.
-	downloadedDataV2, err := r.SkynetSkylinkGet(skylinkV2.String())
+	_, fetchSize, err := skylink.OffsetAndFetchSize()
 	if err != nil {
 		t.Fatal(err)
 	}
+	root := skylink.MerkleRoot()
 
-	// Download it using the v1 link.
-	downloadedDataV1, err := r.SkynetSkylinkGet(slStr)
+	// Check the base sector and root. No sector should be read.
+	before := deps.Count()
+	status, header, err := r.SkynetBaseSectorHead(skylinkStr, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Data should match.
-	if !bytes.Equal(downloadedDataV1, downloadedDataV2) {
-		t.Fatal("data doesn't match")
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
 	}
-	if !bytes.Equal(downloadedDataV1, data) {
-		t.Fatal("data doesn't match")
+	if header.Get("Content-Length") != fmt.Sprint(fetchSize) {
+		t.Fatal("unexpected content length", header.Get("Content-Length"))
 	}
-
-	// Create a recursive v2 link of the max depth.
-	recursiveLink := skylink
-	var links []skymodules.Skylink
-	for i := 0; i < int(renter.MaxSkylinkV2ResolvingDepth); i++ {
-		slv2, err := r.NewSkylinkV2(recursiveLink)
-		if err != nil {
-			t.Fatal(err)
-		}
-		recursiveLink = slv2.Skylink
-		links = append(links, slv2.Skylink)
+	if header.Get(api.SkynetSkylinkHeader) != skylinkStr {
+		t.Fatal("unexpected skylink header", header.Get(api.SkynetSkylinkHeader))
 	}
-
-	// Download the file using that link.
-	downloadedDataRecursive, err := r.SkynetSkylinkGet(recursiveLink.String())
+	status, header, err = r.SkynetRootHead(root, 0, fetchSize, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(downloadedDataV1, downloadedDataRecursive) {
-		t.Fatal("data doesn't match")
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	if header.Get("Content-Length") != fmt.Sprint(fetchSize) {
+		t.Fatal("unexpected content length", header.Get("Content-Length"))
+	}
+	if reads := deps.Count() - before; reads != 0 {
+		t.Fatalf("expected no sector reads but got %v", reads)
 	}
 
-	// Fetch the metadata and confirm the headers.
-	mdH, _, err := r.SkynetMetadataGet(recursiveLink.String())
+	// Unknown roots should return a 404.
+	randomLink, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// The skynet-skylink header should report the v1 skylink.
-	if sl := mdH.Get(api.SkynetSkylinkHeader); sl != skylink.String() {
-		t.Fatalf("wrong skylink %v != %v", sl, skylink.String())
+	status, _, err = r.SkynetBaseSectorHead(randomLink.String(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusNotFound {
+		t.Fatal("unexpected status", status)
+	}
+	status, _, err = r.SkynetRootHead(randomLink.MerkleRoot(), 0, fetchSize, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusNotFound {
+		t.Fatal("unexpected status", status)
 	}
 
-	// Fetch the header.
-	_, h, err := r.SkynetSkylinkHead(recursiveLink.String())
+	// Blocked skylinks should return a 451.
+	err = r.SkynetBlocklistPost([]string{skylinkStr}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// The skynet-skylink header should report the v1 skylink.
-	if sl := h.Get(api.SkynetSkylinkHeader); sl != skylink.String() {
-		t.Fatalf("wrong skylink %v != %v", sl, skylink.String())
+	status, _, err = r.SkynetBaseSectorHead(skylinkStr, 10)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// It should contain a valid proof.
-	var proof []api.RegistryHandlerGET
-	err = json.Unmarshal([]byte(h.Get(api.SkynetProofHeader)), &proof)
+	if status != http.StatusUnavailableForLegalReasons {
+		t.Fatal("unexpected status", status)
+	}
+	status, _, err = r.SkynetRootHead(root, 0, fetchSize, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	for i, rhg := range proof {
-		// Parse the element into a skymodules.RegistryEntry and verify
-		// it.
-		data, _ := hex.DecodeString(rhg.Data)
-		sigBytes, _ := hex.DecodeString(rhg.Signature)
-		var sig crypto.Signature
-		copy(sig[:], sigBytes)
-		v := modules.NewSignedRegistryValue(rhg.DataKey, data, rhg.Revision, sig, rhg.Type)
-		entry := skymodules.NewRegistryEntry(rhg.PublicKey, v)
-		if err := entry.Verify(); err != nil {
-			t.Fatal("verification failed", err)
+	if status != http.StatusUnavailableForLegalReasons {
+		t.Fatal("unexpected status", status)
+	}
+}
+
+// TestSkynetSparseUpload verifies that the zero chunks of a sparse skyfile
+// are neither uploaded to nor downloaded from hosts.
+func TestSkynetSparseUpload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create test group
+	testDir := skynetTestDir(t.Name())
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
 		}
+	}()
 
-		// Get the current v2 link that points to the parsed entry as well as the next
-		// link which is contained within its entry payload.
-		currentLink := skymodules.NewSkylinkV2(entry.PubKey, entry.Tweak)
-		var nextLink skymodules.Skylink
-		err = nextLink.LoadBytes(data)
+	// Add a portal with a dependency to count the sector reads and uploads.
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencyCountSectors()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+
+	// Create a file of 10 chunks where only chunks 0 and 5 contain data. With
+	// 1-of-3 erasure coding, every chunk is a single sector.
+	chunkSize := int(modules.SectorSize)
+	data := make([]byte, 10*chunkSize)
+	fastrand.Read(data[:chunkSize])
+	fastrand.Read(data[5*chunkSize : 6*chunkSize])
+
+	// waitForUploads is a helper that waits for the uploads to settle and
+	// returns the number of sectors uploaded since 'before'.
+	waitForUploads := func(before uint64) uint64 {
+		uploads := deps.Uploads()
+		err := build.Retry(100, 500*time.Millisecond, func() error {
+			current := deps.Uploads()
+			if current != uploads {
+				uploads = current
+				return errors.New("uploads not settled yet")
+			}
+			return nil
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
-		// The current link should match the link at the opposite end of
-		// the links slice.
-		if currentLink.String() != links[len(links)-i-1].String() {
-			t.Fatal("wrong link", currentLink, links[len(links)-i-1])
+		return uploads - before
+	}
+
+	// upload is a helper to upload the data.
+	upload := func(name string, sparse bool) string {
+		sup := skymodules.SkyfileUploadParameters{
+			SiaPath:             skymodules.RandomSkynetFilePath(),
+			BaseChunkRedundancy: 3,
+			Filename:            name,
+			Mode:                0640,
+			Reader:              bytes.NewReader(data),
+			DataPieces:          1,
+			ParityPieces:        2,
+			Sparse:              sparse,
 		}
-		// The nextLink should match the next one. Except for the last
-		// one which should match the origin v1 skylink.
-		if i == len(proof)-1 && nextLink != skylink {
-			t.Fatal("wrong link", nextLink, skylink)
-		} else if i < len(proof)-1 && nextLink.String() != links[len(links)-i-2].String() {
-			t.Fatal("wrong link", nextLink, links[len(links)-i-2])
+		skylink, _, err := r.SkynetSkyfilePost(sup)
+		if err != nil {
+			t.Fatal(err)
 		}
+		return skylink
 	}
 
-	// Add another level of recursion.
-	slv2, err := r.NewSkylinkV2(recursiveLink)
+	// Upload the file without the sparse option first. Every chunk is
+	// uploaded to every host.
+	before := deps.Uploads()
+	denseSkylink := upload("dense", false)
+	if uploads := waitForUploads(before); uploads < 30 {
+		t.Fatalf("expected at least 30 uploaded sectors but got %v", uploads)
+	}
+
+	// Upload the sparse file. Only the 2 chunks with data and the base sector
+	// should be uploaded.
+	before = deps.Uploads()
+	skylink := upload("sparse", true)
+	if uploads := waitForUploads(before); uploads > 3*3 {
+		t.Fatalf("expected at most %v uploaded sectors but got %v", 3*3, uploads)
+	}
+
+	// The encoding should show the zero chunks.
+	var sl skymodules.Skylink
+	if err := sl.LoadString(skylink); err != nil {
+		t.Fatal(err)
+	}
+	encoding, err := r.SkylinkEncodingGET(sl)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Download the file using that link. Should fail.
-	_, err = r.SkynetSkylinkGet(slv2.String())
-	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNesting.Error()) {
-		t.Fatal("should fail to resolve v2 skylink with more than 2 layers of recursion", err)
+	if encoding.NumChunks != 10 || encoding.ZeroChunks != 8 {
+		t.Fatalf("unexpected chunks %v %v", encoding.NumChunks, encoding.ZeroChunks)
 	}
 
-	// Resolve using resolve endpoint.
-	resolvedSkylink, h, err := r.ResolveSkylinkV2Custom(skylinkV2.String(), api.DefaultSkynetRequestTimeout)
+	// Pinning the sparse file shouldn't upload the zero chunks either.
+	before = deps.Uploads()
+	_, err = r.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{
+		SiaPath:             skymodules.RandomSiaPath(),
+		BaseChunkRedundancy: 3,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if resolvedSkylink != skylink.String() {
-		t.Fatal("skylink resolved wrong", resolvedSkylink, skylink.String())
+	if uploads := waitForUploads(before); uploads > 3*3 {
+		t.Fatalf("expected at most %v uploaded sectors for the pin but got %v", 3*3, uploads)
 	}
-	skynetSkylink := h.Get(api.SkynetSkylinkHeader)
-	if skynetSkylink != resolvedSkylink {
-		t.Fatalf("wrong skylink header %v != %v", skynetSkylink, resolvedSkylink)
+
+	// download is a helper to download a skylink and return the number of
+	// sectors read from hosts.
+	download := func(skylink string) uint64 {
+		before := deps.Reads()
+		downloaded, err := r.SkynetSkylinkGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded, data) {
+			t.Fatal("downloaded data doesn't match")
+		}
+		return deps.Reads() - before
 	}
 
-	// Update entry to empty skylink.
-	err = r.DeleteSkylinkV2(&skylinkV2)
-	if err != nil {
-		t.Fatal(err)
+	// Download both files. The zero chunks of the sparse file shouldn't be
+	// read from hosts.
+	denseReads := download(denseSkylink)
+	sparseReads := download(skylink)
+	if sparseReads >= denseReads {
+		t.Fatalf("expected fewer reads for the sparse file %v than for the dense file %v", sparseReads, denseReads)
 	}
 
-	// Download the file using v2 link again.
-	_, err = r.SkynetSkylinkGet(skylinkV2.String())
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
-		t.Fatal(err)
+	// Range requests into and across the zero regions should work as well.
+	ranges := []struct {
+		from, to uint64
+	}{
+		{uint64(chunkSize) + 10, 3 * uint64(chunkSize)},
+		{4*uint64(chunkSize) + 10, 5*uint64(chunkSize) + 10},
+		{5*uint64(chunkSize) + 10, 7 * uint64(chunkSize)},
+		{9 * uint64(chunkSize), 10 * uint64(chunkSize)},
 	}
-	// Resolve using resolve endpoint.
-	_, err = r.ResolveSkylinkV2(skylinkV2.String())
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
-		t.Fatal(err)
+	for _, rng := range ranges {
+		downloaded, err := r.SkynetSkylinkRange(skylink, rng.from, rng.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded, data[rng.from:rng.to]) {
+			t.Fatalf("range %v-%v doesn't match", rng.from, rng.to)
+		}
 	}
 }
 
-// TestSkynetSkylinkHealth tests the /skynet/health/skylink endpoint.
-func TestSkynetSkylinkHealth(t *testing.T) {
+// TestSkynetPrefetch verifies the functionality of the /skynet/prefetch
+// endpoints.
+func TestSkynetPrefetch(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Define the parameters.
+	// Create test group
+	testDir := skynetTestDir(t.Name())
 	groupParams := siatest.GroupParams{
-		Hosts:   modules.RenterDefaultDataPieces + modules.RenterDefaultParityPieces,
-		Miners:  1,
-		Renters: 1,
+		Hosts:  3,
+		Miners: 1,
 	}
-	groupDir := skynetTestDir(t.Name())
-
-	// Create a testgroup.
-	tg, err := siatest.NewGroupFromTemplate(groupDir, groupParams)
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
-		t.Fatal(errors.AddContext(err, "failed to create group"))
+		t.Fatal(err)
 	}
 	defer func() {
 		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	r := tg.Renters()[0]
 
-	// helper function for asserting health
-	assertHealth := func(skylink string, baseSectorRedundancy uint64, fanoutHealth float64) error {
-		// Get the health.
-		var sl skymodules.Skylink
-		if err := sl.LoadString(skylink); err != nil {
-			t.Fatal(err)
-		}
-		sh, err := r.SkylinkHealthGET(sl)
-		if err != nil {
-			return err
-		}
-		if sh.BaseSectorRedundancy != baseSectorRedundancy {
-			return fmt.Errorf("wrong base sector redundancy %v != %v", sh.BaseSectorRedundancy, baseSectorRedundancy)
-		}
-		if sh.FanoutEffectiveRedundancy != fanoutHealth {
-			return fmt.Errorf("fanout not healthy %v != %v", sh.FanoutEffectiveRedundancy, fanoutHealth)
-		}
-		dataPieces := uint8(skymodules.RenterDefaultDataPieces)
-		parityPieces := uint8(skymodules.RenterDefaultParityPieces)
-		if sh.FanoutDataPieces != dataPieces {
-			return fmt.Errorf("invalid datapieces %v != %v", sh.FanoutDataPieces, dataPieces)
-		}
-		if sh.FanoutParityPieces != parityPieces {
-			return fmt.Errorf("invalid paritypieces %v != %v", sh.FanoutParityPieces, parityPieces)
-		}
-		for i, health := range sh.FanoutRedundancy {
-			if health != fanoutHealth {
-				return fmt.Errorf("chunk %v not healthy %v != %v", i, health, fanoutHealth)
-			}
-		}
-		return nil
+	// Add a portal with a dependency to count the sector reads.
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencyCountReadSector()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
+	r := nodes[0]
 
-	// Upload a file with multiple chunks.
-	size := modules.SectorSize * 3
-	skylink, _, _, err := r.UploadNewSkyfileBlocking(t.Name(), size, false)
+	// Upload two small skyfiles.
+	coldSkylink, _, _, err := r.UploadNewSkyfileWithDataBlocking("cold", fastrand.Bytes(100), false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Assert its health. The piece should be on 5 hosts and only 1
-	// datapiece is needed so the health should be 5.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		return assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy, 5)
-	})
+	data := fastrand.Bytes(100)
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking("prefetched", data, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Upload another file but encrypted. This makes sure we test encrypted
-	// skylinks as well as fanouts that can't be compressed.
-	_, err = r.SkykeyCreateKeyPost("key", skykey.TypePrivateID)
+	// Download the first skyfile without prefetching it.
+	before := deps.Count()
+	_, err = r.SkynetSkylinkGet(coldSkylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	skylink2BaseSectorRedundancy := uint64(5)
-	skylink2, _, _, err := r.UploadSkyfileBlockingCustom(t.Name()+"2", fastrand.Bytes(int(size)), "key", uint8(skylink2BaseSectorRedundancy), false)
+	coldReads := deps.Count() - before
+	if coldReads == 0 {
+		t.Fatal("expected the download to read sectors from the hosts")
+	}
+
+	// prefetch is a helper that prefetches a skylink and waits for the
+	// prefetch to finish.
+	prefetch := func(skylink, depth string) skymodules.SkylinkPrefetchStatus {
+		sphp, err := r.SkynetPrefetchPost(skylink, depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var status skymodules.SkylinkPrefetchStatus
+		err = build.Retry(100, 100*time.Millisecond, func() error {
+			status, err = r.SkynetPrefetchStatusGet(sphp.ID)
+			if err != nil {
+				return err
+			}
+			if !status.Completed && status.Error == "" {
+				return errors.New("prefetch not finished")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Error != "" || status.Skylink != skylink || status.Full != (depth == "full") {
+			t.Fatal("unexpected status", status)
+		}
+		return status
+	}
+
+	// Prefetch the second skyfile and download it afterwards. The download
+	// should hit the hosts fewer times.
+	prefetch(skylink, "base")
+	before = deps.Count()
+	downloadedData, err := r.SkynetSkylinkGet(skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !bytes.Equal(downloadedData, data) {
+		t.Fatal("data mismatch")
+	}
+	if reads := deps.Count() - before; reads >= coldReads {
+		t.Fatalf("expected fewer than %v sector reads after prefetching but got %v", coldReads, reads)
+	}
 
-	// Assert its health. Same as before.
-	err = assertHealth(skylink2, skylink2BaseSectorRedundancy, 5)
+	// Prefetch a large skyfile fully.
+	largeSkylink, _, _, err := r.UploadNewSkyfileWithDataBlocking("large", fastrand.Bytes(int(modules.SectorSize)+1), false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	prefetch(largeSkylink, "full")
 
-	// Take two hosts offline.
-	hosts := tg.Hosts()
-	for i := 0; i < 2; i++ {
-		err = tg.RemoveNode(hosts[i])
-		if err != nil {
-			t.Fatal(err)
-		}
+	// An invalid depth should be rejected.
+	_, err = r.SkynetPrefetchPost(skylink, "invalid")
+	if err == nil || !strings.Contains(err.Error(), "invalid 'depth' parameter") {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Check health again.
-	// The first file should either have a base sector redundancy of 0 to 2
-	// depending on whether the hosts we took offline had a piece. 2 of the
-	// fanout pieces are missing so the health is 3 instead of 5.
-	err1 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy-2, 3)
-	err2 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy-1, 3)
-	err3 := assertHealth(skylink, siatest.DefaulTestingBaseChunkRedundancy, 3)
-	if err1 != nil && err2 != nil && err3 != nil {
-		t.Fatal(errors.Compose(err1, err2))
+	// Unknown prefetches should return an error.
+	_, err = r.SkynetPrefetchStatusGet("unknown")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkPrefetchNotFound.Error()) {
+		t.Fatal("unexpected error", err)
 	}
-	// The second file should have a base sector redundancy of 3. 2 of the
-	// fanout pieces are missing so the health is 3 again.
-	err = assertHealth(skylink2, skylink2BaseSectorRedundancy-2, 3)
+
+	// Blocked skylinks should be rejected.
+	err = r.SkynetBlocklistHashPost([]string{coldSkylink}, nil, false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, err = r.SkynetPrefetchPost(coldSkylink, "base")
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatal("unexpected error", err)
+	}
 }
 
-// TestHostLosingRegistryEntry tests the edge case where a host forgets about a
-// registry entry that we have fetched before.
-func TestHostLosingRegistryEntry(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
-	testDir := skynetTestDir(t.Name())
+// testSkynetDiff tests the /skynet/diff endpoint.
+func testSkynetDiff(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Miners: 1,
-		Hosts:  renter.MinUpdateRegistrySuccesses,
+	// Upload two versions of a skapp.
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index")},
+		{Name: "app.js", Data: []byte("app")},
+		{Name: "about.html", Data: []byte("about")},
 	}
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	from, _, _, err := r.UploadNewMultipartSkyfileBlocking("diffFrom", files, "", false, true)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Add renter with a special dependency.
-	deps := dependencies.NewDependencyReadRegistryNoEntry()
-	deps.Disable()
-	params := node.RenterTemplate
-	params.RenterDeps = deps
-	_, err = tg.AddNodes(params)
+	files = []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index")},
+		{Name: "app.js", Data: []byte("app v2")},
+		{Name: "style.css", Data: []byte("style")},
+	}
+	to, _, _, err := r.UploadNewMultipartSkyfileBlocking("diffTo", files, "", false, true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Set an entry.
-	r := tg.Renters()[0]
-	sk, pk := crypto.GenerateKeyPair()
-	spk := types.Ed25519PublicKey(pk)
-	srv := modules.NewRegistryValue(crypto.Hash{}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
-	err = r.RegistryUpdateWithEntry(spk, srv)
+	// Diff them.
+	diff, err := r.SkynetDiffPost(from, to)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(diff.Added) != 1 || diff.Added[0].Filename != "style.css" {
+		t.Fatal("unexpected added files", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Filename != "about.html" {
+		t.Fatal("unexpected removed files", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Filename != "app.js" || diff.Changed[0].OldLen != 3 || diff.Changed[0].NewLen != 6 {
+		t.Fatal("unexpected changed files", diff.Changed)
+	}
 
-	// Enable the dependency and try to read it. Should fail for all
-	// workers and therefore error out.
-	deps.Enable()
-	_, err = r.RegistryRead(spk, srv.Tweak)
-	if err == nil || !strings.Contains(err.Error(), renter.ErrRegistryEntryNotFound.Error()) {
+	// Diffing a skylink with itself should result in an empty diff.
+	diff, err = r.SkynetDiffPost(from, from)
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Fatal("expected empty diff", diff)
+	}
+
+	// Invalid skylinks should be rejected.
+	_, err = r.SkynetDiffPost(from, "invalid")
+	if err == nil {
+		t.Fatal("expected diff with invalid skylink to fail")
+	}
 }
 
-// testUpdateRegistryMulti tests the endpoint for updating multiple host with
-// different entries.
-func testUpdateRegistryMulti(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetBundle verifies that multiple skylinks can be downloaded as a
+// single archive.
+func testSkynetBundle(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Make sure we got at least 3 hosts.
-	// One to update with a primary key.
-	// One to update with a secondary key.
-	// Two to not update at all.
-	hosts := tg.Hosts()
-	if len(hosts) < 3 {
-		t.Fatal("not enough hosts for test")
+	// Upload a single file skyfile, a multi file skyfile and an encrypted
+	// skyfile.
+	singleData := fastrand.Bytes(100)
+	single, _, _, err := r.UploadNewSkyfileWithDataBlocking("bundleSingle", singleData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: []byte("index")},
+		{Name: "dir/app.js", Data: []byte("app")},
+	}
+	multi, _, _, err := r.UploadNewMultipartSkyfileBlocking("bundleMulti", files, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skykeyName := t.Name()
+	_, err = r.SkykeyCreateKeyPost(skykeyName, skykey.TypePrivateID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedData := fastrand.Bytes(200)
+	encrypted, _, _, err := r.UploadNewEncryptedSkyfileBlocking("bundleEncrypted", encryptedData, skykeyName, false)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Ignore the first host. We don't use it.
-	hosts = hosts[1:]
-
-	// Prepare an entry that's a primary entry on the second host.
-	hpk, err := hosts[0].HostPublicKey()
+	// Upload a skyfile and block it.
+	blocked, _, _, err := r.UploadNewSkyfileBlocking("bundleBlocked", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.SkynetBlocklistPost([]string{blocked}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	hpkh := crypto.HashObject(hpk)
-	sk, pk := crypto.GenerateKeyPair()
-	spk := types.Ed25519PublicKey(pk)
-	var dataKey crypto.Hash
-	fastrand.Read(dataKey[:])
-	data := hpkh[:modules.RegistryPubKeyHashSize]
-	srv := modules.NewRegistryValue(dataKey, data, 0, modules.RegistryTypeWithPubkey).Sign(sk)
-	entry := skymodules.NewRegistryEntry(spk, srv)
 
-	// Set the entry on all hosts from the second till the last.
-	srvs := make(map[string]skymodules.RegistryEntry)
-	for _, h := range hosts {
-		hostKey, err := h.HostPublicKey()
+	// Bundle them.
+	entries := []api.SkynetBundleEntry{
+		{Skylink: single, Path: "single.bin"},
+		{Skylink: multi, Path: "multi"},
+		{Skylink: encrypted, Path: "nested/encrypted.bin"},
+		{Skylink: blocked, Path: "blocked.bin"},
+	}
+	expected := map[string][]byte{
+		"single.bin":           singleData,
+		"multi/index.html":     []byte("index"),
+		"multi/dir/app.js":     []byte("app"),
+		"nested/encrypted.bin": encryptedData,
+	}
+	for _, format := range []skymodules.SkyfileFormat{skymodules.SkyfileFormatZip, skymodules.SkyfileFormatTar} {
+		data, err := r.SkynetBundlePost(entries, format)
 		if err != nil {
 			t.Fatal(err)
 		}
-		srvs[hostKey.String()] = entry
+		var contents fileMap
+		if format == skymodules.SkyfileFormatZip {
+			contents, err = readZipArchive(bytes.NewReader(data))
+		} else {
+			contents, err = readTarArchive(bytes.NewReader(data))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The blocked skylink should be reported in the errors file.
+		errsJSON, exists := contents[api.SkynetBundleErrorsFile]
+		if !exists {
+			t.Fatal("errors file is missing")
+		}
+		delete(contents, api.SkynetBundleErrorsFile)
+		var bundleErrs []api.SkynetBundleError
+		err = json.Unmarshal(errsJSON, &bundleErrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bundleErrs) != 1 || bundleErrs[0].Skylink != blocked || bundleErrs[0].Path != "blocked.bin" || !strings.Contains(bundleErrs[0].Error, renter.ErrSkylinkBlocked.Error()) {
+			t.Fatal("unexpected errors", bundleErrs)
+		}
+
+		// Check the remaining contents.
+		if len(contents) != len(expected) {
+			t.Fatal("unexpected number of files", len(contents))
+		}
+		for name, data := range expected {
+			if !bytes.Equal(contents[name], data) {
+				t.Fatal("unexpected data for", name)
+			}
+		}
 	}
-	err = r.RegistryUpdateMulti(srvs)
+
+	// Invalid entries are rejected.
+	_, err = r.SkynetBundlePost([]api.SkynetBundleEntry{{Skylink: single, Path: "../single.bin"}}, skymodules.SkyfileFormatZip)
+	if err == nil {
+		t.Fatal("expected invalid path to be rejected")
+	}
+	_, err = r.SkynetBundlePost([]api.SkynetBundleEntry{{Skylink: single, Path: "a"}, {Skylink: multi, Path: "a"}}, skymodules.SkyfileFormatZip)
+	if err == nil {
+		t.Fatal("expected duplicate path to be rejected")
+	}
+
+	// The zip endpoint replaces failed entries with an error file.
+	data, err := r.SkynetZipPost(entries)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Check the health. We should find the entry on every host but one and
-	// one host should be considered a primary entry.
-	reh, err := r.RegistryEntryHealth(spk, dataKey)
+	contents, err := readZipArchive(bytes.NewReader(data))
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println(siatest.PrintJSON(reh))
-}
-
-// testHostsForRegistryUpdate is a basic smoke test for /skynet/registry/hosts.
-func testHostsForRegistryUpdate(t *testing.T, tg *siatest.TestGroup) {
-	r := tg.Renters()[0]
+	errFile := "blocked.bin" + api.SkynetBundleErrorSuffix
+	if !strings.Contains(string(contents[errFile]), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatal("unexpected error file", string(contents[errFile]))
+	}
+	delete(contents, errFile)
+	if len(contents) != len(expected) {
+		t.Fatal("unexpected number of files", len(contents))
+	}
+	for name, data := range expected {
+		if !bytes.Equal(contents[name], data) {
+			t.Fatal("unexpected data for", name)
+		}
+	}
 
-	// Get the hosts for updating the registry.
-	hg, err := r.HostsForRegistryUpdateGET()
+	// Bundles can report errors inline too and accept the 'filename' alias
+	// used by zip requests.
+	body := fmt.Sprintf(`[{"skylink":"%v","filename":"single.bin"},{"skylink":"%v","filename":"blocked.bin"}]`, single, blocked)
+	req, err := r.NewRequest("POST", "/skynet/bundle?errors=inline&format=tar", strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Store them in a map for easier lookup.
-	hostMap := make(map[string]struct{})
-	for _, host := range hg.Hosts {
-		hostMap[host.Pubkey.String()] = struct{}{}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Compare them to the active contracts.
-	rc, err := r.RenterAllContractsGet()
+	data, err = ioutil.ReadAll(res.Body)
+	if err := errors.Compose(err, res.Body.Close()); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", res.StatusCode, string(data))
+	}
+	contents, err = readTarArchive(bytes.NewReader(data))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rc.ActiveContracts) != len(hg.Hosts) {
-		t.Fatal("wrong length", len(rc.ActiveContracts), len(hg.Hosts))
+	if len(contents) != 2 || !bytes.Equal(contents["single.bin"], singleData) || !strings.Contains(string(contents[errFile]), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatal("unexpected contents", contents)
 	}
-	for _, c := range rc.ActiveContracts {
-		_, ok := hostMap[c.HostPublicKey.String()]
-		if !ok {
-			t.Fatal("wrong host")
-		}
+
+	// Entries which collide with error files are rejected.
+	_, err = r.SkynetZipPost([]api.SkynetBundleEntry{{Skylink: single, Path: "a"}, {Skylink: multi, Path: "a" + api.SkynetBundleErrorSuffix}})
+	if err == nil {
+		t.Fatal("expected colliding filename to be rejected")
 	}
 }
 
-// TestRegistryReadRepair tests if reading a registry entry repairs the entry on
-// the network.
-func TestRegistryReadRepair(t *testing.T) {
+// TestSkynetPartialDownload verifies that skyfiles with unrecoverable fanout
+// chunks can be partially downloaded when allow-partial is set.
+func TestSkynetPartialDownload(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	testDir := skynetTestDir(t.Name())
 
-	// Create a testgroup.
+	// Create test group
+	testDir := skynetTestDir(t.Name())
 	groupParams := siatest.GroupParams{
-		Hosts:   renter.MinUpdateRegistrySuccesses,
-		Renters: 1,
-		Miners:  1,
+		Hosts:  3,
+		Miners: 1,
 	}
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
@@ -5824,86 +12405,150 @@ func TestRegistryReadRepair(t *testing.T) {
 			t.Fatal(err)
 		}
 	}()
-	r := tg.Renters()[0]
 
-	// Create a random skylink.
-	skylink, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(100)), 0, 100)
+	// Add a portal with a dependency to block sectors.
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencyBlockSectors()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
 	if err != nil {
 		t.Fatal(err)
 	}
+	r := nodes[0]
 
-	// Create a signed registry value.
-	sk, pk := crypto.GenerateKeyPair()
-	var dataKey crypto.Hash
-	fastrand.Read(dataKey[:])
-	data := skylink.Bytes()
-	srv := modules.NewRegistryValue(dataKey, data, 0, modules.RegistryTypeWithoutPubkey).Sign(sk) // rev 0
-	spk := types.SiaPublicKey{
-		Algorithm: types.SignatureEd25519,
-		Key:       pk[:],
+	// blockChunk is a helper that blocks all the pieces of the fanout chunk
+	// with the given index and returns the size of the fanout chunks.
+	blockChunk := func(skylink string, chunkIndex int) uint64 {
+		baseSectorReader, err := r.SkynetBaseSectorGet(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		baseSector, err := ioutil.ReadAll(baseSectorReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layout, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(baseSector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks) <= chunkIndex {
+			t.Fatal("not enough chunks", len(chunks))
+		}
+		deps.Block(chunks[chunkIndex]...)
+		return skymodules.ChunkSize(layout.CipherType, uint64(layout.FanoutDataPieces))
 	}
 
-	// Update the regisry.
-	err = r.RegistryUpdate(spk, dataKey, srv.Revision, srv.Signature, skylink)
+	// Upload a large skyfile and block its second chunk. The size is chosen
+	// to result in at least 3 chunks for any number of fanout data pieces.
+	data := fastrand.Bytes(int(50 * modules.SectorSize))
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking("partial", data, false)
 	if err != nil {
 		t.Fatal(err)
 	}
+	chunkSize := blockChunk(skylink, 1)
 
-	// Check the health of the entry.
-	reh, err := r.RegistryEntryHealth(spk, dataKey)
+	// A regular download fails.
+	_, err = r.SkynetSkylinkGet(skylink)
+	if err == nil {
+		t.Fatal("expected download to fail")
+	}
+
+	// A partial download returns the first chunk.
+	partial, missing, err := r.SkynetSkylinkPartialGet(skylink, skymodules.SkyfileFormatNotSpecified)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if reh.NumBestEntries != uint64(renter.MinUpdateRegistrySuccesses) {
-		t.Fatal("wrong number of entries", reh.NumBestEntries, renter.MinUpdateRegistrySuccesses)
+	if !bytes.Equal(partial, data[:chunkSize]) {
+		t.Fatal("wrong data", len(partial), chunkSize)
+	}
+	expected := []skymodules.SkynetMissingRange{{Offset: chunkSize, Length: chunkSize}}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Fatal("unexpected missing ranges", missing)
 	}
 
-	// Add another host. This host won't have the entry.
-	_, err = tg.AddNodeN(node.HostTemplate, 1)
+	// The status code indicates a partial response.
+	values := url.Values{}
+	values.Set("allow-partial", "true")
+	status, header, err := r.SkynetSkylinkHeadWithParameters(skylink, values)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if status != http.StatusPartialContent {
+		t.Fatal("wrong status", status)
+	}
+	if header.Get("Content-Range") != fmt.Sprintf("bytes 0-%d/%d", chunkSize-1, len(data)) {
+		t.Fatal("wrong content range", header.Get("Content-Range"))
+	}
 
-	// Read the entry. This should work and also repair the new host.
-	readSRV, err := r.RegistryRead(spk, dataKey)
+	// Upload a multipart skyfile with files before, across, within and after
+	// the second chunk and block that chunk.
+	files := []siatest.TestFile{
+		{Name: "a", Data: fastrand.Bytes(int(chunkSize / 2))},
+		{Name: "b", Data: fastrand.Bytes(int(chunkSize))},
+		{Name: "c", Data: fastrand.Bytes(int(chunkSize / 2))},
+		{Name: "d", Data: fastrand.Bytes(int(chunkSize))},
+	}
+	skylink, _, _, err = r.UploadNewMultipartSkyfileBlocking("partialdir", files, "", true, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(srv, readSRV) {
-		t.Log(srv)
-		t.Log(readSRV)
-		t.Fatal("srvs don't match")
+	if blockChunk(skylink, 1) != chunkSize {
+		t.Fatal("chunk size mismatch")
+	}
+
+	// Download it as a tar. Only the first and the last file should be
+	// served.
+	partial, missing, err = r.SkynetSkylinkPartialGet(skylink, skymodules.SkyfileFormatTar)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Check the health of the entry again. Should find 1 more entry.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		reh, err = r.RegistryEntryHealth(spk, dataKey)
+	expected = []skymodules.SkynetMissingRange{
+		{Offset: chunkSize / 2, Length: chunkSize, Filename: "b"},
+		{Offset: chunkSize + chunkSize/2, Length: chunkSize / 2, Filename: "c"},
+	}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Fatal("unexpected missing ranges", missing)
+	}
+	tr := tar.NewReader(bytes.NewReader(partial))
+	for _, file := range []siatest.TestFile{files[0], files[3]} {
+		header, err := tr.Next()
 		if err != nil {
-			return err
+			t.Fatal(err)
 		}
-		if reh.NumBestEntries != uint64(renter.MinUpdateRegistrySuccesses)+1 {
-			return fmt.Errorf("wrong number of entries %v != %v", reh.NumBestEntries, renter.MinUpdateRegistrySuccesses+1)
+		if header.Name != file.Name {
+			t.Fatal("wrong file", header.Name, file.Name)
+		}
+		fileData, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fileData, file.Data) {
+			t.Fatal("wrong data for file", file.Name)
 		}
-		return nil
-	})
-	if err != nil {
-		t.Fatal(err)
+	}
+	if _, err := tr.Next(); !errors.Contains(err, io.EOF) {
+		t.Fatal("expected no more files", err)
 	}
 }
 
-// TestRegistrySubscription runs registry subscription related tests.
-func TestRegistrySubscription(t *testing.T) {
+// TestSkynetAlerts verifies that the skynet specific alerts of the renter are
+// registered and cleared again once the condition is resolved.
+func TestSkynetAlerts(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	testDir := skynetTestDir(t.Name())
 
-	// Create a testgroup.
+	// Create test group
+	testDir := skynetTestDir(t.Name())
 	groupParams := siatest.GroupParams{
-		Hosts:   renter.MinUpdateRegistrySuccesses,
-		Portals: 1,
-		Miners:  1,
+		Hosts:  3,
+		Miners: 1,
 	}
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
@@ -5914,383 +12559,568 @@ func TestRegistrySubscription(t *testing.T) {
 			t.Fatal(err)
 		}
 	}()
-	p := tg.Portals()[0]
 
-	// Run basic test.
-	t.Run("Basic", func(t *testing.T) {
-		testRegistrySubscriptionBasic(t, p)
-	})
-	t.Run("Delays", func(t *testing.T) {
-		testRegistrySubscriptionDelays(t, p)
-	})
-}
+	// Add a portal with a dependency to simulate a read-only persist dir. The
+	// dependency starts disabled.
+	rt := node.RenterTemplate
+	rt.CreatePortal = true
+	deps := dependencies.NewDependencySkynetPersistReadOnly()
+	deps.Disable()
+	rt.RenterDeps = deps
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
 
-// testRegistrySubscriptionBasic tests the basic case of subscribing to
-// an entry and then updating it.
-func testRegistrySubscriptionBasic(t *testing.T, p *siatest.TestNode) {
-	// Collect notifications in a slice.
-	var notifications []skymodules.RegistryEntry
-	var notificationMu sync.Mutex
-	notifyFunc := func(entry skymodules.RegistryEntry) {
-		if err := entry.Verify(); err != nil {
-			t.Fatal("failed to verify entry", err)
+	// hasAlert is a helper that checks whether an alert with the given message
+	// is registered as a critical alert and counted by the skynet stats.
+	hasAlert := func(msg string) bool {
+		dag, err := r.DaemonAlertsGet()
+		if err != nil {
+			t.Fatal(err)
 		}
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		notifications = append(notifications, entry)
+		found := false
+		for _, alert := range dag.CriticalAlerts {
+			if alert.Msg == msg {
+				found = true
+			}
+		}
+		stats, err := r.SkynetStatsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.NumCritAlerts != len(dag.CriticalAlerts) {
+			t.Fatal("wrong number of critical alerts", stats.NumCritAlerts, len(dag.CriticalAlerts))
+		}
+		return found
+	}
+	if hasAlert(renter.AlertMSGSkynetBlocklistPersist) || hasAlert(renter.AlertMSGSkynetPortalsPersist) {
+		t.Fatal("unexpected alert")
 	}
 
-	// Start the subscription.
-	closeHandler := func(_ int, msg string) error {
-		// We are going to close the subscription gracefully so the
-		// close handler shouldn't be called on our end.
-		t.Fatal("Close handler called:", msg)
-		return nil
+	// Update the blocklist and portals with a read-only persist dir.
+	deps.Enable()
+	hash := crypto.HashObject(fastrand.Bytes(10)).String()
+	err = r.SkynetBlocklistHashPost([]string{hash}, nil, true)
+	if err == nil {
+		t.Fatal("expected blocklist update to fail")
 	}
-	subscription, err := p.BeginRegistrySubscription(notifyFunc, closeHandler)
+	portal := skymodules.SkynetPortal{Address: "siasky.net:9980", Public: true}
+	err = r.SkynetPortalsPost([]skymodules.SkynetPortal{portal}, nil)
+	if err == nil {
+		t.Fatal("expected portals update to fail")
+	}
+	if !hasAlert(renter.AlertMSGSkynetBlocklistPersist) || !hasAlert(renter.AlertMSGSkynetPortalsPersist) {
+		t.Fatal("expected alerts")
+	}
+
+	// Invalid portal updates don't clear the alert.
+	deps.Disable()
+	err = r.SkynetPortalsPost(nil, nil)
+	if err == nil {
+		t.Fatal("expected invalid portals update to fail")
+	}
+	if !hasAlert(renter.AlertMSGSkynetPortalsPersist) {
+		t.Fatal("expected alert")
+	}
+
+	// Once the updates succeed again, the alerts are cleared.
+	err = r.SkynetBlocklistHashPost([]string{hash}, nil, true)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := subscription.Close(); err != nil {
+	err = r.SkynetPortalsPost([]skymodules.SkynetPortal{portal}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasAlert(renter.AlertMSGSkynetBlocklistPersist) || hasAlert(renter.AlertMSGSkynetPortalsPersist) {
+		t.Fatal("alerts weren't cleared")
+	}
+
+	// hasWarning is a helper that checks whether an alert with the given
+	// message is registered as a warning.
+	hasWarning := func(msg string) bool {
+		dag, err := r.DaemonAlertsGet()
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
+		for _, alert := range dag.WarningAlerts {
+			if alert.Msg == msg {
+				return true
+			}
+		}
+		return false
+	}
 
-	// Set an entry.
-	sk, pk := crypto.GenerateKeyPair()
-	spk := types.Ed25519PublicKey(pk)
-	var tweak crypto.Hash
-	fastrand.Read(tweak[:])
-	srv1 := modules.NewRegistryValue(tweak, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
-	err = p.RegistryUpdateWithEntry(spk, srv1)
+	// Lower the upload threshold to trigger the upload performance alert.
+	err = r.RenterSkynetUploadAlertThresholdPost(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasWarning(renter.AlertMSGSkynetUploadPerformance) {
+		t.Fatal("expected upload performance alert")
+	}
+	err = r.RenterSkynetUploadAlertThresholdPost(0)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if hasWarning(renter.AlertMSGSkynetUploadPerformance) {
+		t.Fatal("upload performance alert wasn't cleared")
+	}
+}
 
-	// Subscribe to that entry.
-	err = subscription.Subscribe(spk, srv1.Tweak)
+// testSkynetRedirect tests uploading skyfiles which redirect to another
+// skylink.
+func testSkynetRedirect(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload some data.
+	data := fastrand.Bytes(100)
+	skylink, _, _, err := r.UploadNewSkyfileWithDataBlocking(t.Name(), data, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// There should be 1 notification now.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		if len(notifications) != 1 {
-			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
+	// Create an alias with a different filename.
+	uploadAlias := func(name, target string) (string, error) {
+		siaPath, err := skymodules.NewSiaPath(t.Name() + "-" + name)
+		if err != nil {
+			t.Fatal(err)
 		}
-		return nil
-	})
+		alias, _, err := r.SkynetSkyfileRedirectPost(skymodules.SkyfileUploadParameters{
+			SiaPath:  siaPath,
+			Filename: name,
+		}, target)
+		return alias, err
+	}
+	alias, err := uploadAlias("alias.bin", skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if alias == skylink {
+		t.Fatal("alias should have a different skylink")
+	}
 
-	// Subscribe again to the same entry.
-	err = subscription.Subscribe(spk, srv1.Tweak)
+	// Downloading the alias serves the original data with the alias'
+	// filename.
+	downloaded, err := r.SkynetSkylinkGet(alias)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// There should be 2 notifications now.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		if len(notifications) != 1 {
-			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
-		}
-		return nil
-	})
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("data doesn't match")
+	}
+	status, header, err := r.SkynetSkylinkHead(alias)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if status != http.StatusOK {
+		t.Fatal("unexpected status", status)
+	}
+	if cd := header.Get("Content-Disposition"); !strings.Contains(cd, `filename="alias.bin"`) {
+		t.Fatal("unexpected Content-Disposition", cd)
+	}
+	if sl := header.Get(api.SkynetSkylinkHeader); sl != skylink {
+		t.Fatalf("expected skylink of redirect target %v but got %v", skylink, sl)
+	}
 
-	// Increase the revision number and set again.
-	srv2 := srv1
-	srv2.Revision++
-	srv2 = srv2.Sign(sk)
-	err = p.RegistryUpdateWithEntry(spk, srv2)
+	// Redirects are resolved transitively.
+	aliasOfAlias, err := uploadAlias("aliasofalias.bin", "sia://"+alias)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// There should be 3 notifications now.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		if len(notifications) != 3 {
-			return fmt.Errorf("notifications: %v != %v", len(notifications), 3)
-		}
-		return nil
-	})
+	downloaded, err = r.SkynetSkylinkGet(aliasOfAlias)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("data doesn't match")
+	}
 
-	// Unsubscribe
-	err = subscription.Unsubscribe(spk, srv2.Tweak)
+	// The body of a redirect needs to be a skylink.
+	_, err = uploadAlias("invalid", "notaskylink")
+	if err == nil || !strings.Contains(err.Error(), "failed to parse skylink from body") {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Create a loop using a v2 skylink which points to an alias which
+	// redirects to the v2 skylink.
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	skylinkV2 := skymodules.NewSkylinkV2(spk, dataKey)
+	loopAlias, err := uploadAlias("loop", skylinkV2.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Increase the revision number and set again.
-	srv3 := srv2
-	srv3.Revision++
-	srv3 = srv3.Sign(sk)
-	err = p.RegistryUpdateWithEntry(spk, srv3)
+	var loopSkylink skymodules.Skylink
+	err = loopSkylink.LoadString(loopAlias)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Wait for a bit to give the notification some time.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// There should still be 3 notifications.
-		notificationMu.Lock()
-		nNotifications := len(notifications)
-		notificationMu.Unlock()
-		if nNotifications != 3 {
-			return fmt.Errorf("notifications: %v != %v", len(notifications), 3)
-		}
-		return nil
-	})
+	srv := modules.NewRegistryValue(dataKey, loopSkylink.Bytes(), 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
+	err = r.RegistryUpdate(spk, dataKey, srv.Revision, srv.Signature, loopSkylink)
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, err = r.SkynetSkylinkGet(loopAlias)
+	if err == nil || !strings.Contains(err.Error(), api.ErrSkylinkRedirectLoop.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+}
 
-	// Make sure first notification matches.
-	if !reflect.DeepEqual(srv1, notifications[0].SignedRegistryValue) {
-		t.Log(srv1)
-		t.Log(notifications[0].SignedRegistryValue)
-		t.Fatal("notification mismatch")
+// testSkynetPinManifest tests syncing the skylinks pinned by one portal to
+// another portal using a manifest.
+func testSkynetPinManifest(t *testing.T, tg *siatest.TestGroup) {
+	portalA := tg.Renters()[0]
+
+	// Upload three files to portal A and one more file which is going to be
+	// blocked on portal B.
+	var skylinks []string
+	for i := 0; i < 3; i++ {
+		skylink, _, _, err := portalA.UploadNewSkyfileBlocking(fmt.Sprintf("manifest%v", i), 100, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skylinks = append(skylinks, skylink)
 	}
-	// Make sure second notification matches.
-	if !reflect.DeepEqual(srv1, notifications[1].SignedRegistryValue) {
-		t.Log(srv1)
-		t.Log(notifications[1].SignedRegistryValue)
-		t.Fatal("notification mismatch")
+	blockedSkylink, _, _, err := portalA.UploadNewSkyfileBlocking("manifestblocked", 100, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Make sure third notification matches.
-	if !reflect.DeepEqual(srv2, *&notifications[2].SignedRegistryValue) {
-		t.Log(srv2)
-		t.Log(notifications[2].SignedRegistryValue)
-		t.Fatal("notification mismatch")
+
+	// Create the manifest. Besides the skylinks it contains an invalid line
+	// and a duplicate.
+	manifestLines := append([]string{}, skylinks...)
+	manifestLines = append(manifestLines, blockedSkylink, "notaskylink", "sia://"+skylinks[0])
+	manifest := []byte(strings.Join(manifestLines, "\n"))
+	manifestSkylink, _, _, err := portalA.UploadNewSkyfileWithDataBlocking("manifest", manifest, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-// testRegistrySubscriptionDelays tests that the bandwidth limit and general
-// delays for notifications are working as expected.
-func testRegistrySubscriptionDelays(t *testing.T, p *siatest.TestNode) {
-	// Collect notifications in a slice.
-	var notifications []time.Time
-	var notificationMu sync.Mutex
-	notifyFunc := func(entry skymodules.RegistryEntry) {
-		if err := entry.Verify(); err != nil {
-			t.Fatal("failed to verify entry", err)
-		}
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		notifications = append(notifications, time.Now())
+	// Add portal B.
+	portalParams := node.Renter(filepath.Join(skynetTestDir(t.Name()), "portalB"))
+	portalParams.CreatePortal = true
+	nodes, err := tg.AddNodes(portalParams)
+	if err != nil {
+		t.Fatal(err)
 	}
+	portalB := nodes[0]
+	defer func() {
+		if err := tg.RemoveNode(portalB); err != nil {
+			t.Fatal(err)
+		}
+	}()
 
-	// Start the subscription.
-	closeHandler := func(_ int, msg string) error {
-		// We are going to close the subscription gracefully so the
-		// close handler shouldn't be called on our end.
-		t.Fatal("Close handler called:", msg)
-		return nil
+	// Block the skylink on portal B.
+	err = portalB.SkynetBlocklistPost([]string{blockedSkylink}, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	delay := 500 * time.Millisecond                                 // 0.5 seconds per notification
-	limit := uint64(api.RegistrySubscriptionNotificationSize * 0.5) // 0.5 notifications per second
-	subscription, err := p.BeginRegistrySubscriptionCustom(limit, delay, notifyFunc, closeHandler)
+
+	// Sync portal B using the manifest skylink.
+	summary, progress, err := portalB.SkynetPinManifestSkylinkPost(manifestSkylink)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := subscription.Close(); err != nil {
+	expected := api.SkynetPinManifestSummary{
+		ToPin:   3,
+		Blocked: 1,
+		Invalid: 1,
+	}
+	if summary != expected {
+		t.Fatal("wrong summary", summary)
+	}
+	if len(progress) != len(skylinks) {
+		t.Fatal("wrong number of pins", len(progress))
+	}
+	for _, pin := range progress {
+		if pin.Error != "" {
+			t.Fatal("pin failed", pin.Skylink, pin.Error)
+		}
+	}
+
+	// The files should exist on portal B.
+	for _, skylink := range skylinks {
+		siaPath, err := skymodules.SkynetFolder.Join(skylink)
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
+		rf, err := portalB.RenterFileRootGet(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rf.File.Skylinks) != 1 || rf.File.Skylinks[0] != skylink {
+			t.Fatal("wrong skylinks", rf.File.Skylinks)
+		}
+	}
 
-	// Set two entries.
-	sk, pk := crypto.GenerateKeyPair()
-	spk := types.Ed25519PublicKey(pk)
-	srv1 := modules.NewRegistryValue(crypto.Hash{1}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
-	srv2 := modules.NewRegistryValue(crypto.Hash{2}, []byte{}, 0, modules.RegistryTypeWithoutPubkey).Sign(sk)
-	err = p.RegistryUpdateWithEntry(spk, srv1)
+	// Syncing again using the manifest itself shouldn't pin anything.
+	summary, progress, err = portalB.SkynetPinManifestPost(manifest)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = p.RegistryUpdateWithEntry(spk, srv2)
+	expected = api.SkynetPinManifestSummary{
+		AlreadyPinned: 3,
+		Blocked:       1,
+		Invalid:       1,
+	}
+	if summary != expected {
+		t.Fatal("wrong summary", summary)
+	}
+	if len(progress) != 0 {
+		t.Fatal("nothing should have been pinned", progress)
+	}
+}
+
+// testSkynetPinFrom tests pinning skylinks from another portal.
+func testSkynetPinFrom(t *testing.T, tg *siatest.TestGroup) {
+	portalA := tg.Renters()[0]
+
+	// Upload a small and a large file to portal A.
+	smallData := fastrand.Bytes(100)
+	smallSkylink, _, _, err := portalA.UploadNewSkyfileWithDataBlocking("pinfromsmall", smallData, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Subscribe to those entries and measure the time. This will trigger a
-	// notification with a delay and another notification with a delay + an
-	// extended delay since the notifications arrived at about the same time.
-	start1 := time.Now()
-	err = subscription.Subscribe(spk, srv1.Tweak)
+	largeData := fastrand.Bytes(int(2*modules.SectorSize) + siatest.Fuzz())
+	largeSkylink, _, _, err := portalA.UploadNewSkyfileWithDataBlocking("pinfromlarge", largeData, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	start2 := time.Now()
-	err = subscription.Subscribe(spk, srv2.Tweak)
+
+	// Add portal B.
+	portalParams := node.Renter(filepath.Join(skynetTestDir(t.Name()), "portalB"))
+	portalParams.CreatePortal = true
+	nodes, err := tg.AddNodes(portalParams)
 	if err != nil {
 		t.Fatal(err)
 	}
+	portalB := nodes[0]
+	defer func() {
+		if err := tg.RemoveNode(portalB); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	portal := "http://" + portalA.Address
 
-	// There should be 2 notifications now.
-	err = build.Retry(1000, 10*time.Millisecond, func() error {
-		notificationMu.Lock()
-		defer notificationMu.Unlock()
-		if len(notifications) != 2 {
-			return fmt.Errorf("notifications: %v != %v", len(notifications), 2)
+	// Pin both files to portal B from portal A.
+	for _, test := range []struct {
+		skylink  string
+		data     []byte
+		extended bool
+	}{
+		{skylink: smallSkylink, data: smallData},
+		{skylink: largeSkylink, data: largeData, extended: true},
+	} {
+		siaPath, err := skymodules.NewSiaPath(test.skylink)
+		if err != nil {
+			t.Fatal(err)
 		}
-		// The first notification should have taken 500 ms to arrive. To
-		// account for inaccuracies we check for 500ms < d < 1s.
-		d := notifications[0].Sub(start1)
-		if d < delay || d >= time.Second {
-			t.Fatalf("1: wrong delay: %v < %v < %v", delay, d, time.Second)
+		pin, err := portalB.SkynetPinFromPost(test.skylink, portal, skymodules.SkyfilePinParameters{
+			SiaPath: siaPath,
+		})
+		if err != nil {
+			t.Fatal(err)
 		}
-		// The second notification should have taken 500ms to arrive +
-		// the time between notifications specified by the bandwidth
-		// limit which is 2s since we are doing 0.5 notifications per
-		// second. So 2.5 seconds should have passed because we already
-		// waited 500ms for the first notification and then the second
-		// notification is delayed by another 500ms + 1.5s to get to
-		// 2 seconds between notifications.
-		d = notifications[1].Sub(start2)
-		if d < time.Second*5/2 || d >= time.Second*3 {
-			t.Fatalf("2: wrong delay: %v < %v < %v", time.Second*5/2, d, time.Second*3)
+		if pin.Skylink != test.skylink {
+			t.Fatal("wrong skylink", pin.Skylink, test.skylink)
 		}
-		return nil
-	})
+		if (pin.ExtendedSiaPath != nil) != test.extended {
+			t.Fatal("unexpected extended siapath", pin.ExtendedSiaPath)
+		}
+		rf, err := portalB.RenterFileRootGet(pin.SiaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rf.File.Skylinks) != 1 || rf.File.Skylinks[0] != test.skylink {
+			t.Fatal("wrong skylinks", rf.File.Skylinks)
+		}
+
+		// The pinned file should be downloadable from portal B.
+		data, err := portalB.SkynetSkylinkGet(test.skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Fatal("wrong data")
+		}
+
+		// Pinning again to the same siapath should fail.
+		_, err = portalB.SkynetPinFromPost(test.skylink, portal, skymodules.SkyfilePinParameters{
+			SiaPath: siaPath,
+		})
+		if err == nil {
+			t.Fatal("expected pin to fail")
+		}
+	}
+
+	// Pinning a skylink unknown to the remote portal should fail.
+	unknownSkylink, err := skymodules.NewSkylinkV1(crypto.Hash{1, 2, 3}, 0, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, err = portalB.SkynetPinFromPost(unknownSkylink.String(), portal, skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	})
+	if err == nil {
+		t.Fatal("expected pin to fail")
+	}
+
+	// Pinning from an invalid portal should fail.
+	_, err = portalB.SkynetPinFromPost(smallSkylink, "notaportal", skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "unable to parse 'portal' parameter") {
+		t.Fatal("unexpected error", err)
+	}
 }
 
-// testRecursiveBaseSector is a integration test for uploading a file which has
-// more metadata + fanout than would fit into the base sector without the
-// recursive extension.
-func testRecursiveBaseSector(t *testing.T, tg *siatest.TestGroup) {
+// testSkynetAllowlist tests the allowlist mode of a portal.
+func testSkynetAllowlist(t *testing.T, tg *siatest.TestGroup) {
 	r := tg.Renters()[0]
 
-	// Choose a filename that is >SectorSize to guarantee we exceed the base
-	// sector. Keep the content small to make it a small upload.
-	data := fastrand.Bytes(10)
-	largeName := hex.EncodeToString(fastrand.Bytes(int(modules.SectorSize) + 1))
-	sp := skymodules.RandomSkynetFilePath()
-
-	// Add a skykey. That way we also confirm that the encryption is
-	// working.
-	sk, err := r.SkykeyCreateKeyPost(t.Name(), skykey.TypePrivateID)
+	// Upload two files. Since the allowlist isn't enforced, they aren't
+	// approved automatically.
+	approved, _, _, err := r.UploadNewSkyfileBlocking("allowlistapproved", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Add another renter.
-	nodes, err := tg.AddNodes(node.RenterTemplate)
+	unapproved, _, _, err := r.UploadNewSkyfileBlocking("allowlistunapproved", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r2 := nodes[0]
-	defer func() {
-		if err := tg.RemoveNode(r2); err != nil {
+	var approvedLink, unapprovedLink skymodules.Skylink
+	if err := approvedLink.LoadString(approved); err != nil {
+		t.Fatal(err)
+	}
+	if err := unapprovedLink.LoadString(unapproved); err != nil {
+		t.Fatal(err)
+	}
+	approvedHash := crypto.HashObject(approvedLink.MerkleRoot())
+	unapprovedHash := crypto.HashObject(unapprovedLink.MerkleRoot())
+
+	// isAllowed is a helper to check whether a hash is on the allowlist.
+	isAllowed := func(hash crypto.Hash) bool {
+		sag, err := r.SkynetAllowlistGet()
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
-	if err := r2.SkykeyAddKeyPost(sk); err != nil {
+		for _, h := range sag.Allowlist {
+			if h == hash {
+				return true
+			}
+		}
+		return false
+	}
+	if isAllowed(approvedHash) || isAllowed(unapprovedHash) {
+		t.Fatal("uploaded skylinks shouldn't be approved")
+	}
+
+	// Approve the first file.
+	err = r.SkynetAllowlistPost([]string{approved}, nil)
+	if err != nil {
 		t.Fatal(err)
 	}
+	if !isAllowed(approvedHash) || isAllowed(unapprovedHash) {
+		t.Fatal("wrong allowlist")
+	}
 
-	sup := skymodules.SkyfileUploadParameters{
-		SiaPath:             sp,
-		BaseChunkRedundancy: 2,
-		Filename:            largeName,
-		Mode:                skymodules.DefaultFilePerm,
-		Reader:              bytes.NewReader(data),
-		Force:               false,
-		Root:                true,
-		SkykeyName:          t.Name(),
+	// As long as the allowlist isn't enforced, both files can be downloaded.
+	if _, err := r.SkynetSkylinkGet(unapproved); err != nil {
+		t.Fatal(err)
 	}
 
-	// upload a skyfile
-	skylink, _, err := r.SkynetSkyfilePost(sup)
+	// Enforce the allowlist.
+	err = r.RenterSkynetAllowlistEnforcedPost(true)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	downloadedData, err := r.SkynetSkylinkGet(skylink)
+	defer func() {
+		if err := r.RenterSkynetAllowlistEnforcedPost(false); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rg, err := r.RenterGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(data, downloadedData) {
-		t.Fatalf("data mismatch %v %v", len(data), len(downloadedData))
+	if !rg.Settings.SkynetAllowlistEnforced {
+		t.Fatal("allowlist should be enforced")
 	}
 
-	// Fetch the metadata.
-	_, md, err := r.SkynetMetadataGet(skylink)
-	if err != nil {
+	// The approved file can still be downloaded.
+	if _, err := r.SkynetSkylinkGet(approved); err != nil {
 		t.Fatal(err)
 	}
-	if int(md.Length) != len(data) {
-		t.Fatal("md.Length is wrong", md.Length)
+
+	// The unapproved file is rejected.
+	status, _, _ := r.SkynetSkylinkHead(unapproved)
+	if status != http.StatusForbidden {
+		t.Fatal("unexpected status", status)
 	}
-	if md.Filename != largeName {
-		t.Fatal("name is wrong")
+	_, err = r.SkynetSkylinkGet(unapproved)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNotAllowed.Error()) {
+		t.Fatal("unexpected error", err)
 	}
-
-	// Try pinning the file with the new renter.
-	err = r2.SkynetSkylinkPinPost(skylink, skymodules.SkyfilePinParameters{
-		SiaPath: sp,
-		Root:    true,
+	_, err = r.SkynetBaseSectorGet(unapproved)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNotAllowed.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = r.SkynetDownloadByRootGet(unapprovedLink.MerkleRoot(), 0, modules.SectorSize, -1)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNotAllowed.Error()) {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = r.SkynetSkylinkPinPost(unapproved, skymodules.SkyfilePinParameters{
+		SiaPath: skymodules.RandomSiaPath(),
 	})
-	if err != nil {
-		t.Fatal(err)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkNotAllowed.Error()) {
+		t.Fatal("unexpected error", err)
 	}
 
-	// Both base sector .sia files should have the same size. This verifies
-	// that we added the baseSectorExtension when we pinned the file.
-	f1, err := r.RenterFileRootGet(sp)
+	// Files uploaded while the allowlist is enforced are approved and served.
+	enforced, _, _, err := r.UploadNewSkyfileBlocking("allowlistenforced", 100, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	f2, err := r2.RenterFileRootGet(sp)
-	if err != nil {
+	var enforcedLink skymodules.Skylink
+	if err := enforcedLink.LoadString(enforced); err != nil {
 		t.Fatal(err)
 	}
-	if f1.File.Size() != f2.File.Size() {
-		t.Fatal("size mismatch", f1.File.Size(), f2.File.Size())
+	if !isAllowed(crypto.HashObject(enforcedLink.MerkleRoot())) {
+		t.Fatal("skylink uploaded while enforced should be approved")
+	}
+	if _, err := r.SkynetSkylinkGet(enforced); err != nil {
+		t.Fatal(err)
 	}
 
-	// Check the actual size. With the settings of this test, raw metadata
-	// and fanout should have a length of 8392 bytes. Uploading that results
-	// in 3 merkle roots which all fit into the original base sector. So the
-	// resulting size is one sector plus 8392 bytes.
-	extensionSize := int64(8392)
-	if f1.File.Size() != int64(modules.SectorSize)+extensionSize {
-		t.Fatal("wrong size", f1.File.Size())
+	// Approving the file again makes it available.
+	err = r.SkynetAllowlistPost([]string{unapproved}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SkynetSkylinkGet(unapproved); err != nil {
+		t.Fatal(err)
 	}
 }
 
-// TestSkynetFailedPin is a regression test that catches an edge case in the pin
-// code where the Pin is successful even if the portal is unable to download the
-// skylink.
-func TestSkynetFailedPin(t *testing.T) {
+// TestSkynetBlockInFlightUpload verifies that blocking a skylink cancels an
+// upload of that skylink which is still in flight and cleans up its data.
+func TestSkynetBlockInFlightUpload(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Create test group
-	testDir := skynetTestDir(t.Name())
+	// Create a testgroup.
 	groupParams := siatest.GroupParams{
-		Hosts:   3,
-		Portals: 1,
-		Miners:  1,
+		Hosts:  3,
+		Miners: 1,
 	}
+	testDir := skynetTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
 		t.Fatal(err)
@@ -6301,64 +13131,83 @@ func TestSkynetFailedPin(t *testing.T) {
 		}
 	}()
 
-	// Grab clean portals
-	cleanPortal := tg.Portals()[0]
-
-	// Add a portal with a dependency to not upload the fanout
+	// Add a renter which never finishes distributing its upload chunks.
 	rt := node.RenterTemplate
-	rt.CreatePortal = true
-	deps := dependencies.NewDependencyDoNotUploadFanout()
-	rt.RenterDeps = deps
+	rt.Allowance = siatest.DefaultAllowance
+	rt.Allowance.PaymentContractInitialFunding = siatest.DefaultPaymentContractInitialFunding
+	rt.RenterDeps = &dependencies.DependencyDelayChunkDistribution{}
 	nodes, err := tg.AddNodes(rt)
 	if err != nil {
 		t.Fatal(err)
 	}
+	r := nodes[0]
 
-	// Grab dependency portal
-	depPortal := nodes[0]
-	deps.Disable()
-
-	// Upload large file to depPortal and download and pin on clean portal
-	skylink, _, _, err := depPortal.UploadNewSkyfileBlocking("largeFile", 2*modules.SectorSize, false)
+	// Perform a dry run to learn the skylink of the upload.
+	data := fastrand.Bytes(100)
+	siaPath, err := skymodules.NewSiaPath(t.Name())
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = cleanPortal.SkynetSkylinkGet(skylink)
+	dryRunSiaPath, err := skymodules.NewSiaPath(t.Name() + "_dryrun")
 	if err != nil {
 		t.Fatal(err)
 	}
-	spp := skymodules.SkyfilePinParameters{
-		SiaPath: skymodules.RandomSiaPath(),
+	sup := skymodules.SkyfileUploadParameters{
+		SiaPath:             dryRunSiaPath,
+		BaseChunkRedundancy: 2,
+		Filename:            "file",
+		Mode:                0640,
+		ModTime:             time.Now().Unix(),
+		DryRun:              true,
+		Reader:              bytes.NewReader(data),
 	}
-	err = cleanPortal.SkynetSkylinkPinPost(skylink, spp)
+	skylink, _, err := r.SkynetSkyfilePost(sup)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Upload a large file that doesn't get it's fanout uploaded
-	deps.Enable()
-	skylink, _, _, err = depPortal.UploadNewSkyfileBlocking("badLargeFile", 2*modules.SectorSize, false)
+	// Start the upload in the background.
+	sup.SiaPath = siaPath
+	sup.DryRun = false
+	sup.Reader = bytes.NewReader(data)
+	uploadErr := make(chan error)
+	go func() {
+		_, _, err := r.SkynetSkyfilePost(sup)
+		uploadErr <- err
+	}()
+
+	// Wait for the siafile to be created.
+	skyfilePath, err := skymodules.SkynetFolder.Join(siaPath.String())
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Download should fail
-	_, err = cleanPortal.SkynetSkylinkGet(skylink)
-	if err == nil {
-		t.Fatal("Download should fail")
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		_, err := r.RenterFileRootGet(skyfilePath)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Pin should fail
-	siaPath := skymodules.RandomSiaPath()
-	spp = skymodules.SkyfilePinParameters{
-		SiaPath: siaPath,
+
+	// Block the skylink while the upload is in flight.
+	err = r.SkynetBlocklistPost([]string{skylink}, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	err = cleanPortal.SkynetSkylinkPinPost(skylink, spp)
-	if err == nil {
-		t.Fatal("Pin should fail")
+
+	// The upload should fail.
+	select {
+	case err = <-uploadErr:
+	case <-time.After(time.Minute):
+		t.Fatal("upload wasn't cancelled")
+	}
+	if err == nil || !strings.Contains(err.Error(), renter.ErrSkylinkBlocked.Error()) {
+		t.Fatal("expected upload to fail with ErrSkylinkBlocked, got", err)
 	}
 
-	// Confirm siafile is deleted
-	_, err = cleanPortal.SkyfileGet(siaPath)
+	// The siafile should be gone.
+	_, err = r.RenterFileRootGet(skyfilePath)
 	if err == nil || !strings.Contains(err.Error(), filesystem.ErrNotExist.Error()) {
-		t.Fatal("unexpected error", err)
+		t.Fatal("expected siafile to be deleted, got", err)
 	}
 }
@@ -20,6 +20,7 @@ func testSkynetTryFiles(t *testing.T, tg *siatest.TestGroup) {
 		{Name: "WithRootIndex", Test: testTryFilesWithRootIndex},
 		{Name: "WithoutRootIndex", Test: testTryFilesWithoutRootIndex},
 		{Name: "ErrorPages", Test: testSkynetErrorPages},
+		{Name: "SinglePageApp", Test: testTryFilesSinglePageApp},
 	}
 	// Run subtests
 	for _, test := range subTests {
@@ -152,6 +153,54 @@ func testTryFilesWithoutRootIndex(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testTryFilesSinglePageApp ensures that a single-page application uploaded
+// with the documented tryfiles setup is served its root index for any unknown
+// path so that client-side routing works.
+func testTryFilesSinglePageApp(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	idx := []byte("<html>app</html>")
+	js := []byte("console.log('app')")
+	docsIdx := []byte("<html>docs</html>")
+	tf := []string{"index.html", "/index.html"}
+	files := []siatest.TestFile{
+		{Name: "index.html", Data: idx},
+		{Name: "static/app.js", Data: js},
+		{Name: "docs/index.html", Data: docsIdx},
+	}
+	skylink, _, _, err := r.UploadNewMultipartSkyfileEncryptedBlocking("single_page_app", files, "", false, tf, map[int]string{}, true, "", skykey.SkykeyID{})
+	if err != nil {
+		t.Fatal("Failed to upload multipart file.", err)
+	}
+
+	// unknown routes are served the root index with a 200
+	for _, route := range []string{"/users", "/users/42", "/users/42/settings", "/static/missing.js"} {
+		data, status, err := download(r, skylink+route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200 for %v, got %d", route, status)
+		}
+		if !bytes.Equal(data, idx) {
+			t.Fatalf("Expected the root index for %v, got %s", route, data)
+		}
+	}
+
+	// existing files and directories with an index are served as usual
+	err = downloadAndCompare(r, skylink+"/static/app.js", js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = downloadAndCompare(r, skylink+"/docs", docsIdx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = downloadAndCompare(r, skylink+"/docs/unknown", idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // testSkynetErrorPages ensures that the errorpages metadata information is
 // treated correctly
 func testSkynetErrorPages(t *testing.T, tg *siatest.TestGroup) {
@@ -278,6 +278,22 @@ func testTUSUploaderTooLarge(t *testing.T, r *siatest.TestNode) {
 	if err == nil {
 		t.Fatal(err)
 	}
+
+	// Set the renter's max upload size to 1 byte less than the file's size.
+	// This should fail as well.
+	err = r.RenterSkynetMaxUploadSizePost(uint64(len(data)) - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.RenterSkynetMaxUploadSizePost(0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = r.SkynetTUSUploadFromBytesWithMaxSize(data, chunkSize, "failure", "", int64(len(data)))
+	if err == nil {
+		t.Fatal("upload should fail")
+	}
 }
 
 // testOptionsHandler makes sure that the tus endpoints set the expected header
@@ -703,12 +719,52 @@ func testTUSUploaderConcat(t *testing.T, r *siatest.TestNode) {
 		t.Fatal("data mismatch", len(downloaded), len(expected))
 	}
 
+	// The final upload lists the partial uploads it consists of.
+	req, err := http.NewRequest("HEAD", urlConcat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	partials := strings.Fields(strings.TrimPrefix(resp.Header.Get("Upload-Concat"), "final;"))
+	if len(partials) != 2 || partials[0] != urlFull || partials[1] != urlPartial {
+		t.Fatal("unexpected partial uploads", resp.Header.Get("Upload-Concat"))
+	}
+
 	// Concat them the wrong way round. This should not work.
 	urlConcat, err = concat(urlPartial, urlFull)
 	if err == nil {
 		t.Fatal("shouldn't work")
 	}
 
+	// A small upload in the middle isn't aligned to the chunk boundary
+	// either.
+	req, err = r.NewRequest("POST", tusEndpoint, bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	urlF, err := url.Parse(urlFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urlP, err := url.Parse(urlPartial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Concat", fmt.Sprintf("final;%s %s %s", urlF.Path, urlP.Path, urlF.Path))
+	req.Header.Set("SkynetMaxUploadSize", fmt.Sprint(10*modules.SectorSize))
+	_, err = request(req)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatal("expected unaligned upload to be rejected", err)
+	}
+
 	// Wait for two full pruning intervals to make sure pruning ran at least
 	// once.
 	time.Sleep(2 * renter.PruneTUSUploadTimeout)
@@ -949,6 +949,97 @@ func TestSkykeyDelete(t *testing.T) {
 	checkForExpectedKeys(expectedKeySet)
 }
 
+// TestSkykeyRename tests the RenameKey method of the skykey manager.
+func TestSkykeyRename(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a key manager.
+	persistDir := build.TempDir("skykey", t.Name())
+	keyMan, err := NewSkykeyManager(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a few keys.
+	keys := make([]Skykey, 0)
+	for i := 0; i < 3; i++ {
+		sk, err := keyMan.CreateKey("key"+fmt.Sprint(i), TypePrivateID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, sk)
+	}
+
+	// Renaming a key that doesn't exist should fail.
+	err = keyMan.RenameKey("unknown", "new")
+	if !errors.Contains(err, ErrNoSkykeysWithThatName) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Renaming a key to a name that is already used should fail.
+	err = keyMan.RenameKey(keys[0].Name, keys[1].Name)
+	if !errors.Contains(err, ErrSkykeyWithNameAlreadyExists) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Renaming a key to a name that is too long should fail.
+	err = keyMan.RenameKey(keys[0].Name, string(fastrand.Bytes(MaxKeyNameLen+1)))
+	if !errors.Contains(err, errSkykeyNameToolong) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Rename the middle key.
+	err = keyMan.RenameKey(keys[1].Name, "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkKeys checks that the key manager and a freshly loaded key manager
+	// contain the renamed key.
+	checkKeys := func(km *SkykeyManager) {
+		if len(km.Skykeys()) != len(keys) {
+			t.Fatalf("expected %v keys but got %v", len(keys), len(km.Skykeys()))
+		}
+		_, err = km.KeyByName(keys[1].Name)
+		if !errors.Contains(err, ErrNoSkykeysWithThatName) {
+			t.Fatal("unexpected error", err)
+		}
+		sk, err := km.KeyByName("renamed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sk.ID() != keys[1].ID() || !sk.equalData(keys[1]) {
+			t.Fatal("renamed key doesn't match original key")
+		}
+		sk, err = km.KeyByID(keys[1].ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sk.Name != "renamed" {
+			t.Fatal("wrong name", sk.Name)
+		}
+		for _, key := range []Skykey{keys[0], keys[2]} {
+			sk, err = km.KeyByName(key.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sk.equals(key) {
+				t.Fatal("keys don't match")
+			}
+		}
+	}
+	checkKeys(keyMan)
+
+	freshKeyMan, err := NewSkykeyManager(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(freshKeyMan)
+}
+
 // TestSkykeyDelete tests the Delete methods for the skykey manager, starting
 // with a file containing skykeys created using the older format.
 func TestSkykeyDeleteCompat(t *testing.T) {
@@ -0,0 +1,181 @@
+package skykey
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// skykeyexport.go contains the logic for exporting a skykey in a password
+// protected format. The skykey is encrypted with a key that is derived from
+// the password using argon2id. The parameters of the key derivation are
+// stored in the header of the exported skykey to allow for changing them in
+// the future without breaking older exports.
+//
+// The format of an exported skykey is:
+//
+// version (1 byte) | time (4 bytes) | memory (4 bytes) | threads (1 byte) |
+// salt (16 bytes) | nonce (24 bytes) | ciphertext
+//
+// The header is authenticated as additional data of the ciphertext.
+
+const (
+	// exportVersion is the current version of the export format.
+	exportVersion = byte(1)
+
+	// exportSaltLen is the length of the salt used for the key derivation.
+	exportSaltLen = 16
+
+	// exportHeaderLen is the length of the export header which includes
+	// the version, the key derivation parameters, the salt and the nonce.
+	exportHeaderLen = 1 + 4 + 4 + 1 + exportSaltLen + chacha20poly1305.NonceSizeX
+
+	// exportTagLen is the length of the authentication tag appended to the
+	// ciphertext.
+	exportTagLen = 16
+
+	// exportMaxTime and exportMaxMemory limit the key derivation parameters
+	// that are accepted when importing a skykey. Otherwise an import could
+	// be used to make the node spend an excessive amount of resources.
+	exportMaxTime   = 16
+	exportMaxMemory = 1 << 20 // 1 GiB
+)
+
+var (
+	// exportTime is the number of passes over the memory of the key
+	// derivation.
+	exportTime = uint32(3)
+
+	// exportMemory is the amount of memory used by the key derivation in
+	// KiB.
+	exportMemory = build.Select(build.Var{
+		Dev:      uint32(64 * 1024),
+		Standard: uint32(64 * 1024),
+		Testing:  uint32(1024),
+	}).(uint32)
+
+	// exportThreads is the number of threads used by the key derivation.
+	exportThreads = uint8(4)
+)
+
+var (
+	// ErrEmptyExportPassword is returned when exporting or importing a
+	// skykey without a password.
+	ErrEmptyExportPassword = errors.New("password for exported skykey can't be empty")
+
+	// ErrInvalidExportPassword is returned when an exported skykey can't be
+	// decrypted. This is either due to a wrong password or because the
+	// exported skykey was modified.
+	ErrInvalidExportPassword = errors.New("wrong password or corrupted skykey export")
+
+	// ErrUnknownExportVersion is returned when importing a skykey with an
+	// unknown version byte.
+	ErrUnknownExportVersion = errors.New("unknown skykey export version")
+
+	errExportTooShort      = errors.New("skykey export is too short")
+	errInvalidExportParams = errors.New("skykey export has invalid key derivation parameters")
+	errExportTrailingBytes = errors.New("skykey export contains trailing bytes")
+	errExportInvalidSkykey = errors.New("skykey export contains an invalid skykey")
+	errExportCipher        = errors.New("failed to create cipher for skykey export")
+)
+
+// exportKey derives the key used to encrypt an exported skykey from the
+// password.
+func exportKey(password string, salt []byte, passes, memory uint32, threads uint8) []byte {
+	return argon2.IDKey([]byte(password), salt, passes, memory, threads, chacha20poly1305.KeySize)
+}
+
+// ExportWithPassword returns the skykey encrypted with a key derived from the
+// given password.
+func (sk Skykey) ExportWithPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrEmptyExportPassword
+	}
+	if err := sk.IsValid(); err != nil {
+		return nil, errors.AddContext(err, "can't export invalid skykey")
+	}
+
+	// Create the header.
+	header := make([]byte, exportHeaderLen)
+	header[0] = exportVersion
+	binary.LittleEndian.PutUint32(header[1:5], exportTime)
+	binary.LittleEndian.PutUint32(header[5:9], exportMemory)
+	header[9] = exportThreads
+	salt := header[10 : 10+exportSaltLen]
+	nonce := header[10+exportSaltLen:]
+	fastrand.Read(salt)
+	fastrand.Read(nonce)
+
+	// Marshal the skykey.
+	var buf bytes.Buffer
+	err := sk.marshalSia(&buf)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to marshal skykey")
+	}
+
+	// Encrypt it.
+	aead, err := chacha20poly1305.NewX(exportKey(password, salt, exportTime, exportMemory, exportThreads))
+	if err != nil {
+		return nil, errors.Compose(errExportCipher, err)
+	}
+	return aead.Seal(header, nonce, buf.Bytes(), header), nil
+}
+
+// ImportWithPassword decrypts a skykey that was exported using
+// ExportWithPassword.
+func ImportWithPassword(export []byte, password string) (Skykey, error) {
+	if password == "" {
+		return Skykey{}, ErrEmptyExportPassword
+	}
+	if len(export) == 0 {
+		return Skykey{}, errExportTooShort
+	}
+	if export[0] != exportVersion {
+		return Skykey{}, errors.AddContext(ErrUnknownExportVersion, fmt.Sprint(export[0]))
+	}
+	if len(export) < exportHeaderLen+exportTagLen {
+		return Skykey{}, errExportTooShort
+	}
+
+	// Parse the header.
+	header := export[:exportHeaderLen]
+	passes := binary.LittleEndian.Uint32(header[1:5])
+	memory := binary.LittleEndian.Uint32(header[5:9])
+	threads := header[9]
+	salt := header[10 : 10+exportSaltLen]
+	nonce := header[10+exportSaltLen:]
+	if passes == 0 || passes > exportMaxTime || memory == 0 || memory > exportMaxMemory || threads == 0 {
+		return Skykey{}, errInvalidExportParams
+	}
+
+	// Decrypt the skykey.
+	aead, err := chacha20poly1305.NewX(exportKey(password, salt, passes, memory, threads))
+	if err != nil {
+		return Skykey{}, errors.Compose(errExportCipher, err)
+	}
+	plaintext, err := aead.Open(nil, nonce, export[exportHeaderLen:], header)
+	if err != nil {
+		return Skykey{}, ErrInvalidExportPassword
+	}
+
+	// Unmarshal it.
+	var sk Skykey
+	r := bytes.NewReader(plaintext)
+	err = sk.unmarshalSia(r)
+	if err != nil {
+		return Skykey{}, errors.Compose(errExportInvalidSkykey, err)
+	}
+	if r.Len() != 0 {
+		return Skykey{}, errExportTrailingBytes
+	}
+	if err := sk.IsValid(); err != nil {
+		return Skykey{}, errors.Compose(errExportInvalidSkykey, err)
+	}
+	return sk, nil
+}
@@ -0,0 +1,115 @@
+package skykey
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkykeyExport tests exporting and importing skykeys with a password.
+func TestSkykeyExport(t *testing.T) {
+	t.Parallel()
+
+	for _, skykeyType := range []SkykeyType{TypePublicID, TypePrivateID} {
+		cipherKey := crypto.GenerateSiaKey(skykeyType.CipherType())
+		sk := Skykey{
+			Name:    "export-" + skykeyType.ToString(),
+			Type:    skykeyType,
+			Entropy: cipherKey.Key(),
+		}
+
+		// Export the key.
+		password := "password"
+		export, err := sk.ExportWithPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if export[0] != exportVersion {
+			t.Fatal("wrong version", export[0])
+		}
+
+		// Exporting the same key twice should result in a different export
+		// due to the random salt and nonce.
+		export2, err := sk.ExportWithPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(export) == string(export2) {
+			t.Fatal("exports shouldn't match")
+		}
+
+		// Import it again.
+		imported, err := ImportWithPassword(export, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !imported.equals(sk) {
+			t.Fatal("imported key doesn't match", imported, sk)
+		}
+		if imported.ID() != sk.ID() {
+			t.Fatal("ID mismatch")
+		}
+
+		// Try a wrong password.
+		_, err = ImportWithPassword(export, "wrong password")
+		if !errors.Contains(err, ErrInvalidExportPassword) {
+			t.Fatal("expected wrong password error", err)
+		}
+
+		// Try empty passwords.
+		_, err = sk.ExportWithPassword("")
+		if !errors.Contains(err, ErrEmptyExportPassword) {
+			t.Fatal("expected empty password error", err)
+		}
+		_, err = ImportWithPassword(export, "")
+		if !errors.Contains(err, ErrEmptyExportPassword) {
+			t.Fatal("expected empty password error", err)
+		}
+
+		// Tamper with the ciphertext.
+		tampered := append([]byte{}, export...)
+		tampered[exportHeaderLen+fastrand.Intn(len(tampered)-exportHeaderLen)]++
+		_, err = ImportWithPassword(tampered, password)
+		if !errors.Contains(err, ErrInvalidExportPassword) {
+			t.Fatal("expected tampered ciphertext to be detected", err)
+		}
+
+		// Tamper with the salt. The header is authenticated too.
+		tampered = append([]byte{}, export...)
+		tampered[10]++
+		_, err = ImportWithPassword(tampered, password)
+		if !errors.Contains(err, ErrInvalidExportPassword) {
+			t.Fatal("expected tampered header to be detected", err)
+		}
+
+		// Use an unknown version.
+		tampered = append([]byte{}, export...)
+		tampered[0]++
+		_, err = ImportWithPassword(tampered, password)
+		if !errors.Contains(err, ErrUnknownExportVersion) {
+			t.Fatal("expected unknown version error", err)
+		}
+
+		// Use invalid key derivation parameters.
+		tampered = append([]byte{}, export...)
+		tampered[1] = exportMaxTime + 1
+		_, err = ImportWithPassword(tampered, password)
+		if !errors.Contains(err, errInvalidExportParams) {
+			t.Fatal("expected invalid params error", err)
+		}
+
+		// Truncate the export.
+		_, err = ImportWithPassword(export[:exportHeaderLen], password)
+		if !errors.Contains(err, errExportTooShort) {
+			t.Fatal("expected too short error", err)
+		}
+	}
+
+	// Invalid keys can't be exported.
+	_, err := Skykey{Name: "invalid"}.ExportWithPassword("password")
+	if err == nil {
+		t.Fatal("expected invalid key to fail")
+	}
+}
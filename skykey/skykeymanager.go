@@ -294,6 +294,47 @@ func (sm *SkykeyManager) KeyByName(name string) (Skykey, error) {
 	return key, nil
 }
 
+// RenameKey renames the skykey with the given name. The key's ID and entropy
+// remain unchanged.
+func (sm *SkykeyManager) RenameKey(oldName, newName string) error {
+	if len(newName) > MaxKeyNameLen {
+		return errSkykeyNameToolong
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	id, ok := sm.idsByName[oldName]
+	if !ok {
+		return ErrNoSkykeysWithThatName
+	}
+	_, ok = sm.idsByName[newName]
+	if ok {
+		return ErrSkykeyWithNameAlreadyExists
+	}
+	key, ok := sm.keysByID[id]
+	if !ok {
+		return ErrNoSkykeysWithThatID
+	}
+
+	// Append the renamed key to the file before marking the old one as
+	// deleted. That way the key is never lost, even if we crash in between.
+	key.Name = newName
+	err := sm.saveKey(key)
+	if err != nil {
+		return errors.AddContext(err, "unable to save renamed skykey")
+	}
+	delete(sm.idsByName, oldName)
+
+	// The old key comes before the renamed one in the file so it is the first
+	// key with that ID.
+	err = sm.deleteKeyFromDisk(id)
+	if err != nil {
+		return errors.AddContext(err, "unable to delete old skykey")
+	}
+	return nil
+}
+
 // Skykeys returns a slice containing each Skykey being stored.
 func (sm *SkykeyManager) Skykeys() []Skykey {
 	sm.mu.Lock()
@@ -325,6 +366,19 @@ func (sm *SkykeyManager) deleteKeyByID(id SkykeyID) error {
 		return ErrNoSkykeysWithThatID
 	}
 
+	err := sm.deleteKeyFromDisk(id)
+	if err != nil {
+		return err
+	}
+
+	delete(sm.keysByID, id)
+	delete(sm.idsByName, key.Name)
+	return nil
+}
+
+// deleteKeyFromDisk marks the first skykey with the given ID in the skykey
+// file as deleted. It must be called while holding the sm.mu lock.
+func (sm *SkykeyManager) deleteKeyFromDisk(id SkykeyID) (err error) {
 	file, err := os.OpenFile(sm.staticPersistFile, os.O_RDWR, defaultFilePerm)
 	if err != nil {
 		return errors.AddContext(err, "Unable to open SkykeyManager persist file")
@@ -364,9 +418,6 @@ func (sm *SkykeyManager) deleteKeyByID(id SkykeyID) error {
 	if err != nil {
 		return errors.AddContext(err, "Unable to mark key as deleted")
 	}
-
-	delete(sm.keysByID, id)
-	delete(sm.idsByName, key.Name)
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
@@ -29,6 +30,11 @@ type (
 	SkydDependencies interface {
 		modules.Dependencies
 
+		// SectorUnavailable returns true if the sector with the given root
+		// should be treated as if no host stored it. It is used to simulate
+		// the loss of data on the network.
+		SectorUnavailable(root crypto.Hash) bool
+
 		// SkynetAddress returns an address to be used to send SC to Skynet Labs
 		SkynetAddress() types.UnlockHash
 	}
@@ -41,6 +47,12 @@ type (
 
 // Satisfy the Skynet specific interface
 
+// SectorUnavailable returns false since no sectors are unavailable in
+// production.
+func (*SkynetDependencies) SectorUnavailable(crypto.Hash) bool {
+	return false
+}
+
 // SkynetAddress returns an address owned by Skynet Labs
 func (*SkynetDependencies) SkynetAddress() types.UnlockHash {
 	return skynetAddress
@@ -0,0 +1,134 @@
+package skymodules
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// Large registry data is stored across multiple registry entries. The entry
+// at the requested data key contains a manifest with the length and hash of
+// the data. The data itself is split into chunks of up to
+// modules.RegistryDataSize bytes. Every chunk is stored in its own entry at
+// revision 0 under a data key derived from the manifest's data key, the hash
+// of the data and the chunk's index. That way chunks are never updated and
+// the manifest is the only entry which needs to be revised.
+
+const (
+	// LargeRegistryChunkSize is the number of bytes of data stored in a
+	// single chunk of large registry data.
+	LargeRegistryChunkSize = modules.RegistryDataSize
+
+	// LargeRegistryMaxChunks is the maximum number of chunks large registry
+	// data can be split into.
+	LargeRegistryMaxChunks = 128
+
+	// LargeRegistryMaxDataSize is the maximum size of large registry data.
+	LargeRegistryMaxDataSize = LargeRegistryMaxChunks * LargeRegistryChunkSize
+
+	// largeRegistryManifestMagic is the prefix of an encoded manifest.
+	largeRegistryManifestMagic = "skylrg1"
+
+	// largeRegistryManifestSize is the size of an encoded manifest.
+	largeRegistryManifestSize = len(largeRegistryManifestMagic) + 8 + crypto.HashSize
+)
+
+var (
+	// ErrLargeRegistryDataTooLarge is returned when trying to store more
+	// than LargeRegistryMaxDataSize bytes of large registry data.
+	ErrLargeRegistryDataTooLarge = fmt.Errorf("large registry data can't exceed %v bytes", LargeRegistryMaxDataSize)
+
+	// ErrInvalidLargeRegistryManifest is returned when a registry entry
+	// doesn't contain a valid manifest for large registry data.
+	ErrInvalidLargeRegistryManifest = errors.New("registry entry doesn't contain a valid large registry data manifest")
+
+	// largeRegistryChunkSpecifier is used to derive the data keys of the
+	// chunks of large registry data.
+	largeRegistryChunkSpecifier = []byte("largeregistrychunk")
+)
+
+type (
+	// LargeRegistryManifest describes large registry data which is split
+	// across multiple registry entries.
+	LargeRegistryManifest struct {
+		Length uint64
+		Hash   crypto.Hash
+	}
+)
+
+// NewLargeRegistryManifest creates the manifest for the given data.
+func NewLargeRegistryManifest(data []byte) LargeRegistryManifest {
+	return LargeRegistryManifest{
+		Length: uint64(len(data)),
+		Hash:   crypto.HashBytes(data),
+	}
+}
+
+// DecodeLargeRegistryManifest decodes a manifest from the data of a registry
+// entry.
+func DecodeLargeRegistryManifest(b []byte) (LargeRegistryManifest, error) {
+	if len(b) != largeRegistryManifestSize || !bytes.HasPrefix(b, []byte(largeRegistryManifestMagic)) {
+		return LargeRegistryManifest{}, ErrInvalidLargeRegistryManifest
+	}
+	b = b[len(largeRegistryManifestMagic):]
+	var m LargeRegistryManifest
+	m.Length = binary.LittleEndian.Uint64(b[:8])
+	copy(m.Hash[:], b[8:])
+	if m.Length > LargeRegistryMaxDataSize {
+		return LargeRegistryManifest{}, errors.AddContext(ErrInvalidLargeRegistryManifest, "manifest exceeds the max data size")
+	}
+	return m, nil
+}
+
+// ChunkDataKey returns the data key of the chunk with the given index.
+func (m LargeRegistryManifest) ChunkDataKey(dataKey crypto.Hash, index uint64) crypto.Hash {
+	return crypto.HashAll(largeRegistryChunkSpecifier, dataKey, m.Hash, index)
+}
+
+// Encode encodes the manifest for storing it in a registry entry.
+func (m LargeRegistryManifest) Encode() []byte {
+	b := make([]byte, 0, largeRegistryManifestSize)
+	b = append(b, largeRegistryManifestMagic...)
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], m.Length)
+	b = append(b, length[:]...)
+	return append(b, m.Hash[:]...)
+}
+
+// NumChunks returns the number of chunks the data is split into.
+func (m LargeRegistryManifest) NumChunks() uint64 {
+	return (m.Length + LargeRegistryChunkSize - 1) / LargeRegistryChunkSize
+}
+
+// SplitLargeRegistryData splits data into the chunks which are stored in the
+// registry.
+func SplitLargeRegistryData(data []byte) ([][]byte, error) {
+	if len(data) > LargeRegistryMaxDataSize {
+		return nil, ErrLargeRegistryDataTooLarge
+	}
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := LargeRegistryChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks, nil
+}
+
+// VerifyData checks the reassembled data against the manifest.
+func (m LargeRegistryManifest) VerifyData(data []byte) error {
+	if uint64(len(data)) != m.Length {
+		return fmt.Errorf("expected %v bytes of data but got %v", m.Length, len(data))
+	}
+	if crypto.HashBytes(data) != m.Hash {
+		return errors.New("data doesn't match the hash of the manifest")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package skymodules
+
+import (
+	"bytes"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestLargeRegistryManifest verifies encoding and decoding manifests as well
+// as splitting and verifying the data they describe.
+func TestLargeRegistryManifest(t *testing.T) {
+	t.Parallel()
+
+	// The encoded manifest needs to fit in a single entry.
+	if largeRegistryManifestSize > modules.RegistryDataSize {
+		t.Fatal("manifest doesn't fit in a registry entry", largeRegistryManifestSize)
+	}
+
+	for _, size := range []int{0, 1, LargeRegistryChunkSize, LargeRegistryChunkSize + 1, LargeRegistryMaxDataSize} {
+		data := fastrand.Bytes(size)
+		m := NewLargeRegistryManifest(data)
+
+		// Encode and decode the manifest.
+		decoded, err := DecodeLargeRegistryManifest(m.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != m {
+			t.Fatal("manifest doesn't match", decoded, m)
+		}
+
+		// Split the data.
+		chunks, err := SplitLargeRegistryData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(chunks)) != m.NumChunks() {
+			t.Fatal("wrong number of chunks", len(chunks), m.NumChunks())
+		}
+		for i, chunk := range chunks {
+			if len(chunk) > LargeRegistryChunkSize || (i < len(chunks)-1 && len(chunk) != LargeRegistryChunkSize) {
+				t.Fatal("wrong chunk size", i, len(chunk))
+			}
+		}
+		if err := m.VerifyData(bytes.Join(chunks, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Data exceeding the max size can't be split.
+	_, err := SplitLargeRegistryData(make([]byte, LargeRegistryMaxDataSize+1))
+	if !errors.Contains(err, ErrLargeRegistryDataTooLarge) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Modified data shouldn't verify.
+	data := fastrand.Bytes(2 * LargeRegistryChunkSize)
+	m := NewLargeRegistryManifest(data)
+	if err := m.VerifyData(data[1:]); err == nil {
+		t.Fatal("data with the wrong length shouldn't verify")
+	}
+	data[0]++
+	if err := m.VerifyData(data); err == nil {
+		t.Fatal("modified data shouldn't verify")
+	}
+
+	// Chunks of the same data under the same data key should have distinct
+	// data keys.
+	var dataKey crypto.Hash
+	fastrand.Read(dataKey[:])
+	if m.ChunkDataKey(dataKey, 0) == m.ChunkDataKey(dataKey, 1) || m.ChunkDataKey(dataKey, 0) == dataKey {
+		t.Fatal("chunk data keys should be unique")
+	}
+
+	// Invalid manifests should be rejected.
+	encoded := m.Encode()
+	tooLarge := NewLargeRegistryManifest(nil)
+	tooLarge.Length = LargeRegistryMaxDataSize + 1
+	for _, invalid := range [][]byte{nil, encoded[1:], append(encoded, 0), append([]byte("x"), encoded[1:]...), tooLarge.Encode()} {
+		_, err := DecodeLargeRegistryManifest(invalid)
+		if !errors.Contains(err, ErrInvalidLargeRegistryManifest) {
+			t.Fatal("unexpected error", err)
+		}
+	}
+}
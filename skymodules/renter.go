@@ -481,8 +481,11 @@ type DirectoryInfo struct {
 	AggregateStuckSize           uint64    `json:"aggregatestucksize"`
 
 	// Skynet Fields
-	AggregateSkynetFiles uint64 `json:"aggregateskynetfiles"`
-	AggregateSkynetSize  uint64 `json:"aggregateskynetsize"`
+	AggregateSkynetExtendedFiles uint64 `json:"aggregateskynetextendedfiles"`
+	AggregateSkynetFiles         uint64 `json:"aggregateskynetfiles"`
+	AggregateSkynetRawSize       uint64 `json:"aggregateskynetrawsize"`
+	AggregateSkynetSize          uint64 `json:"aggregateskynetsize"`
+	AggregateSkynetSkylinks      uint64 `json:"aggregateskynetskylinks"`
 
 	// The following fields are information specific to the siadir that is not
 	// an aggregate of the entire sub directory tree
@@ -506,8 +509,11 @@ type DirectoryInfo struct {
 	UID                 uint64      `json:"uid"`
 
 	// Skynet Fields
-	SkynetFiles uint64 `json:"skynetfiles"`
-	SkynetSize  uint64 `json:"skynetsize"`
+	SkynetExtendedFiles uint64 `json:"skynetextendedfiles"`
+	SkynetFiles         uint64 `json:"skynetfiles"`
+	SkynetRawSize       uint64 `json:"skynetrawsize"`
+	SkynetSize          uint64 `json:"skynetsize"`
+	SkynetSkylinks      uint64 `json:"skynetskylinks"`
 }
 
 // Name implements os.FileInfo.
@@ -583,6 +589,7 @@ type FileInfo struct {
 	FileMode         os.FileMode       `json:"mode,siamismatch"`    // Field is called FileMode for fuse compatibility
 	NumStuckChunks   uint64            `json:"numstuckchunks"`
 	OnDisk           bool              `json:"ondisk"`
+	PinExpiry        time.Time         `json:"pinexpiry"`
 	Recoverable      bool              `json:"recoverable"`
 	Redundancy       float64           `json:"redundancy"`
 	Renewing         bool              `json:"renewing"`
@@ -748,11 +755,27 @@ type RenterPriceEstimation struct {
 
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
-	Allowance        Allowance     `json:"allowance"`
-	IPViolationCheck bool          `json:"ipviolationcheck"`
-	MaxUploadSpeed   int64         `json:"maxuploadspeed"`
-	MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
-	UploadsStatus    UploadsStatus `json:"uploadsstatus"`
+	Allowance                    Allowance             `json:"allowance"`
+	DefaultBaseChunkRedundancy   uint8                 `json:"defaultbasechunkredundancy"`
+	IPViolationCheck             bool                  `json:"ipviolationcheck"`
+	MaxUploadSpeed               int64                 `json:"maxuploadspeed"`
+	MaxDownloadSpeed             int64                 `json:"maxdownloadspeed"`
+	SkynetAllowlistEnforced      bool                  `json:"skynetallowlistenforced"`
+	SkynetCORSOrigins            []string              `json:"skynetcorsorigins"`
+	SkynetDefaultRequestTimeout  uint64                `json:"skynetdefaultrequesttimeout"`
+	SkynetDegradedDownloads      bool                  `json:"skynetdegradeddownloads"`
+	SkynetForceTokenKey          types.SiaPublicKey    `json:"skynetforcetokenkey"`
+	SkynetMaintenance            SkynetMaintenance     `json:"skynetmaintenance"`
+	SkynetMaxDataURISize         uint64                `json:"skynetmaxdataurisize"`
+	SkynetMaxRequestTimeout      uint64                `json:"skynetmaxrequesttimeout"`
+	SkynetMaxUploadSize          uint64                `json:"skynetmaxuploadsize"`
+	SkynetMultipartLimits        SkynetMultipartLimits `json:"skynetmultipartlimits"`
+	SkynetStorageCap             uint64                `json:"skynetstoragecap"`
+	SkynetUploadAlertThresholdMS uint64                `json:"skynetuploadalertthresholdms"`
+	SkynetUploadPolicy           SkynetUploadPolicy    `json:"skynetuploadpolicy"`
+	SkynetUploadRateLimit        SkynetUploadRateLimit `json:"skynetuploadratelimit"`
+	SkynetWeakETags              bool                  `json:"skynetweaketags"`
+	UploadsStatus                UploadsStatus         `json:"uploadsstatus"`
 }
 
 // UploadsStatus contains information about the Renter's Uploads
@@ -1430,10 +1453,64 @@ type Renter interface {
 	// registry value.
 	UpdateRegistry(ctx context.Context, spk types.SiaPublicKey, srv modules.SignedRegistryValue) error
 
+	// CreateRegistryKey creates a new keypair with the given name which can be
+	// used to sign registry updates.
+	CreateRegistryKey(name string) (RegistryKey, error)
+
+	// DeleteRegistryKey deletes the registry keypair with the given name.
+	DeleteRegistryKey(name string) error
+
+	// RegistryKeys returns the public information of all registry keypairs.
+	RegistryKeys() ([]RegistryKey, error)
+
+	// CreateSkynetAPIKey creates a new API key with the given scope. The key
+	// is only returned once since only its hash is persisted.
+	CreateSkynetAPIKey(scope SkynetAPIKeyScope) (string, SkynetAPIKey, error)
+
+	// DeleteSkynetAPIKey deletes the API key with the given id.
+	DeleteSkynetAPIKey(id crypto.Hash) error
+
+	// SkynetAPIKeys returns all API keys.
+	SkynetAPIKeys() ([]SkynetAPIKey, error)
+
+	// SkynetAPIKeyScope returns the scope of the given API key.
+	SkynetAPIKeyScope(key string) (SkynetAPIKeyScope, error)
+
+	// SkynetPortalKey returns the public key which the renter signs serving
+	// proofs with.
+	SkynetPortalKey() (types.SiaPublicKey, error)
+
+	// SignSkynetServingProof signs the given serving proof with the renter's
+	// portal key.
+	SignSkynetServingProof(proof SkynetServingProof) (SkynetServingProof, error)
+
+	// SignRegistryValue signs the registry value with the registry keypair of
+	// the given name and returns the keypair's public key together with the
+	// signed value.
+	SignRegistryValue(name string, rv modules.RegistryValue) (types.SiaPublicKey, modules.SignedRegistryValue, error)
+
+	// PublishSkylink points the registry entry of the named registry keypair
+	// and the data key at a V1 skylink. It bumps the entry's revision and
+	// returns the V2 skylink of the entry together with the revision.
+	PublishSkylink(ctx context.Context, keyName string, dataKey crypto.Hash, sl Skylink) (Skylink, uint64, error)
+
+	// ReadRegistryLarge reads data which was stored using UpdateRegistryLarge
+	// and returns it together with the revision of its manifest.
+	ReadRegistryLarge(ctx context.Context, spk types.SiaPublicKey, dataKey crypto.Hash) ([]byte, uint64, error)
+
+	// UpdateRegistryLarge stores data which might exceed the size of a
+	// single registry entry across multiple entries signed by the named
+	// registry keypair and returns the revision of the manifest entry.
+	UpdateRegistryLarge(ctx context.Context, keyName string, dataKey crypto.Hash, data []byte) (uint64, error)
+
 	// UpdateRegistryMulti updates the registries on the given workers with the
 	// corresponding registry values.
 	UpdateRegistryMulti(ctx context.Context, srvs map[string]RegistryEntry) error
 
+	// UpdateRegistryBatch updates the given registry entries concurrently and
+	// returns the result of each update in the same order as the entries.
+	UpdateRegistryBatch(ctx context.Context, entries []RegistryEntry) []error
+
 	// PauseRepairsAndUploads pauses the renter's repairs and uploads for a time
 	// duration
 	PauseRepairsAndUploads(duration time.Duration) error
@@ -1476,6 +1553,10 @@ type Renter interface {
 	// manager if it exists.
 	DeleteSkykeyByName(string) error
 
+	// RenameSkykey renames the Skykey with the given name in the renter's
+	// skykey manager.
+	RenameSkykey(oldName, newName string) error
+
 	// SkykeyByName gets the Skykey with the given name from the renter's skykey
 	// manager if it exists.
 	SkykeyByName(string) (skykey.Skykey, error)
@@ -1497,31 +1578,102 @@ type Renter interface {
 	// separately as well.
 	CreateSkylinkFromSiafile(SkyfileUploadParameters, SiaPath) (Skylink, error)
 
+	// CreateSkylinkFromSiafileAsync starts the conversion of a siafile to a
+	// skyfile in the background and returns an ID which can be used to query
+	// the progress of the conversion or to cancel it.
+	CreateSkylinkFromSiafileAsync(SkyfileUploadParameters, SiaPath) (string, error)
+
+	// SkyfileConversionStatus returns the status of the asynchronous
+	// conversion with the given ID.
+	SkyfileConversionStatus(id string) (SkyfileConversionStatus, error)
+
+	// CancelSkyfileConversion cancels the asynchronous conversion with the
+	// given ID and removes the partially created skyfile.
+	CancelSkyfileConversion(id string) error
+
 	// DownloadByRoot will fetch data using the merkle root of that data. The
 	// given timeout will make sure this call won't block for a time that
 	// exceeds the given timeout value. Passing a timeout of 0 is considered as
 	// no timeout. The pricePerMS acts as a budget to spend on faster, and thus
-	// potentially more expensive, hosts.
-	DownloadByRoot(root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, error)
+	// potentially more expensive, hosts. The ctx is only used for the
+	// requester it carries.
+	DownloadByRoot(ctx context.Context, root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, error)
+
+	// DownloadByRootWithHostStats works like DownloadByRoot but also returns
+	// information about which hosts contributed to the download.
+	DownloadByRootWithHostStats(ctx context.Context, root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, []SkynetHostStats, error)
 
 	// DownloadSkylink will fetch a file from the Sia network using the given
 	// skylink. The given timeout will make sure this call won't block for a
 	// time that exceeds the given timeout value. Passing a timeout of 0 is
 	// considered as no timeout. The pricePerMS acts as a budget to spend on
-	// faster, and thus potentially more expensive, hosts.
-	DownloadSkylink(link Skylink, timeout time.Duration, pricePerMS types.Currency) (SkyfileStreamer, []RegistryEntry, error)
+	// faster, and thus potentially more expensive, hosts. The ctx is only used
+	// for the trace ID and requester it carries since the returned streamer
+	// may be shared with other callers and outlive the caller's ctx.
+	DownloadSkylink(ctx context.Context, link Skylink, timeout time.Duration, pricePerMS types.Currency) (SkyfileStreamer, []RegistryEntry, error)
+
+	// DownloadSkylinkWithSkykey works like DownloadSkylink but decrypts the
+	// skyfile using the given skykey. The skykey is only used for this
+	// download and is never persisted.
+	DownloadSkylinkWithSkykey(ctx context.Context, link Skylink, sk skykey.Skykey, timeout time.Duration, pricePerMS types.Currency) (SkyfileStreamer, []RegistryEntry, error)
 
 	// DownloadSkylinkBaseSector will take a link and turn it into the data of a
 	// download without any decoding of the metadata, fanout, or decryption. The
 	// given timeout will make sure this call won't block for a time that
 	// exceeds the given timeout value. Passing a timeout of 0 is considered as
 	// no timeout. The pricePerMS acts as a budget to spend on faster, and thus
-	// potentially more expensive, hosts.
-	DownloadSkylinkBaseSector(link Skylink, timeout time.Duration, pricePerMS types.Currency) (Streamer, []RegistryEntry, Skylink, error)
+	// potentially more expensive, hosts. Like for DownloadSkylink, the ctx is
+	// only used for its trace ID and requester.
+	DownloadSkylinkBaseSector(ctx context.Context, link Skylink, timeout time.Duration, pricePerMS types.Currency) (Streamer, []RegistryEntry, Skylink, error)
+
+	// HasRoot checks whether the sector with the given merkle root is
+	// available on the network without downloading it. Passing a timeout of 0
+	// is considered as no timeout. The ctx is only used for the requester it
+	// carries.
+	HasRoot(ctx context.Context, root crypto.Hash, timeout time.Duration) error
+
+	// HasSkylinkBaseSector checks whether the base sector of the given skylink
+	// is available on the network without downloading it. It returns the
+	// registry entries used to resolve the skylink and the resolved skylink.
+	// Passing a timeout of 0 is considered as no timeout. The ctx is only used
+	// for the requester it carries.
+	HasSkylinkBaseSector(ctx context.Context, link Skylink, timeout time.Duration) ([]RegistryEntry, Skylink, error)
+
+	// PrefetchSkylink starts fetching the base sector and, if 'full' is set,
+	// the fanout of a skylink in the background to warm up the renter's
+	// caches. It returns an ID which can be used to query the progress of the
+	// prefetch.
+	PrefetchSkylink(link Skylink, full bool, timeout time.Duration, pricePerMS types.Currency) (string, error)
+
+	// SkylinkPrefetchStatus returns the status of the prefetch with the given
+	// ID.
+	SkylinkPrefetchStatus(id string) (SkylinkPrefetchStatus, error)
 
 	// SkylinkHealth returns the health of a skylink on the network.
 	SkylinkHealth(ctx context.Context, link Skylink, ppms types.Currency) (SkylinkHealth, error)
 
+	// SkylinkEncoding returns information about how efficiently the fanout
+	// of a skylink is encoded.
+	SkylinkEncoding(ctx context.Context, link Skylink, ppms types.Currency) (SkylinkEncoding, error)
+
+	// DownloadSkylinkChunk downloads a single chunk of the fanout of a
+	// skylink by the roots of its pieces and returns its decrypted data.
+	DownloadSkylinkChunk(ctx context.Context, link Skylink, index uint64, sk *skykey.Skykey, ppms types.Currency) ([]byte, error)
+
+	// SkyfileConsistency checks whether the layout, fanout and metadata of
+	// the skyfile behind the given skylink are consistent with each other.
+	SkyfileConsistency(ctx context.Context, link Skylink, ppms types.Currency) (SkyfileConsistency, error)
+
+	// EstimateSkyfileUploadCost estimates the cost of uploading a skyfile of
+	// the given size with the redundancy of the upload parameters without
+	// uploading anything.
+	EstimateSkyfileUploadCost(size uint64, sup SkyfileUploadParameters) (SkyfileUploadCostEstimate, error)
+
+	// EstimateSkylinkPinCost estimates the cost of pinning a skylink with the
+	// base chunk redundancy of the upload parameters. Only the base sector of
+	// the skylink is downloaded.
+	EstimateSkylinkPinCost(link Skylink, sup SkyfileUploadParameters, timeout time.Duration, pricePerMS types.Currency) (SkylinkPinCostEstimate, error)
+
 	// UploadSkyfile will upload data to the Sia network from a reader and
 	// create a skyfile, returning the skylink that can be used to access the
 	// file.
@@ -1532,26 +1684,97 @@ type Renter interface {
 	// file.
 	UploadSkyfile(context.Context, SkyfileUploadParameters, SkyfileUploadReader) (Skylink, error)
 
+	// BeginSkyfileUpload uploads the data of a skyfile without creating the
+	// skyfile. The returned ID is used to finalize the upload once the
+	// metadata of the skyfile is known.
+	BeginSkyfileUpload(ctx context.Context, sup SkyfileUploadParameters, reader io.Reader) (string, error)
+
+	// FinalizeSkyfileUpload creates the skyfile for a pending upload using
+	// the provided metadata and returns its skylink.
+	FinalizeSkyfileUpload(ctx context.Context, id string, md SkyfileMetadata) (Skylink, error)
+
+	// Allowlist returns the hashes of the merkleroots that are approved
+	Allowlist() ([]crypto.Hash, error)
+
 	// Blocklist returns the merkleroots that are blocked
 	Blocklist() ([]crypto.Hash, error)
 
+	// BlocklistHits returns the most recent attempts to download blocked
+	// content.
+	BlocklistHits() ([]SkynetBlocklistHit, error)
+
+	// CheckSkylinkAccess returns an error if the given V1 skylink is blocked
+	// or not on the enforced allowlist.
+	CheckSkylinkAccess(ctx context.Context, link Skylink) error
+
+	// SkynetTrace returns the most recent log lines of skynet operations
+	// which were tagged with the given trace ID.
+	SkynetTrace(traceID string) ([]SkynetTraceEntry, error)
+
+	// SkynetGC deletes the skyfiles which weren't downloaded within the
+	// provided retention window. Skyfiles under the excluded prefix are never
+	// deleted. If dryRun is set, the candidates are returned without deleting
+	// them.
+	SkynetGC(olderThan time.Duration, dryRun bool, excludePrefix SiaPath) (SkynetGCResult, error)
+
+	// SkynetPins returns the skyfiles pinned to the renter together with the
+	// time their pin expires.
+	SkynetPins() ([]SkynetPin, error)
+
+	// SetSkynetPinExpiry sets the time after which the skyfile at the given
+	// siapath is automatically unpinned. A zero time removes the expiry.
+	SetSkynetPinExpiry(siaPath SiaPath, expiry time.Time) error
+
+	// SkynetSnapshotCreate captures the skylinks pinned under the given
+	// directory in a snapshot with the given name. An existing snapshot with
+	// the same name is replaced.
+	SkynetSnapshotCreate(name string, dir SiaPath) (SkynetSnapshot, error)
+
+	// SkynetSnapshotDiff returns the skylinks which were added, removed or
+	// moved between two snapshots. Either of them can be SkynetSnapshotNow
+	// to compare against the current state of the other's directory.
+	SkynetSnapshotDiff(from, to string) (SkynetSnapshotDiff, error)
+
+	// SkynetSearch searches the metadata of the skyfiles pinned under a
+	// directory. It returns the requested page of matches sorted by siapath
+	// and the total number of matches.
+	SkynetSearch(params SkynetSearchParameters) ([]SkynetSearchResult, uint64, error)
+
 	// PinSkylink re-uploads the data stored at the file under that skylink with
 	// the given parameters. Alongside the parameters we can pass a timeout and
 	// a price per millisecond. The timeout ensures fetching the base sector
 	// does not surpass it, the price per millisecond is the budget we are
-	// allowed to spend on faster hosts.
-	PinSkylink(link Skylink, sup SkyfileUploadParameters, timeout time.Duration, pricePerMS types.Currency) error
+	// allowed to spend on faster hosts. If baseSectorOnly is set, only the
+	// base sector is re-uploaded and the fanout is not pinned.
+	PinSkylink(link Skylink, sup SkyfileUploadParameters, baseSectorOnly bool, timeout time.Duration, pricePerMS types.Currency) error
+
+	// PinSkylinkFrom pins a skylink like PinSkylink but fetches its base
+	// sector and fanout using the given fetcher instead of downloading them
+	// from the renter's hosts. The fetched data is verified against the
+	// skylink and its fanout before it is re-uploaded.
+	PinSkylinkFrom(link Skylink, sup SkyfileUploadParameters, fetcher SkynetSectorFetcher, timeout time.Duration) error
 
 	// UnpinSkylink unpins a skylink from the renter by removing the underlying
 	// siafile.
 	UnpinSkylink(skylink Skylink) error
 
+	// VerifySkyfile checks that the base sector stored under the given v1
+	// skylink matches the skylink's merkle root and returns a verifier for
+	// the skyfile's payload. If a skykey is provided, it is used for
+	// decrypting the base sector. The timeout and price per millisecond are
+	// used for fetching the base sector.
+	VerifySkyfile(ctx context.Context, link Skylink, sk *skykey.Skykey, timeout time.Duration, pricePerMS types.Currency) (*SkyfileVerifier, error)
+
 	// Portals returns the list of known skynet portals.
 	Portals() ([]SkynetPortal, error)
 
 	// RestoreSkyfile restores a skyfile such that the skylink is preserved.
 	RestoreSkyfile(reader io.Reader) (Skylink, error)
 
+	// UpdateSkynetAllowlist updates the list of hashed merkleroots that are
+	// approved
+	UpdateSkynetAllowlist(ctx context.Context, additions, removals []string, isHash bool) error
+
 	// UpdateSkynetBlocklist updates the list of hashed merkleroots that are
 	// blocked
 	UpdateSkynetBlocklist(ctx context.Context, additions, removals []string, isHash bool) error
@@ -1587,6 +1810,42 @@ type SkyfileStreamer interface {
 	Skylink() Skylink
 }
 
+// SkyfileHostStatsStreamer is implemented by skyfile streamers which are able
+// to report the hosts that served the data read from them.
+type SkyfileHostStatsStreamer interface {
+	HostStats() []SkynetHostStats
+}
+
+// SkyfileDegradedStreamer is implemented by skyfile streamers which are able
+// to report whether the data read from them was downloaded with fewer usable
+// workers than pieces needed to recover it.
+type SkyfileDegradedStreamer interface {
+	Degraded() bool
+}
+
+// SkyfileFetchLimitStreamer is implemented by skyfile streamers which can be
+// prevented from fetching data beyond a certain offset ahead of time. Reading
+// beyond the limit is still possible but the data is only fetched once it is
+// read.
+type SkyfileFetchLimitStreamer interface {
+	SetFetchLimit(limit uint64)
+}
+
+// SkyfileLazySeekStreamer is implemented by skyfile streamers which can move
+// their read head without fetching the data at the new offset. The data is
+// only fetched once the streamer is read from. Invalid offsets are still
+// rejected right away.
+type SkyfileLazySeekStreamer interface {
+	SeekLazy(offset int64, whence int) (int64, error)
+}
+
+// SkyfilePartialStreamer is implemented by skyfile streamers which are able
+// to report the ranges of the skyfile that can't be recovered from the
+// network.
+type SkyfilePartialStreamer interface {
+	UnrecoverableRanges() ([]SkynetMissingRange, error)
+}
+
 // SkylinkHealth describes the health of a skylink on the network.
 type SkylinkHealth struct {
 	// BaseSectorRedundancy is the number of base sector pieces on the
@@ -1610,6 +1869,55 @@ type SkylinkHealth struct {
 	FanoutRedundancy []float64 `json:"fanoutredundancy,omitempty"`
 }
 
+// SkyfileConsistency is the result of checking whether the layout, fanout and
+// metadata of a skyfile are consistent with each other.
+type SkyfileConsistency struct {
+	// Consistent is true if no inconsistencies were detected.
+	Consistent bool `json:"consistent"`
+
+	// Inconsistencies describes every detected inconsistency.
+	Inconsistencies []string `json:"inconsistencies"`
+}
+
+// SkylinkEncoding describes how efficiently the fanout of a skylink is
+// encoded. Skyfiles which are stored within the base sector don't have a
+// fanout, so all of the fanout specific fields are zero.
+type SkylinkEncoding struct {
+	// CipherType is the type of encryption used for the fanout.
+	CipherType string `json:"ciphertype"`
+
+	// DataPieces and ParityPieces are the erasure coding of the fanout.
+	DataPieces   uint8 `json:"datapieces"`
+	ParityPieces uint8 `json:"paritypieces"`
+
+	// DedupOptimization indicates whether the fanout only contains a single
+	// root per chunk. That is the case for unencrypted 1-of-N skyfiles since
+	// all pieces of a chunk are identical.
+	DedupOptimization bool `json:"dedupoptimization"`
+
+	// Filesize is the size of the skyfile's data.
+	Filesize uint64 `json:"filesize"`
+
+	// NumChunks is the number of chunks in the fanout.
+	NumChunks uint64 `json:"numchunks"`
+
+	// ZeroChunks is the number of chunks of a sparse skyfile which only
+	// contain zeros and are therefore not stored on the network.
+	ZeroChunks uint64 `json:"zerochunks"`
+
+	// TheoreticalSize is the size of the data multiplied by the redundancy
+	// of the erasure coding.
+	TheoreticalSize uint64 `json:"theoreticalsize"`
+
+	// ActualSize is the size of all the sectors that store the fanout on
+	// the network, including padding.
+	ActualSize uint64 `json:"actualsize"`
+
+	// Overhead is the ratio between the actual size and the size of the
+	// data.
+	Overhead float64 `json:"overhead"`
+}
+
 // RenterDownloadParameters defines the parameters passed to the Renter's
 // Download method.
 type RenterDownloadParameters struct {
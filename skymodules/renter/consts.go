@@ -6,6 +6,7 @@ import (
 
 	"gitlab.com/SkynetLabs/skyd/build"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/modules"
 )
 
 // Version and system parameters.
@@ -23,6 +24,45 @@ const (
 	AlertSiafileLowRedundancyThreshold = 0.75
 )
 
+// The following consts are the ids of the skynet specific alerts of the
+// renter.
+const (
+	// AlertIDSkynetRegistryWriteFailures is the id of the alert that is
+	// registered if too many consecutive registry updates failed.
+	AlertIDSkynetRegistryWriteFailures = modules.AlertID("skynet-registry-write-failures")
+
+	// AlertIDSkynetUploadPerformance is the id of the alert that is
+	// registered if the p99 of the base sector uploads exceeds the configured
+	// threshold.
+	AlertIDSkynetUploadPerformance = modules.AlertID("skynet-upload-performance")
+
+	// AlertIDSkynetBlocklistPersist is the id of the alert that is registered
+	// if an update to the blocklist couldn't be persisted.
+	AlertIDSkynetBlocklistPersist = modules.AlertID("skynet-blocklist-persist")
+
+	// AlertIDSkynetPortalsPersist is the id of the alert that is registered
+	// if an update to the portals list couldn't be persisted.
+	AlertIDSkynetPortalsPersist = modules.AlertID("skynet-portals-persist")
+)
+
+const (
+	// AlertMSGSkynetRegistryWriteFailures indicates that registry updates are
+	// failing.
+	AlertMSGSkynetRegistryWriteFailures = "Registry updates are failing consistently"
+
+	// AlertMSGSkynetUploadPerformance indicates that base sector uploads are
+	// slower than the configured threshold.
+	AlertMSGSkynetUploadPerformance = "The p99 of base sector uploads exceeds the configured threshold"
+
+	// AlertMSGSkynetBlocklistPersist indicates that the blocklist couldn't be
+	// persisted.
+	AlertMSGSkynetBlocklistPersist = "The skynet blocklist couldn't be persisted"
+
+	// AlertMSGSkynetPortalsPersist indicates that the portals list couldn't
+	// be persisted.
+	AlertMSGSkynetPortalsPersist = "The skynet portals list couldn't be persisted"
+)
+
 // AlertCauseSiafileLowRedundancy creates a customized "cause" for a siafile
 // with a certain path and health.
 func AlertCauseSiafileLowRedundancy(siaPath skymodules.SiaPath, health, redundancy float64) string {
@@ -48,6 +88,97 @@ var (
 		Standard: time.Minute * 10,
 		Testing:  100 * time.Millisecond,
 	}).(time.Duration)
+
+	// skyfileConversionPruneThreshold is the amount of time the status of a
+	// finished asynchronous skyfile conversion is kept around.
+	skyfileConversionPruneThreshold = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 24 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// accessTimePersistInterval is the minimum amount of time between two
+	// updates of the persisted access time of a skyfile. It limits the write
+	// amplification caused by frequently downloaded skyfiles.
+	accessTimePersistInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Hour,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// pinExpiryCheckInterval is the interval at which the renter checks for
+	// skyfiles with an expired pin TTL and unpins them.
+	pinExpiryCheckInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// skylinkDownloadRetryBaseBackoff is the base of the exponential backoff
+	// between two attempts of a skylink download which failed due to a
+	// temporarily degraded worker pool.
+	skylinkDownloadRetryBaseBackoff = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// skyfilePendingUploadTimeout is the amount of time a skyfile upload can
+	// wait for its metadata before its data is deleted.
+	skyfilePendingUploadTimeout = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 24 * time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// maxSkynetSnapshots is the maximum number of skynet snapshots that are
+	// kept. Once it is exceeded, the oldest snapshots are pruned.
+	maxSkynetSnapshots = build.Select(build.Var{
+		Dev:      20,
+		Standard: 100,
+		Testing:  3,
+	}).(int)
+
+	// maxConcurrentSkylinkPrefetches is the maximum number of skylink
+	// prefetches that are allowed to fetch data at the same time.
+	maxConcurrentSkylinkPrefetches = build.Select(build.Var{
+		Dev:      10,
+		Standard: 50,
+		Testing:  2,
+	}).(int)
+
+	// skylinkPrefetchKeepDuration is the amount of time the stream of a
+	// finished skylink prefetch is kept open to keep the prefetched data in
+	// the stream buffer set.
+	skylinkPrefetchKeepDuration = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// skylinkPrefetchPruneThreshold is the amount of time the status of a
+	// finished skylink prefetch is kept around.
+	skylinkPrefetchPruneThreshold = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// skynetBlocklistHitsSize is the number of attempts to download blocked
+	// content that are kept in memory.
+	skynetBlocklistHitsSize = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testing:  10,
+	}).(int)
+
+	// skynetTraceBufferSize is the number of log lines tagged with a trace ID
+	// that are kept in memory.
+	skynetTraceBufferSize = build.Select(build.Var{
+		Dev:      1000,
+		Standard: 10000,
+		Testing:  1000,
+	}).(int)
 )
 
 // Default memory usage parameters.
@@ -140,6 +271,11 @@ var (
 	}).(int64)
 )
 
+// maxSkylinkDownloadRetries is the maximum number of times a skylink download
+// is retried by the renter after failing due to a temporarily degraded worker
+// pool.
+const maxSkylinkDownloadRetries = 3
+
 // Default bandwidth usage parameters.
 const (
 	// DefaultMaxDownloadSpeed is set to zero to indicate no limit, the user
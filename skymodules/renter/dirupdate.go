@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
@@ -37,6 +38,7 @@ func (r *Renter) managedUpdateDirMetadata(siaPath skymodules.SiaPath) error {
 	//
 	// TODO: Either pass in the stuff from the previous call or combine the
 	// calls.
+	start := time.Now()
 	metadata, err := r.callCalculateDirectoryMetadata(siaPath)
 	if err != nil {
 		e := fmt.Sprintf("could not calculate the metadata of directory '%v'", siaPath.String())
@@ -59,6 +61,12 @@ func (r *Renter) managedUpdateDirMetadata(siaPath skymodules.SiaPath) error {
 		return err
 	}
 
+	// Reconcile the used skynet storage with the new size of the skynet
+	// folder.
+	if siaPath.Equals(skymodules.SkynetFolder) {
+		r.staticSkynetStorageTracker.callReconcile(metadata.AggregateSkynetSize, start)
+	}
+
 	// If we are at the root directory then check if any files were found in
 	// need of repair or and stuck chunks and trigger the appropriate repair
 	// loop. This is only done at the root directory as the repair and stuck
@@ -81,6 +89,7 @@ func (r *Renter) managedUpdateDirMetadata(siaPath skymodules.SiaPath) error {
 		//
 		// TODO: Probably this isn't the right way to be doing delete.
 		r.staticSkylinkManager.callUpdatePruneTimeThreshold(metadata.AggregateLastHealthCheckTime)
+		r.staticSkylinkManager.callPruneAccessTimes()
 	}
 	return nil
 }
@@ -109,6 +118,7 @@ func (r *Renter) managedUpdateFileMetadatasParams(dirSiaPath skymodules.SiaPath,
 					return err
 				}
 				err = sf.UpdateMetadata(offlineMap, goodForRenewMap, contracts, used)
+				err = errors.Compose(err, r.managedPersistAccessTime(sf))
 				return errors.Compose(err, sf.Close())
 			}()
 			errMU.Lock()
@@ -0,0 +1,89 @@
+package renter
+
+// downloadhoststats.go contains the helpers to keep track of which hosts
+// contributed to a download. The stats are derived from the launched worker
+// information that is attached to every download response of a pcws.
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// hostStats maps the public key of a host to the stats of that host.
+type hostStats map[string]*skymodules.SkynetHostStats
+
+// newHostStats creates the host stats for a single chunk download from the
+// workers that were launched for it. The 'length' bytes of the download are
+// attributed evenly to the workers which successfully downloaded a piece,
+// since those are the pieces that the data was recovered from.
+func newHostStats(launchedWorkers []*launchedWorkerInfo, length uint64) hostStats {
+	hs := make(hostStats)
+	var contributors []*skymodules.SkynetHostStats
+	for _, lw := range launchedWorkers {
+		w := lw.staticWorker
+		stats, exists := hs[w.staticHostPubKeyStr]
+		if !exists {
+			stats = &skymodules.SkynetHostStats{
+				HostKey: w.staticHostPubKey,
+			}
+			hs[w.staticHostPubKeyStr] = stats
+		}
+		stats.Overdrive = stats.Overdrive || lw.staticIsOverdriveWorker
+
+		// Workers that haven't responded yet are still accounted for with
+		// the time that passed since they were launched.
+		if lw.completeTime.IsZero() {
+			stats.TotalTimeMS += uint64(time.Since(lw.staticLaunchTime).Milliseconds())
+			continue
+		}
+		stats.TotalTimeMS += uint64(lw.totalDuration.Milliseconds())
+		stats.Bandwidth += lw.bandwidth
+		if lw.jobErr == nil {
+			stats.Sectors++
+			contributors = append(contributors, stats)
+		}
+	}
+
+	// Attribute the downloaded bytes. The first contributor receives the
+	// remainder.
+	if len(contributors) > 0 {
+		share := length / uint64(len(contributors))
+		for _, stats := range contributors {
+			stats.Bytes += share
+		}
+		contributors[0].Bytes += length % uint64(len(contributors))
+	}
+	return hs
+}
+
+// merge adds the stats of 'other' to the stats.
+func (hs hostStats) merge(other hostStats) {
+	for key, s := range other {
+		stats, exists := hs[key]
+		if !exists {
+			stats = &skymodules.SkynetHostStats{
+				HostKey: s.HostKey,
+			}
+			hs[key] = stats
+		}
+		stats.Sectors += s.Sectors
+		stats.Bytes += s.Bytes
+		stats.TotalTimeMS += s.TotalTimeMS
+		stats.Bandwidth += s.Bandwidth
+		stats.Overdrive = stats.Overdrive || s.Overdrive
+	}
+}
+
+// toSlice returns the stats as a slice sorted by host key.
+func (hs hostStats) toSlice() []skymodules.SkynetHostStats {
+	stats := make([]skymodules.SkynetHostStats, 0, len(hs))
+	for _, s := range hs {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].HostKey.String() < stats[j].HostKey.String()
+	})
+	return stats
+}
@@ -0,0 +1,69 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/types"
+)
+
+// TestNewHostStats is a unit test for newHostStats.
+func TestNewHostStats(t *testing.T) {
+	t.Parallel()
+
+	// Create 3 workers.
+	newWorker := func(b byte) *worker {
+		hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{b}}
+		return &worker{
+			staticHostPubKey:    hpk,
+			staticHostPubKeyStr: hpk.String(),
+		}
+	}
+	w1, w2, w3 := newWorker(1), newWorker(2), newWorker(3)
+
+	// The first two workers succeed, one of them twice. The third worker is an
+	// overdrive worker that fails.
+	now := time.Now()
+	launchedWorkers := []*launchedWorkerInfo{
+		{staticWorker: w1, completeTime: now, totalDuration: time.Second, bandwidth: 10},
+		{staticWorker: w2, completeTime: now, totalDuration: time.Second, bandwidth: 10},
+		{staticWorker: w1, completeTime: now, totalDuration: time.Second, bandwidth: 10},
+		{staticWorker: w3, completeTime: now, totalDuration: time.Second, bandwidth: 10, jobErr: errors.New("failed"), staticIsOverdriveWorker: true},
+	}
+	hs := newHostStats(launchedWorkers, 100)
+	if len(hs) != 3 {
+		t.Fatal("wrong number of hosts", len(hs))
+	}
+	s1, s2, s3 := hs[w1.staticHostPubKeyStr], hs[w2.staticHostPubKeyStr], hs[w3.staticHostPubKeyStr]
+	if s1.Sectors != 2 || s1.Bytes != 67 || s1.TotalTimeMS != 2000 || s1.Bandwidth != 20 || s1.Overdrive {
+		t.Fatal("unexpected stats", s1)
+	}
+	if s2.Sectors != 1 || s2.Bytes != 33 || s2.TotalTimeMS != 1000 || s2.Bandwidth != 10 || s2.Overdrive {
+		t.Fatal("unexpected stats", s2)
+	}
+	if s3.Sectors != 0 || s3.Bytes != 0 || s3.TotalTimeMS != 1000 || s3.Bandwidth != 10 || !s3.Overdrive {
+		t.Fatal("unexpected stats", s3)
+	}
+
+	// Merge the stats with themselves.
+	hs.merge(newHostStats(launchedWorkers, 100))
+	stats := hs.toSlice()
+	if len(stats) != 3 {
+		t.Fatal("wrong number of hosts", len(stats))
+	}
+	var bytes, bandwidth uint64
+	for i, s := range stats {
+		bytes += s.Bytes
+		bandwidth += s.Bandwidth
+		if i > 0 && stats[i-1].HostKey.String() >= s.HostKey.String() {
+			t.Fatal("stats aren't sorted")
+		}
+	}
+	if bytes != 200 {
+		t.Fatal("wrong number of bytes", bytes)
+	}
+	if bandwidth != 80 {
+		t.Fatal("wrong bandwidth", bandwidth)
+	}
+}
@@ -1,7 +1,10 @@
 package renter
 
 import (
+	"time"
+
 	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
 
 	"gitlab.com/NebulousLabs/errors"
 )
@@ -15,11 +18,28 @@ func (r *Renter) DeleteFile(siaPath skymodules.SiaPath) error {
 	}
 	defer r.tg.Done()
 
+	// Remember the size of siafiles in the skynet folder to free up the
+	// skynet storage they use.
+	var size uint64
+	var created time.Time
+	if skymodules.IsSkynetDir(siaPath) {
+		sf, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err == nil {
+			size, created = sf.Size(), sf.CreateTime()
+			err = sf.Close()
+		}
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			return errors.AddContext(err, "unable to open siafile")
+		}
+	}
+
 	// Perform the delete operation.
 	err = r.staticFileSystem.DeleteFile(siaPath)
 	if err != nil {
 		return errors.AddContext(err, "unable to delete siafile from filesystem")
 	}
+	r.staticSkynetStorageTracker.callFileDeleted(siaPath, size, created)
+	r.staticSkynetSearchIndex.callRemove(siaPath)
 
 	// Update the filesystem metadata.
 	//
@@ -98,6 +118,7 @@ func (r *Renter) RenameFile(currentName, newName skymodules.SiaPath) error {
 	if err != nil {
 		return err
 	}
+	r.staticSkynetSearchIndex.callRename(currentName, newName)
 
 	// Queue an update on each parent dir of the filenames to ensure that the
 	// aggregate metadata is correctly updated.
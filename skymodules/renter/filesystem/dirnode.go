@@ -584,8 +584,11 @@ func (n *DirNode) managedInfo(siaPath skymodules.SiaPath) (skymodules.DirectoryI
 		AggregateStuckSize:           metadata.AggregateStuckSize,
 
 		// Skynet Fields
-		AggregateSkynetFiles: metadata.AggregateSkynetFiles,
-		AggregateSkynetSize:  metadata.AggregateSkynetSize,
+		AggregateSkynetExtendedFiles: metadata.AggregateSkynetExtendedFiles,
+		AggregateSkynetFiles:         metadata.AggregateSkynetFiles,
+		AggregateSkynetRawSize:       metadata.AggregateSkynetRawSize,
+		AggregateSkynetSize:          metadata.AggregateSkynetSize,
+		AggregateSkynetSkylinks:      metadata.AggregateSkynetSkylinks,
 
 		// SiaDir Fields
 		Health:              metadata.Health,
@@ -607,8 +610,11 @@ func (n *DirNode) managedInfo(siaPath skymodules.SiaPath) (skymodules.DirectoryI
 		UID:                 n.staticUID,
 
 		// Skynet Fields
-		SkynetFiles: metadata.SkynetFiles,
-		SkynetSize:  metadata.SkynetSize,
+		SkynetExtendedFiles: metadata.SkynetExtendedFiles,
+		SkynetFiles:         metadata.SkynetFiles,
+		SkynetRawSize:       metadata.SkynetRawSize,
+		SkynetSize:          metadata.SkynetSize,
+		SkynetSkylinks:      metadata.SkynetSkylinks,
 	}, nil
 }
 
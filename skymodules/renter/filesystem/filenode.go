@@ -154,6 +154,7 @@ func (n *FileNode) managedFileInfo(siaPath skymodules.SiaPath, offline map[strin
 		ModificationTime: md.ModTime,
 		NumStuckChunks:   numStuckChunks,
 		OnDisk:           onDisk,
+		PinExpiry:        md.PinExpiry,
 		Recoverable:      onDisk || redundancy >= 1,
 		Redundancy:       redundancy,
 		Renewing:         true,
@@ -240,6 +241,7 @@ func (n *FileNode) staticCachedInfo(siaPath skymodules.SiaPath) (skymodules.File
 		ModificationTime: md.ModTime,
 		NumStuckChunks:   md.NumStuckChunks,
 		OnDisk:           onDisk,
+		PinExpiry:        md.PinExpiry,
 		Recoverable:      onDisk || md.CachedUserRedundancy >= 1,
 		Redundancy:       md.CachedUserRedundancy,
 		Renewing:         true,
@@ -206,8 +206,11 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	sd.metadata.AggregateStuckHealth = metadata.AggregateStuckHealth
 	sd.metadata.AggregateStuckSize = metadata.AggregateStuckSize
 
+	sd.metadata.AggregateSkynetExtendedFiles = metadata.AggregateSkynetExtendedFiles
 	sd.metadata.AggregateSkynetFiles = metadata.AggregateSkynetFiles
+	sd.metadata.AggregateSkynetRawSize = metadata.AggregateSkynetRawSize
 	sd.metadata.AggregateSkynetSize = metadata.AggregateSkynetSize
+	sd.metadata.AggregateSkynetSkylinks = metadata.AggregateSkynetSkylinks
 
 	sd.metadata.Health = metadata.Health
 	sd.metadata.LastHealthCheckTime = metadata.LastHealthCheckTime
@@ -225,8 +228,11 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	sd.metadata.StuckHealth = metadata.StuckHealth
 	sd.metadata.StuckSize = metadata.StuckSize
 
+	sd.metadata.SkynetExtendedFiles = metadata.SkynetExtendedFiles
 	sd.metadata.SkynetFiles = metadata.SkynetFiles
+	sd.metadata.SkynetRawSize = metadata.SkynetRawSize
 	sd.metadata.SkynetSize = metadata.SkynetSize
+	sd.metadata.SkynetSkylinks = metadata.SkynetSkylinks
 
 	// NOTE: We're setting the version manually here because we are saving the
 	// metadata to disk using the most recent code. If the metadata used to have
@@ -1,6 +1,8 @@
 package siadir
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -9,6 +11,7 @@ import (
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/persist"
 )
@@ -24,6 +27,7 @@ func TestPersist(t *testing.T) {
 
 	t.Run("CallLoadSiaDirMetadata", testCallLoadSiaDirMetadata)
 	t.Run("CreateDirMetadataAll", testCreateDirMetadataAll)
+	t.Run("LoadMissingSkynetFields", testLoadMissingSkynetFields)
 }
 
 // testCallLoadSiaDirMetadata probes the callLoadSiaDirMetadata function
@@ -94,6 +98,64 @@ func testCallLoadSiaDirMetadata(t *testing.T) {
 	}
 }
 
+// testLoadMissingSkynetFields makes sure that metadata which was persisted
+// before the skynet extended files and skylinks were tracked can still be
+// loaded and defaults the missing fields to zero.
+func testLoadMissingSkynetFields(t *testing.T) {
+	testDir, err := newSiaDirTestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Marshal random metadata without the new fields.
+	md := randomMetadata()
+	mdBytes, err := json.Marshal(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(mdBytes, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"aggregateskynetextendedfiles", "aggregateskynetskylinks", "skynetextendedfiles", "skynetskylinks"} {
+		if _, exists := fields[field]; !exists {
+			t.Fatal("missing field", field)
+		}
+		delete(fields, field)
+	}
+	mdBytes, err = json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write it to disk with its checksum.
+	path := filepath.Join(testDir, modules.SiaDirExtension)
+	checksum := crypto.HashBytes(mdBytes)
+	err = ioutil.WriteFile(path, append(checksum[:], mdBytes...), modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load it. The missing fields should be zero.
+	loaded, err := callLoadSiaDirMetadata(path, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.AggregateSkynetExtendedFiles = 0
+	md.AggregateSkynetSkylinks = 0
+	md.SkynetExtendedFiles = 0
+	md.SkynetSkylinks = 0
+	// The times lose their monotonic clock reading when persisted.
+	md.AggregateLastHealthCheckTime = loaded.AggregateLastHealthCheckTime
+	md.AggregateModTime = loaded.AggregateModTime
+	md.LastHealthCheckTime = loaded.LastHealthCheckTime
+	md.ModTime = loaded.ModTime
+	if err := EqualMetadatas(md, loaded); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // testCreateDirMetadataAll probes the case of a potential infinite loop in
 // createDirMetadataAll
 func testCreateDirMetadataAll(t *testing.T) {
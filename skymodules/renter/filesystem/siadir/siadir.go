@@ -84,8 +84,11 @@ type (
 		AggregateStuckSize           uint64    `json:"aggregatestucksize"`
 
 		// Aggregate Skynet Specific Stats
-		AggregateSkynetFiles uint64 `json:"aggregateskynetfiles"`
-		AggregateSkynetSize  uint64 `json:"aggregateskynetsize"`
+		AggregateSkynetExtendedFiles uint64 `json:"aggregateskynetextendedfiles"`
+		AggregateSkynetFiles         uint64 `json:"aggregateskynetfiles"`
+		AggregateSkynetRawSize       uint64 `json:"aggregateskynetrawsize"`
+		AggregateSkynetSize          uint64 `json:"aggregateskynetsize"`
+		AggregateSkynetSkylinks      uint64 `json:"aggregateskynetskylinks"`
 
 		// The following fields are information specific to the siadir that is not
 		// an aggregate of the entire sub directory tree
@@ -106,8 +109,11 @@ type (
 		StuckSize           uint64      `json:"stucksize"`
 
 		// Skynet Specific Stats
-		SkynetFiles uint64 `json:"skynetfiles"`
-		SkynetSize  uint64 `json:"skynetsize"`
+		SkynetExtendedFiles uint64 `json:"skynetextendedfiles"`
+		SkynetFiles         uint64 `json:"skynetfiles"`
+		SkynetRawSize       uint64 `json:"skynetrawsize"`
+		SkynetSize          uint64 `json:"skynetsize"`
+		SkynetSkylinks      uint64 `json:"skynetskylinks"`
 
 		// Version is the used version of the header file.
 		Version string `json:"version"`
@@ -163,12 +169,21 @@ func EqualMetadatas(md1, md2 Metadata) (err error) {
 	}
 
 	// Aggregate Skynet Fields
+	if md1.AggregateSkynetExtendedFiles != md2.AggregateSkynetExtendedFiles {
+		err = errors.Compose(err, fmt.Errorf("AggregateSkynetExtendedFiles not equal, %v and %v", md1.AggregateSkynetExtendedFiles, md2.AggregateSkynetExtendedFiles))
+	}
 	if md1.AggregateSkynetFiles != md2.AggregateSkynetFiles {
 		err = errors.Compose(err, fmt.Errorf("AggregateSkynetFiles not equal, %v and %v", md1.AggregateSkynetFiles, md2.AggregateSkynetFiles))
 	}
+	if md1.AggregateSkynetRawSize != md2.AggregateSkynetRawSize {
+		err = errors.Compose(err, fmt.Errorf("AggregateSkynetRawSize not equal, %v and %v", md1.AggregateSkynetRawSize, md2.AggregateSkynetRawSize))
+	}
 	if md1.AggregateSkynetSize != md2.AggregateSkynetSize {
 		err = errors.Compose(err, fmt.Errorf("AggregateSkynetSize not equal, %v and %v", md1.AggregateSkynetSize, md2.AggregateSkynetSize))
 	}
+	if md1.AggregateSkynetSkylinks != md2.AggregateSkynetSkylinks {
+		err = errors.Compose(err, fmt.Errorf("AggregateSkynetSkylinks not equal, %v and %v", md1.AggregateSkynetSkylinks, md2.AggregateSkynetSkylinks))
+	}
 
 	// Check SiaDir Fields
 	if md1.Health != md2.Health {
@@ -215,12 +230,21 @@ func EqualMetadatas(md1, md2 Metadata) (err error) {
 	}
 
 	// Skynet Fields
+	if md1.SkynetExtendedFiles != md2.SkynetExtendedFiles {
+		err = errors.Compose(err, fmt.Errorf("SkynetExtendedFiles not equal, %v and %v", md1.SkynetExtendedFiles, md2.SkynetExtendedFiles))
+	}
 	if md1.SkynetFiles != md2.SkynetFiles {
 		err = errors.Compose(err, fmt.Errorf("SkynetFiles not equal, %v and %v", md1.SkynetFiles, md2.SkynetFiles))
 	}
+	if md1.SkynetRawSize != md2.SkynetRawSize {
+		err = errors.Compose(err, fmt.Errorf("SkynetRawSize not equal, %v and %v", md1.SkynetRawSize, md2.SkynetRawSize))
+	}
 	if md1.SkynetSize != md2.SkynetSize {
 		err = errors.Compose(err, fmt.Errorf("SkynetSize not equal, %v and %v", md1.SkynetSize, md2.SkynetSize))
 	}
+	if md1.SkynetSkylinks != md2.SkynetSkylinks {
+		err = errors.Compose(err, fmt.Errorf("SkynetSkylinks not equal, %v and %v", md1.SkynetSkylinks, md2.SkynetSkylinks))
+	}
 	return
 }
 
@@ -38,8 +38,11 @@ func randomMetadata() Metadata {
 		AggregateStuckHealth:         float64(fastrand.Intn(100)),
 		AggregateStuckSize:           fastrand.Uint64n(100),
 
-		AggregateSkynetFiles: fastrand.Uint64n(100),
-		AggregateSkynetSize:  fastrand.Uint64n(100),
+		AggregateSkynetExtendedFiles: fastrand.Uint64n(100),
+		AggregateSkynetFiles:         fastrand.Uint64n(100),
+		AggregateSkynetRawSize:       fastrand.Uint64n(100),
+		AggregateSkynetSize:          fastrand.Uint64n(100),
+		AggregateSkynetSkylinks:      fastrand.Uint64n(100),
 
 		Health:              float64(fastrand.Intn(100)),
 		LastHealthCheckTime: time.Now(),
@@ -56,8 +59,11 @@ func randomMetadata() Metadata {
 		StuckHealth:         float64(fastrand.Intn(100)),
 		StuckSize:           fastrand.Uint64n(100),
 
-		SkynetFiles: fastrand.Uint64n(100),
-		SkynetSize:  fastrand.Uint64n(100),
+		SkynetExtendedFiles: fastrand.Uint64n(100),
+		SkynetFiles:         fastrand.Uint64n(100),
+		SkynetRawSize:       fastrand.Uint64n(100),
+		SkynetSize:          fastrand.Uint64n(100),
+		SkynetSkylinks:      fastrand.Uint64n(100),
 	}
 	return md
 }
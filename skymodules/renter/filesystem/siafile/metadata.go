@@ -150,6 +150,17 @@ type (
 		// skyfiles, those skyfiles will be listed here. It should be noted that
 		// a single siafile can be responsible for tracking many skyfiles.
 		Skylinks []string `json:"skylinks"`
+
+		// PinExpiry is the time after which the skyfile tracked by this
+		// siafile is automatically unpinned. A zero value means that the pin
+		// doesn't expire.
+		PinExpiry time.Time `json:"pinexpiry"`
+
+		// SkynetPaddingSize is the number of bytes of the file which don't
+		// belong to the data of a skyfile. For base sectors that includes
+		// the layout, metadata, fanout and the padding of the sector. It
+		// is used to compute the raw size of the skyfiles in a directory.
+		SkynetPaddingSize uint64 `json:"skynetpaddingsize"`
 	}
 
 	// BubbledMetadata is the metadata of a siafile that gets bubbled
@@ -165,6 +176,7 @@ type (
 		Redundancy          float64
 		RepairBytes         uint64
 		Size                uint64
+		SkynetRawSize       uint64
 		StuckBytes          uint64
 		StuckHealth         float64
 		UID                 SiafileUID
@@ -335,6 +347,8 @@ func (md Metadata) backup() (b Metadata) {
 	b.GroupID = md.GroupID
 	b.ChunkOffset = md.ChunkOffset
 	b.PubKeyTableOffset = md.PubKeyTableOffset
+	b.SkynetPaddingSize = md.SkynetPaddingSize
+	b.PinExpiry = md.PinExpiry
 	// Special handling for slice since reflect.DeepEqual is false when
 	// comparing empty slice to nil.
 	if md.Skylinks == nil {
@@ -379,6 +393,8 @@ func (md *Metadata) restore(b Metadata) {
 	md.GroupID = b.GroupID
 	md.ChunkOffset = b.ChunkOffset
 	md.PubKeyTableOffset = b.PubKeyTableOffset
+	md.SkynetPaddingSize = b.SkynetPaddingSize
+	md.PinExpiry = b.PinExpiry
 	md.Skylinks = b.Skylinks
 	// If the backup was successful it should match the backup.
 	if build.Release == "testing" && !md.equals(b) {
@@ -510,6 +526,27 @@ func (sf *SiaFile) SetLocalPath(path string) (err error) {
 	return sf.saveMetadata()
 }
 
+// SetSkynetPaddingSize sets the number of bytes of the file which don't belong
+// to the data of a skyfile.
+func (sf *SiaFile) SetSkynetPaddingSize(paddingSize uint64) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if paddingSize > uint64(sf.staticMetadata.FileSize) {
+		return fmt.Errorf("padding size %v can't be larger than the file size %v", paddingSize, sf.staticMetadata.FileSize)
+	}
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	sf.staticMetadata.SkynetPaddingSize = paddingSize
+
+	// Save changes to metadata to disk.
+	return sf.saveMetadata()
+}
+
 // Size returns the file's size.
 func (sf *SiaFile) Size() uint64 {
 	sf.mu.RLock()
@@ -539,6 +576,40 @@ func (sf *SiaFile) UpdateAccessTime() (err error) {
 	return sf.saveMetadata()
 }
 
+// SetAccessTime sets the AccessTime field of the metadata to the provided time.
+func (sf *SiaFile) SetAccessTime(t time.Time) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	sf.staticMetadata.AccessTime = t
+
+	// Save changes to metadata to disk.
+	return sf.saveMetadata()
+}
+
+// SetPinExpiry sets the PinExpiry field of the metadata to the provided time.
+func (sf *SiaFile) SetPinExpiry(t time.Time) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	sf.staticMetadata.PinExpiry = t
+
+	// Save changes to metadata to disk.
+	return sf.saveMetadata()
+}
+
 // numStuckChunks returns the number of stuck chunks recorded in the file's
 // metadata.
 func (sf *SiaFile) numStuckChunks() uint64 {
@@ -57,8 +57,11 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath skymodules.SiaPath) (sia
 		AggregateStuckHealth:         siadir.DefaultDirHealth,
 		AggregateStuckSize:           uint64(0),
 
-		AggregateSkynetFiles: uint64(0),
-		AggregateSkynetSize:  uint64(0),
+		AggregateSkynetExtendedFiles: uint64(0),
+		AggregateSkynetFiles:         uint64(0),
+		AggregateSkynetRawSize:       uint64(0),
+		AggregateSkynetSize:          uint64(0),
+		AggregateSkynetSkylinks:      uint64(0),
 
 		Health:              siadir.DefaultDirHealth,
 		LastHealthCheckTime: now,
@@ -75,8 +78,11 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath skymodules.SiaPath) (sia
 		StuckHealth:         siadir.DefaultDirHealth,
 		StuckSize:           uint64(0),
 
-		SkynetFiles: uint64(0),
-		SkynetSize:  uint64(0),
+		SkynetExtendedFiles: uint64(0),
+		SkynetFiles:         uint64(0),
+		SkynetRawSize:       uint64(0),
+		SkynetSize:          uint64(0),
+		SkynetSkylinks:      uint64(0),
 	}
 	// Read directory
 	fileinfos, err := r.staticFileSystem.ReadDir(siaPath)
@@ -253,22 +259,37 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath skymodules.SiaPath) (sia
 			// contains a skylink in the metadata, then we count the file towards the
 			// Skynet Stats.
 			//
-			// For all cases we count the size.
+			// For all cases we count the size. The raw size only counts the
+			// bytes of the actual skyfile data.
 			//
 			// We only count the file towards the number of files if it is in the
 			// skynet folder and is not extended. We do not count files outside of the
 			// skynet folder because they should be treated as an extended file.
+			// Extended files in the skynet folder are counted separately.
+			//
+			// The skylinks of extended files are not counted since they are the
+			// same as the skylinks of the files they extend.
 			isSkynetDir := skymodules.IsSkynetDir(siaPath)
 			isExtended := strings.Contains(fileSiaPath.String(), skymodules.ExtendedSuffix)
 			hasSkylinks := fileMetadata.NumSkylinks > 0
 			if isSkynetDir || hasSkylinks {
+				metadata.AggregateSkynetRawSize += fileMetadata.SkynetRawSize
 				metadata.AggregateSkynetSize += fileMetadata.Size
+				metadata.SkynetRawSize += fileMetadata.SkynetRawSize
 				metadata.SkynetSize += fileMetadata.Size
+				if !isExtended {
+					metadata.AggregateSkynetSkylinks += fileMetadata.NumSkylinks
+					metadata.SkynetSkylinks += fileMetadata.NumSkylinks
+				}
 			}
 			if isSkynetDir && !isExtended {
 				metadata.AggregateSkynetFiles++
 				metadata.SkynetFiles++
 			}
+			if isSkynetDir && isExtended {
+				metadata.AggregateSkynetExtendedFiles++
+				metadata.SkynetExtendedFiles++
+			}
 		} else if len(dirMetadatas) > 0 {
 			// Get next dir's metadata.
 			dirMetadata := dirMetadatas[0]
@@ -302,8 +323,11 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath skymodules.SiaPath) (sia
 			metadata.AggregateStuckSize += dirMetadata.AggregateStuckSize
 
 			// Update aggregate Skynet fields
+			metadata.AggregateSkynetExtendedFiles += dirMetadata.AggregateSkynetExtendedFiles
 			metadata.AggregateSkynetFiles += dirMetadata.AggregateSkynetFiles
+			metadata.AggregateSkynetRawSize += dirMetadata.AggregateSkynetRawSize
 			metadata.AggregateSkynetSize += dirMetadata.AggregateSkynetSize
+			metadata.AggregateSkynetSkylinks += dirMetadata.AggregateSkynetSkylinks
 
 			// Add 1 to the AggregateNumSubDirs to account for this subdirectory.
 			metadata.AggregateNumSubDirs++
@@ -383,6 +407,12 @@ func (r *Renter) managedCachedFileMetadata(siaPath skymodules.SiaPath) (bubbledS
 	_, err = os.Stat(md.LocalPath)
 	onDisk := err == nil
 
+	// Compute the raw size of the file's skyfile data.
+	var skynetRawSize uint64
+	if uint64(md.FileSize) > md.SkynetPaddingSize {
+		skynetRawSize = uint64(md.FileSize) - md.SkynetPaddingSize
+	}
+
 	// Check if file is unrecoverable and log it
 	maxHealth := math.Max(md.CachedHealth, md.CachedStuckHealth)
 	unrecoverable := siafile.Unrecoverable(maxHealth, onDisk)
@@ -405,6 +435,7 @@ func (r *Renter) managedCachedFileMetadata(siaPath skymodules.SiaPath) (bubbledS
 			Redundancy:          md.CachedRedundancy,
 			RepairBytes:         md.CachedRepairBytes,
 			Size:                uint64(md.FileSize),
+			SkynetRawSize:       skynetRawSize,
 			StuckHealth:         md.CachedStuckHealth,
 			StuckBytes:          md.CachedStuckBytes,
 			UID:                 md.UniqueID,
@@ -187,6 +187,11 @@ func TestCalculateDirectoryMetadata(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	// Mark half of the file as padding.
+	err = rootSkyFile.SetSkynetPaddingSize(fileSize / 2)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Update siafile metadatas
 	err = rt.updateFileMetadatas(skymodules.RootSiaPath())
@@ -202,8 +207,11 @@ func TestCalculateDirectoryMetadata(t *testing.T) {
 		AggregateRepairSize:          repairSize,
 		AggregateSize:                modules.SectorSize,
 
-		AggregateSkynetFiles: 1,
-		AggregateSkynetSize:  modules.SectorSize,
+		AggregateSkynetExtendedFiles: 1,
+		AggregateSkynetFiles:         1,
+		AggregateSkynetRawSize:       fileSize,
+		AggregateSkynetSize:          modules.SectorSize,
+		AggregateSkynetSkylinks:      1,
 	}
 	if err := rt.openAndUpdateDir(skymodules.VarFolder, varMetadata); err != nil {
 		t.Fatal(err)
@@ -243,8 +251,11 @@ func TestCalculateDirectoryMetadata(t *testing.T) {
 		AggregateStuckHealth:         0,
 		AggregateStuckSize:           0,
 
-		AggregateSkynetFiles: 1,
-		AggregateSkynetSize:  fileSize + modules.SectorSize,
+		AggregateSkynetExtendedFiles: 1,
+		AggregateSkynetFiles:         1,
+		AggregateSkynetRawSize:       fileSize + fileSize/2,
+		AggregateSkynetSize:          fileSize + modules.SectorSize,
+		AggregateSkynetSkylinks:      2,
 
 		Health:              worstFileHealth,
 		LastHealthCheckTime: beforeUpdate,
@@ -260,8 +271,11 @@ func TestCalculateDirectoryMetadata(t *testing.T) {
 		StuckHealth:         0,
 		StuckSize:           0,
 
-		SkynetFiles: 0,
-		SkynetSize:  fileSize,
+		SkynetExtendedFiles: 0,
+		SkynetFiles:         0,
+		SkynetRawSize:       fileSize / 2,
+		SkynetSize:          fileSize,
+		SkynetSkylinks:      1,
 	}
 
 	// call callCalculateDirectoryMetadata
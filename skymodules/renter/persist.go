@@ -13,6 +13,7 @@ import (
 	"gitlab.com/SkynetLabs/skyd/skymodules"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem/siafile"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
 )
@@ -86,10 +87,28 @@ var (
 type (
 	// persist contains all of the persistent renter data.
 	persistence struct {
-		MaxDownloadSpeed int64
-		MaxUploadSpeed   int64
-		UploadedBackups  []skymodules.UploadedBackup
-		SyncedContracts  []types.FileContractID
+		DefaultBaseChunkRedundancy   uint8
+		MaxDownloadSpeed             int64
+		MaxUploadSpeed               int64
+		SkynetAllowlistEnforced      bool
+		SkynetAPIKeys                []skymodules.SkynetAPIKey
+		SkynetCORSOrigins            []string
+		SkynetDefaultRequestTimeout  uint64
+		SkynetDegradedDownloads      bool
+		SkynetForceTokenKey          types.SiaPublicKey
+		SkynetMaintenance            skymodules.SkynetMaintenance
+		SkynetMaxDataURISize         uint64
+		SkynetMaxRequestTimeout      uint64
+		SkynetMaxUploadSize          uint64
+		SkynetMultipartLimits        skymodules.SkynetMultipartLimits
+		SkynetPortalSecretKey        crypto.SecretKey
+		SkynetStorageCap             uint64
+		SkynetUploadAlertThresholdMS uint64
+		SkynetUploadPolicy           skymodules.SkynetUploadPolicy
+		SkynetUploadRateLimit        skymodules.SkynetUploadRateLimit
+		SkynetWeakETags              bool
+		UploadedBackups              []skymodules.UploadedBackup
+		SyncedContracts              []types.FileContractID
 	}
 )
 
@@ -168,6 +187,12 @@ func (r *Renter) managedLoadSettings() error {
 		return err
 	}
 
+	// Generate the portal key if the renter doesn't have one yet.
+	err = r.managedInitSkynetPortalKey()
+	if err != nil {
+		return errors.AddContext(err, "failed to init portal key")
+	}
+
 	// Set the bandwidth limits on the contractor, which was already initialized
 	// without bandwidth limits.
 	return r.staticSetBandwidthLimits(r.persist.MaxDownloadSpeed, r.persist.MaxUploadSpeed)
@@ -3,6 +3,7 @@ package renter
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -127,13 +128,42 @@ func TestRenterSaveLoad(t *testing.T) {
 	// download speed.
 	newDownSpeed := int64(300e3)
 	newUpSpeed := int64(500e3)
+	newMaintenance := skymodules.SkynetMaintenance{
+		Enabled: true,
+		Message: "maintenance",
+	}
+	newUploadRateLimit := skymodules.SkynetUploadRateLimit{
+		MaxUploads:    10,
+		MaxBytes:      1 << 20,
+		WindowSeconds: 60,
+	}
+	newMultipartLimits := skymodules.SkynetMultipartLimits{
+		MaxFilenameLength: 255,
+		MaxHeaderBytes:    1 << 16,
+	}
 	settings.MaxDownloadSpeed = newDownSpeed
 	settings.MaxUploadSpeed = newUpSpeed
+	settings.SkynetMaintenance = newMaintenance
+	settings.SkynetUploadRateLimit = newUploadRateLimit
+	settings.SkynetMultipartLimits = newMultipartLimits
+	settings.SkynetDegradedDownloads = true
 	err = rt.renter.SetSettings(settings)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// Create an API key.
+	apiKey, apiKeyInfo, err := rt.renter.CreateSkynetAPIKey(skymodules.SkynetAPIKeyScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fetch the portal key.
+	portalKey, err := rt.renter.SkynetPortalKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Add a file to the renter
 	entry, err := rt.renter.newRenterTestFile()
 	if err != nil {
@@ -183,6 +213,47 @@ func TestRenterSaveLoad(t *testing.T) {
 	if newSettings.MaxUploadSpeed != newUpSpeed {
 		t.Error("upload settings not being persisted correctly")
 	}
+	if newSettings.SkynetMaintenance != newMaintenance {
+		t.Error("maintenance mode not being persisted correctly")
+	}
+	if newSettings.SkynetUploadRateLimit != newUploadRateLimit {
+		t.Error("upload rate limit not being persisted correctly")
+	}
+	if newSettings.SkynetMultipartLimits != newMultipartLimits {
+		t.Error("multipart limits not being persisted correctly")
+	}
+	if !newSettings.SkynetDegradedDownloads {
+		t.Error("degraded downloads not being persisted correctly")
+	}
+
+	// The API key should be persisted but only its hash.
+	scope, err := rt.renter.SkynetAPIKeyScope(apiKey)
+	if err != nil || scope != skymodules.SkynetAPIKeyScopeRead {
+		t.Error("api key not being persisted correctly", scope, err)
+	}
+	keys, err := rt.renter.SkynetAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != apiKeyInfo.ID {
+		t.Error("api keys not being persisted correctly", keys)
+	}
+	persistBytes, err := ioutil.ReadFile(filepath.Join(rt.dir, skymodules.RenterDir, PersistFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(persistBytes, []byte(apiKey)) {
+		t.Error("api key was persisted in plaintext")
+	}
+
+	// The portal key shouldn't change.
+	newPortalKey, err := rt.renter.SkynetPortalKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newPortalKey.Equals(portalKey) {
+		t.Error("portal key not being persisted correctly")
+	}
 
 	// Check that SiaFileSet loaded the renter's file
 	_, err = rt.renter.staticFileSystem.OpenSiaFile(siapath)
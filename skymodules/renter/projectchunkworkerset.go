@@ -167,6 +167,20 @@ type chunkFetcher interface {
 	Download(ctx context.Context, pricePerMS types.Currency, offset, length uint64, skipRecovery, lowPrio bool) (chan *downloadResponse, error)
 }
 
+// zeroChunkFetcher is the chunkFetcher for the zero chunks of sparse skyfiles.
+// It returns zeros without contacting any hosts.
+type zeroChunkFetcher struct{}
+
+// Download returns a response with the requested number of zeros.
+func (zeroChunkFetcher) Download(_ context.Context, _ types.Currency, _, length uint64, _, _ bool) (chan *downloadResponse, error) {
+	responseChan := make(chan *downloadResponse, 1)
+	responseChan <- &downloadResponse{
+		data: make([]byte, length),
+	}
+	close(responseChan)
+	return responseChan, nil
+}
+
 // Download will download a range from a chunk.
 func (pcws *projectChunkWorkerSet) Download(ctx context.Context, pricePerMS types.Currency, offset, length uint64, skipRecovery, lowPrio bool) (chan *downloadResponse, error) {
 	return pcws.managedDownload(ctx, pricePerMS, offset, length, skipRecovery, lowPrio)
@@ -357,6 +371,22 @@ func (pcws *projectChunkWorkerSet) managedWorkerState() *pcwsWorkerState {
 	return pcws.workerState
 }
 
+// managedRecoverable returns whether enough pieces of the chunk were found on
+// the network to recover it. It waits for the current worker state to resolve
+// all of its workers or until the ctx is closed.
+func (pcws *projectChunkWorkerSet) managedRecoverable(ctx context.Context) bool {
+	available := make(map[uint64]struct{})
+	for _, resp := range pcws.managedWorkerState().WaitForResults(ctx) {
+		if resp.err != nil {
+			continue
+		}
+		for _, pieceIndex := range resp.pieceIndices {
+			available[pieceIndex] = struct{}{}
+		}
+	}
+	return len(available) >= pcws.staticErasureCoder.MinPieces()
+}
+
 // managedTryUpdateWorkerState will check whether the worker state needs to be
 // refreshed. If so, it will refresh the worker state.
 func (pcws *projectChunkWorkerSet) managedTryUpdateWorkerState() error {
@@ -518,8 +548,9 @@ func (pcws *projectChunkWorkerSet) managedDownload(ctx context.Context, pricePer
 		piecesInfo:         make([]pieceInfo, ec.NumPieces()),
 		staticSkipRecovery: skipRecovery,
 
-		staticIsLowPrio:  lowPrio,
-		staticLaunchTime: time.Now(),
+		staticAllowDegraded: pcws.staticRenter.managedSkynetDegradedDownloads(),
+		staticIsLowPrio:     lowPrio,
+		staticLaunchTime:    time.Now(),
 
 		staticPieceIndices:   pieceIndices,
 		ctx:                  ctx,
@@ -1,6 +1,7 @@
 package renter
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -40,6 +41,7 @@ func TestPCWS(t *testing.T) {
 	}()
 
 	t.Run("basic", func(t *testing.T) { testBasic(t, wt) })
+	t.Run("degraded", func(t *testing.T) { testDegraded(t, wt) })
 	t.Run("multiple", func(t *testing.T) { testMultiple(t, wt) })
 	t.Run("newPCWSByRoots", testNewPCWSByRoots)
 }
@@ -146,6 +148,87 @@ func testBasic(t *testing.T, wt *workerTester) {
 	}
 }
 
+// testDegraded verifies that a chunk can only be downloaded from fewer workers
+// than pieces needed to recover it if degraded downloads are enabled.
+func testDegraded(t *testing.T, wt *workerTester) {
+	// create a ctx with test span
+	ctx := opentracing.ContextWithSpan(context.Background(), testSpan())
+	r := wt.staticRenter
+
+	// create a chunk with 2 data pieces and store all of its pieces on the
+	// only host, leaving us with a single worker for the download
+	ec, err := skymodules.NewRSCode(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(int(2 * modules.SectorSize))
+	pieces, err := ec.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, len(pieces))
+	for i, piece := range pieces {
+		roots[i] = crypto.MerkleRoot(piece)
+		err = wt.host.AddSector(roots[i], piece)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// create PCWS and wait until the worker resolved
+	pcws, err := r.newPCWSByRoots(ctx, roots, ec, ptck, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcws.managedWorkerState().WaitForResults(ctx)
+
+	// create a helper function that downloads the chunk
+	download := func() *downloadResponse {
+		respChan, err := pcws.managedDownload(ctx, types.ZeroCurrency, 0, uint64(len(data)), false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return <-respChan
+	}
+
+	// without degraded downloads there are not enough workers
+	resp := download()
+	if !errors.Contains(resp.err, errNotEnoughWorkers) || errors.Contains(resp.err, errNoUsableWorkers) {
+		t.Fatal("unexpected error", resp.err)
+	}
+
+	// enable degraded downloads
+	setDegraded := func(degraded bool) {
+		settings, err := r.Settings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		settings.SkynetDegradedDownloads = degraded
+		err = r.SetSettings(settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	setDegraded(true)
+	defer setDegraded(false)
+
+	// the worker should download both pieces
+	resp = download()
+	if resp.err != nil {
+		t.Fatal(resp.err)
+	}
+	if !resp.degraded {
+		t.Fatal("download should be degraded")
+	}
+	if !bytes.Equal(resp.data, data) {
+		t.Fatal("wrong data")
+	}
+}
+
 // testMultiple verifies the PCWS for a multiple sector lookup on multiple
 // hosts.
 func testMultiple(t *testing.T, wt *workerTester) {
@@ -22,6 +22,10 @@ var (
 	// successfully complete the download
 	errNotEnoughPieces = errors.New("not enough pieces to complete download")
 
+	// errNoUsableWorkers is returned if none of the workers is usable for
+	// downloading the chunk.
+	errNoUsableWorkers = errors.New("no usable workers to complete download")
+
 	// errNotEnoughWorkers is returned if the working set does not have enough
 	// workers to successfully complete the download
 	errNotEnoughWorkers = errors.New("not enough workers to complete download")
@@ -83,6 +87,13 @@ type (
 		// effect on the read jobs scheduled by the PDC.
 		staticIsLowPrio bool
 
+		// staticAllowDegraded indicates whether the download may proceed with
+		// fewer usable workers than pieces needed to recover the chunk. In
+		// that case the workers need to download multiple pieces each and
+		// degraded is set.
+		staticAllowDegraded bool
+		degraded            bool
+
 		// staticLaunchTime indicates when this PDC was launched.
 		staticLaunchTime time.Time
 
@@ -147,6 +158,10 @@ type (
 		// fail.
 		totalDuration time.Duration
 
+		// bandwidth is the bandwidth, uploaded and downloaded, that was
+		// consumed by the job.
+		bandwidth uint64
+
 		// staticExpectedCompleteTime is an estimate of when we expect the
 		// worker to have completed the download.
 		staticExpectedCompleteTime time.Time
@@ -196,6 +211,10 @@ type (
 		// be used for debugging purposes should the download time out or error
 		// out.
 		launchedWorkers []*launchedWorkerInfo
+
+		// degraded indicates that the download proceeded with fewer usable
+		// workers than pieces needed to recover the chunk.
+		degraded bool
 	}
 )
 
@@ -293,6 +312,7 @@ func (pdc *projectDownloadChunk) handleJobReadResponse(jrr *jobReadResponse) {
 	launchedWorker.jobDuration = jrr.staticJobTime
 	launchedWorker.jobErr = jrr.staticErr
 	launchedWorker.totalDuration = time.Since(launchedWorker.staticLaunchTime)
+	launchedWorker.bandwidth = jrr.staticBandwidth
 
 	// Update the piece information
 	pdc.workerProgress[workerKey].completedPieces[pieceIndex] = struct{}{}
@@ -403,6 +423,7 @@ func (pdc *projectDownloadChunk) finalize() {
 		err:                    err,
 
 		launchedWorkers: pdc.launchedWorkers,
+		degraded:        pdc.degraded,
 	}
 	pdc.downloadResponseChan <- dr
 }
@@ -81,9 +81,10 @@ func testProjectDownloadChunkHandleJobResponse(t *testing.T) {
 
 	// mock a successful read response for piece 1
 	success := &jobReadResponse{
-		staticData:    pieces[1],
-		staticErr:     nil,
-		staticJobTime: time.Duration(1),
+		staticData:      pieces[1],
+		staticErr:       nil,
+		staticJobTime:   time.Duration(1),
+		staticBandwidth: 1,
 		staticMetadata: jobReadMetadata{
 			staticLaunchedWorkerIndex: 0,
 			staticPieceRootIndex:      1,
@@ -113,6 +114,7 @@ func testProjectDownloadChunkHandleJobResponse(t *testing.T) {
 	if lwi.completeTime == (time.Time{}) ||
 		lwi.jobDuration == 0 ||
 		lwi.totalDuration == 0 ||
+		lwi.bandwidth != 1 ||
 		lwi.jobErr != nil {
 		t.Fatal("unexpected")
 	}
@@ -824,11 +824,20 @@ func (pdc *projectDownloadChunk) threadedLaunchProjectDownload() {
 	// workers to avoid needless performing gouging checks on every iteration
 	workers := pdc.workers()
 
-	// verify we have enough workers to complete the download
-	if len(workers) < ec.MinPieces() {
-		pdc.fail(errors.Compose(ErrRootNotFound, errors.AddContext(errNotEnoughWorkers, fmt.Sprintf("%v < %v", len(workers), ec.MinPieces()))))
+	// verify we have enough workers to complete the download, in degraded
+	// mode fewer workers are fine since they might have multiple pieces each,
+	// whether they have enough pieces is checked when the download finishes
+	if len(workers) == 0 {
+		pdc.fail(errors.Compose(ErrRootNotFound, errNoUsableWorkers, errors.AddContext(errNotEnoughWorkers, fmt.Sprintf("0 < %v", ec.MinPieces()))))
 		return
 	}
+	if len(workers) < ec.MinPieces() {
+		if !pdc.staticAllowDegraded {
+			pdc.fail(errors.Compose(ErrRootNotFound, errors.AddContext(errNotEnoughWorkers, fmt.Sprintf("%v < %v", len(workers), ec.MinPieces()))))
+			return
+		}
+		pdc.degraded = true
+	}
 
 	// register for a worker update chan
 	workerUpdateChan := ws.managedRegisterForWorkerUpdate()
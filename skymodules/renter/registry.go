@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -242,6 +243,23 @@ func (r *Renter) UpdateRegistryMulti(ctx context.Context, srvs map[string]skymod
 	return r.managedUpdateRegistryMulti(ctx, workers, srvs, MinUpdateRegistrySuccesses)
 }
 
+// UpdateRegistryBatch updates the given registry entries concurrently. All
+// updates share the same context. The returned slice contains the result of
+// each update in the same order as the entries.
+func (r *Renter) UpdateRegistryBatch(ctx context.Context, entries []skymodules.RegistryEntry) []error {
+	errs := make([]error, len(entries))
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = r.UpdateRegistry(ctx, entries[i].PubKey, entries[i].SignedRegistryValue)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
 // managedRegistryEntryHealth reads an entry from all hosts on the network until
 // ctx is closed. It will then find out the best entry and count how many times
 // that entry was found on the network.
@@ -356,10 +374,11 @@ func (r *Renter) managedRegistryEntryHealth(ctx context.Context, rid modules.Reg
 // jobs have 'timeout' amount of time to finish their jobs and return a
 // response. Otherwise the response with the highest revision number will be
 // used.
-func (r *Renter) managedReadRegistry(ctx context.Context, rid modules.RegistryEntryID, spk *types.SiaPublicKey, tweak *crypto.Hash) (skymodules.RegistryEntry, error) {
+func (r *Renter) managedReadRegistry(ctx context.Context, rid modules.RegistryEntryID, spk *types.SiaPublicKey, tweak *crypto.Hash) (_ skymodules.RegistryEntry, err error) {
 	// Start tracing.
 	tracer := opentracing.GlobalTracer()
 	span := tracer.StartSpan("managedReadRegistry")
+	span.SetTag("traceid", skymodules.TraceIDFromContext(ctx))
 	defer span.Finish()
 
 	// Check if we are subscribed to the entry first.
@@ -367,8 +386,17 @@ func (r *Renter) managedReadRegistry(ctx context.Context, rid modules.RegistryEn
 	span.SetTag("cached", ok)
 	if ok && subscribedRV != nil {
 		// We are, no need to look it up.
+		r.staticTracef(ctx, "registry entry %v served from subscription", crypto.Hash(rid))
 		return *subscribedRV, nil
 	}
+	r.staticTracef(ctx, "registry lookup of entry %v started", crypto.Hash(rid))
+	defer func() {
+		if err != nil {
+			r.staticTracef(ctx, "registry lookup of entry %v failed: %v", crypto.Hash(rid), err)
+		} else {
+			r.staticTracef(ctx, "registry lookup of entry %v finished", crypto.Hash(rid))
+		}
+	}()
 
 	// Measure the time it takes to fetch the entry.
 	startTime := time.Now()
@@ -556,6 +584,11 @@ func (r *Renter) managedUpdateRegistryMulti(ctx context.Context, workers []*work
 	span := tracer.StartSpan("managedUpdateRegistryMulti")
 	defer span.Finish()
 
+	// Keep track of failing updates.
+	defer func() {
+		r.callTrackRegistryWrite(err)
+	}()
+
 	// Check how many updates we expect at the very least.
 	if minUpdates > len(srvs) {
 		minUpdates = len(srvs)
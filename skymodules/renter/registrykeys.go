@@ -0,0 +1,433 @@
+package renter
+
+// registrykeys.go contains the registryKeyManager which stores named ed25519
+// keypairs that the renter uses to sign registry updates on behalf of clients
+// that trust it. Like the skykey manager, the keys are persisted in an
+// append-only file with a header and deleted keys are overwritten in place.
+// The secret keys are encrypted with a key derived from the wallet seed and
+// never leave the node.
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// registryKeysPersistFile is the name of the file which contains the
+	// registry keys.
+	registryKeysPersistFile = "registrykeys.dat"
+
+	// registryKeysHeaderLen is the length of the registry keys file header.
+	// It consists of the magic, the version and the length of the file.
+	registryKeysHeaderLen = types.SpecifierLen + types.SpecifierLen + 8
+
+	// registryKeyEntryDeleted and registryKeyEntryActive are the types of
+	// the entries within the registry keys file.
+	registryKeyEntryDeleted byte = 0
+	registryKeyEntryActive  byte = 1
+)
+
+var (
+	// registryKeysMagic is the first piece of data found in the registry keys
+	// file.
+	registryKeysMagic = types.NewSpecifier("RegistryKeys")
+
+	// registryKeysVersion is the current version of the registry keys file.
+	registryKeysVersion = types.NewSpecifier("1.0")
+
+	// registryKeySpecifier is the specifier used for deriving the secret used
+	// to encrypt the registry keys from the RenterSeed.
+	registryKeySpecifier = types.NewSpecifier("registrykey")
+)
+
+var (
+	// ErrRegistryKeyExists is returned when trying to create a registry key
+	// with a name that is already in use.
+	ErrRegistryKeyExists = errors.New("registry key with that name already exists")
+
+	// ErrRegistryKeyNotFound is returned when a registry key with the given
+	// name doesn't exist.
+	ErrRegistryKeyNotFound = errors.New("no registry key with that name")
+
+	// errRegistryKeyInvalidName is returned when trying to create a registry
+	// key with an empty name or a name that is too long.
+	errRegistryKeyInvalidName = errors.New("registry key name must not be empty or exceed the max length")
+)
+
+type (
+	// registryKeyManager manages the registry keys of the renter.
+	registryKeyManager struct {
+		keys    map[string]registryKey
+		fileLen uint64 // Invariant: fileLen is at least registryKeysHeaderLen
+
+		staticPersistFile string
+		mu                sync.Mutex
+	}
+
+	// registryKey is a registry key together with its location in the
+	// persist file.
+	registryKey struct {
+		persistedRegistryKey
+		offset uint64
+		size   uint64
+	}
+
+	// persistedRegistryKey is the on-disk representation of a registry key.
+	persistedRegistryKey struct {
+		Name               string
+		PublicKey          crypto.PublicKey
+		EncryptedSecretKey []byte
+	}
+)
+
+// newRegistryKeyManager creates a new registryKeyManager which persists its
+// keys in the given dir.
+func newRegistryKeyManager(persistDir string) (*registryKeyManager, error) {
+	km := &registryKeyManager{
+		keys:              make(map[string]registryKey),
+		staticPersistFile: filepath.Join(persistDir, registryKeysPersistFile),
+	}
+	err := os.MkdirAll(persistDir, skymodules.DefaultDirPerm)
+	if err != nil {
+		return nil, err
+	}
+	err = km.load()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to load registry keys")
+	}
+	return km, nil
+}
+
+// CreateKey generates a new keypair with the given name. The secret key is
+// encrypted with the provided cipher key before it is persisted.
+func (km *registryKeyManager) CreateKey(name string, ck crypto.CipherKey) (skymodules.RegistryKey, error) {
+	if name == "" || len(name) > skykey.MaxKeyNameLen {
+		return skymodules.RegistryKey{}, errRegistryKeyInvalidName
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if _, exists := km.keys[name]; exists {
+		return skymodules.RegistryKey{}, ErrRegistryKeyExists
+	}
+
+	// Generate the keypair and wipe the secret key once it is encrypted.
+	sk, pk := crypto.GenerateKeyPair()
+	defer fastrand.Read(sk[:])
+	key := persistedRegistryKey{
+		Name:               name,
+		PublicKey:          pk,
+		EncryptedSecretKey: ck.EncryptBytes(sk[:]),
+	}
+	err := km.saveKey(key)
+	if err != nil {
+		return skymodules.RegistryKey{}, err
+	}
+	return key.registryKey(), nil
+}
+
+// DeleteKey deletes the key with the given name.
+func (km *registryKeyManager) DeleteKey(name string) (err error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	key, exists := km.keys[name]
+	if !exists {
+		return ErrRegistryKeyNotFound
+	}
+
+	file, err := os.OpenFile(km.staticPersistFile, os.O_RDWR, skymodules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "unable to open registry keys file")
+	}
+	defer func() {
+		err = errors.Compose(err, file.Close())
+	}()
+
+	// Overwrite the entry with a deleted entry of the same size.
+	var buf bytes.Buffer
+	e := encoding.NewEncoder(&buf)
+	e.WriteByte(registryKeyEntryDeleted)
+	e.WritePrefixedBytes(make([]byte, key.size))
+	if e.Err() != nil {
+		return e.Err()
+	}
+	_, err = file.WriteAt(buf.Bytes(), int64(key.offset))
+	if err != nil {
+		return errors.AddContext(err, "unable to overwrite registry key")
+	}
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+	delete(km.keys, name)
+	return nil
+}
+
+// Keys returns the public information of all keys sorted by name.
+func (km *registryKeyManager) Keys() []skymodules.RegistryKey {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	keys := make([]skymodules.RegistryKey, 0, len(km.keys))
+	for _, key := range km.keys {
+		keys = append(keys, key.registryKey())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// PublicKey returns the public key of the key with the given name.
+func (km *registryKeyManager) PublicKey(name string) (types.SiaPublicKey, error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	key, exists := km.keys[name]
+	if !exists {
+		return types.SiaPublicKey{}, ErrRegistryKeyNotFound
+	}
+	return types.Ed25519PublicKey(key.PublicKey), nil
+}
+
+// Sign signs the registry value with the key of the given name. The cipher key
+// needs to be the one that was used to create the key.
+func (km *registryKeyManager) Sign(name string, rv modules.RegistryValue, ck crypto.CipherKey) (types.SiaPublicKey, modules.SignedRegistryValue, error) {
+	km.mu.Lock()
+	key, exists := km.keys[name]
+	km.mu.Unlock()
+	if !exists {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, ErrRegistryKeyNotFound
+	}
+
+	// Decrypt the secret key and wipe it after signing.
+	plaintext, err := ck.DecryptBytes(key.EncryptedSecretKey)
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.AddContext(err, "failed to decrypt registry key")
+	}
+	defer fastrand.Read(plaintext)
+	var sk crypto.SecretKey
+	if len(plaintext) != len(sk) {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, errors.New("decrypted registry key has the wrong length")
+	}
+	copy(sk[:], plaintext)
+	defer fastrand.Read(sk[:])
+	return types.Ed25519PublicKey(key.PublicKey), rv.Sign(sk), nil
+}
+
+// load loads the keys from the persist file. If the file doesn't exist yet,
+// it is initialized.
+func (km *registryKeyManager) load() error {
+	b, err := ioutil.ReadFile(km.staticPersistFile)
+	if os.IsNotExist(err) || (err == nil && len(b) < registryKeysHeaderLen) {
+		return km.initPersist()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Load the header.
+	r := bytes.NewReader(b)
+	dec := encoding.NewDecoder(r, encoding.DefaultAllocLimit)
+	var magic, version types.Specifier
+	dec.Decode(&magic)
+	dec.Decode(&version)
+	km.fileLen = dec.NextUint64()
+	if err := dec.Err(); err != nil {
+		return errors.AddContext(err, "failed to decode header")
+	}
+	if magic != registryKeysMagic {
+		return errors.New("expected registry keys file magic")
+	}
+	if version != registryKeysVersion {
+		return errors.New("unknown registry keys file version")
+	}
+	if km.fileLen > uint64(len(b)) {
+		return errors.New("registry keys file is shorter than expected")
+	}
+
+	// Load the keys up to the length specified in the header.
+	r = bytes.NewReader(b[:km.fileLen])
+	dec = encoding.NewDecoder(r, encoding.DefaultAllocLimit)
+	_, err = r.Seek(registryKeysHeaderLen, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	for r.Len() > 0 {
+		offset := km.fileLen - uint64(r.Len())
+		entryType, err := dec.ReadByte()
+		if err != nil {
+			return errors.AddContext(err, "failed to read entry type")
+		}
+		data := dec.ReadPrefixedBytes()
+		if err := dec.Err(); err != nil {
+			return errors.AddContext(err, "failed to read entry")
+		}
+		if entryType == registryKeyEntryDeleted {
+			continue
+		}
+		var key persistedRegistryKey
+		err = encoding.Unmarshal(data, &key)
+		if err != nil {
+			return errors.AddContext(err, "failed to unmarshal registry key")
+		}
+		km.keys[key.Name] = registryKey{
+			persistedRegistryKey: key,
+			offset:               offset,
+			size:                 uint64(len(data)),
+		}
+	}
+	return nil
+}
+
+// initPersist creates the persist file and writes the header to it.
+func (km *registryKeyManager) initPersist() (err error) {
+	file, err := os.OpenFile(km.staticPersistFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, skymodules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "unable to create registry keys file")
+	}
+	defer func() {
+		err = errors.Compose(err, file.Close())
+	}()
+	km.fileLen = registryKeysHeaderLen
+	return km.saveHeader(file)
+}
+
+// saveHeader writes the header of the registry keys file and syncs the file.
+func (km *registryKeyManager) saveHeader(file *os.File) error {
+	var buf bytes.Buffer
+	e := encoding.NewEncoder(&buf)
+	e.Encode(registryKeysMagic)
+	e.Encode(registryKeysVersion)
+	e.WriteUint64(km.fileLen)
+	if e.Err() != nil {
+		return errors.AddContext(e.Err(), "failed to encode registry keys header")
+	}
+	_, err := file.WriteAt(buf.Bytes(), 0)
+	if err != nil {
+		return errors.AddContext(err, "failed to write registry keys header")
+	}
+	return file.Sync()
+}
+
+// saveKey appends the key to the persist file and updates the header. It
+// needs to be called while holding the lock.
+func (km *registryKeyManager) saveKey(key persistedRegistryKey) (err error) {
+	file, err := os.OpenFile(km.staticPersistFile, os.O_RDWR, skymodules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "unable to open registry keys file")
+	}
+	defer func() {
+		err = errors.Compose(err, file.Close())
+	}()
+
+	// Append the key to the end of the known-to-be-valid part of the file.
+	data := encoding.Marshal(key)
+	var buf bytes.Buffer
+	e := encoding.NewEncoder(&buf)
+	e.WriteByte(registryKeyEntryActive)
+	e.WritePrefixedBytes(data)
+	if e.Err() != nil {
+		return e.Err()
+	}
+	_, err = file.WriteAt(buf.Bytes(), int64(km.fileLen))
+	if err != nil {
+		return errors.AddContext(err, "unable to write registry key")
+	}
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+
+	// Update the header.
+	km.keys[key.Name] = registryKey{
+		persistedRegistryKey: key,
+		offset:               km.fileLen,
+		size:                 uint64(len(data)),
+	}
+	km.fileLen += uint64(buf.Len())
+	return km.saveHeader(file)
+}
+
+// registryKey returns the public information of the key.
+func (key persistedRegistryKey) registryKey() skymodules.RegistryKey {
+	return skymodules.RegistryKey{
+		Name:      key.Name,
+		PublicKey: types.Ed25519PublicKey(key.PublicKey),
+	}
+}
+
+// managedRegistryKeyCipher derives the key which is used to encrypt the
+// secret keys of the registry keys from the wallet seed.
+func (r *Renter) managedRegistryKeyCipher() (crypto.CipherKey, error) {
+	// Get the wallet seed.
+	ws, _, err := r.staticWallet.PrimarySeed()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to get wallet's primary seed")
+	}
+	// Derive the renter seed and wipe the memory once we are done using it.
+	rs := skymodules.DeriveRenterSeed(ws)
+	defer fastrand.Read(rs[:])
+	// Derive the secret and wipe it afterwards.
+	secret := crypto.HashAll(rs, registryKeySpecifier)
+	defer fastrand.Read(secret[:])
+	return crypto.NewSiaKey(crypto.TypeTwofish, secret[:])
+}
+
+// CreateRegistryKey creates a new registry keypair with the given name.
+func (r *Renter) CreateRegistryKey(name string) (skymodules.RegistryKey, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.RegistryKey{}, err
+	}
+	defer r.tg.Done()
+	ck, err := r.managedRegistryKeyCipher()
+	if err != nil {
+		return skymodules.RegistryKey{}, err
+	}
+	return r.staticRegistryKeyManager.CreateKey(name, ck)
+}
+
+// DeleteRegistryKey deletes the registry keypair with the given name.
+func (r *Renter) DeleteRegistryKey(name string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticRegistryKeyManager.DeleteKey(name)
+}
+
+// RegistryKeys returns the public information of all registry keypairs.
+func (r *Renter) RegistryKeys() ([]skymodules.RegistryKey, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticRegistryKeyManager.Keys(), nil
+}
+
+// SignRegistryValue signs the registry value with the registry keypair of
+// the given name and returns the public key of the keypair together with the
+// signed value.
+func (r *Renter) SignRegistryValue(name string, rv modules.RegistryValue) (types.SiaPublicKey, modules.SignedRegistryValue, error) {
+	if err := r.tg.Add(); err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	defer r.tg.Done()
+	ck, err := r.managedRegistryKeyCipher()
+	if err != nil {
+		return types.SiaPublicKey{}, modules.SignedRegistryValue{}, err
+	}
+	return r.staticRegistryKeyManager.Sign(name, rv, ck)
+}
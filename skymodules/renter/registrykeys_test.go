@@ -0,0 +1,135 @@
+package renter
+
+import (
+	"os"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestRegistryKeyManager is a unit test for the registryKeyManager.
+func TestRegistryKeyManager(t *testing.T) {
+	t.Parallel()
+
+	testDir := build.TempDir("renter", t.Name())
+	err := os.RemoveAll(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km, err := newRegistryKeyManager(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck := crypto.GenerateSiaKey(crypto.TypeTwofish)
+
+	// Create some keys.
+	key1, err := km.CreateKey("key1", ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := km.CreateKey("key2", ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key1.PublicKey.Equals(key2.PublicKey) {
+		t.Fatal("keys should be different")
+	}
+
+	// Names need to be unique and valid.
+	_, err = km.CreateKey("key1", ck)
+	if !errors.Contains(err, ErrRegistryKeyExists) {
+		t.Fatal("unexpected error", err)
+	}
+	_, err = km.CreateKey("", ck)
+	if !errors.Contains(err, errRegistryKeyInvalidName) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Sign a value with the first key.
+	rv := modules.NewRegistryValue(crypto.Hash{1}, fastrand.Bytes(10), 1, modules.RegistryTypeWithoutPubkey)
+	spk, srv, err := km.Sign("key1", rv, ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spk.Equals(key1.PublicKey) {
+		t.Fatal("wrong public key")
+	}
+	var pk crypto.PublicKey
+	copy(pk[:], spk.Key)
+	if err := srv.Verify(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// The public key can be looked up by name.
+	spk, err = km.PublicKey("key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spk.Equals(key1.PublicKey) {
+		t.Fatal("wrong public key")
+	}
+	_, err = km.PublicKey("key3")
+	if !errors.Contains(err, ErrRegistryKeyNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Signing with an unknown key or the wrong cipher key fails.
+	_, _, err = km.Sign("key3", rv, ck)
+	if !errors.Contains(err, ErrRegistryKeyNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+	_, _, err = km.Sign("key1", rv, crypto.GenerateSiaKey(crypto.TypeTwofish))
+	if err == nil {
+		t.Fatal("signing with the wrong cipher key should fail")
+	}
+
+	// Delete the first key.
+	err = km.DeleteKey("key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = km.DeleteKey("key1")
+	if !errors.Contains(err, ErrRegistryKeyNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Create another key and reload the manager.
+	key3, err := km.CreateKey("key3", ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km, err = newRegistryKeyManager(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := km.Keys()
+	if len(keys) != 2 {
+		t.Fatal("wrong number of keys", len(keys))
+	}
+	if keys[0].Name != "key2" || !keys[0].PublicKey.Equals(key2.PublicKey) {
+		t.Fatal("unexpected key", keys[0])
+	}
+	if keys[1].Name != "key3" || !keys[1].PublicKey.Equals(key3.PublicKey) {
+		t.Fatal("unexpected key", keys[1])
+	}
+
+	// The reloaded keys can still sign.
+	spk, srv, err = km.Sign("key3", rv, ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(pk[:], spk.Key)
+	if err := srv.Verify(pk); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name of the deleted key can be reused.
+	_, err = km.CreateKey("key1", ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,145 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// UpdateRegistryLarge stores data which might exceed the size of a single
+// registry entry under the registry key with the given name and the data key.
+// The data is split into chunks which are written first, the manifest entry
+// at the data key is only updated once all chunks were stored. The revision of
+// the manifest is bumped automatically and returned.
+func (r *Renter) UpdateRegistryLarge(ctx context.Context, keyName string, dataKey crypto.Hash, data []byte) (uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer r.tg.Done()
+	chunks, err := skymodules.SplitLargeRegistryData(data)
+	if err != nil {
+		return 0, err
+	}
+	spk, err := r.staticRegistryKeyManager.PublicKey(keyName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only allow for one update of the manifest at a time.
+	done, err := r.managedStartPublish(modules.DeriveRegistryEntryID(spk, dataKey))
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	// Look up the current revision of the manifest. If it already describes
+	// the data, all of the chunks were stored before.
+	manifest := skymodules.NewLargeRegistryManifest(data)
+	readCtx, readCancel := context.WithTimeout(ctx, MaxRegistryReadTimeout)
+	defer readCancel()
+	var revision uint64
+	entry, err := r.ReadRegistry(readCtx, spk, dataKey)
+	if err == nil {
+		existing, decodeErr := skymodules.DecodeLargeRegistryManifest(entry.Data)
+		if decodeErr == nil && existing == manifest {
+			return entry.Revision, nil
+		}
+		revision = entry.Revision + 1
+	} else if !errors.Contains(err, ErrRegistryEntryNotFound) && !errors.Contains(err, ErrRegistryLookupTimeout) {
+		return 0, errors.AddContext(err, "failed to read current revision")
+	}
+
+	// Sign the chunks. They are content addressed so they are always stored
+	// at revision 0.
+	ck, err := r.managedRegistryKeyCipher()
+	if err != nil {
+		return 0, err
+	}
+	entries := make([]skymodules.RegistryEntry, 0, len(chunks))
+	for i, chunk := range chunks {
+		rv := modules.NewRegistryValue(manifest.ChunkDataKey(dataKey, uint64(i)), chunk, 0, modules.RegistryTypeWithoutPubkey)
+		_, srv, err := r.staticRegistryKeyManager.Sign(keyName, rv, ck)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, skymodules.NewRegistryEntry(spk, srv))
+	}
+
+	// Store the chunks.
+	updateCtx, updateCancel := context.WithTimeout(ctx, DefaultRegistryUpdateTimeout)
+	defer updateCancel()
+	for i, err := range r.UpdateRegistryBatch(updateCtx, entries) {
+		if err != nil {
+			return 0, errors.AddContext(err, fmt.Sprintf("failed to store chunk %v", i))
+		}
+	}
+
+	// Point the manifest at the chunks.
+	rv := modules.NewRegistryValue(dataKey, manifest.Encode(), revision, modules.RegistryTypeWithoutPubkey)
+	_, srv, err := r.staticRegistryKeyManager.Sign(keyName, rv, ck)
+	if err != nil {
+		return 0, err
+	}
+	err = r.UpdateRegistry(updateCtx, spk, srv)
+	if err != nil {
+		return 0, errors.AddContext(err, "failed to update manifest")
+	}
+	return revision, nil
+}
+
+// ReadRegistryLarge reads data which was stored using UpdateRegistryLarge. It
+// returns the reassembled data together with the revision of the manifest.
+func (r *Renter) ReadRegistryLarge(ctx context.Context, spk types.SiaPublicKey, dataKey crypto.Hash) ([]byte, uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, 0, err
+	}
+	defer r.tg.Done()
+
+	// Read the manifest.
+	entry, err := r.ReadRegistry(ctx, spk, dataKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	manifest, err := skymodules.DecodeLargeRegistryManifest(entry.Data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Read the chunks in parallel.
+	numChunks := manifest.NumChunks()
+	chunks := make([][]byte, numChunks)
+	errs := make([]error, numChunks)
+	var wg sync.WaitGroup
+	for i := uint64(0); i < numChunks; i++ {
+		wg.Add(1)
+		go func(i uint64) {
+			defer wg.Done()
+			chunk, err := r.ReadRegistry(ctx, spk, manifest.ChunkDataKey(dataKey, i))
+			if err != nil {
+				errs[i] = errors.AddContext(err, fmt.Sprintf("failed to read chunk %v", i))
+				return
+			}
+			chunks[i] = chunk.Data
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Compose(errs...); err != nil {
+		return nil, 0, err
+	}
+
+	// Reassemble the data.
+	data := make([]byte, 0, manifest.Length)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	if err := manifest.VerifyData(data); err != nil {
+		return nil, 0, errors.AddContext(err, "failed to verify large registry data")
+	}
+	return data, entry.Revision, nil
+}
@@ -47,6 +47,7 @@ import (
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/contractor"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/hostdb"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/skynetallowlist"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/skynetblocklist"
 	"gitlab.com/SkynetLabs/skyd/skymodules/renter/skynetportals"
 	"go.sia.tech/siad/crypto"
@@ -210,12 +211,24 @@ type Renter struct {
 	// friendly to the atomic package, but actually it's a time.Duration.
 	atomicSystemHealthScanDuration uint64
 
+	// atomicRegistryWriteFailures is the number of consecutive registry
+	// updates that failed.
+	atomicRegistryWriteFailures uint64
+
 	// Skynet Management
-	staticSkylinkManager    *skylinkManager
-	staticSkynetBlocklist   *skynetblocklist.SkynetBlocklist
-	staticSkynetPortals     *skynetportals.SkynetPortals
-	staticSpendingHistory   *spendingHistory
-	staticSkynetTUSUploader *skynetTUSUploader
+	staticSkyfileConversionManager    *skyfileConversionManager
+	staticSkyfilePendingUploadManager *skyfilePendingUploadManager
+	staticSkylinkManager              *skylinkManager
+	staticSkylinkPrefetchManager      *skylinkPrefetchManager
+	staticSkynetAllowlist             *skynetallowlist.SkynetAllowlist
+	staticSkynetBlocklist             *skynetblocklist.SkynetBlocklist
+	staticSkynetBlocklistHits         *skynetBlocklistHits
+	staticSkynetInFlightTracker       *skynetInFlightTracker
+	staticSkynetStorageTracker        *skynetStorageTracker
+	staticSkynetPortals               *skynetportals.SkynetPortals
+	staticSkynetTraceBuffer           *skynetTraceBuffer
+	staticSpendingHistory             *spendingHistory
+	staticSkynetTUSUploader           *skynetTUSUploader
 
 	// Download management.
 	staticDownloadHeap *downloadHeap
@@ -236,6 +249,10 @@ type Renter struct {
 	ongoingRegistryRepairs   map[modules.RegistryEntryID]struct{}
 	ongoingRegistryRepairsMu sync.Mutex
 
+	// Registry entries which are currently being updated by PublishSkylink.
+	ongoingPublishes   map[modules.RegistryEntryID]struct{}
+	ongoingPublishesMu sync.Mutex
+
 	// Cache the hosts from the last price estimation result.
 	lastEstimationHosts []skymodules.HostDBEntry
 
@@ -297,6 +314,9 @@ type Renter struct {
 	staticHostContractor               hostContractor
 	staticHostDB                       skymodules.HostDB
 	staticSkykeyManager                *skykey.SkykeyManager
+	staticRegistryKeyManager           *registryKeyManager
+	staticSkynetSearchIndex            *skynetSearchIndex
+	staticSkynetSnapshotManager        *skynetSnapshotManager
 	staticStreamBufferSet              *streamBufferSet
 	staticTPool                        modules.TransactionPool
 	staticUploadChunkDistributionQueue *uploadChunkDistributionQueue
@@ -322,7 +342,7 @@ func (r *Renter) Close() error {
 		return nil
 	}
 
-	return errors.Compose(r.tg.Stop(), r.staticHostDB.Close(), r.staticHostContractor.Close(), r.staticSkynetBlocklist.Close(), r.staticSkynetPortals.Close())
+	return errors.Compose(r.tg.Stop(), r.staticHostDB.Close(), r.staticHostContractor.Close(), r.staticSkynetAllowlist.Close(), r.staticSkynetBlocklist.Close(), r.staticSkynetPortals.Close())
 }
 
 // MemoryStatus returns the current status of the memory manager
@@ -615,6 +635,18 @@ func (r *Renter) SetSettings(s skymodules.RenterSettings) error {
 	if s.MaxDownloadSpeed < 0 || s.MaxUploadSpeed < 0 {
 		return errors.New("bandwidth limits cannot be negative")
 	}
+	if s.SkynetDefaultRequestTimeout > 0 && s.SkynetMaxRequestTimeout > 0 && s.SkynetDefaultRequestTimeout > s.SkynetMaxRequestTimeout {
+		return errors.New("default skynet request timeout cannot exceed the max skynet request timeout")
+	}
+	if err := s.SkynetUploadPolicy.Validate(); err != nil {
+		return errors.AddContext(err, "invalid skynet upload policy")
+	}
+	if err := s.SkynetUploadRateLimit.Validate(); err != nil {
+		return errors.AddContext(err, "invalid skynet upload rate limit")
+	}
+	if err := skymodules.ValidateCORSOrigins(s.SkynetCORSOrigins); err != nil {
+		return errors.AddContext(err, "invalid skynet cors origins")
+	}
 
 	// Set allowance.
 	err := r.staticHostContractor.SetAllowance(s.Allowance)
@@ -633,14 +665,33 @@ func (r *Renter) SetSettings(s skymodules.RenterSettings) error {
 
 	// Save the changes.
 	id := r.mu.Lock()
+	r.persist.DefaultBaseChunkRedundancy = s.DefaultBaseChunkRedundancy
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.SkynetAllowlistEnforced = s.SkynetAllowlistEnforced
+	r.persist.SkynetCORSOrigins = s.SkynetCORSOrigins
+	r.persist.SkynetDefaultRequestTimeout = s.SkynetDefaultRequestTimeout
+	r.persist.SkynetDegradedDownloads = s.SkynetDegradedDownloads
+	r.persist.SkynetForceTokenKey = s.SkynetForceTokenKey
+	r.persist.SkynetMaintenance = s.SkynetMaintenance
+	r.persist.SkynetMaxDataURISize = s.SkynetMaxDataURISize
+	r.persist.SkynetMaxRequestTimeout = s.SkynetMaxRequestTimeout
+	r.persist.SkynetMaxUploadSize = s.SkynetMaxUploadSize
+	r.persist.SkynetMultipartLimits = s.SkynetMultipartLimits
+	r.persist.SkynetStorageCap = s.SkynetStorageCap
+	r.persist.SkynetUploadAlertThresholdMS = s.SkynetUploadAlertThresholdMS
+	r.persist.SkynetUploadPolicy = s.SkynetUploadPolicy
+	r.persist.SkynetUploadRateLimit = s.SkynetUploadRateLimit
+	r.persist.SkynetWeakETags = s.SkynetWeakETags
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
 		return err
 	}
 
+	// Check the upload performance against the new threshold.
+	r.managedCheckUploadPerformance()
+
 	// Update the worker pool so that the changes are immediately apparent to
 	// users.
 	r.staticWorkerPool.callUpdate()
@@ -845,11 +896,48 @@ func (r *Renter) Settings() (skymodules.RenterSettings, error) {
 		return skymodules.RenterSettings{}, errors.AddContext(err, "error getting IPViolationsCheck:")
 	}
 	paused, endTime := r.staticUploadHeap.managedPauseStatus()
+	id := r.mu.RLock()
+	baseChunkRedundancy := r.persist.DefaultBaseChunkRedundancy
+	allowlistEnforced := r.persist.SkynetAllowlistEnforced
+	corsOrigins := r.persist.SkynetCORSOrigins
+	defaultRequestTimeout := r.persist.SkynetDefaultRequestTimeout
+	degradedDownloads := r.persist.SkynetDegradedDownloads
+	forceTokenKey := r.persist.SkynetForceTokenKey
+	maintenance := r.persist.SkynetMaintenance
+	maxDataURISize := r.persist.SkynetMaxDataURISize
+	maxRequestTimeout := r.persist.SkynetMaxRequestTimeout
+	maxUploadSize := r.persist.SkynetMaxUploadSize
+	multipartLimits := r.persist.SkynetMultipartLimits
+	storageCap := r.persist.SkynetStorageCap
+	uploadAlertThreshold := r.persist.SkynetUploadAlertThresholdMS
+	uploadPolicy := r.persist.SkynetUploadPolicy
+	uploadRateLimit := r.persist.SkynetUploadRateLimit
+	weakETags := r.persist.SkynetWeakETags
+	r.mu.RUnlock(id)
+	if baseChunkRedundancy == 0 {
+		baseChunkRedundancy = SkyfileDefaultBaseChunkRedundancy
+	}
 	return skymodules.RenterSettings{
-		Allowance:        r.staticHostContractor.Allowance(),
-		IPViolationCheck: enabled,
-		MaxDownloadSpeed: download,
-		MaxUploadSpeed:   upload,
+		Allowance:                    r.staticHostContractor.Allowance(),
+		DefaultBaseChunkRedundancy:   baseChunkRedundancy,
+		IPViolationCheck:             enabled,
+		MaxDownloadSpeed:             download,
+		MaxUploadSpeed:               upload,
+		SkynetAllowlistEnforced:      allowlistEnforced,
+		SkynetCORSOrigins:            corsOrigins,
+		SkynetDefaultRequestTimeout:  defaultRequestTimeout,
+		SkynetDegradedDownloads:      degradedDownloads,
+		SkynetForceTokenKey:          forceTokenKey,
+		SkynetMaintenance:            maintenance,
+		SkynetMaxDataURISize:         maxDataURISize,
+		SkynetMaxRequestTimeout:      maxRequestTimeout,
+		SkynetMaxUploadSize:          maxUploadSize,
+		SkynetMultipartLimits:        multipartLimits,
+		SkynetStorageCap:             storageCap,
+		SkynetUploadAlertThresholdMS: uploadAlertThreshold,
+		SkynetUploadPolicy:           uploadPolicy,
+		SkynetUploadRateLimit:        uploadRateLimit,
+		SkynetWeakETags:              weakETags,
 		UploadsStatus: skymodules.UploadsStatus{
 			Paused:       paused,
 			PauseEndTime: endTime,
@@ -993,6 +1081,16 @@ func (r *Renter) DeleteSkykeyByName(name string) error {
 	return r.staticSkykeyManager.DeleteKeyByName(name)
 }
 
+// RenameSkykey renames the Skykey with the given name in the renter's skykey
+// manager.
+func (r *Renter) RenameSkykey(oldName, newName string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.staticSkykeyManager.RenameKey(oldName, newName)
+}
+
 // SkykeyByName gets the Skykey with the given name from the renter's skykey
 // manager if it exists.
 func (r *Renter) SkykeyByName(name string) (skykey.Skykey, error) {
@@ -1077,7 +1175,12 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 
 	r := &Renter{
 		// Initiate skynet resources
-		staticSkylinkManager: newSkylinkManager(),
+		staticSkyfileConversionManager:    newSkyfileConversionManager(),
+		staticSkyfilePendingUploadManager: newSkyfilePendingUploadManager(),
+		staticSkynetInFlightTracker:       newSkynetInFlightTracker(),
+		staticSkynetStorageTracker:        newSkynetStorageTracker(),
+		staticSkylinkManager:              newSkylinkManager(),
+		staticSkylinkPrefetchManager:      newSkylinkPrefetchManager(),
 
 		repairingChunks: make(map[uploadChunkID]*unfinishedUploadChunk),
 
@@ -1109,7 +1212,11 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 
 		staticDownloadHistory: newDownloadHistory(),
 
+		staticSkynetBlocklistHits: newSkynetBlocklistHits(skynetBlocklistHitsSize),
+		staticSkynetTraceBuffer:   newSkynetTraceBuffer(skynetTraceBufferSize),
+
 		ongoingRegistryRepairs: make(map[modules.RegistryEntryID]struct{}),
+		ongoingPublishes:       make(map[modules.RegistryEntryID]struct{}),
 
 		staticConsensusSet:   cs,
 		staticDeps:           deps,
@@ -1129,6 +1236,9 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	if err := r.tg.AfterStop(r.staticSkynetTUSUploader.Close); err != nil {
 		return nil, err
 	}
+	if err := r.tg.OnStop(r.managedDeletePendingUploads); err != nil {
+		return nil, err
+	}
 	r.staticUploadChunkDistributionQueue = newUploadChunkDistributionQueue(r)
 	close(r.staticUploadHeap.pauseChan)
 
@@ -1188,6 +1298,13 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	}
 	r.staticSkynetBlocklist = sb
 
+	// Add SkynetAllowlist
+	sa, err := skynetallowlist.New(r.persistDir)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create new skynet allowlist")
+	}
+	r.staticSkynetAllowlist = sa
+
 	// Add SkynetPortals
 	sp, err := skynetportals.New(r.persistDir)
 	if err != nil {
@@ -1201,6 +1318,12 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		return nil, err
 	}
 
+	// Init the skynet storage tracker now that the filesystem is loaded.
+	err = r.managedInitSkynetStorageTracker()
+	if err != nil {
+		return nil, err
+	}
+
 	// Init stream buffer now that the stats are initialised.
 	r.staticStreamBufferSet = newStreamBufferSet(r.staticStreamBufferStats, &r.tg)
 
@@ -1233,6 +1356,27 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		return nil, err
 	}
 
+	// Create the registry key manager.
+	r.staticRegistryKeyManager, err = newRegistryKeyManager(persistDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the skynet snapshot manager.
+	r.staticSkynetSnapshotManager, err = newSkynetSnapshotManager(persistDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the skynet search index and persist it on shutdown.
+	r.staticSkynetSearchIndex = newSkynetSearchIndex(persistDir, r.staticLog)
+	err = r.tg.AfterStop(func() error {
+		return errors.AddContext(r.staticSkynetSearchIndex.callSave(), "failed to persist skynet search index")
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Calculate the initial cached utilities and kick off a thread that updates
 	// the utilities regularly.
 	r.managedUpdateRenterContractsAndUtilities()
@@ -1241,6 +1385,12 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	// Launch the stat persisting thread.
 	go r.threadedStatsPersister()
 
+	// Launch the thread that unpins skyfiles with an expired pin.
+	go r.threadedUnpinExpiredSkyfiles()
+
+	// Launch the thread that persists the skynet search index.
+	go r.threadedPersistSkynetSearchIndex()
+
 	// Spin up background threads which are not depending on the renter being
 	// up-to-date with consensus.
 	if !r.staticDeps.Disrupt("DisableRepairAndHealthLoops") {
@@ -30,11 +30,14 @@ package renter
 // the base level by Sia.
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 	"time"
 
@@ -94,6 +97,10 @@ var (
 	// ErrSkylinkBlocked is the error returned when a skylink is blocked
 	ErrSkylinkBlocked = errors.New("skylink is blocked")
 
+	// ErrSkylinkNotAllowed is the error returned when the allowlist is
+	// enforced and a skylink is not on it
+	ErrSkylinkNotAllowed = errors.New("skylink is not on the allowlist")
+
 	// ErrSkylinkNesting is the error returned when a skylink is nested more
 	// times than MaxSkylinkV2ResolvingDepth
 	ErrSkylinkNesting = errors.New("skylink is nested more times than is supported")
@@ -101,16 +108,69 @@ var (
 	// ErrInvalidSkylinkVersion is returned when an operation fails due to the
 	// skylink having the wrong version.
 	ErrInvalidSkylinkVersion = errors.New("skylink had unexpected version")
+
+	// ErrInvalidFanoutPieces is returned when the requested erasure coding
+	// settings for the fanout of a skyfile can't be used.
+	ErrInvalidFanoutPieces = errors.New("invalid fanout data and parity pieces")
+
+	// ErrSparseEncrypted is returned when trying to upload an encrypted
+	// skyfile as a sparse skyfile.
+	ErrSparseEncrypted = errors.New("sparse skyfiles can't be encrypted")
+)
+
+type (
+	// parseSkyfileMetadataFunc parses the metadata of a decrypted base sector.
+	// It matches the signature of the renter's ParseSkyfileMetadata.
+	parseSkyfileMetadataFunc func(baseSector []byte) (sl skymodules.SkyfileLayout, fanoutBytes []byte, sm skymodules.SkyfileMetadata, rawSM, baseSectorPayload, baseSectorExtension []byte, err error)
+
+	// fanoutReaderFunc returns a reader for the data of a skyfile's fanout
+	// given its layout, its fanout and the skykey of encrypted skyfiles.
+	fanoutReaderFunc func(layout skymodules.SkyfileLayout, fanoutBytes []byte, fileSkykey skykey.Skykey) (io.Reader, error)
 )
 
-// skyfileEstablishDefaults will set any zero values in the lup to be equal to
-// the desired defaults.
-func skyfileEstablishDefaults(lup *skymodules.SkyfileUploadParameters) {
+// managedSkyfileEstablishDefaults will set any zero values in the lup to be
+// equal to the desired defaults.
+func (r *Renter) managedSkyfileEstablishDefaults(lup *skymodules.SkyfileUploadParameters) {
 	if lup.BaseChunkRedundancy == 0 {
-		lup.BaseChunkRedundancy = SkyfileDefaultBaseChunkRedundancy
+		lup.BaseChunkRedundancy = r.managedDefaultBaseChunkRedundancy()
 	}
 }
 
+// managedDefaultBaseChunkRedundancy returns the base chunk redundancy for
+// skyfiles which don't specify one. It is the renter's
+// DefaultBaseChunkRedundancy setting or SkyfileDefaultBaseChunkRedundancy if
+// the setting is 0.
+func (r *Renter) managedDefaultBaseChunkRedundancy() uint8 {
+	id := r.mu.RLock()
+	redundancy := r.persist.DefaultBaseChunkRedundancy
+	r.mu.RUnlock(id)
+	if redundancy == 0 {
+		return SkyfileDefaultBaseChunkRedundancy
+	}
+	return redundancy
+}
+
+// managedFanoutPieces returns the number of data and parity pieces to use for
+// the fanout of a large skyfile. If the upload parameters don't specify any,
+// the defaults are returned. Otherwise the requested pieces are validated
+// against the number of available hosts.
+func (r *Renter) managedFanoutPieces(sup skymodules.SkyfileUploadParameters) (int, int, error) {
+	if sup.DataPieces == 0 && sup.ParityPieces == 0 {
+		return skymodules.RenterDefaultDataPieces, skymodules.RenterDefaultParityPieces, nil
+	}
+	if sup.DataPieces <= 0 || sup.ParityPieces <= 0 {
+		return 0, 0, errors.AddContext(ErrInvalidFanoutPieces, "both data and parity pieces need to be greater than zero")
+	}
+	if sup.DataPieces > math.MaxUint8 || sup.ParityPieces > math.MaxUint8 {
+		return 0, 0, errors.AddContext(ErrInvalidFanoutPieces, fmt.Sprintf("at most %v data and parity pieces are supported", math.MaxUint8))
+	}
+	numPieces := sup.DataPieces + sup.ParityPieces
+	if numHosts := r.staticWorkerPool.callNumWorkers(); numPieces > numHosts {
+		return 0, 0, errors.AddContext(ErrInvalidFanoutPieces, fmt.Sprintf("%v pieces requested but only %v hosts are available", numPieces, numHosts))
+	}
+	return sup.DataPieces, sup.ParityPieces, nil
+}
+
 // fileUploadParams will create an erasure coder and return the FileUploadParams
 // to use when uploading using the provided parameters.
 func fileUploadParams(siaPath skymodules.SiaPath, dataPieces, parityPieces int, force bool, ct crypto.CipherType) (skymodules.FileUploadParams, error) {
@@ -132,11 +192,9 @@ func fileUploadParams(siaPath skymodules.SiaPath, dataPieces, parityPieces int,
 
 // baseSectorUploadParamsFromSUP will derive the FileUploadParams to use when
 // uploading the base chunk siafile of a skyfile using the skyfile's upload
-// parameters.
+// parameters. The defaults of the upload parameters need to be established
+// already.
 func baseSectorUploadParamsFromSUP(sup skymodules.SkyfileUploadParameters) (skymodules.FileUploadParams, error) {
-	// Establish defaults
-	skyfileEstablishDefaults(&sup)
-
 	// Create parameters to upload the file with 1-of-N erasure coding and no
 	// encryption. This should cause all of the pieces to have the same Merkle
 	// root, which is critical to making the file discoverable to viewnodes and
@@ -212,39 +270,64 @@ func (sfr *skylinkStreamerFromReader) Skylink() skymodules.Skylink {
 // sector skyfile will be placed, and the siaPath provided as its own input is
 // the siaPath of the file that is being used to create the skyfile.
 func (r *Renter) CreateSkylinkFromSiafile(sup skymodules.SkyfileUploadParameters, siaPath skymodules.SiaPath) (_ skymodules.Skylink, err error) {
+	// Grab the filenode for the provided siapath.
+	fileNode, err := r.managedOpenSiafileForConversion(&sup, siaPath)
+	if err != nil {
+		return skymodules.Skylink{}, err
+	}
+	defer func() {
+		err = errors.Compose(err, fileNode.Close())
+	}()
+	return r.managedCreateSkylinkFromSiafile(r.tg.StopCtx(), sup, siaPath, fileNode, nil)
+}
+
+// managedOpenSiafileForConversion validates the upload parameters of a siafile
+// conversion, sets the defaults for any blank fields and opens the siafile at
+// the provided siaPath.
+func (r *Renter) managedOpenSiafileForConversion(sup *skymodules.SkyfileUploadParameters, siaPath skymodules.SiaPath) (*filesystem.FileNode, error) {
 	// Encryption is not supported for SiaFile conversion.
-	if encryptionEnabled(&sup) {
-		return skymodules.Skylink{}, errors.AddContext(ErrEncryptionNotSupported, "unable to convert siafile")
+	if encryptionEnabled(sup) {
+		return nil, errors.AddContext(ErrEncryptionNotSupported, "unable to convert siafile")
 	}
 	// Set reasonable default values for any sup fields that are blank.
-	skyfileEstablishDefaults(&sup)
+	r.managedSkyfileEstablishDefaults(sup)
 
 	// Grab the filenode for the provided siapath.
 	fileNode, err := r.staticFileSystem.OpenSiaFile(siaPath)
 	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "unable to open siafile")
+		return nil, errors.AddContext(err, "unable to open siafile")
 	}
-	defer func() {
-		err = errors.Compose(err, fileNode.Close())
-	}()
+	return fileNode, nil
+}
 
+// managedCreateSkylinkFromSiafile creates a skyfile from the provided fileNode
+// of a siafile. 'chunkDone' is called with the number of processed chunks after
+// every chunk of the siafile and may be nil.
+func (r *Renter) managedCreateSkylinkFromSiafile(ctx context.Context, sup skymodules.SkyfileUploadParameters, siaPath skymodules.SiaPath, fileNode *filesystem.FileNode, chunkDone func(uint64)) (skymodules.Skylink, error) {
 	// Override the metadata with the info from the fileNode.
 	metadata := skymodules.SkyfileMetadata{
 		Filename: siaPath.Name(),
 		Mode:     fileNode.Mode(),
 		Length:   fileNode.Size(),
+		ModTime:  sup.ModTime,
 	}
 
 	// Generate the fanoutBytes
 	dataPieces := fileNode.ErasureCode().MinPieces()
 	cipherType := fileNode.Metadata().StaticMasterKeyType
 	onlyOnePieceNeeded := dataPieces == 1 && cipherType == crypto.TypePlain
-	fanoutBytes, err := skyfileEncodeFanoutFromFileNode(fileNode, onlyOnePieceNeeded)
+	fanoutBytes, err := skyfileEncodeFanoutFromFileNode(ctx, fileNode, onlyOnePieceNeeded, chunkDone)
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to generate the fanout bytes")
 	}
 
-	return r.managedCreateSkylinkFromFileNode(r.tg.StopCtx(), sup, metadata, fileNode, fanoutBytes)
+	// Check for interruption before uploading the base sector.
+	select {
+	case <-ctx.Done():
+		return skymodules.Skylink{}, ctx.Err()
+	default:
+	}
+	return r.managedCreateSkylinkFromFileNode(ctx, sup, metadata, fileNode, fanoutBytes, fileNode.Size())
 }
 
 // managedCreateSkylink creates a skylink from the provided parameters.
@@ -283,6 +366,9 @@ func (r *Renter) managedCreateSkylinkRawMD(ctx context.Context, sup skymodules.S
 	if !encryptionEnabled(&sup) {
 		copy(sl.KeyData[:], masterKey.Key())
 	}
+	if sup.BaseSectorHint != nil {
+		sup.BaseSectorHint(sl, metadataBytes)
+	}
 	// Create the base sector.
 	baseSector, fetchSize, baseSectorExtension := skymodules.BuildBaseSector(sl.Encode(), fanoutBytes, metadataBytes, nil)
 
@@ -327,20 +413,22 @@ func (r *Renter) managedCreateSkylinkRawMD(ctx context.Context, sup skymodules.S
 	}
 
 	// Upload the base sector.
-	err = r.managedUploadBaseSector(ctx, sup, baseSector, skylink)
+	err = r.managedUploadBaseSector(ctx, sup, baseSector, sl, skylink)
 	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "Unable to upload base sector for file node. ")
+		return skymodules.Skylink{}, newSkyfileUploadError(errors.AddContext(err, "Unable to upload base sector for file node. "), skylink)
 	}
 
 	return skylink, errors.AddContext(err, "unable to add skylink to the sianodes")
 }
 
-// managedCreateSkylinkFromFileNode creates a skylink from a file node.
+// managedCreateSkylinkFromFileNode creates a skylink from a file node. The size
+// is the size of the skyfile's data which only differs from the size of the
+// file node for sparse skyfiles.
 //
 // The name needs to be passed in explicitly because a file node does not track
 // its own name, which allows the file to be renamed concurrently without
 // causing any race conditions.
-func (r *Renter) managedCreateSkylinkFromFileNode(ctx context.Context, sup skymodules.SkyfileUploadParameters, skyfileMetadata skymodules.SkyfileMetadata, fileNode *filesystem.FileNode, fanoutBytes []byte) (skymodules.Skylink, error) {
+func (r *Renter) managedCreateSkylinkFromFileNode(ctx context.Context, sup skymodules.SkyfileUploadParameters, skyfileMetadata skymodules.SkyfileMetadata, fileNode *filesystem.FileNode, fanoutBytes []byte, size uint64) (skymodules.Skylink, error) {
 	// Check if any of the skylinks associated with the siafile are blocked
 	if r.managedIsFileNodeBlocked(fileNode) {
 		err := ErrSkylinkBlocked
@@ -354,7 +442,7 @@ func (r *Renter) managedCreateSkylinkFromFileNode(ctx context.Context, sup skymo
 	}
 
 	// Create the skylink.
-	skylink, err := r.managedCreateSkylink(ctx, sup, skyfileMetadata, fanoutBytes, fileNode.Size(), fileNode.MasterKey(), fileNode.ErasureCode())
+	skylink, err := r.managedCreateSkylink(ctx, sup, skyfileMetadata, fanoutBytes, size, fileNode.MasterKey(), fileNode.ErasureCode())
 	if err != nil {
 		return skymodules.Skylink{}, err
 	}
@@ -408,6 +496,39 @@ func (r *Renter) managedPopulateFileNodeFromReader(fileNode *filesystem.FileNode
 	}
 }
 
+// Allowlist returns the hashes of the merkleroots that are on the allowlist
+func (r *Renter) Allowlist() ([]crypto.Hash, error) {
+	err := r.tg.Add()
+	if err != nil {
+		return []crypto.Hash{}, err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetAllowlist.Allowlist(), nil
+}
+
+// UpdateSkynetAllowlist updates the list of hashed merkleroots that are
+// approved
+func (r *Renter) UpdateSkynetAllowlist(ctx context.Context, additions, removals []string, isHash bool) error {
+	err := r.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// The allowlist uses the same hashes as the blocklist.
+	addHashes, err := r.managedParseBlocklistHashes(ctx, additions, isHash)
+	if err != nil {
+		return errors.AddContext(err, "unable to parse allowlist additions")
+	}
+	removeHashes, err := r.managedParseBlocklistHashes(ctx, removals, isHash)
+	if err != nil {
+		return errors.AddContext(err, "unable to parse allowlist removals")
+	}
+
+	// Update the allowlist
+	return r.staticSkynetAllowlist.UpdateAllowlist(addHashes, removeHashes)
+}
+
 // Blocklist returns the merkleroots that are on the blocklist
 func (r *Renter) Blocklist() ([]crypto.Hash, error) {
 	err := r.tg.Add()
@@ -437,7 +558,7 @@ func (r *Renter) UpdateSkynetBlocklist(ctx context.Context, additions, removals
 	}
 
 	// Update the blocklist
-	return r.staticSkynetBlocklist.UpdateBlocklist(addHashes, removeHashes)
+	return r.managedUpdateSkynetBlocklist(addHashes, removeHashes)
 }
 
 // Portals returns the list of known skynet portals.
@@ -457,13 +578,14 @@ func (r *Renter) UpdateSkynetPortals(additions []skymodules.SkynetPortal, remova
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticSkynetPortals.UpdatePortals(additions, removals)
+	return r.managedUpdateSkynetPortals(additions, removals)
 }
 
 // managedUploadBaseSector will take the raw baseSector bytes and upload them,
 // returning the resulting merkle root, and the fileNode of the siafile that is
-// tracking the base sector.
-func (r *Renter) managedUploadBaseSector(ctx context.Context, sup skymodules.SkyfileUploadParameters, baseSector []byte, skylink skymodules.Skylink) (err error) {
+// tracking the base sector. The layout is used to determine how much of the
+// base sector is padding rather than file data.
+func (r *Renter) managedUploadBaseSector(ctx context.Context, sup skymodules.SkyfileUploadParameters, baseSector []byte, sl skymodules.SkyfileLayout, skylink skymodules.Skylink) (err error) {
 	// Trace the base sector upload in its own span if the given ctx already has
 	// a span attached.
 	span, ctx := opentracing.StartSpanFromContext(ctx, "managedUploadBaseSector")
@@ -476,6 +598,11 @@ func (r *Renter) managedUploadBaseSector(ctx context.Context, sup skymodules.Sky
 		span.Finish()
 	}()
 
+	// Abort the upload if the skylink gets blocked while it is in flight.
+	ctx, done := r.managedTrackSkylink(ctx, skylink, sup.SiaPath)
+	defer done(&err)
+
+	r.managedSkyfileEstablishDefaults(&sup)
 	uploadParams, err := baseSectorUploadParamsFromSUP(sup)
 	if err != nil {
 		return errors.AddContext(err, "failed to create siafile upload parameters")
@@ -503,9 +630,34 @@ func (r *Renter) managedUploadBaseSector(ctx context.Context, sup skymodules.Sky
 		err = errors.Compose(err, fileNode.Close())
 	}()
 
+	// Only small files store their data in the base sector. Everything else
+	// is padding.
+	var rawSize uint64
+	if sl.FanoutSize == 0 {
+		rawSize = sl.Filesize
+	}
+	if rawSize > fileNode.Size() {
+		return fmt.Errorf("file size in layout %v exceeds the base sector size %v", rawSize, fileNode.Size())
+	}
+	err = fileNode.SetSkynetPaddingSize(fileNode.Size() - rawSize)
+	if err != nil {
+		return errors.AddContext(err, "unable to set padding size of siafile")
+	}
+
 	// Add the skylink to the Siafile.
 	err = fileNode.AddSkylink(skylink)
-	return errors.AddContext(err, "unable to add skylink to siafile")
+	if err != nil {
+		return errors.AddContext(err, "unable to add skylink to siafile")
+	}
+
+	// Skylinks uploaded by this node are approved while the allowlist is
+	// enforced. They are not recorded otherwise since the allowlist would
+	// grow with every upload.
+	if !r.managedSkynetAllowlistEnforced() {
+		return nil
+	}
+	err = r.staticSkynetAllowlist.UpdateAllowlist([]crypto.Hash{crypto.HashObject(skylink.MerkleRoot())}, nil)
+	return errors.AddContext(err, "unable to add skylink to allowlist")
 }
 
 // managedUploadSkyfile uploads a file and returns the skylink and whether or
@@ -516,6 +668,12 @@ func (r *Renter) managedUploadSkyfile(ctx context.Context, sup skymodules.Skyfil
 	numBytes, err := io.ReadFull(reader, buf)
 	buf = buf[:numBytes] // truncate the buffer
 
+	// any error other than reaching the end of the data means the upload
+	// failed, e.g. because it violates the upload policy
+	if err != nil && !errors.Contains(err, io.EOF) && !errors.Contains(err, io.ErrUnexpectedEOF) {
+		return skymodules.Skylink{}, errors.AddContext(err, "unable to read skyfile data")
+	}
+
 	// if we've reached EOF, we can safely fetch the metadata and calculate the
 	// actual header size, if that fits in a single sector we can upload the
 	// Skyfile as a small file
@@ -571,6 +729,9 @@ func (r *Renter) managedUploadSkyfileSmallFile(ctx context.Context, sup skymodul
 	// Create the layout. Since this is a small upload it doesn't have a
 	// fanout.
 	sl := skymodules.NewSkyfileLayoutNoFanout(uint64(len(fileBytes)), uint64(len(metadataBytes)), crypto.TypePlain)
+	if sup.BaseSectorHint != nil {
+		sup.BaseSectorHint(sl, metadataBytes)
+	}
 
 	// Create the base sector. This is done as late as possible so that any
 	// errors are caught before a large block of memory is allocated.
@@ -601,13 +762,19 @@ func (r *Renter) managedUploadSkyfileSmallFile(ctx context.Context, sup skymodul
 		return skylink, nil
 	}
 
+	// Hint the skylink before uploading the base sector.
+	if sup.SkylinkHint != nil {
+		sup.SkylinkHint(skylink)
+	}
+
 	// Upload the base sector.
 	start := time.Now()
-	err = r.managedUploadBaseSector(ctx, sup, baseSector, skylink)
+	err = r.managedUploadBaseSector(ctx, sup, baseSector, sl, skylink)
 	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "failed to upload base sector")
+		return skymodules.Skylink{}, newSkyfileUploadError(errors.AddContext(err, "failed to upload base sector"), skylink)
 	}
 	r.staticBaseSectorUploadStats.AddDataPoint(time.Since(start))
+	r.managedCheckUploadPerformance()
 	return skylink, nil
 }
 
@@ -626,36 +793,8 @@ func (r *Renter) managedUploadSkyfileLargeFile(ctx context.Context, sup skymodul
 		}()
 	}
 
-	// Create the siapath for the skyfile extra data. This is going to be the
-	// same as the skyfile upload siapath, except with a suffix.
-	siaPath, err := sup.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
-	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "unable to create SiaPath for large skyfile extended data")
-	}
-
-	// Disrupt and use custom redundancy if the StandardUploadRedundancy
-	// dependency is set.
-	dataPieces := skymodules.RenterDefaultDataPieces
-	parityPieces := skymodules.RenterDefaultParityPieces
-	if r.staticDeps.Disrupt("StandardUploadRedundancy") {
-		dataPieces = 10
-		parityPieces = 20
-	}
-
-	// Create the FileUploadParams
-	fup, err := fileUploadParams(siaPath, dataPieces, parityPieces, sup.Force, crypto.TypePlain)
-	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "unable to create FileUploadParams for large file")
-	}
-
-	// Generate a Cipher Key for the FileUploadParams.
-	err = generateCipherKey(&fup, sup)
-	if err != nil {
-		return skymodules.Skylink{}, errors.AddContext(err, "unable to create Cipher key for FileUploadParams")
-	}
-
-	// Check the upload params first and create a fileNode.
-	fileNode, err := r.managedInitUploadStream(fup)
+	// Create the fileNode for the skyfile extra data.
+	fileNode, err := r.managedInitFanoutFileNode(sup)
 	if err != nil {
 		return skymodules.Skylink{}, err
 	}
@@ -675,16 +814,46 @@ func (r *Renter) managedUploadSkyfileLargeFile(ctx context.Context, sup skymodul
 	// Figure out how to create the fanout. If only one piece is needed, we
 	// create it from the node directly after the upload.
 	cipherType := fileNode.MasterKey().Type()
-	dataPieces = fileNode.ErasureCode().MinPieces()
+	dataPieces := fileNode.ErasureCode().MinPieces()
 	onlyOnePieceNeeded := dataPieces == 1 && cipherType == crypto.TypePlain
 
-	// Wrap the reader in a FanoutChunkReader.
-	cr := NewFanoutChunkReader(fileReader, fileNode.ErasureCode(), onlyOnePieceNeeded, fileNode.MasterKey())
+	// Wrap the reader in a FanoutChunkReader. Sparse uploads skip the zero
+	// chunks which means that the siafile only contains the chunks with data.
+	// The size of the skyfile is therefore tracked by the reader.
+	var cr skymodules.FanoutChunkReader
+	var sparseReader *sparseFanoutChunkReader
+	if sup.Sparse {
+		if cipherType != crypto.TypePlain {
+			return skymodules.Skylink{}, ErrSparseEncrypted
+		}
+		sparseReader = newSparseFanoutChunkReader(fileReader, fileNode.ErasureCode(), onlyOnePieceNeeded, fileNode.MasterKey())
+		cr = sparseReader
+	} else {
+		cr = NewFanoutChunkReader(fileReader, fileNode.ErasureCode(), onlyOnePieceNeeded, fileNode.MasterKey())
+	}
 	if sup.DryRun || r.staticDeps.Disrupt("DoNotUploadFanout") {
 		// In case of a dry-run we don't want to perform the actual upload,
 		// instead we create a filenode that contains all of the data pieces and
 		// their merkle roots.
 		err = r.managedPopulateFileNodeFromReader(fileNode, cr)
+	} else if sup.SkylinkHint != nil {
+		// Upload the file using a streamer. The skylink is hinted as soon
+		// as all the data was read, before waiting for it to become
+		// available on the network.
+		var chunks []*unfinishedUploadChunk
+		var n int64
+		chunks, n, err = r.callUploadStreamFromReaderWithFileNodeNoBlock(ctx, fileNode, cr, 0)
+		if err == nil {
+			size := uint64(n)
+			if sparseReader != nil {
+				size = sparseReader.Size()
+			}
+			hinted, ok := r.managedHintSkylink(ctx, sup, fileReader, fileNode, cr.Fanout(), size)
+			err = r.managedWaitForUploadStream(ctx, chunks)
+			if ok {
+				err = newSkyfileUploadError(err, hinted)
+			}
+		}
 	} else {
 		// Upload the file using a streamer.
 		_, err = r.callUploadStreamFromReaderWithFileNode(ctx, fileNode, cr, 0)
@@ -699,7 +868,7 @@ func (r *Renter) managedUploadSkyfileLargeFile(ctx context.Context, sup skymodul
 	if fileReader != nil {
 		fanout = cr.Fanout()
 	} else {
-		fanout, err = skyfileEncodeFanoutFromFileNode(fileNode, onlyOnePieceNeeded)
+		fanout, err = skyfileEncodeFanoutFromFileNode(ctx, fileNode, onlyOnePieceNeeded, nil)
 	}
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "failed to compute fanout")
@@ -713,28 +882,122 @@ func (r *Renter) managedUploadSkyfileLargeFile(ctx context.Context, sup skymodul
 
 	// Convert the new siafile we just uploaded into a skyfile using the
 	// convert function.
-	skylink, err = r.managedCreateSkylinkFromFileNode(ctx, sup, metadata, fileNode, fanout)
+	size := fileNode.Size()
+	if sparseReader != nil {
+		size = sparseReader.Size()
+	}
+	skylink, err = r.managedCreateSkylinkFromFileNode(ctx, sup, metadata, fileNode, fanout, size)
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to create skylink from filenode")
 	}
 	return skylink, nil
 }
 
+// managedInitFanoutFileNode creates the fileNode for the extended siafile of a
+// large skyfile which contains the fanout data.
+func (r *Renter) managedInitFanoutFileNode(sup skymodules.SkyfileUploadParameters) (*filesystem.FileNode, error) {
+	// Create the siapath for the skyfile extra data. This is going to be the
+	// same as the skyfile upload siapath, except with a suffix.
+	siaPath, err := sup.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create SiaPath for large skyfile extended data")
+	}
+
+	// Determine the redundancy of the fanout. Disrupt and use custom
+	// redundancy if the StandardUploadRedundancy dependency is set.
+	dataPieces, parityPieces, err := r.managedFanoutPieces(sup)
+	if err != nil {
+		return nil, err
+	}
+	if r.staticDeps.Disrupt("StandardUploadRedundancy") {
+		dataPieces = 10
+		parityPieces = 20
+	}
+
+	// Create the FileUploadParams
+	fup, err := fileUploadParams(siaPath, dataPieces, parityPieces, sup.Force, crypto.TypePlain)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create FileUploadParams for large file")
+	}
+
+	// Generate a Cipher Key for the FileUploadParams.
+	err = generateCipherKey(&fup, sup)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create Cipher key for FileUploadParams")
+	}
+
+	// Check the upload params first and create a fileNode.
+	return r.managedInitUploadStream(fup)
+}
+
+// managedHintSkylink computes the skylink of a large skyfile after all of its
+// data was read and passes it to the SkylinkHint of the upload. The skylink is
+// computed the same way as for a dry-run so the base sector isn't uploaded
+// yet. Since the hint is optional, failures are only logged. The hinted
+// skylink is returned together with a bool indicating whether a skylink was
+// hinted.
+func (r *Renter) managedHintSkylink(ctx context.Context, sup skymodules.SkyfileUploadParameters, fileReader skymodules.SkyfileUploadReader, fileNode *filesystem.FileNode, fanout []byte, size uint64) (skymodules.Skylink, bool) {
+	metadata, err := fileReader.SkyfileMetadata(ctx)
+	if err != nil {
+		r.staticLog.Debugln("failed to get skyfile metadata for skylink hint", err)
+		return skymodules.Skylink{}, false
+	}
+	sup.DryRun = true
+	sup.BaseSectorHint = nil
+	skylink, err := r.managedCreateSkylink(ctx, sup, metadata, fanout, size, fileNode.MasterKey(), fileNode.ErasureCode())
+	if err != nil {
+		r.staticLog.Debugln("failed to create skylink hint", err)
+		return skymodules.Skylink{}, false
+	}
+	// Don't hint blocked skylinks. The upload will fail later on anyway.
+	blocked, err := r.managedIsBlocked(ctx, skylink)
+	if err != nil || blocked {
+		return skymodules.Skylink{}, false
+	}
+	sup.SkylinkHint(skylink)
+	return skylink, true
+}
+
 // DownloadByRoot will fetch data using the merkle root of that data. This uses
-// all of the async worker primitives to improve speed and throughput.
-func (r *Renter) DownloadByRoot(root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, error) {
+// all of the async worker primitives to improve speed and throughput. Only the
+// requester of the provided ctx is used.
+func (r *Renter) DownloadByRoot(ctx context.Context, root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, error) {
+	data, _, err := r.managedDownloadByRootWithTimeout(ctx, root, offset, length, timeout, pricePerMS)
+	return data, err
+}
+
+// DownloadByRootWithHostStats is the same as DownloadByRoot but it also
+// returns the stats of the hosts that contributed to the download.
+func (r *Renter) DownloadByRootWithHostStats(ctx context.Context, root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, []skymodules.SkynetHostStats, error) {
+	data, launchedWorkers, err := r.managedDownloadByRootWithTimeout(ctx, root, offset, length, timeout, pricePerMS)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, newHostStats(launchedWorkers, uint64(len(data))).toSlice(), nil
+}
+
+// managedDownloadByRootWithTimeout fetches data using the merkle root of that
+// data and returns it together with the workers that were launched for the
+// download. Only the requester of the provided ctx is used.
+func (r *Renter) managedDownloadByRootWithTimeout(ctx context.Context, root crypto.Hash, offset, length uint64, timeout time.Duration, pricePerMS types.Currency) ([]byte, []*launchedWorkerInfo, error) {
 	if err := r.tg.Add(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer r.tg.Done()
 
 	// Check if the merkleroot is blocked
 	if r.staticSkynetBlocklist.IsHashBlocked(crypto.HashObject(root)) {
-		return nil, ErrSkylinkBlocked
+		r.staticLogBlocklistRootHit(ctx, root)
+		return nil, nil, ErrSkylinkBlocked
+	}
+
+	// Check if the merkleroot is approved
+	if err := r.managedCheckAllowlist(crypto.HashObject(root)); err != nil {
+		return nil, nil, err
 	}
 
 	// Create the context
-	ctx := r.tg.StopCtx()
+	ctx = r.tg.StopCtx()
 	if timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(r.tg.StopCtx(), timeout)
@@ -750,79 +1013,257 @@ func (r *Renter) DownloadByRoot(root crypto.Hash, offset, length uint64, timeout
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
 	// Fetch the data
-	data, _, err := r.managedDownloadByRoot(ctx, root, offset, length, pricePerMS)
+	data, _, launchedWorkers, err := r.managedDownloadByRootWithLaunchedWorkers(ctx, root, offset, length, pricePerMS)
 	if errors.Contains(err, ErrProjectTimedOut) {
 		err = errors.AddContext(err, fmt.Sprintf("timed out after %vs", timeout.Seconds()))
 	}
-	return data, err
+	return data, launchedWorkers, err
+}
+
+// HasRoot checks whether the sector with the given merkle root is available on
+// the network. Unlike DownloadByRoot it only asks the hosts whether they store
+// the sector without downloading any data. Only the requester of the provided
+// ctx is used.
+func (r *Renter) HasRoot(ctx context.Context, root crypto.Hash, timeout time.Duration) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Check if the merkleroot is blocked
+	if r.staticSkynetBlocklist.IsHashBlocked(crypto.HashObject(root)) {
+		r.staticLogBlocklistRootHit(ctx, root)
+		return ErrSkylinkBlocked
+	}
+
+	// Check if the merkleroot is approved
+	if err := r.managedCheckAllowlist(crypto.HashObject(root)); err != nil {
+		return err
+	}
+
+	// Create the context
+	ctx = r.tg.StopCtx()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(r.tg.StopCtx(), timeout)
+		defer cancel()
+	}
+
+	err := r.managedHasRoot(ctx, root)
+	if errors.Contains(err, ErrProjectTimedOut) {
+		err = errors.AddContext(err, fmt.Sprintf("timed out after %vs", timeout.Seconds()))
+	}
+	return err
+}
+
+// HasSkylinkBaseSector checks whether the base sector of the given skylink is
+// available on the network without downloading it. V2 skylinks are resolved
+// first. It returns the registry entries of the resolution and the resolved
+// skylink. Only the requester of the provided ctx is used.
+func (r *Renter) HasSkylinkBaseSector(ctx context.Context, link skymodules.Skylink, timeout time.Duration) ([]skymodules.RegistryEntry, skymodules.Skylink, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, link, err
+	}
+	defer r.tg.Done()
+
+	// Create the context
+	ctx = skymodules.ContextWithRequester(r.tg.StopCtx(), skymodules.RequesterFromContext(ctx))
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Check if link needs to be resolved from V2 to V1.
+	resolved, srvs, err := r.managedResolveSkylinkForDownload(ctx, link)
+	if err != nil {
+		return nil, resolved, err
+	}
+	link = resolved
+
+	// Check if the link is approved.
+	err = r.managedCheckAllowlist(crypto.HashObject(link.MerkleRoot()))
+	if err != nil {
+		return nil, link, err
+	}
+
+	err = r.managedHasRoot(ctx, link.MerkleRoot())
+	if errors.Contains(err, ErrProjectTimedOut) {
+		err = errors.AddContext(err, fmt.Sprintf("timed out after %vs", timeout.Seconds()))
+	}
+	return srvs, link, err
+}
+
+// managedHasRoot asks all workers whether their host stores the sector with the
+// given root. It returns as soon as one of them does.
+func (r *Renter) managedHasRoot(ctx context.Context, root crypto.Hash) error {
+	workers := r.staticWorkerPool.callWorkers()
+	responseChan := make(chan *jobHasSectorResponse, len(workers))
+	launchedWorkers := 0
+	for _, worker := range workers {
+		// Check for gouging.
+		pt := worker.staticPriceTable().staticPriceTable
+		cache := worker.staticCache()
+		err := checkPCWSGouging(pt, cache.staticRenterAllowance, len(workers), 1)
+		if err != nil {
+			continue // ignore
+		}
+
+		// Add job to worker.
+		jhs := worker.newJobHasSector(ctx, responseChan, 1, root)
+		if !worker.staticJobHasSectorQueue.callAdd(jhs) {
+			continue // ignore
+		}
+		launchedWorkers++
+	}
+
+	for i := 0; i < launchedWorkers; i++ {
+		var resp *jobHasSectorResponse
+		select {
+		case <-ctx.Done():
+			return errors.Compose(ErrProjectTimedOut, ErrRootNotFound)
+		case resp = <-responseChan:
+		}
+		if resp.staticErr == nil && len(resp.staticAvailbleIndices) > 0 {
+			return nil
+		}
+	}
+	return ErrRootNotFound
 }
 
 // DownloadSkylink will take a link and turn it into the metadata and data of a
 // download.
-func (r *Renter) DownloadSkylink(link skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileStreamer, []skymodules.RegistryEntry, error) {
+func (r *Renter) DownloadSkylink(ctx context.Context, link skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileStreamer, []skymodules.RegistryEntry, error) {
+	return r.callDownloadSkylink(ctx, link, nil, timeout, pricePerMS)
+}
+
+// DownloadSkylinkWithSkykey works like DownloadSkylink but uses the given
+// skykey to decrypt the skyfile. The skykey is only used for this download and
+// never added to the renter's skykey manager.
+func (r *Renter) DownloadSkylinkWithSkykey(ctx context.Context, link skymodules.Skylink, sk skykey.Skykey, timeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileStreamer, []skymodules.RegistryEntry, error) {
+	return r.callDownloadSkylink(ctx, link, &sk, timeout, pricePerMS)
+}
+
+// callDownloadSkylink will take a link and turn it into the metadata and data
+// of a download. If a skykey is provided, it is used for decrypting the
+// skyfile. Only the trace ID, the download retry counter and the requester of
+// the provided ctx are used.
+func (r *Renter) callDownloadSkylink(ctx context.Context, link skymodules.Skylink, sk *skykey.Skykey, timeout time.Duration, pricePerMS types.Currency) (_ skymodules.SkyfileStreamer, _ []skymodules.RegistryEntry, err error) {
 	if err := r.tg.Add(); err != nil {
 		return nil, nil, err
 	}
 	defer r.tg.Done()
 
 	// Create a context
-	ctx := r.tg.StopCtx()
+	retries := skymodules.DownloadRetriesFromContext(ctx)
+	requester := skymodules.RequesterFromContext(ctx)
+	ctx = skymodules.ContextWithTraceID(r.tg.StopCtx(), skymodules.TraceIDFromContext(ctx))
+	ctx = skymodules.ContextWithDownloadRetries(ctx, retries)
+	ctx = skymodules.ContextWithRequester(ctx, requester)
 	if timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(r.tg.StopCtx(), timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
 	// Create a new span.
 	span := opentracing.StartSpan("DownloadSkylink")
 	span.SetTag("skylink", link.String())
+	span.SetTag("traceid", skymodules.TraceIDFromContext(ctx))
 
 	// Attach the span to the ctx
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
+	r.staticTracef(ctx, "download of skylink %v started", link)
+	defer func() {
+		if err != nil {
+			r.staticTracef(ctx, "download of skylink %v failed: %v", link, err)
+		}
+	}()
+
 	// Check if link needs to be resolved from V2 to V1.
-	link, srvs, err := r.managedTryResolveSkylinkV2(ctx, link, true)
+	resolved, srvs, err := r.managedResolveSkylinkForDownload(ctx, link)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resolved != link {
+		r.staticTracef(ctx, "skylink %v resolved to %v", link, resolved)
+	}
+	link = resolved
+
+	// Check if the link is approved.
+	err = r.managedCheckAllowlist(crypto.HashObject(link.MerkleRoot()))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Download the data
-	streamer, err := r.managedDownloadSkylink(ctx, link, timeout, pricePerMS)
+	// Download the data. Failures due to a temporarily degraded worker pool
+	// are retried.
+	var streamer skymodules.SkyfileStreamer
+	err = r.managedRetrySkylinkDownload(ctx, func() (err error) {
+		streamer, err = r.managedDownloadSkylink(ctx, link, sk, timeout, pricePerMS)
+		return err
+	})
 	if errors.Contains(err, ErrProjectTimedOut) {
 		span.LogKV("timeout", timeout)
 		span.SetTag("timeout", true)
 		err = errors.AddContext(err, fmt.Sprintf("timed out after %vs", timeout.Seconds()))
 	}
-
-	return streamer, srvs, err
+	if err != nil {
+		return streamer, srvs, err
+	}
+	r.staticTracef(ctx, "download of skylink %v is ready to stream", link)
+	r.staticSkylinkManager.callRecordAccess(link)
+	return streamer, srvs, nil
 }
 
 // DownloadSkylinkBaseSector will take a link and turn it into the data of
 // a basesector without any decoding of the metadata, fanout, or decryption.
-func (r *Renter) DownloadSkylinkBaseSector(link skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) (skymodules.Streamer, []skymodules.RegistryEntry, skymodules.Skylink, error) {
+// Only the trace ID, the download retry counter and the requester of the
+// provided ctx are used.
+func (r *Renter) DownloadSkylinkBaseSector(ctx context.Context, link skymodules.Skylink, timeout time.Duration, pricePerMS types.Currency) (_ skymodules.Streamer, _ []skymodules.RegistryEntry, _ skymodules.Skylink, err error) {
 	if err := r.tg.Add(); err != nil {
 		return nil, nil, link, err
 	}
 	defer r.tg.Done()
 
 	// Create the context
-	ctx := r.tg.StopCtx()
+	retries := skymodules.DownloadRetriesFromContext(ctx)
+	requester := skymodules.RequesterFromContext(ctx)
+	ctx = skymodules.ContextWithTraceID(r.tg.StopCtx(), skymodules.TraceIDFromContext(ctx))
+	ctx = skymodules.ContextWithDownloadRetries(ctx, retries)
+	ctx = skymodules.ContextWithRequester(ctx, requester)
 	if timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(r.tg.StopCtx(), timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
 
 	// Create a span
 	span := opentracing.StartSpan("DownloadSkylinkBaseSector")
 	span.SetTag("skylink", link.String())
+	span.SetTag("traceid", skymodules.TraceIDFromContext(ctx))
 	defer span.Finish()
 
 	// Attach the span to the ctx
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
+	r.staticTracef(ctx, "base sector download of skylink %v started", link)
+	defer func() {
+		if err != nil {
+			r.staticTracef(ctx, "base sector download of skylink %v failed: %v", link, err)
+		}
+	}()
+
 	// Check if link needs to be resolved from V2 to V1.
-	link, srvs, err := r.managedTryResolveSkylinkV2(ctx, link, true)
+	resolved, srvs, err := r.managedResolveSkylinkForDownload(ctx, link)
+	if err != nil {
+		return nil, nil, resolved, err
+	}
+	link = resolved
+
+	// Check if the link is approved.
+	err = r.managedCheckAllowlist(crypto.HashObject(link.MerkleRoot()))
 	if err != nil {
 		return nil, nil, link, err
 	}
@@ -833,14 +1274,19 @@ func (r *Renter) DownloadSkylinkBaseSector(link skymodules.Skylink, timeout time
 		return nil, nil, link, errors.AddContext(err, "unable to get offset and fetch size")
 	}
 
-	// Download the base sector
-	baseSector, _, err := r.managedDownloadByRoot(ctx, link.MerkleRoot(), offset, fetchSize, pricePerMS)
+	// Download the base sector. Failures due to a temporarily degraded worker
+	// pool are retried.
+	var baseSector []byte
+	err = r.managedRetrySkylinkDownload(ctx, func() (err error) {
+		baseSector, _, err = r.managedDownloadByRoot(ctx, link.MerkleRoot(), offset, fetchSize, pricePerMS)
+		return err
+	})
 	return StreamerFromSlice(baseSector), srvs, link, err
 }
 
 // managedDownloadSkylink will take a link and turn it into the metadata and
 // data of a download.
-func (r *Renter) managedDownloadSkylink(ctx context.Context, link skymodules.Skylink, streamReadTimeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileStreamer, error) {
+func (r *Renter) managedDownloadSkylink(ctx context.Context, link skymodules.Skylink, sk *skykey.Skykey, streamReadTimeout time.Duration, pricePerMS types.Currency) (skymodules.SkyfileStreamer, error) {
 	if r.staticDeps.Disrupt("resolveSkylinkToFixture") {
 		sf, err := fixtures.LoadSkylinkFixture(link)
 		if err != nil {
@@ -867,15 +1313,16 @@ func (r *Renter) managedDownloadSkylink(ctx context.Context, link skymodules.Sky
 	// Check if this skylink is already in the stream buffer set. If so, we can
 	// skip the lookup procedure and use any data that other threads have
 	// cached.
-	id := link.DataSourceID()
+	id := skylinkDataSourceID(link, sk)
 	var stream *stream
 	stream, exists = r.staticStreamBufferSet.callNewStreamFromID(ctx, id, 0, streamReadTimeout)
 	if exists {
+		r.staticTracef(ctx, "using cached data source for skylink %v", link)
 		return stream, nil
 	}
 
 	// Create the data source and add it to the stream buffer set.
-	dataSource, err := r.managedSkylinkDataSource(ctx, link, pricePerMS)
+	dataSource, err := r.managedSkylinkDataSource(ctx, link, sk, pricePerMS)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to create data source for skylink")
 	}
@@ -884,8 +1331,10 @@ func (r *Renter) managedDownloadSkylink(ctx context.Context, link skymodules.Sky
 }
 
 // PinSkylink will fetch the file associated with the Skylink, and then pin all
-// necessary content to maintain that Skylink.
-func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUploadParameters, timeout time.Duration, pricePerMS types.Currency) (err error) {
+// necessary content to maintain that Skylink. If 'baseSectorOnly' is set, only
+// the base sector is pinned which keeps the skylink resolvable but doesn't
+// guarantee the availability of the fanout.
+func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUploadParameters, baseSectorOnly bool, timeout time.Duration, pricePerMS types.Currency) (err error) {
 	err = r.tg.Add()
 	if err != nil {
 		return err
@@ -913,6 +1362,12 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 		return ErrSkylinkBlocked
 	}
 
+	// Check if link is approved
+	err = r.managedCheckAllowlist(crypto.HashObject(skylink.MerkleRoot()))
+	if err != nil {
+		return err
+	}
+
 	// Create a span.
 	span := opentracing.StartSpan("PinSkylink")
 	span.SetTag("skylink", skylink.String())
@@ -922,7 +1377,7 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 	ctx = opentracing.ContextWithSpan(ctx, span)
 
 	// Fetch the leading chunk.
-	baseSector, err := r.DownloadByRoot(skylink.MerkleRoot(), 0, modules.SectorSize, timeout, pricePerMS)
+	baseSector, err := r.DownloadByRoot(ctx, skylink.MerkleRoot(), 0, modules.SectorSize, timeout, pricePerMS)
 	if err != nil {
 		return errors.AddContext(err, "unable to fetch base sector of skylink")
 	}
@@ -930,6 +1385,36 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 		return errors.New("download did not fetch enough data, file cannot be re-pinned")
 	}
 
+	// The fanout is read from a stream of the skylink's data.
+	fanoutReader := func(_ skymodules.SkyfileLayout, _ []byte, _ skykey.Skykey) (io.Reader, error) {
+		dataSource, err := r.managedSkylinkDataSource(ctx, skylink, nil, pricePerMS)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to create data source for skylink")
+		}
+		return r.staticStreamBufferSet.callNewStream(ctx, dataSource, 0, timeout, pricePerMS), nil
+	}
+	return r.managedPinBaseSector(ctx, skylink, lup, baseSector, baseSectorOnly, r.ParseSkyfileMetadata, fanoutReader)
+}
+
+// managedPinBaseSector pins a skyfile given its full base sector. The base
+// sector is parsed using parseFn and re-uploaded. Unless baseSectorOnly is
+// set, the fanout is then re-uploaded from the reader returned by
+// fanoutReaderFn.
+func (r *Renter) managedPinBaseSector(ctx context.Context, skylink skymodules.Skylink, lup skymodules.SkyfileUploadParameters, baseSector []byte, baseSectorOnly bool, parseFn parseSkyfileMetadataFunc, fanoutReaderFn fanoutReaderFunc) (err error) {
+	// Abort the pin if the skylink gets blocked while it is in flight.
+	ctx, done := r.managedTrackSkylink(ctx, skylink, lup.SiaPath)
+	defer done(&err)
+
+	// Add the pinned siafiles to the used skynet storage and the search
+	// index.
+	var metadata skymodules.SkyfileMetadata
+	defer func() {
+		if err == nil {
+			r.managedTrackSkyfileStorage(lup.SiaPath)
+			r.staticSkynetSearchIndex.callAdd(lup.SiaPath, skylink, metadata)
+		}
+	}()
+
 	// Check if the base sector is encrypted, and attempt to decrypt it.
 	var fileSpecificSkykey skykey.Skykey
 	encrypted := skymodules.IsEncryptedBaseSector(baseSector)
@@ -941,17 +1426,28 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 	}
 
 	// Parse out the metadata of the skyfile.
-	layout, _, _, _, _, baseSectorExtension, err := r.ParseSkyfileMetadata(baseSector)
+	layout, fanoutBytes, metadata, _, _, baseSectorExtension, err := parseFn(baseSector)
 	if err != nil {
 		return errors.AddContext(err, "error parsing skyfile metadata")
 	}
 
+	// Reject the pin if the skynet storage cap would be exceeded by the base
+	// sector and the fanout.
+	incoming := uint64(len(baseSector) + len(baseSectorExtension))
+	if layout.FanoutSize > 0 && !baseSectorOnly {
+		incoming += layout.Filesize
+	}
+	err = r.managedCheckSkynetStorageCap(incoming)
+	if err != nil {
+		return err
+	}
+
 	// We need to pin the extended fanout as well so we just add it to the
 	// base sector.
 	baseSector = append(baseSector, baseSectorExtension...)
 
 	// Set sane defaults for unspecified values.
-	skyfileEstablishDefaults(&lup)
+	r.managedSkyfileEstablishDefaults(&lup)
 
 	// Start setting up the FUP.
 	fup := skymodules.FileUploadParams{
@@ -985,13 +1481,14 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 	}
 
 	// Re-upload the baseSector.
-	err = r.managedUploadBaseSector(ctx, lup, baseSector, skylink)
+	err = r.managedUploadBaseSector(ctx, lup, baseSector, layout, skylink)
 	if err != nil {
 		return errors.AddContext(err, "unable to upload base sector")
 	}
 
-	// If there is no fanout, nothing more to do, the pin is complete.
-	if layout.FanoutSize == 0 {
+	// If there is no fanout or only the base sector should be pinned, nothing
+	// more to do, the pin is complete.
+	if layout.FanoutSize == 0 || baseSectorOnly {
 		return nil
 	}
 
@@ -1029,24 +1526,66 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 		return errors.AddContext(err, "unable to create SiaPath for large skyfile extended data")
 	}
 
-	// Create the data source and add it to the stream buffer set.
-	dataSource, err := r.managedSkylinkDataSource(ctx, skylink, pricePerMS)
+	// Create the reader for the fanout.
+	reader, err := fanoutReaderFn(layout, fanoutBytes, fileSpecificSkykey)
 	if err != nil {
-		return errors.AddContext(err, "unable to create data source for skylink")
+		return err
+	}
+
+	// Close the reader once the fanout is uploaded. A stream of the skylink
+	// keeps its stream buffer alive until it's closed.
+	if closer, ok := reader.(io.Closer); ok {
+		defer func() {
+			err = errors.Compose(err, closer.Close())
+		}()
+	}
+
+	// Zero chunks of sparse skyfiles are not uploaded, just like for the
+	// original upload. Only unencrypted skyfiles can be sparse.
+	var sparse bool
+	if fup.CipherType == crypto.TypePlain {
+		fanoutChunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+		if err != nil {
+			return errors.AddContext(err, "unable to decode fanout")
+		}
+		for _, chunk := range fanoutChunks {
+			if skymodules.IsZeroChunk(chunk) {
+				sparse = true
+				break
+			}
+		}
 	}
-	stream := r.staticStreamBufferSet.callNewStream(ctx, dataSource, 0, timeout, pricePerMS)
 
-	// Upload directly from the stream.
-	fileNode, err := r.callUploadStreamFromReader(ctx, fup, stream)
+	// Upload directly from the reader.
+	fileNode, err := r.managedInitUploadStream(fup)
 	if err != nil {
 		return errors.AddContext(err, "unable to upload large skyfile")
 	}
+	var cr skymodules.ChunkReader
+	var sparseReader *sparseFanoutChunkReader
+	if sparse {
+		onePiece := layout.FanoutDataPieces == 1
+		sparseReader = newSparseFanoutChunkReader(reader, fileNode.ErasureCode(), onePiece, fileNode.MasterKey())
+		cr = sparseReader
+	} else {
+		cr = NewChunkReader(reader, fileNode.ErasureCode(), fileNode.MasterKey())
+	}
+	_, err = r.callUploadStreamFromReaderWithFileNode(ctx, fileNode, cr, 0)
+	if err != nil {
+		err = errors.Compose(err, fileNode.Close())
+		return errors.AddContext(err, "unable to upload large skyfile")
+	}
 
 	// Sanity Check that the fileNode created matches the layout. This is to
 	// protect against an edge case where a portal can download a basesector
 	// but none of the fanout data. In this case the fileNode is
-	// successfully created, but with no data uploaded.
+	// successfully created, but with no data uploaded. The siafile of a
+	// sparse skyfile only contains the chunks with data so the size read by
+	// the reader is checked instead.
 	actual := fileNode.Metadata().FileSize
+	if sparseReader != nil {
+		actual = int64(sparseReader.Size())
+	}
 	expected := int64(layout.Filesize)
 	if actual != expected {
 		return fmt.Errorf("pin unsuccessful, filesize %v does not match layout filesize %v", actual, expected)
@@ -1061,10 +1600,18 @@ func (r *Renter) PinSkylink(skylink skymodules.Skylink, lup skymodules.SkyfileUp
 }
 
 // RestoreSkyfile restores a skyfile from disk such that the skylink is
-// preserved.
+// preserved. The reader can either contain a backup created by
+// skymodules.BackupSkylink or a backup archive of the skyfile's sectors.
 func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
+	// Backup archives start with the tar header of their manifest.
+	br := bufio.NewReader(reader)
+	prefix, _ := br.Peek(len(skymodules.SkyfileArchiveManifestName))
+	if string(prefix) == skymodules.SkyfileArchiveManifestName {
+		return r.managedRestoreSkyfileArchive(br)
+	}
+
 	// Restore the skylink and baseSector from the reader
-	skylinkStr, baseSector, err := skymodules.RestoreSkylink(reader)
+	skylinkStr, baseSector, err := skymodules.RestoreSkylink(br)
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to restore skyfile from backup")
 	}
@@ -1075,7 +1622,60 @@ func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to load skylink")
 	}
+	return r.managedRestoreSkyfile(skylink, baseSector, br)
+}
 
+// managedRestoreSkyfileArchive restores a skyfile from a backup archive. The
+// data of the skyfile is recovered from the archived sectors so the hosts are
+// only contacted to upload the restored skyfile.
+func (r *Renter) managedRestoreSkyfileArchive(reader io.Reader) (skymodules.Skylink, error) {
+	// Read the manifest and the base sector.
+	tr := tar.NewReader(reader)
+	_, skylink, sector, err := skymodules.ReadSkyfileArchiveHeader(tr)
+	if err != nil {
+		return skymodules.Skylink{}, errors.AddContext(err, "unable to restore skyfile from backup archive")
+	}
+	offset, fetchSize, err := skylink.OffsetAndFetchSize()
+	if err != nil {
+		return skymodules.Skylink{}, errors.AddContext(err, "unable to get offset and fetch size")
+	}
+	baseSector := sector[offset : offset+fetchSize]
+
+	// Parse a decrypted copy of the base sector to get the fanout.
+	decrypted := append([]byte(nil), baseSector...)
+	var fileSpecificSkykey skykey.Skykey
+	if skymodules.IsEncryptedBaseSector(decrypted) {
+		fileSpecificSkykey, err = r.managedDecryptBaseSector(decrypted)
+		if err != nil {
+			return skymodules.Skylink{}, errors.AddContext(err, "unable to decrypt skyfile base sector")
+		}
+	}
+	sl, fanoutBytes, _, _, _, err := skymodules.ParseSkyfileMetadata(decrypted)
+	if errors.Contains(err, skymodules.ErrRecursiveBaseSector) {
+		return skymodules.Skylink{}, errors.New("restoring skyfiles with an extended base sector from a backup archive is not supported")
+	} else if err != nil {
+		return skymodules.Skylink{}, errors.AddContext(err, "error parsing the baseSector")
+	}
+	chunks, err := sl.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		return skymodules.Skylink{}, errors.AddContext(err, "error decoding fanout")
+	}
+	fanoutKey, err := skymodules.DeriveFanoutKey(&sl, fileSpecificSkykey)
+	if err != nil {
+		return skymodules.Skylink{}, errors.AddContext(err, "unable to derive fanout key")
+	}
+
+	// Restore the skyfile from the recovered data.
+	data := skymodules.NewSkyfileArchiveDataReader(tr, sl, chunks, fanoutKey)
+	defer func() {
+		_ = data.Close()
+	}()
+	return r.managedRestoreSkyfile(skylink, baseSector, data)
+}
+
+// managedRestoreSkyfile restores the skyfile with the given skylink, base
+// sector and data.
+func (r *Renter) managedRestoreSkyfile(skylink skymodules.Skylink, baseSector []byte, reader io.Reader) (skymodules.Skylink, error) {
 	// Check if the new skylink is blocked
 	blocked, err := r.managedIsBlocked(r.tg.StopCtx(), skylink)
 	if err != nil {
@@ -1118,8 +1718,9 @@ func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
 
 		TryFiles:   sm.TryFiles,
 		ErrorPages: sm.ErrorPages,
+		ModTime:    sm.ModTime,
 	}
-	skyfileEstablishDefaults(&sup)
+	r.managedSkyfileEstablishDefaults(&sup)
 
 	// Re-encrypt the baseSector for upload and set the Skykey fields of the
 	// sup.
@@ -1139,7 +1740,13 @@ func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
 	if len(sm.Subfiles) == 0 {
 		restoreReader = skymodules.NewSkyfileReader(reader, sup)
 	} else {
-		// Create multipart reader from the subfiles
+		// Create multipart reader from the subfiles. The subfiles are part
+		// of the restored skylink so they are not subject to the limits of
+		// new uploads.
+		sup.MultipartLimits = skymodules.SkynetMultipartLimits{
+			MaxFilenameLength: math.MaxUint64,
+			MaxHeaderBytes:    math.MaxUint64,
+		}
 		multiReader, err := skymodules.NewMultipartReader(reader, sm.Subfiles)
 		if err != nil {
 			return skymodules.Skylink{}, errors.AddContext(err, "unable to create multireader")
@@ -1148,7 +1755,7 @@ func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
 	}
 
 	// Upload the Base Sector of the skyfile
-	err = r.managedUploadBaseSector(r.tg.StopCtx(), sup, baseSector, skylink)
+	err = r.managedUploadBaseSector(r.tg.StopCtx(), sup, baseSector, sl, skylink)
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "failed to upload base sector")
 	}
@@ -1236,7 +1843,7 @@ func (r *Renter) RestoreSkyfile(reader io.Reader) (skymodules.Skylink, error) {
 // both the file data and metadata.
 func (r *Renter) UploadSkyfile(ctx context.Context, sup skymodules.SkyfileUploadParameters, reader skymodules.SkyfileUploadReader) (skylink skymodules.Skylink, err error) {
 	// Set reasonable default values for any sup fields that are blank.
-	skyfileEstablishDefaults(&sup)
+	r.managedSkyfileEstablishDefaults(&sup)
 
 	// If a skykey name or ID was specified, generate a file-specific key for
 	// this upload.
@@ -1245,6 +1852,16 @@ func (r *Renter) UploadSkyfile(ctx context.Context, sup skymodules.SkyfileUpload
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to upload skyfile")
 	}
 
+	// Reject the upload if the skynet storage cap is reached or would be
+	// exceeded by the expected size of the upload. Dry runs don't use any
+	// storage.
+	if !sup.DryRun {
+		err = r.managedCheckSkynetStorageCap(sup.ExpectedSize)
+		if err != nil {
+			return skymodules.Skylink{}, errors.AddContext(err, "unable to upload skyfile")
+		}
+	}
+
 	// defer a function that cleans up the siafiles after a failed upload
 	// attempt or after a dry run
 	defer func() {
@@ -1264,6 +1881,7 @@ func (r *Renter) UploadSkyfile(ctx context.Context, sup skymodules.SkyfileUpload
 
 	// Create a span and attach it to our context
 	span := opentracing.StartSpan("UploadSkyfile")
+	span.SetTag("traceid", skymodules.TraceIDFromContext(ctx))
 	ctx = opentracing.ContextWithSpan(ctx, span)
 	defer func() {
 		if err != nil {
@@ -1274,13 +1892,22 @@ func (r *Renter) UploadSkyfile(ctx context.Context, sup skymodules.SkyfileUpload
 		span.Finish()
 	}()
 
+	r.staticTracef(ctx, "upload of skyfile %v started", sup.SiaPath)
+	defer func() {
+		if err != nil {
+			r.staticTracef(ctx, "upload of skyfile %v failed: %v", sup.SiaPath, err)
+		} else {
+			r.staticTracef(ctx, "upload of skyfile %v finished with skylink %v", sup.SiaPath, skylink)
+		}
+	}()
+
 	// Upload the skyfile
 	skylink, err = r.managedUploadSkyfile(ctx, sup, reader)
 	if err != nil {
 		return skymodules.Skylink{}, errors.AddContext(err, "unable to upload skyfile")
 	}
 	if r.staticDeps.Disrupt("SkyfileUploadFail") {
-		return skymodules.Skylink{}, errors.New("SkyfileUploadFail")
+		return skymodules.Skylink{}, newSkyfileUploadError(errors.New("SkyfileUploadFail"), skylink)
 	}
 
 	// After uploading the file we queue a bubble for the new files on disk.
@@ -1293,14 +1920,24 @@ func (r *Renter) UploadSkyfile(ctx context.Context, sup skymodules.SkyfileUpload
 	// Check if skylink is blocked
 	blocked, err := r.managedIsBlocked(ctx, skylink)
 	if err != nil {
-		return skymodules.Skylink{}, err
+		return skymodules.Skylink{}, newSkyfileUploadError(err, skylink)
 	}
 	if blocked && !sup.DryRun {
 		// No need to try and delete the file, the above defer func will handle
 		// the deletion
 		return skymodules.Skylink{}, ErrSkylinkBlocked
 	}
+	if !sup.DryRun {
+		r.managedTrackSkyfileStorage(sup.SiaPath)
 
+		// The reader is drained, so its metadata is available.
+		metadata, err := reader.SkyfileMetadata(ctx)
+		if err != nil {
+			r.staticLog.Printf("failed to index skyfile %v for search: %v", sup.SiaPath, err)
+		} else {
+			r.staticSkynetSearchIndex.callAdd(sup.SiaPath, skylink, metadata)
+		}
+	}
 	return skylink, nil
 }
 
@@ -1451,6 +2088,24 @@ func (r *Renter) managedTryResolveSkylinkV2(ctx context.Context, link skymodules
 	return link, srvs, nil
 }
 
+// managedResolveSkylinkForDownload resolves a V2 skylink to a V1 skylink and
+// checks whether the V1 skylink is blocked. Since V2 skylinks are blocked by
+// the hash of the V1 skylink they resolve to, the blocklist only needs to be
+// checked once the link is resolved. Blocked attempts are recorded as
+// blocklist hits.
+func (r *Renter) managedResolveSkylinkForDownload(ctx context.Context, link skymodules.Skylink) (skymodules.Skylink, []skymodules.RegistryEntry, error) {
+	resolved, srvs, err := r.managedTryResolveSkylinkV2(ctx, link, false)
+	if err != nil {
+		return skymodules.Skylink{}, nil, err
+	}
+	hash := crypto.HashObject(resolved.MerkleRoot())
+	if r.staticSkynetBlocklist.IsHashBlocked(hash) {
+		r.staticLogBlocklistHit(ctx, link, hash)
+		return skymodules.Skylink{}, nil, ErrSkylinkBlocked
+	}
+	return resolved, srvs, nil
+}
+
 // managedSkylinkHealth returns the health of a skylink on the network.
 func (r *Renter) managedSkylinkHealth(ctx context.Context, sl skymodules.Skylink, ppms types.Currency) (skymodules.SkylinkHealth, error) {
 	// Resolve the skylink if necessary.
@@ -1493,6 +2148,7 @@ func (r *Renter) managedSkylinkHealth(ctx context.Context, sl skymodules.Skylink
 	// If the file has a fanout, ask the hosts for the fanout as well.
 	rootIndexToChunkIndex := make(map[int]int)
 	numChunks := 0
+	var zeroChunks []int
 	if len(fanoutBytes) > 0 {
 		// Create the list of chunks from the fanout. Since we want to
 		// give an overview of the health of the file on the network, we
@@ -1503,6 +2159,12 @@ func (r *Renter) managedSkylinkHealth(ctx context.Context, sl skymodules.Skylink
 		}
 
 		for chunkIndex, chunk := range fanoutChunks {
+			// Zero chunks of sparse skyfiles are not stored on the
+			// network.
+			if skymodules.IsZeroChunk(chunk) {
+				zeroChunks = append(zeroChunks, chunkIndex)
+				continue
+			}
 			for _, root := range chunk {
 				rootIndexToChunkIndex[len(roots)] = chunkIndex
 				roots = append(roots, root)
@@ -1616,6 +2278,10 @@ func (r *Renter) managedSkylinkHealth(ctx context.Context, sl skymodules.Skylink
 			chunkGoodPieces[chunkIndex]++
 		}
 	}
+	// Zero chunks can always be recovered.
+	for _, chunkIndex := range zeroChunks {
+		chunkGoodPieces[chunkIndex] = numPieces
+	}
 
 	// Set the base sector redundancy.
 	health := skymodules.SkylinkHealth{
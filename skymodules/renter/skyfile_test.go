@@ -193,13 +193,13 @@ func TestShortFanoutPanic(t *testing.T) {
 	// Upload the base sector.
 	err = r.managedUploadBaseSector(context.Background(), skymodules.SkyfileUploadParameters{
 		SiaPath: skymodules.RandomSiaPath(),
-	}, bs, skylink)
+	}, bs, sl, skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Download the file. This should fail due to the short fanout.
-	_, _, err = r.DownloadSkylink(skylink, time.Hour, skymodules.DefaultSkynetPricePerMS)
+	_, _, err = r.DownloadSkylink(context.Background(), skylink, time.Hour, skymodules.DefaultSkynetPricePerMS)
 	if err == nil || !strings.Contains(err.Error(), skymodules.ErrMalformedBaseSector.Error()) {
 		t.Fatal(err)
 	}
@@ -295,7 +295,7 @@ func TestParseSkyfileMetadataRecursive(t *testing.T) {
 	// Upload the base sector.
 	err = r.managedUploadBaseSector(context.Background(), skymodules.SkyfileUploadParameters{
 		SiaPath: skymodules.RandomSkynetFilePath(),
-	}, bs, skylink)
+	}, bs, sl, skylink)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,134 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/types"
+)
+
+// SkyfileConsistency checks whether the layout, fanout and metadata of the
+// skyfile behind the given skylink are consistent with each other. Only the
+// base sector of the skylink is downloaded. Unlike regular downloads, the
+// metadata is not validated while parsing it to allow for inspecting malformed
+// skyfiles.
+func (r *Renter) SkyfileConsistency(ctx context.Context, sl skymodules.Skylink, ppms types.Currency) (skymodules.SkyfileConsistency, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkyfileConsistency{}, err
+	}
+	defer r.tg.Done()
+
+	// Resolve the skylink if necessary.
+	sl, _, err := r.managedTryResolveSkylinkV2(ctx, sl, true)
+	if err != nil {
+		return skymodules.SkyfileConsistency{}, errors.AddContext(err, "failed to resolve skylink")
+	}
+
+	// Get the base sector.
+	offset, fetchSize, err := sl.OffsetAndFetchSize()
+	if err != nil {
+		return skymodules.SkyfileConsistency{}, errors.AddContext(err, "unable to parse offset and fetchsize from skylink")
+	}
+	baseSector, _, err := r.managedDownloadByRoot(ctx, sl.MerkleRoot(), offset, fetchSize, ppms)
+	if err != nil {
+		return skymodules.SkyfileConsistency{}, errors.AddContext(err, "unable to download base sector")
+	}
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		_, err = r.managedDecryptBaseSector(baseSector)
+		if err != nil {
+			return skymodules.SkyfileConsistency{}, errors.AddContext(err, "failed to decrypt base sector")
+		}
+	}
+
+	// Parse the base sector without validating the metadata. Recursive base
+	// sectors are parsed by the renter which doesn't validate the metadata
+	// either.
+	layout, fanoutBytes, md, _, _, err := skymodules.ParseSkyfileMetadataUnvalidated(baseSector)
+	if errors.Contains(err, skymodules.ErrRecursiveBaseSector) {
+		layout, fanoutBytes, md, _, _, _, err = r.ParseSkyfileMetadata(baseSector)
+	}
+	if err != nil {
+		return skymodules.SkyfileConsistency{}, errors.AddContext(err, "error parsing skyfile metadata")
+	}
+	inconsistencies := skyfileInconsistencies(layout, fanoutBytes, md)
+	return skymodules.SkyfileConsistency{
+		Consistent:      len(inconsistencies) == 0,
+		Inconsistencies: inconsistencies,
+	}, nil
+}
+
+// skyfileInconsistencies returns a description of every inconsistency between
+// the layout, fanout and metadata of a skyfile.
+func skyfileInconsistencies(layout skymodules.SkyfileLayout, fanoutBytes []byte, md skymodules.SkyfileMetadata) []string {
+	inconsistencies := make([]string, 0)
+	report := func(format string, args ...interface{}) {
+		inconsistencies = append(inconsistencies, fmt.Sprintf(format, args...))
+	}
+
+	// The length in the metadata needs to match the filesize in the layout.
+	// Legacy skyfiles don't set the length.
+	if md.Length != 0 && md.Length != layout.Filesize {
+		report("metadata length %v doesn't match layout filesize %v", md.Length, layout.Filesize)
+	}
+
+	// The subfiles need to be laid out back to back within the file.
+	if len(md.Subfiles) > 0 {
+		subfiles := make([]skymodules.SkyfileSubfileMetadata, 0, len(md.Subfiles))
+		for _, sf := range md.Subfiles {
+			subfiles = append(subfiles, sf)
+		}
+		// Empty subfiles share their offset with the following subfile, so
+		// they are sorted first.
+		sort.Slice(subfiles, func(i, j int) bool {
+			if subfiles[i].Offset != subfiles[j].Offset {
+				return subfiles[i].Offset < subfiles[j].Offset
+			}
+			if subfiles[i].Len != subfiles[j].Len {
+				return subfiles[i].Len < subfiles[j].Len
+			}
+			return subfiles[i].Filename < subfiles[j].Filename
+		})
+		var totalLength, end uint64
+		for i, sf := range subfiles {
+			totalLength += sf.Len
+			if i > 0 && sf.Offset < end {
+				report("subfile '%v' at offset %v overlaps subfile '%v' which ends at offset %v", sf.Filename, sf.Offset, subfiles[i-1].Filename, end)
+			} else if sf.Offset > end {
+				report("subfile '%v' starts at offset %v but the previous data ends at offset %v", sf.Filename, sf.Offset, end)
+			}
+			if sf.Offset+sf.Len > layout.Filesize {
+				report("subfile '%v' ends at offset %v which exceeds the filesize %v", sf.Filename, sf.Offset+sf.Len, layout.Filesize)
+			}
+			if sf.Offset+sf.Len > end {
+				end = sf.Offset + sf.Len
+			}
+		}
+		if md.Length != 0 && totalLength != md.Length {
+			report("sum of subfile lengths %v doesn't match metadata length %v", totalLength, md.Length)
+		}
+	}
+
+	// The fanout needs to contain the number of chunks required by the
+	// filesize.
+	if layout.FanoutSize > 0 {
+		if layout.FanoutDataPieces == 0 {
+			report("fanout has 0 data pieces")
+		} else if _, _, numChunks, err := skymodules.DecodeFanout(layout, fanoutBytes); err != nil {
+			report("invalid fanout: %v", err)
+		} else if expected := skymodules.NumChunks(layout.CipherType, layout.Filesize, uint64(layout.FanoutDataPieces)); numChunks != expected {
+			report("fanout contains %v chunks but filesize %v requires %v chunks", numChunks, layout.Filesize, expected)
+		}
+	}
+
+	// Run the regular metadata validation as well. It's only reported if none
+	// of the more specific checks found anything since it covers some of them.
+	if len(inconsistencies) == 0 {
+		if err := skymodules.ValidateSkyfileMetadata(md); err != nil {
+			report("invalid metadata: %v", err)
+		}
+	}
+	return inconsistencies
+}
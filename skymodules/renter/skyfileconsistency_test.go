@@ -0,0 +1,133 @@
+package renter
+
+import (
+	"strings"
+	"testing"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestSkyfileInconsistencies is a unit test for skyfileInconsistencies.
+func TestSkyfileInconsistencies(t *testing.T) {
+	t.Parallel()
+
+	ss := modules.SectorSize
+	subfiles := func(sfs ...skymodules.SkyfileSubfileMetadata) skymodules.SkyfileSubfiles {
+		m := make(skymodules.SkyfileSubfiles)
+		for _, sf := range sfs {
+			m[sf.Filename] = sf
+		}
+		return m
+	}
+	smallLayout := skymodules.SkyfileLayout{Version: 1, Filesize: 30}
+	largeLayout := skymodules.SkyfileLayout{
+		Version:            1,
+		Filesize:           ss + 1,
+		FanoutSize:         2 * crypto.HashSize,
+		FanoutDataPieces:   1,
+		FanoutParityPieces: 9,
+		CipherType:         crypto.TypePlain,
+	}
+
+	tests := []struct {
+		name     string
+		layout   skymodules.SkyfileLayout
+		fanout   []byte
+		md       skymodules.SkyfileMetadata
+		expected []string
+	}{
+		{
+			name:   "single file",
+			layout: smallLayout,
+			md:     skymodules.SkyfileMetadata{Filename: "file", Length: 30},
+		},
+		{
+			name:   "legacy file without length",
+			layout: smallLayout,
+			md:     skymodules.SkyfileMetadata{Filename: "file"},
+		},
+		{
+			name:   "consistent subfiles",
+			layout: smallLayout,
+			md: skymodules.SkyfileMetadata{Filename: "dir", Length: 30, Subfiles: subfiles(
+				skymodules.SkyfileSubfileMetadata{Filename: "a", Offset: 0, Len: 10},
+				skymodules.SkyfileSubfileMetadata{Filename: "empty", Offset: 10, Len: 0},
+				skymodules.SkyfileSubfileMetadata{Filename: "b", Offset: 10, Len: 20},
+			)},
+		},
+		{
+			name:     "length mismatch",
+			layout:   smallLayout,
+			md:       skymodules.SkyfileMetadata{Filename: "file", Length: 20},
+			expected: []string{"doesn't match layout filesize"},
+		},
+		{
+			name:   "overlapping subfiles",
+			layout: smallLayout,
+			md: skymodules.SkyfileMetadata{Filename: "dir", Length: 30, Subfiles: subfiles(
+				skymodules.SkyfileSubfileMetadata{Filename: "a", Offset: 0, Len: 20},
+				skymodules.SkyfileSubfileMetadata{Filename: "b", Offset: 10, Len: 10},
+			)},
+			expected: []string{"overlaps subfile 'a'"},
+		},
+		{
+			name:   "gap and out of bounds",
+			layout: smallLayout,
+			md: skymodules.SkyfileMetadata{Filename: "dir", Length: 30, Subfiles: subfiles(
+				skymodules.SkyfileSubfileMetadata{Filename: "a", Offset: 0, Len: 10},
+				skymodules.SkyfileSubfileMetadata{Filename: "b", Offset: 20, Len: 20},
+			)},
+			expected: []string{"previous data ends at offset 10", "exceeds the filesize"},
+		},
+		{
+			name:   "subfile lengths mismatch",
+			layout: smallLayout,
+			md: skymodules.SkyfileMetadata{Filename: "dir", Length: 30, Subfiles: subfiles(
+				skymodules.SkyfileSubfileMetadata{Filename: "a", Offset: 0, Len: 10},
+				skymodules.SkyfileSubfileMetadata{Filename: "b", Offset: 10, Len: 10},
+			)},
+			expected: []string{"sum of subfile lengths 20 doesn't match metadata length 30"},
+		},
+		{
+			name:   "consistent fanout",
+			layout: largeLayout,
+			fanout: make([]byte, 2*crypto.HashSize),
+			md:     skymodules.SkyfileMetadata{Filename: "file", Length: ss + 1},
+		},
+		{
+			name:     "fanout chunk mismatch",
+			layout:   largeLayout,
+			fanout:   make([]byte, 3*crypto.HashSize),
+			md:       skymodules.SkyfileMetadata{Filename: "file", Length: ss + 1},
+			expected: []string{"fanout contains 3 chunks but filesize"},
+		},
+		{
+			name:     "invalid metadata",
+			layout:   smallLayout,
+			md:       skymodules.SkyfileMetadata{Filename: "", Length: 30},
+			expected: []string{"invalid metadata"},
+		},
+	}
+	for _, test := range tests {
+		inconsistencies := skyfileInconsistencies(test.layout, test.fanout, test.md)
+		if len(inconsistencies) != len(test.expected) {
+			t.Fatalf("%v: expected %v inconsistencies but got %v", test.name, len(test.expected), inconsistencies)
+		}
+		for i, expected := range test.expected {
+			if !strings.Contains(inconsistencies[i], expected) {
+				t.Fatalf("%v: expected '%v' to contain '%v'", test.name, inconsistencies[i], expected)
+			}
+		}
+	}
+
+	// A fanout without data pieces is reported rather than causing a panic.
+	layout := largeLayout
+	layout.FanoutDataPieces = 0
+	layout.FanoutParityPieces = 0
+	inconsistencies := skyfileInconsistencies(layout, make([]byte, 2*crypto.HashSize), skymodules.SkyfileMetadata{Filename: "file", Length: ss + 1})
+	if len(inconsistencies) != 1 || !strings.Contains(inconsistencies[0], "0 data pieces") {
+		t.Fatal("unexpected inconsistencies", inconsistencies)
+	}
+}
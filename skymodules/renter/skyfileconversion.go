@@ -0,0 +1,296 @@
+package renter
+
+// skyfileconversion.go implements the asynchronous conversion of siafiles to
+// skyfiles. A conversion is started in the background and tracked by the
+// skyfileConversionManager which allows for querying its progress and for
+// cancelling it.
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem/siafile"
+)
+
+var (
+	// ErrSkyfileConversionNotFound is returned if there is no conversion for
+	// a given ID.
+	ErrSkyfileConversionNotFound = errors.New("skyfile conversion not found")
+
+	// ErrSkyfileConversionCancelled is returned as the error of a conversion
+	// that was cancelled before it finished.
+	ErrSkyfileConversionCancelled = errors.New("skyfile conversion was cancelled")
+
+	// ErrSkyfileConversionFinished is returned when trying to cancel a
+	// conversion that already finished.
+	ErrSkyfileConversionFinished = errors.New("skyfile conversion already finished")
+)
+
+type (
+	// skyfileConversionManager keeps track of all the asynchronous skyfile
+	// conversions.
+	skyfileConversionManager struct {
+		conversions map[string]*skyfileConversion
+		mu          sync.Mutex
+	}
+
+	// skyfileConversion tracks the state of a single asynchronous conversion.
+	skyfileConversion struct {
+		staticCancel      context.CancelFunc
+		staticID          string
+		staticSiaPath     skymodules.SiaPath
+		staticTotalChunks uint64
+
+		cancelRequested bool
+		cancelled       bool
+		chunksConverted uint64
+		completed       bool
+		err             error
+		finishTime      time.Time
+		skylink         skymodules.Skylink
+		mu              sync.Mutex
+	}
+)
+
+// newSkyfileConversionManager returns a newly initialized
+// skyfileConversionManager.
+func newSkyfileConversionManager() *skyfileConversionManager {
+	return &skyfileConversionManager{
+		conversions: make(map[string]*skyfileConversion),
+	}
+}
+
+// callConversion returns the conversion with the given ID.
+func (scm *skyfileConversionManager) callConversion(id string) (*skyfileConversion, bool) {
+	scm.mu.Lock()
+	defer scm.mu.Unlock()
+	sc, exists := scm.conversions[id]
+	return sc, exists
+}
+
+// callNewConversion creates a new conversion and adds it to the manager. Old
+// finished conversions are pruned in the process.
+func (scm *skyfileConversionManager) callNewConversion(siaPath skymodules.SiaPath, totalChunks uint64, cancel context.CancelFunc) *skyfileConversion {
+	sc := &skyfileConversion{
+		staticCancel:      cancel,
+		staticID:          hex.EncodeToString(fastrand.Bytes(16)),
+		staticSiaPath:     siaPath,
+		staticTotalChunks: totalChunks,
+	}
+
+	scm.mu.Lock()
+	defer scm.mu.Unlock()
+	for id, conversion := range scm.conversions {
+		if conversion.callFinishedBefore(time.Now().Add(-skyfileConversionPruneThreshold)) {
+			delete(scm.conversions, id)
+		}
+	}
+	scm.conversions[sc.staticID] = sc
+	return sc
+}
+
+// callCancel requests the cancellation of the conversion.
+func (sc *skyfileConversion) callCancel() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.completed || sc.cancelled || sc.err != nil {
+		return ErrSkyfileConversionFinished
+	}
+	sc.cancelRequested = true
+	sc.staticCancel()
+	return nil
+}
+
+// callFinish marks the conversion as finished. It returns 'true' if the
+// conversion was cancelled.
+func (sc *skyfileConversion) callFinish(skylink skymodules.Skylink, err error) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.finishTime = time.Now()
+	if err != nil && sc.cancelRequested {
+		sc.cancelled = true
+		sc.err = ErrSkyfileConversionCancelled
+		return true
+	}
+	if err != nil {
+		sc.err = err
+		return false
+	}
+	sc.completed = true
+	sc.skylink = skylink
+	return false
+}
+
+// callFinishedBefore returns whether the conversion finished before the
+// given time.
+func (sc *skyfileConversion) callFinishedBefore(t time.Time) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return !sc.finishTime.IsZero() && sc.finishTime.Before(t)
+}
+
+// callSetProgress updates the number of converted chunks.
+func (sc *skyfileConversion) callSetProgress(chunksConverted uint64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.chunksConverted = chunksConverted
+}
+
+// callStatus returns the status of the conversion.
+func (sc *skyfileConversion) callStatus() skymodules.SkyfileConversionStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	status := skymodules.SkyfileConversionStatus{
+		ID:              sc.staticID,
+		SiaPath:         sc.staticSiaPath,
+		ChunksConverted: sc.chunksConverted,
+		TotalChunks:     sc.staticTotalChunks,
+		Completed:       sc.completed,
+		Cancelled:       sc.cancelled,
+	}
+	if sc.completed {
+		status.Skylink = sc.skylink.String()
+	}
+	if sc.err != nil {
+		status.Error = sc.err.Error()
+	}
+	return status
+}
+
+// CancelSkyfileConversion cancels the asynchronous conversion with the given
+// ID. The partially created skyfile is removed once the conversion stopped.
+func (r *Renter) CancelSkyfileConversion(id string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	sc, exists := r.staticSkyfileConversionManager.callConversion(id)
+	if !exists {
+		return ErrSkyfileConversionNotFound
+	}
+	return sc.callCancel()
+}
+
+// CreateSkylinkFromSiafileAsync starts converting the siafile at the provided
+// siaPath to a skyfile in the background. It returns the ID of the conversion
+// which can be used to track its progress.
+func (r *Renter) CreateSkylinkFromSiafileAsync(sup skymodules.SkyfileUploadParameters, siaPath skymodules.SiaPath) (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", err
+	}
+	defer r.tg.Done()
+
+	// Grab the filenode for the provided siapath.
+	fileNode, err := r.managedOpenSiafileForConversion(&sup, siaPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Register the conversion and launch it.
+	ctx, cancel := context.WithCancel(r.tg.StopCtx())
+	sc := r.staticSkyfileConversionManager.callNewConversion(siaPath, fileNode.NumChunks(), cancel)
+	go r.threadedConvertSiafile(ctx, sc, sup, fileNode)
+	return sc.staticID, nil
+}
+
+// SkyfileConversionStatus returns the status of the asynchronous conversion
+// with the given ID.
+func (r *Renter) SkyfileConversionStatus(id string) (skymodules.SkyfileConversionStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkyfileConversionStatus{}, err
+	}
+	defer r.tg.Done()
+	sc, exists := r.staticSkyfileConversionManager.callConversion(id)
+	if !exists {
+		return skymodules.SkyfileConversionStatus{}, ErrSkyfileConversionNotFound
+	}
+	return sc.callStatus(), nil
+}
+
+// threadedConvertSiafile performs the conversion of a siafile to a skyfile and
+// updates the state of the conversion while doing so. If the conversion is
+// cancelled, the partially created skyfile is deleted.
+func (r *Renter) threadedConvertSiafile(ctx context.Context, sc *skyfileConversion, sup skymodules.SkyfileUploadParameters, fileNode *filesystem.FileNode) {
+	// Release the context and close the file node when done.
+	defer sc.staticCancel()
+	defer func() {
+		if err := fileNode.Close(); err != nil {
+			r.staticLog.Printf("failed to close siafile %v after conversion: %v", sc.staticSiaPath, err)
+		}
+	}()
+
+	if err := r.tg.Add(); err != nil {
+		sc.callFinish(skymodules.Skylink{}, err)
+		return
+	}
+	defer r.tg.Done()
+
+	chunkDone := func(chunksConverted uint64) {
+		sc.callSetProgress(chunksConverted)
+
+		// Block the conversion for as long as the dependency is enabled or
+		// until the conversion is interrupted.
+		for r.staticDeps.Disrupt("BlockSkyfileConversion") {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	// Remember the siafiles which already exist at the target siapaths. If
+	// the conversion is forced over an existing skyfile and cancelled before
+	// replacing it, the existing skyfile must not be deleted.
+	targets := []skymodules.SiaPath{sup.SiaPath}
+	extendedSiaPath, err := sup.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err == nil {
+		targets = append(targets, extendedSiaPath)
+	}
+	existing := make(map[skymodules.SiaPath]siafile.SiafileUID)
+	for _, target := range targets {
+		if uid, exists := r.managedSiafileUID(target); exists {
+			existing[target] = uid
+		}
+	}
+
+	skylink, err := r.managedCreateSkylinkFromSiafile(ctx, sup, sc.staticSiaPath, fileNode, chunkDone)
+	if !sc.callFinish(skylink, err) {
+		return
+	}
+
+	// The conversion was cancelled, remove the partial skyfile including the
+	// extended siafile. Only siafiles created by this conversion are removed.
+	for _, target := range targets {
+		uid, exists := r.managedSiafileUID(target)
+		if !exists {
+			continue
+		}
+		if existingUID, existed := existing[target]; existed && existingUID == uid {
+			continue
+		}
+		if err := r.DeleteFile(target); err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			r.staticLog.Printf("error deleting %v after cancelled conversion: %v", target, err)
+		}
+	}
+}
+
+// managedSiafileUID returns the UID of the siafile at the given siapath and
+// whether it exists.
+func (r *Renter) managedSiafileUID(siaPath skymodules.SiaPath) (siafile.SiafileUID, bool) {
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return "", false
+	}
+	defer func() {
+		if err := entry.Close(); err != nil {
+			r.staticLog.Printf("failed to close siafile %v: %v", siaPath, err)
+		}
+	}()
+	return entry.UID(), true
+}
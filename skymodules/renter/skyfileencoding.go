@@ -0,0 +1,95 @@
+package renter
+
+import (
+	"context"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// SkylinkEncoding returns information about how efficiently the fanout of the
+// skyfile behind the given skylink is encoded. Only the base sector of the
+// skylink is downloaded.
+func (r *Renter) SkylinkEncoding(ctx context.Context, sl skymodules.Skylink, ppms types.Currency) (skymodules.SkylinkEncoding, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkylinkEncoding{}, err
+	}
+	defer r.tg.Done()
+
+	// Resolve the skylink if necessary.
+	sl, _, err := r.managedTryResolveSkylinkV2(ctx, sl, true)
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "failed to resolve skylink")
+	}
+
+	// Get the base sector.
+	offset, fetchSize, err := sl.OffsetAndFetchSize()
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "unable to parse offset and fetchsize from skylink")
+	}
+	baseSector, _, err := r.managedDownloadByRoot(ctx, sl.MerkleRoot(), offset, fetchSize, ppms)
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "unable to download base sector")
+	}
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		_, err = r.managedDecryptBaseSector(baseSector)
+		if err != nil {
+			return skymodules.SkylinkEncoding{}, errors.AddContext(err, "failed to decrypt base sector")
+		}
+	}
+
+	// Parse the layout and fanout.
+	layout, fanoutBytes, _, _, _, _, err := r.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "error parsing skyfile metadata")
+	}
+	return skylinkEncoding(layout, fanoutBytes)
+}
+
+// skylinkEncoding computes the encoding information of a skyfile from its
+// layout and fanout.
+func skylinkEncoding(layout skymodules.SkyfileLayout, fanoutBytes []byte) (skymodules.SkylinkEncoding, error) {
+	encoding := skymodules.SkylinkEncoding{
+		CipherType:   layout.CipherType.String(),
+		DataPieces:   layout.FanoutDataPieces,
+		ParityPieces: layout.FanoutParityPieces,
+		Filesize:     layout.Filesize,
+	}
+
+	// Small skyfiles are stored within the base sector and don't have a
+	// fanout.
+	if len(fanoutBytes) == 0 {
+		return encoding, nil
+	}
+	piecesPerChunk, _, numChunks, err := skymodules.DecodeFanout(layout, fanoutBytes)
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "error decoding fanout")
+	}
+	numPieces := uint64(layout.FanoutDataPieces) + uint64(layout.FanoutParityPieces)
+	encoding.NumChunks = numChunks
+	encoding.DedupOptimization = piecesPerChunk == 1 && numPieces > 1
+
+	// Zero chunks of sparse skyfiles are not stored on the network.
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		return skymodules.SkylinkEncoding{}, errors.AddContext(err, "error decoding fanout")
+	}
+	for _, chunk := range chunks {
+		if skymodules.IsZeroChunk(chunk) {
+			encoding.ZeroChunks++
+		}
+	}
+
+	// The theoretical size is the size of the file multiplied by the
+	// redundancy of the erasure coding. The actual size is the size of all
+	// the pieces that are stored on hosts, which includes the padding of the
+	// last chunk as well as the overhead of encryption.
+	encoding.TheoreticalSize = layout.Filesize * numPieces / uint64(layout.FanoutDataPieces)
+	encoding.ActualSize = (numChunks - encoding.ZeroChunks) * numPieces * modules.SectorSize
+	if layout.Filesize > 0 {
+		encoding.Overhead = float64(encoding.ActualSize) / float64(layout.Filesize)
+	}
+	return encoding, nil
+}
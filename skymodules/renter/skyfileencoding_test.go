@@ -0,0 +1,101 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestSkylinkEncoding is a unit test for skylinkEncoding.
+func TestSkylinkEncoding(t *testing.T) {
+	t.Parallel()
+
+	ss := modules.SectorSize
+	tests := []struct {
+		name         string
+		filesize     uint64
+		dataPieces   uint8
+		parityPieces uint8
+		cipherType   crypto.CipherType
+		fanoutRoots  uint64
+		numChunks    uint64
+		dedup        bool
+		theoretical  uint64
+		actual       uint64
+	}{
+		{"small", 100, 1, 9, crypto.TypePlain, 0, 0, false, 0, 0},
+		{"1-of-10", ss + 1, 1, 9, crypto.TypePlain, 2, 2, true, (ss + 1) * 10, 2 * 10 * ss},
+		{"1-of-1", ss, 1, 0, crypto.TypePlain, 1, 1, false, ss, ss},
+		{"1-of-10 encrypted", ss, 1, 9, crypto.TypeXChaCha20, 10, 1, false, ss * 10, 10 * ss},
+		{"10-of-30", 10 * ss, 10, 20, crypto.TypePlain, 30, 1, false, 30 * ss, 30 * ss},
+	}
+	for _, test := range tests {
+		layout := skymodules.SkyfileLayout{
+			Filesize:           test.filesize,
+			FanoutDataPieces:   test.dataPieces,
+			FanoutParityPieces: test.parityPieces,
+			CipherType:         test.cipherType,
+		}
+		fanout := fastrand.Bytes(int(test.fanoutRoots * crypto.HashSize))
+		encoding, err := skylinkEncoding(layout, fanout)
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if encoding.CipherType != test.cipherType.String() {
+			t.Fatalf("%v: wrong cipher type %v", test.name, encoding.CipherType)
+		}
+		if encoding.DataPieces != test.dataPieces || encoding.ParityPieces != test.parityPieces {
+			t.Fatalf("%v: wrong erasure coding %v-of-%v", test.name, encoding.DataPieces, encoding.ParityPieces)
+		}
+		if encoding.NumChunks != test.numChunks {
+			t.Fatalf("%v: expected %v chunks but got %v", test.name, test.numChunks, encoding.NumChunks)
+		}
+		if encoding.DedupOptimization != test.dedup {
+			t.Fatalf("%v: expected dedup %v but got %v", test.name, test.dedup, encoding.DedupOptimization)
+		}
+		if encoding.TheoreticalSize != test.theoretical {
+			t.Fatalf("%v: expected theoretical size %v but got %v", test.name, test.theoretical, encoding.TheoreticalSize)
+		}
+		if encoding.ActualSize != test.actual {
+			t.Fatalf("%v: expected actual size %v but got %v", test.name, test.actual, encoding.ActualSize)
+		}
+		expectedOverhead := float64(test.actual) / float64(test.filesize)
+		if encoding.Overhead != expectedOverhead {
+			t.Fatalf("%v: expected overhead %v but got %v", test.name, expectedOverhead, encoding.Overhead)
+		}
+	}
+
+	// A fanout that doesn't match the erasure coding is invalid.
+	layout := skymodules.SkyfileLayout{
+		Filesize:           ss,
+		FanoutDataPieces:   2,
+		FanoutParityPieces: 1,
+		CipherType:         crypto.TypePlain,
+	}
+	_, err := skylinkEncoding(layout, make([]byte, 2*crypto.HashSize))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Zero chunks of a sparse skyfile don't count towards the actual size.
+	layout = skymodules.SkyfileLayout{
+		Filesize:           3 * ss,
+		FanoutDataPieces:   1,
+		FanoutParityPieces: 9,
+		CipherType:         crypto.TypePlain,
+	}
+	fanout := append(fastrand.Bytes(crypto.HashSize), make([]byte, 2*crypto.HashSize)...)
+	encoding, err := skylinkEncoding(layout, fanout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding.NumChunks != 3 || encoding.ZeroChunks != 2 {
+		t.Fatalf("unexpected chunks %v %v", encoding.NumChunks, encoding.ZeroChunks)
+	}
+	if encoding.ActualSize != 10*ss {
+		t.Fatalf("unexpected actual size %v", encoding.ActualSize)
+	}
+}
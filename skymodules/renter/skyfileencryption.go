@@ -14,7 +14,15 @@ import (
 	"github.com/aead/chacha20/chacha"
 )
 
-var errNoSkykeyMatchesSkyfileEncryptionID = errors.New("Unable to find matching skykey for public ID encryption")
+var (
+	// ErrNoSkykeyMatchesSkyfileEncryptionID is returned when none of the
+	// renter's skykeys can be used to decrypt a skyfile.
+	ErrNoSkykeyMatchesSkyfileEncryptionID = errors.New("Unable to find matching skykey for public ID encryption")
+
+	// ErrSkykeyMismatch is returned when the skykey provided for a download
+	// can't be used to decrypt the skyfile.
+	ErrSkykeyMismatch = errors.New("provided skykey doesn't match the skyfile's encryption")
+)
 
 // DecryptBaseSector attempts to decrypt the baseSector. If it has the
 // necessary Skykey, it will decrypt the baseSector in-place. It returns the
@@ -28,6 +36,20 @@ func (r *Renter) DecryptBaseSector(baseSector []byte) (skykey.Skykey, error) {
 	return r.managedDecryptBaseSector(baseSector)
 }
 
+// decryptBaseSectorWithSkykey decrypts the baseSector in-place using the given
+// skykey instead of the ones known to the renter. It returns the file-specific
+// skykey to be used for decrypting the rest of the associated skyfile.
+func decryptBaseSectorWithSkykey(baseSector []byte, sk skykey.Skykey) (skykey.Skykey, error) {
+	matches, err := skymodules.SkykeyMatchesBaseSector(baseSector, sk)
+	if err != nil {
+		return skykey.Skykey{}, errors.AddContext(err, "unable to check if skykey matches base sector")
+	}
+	if !matches {
+		return skykey.Skykey{}, ErrSkykeyMismatch
+	}
+	return skymodules.DecryptBaseSector(baseSector, sk)
+}
+
 // managedCheckSkyfileEncryptionIDMatch tries to find a Skykey that can decrypt
 // the identifier and be used for decrypting the associated skyfile. It returns
 // an error if it is not found.
@@ -43,7 +65,7 @@ func (r *Renter) managedCheckSkyfileEncryptionIDMatch(encryptionIdentifier []byt
 			return sk, nil
 		}
 	}
-	return skykey.Skykey{}, errNoSkykeyMatchesSkyfileEncryptionID
+	return skykey.Skykey{}, ErrNoSkykeyMatchesSkyfileEncryptionID
 }
 
 // managedDecryptBaseSector attempts to decrypt the baseSector. If it has the
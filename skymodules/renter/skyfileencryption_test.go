@@ -395,4 +395,33 @@ func TestBaseSectorKeyID(t *testing.T) {
 		t.Log(expectedEncID, keyID2)
 		t.Fatal("Expected to find the skyfile encryption ID")
 	}
+
+	// The key IDs returned by EncryptedBaseSectorKeyID should match.
+	if id := skymodules.EncryptedBaseSectorKeyID(bsCopy); id != keyID {
+		t.Fatal("wrong key id", id, keyID)
+	}
+	if id := skymodules.EncryptedBaseSectorKeyID(bsCopy2); id != keyID2 {
+		t.Fatal("wrong key id", id, keyID2)
+	}
+
+	// Each base sector should only match the skykey it was encrypted with.
+	tests := []struct {
+		baseSector []byte
+		sk         skykey.Skykey
+		matches    bool
+	}{
+		{baseSector: bsCopy, sk: publicIDKey, matches: true},
+		{baseSector: bsCopy, sk: privateIDKey, matches: false},
+		{baseSector: bsCopy2, sk: publicIDKey, matches: false},
+		{baseSector: bsCopy2, sk: privateIDKey, matches: true},
+	}
+	for i, test := range tests {
+		matches, err := skymodules.SkykeyMatchesBaseSector(test.baseSector, test.sk)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if matches != test.matches {
+			t.Fatalf("%v: expected matches to be %v but was %v", i, test.matches, matches)
+		}
+	}
 }
@@ -0,0 +1,214 @@
+package renter
+
+import (
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errNoUploadHosts is returned if there are no hosts to estimate the
+	// cost of an upload from.
+	errNoUploadHosts = errors.New("no hosts to estimate the upload cost from, the renter doesn't have any contracts which are good for upload")
+
+	// errNoAllowancePeriod is returned if the cost of an upload is estimated
+	// without an allowance.
+	errNoAllowancePeriod = errors.New("the cost of an upload can't be estimated without an allowance")
+
+	// errNoPriceTables is returned if the cost of pinning a skylink is
+	// estimated without any workers that have a valid price table.
+	errNoPriceTables = errors.New("no workers with a valid price table to estimate the bandwidth cost from")
+)
+
+// EstimateSkyfileUploadCost estimates the cost of uploading a skyfile of the
+// given size with the redundancy specified by the upload parameters. The
+// estimate is based on the prices of the hosts the renter has contracts with
+// that are good for upload. Nothing is uploaded.
+func (r *Renter) EstimateSkyfileUploadCost(size uint64, sup skymodules.SkyfileUploadParameters) (skymodules.SkyfileUploadCostEstimate, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkyfileUploadCostEstimate{}, err
+	}
+	defer r.tg.Done()
+
+	// Determine the redundancy of the upload.
+	r.managedSkyfileEstablishDefaults(&sup)
+	dataPieces, parityPieces, err := r.managedFanoutPieces(sup)
+	if err != nil {
+		return skymodules.SkyfileUploadCostEstimate{}, err
+	}
+
+	// The storage is paid for one period of the allowance.
+	period := r.staticHostContractor.Allowance().Period
+	if period == 0 {
+		return skymodules.SkyfileUploadCostEstimate{}, errNoAllowancePeriod
+	}
+
+	// Collect the hosts of the contracts which are good for upload.
+	var hosts []skymodules.HostDBEntry
+	for _, c := range r.Contracts() {
+		u, ok := r.ContractUtility(c.HostPublicKey)
+		if !ok || !u.GoodForUpload {
+			continue
+		}
+		host, ok, err := r.staticHostDB.Host(c.HostPublicKey)
+		if !ok || err != nil {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return estimateSkyfileUploadCost(size, sup.BaseChunkRedundancy, dataPieces, parityPieces, period, hosts)
+}
+
+// estimateSkyfileUploadCost estimates the cost of uploading a skyfile of the
+// given size to the given hosts. The cost of uploading a single sector is the
+// average over all hosts.
+func estimateSkyfileUploadCost(size uint64, baseChunkRedundancy uint8, dataPieces, parityPieces int, period types.BlockHeight, hosts []skymodules.HostDBEntry) (skymodules.SkyfileUploadCostEstimate, error) {
+	if len(hosts) == 0 {
+		return skymodules.SkyfileUploadCostEstimate{}, errNoUploadHosts
+	}
+
+	// Count the sectors that need to be uploaded. The base sector is always
+	// uploaded. If the data doesn't fit into the base sector together with
+	// the layout, it is uploaded as the fanout of a large skyfile.
+	numSectors := uint64(baseChunkRedundancy)
+	if size > modules.SectorSize-skymodules.SkyfileLayoutSize {
+		chunkSize := modules.SectorSize * uint64(dataPieces)
+		numChunks := size / chunkSize
+		if size%chunkSize != 0 {
+			numChunks++
+		}
+		numSectors += numChunks * uint64(dataPieces+parityPieces)
+	}
+
+	// Compute the average cost of uploading a sector.
+	var storageCost, bandwidthCost, rpcCost types.Currency
+	for _, host := range hosts {
+		storageCost = storageCost.Add(host.StoragePrice.Mul64(modules.SectorSize).Mul64(uint64(period)))
+		bandwidthCost = bandwidthCost.Add(host.UploadBandwidthPrice.Mul64(modules.SectorSize))
+		rpcCost = rpcCost.Add(host.BaseRPCPrice.Add(host.SectorAccessPrice))
+	}
+	numHosts := uint64(len(hosts))
+	estimate := skymodules.SkyfileUploadCostEstimate{
+		NumSectors:          numSectors,
+		Period:              period,
+		StorageCost:         storageCost.Mul64(numSectors).Div64(numHosts),
+		UploadBandwidthCost: bandwidthCost.Mul64(numSectors).Div64(numHosts),
+		RPCCost:             rpcCost.Mul64(numSectors).Div64(numHosts),
+	}
+	estimate.TotalCost = estimate.StorageCost.Add(estimate.UploadBandwidthCost).Add(estimate.RPCCost)
+	return estimate, nil
+}
+
+// EstimateSkylinkPinCost estimates the cost of pinning the given skylink with
+// the base chunk redundancy specified by the upload parameters. Only the base
+// sector of the skylink is downloaded to determine its layout. The bandwidth
+// costs are based on the average prices of the workers' price tables.
+func (r *Renter) EstimateSkylinkPinCost(link skymodules.Skylink, sup skymodules.SkyfileUploadParameters, timeout time.Duration, pricePerMS types.Currency) (skymodules.SkylinkPinCostEstimate, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkylinkPinCostEstimate{}, err
+	}
+	defer r.tg.Done()
+
+	// Just like for pinning, version 2 skylinks are not supported.
+	if link.IsSkylinkV2() {
+		return skymodules.SkylinkPinCostEstimate{}, errors.New("can't pin version 2 skylink")
+	}
+
+	// Fetch the base sector.
+	baseSector, err := r.DownloadByRoot(r.tg.StopCtx(), link.MerkleRoot(), 0, modules.SectorSize, timeout, pricePerMS)
+	if err != nil {
+		return skymodules.SkylinkPinCostEstimate{}, errors.AddContext(err, "unable to fetch base sector of skylink")
+	}
+
+	// The layout of an encrypted base sector is encrypted as well, so the
+	// base sector needs to be decrypted to learn the size of the skyfile.
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		_, err = r.managedDecryptBaseSector(baseSector)
+		if err != nil {
+			return skymodules.SkylinkPinCostEstimate{}, errors.AddContext(err, "unable to decrypt base sector")
+		}
+	}
+	layout := skymodules.ParseSkyfileLayout(baseSector)
+
+	// Collect the average price table of the workers and count the contracts
+	// that are good for upload.
+	var numPriceTables, numUploadContracts uint64
+	var dlPrice, ulPrice types.Currency
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		if w.staticCache().staticContractUtility.GoodForUpload {
+			numUploadContracts++
+		}
+		wpt := w.staticPriceTable()
+		if !wpt.staticValid() {
+			continue
+		}
+		dlPrice = dlPrice.Add(wpt.staticPriceTable.DownloadBandwidthCost)
+		ulPrice = ulPrice.Add(wpt.staticPriceTable.UploadBandwidthCost)
+		numPriceTables++
+	}
+	if numPriceTables == 0 {
+		return skymodules.SkylinkPinCostEstimate{}, errNoPriceTables
+	}
+	dlPrice = dlPrice.Div64(numPriceTables)
+	ulPrice = ulPrice.Div64(numPriceTables)
+
+	r.managedSkyfileEstablishDefaults(&sup)
+	return estimateSkylinkPinCost(layout, sup.BaseChunkRedundancy, numUploadContracts, dlPrice, ulPrice), nil
+}
+
+// estimateSkylinkPinCost estimates the cost of pinning a skyfile with the
+// given layout. The prices are the per-byte bandwidth prices.
+func estimateSkylinkPinCost(layout skymodules.SkyfileLayout, baseChunkRedundancy uint8, numUploadContracts uint64, dlPrice, ulPrice types.Currency) skymodules.SkylinkPinCostEstimate {
+	// The base sector is fetched once. If the metadata and fanout don't fit
+	// into the base sector, they are stored in a base sector extension which
+	// is fetched and uploaded together with the base sector.
+	baseSectors := uint64(1)
+	extensionSize := layout.MetadataSize + layout.FanoutSize
+	maxSize := modules.SectorSize - skymodules.SkyfileLayoutSize
+	for extensionSize > maxSize {
+		numChunks := skymodules.NumChunks(crypto.TypePlain, extensionSize, 1)
+		baseSectors += numChunks
+		extensionSize = numChunks * crypto.HashSize
+	}
+	numFetchSectors := baseSectors
+	numUploadSectors := baseSectors * uint64(baseChunkRedundancy)
+	redundancy := float64(baseChunkRedundancy)
+	piecesPerChunk := uint64(baseChunkRedundancy)
+
+	// The fanout is fetched with the minimum number of pieces per chunk and
+	// re-uploaded with all pieces.
+	if layout.FanoutSize > 0 {
+		dataPieces := uint64(layout.FanoutDataPieces)
+		numPieces := dataPieces + uint64(layout.FanoutParityPieces)
+		numChunks := skymodules.NumChunks(layout.CipherType, layout.Filesize, dataPieces)
+		numFetchSectors += numChunks * dataPieces
+		numUploadSectors += numChunks * numPieces
+		redundancy = float64(numPieces) / float64(dataPieces)
+		if numPieces > piecesPerChunk {
+			piecesPerChunk = numPieces
+		}
+	}
+
+	// Every piece of a chunk is uploaded to a different host.
+	numContracts := piecesPerChunk
+	if numUploadContracts < numContracts {
+		numContracts = numUploadContracts
+	}
+
+	estimate := skymodules.SkylinkPinCostEstimate{
+		Filesize:              layout.Filesize,
+		Redundancy:            redundancy,
+		StorageSize:           numUploadSectors * modules.SectorSize,
+		NumFetchSectors:       numFetchSectors,
+		NumUploadSectors:      numUploadSectors,
+		NumContracts:          numContracts,
+		DownloadBandwidthCost: dlPrice.Mul64(numFetchSectors * modules.SectorSize),
+		UploadBandwidthCost:   ulPrice.Mul64(numUploadSectors * modules.SectorSize),
+	}
+	estimate.TotalBandwidthCost = estimate.DownloadBandwidthCost.Add(estimate.UploadBandwidthCost)
+	return estimate
+}
@@ -0,0 +1,154 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestEstimateSkyfileUploadCost is a unit test for estimateSkyfileUploadCost.
+func TestEstimateSkyfileUploadCost(t *testing.T) {
+	t.Parallel()
+
+	// Without hosts there is no estimate.
+	_, err := estimateSkyfileUploadCost(1, 1, 1, 1, 1, nil)
+	if !errors.Contains(err, errNoUploadHosts) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Create two hosts with different prices. On average a sector costs 150H
+	// to store per block, 15H to upload and 3H in rpc costs.
+	newHost := func(storage, bandwidth, rpc uint64) skymodules.HostDBEntry {
+		var host skymodules.HostDBEntry
+		host.StoragePrice = types.NewCurrency64(storage)
+		host.UploadBandwidthPrice = types.NewCurrency64(bandwidth)
+		host.BaseRPCPrice = types.NewCurrency64(rpc)
+		host.SectorAccessPrice = types.NewCurrency64(rpc)
+		return host
+	}
+	hosts := []skymodules.HostDBEntry{
+		newHost(100, 10, 1),
+		newHost(200, 20, 2),
+	}
+	ss := modules.SectorSize
+	period := types.BlockHeight(10)
+
+	// Use a base chunk redundancy of 2 for all tests.
+	tests := []struct {
+		name         string
+		size         uint64
+		dataPieces   int
+		parityPieces int
+		numSectors   uint64
+	}{
+		{"small", 100, 1, 2, 2},
+		{"max small", ss - skymodules.SkyfileLayoutSize, 1, 2, 2},
+		{"one chunk", ss, 1, 2, 2 + 3},
+		{"two chunks", ss*2 + 1, 2, 1, 2 + 6},
+		{"three chunks", ss*4 + 1, 2, 1, 2 + 9},
+	}
+	for _, test := range tests {
+		estimate, err := estimateSkyfileUploadCost(test.size, 2, test.dataPieces, test.parityPieces, period, hosts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if estimate.NumSectors != test.numSectors {
+			t.Fatalf("%v: expected %v sectors but got %v", test.name, test.numSectors, estimate.NumSectors)
+		}
+		if estimate.Period != period {
+			t.Fatal("wrong period", estimate.Period)
+		}
+		n := test.numSectors
+		expectedStorage := types.NewCurrency64(150).Mul64(ss).Mul64(uint64(period)).Mul64(n)
+		expectedBandwidth := types.NewCurrency64(15).Mul64(ss).Mul64(n)
+		expectedRPC := types.NewCurrency64(3).Mul64(n)
+		if !estimate.StorageCost.Equals(expectedStorage) {
+			t.Fatalf("%v: wrong storage cost %v != %v", test.name, estimate.StorageCost, expectedStorage)
+		}
+		if !estimate.UploadBandwidthCost.Equals(expectedBandwidth) {
+			t.Fatalf("%v: wrong bandwidth cost %v != %v", test.name, estimate.UploadBandwidthCost, expectedBandwidth)
+		}
+		if !estimate.RPCCost.Equals(expectedRPC) {
+			t.Fatalf("%v: wrong rpc cost %v != %v", test.name, estimate.RPCCost, expectedRPC)
+		}
+		if !estimate.TotalCost.Equals(expectedStorage.Add(expectedBandwidth).Add(expectedRPC)) {
+			t.Fatalf("%v: wrong total cost %v", test.name, estimate.TotalCost)
+		}
+	}
+}
+
+// TestEstimateSkylinkPinCost is a unit test for estimateSkylinkPinCost.
+func TestEstimateSkylinkPinCost(t *testing.T) {
+	t.Parallel()
+
+	ss := modules.SectorSize
+	dlPrice := types.NewCurrency64(2)
+	ulPrice := types.NewCurrency64(3)
+
+	// newLayout creates a layout for a skyfile of the given size and
+	// redundancy.
+	newLayout := func(size, metadataSize uint64, dataPieces, parityPieces int, ct crypto.CipherType) skymodules.SkyfileLayout {
+		if size+metadataSize <= ss-skymodules.SkyfileLayoutSize {
+			return skymodules.NewSkyfileLayoutNoFanout(size, metadataSize, ct)
+		}
+		ec, err := skymodules.NewRSSubCode(dataPieces, parityPieces, crypto.SegmentSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numChunks := skymodules.NumChunks(ct, size, uint64(dataPieces))
+		piecesPerChunk := uint64(dataPieces + parityPieces)
+		if dataPieces == 1 && ct == crypto.TypePlain {
+			piecesPerChunk = 1
+		}
+		return skymodules.NewSkyfileLayout(size, metadataSize, numChunks*piecesPerChunk*crypto.HashSize, ec, ct)
+	}
+
+	// Use a base chunk redundancy of 2 and 5 contracts for all tests.
+	tests := []struct {
+		name         string
+		layout       skymodules.SkyfileLayout
+		fetch        uint64
+		upload       uint64
+		numContracts uint64
+		redundancy   float64
+	}{
+		{"small", newLayout(100, 100, 1, 2, crypto.TypePlain), 1, 2, 2, 2},
+		{"one of n", newLayout(ss*3, 100, 1, 9, crypto.TypePlain), 1 + 3, 2 + 30, 5, 10},
+		{"three chunks", newLayout(ss*4+1, 100, 2, 1, crypto.TypePlain), 1 + 6, 2 + 9, 3, 1.5},
+		{"encrypted", newLayout(ss*2, 100, 1, 2, crypto.TypeThreefish), 1 + 2, 2 + 6, 3, 3},
+		{"extension", newLayout(ss*2, 2*ss, 1, 2, crypto.TypePlain), 1 + 3 + 2, 2 + 6 + 6, 3, 3},
+	}
+	for _, test := range tests {
+		estimate := estimateSkylinkPinCost(test.layout, 2, 5, dlPrice, ulPrice)
+		if estimate.Filesize != test.layout.Filesize {
+			t.Fatalf("%v: wrong filesize %v", test.name, estimate.Filesize)
+		}
+		if estimate.NumFetchSectors != test.fetch {
+			t.Fatalf("%v: expected %v fetched sectors but got %v", test.name, test.fetch, estimate.NumFetchSectors)
+		}
+		if estimate.NumUploadSectors != test.upload {
+			t.Fatalf("%v: expected %v uploaded sectors but got %v", test.name, test.upload, estimate.NumUploadSectors)
+		}
+		if estimate.StorageSize != test.upload*ss {
+			t.Fatalf("%v: wrong storage size %v", test.name, estimate.StorageSize)
+		}
+		if estimate.NumContracts != test.numContracts {
+			t.Fatalf("%v: expected %v contracts but got %v", test.name, test.numContracts, estimate.NumContracts)
+		}
+		if estimate.Redundancy != test.redundancy {
+			t.Fatalf("%v: expected redundancy %v but got %v", test.name, test.redundancy, estimate.Redundancy)
+		}
+		expectedDL := dlPrice.Mul64(test.fetch * ss)
+		expectedUL := ulPrice.Mul64(test.upload * ss)
+		if !estimate.DownloadBandwidthCost.Equals(expectedDL) || !estimate.UploadBandwidthCost.Equals(expectedUL) {
+			t.Fatalf("%v: wrong bandwidth cost %v %v", test.name, estimate.DownloadBandwidthCost, estimate.UploadBandwidthCost)
+		}
+		if !estimate.TotalBandwidthCost.Equals(expectedDL.Add(expectedUL)) {
+			t.Fatalf("%v: wrong total bandwidth cost %v", test.name, estimate.TotalBandwidthCost)
+		}
+	}
+}
@@ -10,6 +10,7 @@ package renter
 // appended immediately after, and so on.
 
 import (
+	"context"
 	"fmt"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -25,7 +26,11 @@ import (
 // piece 1 of chunk 0 is second, etc. This method assumes the  special case for
 // unencrypted 1-of-N files. Because every piece is identical for an unencrypted
 // 1-of-N file, only the first piece of each chunk is included.
-func skyfileEncodeFanoutFromFileNode(fileNode *filesystem.FileNode, onePiece bool) ([]byte, error) {
+//
+// The context is checked before every chunk which allows for interrupting the
+// encoding of large files. If 'chunkDone' is not nil, it is called with the
+// number of encoded chunks after every chunk.
+func skyfileEncodeFanoutFromFileNode(ctx context.Context, fileNode *filesystem.FileNode, onePiece bool, chunkDone func(uint64)) ([]byte, error) {
 	// Allocate the memory for the fanout.
 	fanout := make([]byte, 0, fileNode.NumChunks()*crypto.HashSize)
 
@@ -44,6 +49,13 @@ func skyfileEncodeFanoutFromFileNode(fileNode *filesystem.FileNode, onePiece boo
 
 	// Build the fanout one chunk at a time.
 	for i := uint64(0); i < fileNode.NumChunks(); i++ {
+		// Check for interruption.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
 		// Get the pieces for this chunk.
 		allPieces, err := fileNode.Pieces(i)
 		if err != nil {
@@ -69,18 +81,22 @@ func skyfileEncodeFanoutFromFileNode(fileNode *filesystem.FileNode, onePiece boo
 				build.Critical(err)
 				return nil, err
 			}
-			continue
+		} else {
+			// Generate all the piece roots
+			for pi, pieceSet := range allPieces {
+				root := findPieceInPieceSet(pieceSet)
+				if root == emptyHash {
+					err = fmt.Errorf("Empty piece root at index %v found for chunk %v", pi, i)
+					build.Critical(err)
+					return nil, err
+				}
+				fanout = append(fanout, root[:]...)
+			}
 		}
 
-		// Generate all the piece roots
-		for pi, pieceSet := range allPieces {
-			root := findPieceInPieceSet(pieceSet)
-			if root == emptyHash {
-				err = fmt.Errorf("Empty piece root at index %v found for chunk %v", pi, i)
-				build.Critical(err)
-				return nil, err
-			}
-			fanout = append(fanout, root[:]...)
+		// Report the progress.
+		if chunkDone != nil {
+			chunkDone(i + 1)
 		}
 	}
 	return fanout, nil
@@ -0,0 +1,244 @@
+package renter
+
+// skyfilependingupload.go implements two-phase skyfile uploads. In the first
+// phase the data of the skyfile is uploaded the same way as the fanout of a
+// large skyfile. The renter then holds on to the extended siafile and the
+// fanout until the metadata is provided in the second phase, which creates and
+// uploads the base sector. Pending uploads only live in memory. If they are not
+// finalized in time or the renter shuts down, their data is deleted.
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
+	"go.sia.tech/siad/crypto"
+)
+
+var (
+	// ErrSkyfilePendingUploadNotFound is returned if there is no pending
+	// upload for a given ID.
+	ErrSkyfilePendingUploadNotFound = errors.New("pending skyfile upload not found")
+)
+
+type (
+	// skyfilePendingUploadManager keeps track of all the skyfile uploads that
+	// are waiting for their metadata.
+	skyfilePendingUploadManager struct {
+		uploads map[string]*skyfilePendingUpload
+		mu      sync.Mutex
+	}
+
+	// skyfilePendingUpload is an upload which has its data uploaded but is
+	// still waiting for its metadata.
+	skyfilePendingUpload struct {
+		staticCreated  time.Time
+		staticFanout   []byte
+		staticFileNode *filesystem.FileNode
+		staticID       string
+		staticSup      skymodules.SkyfileUploadParameters
+	}
+)
+
+// newSkyfilePendingUploadManager returns a newly initialized
+// skyfilePendingUploadManager.
+func newSkyfilePendingUploadManager() *skyfilePendingUploadManager {
+	return &skyfilePendingUploadManager{
+		uploads: make(map[string]*skyfilePendingUpload),
+	}
+}
+
+// callAdd adds a pending upload to the manager and returns its ID. Any uploads
+// which have been pending for longer than skyfilePendingUploadTimeout are
+// removed from the manager and returned to be deleted by the caller.
+func (spum *skyfilePendingUploadManager) callAdd(sup skymodules.SkyfileUploadParameters, fileNode *filesystem.FileNode, fanout []byte) (string, []*skyfilePendingUpload) {
+	spu := &skyfilePendingUpload{
+		staticCreated:  time.Now(),
+		staticFanout:   fanout,
+		staticFileNode: fileNode,
+		staticID:       hex.EncodeToString(fastrand.Bytes(16)),
+		staticSup:      sup,
+	}
+
+	spum.mu.Lock()
+	defer spum.mu.Unlock()
+	var expired []*skyfilePendingUpload
+	for id, upload := range spum.uploads {
+		if time.Since(upload.staticCreated) > skyfilePendingUploadTimeout {
+			expired = append(expired, upload)
+			delete(spum.uploads, id)
+		}
+	}
+	spum.uploads[spu.staticID] = spu
+	return spu.staticID, expired
+}
+
+// callPendingUpload returns the pending upload with the given ID.
+func (spum *skyfilePendingUploadManager) callPendingUpload(id string) (*skyfilePendingUpload, bool) {
+	spum.mu.Lock()
+	defer spum.mu.Unlock()
+	spu, exists := spum.uploads[id]
+	return spu, exists
+}
+
+// callRemove removes the pending upload with the given ID from the manager
+// and returns it.
+func (spum *skyfilePendingUploadManager) callRemove(id string) (*skyfilePendingUpload, bool) {
+	spum.mu.Lock()
+	defer spum.mu.Unlock()
+	spu, exists := spum.uploads[id]
+	delete(spum.uploads, id)
+	return spu, exists
+}
+
+// callRemoveAll removes all pending uploads from the manager and returns them.
+func (spum *skyfilePendingUploadManager) callRemoveAll() []*skyfilePendingUpload {
+	spum.mu.Lock()
+	defer spum.mu.Unlock()
+	uploads := make([]*skyfilePendingUpload, 0, len(spum.uploads))
+	for id, upload := range spum.uploads {
+		uploads = append(uploads, upload)
+		delete(spum.uploads, id)
+	}
+	return uploads
+}
+
+// metadata returns the metadata of the pending upload's skyfile by combining
+// the provided metadata with the length of the uploaded data.
+func (spu *skyfilePendingUpload) metadata(md skymodules.SkyfileMetadata) (skymodules.SkyfileMetadata, error) {
+	md.Length = spu.staticFileNode.Size()
+	if md.Filename == "" {
+		md.Filename = spu.staticSup.SiaPath.Name()
+	}
+	if md.DefaultPath != "" {
+		defaultPath, err := skymodules.NormalizeDefaultPath(md.DefaultPath)
+		if err != nil {
+			return skymodules.SkyfileMetadata{}, errors.Compose(skymodules.ErrInvalidDefaultPath, err)
+		}
+		md.DefaultPath = defaultPath
+	}
+	return md, skymodules.ValidateSkyfileMetadata(md)
+}
+
+// BeginSkyfileUpload uploads the data of a skyfile without creating its base
+// sector. It returns the ID of the pending upload which needs to be passed to
+// FinalizeSkyfileUpload together with the metadata of the skyfile.
+func (r *Renter) BeginSkyfileUpload(ctx context.Context, sup skymodules.SkyfileUploadParameters, reader io.Reader) (_ string, err error) {
+	if err := r.tg.Add(); err != nil {
+		return "", err
+	}
+	defer r.tg.Done()
+
+	// Encryption is not supported for pending uploads.
+	if encryptionEnabled(&sup) {
+		return "", errors.AddContext(ErrEncryptionNotSupported, "unable to begin skyfile upload")
+	}
+	if sup.DryRun {
+		return "", errors.New("dry-run is not supported for pending skyfile uploads")
+	}
+	r.managedSkyfileEstablishDefaults(&sup)
+
+	// Reject the upload if the skynet storage cap is reached.
+	err = r.managedCheckSkynetStorageCap(0)
+	if err != nil {
+		return "", err
+	}
+
+	// Create the fileNode for the data.
+	fileNode, err := r.managedInitFanoutFileNode(sup)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, r.managedDeletePendingUpload(&skyfilePendingUpload{
+				staticFileNode: fileNode,
+				staticSup:      sup,
+			}))
+		}
+	}()
+
+	// Upload the data.
+	onlyOnePieceNeeded := fileNode.ErasureCode().MinPieces() == 1 && fileNode.MasterKey().Type() == crypto.TypePlain
+	cr := NewFanoutChunkReader(reader, fileNode.ErasureCode(), onlyOnePieceNeeded, fileNode.MasterKey())
+	_, err = r.callUploadStreamFromReaderWithFileNode(ctx, fileNode, cr, 0)
+	if err != nil {
+		return "", errors.AddContext(err, "failed to upload file")
+	}
+
+	// Register the pending upload and delete the expired ones.
+	id, expired := r.staticSkyfilePendingUploadManager.callAdd(sup, fileNode, cr.Fanout())
+	for _, spu := range expired {
+		if err := r.managedDeletePendingUpload(spu); err != nil {
+			r.staticLog.Printf("failed to delete expired pending upload %v: %v", spu.staticID, err)
+		}
+	}
+	return id, nil
+}
+
+// FinalizeSkyfileUpload creates the skyfile for the pending upload with the
+// given ID using the provided metadata. The length of the metadata is set to
+// the size of the uploaded data. If the metadata is invalid, the upload stays
+// pending and can be finalized again.
+func (r *Renter) FinalizeSkyfileUpload(ctx context.Context, id string, md skymodules.SkyfileMetadata) (skymodules.Skylink, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.Skylink{}, err
+	}
+	defer r.tg.Done()
+
+	// Validate the metadata before removing the pending upload.
+	spu, exists := r.staticSkyfilePendingUploadManager.callPendingUpload(id)
+	if !exists {
+		return skymodules.Skylink{}, ErrSkyfilePendingUploadNotFound
+	}
+	md, err := spu.metadata(md)
+	if err != nil {
+		return skymodules.Skylink{}, errors.Compose(ErrInvalidMetadata, err)
+	}
+
+	// Remove the pending upload. If it was removed in the meantime, it is
+	// being finalized by another call.
+	spu, exists = r.staticSkyfilePendingUploadManager.callRemove(id)
+	if !exists {
+		return skymodules.Skylink{}, ErrSkyfilePendingUploadNotFound
+	}
+
+	skylink, err := r.managedCreateSkylinkFromFileNode(ctx, spu.staticSup, md, spu.staticFileNode, spu.staticFanout, spu.staticFileNode.Size())
+	if err != nil {
+		err = errors.AddContext(err, "unable to create skylink from pending upload")
+		return skymodules.Skylink{}, errors.Compose(err, r.managedDeletePendingUpload(spu))
+	}
+	r.managedTrackSkyfileStorage(spu.staticSup.SiaPath)
+	return skylink, spu.staticFileNode.Close()
+}
+
+// managedDeletePendingUpload closes the fileNode of a pending upload and
+// deletes its data. The file is deleted from the filesystem directly since this
+// is also called while the renter is shutting down.
+func (r *Renter) managedDeletePendingUpload(spu *skyfilePendingUpload) error {
+	extendedSiaPath, err := spu.staticSup.SiaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		return errors.Compose(err, spu.staticFileNode.Close())
+	}
+	err = r.staticFileSystem.DeleteFile(extendedSiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		err = nil
+	}
+	return errors.Compose(err, spu.staticFileNode.Close())
+}
+
+// managedDeletePendingUploads deletes the data of all pending uploads. It is
+// called on shutdown since pending uploads don't survive a restart.
+func (r *Renter) managedDeletePendingUploads() error {
+	var errs []error
+	for _, spu := range r.staticSkyfilePendingUploadManager.callRemoveAll() {
+		errs = append(errs, r.managedDeletePendingUpload(spu))
+	}
+	return errors.Compose(errs...)
+}
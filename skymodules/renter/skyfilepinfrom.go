@@ -0,0 +1,215 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// fanoutFetchReader is an io.Reader that recovers the data of a skyfile chunk
+// by chunk from the pieces of its fanout. The pieces are fetched using a
+// SkynetSectorFetcher and verified against the fanout before they are used.
+type fanoutFetchReader struct {
+	buf       bytes.Buffer
+	nextChunk int
+	remaining uint64
+
+	staticChunks    [][]crypto.Hash
+	staticChunkSize uint64
+	staticCtx       context.Context
+	staticEC        skymodules.ErasureCoder
+	staticFanoutKey crypto.CipherKey
+	staticFetcher   skymodules.SkynetSectorFetcher
+}
+
+// newFanoutFetchReader creates a reader for the data of the skyfile with the
+// given layout and fanout.
+func newFanoutFetchReader(ctx context.Context, fetcher skymodules.SkynetSectorFetcher, layout skymodules.SkyfileLayout, fanoutBytes []byte, fileSkykey skykey.Skykey) (*fanoutFetchReader, error) {
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to decode fanout")
+	}
+	ec, err := skymodules.NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create erasure coder")
+	}
+	fanoutKey, err := skymodules.DeriveFanoutKey(&layout, fileSkykey)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to derive fanout key")
+	}
+	return &fanoutFetchReader{
+		remaining: layout.Filesize,
+
+		staticChunks:    chunks,
+		staticChunkSize: skymodules.ChunkSize(layout.CipherType, uint64(layout.FanoutDataPieces)),
+		staticCtx:       ctx,
+		staticEC:        ec,
+		staticFanoutKey: fanoutKey,
+		staticFetcher:   fetcher,
+	}, nil
+}
+
+// Read implements io.Reader. The next chunk is only fetched once all the data
+// of the previous one was read.
+func (fr *fanoutFetchReader) Read(b []byte) (int, error) {
+	if fr.buf.Len() == 0 {
+		if fr.remaining == 0 {
+			return 0, io.EOF
+		}
+		if fr.nextChunk >= len(fr.staticChunks) {
+			return 0, fmt.Errorf("fanout is missing chunks for the remaining %v bytes", fr.remaining)
+		}
+		if err := fr.fetchNextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return fr.buf.Read(b)
+}
+
+// fetchNextChunk fetches the minimum number of pieces required to recover the
+// next chunk and writes the recovered data to the buffer. Pieces which can't
+// be fetched or don't match their merkle root are skipped.
+func (fr *fanoutFetchReader) fetchNextChunk() error {
+	chunkIndex := fr.nextChunk
+
+	// Zero chunks of sparse skyfiles don't need to be fetched.
+	n := fr.staticChunkSize
+	if n > fr.remaining {
+		n = fr.remaining
+	}
+	if skymodules.IsZeroChunk(fr.staticChunks[chunkIndex]) {
+		fr.buf.Write(make([]byte, n))
+		fr.remaining -= n
+		fr.nextChunk++
+		return nil
+	}
+
+	pieces := make([][]byte, fr.staticEC.NumPieces())
+	var fetched int
+	var errs error
+	for pieceIndex, root := range fr.staticChunks[chunkIndex] {
+		if fetched == fr.staticEC.MinPieces() {
+			break
+		}
+		if root == (crypto.Hash{}) {
+			continue
+		}
+		piece, err := fr.staticFetcher.Sector(fr.staticCtx, root)
+		if err != nil {
+			errs = errors.Compose(errs, errors.AddContext(err, fmt.Sprintf("failed to fetch piece %v of chunk %v", pieceIndex, chunkIndex)))
+			continue
+		}
+		if crypto.MerkleRoot(piece) != root {
+			errs = errors.Compose(errs, errors.AddContext(skymodules.ErrSkyfileVerificationFailed, fmt.Sprintf("piece %v of chunk %v doesn't match its root", pieceIndex, chunkIndex)))
+			continue
+		}
+		piece, err = fr.staticFanoutKey.Derive(uint64(chunkIndex), uint64(pieceIndex)).DecryptBytes(piece)
+		if err != nil {
+			return errors.AddContext(err, "failed to decrypt piece")
+		}
+		pieces[pieceIndex] = piece
+		fetched++
+	}
+	if fetched < fr.staticEC.MinPieces() {
+		return errors.Compose(fmt.Errorf("only fetched %v of the %v pieces required to recover chunk %v", fetched, fr.staticEC.MinPieces(), chunkIndex), errs)
+	}
+
+	// Recover the chunk's data. The last chunk is padded so only the
+	// remaining data is written.
+	err := fr.staticEC.Recover(pieces, n, &fr.buf)
+	if err != nil {
+		return errors.AddContext(err, fmt.Sprintf("failed to recover chunk %v", chunkIndex))
+	}
+	fr.remaining -= n
+	fr.nextChunk++
+	return nil
+}
+
+// PinSkylinkFrom pins a skylink using the base sector and fanout fetched with
+// the given fetcher instead of downloading them from the renter's hosts. The
+// base sector is verified against the skylink's merkle root and every piece of
+// the fanout against the fanout before anything is re-uploaded.
+func (r *Renter) PinSkylinkFrom(skylink skymodules.Skylink, lup skymodules.SkyfileUploadParameters, fetcher skymodules.SkynetSectorFetcher, timeout time.Duration) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Check if link is v2.
+	if skylink.IsSkylinkV2() {
+		return errors.New("can't pin version 2 skylink")
+	}
+	// Create a context.
+	ctx := r.tg.StopCtx()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Check if link is blocked
+	blocked, err := r.managedIsBlocked(ctx, skylink)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrSkylinkBlocked
+	}
+
+	// Check if link is approved
+	err = r.managedCheckAllowlist(crypto.HashObject(skylink.MerkleRoot()))
+	if err != nil {
+		return err
+	}
+
+	// Create a span.
+	span := opentracing.StartSpan("PinSkylinkFrom")
+	span.SetTag("skylink", skylink.String())
+	defer span.Finish()
+
+	// Attach the span to the ctx
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	// Fetch the base sector and pad it to a full sector. Base sectors are
+	// uploaded zero-padded so the result has to match the skylink's merkle
+	// root.
+	offset, fetchSize, err := skylink.OffsetAndFetchSize()
+	if err != nil {
+		return errors.AddContext(err, "unable to get offset and fetch size")
+	}
+	fetched, err := fetcher.BaseSector(ctx, skylink)
+	if err != nil {
+		return errors.AddContext(err, "unable to fetch base sector of skylink")
+	}
+	if uint64(len(fetched)) > fetchSize {
+		return fmt.Errorf("fetched base sector has size %v but the skylink's fetch size is %v", len(fetched), fetchSize)
+	}
+	baseSector := make([]byte, modules.SectorSize)
+	copy(baseSector[offset:], fetched)
+	if crypto.MerkleRoot(baseSector) != skylink.MerkleRoot() {
+		return errors.AddContext(skymodules.ErrSkyfileVerificationFailed, "base sector doesn't match the skylink's merkle root")
+	}
+
+	// The base sector extension can't be fetched from the fetcher, so only
+	// skyfiles with a fanout that fits into the base sector are supported.
+	parseFn := func(sector []byte) (skymodules.SkyfileLayout, []byte, skymodules.SkyfileMetadata, []byte, []byte, []byte, error) {
+		sl, fanoutBytes, sm, rawSM, baseSectorPayload, err := skymodules.ParseSkyfileMetadata(sector)
+		if errors.Contains(err, skymodules.ErrRecursiveBaseSector) {
+			err = errors.AddContext(err, "skyfiles with an extended fanout can't be pinned from a fetcher")
+		}
+		return sl, fanoutBytes, sm, rawSM, baseSectorPayload, nil, err
+	}
+	fanoutReader := func(layout skymodules.SkyfileLayout, fanoutBytes []byte, fileSkykey skykey.Skykey) (io.Reader, error) {
+		return newFanoutFetchReader(ctx, fetcher, layout, fanoutBytes, fileSkykey)
+	}
+	return r.managedPinBaseSector(ctx, skylink, lup, baseSector, false, parseFn, fanoutReader)
+}
@@ -0,0 +1,142 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// testSectorFetcher is a skymodules.SkynetSectorFetcher which serves sectors
+// from memory.
+type testSectorFetcher struct {
+	sectors map[crypto.Hash][]byte
+}
+
+// BaseSector implements skymodules.SkynetSectorFetcher.
+func (f *testSectorFetcher) BaseSector(ctx context.Context, link skymodules.Skylink) ([]byte, error) {
+	return f.Sector(ctx, link.MerkleRoot())
+}
+
+// Sector implements skymodules.SkynetSectorFetcher.
+func (f *testSectorFetcher) Sector(_ context.Context, root crypto.Hash) ([]byte, error) {
+	sector, ok := f.sectors[root]
+	if !ok {
+		return nil, errors.New("sector not found")
+	}
+	return append([]byte{}, sector...), nil
+}
+
+// TestFanoutFetchReader is a unit test for the fanoutFetchReader.
+func TestFanoutFetchReader(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	t.Run("OneOfN", func(t *testing.T) {
+		testFanoutFetchReader(t, 1, 2, crypto.TypePlain)
+	})
+	t.Run("Parity", func(t *testing.T) {
+		testFanoutFetchReader(t, 2, 1, crypto.TypePlain)
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		testFanoutFetchReader(t, 2, 1, crypto.TypeThreefish)
+	})
+}
+
+// testFanoutFetchReader tests reading a skyfile with the given erasure coding
+// and cipher type from a fanoutFetchReader.
+func testFanoutFetchReader(t *testing.T, dataPieces, parityPieces int, ct crypto.CipherType) {
+	ec, err := skymodules.NewRSSubCode(dataPieces, parityPieces, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := crypto.GenerateSiaKey(ct)
+
+	// Create a payload of one and a half chunks and upload its pieces to the
+	// fetcher.
+	chunkSize := skymodules.ChunkSize(ct, uint64(dataPieces))
+	payload := fastrand.Bytes(int(chunkSize + chunkSize/2))
+	onePiece := dataPieces == 1 && ct == crypto.TypePlain
+	fetcher := &testSectorFetcher{sectors: make(map[crypto.Hash][]byte)}
+	var fanout []byte
+	var roots []crypto.Hash
+	for chunkIndex := uint64(0); chunkIndex < 2; chunkIndex++ {
+		chunk := make([]byte, chunkSize)
+		copy(chunk, payload[chunkIndex*chunkSize:])
+		pieces, err := ec.Encode(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pieceIndex, piece := range pieces {
+			piece = append(piece, make([]byte, int(modules.SectorSize)-len(piece))...)
+			piece = key.Derive(chunkIndex, uint64(pieceIndex)).EncryptBytes(piece)
+			root := crypto.MerkleRoot(piece)
+			fetcher.sectors[root] = piece
+			fanout = append(fanout, root[:]...)
+			roots = append(roots, root)
+			if onePiece {
+				break
+			}
+		}
+	}
+	layout := skymodules.NewSkyfileLayout(uint64(len(payload)), 0, uint64(len(fanout)), ec, ct)
+	copy(layout.KeyData[:], key.Key())
+
+	// readAll is a helper to read the payload from a new reader.
+	readAll := func() ([]byte, error) {
+		fr, err := newFanoutFetchReader(context.Background(), fetcher, layout, fanout, skykey.Skykey{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ioutil.ReadAll(fr)
+	}
+
+	// The payload should be recovered.
+	data, err := readAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Fatal("recovered data doesn't match payload")
+	}
+
+	// Corrupt the first piece of the last chunk. If there are enough other
+	// pieces, the payload can still be recovered. Otherwise the verification
+	// fails.
+	corrupted := roots[len(roots)-1]
+	if !onePiece {
+		corrupted = roots[len(roots)-dataPieces-parityPieces]
+	}
+	fetcher.sectors[corrupted][0]++
+	data, err = readAll()
+	if !onePiece {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, payload) {
+			t.Fatal("recovered data doesn't match payload")
+		}
+	} else if !errors.Contains(err, skymodules.ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+
+	// Remove the last piece of the last chunk as well. The payload can't be
+	// recovered anymore.
+	if last := roots[len(roots)-1]; last != corrupted {
+		delete(fetcher.sectors, last)
+	}
+	_, err = readAll()
+	if err == nil || !strings.Contains(err.Error(), "pieces required to recover chunk 1") {
+		t.Fatal("expected recovery to fail", err)
+	}
+}
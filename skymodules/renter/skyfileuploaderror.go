@@ -0,0 +1,49 @@
+package renter
+
+import (
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// SkyfileUploadError is composed with the error of a failed skyfile upload if
+// the skylink of the upload was already computed when the upload failed.
+// Since skylinks are deterministic, retrying the upload with the same data and
+// parameters will result in the same skylink.
+type SkyfileUploadError struct {
+	Skylink skymodules.Skylink
+}
+
+// Error implements the error interface.
+func (err SkyfileUploadError) Error() string {
+	return fmt.Sprintf("upload failed after computing skylink %v", err.Skylink)
+}
+
+// newSkyfileUploadError composes err with a SkyfileUploadError for the given
+// skylink. If err is nil, nil is returned.
+func newSkyfileUploadError(err error, skylink skymodules.Skylink) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := SkylinkFromUploadError(err); ok {
+		return err
+	}
+	return errors.Compose(err, SkyfileUploadError{Skylink: skylink})
+}
+
+// SkylinkFromUploadError returns the skylink of a failed skyfile upload if the
+// error contains a SkyfileUploadError.
+func SkylinkFromUploadError(err error) (skymodules.Skylink, bool) {
+	switch e := err.(type) {
+	case SkyfileUploadError:
+		return e.Skylink, true
+	case errors.Error:
+		for _, err := range e.ErrSet {
+			if skylink, ok := SkylinkFromUploadError(err); ok {
+				return skylink, true
+			}
+		}
+	}
+	return skymodules.Skylink{}, false
+}
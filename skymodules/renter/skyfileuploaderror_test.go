@@ -0,0 +1,57 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkyfileUploadError is a unit test for newSkyfileUploadError and
+// SkylinkFromUploadError.
+func TestSkyfileUploadError(t *testing.T) {
+	t.Parallel()
+
+	var root crypto.Hash
+	fastrand.Read(root[:])
+	skylink, err := skymodules.NewSkylinkV1(root, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A nil error stays nil.
+	if err := newSkyfileUploadError(nil, skylink); err != nil {
+		t.Fatal("expected nil error", err)
+	}
+
+	// Regular errors don't carry a skylink.
+	errUpload := errors.New("upload failed")
+	if _, ok := SkylinkFromUploadError(errUpload); ok {
+		t.Fatal("regular error shouldn't contain a skylink")
+	}
+
+	// The skylink can be extracted after adding context and the original
+	// error is still contained.
+	err = errors.AddContext(newSkyfileUploadError(errUpload, skylink), "context")
+	sl, ok := SkylinkFromUploadError(err)
+	if !ok || sl != skylink {
+		t.Fatal("wrong skylink", sl, ok)
+	}
+	if !errors.Contains(err, errUpload) {
+		t.Fatal("original error should be contained")
+	}
+
+	// Wrapping an error twice keeps the first skylink.
+	var otherRoot crypto.Hash
+	fastrand.Read(otherRoot[:])
+	otherSkylink, err2 := skymodules.NewSkylinkV1(otherRoot, 0, 100)
+	if err2 != nil {
+		t.Fatal(err2)
+	}
+	sl, ok = SkylinkFromUploadError(newSkyfileUploadError(err, otherSkylink))
+	if !ok || sl != skylink {
+		t.Fatal("wrong skylink", sl, ok)
+	}
+}
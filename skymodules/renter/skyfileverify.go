@@ -0,0 +1,71 @@
+package renter
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// VerifySkyfile checks that the base sector of the skyfile matches the
+// skylink's merkle root and returns a verifier for the skyfile's payload. The
+// whole sector the base sector is stored in is downloaded to recompute its
+// merkle root. If a skykey is provided, it is used for decrypting the base
+// sector instead of the skykeys known to the renter.
+func (r *Renter) VerifySkyfile(ctx context.Context, link skymodules.Skylink, sk *skykey.Skykey, timeout time.Duration, pricePerMS types.Currency) (*skymodules.SkyfileVerifier, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	// Fetch the whole sector the base sector is stored in and make sure it
+	// matches the skylink's merkle root.
+	sector, err := r.DownloadByRoot(ctx, link.MerkleRoot(), 0, modules.SectorSize, timeout, pricePerMS)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to download base sector")
+	}
+	if crypto.MerkleRoot(sector) != link.MerkleRoot() {
+		return nil, errors.AddContext(skymodules.ErrSkyfileVerificationFailed, "base sector doesn't match the skylink's merkle root")
+	}
+
+	// Extract the base sector.
+	offset, fetchSize, err := link.OffsetAndFetchSize()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to get offset and fetch size")
+	}
+	baseSector := sector[offset : offset+fetchSize]
+
+	// Decrypt it if necessary.
+	var fileSkykey skykey.Skykey
+	if skymodules.IsEncryptedBaseSector(baseSector) {
+		if sk != nil {
+			fileSkykey, err = decryptBaseSectorWithSkykey(baseSector, *sk)
+		} else {
+			fileSkykey, err = r.managedDecryptBaseSector(baseSector)
+		}
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to decrypt base sector")
+		}
+	}
+
+	// Parse the base sector.
+	layout, fanoutBytes, _, _, baseSectorPayload, _, err := r.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to parse base sector")
+	}
+
+	// Derive the fanout key if there is a fanout to verify.
+	var fanoutKey crypto.CipherKey
+	if layout.FanoutSize > 0 {
+		fanoutKey, err = skymodules.DeriveFanoutKey(&layout, fileSkykey)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to derive fanout key")
+		}
+	}
+	return skymodules.NewSkyfileVerifier(layout, fanoutBytes, fanoutKey, baseSectorPayload)
+}
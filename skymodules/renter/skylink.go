@@ -14,6 +14,12 @@ import (
 
 // skylinkManager manages skylink requests
 type skylinkManager struct {
+	// accessTimes contains the most recent time a skylink was downloaded. It
+	// is a map of Skylink.String() to the time of the last download. The
+	// access times are periodically persisted in the metadata of the
+	// corresponding siafiles by the bubble code.
+	accessTimes map[string]time.Time
+
 	// pruneTimeThreshold is the time threshold for pruning unpin requests.
 	pruneTimeThreshold time.Time
 
@@ -28,10 +34,33 @@ type skylinkManager struct {
 // newSkylinkManager returns a newly initialized skylinkManager
 func newSkylinkManager() *skylinkManager {
 	return &skylinkManager{
+		accessTimes:   make(map[string]time.Time),
 		unpinRequests: make(map[string]time.Time),
 	}
 }
 
+// callLastAccess returns the most recent time any of the provided skylinks was
+// downloaded. If none of them were downloaded since the access times were last
+// pruned, the zero time is returned.
+func (sm *skylinkManager) callLastAccess(skylinks []string) time.Time {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	var lastAccess time.Time
+	for _, skylink := range skylinks {
+		if t, ok := sm.accessTimes[skylink]; ok && t.After(lastAccess) {
+			lastAccess = t
+		}
+	}
+	return lastAccess
+}
+
+// callRecordAccess records a download of the provided skylink.
+func (sm *skylinkManager) callRecordAccess(skylink skymodules.Skylink) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.accessTimes[skylink.String()] = time.Now()
+}
+
 // callIsUnpinned returns whether or not a FileNode has be requested to be
 // unpinned.
 func (sm *skylinkManager) callIsUnpinned(fn *filesystem.FileNode) bool {
@@ -49,6 +78,19 @@ func (sm *skylinkManager) callIsUnpinned(fn *filesystem.FileNode) bool {
 	return false
 }
 
+// callPruneAccessTimes will prune the skylinkManager's old access times.
+// Access times that are older than the pruneTimeThreshold have been seen by the
+// health loop of every file and were persisted if necessary.
+func (sm *skylinkManager) callPruneAccessTimes() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for sl, t := range sm.accessTimes {
+		if t.Before(sm.pruneTimeThreshold) {
+			delete(sm.accessTimes, sl)
+		}
+	}
+}
+
 // callPruneUnpinRequests will prune the skylinkManager's old unpinRequests
 func (sm *skylinkManager) callPruneUnpinRequests() {
 	sm.mu.Lock()
@@ -92,6 +134,22 @@ func (sm *skylinkManager) managedAddUnpinRequest(skylink skymodules.Skylink) {
 	sm.unpinRequests[skylinkStr] = time.Now().Add(TargetHealthCheckFrequency * 2)
 }
 
+// managedPersistAccessTime persists the most recent download time of the
+// skyfile's skylinks in the metadata of the siafile. To limit the number of
+// writes, the access time is only updated once it is more than
+// accessTimePersistInterval newer than the persisted one.
+func (r *Renter) managedPersistAccessTime(sf *filesystem.FileNode) error {
+	md := sf.Metadata()
+	if len(md.Skylinks) == 0 {
+		return nil
+	}
+	lastAccess := r.staticSkylinkManager.callLastAccess(md.Skylinks)
+	if lastAccess.Sub(md.AccessTime) < accessTimePersistInterval {
+		return nil
+	}
+	return sf.SetAccessTime(lastAccess)
+}
+
 // UnpinSkylink unpins a skylink from the renter by removing the underlying
 // siafile.
 //
@@ -165,6 +223,58 @@ func (r *Renter) managedIsBlocked(ctx context.Context, sl skymodules.Skylink) (b
 	return r.staticSkynetBlocklist.IsHashBlocked(hash), nil
 }
 
+// managedCheckAllowlist returns ErrSkylinkNotAllowed if the allowlist is
+// enforced and the given blocklist hash is not on it. The allowlist uses the
+// same hashes as the blocklist.
+func (r *Renter) managedCheckAllowlist(hash crypto.Hash) error {
+	if r.managedSkynetAllowlistEnforced() && !r.staticSkynetAllowlist.IsHashAllowed(hash) {
+		return ErrSkylinkNotAllowed
+	}
+	return nil
+}
+
+// managedSkynetAllowlistEnforced returns whether only skylinks on the
+// allowlist are served.
+func (r *Renter) managedSkynetAllowlistEnforced() bool {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.persist.SkynetAllowlistEnforced
+}
+
+// managedSkynetDegradedDownloads returns whether downloads may proceed with
+// fewer usable workers than pieces needed to recover the data.
+func (r *Renter) managedSkynetDegradedDownloads() bool {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.persist.SkynetDegradedDownloads
+}
+
+// CheckSkylinkAccess returns ErrSkylinkBlocked if the given V1 skylink is
+// blocked and ErrSkylinkNotAllowed if the allowlist is enforced and the skylink
+// isn't on it. It allows for serving a skylink without downloading it, which is
+// why the access is recorded like a download.
+func (r *Renter) CheckSkylinkAccess(ctx context.Context, link skymodules.Skylink) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	if !link.IsSkylinkV1() {
+		return ErrInvalidSkylinkVersion
+	}
+	hash := crypto.HashObject(link.MerkleRoot())
+	if r.staticSkynetBlocklist.IsHashBlocked(hash) {
+		r.staticLogBlocklistHit(ctx, link, hash)
+		return ErrSkylinkBlocked
+	}
+	err := r.managedCheckAllowlist(hash)
+	if err != nil {
+		return err
+	}
+	r.staticSkylinkManager.callRecordAccess(link)
+	return nil
+}
+
 // managedParseBlocklistHashes parses the input hash string slice and returns
 // the appropriate hash to be added to the blocklist.
 func (r *Renter) managedParseBlocklistHashes(ctx context.Context, hashStrs []string, isHash bool) ([]crypto.Hash, error) {
@@ -179,8 +289,7 @@ func (r *Renter) managedParseBlocklistHashes(ctx context.Context, hashStrs []str
 			}
 		} else {
 			// Convert Skylink
-			var skylink skymodules.Skylink
-			err := skylink.LoadString(paramStr)
+			skylink, err := skymodules.ParseSkylink(paramStr)
 			if err != nil {
 				return nil, errors.AddContext(err, "error parsing skylink")
 			}
@@ -0,0 +1,136 @@
+package renter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrChunkIndexOutOfBounds is returned when a chunk is requested that
+	// doesn't exist in the fanout of a skyfile.
+	ErrChunkIndexOutOfBounds = errors.New("chunk index out of bounds")
+)
+
+// DownloadSkylinkChunk downloads a single chunk of the fanout of the skyfile
+// behind the given skylink and returns its raw, decrypted bytes. Every piece
+// of the chunk is downloaded by its root, which makes it possible to figure
+// out which pieces of a chunk are missing from the network. If a skykey is
+// provided, it is used to decrypt the skyfile instead of the skykeys known to
+// the renter.
+func (r *Renter) DownloadSkylinkChunk(ctx context.Context, sl skymodules.Skylink, index uint64, sk *skykey.Skykey, ppms types.Currency) ([]byte, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	// Resolve the skylink if necessary.
+	sl, _, err := r.managedTryResolveSkylinkV2(ctx, sl, true)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to resolve skylink")
+	}
+
+	// Get the base sector.
+	offset, fetchSize, err := sl.OffsetAndFetchSize()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to parse offset and fetchsize from skylink")
+	}
+	baseSector, _, err := r.managedDownloadByRoot(ctx, sl.MerkleRoot(), offset, fetchSize, ppms)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to download base sector")
+	}
+
+	// Decrypt the base sector if necessary.
+	var fileSpecificSkykey skykey.Skykey
+	if skymodules.IsEncryptedBaseSector(baseSector) && sk != nil {
+		fileSpecificSkykey, err = decryptBaseSectorWithSkykey(baseSector, *sk)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to decrypt skyfile base sector with provided skykey")
+		}
+	} else if skymodules.IsEncryptedBaseSector(baseSector) {
+		fileSpecificSkykey, err = r.managedDecryptBaseSector(baseSector)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to decrypt skyfile base sector")
+		}
+	}
+
+	// Parse the layout and get the roots of the requested chunk.
+	layout, fanoutBytes, _, _, _, _, err := r.ParseSkyfileMetadata(baseSector)
+	if err != nil {
+		return nil, errors.AddContext(err, "error parsing skyfile metadata")
+	}
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		return nil, errors.AddContext(err, "error decoding fanout")
+	}
+	if index >= uint64(len(chunks)) {
+		return nil, errors.AddContext(ErrChunkIndexOutOfBounds, fmt.Sprintf("skyfile has %v chunks", len(chunks)))
+	}
+	roots := chunks[index]
+
+	// Zero chunks of sparse skyfiles are not stored on the network.
+	chunkSize := skymodules.ChunkSize(layout.CipherType, uint64(layout.FanoutDataPieces))
+	if skymodules.IsZeroChunk(roots) {
+		return make([]byte, chunkSize), nil
+	}
+
+	// Derive the fanout key and create the erasure coder.
+	fanoutKey, err := skymodules.DeriveFanoutKey(&layout, fileSpecificSkykey)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to derive encryption key")
+	}
+	ec, err := skymodules.NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to derive erasure coding settings for fanout")
+	}
+
+	// Download the pieces one by one until we have enough of them to recover
+	// the chunk.
+	pieces := make([][]byte, ec.NumPieces())
+	var piecesErr error
+	var downloaded int
+	for pieceIndex, root := range roots {
+		if downloaded == ec.MinPieces() {
+			break
+		}
+		piece, _, err := r.managedDownloadByRoot(ctx, root, 0, modules.SectorSize, ppms)
+		if err != nil {
+			piecesErr = errors.Compose(piecesErr, errors.AddContext(err, fmt.Sprintf("failed to download piece %v with root %v", pieceIndex, root)))
+			continue
+		}
+		key := fanoutKey.Derive(index, uint64(pieceIndex))
+		_, err = key.DecryptBytesInPlace(piece, 0)
+		if err != nil {
+			return nil, errors.AddContext(err, fmt.Sprintf("failed to decrypt piece %v", pieceIndex))
+		}
+		pieces[pieceIndex] = piece
+		downloaded++
+	}
+
+	// If the fanout only contains a single root per chunk, all pieces are
+	// identical.
+	if len(roots) == 1 && pieces[0] != nil {
+		for i := range pieces {
+			pieces[i] = pieces[0]
+		}
+		downloaded = len(pieces)
+	}
+	if downloaded < ec.MinPieces() {
+		return nil, errors.Compose(fmt.Errorf("only %v of the %v required pieces of chunk %v are available", downloaded, ec.MinPieces(), index), piecesErr)
+	}
+
+	// Recover the chunk.
+	buf := bytes.NewBuffer(make([]byte, 0, chunkSize))
+	err = ec.Recover(pieces, chunkSize, buf)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to recover chunk")
+	}
+	return buf.Bytes(), nil
+}
@@ -2,6 +2,7 @@ package renter
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/opentracing/opentracing-go"
 	"gitlab.com/SkynetLabs/skyd/build"
@@ -39,6 +40,10 @@ type (
 		// if there is no fanout. However if there's a fanout it will be nil.
 		staticBaseSectorPayload []byte
 
+		// staticBaseSectorHostStats contains the stats of the hosts which
+		// served the base sector.
+		staticBaseSectorHostStats hostStats
+
 		// staticChunkFetchers contains one pcws for every chunk in the fanout.
 		// The worker sets are spun up in advance so that the HasSector queries
 		// have completed by the time that someone needs to fetch the data.
@@ -63,6 +68,11 @@ func (sds *skylinkDataSource) DataSize() uint64 {
 	return sds.staticLayout.Filesize
 }
 
+// HostStats implements streamBufferDataSource
+func (sds *skylinkDataSource) HostStats() hostStats {
+	return sds.staticBaseSectorHostStats
+}
+
 // ID implements streamBufferDataSource
 func (sds *skylinkDataSource) ID() skymodules.DataSourceID {
 	return sds.staticID
@@ -101,6 +111,56 @@ func (sds *skylinkDataSource) SilentClose() {
 	sds.staticCancelFunc()
 }
 
+// UnrecoverableRanges implements streamBufferDataSource
+func (sds *skylinkDataSource) UnrecoverableRanges(ctx context.Context) ([]skymodules.SkynetMissingRange, error) {
+	// Small files are fully contained within the base sector which was
+	// already downloaded.
+	if len(sds.staticBaseSectorPayload) != 0 {
+		return nil, nil
+	}
+
+	var ranges []skymodules.SkynetMissingRange
+	chunkSize := skymodules.ChunkSize(sds.staticLayout.CipherType, uint64(sds.staticLayout.FanoutDataPieces))
+	for chunkIndex := range sds.staticChunkFetchers {
+		select {
+		case <-sds.staticChunksReady[chunkIndex]:
+		case <-ctx.Done():
+			return nil, errors.New("timeout while waiting for chunk fetchers to be ready")
+		case <-sds.staticRenter.tg.StopChan():
+			return nil, errors.New("aborted because of renter shutdown")
+		}
+
+		// A chunk is unrecoverable if its fetcher couldn't be created or if
+		// not enough pieces were found on the network.
+		recoverable := sds.staticChunkErrs[chunkIndex] == nil
+		if pcws, ok := sds.staticChunkFetchers[chunkIndex].(*projectChunkWorkerSet); ok && recoverable {
+			recoverable = pcws.managedRecoverable(ctx)
+		}
+		if recoverable {
+			continue
+		}
+
+		// Extend the previous range if the chunks are adjacent.
+		offset := uint64(chunkIndex) * chunkSize
+		length := chunkSize
+		if offset+length > sds.staticLayout.Filesize {
+			length = sds.staticLayout.Filesize - offset
+		}
+		if len(ranges) > 0 {
+			last := &ranges[len(ranges)-1]
+			if last.Offset+last.Length == offset {
+				last.Length += length
+				continue
+			}
+		}
+		ranges = append(ranges, skymodules.SkynetMissingRange{
+			Offset: offset,
+			Length: length,
+		})
+	}
+	return ranges, nil
+}
+
 // ReadStream implements streamBufferDataSource
 func (sds *skylinkDataSource) ReadStream(ctx context.Context, off, fetchSize uint64, pricePerMS types.Currency) chan *readResponse {
 	// Prepare the response channel
@@ -136,6 +196,7 @@ func (sds *skylinkDataSource) ReadStream(ctx context.Context, off, fetchSize uin
 		numChunks += 1
 	}
 	downloadChans := make([]chan *downloadResponse, 0, numChunks)
+	downloadSizes := make([]uint64, 0, numChunks)
 
 	// Otherwise we are dealing with a large skyfile and have to aggregate the
 	// download responses for every chunk in the fanout. We keep reading from
@@ -180,36 +241,46 @@ func (sds *skylinkDataSource) ReadStream(ctx context.Context, off, fetchSize uin
 			return responseChan
 		}
 		downloadChans = append(downloadChans, respChan)
+		downloadSizes = append(downloadSizes, downloadSize)
 
 		off += downloadSize
 		n += downloadSize
 	}
 
 	// Launch a goroutine that collects all download responses, aggregates them
-	// and sends it as a single response over the response channel.
+	// and sends it as a single response over the response channel. If any of
+	// the chunks fail, the errors of all failed chunks are reported.
+	firstChunkIndex := (off - n) / chunkSize
 	err := sds.staticRenter.tg.Launch(func() {
 		data := make([]byte, fetchSize)
 		offset := 0
-		failed := false
+		var errs []error
+		var degraded bool
+		hs := make(hostStats)
 
-		for _, respChan := range downloadChans {
+		for i, respChan := range downloadChans {
 			resp := <-respChan
-			if resp.err == nil {
-				n := copy(data[offset:], resp.data)
-				offset += n
+			if resp.err != nil {
+				chunkIndex := firstChunkIndex + uint64(i)
+				errs = append(errs, errors.AddContext(resp.err, fmt.Sprintf("failed to download chunk %v", chunkIndex)))
 				continue
 			}
-			if !failed {
-				failed = true
-				responseChan <- &readResponse{staticErr: resp.err}
-				close(responseChan)
-			}
+			n := copy(data[offset:], resp.data)
+			offset += n
+			hs.merge(newHostStats(resp.launchedWorkers, downloadSizes[i]))
+			degraded = degraded || resp.degraded
 		}
 
-		if !failed {
-			responseChan <- &readResponse{staticData: data}
-			close(responseChan)
+		if len(errs) > 0 {
+			responseChan <- &readResponse{staticErr: errors.Compose(errs...)}
+		} else {
+			responseChan <- &readResponse{
+				staticData:      data,
+				staticDegraded:  degraded,
+				staticHostStats: hs,
+			}
 		}
+		close(responseChan)
 	})
 	if err != nil {
 		responseChan <- &readResponse{staticErr: err}
@@ -219,6 +290,14 @@ func (sds *skylinkDataSource) ReadStream(ctx context.Context, off, fetchSize uin
 
 // managedDownloadByRoot will fetch data using the merkle root of that data.
 func (r *Renter) managedDownloadByRoot(ctx context.Context, root crypto.Hash, offset, length uint64, pricePerMS types.Currency) ([]byte, *pcwsWorkerState, error) {
+	data, ws, _, err := r.managedDownloadByRootWithLaunchedWorkers(ctx, root, offset, length, pricePerMS)
+	return data, ws, err
+}
+
+// managedDownloadByRootWithLaunchedWorkers will fetch data using the merkle
+// root of that data. On top of the data it returns the information about the
+// workers that were launched for the download.
+func (r *Renter) managedDownloadByRootWithLaunchedWorkers(ctx context.Context, root crypto.Hash, offset, length uint64, pricePerMS types.Currency) ([]byte, *pcwsWorkerState, []*launchedWorkerInfo, error) {
 	// Create a context that dies when the function ends, this will cancel all
 	// of the worker jobs that get created by this function.
 	ctx, cancel := context.WithCancel(ctx)
@@ -238,11 +317,11 @@ func (r *Renter) managedDownloadByRoot(ctx context.Context, root crypto.Hash, of
 	ptec := skymodules.NewPassthroughErasureCoder()
 	tpsk, err := crypto.NewSiaKey(crypto.TypePlain, nil)
 	if err != nil {
-		return nil, nil, errors.AddContext(err, "unable to create plain skykey")
+		return nil, nil, nil, errors.AddContext(err, "unable to create plain skykey")
 	}
 	pcws, err := r.newPCWSByRoots(ctx, []crypto.Hash{root}, ptec, tpsk, 0)
 	if err != nil {
-		return nil, nil, errors.AddContext(err, "unable to create the worker set for this skylink")
+		return nil, nil, nil, errors.AddContext(err, "unable to create the worker set for this skylink")
 	}
 
 	// Download the base sector. The base sector contains the metadata, without
@@ -250,20 +329,24 @@ func (r *Renter) managedDownloadByRoot(ctx context.Context, root crypto.Hash, of
 	//
 	// NOTE: we pass in the provided context here, if the user imposed a timeout
 	// on the download request, this will fire if it takes too long.
+	r.staticTracef(ctx, "downloading %v bytes at offset %v of root %v", length, offset, root)
 	respChan, err := pcws.managedDownload(ctx, pricePerMS, offset, length, false, false)
 	if err != nil {
-		return nil, nil, errors.AddContext(err, "unable to start download")
+		return nil, nil, nil, errors.AddContext(err, "unable to start download")
 	}
 	resp := <-respChan
+	for _, lw := range resp.launchedWorkers {
+		r.staticTracef(ctx, "root %v: %v", root, lw)
+	}
 	if resp.err != nil {
-		return nil, nil, errors.AddContext(resp.err, "base sector download did not succeed")
+		return nil, nil, nil, errors.AddContext(resp.err, "base sector download did not succeed")
 	}
 	baseSector := resp.data
 	if len(baseSector) < skymodules.SkyfileLayoutSize {
-		return nil, nil, errors.New("download did not fetch enough data, layout cannot be decoded")
+		return nil, nil, nil, errors.New("download did not fetch enough data, layout cannot be decoded")
 	}
 
-	return baseSector, pcws.managedWorkerState(), nil
+	return baseSector, pcws.managedWorkerState(), resp.launchedWorkers, nil
 }
 
 // managedSkylinkDataSource will create a streamBufferDataSource for the data
@@ -276,7 +359,12 @@ func (r *Renter) managedDownloadByRoot(ctx context.Context, root crypto.Hash, of
 // timeout. This can be optimized to always create the data source when it was
 // requested, but we should only do so after gathering some real world feedback
 // that indicates we would benefit from this.
-func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodules.Skylink, pricePerMS types.Currency) (streamBufferDataSource, error) {
+//
+// If a skykey is provided, it is used to decrypt the skyfile instead of the
+// skykeys known to the renter. The data source is then cached under an ID
+// derived from the skykey to make sure only callers with the same skykey can
+// access it.
+func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodules.Skylink, sk *skykey.Skykey, pricePerMS types.Currency) (streamBufferDataSource, error) {
 	// Get the offset and fetchsize from the skylink
 	offset, fetchSize, err := skylink.OffsetAndFetchSize()
 	if err != nil {
@@ -288,7 +376,7 @@ func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodule
 	//
 	// NOTE: we pass in the provided context here, if the user imposed a timeout
 	// on the download request, this will fire if it takes too long.
-	baseSector, _, err := r.managedDownloadByRoot(ctx, skylink.MerkleRoot(), offset, fetchSize, pricePerMS)
+	baseSector, _, launchedWorkers, err := r.managedDownloadByRootWithLaunchedWorkers(ctx, skylink.MerkleRoot(), offset, fetchSize, pricePerMS)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to download base sector")
 	}
@@ -296,7 +384,12 @@ func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodule
 	// Check if the base sector is encrypted, and attempt to decrypt it.
 	// This will fail if we don't have the decryption key.
 	var fileSpecificSkykey skykey.Skykey
-	if skymodules.IsEncryptedBaseSector(baseSector) {
+	if skymodules.IsEncryptedBaseSector(baseSector) && sk != nil {
+		fileSpecificSkykey, err = decryptBaseSectorWithSkykey(baseSector, *sk)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to decrypt skyfile base sector with provided skykey")
+		}
+	} else if skymodules.IsEncryptedBaseSector(baseSector) {
 		fileSpecificSkykey, err = r.managedDecryptBaseSector(baseSector)
 		if err != nil {
 			return nil, errors.AddContext(err, "unable to decrypt skyfile base sector")
@@ -387,6 +480,13 @@ func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodule
 		// channels as they are ready.
 		err = r.tg.Launch(func() {
 			for i, chunk := range fanoutChunks {
+				// Zero chunks of sparse skyfiles are not stored on
+				// the network.
+				if skymodules.IsZeroChunk(chunk) {
+					fanoutChunkFetchers[i] = zeroChunkFetcher{}
+					close(fanoutChunksReady[i])
+					continue
+				}
 				pcws, err := r.newPCWSByRoots(dsCtx, chunk, ec, fanoutKey, uint64(i))
 				fanoutChunkErrs[i] = err
 				fanoutChunkFetchers[i] = pcws
@@ -400,16 +500,18 @@ func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodule
 	}
 
 	sds := &skylinkDataSource{
-		staticID:          skylink.DataSourceID(),
+		staticID:          skylinkDataSourceID(skylink, sk),
 		staticLayout:      layout,
 		staticMetadata:    metadata,
 		staticRawMetadata: rawMetadata,
 		staticSkylink:     skylink,
 
 		staticBaseSectorPayload: baseSectorPayload,
-		staticChunkFetchers:     fanoutChunkFetchers,
-		staticChunksReady:       fanoutChunksReady,
-		staticChunkErrs:         fanoutChunkErrs,
+		// The base sector only contributes to the data of small files.
+		staticBaseSectorHostStats: newHostStats(launchedWorkers, uint64(len(baseSectorPayload))),
+		staticChunkFetchers:       fanoutChunkFetchers,
+		staticChunksReady:         fanoutChunksReady,
+		staticChunkErrs:           fanoutChunkErrs,
 
 		staticCtx:        dsCtx,
 		staticCancelFunc: cancelFunc,
@@ -417,3 +519,13 @@ func (r *Renter) managedSkylinkDataSource(ctx context.Context, skylink skymodule
 	}
 	return sds, nil
 }
+
+// skylinkDataSourceID returns the ID of the data source for a skylink. Data
+// sources which were created with a skykey provided by the caller use an ID
+// derived from that skykey.
+func skylinkDataSourceID(skylink skymodules.Skylink, sk *skykey.Skykey) skymodules.DataSourceID {
+	if sk == nil {
+		return skylink.DataSourceID()
+	}
+	return skymodules.DataSourceID(crypto.HashAll(skylink.DataSourceID(), sk.Type, sk.Entropy))
+}
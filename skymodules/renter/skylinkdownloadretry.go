@@ -0,0 +1,71 @@
+package renter
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// isTransientSkylinkDownloadError returns whether a skylink download failed
+// due to a temporarily degraded worker pool. Such downloads are likely to
+// succeed if they are retried shortly after.
+func isTransientSkylinkDownloadError(err error) bool {
+	return errors.Contains(err, errNotEnoughWorkers) ||
+		errors.Contains(err, skymodules.ErrNotEnoughWorkersInWorkerPool) ||
+		errors.Contains(err, errWorkerOnCooldown) ||
+		errors.Contains(err, errWorkerPriceTableInvalid)
+}
+
+// managedWorkerPoolDegraded returns whether any of the renter's workers is
+// temporarily unable to download because it is on cooldown or its price table
+// expired. An empty worker pool is not considered degraded since it won't
+// recover without contracts being formed.
+func (r *Renter) managedWorkerPoolDegraded() bool {
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		if w.managedOnMaintenanceCooldown() ||
+			!w.staticPriceTable().staticValid() ||
+			w.staticJobReadQueue.callOnCooldown() ||
+			w.staticJobHasSectorQueue.callOnCooldown() {
+			return true
+		}
+	}
+	return false
+}
+
+// managedRetrySkylinkDownload calls download until it succeeds, fails with an
+// error that isn't transient, ctx is done or it was retried
+// maxSkylinkDownloadRetries times. The time between two attempts grows
+// exponentially starting at skylinkDownloadRetryBaseBackoff and is jittered.
+// Not having enough workers is only considered transient while the worker
+// pool is degraded. Otherwise the data is simply not available or the renter
+// has no workers at all and the error is returned right away. Every retry is
+// counted in the retry counter attached to ctx.
+func (r *Renter) managedRetrySkylinkDownload(ctx context.Context, download func() error) error {
+	retries := skymodules.DownloadRetriesFromContext(ctx)
+	backoff := skylinkDownloadRetryBaseBackoff
+	for attempt := 0; ; attempt++ {
+		err := download()
+		if err == nil || attempt >= maxSkylinkDownloadRetries || !isTransientSkylinkDownloadError(err) {
+			return err
+		}
+		if !r.managedWorkerPoolDegraded() {
+			return err
+		}
+
+		// Wait for somewhere between half the backoff and the full backoff.
+		wait := backoff/2 + time.Duration(fastrand.Uint64n(uint64(backoff/2)+1))
+		r.staticTracef(ctx, "retrying download in %v after transient error: %v", wait, err)
+		select {
+		case <-ctx.Done():
+			return errors.Compose(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if retries != nil {
+			retries.Add()
+		}
+	}
+}
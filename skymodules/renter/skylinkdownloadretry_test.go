@@ -0,0 +1,112 @@
+package renter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// TestRetrySkylinkDownload probes managedRetrySkylinkDownload.
+func TestRetrySkylinkDownload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// download is a helper that fails with the given error until it was
+	// called the given number of times.
+	download := func(failures int, failErr error) (func() error, *int) {
+		var attempts int
+		return func() error {
+			attempts++
+			if attempts <= failures {
+				return failErr
+			}
+			return nil
+		}, &attempts
+	}
+	errTransient := errors.Compose(ErrRootNotFound, errNotEnoughWorkers)
+
+	// With a healthy worker pool, not having enough workers means the data
+	// isn't available. It shouldn't be retried.
+	dr := new(skymodules.DownloadRetries)
+	ctx := skymodules.ContextWithDownloadRetries(context.Background(), dr)
+	fn, attempts := download(1, errTransient)
+	err = r.managedRetrySkylinkDownload(ctx, fn)
+	if !errors.Contains(err, errNotEnoughWorkers) || *attempts != 1 || dr.Load() != 0 {
+		t.Fatal("unexpected result", err, *attempts, dr.Load())
+	}
+
+	// Put the worker on cooldown to degrade the worker pool.
+	wms := wt.worker.staticMaintenanceState
+	wms.mu.Lock()
+	wms.cooldownUntil = time.Now().Add(time.Hour)
+	wms.mu.Unlock()
+	defer func() {
+		wms.mu.Lock()
+		wms.cooldownUntil = time.Time{}
+		wms.mu.Unlock()
+	}()
+
+	// Non-transient errors are not retried.
+	fn, attempts = download(1, ErrRootNotFound)
+	err = r.managedRetrySkylinkDownload(ctx, fn)
+	if !errors.Contains(err, ErrRootNotFound) || *attempts != 1 || dr.Load() != 0 {
+		t.Fatal("unexpected result", err, *attempts, dr.Load())
+	}
+
+	// Transient errors are retried until the download succeeds.
+	fn, attempts = download(2, errWorkerOnCooldown)
+	err = r.managedRetrySkylinkDownload(ctx, fn)
+	if err != nil || *attempts != 3 || dr.Load() != 2 {
+		t.Fatal("unexpected result", err, *attempts, dr.Load())
+	}
+
+	// The number of retries is bounded.
+	dr = new(skymodules.DownloadRetries)
+	ctx = skymodules.ContextWithDownloadRetries(context.Background(), dr)
+	fn, attempts = download(maxSkylinkDownloadRetries+1, errTransient)
+	err = r.managedRetrySkylinkDownload(ctx, fn)
+	if !errors.Contains(err, errNotEnoughWorkers) || *attempts != maxSkylinkDownloadRetries+1 || dr.Load() != maxSkylinkDownloadRetries {
+		t.Fatal("unexpected result", err, *attempts, dr.Load())
+	}
+
+	// The retries respect the context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fn, attempts = download(1, errWorkerPriceTableInvalid)
+	err = r.managedRetrySkylinkDownload(ctx, fn)
+	if !errors.Contains(err, errWorkerPriceTableInvalid) || !errors.Contains(err, context.Canceled) || *attempts != 1 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+
+	// Without any workers, transient errors are not retried either.
+	rt, err := newRenterTester(t.Name() + "-noworkers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	fn, attempts = download(1, skymodules.ErrNotEnoughWorkersInWorkerPool)
+	err = rt.renter.managedRetrySkylinkDownload(context.Background(), fn)
+	if !errors.Contains(err, skymodules.ErrNotEnoughWorkersInWorkerPool) || *attempts != 1 {
+		t.Fatal("unexpected result", err, *attempts)
+	}
+}
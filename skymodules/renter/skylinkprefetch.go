@@ -0,0 +1,203 @@
+package renter
+
+// skylinkprefetch.go implements prefetching skylinks. A prefetch downloads the
+// base sector and optionally the fanout of a skylink in the background without
+// returning the data. The data is kept in the stream buffer set for a while
+// after the prefetch finished which allows for serving subsequent downloads of
+// the same skylink without having to fetch the data from the hosts again.
+
+import (
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrSkylinkPrefetchNotFound is returned if there is no prefetch for a
+	// given ID.
+	ErrSkylinkPrefetchNotFound = errors.New("skylink prefetch not found")
+
+	// ErrSkylinkPrefetchLimitReached is returned if a new prefetch is started
+	// while the maximum number of concurrent prefetches is already running.
+	ErrSkylinkPrefetchLimitReached = errors.New("too many concurrent skylink prefetches")
+)
+
+type (
+	// skylinkPrefetchManager keeps track of all the skylink prefetches.
+	skylinkPrefetchManager struct {
+		active     int
+		prefetches map[string]*skylinkPrefetch
+		mu         sync.Mutex
+	}
+
+	// skylinkPrefetch tracks the state of a single prefetch.
+	skylinkPrefetch struct {
+		staticFull    bool
+		staticID      string
+		staticSkylink skymodules.Skylink
+
+		completed  bool
+		err        error
+		finishTime time.Time
+		mu         sync.Mutex
+	}
+)
+
+// newSkylinkPrefetchManager returns a newly initialized
+// skylinkPrefetchManager.
+func newSkylinkPrefetchManager() *skylinkPrefetchManager {
+	return &skylinkPrefetchManager{
+		prefetches: make(map[string]*skylinkPrefetch),
+	}
+}
+
+// callPrefetch returns the prefetch with the given ID.
+func (spm *skylinkPrefetchManager) callPrefetch(id string) (*skylinkPrefetch, bool) {
+	spm.mu.Lock()
+	defer spm.mu.Unlock()
+	sp, exists := spm.prefetches[id]
+	return sp, exists
+}
+
+// callNewPrefetch creates a new prefetch and adds it to the manager. Old
+// finished prefetches are pruned in the process. An error is returned if the
+// maximum number of concurrent prefetches was reached.
+func (spm *skylinkPrefetchManager) callNewPrefetch(skylink skymodules.Skylink, full bool) (*skylinkPrefetch, error) {
+	sp := &skylinkPrefetch{
+		staticFull:    full,
+		staticID:      hex.EncodeToString(fastrand.Bytes(16)),
+		staticSkylink: skylink,
+	}
+
+	spm.mu.Lock()
+	defer spm.mu.Unlock()
+	if spm.active >= maxConcurrentSkylinkPrefetches {
+		return nil, ErrSkylinkPrefetchLimitReached
+	}
+	for id, prefetch := range spm.prefetches {
+		if prefetch.callFinishedBefore(time.Now().Add(-skylinkPrefetchPruneThreshold)) {
+			delete(spm.prefetches, id)
+		}
+	}
+	spm.active++
+	spm.prefetches[sp.staticID] = sp
+	return sp, nil
+}
+
+// callFinish marks the prefetch as finished and frees up its slot in the
+// manager.
+func (spm *skylinkPrefetchManager) callFinish(sp *skylinkPrefetch, err error) {
+	sp.mu.Lock()
+	sp.finishTime = time.Now()
+	sp.completed = err == nil
+	sp.err = err
+	sp.mu.Unlock()
+
+	spm.mu.Lock()
+	spm.active--
+	spm.mu.Unlock()
+}
+
+// callFinishedBefore returns whether the prefetch finished before the given
+// time.
+func (sp *skylinkPrefetch) callFinishedBefore(t time.Time) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return !sp.finishTime.IsZero() && sp.finishTime.Before(t)
+}
+
+// callStatus returns the status of the prefetch.
+func (sp *skylinkPrefetch) callStatus() skymodules.SkylinkPrefetchStatus {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	status := skymodules.SkylinkPrefetchStatus{
+		ID:        sp.staticID,
+		Skylink:   sp.staticSkylink.String(),
+		Full:      sp.staticFull,
+		Completed: sp.completed,
+	}
+	if sp.err != nil {
+		status.Error = sp.err.Error()
+	}
+	return status
+}
+
+// PrefetchSkylink starts fetching the base sector of the provided skylink in
+// the background. If 'full' is set, the fanout is fetched as well. It returns
+// the ID of the prefetch which can be used to track its progress.
+func (r *Renter) PrefetchSkylink(link skymodules.Skylink, full bool, timeout time.Duration, pricePerMS types.Currency) (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", err
+	}
+	defer r.tg.Done()
+
+	// Check if the skylink is blocked.
+	blocked, err := r.managedIsBlocked(r.tg.StopCtx(), link)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return "", ErrSkylinkBlocked
+	}
+
+	// Register the prefetch and launch it.
+	sp, err := r.staticSkylinkPrefetchManager.callNewPrefetch(link, full)
+	if err != nil {
+		return "", err
+	}
+	go r.threadedPrefetchSkylink(sp, timeout, pricePerMS)
+	return sp.staticID, nil
+}
+
+// SkylinkPrefetchStatus returns the status of the prefetch with the given ID.
+func (r *Renter) SkylinkPrefetchStatus(id string) (skymodules.SkylinkPrefetchStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkylinkPrefetchStatus{}, err
+	}
+	defer r.tg.Done()
+	sp, exists := r.staticSkylinkPrefetchManager.callPrefetch(id)
+	if !exists {
+		return skymodules.SkylinkPrefetchStatus{}, ErrSkylinkPrefetchNotFound
+	}
+	return sp.callStatus(), nil
+}
+
+// threadedPrefetchSkylink fetches the data of a skylink into the stream buffer
+// set. After the data was fetched, the stream is kept open for
+// skylinkPrefetchKeepDuration to prevent the stream buffer from being
+// released.
+func (r *Renter) threadedPrefetchSkylink(sp *skylinkPrefetch, timeout time.Duration, pricePerMS types.Currency) {
+	if err := r.tg.Add(); err != nil {
+		r.staticSkylinkPrefetchManager.callFinish(sp, err)
+		return
+	}
+	defer r.tg.Done()
+
+	// Fetching the streamer fetches the base sector.
+	streamer, _, err := r.DownloadSkylink(r.tg.StopCtx(), sp.staticSkylink, timeout, pricePerMS)
+	if err != nil {
+		r.staticSkylinkPrefetchManager.callFinish(sp, errors.AddContext(err, "failed to fetch base sector"))
+		return
+	}
+
+	// Read the whole file if requested. This causes the stream buffer to fetch
+	// the fanout. The stream buffer only keeps a limited amount of data per
+	// stream, so for large files only the tail of the file remains cached.
+	if sp.staticFull {
+		_, err = io.Copy(ioutil.Discard, streamer)
+	}
+	r.staticSkylinkPrefetchManager.callFinish(sp, errors.AddContext(err, "failed to fetch fanout"))
+
+	// Keep the stream open for a while.
+	r.tg.Sleep(skylinkPrefetchKeepDuration)
+	if err := streamer.Close(); err != nil {
+		r.staticLog.Printf("failed to close stream of prefetched skylink %v: %v", sp.staticSkylink, err)
+	}
+}
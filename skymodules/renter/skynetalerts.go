@@ -0,0 +1,122 @@
+package renter
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/skynetportals"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// skynetalerts.go contains the logic for registering and unregistering the
+// skynet specific alerts of the renter. All of the alerts are unregistered
+// automatically once the condition that caused them is resolved.
+
+var (
+	// registryWriteFailuresAlertThreshold is the number of consecutive
+	// registry updates that need to fail before an alert is registered.
+	registryWriteFailuresAlertThreshold = build.Select(build.Var{
+		Dev:      uint64(10),
+		Standard: uint64(25),
+		Testing:  uint64(3),
+	}).(uint64)
+
+	// DefaultSkynetUploadAlertThreshold is the default threshold for the p99
+	// of the base sector uploads at which an alert is registered. It is used
+	// if the threshold isn't set in the renter's settings.
+	DefaultSkynetUploadAlertThreshold = time.Minute
+)
+
+var (
+	// errSkynetPersistReadOnly is returned by the skynet list updates when
+	// the persistence is simulated to be read-only.
+	errSkynetPersistReadOnly = errors.New("skynet persist dir is read-only")
+)
+
+// callTrackRegistryWrite updates the number of consecutive failed registry
+// updates with the result of a registry update. Only failures caused by the
+// network are taken into account.
+func (r *Renter) callTrackRegistryWrite(err error) {
+	if err == nil {
+		atomic.StoreUint64(&r.atomicRegistryWriteFailures, 0)
+		r.staticAlerter.UnregisterAlert(AlertIDSkynetRegistryWriteFailures)
+		return
+	}
+	networkFailure := errors.Contains(err, ErrRegistryUpdateTimeout) ||
+		errors.Contains(err, ErrRegistryUpdateNoSuccessfulUpdates) ||
+		errors.Contains(err, ErrRegistryUpdateInsufficientRedundancy) ||
+		errors.Contains(err, skymodules.ErrNotEnoughWorkersInWorkerPool)
+	if !networkFailure {
+		return
+	}
+	failures := atomic.AddUint64(&r.atomicRegistryWriteFailures, 1)
+	if failures >= registryWriteFailuresAlertThreshold {
+		cause := fmt.Sprintf("%v consecutive registry updates failed, last error: %v", failures, err)
+		r.staticAlerter.RegisterAlert(AlertIDSkynetRegistryWriteFailures, AlertMSGSkynetRegistryWriteFailures, cause, modules.SeverityCritical)
+	}
+}
+
+// managedCheckUploadPerformance registers an alert if the p99 of the base
+// sector uploads within the last 15 minutes exceeds the configured threshold.
+func (r *Renter) managedCheckUploadPerformance() {
+	id := r.mu.RLock()
+	threshold := time.Duration(r.persist.SkynetUploadAlertThresholdMS) * time.Millisecond
+	r.mu.RUnlock(id)
+	if threshold == 0 {
+		threshold = DefaultSkynetUploadAlertThreshold
+	}
+	p99 := r.staticBaseSectorUploadStats.Distribution(0).PStat(0.99)
+	if p99 <= threshold {
+		r.staticAlerter.UnregisterAlert(AlertIDSkynetUploadPerformance)
+		return
+	}
+	cause := fmt.Sprintf("base sector upload p99 of %v exceeds threshold of %v", p99, threshold)
+	r.staticAlerter.RegisterAlert(AlertIDSkynetUploadPerformance, AlertMSGSkynetUploadPerformance, cause, modules.SeverityWarning)
+}
+
+// managedUpdateSkynetBlocklist updates the blocklist and registers an alert if
+// the update couldn't be persisted. In-flight uploads and pins of newly blocked
+// skylinks are cancelled.
+func (r *Renter) managedUpdateSkynetBlocklist(additions, removals []crypto.Hash) error {
+	var err error
+	if r.staticDeps.Disrupt("SkynetPersistReadOnly") {
+		err = errSkynetPersistReadOnly
+	} else {
+		err = r.staticSkynetBlocklist.UpdateBlocklist(additions, removals)
+	}
+	// All errors of the blocklist update are caused by the persistence.
+	if err != nil {
+		r.staticAlerter.RegisterAlert(AlertIDSkynetBlocklistPersist, AlertMSGSkynetBlocklistPersist, err.Error(), modules.SeverityCritical)
+		return err
+	}
+	r.staticAlerter.UnregisterAlert(AlertIDSkynetBlocklistPersist)
+	r.staticSkynetInFlightTracker.callCancel(additions)
+	return nil
+}
+
+// managedUpdateSkynetPortals updates the portals list and registers an alert
+// if the update couldn't be persisted.
+func (r *Renter) managedUpdateSkynetPortals(additions []skymodules.SkynetPortal, removals []modules.NetAddress) error {
+	var err error
+	if r.staticDeps.Disrupt("SkynetPersistReadOnly") {
+		err = errSkynetPersistReadOnly
+	} else {
+		err = r.staticSkynetPortals.UpdatePortals(additions, removals)
+	}
+	// Invalid updates are rejected before anything is persisted.
+	if err != nil && strings.Contains(err.Error(), skynetportals.ErrSkynetPortalsValidation.Error()) {
+		return err
+	}
+	if err != nil {
+		r.staticAlerter.RegisterAlert(AlertIDSkynetPortalsPersist, AlertMSGSkynetPortalsPersist, err.Error(), modules.SeverityCritical)
+		return err
+	}
+	r.staticAlerter.UnregisterAlert(AlertIDSkynetPortalsPersist)
+	return nil
+}
@@ -0,0 +1,54 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+// TestTrackRegistryWrite is a unit test for callTrackRegistryWrite.
+func TestTrackRegistryWrite(t *testing.T) {
+	t.Parallel()
+
+	r := &Renter{
+		staticAlerter: modules.NewAlerter("renter"),
+	}
+	// The alerter might contain test alerts.
+	crit, _, _ := r.staticAlerter.Alerts()
+	initialCrit := len(crit)
+	numCrit := func() int {
+		crit, _, _ := r.staticAlerter.Alerts()
+		return len(crit) - initialCrit
+	}
+
+	// Errors which aren't caused by the network are ignored.
+	for i := uint64(0); i < registryWriteFailuresAlertThreshold; i++ {
+		r.callTrackRegistryWrite(errors.New("invalid signature"))
+	}
+	if numCrit() != 0 {
+		t.Fatal("unexpected alert")
+	}
+
+	// Network failures register an alert once the threshold is reached.
+	for i := uint64(0); i < registryWriteFailuresAlertThreshold-1; i++ {
+		r.callTrackRegistryWrite(errors.AddContext(ErrRegistryUpdateTimeout, "failed"))
+	}
+	if numCrit() != 0 {
+		t.Fatal("unexpected alert")
+	}
+	r.callTrackRegistryWrite(ErrRegistryUpdateNoSuccessfulUpdates)
+	if numCrit() != 1 {
+		t.Fatal("expected alert")
+	}
+
+	// A successful update clears the alert and resets the counter.
+	r.callTrackRegistryWrite(nil)
+	if numCrit() != 0 {
+		t.Fatal("alert wasn't cleared")
+	}
+	r.callTrackRegistryWrite(ErrRegistryUpdateInsufficientRedundancy)
+	if numCrit() != 0 {
+		t.Fatal("counter wasn't reset")
+	}
+}
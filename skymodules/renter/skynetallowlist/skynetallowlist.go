@@ -0,0 +1,199 @@
+package skynetallowlist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// persistFile is the name of the persist file
+	persistFile string = "skynetallowlist.dat"
+
+	// persistSize is the size of a persisted merkleroot in the allowlist. It is
+	// the length of `merkleroot` plus the `listed` flag (32 + 1).
+	persistSize uint64 = 33
+)
+
+var (
+	// metadataHeader is the header of the metadata for the persist file
+	metadataHeader = types.NewSpecifier("SkynetAllowlist\n")
+
+	// metadataVersion is the version of the persistence file
+	metadataVersion = types.NewSpecifier("v1.5.7\n")
+)
+
+type (
+	// SkynetAllowlist manages a set of approved skylinks by tracking the
+	// hashes of their merkleroots and persists the list to disk.
+	SkynetAllowlist struct {
+		staticAop *persist.AppendOnlyPersist
+
+		// hashes is a set of hashed approved merkleroots.
+		hashes map[crypto.Hash]struct{}
+
+		mu sync.Mutex
+	}
+
+	// persistEntry contains a hash and whether it should be listed as being in
+	// the current allowlist.
+	persistEntry struct {
+		Hash   crypto.Hash
+		Listed bool
+	}
+)
+
+// New returns an initialized SkynetAllowlist.
+func New(persistDir string) (*SkynetAllowlist, error) {
+	// Initialize the persistence of the allowlist.
+	aop, reader, err := persist.NewAppendOnlyPersist(persistDir, persistFile, metadataHeader, metadataVersion)
+	if err != nil {
+		return nil, errors.AddContext(err, fmt.Sprintf("unable to initialize the skynet allowlist persistence at '%v'", aop.FilePath()))
+	}
+
+	sa := &SkynetAllowlist{
+		staticAop: aop,
+	}
+	hashes, err := unmarshalObjects(reader)
+	if err != nil {
+		err = errors.Compose(err, aop.Close())
+		return nil, errors.AddContext(err, "unable to unmarshal persist objects")
+	}
+	sa.hashes = hashes
+
+	return sa, nil
+}
+
+// Allowlist returns the hashes of the merkleroots that are approved
+func (sa *SkynetAllowlist) Allowlist() []crypto.Hash {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	var allowlist []crypto.Hash
+	for hash := range sa.hashes {
+		allowlist = append(allowlist, hash)
+	}
+	return allowlist
+}
+
+// Close closes and frees associated resources.
+func (sa *SkynetAllowlist) Close() error {
+	return sa.staticAop.Close()
+}
+
+// IsAllowed indicates if a skylink is currently approved
+func (sa *SkynetAllowlist) IsAllowed(skylink skymodules.Skylink) bool {
+	if !skylink.IsSkylinkV1() {
+		build.Critical("IsAllowed requires V1 skylink")
+		return false
+	}
+	hash := crypto.HashObject(skylink.MerkleRoot())
+	return sa.IsHashAllowed(hash)
+}
+
+// IsHashAllowed indicates if a hash is currently approved
+func (sa *SkynetAllowlist) IsHashAllowed(hash crypto.Hash) bool {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	_, ok := sa.hashes[hash]
+	return ok
+}
+
+// UpdateAllowlist updates the list of skylinks that are approved.
+func (sa *SkynetAllowlist) UpdateAllowlist(additions, removals []crypto.Hash) error {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	buf, err := sa.marshalObjects(additions, removals)
+	if err != nil {
+		return errors.AddContext(err, fmt.Sprintf("unable to update skynet allowlist persistence at '%v'", sa.staticAop.FilePath()))
+	}
+	// Nothing to persist if the update didn't change the allowlist.
+	if buf.Len() == 0 {
+		return nil
+	}
+	_, err = sa.staticAop.Write(buf.Bytes())
+	return errors.AddContext(err, fmt.Sprintf("unable to update skynet allowlist persistence at '%v'", sa.staticAop.FilePath()))
+}
+
+// marshalObjects marshals the given objects into a byte buffer.
+func (sa *SkynetAllowlist) marshalObjects(additions, removals []crypto.Hash) (bytes.Buffer, error) {
+	// Create buffer for encoder
+	var buf bytes.Buffer
+	// Create and encode the persist links
+	listed := true
+	for _, hash := range additions {
+		// Check if the hash is already approved
+		if _, ok := sa.hashes[hash]; ok {
+			continue
+		}
+
+		// Add hash to map
+		sa.hashes[hash] = struct{}{}
+
+		// Marshal the update
+		pe := persistEntry{hash, listed}
+		data := encoding.Marshal(pe)
+		_, err := buf.Write(data)
+		if err != nil {
+			return bytes.Buffer{}, errors.AddContext(err, "unable to write addition to the buffer")
+		}
+	}
+	listed = false
+	for _, hash := range removals {
+		// Check if the hash is already removed
+		if _, ok := sa.hashes[hash]; !ok {
+			continue
+		}
+
+		// Remove hash from map
+		delete(sa.hashes, hash)
+
+		// Marshal the update
+		pe := persistEntry{hash, listed}
+		data := encoding.Marshal(pe)
+		_, err := buf.Write(data)
+		if err != nil {
+			return bytes.Buffer{}, errors.AddContext(err, "unable to write removal to the buffer")
+		}
+	}
+
+	return buf, nil
+}
+
+// unmarshalObjects unmarshals the sia encoded objects.
+func unmarshalObjects(reader io.Reader) (map[crypto.Hash]struct{}, error) {
+	allowlist := make(map[crypto.Hash]struct{})
+	// Unmarshal approved links one by one until EOF.
+	for {
+		buf := make([]byte, persistSize)
+		_, err := io.ReadFull(reader, buf)
+		if errors.Contains(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var pe persistEntry
+		err = encoding.Unmarshal(buf, &pe)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pe.Listed {
+			delete(allowlist, pe.Hash)
+			continue
+		}
+		allowlist[pe.Hash] = struct{}{}
+	}
+	return allowlist, nil
+}
@@ -0,0 +1,110 @@
+package skynetallowlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/persist"
+)
+
+// testDir is a helper function for creating the testing directory
+func testDir(name string) string {
+	return build.TempDir("skynetallowlist", name)
+}
+
+// checkNumPersistedLinks checks that the expected number of links has been
+// persisted on disk by checking the size of the persistence file.
+func checkNumPersistedLinks(allowlistPath string, numLinks int) error {
+	expectedSize := numLinks*int(persistSize) + int(persist.MetadataPageSize)
+	if fi, err := os.Stat(allowlistPath); err != nil {
+		return errors.AddContext(err, "failed to get allowlist filesize")
+	} else if fi.Size() != int64(expectedSize) {
+		return fmt.Errorf("expected %v links and to have a filesize of %v but was %v", numLinks, expectedSize, fi.Size())
+	}
+	return nil
+}
+
+// TestPersist tests the persistence of the Skynet allowlist.
+func TestPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a new SkynetAllowlist
+	testdir := testDir(t.Name())
+	sa, err := New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(testdir, persistFile)
+	if filename != sa.staticAop.FilePath() {
+		t.Fatalf("Expected filepath %v, was %v", filename, sa.staticAop.FilePath())
+	}
+
+	// There should be no skylinks in the allowlist
+	if len(sa.Allowlist()) != 0 {
+		t.Fatal("Expected allowlist to be empty but found:", len(sa.Allowlist()))
+	}
+
+	// Add two skylinks and remove one of them again.
+	var mr1, mr2 crypto.Hash
+	fastrand.Read(mr1[:])
+	fastrand.Read(mr2[:])
+	skylink1, err := skymodules.NewSkylinkV1(mr1, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skylink2, err := skymodules.NewSkylinkV1(mr2, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash1 := crypto.HashObject(skylink1.MerkleRoot())
+	hash2 := crypto.HashObject(skylink2.MerkleRoot())
+	err = sa.UpdateAllowlist([]crypto.Hash{hash1, hash2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sa.UpdateAllowlist(nil, []crypto.Hash{hash2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sa.IsAllowed(skylink1) || sa.IsAllowed(skylink2) {
+		t.Fatal("wrong allowlist", sa.IsAllowed(skylink1), sa.IsAllowed(skylink2))
+	}
+	if err := checkNumPersistedLinks(filename, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding an approved skylink again shouldn't persist anything.
+	err = sa.UpdateAllowlist([]crypto.Hash{hash1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkNumPersistedLinks(filename, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the allowlist from disk again.
+	if err := sa.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sa, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowlist := sa.Allowlist()
+	if len(allowlist) != 1 || allowlist[0] != hash1 {
+		t.Fatal("wrong allowlist after reload", allowlist)
+	}
+	if !sa.IsHashAllowed(hash1) || sa.IsHashAllowed(hash2) {
+		t.Fatal("wrong allowlist after reload")
+	}
+}
@@ -0,0 +1,89 @@
+package renter
+
+import (
+	"time"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// CreateSkynetAPIKey creates a new API key with the given scope. Only the hash
+// of the key is persisted which means the returned key can't be retrieved
+// again.
+func (r *Renter) CreateSkynetAPIKey(scope skymodules.SkynetAPIKeyScope) (string, skymodules.SkynetAPIKey, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", skymodules.SkynetAPIKey{}, err
+	}
+	defer r.tg.Done()
+	if _, err := skymodules.ParseSkynetAPIKeyScope(string(scope)); err != nil {
+		return "", skymodules.SkynetAPIKey{}, err
+	}
+	key := skymodules.NewSkynetAPIKey()
+	info := skymodules.SkynetAPIKey{
+		ID:        skymodules.SkynetAPIKeyID(key),
+		Scope:     scope,
+		CreatedAt: time.Now(),
+	}
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.persist.SkynetAPIKeys = append(r.persist.SkynetAPIKeys, info)
+	if err := r.saveSync(); err != nil {
+		r.persist.SkynetAPIKeys = r.persist.SkynetAPIKeys[:len(r.persist.SkynetAPIKeys)-1]
+		return "", skymodules.SkynetAPIKey{}, err
+	}
+	return key, info, nil
+}
+
+// DeleteSkynetAPIKey deletes the API key with the given id.
+func (r *Renter) DeleteSkynetAPIKey(keyID crypto.Hash) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	for i, key := range r.persist.SkynetAPIKeys {
+		if key.ID != keyID {
+			continue
+		}
+		keys := append([]skymodules.SkynetAPIKey(nil), r.persist.SkynetAPIKeys[:i]...)
+		keys = append(keys, r.persist.SkynetAPIKeys[i+1:]...)
+		old := r.persist.SkynetAPIKeys
+		r.persist.SkynetAPIKeys = keys
+		if err := r.saveSync(); err != nil {
+			r.persist.SkynetAPIKeys = old
+			return err
+		}
+		return nil
+	}
+	return skymodules.ErrSkynetAPIKeyNotFound
+}
+
+// SkynetAPIKeys returns all API keys.
+func (r *Renter) SkynetAPIKeys() ([]skymodules.SkynetAPIKey, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return append([]skymodules.SkynetAPIKey{}, r.persist.SkynetAPIKeys...), nil
+}
+
+// SkynetAPIKeyScope returns the scope of the given API key.
+func (r *Renter) SkynetAPIKeyScope(key string) (skymodules.SkynetAPIKeyScope, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", err
+	}
+	defer r.tg.Done()
+	keyID := skymodules.SkynetAPIKeyID(key)
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	for _, key := range r.persist.SkynetAPIKeys {
+		if key.ID == keyID {
+			return key.Scope, nil
+		}
+	}
+	return "", skymodules.ErrSkynetAPIKeyNotFound
+}
@@ -0,0 +1,300 @@
+package skynetblocklist
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
+)
+
+// journal.go contains the write-ahead journal of the skynet blocklist. The
+// journal starts with the metadata header and version, followed by fixed size
+// records. Every record contains a sia encoded persistEntry and a checksum of
+// that entry. Records are only ever appended to the journal and synced before
+// an update returns. Once the journal contains a lot more records than there
+// are blocked hashes, it is compacted by writing the current blocklist to a
+// temporary file which atomically replaces the journal.
+//
+// When loading the journal, a partially written record at the end of the file
+// is dropped since the update it belongs to never returned successfully. The
+// same is true for records at the end of the file which consist of zeros only,
+// which can happen when the filesystem extended the file but the data never
+// made it to disk. Any other record with an invalid checksum means that data
+// which was successfully written got corrupted, which results in an error.
+
+const (
+	// checksumSize is the size of the checksum of a record in the journal.
+	checksumSize uint64 = 8
+
+	// journalHeaderSize is the size of the header at the beginning of the
+	// journal. It consists of the metadata header and version.
+	journalHeaderSize = uint64(2 * types.SpecifierLen)
+
+	// recordSize is the size of a record in the journal.
+	recordSize = persistSize + checksumSize
+)
+
+var (
+	// errCorruptRecord is returned when a record that is not at the end of the
+	// journal has an invalid checksum.
+	errCorruptRecord = errors.New("journal record is corrupt")
+
+	// journalCompactionThreshold is the minimum number of records in the
+	// journal before it is considered for compaction.
+	journalCompactionThreshold = build.Select(build.Var{
+		Dev:      uint64(1000),
+		Standard: uint64(10000),
+		Testing:  uint64(10),
+	}).(uint64)
+)
+
+// journal is an append-only log of blocklist updates.
+type journal struct {
+	// f is the file handle of the journal. It is replaced when the journal is
+	// compacted.
+	f *os.File
+
+	// numRecords is the number of valid records in the journal.
+	numRecords uint64
+
+	staticPath string
+}
+
+// checksum returns the checksum of a marshaled persistEntry.
+func checksum(entry []byte) []byte {
+	h := crypto.HashBytes(entry)
+	return h[:checksumSize]
+}
+
+// marshalRecord marshals a persistEntry into a journal record.
+func marshalRecord(pe persistEntry) []byte {
+	entry := encoding.Marshal(pe)
+	return append(entry, checksum(entry)...)
+}
+
+// journalHeader returns the header of the journal.
+func journalHeader() []byte {
+	header := make([]byte, 0, journalHeaderSize)
+	header = append(header, metadataHeader[:]...)
+	return append(header, metadataVersion[:]...)
+}
+
+// openJournal opens the journal at the given path and returns the set of
+// blocked hashes it contains. A new journal is created if none exists yet.
+func openJournal(path string) (*journal, map[crypto.Hash]struct{}, error) {
+	err := os.MkdirAll(filepath.Dir(path), skymodules.DefaultDirPerm)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to create persist dir")
+	}
+
+	// Remove any leftovers of an interrupted compaction. The journal is only
+	// replaced once the temporary file was fully written.
+	err = os.RemoveAll(tempPersistFileName(path))
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to remove temporary journal")
+	}
+
+	// Create a new journal if none exists.
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		hashes := make(map[crypto.Hash]struct{})
+		f, err := writeJournalFile(path, hashes)
+		if err != nil {
+			return nil, nil, errors.AddContext(err, "unable to create journal")
+		}
+		return &journal{f: f, staticPath: path}, hashes, nil
+	}
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to read journal")
+	}
+
+	// Parse the journal.
+	hashes, numRecords, err := unmarshalJournal(data)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, fmt.Sprintf("unable to load journal at '%v'", path))
+	}
+
+	// Open the journal and drop any incomplete records at the end.
+	f, err := os.OpenFile(path, os.O_RDWR, skymodules.DefaultFilePerm)
+	if err != nil {
+		return nil, nil, errors.AddContext(err, "unable to open journal")
+	}
+	j := &journal{f: f, numRecords: numRecords, staticPath: path}
+	if size := j.size(); size != uint64(len(data)) {
+		err = errors.Compose(f.Truncate(int64(size)), f.Sync())
+		if err != nil {
+			err = errors.Compose(err, f.Close())
+			return nil, nil, errors.AddContext(err, "unable to drop incomplete records")
+		}
+	}
+
+	// Compact the journal if necessary.
+	err = j.maybeCompact(hashes)
+	if err != nil {
+		err = errors.Compose(err, j.close())
+		return nil, nil, errors.AddContext(err, "unable to compact journal")
+	}
+	return j, hashes, nil
+}
+
+// unmarshalJournal parses the contents of a journal. It returns the set of
+// blocked hashes and the number of valid records.
+func unmarshalJournal(data []byte) (map[crypto.Hash]struct{}, uint64, error) {
+	// Check the header.
+	if uint64(len(data)) < journalHeaderSize {
+		return nil, 0, errors.New("journal is too short to contain a header")
+	}
+	var header, version types.Specifier
+	copy(header[:], data[:types.SpecifierLen])
+	copy(version[:], data[types.SpecifierLen:journalHeaderSize])
+	if header != metadataHeader {
+		return nil, 0, persist.ErrWrongHeader
+	}
+	if version != metadataVersion {
+		return nil, 0, persist.ErrWrongVersion
+	}
+
+	// Apply the records one by one.
+	hashes := make(map[crypto.Hash]struct{})
+	var numRecords uint64
+	for off := journalHeaderSize; off+recordSize <= uint64(len(data)); off += recordSize {
+		record := data[off : off+recordSize]
+		entry := record[:persistSize]
+		if !bytes.Equal(record[persistSize:], checksum(entry)) {
+			// Zeros at the end of the journal belong to a write that never
+			// completed.
+			if allZeros(data[off:]) {
+				break
+			}
+			return nil, 0, errors.AddContext(errCorruptRecord, fmt.Sprintf("record %v at offset %v", numRecords, off))
+		}
+		var pe persistEntry
+		err := encoding.Unmarshal(entry, &pe)
+		if err != nil {
+			return nil, 0, errors.AddContext(err, fmt.Sprintf("unable to unmarshal record %v at offset %v", numRecords, off))
+		}
+		if pe.Listed {
+			hashes[pe.Hash] = struct{}{}
+		} else {
+			delete(hashes, pe.Hash)
+		}
+		numRecords++
+	}
+	return hashes, numRecords, nil
+}
+
+// allZeros returns true if the provided data only consists of zeros.
+func allZeros(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJournalFile writes a compacted journal containing the provided hashes
+// to a temporary file which then atomically replaces the file at the given
+// path. The returned file handle points to the new journal.
+func writeJournalFile(path string, hashes map[crypto.Hash]struct{}) (_ *os.File, err error) {
+	buf := bytes.NewBuffer(journalHeader())
+	for hash := range hashes {
+		buf.Write(marshalRecord(persistEntry{hash, true}))
+	}
+
+	tempPath := tempPersistFileName(path)
+	f, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, skymodules.DefaultFilePerm)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create temporary journal")
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, f.Close(), os.Remove(tempPath))
+		}
+	}()
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to write temporary journal")
+	}
+	err = f.Sync()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to sync temporary journal")
+	}
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to replace journal")
+	}
+	err = syncDir(filepath.Dir(path))
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to sync persist dir")
+	}
+	return f, nil
+}
+
+// syncDir syncs the directory at the given path to make sure that a rename
+// within the directory is persisted.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Compose(d.Sync(), d.Close())
+}
+
+// append appends the provided records to the journal and syncs them to disk.
+func (j *journal) append(records []byte) error {
+	if uint64(len(records))%recordSize != 0 {
+		build.Critical("records are not a multiple of the record size")
+		return errors.New("invalid records")
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	size := j.size()
+	_, err := j.f.WriteAt(records, int64(size))
+	if err == nil {
+		err = j.f.Sync()
+	}
+	if err != nil {
+		// Try to drop the partially written records again. If this fails they
+		// are dropped the next time the journal is loaded.
+		return errors.Compose(err, j.f.Truncate(int64(size)))
+	}
+	j.numRecords += uint64(len(records)) / recordSize
+	return nil
+}
+
+// close closes the journal.
+func (j *journal) close() error {
+	return j.f.Close()
+}
+
+// maybeCompact compacts the journal if it contains significantly more records
+// than blocked hashes.
+func (j *journal) maybeCompact(hashes map[crypto.Hash]struct{}) error {
+	if j.numRecords < journalCompactionThreshold || j.numRecords <= 2*uint64(len(hashes)) {
+		return nil
+	}
+	f, err := writeJournalFile(j.staticPath, hashes)
+	if err != nil {
+		return err
+	}
+	err = j.f.Close()
+	j.f = f
+	j.numRecords = uint64(len(hashes))
+	return err
+}
+
+// size returns the size of the valid part of the journal.
+func (j *journal) size() uint64 {
+	return journalHeaderSize + j.numRecords*recordSize
+}
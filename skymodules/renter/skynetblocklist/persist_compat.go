@@ -17,11 +17,16 @@ import (
 
 const (
 	blacklistPersistFile string = "skynetblacklist"
+
+	// persistFileV151 is the name of the append-only persist file used from
+	// v1.5.1 until the journal was introduced in v1.5.8.
+	persistFileV151 string = "skynetblocklist.dat"
 )
 
 var (
 	blacklistMetadataHeader = types.NewSpecifier("SkynetBlacklist\n")
 	metadataVersionV143     = types.NewSpecifier("v1.4.3\n")
+	metadataVersionV151     = types.NewSpecifier("v1.5.1\n")
 
 	// NOTE: There is a MetadataVersionV150 in the persist package
 )
@@ -121,7 +126,7 @@ func convertPersistVersionFromv150Tov151(persistDir string) error {
 	}
 
 	// Initialize new blocklist persistence
-	aopBlocklist, _, err := persist.NewAppendOnlyPersist(persistDir, persistFile, metadataHeader, metadataVersion)
+	aopBlocklist, _, err := persist.NewAppendOnlyPersist(persistDir, persistFileV151, metadataHeader, metadataVersionV151)
 	if err != nil {
 		return errors.AddContext(err, "unable to initialize blocklist persist file")
 	}
@@ -146,6 +151,62 @@ func convertPersistVersionFromv150Tov151(persistDir string) error {
 	return nil
 }
 
+// convertPersistVersionFromv151Tov158 handles the compatibility code for
+// upgrading the persistence from v1.5.1 to v1.5.8. The change in persistence is
+// that the append-only persist file was replaced by a journal with checksummed
+// records. The blocked hashes are appended to the journal before the v1.5.1
+// persist file is removed, so an interrupted conversion is simply repeated.
+func convertPersistVersionFromv151Tov158(persistDir string) (err error) {
+	// Nothing to do if there is no v1.5.1 persist file. NewAppendOnlyPersist
+	// would create one otherwise.
+	persistFilePath := filepath.Join(persistDir, persistFileV151)
+	_, err = os.Stat(persistFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.AddContext(err, "unable to stat v1.5.1 persist file")
+	}
+
+	// Load the v1.5.1 persistence
+	aop, reader, err := persist.NewAppendOnlyPersist(persistDir, persistFileV151, metadataHeader, metadataVersionV151)
+	if err != nil {
+		return errors.AddContext(err, "unable to load v1.5.1 persist file")
+	}
+	hashes, err := unmarshalObjects(reader)
+	err = errors.Compose(err, aop.Close())
+	if err != nil {
+		return errors.AddContext(err, "unable to unmarshal v1.5.1 persist objects")
+	}
+
+	// Open the journal and append the hashes that are not blocked yet
+	j, journalHashes, err := openJournal(filepath.Join(persistDir, persistFile))
+	if err != nil {
+		return errors.AddContext(err, "unable to open journal")
+	}
+	defer func() {
+		err = errors.Compose(err, j.close())
+	}()
+	var buf bytes.Buffer
+	for hash := range hashes {
+		if _, ok := journalHashes[hash]; ok {
+			continue
+		}
+		buf.Write(marshalRecord(persistEntry{hash, true}))
+	}
+	err = j.append(buf.Bytes())
+	if err != nil {
+		return errors.AddContext(err, "unable to write to journal")
+	}
+
+	// Delete the v1.5.1 persist file
+	err = os.Remove(persistFilePath)
+	if err != nil {
+		return errors.AddContext(err, "unable to remove v1.5.1 persist file from disk")
+	}
+	return nil
+}
+
 // createTempFileFromPersistFile copies the data from the persist file into
 // a temporary file and returns a reader for the data. This function checks for
 // the existence of a temp file first and will return a reader for the temporary
@@ -261,7 +322,7 @@ func loadTempFile(tempFilePath string) (_ io.Reader, err error) {
 
 // loadPersist will load the persistence from the persist file in a way that
 // takes into account any previous persistence updates
-func loadPersist(persistDir string) (*persist.AppendOnlyPersist, io.Reader, error) {
+func loadPersist(persistDir string) (*journal, map[crypto.Hash]struct{}, error) {
 	// Check for any temp files indicating that a persistence update was
 	// interrupted
 	//
@@ -279,9 +340,10 @@ func loadPersist(persistDir string) (*persist.AppendOnlyPersist, io.Reader, erro
 		}
 	}
 
-	// Check for the existence of the old persist file
-	_, err = os.Stat(filepath.Join(persistDir, blacklistPersistFile))
-	if !os.IsNotExist(err) {
+	// Check for the existence of the old persist files
+	_, errBlacklist := os.Stat(filepath.Join(persistDir, blacklistPersistFile))
+	_, errV151 := os.Stat(filepath.Join(persistDir, persistFileV151))
+	if !os.IsNotExist(errBlacklist) || !os.IsNotExist(errV151) {
 		// Old persist file exists, try and update persistence
 		err = convertPersistence(persistDir)
 		if err != nil {
@@ -290,28 +352,19 @@ func loadPersist(persistDir string) (*persist.AppendOnlyPersist, io.Reader, erro
 	}
 
 	// Load Persistence
-	aop, reader, err := persist.NewAppendOnlyPersist(persistDir, persistFile, metadataHeader, metadataVersion)
-	if errors.Contains(err, persist.ErrWrongVersion) {
-		// Wrong version, try and convert persistence
-		err = convertPersistence(persistDir)
-		if err != nil {
-			return nil, nil, errors.AddContext(err, "unable to convert persistence after wrong version error")
-		}
-		// Load the v1.5.1 persistence
-		aop, reader, err = persist.NewAppendOnlyPersist(persistDir, persistFile, metadataHeader, metadataVersion)
-	}
+	journalPath := filepath.Join(persistDir, persistFile)
+	j, hashes, err := openJournal(journalPath)
 	if err != nil {
-		return nil, nil, errors.AddContext(err, fmt.Sprintf("unable to initialize the skynet blocklist persistence at '%v'", aop.FilePath()))
+		return nil, nil, errors.AddContext(err, fmt.Sprintf("unable to initialize the skynet blocklist persistence at '%v'", journalPath))
 	}
-
-	return aop, reader, nil
+	return j, hashes, nil
 }
 
 // convertPersistence will try and convert the persistence from the oldest
 // persist version to the newest.
 //
 // NOTE: Errors from earlier versions will only be returned if there is an error
-// with the newest version
+// with a newer version
 func convertPersistence(persistDir string) error {
 	// Try converting persistence from v1.4.3 to v1.5.0
 	errv143Tov150 := convertPersistVersionFromv143Tov150(persistDir)
@@ -321,5 +374,42 @@ func convertPersistence(persistDir string) error {
 	if errv150TOv151 != nil {
 		return errors.Compose(errv143Tov150, errv150TOv151)
 	}
+
+	// Try converting persistence from v1.5.1 to v1.5.8
+	errv151Tov158 := convertPersistVersionFromv151Tov158(persistDir)
+	if errv151Tov158 != nil {
+		return errors.Compose(errv143Tov150, errv151Tov158)
+	}
 	return nil
 }
+
+// unmarshalObjects unmarshals the sia encoded objects of the append-only
+// persist files used before v1.5.8.
+func unmarshalObjects(reader io.Reader) (map[crypto.Hash]struct{}, error) {
+	blocklist := make(map[crypto.Hash]struct{})
+	// Unmarshal blocked links one by one until EOF.
+	var offset uint64
+	for {
+		buf := make([]byte, persistSize)
+		_, err := io.ReadFull(reader, buf)
+		if errors.Contains(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var pe persistEntry
+		err = encoding.Unmarshal(buf, &pe)
+		if err != nil {
+			return nil, err
+		}
+		offset += persistSize
+
+		if !pe.Listed {
+			delete(blocklist, pe.Hash)
+			continue
+		}
+		blocklist[pe.Hash] = struct{}{}
+	}
+	return blocklist, nil
+}
@@ -27,6 +27,10 @@ func TestPersistCompat(t *testing.T) {
 	t.Run("V143ToV150", testPersistCompatv143Tov150)
 	t.Run("V143ToV151", testPersistCompatv143Tov151)
 	t.Run("V150ToV151", testPersistCompatv150Tov151)
+	t.Run("V143ToV158", testPersistCompatv143Tov158)
+	t.Run("V150ToV158", testPersistCompatv150Tov158)
+	t.Run("V151ToV158", testPersistCompatv151Tov158)
+	t.Run("BadCompatTwoFilesV151", testPersistCompatTwoFilesV151)
 	t.Run("BadCompatTwoFiles", testPersistCompatTwoFiles)
 }
 
@@ -113,17 +117,109 @@ func testPersistCompatv143Tov150(t *testing.T) {
 func testPersistCompatv143Tov151(t *testing.T) {
 	t.Parallel()
 	testdir := testDir(t.Name())
-	testPersistCompat(t, testdir, blacklistPersistFile, persistFile, blacklistMetadataHeader, metadataHeader, metadataVersionV143, metadataVersion)
+	testPersistCompat(t, testdir, blacklistPersistFile, persistFileV151, blacklistMetadataHeader, metadataHeader, metadataVersionV143, metadataVersionV151)
 }
 
 // testPersistCompatv150Tov151 tests converting the skynet blacklist persistence
 // from v1.5.0 to v1.5.1
 func testPersistCompatv150Tov151(t *testing.T) {
+	t.Parallel()
+	testdir := testDir(t.Name())
+	testPersistCompat(t, testdir, blacklistPersistFile, persistFileV151, blacklistMetadataHeader, metadataHeader, persist.MetadataVersionv150, metadataVersionV151)
+}
+
+// testPersistCompatv143Tov158 tests converting the skynet blacklist persistence
+// from v1.4.3 to v1.5.8
+func testPersistCompatv143Tov158(t *testing.T) {
+	t.Parallel()
+	testdir := testDir(t.Name())
+	testPersistCompat(t, testdir, blacklistPersistFile, persistFile, blacklistMetadataHeader, metadataHeader, metadataVersionV143, metadataVersion)
+}
+
+// testPersistCompatv150Tov158 tests converting the skynet blacklist persistence
+// from v1.5.0 to v1.5.8
+func testPersistCompatv150Tov158(t *testing.T) {
 	t.Parallel()
 	testdir := testDir(t.Name())
 	testPersistCompat(t, testdir, blacklistPersistFile, persistFile, blacklistMetadataHeader, metadataHeader, persist.MetadataVersionv150, metadataVersion)
 }
 
+// testPersistCompatv151Tov158 tests converting the skynet blocklist persistence
+// from v1.5.1 to v1.5.8
+func testPersistCompatv151Tov158(t *testing.T) {
+	t.Parallel()
+	testdir := testDir(t.Name())
+	testPersistCompatClean(t, testdir, persistFileV151, persistFile, metadataHeader, metadataHeader, metadataVersionV151, metadataVersion)
+
+	// The v1.5.1 persist file should be gone
+	_, err := os.Stat(filepath.Join(testdir, "CleanConvert", persistFileV151))
+	if !os.IsNotExist(err) {
+		t.Fatal("v1.5.1 persist file still exists", err)
+	}
+}
+
+// testPersistCompatTwoFilesV151 tests the handling of the persist code when a
+// v1.5.1 persist file exists next to the journal, e.g. after a downgrade.
+func testPersistCompatTwoFilesV151(t *testing.T) {
+	t.Parallel()
+	// Create new blocklist persistence by loading a new SkynetBlocklist
+	testdir := testDir(t.Name())
+	sb, err := New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add links to it
+	additions := []crypto.Hash{crypto.HashObject("link1"), crypto.HashObject("link2")}
+	err = sb.UpdateBlocklist(additions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sb.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a v1.5.1 persist file
+	err = loadCompatPersistFile(testdir, metadataVersionV151)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPersistence, err := loadOldPersistence(testdir, persistFileV151, metadataHeader, metadataVersionV151)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load SkynetBlocklist again
+	sb, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sb.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The v1.5.1 persist file should be gone
+	_, err = os.Stat(filepath.Join(testdir, persistFileV151))
+	if !os.IsNotExist(err) {
+		t.Fatal("v1.5.1 persist file still exists")
+	}
+
+	// Both the old and the new links should be blocked
+	for hash := range oldPersistence {
+		if !sb.IsHashBlocked(hash) {
+			t.Fatal("old hash not found in new persistence")
+		}
+	}
+	for _, hash := range additions {
+		if !sb.IsHashBlocked(hash) {
+			t.Fatal("added hash not found in new persistence")
+		}
+	}
+}
+
 // testPersistCompat tests the persist compat code going between two versions
 func testPersistCompat(t *testing.T, testdir, oldPersistFile, newPersistFile string, oldHeader, newHeader, oldVersion, newVersion types.Specifier) {
 	t.Run("Clean", func(t *testing.T) {
@@ -183,19 +279,19 @@ func testPersistCompatClean(t *testing.T, testdir, oldPersistFile, newPersistFil
 	}
 
 	// Load the persistence
-	aop, reader, err := loadPersist(subTestDir)
+	j, newPersistence, err := loadPersist(subTestDir)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Compare the persistence
-	err = readAndComparePersistence(reader, oldVersion, oldPersistence)
+	err = comparePersistence(newPersistence, oldVersion, oldPersistence)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Close the AOP
-	err = aop.Close()
+	// Close the journal
+	err = j.close()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -343,13 +439,26 @@ func loadAndVerifyPersistence(testDir, oldPersistFile, newPersistFile string, ol
 	if oldVersion == metadataVersionV143 {
 		err = convertPersistVersionFromv143Tov150(testDir)
 	}
-	if oldVersion == persist.MetadataVersionv150 || newVersion == metadataVersion {
+	if oldVersion == persist.MetadataVersionv150 || newVersion == metadataVersionV151 || newVersion == metadataVersion {
 		err = errors.Compose(err, convertPersistVersionFromv150Tov151(testDir))
 	}
+	if newVersion == metadataVersion {
+		err = errors.Compose(err, convertPersistVersionFromv151Tov158(testDir))
+	}
 	if err != nil {
 		return errors.AddContext(err, "unable to convert persistence")
 	}
 
+	// Load the journal
+	if newVersion == metadataVersion {
+		j, newPersistence, err := openJournal(filepath.Join(testDir, newPersistFile))
+		if err != nil {
+			return errors.AddContext(err, "unable to open journal")
+		}
+		err = comparePersistence(newPersistence, oldVersion, oldPersistence)
+		return errors.Compose(err, j.close())
+	}
+
 	// Load the new persistence
 	aop, reader, err := persist.NewAppendOnlyPersist(testDir, newPersistFile, newHeader, newVersion)
 	if err != nil {
@@ -370,6 +479,8 @@ func loadCompatPersistFile(testDir string, version types.Specifier) error {
 		return loadV143CompatPersistFile(testDir)
 	case persist.MetadataVersionv150:
 		return loadV150CompatPersistFile(testDir)
+	case metadataVersionV151:
+		return loadV151CompatPersistFile(testDir)
 	default:
 	}
 	return errors.New("invalid error")
@@ -409,6 +520,16 @@ func loadV150CompatPersistFile(testDir string) error {
 	return copyFileToTestDir(v150FileName, filepath.Join(testDir, blacklistPersistFile))
 }
 
+// loadV151CompatPersistFile creates a v1.5.1 persist file in the testDir by
+// converting the v1.5.0 persist file
+func loadV151CompatPersistFile(testDir string) error {
+	err := loadV150CompatPersistFile(testDir)
+	if err != nil {
+		return err
+	}
+	return convertPersistVersionFromv150Tov151(testDir)
+}
+
 // readAndComparePersistence reads the persistence from the reader and compares
 // it to the provided oldPersistence
 func readAndComparePersistence(reader io.Reader, oldVersion types.Specifier, oldPersistence map[crypto.Hash]struct{}) error {
@@ -417,6 +538,12 @@ func readAndComparePersistence(reader io.Reader, oldVersion types.Specifier, old
 	if err != nil {
 		return errors.AddContext(err, "unable to unmarshal new persistence")
 	}
+	return comparePersistence(newPersistence, oldVersion, oldPersistence)
+}
+
+// comparePersistence compares the newPersistence to the provided
+// oldPersistence
+func comparePersistence(newPersistence map[crypto.Hash]struct{}, oldVersion types.Specifier, oldPersistence map[crypto.Hash]struct{}) error {
 	if len(newPersistence) == 0 {
 		return errors.New("no data in new version's persist file")
 	}
@@ -430,7 +557,7 @@ func readAndComparePersistence(reader io.Reader, oldVersion types.Specifier, old
 		switch oldVersion {
 		case metadataVersionV143:
 			hash = crypto.HashObject(p)
-		case persist.MetadataVersionv150:
+		case persist.MetadataVersionv150, metadataVersionV151:
 			hash = p
 		default:
 			return errors.New("invalid version")
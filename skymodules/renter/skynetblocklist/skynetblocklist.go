@@ -3,21 +3,18 @@ package skynetblocklist
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"sync"
 
-	"gitlab.com/NebulousLabs/encoding"
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/SkynetLabs/skyd/build"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
 	"go.sia.tech/siad/crypto"
-	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
 )
 
 const (
 	// persistFile is the name of the persist file
-	persistFile string = "skynetblocklist.dat"
+	persistFile string = "skynetblocklist.journal"
 
 	// persistSize is the size of a persisted merkleroot in the blocklist. It is
 	// the length of `merkleroot` plus the `listed` flag (32 + 1).
@@ -29,14 +26,14 @@ var (
 	metadataHeader = types.NewSpecifier("SkynetBlocklist\n")
 
 	// metadataVersion is the version of the persistence file
-	metadataVersion = types.NewSpecifier("v1.5.1\n")
+	metadataVersion = types.NewSpecifier("v1.5.8\n")
 )
 
 type (
 	// SkynetBlocklist manages a set of blocked skylinks by tracking the
 	// merkleroots and persists the list to disk.
 	SkynetBlocklist struct {
-		staticAop *persist.AppendOnlyPersist
+		staticJournal *journal
 
 		// hashes is a set of hashed blocked merkleroots.
 		hashes map[crypto.Hash]struct{}
@@ -55,22 +52,15 @@ type (
 // New returns an initialized SkynetBlocklist.
 func New(persistDir string) (*SkynetBlocklist, error) {
 	// Load the persistence of the blocklist.
-	aop, reader, err := loadPersist(persistDir)
+	j, hashes, err := loadPersist(persistDir)
 	if err != nil {
 		return nil, errors.AddContext(err, "unable to load the skynet blocklist persistence")
 	}
 
-	sb := &SkynetBlocklist{
-		staticAop: aop,
-	}
-	hashes, err := unmarshalObjects(reader)
-	if err != nil {
-		err = errors.Compose(err, aop.Close())
-		return nil, errors.AddContext(err, "unable to unmarshal persist objects")
-	}
-	sb.hashes = hashes
-
-	return sb, nil
+	return &SkynetBlocklist{
+		staticJournal: j,
+		hashes:        hashes,
+	}, nil
 }
 
 // Blocklist returns the hashes of the merkleroots that are blocked
@@ -87,7 +77,9 @@ func (sb *SkynetBlocklist) Blocklist() []crypto.Hash {
 
 // Close closes and frees associated resources.
 func (sb *SkynetBlocklist) Close() error {
-	return sb.staticAop.Close()
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.staticJournal.close()
 }
 
 // IsBlocked indicates if a skylink is currently blocked
@@ -115,13 +107,18 @@ func (sb *SkynetBlocklist) UpdateBlocklist(additions, removals []crypto.Hash) er
 
 	buf, err := sb.marshalObjects(additions, removals)
 	if err != nil {
-		return errors.AddContext(err, fmt.Sprintf("unable to update skynet blocklist persistence at '%v'", sb.staticAop.FilePath()))
+		return errors.AddContext(err, fmt.Sprintf("unable to update skynet blocklist persistence at '%v'", sb.staticJournal.staticPath))
+	}
+	err = sb.staticJournal.append(buf.Bytes())
+	if err != nil {
+		return errors.AddContext(err, fmt.Sprintf("unable to update skynet blocklist persistence at '%v'", sb.staticJournal.staticPath))
 	}
-	_, err = sb.staticAop.Write(buf.Bytes())
-	return errors.AddContext(err, fmt.Sprintf("unable to update skynet blocklist persistence at '%v'", sb.staticAop.FilePath()))
+	err = sb.staticJournal.maybeCompact(sb.hashes)
+	return errors.AddContext(err, fmt.Sprintf("unable to compact skynet blocklist persistence at '%v'", sb.staticJournal.staticPath))
 }
 
-// marshalObjects marshals the given objects into a byte buffer.
+// marshalObjects marshals the given objects into a byte buffer of journal
+// records.
 func (sb *SkynetBlocklist) marshalObjects(additions, removals []crypto.Hash) (bytes.Buffer, error) {
 	// Create buffer for encoder
 	var buf bytes.Buffer
@@ -138,7 +135,7 @@ func (sb *SkynetBlocklist) marshalObjects(additions, removals []crypto.Hash) (by
 
 		// Marshal the update
 		pe := persistEntry{hash, listed}
-		data := encoding.Marshal(pe)
+		data := marshalRecord(pe)
 		_, err := buf.Write(data)
 		if err != nil {
 			return bytes.Buffer{}, errors.AddContext(err, "unable to write addition to the buffer")
@@ -156,7 +153,7 @@ func (sb *SkynetBlocklist) marshalObjects(additions, removals []crypto.Hash) (by
 
 		// Marshal the update
 		pe := persistEntry{hash, listed}
-		data := encoding.Marshal(pe)
+		data := marshalRecord(pe)
 		_, err := buf.Write(data)
 		if err != nil {
 			return bytes.Buffer{}, errors.AddContext(err, "unable to write removal to the buffer")
@@ -165,33 +162,3 @@ func (sb *SkynetBlocklist) marshalObjects(additions, removals []crypto.Hash) (by
 
 	return buf, nil
 }
-
-// unmarshalObjects unmarshals the sia encoded objects.
-func unmarshalObjects(reader io.Reader) (map[crypto.Hash]struct{}, error) {
-	blocklist := make(map[crypto.Hash]struct{})
-	// Unmarshal blocked links one by one until EOF.
-	var offset uint64
-	for {
-		buf := make([]byte, persistSize)
-		_, err := io.ReadFull(reader, buf)
-		if errors.Contains(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		var pe persistEntry
-		err = encoding.Unmarshal(buf, &pe)
-		if err != nil {
-			return nil, err
-		}
-		offset += persistSize
-
-		if !pe.Listed {
-			delete(blocklist, pe.Hash)
-			continue
-		}
-		blocklist[pe.Hash] = struct{}{}
-	}
-	return blocklist, nil
-}
@@ -3,8 +3,10 @@ package skynetblocklist
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"gitlab.com/NebulousLabs/encoding"
@@ -13,7 +15,6 @@ import (
 	"gitlab.com/SkynetLabs/skyd/build"
 	"gitlab.com/SkynetLabs/skyd/skymodules"
 	"go.sia.tech/siad/crypto"
-	"go.sia.tech/siad/persist"
 )
 
 // testDir is a helper function for creating the testing directory
@@ -24,7 +25,7 @@ func testDir(name string) string {
 // checkNumPersistedLinks checks that the expected number of links has been
 // persisted on disk by checking the size of the persistence file.
 func checkNumPersistedLinks(blocklistPath string, numLinks int) error {
-	expectedSize := numLinks*int(persistSize) + int(persist.MetadataPageSize)
+	expectedSize := numLinks*int(recordSize) + int(journalHeaderSize)
 	if fi, err := os.Stat(blocklistPath); err != nil {
 		return errors.AddContext(err, "failed to get blocklist filesize")
 	} else if fi.Size() != int64(expectedSize) {
@@ -48,8 +49,8 @@ func TestPersist(t *testing.T) {
 	}
 
 	filename := filepath.Join(testdir, persistFile)
-	if filename != sb.staticAop.FilePath() {
-		t.Fatalf("Expected filepath %v, was %v", filename, sb.staticAop.FilePath())
+	if filename != sb.staticJournal.staticPath {
+		t.Fatalf("Expected filepath %v, was %v", filename, sb.staticJournal.staticPath)
 	}
 
 	// There should be no skylinks in the blocklist
@@ -173,143 +174,269 @@ func TestPersistCorruption(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-
 	filename := filepath.Join(testdir, persistFile)
-	if filename != sb.staticAop.FilePath() {
-		t.Fatalf("Expected filepath %v, was %v", filename, sb.staticAop.FilePath())
-	}
 
-	// There should be no skylinks in the blocklist
-	if len(sb.hashes) != 0 {
-		t.Fatal("Expected blocklist to be empty but found:", len(sb.hashes))
+	// Block a skylink
+	var skylink skymodules.Skylink
+	hash := crypto.HashObject(skylink.MerkleRoot())
+	add := []crypto.Hash{hash}
+	err = sb.UpdateBlocklist(add, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Append a bunch of random data to the end of the blocklist file to test
-	// corruption
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, skymodules.DefaultFilePerm)
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	minNumBytes := int(2 * persist.MetadataPageSize)
-	_, err = f.Write(fastrand.Bytes(minNumBytes + fastrand.Intn(minNumBytes)))
+
+	// appendToFile is a helper to append data to the blocklist file.
+	appendToFile := func(data []byte) {
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, skymodules.DefaultFilePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Append a partial record and a few records of zeros to simulate
+	// interrupted writes. Both should be dropped when loading the blocklist.
+	appendToFile(fastrand.Bytes(fastrand.Intn(int(recordSize)-1) + 1))
+	sb, err = New(testdir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = f.Close()
+	if err := checkNumPersistedLinks(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// The filesize with corruption should be greater than the persist length.
-	fi, err := os.Stat(filename)
+	appendToFile(make([]byte, 3*recordSize+1))
+	sb, err = New(testdir)
 	if err != nil {
 		t.Fatal(err)
 	}
-	filesize := fi.Size()
-	if uint64(filesize) <= sb.staticAop.PersistLength() {
-		t.Fatalf("Expected file size greater than %v, got %v", sb.staticAop.PersistLength(), filesize)
+	if err := checkNumPersistedLinks(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !sb.IsBlocked(skylink) {
+		t.Fatal("Expected skylink to be listed in blocklist")
 	}
 
-	// Update blocklist
-	var skylink skymodules.Skylink
-	hash := crypto.HashObject(skylink.MerkleRoot())
-	add := []crypto.Hash{hash}
-	remove := []crypto.Hash{hash}
-	err = sb.UpdateBlocklist(add, remove)
+	// Updates should still be persisted after the dropped records.
+	err = sb.UpdateBlocklist(nil, add)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// The filesize should be equal to the persist length now due to the
-	// truncate when updating.
-	fi, err = os.Stat(filename)
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	filesize = fi.Size()
-	if uint64(filesize) != sb.staticAop.PersistLength() {
-		t.Fatalf("Expected file size %v, got %v", sb.staticAop.PersistLength(), filesize)
+	sb, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Blocklist should be empty because we added and then removed the same
-	// skylink
-	if len(sb.hashes) != 0 {
-		t.Fatal("Expected blocklist to be empty but found:", len(sb.hashes))
+	if sb.IsBlocked(skylink) {
+		t.Fatal("Expected skylink to not be listed in blocklist")
 	}
-
-	// Add the skylink again
-	err = sb.UpdateBlocklist(add, []crypto.Hash{})
+	if err := checkNumPersistedLinks(filename, 2); err != nil {
+		t.Fatal(err)
+	}
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// There should be 1 element in the blocklist now
-	if len(sb.hashes) != 1 {
-		t.Fatal("Expected 1 element in the blocklist but found:", len(sb.hashes))
+	// Append a full record of random data. This can't be told apart from a
+	// corrupted record so loading the blocklist should fail.
+	appendToFile(fastrand.Bytes(int(recordSize)))
+	_, err = New(testdir)
+	if !errors.Contains(err, errCorruptRecord) {
+		t.Fatal("expected corrupt record error", err)
 	}
-	if !sb.IsBlocked(skylink) {
-		t.Fatal("Expected skylink to be listed in blocklist")
+}
+
+// TestJournalCompaction tests that the journal is compacted once it contains
+// a lot more records than blocked hashes.
+func TestJournalCompaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
 	}
+	t.Parallel()
 
-	// Load a new Skynet Blocklist to verify the contents from disk get loaded
-	// properly
-	sb2, err := New(testdir)
+	testdir := testDir(t.Name())
+	sb, err := New(testdir)
 	if err != nil {
 		t.Fatal(err)
 	}
+	filename := filepath.Join(testdir, persistFile)
 
-	// There should be 1 element in the blocklist
-	if len(sb2.hashes) != 1 {
-		t.Fatal("Expected 1 element in the blocklist but found:", len(sb2.hashes))
+	// Block one hash permanently and keep adding and removing another one.
+	blocked := crypto.HashObject("blocked")
+	toggled := crypto.HashObject("toggled")
+	err = sb.UpdateBlocklist([]crypto.Hash{blocked}, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !sb2.IsBlocked(skylink) {
-		t.Fatal("Expected skylink to be listed in blocklist")
+	for i := uint64(0); i < journalCompactionThreshold; i++ {
+		err = sb.UpdateBlocklist([]crypto.Hash{toggled}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sb.UpdateBlocklist(nil, []crypto.Hash{toggled})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The journal should never have exceeded the compaction threshold by more
+	// than one record.
+	sb.mu.Lock()
+	numRecords := sb.staticJournal.numRecords
+	sb.mu.Unlock()
+	if numRecords > journalCompactionThreshold {
+		t.Fatal("journal wasn't compacted", numRecords)
+	}
+	if err := checkNumPersistedLinks(filename, int(numRecords)); err != nil {
+		t.Fatal(err)
 	}
 
-	// Add the skylink again
-	err = sb2.UpdateBlocklist(add, []crypto.Hash{})
+	// Reload the blocklist and check the contents.
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// There should still only be 1 element in the blocklist
-	if len(sb2.hashes) != 1 {
-		t.Fatal("Expected 1 element in the blocklist but found:", len(sb2.hashes))
+	sb, err = New(testdir)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !sb2.IsBlocked(skylink) {
-		t.Fatal("Expected skylink to be listed in blocklist")
+	if !sb.IsHashBlocked(blocked) || sb.IsHashBlocked(toggled) {
+		t.Fatal("wrong blocklist after compaction", sb.Blocklist())
 	}
-
-	// Load another new Skynet Blocklist to verify the contents from disk get loaded
-	// properly
-	sb3, err := New(testdir)
+	if len(sb.Blocklist()) != 1 {
+		t.Fatal("wrong blocklist length", len(sb.Blocklist()))
+	}
+	err = sb.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	// There should be 1 element in the blocklist
-	if len(sb3.hashes) != 1 {
-		t.Fatal("Expected 1 element in the blocklist but found:", len(sb3.hashes))
-	}
-	if !sb3.IsBlocked(skylink) {
-		t.Fatal("Expected skylink to be listed in blocklist")
+// TestJournalFuzz truncates the journal and flips bits in it at random offsets.
+// Loading the journal must either preserve all fully written records or fail.
+func TestJournalFuzz(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
 	}
+	t.Parallel()
 
-	// The final filesize should be equal to the persist length.
-	fi, err = os.Stat(filename)
+	testdir := testDir(t.Name())
+	err := os.MkdirAll(testdir, skymodules.DefaultDirPerm)
 	if err != nil {
 		t.Fatal(err)
 	}
-	filesize = fi.Size()
-	if uint64(filesize) != sb3.staticAop.PersistLength() {
-		t.Fatalf("Expected file size %v, got %v", sb3.staticAop.PersistLength(), filesize)
+	path := filepath.Join(testdir, persistFile)
+
+	// Create a journal with random additions and removals.
+	var entries []persistEntry
+	var hashes []crypto.Hash
+	for i := 0; i < 20; i++ {
+		if len(hashes) > 0 && fastrand.Intn(3) == 0 {
+			entries = append(entries, persistEntry{hashes[fastrand.Intn(len(hashes))], false})
+			continue
+		}
+		var hash crypto.Hash
+		fastrand.Read(hash[:])
+		hashes = append(hashes, hash)
+		entries = append(entries, persistEntry{hash, true})
+	}
+	data := journalHeader()
+	for _, pe := range entries {
+		data = append(data, marshalRecord(pe)...)
+	}
+
+	// expectedHashes returns the blocked hashes after applying the first n
+	// entries.
+	expectedHashes := func(n int) map[crypto.Hash]struct{} {
+		expected := make(map[crypto.Hash]struct{})
+		for _, pe := range entries[:n] {
+			if pe.Listed {
+				expected[pe.Hash] = struct{}{}
+			} else {
+				delete(expected, pe.Hash)
+			}
+		}
+		return expected
+	}
+
+	// load writes the data to disk and opens the journal.
+	load := func(data []byte) (map[crypto.Hash]struct{}, error) {
+		err := ioutil.WriteFile(path, data, skymodules.DefaultFilePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, hashes, err := openJournal(path)
+		if err != nil {
+			return nil, err
+		}
+		return hashes, j.close()
+	}
+
+	// Truncate the journal at random offsets. All complete records need to be
+	// preserved and the partial record needs to be dropped.
+	for i := 0; i < 100; i++ {
+		off := fastrand.Intn(len(data) + 1)
+		loaded, err := load(data[:off])
+		if uint64(off) < journalHeaderSize {
+			if err == nil {
+				t.Fatal("expected truncated header to fail", off)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(off, err)
+		}
+		numRecords := (uint64(off) - journalHeaderSize) / recordSize
+		expected := expectedHashes(int(numRecords))
+		if !reflect.DeepEqual(loaded, expected) {
+			t.Fatal("wrong hashes after truncating at offset", off)
+		}
+		// The journal might have been compacted when it was opened.
+		if numRecords >= journalCompactionThreshold && numRecords > 2*uint64(len(expected)) {
+			numRecords = uint64(len(expected))
+		}
+		if err := checkNumPersistedLinks(path, int(numRecords)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Flip a random bit at random offsets. Since all records were written
+	// completely, loading the journal needs to fail.
+	for i := 0; i < 100; i++ {
+		corrupted := append([]byte{}, data...)
+		off := fastrand.Intn(len(corrupted))
+		corrupted[off] ^= 1 << uint(fastrand.Intn(8))
+		loaded, err := load(corrupted)
+		if err == nil {
+			t.Fatal("expected bit flip to fail", off, len(loaded))
+		}
+	}
+
+	// The unmodified journal should load all entries.
+	loaded, err := load(data)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Verify that the correct number of links were persisted to verify no links
-	// are being truncated. Expect 3 links for the Add, remove, Add. Then final
-	// add would not be persisted because it already existed.
-	if err = checkNumPersistedLinks(filename, 3); err != nil {
-		t.Errorf("error verifying correct number of links: %v", err)
+	if !reflect.DeepEqual(loaded, expectedHashes(len(entries))) {
+		t.Fatal("wrong hashes")
 	}
 }
 
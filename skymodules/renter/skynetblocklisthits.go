@@ -0,0 +1,84 @@
+package renter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// skynetBlocklistHits is a fixed size ring buffer of the most recent attempts
+// to download blocked content.
+type skynetBlocklistHits struct {
+	hits []skymodules.SkynetBlocklistHit
+	next int
+	mu   sync.Mutex
+}
+
+// newSkynetBlocklistHits returns a new, empty ring buffer that holds up to
+// size hits.
+func newSkynetBlocklistHits(size int) *skynetBlocklistHits {
+	return &skynetBlocklistHits{
+		hits: make([]skymodules.SkynetBlocklistHit, 0, size),
+	}
+}
+
+// callAdd adds a hit to the buffer, overwriting the oldest hit if the buffer
+// is full.
+func (bh *skynetBlocklistHits) callAdd(hit skymodules.SkynetBlocklistHit) {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	if cap(bh.hits) == 0 {
+		return
+	}
+	if len(bh.hits) < cap(bh.hits) {
+		bh.hits = append(bh.hits, hit)
+		return
+	}
+	bh.hits[bh.next] = hit
+	bh.next = (bh.next + 1) % len(bh.hits)
+}
+
+// callHits returns the hits in the buffer, ordered from oldest to newest.
+func (bh *skynetBlocklistHits) callHits() []skymodules.SkynetBlocklistHit {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	hits := make([]skymodules.SkynetBlocklistHit, 0, len(bh.hits))
+	hits = append(hits, bh.hits[bh.next:]...)
+	hits = append(hits, bh.hits[:bh.next]...)
+	return hits
+}
+
+// BlocklistHits returns the most recent attempts to download blocked content,
+// ordered from oldest to newest.
+func (r *Renter) BlocklistHits() ([]skymodules.SkynetBlocklistHit, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetBlocklistHits.callHits(), nil
+}
+
+// staticLogBlocklistHit records an attempt to download a blocked skylink
+// together with the requester attached to ctx. The hash is the blocklist hash
+// the skylink was found to be blocked by.
+func (r *Renter) staticLogBlocklistHit(ctx context.Context, link skymodules.Skylink, hash crypto.Hash) {
+	r.staticSkynetBlocklistHits.callAdd(skymodules.SkynetBlocklistHit{
+		Hash:      hash,
+		Requester: skymodules.RequesterFromContext(ctx),
+		Skylink:   link.String(),
+		Timestamp: time.Now(),
+	})
+}
+
+// staticLogBlocklistRootHit records an attempt to download a blocked
+// merkleroot together with the requester attached to ctx.
+func (r *Renter) staticLogBlocklistRootHit(ctx context.Context, root crypto.Hash) {
+	r.staticSkynetBlocklistHits.callAdd(skymodules.SkynetBlocklistHit{
+		Hash:      crypto.HashObject(root),
+		Requester: skymodules.RequesterFromContext(ctx),
+		Timestamp: time.Now(),
+	})
+}
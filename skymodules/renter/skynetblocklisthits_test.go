@@ -0,0 +1,56 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetBlocklistHits tests the ring buffer of blocklist hits.
+func TestSkynetBlocklistHits(t *testing.T) {
+	t.Parallel()
+
+	// hitWithHash is a helper to create a hit whose hash is the given byte.
+	hitWithHash := func(b byte) skymodules.SkynetBlocklistHit {
+		var h crypto.Hash
+		h[0] = b
+		return skymodules.SkynetBlocklistHit{Hash: h}
+	}
+	// checkHits is a helper to check the order of the hits in the buffer.
+	checkHits := func(bh *skynetBlocklistHits, expected ...byte) {
+		t.Helper()
+		hits := bh.callHits()
+		if len(hits) != len(expected) {
+			t.Fatalf("expected %v hits but got %v", len(expected), len(hits))
+		}
+		for i, hit := range hits {
+			if hit.Hash[0] != expected[i] {
+				t.Fatalf("hit %v: expected %v but got %v", i, expected[i], hit.Hash[0])
+			}
+		}
+	}
+
+	// An empty buffer returns no hits.
+	bh := newSkynetBlocklistHits(3)
+	checkHits(bh)
+
+	// Fill the buffer.
+	for i := byte(1); i <= 3; i++ {
+		bh.callAdd(hitWithHash(i))
+	}
+	checkHits(bh, 1, 2, 3)
+
+	// Adding more hits overwrites the oldest ones.
+	bh.callAdd(hitWithHash(4))
+	checkHits(bh, 2, 3, 4)
+	bh.callAdd(hitWithHash(5))
+	bh.callAdd(hitWithHash(6))
+	bh.callAdd(hitWithHash(7))
+	checkHits(bh, 5, 6, 7)
+
+	// A buffer without capacity drops all hits.
+	bh = newSkynetBlocklistHits(0)
+	bh.callAdd(hitWithHash(1))
+	checkHits(bh)
+}
@@ -0,0 +1,114 @@
+package renter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
+)
+
+// SkynetGC deletes the skyfiles which weren't downloaded within the provided
+// retention window together with their extended siafiles. The last access of a
+// skyfile is the most recent of the access time persisted in its siafiles and
+// the downloads that the renter recorded since. Skyfiles under the excluded
+// prefix are never deleted. If dryRun is set, the candidates are returned
+// without deleting them.
+func (r *Renter) SkynetGC(olderThan time.Duration, dryRun bool, excludePrefix skymodules.SiaPath) (skymodules.SkynetGCResult, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkynetGCResult{}, err
+	}
+	defer r.tg.Done()
+
+	// Collect all the siafiles in the skynet folder.
+	var mu sync.Mutex
+	files := make(map[skymodules.SiaPath]skymodules.FileInfo)
+	flf := func(fi skymodules.FileInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		files[fi.SiaPath] = fi
+	}
+	err := r.staticFileSystem.CachedList(skymodules.SkynetFolder, true, flf, func(skymodules.DirectoryInfo) {})
+	if err != nil {
+		return skymodules.SkynetGCResult{}, errors.AddContext(err, "failed to list skyfiles")
+	}
+
+	// Find the skyfiles which weren't accessed since the cutoff.
+	cutoff := time.Now().Add(-olderThan)
+	result := skymodules.SkynetGCResult{
+		Candidates: []skymodules.SkynetGCCandidate{},
+	}
+	var extendedPaths []skymodules.SiaPath
+	for sp, fi := range files {
+		if strings.HasSuffix(sp.String(), skymodules.ExtendedSuffix) || len(fi.Skylinks) == 0 {
+			continue
+		}
+		if isExcludedSiaPath(sp, excludePrefix) {
+			continue
+		}
+		candidate := skymodules.SkynetGCCandidate{
+			LastAccess: fi.AccessTime,
+			SiaPath:    sp,
+			Size:       fi.Filesize,
+			Skylinks:   fi.Skylinks,
+		}
+		if lastAccess := r.staticSkylinkManager.callLastAccess(fi.Skylinks); lastAccess.After(candidate.LastAccess) {
+			candidate.LastAccess = lastAccess
+		}
+		extendedPath, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+		if err != nil {
+			return skymodules.SkynetGCResult{}, errors.AddContext(err, "failed to get siapath of extended siafile")
+		}
+		extended, hasExtended := files[extendedPath]
+		if hasExtended {
+			if extended.AccessTime.After(candidate.LastAccess) {
+				candidate.LastAccess = extended.AccessTime
+			}
+			candidate.Size += extended.Filesize
+		}
+		if !candidate.LastAccess.Before(cutoff) {
+			continue
+		}
+		result.Candidates = append(result.Candidates, candidate)
+		result.NumFiles++
+		result.ReclaimedBytes += candidate.Size
+		if hasExtended {
+			extendedPaths = append(extendedPaths, extendedPath)
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// Delete the candidates and their extended siafiles.
+	var errs error
+	for _, candidate := range result.Candidates {
+		err = r.DeleteFile(candidate.SiaPath)
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			errs = errors.Compose(errs, errors.AddContext(err, "failed to delete "+candidate.SiaPath.String()))
+		}
+	}
+	for _, sp := range extendedPaths {
+		err = r.DeleteFile(sp)
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			errs = errors.Compose(errs, errors.AddContext(err, "failed to delete "+sp.String()))
+		}
+	}
+	if errs != nil {
+		return skymodules.SkynetGCResult{}, errs
+	}
+	r.staticLog.Printf("Skynet GC deleted %v skyfiles which weren't accessed since %v", result.NumFiles, cutoff)
+	return result, nil
+}
+
+// isExcludedSiaPath returns whether the siapath is equal to or a child of the
+// excluded prefix. An empty prefix excludes nothing.
+func isExcludedSiaPath(sp, excludePrefix skymodules.SiaPath) bool {
+	if excludePrefix.IsEmpty() {
+		return false
+	}
+	prefix := excludePrefix.String()
+	return sp.String() == prefix || strings.HasPrefix(sp.String(), prefix+"/")
+}
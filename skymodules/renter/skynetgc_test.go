@@ -0,0 +1,224 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetGC probes the garbage collection of skyfiles which weren't
+// accessed within the retention window.
+func TestSkynetGC(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = rt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Helper to create a siafile with a random skylink in the skynet folder.
+	// If old is set, its access time lies far in the past.
+	createSkyfile := func(path string, old bool) (skymodules.SiaPath, skymodules.Skylink) {
+		sp, err := skymodules.SkynetFolder.Join(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rsc := testingFileParams()
+		sf, err := r.createRenterTestFileWithParams(sp, rsc, crypto.TypePlain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var root crypto.Hash
+		fastrand.Read(root[:])
+		skylink, err := skymodules.NewSkylinkV1(root, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sf.AddSkylink(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if old {
+			err = sf.SetAccessTime(time.Now().Add(-time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return sp, skylink
+	}
+	exists := func(sp skymodules.SiaPath) bool {
+		exists, err := r.staticFileSystem.FileExists(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	// Create an old skyfile with an extended siafile, a recent skyfile, an
+	// old skyfile which was recently downloaded and an old skyfile under the
+	// excluded prefix.
+	oldPath, _ := createSkyfile("old", true)
+	oldExtendedPath, _ := createSkyfile("old"+skymodules.ExtendedSuffix, true)
+	recentPath, _ := createSkyfile("recent", false)
+	downloadedPath, downloadedLink := createSkyfile("downloaded", true)
+	pinnedPath, _ := createSkyfile("pinned/old", true)
+	r.staticSkylinkManager.callRecordAccess(downloadedLink)
+
+	excludePrefix, err := skymodules.SkynetFolder.Join("pinned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allPaths := []skymodules.SiaPath{oldPath, oldExtendedPath, recentPath, downloadedPath, pinnedPath}
+
+	// A dry run should only return the old skyfile without deleting it.
+	result, err := r.SkynetGC(time.Minute, true, excludePrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumFiles != 1 || len(result.Candidates) != 1 {
+		t.Fatalf("expected 1 candidate but got %v", result.Candidates)
+	}
+	candidate := result.Candidates[0]
+	if !candidate.SiaPath.Equals(oldPath) {
+		t.Fatalf("wrong candidate %v != %v", candidate.SiaPath, oldPath)
+	}
+	if candidate.Size != 2000 || result.ReclaimedBytes != candidate.Size {
+		t.Fatalf("wrong size %v %v", candidate.Size, result.ReclaimedBytes)
+	}
+	for _, sp := range allPaths {
+		if !exists(sp) {
+			t.Fatal("dry run deleted", sp)
+		}
+	}
+
+	// Run the gc for real. The old skyfile and its extended siafile should be
+	// gone.
+	result, err = r.SkynetGC(time.Minute, false, excludePrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumFiles != 1 || result.ReclaimedBytes != 2000 {
+		t.Fatalf("unexpected result %v", result)
+	}
+	for _, sp := range allPaths {
+		deleted := sp.Equals(oldPath) || sp.Equals(oldExtendedPath)
+		if exists(sp) == deleted {
+			t.Fatalf("%v should be deleted: %v", sp, deleted)
+		}
+	}
+
+	// Without the excluded prefix, the pinned skyfile is collected too.
+	result, err = r.SkynetGC(time.Minute, false, skymodules.SiaPath{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumFiles != 1 || !result.Candidates[0].SiaPath.Equals(pinnedPath) {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if exists(pinnedPath) {
+		t.Fatal("pinned skyfile should be deleted")
+	}
+}
+
+// TestPersistAccessTime probes managedPersistAccessTime.
+func TestPersistAccessTime(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = rt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// create siafile with a skylink
+	sf, err := r.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = sf.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var root crypto.Hash
+	fastrand.Read(root[:])
+	skylink, err := skymodules.NewSkylinkV1(root, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sf.AddSkylink(skylink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	err = sf.SetAccessTime(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a recorded download, nothing changes.
+	err = r.managedPersistAccessTime(sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sf.Metadata().AccessTime.Equal(old) {
+		t.Fatal("access time shouldn't have changed")
+	}
+
+	// Record a download. The access time should be updated.
+	r.staticSkylinkManager.callRecordAccess(skylink)
+	err = r.managedPersistAccessTime(sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessTime := sf.Metadata().AccessTime
+	if !accessTime.After(old) {
+		t.Fatal("access time should have been updated")
+	}
+
+	// Record another download right away. The access time should not be
+	// updated again within the persist interval.
+	r.staticSkylinkManager.callRecordAccess(skylink)
+	err = r.managedPersistAccessTime(sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sf.Metadata().AccessTime.Equal(accessTime) {
+		t.Fatal("access time shouldn't have changed")
+	}
+
+	// Once the access times are pruned, the skylink is forgotten.
+	r.staticSkylinkManager.callUpdatePruneTimeThreshold(time.Now())
+	r.staticSkylinkManager.callPruneAccessTimes()
+	if !r.staticSkylinkManager.callLastAccess([]string{skylink.String()}).IsZero() {
+		t.Fatal("access time should have been pruned")
+	}
+}
@@ -0,0 +1,124 @@
+package renter
+
+// skynetinflight.go keeps track of the skylinks which are currently being
+// uploaded or pinned by the renter. When a skylink is added to the blocklist,
+// all in-flight operations for it are cancelled so the renter doesn't finish
+// uploading data that it just blocked.
+
+import (
+	"context"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
+	"go.sia.tech/siad/crypto"
+)
+
+type (
+	// skynetInFlightTracker indexes the in-flight skylink operations by the
+	// blocklist hash of the skylink.
+	skynetInFlightTracker struct {
+		nextID uint64
+		ops    map[crypto.Hash]map[uint64]*skynetInFlightOp
+		mu     sync.Mutex
+	}
+
+	// skynetInFlightOp is a single in-flight operation.
+	skynetInFlightOp struct {
+		blocked      bool
+		staticCancel context.CancelFunc
+	}
+)
+
+// newSkynetInFlightTracker returns a newly initialized skynetInFlightTracker.
+func newSkynetInFlightTracker() *skynetInFlightTracker {
+	return &skynetInFlightTracker{
+		ops: make(map[crypto.Hash]map[uint64]*skynetInFlightOp),
+	}
+}
+
+// callTrack registers an operation for the given skylink. It returns a ctx
+// derived from the provided one which is closed if the skylink gets blocked,
+// and a function which needs to be called once the operation is done. That
+// function returns whether the skylink was blocked while the operation was in
+// flight.
+func (t *skynetInFlightTracker) callTrack(ctx context.Context, skylink skymodules.Skylink) (context.Context, func() bool) {
+	hash := crypto.HashObject(skylink.MerkleRoot())
+	ctx, cancel := context.WithCancel(ctx)
+	op := &skynetInFlightOp{staticCancel: cancel}
+
+	t.mu.Lock()
+	id := t.nextID
+	t.nextID++
+	if _, exists := t.ops[hash]; !exists {
+		t.ops[hash] = make(map[uint64]*skynetInFlightOp)
+	}
+	t.ops[hash][id] = op
+	t.mu.Unlock()
+
+	return ctx, func() bool {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.ops[hash], id)
+		if len(t.ops[hash]) == 0 {
+			delete(t.ops, hash)
+		}
+		cancel()
+		return op.blocked
+	}
+}
+
+// callCancel cancels all in-flight operations for the given blocklist hashes.
+func (t *skynetInFlightTracker) callCancel(hashes []crypto.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, hash := range hashes {
+		for _, op := range t.ops[hash] {
+			op.blocked = true
+			op.staticCancel()
+		}
+	}
+}
+
+// managedTrackSkylink registers an in-flight upload or pin of a skylink. The
+// returned function needs to be called with a pointer to the operation's error
+// once it is done. If the skylink was blocked in the meantime, the error is
+// replaced with ErrSkylinkBlocked and the skyfile at the given siapath is
+// deleted together with its extended file.
+func (r *Renter) managedTrackSkylink(ctx context.Context, skylink skymodules.Skylink, siaPath skymodules.SiaPath) (context.Context, func(*error)) {
+	ctx, done := r.staticSkynetInFlightTracker.callTrack(ctx, skylink)
+
+	// Check the blocklist again after registering the operation in case the
+	// skylink was blocked right before.
+	hash := crypto.HashObject(skylink.MerkleRoot())
+	if r.staticSkynetBlocklist.IsHashBlocked(hash) {
+		r.staticSkynetInFlightTracker.callCancel([]crypto.Hash{hash})
+	}
+	return ctx, func(err *error) {
+		if !done() {
+			return
+		}
+		*err = ErrSkylinkBlocked
+		if deleteErr := r.managedDeleteSkyfile(siaPath); deleteErr != nil {
+			r.staticLog.Printf("failed to delete skyfile %v of blocked skylink %v: %v", siaPath, skylink, deleteErr)
+		}
+	}
+}
+
+// managedDeleteSkyfile deletes the siafile at the given siapath as well as its
+// extended siafile. Files that don't exist are ignored.
+func (r *Renter) managedDeleteSkyfile(siaPath skymodules.SiaPath) error {
+	extendedSiaPath, err := siaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, sp := range []skymodules.SiaPath{siaPath, extendedSiaPath} {
+		err := r.DeleteFile(sp)
+		if err != nil && !errors.Contains(err, filesystem.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Compose(errs...)
+}
@@ -0,0 +1,59 @@
+package renter
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetInFlightTracker is a unit test for the skynetInFlightTracker.
+func TestSkynetInFlightTracker(t *testing.T) {
+	t.Parallel()
+
+	var sl1, sl2 skymodules.Skylink
+	if err := sl1.LoadString("AAAJCTb0NnNUP56PgzE2bBAXovFrqUY2T1TLx9V1hJyYfw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sl2.LoadString("AAAtMN7YbmBYNgYBgxV3x80BM5EjdLWXqeq3R_eybhJJPQ"); err != nil {
+		t.Fatal(err)
+	}
+	hash1 := crypto.HashObject(sl1.MerkleRoot())
+
+	tracker := newSkynetInFlightTracker()
+	ctx1, done1 := tracker.callTrack(context.Background(), sl1)
+	ctx2, done2 := tracker.callTrack(context.Background(), sl1)
+	ctx3, done3 := tracker.callTrack(context.Background(), sl2)
+
+	// Blocking the first skylink should cancel both of its operations.
+	tracker.callCancel([]crypto.Hash{hash1})
+	for _, ctx := range []context.Context{ctx1, ctx2} {
+		select {
+		case <-ctx.Done():
+		default:
+			t.Fatal("ctx should be closed")
+		}
+	}
+	select {
+	case <-ctx3.Done():
+		t.Fatal("ctx shouldn't be closed")
+	default:
+	}
+	if !done1() || !done2() {
+		t.Fatal("operations should be blocked")
+	}
+	if done3() {
+		t.Fatal("operation shouldn't be blocked")
+	}
+
+	// Once done, all operations should be removed and their ctxs closed.
+	if len(tracker.ops) != 0 {
+		t.Fatal("tracker should be empty", len(tracker.ops))
+	}
+	select {
+	case <-ctx3.Done():
+	default:
+		t.Fatal("ctx should be closed")
+	}
+}
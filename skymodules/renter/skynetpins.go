@@ -0,0 +1,142 @@
+package renter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// SkynetPins returns the skyfiles pinned to the renter together with the time
+// their pin expires. Extended siafiles are not listed separately, instead
+// their size is added to the size of their base siafile. The pins are sorted
+// by siapath.
+func (r *Renter) SkynetPins() ([]skymodules.SkynetPin, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.managedSkynetPins()
+}
+
+// SetSkynetPinExpiry sets the time after which the skyfile at the given
+// siapath is automatically unpinned. A zero time removes the expiry.
+func (r *Renter) SetSkynetPinExpiry(siaPath skymodules.SiaPath, expiry time.Time) (err error) {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	sf, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "failed to open skyfile")
+	}
+	defer func() {
+		err = errors.Compose(err, sf.Close())
+	}()
+	if len(sf.Metadata().Skylinks) == 0 {
+		return errors.New("siafile doesn't belong to a skyfile")
+	}
+	return sf.SetPinExpiry(expiry)
+}
+
+// managedSkynetPins lists all the siafiles of the renter which track a
+// skylink.
+func (r *Renter) managedSkynetPins() ([]skymodules.SkynetPin, error) {
+	return r.managedListSkyfiles(skymodules.RootSiaPath())
+}
+
+// managedListSkyfiles lists the siafiles under the given directory which track
+// a skylink. Extended siafiles are not listed separately, instead their size
+// is added to the size of their base siafile.
+func (r *Renter) managedListSkyfiles(dir skymodules.SiaPath) ([]skymodules.SkynetPin, error) {
+	var mu sync.Mutex
+	files := make(map[skymodules.SiaPath]skymodules.FileInfo)
+	flf := func(fi skymodules.FileInfo) {
+		if len(fi.Skylinks) == 0 {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		files[fi.SiaPath] = fi
+	}
+	err := r.staticFileSystem.CachedList(dir, true, flf, func(skymodules.DirectoryInfo) {})
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to list skyfiles")
+	}
+
+	pins := make([]skymodules.SkynetPin, 0, len(files))
+	for sp, fi := range files {
+		if strings.HasSuffix(sp.String(), skymodules.ExtendedSuffix) {
+			continue
+		}
+		pin := skymodules.SkynetPin{
+			PinExpiry: fi.PinExpiry,
+			SiaPath:   sp,
+			Size:      fi.Filesize,
+			Skylinks:  fi.Skylinks,
+		}
+		extendedPath, err := sp.AddSuffixStr(skymodules.ExtendedSuffix)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to get siapath of extended siafile")
+		}
+		if extended, exists := files[extendedPath]; exists {
+			pin.Size += extended.Filesize
+		}
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].SiaPath.String() < pins[j].SiaPath.String()
+	})
+	return pins, nil
+}
+
+// managedUnpinExpiredSkyfiles deletes the skyfiles whose pin expired. Only
+// the expired siafiles are deleted and no unpin request is added for their
+// skylinks since that would also unpin the skylinks from any other siapath
+// they are pinned to.
+func (r *Renter) managedUnpinExpiredSkyfiles() error {
+	pins, err := r.managedSkynetPins()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var errs []error
+	for _, pin := range pins {
+		if pin.PinExpiry.IsZero() || pin.PinExpiry.After(now) {
+			continue
+		}
+		err = r.managedDeleteSkyfile(pin.SiaPath)
+		if err != nil {
+			errs = append(errs, errors.AddContext(err, "failed to delete "+pin.SiaPath.String()))
+			continue
+		}
+		r.staticLog.Printf("Unpinned skyfile %v after its pin expired at %v", pin.SiaPath, pin.PinExpiry)
+	}
+	return errors.Compose(errs...)
+}
+
+// threadedUnpinExpiredSkyfiles periodically unpins the skyfiles whose pin
+// expired.
+func (r *Renter) threadedUnpinExpiredSkyfiles() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	ticker := time.NewTicker(pinExpiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.tg.StopCtx().Done():
+			return // shutdown
+		case <-ticker.C:
+		}
+		if err := r.managedUnpinExpiredSkyfiles(); err != nil {
+			r.staticLog.Print("Failed to unpin expired skyfiles:", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetPinExpiry probes listing pinned skyfiles and unpinning the ones
+// with an expired pin.
+func TestSkynetPinExpiry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = rt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Helper to create a siafile with a random skylink.
+	createSkyfile := func(sp skymodules.SiaPath) {
+		_, rsc := testingFileParams()
+		sf, err := r.createRenterTestFileWithParams(sp, rsc, crypto.TypePlain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var root crypto.Hash
+		fastrand.Read(root[:])
+		skylink, err := skymodules.NewSkylinkV1(root, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sf.AddSkylink(skylink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(sp skymodules.SiaPath) bool {
+		exists, err := r.staticFileSystem.FileExists(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	// Create a skyfile without an expiry, an expired skyfile with an extended
+	// siafile, a skyfile that expires in the future and a regular siafile.
+	permanentPath, err := skymodules.SkynetFolder.Join("permanent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredPath, err := skymodules.SkynetFolder.Join("expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredExtendedPath, err := expiredPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	futurePath := skymodules.RandomSiaPath()
+	createSkyfile(permanentPath)
+	createSkyfile(expiredPath)
+	createSkyfile(expiredExtendedPath)
+	createSkyfile(futurePath)
+	sf, err := r.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	regularPath := r.staticFileSystem.FileSiaPath(sf)
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Regular siafiles can't expire.
+	if err := r.SetSkynetPinExpiry(regularPath, time.Now()); err == nil {
+		t.Fatal("expected setting the pin expiry of a regular siafile to fail")
+	}
+
+	// Set the expiries.
+	expired := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	if err := r.SetSkynetPinExpiry(expiredPath, expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetSkynetPinExpiry(futurePath, future); err != nil {
+		t.Fatal(err)
+	}
+
+	// The listing contains the three skyfiles but not the extended siafile
+	// or the regular siafile.
+	pins, err := r.SkynetPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 3 {
+		t.Fatalf("expected 3 pins but got %v", pins)
+	}
+	expiries := make(map[skymodules.SiaPath]time.Time)
+	for _, pin := range pins {
+		expiries[pin.SiaPath] = pin.PinExpiry
+	}
+	if !expiries[permanentPath].IsZero() || !expiries[expiredPath].Equal(expired) || !expiries[futurePath].Equal(future) {
+		t.Fatalf("unexpected expiries %v", expiries)
+	}
+
+	// Unpin the expired skyfiles. Only the expired skyfile and its extended
+	// siafile should be deleted.
+	if err := r.managedUnpinExpiredSkyfiles(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sp := range []skymodules.SiaPath{permanentPath, expiredPath, expiredExtendedPath, futurePath, regularPath} {
+		deleted := sp.Equals(expiredPath) || sp.Equals(expiredExtendedPath)
+		if exists(sp) == deleted {
+			t.Fatalf("%v should be deleted: %v", sp, deleted)
+		}
+	}
+
+	// Removing the expiry of the future skyfile prevents it from being
+	// unpinned.
+	if err := r.SetSkynetPinExpiry(futurePath, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	pins, err = r.SkynetPins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || !pins[0].PinExpiry.IsZero() || !pins[1].PinExpiry.IsZero() {
+		t.Fatalf("unexpected pins %v", pins)
+	}
+}
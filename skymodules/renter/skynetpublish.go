@@ -0,0 +1,91 @@
+package renter
+
+import (
+	"context"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// ErrPublishInProgress is returned when a skylink is published to a registry
+// entry which is already being updated by another publish.
+var ErrPublishInProgress = errors.New("another publish to this registry entry is in progress")
+
+// PublishSkylink points the registry entry of the registry key with the given
+// name and the data key at the provided V1 skylink. The revision number of the
+// entry is bumped automatically. The returned V2 skylink resolves to the
+// published skylink. If the entry already points at the skylink, it is not
+// updated again.
+func (r *Renter) PublishSkylink(ctx context.Context, keyName string, dataKey crypto.Hash, sl skymodules.Skylink) (skymodules.Skylink, uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.Skylink{}, 0, err
+	}
+	defer r.tg.Done()
+	if !sl.IsSkylinkV1() {
+		return skymodules.Skylink{}, 0, ErrInvalidSkylinkVersion
+	}
+	spk, err := r.staticRegistryKeyManager.PublicKey(keyName)
+	if err != nil {
+		return skymodules.Skylink{}, 0, err
+	}
+
+	// Only allow for one publish per entry at a time. Otherwise two publishes
+	// might read the same revision and race to update it.
+	done, err := r.managedStartPublish(modules.DeriveRegistryEntryID(spk, dataKey))
+	if err != nil {
+		return skymodules.Skylink{}, 0, err
+	}
+	defer done()
+
+	// Look up the current revision of the entry. If the entry can't be found,
+	// we start at revision 0. Should the lookup have missed an existing entry,
+	// the hosts will reject the update due to its lower revision.
+	readCtx, readCancel := context.WithTimeout(ctx, MaxRegistryReadTimeout)
+	defer readCancel()
+	var revision uint64
+	entry, err := r.ReadRegistry(readCtx, spk, dataKey)
+	if err == nil && entry.Type == modules.RegistryTypeWithoutPubkey && string(entry.Data) == string(sl.Bytes()) {
+		return skymodules.NewSkylinkV2(spk, dataKey), entry.Revision, nil
+	} else if err == nil {
+		revision = entry.Revision + 1
+	} else if !errors.Contains(err, ErrRegistryEntryNotFound) && !errors.Contains(err, ErrRegistryLookupTimeout) {
+		return skymodules.Skylink{}, 0, errors.AddContext(err, "failed to read current revision")
+	}
+
+	// Sign and publish the new revision.
+	ck, err := r.managedRegistryKeyCipher()
+	if err != nil {
+		return skymodules.Skylink{}, 0, err
+	}
+	rv := modules.NewRegistryValue(dataKey, sl.Bytes(), revision, modules.RegistryTypeWithoutPubkey)
+	spk, srv, err := r.staticRegistryKeyManager.Sign(keyName, rv, ck)
+	if err != nil {
+		return skymodules.Skylink{}, 0, err
+	}
+	updateCtx, updateCancel := context.WithTimeout(ctx, DefaultRegistryUpdateTimeout)
+	defer updateCancel()
+	err = r.UpdateRegistry(updateCtx, spk, srv)
+	if err != nil {
+		return skymodules.Skylink{}, 0, errors.AddContext(err, "failed to update registry")
+	}
+	return skymodules.NewSkylinkV2(spk, dataKey), revision, nil
+}
+
+// managedStartPublish marks the registry entry with the given id as being
+// published to. The returned function needs to be called once the publish is
+// done.
+func (r *Renter) managedStartPublish(rid modules.RegistryEntryID) (func(), error) {
+	r.ongoingPublishesMu.Lock()
+	defer r.ongoingPublishesMu.Unlock()
+	if _, exists := r.ongoingPublishes[rid]; exists {
+		return nil, ErrPublishInProgress
+	}
+	r.ongoingPublishes[rid] = struct{}{}
+	return func() {
+		r.ongoingPublishesMu.Lock()
+		delete(r.ongoingPublishes, rid)
+		r.ongoingPublishesMu.Unlock()
+	}, nil
+}
@@ -0,0 +1,531 @@
+package renter
+
+// skynetsearch.go implements a search over the metadata of the skyfiles pinned
+// to the renter. The renter keeps an index of the searchable fields of every
+// skyfile's metadata, grouped by the directory the skyfile is in. The index is
+// updated whenever a skyfile is uploaded, pinned, renamed or deleted. Skyfiles
+// that end up in a directory some other way are indexed the next time the
+// directory is searched, which requires fetching their metadata. A directory
+// that was indexed by an older version of the index is re-indexed from scratch
+// the first time it is searched. To keep the index small, it only stores
+// truncated names and content types instead of the full metadata.
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/opentracing/opentracing-go"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/persist"
+)
+
+const (
+	// skynetSearchIndexFilename is the name of the file within the renter's
+	// persist dir which contains the skynet search index.
+	skynetSearchIndexFilename = "skynetsearch.json"
+
+	// skynetSearchIndexVersion is the version of the indexed fields. Bumping
+	// it causes every directory to be re-indexed the next time it is
+	// searched.
+	skynetSearchIndexVersion = 1
+
+	// maxSkynetSearchStringLen is the maximum number of bytes of a name or
+	// content type that are indexed. Longer ones are truncated.
+	maxSkynetSearchStringLen = 64
+
+	// maxSkynetSearchSubfiles is the maximum number of subfile names that are
+	// indexed per skyfile.
+	maxSkynetSearchSubfiles = 32
+
+	// maxSkynetSearchContentTypes is the maximum number of distinct content
+	// types that are indexed per skyfile.
+	maxSkynetSearchContentTypes = 8
+)
+
+const (
+	// The fields of a skyfile's metadata that can match a search.
+	skynetSearchFieldContentType = "contenttype"
+	skynetSearchFieldFilename    = "filename"
+	skynetSearchFieldSize        = "size"
+	skynetSearchFieldSubfiles    = "subfiles"
+)
+
+var (
+	// skynetSearchIndexMetadata is the metadata of the skynet search index
+	// file.
+	skynetSearchIndexMetadata = persist.Metadata{
+		Header:  "Skynet Search Index",
+		Version: "1.5.7",
+	}
+
+	// skynetSearchIndexPersistInterval is the interval at which the skynet
+	// search index is persisted if it changed.
+	skynetSearchIndexPersistInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  2 * time.Second,
+	}).(time.Duration)
+
+	// skynetSearchFetchTimeout is the timeout for fetching the metadata of a
+	// skyfile that is not indexed yet.
+	skynetSearchFetchTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// skynetSearchIndex is the index of the searchable metadata of the
+	// skyfiles pinned to the renter.
+	skynetSearchIndex struct {
+		dirs       map[string]*skynetSearchDir
+		dirty      bool
+		mu         sync.Mutex
+		staticPath string
+	}
+
+	// persistedSkynetSearchIndex is the on-disk representation of the skynet
+	// search index.
+	persistedSkynetSearchIndex struct {
+		Dirs map[string]*skynetSearchDir `json:"dirs"`
+	}
+
+	// skynetSearchDir contains the index entries of the skyfiles in a
+	// directory by their siapaths together with the version of the index
+	// that created them.
+	skynetSearchDir struct {
+		Version uint64                       `json:"version"`
+		Entries map[string]skynetSearchEntry `json:"entries"`
+	}
+
+	// skynetSearchEntry contains the searchable fields of a skyfile's
+	// metadata. The names and content types are lowercased and truncated to
+	// maxSkynetSearchStringLen bytes.
+	skynetSearchEntry struct {
+		ContentTypes []string `json:"contenttypes,omitempty"`
+		Filename     string   `json:"filename"`
+		Size         uint64   `json:"size"`
+		Skylink      string   `json:"skylink"`
+		Subfiles     []string `json:"subfiles,omitempty"`
+	}
+)
+
+// newSkynetSearchIndex loads the skynet search index from the given dir. Since
+// the index can be rebuilt, an index that can't be loaded is replaced with an
+// empty one.
+func newSkynetSearchIndex(persistDir string, log *persist.Logger) *skynetSearchIndex {
+	ssi := &skynetSearchIndex{
+		dirs:       make(map[string]*skynetSearchDir),
+		staticPath: filepath.Join(persistDir, skynetSearchIndexFilename),
+	}
+	var pssi persistedSkynetSearchIndex
+	err := persist.LoadJSON(skynetSearchIndexMetadata, &pssi, ssi.staticPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("failed to load skynet search index, starting with an empty one:", err)
+		return ssi
+	}
+	for dir, ssd := range pssi.Dirs {
+		if ssd == nil {
+			continue
+		}
+		if ssd.Entries == nil {
+			ssd.Entries = make(map[string]skynetSearchEntry)
+		}
+		ssi.dirs[dir] = ssd
+	}
+	return ssi
+}
+
+// SkynetSearch searches the metadata of the skyfiles pinned under a directory.
+// It returns the requested page of matches sorted by siapath and the total
+// number of matches.
+func (r *Renter) SkynetSearch(params skymodules.SkynetSearchParameters) ([]skymodules.SkynetSearchResult, uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, 0, err
+	}
+	defer r.tg.Done()
+
+	pins, err := r.managedListSkyfiles(params.SiaPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Group the skyfiles by directory and index the ones that aren't indexed
+	// yet.
+	byDir := make(map[skymodules.SiaPath][]skymodules.SkynetPin)
+	for _, pin := range pins {
+		dir, err := pin.SiaPath.Dir()
+		if err != nil {
+			return nil, 0, errors.AddContext(err, "failed to get dir of skyfile")
+		}
+		byDir[dir] = append(byDir[dir], pin)
+	}
+	for dir, dirPins := range byDir {
+		r.managedIndexSkynetSearchDir(dir, dirPins)
+	}
+	results, total := r.staticSkynetSearchIndex.callSearch(pins, params)
+	return results, total, nil
+}
+
+// managedIndexSkynetSearchDir brings the index of a directory up-to-date with
+// the skyfiles in it. The skyfiles without a valid index entry are indexed by
+// fetching their metadata. If the directory was indexed by an older version of
+// the index, all of its skyfiles are indexed again. Skyfiles whose metadata
+// can't be fetched are skipped until the next search.
+func (r *Renter) managedIndexSkynetSearchDir(dir skymodules.SiaPath, pins []skymodules.SkynetPin) {
+	missing := r.staticSkynetSearchIndex.callPrepareDir(dir, pins)
+	entries := make(map[string]skynetSearchEntry, len(missing))
+	for _, pin := range missing {
+		entry, err := r.managedFetchSkynetSearchEntry(pin)
+		if err != nil {
+			r.staticLog.Printf("failed to index skyfile %v for search: %v", pin.SiaPath, err)
+			continue
+		}
+		entries[pin.SiaPath.String()] = entry
+	}
+	r.staticSkynetSearchIndex.callAddDirEntries(dir, entries)
+}
+
+// managedFetchSkynetSearchEntry fetches the metadata of a skyfile and turns it
+// into an index entry.
+func (r *Renter) managedFetchSkynetSearchEntry(pin skymodules.SkynetPin) (skynetSearchEntry, error) {
+	var errs []error
+	for _, skylinkStr := range pin.Skylinks {
+		var skylink skymodules.Skylink
+		err := skylink.LoadString(skylinkStr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		span := opentracing.StartSpan("managedFetchSkynetSearchEntry")
+		span.SetTag("skylink", skylinkStr)
+		ctx, cancel := context.WithTimeout(r.tg.StopCtx(), skynetSearchFetchTimeout)
+		ctx = opentracing.ContextWithSpan(ctx, span)
+		streamer, err := r.managedDownloadSkylink(ctx, skylink, nil, skynetSearchFetchTimeout, skymodules.DefaultSkynetPricePerMS)
+		if err != nil {
+			cancel()
+			span.Finish()
+			errs = append(errs, err)
+			continue
+		}
+		md := streamer.Metadata()
+		err = streamer.Close()
+		cancel()
+		span.Finish()
+		if err != nil {
+			r.staticLog.Printf("failed to close streamer of %v: %v", skylink, err)
+		}
+		return newSkynetSearchEntry(skylink, md), nil
+	}
+	return skynetSearchEntry{}, errors.AddContext(errors.Compose(errs...), "failed to fetch metadata")
+}
+
+// threadedPersistSkynetSearchIndex periodically persists the skynet search
+// index if it changed.
+func (r *Renter) threadedPersistSkynetSearchIndex() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	ticker := time.NewTicker(skynetSearchIndexPersistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.tg.StopCtx().Done():
+			return // shutdown
+		case <-ticker.C:
+		}
+		err := r.staticSkynetSearchIndex.callSave()
+		if err != nil {
+			r.staticLog.Println("Failed to persist skynet search index:", err)
+		}
+	}
+}
+
+// callAdd adds or replaces the entry of the skyfile at the given siapath.
+func (ssi *skynetSearchIndex) callAdd(siaPath skymodules.SiaPath, skylink skymodules.Skylink, md skymodules.SkyfileMetadata) {
+	dir, err := siaPath.Dir()
+	if err != nil {
+		return
+	}
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	ssi.dir(dir).Entries[siaPath.String()] = newSkynetSearchEntry(skylink, md)
+	ssi.dirty = true
+}
+
+// callAddDirEntries adds the given entries to a directory.
+func (ssi *skynetSearchIndex) callAddDirEntries(dir skymodules.SiaPath, entries map[string]skynetSearchEntry) {
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	ssd := ssi.dir(dir)
+	for siaPath, entry := range entries {
+		ssd.Entries[siaPath] = entry
+		ssi.dirty = true
+	}
+}
+
+// callPrepareDir drops the entries of a directory which don't belong to one of
+// the given skyfiles anymore and returns the skyfiles which need to be
+// indexed. If the directory was indexed by an older version, all of its
+// entries are dropped.
+func (ssi *skynetSearchIndex) callPrepareDir(dir skymodules.SiaPath, pins []skymodules.SkynetPin) []skymodules.SkynetPin {
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	ssd := ssi.dir(dir)
+	if ssd.Version != skynetSearchIndexVersion {
+		ssd.Version = skynetSearchIndexVersion
+		ssd.Entries = make(map[string]skynetSearchEntry)
+		ssi.dirty = true
+	}
+	listed := make(map[string]struct{}, len(pins))
+	var missing []skymodules.SkynetPin
+	for _, pin := range pins {
+		siaPath := pin.SiaPath.String()
+		listed[siaPath] = struct{}{}
+		entry, exists := ssd.Entries[siaPath]
+		if !exists || !isSkylinkIn(entry.Skylink, pin.Skylinks) {
+			missing = append(missing, pin)
+		}
+	}
+	for siaPath := range ssd.Entries {
+		if _, exists := listed[siaPath]; !exists {
+			delete(ssd.Entries, siaPath)
+			ssi.dirty = true
+		}
+	}
+	return missing
+}
+
+// callRemove removes the entry of the skyfile at the given siapath.
+func (ssi *skynetSearchIndex) callRemove(siaPath skymodules.SiaPath) {
+	dir, err := siaPath.Dir()
+	if err != nil {
+		return
+	}
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	ssi.remove(dir, siaPath)
+}
+
+// callRename moves the entry of a skyfile to a new siapath.
+func (ssi *skynetSearchIndex) callRename(oldSiaPath, newSiaPath skymodules.SiaPath) {
+	oldDir, err1 := oldSiaPath.Dir()
+	newDir, err2 := newSiaPath.Dir()
+	if err1 != nil || err2 != nil {
+		return
+	}
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	ssd, exists := ssi.dirs[oldDir.String()]
+	if !exists {
+		return
+	}
+	entry, exists := ssd.Entries[oldSiaPath.String()]
+	if !exists {
+		return
+	}
+	ssi.remove(oldDir, oldSiaPath)
+	ssi.dir(newDir).Entries[newSiaPath.String()] = entry
+	ssi.dirty = true
+}
+
+// callSave persists the index if it changed since it was last persisted.
+func (ssi *skynetSearchIndex) callSave() error {
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	if !ssi.dirty {
+		return nil
+	}
+	err := persist.SaveJSON(skynetSearchIndexMetadata, persistedSkynetSearchIndex{
+		Dirs: ssi.dirs,
+	}, ssi.staticPath)
+	if err != nil {
+		return err
+	}
+	ssi.dirty = false
+	return nil
+}
+
+// callSearch returns the requested page of the given skyfiles which match the
+// search parameters together with the total number of matches. The skyfiles
+// need to be sorted by siapath. Skyfiles without a valid index entry don't
+// match.
+func (ssi *skynetSearchIndex) callSearch(pins []skymodules.SkynetPin, params skymodules.SkynetSearchParameters) ([]skymodules.SkynetSearchResult, uint64) {
+	ssi.mu.Lock()
+	defer ssi.mu.Unlock()
+	results := []skymodules.SkynetSearchResult{}
+	var total uint64
+	for _, pin := range pins {
+		dir, err := pin.SiaPath.Dir()
+		if err != nil {
+			continue
+		}
+		ssd, exists := ssi.dirs[dir.String()]
+		if !exists {
+			continue
+		}
+		entry, exists := ssd.Entries[pin.SiaPath.String()]
+		if !exists || !isSkylinkIn(entry.Skylink, pin.Skylinks) {
+			continue
+		}
+		matched, ok := entry.match(params)
+		if !ok {
+			continue
+		}
+		total++
+		if total <= params.Offset || uint64(len(results)) >= params.Limit {
+			continue
+		}
+		results = append(results, skymodules.SkynetSearchResult{
+			Matched: matched,
+			SiaPath: pin.SiaPath,
+			Size:    entry.Size,
+			Skylink: entry.Skylink,
+		})
+	}
+	return results, total
+}
+
+// dir returns the index of the given directory and creates it if it doesn't
+// exist.
+func (ssi *skynetSearchIndex) dir(dir skymodules.SiaPath) *skynetSearchDir {
+	ssd, exists := ssi.dirs[dir.String()]
+	if !exists {
+		ssd = &skynetSearchDir{
+			Version: skynetSearchIndexVersion,
+			Entries: make(map[string]skynetSearchEntry),
+		}
+		ssi.dirs[dir.String()] = ssd
+	}
+	return ssd
+}
+
+// remove removes the entry of the skyfile at the given siapath and the
+// directory's index if it became empty.
+func (ssi *skynetSearchIndex) remove(dir, siaPath skymodules.SiaPath) {
+	ssd, exists := ssi.dirs[dir.String()]
+	if !exists {
+		return
+	}
+	if _, exists := ssd.Entries[siaPath.String()]; !exists {
+		return
+	}
+	delete(ssd.Entries, siaPath.String())
+	if len(ssd.Entries) == 0 {
+		delete(ssi.dirs, dir.String())
+	}
+	ssi.dirty = true
+}
+
+// newSkynetSearchEntry creates the index entry of a skyfile from its metadata.
+func newSkynetSearchEntry(skylink skymodules.Skylink, md skymodules.SkyfileMetadata) skynetSearchEntry {
+	entry := skynetSearchEntry{
+		Filename: truncateSkynetSearchString(md.Filename),
+		Size:     md.Length,
+		Skylink:  skylink.String(),
+	}
+	keys := make([]string, 0, len(md.Subfiles))
+	for key := range md.Subfiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seenSubfiles := make(map[string]struct{})
+	seenContentTypes := make(map[string]struct{})
+	for _, key := range keys {
+		sub := md.Subfiles[key]
+		name := truncateSkynetSearchString(sub.Filename)
+		if _, seen := seenSubfiles[name]; !seen && len(entry.Subfiles) < maxSkynetSearchSubfiles {
+			seenSubfiles[name] = struct{}{}
+			entry.Subfiles = append(entry.Subfiles, name)
+		}
+		// Only the media type is indexed, without any parameters.
+		ct := strings.TrimSpace(strings.SplitN(sub.ContentType, ";", 2)[0])
+		ct = truncateSkynetSearchString(ct)
+		if _, seen := seenContentTypes[ct]; ct != "" && !seen && len(entry.ContentTypes) < maxSkynetSearchContentTypes {
+			seenContentTypes[ct] = struct{}{}
+			entry.ContentTypes = append(entry.ContentTypes, ct)
+		}
+	}
+	return entry
+}
+
+// match returns whether the entry matches the search parameters and which of
+// its fields matched. Search parameters that are unset don't appear in the
+// matched fields.
+func (entry skynetSearchEntry) match(params skymodules.SkynetSearchParameters) ([]string, bool) {
+	matched := []string{}
+	if params.Filename != "" {
+		query := strings.ToLower(params.Filename)
+		var ok bool
+		if strings.Contains(entry.Filename, query) {
+			matched = append(matched, skynetSearchFieldFilename)
+			ok = true
+		}
+		for _, sub := range entry.Subfiles {
+			if strings.Contains(sub, query) {
+				matched = append(matched, skynetSearchFieldSubfiles)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	if params.ContentType != "" {
+		query := strings.ToLower(params.ContentType)
+		var ok bool
+		for _, ct := range entry.ContentTypes {
+			if strings.HasPrefix(ct, query) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		matched = append(matched, skynetSearchFieldContentType)
+	}
+	if params.MinSize > 0 || params.MaxSize > 0 {
+		if entry.Size < params.MinSize || (params.MaxSize > 0 && entry.Size > params.MaxSize) {
+			return nil, false
+		}
+		matched = append(matched, skynetSearchFieldSize)
+	}
+	return matched, true
+}
+
+// isSkylinkIn returns true if the skylink is one of the given skylinks.
+func isSkylinkIn(skylink string, skylinks []string) bool {
+	for _, sl := range skylinks {
+		if sl == skylink {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateSkynetSearchString lowercases a string and truncates it to at most
+// maxSkynetSearchStringLen bytes without splitting a character.
+func truncateSkynetSearchString(s string) string {
+	s = strings.ToLower(s)
+	if len(s) <= maxSkynetSearchStringLen {
+		return s
+	}
+	s = s[:maxSkynetSearchStringLen]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
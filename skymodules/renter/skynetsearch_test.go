@@ -0,0 +1,240 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/persist"
+)
+
+// TestNewSkynetSearchEntry is a unit test for newSkynetSearchEntry.
+func TestNewSkynetSearchEntry(t *testing.T) {
+	t.Parallel()
+
+	var skylink skymodules.Skylink
+	longName := strings.Repeat("ä", maxSkynetSearchStringLen)
+	md := skymodules.SkyfileMetadata{
+		Filename: "Dir",
+		Length:   42,
+		Subfiles: skymodules.SkyfileSubfiles{
+			"b.html":  {Filename: "b.html", ContentType: "text/html; charset=utf-8"},
+			"a.HTML":  {Filename: "a.HTML", ContentType: "TEXT/HTML"},
+			"c.png":   {Filename: "c.png", ContentType: "image/png"},
+			longName:  {Filename: longName},
+			"symlink": {Filename: "symlink", SymlinkTarget: "c.png"},
+		},
+	}
+	for i := 0; i < maxSkynetSearchSubfiles+maxSkynetSearchContentTypes; i++ {
+		name := fmt.Sprintf("z%03d", i)
+		md.Subfiles[name] = skymodules.SkyfileSubfileMetadata{
+			Filename:    name,
+			ContentType: fmt.Sprintf("application/x-%d", i),
+		}
+	}
+	entry := newSkynetSearchEntry(skylink, md)
+
+	// Names and content types are lowercased and deduplicated.
+	if entry.Filename != "dir" || entry.Size != 42 || entry.Skylink != skylink.String() {
+		t.Fatal("unexpected entry", entry)
+	}
+	if entry.Subfiles[0] != "a.html" || entry.Subfiles[1] != "b.html" || entry.Subfiles[2] != "c.png" {
+		t.Fatal("unexpected subfiles", entry.Subfiles)
+	}
+	if entry.ContentTypes[0] != "text/html" || entry.ContentTypes[1] != "image/png" {
+		t.Fatal("unexpected content types", entry.ContentTypes)
+	}
+
+	// The number of names and content types is capped.
+	if len(entry.Subfiles) != maxSkynetSearchSubfiles {
+		t.Fatal("wrong number of subfiles", len(entry.Subfiles))
+	}
+	if len(entry.ContentTypes) != maxSkynetSearchContentTypes {
+		t.Fatal("wrong number of content types", len(entry.ContentTypes))
+	}
+
+	// Long names are truncated without splitting characters.
+	truncated := truncateSkynetSearchString(longName)
+	if truncated != strings.Repeat("ä", maxSkynetSearchStringLen/2) {
+		t.Fatal("unexpected truncation", truncated)
+	}
+	if truncated := truncateSkynetSearchString("x" + longName); len(truncated) != maxSkynetSearchStringLen-1 {
+		t.Fatal("unexpected truncation", truncated)
+	}
+}
+
+// TestSkynetSearchIndex probes adding, searching, renaming and removing
+// entries of the skynet search index as well as persisting it.
+func TestSkynetSearchIndex(t *testing.T) {
+	t.Parallel()
+
+	testDir := build.TempDir("renter", t.Name())
+	err := os.RemoveAll(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(testDir, skymodules.DefaultDirPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := persist.NewLogger(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssi := newSkynetSearchIndex(testDir, log)
+
+	// Add a few skyfiles in two directories.
+	sp := func(s string) skymodules.SiaPath {
+		siaPath, err := skymodules.SkynetFolder.Join(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPath
+	}
+	var pins []skymodules.SkynetPin
+	add := func(siaPath skymodules.SiaPath, md skymodules.SkyfileMetadata) {
+		skylink, err := skymodules.NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(10)), 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ssi.callAdd(siaPath, skylink, md)
+		pins = append(pins, skymodules.SkynetPin{
+			SiaPath:  siaPath,
+			Skylinks: []string{skylink.String()},
+		})
+	}
+	add(sp("a/cat.png"), skymodules.SkyfileMetadata{
+		Filename: "cat.png",
+		Length:   100,
+		Subfiles: skymodules.SkyfileSubfiles{"cat.png": {Filename: "cat.png", ContentType: "image/png"}},
+	})
+	add(sp("a/dog.jpg"), skymodules.SkyfileMetadata{
+		Filename: "dog.jpg",
+		Length:   200,
+		Subfiles: skymodules.SkyfileSubfiles{"dog.jpg": {Filename: "dog.jpg", ContentType: "image/jpeg"}},
+	})
+	add(sp("a/site"), skymodules.SkyfileMetadata{
+		Filename: "site",
+		Length:   300,
+		Subfiles: skymodules.SkyfileSubfiles{
+			"index.html": {Filename: "index.html", ContentType: "text/html"},
+			"Cat.gif":    {Filename: "Cat.gif", ContentType: "image/gif"},
+		},
+	})
+	add(sp("b/notes.txt"), skymodules.SkyfileMetadata{
+		Filename: "notes.txt",
+		Length:   400,
+		Subfiles: skymodules.SkyfileSubfiles{"notes.txt": {Filename: "notes.txt", ContentType: "text/plain"}},
+	})
+
+	// search returns the siapaths and matched fields of the matches.
+	search := func(params skymodules.SkynetSearchParameters) ([]string, uint64) {
+		if params.Limit == 0 {
+			params.Limit = 100
+		}
+		results, total := ssi.callSearch(pins, params)
+		var found []string
+		for _, result := range results {
+			found = append(found, strings.TrimPrefix(result.SiaPath.String(), skymodules.SkynetFolder.String()+"/")+":"+strings.Join(result.Matched, ","))
+		}
+		return found, total
+	}
+	tests := []struct {
+		params   skymodules.SkynetSearchParameters
+		expected []string
+	}{
+		{skymodules.SkynetSearchParameters{}, []string{"a/cat.png:", "a/dog.jpg:", "a/site:", "b/notes.txt:"}},
+		{skymodules.SkynetSearchParameters{Filename: "CAT"}, []string{"a/cat.png:filename,subfiles", "a/site:subfiles"}},
+		{skymodules.SkynetSearchParameters{ContentType: "image/"}, []string{"a/cat.png:contenttype", "a/dog.jpg:contenttype", "a/site:contenttype"}},
+		{skymodules.SkynetSearchParameters{ContentType: "text/"}, []string{"a/site:contenttype", "b/notes.txt:contenttype"}},
+		{skymodules.SkynetSearchParameters{MinSize: 200, MaxSize: 300}, []string{"a/dog.jpg:size", "a/site:size"}},
+		{skymodules.SkynetSearchParameters{MinSize: 250}, []string{"a/site:size", "b/notes.txt:size"}},
+		{skymodules.SkynetSearchParameters{Filename: "cat", ContentType: "text/html"}, []string{"a/site:subfiles,contenttype"}},
+		{skymodules.SkynetSearchParameters{Filename: "unknown"}, nil},
+	}
+	for _, test := range tests {
+		found, total := search(test.params)
+		if !reflect.DeepEqual(found, test.expected) || total != uint64(len(test.expected)) {
+			t.Fatalf("unexpected results for %+v: %v %v", test.params, found, total)
+		}
+	}
+
+	// Page through the results.
+	found, total := search(skymodules.SkynetSearchParameters{Offset: 1, Limit: 2})
+	if !reflect.DeepEqual(found, []string{"a/dog.jpg:", "a/site:"}) || total != 4 {
+		t.Fatal("unexpected page", found, total)
+	}
+	found, total = search(skymodules.SkynetSearchParameters{Offset: 3, Limit: 2})
+	if !reflect.DeepEqual(found, []string{"b/notes.txt:"}) || total != 4 {
+		t.Fatal("unexpected page", found, total)
+	}
+	found, total = search(skymodules.SkynetSearchParameters{Offset: 4, Limit: 2})
+	if len(found) != 0 || total != 4 {
+		t.Fatal("unexpected page", found, total)
+	}
+
+	// A skyfile with another skylink than the indexed one needs to be indexed
+	// again and doesn't match until it is.
+	pins[0].Skylinks = []string{"other"}
+	if found, _ := search(skymodules.SkynetSearchParameters{Filename: "cat.png"}); len(found) != 0 {
+		t.Fatal("stale entry shouldn't match", found)
+	}
+	dirA, err := pins[0].SiaPath.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := ssi.callPrepareDir(dirA, pins[:3])
+	if len(missing) != 1 || !missing[0].SiaPath.Equals(pins[0].SiaPath) {
+		t.Fatal("unexpected missing skyfiles", missing)
+	}
+
+	// Entries of skyfiles that aren't in the directory anymore are dropped.
+	missing = ssi.callPrepareDir(dirA, pins[1:3])
+	if len(missing) != 0 || len(ssi.dirs[dirA.String()].Entries) != 2 {
+		t.Fatal("entry wasn't dropped", missing, len(ssi.dirs[dirA.String()].Entries))
+	}
+	pins = pins[1:]
+
+	// Rename and remove a skyfile.
+	ssi.callRename(pins[0].SiaPath, sp("b/dog.jpg"))
+	pins[0].SiaPath = sp("b/dog.jpg")
+	ssi.callRemove(pins[1].SiaPath)
+	pins = pins[:1:1]
+	pins = append(pins, skymodules.SkynetPin{SiaPath: sp("b/notes.txt"), Skylinks: []string{ssi.dirs[sp("b").String()].Entries[sp("b/notes.txt").String()].Skylink}})
+	if found, _ := search(skymodules.SkynetSearchParameters{}); !reflect.DeepEqual(found, []string{"b/dog.jpg:", "b/notes.txt:"}) {
+		t.Fatal("unexpected results", found)
+	}
+	if _, exists := ssi.dirs[dirA.String()]; exists {
+		t.Fatal("empty directory should have been removed")
+	}
+
+	// Persist the index and load it again.
+	if err := ssi.callSave(); err != nil {
+		t.Fatal(err)
+	}
+	ssi = newSkynetSearchIndex(testDir, log)
+	if found, _ := search(skymodules.SkynetSearchParameters{Filename: "dog"}); !reflect.DeepEqual(found, []string{"b/dog.jpg:filename,subfiles"}) {
+		t.Fatal("unexpected results after reload", found)
+	}
+
+	// A directory indexed by an older version needs to be indexed again.
+	dirB, err := pins[0].SiaPath.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssi.dirs[dirB.String()].Version--
+	missing = ssi.callPrepareDir(dirB, pins)
+	if len(missing) != len(pins) || ssi.dirs[dirB.String()].Version != skynetSearchIndexVersion {
+		t.Fatal("directory should be indexed again", len(missing))
+	}
+	if found, _ := search(skymodules.SkynetSearchParameters{}); len(found) != 0 {
+		t.Fatal("outdated entries shouldn't match", found)
+	}
+}
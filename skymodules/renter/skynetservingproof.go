@@ -0,0 +1,42 @@
+package renter
+
+import (
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// managedInitSkynetPortalKey generates the keypair which is used to sign
+// serving proofs if the renter doesn't have one yet.
+func (r *Renter) managedInitSkynetPortalKey() error {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.persist.SkynetPortalSecretKey != (crypto.SecretKey{}) {
+		return nil
+	}
+	r.persist.SkynetPortalSecretKey, _ = crypto.GenerateKeyPair()
+	return r.saveSync()
+}
+
+// SkynetPortalKey returns the public key which serving proofs are signed with.
+func (r *Renter) SkynetPortalKey() (types.SiaPublicKey, error) {
+	if err := r.tg.Add(); err != nil {
+		return types.SiaPublicKey{}, err
+	}
+	defer r.tg.Done()
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return types.Ed25519PublicKey(r.persist.SkynetPortalSecretKey.PublicKey()), nil
+}
+
+// SignSkynetServingProof signs the given serving proof with the portal key.
+func (r *Renter) SignSkynetServingProof(proof skymodules.SkynetServingProof) (skymodules.SkynetServingProof, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkynetServingProof{}, err
+	}
+	defer r.tg.Done()
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	proof.Signature = crypto.SignHash(proof.SigHash(), r.persist.SkynetPortalSecretKey)
+	return proof, nil
+}
@@ -0,0 +1,415 @@
+package renter
+
+// skynetsnapshots.go implements snapshots of the skylinks pinned under a
+// directory. Backup tooling takes a snapshot after every sync and diffs it
+// against the current state on the next sync to find the skylinks which were
+// added, removed or moved in the meantime. Every snapshot is persisted in its
+// own file. To keep them compact, the skylinks are stored sorted with an index
+// into a table of the siapaths they are pinned at.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/persist"
+)
+
+const (
+	// skynetSnapshotsDir is the name of the directory within the renter's
+	// persist dir which contains the skynet snapshots.
+	skynetSnapshotsDir = "skynetsnapshots"
+
+	// skynetSnapshotExtension is the extension of a skynet snapshot file.
+	skynetSnapshotExtension = ".json"
+
+	// skynetSnapshotTempSuffix is the suffix of the backup copy that
+	// persist.SaveJSON keeps next to every snapshot file.
+	skynetSnapshotTempSuffix = "_temp"
+
+	// maxSkynetSnapshotNameLen is the maximum length of the name of a skynet
+	// snapshot.
+	maxSkynetSnapshotNameLen = 64
+)
+
+var (
+	// ErrSkynetSnapshotNotFound is returned if there is no skynet snapshot
+	// with a given name.
+	ErrSkynetSnapshotNotFound = errors.New("skynet snapshot not found")
+
+	// ErrSkynetSnapshotInvalidName is returned when trying to create a skynet
+	// snapshot with an invalid name.
+	ErrSkynetSnapshotInvalidName = errors.New("skynet snapshot name must consist of up to 64 letters, digits, '-', '_' or '.' and can't be 'now'")
+
+	// skynetSnapshotMetadata is the metadata of a skynet snapshot file.
+	skynetSnapshotMetadata = persist.Metadata{
+		Header:  "Skynet Snapshot",
+		Version: "1.5.7",
+	}
+)
+
+type (
+	// skynetSnapshotManager manages the skynet snapshots of the renter.
+	skynetSnapshotManager struct {
+		staticDir string
+		mu        sync.Mutex
+	}
+
+	// persistedSkynetSnapshot is the on-disk representation of a skynet
+	// snapshot. The entries are sorted by skylink and refer to the path
+	// table by index.
+	persistedSkynetSnapshot struct {
+		Created time.Time                      `json:"created"`
+		Name    string                         `json:"name"`
+		SiaPath skymodules.SiaPath             `json:"siapath"`
+		Paths   []persistedSkynetSnapshotPath  `json:"paths"`
+		Entries []persistedSkynetSnapshotEntry `json:"entries"`
+	}
+
+	// persistedSkynetSnapshotPath is an entry of the path table of a
+	// persisted skynet snapshot.
+	persistedSkynetSnapshotPath struct {
+		SiaPath skymodules.SiaPath `json:"siapath"`
+		Size    uint64             `json:"size"`
+	}
+
+	// persistedSkynetSnapshotEntry is a skylink together with the index of
+	// the siapath it is pinned at within the path table.
+	persistedSkynetSnapshotEntry struct {
+		Skylink string `json:"skylink"`
+		Path    uint64 `json:"path"`
+	}
+)
+
+// newSkynetSnapshotManager creates a new skynetSnapshotManager which persists
+// its snapshots in a subdirectory of the given dir.
+func newSkynetSnapshotManager(persistDir string) (*skynetSnapshotManager, error) {
+	ssm := &skynetSnapshotManager{
+		staticDir: filepath.Join(persistDir, skynetSnapshotsDir),
+	}
+	err := os.MkdirAll(ssm.staticDir, skymodules.DefaultDirPerm)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create skynet snapshots dir")
+	}
+	return ssm, nil
+}
+
+// SkynetSnapshotCreate captures the skylinks pinned under the given directory
+// in a snapshot with the given name. An existing snapshot with the same name
+// is replaced. Once there are more than maxSkynetSnapshots snapshots, the
+// oldest ones are pruned.
+func (r *Renter) SkynetSnapshotCreate(name string, dir skymodules.SiaPath) (skymodules.SkynetSnapshot, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkynetSnapshot{}, err
+	}
+	defer r.tg.Done()
+
+	if !isValidSkynetSnapshotName(name) {
+		return skymodules.SkynetSnapshot{}, ErrSkynetSnapshotInvalidName
+	}
+	created := time.Now()
+	pins, err := r.managedListSkyfiles(dir)
+	if err != nil {
+		return skymodules.SkynetSnapshot{}, err
+	}
+	entries := skynetSnapshotEntries(pins)
+	err = r.staticSkynetSnapshotManager.callSave(newPersistedSkynetSnapshot(name, dir, created, entries))
+	if err != nil {
+		return skymodules.SkynetSnapshot{}, errors.AddContext(err, "failed to save skynet snapshot")
+	}
+	return skymodules.SkynetSnapshot{
+		Created:    created,
+		Name:       name,
+		NumEntries: uint64(len(entries)),
+		SiaPath:    dir,
+	}, nil
+}
+
+// SkynetSnapshotDiff returns the skylinks which were added, removed or moved
+// between two snapshots. Either of them can be skymodules.SkynetSnapshotNow to
+// compare against the current state of the other snapshot's directory.
+func (r *Renter) SkynetSnapshotDiff(from, to string) (skymodules.SkynetSnapshotDiff, error) {
+	if err := r.tg.Add(); err != nil {
+		return skymodules.SkynetSnapshotDiff{}, err
+	}
+	defer r.tg.Done()
+
+	if from == skymodules.SkynetSnapshotNow && to == skymodules.SkynetSnapshotNow {
+		return skymodules.SkynetSnapshotDiff{}, errors.New("at least one of the snapshots can't be 'now'")
+	}
+
+	// Load the snapshots which aren't "now" first to know the directory of
+	// the current state.
+	var fromEntries, toEntries []skymodules.SkynetSnapshotEntry
+	var dir skymodules.SiaPath
+	var err error
+	if from != skymodules.SkynetSnapshotNow {
+		fromEntries, dir, err = r.staticSkynetSnapshotManager.callLoad(from)
+		if err != nil {
+			return skymodules.SkynetSnapshotDiff{}, errors.AddContext(err, "failed to load 'from' snapshot")
+		}
+	}
+	if to != skymodules.SkynetSnapshotNow {
+		toEntries, dir, err = r.staticSkynetSnapshotManager.callLoad(to)
+		if err != nil {
+			return skymodules.SkynetSnapshotDiff{}, errors.AddContext(err, "failed to load 'to' snapshot")
+		}
+	}
+	if from == skymodules.SkynetSnapshotNow || to == skymodules.SkynetSnapshotNow {
+		pins, err := r.managedListSkyfiles(dir)
+		if err != nil {
+			return skymodules.SkynetSnapshotDiff{}, err
+		}
+		if from == skymodules.SkynetSnapshotNow {
+			fromEntries = skynetSnapshotEntries(pins)
+		} else {
+			toEntries = skynetSnapshotEntries(pins)
+		}
+	}
+	return diffSkynetSnapshots(fromEntries, toEntries), nil
+}
+
+// callLoad loads the entries and the directory of the snapshot with the given
+// name.
+func (ssm *skynetSnapshotManager) callLoad(name string) ([]skymodules.SkynetSnapshotEntry, skymodules.SiaPath, error) {
+	if !isValidSkynetSnapshotName(name) {
+		return nil, skymodules.SiaPath{}, ErrSkynetSnapshotNotFound
+	}
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+	var pss persistedSkynetSnapshot
+	err := persist.LoadJSON(skynetSnapshotMetadata, &pss, ssm.path(name))
+	if os.IsNotExist(err) {
+		return nil, skymodules.SiaPath{}, ErrSkynetSnapshotNotFound
+	}
+	if err != nil {
+		return nil, skymodules.SiaPath{}, err
+	}
+	entries := make([]skymodules.SkynetSnapshotEntry, 0, len(pss.Entries))
+	for _, entry := range pss.Entries {
+		if entry.Path >= uint64(len(pss.Paths)) {
+			return nil, skymodules.SiaPath{}, errors.New("snapshot entry refers to unknown path")
+		}
+		path := pss.Paths[entry.Path]
+		entries = append(entries, skymodules.SkynetSnapshotEntry{
+			SiaPath: path.SiaPath,
+			Size:    path.Size,
+			Skylink: entry.Skylink,
+		})
+	}
+	return entries, pss.SiaPath, nil
+}
+
+// callSave persists the given snapshot and prunes the oldest snapshots if
+// there are more than maxSkynetSnapshots.
+func (ssm *skynetSnapshotManager) callSave(pss persistedSkynetSnapshot) error {
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+	err := persist.SaveJSON(skynetSnapshotMetadata, pss, ssm.path(pss.Name))
+	if err != nil {
+		return err
+	}
+
+	// Prune the oldest snapshots.
+	fis, err := ioutil.ReadDir(ssm.staticDir)
+	if err != nil {
+		return errors.AddContext(err, "failed to read skynet snapshots dir")
+	}
+	var snapshots []os.FileInfo
+	for _, fi := range fis {
+		if !fi.IsDir() && filepath.Ext(fi.Name()) == skynetSnapshotExtension {
+			snapshots = append(snapshots, fi)
+		}
+	}
+	if len(snapshots) <= maxSkynetSnapshots {
+		return nil
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].ModTime().Equal(snapshots[j].ModTime()) {
+			return snapshots[i].ModTime().Before(snapshots[j].ModTime())
+		}
+		return snapshots[i].Name() < snapshots[j].Name()
+	})
+	var errs []error
+	for _, fi := range snapshots[:len(snapshots)-maxSkynetSnapshots] {
+		if fi.Name() == pss.Name+skynetSnapshotExtension {
+			continue // never prune the new snapshot
+		}
+		path := filepath.Join(ssm.staticDir, fi.Name())
+		errs = append(errs, os.Remove(path))
+		if err := os.Remove(path + skynetSnapshotTempSuffix); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.AddContext(errors.Compose(errs...), "failed to prune skynet snapshots")
+}
+
+// path returns the path of the file of the snapshot with the given name.
+func (ssm *skynetSnapshotManager) path(name string) string {
+	return filepath.Join(ssm.staticDir, name+skynetSnapshotExtension)
+}
+
+// newPersistedSkynetSnapshot creates the on-disk representation of a snapshot
+// with the given entries.
+func newPersistedSkynetSnapshot(name string, dir skymodules.SiaPath, created time.Time, entries []skymodules.SkynetSnapshotEntry) persistedSkynetSnapshot {
+	pss := persistedSkynetSnapshot{
+		Created: created,
+		Name:    name,
+		SiaPath: dir,
+		Paths:   []persistedSkynetSnapshotPath{},
+		Entries: make([]persistedSkynetSnapshotEntry, 0, len(entries)),
+	}
+	paths := make(map[skymodules.SiaPath]uint64)
+	for _, entry := range entries {
+		index, exists := paths[entry.SiaPath]
+		if !exists {
+			index = uint64(len(pss.Paths))
+			paths[entry.SiaPath] = index
+			pss.Paths = append(pss.Paths, persistedSkynetSnapshotPath{
+				SiaPath: entry.SiaPath,
+				Size:    entry.Size,
+			})
+		}
+		pss.Entries = append(pss.Entries, persistedSkynetSnapshotEntry{
+			Skylink: entry.Skylink,
+			Path:    index,
+		})
+	}
+	sort.Slice(pss.Entries, func(i, j int) bool {
+		if pss.Entries[i].Skylink != pss.Entries[j].Skylink {
+			return pss.Entries[i].Skylink < pss.Entries[j].Skylink
+		}
+		return pss.Entries[i].Path < pss.Entries[j].Path
+	})
+	return pss
+}
+
+// skynetSnapshotEntries turns the given pinned skyfiles into snapshot entries.
+// A skyfile with multiple skylinks results in one entry per skylink.
+func skynetSnapshotEntries(pins []skymodules.SkynetPin) []skymodules.SkynetSnapshotEntry {
+	var entries []skymodules.SkynetSnapshotEntry
+	for _, pin := range pins {
+		for _, skylink := range pin.Skylinks {
+			entries = append(entries, skymodules.SkynetSnapshotEntry{
+				SiaPath: pin.SiaPath,
+				Size:    pin.Size,
+				Skylink: skylink,
+			})
+		}
+	}
+	return entries
+}
+
+// diffSkynetSnapshots returns the difference between the entries of two
+// snapshots. A skylink that is pinned at a siapath in the newer snapshot that
+// it wasn't pinned at in the older one counts as moved if it was pinned at a
+// siapath in the older snapshot that it isn't pinned at anymore. Otherwise it
+// counts as added. The remaining siapaths of the older snapshot count as
+// removed.
+func diffSkynetSnapshots(from, to []skymodules.SkynetSnapshotEntry) skymodules.SkynetSnapshotDiff {
+	// Group the entries by skylink.
+	fromBySkylink := make(map[string][]skymodules.SkynetSnapshotEntry)
+	for _, entry := range from {
+		fromBySkylink[entry.Skylink] = append(fromBySkylink[entry.Skylink], entry)
+	}
+	toBySkylink := make(map[string][]skymodules.SkynetSnapshotEntry)
+	for _, entry := range to {
+		toBySkylink[entry.Skylink] = append(toBySkylink[entry.Skylink], entry)
+	}
+
+	diff := skymodules.SkynetSnapshotDiff{
+		Added:   []skymodules.SkynetSnapshotEntry{},
+		Removed: []skymodules.SkynetSnapshotEntry{},
+		Moved:   []skymodules.SkynetSnapshotMove{},
+	}
+	for skylink, toEntries := range toBySkylink {
+		fromEntries := fromBySkylink[skylink]
+		delete(fromBySkylink, skylink)
+
+		// Ignore the siapaths which didn't change.
+		var added, removed []skymodules.SkynetSnapshotEntry
+		for _, toEntry := range toEntries {
+			if !isSiaPathIn(toEntry.SiaPath, fromEntries) {
+				added = append(added, toEntry)
+			}
+		}
+		for _, fromEntry := range fromEntries {
+			if !isSiaPathIn(fromEntry.SiaPath, toEntries) {
+				removed = append(removed, fromEntry)
+			}
+		}
+
+		// Pair up the remaining siapaths as moves.
+		sortSkynetSnapshotEntries(added)
+		sortSkynetSnapshotEntries(removed)
+		for len(added) > 0 && len(removed) > 0 {
+			diff.Moved = append(diff.Moved, skymodules.SkynetSnapshotMove{
+				From:    removed[0].SiaPath,
+				To:      added[0].SiaPath,
+				Size:    added[0].Size,
+				Skylink: skylink,
+			})
+			added, removed = added[1:], removed[1:]
+		}
+		diff.Added = append(diff.Added, added...)
+		diff.Removed = append(diff.Removed, removed...)
+	}
+	for _, fromEntries := range fromBySkylink {
+		diff.Removed = append(diff.Removed, fromEntries...)
+	}
+
+	sortSkynetSnapshotEntries(diff.Added)
+	sortSkynetSnapshotEntries(diff.Removed)
+	sort.Slice(diff.Moved, func(i, j int) bool {
+		if !diff.Moved[i].To.Equals(diff.Moved[j].To) {
+			return diff.Moved[i].To.String() < diff.Moved[j].To.String()
+		}
+		return diff.Moved[i].Skylink < diff.Moved[j].Skylink
+	})
+	return diff
+}
+
+// isSiaPathIn returns true if one of the entries is at the given siapath.
+func isSiaPathIn(siaPath skymodules.SiaPath, entries []skymodules.SkynetSnapshotEntry) bool {
+	for _, entry := range entries {
+		if entry.SiaPath.Equals(siaPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidSkynetSnapshotName returns true if the name can be used as the name
+// of a skynet snapshot and as a filename.
+func isValidSkynetSnapshotName(name string) bool {
+	if name == "" || len(name) > maxSkynetSnapshotNameLen || name == skymodules.SkynetSnapshotNow {
+		return false
+	}
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, c := range name {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSkynetSnapshotEntries sorts the entries by siapath and skylink.
+func sortSkynetSnapshotEntries(entries []skymodules.SkynetSnapshotEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].SiaPath.Equals(entries[j].SiaPath) {
+			return entries[i].SiaPath.String() < entries[j].SiaPath.String()
+		}
+		return entries[i].Skylink < entries[j].Skylink
+	})
+}
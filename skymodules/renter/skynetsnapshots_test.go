@@ -0,0 +1,179 @@
+package renter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/build"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// TestDiffSkynetSnapshots is a unit test for diffSkynetSnapshots.
+func TestDiffSkynetSnapshots(t *testing.T) {
+	t.Parallel()
+
+	sp := func(s string) skymodules.SiaPath {
+		siaPath, err := skymodules.NewSiaPath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPath
+	}
+	entry := func(skylink, siaPath string, size uint64) skymodules.SkynetSnapshotEntry {
+		return skymodules.SkynetSnapshotEntry{
+			SiaPath: sp(siaPath),
+			Size:    size,
+			Skylink: skylink,
+		}
+	}
+
+	from := []skymodules.SkynetSnapshotEntry{
+		entry("unchanged", "a", 1),
+		entry("removed", "b", 2),
+		entry("moved", "c", 3),
+		entry("copied", "d", 4),
+		entry("twice", "e", 5),
+		entry("twice", "f", 5),
+	}
+	to := []skymodules.SkynetSnapshotEntry{
+		entry("added", "z", 6),
+		entry("unchanged", "a", 1),
+		entry("moved", "y", 3),
+		entry("copied", "d", 4),
+		entry("copied", "x", 4),
+		entry("twice", "e", 5),
+	}
+	expected := skymodules.SkynetSnapshotDiff{
+		Added: []skymodules.SkynetSnapshotEntry{
+			entry("copied", "x", 4),
+			entry("added", "z", 6),
+		},
+		Removed: []skymodules.SkynetSnapshotEntry{
+			entry("removed", "b", 2),
+			entry("twice", "f", 5),
+		},
+		Moved: []skymodules.SkynetSnapshotMove{
+			{From: sp("c"), To: sp("y"), Size: 3, Skylink: "moved"},
+		},
+	}
+	diff := diffSkynetSnapshots(from, to)
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("unexpected diff\n%+v\n%+v", diff, expected)
+	}
+
+	// Diffing in the other direction swaps added and removed.
+	diff = diffSkynetSnapshots(to, from)
+	if !reflect.DeepEqual(diff.Added, expected.Removed) || !reflect.DeepEqual(diff.Removed, expected.Added) {
+		t.Fatal("unexpected diff", diff)
+	}
+	if len(diff.Moved) != 1 || !diff.Moved[0].From.Equals(sp("y")) || !diff.Moved[0].To.Equals(sp("c")) {
+		t.Fatal("unexpected moves", diff.Moved)
+	}
+
+	// No difference between equal snapshots.
+	diff = diffSkynetSnapshots(from, from)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Moved) != 0 {
+		t.Fatal("expected empty diff", diff)
+	}
+}
+
+// TestSkynetSnapshotManager probes saving, loading and pruning skynet
+// snapshots.
+func TestSkynetSnapshotManager(t *testing.T) {
+	t.Parallel()
+
+	testDir := build.TempDir("renter", t.Name())
+	err := os.RemoveAll(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssm, err := newSkynetSnapshotManager(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown snapshots and invalid names are not found.
+	for _, name := range []string{"unknown", "../renter", skymodules.SkynetSnapshotNow} {
+		_, _, err = ssm.callLoad(name)
+		if !errors.Contains(err, ErrSkynetSnapshotNotFound) {
+			t.Fatal("unexpected error", name, err)
+		}
+	}
+
+	// Save a snapshot which pins the same siapath with multiple skylinks.
+	dir := skymodules.SkynetFolder
+	a, err := dir.Join("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := dir.Join("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []skymodules.SkynetSnapshotEntry{
+		{SiaPath: b, Size: 20, Skylink: "skylink3"},
+		{SiaPath: a, Size: 10, Skylink: "skylink2"},
+		{SiaPath: a, Size: 10, Skylink: "skylink1"},
+	}
+	pss := newPersistedSkynetSnapshot("snapshot", dir, time.Now(), entries)
+	if len(pss.Paths) != 2 || len(pss.Entries) != 3 {
+		t.Fatal("unexpected persisted snapshot", pss)
+	}
+	for i, skylink := range []string{"skylink1", "skylink2", "skylink3"} {
+		if pss.Entries[i].Skylink != skylink {
+			t.Fatal("entries are not sorted", pss.Entries)
+		}
+	}
+	err = ssm.callSave(pss)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load it again.
+	loaded, loadedDir, err := ssm.callLoad("snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loadedDir.Equals(dir) {
+		t.Fatal("wrong dir", loadedDir)
+	}
+	sortSkynetSnapshotEntries(entries)
+	sortSkynetSnapshotEntries(loaded)
+	if !reflect.DeepEqual(loaded, entries) {
+		t.Fatalf("loaded entries don't match\n%v\n%v", loaded, entries)
+	}
+
+	// Save more snapshots than the max. The oldest ones are pruned.
+	for i := 0; i < maxSkynetSnapshots; i++ {
+		time.Sleep(10 * time.Millisecond)
+		err = ssm.callSave(newPersistedSkynetSnapshot(fmt.Sprint("snapshot", i), dir, time.Now(), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, err = ssm.callLoad("snapshot")
+	if !errors.Contains(err, ErrSkynetSnapshotNotFound) {
+		t.Fatal("oldest snapshot wasn't pruned", err)
+	}
+	for i := 0; i < maxSkynetSnapshots; i++ {
+		loaded, _, err := ssm.callLoad(fmt.Sprint("snapshot", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(loaded) != 0 {
+			t.Fatal("expected empty snapshot", loaded)
+		}
+	}
+	fis, err := ioutil.ReadDir(ssm.staticDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 2*maxSkynetSnapshots {
+		t.Fatal("wrong number of snapshot files", len(fis))
+	}
+}
@@ -0,0 +1,220 @@
+package renter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"gitlab.com/SkynetLabs/skyd/skymodules/renter/filesystem"
+)
+
+var (
+	// ErrSkynetStorageCapExceeded is returned if an upload or pin is rejected
+	// because the skynet folder would exceed the skynet storage cap.
+	ErrSkynetStorageCapExceeded = errors.New("skynet storage cap exceeded")
+)
+
+type (
+	// SkynetStorageCapError is composed with ErrSkynetStorageCapExceeded to
+	// describe by how much the cap would be exceeded. Incoming is 0 if the
+	// size of the rejected upload wasn't known in advance.
+	SkynetStorageCapError struct {
+		Cap      uint64
+		Used     uint64
+		Incoming uint64
+	}
+
+	// skynetStorageTracker tracks the number of bytes used by the siafiles
+	// in the skynet folder. The AggregateSkynetSize of the folder is only
+	// updated by bubbles so the tracker adjusts it by the siafiles that were
+	// added and deleted since the last bubble.
+	skynetStorageTracker struct {
+		// bubbledSize is the AggregateSkynetSize of the skynet folder and
+		// bubbledAt is the time at which the bubble that calculated it
+		// started.
+		bubbledSize uint64
+		bubbledAt   time.Time
+
+		// added contains the siafiles which were added since the last bubble
+		// and deleted contains the siafiles which were deleted since the last
+		// bubble and were accounted for by it.
+		added   map[skymodules.SiaPath]skynetStorageChange
+		deleted []skynetStorageChange
+
+		mu sync.Mutex
+	}
+
+	// skynetStorageChange describes a siafile that was added to or deleted
+	// from the skynet folder.
+	skynetStorageChange struct {
+		size uint64
+		at   time.Time
+	}
+)
+
+// Error implements the error interface.
+func (err SkynetStorageCapError) Error() string {
+	return fmt.Sprintf("%v bytes of the cap of %v bytes are used, incoming %v bytes", err.Used, err.Cap, err.Incoming)
+}
+
+// SkynetStorageCapFromError returns the SkynetStorageCapError of a rejected
+// upload or pin if the error contains one.
+func SkynetStorageCapFromError(err error) (SkynetStorageCapError, bool) {
+	switch e := err.(type) {
+	case SkynetStorageCapError:
+		return e, true
+	case errors.Error:
+		for _, err := range e.ErrSet {
+			if sce, ok := SkynetStorageCapFromError(err); ok {
+				return sce, true
+			}
+		}
+	}
+	return SkynetStorageCapError{}, false
+}
+
+// newSkynetStorageTracker creates a new tracker.
+func newSkynetStorageTracker() *skynetStorageTracker {
+	return &skynetStorageTracker{
+		added: make(map[skymodules.SiaPath]skynetStorageChange),
+	}
+}
+
+// callFileAdded adds the siafile at the given siapath to the used storage if
+// it is in the skynet folder.
+func (sst *skynetStorageTracker) callFileAdded(siaPath skymodules.SiaPath, size uint64) {
+	if !skymodules.IsSkynetDir(siaPath) {
+		return
+	}
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.added[siaPath] = skynetStorageChange{
+		size: size,
+		at:   time.Now(),
+	}
+}
+
+// callFileDeleted removes the deleted siafile at the given siapath from the
+// used storage if it is in the skynet folder. Siafiles that were created after
+// the last bubble and never added, e.g. because their upload failed, are
+// ignored.
+func (sst *skynetStorageTracker) callFileDeleted(siaPath skymodules.SiaPath, size uint64, created time.Time) {
+	if !skymodules.IsSkynetDir(siaPath) {
+		return
+	}
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	if _, exists := sst.added[siaPath]; exists {
+		delete(sst.added, siaPath)
+		return
+	}
+	if created.After(sst.bubbledAt) {
+		return
+	}
+	sst.deleted = append(sst.deleted, skynetStorageChange{
+		size: size,
+		at:   time.Now(),
+	})
+}
+
+// callReconcile updates the tracker with the AggregateSkynetSize of the skynet
+// folder calculated by a bubble which started at the given time. Changes
+// before the start of the bubble are accounted for by the size.
+func (sst *skynetStorageTracker) callReconcile(size uint64, start time.Time) {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.bubbledSize = size
+	sst.bubbledAt = start
+	for sp, change := range sst.added {
+		if change.at.Before(start) {
+			delete(sst.added, sp)
+		}
+	}
+	deleted := sst.deleted[:0]
+	for _, change := range sst.deleted {
+		if !change.at.Before(start) {
+			deleted = append(deleted, change)
+		}
+	}
+	sst.deleted = deleted
+}
+
+// callUsed returns the number of bytes used by the skynet folder.
+func (sst *skynetStorageTracker) callUsed() uint64 {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	used := sst.bubbledSize
+	for _, change := range sst.added {
+		used += change.size
+	}
+	for _, change := range sst.deleted {
+		if change.size > used {
+			return 0
+		}
+		used -= change.size
+	}
+	return used
+}
+
+// managedCheckSkynetStorageCap returns ErrSkynetStorageCapExceeded if adding
+// the given number of bytes to the skynet folder would exceed the skynet
+// storage cap. If the size of an upload isn't known in advance, 0 is passed
+// and the upload is only rejected if the cap is already reached.
+func (r *Renter) managedCheckSkynetStorageCap(incoming uint64) error {
+	id := r.mu.RLock()
+	storageCap := r.persist.SkynetStorageCap
+	r.mu.RUnlock(id)
+	if storageCap == 0 {
+		return nil // unlimited
+	}
+	used := r.staticSkynetStorageTracker.callUsed()
+	if used >= storageCap || incoming > storageCap-used {
+		return errors.Compose(ErrSkynetStorageCapExceeded, SkynetStorageCapError{
+			Cap:      storageCap,
+			Used:     used,
+			Incoming: incoming,
+		})
+	}
+	return nil
+}
+
+// managedInitSkynetStorageTracker initializes the tracker with the last
+// bubbled AggregateSkynetSize of the skynet folder.
+func (r *Renter) managedInitSkynetStorageTracker() error {
+	sd, err := r.staticFileSystem.OpenSiaDir(skymodules.SkynetFolder)
+	if err != nil {
+		return errors.AddContext(err, "failed to open skynet folder")
+	}
+	md, err := sd.Metadata()
+	if err != nil {
+		return errors.Compose(errors.AddContext(err, "failed to fetch metadata of skynet folder"), sd.Close())
+	}
+	r.staticSkynetStorageTracker.callReconcile(md.AggregateSkynetSize, time.Now())
+	return sd.Close()
+}
+
+// managedTrackSkyfileStorage adds the siafile of a skyfile at the given
+// siapath and its extended siafile to the used skynet storage.
+func (r *Renter) managedTrackSkyfileStorage(siaPath skymodules.SiaPath) {
+	extendedSiaPath, err := siaPath.AddSuffixStr(skymodules.ExtendedSuffix)
+	if err != nil {
+		r.staticLog.Printf("failed to get siapath of extended siafile of %v: %v", siaPath, err)
+		return
+	}
+	for _, sp := range []skymodules.SiaPath{siaPath, extendedSiaPath} {
+		sf, err := r.staticFileSystem.OpenSiaFile(sp)
+		if errors.Contains(err, filesystem.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			r.staticLog.Printf("failed to open siafile %v to track its size: %v", sp, err)
+			continue
+		}
+		r.staticSkynetStorageTracker.callFileAdded(sp, sf.Size())
+		if err := sf.Close(); err != nil {
+			r.staticLog.Printf("failed to close siafile %v: %v", sp, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+)
+
+// TestSkynetStorageCap probes tracking the storage used by the skynet folder
+// and enforcing the skynet storage cap.
+func TestSkynetStorageCap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = rt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Helper to create a siafile of 1000 bytes in the skynet folder.
+	createFile := func(name string, track bool) skymodules.SiaPath {
+		sp, err := skymodules.SkynetFolder.Join(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rsc := testingFileParams()
+		sf, err := r.createRenterTestFileWithParamsAndSize(sp, rsc, crypto.TypePlain, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sf.SetFinished(0); err != nil {
+			t.Fatal(err)
+		}
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if track {
+			r.managedTrackSkyfileStorage(sp)
+		}
+		return sp
+	}
+	used := func(expected uint64) {
+		t.Helper()
+		if u := r.staticSkynetStorageTracker.callUsed(); u != expected {
+			t.Fatalf("expected %v bytes to be used but got %v", expected, u)
+		}
+	}
+	setCap := func(storageCap uint64) {
+		id := r.mu.Lock()
+		r.persist.SkynetStorageCap = storageCap
+		r.mu.Unlock(id)
+	}
+
+	// Without a cap, everything is accepted.
+	used(0)
+	if err := r.managedCheckSkynetStorageCap(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a file and set a cap.
+	a := createFile("a", true)
+	used(1000)
+	setCap(2500)
+	if err := r.managedCheckSkynetStorageCap(1500); err != nil {
+		t.Fatal(err)
+	}
+	err = r.managedCheckSkynetStorageCap(1501)
+	if !errors.Contains(err, ErrSkynetStorageCapExceeded) {
+		t.Fatal("expected cap to be exceeded", err)
+	}
+	sce, ok := SkynetStorageCapFromError(err)
+	if !ok || sce != (SkynetStorageCapError{Cap: 2500, Used: 1000, Incoming: 1501}) {
+		t.Fatal("unexpected error", sce, ok)
+	}
+
+	// Files outside of the skynet folder don't count.
+	sf, err := r.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.managedTrackSkyfileStorage(r.staticFileSystem.FileSiaPath(sf))
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	used(1000)
+
+	// Add another file and one that is deleted without ever being added, like
+	// a failed upload.
+	createFile("b", true)
+	failed := createFile("failed", false)
+	if err := r.DeleteFile(failed); err != nil {
+		t.Fatal(err)
+	}
+	used(2000)
+
+	// Bubbling the skynet folder reconciles the tracker.
+	if err := r.managedUpdateDirMetadata(skymodules.SkynetFolder); err != nil {
+		t.Fatal(err)
+	}
+	used(2000)
+
+	// Fill the cap. Uploads of unknown size are rejected.
+	createFile("c", true)
+	used(3000)
+	if err := r.managedCheckSkynetStorageCap(0); !errors.Contains(err, ErrSkynetStorageCapExceeded) {
+		t.Fatal("expected cap to be exceeded", err)
+	}
+
+	// Deleting a bubbled file frees up headroom.
+	if err := r.DeleteFile(a); err != nil {
+		t.Fatal(err)
+	}
+	used(2000)
+	if err := r.managedCheckSkynetStorageCap(500); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.managedCheckSkynetStorageCap(501); !errors.Contains(err, ErrSkynetStorageCapExceeded) {
+		t.Fatal("expected cap to be exceeded", err)
+	}
+
+	// The next bubble agrees with the tracker.
+	if err := r.managedUpdateDirMetadata(skymodules.SkynetFolder); err != nil {
+		t.Fatal(err)
+	}
+	used(2000)
+
+	// Removing the cap accepts everything again.
+	setCap(0)
+	if err := r.managedCheckSkynetStorageCap(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+type (
+	// skynetTraceBuffer is a fixed size ring buffer of the most recent log
+	// lines which were tagged with a trace ID.
+	skynetTraceBuffer struct {
+		entries []skynetTraceBufferEntry
+		next    int
+		mu      sync.Mutex
+	}
+
+	// skynetTraceBufferEntry is a log line in the buffer together with its
+	// trace ID.
+	skynetTraceBufferEntry struct {
+		traceID string
+		entry   skymodules.SkynetTraceEntry
+	}
+)
+
+// newSkynetTraceBuffer returns a new, empty ring buffer that holds up to size
+// log lines.
+func newSkynetTraceBuffer(size int) *skynetTraceBuffer {
+	return &skynetTraceBuffer{
+		entries: make([]skynetTraceBufferEntry, 0, size),
+	}
+}
+
+// callAdd adds a log line to the buffer, overwriting the oldest one if the
+// buffer is full.
+func (tb *skynetTraceBuffer) callAdd(traceID string, entry skymodules.SkynetTraceEntry) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if cap(tb.entries) == 0 {
+		return
+	}
+	e := skynetTraceBufferEntry{traceID: traceID, entry: entry}
+	if len(tb.entries) < cap(tb.entries) {
+		tb.entries = append(tb.entries, e)
+		return
+	}
+	tb.entries[tb.next] = e
+	tb.next = (tb.next + 1) % len(tb.entries)
+}
+
+// callEntries returns the log lines in the buffer which were tagged with the
+// given trace ID, ordered from oldest to newest.
+func (tb *skynetTraceBuffer) callEntries(traceID string) []skymodules.SkynetTraceEntry {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	entries := []skymodules.SkynetTraceEntry{}
+	for i := range tb.entries {
+		e := tb.entries[(tb.next+i)%len(tb.entries)]
+		if e.traceID == traceID {
+			entries = append(entries, e.entry)
+		}
+	}
+	return entries
+}
+
+// SkynetTrace returns the most recent log lines which were tagged with the
+// given trace ID, ordered from oldest to newest.
+func (r *Renter) SkynetTrace(traceID string) ([]skymodules.SkynetTraceEntry, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticSkynetTraceBuffer.callEntries(traceID), nil
+}
+
+// staticTracef logs a message for the trace ID attached to ctx. The message
+// is written to the renter's debug log and kept in the trace buffer. If ctx
+// doesn't carry a trace ID, the message is dropped.
+func (r *Renter) staticTracef(ctx context.Context, format string, args ...interface{}) {
+	traceID := skymodules.TraceIDFromContext(ctx)
+	if traceID == "" {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	r.staticLog.Debugf("[trace %v] %v", traceID, msg)
+	r.staticSkynetTraceBuffer.callAdd(traceID, skymodules.SkynetTraceEntry{
+		Time:    time.Now(),
+		Message: msg,
+	})
+}
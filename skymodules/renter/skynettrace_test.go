@@ -0,0 +1,62 @@
+package renter
+
+import (
+	"fmt"
+	"testing"
+
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+)
+
+// TestSkynetTraceBuffer tests the ring buffer of traced log lines.
+func TestSkynetTraceBuffer(t *testing.T) {
+	t.Parallel()
+
+	// add is a helper to add a log line for a trace ID.
+	add := func(tb *skynetTraceBuffer, traceID string, i int) {
+		tb.callAdd(traceID, skymodules.SkynetTraceEntry{Message: fmt.Sprint(i)})
+	}
+	// checkEntries is a helper to check the order of the log lines of a
+	// trace ID.
+	checkEntries := func(tb *skynetTraceBuffer, traceID string, expected ...int) {
+		t.Helper()
+		entries := tb.callEntries(traceID)
+		if len(entries) != len(expected) {
+			t.Fatalf("expected %v entries but got %v", len(expected), len(entries))
+		}
+		for i, entry := range entries {
+			if entry.Message != fmt.Sprint(expected[i]) {
+				t.Fatalf("entry %v: expected %v but got %v", i, expected[i], entry.Message)
+			}
+		}
+	}
+
+	// An empty buffer returns no entries.
+	tb := newSkynetTraceBuffer(4)
+	checkEntries(tb, "a")
+
+	// Fill the buffer with entries of two traces.
+	add(tb, "a", 1)
+	add(tb, "b", 2)
+	add(tb, "a", 3)
+	add(tb, "b", 4)
+	checkEntries(tb, "a", 1, 3)
+	checkEntries(tb, "b", 2, 4)
+	checkEntries(tb, "c")
+
+	// Adding more entries overwrites the oldest ones.
+	add(tb, "a", 5)
+	checkEntries(tb, "a", 3, 5)
+	checkEntries(tb, "b", 2, 4)
+	add(tb, "a", 6)
+	add(tb, "a", 7)
+	checkEntries(tb, "a", 5, 6, 7)
+	checkEntries(tb, "b", 4)
+	add(tb, "a", 8)
+	checkEntries(tb, "a", 5, 6, 7, 8)
+	checkEntries(tb, "b")
+
+	// A buffer without capacity drops all entries.
+	tb = newSkynetTraceBuffer(0)
+	add(tb, "a", 1)
+	checkEntries(tb, "a")
+}
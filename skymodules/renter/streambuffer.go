@@ -147,6 +147,16 @@ type streamBufferDataSource interface {
 	// Skylink returns the skylink of the datasource.
 	Skylink() skymodules.Skylink
 
+	// HostStats returns the stats of the hosts which contributed to the data
+	// source independently of any calls to ReadStream.
+	HostStats() hostStats
+
+	// UnrecoverableRanges returns the ranges of the data source which can't
+	// be recovered because not enough pieces are available on the network.
+	// It blocks until the availability of all the data is known or the
+	// context is closed.
+	UnrecoverableRanges(context.Context) ([]skymodules.SkynetMissingRange, error)
+
 	// ReadStream allows the stream buffer to request specific data chunks from
 	// the data source. It returns a channel containing a read response.
 	ReadStream(context.Context, uint64, uint64, types.Currency) chan *readResponse
@@ -156,8 +166,10 @@ type streamBufferDataSource interface {
 // source. It contains the data being downloaded and an error in case of
 // failure.
 type readResponse struct {
-	staticData []byte
-	staticErr  error
+	staticData      []byte
+	staticDegraded  bool
+	staticErr       error
+	staticHostStats hostStats
 }
 
 // dataSection represents a section of data from a data source. The data section
@@ -171,10 +183,12 @@ type dataSection struct {
 	// until the data available channel has been closed. Once the dataAvailable
 	// channel has been closed, externData, externDuration and externErr are to
 	// be treated like static fields.
-	dataAvailable  chan struct{}
-	externDuration time.Duration
-	externData     []byte
-	externErr      error
+	dataAvailable   chan struct{}
+	externDuration  time.Duration
+	externData      []byte
+	externDegraded  bool
+	externErr       error
+	externHostStats hostStats
 
 	refCount uint64
 }
@@ -190,6 +204,29 @@ type stream struct {
 	lru    *leastRecentlyUsedCache
 	offset uint64
 
+	// prepared indicates whether the data sections at the current offset
+	// were requested. A new stream or a stream moved with SeekLazy defers
+	// that until it is read from so that it doesn't fetch any data which
+	// might never be read, e.g. the beginning of a skyfile when only one of
+	// its subfiles is served or when a request is rejected after validating
+	// the metadata.
+	prepared bool
+
+	// fetchLimit is the offset up to which data sections are fetched ahead
+	// of time. The data section at the offset of the stream is always
+	// fetched. 0 means no limit.
+	fetchLimit uint64
+
+	// hostStats contains the stats of the hosts which served the data
+	// sections read by the stream. readSections keeps track of which
+	// sections were already accounted for.
+	hostStats    hostStats
+	readSections map[uint64]struct{}
+
+	// degraded is set if any of the data sections read by the stream was
+	// downloaded in degraded mode.
+	degraded bool
+
 	mu                 sync.Mutex
 	staticStreamBuffer *streamBuffer
 
@@ -380,6 +417,45 @@ func (s *stream) Layout() skymodules.SkyfileLayout {
 	return s.staticStreamBuffer.staticDataSource.Layout()
 }
 
+// Degraded returns whether any of the data read from the stream so far was
+// downloaded with fewer usable workers than pieces needed to recover it.
+func (s *stream) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.degraded
+}
+
+// HostStats returns the stats of the hosts which served the data read from the
+// stream so far.
+func (s *stream) HostStats() []skymodules.SkynetHostStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs := make(hostStats)
+	hs.merge(s.staticStreamBuffer.staticDataSource.HostStats())
+	hs.merge(s.hostStats)
+	return hs.toSlice()
+}
+
+// UnrecoverableRanges returns the ranges of the underlying data source which
+// can't be recovered from the network.
+func (s *stream) UnrecoverableRanges() ([]skymodules.SkynetMissingRange, error) {
+	ctx := s.staticContext
+	if s.staticReadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.staticReadTimeout)
+		defer cancel()
+	}
+	return s.staticStreamBuffer.staticDataSource.UnrecoverableRanges(ctx)
+}
+
+// SetFetchLimit prevents the stream from fetching data sections beyond the
+// limit ahead of time.
+func (s *stream) SetFetchLimit(limit uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchLimit = limit
+}
+
 // Skylink returns the skylink associated with this stream.
 func (s *stream) Skylink() skymodules.Skylink {
 	return s.staticStreamBuffer.staticDataSource.Skylink()
@@ -418,6 +494,12 @@ func (s *stream) Read(b []byte) (int, error) {
 		return 0, io.EOF
 	}
 
+	// Fetch the data at the current offset if that didn't happen yet.
+	if !s.prepared {
+		s.prepareOffset()
+		s.prepared = true
+	}
+
 	// Get the index of the current section and the offset within the current
 	// section.
 	currentSection := s.offset / dataSectionSize
@@ -460,6 +542,13 @@ func (s *stream) Read(b []byte) (int, error) {
 	n := copy(b, data[offsetInSection:offsetInSection+bytesToRead])
 	s.offset += uint64(n)
 
+	// Account for the hosts that served the section.
+	if _, read := s.readSections[currentSection]; !read {
+		s.readSections[currentSection] = struct{}{}
+		s.hostStats.merge(dataSection.externHostStats)
+		s.degraded = s.degraded || dataSection.externDegraded
+	}
+
 	// Send the call to prepare the next data section.
 	s.prepareOffset()
 	return n, nil
@@ -467,6 +556,19 @@ func (s *stream) Read(b []byte) (int, error) {
 
 // Seek will move the read head of the stream to the provided offset.
 func (s *stream) Seek(offset int64, whence int) (int64, error) {
+	return s.managedSeek(offset, whence, true)
+}
+
+// SeekLazy will move the read head of the stream to the provided offset
+// without fetching the data at that offset until the stream is read from.
+func (s *stream) SeekLazy(offset int64, whence int) (int64, error) {
+	return s.managedSeek(offset, whence, false)
+}
+
+// managedSeek will move the read head of the stream to the provided offset. If
+// prepare is true, the fetch of the data at the new offset is started right
+// away.
+func (s *stream) managedSeek(offset int64, whence int, prepare bool) (int64, error) {
 	// Input checking.
 	if offset < 0 {
 		return int64(s.offset), errors.New("offset cannot be negative in call to seek")
@@ -495,7 +597,10 @@ func (s *stream) Seek(offset int64, whence int) (int64, error) {
 	}
 
 	// Prepare the fetch of the updated offset.
-	s.prepareOffset()
+	s.prepared = prepare
+	if prepare {
+		s.prepareOffset()
+	}
 	return int64(s.offset), nil
 }
 
@@ -511,6 +616,12 @@ func (s *stream) prepareOffset() {
 		return
 	}
 
+	// Don't fetch data sections beyond the fetch limit ahead of time.
+	fetchSize := dataSize
+	if s.fetchLimit > 0 && s.fetchLimit < fetchSize {
+		fetchSize = s.fetchLimit
+	}
+
 	// Update the current data section. The update call will trigger the
 	// streamBuffer to fetch the dataSection if the dataSection is not already
 	// in the streamBuffer cache.
@@ -519,16 +630,17 @@ func (s *stream) prepareOffset() {
 
 	// If there is a following data section, update that as well. This update is
 	// done regardless of the minimumLookahead, we always want to buffer at
-	// least one more piece than the current piece.
+	// least one more piece than the current piece unless it is beyond the
+	// fetch limit.
 	nextIndex := index + 1
-	if nextIndex*dataSectionSize < dataSize {
+	if nextIndex*dataSectionSize < fetchSize {
 		s.lru.callUpdate(nextIndex)
 	}
 
 	// Keep adding more pieces to the buffer until we have buffered at least
 	// minimumLookahead total data or have reached the end of the stream.
 	nextIndex++
-	for i := dataSectionSize * 2; i < minimumLookahead && nextIndex*dataSectionSize < dataSize; i += dataSectionSize {
+	for i := dataSectionSize * 2; i < minimumLookahead && nextIndex*dataSectionSize < fetchSize; i += dataSectionSize {
 		s.lru.callUpdate(nextIndex)
 		nextIndex++
 	}
@@ -574,6 +686,9 @@ func (sb *streamBuffer) callRemoveDataSection(index uint64) {
 // managedPrepareNewStream creates a new stream from an existing stream buffer.
 // The ref count for the buffer needs to be incremented under the
 // streamBufferSet lock, before this method is called.
+//
+// NOTE: the stream doesn't fetch any data until it is read from or seeked
+// with Seek.
 func (sb *streamBuffer) managedPrepareNewStream(ctx context.Context, initialOffset uint64, timeout time.Duration) *stream {
 	// Determine how many data sections the stream should cache.
 	dataSectionsToCache := bytesBufferedPerStream / sb.staticDataSectionSize
@@ -586,12 +701,14 @@ func (sb *streamBuffer) managedPrepareNewStream(ctx context.Context, initialOffs
 		lru:    newLeastRecentlyUsedCache(dataSectionsToCache, sb),
 		offset: initialOffset,
 
+		hostStats:    make(hostStats),
+		readSections: make(map[uint64]struct{}),
+
 		staticContext:      sb.staticTG.StopCtx(),
 		staticReadTimeout:  timeout,
 		staticStreamBuffer: sb,
 		staticSpan:         opentracing.SpanFromContext(ctx),
 	}
-	stream.prepareOffset()
 	return stream
 }
 
@@ -659,6 +776,8 @@ func (sb *streamBuffer) newDataSection(index uint64) *dataSection {
 			ds.externErr = errors.AddContext(response.staticErr, "data section ReadStream failed")
 			ds.externDuration = time.Since(start)
 			ds.externData = response.staticData
+			ds.externDegraded = response.staticDegraded
+			ds.externHostStats = response.staticHostStats
 			if ds.externErr == nil {
 				sb.staticStreamBufferSet.staticStatsCollector.AddDataPoint(ds.externDuration)
 			}
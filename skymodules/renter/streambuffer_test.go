@@ -70,6 +70,16 @@ func (mds *mockDataSource) Layout() skymodules.SkyfileLayout {
 	return skymodules.SkyfileLayout{}
 }
 
+// HostStats implements streamBufferDataSource.
+func (mds *mockDataSource) HostStats() hostStats {
+	return nil
+}
+
+// UnrecoverableRanges implements streamBufferDataSource.
+func (mds *mockDataSource) UnrecoverableRanges(context.Context) ([]skymodules.SkynetMissingRange, error) {
+	return nil, nil
+}
+
 // RequestSize implements streamBufferDataSource.
 func (mds *mockDataSource) RequestSize() uint64 {
 	return mds.staticRequestSize
@@ -137,6 +147,14 @@ func TestStreamSmoke(t *testing.T) {
 	sbs := newStreamBufferSet(dt, &tg)
 	stream := sbs.callNewStream(ctx, dataSource, 0, 0, types.ZeroCurrency)
 
+	// A new stream shouldn't fetch any data before it is used.
+	stream.staticStreamBuffer.mu.Lock()
+	sections := len(stream.staticStreamBuffer.dataSections)
+	stream.staticStreamBuffer.mu.Unlock()
+	if sections != 0 {
+		t.Fatal("new stream shouldn't fetch any data", sections)
+	}
+
 	// Check that there is one reference in the stream buffer.
 	sbs.mu.Lock()
 	refs := stream.staticStreamBuffer.externRefCount
@@ -501,3 +519,149 @@ func TestStreamSmoke(t *testing.T) {
 		t.Fatal("bad")
 	}
 }
+
+// TestStreamFetchLimit checks that a stream doesn't fetch data sections beyond
+// its fetch limit ahead of time.
+func TestStreamFetchLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a ctx with test span
+	ctx := opentracing.ContextWithSpan(context.Background(), testSpan())
+
+	// Create a stream and limit it to the first two data sections.
+	var tg threadgroup.ThreadGroup
+	data := fastrand.Bytes(15999)
+	dataSectionSize := uint64(16)
+	dataSource := newMockDataSource(data, dataSectionSize)
+	dt := skymodules.NewDistributionTrackerStandard()
+	sbs := newStreamBufferSet(dt, &tg)
+	stream := sbs.callNewStream(ctx, dataSource, 0, 0, types.ZeroCurrency)
+	defer func() {
+		if err := stream.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	stream.SetFetchLimit(dataSectionSize + 1)
+
+	// sections returns the indices of the buffered data sections.
+	sections := func() map[uint64]struct{} {
+		sb := stream.staticStreamBuffer
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		indices := make(map[uint64]struct{})
+		for index := range sb.dataSections {
+			indices[index] = struct{}{}
+		}
+		return indices
+	}
+
+	// Read the first byte. Only the first two sections should be fetched.
+	buf := make([]byte, 1)
+	_, err := io.ReadFull(stream, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[:1]) {
+		t.Fatal("wrong data")
+	}
+	indices := sections()
+	_, exists0 := indices[0]
+	_, exists1 := indices[1]
+	if len(indices) != 2 || !exists0 || !exists1 {
+		t.Fatal("unexpected sections", indices)
+	}
+
+	// Reading beyond the limit is still possible.
+	buf = make([]byte, 3*dataSectionSize)
+	_, err = io.ReadFull(stream, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[1:1+3*dataSectionSize]) {
+		t.Fatal("wrong data")
+	}
+	indices = sections()
+	if _, exists := indices[4]; exists {
+		t.Fatal("section beyond the read data shouldn't be fetched", indices)
+	}
+}
+
+// TestStreamSeekLazy checks that a stream seeked with SeekLazy doesn't fetch
+// any data sections until it is read from.
+func TestStreamSeekLazy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a ctx with test span
+	ctx := opentracing.ContextWithSpan(context.Background(), testSpan())
+
+	// Create a stream.
+	var tg threadgroup.ThreadGroup
+	data := fastrand.Bytes(15999)
+	dataSectionSize := uint64(16)
+	dataSource := newMockDataSource(data, dataSectionSize)
+	dt := skymodules.NewDistributionTrackerStandard()
+	sbs := newStreamBufferSet(dt, &tg)
+	stream := sbs.callNewStream(ctx, dataSource, 0, 0, types.ZeroCurrency)
+	defer func() {
+		if err := stream.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// numSections returns the number of buffered data sections.
+	numSections := func() int {
+		sb := stream.staticStreamBuffer
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		return len(sb.dataSections)
+	}
+
+	// Perform the seeks http.ServeContent performs for a range request. No
+	// data should be fetched.
+	offset, err := stream.SeekLazy(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != int64(len(data)) {
+		t.Fatal("wrong offset", offset)
+	}
+	offset, err = stream.SeekLazy(1000, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 1000 {
+		t.Fatal("wrong offset", offset)
+	}
+	if n := numSections(); n != 0 {
+		t.Fatal("lazy seek shouldn't fetch any data", n)
+	}
+
+	// Invalid seeks are still rejected right away.
+	_, err = stream.SeekLazy(int64(len(data))+1, io.SeekEnd)
+	if err == nil {
+		t.Fatal("expected seek before the front of the file to fail")
+	}
+	_, err = stream.SeekLazy(-1, io.SeekStart)
+	if err == nil {
+		t.Fatal("expected seek to a negative offset to fail")
+	}
+
+	// The first read fetches the data at the offset.
+	buf := make([]byte, dataSectionSize)
+	_, err = io.ReadFull(stream, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[1000:1000+dataSectionSize]) {
+		t.Fatal("wrong data")
+	}
+	if n := numSections(); n == 0 {
+		t.Fatal("read should fetch data")
+	}
+}
@@ -117,7 +117,7 @@ func (stu *skynetTUSUploader) NewUpload(ctx context.Context, info handler.FileIn
 	sup := skymodules.SkyfileUploadParameters{
 		SiaPath:             sp,
 		Filename:            fileName,
-		BaseChunkRedundancy: SkyfileDefaultBaseChunkRedundancy,
+		BaseChunkRedundancy: stu.staticRenter.managedDefaultBaseChunkRedundancy(),
 	}
 
 	// Create metadata.
@@ -125,6 +125,7 @@ func (stu *skynetTUSUploader) NewUpload(ctx context.Context, info handler.FileIn
 		Filename: sup.Filename,
 		Length:   uint64(info.Size),
 		Mode:     sup.Mode,
+		ModTime:  time.Now().Unix(),
 		Subfiles: skymodules.SkyfileSubfiles{
 			sup.Filename: skymodules.SkyfileSubfileMetadata{
 				Filename:    fileName,
@@ -141,11 +142,17 @@ func (stu *skynetTUSUploader) NewUpload(ctx context.Context, info handler.FileIn
 		return nil, errors.AddContext(err, "invalid metadata")
 	}
 
+	// Reject the upload if it would exceed the skynet storage cap.
+	err = stu.staticRenter.managedCheckSkynetStorageCap(uint64(info.Size))
+	if err != nil {
+		return nil, err
+	}
+
 	// Set the upload params to 'force' to allow overwriting the fileNode.
 	sup.Force = true
 
 	// Create the upload.
-	upload, err := stu.managedCreateUpload(info, sp, fileName, SkyfileDefaultBaseChunkRedundancy, skymodules.RenterDefaultDataPieces, skymodules.RenterDefaultParityPieces, sm, crypto.TypePlain)
+	upload, err := stu.managedCreateUpload(info, sp, fileName, sup.BaseChunkRedundancy, skymodules.RenterDefaultDataPieces, skymodules.RenterDefaultParityPieces, sm, crypto.TypePlain)
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to save new upload")
 	}
@@ -427,17 +434,20 @@ func (u *ongoingTUSUpload) FinishUpload(ctx context.Context) (err error) {
 	if fi.IsPartial {
 		return nil
 	}
+	sup, _, err := u.staticUpload.UploadParams(ctx)
+	if err != nil {
+		return errors.AddContext(err, "failed to fetch upload params")
+	}
+	r := u.staticUploader.staticRenter
+
 	// If the upload is a small file upload with >0 size we are done because
 	// it was already finalised in WriteChunk.
 	if u.fileNode == nil && fi.Size > 0 {
+		r.managedTrackSkyfileStorage(sup.SiaPath)
 		return nil
 	}
 
 	// Finish the large or 0-byte upload.
-	sup, _, err := u.staticUpload.UploadParams(ctx)
-	if err != nil {
-		return errors.AddContext(err, "failed to fetch upload params")
-	}
 	smBytes, err := u.staticUpload.SkyfileMetadata(ctx)
 	if err != nil {
 		return errors.AddContext(err, "failed to fetch smBytes")
@@ -458,6 +468,7 @@ func (u *ongoingTUSUpload) FinishUpload(ctx context.Context) (err error) {
 	if err != nil {
 		return errors.AddContext(err, "failed to finish upload")
 	}
+	r.managedTrackSkyfileStorage(sup.SiaPath)
 	return u.staticUpload.CommitFinishUpload(ctx, skylink)
 }
 
@@ -580,28 +591,31 @@ func (u *ongoingTUSUpload) ConcatUploads(ctx context.Context, partialUploads []h
 
 	// Concatenate the uploads by combining their fanouts. Concatenated
 	// uploads may never consist of small uploads except for the last
-	// upload.
-	pu := partialUploads[0].(*ongoingTUSUpload)
+	// upload. That way every partial upload ends on a chunk boundary and the
+	// fanouts can be appended without re-encoding any data. All partial
+	// uploads are validated before any fanout is fetched.
 	sup, fup, err := u.staticUpload.UploadParams(ctx)
 	if err != nil {
 		return err
 	}
 	chunkSize := skymodules.ChunkSize(fup.CipherType, uint64(fup.ErasureCode.MinPieces()))
-	ec := pu.fileNode.ErasureCode()
-	masterKey := pu.fileNode.MasterKey()
-	var fanout []byte
+	first := partialUploads[0].(*ongoingTUSUpload)
+	ec := first.fileNode.ErasureCode()
+	masterKey := first.fileNode.MasterKey()
 	for i := range partialUploads {
-		fi, err := pu.GetInfo(ctx)
+		pu := partialUploads[i].(*ongoingTUSUpload)
+		pfi, err := pu.GetInfo(ctx)
 		if err != nil {
 			return errors.AddContext(err, "failed to get partial upload's fileinfo")
 		}
-		isSmall := fi.Size%int64(chunkSize) != 0
+		isSmall := pfi.Size%int64(chunkSize) != 0
 		if i < len(partialUploads)-1 && isSmall {
-			return errors.New("only last upload is allowed to be small")
+			err = fmt.Errorf("partial upload %v has size %v which is not aligned to the chunk size of %v, only the last upload is allowed to be small", pfi.ID, pfi.Size, chunkSize)
+			return handler.NewHTTPError(err, http.StatusBadRequest)
 		}
-		pu := partialUploads[i].(*ongoingTUSUpload)
-		if pu.fileNode.ErasureCode().Identifier() != ec.Identifier() {
-			return errors.New("all partial uploads need to use the same erasure coding")
+		if pu.fileNode.ErasureCode().Identifier() != ec.Identifier() || ec.Identifier() != fup.ErasureCode.Identifier() {
+			err = fmt.Errorf("partial upload %v uses an incompatible erasure coding, all partial uploads need to use the same erasure coding", pfi.ID)
+			return handler.NewHTTPError(err, http.StatusBadRequest)
 		}
 		if pu.fileNode.MasterKey().Type() != masterKey.Type() {
 			return errors.New("all masterkeys need to have the same type")
@@ -609,6 +623,10 @@ func (u *ongoingTUSUpload) ConcatUploads(ctx context.Context, partialUploads []h
 		if !bytes.Equal(pu.fileNode.MasterKey().Key(), masterKey.Key()) {
 			return errors.New("all masterkeys need to be the same")
 		}
+	}
+	var fanout []byte
+	for i := range partialUploads {
+		pu := partialUploads[i].(*ongoingTUSUpload)
 		partialFanout, err := pu.staticUpload.Fanout(ctx)
 		if err != nil {
 			return errors.AddContext(err, "failed to fetch fanout of partial upload")
@@ -640,7 +658,7 @@ func (u *ongoingTUSUpload) ConcatUploads(ctx context.Context, partialUploads []h
 		// from being pruned.
 		for i := range partialUploads {
 			// Commit the partial upload as complete as well.
-			pu = partialUploads[i].(*ongoingTUSUpload)
+			pu := partialUploads[i].(*ongoingTUSUpload)
 			err := pu.staticUpload.CommitFinishUpload(sctx, skylink)
 			if err != nil {
 				return errors.AddContext(err, "failed to commit partial upload")
@@ -19,6 +19,7 @@ type chunkReader struct {
 
 	chunkIndex uint64
 	peek       []byte
+	peekErr    error
 }
 
 // fanoutChunkReader implements the FanoutChunkReader interface by wrapping a
@@ -29,6 +30,24 @@ type fanoutChunkReader struct {
 	staticOnePiece bool
 }
 
+// sparseFanoutChunkReader implements the FanoutChunkReader interface for
+// sparse skyfiles. Chunks which only contain zeros are skipped and marked in
+// the fanout with the ZeroChunkRoot instead of being returned for upload.
+type sparseFanoutChunkReader struct {
+	staticChunkReader skymodules.ChunkReader
+	staticOnePiece    bool
+
+	fanout []byte
+	size   uint64
+
+	// peeked contains the next chunk with data if it was already read by
+	// Peek.
+	peeked    bool
+	peekChunk [][]byte
+	peekN     uint64
+	peekErr   error
+}
+
 // NewChunkReader creates a new chunkReader.
 func NewChunkReader(r io.Reader, ec skymodules.ErasureCoder, mk crypto.CipherKey) skymodules.ChunkReader {
 	return NewChunkReaderWithChunkIndex(r, ec, mk, 0)
@@ -54,20 +73,37 @@ func NewFanoutChunkReader(r io.Reader, ec skymodules.ErasureCoder, onePiece bool
 	}
 }
 
+// newSparseFanoutChunkReader creates a new sparseFanoutChunkReader. Since the
+// pieces of a zero chunk are only zero if they are not encrypted, the master
+// key needs to be of type plain.
+func newSparseFanoutChunkReader(r io.Reader, ec skymodules.ErasureCoder, onePiece bool, mk crypto.CipherKey) *sparseFanoutChunkReader {
+	return &sparseFanoutChunkReader{
+		staticChunkReader: NewChunkReader(r, ec, mk),
+		staticOnePiece:    onePiece,
+	}
+}
+
 // Peek returns whether the next call to ReadChunk is expected to return a
 // chunk or if there is no more data.
 func (cr *chunkReader) Peek() bool {
-	// If 'peek' already has data, then there is more data to consume.
-	if len(cr.peek) > 0 {
+	// If 'peek' already has data or peeking failed, then there is more data
+	// to consume.
+	if len(cr.peek) > 0 || cr.peekErr != nil {
 		return true
 	}
 
-	// Read a byte into peek.
-	cr.peek = append(cr.peek, 0)
-	_, err := io.ReadFull(cr.staticReader, cr.peek)
-	if err != nil {
+	// Read a byte into peek. An error other than EOF is remembered and
+	// returned by the next call to ReadChunk. Otherwise it would be mistaken
+	// for the end of the data.
+	peek := make([]byte, 1)
+	_, err := io.ReadFull(cr.staticReader, peek)
+	if errors.Contains(err, io.EOF) {
 		return false
+	} else if err != nil {
+		cr.peekErr = err
+		return true
 	}
+	cr.peek = peek
 	return true
 }
 
@@ -76,6 +112,9 @@ func (cr *chunkReader) Peek() bool {
 // that this chunk was created from which is useful because the last chunk might
 // be padded.
 func (cr *chunkReader) ReadChunk() ([][]byte, uint64, error) {
+	if cr.peekErr != nil {
+		return nil, 0, errors.AddContext(cr.peekErr, "ReadChunk: failed to peek data")
+	}
 	r := io.MultiReader(bytes.NewReader(cr.peek), cr.staticReader)
 	dataPieces, n, err := readDataPieces(r, cr.staticEC, cr.staticPieceSize)
 	if err != nil {
@@ -112,14 +151,102 @@ func (cr *fanoutChunkReader) ReadChunk() ([][]byte, uint64, error) {
 		return chunk, n, err
 	}
 	// Append the root to the fanout.
+	cr.fanout = appendFanoutRoots(cr.fanout, chunk, cr.staticOnePiece)
+	return chunk, n, nil
+}
+
+// Fanout returns the current fanout.
+func (cr *sparseFanoutChunkReader) Fanout() []byte {
+	return cr.fanout
+}
+
+// Size returns the number of bytes read so far, including the bytes of the
+// skipped zero chunks.
+func (cr *sparseFanoutChunkReader) Size() uint64 {
+	return cr.size
+}
+
+// Peek returns whether the next call to ReadChunk is expected to return a
+// chunk or if there is no more data. Since trailing zero chunks are not
+// returned, the next chunk with data is read ahead of time.
+func (cr *sparseFanoutChunkReader) Peek() bool {
+	if cr.peeked {
+		return true
+	}
+	if !cr.staticChunkReader.Peek() {
+		return false
+	}
+	chunk, n, err := cr.readNonZeroChunk()
+	if errors.Contains(err, io.EOF) {
+		return false
+	}
+	cr.peeked = true
+	cr.peekChunk, cr.peekN, cr.peekErr = chunk, n, err
+	return true
+}
+
+// ReadChunk reads the next chunk from the reader which contains data other
+// than zeros.
+func (cr *sparseFanoutChunkReader) ReadChunk() ([][]byte, uint64, error) {
+	if cr.peeked {
+		cr.peeked = false
+		chunk, n, err := cr.peekChunk, cr.peekN, cr.peekErr
+		cr.peekChunk, cr.peekErr = nil, nil
+		return chunk, n, err
+	}
+	return cr.readNonZeroChunk()
+}
+
+// readNonZeroChunk reads chunks from the underlying reader until it finds one
+// which contains data other than zeros. All zero chunks that are read before
+// that chunk are added to the fanout without being returned.
+func (cr *sparseFanoutChunkReader) readNonZeroChunk() ([][]byte, uint64, error) {
+	for {
+		chunk, n, err := cr.staticChunkReader.ReadChunk()
+		if err != nil {
+			return chunk, n, err
+		}
+		cr.size += n
+		if !isZeroChunk(chunk) {
+			cr.fanout = appendFanoutRoots(cr.fanout, chunk, cr.staticOnePiece)
+			return chunk, n, nil
+		}
+		numRoots := len(chunk)
+		if cr.staticOnePiece {
+			numRoots = 1
+		}
+		for i := 0; i < numRoots; i++ {
+			cr.fanout = append(cr.fanout, skymodules.ZeroChunkRoot[:]...)
+		}
+	}
+}
+
+// appendFanoutRoots appends the merkle roots of the pieces of a chunk to the
+// fanout. If only one piece is needed, only the root of the first piece is
+// appended.
+func appendFanoutRoots(fanout []byte, chunk [][]byte, onePiece bool) []byte {
 	for pieceIndex := range chunk {
 		root := crypto.MerkleRoot(chunk[pieceIndex])
-		cr.fanout = append(cr.fanout, root[:]...)
+		fanout = append(fanout, root[:]...)
 
-		// If only one piece is needed break out of the inner loop.
-		if cr.staticOnePiece {
+		// If only one piece is needed break out of the loop.
+		if onePiece {
 			break
 		}
 	}
-	return chunk, n, nil
+	return fanout
+}
+
+// isZeroChunk returns whether all the pieces of a chunk only contain zeros.
+func isZeroChunk(chunk [][]byte) bool {
+	var zeros []byte
+	for _, piece := range chunk {
+		if len(zeros) < len(piece) {
+			zeros = make([]byte, len(piece))
+		}
+		if !bytes.Equal(piece, zeros[:len(piece)]) {
+			return false
+		}
+	}
+	return true
 }
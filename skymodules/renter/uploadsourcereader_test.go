@@ -0,0 +1,154 @@
+package renter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skymodules"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestChunkReaderPeekError makes sure that an error which happens while
+// peeking at a chunk boundary is not mistaken for the end of the data.
+func TestChunkReaderPeekError(t *testing.T) {
+	t.Parallel()
+
+	ec := skymodules.NewRSSubCodeDefault()
+	mk := crypto.GenerateSiaKey(crypto.TypePlain)
+	chunkSize := int(modules.SectorSize) * ec.MinPieces()
+
+	// Create a reader that returns an error after exactly one chunk.
+	errRead := errors.New("read failed")
+	data := fastrand.Bytes(chunkSize)
+	r := io.MultiReader(bytes.NewReader(data), &errReader{err: errRead})
+	cr := NewChunkReader(r, ec, mk)
+
+	// The first chunk should be read successfully.
+	if !cr.Peek() {
+		t.Fatal("expected more data")
+	}
+	_, n, err := cr.ReadChunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != uint64(chunkSize) {
+		t.Fatal("wrong number of bytes read", n)
+	}
+
+	// Peeking should indicate more data and reading the next chunk should
+	// return the error.
+	if !cr.Peek() {
+		t.Fatal("peek should return true on error")
+	}
+	_, _, err = cr.ReadChunk()
+	if !errors.Contains(err, errRead) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// A reader at EOF should return false.
+	cr = NewChunkReader(bytes.NewReader(nil), ec, mk)
+	if cr.Peek() {
+		t.Fatal("peek should return false at EOF")
+	}
+}
+
+// TestSparseFanoutChunkReader tests that the sparseFanoutChunkReader skips
+// chunks which only contain zeros and marks them in the fanout.
+func TestSparseFanoutChunkReader(t *testing.T) {
+	t.Parallel()
+
+	ec, err := skymodules.NewRSSubCode(1, 2, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mk := crypto.GenerateSiaKey(crypto.TypePlain)
+	chunkSize := int(modules.SectorSize)
+
+	// Create data with a zero chunk in the middle and a partial zero chunk
+	// at the end.
+	chunk0 := fastrand.Bytes(chunkSize)
+	chunk2 := fastrand.Bytes(chunkSize)
+	var data []byte
+	data = append(data, chunk0...)
+	data = append(data, make([]byte, chunkSize)...)
+	data = append(data, chunk2...)
+	data = append(data, make([]byte, chunkSize/2)...)
+
+	for _, onePiece := range []bool{true, false} {
+		cr := newSparseFanoutChunkReader(bytes.NewReader(data), ec, onePiece, mk)
+
+		// readChunk is a helper to read the next chunk and compare it to
+		// the expected data.
+		readChunk := func(expected []byte) {
+			t.Helper()
+			if !cr.Peek() {
+				t.Fatal("expected more data")
+			}
+			chunk, n, err := cr.ReadChunk()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != uint64(len(expected)) || !bytes.Equal(chunk[0], expected) {
+				t.Fatal("wrong chunk returned")
+			}
+		}
+		readChunk(chunk0)
+		readChunk(chunk2)
+		if cr.Peek() {
+			t.Fatal("trailing zero chunk shouldn't be returned")
+		}
+		if cr.Size() != uint64(len(data)) {
+			t.Fatalf("expected size %v but got %v", len(data), cr.Size())
+		}
+
+		// Check the fanout.
+		layout := skymodules.SkyfileLayout{
+			Filesize:           uint64(len(data)),
+			FanoutDataPieces:   1,
+			FanoutParityPieces: 2,
+			CipherType:         crypto.TypePlain,
+		}
+		if !onePiece {
+			// Pretend that the fanout is encrypted to decode all roots.
+			layout.CipherType = crypto.TypeXChaCha20
+		}
+		chunks, err := layout.DecodeFanoutIntoChunks(cr.Fanout())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks) != 4 {
+			t.Fatal("wrong number of chunks", len(chunks))
+		}
+		for i, chunk := range chunks {
+			if isZero := i%2 == 1; skymodules.IsZeroChunk(chunk) != isZero {
+				t.Fatalf("chunk %v: expected zero chunk %v", i, isZero)
+			}
+		}
+		if chunks[0][0] != crypto.MerkleRoot(chunk0) || chunks[2][0] != crypto.MerkleRoot(chunk2) {
+			t.Fatal("wrong roots")
+		}
+	}
+
+	// A reader that only contains zeros has no chunks to upload.
+	cr := newSparseFanoutChunkReader(bytes.NewReader(make([]byte, 2*chunkSize)), ec, true, mk)
+	if cr.Peek() {
+		t.Fatal("expected no data")
+	}
+	if len(cr.Fanout()) != 2*crypto.HashSize || cr.Size() != uint64(2*chunkSize) {
+		t.Fatal("wrong fanout or size", len(cr.Fanout()), cr.Size())
+	}
+}
+
+// errReader is a reader which always returns an error.
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (r *errReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
@@ -307,26 +307,35 @@ func (r *Renter) callUploadStreamFromReaderWithFileNode(ctx context.Context, fil
 	if err != nil {
 		return n, err
 	}
+	return n, r.managedWaitForUploadStream(ctx, chunks)
+}
+
+// managedWaitForUploadStream waits for the chunks of a streaming upload which
+// were returned by callUploadStreamFromReaderWithFileNodeNoBlock to become
+// available on the Sia network. It stops waiting once the ctx is closed.
+func (r *Renter) managedWaitForUploadStream(ctx context.Context, chunks []*unfinishedUploadChunk) (err error) {
 	// Wait for all chunks to become available.
 	for _, chunk := range chunks {
 		select {
 		case <-r.tg.StopChan():
 			err = errors.New("upload timed out, renter has shutdown")
+		case <-ctx.Done():
+			err = errors.AddContext(ctx.Err(), "upload was cancelled")
 		case <-chunk.staticAvailableChan:
 			chunk.mu.Lock()
 			err = chunk.err
 			chunk.mu.Unlock()
 		}
 		if err != nil {
-			return n, errors.AddContext(err, "upload streamer failed to get all data available")
+			return errors.AddContext(err, "upload streamer failed to get all data available")
 		}
 	}
 	// Disrupt to force an error and ensure the fileNode is being closed
 	// correctly.
 	if r.staticDeps.Disrupt("failUploadStreamFromReader") {
-		return n, errors.New("disrupted by failUploadStreamFromReader")
+		return errors.New("disrupted by failUploadStreamFromReader")
 	}
-	return n, nil
+	return nil
 }
 
 // callUploadStreamFromReader reads from the provided reader until io.EOF is
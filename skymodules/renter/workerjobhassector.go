@@ -435,7 +435,9 @@ func (j *jobHasSectorBatch) managedHasSector() (results [][]uint64, err error) {
 	for _, hsj := range j.staticJobs {
 		var availables []uint64
 		for i := 0; i < len(hsj.staticSectors); i++ {
-			if hasSectors[i] {
+			// Sectors can be made unavailable through the dependencies to
+			// simulate lost data.
+			if hasSectors[i] && !w.staticRenter.staticDeps.SectorUnavailable(hsj.staticSectors[i]) {
 				availables = append(availables, uint64(i))
 			}
 		}
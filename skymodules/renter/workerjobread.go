@@ -92,6 +92,10 @@ type (
 
 		// The time it took for this job to complete.
 		staticJobTime time.Duration
+
+		// The bandwidth, uploaded and downloaded, that was consumed by the
+		// program which executed the job.
+		staticBandwidth uint64
 	}
 
 	// jobReadMetadata contains meta information about a read job.
@@ -158,7 +162,7 @@ func (j *jobRead) callDiscard(err error) {
 // managedFinishExecute will execute code that is shared by multiple read jobs
 // after execution. It updates the performance metrics, records whether the
 // execution was successful and returns the response.
-func (j *jobRead) managedFinishExecute(readData []byte, readErr error, readJobTime time.Duration) {
+func (j *jobRead) managedFinishExecute(readData []byte, readBandwidth uint64, readErr error, readJobTime time.Duration) {
 	// Log result and finish
 	if j.staticSpan != nil {
 		j.staticSpan.LogKV(
@@ -176,8 +180,9 @@ func (j *jobRead) managedFinishExecute(readData []byte, readErr error, readJobTi
 		staticData: readData,
 		staticErr:  readErr,
 
-		staticMetadata: j.staticJobReadMetadata(),
-		staticJobTime:  readJobTime,
+		staticMetadata:  j.staticJobReadMetadata(),
+		staticJobTime:   readJobTime,
+		staticBandwidth: readBandwidth,
 	}
 	w := j.staticQueue.staticWorker()
 	err := w.staticTG.Launch(func() {
@@ -217,12 +222,19 @@ func (j *jobRead) callExpectedBandwidth() (ul, dl uint64) {
 }
 
 // managedRead returns the sector data for the given read program and the merkle
-// proof.
-func (j *jobRead) managedRead(w *worker, program modules.Program, programData []byte, cost types.Currency) ([]programResponse, error) {
+// proof. It also returns the bandwidth that was consumed by the program, which
+// is returned even if the program failed.
+func (j *jobRead) managedRead(w *worker, program modules.Program, programData []byte, cost types.Currency) ([]programResponse, uint64, error) {
 	// execute it
-	responses, _, err := w.managedExecuteProgram(program, programData, w.staticCache().staticContractID, j.staticJobReadMetadata().staticSpendingCategory, cost)
+	responses, limit, err := w.managedExecuteProgram(program, programData, w.staticCache().staticContractID, j.staticJobReadMetadata().staticSpendingCategory, cost)
+
+	// The limit is nil if the stream couldn't be created.
+	var bandwidth uint64
+	if limit != nil {
+		bandwidth = limit.Downloaded() + limit.Uploaded()
+	}
 	if err != nil {
-		return []programResponse{}, err
+		return []programResponse{}, bandwidth, err
 	}
 
 	// Sanity check number of responses.
@@ -236,21 +248,21 @@ func (j *jobRead) managedRead(w *worker, program modules.Program, programData []
 	// contain an error message.
 	if len(responses) != len(program) {
 		err := responses[len(responses)-1].Error
-		return []programResponse{}, errors.AddContext(err, "managedRead: program execution was interrupted")
+		return []programResponse{}, bandwidth, errors.AddContext(err, "managedRead: program execution was interrupted")
 	}
 
 	// The last instruction is the actual download.
 	response := responses[len(responses)-1]
 	if response.Error != nil {
-		return []programResponse{}, response.Error
+		return []programResponse{}, bandwidth, response.Error
 	}
 	sectorData := response.Output
 
 	// Check that we received the amount of data that we were expecting.
 	if uint64(len(sectorData)) != j.staticLength {
-		return []programResponse{}, errors.New("worker returned the wrong amount of data")
+		return []programResponse{}, bandwidth, errors.New("worker returned the wrong amount of data")
 	}
-	return responses, nil
+	return responses, bandwidth, nil
 }
 
 // callAddWithEstimate will add a job to the job read queue while providing an
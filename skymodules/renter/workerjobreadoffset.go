@@ -22,15 +22,16 @@ type (
 func (j *jobReadOffset) callExecute() {
 	// Track how long the job takes.
 	start := time.Now()
-	data, err := j.managedReadOffset()
+	data, bandwidth, err := j.managedReadOffset()
 	jobTime := time.Since(start)
 
 	// Finish the execution.
-	j.jobRead.managedFinishExecute(data, err, jobTime)
+	j.jobRead.managedFinishExecute(data, bandwidth, err, jobTime)
 }
 
-// managedReadOffset returns the sector data for given root.
-func (j *jobReadOffset) managedReadOffset() ([]byte, error) {
+// managedReadOffset returns the sector data for given root as well as the
+// bandwidth consumed by reading it.
+func (j *jobReadOffset) managedReadOffset() ([]byte, uint64, error) {
 	// create the program
 	w := j.staticQueue.staticWorker()
 	bh := w.staticCache().staticBlockHeight
@@ -47,9 +48,9 @@ func (j *jobReadOffset) managedReadOffset() ([]byte, error) {
 	cost = cost.Add(bandwidthCost)
 
 	// Read responses.
-	responses, err := j.jobRead.managedRead(w, program, programData, cost)
+	responses, bandwidth, err := j.jobRead.managedRead(w, program, programData, cost)
 	if err != nil {
-		return nil, errors.AddContext(err, "jobReadOffset: failed to execute managedRead")
+		return nil, bandwidth, errors.AddContext(err, "jobReadOffset: failed to execute managedRead")
 	}
 	revResponse := responses[0]
 	downloadResponse := responses[1]
@@ -60,23 +61,23 @@ func (j *jobReadOffset) managedReadOffset() ([]byte, error) {
 	// agreed upon at some point.
 	cpk, ok := w.staticRenter.staticHostContractor.ContractPublicKey(w.staticHostPubKey)
 	if !ok {
-		return nil, errors.New("jobReadOffset: failed to get public key for contract")
+		return nil, bandwidth, errors.New("jobReadOffset: failed to get public key for contract")
 	}
 
 	// Unmarshal the revision response.
 	var revResp modules.MDMInstructionRevisionResponse
 	err = encoding.Unmarshal(revResponse.Output, &revResp)
 	if err != nil {
-		return nil, errors.AddContext(err, "jobReadOffset: failed to unmarshal revision")
+		return nil, bandwidth, errors.AddContext(err, "jobReadOffset: failed to unmarshal revision")
 	}
 	// Check that the revision txn contains the right number of signatures
 	revisionTxn := revResp.RevisionTxn
 	if len(revisionTxn.TransactionSignatures) != 2 {
-		return nil, errors.New("jobReadOffset: invalid number of signatures on txn")
+		return nil, bandwidth, errors.New("jobReadOffset: invalid number of signatures on txn")
 	}
 	// Check that the revision txn contains the right number of revisions.
 	if len(revisionTxn.FileContractRevisions) != 1 {
-		return nil, errors.New("jobReadOffset: invalid number of revisions in txn")
+		return nil, bandwidth, errors.New("jobReadOffset: invalid number of revisions in txn")
 	}
 	rev := revResp.RevisionTxn.FileContractRevisions[0]
 	// Verify the signatures.
@@ -85,16 +86,16 @@ func (j *jobReadOffset) managedReadOffset() ([]byte, error) {
 	hash := revisionTxn.SigHash(0, bh) // this should be the start height but this works too
 	err = crypto.VerifyHash(hash, cpk, signature)
 	if err != nil {
-		return nil, errors.AddContext(err, "jobReadOffset: failed to verify signature on revision")
+		return nil, bandwidth, errors.AddContext(err, "jobReadOffset: failed to verify signature on revision")
 	}
 	// Verify proof.
 	proofStart := int(j.staticOffset) / crypto.SegmentSize
 	proofEnd := int(j.staticOffset+j.staticLength) / crypto.SegmentSize
 	ok = crypto.VerifyMixedRangeProof(downloadResponse.Output, downloadResponse.Proof, rev.NewFileMerkleRoot, proofStart, proofEnd)
 	if !ok {
-		return nil, errors.New("verifying proof failed")
+		return nil, bandwidth, errors.New("verifying proof failed")
 	}
-	return downloadResponse.Output, nil
+	return downloadResponse.Output, bandwidth, nil
 }
 
 // ReadOffset is a helper method to run a ReadOffset job on a worker.
@@ -31,17 +31,19 @@ func (j *jobReadSector) callExecute() {
 
 	// Track how long the job takes.
 	start := time.Now()
-	data, err := j.managedReadSector()
+	data, bandwidth, err := j.managedReadSector()
 	jobTime := time.Since(start)
 
 	// Finish the execution.
-	j.jobRead.managedFinishExecute(data, err, jobTime)
+	j.jobRead.managedFinishExecute(data, bandwidth, err, jobTime)
 }
 
-// managedReadSector returns the sector data for given root.
-func (j *jobReadSector) managedReadSector() ([]byte, error) {
+// managedReadSector returns the sector data for given root as well as the
+// bandwidth consumed by reading it.
+func (j *jobReadSector) managedReadSector() ([]byte, uint64, error) {
 	// create the program
 	w := j.staticQueue.staticWorker()
+	w.staticRenter.staticDeps.Disrupt("CountReadSector")
 	pt := w.staticPriceTable().staticPriceTable
 	pb := modules.NewProgramBuilder(&pt, 0) // 0 duration since ReadSector doesn't depend on it.
 	pb.AddReadSectorInstruction(j.staticLength, j.staticOffset, j.staticSector, true)
@@ -53,9 +55,9 @@ func (j *jobReadSector) managedReadSector() ([]byte, error) {
 	bandwidthCost := modules.MDMBandwidthCost(pt, ulBandwidth, dlBandwidth)
 	cost = cost.Add(bandwidthCost)
 
-	responses, err := j.jobRead.managedRead(w, program, programData, cost)
+	responses, bandwidth, err := j.jobRead.managedRead(w, program, programData, cost)
 	if err != nil {
-		return nil, errors.AddContext(err, "jobReadSector: failed to execute managedRead")
+		return nil, bandwidth, errors.AddContext(err, "jobReadSector: failed to execute managedRead")
 	}
 	data := responses[0].Output
 	proof := responses[0].Proof
@@ -64,9 +66,9 @@ func (j *jobReadSector) managedReadSector() ([]byte, error) {
 	proofStart := int(j.staticOffset) / crypto.SegmentSize
 	proofEnd := int(j.staticOffset+j.staticLength) / crypto.SegmentSize
 	if !crypto.VerifyRangeProof(data, proof, proofStart, proofEnd, j.staticSector) {
-		return nil, errors.New("proof verification failed")
+		return nil, bandwidth, errors.New("proof verification failed")
 	}
-	return data, nil
+	return data, bandwidth, nil
 }
 
 // newJobReadSector creates a new read sector job.
@@ -9,6 +9,16 @@ import (
 	"gitlab.com/NebulousLabs/errors"
 )
 
+var (
+	// errWorkerOnCooldown is returned for the async jobs of a worker whose
+	// account is on cooldown.
+	errWorkerOnCooldown = errors.New("the worker account is on cooldown")
+
+	// errWorkerPriceTableInvalid is returned for the async jobs of a worker
+	// whose price table expired.
+	errWorkerPriceTableInvalid = errors.New("price table with host is no longer valid")
+)
+
 type (
 	// workerLoopState tracks the state of the worker loop.
 	workerLoopState struct {
@@ -180,13 +190,13 @@ func (w *worker) externLaunchAsyncJob(job workerJob) bool {
 func (w *worker) managedAsyncReady() bool {
 	// A valid price table is required to perform async tasks.
 	if wpt := w.staticPriceTable(); !wpt.staticValid() {
-		w.managedDiscardAsyncJobs(errors.New("price table with host is no longer valid"))
+		w.managedDiscardAsyncJobs(errWorkerPriceTableInvalid)
 		return false
 	}
 
 	// RHP3 must not be on cooldown to perform async tasks.
 	if w.managedOnMaintenanceCooldown() {
-		w.managedDiscardAsyncJobs(errors.New("the worker account is on cooldown"))
+		w.managedDiscardAsyncJobs(errWorkerOnCooldown)
 		return false
 	}
 	return true
@@ -285,6 +285,7 @@ func (w *worker) managedPerformUploadChunkJob() {
 	//
 	// Ignore the error if it's a ErrMaxVirtualSectors coming from a pre-1.5.5
 	// host.
+	w.staticRenter.staticDeps.Disrupt("CountUploadSector")
 	root, err := s.Upload(uc.physicalChunkData[pieceIndex])
 	ignoreErr := build.VersionCmp(hostSettings.Version, "1.5.5") < 0 && err != nil && strings.Contains(err.Error(), modules.ErrMaxVirtualSectors.Error())
 	if err != nil && !ignoreErr {
@@ -10,6 +10,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"sort"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -28,6 +29,18 @@ var (
 	// ErrSkyfileMetadataUnavailable is returned when the context passed to
 	// SkyfileMetadata is cancelled before the metadata became available
 	ErrSkyfileMetadataUnavailable = errors.New("metadata unavailable")
+
+	// ErrMultipartSizeMismatch is returned when the multipart form contains a
+	// part whose declared Content-Length doesn't match the length of its data
+	ErrMultipartSizeMismatch = errors.New("multipart file length doesn't match its declared Content-Length")
+
+	// ErrSymlinkWithData is returned when the multipart form contains a
+	// symlink part which contains data
+	ErrSymlinkWithData = errors.New("multipart symlink can't contain any data")
+
+	// ErrDuplicateSubfile is returned when the multipart form contains
+	// multiple parts with the same path
+	ErrDuplicateSubfile = errors.New("multipart upload contains a duplicate subfile")
 )
 
 type (
@@ -39,6 +52,21 @@ type (
 		io.Reader
 	}
 
+	// SkyfilePartStager stages the parts of a multipart upload. That allows
+	// for resuming a failed upload without transferring the parts which were
+	// already received again.
+	SkyfilePartStager interface {
+		// StagedPart returns the staged data of the part with the given
+		// filename if there is any. It is used to replace a zero-length
+		// placeholder part.
+		StagedPart(filename string) ([]byte, bool)
+
+		// StagePart returns a writer for the data of the part with the given
+		// filename. Closing the writer stages the data. It returns nil if the
+		// part shouldn't be staged.
+		StagePart(filename string) io.WriteCloser
+	}
+
 	// skyfileMultipartReader is a helper struct that implements the
 	// SkyfileUploadReader interface for a multipart upload.
 	//
@@ -52,8 +80,29 @@ type (
 		currOff  uint64
 		currPart *multipart.Part
 
+		// currPartBuf contains the data of the current part which was read
+		// ahead to sniff its content type.
+		currPartBuf []byte
+		numParts    uint64
+
+		// currPartStaged indicates whether the data of the current part is
+		// replaced with staged data. currPartStage receives the data of the
+		// current part if it should be staged.
+		currPartStaged bool
+		currPartStage  io.WriteCloser
+
+		// headerBytes is the size of the headers of all parts read so far.
+		// subfilePaths maps the cleaned paths of those parts to their
+		// filenames to detect duplicates.
+		headerBytes  uint64
+		subfilePaths map[string]string
+
 		metadata      SkyfileMetadata
 		metadataAvail chan struct{}
+
+		staticLimits       SkynetMultipartLimits
+		staticPartStager   SkyfilePartStager
+		staticUploadPolicy SkynetUploadPolicy
 	}
 
 	// skyfileReader is a helper struct that implements the SkyfileUploadReader
@@ -81,6 +130,8 @@ func NewSkyfileReader(reader io.Reader, sup SkyfileUploadParameters) SkyfileUplo
 		metadata: SkyfileMetadata{
 			Filename: sup.Filename,
 			Mode:     sup.Mode,
+			Redirect: sup.Redirect,
+			ModTime:  sup.ModTime,
 		},
 		metadataAvail: make(chan struct{}),
 	}
@@ -235,9 +286,14 @@ func newSkyfileMultipartReader(reader *multipart.Reader, sup SkyfileUploadParame
 			DisableDefaultPath: sup.DisableDefaultPath,
 			TryFiles:           sup.TryFiles,
 			ErrorPages:         sup.ErrorPages,
+			ModTime:            sup.ModTime,
 			Subfiles:           make(SkyfileSubfiles),
 		},
-		metadataAvail: make(chan struct{}),
+		metadataAvail:      make(chan struct{}),
+		subfilePaths:       make(map[string]string),
+		staticLimits:       sup.MultipartLimits.WithDefaults(),
+		staticPartStager:   sup.PartStager,
+		staticUploadPolicy: sup.UploadPolicy,
 	}
 }
 
@@ -314,26 +370,81 @@ func (sr *skyfileMultipartReader) Read(p []byte) (n int, err error) {
 			}
 			sr.currOff += sr.currLen
 			sr.currLen = 0
+			sr.currPartStaged = false
+			sr.currPartStage = nil
+
+			// verify the part is within the limits before reading any of
+			// its data
+			err = sr.checkCurrPartLimits()
+			if err != nil {
+				break
+			}
 
 			// verify the multipart file is submitted under the expected name
 			if !isLegalFormName(sr.currPart.FormName()) {
 				err = ErrIllegalFormName
 				break
 			}
+
+			// verify the part is allowed by the upload policy
+			err = sr.checkCurrPartUploadPolicy()
+			if err != nil {
+				break
+			}
+
+			// stage the part if necessary
+			if sr.staticPartStager != nil {
+				filename, _ := partFilename(sr.currPart)
+				sr.currPartStage = sr.staticPartStager.StagePart(filename)
+			}
 		}
 
 		// read data from the part
 		var nn int
-		nn, err = sr.currPart.Read(p[n:])
+		nn, err = sr.readCurrPart(p[n:])
+		if sr.currPartStage != nil && nn > 0 {
+			_, stageErr := sr.currPartStage.Write(p[n : n+nn])
+			if stageErr != nil {
+				err = errors.Compose(err, errors.AddContext(stageErr, "failed to stage part"))
+			}
+		}
 		n += nn
 
 		// update the length
 		sr.currLen += uint64(nn)
 
+		// a part that ends unexpectedly is truncated, check whether its
+		// declared length tells us more
+		if errors.Contains(err, io.ErrUnexpectedEOF) {
+			err = errors.Compose(err, sr.verifyCurrPartLength())
+			break
+		}
+
 		// ignore the EOF to continue reading from the next part if necessary,
 		if err == io.EOF {
 			err = nil
 
+			// a zero-length placeholder is replaced with the part's staged
+			// data
+			if sr.currLen == 0 && !sr.currPartStaged && sr.staticPartStager != nil {
+				filename, _ := partFilename(sr.currPart)
+				if staged, ok := sr.staticPartStager.StagedPart(filename); ok {
+					sr.currPartBuf = staged
+					sr.currPartStaged = true
+					sr.currPartStage = nil
+					continue
+				}
+			}
+
+			// stage the part now that all of its data was read
+			if sr.currPartStage != nil {
+				err = sr.currPartStage.Close()
+				sr.currPartStage = nil
+				if err != nil {
+					break
+				}
+			}
+
 			// create the metadata for the current subfile before resetting the
 			// current part
 			err = sr.createSubfileFromCurrPart()
@@ -347,6 +458,73 @@ func (sr *skyfileMultipartReader) Read(p []byte) (n int, err error) {
 	return
 }
 
+// checkCurrPartLimits checks the current part against the multipart limits
+// and the paths of the previous parts.
+func (sr *skyfileMultipartReader) checkCurrPartLimits() error {
+	sr.numParts++
+
+	// count the bytes of the header as they appear on the wire
+	for key, values := range sr.currPart.Header {
+		for _, value := range values {
+			sr.headerBytes += uint64(len(key) + len(": \r\n") + len(value))
+		}
+	}
+	if sr.headerBytes > sr.staticLimits.MaxHeaderBytes {
+		return errors.AddContext(ErrMultipartHeadersTooLarge, fmt.Sprintf("headers of the parts exceed %v bytes", sr.staticLimits.MaxHeaderBytes))
+	}
+
+	// check the filename, parts without one are rejected later on
+	filename, err := partFilename(sr.currPart)
+	if err != nil {
+		return nil
+	}
+	if uint64(len(filename)) > sr.staticLimits.MaxFilenameLength {
+		return errors.AddContext(ErrSubfileNameTooLong, fmt.Sprintf("name of subfile %v exceeds %v bytes", sr.numParts, sr.staticLimits.MaxFilenameLength))
+	}
+	subfilePath := path.Clean("/" + filename)
+	if existing, exists := sr.subfilePaths[subfilePath]; exists {
+		return errors.AddContext(ErrDuplicateSubfile, fmt.Sprintf("subfile '%v' has the same path as subfile '%v'", filename, existing))
+	}
+	sr.subfilePaths[subfilePath] = filename
+	return nil
+}
+
+// checkCurrPartUploadPolicy checks the current part against the upload
+// policy. If the part doesn't declare its content type, it is sniffed from the
+// beginning of its data.
+func (sr *skyfileMultipartReader) checkCurrPartUploadPolicy() error {
+	err := sr.staticUploadPolicy.CheckSubfiles(sr.numParts)
+	if err != nil {
+		return err
+	}
+	filename, err := partFilename(sr.currPart)
+	if err != nil {
+		return err
+	}
+	contentType := sr.currPart.Header.Get("Content-Type")
+	if contentType == "" && sr.staticUploadPolicy.HasMIMERules() {
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(sr.currPart, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return errors.AddContext(err, "failed to read data to sniff content type")
+		}
+		sr.currPartBuf = buf[:n]
+		contentType = http.DetectContentType(sr.currPartBuf)
+	}
+	return sr.staticUploadPolicy.CheckFile(filename, contentType)
+}
+
+// readCurrPart reads from the current part. Data that was read ahead to sniff
+// the content type is returned first.
+func (sr *skyfileMultipartReader) readCurrPart(p []byte) (int, error) {
+	if len(sr.currPartBuf) > 0 {
+		n := copy(p, sr.currPartBuf)
+		sr.currPartBuf = sr.currPartBuf[n:]
+		return n, nil
+	}
+	return sr.currPart.Read(p)
+}
+
 // createSubfileFromCurrPart adds a subfile for the current part.
 func (sr *skyfileMultipartReader) createSubfileFromCurrPart() error {
 	// sanity check the reader has a current part set
@@ -362,29 +540,74 @@ func (sr *skyfileMultipartReader) createSubfileFromCurrPart() error {
 	}
 
 	// parse the filename
-	// WARNING: don't use currPart.Filename() here since it caused
-	// unexpected behaviour on some deploys. Paths were trimmed from the
-	// filename, flattening the directory structure of the upload.
-	values := sr.currPart.Header.Get("Content-Disposition")
-	_, m, err := mime.ParseMediaType(values)
+	filename, err := partFilename(sr.currPart)
 	if err != nil {
-		return errors.AddContext(err, "failed to parse media type for subfile")
+		return err
 	}
-	filename, exists := m["filename"]
-	if !exists || filename == "" {
-		return ErrEmptyFilename
+
+	// verify the length of the part, the declared length of a placeholder
+	// doesn't include the staged data
+	if !sr.currPartStaged {
+		if err := sr.verifyCurrPartLength(); err != nil {
+			return err
+		}
+	}
+
+	// symlinks only consist of their target
+	symlinkTarget := sr.currPart.Header.Get(SymlinkTargetHeader)
+	if symlinkTarget != "" && sr.currLen > 0 {
+		return errors.AddContext(ErrSymlinkWithData, fmt.Sprintf("symlink '%v' contains %v bytes", filename, sr.currLen))
 	}
 
 	sr.metadata.Subfiles[filename] = SkyfileSubfileMetadata{
-		FileMode:    mode,
-		Filename:    filename,
-		ContentType: sr.currPart.Header.Get("Content-Type"),
-		Offset:      sr.currOff,
-		Len:         sr.currLen,
+		FileMode:      mode,
+		Filename:      filename,
+		ContentType:   sr.currPart.Header.Get("Content-Type"),
+		Offset:        sr.currOff,
+		Len:           sr.currLen,
+		SymlinkTarget: symlinkTarget,
+	}
+	return nil
+}
+
+// verifyCurrPartLength checks the number of bytes read from the current part
+// against the part's Content-Length header. Parts without the header are not
+// verified.
+func (sr *skyfileMultipartReader) verifyCurrPartLength() error {
+	lengthStr := sr.currPart.Header.Get("Content-Length")
+	if lengthStr == "" {
+		return nil
+	}
+	var length uint64
+	_, err := fmt.Sscan(lengthStr, &length)
+	if err != nil {
+		return errors.Compose(ErrMultipartSizeMismatch, errors.AddContext(err, "failed to parse Content-Length of multipart file"))
+	}
+	if length != sr.currLen {
+		return errors.AddContext(ErrMultipartSizeMismatch, fmt.Sprintf("multipart file declared %v bytes but contained %v bytes", length, sr.currLen))
 	}
 	return nil
 }
 
+// partFilename is a helper function that parses the filename of a part from
+// its Content-Disposition header.
+//
+// WARNING: don't use part.Filename() here since it caused unexpected
+// behaviour on some deploys. Paths were trimmed from the filename, flattening
+// the directory structure of the upload.
+func partFilename(part *multipart.Part) (string, error) {
+	values := part.Header.Get("Content-Disposition")
+	_, m, err := mime.ParseMediaType(values)
+	if err != nil {
+		return "", errors.AddContext(err, "failed to parse media type for subfile")
+	}
+	filename, exists := m["filename"]
+	if !exists || filename == "" {
+		return "", ErrEmptyFilename
+	}
+	return filename, nil
+}
+
 // isLegalFormName is a helper function that returns true if the given form name
 // is allowed to submit a Skyfile subfile.
 func isLegalFormName(formName string) bool {
@@ -7,7 +7,12 @@ import (
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -205,9 +210,15 @@ func TestSkyfileMultipartReader(t *testing.T) {
 	t.Run("Basic", testSkyfileMultipartReaderBasic)
 	t.Run("IllegalFormName", testSkyfileMultipartReaderIllegalFormName)
 	t.Run("EmptyFilename", testSkyfileMultipartReaderEmptyFilename)
+	t.Run("SizeMismatch", testSkyfileMultipartReaderSizeMismatch)
+	t.Run("Symlink", testSkyfileMultipartReaderSymlink)
+	t.Run("EmptyDirectory", testSkyfileMultipartReaderEmptyDirectory)
+	t.Run("StagedParts", testSkyfileMultipartReaderStagedParts)
 	t.Run("RandomReadSize", testSkyfileMultipartReaderRandomReadSize)
 	t.Run("ReadBuffer", testSkyfileMultipartReaderReadBuffer)
 	t.Run("MetadataTimeout", testSkyfileMultipartReaderMetadataTimeout)
+	t.Run("UploadPolicy", testSkyfileMultipartReaderUploadPolicy)
+	t.Run("Limits", testSkyfileMultipartReaderLimits)
 }
 
 // testSkyfileMultipartReaderBasic verifies the basic use case of a skyfile
@@ -473,6 +484,345 @@ func testSkyfileMultipartReaderEmptyFilename(t *testing.T) {
 	}
 }
 
+// testSkyfileMultipartReaderSymlink verifies that symlink parts are added to
+// the metadata with their target and that they can't contain any data.
+func testSkyfileMultipartReaderSymlink(t *testing.T) {
+	t.Parallel()
+
+	// create upload parameters
+	sup := SkyfileUploadParameters{
+		Filename: t.Name(),
+		Mode:     DefaultFilePerm,
+	}
+
+	// write a file and a symlink pointing to it
+	buffer := new(bytes.Buffer)
+	writer := multipart.NewWriter(buffer)
+	data := fastrand.Bytes(10)
+	off := uint64(0)
+	md1, err1 := AddMultipartFile(writer, data, "files[]", "dir/file", 0600, &off)
+	md2, err2 := AddMultipartSymlink(writer, "files[]", "link", "dir/file", 0777, &off)
+	if err := errors.Compose(err1, err2, writer.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// read the upload
+	multipartReader := multipart.NewReader(bytes.NewReader(buffer.Bytes()), writer.Boundary())
+	sfReader := NewSkyfileMultipartReader(multipartReader, sup)
+	read, err := ioutil.ReadAll(sfReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatal("unexpected data")
+	}
+
+	// verify the metadata
+	metadata, err := sfReader.SkyfileMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSkyfileMetadata(metadata); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metadata.Subfiles["dir/file"], md1) || metadata.Subfiles["dir/file"].IsSymlink() {
+		t.Fatal("unexpected file metadata", metadata.Subfiles["dir/file"])
+	}
+	link := metadata.Subfiles["link"]
+	if !reflect.DeepEqual(link, md2) || link.SymlinkTarget != "dir/file" || link.Offset != 10 || link.Len != 0 {
+		t.Fatal("unexpected symlink metadata", link)
+	}
+	if link.Mode() != 0777|os.ModeSymlink {
+		t.Fatal("unexpected symlink mode", link.Mode())
+	}
+
+	// a symlink with data should fail
+	buffer = new(bytes.Buffer)
+	writer = multipart.NewWriter(buffer)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="link"`)
+	h.Set(SymlinkTargetHeader, "file")
+	part, err := writer.CreatePart(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = part.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	multipartReader = multipart.NewReader(bytes.NewReader(buffer.Bytes()), writer.Boundary())
+	sfReader = NewSkyfileMultipartReader(multipartReader, sup)
+	_, err = ioutil.ReadAll(sfReader)
+	if !errors.Contains(err, ErrSymlinkWithData) {
+		t.Fatal("expected ErrSymlinkWithData, got", err)
+	}
+}
+
+// testSkyfileMultipartReaderEmptyDirectory verifies that a request which only
+// contains empty subfiles results in valid metadata for a directory without
+// any data.
+func testSkyfileMultipartReaderEmptyDirectory(t *testing.T) {
+	t.Parallel()
+
+	// create upload parameters
+	sup := SkyfileUploadParameters{
+		Filename: t.Name(),
+		Mode:     DefaultFilePerm,
+	}
+
+	// write the empty files
+	buffer := new(bytes.Buffer)
+	writer := multipart.NewWriter(buffer)
+	filenames := []string{"index.html", "dir/a", "dir/b"}
+	off := uint64(0)
+	for _, filename := range filenames {
+		_, err := AddMultipartFile(writer, []byte{}, "files[]", filename, 0600, &off)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read the upload from a request
+	req, err := http.NewRequest(http.MethodPost, "/skynet/skyfile", bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	sfReader, err := NewSkyfileMultipartReaderFromRequest(req, sup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ioutil.ReadAll(sfReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 0 {
+		t.Fatal("unexpected data", len(read))
+	}
+
+	// verify the metadata
+	metadata, err := sfReader.SkyfileMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSkyfileMetadata(metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Filename != sup.Filename || metadata.Length != 0 {
+		t.Fatal("unexpected metadata", metadata.Filename, metadata.Length)
+	}
+	if len(metadata.Subfiles) != len(filenames) {
+		t.Fatal("unexpected number of subfiles", len(metadata.Subfiles))
+	}
+	for _, filename := range filenames {
+		sf, exists := metadata.Subfiles[filename]
+		if !exists {
+			t.Fatal("missing subfile", filename)
+		}
+		if sf.Filename != filename || sf.Offset != 0 || sf.Len != 0 {
+			t.Fatal("unexpected subfile metadata", sf)
+		}
+	}
+}
+
+// testStagingPartStager is a SkyfilePartStager which stages the parts of an
+// upload in memory.
+type testStagingPartStager struct {
+	staged map[string][]byte
+}
+
+// testFailingReader is a reader which always fails.
+type testFailingReader struct{}
+
+// Read implements io.Reader.
+func (r *testFailingReader) Read(_ []byte) (int, error) {
+	return 0, errors.New("connection failed")
+}
+
+// testStagingPartWriter buffers the data of a part and stages it on Close.
+type testStagingPartWriter struct {
+	bytes.Buffer
+	filename string
+	stager   *testStagingPartStager
+}
+
+// StagedPart implements SkyfilePartStager.
+func (s *testStagingPartStager) StagedPart(filename string) ([]byte, bool) {
+	data, exists := s.staged[filename]
+	return data, exists
+}
+
+// StagePart implements SkyfilePartStager.
+func (s *testStagingPartStager) StagePart(filename string) io.WriteCloser {
+	return &testStagingPartWriter{filename: filename, stager: s}
+}
+
+// Close implements io.Closer.
+func (w *testStagingPartWriter) Close() error {
+	w.stager.staged[w.filename] = w.Bytes()
+	return nil
+}
+
+// testSkyfileMultipartReaderStagedParts verifies the reader stages the parts
+// it read completely and replaces zero-length placeholders with staged parts.
+func testSkyfileMultipartReaderStagedParts(t *testing.T) {
+	t.Parallel()
+
+	// create the upload parameters and the data of 4 parts
+	stager := &testStagingPartStager{staged: make(map[string][]byte)}
+	sup := SkyfileUploadParameters{
+		Filename:   t.Name(),
+		Mode:       DefaultFilePerm,
+		PartStager: stager,
+	}
+	filenames := []string{"file1.txt", "file2.txt", "file3.txt", "file4.txt"}
+	var datas [][]byte
+	for i := range filenames {
+		datas = append(datas, fastrand.Bytes(100*(i+1)))
+	}
+
+	// multipartBody is a helper which writes the parts, the parts for which
+	// skip returns true are written as placeholders
+	multipartBody := func(skip func(int) bool) (*bytes.Buffer, string) {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		for i, filename := range filenames {
+			data := datas[i]
+			if skip(i) {
+				data = nil
+			}
+			_, err := AddMultipartFile(writer, data, "files[]", filename, 0600, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buffer, writer.Boundary()
+	}
+
+	// the connection of the first attempt fails within the headers of the
+	// third part
+	body, boundary := multipartBody(func(int) bool { return false })
+	cutoff := bytes.Index(body.Bytes(), []byte(`filename="file3.txt"`))
+	failingBody := io.MultiReader(bytes.NewReader(body.Bytes()[:cutoff]), &testFailingReader{})
+	_, err := ioutil.ReadAll(NewSkyfileMultipartReader(multipart.NewReader(failingBody, boundary), sup))
+	if err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if len(stager.staged) != 2 || !bytes.Equal(stager.staged[filenames[0]], datas[0]) || !bytes.Equal(stager.staged[filenames[1]], datas[1]) {
+		t.Fatal("expected the first 2 parts to be staged", len(stager.staged))
+	}
+
+	// the retry only contains the data of the remaining parts
+	body, boundary = multipartBody(func(i int) bool { return i < 2 })
+	if bytes.Contains(body.Bytes(), datas[0]) || bytes.Contains(body.Bytes(), datas[1]) {
+		t.Fatal("retry shouldn't contain the staged parts")
+	}
+	sfReader := NewSkyfileMultipartReader(multipart.NewReader(body, boundary), sup)
+	read, err := ioutil.ReadAll(sfReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, bytes.Join(datas, nil)) {
+		t.Fatal("unexpected data")
+	}
+
+	// verify the metadata
+	metadata, err := sfReader.SkyfileMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset uint64
+	for i, filename := range filenames {
+		sf := metadata.Subfiles[filename]
+		if sf.Offset != offset || sf.Len != uint64(len(datas[i])) {
+			t.Fatal("unexpected subfile metadata", sf)
+		}
+		offset += sf.Len
+	}
+}
+
+// testSkyfileMultipartReaderSizeMismatch verifies the reader returns an error
+// if the declared Content-Length of a part doesn't match its data.
+func testSkyfileMultipartReaderSizeMismatch(t *testing.T) {
+	t.Parallel()
+
+	// create upload parameters
+	sup := SkyfileUploadParameters{
+		Filename: t.Name(),
+		Mode:     DefaultFilePerm,
+	}
+
+	// prepare random file data
+	data := fastrand.Bytes(10)
+
+	// readMultipart is a helper that writes a part with the given
+	// Content-Length and reads it back using a skyfile reader. If 'truncate'
+	// is set, the body is cut off in the middle of the part's data.
+	readMultipart := func(contentLength string, truncate bool) error {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="file"; filename="file1"`)
+		h.Set("Content-Type", "application/octet-stream")
+		if contentLength != "" {
+			h.Set("Content-Length", contentLength)
+		}
+		part, err := writer.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = part.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		body := buffer.Bytes()
+		if truncate {
+			closingDelimiter := "\r\n--" + writer.Boundary() + "--\r\n"
+			body = body[:len(body)-len(closingDelimiter)-len(data)/2]
+		}
+		multipartReader := multipart.NewReader(bytes.NewReader(body), writer.Boundary())
+		sfReader := NewSkyfileMultipartReader(multipartReader, sup)
+		_, err = ioutil.ReadAll(sfReader)
+		return err
+	}
+
+	// a matching or missing Content-Length should work
+	for _, cl := range []string{"", fmt.Sprint(len(data))} {
+		if err := readMultipart(cl, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a lying or invalid Content-Length should fail
+	for _, cl := range []string{fmt.Sprint(len(data) - 1), fmt.Sprint(len(data) + 1), "foo"} {
+		err := readMultipart(cl, false)
+		if !errors.Contains(err, ErrMultipartSizeMismatch) {
+			t.Fatalf("expected ErrMultipartSizeMismatch for Content-Length '%v', instead err was '%v'", cl, err)
+		}
+	}
+
+	// a truncated part should fail with ErrMultipartSizeMismatch if the
+	// Content-Length was declared
+	err := readMultipart(fmt.Sprint(len(data)), true)
+	if !errors.Contains(err, ErrMultipartSizeMismatch) {
+		t.Fatalf("expected ErrMultipartSizeMismatch, instead err was '%v'", err)
+	}
+	err = readMultipart("", true)
+	if err == nil || errors.Contains(err, ErrMultipartSizeMismatch) {
+		t.Fatalf("expected a different error, instead err was '%v'", err)
+	}
+}
+
 // testSkyfileMultipartReaderReadBuffer verifies the functionality of the read
 // buffer.
 func testSkyfileMultipartReaderReadBuffer(t *testing.T) {
@@ -597,3 +947,314 @@ func testSkyfileMultipartReaderMetadataTimeout(t *testing.T) {
 		t.Fatal("unexpected metadata", metadata)
 	}
 }
+
+// testSkyfileMultipartReaderUploadPolicy verifies the reader enforces the
+// upload policy on every part it reads.
+func testSkyfileMultipartReaderUploadPolicy(t *testing.T) {
+	t.Parallel()
+
+	// helper to create a multipart reader with a part for every given content
+	// type, an empty content type results in a part without the header
+	htmlData := []byte("<html><body>skynet</body></html>")
+	newReader := func(policy SkynetUploadPolicy, contentTypes ...string) SkyfileUploadReader {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		for i, contentType := range contentTypes {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[]"; filename="part%d"`, i))
+			if contentType != "" {
+				h.Set("Content-Type", contentType)
+			}
+			part, err := writer.CreatePart(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = part.Write(htmlData)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sup := SkyfileUploadParameters{
+			Filename:     t.Name(),
+			Mode:         DefaultFilePerm,
+			UploadPolicy: policy,
+		}
+		multipartReader := multipart.NewReader(bytes.NewReader(buffer.Bytes()), writer.Boundary())
+		return NewSkyfileMultipartReader(multipartReader, sup)
+	}
+
+	// a part with a blocked declared content type is rejected
+	policy := SkynetUploadPolicy{
+		BlockedMIMEPrefixes: []string{"application/x-msdownload"},
+	}
+	_, err := ioutil.ReadAll(newReader(policy, "text/plain", "application/x-msdownload"))
+	if !errors.Contains(err, ErrUploadPolicyViolation) {
+		t.Fatalf("expected ErrUploadPolicyViolation, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), "part1") {
+		t.Fatalf("expected error to name the offending file, got '%v'", err)
+	}
+
+	// a part without a content type is sniffed
+	policy = SkynetUploadPolicy{
+		BlockedMIMEPrefixes: []string{"text/html"},
+	}
+	_, err = ioutil.ReadAll(newReader(policy, ""))
+	if !errors.Contains(err, ErrUploadPolicyViolation) {
+		t.Fatalf("expected ErrUploadPolicyViolation, got '%v'", err)
+	}
+
+	// the data which was read to sniff the content type is not lost
+	policy = SkynetUploadPolicy{
+		AllowedMIMEPrefixes: []string{"text/"},
+	}
+	data, err := ioutil.ReadAll(newReader(policy, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, append(append([]byte{}, htmlData...), htmlData...)) {
+		t.Fatal("unexpected data", string(data))
+	}
+
+	// uploads with too many parts are rejected
+	policy = SkynetUploadPolicy{
+		MaxSubfiles: 2,
+	}
+	_, err = ioutil.ReadAll(newReader(policy, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadAll(newReader(policy, "", "", ""))
+	if !errors.Contains(err, ErrUploadPolicyViolation) {
+		t.Fatalf("expected ErrUploadPolicyViolation, got '%v'", err)
+	}
+	if !errors.Contains(err, ErrTooManySubfiles) {
+		t.Fatalf("expected ErrTooManySubfiles, got '%v'", err)
+	}
+	if len(data) != 2*len(htmlData) {
+		t.Fatal("data of the extra part was read", len(data))
+	}
+
+	// there is no limit on the number of parts by default
+	_, err = ioutil.ReadAll(newReader(SkynetUploadPolicy{}, make([]string, 100)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkyfileMultipartReaderLimits verifies the reader enforces the multipart
+// limits before reading the data of the offending part.
+func testSkyfileMultipartReaderLimits(t *testing.T) {
+	t.Parallel()
+
+	// helper to create a multipart reader with a part for every filename
+	partData := []byte("data")
+	newReader := func(limits SkynetMultipartLimits, extraHeader string, filenames ...string) SkyfileUploadReader {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		for _, filename := range filenames {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[]"; filename="%s"`, filename))
+			if extraHeader != "" {
+				h.Set("X-Extra", extraHeader)
+			}
+			part, err := writer.CreatePart(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = part.Write(partData)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sup := SkyfileUploadParameters{
+			Filename:        t.Name(),
+			Mode:            DefaultFilePerm,
+			MultipartLimits: limits,
+		}
+		multipartReader := multipart.NewReader(bytes.NewReader(buffer.Bytes()), writer.Boundary())
+		return NewSkyfileMultipartReader(multipartReader, sup)
+	}
+
+	// unset limits fall back to the defaults
+	limits := SkynetMultipartLimits{MaxFilenameLength: 10}.WithDefaults()
+	if limits.MaxFilenameLength != 10 || limits.MaxHeaderBytes != DefaultSkynetMultipartLimits.MaxHeaderBytes {
+		t.Fatal("unexpected limits", limits)
+	}
+
+	// long filenames are rejected
+	limits = SkynetMultipartLimits{MaxFilenameLength: 10}
+	_, err := ioutil.ReadAll(newReader(limits, "", strings.Repeat("a", 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(newReader(limits, "", strings.Repeat("a", 11)))
+	if !errors.Contains(err, ErrSubfileNameTooLong) {
+		t.Fatalf("expected ErrSubfileNameTooLong, got '%v'", err)
+	}
+	if len(data) != 0 {
+		t.Fatal("data of the part was read", len(data))
+	}
+
+	// the headers of all parts count towards the header limit
+	header := strings.Repeat("h", 100)
+	limits = SkynetMultipartLimits{MaxHeaderBytes: 500}
+	_, err = ioutil.ReadAll(newReader(limits, header, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadAll(newReader(limits, header, "a", "b", "c"))
+	if !errors.Contains(err, ErrMultipartHeadersTooLarge) {
+		t.Fatalf("expected ErrMultipartHeadersTooLarge, got '%v'", err)
+	}
+	if len(data) != 2*len(partData) {
+		t.Fatal("data of the part exceeding the limit was read", len(data))
+	}
+
+	// duplicate paths are rejected and named in the error
+	for _, filenames := range [][]string{
+		{"dir/file", "dir/file"},
+		{"dir/file", "/dir/file"},
+		{"dir/file", "dir//file"},
+		{"dir/file", "./dir/file"},
+	} {
+		data, err = ioutil.ReadAll(newReader(SkynetMultipartLimits{}, "", filenames...))
+		if !errors.Contains(err, ErrDuplicateSubfile) {
+			t.Fatalf("expected ErrDuplicateSubfile for %v, got '%v'", filenames, err)
+		}
+		if !strings.Contains(err.Error(), filenames[1]) {
+			t.Fatalf("expected error to name the duplicate, got '%v'", err)
+		}
+		if len(data) != len(partData) {
+			t.Fatal("data of the duplicate was read", len(data))
+		}
+	}
+	_, err = ioutil.ReadAll(newReader(SkynetMultipartLimits{}, "", "dir/file", "dir/file2", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSkyfileMultipartReaderFuzz feeds randomly mutated multipart bodies to the
+// multipart reader. The reader must never panic and the memory it allocates
+// must stay within a budget independent of the structure of the body.
+func TestSkyfileMultipartReaderFuzz(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	// NOTE: this test is not run in parallel to get meaningful memory stats.
+
+	limits := SkynetMultipartLimits{
+		MaxFilenameLength: 64,
+		MaxHeaderBytes:    1 << 12,
+	}
+	policy := SkynetUploadPolicy{
+		MaxSubfiles: 20,
+	}
+	const maxBodySize = 1 << 16
+	const memoryBudget = 8 << 20
+
+	// newBody creates a valid multipart body with random parts.
+	newBody := func() ([]byte, string) {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+		numParts := fastrand.Intn(40)
+		for i := 0; i < numParts; i++ {
+			h := make(textproto.MIMEHeader)
+			filename := fmt.Sprintf("dir%d/file%d", fastrand.Intn(3), fastrand.Intn(30))
+			if fastrand.Intn(10) == 0 {
+				filename = strings.Repeat("f", fastrand.Intn(200))
+			}
+			formName := "files[]"
+			if fastrand.Intn(20) == 0 {
+				formName = "other"
+			}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, formName, filename))
+			if fastrand.Intn(5) == 0 {
+				h.Set("X-Extra", strings.Repeat("x", fastrand.Intn(1000)))
+			}
+			if fastrand.Intn(5) == 0 {
+				h.Set("Content-Type", "text/plain")
+			}
+			part, err := writer.CreatePart(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = part.Write(fastrand.Bytes(fastrand.Intn(2000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Bytes(), writer.Boundary()
+	}
+
+	// mutate randomly corrupts the body.
+	mutate := func(body []byte, boundary string) []byte {
+		for i := fastrand.Intn(5); i > 0 && len(body) > 0; i-- {
+			switch fastrand.Intn(4) {
+			case 0: // flip a byte
+				body[fastrand.Intn(len(body))] ^= byte(fastrand.Intn(255) + 1)
+			case 1: // truncate
+				body = body[:fastrand.Intn(len(body))]
+			case 2: // insert a boundary
+				offset := fastrand.Intn(len(body))
+				insert := []byte("\r\n--" + boundary + "\r\n")
+				body = append(body[:offset:offset], append(insert, body[offset:]...)...)
+			case 3: // duplicate a section
+				from := fastrand.Intn(len(body))
+				to := from + fastrand.Intn(len(body)-from)
+				body = append(body, body[from:to]...)
+			}
+		}
+		if len(body) > maxBodySize {
+			body = body[:maxBodySize]
+		}
+		return body
+	}
+
+	var ms runtime.MemStats
+	for i := 0; i < 1000; i++ {
+		body, boundary := newBody()
+		body = mutate(body, boundary)
+
+		runtime.ReadMemStats(&ms)
+		before := ms.TotalAlloc
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("reader panicked: %v\nbody: %q", r, body)
+				}
+			}()
+			sup := SkyfileUploadParameters{
+				Filename:        t.Name(),
+				MultipartLimits: limits,
+				UploadPolicy:    policy,
+			}
+			sr := newSkyfileMultipartReader(multipart.NewReader(bytes.NewReader(body), boundary), sup)
+			_, _ = io.Copy(ioutil.Discard, sr)
+			if uint64(len(sr.metadata.Subfiles)) > policy.MaxSubfiles {
+				t.Fatal("too many subfiles", len(sr.metadata.Subfiles))
+			}
+			for filename := range sr.metadata.Subfiles {
+				if uint64(len(filename)) > limits.MaxFilenameLength {
+					t.Fatal("filename too long", filename)
+				}
+			}
+		}()
+		runtime.ReadMemStats(&ms)
+		if allocated := ms.TotalAlloc - before; allocated > memoryBudget {
+			t.Fatalf("reader allocated %v bytes for a body of %v bytes", allocated, len(body))
+		}
+	}
+}
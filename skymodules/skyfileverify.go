@@ -0,0 +1,144 @@
+package skymodules
+
+import (
+	"bytes"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// ErrSkyfileVerificationFailed is returned if the data of a skyfile doesn't
+// match the merkle roots it is supposed to be stored under.
+var ErrSkyfileVerificationFailed = errors.New("skyfile data doesn't match its merkle roots")
+
+// SkyfileVerifier verifies the payload of a skyfile one chunk at a time. For
+// skyfiles with a fanout, every chunk is erasure coded and encrypted the same
+// way it was uploaded and the merkle roots of the resulting pieces are
+// compared to the fanout. For skyfiles without a fanout, the payload is
+// compared to the payload within the already verified base sector.
+type SkyfileVerifier struct {
+	staticBaseSectorPayload []byte
+	staticChunks            [][]crypto.Hash
+	staticChunkSize         uint64
+	staticEC                ErasureCoder
+	staticFanoutKey         crypto.CipherKey
+	staticFilesize          uint64
+}
+
+// NewSkyfileVerifier creates a verifier for the payload of the skyfile with
+// the given layout. The fanout bytes and base sector payload are expected to
+// be taken from a base sector which was verified against its skylink already.
+func NewSkyfileVerifier(layout SkyfileLayout, fanoutBytes []byte, fanoutKey crypto.CipherKey, baseSectorPayload []byte) (*SkyfileVerifier, error) {
+	// If there is no fanout, the whole payload is stored within the base
+	// sector and therefore within a single chunk.
+	if layout.FanoutSize == 0 {
+		return &SkyfileVerifier{
+			staticBaseSectorPayload: baseSectorPayload,
+			staticChunkSize:         modules.SectorSize,
+			staticFilesize:          layout.Filesize,
+		}, nil
+	}
+	chunks, err := layout.DecodeFanoutIntoChunks(fanoutBytes)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to decode fanout")
+	}
+	ec, err := NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create erasure coder")
+	}
+	return &SkyfileVerifier{
+		staticChunks:    chunks,
+		staticChunkSize: ChunkSize(layout.CipherType, uint64(layout.FanoutDataPieces)),
+		staticEC:        ec,
+		staticFanoutKey: fanoutKey,
+		staticFilesize:  layout.Filesize,
+	}, nil
+}
+
+// ChunkSize returns the size of the chunks the verifier expects.
+func (sv *SkyfileVerifier) ChunkSize() uint64 {
+	return sv.staticChunkSize
+}
+
+// VerifyChunk checks that the data matches the chunk with the given index.
+// The data needs to contain the whole chunk, except for the last chunk of the
+// payload which ends at the end of the file.
+func (sv *SkyfileVerifier) VerifyChunk(chunkIndex uint64, data []byte) error {
+	start := chunkIndex * sv.staticChunkSize
+	if chunkIndex > 0 && start >= sv.staticFilesize {
+		return fmt.Errorf("chunk %v is out of bounds", chunkIndex)
+	}
+	expectedSize := sv.staticFilesize - start
+	if expectedSize > sv.staticChunkSize {
+		expectedSize = sv.staticChunkSize
+	}
+	if uint64(len(data)) != expectedSize {
+		return errors.AddContext(ErrSkyfileVerificationFailed, fmt.Sprintf("chunk %v has size %v but expected %v", chunkIndex, len(data), expectedSize))
+	}
+
+	// If there is no fanout, compare the data to the base sector.
+	if sv.staticChunks == nil {
+		if !bytes.Equal(sv.staticBaseSectorPayload, data) {
+			return errors.AddContext(ErrSkyfileVerificationFailed, "payload doesn't match base sector")
+		}
+		return nil
+	}
+	if chunkIndex >= uint64(len(sv.staticChunks)) {
+		return errors.AddContext(ErrSkyfileVerificationFailed, fmt.Sprintf("fanout doesn't contain chunk %v", chunkIndex))
+	}
+	roots := sv.staticChunks[chunkIndex]
+
+	// Pad the data to a full chunk.
+	chunk := make([]byte, sv.staticChunkSize)
+	copy(chunk, data)
+
+	// Zero chunks of sparse skyfiles don't have any roots to compare against
+	// but their data needs to be zero.
+	if IsZeroChunk(roots) {
+		if !bytes.Equal(chunk, make([]byte, sv.staticChunkSize)) {
+			return errors.AddContext(ErrSkyfileVerificationFailed, fmt.Sprintf("zero chunk %v contains data", chunkIndex))
+		}
+		return nil
+	}
+
+	// Encode it and compare the roots of the pieces.
+	pieces, err := sv.staticEC.Encode(chunk)
+	if err != nil {
+		return errors.AddContext(err, "failed to encode chunk")
+	}
+	for pieceIndex, root := range roots {
+		piece := pieces[pieceIndex]
+		if short := int(modules.SectorSize) - len(piece); short > 0 {
+			piece = append(piece, make([]byte, short)...)
+		}
+		piece = sv.staticFanoutKey.Derive(chunkIndex, uint64(pieceIndex)).EncryptBytes(piece)
+		if crypto.MerkleRoot(piece) != root {
+			return errors.AddContext(ErrSkyfileVerificationFailed, fmt.Sprintf("piece %v of chunk %v doesn't match its root", pieceIndex, chunkIndex))
+		}
+	}
+	return nil
+}
+
+// VerifySkyfileFanout checks that the payload of a skyfile matches the merkle
+// roots within its fanout by verifying it chunk by chunk.
+func VerifySkyfileFanout(layout SkyfileLayout, fanoutBytes []byte, fanoutKey crypto.CipherKey, payload []byte) error {
+	if uint64(len(payload)) != layout.Filesize {
+		return errors.AddContext(ErrSkyfileVerificationFailed, fmt.Sprintf("payload has size %v but expected %v", len(payload), layout.Filesize))
+	}
+	sv, err := NewSkyfileVerifier(layout, fanoutBytes, fanoutKey, nil)
+	if err != nil {
+		return err
+	}
+	for start, chunkIndex := uint64(0), uint64(0); start < layout.Filesize; start, chunkIndex = start+sv.ChunkSize(), chunkIndex+1 {
+		end := start + sv.ChunkSize()
+		if end > layout.Filesize {
+			end = layout.Filesize
+		}
+		if err := sv.VerifyChunk(chunkIndex, payload[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package skymodules
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestVerifySkyfileFanout is a unit test for VerifySkyfileFanout.
+func TestVerifySkyfileFanout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	t.Run("OneOfN", func(t *testing.T) {
+		testVerifySkyfileFanout(t, 1, 2, crypto.TypePlain)
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		testVerifySkyfileFanout(t, 2, 1, crypto.TypeThreefish)
+	})
+}
+
+// testVerifySkyfileFanout tests VerifySkyfileFanout for a file with the given
+// erasure coding and cipher type.
+func testVerifySkyfileFanout(t *testing.T, dataPieces, parityPieces int, ct crypto.CipherType) {
+	ec, err := NewRSSubCode(dataPieces, parityPieces, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := crypto.GenerateSiaKey(ct)
+
+	// Create a payload of one and a half chunks.
+	chunkSize := ChunkSize(ct, uint64(dataPieces))
+	payload := fastrand.Bytes(int(chunkSize + chunkSize/2))
+
+	// Build the fanout the same way an upload would.
+	onePiece := dataPieces == 1 && ct == crypto.TypePlain
+	var fanout []byte
+	for chunkIndex := uint64(0); chunkIndex < 2; chunkIndex++ {
+		chunk := make([]byte, chunkSize)
+		copy(chunk, payload[chunkIndex*chunkSize:])
+		pieces, err := ec.Encode(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pieceIndex, piece := range pieces {
+			piece = append(piece, make([]byte, int(modules.SectorSize)-len(piece))...)
+			piece = key.Derive(chunkIndex, uint64(pieceIndex)).EncryptBytes(piece)
+			root := crypto.MerkleRoot(piece)
+			fanout = append(fanout, root[:]...)
+			if onePiece {
+				break
+			}
+		}
+	}
+	layout := NewSkyfileLayout(uint64(len(payload)), 0, uint64(len(fanout)), ec, ct)
+
+	// The payload should verify.
+	err = VerifySkyfileFanout(layout, fanout, key, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt a byte in the last chunk.
+	corrupted := append([]byte{}, payload...)
+	corrupted[len(corrupted)-1]++
+	err = VerifySkyfileFanout(layout, fanout, key, corrupted)
+	if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+
+	// Truncate the payload.
+	err = VerifySkyfileFanout(layout, fanout, key, payload[:len(payload)-1])
+	if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+
+	// Use the wrong key. This only matters for encrypted files.
+	if ct != crypto.TypePlain {
+		err = VerifySkyfileFanout(layout, fanout, crypto.GenerateSiaKey(ct), payload)
+		if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+			t.Fatal("expected verification to fail", err)
+		}
+	}
+
+	// Verify the chunks individually.
+	sv, err := NewSkyfileVerifier(layout, fanout, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv.ChunkSize() != chunkSize {
+		t.Fatalf("expected chunk size %v but got %v", chunkSize, sv.ChunkSize())
+	}
+	err = sv.VerifyChunk(1, payload[chunkSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sv.VerifyChunk(0, payload[chunkSize:])
+	if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+	err = sv.VerifyChunk(2, nil)
+	if err == nil {
+		t.Fatal("expected out of bounds chunk to fail")
+	}
+}
+
+// TestSkyfileVerifierNoFanout tests verifying a skyfile which is stored within
+// its base sector.
+func TestSkyfileVerifierNoFanout(t *testing.T) {
+	t.Parallel()
+
+	payload := fastrand.Bytes(100)
+	layout := NewSkyfileLayoutNoFanout(uint64(len(payload)), 0, crypto.TypePlain)
+	sv, err := NewSkyfileVerifier(layout, nil, nil, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv.ChunkSize() != modules.SectorSize {
+		t.Fatalf("expected chunk size %v but got %v", modules.SectorSize, sv.ChunkSize())
+	}
+	err = sv.VerifyChunk(0, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte{}, payload...)
+	corrupted[0]++
+	err = sv.VerifyChunk(0, corrupted)
+	if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+	err = sv.VerifyChunk(0, payload[1:])
+	if !errors.Contains(err, ErrSkyfileVerificationFailed) {
+		t.Fatal("expected verification to fail", err)
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"math/bits"
+	"net/url"
 	"path/filepath"
 	"strings"
 
@@ -36,6 +39,21 @@ const (
 	// encoded using base64.
 	base64EncodedSkylinkSize = 46
 
+	// base64PaddedEncodedSkylinkSize is the size of the Skylink after it has
+	// been encoded using base64 with padding.
+	base64PaddedEncodedSkylinkSize = 48
+
+	// hexEncodedSkylinkSize is the size of the Skylink after its raw data has
+	// been hex encoded.
+	hexEncodedSkylinkSize = 68
+
+	// SkylinkScheme is the optional scheme that skylinks can be prefixed with.
+	SkylinkScheme = "sia://"
+
+	// skylinkExpectedFormats is the context that is added to errors of
+	// strings that couldn't be decoded to list the supported formats.
+	skylinkExpectedFormats = "expected a 46 character base64url, 48 character padded base64url, 55 character base32 or 68 character hex encoded skylink"
+
 	// rawSkylinkSize is the raw size of the data that gets put into a link.
 	rawSkylinkSize = 34
 )
@@ -45,6 +63,11 @@ var (
 	// into a Skylink due to it having an incorrect size.
 	ErrSkylinkIncorrectSize = errors.New("skylink has incorrect size")
 
+	// ErrSkylinkInvalidEncoding is returned when a string has the size of an
+	// encoded Skylink but contains characters that are not valid for its
+	// encoding.
+	ErrSkylinkInvalidEncoding = errors.New("skylink has invalid encoding")
+
 	// ErrMalformedSkylink is returned when a v2 skylink contains malformed
 	// data, causing parsing the v1 skylink to fail.
 	ErrMalformedSkylink = errors.New("failed to parse skylink - data is malformed")
@@ -92,6 +115,29 @@ func isSkylinkV2(bitfield uint16) bool {
 	return bitfield == 1
 }
 
+// ParseSkylink parses a skylink from any of the formats accepted by
+// LoadString. Handlers should use it for all user provided skylinks to make
+// sure different representations of the same skylink are treated equally.
+func ParseSkylink(s string) (Skylink, error) {
+	var sl Skylink
+	err := sl.LoadString(s)
+	if err != nil {
+		return Skylink{}, err
+	}
+	return sl, nil
+}
+
+// CanonicalizeSkylink returns the canonical representation of a skylink. The
+// canonical form is the unpadded base64url encoding without a scheme, path or
+// query.
+func CanonicalizeSkylink(s string) (string, error) {
+	sl, err := ParseSkylink(s)
+	if err != nil {
+		return "", err
+	}
+	return sl.String(), nil
+}
+
 // NewSkylinkV2 creates a version 2 skylink.
 func NewSkylinkV2(spk types.SiaPublicKey, tweak crypto.Hash) Skylink {
 	var sl Skylink
@@ -213,7 +259,7 @@ func (sl *Skylink) LoadString(s string) (err error) {
 	// No need to check if there is an element returned by strings.SplitN, so
 	// long as the second arg is not-nil (in this case, '?'), SplitN cannot
 	// return an empty slice.
-	base := splits[0]
+	base := strings.TrimPrefix(strings.TrimSpace(splits[0]), SkylinkScheme)
 
 	// The base can still contain a path to a nested file within the siafile.
 	// This is however not part of the skylink and gets trimmed.
@@ -222,6 +268,12 @@ func (sl *Skylink) LoadString(s string) (err error) {
 		base = splits[0]
 	}
 
+	// The base might contain percent-encoded characters.
+	base, err = url.PathUnescape(base)
+	if err != nil {
+		return errors.AddContext(errors.Compose(ErrSkylinkInvalidEncoding, err), skylinkExpectedFormats)
+	}
+
 	// Decode the base into raw data
 	raw, err := decodeSkylink(base)
 	if err != nil {
@@ -492,15 +544,26 @@ func (sl *Skylink) setOffsetAndFetchSize(offset, fetchSize uint64) error {
 }
 
 // decodeSkylink is a helper function that decodes the given string
-// representation of a skylink  into raw bytes. It either performs a base32
-// decoding, or base64 decoding, depending on the length.
-func decodeSkylink(encoded string) ([]byte, error) {
+// representation of a skylink  into raw bytes. It either performs a base32,
+// base64 or hex decoding, depending on the length.
+func decodeSkylink(encoded string) (raw []byte, err error) {
 	switch len(encoded) {
 	case base32EncodedSkylinkSize:
-		return base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(encoded))
+		raw, err = base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(encoded))
 	case base64EncodedSkylinkSize:
-		return base64.RawURLEncoding.DecodeString(encoded)
+		raw, err = base64.RawURLEncoding.DecodeString(encoded)
+	case base64PaddedEncodedSkylinkSize:
+		raw, err = base64.URLEncoding.DecodeString(encoded)
+	case hexEncodedSkylinkSize:
+		raw, err = hex.DecodeString(encoded)
 	default:
-		return nil, ErrSkylinkIncorrectSize
+		return nil, errors.AddContext(ErrSkylinkIncorrectSize, skylinkExpectedFormats)
+	}
+	if err == nil && len(raw) != rawSkylinkSize {
+		err = fmt.Errorf("decoded skylink has %v bytes instead of %v", len(raw), rawSkylinkSize)
+	}
+	if err != nil {
+		return nil, errors.AddContext(errors.Compose(ErrSkylinkInvalidEncoding, err), skylinkExpectedFormats)
 	}
+	return raw, nil
 }
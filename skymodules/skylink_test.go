@@ -1,7 +1,11 @@
 package skymodules
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -301,6 +305,71 @@ func TestSkylink(t *testing.T) {
 	}
 }
 
+// TestSkylinkLoadStringFormats verifies that LoadString accepts the
+// alternative encodings of a skylink and rejects malformed ones.
+func TestSkylinkLoadStringFormats(t *testing.T) {
+	t.Parallel()
+
+	sl, err := NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := sl.String()
+	percentEncoded := fmt.Sprintf("%%%X", canonical[0]) + canonical[1:]
+	hexEncoded := hex.EncodeToString(sl.Bytes())
+
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		// Valid inputs.
+		{name: "canonical", input: canonical},
+		{name: "scheme", input: SkylinkScheme + canonical},
+		{name: "trailing slash", input: canonical + "/"},
+		{name: "scheme with path", input: SkylinkScheme + canonical + "/foo/bar"},
+		{name: "query", input: canonical + "?foo=bar"},
+		{name: "padded base64url", input: base64.URLEncoding.EncodeToString(sl.Bytes())},
+		{name: "base32", input: sl.Base32EncodedString()},
+		{name: "hex", input: hexEncoded},
+		{name: "uppercase hex", input: strings.ToUpper(hexEncoded)},
+		{name: "percent-encoded", input: percentEncoded},
+		{name: "whitespace", input: " " + canonical + "\n"},
+
+		// Invalid inputs.
+		{name: "empty", input: "", errMsg: ErrSkylinkIncorrectSize.Error()},
+		{name: "scheme only", input: SkylinkScheme, errMsg: ErrSkylinkIncorrectSize.Error()},
+		{name: "malformed scheme", input: "sia:/" + canonical, errMsg: ErrSkylinkIncorrectSize.Error()},
+		{name: "too short", input: canonical[1:], errMsg: ErrSkylinkIncorrectSize.Error()},
+		{name: "too long", input: canonical + "A", errMsg: ErrSkylinkIncorrectSize.Error()},
+		{name: "standard base64", input: "+" + canonical[1:], errMsg: ErrSkylinkInvalidEncoding.Error()},
+		{name: "invalid padding", input: canonical + "A=", errMsg: ErrSkylinkInvalidEncoding.Error()},
+		{name: "invalid hex", input: strings.Repeat("z", hexEncodedSkylinkSize), errMsg: ErrSkylinkInvalidEncoding.Error()},
+		{name: "invalid base32", input: strings.Repeat("z", base32EncodedSkylinkSize), errMsg: ErrSkylinkInvalidEncoding.Error()},
+		{name: "invalid percent-encoding", input: "%zz" + canonical[1:], errMsg: ErrSkylinkInvalidEncoding.Error()},
+		{name: "hex merkle root only", input: hex.EncodeToString(sl.merkleRoot[:]), errMsg: ErrSkylinkIncorrectSize.Error()},
+	}
+	for _, test := range tests {
+		var loaded Skylink
+		err := loaded.LoadString(test.input)
+		if test.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Fatalf("%v: expected error '%v' but got %v", test.name, test.errMsg, err)
+			}
+			if !strings.Contains(err.Error(), skylinkExpectedFormats) {
+				t.Fatalf("%v: error doesn't name the expected formats: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.name, err)
+		}
+		if loaded.String() != canonical {
+			t.Fatalf("%v: expected %v but got %v", test.name, canonical, loaded.String())
+		}
+	}
+}
+
 // TestSkylinkAutoExamples performs a brute force test over lots of values for
 // the skylink bitfield to ensure correctness.
 func TestSkylinkAutoExamples(t *testing.T) {
@@ -439,3 +508,51 @@ func TestNewSkylinkV2(t *testing.T) {
 		t.Fatal("skylink has wrong version")
 	}
 }
+
+// TestCanonicalizeSkylink tests that different representations of the same
+// skylink are canonicalized to the same string.
+func TestCanonicalizeSkylink(t *testing.T) {
+	t.Parallel()
+
+	sl, err := NewSkylinkV1(crypto.HashBytes(fastrand.Bytes(32)), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := sl.String()
+
+	inputs := []string{
+		canonical,
+		SkylinkScheme + canonical,
+		SkylinkScheme + canonical + "/",
+		canonical + "/foo/bar?foo=bar",
+		sl.Base32EncodedString(),
+		hex.EncodeToString(sl.Bytes()),
+		" " + canonical + "\n",
+	}
+	for _, input := range inputs {
+		s, err := CanonicalizeSkylink(input)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", input, err)
+		}
+		if s != canonical {
+			t.Fatalf("%v: expected %v but got %v", input, canonical, s)
+		}
+		parsed, err := ParseSkylink(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != sl {
+			t.Fatalf("%v: parsed skylink doesn't match", input)
+		}
+	}
+
+	// Invalid skylinks should return an error.
+	_, err = CanonicalizeSkylink(canonical[1:])
+	if !errors.Contains(err, ErrMalformedSkylink) {
+		t.Fatal("expected ErrMalformedSkylink", err)
+	}
+	_, err = ParseSkylink("")
+	if !errors.Contains(err, ErrMalformedSkylink) {
+		t.Fatal("expected ErrMalformedSkylink", err)
+	}
+}
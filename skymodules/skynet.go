@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -35,6 +36,10 @@ const (
 	// determine the skynet fee to be paid.
 	SkynetFeeDivider = 5 // 20%
 
+	// SkynetSnapshotNow is the reserved snapshot name which refers to the
+	// current state of a directory when diffing snapshots.
+	SkynetSnapshotNow = "now"
+
 	// SkyfileVersion establishes the current version for creating skyfiles.
 	// The skyfile versions are different from the siafile versions.
 	SkyfileVersion = 1
@@ -72,6 +77,12 @@ var (
 	// a large file upload
 	ExtendedSuffix = "-extended"
 
+	// ZeroChunkRoot is the reserved root which marks a chunk of a sparse
+	// skyfile that only contains zeros. Such chunks are not uploaded to hosts
+	// and every root of the chunk within the fanout is set to ZeroChunkRoot.
+	// The empty hash can't collide with the merkle root of an actual piece.
+	ZeroChunkRoot = crypto.Hash{}
+
 	// ErrZeroMonetizer is returned if a caller tries to set a monetizer with 0H
 	// payout.
 	ErrZeroMonetizer = errors.New("can't provide 0 monetization")
@@ -90,6 +101,10 @@ var (
 	// ErrZeroConversionRate is returned when trying to pay for a monetized file
 	// with a 0 conversion rate.
 	ErrZeroConversionRate = fmt.Errorf("can't pay monetizers when the conversion rate for 0")
+
+	// ErrSubfileIndexOutOfRange is returned if a skyfile doesn't have a
+	// subfile at the requested index.
+	ErrSubfileIndexOutOfRange = errors.New("subfile index out of range")
 )
 
 var (
@@ -98,6 +113,10 @@ var (
 	SkyfileFormatNotSpecified = SkyfileFormat("")
 	// SkyfileFormatConcat returns the skyfiles in a concatenated manner.
 	SkyfileFormatConcat = SkyfileFormat("concat")
+	// SkyfileFormatDataURI returns the skyfile as a base64 encoded data URI.
+	SkyfileFormatDataURI = SkyfileFormat("datauri")
+	// SkyfileFormatIndex returns a listing of the skyfiles.
+	SkyfileFormatIndex = SkyfileFormat("index")
 	// SkyfileFormatTar returns the skyfiles as a .tar.
 	SkyfileFormatTar = SkyfileFormat("tar")
 	// SkyfileFormatTarGz returns the skyfiles as a .tar.gz.
@@ -106,6 +125,27 @@ var (
 	SkyfileFormatZip = SkyfileFormat("zip")
 )
 
+var (
+	// DefaultPathReasonNone indicates that no default path or tryfile applied
+	// to the request of a skyfile's root.
+	DefaultPathReasonNone = DefaultPathReason("none")
+	// DefaultPathReasonExplicit indicates that the defaultpath set in the
+	// metadata was served.
+	DefaultPathReasonExplicit = DefaultPathReason("explicit")
+	// DefaultPathReasonAutoSingle indicates that the only subfile of the
+	// skyfile was served.
+	DefaultPathReasonAutoSingle = DefaultPathReason("auto-single")
+	// DefaultPathReasonIndexDetected indicates that the skyfile's index.html
+	// was served.
+	DefaultPathReasonIndexDetected = DefaultPathReason("index-detected")
+	// DefaultPathReasonDisabled indicates that the default path is disabled
+	// in the metadata.
+	DefaultPathReasonDisabled = DefaultPathReason("disabled")
+	// DefaultPathReasonTryFiles indicates that the path was resolved using
+	// the tryfiles of the metadata.
+	DefaultPathReasonTryFiles = DefaultPathReason("tryfiles")
+)
+
 // SkynetFeePayoutInterval is the time after which the renter pays out the
 // accumulated skynet fees.
 var SkynetFeePayoutInterval = build.Select(build.Var{
@@ -196,6 +236,55 @@ type (
 
 		// ErrorPages overrides the content we serve for some error codes.
 		ErrorPages map[int]string
+
+		// DataPieces and ParityPieces override the erasure coding settings
+		// of the fanout of a large skyfile. If both are zero, the renter's
+		// defaults are used.
+		DataPieces   int
+		ParityPieces int
+
+		// SkylinkHint is an optional callback which is called with the
+		// skylink of the upload as soon as it is known. That is before the
+		// base sector is uploaded and, for large skyfiles, before the fanout
+		// is available on the network.
+		SkylinkHint func(Skylink)
+
+		// BaseSectorHint is an optional callback which is called with the
+		// layout and the encoded metadata of the skyfile as soon as its
+		// base sector was built.
+		BaseSectorHint func(SkyfileLayout, []byte)
+
+		// ModTime is the modification time of the skyfile as a unix
+		// timestamp in seconds. If zero, the skyfile won't have a
+		// modification time.
+		ModTime int64
+
+		// ExpectedSize is the number of bytes the upload is expected to
+		// contain, e.g. the Content-Length of the request. It's counted
+		// against the skynet storage cap before any data is read. If zero,
+		// the size isn't known in advance.
+		ExpectedSize uint64
+
+		// Redirect is the skylink the uploaded skyfile points to. If set,
+		// the skyfile can't contain any data.
+		Redirect string
+
+		// UploadPolicy restricts the files a multipart upload can contain.
+		// Reading a part which violates the policy fails the upload.
+		UploadPolicy SkynetUploadPolicy
+
+		// MultipartLimits limits the structure of a multipart upload. Unset
+		// limits fall back to DefaultSkynetMultipartLimits.
+		MultipartLimits SkynetMultipartLimits
+
+		// PartStager optionally stages the parts of a multipart upload to
+		// resume it after a failed attempt.
+		PartStager SkyfilePartStager
+
+		// Sparse indicates that chunks of a large skyfile which only contain
+		// zeros are not uploaded. They are marked with the ZeroChunkRoot in
+		// the fanout instead. Sparse skyfiles can't be encrypted.
+		Sparse bool
 	}
 
 	// SkyfileMultipartUploadParameters defines the parameters specific to
@@ -236,10 +325,85 @@ type (
 	// skylink. See SkyfileUploadParameters for a detailed description of the
 	// fields.
 	SkyfilePinParameters struct {
-		SiaPath             SiaPath `json:"siapath"`
-		Force               bool    `json:"force"`
-		Root                bool    `json:"root"`
-		BaseChunkRedundancy uint8   `json:"basechunkredundancy"`
+		SiaPath             SiaPath       `json:"siapath"`
+		Force               bool          `json:"force"`
+		Root                bool          `json:"root"`
+		BaseChunkRedundancy uint8         `json:"basechunkredundancy"`
+		BaseSectorOnly      bool          `json:"basesectoronly"`
+		WaitHealthy         bool          `json:"waithealthy"`
+		TTL                 time.Duration `json:"ttl"`
+	}
+
+	// SkyfileConversionStatus contains information about the progress of an
+	// asynchronous conversion of a siafile to a skyfile.
+	SkyfileConversionStatus struct {
+		ID              string  `json:"id"`
+		SiaPath         SiaPath `json:"siapath"`
+		ChunksConverted uint64  `json:"chunksconverted"`
+		TotalChunks     uint64  `json:"totalchunks"`
+		Skylink         string  `json:"skylink,omitempty"`
+		Completed       bool    `json:"completed"`
+		Cancelled       bool    `json:"cancelled"`
+		Error           string  `json:"error,omitempty"`
+	}
+
+	// RegistryKey is the public information of a named keypair that the
+	// renter uses to sign registry updates. The secret key never leaves the
+	// node.
+	RegistryKey struct {
+		Name      string             `json:"name"`
+		PublicKey types.SiaPublicKey `json:"publickey"`
+	}
+
+	// SkynetHostStats contains information about how much data a single host
+	// contributed to a download.
+	SkynetHostStats struct {
+		// HostKey is the public key of the host.
+		HostKey types.SiaPublicKey `json:"hostkey"`
+
+		// Sectors is the number of sector downloads the host completed
+		// successfully.
+		Sectors uint64 `json:"sectors"`
+
+		// Bytes is the amount of the downloaded data which was recovered from
+		// the sectors served by the host.
+		Bytes uint64 `json:"bytes"`
+
+		// TotalTimeMS is the total time in milliseconds the host spent on the
+		// download jobs it was launched for.
+		TotalTimeMS uint64 `json:"totaltimems"`
+
+		// Bandwidth is the total bandwidth, including overhead, which was
+		// consumed by the download jobs of the host. This includes the
+		// bandwidth of failed jobs.
+		Bandwidth uint64 `json:"bandwidth"`
+
+		// Overdrive indicates whether the host was launched as an overdrive
+		// worker for any of its jobs.
+		Overdrive bool `json:"overdrive"`
+	}
+
+	// SkynetMissingRange describes a range of a skyfile which can't be
+	// recovered from the network because not enough pieces of the fanout
+	// chunks covering it are available.
+	SkynetMissingRange struct {
+		// Offset and Length describe the missing range in bytes.
+		Offset uint64 `json:"offset"`
+		Length uint64 `json:"length"`
+
+		// Filename is set if the range corresponds to a subfile of the
+		// skyfile which was omitted from the response.
+		Filename string `json:"filename,omitempty"`
+	}
+
+	// SkylinkPrefetchStatus contains information about the progress of a
+	// skylink prefetch.
+	SkylinkPrefetchStatus struct {
+		ID        string `json:"id"`
+		Skylink   string `json:"skylink"`
+		Full      bool   `json:"full"`
+		Completed bool   `json:"completed"`
+		Error     string `json:"error,omitempty"`
 	}
 
 	// SkyfileMetadata is all of the metadata that gets placed into the first
@@ -256,6 +420,136 @@ type (
 		DisableDefaultPath bool            `json:"disabledefaultpath,omitempty"`
 		TryFiles           []string        `json:"tryfiles,omitempty"`
 		ErrorPages         map[int]string  `json:"errorpages,omitempty"`
+
+		// Redirect is set on skyfiles which don't contain any data but point
+		// to another skylink. Downloading such a skyfile serves the content
+		// of the skylink it points to instead.
+		Redirect string `json:"redirect,omitempty"`
+
+		// ModTime is the time of the upload as a unix timestamp in seconds.
+		// It is not set on skyfiles that were uploaded before it was
+		// introduced.
+		ModTime int64 `json:"modtime,omitempty"`
+	}
+
+	// SkyfileUploadCostEstimate is an estimate of the cost of uploading a
+	// skyfile, split up into the costs the hosts charge for.
+	SkyfileUploadCostEstimate struct {
+		NumSectors          uint64            `json:"numsectors"`
+		Period              types.BlockHeight `json:"period"`
+		RPCCost             types.Currency    `json:"rpccost"`
+		StorageCost         types.Currency    `json:"storagecost"`
+		UploadBandwidthCost types.Currency    `json:"uploadbandwidthcost"`
+		TotalCost           types.Currency    `json:"totalcost"`
+	}
+
+	// SkylinkPinCostEstimate is an estimate of the resources consumed by
+	// pinning a skylink.
+	SkylinkPinCostEstimate struct {
+		Filesize              uint64         `json:"filesize"`
+		Redundancy            float64        `json:"redundancy"`
+		StorageSize           uint64         `json:"storagesize"`
+		NumFetchSectors       uint64         `json:"numfetchsectors"`
+		NumUploadSectors      uint64         `json:"numuploadsectors"`
+		NumContracts          uint64         `json:"numcontracts"`
+		DownloadBandwidthCost types.Currency `json:"downloadbandwidthcost"`
+		UploadBandwidthCost   types.Currency `json:"uploadbandwidthcost"`
+		TotalBandwidthCost    types.Currency `json:"totalbandwidthcost"`
+	}
+
+	// SkynetBlocklistHit describes a single attempt to download blocked
+	// content.
+	SkynetBlocklistHit struct {
+		Hash      crypto.Hash `json:"hash"`      // the blocklist hash of the requested content
+		Requester string      `json:"requester"` // the IP of the client, empty if unknown
+		Skylink   string      `json:"skylink"`   // the requested skylink, empty for downloads by root
+		Timestamp time.Time   `json:"timestamp"` // the time of the attempt
+	}
+
+	// SkynetGCCandidate describes a skyfile that wasn't downloaded within the
+	// retention window of a skyfile garbage collection.
+	SkynetGCCandidate struct {
+		LastAccess time.Time `json:"lastaccess"` // the time of the most recent download
+		SiaPath    SiaPath   `json:"siapath"`    // the siapath of the base siafile
+		Size       uint64    `json:"size"`       // the combined filesize of the base and extended siafile
+		Skylinks   []string  `json:"skylinks"`   // the skylinks of the skyfile
+	}
+
+	// SkynetGCResult is the result of a skyfile garbage collection.
+	SkynetGCResult struct {
+		Candidates     []SkynetGCCandidate `json:"candidates"`
+		NumFiles       uint64              `json:"numfiles"`
+		ReclaimedBytes uint64              `json:"reclaimedbytes"`
+	}
+
+	// SkynetMaintenance describes the maintenance mode of a portal. While it
+	// is enabled, downloads are served but new data is rejected.
+	SkynetMaintenance struct {
+		Enabled bool   `json:"enabled"`
+		Message string `json:"message"` // the message returned to rejected requests
+	}
+
+	// SkynetPin describes a skyfile pinned to the renter. A zero PinExpiry
+	// means that the pin doesn't expire.
+	SkynetPin struct {
+		PinExpiry time.Time `json:"pinexpiry"` // the time after which the skyfile is unpinned
+		SiaPath   SiaPath   `json:"siapath"`   // the siapath of the base siafile
+		Size      uint64    `json:"size"`      // the combined filesize of the base and extended siafile
+		Skylinks  []string  `json:"skylinks"`  // the skylinks of the skyfile
+	}
+
+	// SkynetSnapshot describes a snapshot of the skylinks pinned under a
+	// directory.
+	SkynetSnapshot struct {
+		Created    time.Time `json:"created"`    // the time the snapshot was taken
+		Name       string    `json:"name"`       // the name of the snapshot
+		NumEntries uint64    `json:"numentries"` // the number of skylinks in the snapshot
+		SiaPath    SiaPath   `json:"siapath"`    // the directory of the snapshot
+	}
+
+	// SkynetSnapshotEntry is a skylink pinned at a siapath.
+	SkynetSnapshotEntry struct {
+		SiaPath SiaPath `json:"siapath"` // the siapath of the base siafile
+		Size    uint64  `json:"size"`    // the combined filesize of the base and extended siafile
+		Skylink string  `json:"skylink"` // the skylink
+	}
+
+	// SkynetSnapshotMove is a skylink that is pinned at a different siapath
+	// than before.
+	SkynetSnapshotMove struct {
+		From    SiaPath `json:"from"`    // the siapath in the older snapshot
+		To      SiaPath `json:"to"`      // the siapath in the newer snapshot
+		Size    uint64  `json:"size"`    // the combined filesize in the newer snapshot
+		Skylink string  `json:"skylink"` // the skylink
+	}
+
+	// SkynetSnapshotDiff is the difference between two snapshots.
+	SkynetSnapshotDiff struct {
+		Added   []SkynetSnapshotEntry `json:"added"`
+		Removed []SkynetSnapshotEntry `json:"removed"`
+		Moved   []SkynetSnapshotMove  `json:"moved"`
+	}
+
+	// SkynetSearchParameters are the criteria of a search over the metadata
+	// of the skyfiles pinned under a directory. Unset criteria match every
+	// skyfile.
+	SkynetSearchParameters struct {
+		SiaPath     SiaPath // the directory to search in
+		Filename    string  // case-insensitive substring of the filename or a subfile name
+		ContentType string  // case-insensitive prefix of a subfile's content type
+		MinSize     uint64  // the minimum length of the skyfile's content
+		MaxSize     uint64  // the maximum length of the skyfile's content, 0 for no maximum
+		Offset      uint64  // the number of matches to skip
+		Limit       uint64  // the maximum number of matches to return
+	}
+
+	// SkynetSearchResult is a skyfile matching a search together with the
+	// fields that matched.
+	SkynetSearchResult struct {
+		Matched []string `json:"matched"` // the metadata fields that matched the search criteria
+		SiaPath SiaPath  `json:"siapath"` // the siapath of the base siafile
+		Size    uint64   `json:"size"`    // the length of the skyfile's content
+		Skylink string   `json:"skylink"` // the skylink of the skyfile
 	}
 
 	// SkynetPortal contains information identifying a Skynet portal.
@@ -278,6 +572,17 @@ type (
 		Skylink(id string) (Skylink, bool)
 	}
 
+	// SkynetSectorFetcher fetches the sectors of skyfiles from a source other
+	// than the renter's hosts, e.g. another portal.
+	SkynetSectorFetcher interface {
+		// BaseSector returns the base sector of the skyfile with the given
+		// skylink.
+		BaseSector(ctx context.Context, link Skylink) ([]byte, error)
+
+		// Sector returns the full sector with the given merkle root.
+		Sector(ctx context.Context, root crypto.Hash) ([]byte, error)
+	}
+
 	// RegistryEntryHealth contains information about a registry entry's
 	// health on the network.
 	RegistryEntryHealth struct {
@@ -364,6 +669,7 @@ func (sm SkyfileMetadata) ForPath(path string) (SkyfileMetadata, bool, uint64, u
 		Subfiles:   make(SkyfileSubfiles),
 		TryFiles:   sm.TryFiles,
 		ErrorPages: sm.ErrorPages,
+		ModTime:    sm.ModTime,
 	}
 
 	// Try to find an exact match
@@ -400,6 +706,58 @@ func (sm SkyfileMetadata) ForPath(path string) (SkyfileMetadata, bool, uint64, u
 	return metadata, isFile, offset, metadata.size()
 }
 
+// ForIndex returns a subset of the SkyfileMetadata that contains only the
+// subfile at the given index. Subfiles are ordered by their offset, subfiles
+// which share an offset, which is only possible for empty files, are ordered
+// by filename. A skyfile without subfiles consists of a single file at index
+// 0. Just like ForPath, the subfile's offset is relative to the returned
+// offset and the returned metadata's filename is the subfile's path.
+func (sm SkyfileMetadata) ForIndex(index uint64) (SkyfileMetadata, uint64, uint64, error) {
+	// A skyfile without subfiles is served as a whole.
+	if len(sm.Subfiles) == 0 {
+		if index != 0 {
+			return SkyfileMetadata{}, 0, 0, errors.AddContext(ErrSubfileIndexOutOfRange, "valid index is 0")
+		}
+		return sm, 0, sm.Length, nil
+	}
+	if index >= uint64(len(sm.Subfiles)) {
+		return SkyfileMetadata{}, 0, 0, errors.AddContext(ErrSubfileIndexOutOfRange, fmt.Sprintf("valid indices are 0 to %v", len(sm.Subfiles)-1))
+	}
+
+	// Order the subfiles.
+	subfiles := make([]SkyfileSubfileMetadata, 0, len(sm.Subfiles))
+	for _, sf := range sm.Subfiles {
+		subfiles = append(subfiles, sf)
+	}
+	sort.Slice(subfiles, func(i, j int) bool {
+		if subfiles[i].Offset != subfiles[j].Offset {
+			return subfiles[i].Offset < subfiles[j].Offset
+		}
+		return subfiles[i].Filename < subfiles[j].Filename
+	})
+	sf := subfiles[index]
+	offset := sf.Offset
+	sf.Offset = 0
+	metadata := SkyfileMetadata{
+		Filename:   EnsurePrefix(sf.Filename, "/"),
+		Length:     sf.Len,
+		Subfiles:   SkyfileSubfiles{sf.Filename: sf},
+		TryFiles:   sm.TryFiles,
+		ErrorPages: sm.ErrorPages,
+		ModTime:    sm.ModTime,
+	}
+	return metadata, offset, sf.Len, nil
+}
+
+// LastModified returns the modification time of the skyfile or the zero time
+// if the skyfile doesn't have one.
+func (sm SkyfileMetadata) LastModified() time.Time {
+	if sm.ModTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sm.ModTime, 0)
+}
+
 // ContentType returns the Content Type of the data. We only return a
 // content-type if it has exactly one subfile. As that is the only case where we
 // can be sure of it.
@@ -436,6 +794,38 @@ func (sm SkyfileMetadata) EffectiveDefaultPath() string {
 	return sm.DefaultPath
 }
 
+// matchDefaultPath returns the path of the subfile the given default path
+// refers to. Older skyfiles might contain default paths which only match a
+// subfile after normalizing them or ignoring their case. These are still
+// matched as long as there is exactly one matching subfile.
+func (ss SkyfileSubfiles) matchDefaultPath(defaultPath string) (string, bool) {
+	if _, exists := ss[strings.TrimPrefix(defaultPath, "/")]; exists {
+		return EnsurePrefix(defaultPath, "/"), true
+	}
+	normalized, err := NormalizeDefaultPath(defaultPath)
+	if err != nil {
+		return "", false
+	}
+	name := strings.TrimPrefix(normalized, "/")
+	if _, exists := ss[name]; exists {
+		return normalized, true
+	}
+	var match string
+	for filename := range ss {
+		if !strings.EqualFold(filename, name) {
+			continue
+		}
+		if match != "" {
+			return "", false // ambiguous
+		}
+		match = filename
+	}
+	if match == "" {
+		return "", false
+	}
+	return EnsurePrefix(match, "/"), true
+}
+
 // IsDirectory returns true if the SkyfileMetadata represents a directory.
 func (sm SkyfileMetadata) IsDirectory() bool {
 	if len(sm.Subfiles) > 1 {
@@ -457,28 +847,53 @@ func (sm SkyfileMetadata) IsDirectory() bool {
 // ServePath takes a requested path and determines what path should be served
 // based on the existence of the requested path, defaultpath, tryfiles, etc.
 func (sm SkyfileMetadata) ServePath(path string) string {
+	servePath, _ := sm.ServePathWithReason(path)
+	return servePath
+}
+
+// ServePathWithReason works like ServePath but also returns the reason for
+// serving the returned path. The reason is empty if the requested path is
+// served as is and it's not the root of the skyfile.
+func (sm SkyfileMetadata) ServePathWithReason(path string) (string, DefaultPathReason) {
 	// If there's a single subfile in the skyfile we want to serve it. We don't
 	// even need to check the tryfiles.
 	if path == "/" && len(sm.Subfiles) == 1 && !sm.DisableDefaultPath {
 		for filename := range sm.Subfiles {
-			return EnsurePrefix(filename, "/")
+			return EnsurePrefix(filename, "/"), DefaultPathReasonAutoSingle
+		}
+	}
+
+	// unresolved returns the reason for serving the requested path as is.
+	unresolved := func() DefaultPathReason {
+		if path != "/" {
+			return ""
 		}
+		if sm.DisableDefaultPath {
+			return DefaultPathReasonDisabled
+		}
+		return DefaultPathReasonNone
 	}
 
 	// If there are tryfiles, determine the servePath based on those.
 	if len(sm.TryFiles) > 0 {
-		return sm.determinePathBasedOnTryfiles(path)
+		servePath := sm.determinePathBasedOnTryfiles(path)
+		if servePath == path {
+			return path, unresolved()
+		}
+		return servePath, DefaultPathReasonTryFiles
 	}
 
 	// Check the defaultpath to determine the servePath.
 	defaultPath := sm.EffectiveDefaultPath()
 	if defaultPath != "" && path == "/" {
-		_, exists := sm.Subfiles[strings.TrimPrefix(defaultPath, "/")]
-		if exists {
-			return EnsurePrefix(defaultPath, "/")
+		servePath, exists := sm.Subfiles.matchDefaultPath(defaultPath)
+		if exists && sm.DefaultPath != "" {
+			return servePath, DefaultPathReasonExplicit
+		} else if exists {
+			return servePath, DefaultPathReasonIndexDetected
 		}
 	}
-	return path
+	return path, unresolved()
 }
 
 // size returns the total size, which is the sum of the length of all subfiles.
@@ -590,6 +1005,20 @@ func (sl *SkyfileLayout) Decode(b []byte) {
 	}
 }
 
+// IsZeroChunk returns whether the roots of a fanout chunk mark the chunk as a
+// zero chunk of a sparse skyfile.
+func IsZeroChunk(roots []crypto.Hash) bool {
+	if len(roots) == 0 {
+		return false
+	}
+	for _, root := range roots {
+		if root != ZeroChunkRoot {
+			return false
+		}
+	}
+	return true
+}
+
 // DecodeFanoutIntoChunks will take the fanout bytes from a skyfile and decode
 // them in to chunks.
 func (sl *SkyfileLayout) DecodeFanoutIntoChunks(fanoutBytes []byte) ([][]crypto.Hash, error) {
@@ -674,11 +1103,17 @@ func (sl SkyfileLayout) Encode() []byte {
 // as nested files and directories are allowed within a single Skyfile, but it
 // is not allowed to contain ./, ../, be empty, or start with a forward slash.
 type SkyfileSubfileMetadata struct {
-	FileMode    os.FileMode `json:"mode,omitempty,siamismatch"` // different json name for compat reasons
-	Filename    string      `json:"filename,omitempty"`
-	ContentType string      `json:"contenttype,omitempty"`
-	Offset      uint64      `json:"offset,omitempty"`
-	Len         uint64      `json:"len,omitempty"`
+	FileMode      os.FileMode `json:"mode,omitempty,siamismatch"` // different json name for compat reasons
+	Filename      string      `json:"filename,omitempty"`
+	ContentType   string      `json:"contenttype,omitempty"`
+	Offset        uint64      `json:"offset,omitempty"`
+	Len           uint64      `json:"len,omitempty"`
+	SymlinkTarget string      `json:"symlinktarget,omitempty"` // only set for symlinks, which don't contain any data
+}
+
+// IsSymlink returns whether the subfile is a symlink.
+func (sm SkyfileSubfileMetadata) IsSymlink() bool {
+	return sm.SymlinkTarget != ""
 }
 
 // IsDir implements the os.FileInfo interface for SkyfileSubfileMetadata.
@@ -688,6 +1123,9 @@ func (sm SkyfileSubfileMetadata) IsDir() bool {
 
 // Mode implements the os.FileInfo interface for SkyfileSubfileMetadata.
 func (sm SkyfileSubfileMetadata) Mode() os.FileMode {
+	if sm.IsSymlink() {
+		return sm.FileMode | os.ModeSymlink
+	}
 	return sm.FileMode
 }
 
@@ -711,6 +1149,9 @@ func (sm SkyfileSubfileMetadata) Sys() interface{} {
 	return nil
 }
 
+// DefaultPathReason describes why a certain path of a skyfile was served.
+type DefaultPathReason string
+
 // SkyfileFormat is the file format the API uses to return a Skyfile as.
 type SkyfileFormat string
 
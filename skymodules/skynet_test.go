@@ -3,6 +3,7 @@ package skymodules
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -291,6 +292,78 @@ func TestSkyfileMetadata_ForPath(t *testing.T) {
 	}
 }
 
+// TestSkyfileMetadata_ForIndex tests finding subfiles by their index.
+func TestSkyfileMetadata_ForIndex(t *testing.T) {
+	// Files b and c are empty and share their offset with d. Files are added
+	// to the map in a different order than their offsets.
+	fullMeta := SkyfileMetadata{
+		ModTime: 1,
+		Subfiles: SkyfileSubfiles{
+			"d.txt":     SkyfileSubfileMetadata{Filename: "d.txt", Offset: 3, Len: 4},
+			"a.txt":     SkyfileSubfileMetadata{Filename: "a.txt", Offset: 0, Len: 3},
+			"dir/c.txt": SkyfileSubfileMetadata{Filename: "dir/c.txt", Offset: 3, Len: 0},
+			"b.txt":     SkyfileSubfileMetadata{Filename: "b.txt", Offset: 3, Len: 0},
+			"e.txt":     SkyfileSubfileMetadata{Filename: "e.txt", Offset: 7, Len: 5},
+		},
+	}
+	tests := []struct {
+		filename string
+		offset   uint64
+		size     uint64
+	}{
+		{"a.txt", 0, 3},
+		{"b.txt", 3, 0},
+		{"d.txt", 3, 4},
+		{"dir/c.txt", 3, 0},
+		{"e.txt", 7, 5},
+	}
+	for i, test := range tests {
+		subMeta, offset, size, err := fullMeta.ForIndex(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sf, exists := subMeta.Subfiles[test.filename]
+		if !exists || len(subMeta.Subfiles) != 1 {
+			t.Fatalf("%v: expected to find %v, got %v", i, test.filename, subMeta.Subfiles)
+		}
+		if sf.Offset != 0 {
+			t.Fatalf("%v: expected subfile offset 0, got %v", i, sf.Offset)
+		}
+		if offset != test.offset || size != test.size || subMeta.Length != test.size {
+			t.Fatalf("%v: expected offset %v and size %v, got %v, %v and length %v", i, test.offset, test.size, offset, size, subMeta.Length)
+		}
+		if subMeta.ModTime != fullMeta.ModTime {
+			t.Fatalf("%v: expected modtime to be kept", i)
+		}
+
+		// The subfile is the same as the one found by its path.
+		pathMeta, isFile, pathOffset, pathSize := fullMeta.ForPath(subMeta.Filename)
+		if !isFile || pathOffset != offset || pathSize != size || !reflect.DeepEqual(pathMeta, subMeta) {
+			t.Fatalf("%v: subfile doesn't match the one found by path %v", i, subMeta.Filename)
+		}
+	}
+
+	// Indices past the last subfile are out of range.
+	_, _, _, err := fullMeta.ForIndex(uint64(len(tests)))
+	if !errors.Contains(err, ErrSubfileIndexOutOfRange) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// A skyfile without subfiles consists of a single file at index 0.
+	singleMeta := SkyfileMetadata{Filename: "file.txt", Length: 10}
+	subMeta, offset, size, err := singleMeta.ForIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(subMeta, singleMeta) || offset != 0 || size != 10 {
+		t.Fatal("unexpected result", subMeta, offset, size)
+	}
+	_, _, _, err = singleMeta.ForIndex(1)
+	if !errors.Contains(err, ErrSubfileIndexOutOfRange) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 // TestSkyfileMetadata_IsDirectory is a table test for the IsDirectory method.
 func TestSkyfileMetadata_IsDirectory(t *testing.T) {
 	tests := []struct {
@@ -730,3 +803,127 @@ func TestDeterminePathBasedOnTryFiles(t *testing.T) {
 		})
 	}
 }
+
+// TestSkyfileMetadata_ServePathWithReason is a unit test for
+// ServePathWithReason.
+func TestSkyfileMetadata_ServePathWithReason(t *testing.T) {
+	t.Parallel()
+
+	single := SkyfileSubfiles{
+		"about.html": SkyfileSubfileMetadata{Filename: "about.html"},
+	}
+	multi := SkyfileSubfiles{
+		"index.html": SkyfileSubfileMetadata{Filename: "index.html"},
+		"about.html": SkyfileSubfileMetadata{Filename: "about.html"},
+	}
+	noIndex := SkyfileSubfiles{
+		"main.html":  SkyfileSubfileMetadata{Filename: "main.html"},
+		"about.html": SkyfileSubfileMetadata{Filename: "about.html"},
+	}
+	ambiguous := SkyfileSubfiles{
+		"About.html": SkyfileSubfileMetadata{Filename: "About.html"},
+		"about.html": SkyfileSubfileMetadata{Filename: "about.html"},
+	}
+
+	tests := []struct {
+		name           string
+		meta           SkyfileMetadata
+		path           string
+		expectedPath   string
+		expectedReason DefaultPathReason
+	}{
+		{
+			name:           "auto single",
+			meta:           SkyfileMetadata{Subfiles: single},
+			path:           "/",
+			expectedPath:   "/about.html",
+			expectedReason: DefaultPathReasonAutoSingle,
+		},
+		{
+			name:           "single disabled",
+			meta:           SkyfileMetadata{Subfiles: single, DisableDefaultPath: true},
+			path:           "/",
+			expectedPath:   "/",
+			expectedReason: DefaultPathReasonDisabled,
+		},
+		{
+			name:           "explicit",
+			meta:           SkyfileMetadata{Subfiles: multi, DefaultPath: "/about.html"},
+			path:           "/",
+			expectedPath:   "/about.html",
+			expectedReason: DefaultPathReasonExplicit,
+		},
+		{
+			name:           "index detected",
+			meta:           SkyfileMetadata{Subfiles: multi},
+			path:           "/",
+			expectedPath:   "/index.html",
+			expectedReason: DefaultPathReasonIndexDetected,
+		},
+		{
+			name:           "multi disabled",
+			meta:           SkyfileMetadata{Subfiles: multi, DisableDefaultPath: true},
+			path:           "/",
+			expectedPath:   "/",
+			expectedReason: DefaultPathReasonDisabled,
+		},
+		{
+			name:           "none",
+			meta:           SkyfileMetadata{Subfiles: noIndex},
+			path:           "/",
+			expectedPath:   "/",
+			expectedReason: DefaultPathReasonNone,
+		},
+		{
+			name:           "tryfiles",
+			meta:           SkyfileMetadata{Subfiles: noIndex, TryFiles: []string{"/main.html"}},
+			path:           "/missing",
+			expectedPath:   "/main.html",
+			expectedReason: DefaultPathReasonTryFiles,
+		},
+		{
+			name:           "tryfiles existing path",
+			meta:           SkyfileMetadata{Subfiles: noIndex, TryFiles: []string{"/main.html"}},
+			path:           "/about.html",
+			expectedPath:   "/about.html",
+			expectedReason: "",
+		},
+		{
+			name:           "subfile",
+			meta:           SkyfileMetadata{Subfiles: multi},
+			path:           "/about.html",
+			expectedPath:   "/about.html",
+			expectedReason: "",
+		},
+		{
+			name:           "legacy case mismatch",
+			meta:           SkyfileMetadata{Subfiles: multi, DefaultPath: "/ABOUT.HTML"},
+			path:           "/",
+			expectedPath:   "/about.html",
+			expectedReason: DefaultPathReasonExplicit,
+		},
+		{
+			name:           "legacy encoded",
+			meta:           SkyfileMetadata{Subfiles: multi, DefaultPath: "/about%2Ehtml/"},
+			path:           "/",
+			expectedPath:   "/about.html",
+			expectedReason: DefaultPathReasonExplicit,
+		},
+		{
+			name:           "legacy ambiguous",
+			meta:           SkyfileMetadata{Subfiles: ambiguous, DefaultPath: "/ABOUT.HTML"},
+			path:           "/",
+			expectedPath:   "/",
+			expectedReason: DefaultPathReasonNone,
+		},
+	}
+	for _, test := range tests {
+		path, reason := test.meta.ServePathWithReason(test.path)
+		if path != test.expectedPath || reason != test.expectedReason {
+			t.Fatalf("'%s' failed: expected '%v' '%v', got '%v' '%v'", test.name, test.expectedPath, test.expectedReason, path, reason)
+		}
+		if servePath := test.meta.ServePath(test.path); servePath != path {
+			t.Fatalf("'%s' failed: ServePath returned '%v'", test.name, servePath)
+		}
+	}
+}
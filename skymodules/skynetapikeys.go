@@ -0,0 +1,87 @@
+package skymodules
+
+import (
+	"encoding/hex"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+)
+
+const (
+	// SkynetAPIKeyScopeRead allows a key to access the read-only skynet
+	// endpoints.
+	SkynetAPIKeyScopeRead SkynetAPIKeyScope = "read"
+
+	// SkynetAPIKeyScopeUpload allows a key to upload skyfiles and update the
+	// registry in addition to reading.
+	SkynetAPIKeyScopeUpload SkynetAPIKeyScope = "upload"
+
+	// SkynetAPIKeyScopePin allows a key to pin and unpin skylinks in
+	// addition to reading.
+	SkynetAPIKeyScopePin SkynetAPIKeyScope = "pin"
+
+	// SkynetAPIKeyScopeAdmin allows a key to access all skynet endpoints.
+	// It is the scope of the API password.
+	SkynetAPIKeyScopeAdmin SkynetAPIKeyScope = "admin"
+)
+
+var (
+	// ErrInvalidSkynetAPIKeyScope is returned when an unknown scope is
+	// specified for an API key.
+	ErrInvalidSkynetAPIKeyScope = errors.New("invalid api key scope, must be one of 'read', 'upload', 'pin' or 'admin'")
+
+	// ErrSkynetAPIKeyNotFound is returned when an API key with a given id
+	// doesn't exist.
+	ErrSkynetAPIKeyNotFound = errors.New("no api key with that id")
+)
+
+type (
+	// SkynetAPIKeyScope is the scope of a skynet API key which determines the
+	// endpoints it can access.
+	SkynetAPIKeyScope string
+
+	// SkynetAPIKey is the information about an API key which is persisted by
+	// the renter. Only the hash of the key is stored, the key itself is only
+	// returned once when it is created.
+	SkynetAPIKey struct {
+		ID        crypto.Hash       `json:"id"`
+		Scope     SkynetAPIKeyScope `json:"scope"`
+		CreatedAt time.Time         `json:"createdat"`
+	}
+)
+
+// ParseSkynetAPIKeyScope parses a scope from a string.
+func ParseSkynetAPIKeyScope(s string) (SkynetAPIKeyScope, error) {
+	scope := SkynetAPIKeyScope(s)
+	switch scope {
+	case SkynetAPIKeyScopeRead, SkynetAPIKeyScopeUpload, SkynetAPIKeyScopePin, SkynetAPIKeyScopeAdmin:
+		return scope, nil
+	}
+	return "", ErrInvalidSkynetAPIKeyScope
+}
+
+// Allows returns true if a key with the scope can access an endpoint that
+// requires the given scope. The admin scope allows everything and every scope
+// allows reading.
+func (s SkynetAPIKeyScope) Allows(required SkynetAPIKeyScope) bool {
+	if s == SkynetAPIKeyScopeAdmin || s == required {
+		return true
+	}
+	if required == SkynetAPIKeyScopeRead {
+		_, err := ParseSkynetAPIKeyScope(string(s))
+		return err == nil
+	}
+	return false
+}
+
+// SkynetAPIKeyID returns the id of an API key which is the hash of the key.
+func SkynetAPIKeyID(key string) crypto.Hash {
+	return crypto.HashBytes([]byte(key))
+}
+
+// NewSkynetAPIKey generates a new random API key.
+func NewSkynetAPIKey() string {
+	return hex.EncodeToString(fastrand.Bytes(32))
+}
@@ -0,0 +1,64 @@
+package skymodules
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestSkynetAPIKeyScope verifies which endpoints a scope allows.
+func TestSkynetAPIKeyScope(t *testing.T) {
+	t.Parallel()
+
+	read := SkynetAPIKeyScopeRead
+	upload := SkynetAPIKeyScopeUpload
+	pin := SkynetAPIKeyScopePin
+	admin := SkynetAPIKeyScopeAdmin
+	tests := []struct {
+		scope    SkynetAPIKeyScope
+		required SkynetAPIKeyScope
+		allowed  bool
+	}{
+		{read, read, true},
+		{read, upload, false},
+		{read, pin, false},
+		{read, admin, false},
+		{upload, read, true},
+		{upload, upload, true},
+		{upload, pin, false},
+		{upload, admin, false},
+		{pin, read, true},
+		{pin, upload, false},
+		{pin, pin, true},
+		{pin, admin, false},
+		{admin, read, true},
+		{admin, upload, true},
+		{admin, pin, true},
+		{admin, admin, true},
+		{"", read, false},
+		{"unknown", read, false},
+	}
+	for _, test := range tests {
+		if allowed := test.scope.Allows(test.required); allowed != test.allowed {
+			t.Errorf("scope '%v' requiring '%v': expected %v but got %v", test.scope, test.required, test.allowed, allowed)
+		}
+	}
+
+	// Parse the scopes.
+	for _, scope := range []SkynetAPIKeyScope{read, upload, pin, admin} {
+		parsed, err := ParseSkynetAPIKeyScope(string(scope))
+		if err != nil || parsed != scope {
+			t.Fatal("failed to parse scope", scope, err)
+		}
+	}
+	_, err := ParseSkynetAPIKeyScope("write")
+	if !errors.Contains(err, ErrInvalidSkynetAPIKeyScope) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Keys should be unique and their ids should be their hashes.
+	key1, key2 := NewSkynetAPIKey(), NewSkynetAPIKey()
+	if key1 == key2 || SkynetAPIKeyID(key1) == SkynetAPIKeyID(key2) {
+		t.Fatal("keys should be unique")
+	}
+}
@@ -0,0 +1,272 @@
+package skymodules
+
+// The Skynet Backup Archive is a tar archive containing the raw sectors of a
+// skyfile as they are stored on the hosts. Every sector is verified against
+// its merkle root when it is read, which makes the archive self-verifying.
+// Encrypted skyfiles are archived as ciphertext. The entries of the archive
+// are in a deterministic order:
+//
+//   manifest.json   - the SkyfileArchiveManifest
+//   basesector      - the full sector the base sector is stored in
+//   sectors/<root>  - the pieces of every chunk of the fanout in the order of
+//                     the fanout, zero chunks of sparse skyfiles are omitted
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// SkyfileArchiveVersion is the version of the backup archive format.
+	SkyfileArchiveVersion = "1"
+
+	// SkyfileArchiveManifestName is the name of the manifest within a backup
+	// archive.
+	SkyfileArchiveManifestName = "manifest.json"
+
+	// SkyfileArchiveBaseSectorName is the name of the base sector within a
+	// backup archive.
+	SkyfileArchiveBaseSectorName = "basesector"
+
+	// SkyfileArchiveSectorDir is the directory of the fanout sectors within a
+	// backup archive.
+	SkyfileArchiveSectorDir = "sectors/"
+)
+
+var (
+	// ErrInvalidSkyfileArchive is returned if a backup archive doesn't
+	// contain the expected entries or sectors.
+	ErrInvalidSkyfileArchive = errors.New("invalid skyfile backup archive")
+)
+
+// SkyfileArchiveManifest describes the content of a backup archive.
+type SkyfileArchiveManifest struct {
+	Version      string `json:"version"`
+	Skylink      string `json:"skylink"`
+	Filesize     uint64 `json:"filesize"`
+	DataPieces   uint8  `json:"datapieces"`
+	ParityPieces uint8  `json:"paritypieces"`
+	CipherType   string `json:"ciphertype"`
+
+	// Chunks contains the roots of every chunk of the fanout.
+	Chunks [][]crypto.Hash `json:"chunks"`
+
+	// Sectors contains the roots of the fanout sectors in the order in which
+	// they are stored in the archive.
+	Sectors []crypto.Hash `json:"sectors"`
+}
+
+// NewSkyfileArchiveManifest creates the manifest of a backup archive for the
+// skyfile with the given V1 skylink, layout and fanout chunks.
+func NewSkyfileArchiveManifest(skylink Skylink, layout SkyfileLayout, chunks [][]crypto.Hash) SkyfileArchiveManifest {
+	var sectors []crypto.Hash
+	for _, roots := range chunks {
+		sectors = append(sectors, SkyfileArchiveChunkSectors(roots)...)
+	}
+	return SkyfileArchiveManifest{
+		Version:      SkyfileArchiveVersion,
+		Skylink:      skylink.String(),
+		Filesize:     layout.Filesize,
+		DataPieces:   layout.FanoutDataPieces,
+		ParityPieces: layout.FanoutParityPieces,
+		CipherType:   layout.CipherType.String(),
+		Chunks:       chunks,
+		Sectors:      sectors,
+	}
+}
+
+// SkyfileArchiveChunkSectors returns the distinct roots of a chunk in the
+// order in which they are stored in a backup archive. Zero chunks are not
+// stored at all.
+func SkyfileArchiveChunkSectors(roots []crypto.Hash) []crypto.Hash {
+	if IsZeroChunk(roots) {
+		return nil
+	}
+	seen := make(map[crypto.Hash]struct{}, len(roots))
+	var sectors []crypto.Hash
+	for _, root := range roots {
+		if _, exists := seen[root]; exists {
+			continue
+		}
+		seen[root] = struct{}{}
+		sectors = append(sectors, root)
+	}
+	return sectors
+}
+
+// SkyfileArchiveSectorName returns the name of the archive entry which
+// contains the sector with the given root.
+func SkyfileArchiveSectorName(root crypto.Hash) string {
+	return SkyfileArchiveSectorDir + root.String()
+}
+
+// SkyfileArchiveEntryHeader returns the encoded tar header of an archive entry
+// with the given name and size. The headers don't depend on the time of the
+// backup which keeps the archive deterministic.
+func SkyfileArchiveEntryHeader(name string, size int64) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := tar.NewWriter(buf).WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  time.Unix(0, 0),
+		Format:   tar.FormatUSTAR,
+	})
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to encode tar header")
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadSkyfileArchiveHeader reads the manifest and base sector from a backup
+// archive and verifies the base sector against the skylink of the manifest.
+// It returns the V1 skylink and the full sector of the base sector.
+func ReadSkyfileArchiveHeader(tr *tar.Reader) (SkyfileArchiveManifest, Skylink, []byte, error) {
+	// Read the manifest.
+	var manifest SkyfileArchiveManifest
+	err := nextSkyfileArchiveEntry(tr, SkyfileArchiveManifestName)
+	if err != nil {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, err
+	}
+	err = json.NewDecoder(tr).Decode(&manifest)
+	if err != nil {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, errors.AddContext(err, "failed to decode manifest")
+	}
+	if manifest.Version != SkyfileArchiveVersion {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, errors.AddContext(errWrongVersion, fmt.Sprintf("unsupported archive version '%v'", manifest.Version))
+	}
+	var skylink Skylink
+	err = skylink.LoadString(manifest.Skylink)
+	if err != nil {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, errors.AddContext(err, "failed to load skylink")
+	}
+	if !skylink.IsSkylinkV1() {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, errors.AddContext(ErrInvalidSkyfileArchive, "manifest doesn't contain a V1 skylink")
+	}
+
+	// Read the base sector.
+	err = nextSkyfileArchiveEntry(tr, SkyfileArchiveBaseSectorName)
+	if err != nil {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, err
+	}
+	sector, err := readSkyfileArchiveSector(tr, skylink.MerkleRoot())
+	if err != nil {
+		return SkyfileArchiveManifest{}, Skylink{}, nil, errors.AddContext(err, "failed to read base sector")
+	}
+	return manifest, skylink, sector, nil
+}
+
+// NewSkyfileArchiveDataReader returns a reader for the data of a skyfile which
+// is recovered from the fanout sectors of a backup archive. The sectors are
+// read one chunk at a time, verified against the fanout's roots and decrypted
+// with the fanout key. The reader must be closed once it is no longer needed.
+func NewSkyfileArchiveDataReader(tr *tar.Reader, layout SkyfileLayout, chunks [][]crypto.Hash, fanoutKey crypto.CipherKey) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		err := writeSkyfileArchiveData(pw, tr, layout, chunks, fanoutKey)
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// writeSkyfileArchiveData recovers the chunks of a skyfile from the sectors of
+// a backup archive and writes them to w.
+func writeSkyfileArchiveData(w io.Writer, tr *tar.Reader, layout SkyfileLayout, chunks [][]crypto.Hash, fanoutKey crypto.CipherKey) error {
+	ec, err := NewRSSubCode(int(layout.FanoutDataPieces), int(layout.FanoutParityPieces), crypto.SegmentSize)
+	if err != nil {
+		return errors.AddContext(err, "failed to create erasure coder")
+	}
+	chunkSize := ChunkSize(layout.CipherType, uint64(layout.FanoutDataPieces))
+	remaining := layout.Filesize
+	for chunkIndex, roots := range chunks {
+		if remaining == 0 {
+			break
+		}
+		size := chunkSize
+		if size > remaining {
+			size = remaining
+		}
+		remaining -= size
+
+		// Zero chunks are not stored in the archive.
+		if IsZeroChunk(roots) {
+			_, err = w.Write(make([]byte, size))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Read the sectors of the chunk.
+		sectors := make(map[crypto.Hash][]byte)
+		for _, root := range SkyfileArchiveChunkSectors(roots) {
+			err = nextSkyfileArchiveEntry(tr, SkyfileArchiveSectorName(root))
+			if err != nil {
+				return err
+			}
+			sectors[root], err = readSkyfileArchiveSector(tr, root)
+			if err != nil {
+				return errors.AddContext(err, fmt.Sprintf("failed to read sector of chunk %v", chunkIndex))
+			}
+		}
+
+		// Decrypt enough pieces to recover the chunk. If the fanout only
+		// contains a single root per chunk, all pieces are identical.
+		pieces := make([][]byte, ec.NumPieces())
+		for pieceIndex := 0; pieceIndex < ec.MinPieces(); pieceIndex++ {
+			rootIndex := pieceIndex
+			if len(roots) == 1 {
+				rootIndex = 0
+			}
+			piece := append([]byte(nil), sectors[roots[rootIndex]]...)
+			_, err = fanoutKey.Derive(uint64(chunkIndex), uint64(rootIndex)).DecryptBytesInPlace(piece, 0)
+			if err != nil {
+				return errors.AddContext(err, fmt.Sprintf("failed to decrypt piece %v of chunk %v", pieceIndex, chunkIndex))
+			}
+			pieces[pieceIndex] = piece
+		}
+		err = ec.Recover(pieces, size, w)
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("failed to recover chunk %v", chunkIndex))
+		}
+	}
+	return nil
+}
+
+// nextSkyfileArchiveEntry advances the archive to the next entry and checks
+// that it has the expected name.
+func nextSkyfileArchiveEntry(tr *tar.Reader, name string) error {
+	hdr, err := tr.Next()
+	if errors.Contains(err, io.EOF) {
+		return errors.AddContext(ErrInvalidSkyfileArchive, fmt.Sprintf("archive ended before '%v'", name))
+	} else if err != nil {
+		return errors.AddContext(err, "failed to read archive entry")
+	}
+	if hdr.Name != name {
+		return errors.AddContext(ErrInvalidSkyfileArchive, fmt.Sprintf("expected entry '%v' but got '%v'", name, hdr.Name))
+	}
+	return nil
+}
+
+// readSkyfileArchiveSector reads a full sector from the current entry of the
+// archive and verifies it against the given root.
+func readSkyfileArchiveSector(r io.Reader, root crypto.Hash) ([]byte, error) {
+	sector := make([]byte, modules.SectorSize)
+	_, err := io.ReadFull(r, sector)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read sector")
+	}
+	if crypto.MerkleRoot(sector) != root {
+		return nil, errors.AddContext(ErrInvalidSkyfileArchive, fmt.Sprintf("sector doesn't match its root %v", root))
+	}
+	return sector, nil
+}
@@ -0,0 +1,135 @@
+package skymodules
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"gitlab.com/SkynetLabs/skyd/skykey"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestSkyfileArchive probes reading the base sector and data of a skyfile from
+// a backup archive.
+func TestSkyfileArchive(t *testing.T) {
+	t.Parallel()
+
+	// Create a skyfile with a full chunk, a zero chunk and a partial chunk.
+	baseSector := fastrand.Bytes(int(modules.SectorSize))
+	skylink, err := NewSkylinkV1(crypto.MerkleRoot(baseSector), 0, modules.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(int(modules.SectorSize))
+	lastChunk := make([]byte, modules.SectorSize)
+	fastrand.Read(lastChunk[:10])
+	data = append(data, make([]byte, modules.SectorSize)...)
+	data = append(data, lastChunk[:10]...)
+	sectors := map[crypto.Hash][]byte{
+		crypto.MerkleRoot(data[:modules.SectorSize]): data[:modules.SectorSize],
+		crypto.MerkleRoot(lastChunk):                 lastChunk,
+	}
+	chunks := [][]crypto.Hash{
+		{crypto.MerkleRoot(data[:modules.SectorSize])},
+		{ZeroChunkRoot},
+		{crypto.MerkleRoot(lastChunk)},
+	}
+	layout := SkyfileLayout{
+		Version:            SkyfileVersion,
+		Filesize:           uint64(len(data)),
+		FanoutDataPieces:   1,
+		FanoutParityPieces: 9,
+		CipherType:         crypto.TypePlain,
+	}
+	manifest := NewSkyfileArchiveManifest(skylink, layout, chunks)
+	if len(manifest.Sectors) != 2 {
+		t.Fatal("zero chunk shouldn't be archived", manifest.Sectors)
+	}
+
+	// newArchive creates an archive for the given manifest.
+	newArchive := func(manifest SkyfileArchiveManifest, sectors map[crypto.Hash][]byte) []byte {
+		buf := new(bytes.Buffer)
+		addEntry := func(name string, data []byte) {
+			hdr, err := SkyfileArchiveEntryHeader(name, int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.Write(hdr)
+			buf.Write(data)
+			buf.Write(make([]byte, (512-len(data)%512)%512))
+		}
+		manifestBytes, err := json.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addEntry(SkyfileArchiveManifestName, manifestBytes)
+		addEntry(SkyfileArchiveBaseSectorName, baseSector)
+		for _, root := range manifest.Sectors {
+			addEntry(SkyfileArchiveSectorName(root), sectors[root])
+		}
+		buf.Write(make([]byte, 1024))
+		return buf.Bytes()
+	}
+
+	// readArchive reads the data of an archive.
+	fanoutKey, err := DeriveFanoutKey(&layout, skykey.Skykey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readArchive := func(archive []byte) ([]byte, error) {
+		tr := tar.NewReader(bytes.NewReader(archive))
+		_, sl, sector, err := ReadSkyfileArchiveHeader(tr)
+		if err != nil {
+			return nil, err
+		}
+		if sl != skylink || !bytes.Equal(sector, baseSector) {
+			t.Fatal("wrong skylink or base sector")
+		}
+		r := NewSkyfileArchiveDataReader(tr, layout, chunks, fanoutKey)
+		defer func() {
+			_ = r.Close()
+		}()
+		return ioutil.ReadAll(r)
+	}
+
+	// The data should be recovered.
+	recovered, err := readArchive(newArchive(manifest, sectors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, data) {
+		t.Fatal("recovered data doesn't match")
+	}
+
+	// Corrupted sectors should be detected.
+	corrupted := make(map[crypto.Hash][]byte)
+	for root, sector := range sectors {
+		corrupted[root] = append([]byte(nil), sector...)
+		corrupted[root][0]++
+	}
+	_, err = readArchive(newArchive(manifest, corrupted))
+	if !errors.Contains(err, ErrInvalidSkyfileArchive) {
+		t.Fatal("expected corruption to be detected", err)
+	}
+
+	// Missing sectors should be detected.
+	missing := manifest
+	missing.Sectors = missing.Sectors[:1]
+	_, err = readArchive(newArchive(missing, sectors))
+	if !errors.Contains(err, ErrInvalidSkyfileArchive) {
+		t.Fatal("expected missing sector to be detected", err)
+	}
+
+	// Unknown versions should be rejected.
+	wrongVersion := manifest
+	wrongVersion.Version = "2"
+	_, err = readArchive(newArchive(wrongVersion, sectors))
+	if !errors.Contains(err, errWrongVersion) {
+		t.Fatal("expected wrong version to be rejected", err)
+	}
+}
@@ -0,0 +1,49 @@
+package skymodules
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// CORSWildcardOrigin is the origin which allows all origins to access
+// skyfiles.
+const CORSWildcardOrigin = "*"
+
+// AllowedCORSOrigin returns the value of the Access-Control-Allow-Origin
+// header for a request from the given origin. The returned bool is false if
+// the origin is not allowed to access skyfiles.
+func AllowedCORSOrigin(origins []string, origin string) (string, bool) {
+	for _, allowed := range origins {
+		if allowed == CORSWildcardOrigin {
+			return CORSWildcardOrigin, true
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// ValidateCORSOrigins checks that the origins are either a single wildcard or
+// a list of origins of the form scheme://host[:port].
+func ValidateCORSOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == CORSWildcardOrigin {
+			if len(origins) > 1 {
+				return errors.New("wildcard origin can't be combined with other origins")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return errors.AddContext(err, fmt.Sprintf("invalid origin '%v'", origin))
+		}
+		if u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid origin '%v', expected scheme://host[:port]", origin)
+		}
+	}
+	return nil
+}
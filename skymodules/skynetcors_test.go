@@ -0,0 +1,58 @@
+package skymodules
+
+import "testing"
+
+// TestAllowedCORSOrigin is a unit test for AllowedCORSOrigin.
+func TestAllowedCORSOrigin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		origins []string
+		origin  string
+		result  string
+		allowed bool
+	}{
+		{nil, "https://siasky.net", "", false},
+		{[]string{"*"}, "https://siasky.net", "*", true},
+		{[]string{"*"}, "", "*", true},
+		{[]string{"https://siasky.net"}, "https://siasky.net", "https://siasky.net", true},
+		{[]string{"https://siasky.net/"}, "https://siasky.net", "https://siasky.net", true},
+		{[]string{"https://SIASKY.net"}, "https://siasky.net", "https://siasky.net", true},
+		{[]string{"https://siasky.net"}, "http://siasky.net", "", false},
+		{[]string{"https://siasky.net"}, "", "", false},
+		{[]string{"https://a.net", "https://b.net"}, "https://b.net", "https://b.net", true},
+	}
+	for i, test := range tests {
+		result, allowed := AllowedCORSOrigin(test.origins, test.origin)
+		if result != test.result || allowed != test.allowed {
+			t.Errorf("%v: expected %v %v but got %v %v", i, test.result, test.allowed, result, allowed)
+		}
+	}
+}
+
+// TestValidateCORSOrigins is a unit test for ValidateCORSOrigins.
+func TestValidateCORSOrigins(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		origins []string
+		valid   bool
+	}{
+		{nil, true},
+		{[]string{"*"}, true},
+		{[]string{"https://siasky.net"}, true},
+		{[]string{"https://siasky.net/"}, true},
+		{[]string{"http://localhost:3000", "https://siasky.net"}, true},
+		{[]string{"*", "https://siasky.net"}, false},
+		{[]string{""}, false},
+		{[]string{"siasky.net"}, false},
+		{[]string{"https://siasky.net/path"}, false},
+		{[]string{"https://siasky.net?foo=bar"}, false},
+	}
+	for i, test := range tests {
+		err := ValidateCORSOrigins(test.origins)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid to be %v but got %v", i, test.valid, err)
+		}
+	}
+}
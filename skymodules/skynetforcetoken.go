@@ -0,0 +1,75 @@
+package skymodules
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrInvalidSkynetForceToken is returned when a force token doesn't grant
+	// forcing the request it was passed with.
+	ErrInvalidSkynetForceToken = errors.New("invalid force token")
+
+	// skynetForceTokenSpecifier is signed together with a force token to
+	// prevent signatures from other contexts being used as tokens.
+	skynetForceTokenSpecifier = types.NewSpecifier("SkynetForceToken")
+)
+
+type (
+	// SkynetForceToken grants permission to overwrite the file at a siapath
+	// with the force flag, even if force was disabled for the request. It is
+	// signed with the secret key belonging to the renter's
+	// SkynetForceTokenKey setting.
+	SkynetForceToken struct {
+		SiaPath   string           `json:"siapath"`
+		Expiry    int64            `json:"expiry"`
+		Signature crypto.Signature `json:"signature"`
+	}
+)
+
+// NewSkynetForceToken creates a force token for the given siapath which expires
+// at the given unix timestamp.
+func NewSkynetForceToken(siaPath SiaPath, expiry int64, sk crypto.SecretKey) SkynetForceToken {
+	t := SkynetForceToken{
+		SiaPath: siaPath.String(),
+		Expiry:  expiry,
+	}
+	t.Signature = crypto.SignHash(t.SigHash(), sk)
+	return t
+}
+
+// SigHash returns the hash which is signed by the token's issuer.
+func (t SkynetForceToken) SigHash() crypto.Hash {
+	return crypto.HashAll(skynetForceTokenSpecifier, t.SiaPath, t.Expiry)
+}
+
+// Verify checks that the token grants forcing a request for the given
+// siapath at the given time and that it was signed by the given key.
+func (t SkynetForceToken) Verify(key types.SiaPublicKey, siaPath SiaPath, now time.Time) error {
+	if len(key.Key) == 0 {
+		return errors.AddContext(ErrInvalidSkynetForceToken, "no force token key is configured")
+	}
+	if key.Algorithm != types.SignatureEd25519 {
+		return errors.AddContext(ErrInvalidSkynetForceToken, "force token key is not an ed25519 key")
+	}
+	var pk crypto.PublicKey
+	if len(key.Key) != len(pk) {
+		return errors.AddContext(ErrInvalidSkynetForceToken, "force token key has an invalid length")
+	}
+	copy(pk[:], key.Key)
+	if t.SiaPath != siaPath.String() {
+		return errors.AddContext(ErrInvalidSkynetForceToken, fmt.Sprintf("token was issued for siapath %v", t.SiaPath))
+	}
+	if now.Unix() >= t.Expiry {
+		return errors.AddContext(ErrInvalidSkynetForceToken, "token expired")
+	}
+	err := crypto.VerifyHash(t.SigHash(), pk, t.Signature)
+	if err != nil {
+		return errors.Compose(ErrInvalidSkynetForceToken, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package skymodules
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// TestSkynetForceToken verifies that force tokens are only valid for their
+// siapath, before their expiry and for the key they were signed with.
+func TestSkynetForceToken(t *testing.T) {
+	t.Parallel()
+
+	sk, pk := crypto.GenerateKeyPair()
+	key := types.Ed25519PublicKey(pk)
+	siaPath := RandomSiaPath()
+	now := time.Now()
+	token := NewSkynetForceToken(siaPath, now.Add(time.Minute).Unix(), sk)
+	if err := token.Verify(key, siaPath, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token is only valid for its siapath.
+	if err := token.Verify(key, RandomSiaPath(), now); !errors.Contains(err, ErrInvalidSkynetForceToken) {
+		t.Fatal("token should be invalid for another siapath", err)
+	}
+
+	// The token expires.
+	if err := token.Verify(key, siaPath, now.Add(time.Minute)); !errors.Contains(err, ErrInvalidSkynetForceToken) {
+		t.Fatal("token should have expired", err)
+	}
+
+	// Tampering with the token is detected.
+	tampered := token
+	tampered.Expiry++
+	if err := tampered.Verify(key, siaPath, now); !errors.Contains(err, ErrInvalidSkynetForceToken) {
+		t.Fatal("tampered token should be invalid", err)
+	}
+
+	// Another key or no key doesn't verify the token.
+	_, otherPK := crypto.GenerateKeyPair()
+	if err := token.Verify(types.Ed25519PublicKey(otherPK), siaPath, now); !errors.Contains(err, ErrInvalidSkynetForceToken) {
+		t.Fatal("token should be invalid for another key", err)
+	}
+	if err := token.Verify(types.SiaPublicKey{}, siaPath, now); !errors.Contains(err, ErrInvalidSkynetForceToken) {
+		t.Fatal("token should be invalid without a key", err)
+	}
+}
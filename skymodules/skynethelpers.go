@@ -9,7 +9,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -21,6 +23,12 @@ import (
 	"go.sia.tech/siad/modules"
 )
 
+const (
+	// SymlinkTargetHeader is the header of a multipart part which marks the
+	// part as a symlink to the given target.
+	SymlinkTargetHeader = "Symlink-Target"
+)
+
 var (
 	// ErrInvalidDefaultPath is returned when the specified default path is not
 	// valid, e.g. the file it points to does not exist.
@@ -65,6 +73,34 @@ func AddMultipartFile(w *multipart.Writer, filedata []byte, filekey, filename st
 	return metadata, nil
 }
 
+// AddMultipartSymlink is a helper function to add a symlink to multipart
+// form-data. Symlinks don't contain any data, their target is sent in the
+// part's Symlink-Target header.
+func AddMultipartSymlink(w *multipart.Writer, filekey, filename, target string, filemode uint64, offset *uint64) (SkyfileSubfileMetadata, error) {
+	if target == "" {
+		return SkyfileSubfileMetadata{}, errors.New("symlink target can't be empty")
+	}
+	filemodeStr := fmt.Sprintf("%o", filemode)
+	partHeader, err := createFormFileHeaders(filekey, filename, filemodeStr, "")
+	if err != nil {
+		return SkyfileSubfileMetadata{}, err
+	}
+	partHeader.Set(SymlinkTargetHeader, target)
+	_, err = w.CreatePart(partHeader)
+	if err != nil {
+		return SkyfileSubfileMetadata{}, err
+	}
+	metadata := SkyfileSubfileMetadata{
+		Filename:      filename,
+		FileMode:      os.FileMode(filemode),
+		SymlinkTarget: target,
+	}
+	if offset != nil {
+		metadata.Offset = *offset
+	}
+	return metadata, nil
+}
+
 // ChunkIndexByOffset returns the chunk offset and relative offset within that
 // chunk given an offset within some data and chunksize.
 func ChunkIndexByOffset(offset, chunkSize uint64) (chunkIndex, off uint64) {
@@ -390,6 +426,29 @@ func IsEncryptedBaseSector(baseSector []byte) bool {
 	return IsEncryptedLayout(sl)
 }
 
+// EncryptedBaseSectorKeyID returns the key ID stored in the layout of an
+// encrypted base sector. For skykeys of type TypePrivateID this is an
+// encrypted identifier which can only be matched by the owner of the skykey.
+func EncryptedBaseSectorKeyID(baseSector []byte) (keyID skykey.SkykeyID) {
+	var sl SkyfileLayout
+	sl.Decode(baseSector)
+	copy(keyID[:], sl.KeyData[:skykey.SkykeyIDLen])
+	return
+}
+
+// SkykeyMatchesBaseSector returns true if the encrypted base sector was
+// encrypted using the given skykey.
+func SkykeyMatchesBaseSector(baseSector []byte, sk skykey.Skykey) (bool, error) {
+	var sl SkyfileLayout
+	sl.Decode(baseSector)
+	keyID := EncryptedBaseSectorKeyID(baseSector)
+	if keyID == sk.ID() {
+		return true, nil
+	}
+	nonce := sl.KeyData[skykey.SkykeyIDLen : skykey.SkykeyIDLen+chacha.XNonceSize]
+	return sk.MatchesSkyfileEncryptionID(keyID[:], nonce)
+}
+
 // IsEncryptedLayout returns true if and only if the the layout indicates that
 // it is from an encrypted base sector.
 func IsEncryptedLayout(sl SkyfileLayout) bool {
@@ -415,6 +474,22 @@ var ErrRecursiveBaseSector = errors.New("can't use skymodules.ParseSkyfileMetada
 // ParseSkyfileMetadata will pull the metadata (including layout and fanout) out
 // of a skyfile.
 func ParseSkyfileMetadata(baseSector []byte) (sl SkyfileLayout, fanoutBytes []byte, sm SkyfileMetadata, rawSM, baseSectorPayload []byte, err error) {
+	sl, fanoutBytes, sm, rawSM, baseSectorPayload, err = ParseSkyfileMetadataUnvalidated(baseSector)
+	if err != nil {
+		return SkyfileLayout{}, nil, SkyfileMetadata{}, nil, nil, err
+	}
+
+	// Make sure the returned metadata is valid.
+	if err := ValidateSkyfileMetadata(sm); err != nil {
+		return SkyfileLayout{}, nil, SkyfileMetadata{}, nil, nil, err
+	}
+	return sl, fanoutBytes, sm, rawSM, baseSectorPayload, nil
+}
+
+// ParseSkyfileMetadataUnvalidated works like ParseSkyfileMetadata but doesn't
+// validate the parsed metadata. It should only be used to inspect skyfiles
+// which might be malformed.
+func ParseSkyfileMetadataUnvalidated(baseSector []byte) (sl SkyfileLayout, fanoutBytes []byte, sm SkyfileMetadata, rawSM, baseSectorPayload []byte, err error) {
 	// Parse the layout.
 	var offset uint64
 	sl = ParseSkyfileLayout(baseSector)
@@ -449,6 +524,15 @@ func ParseSkyfileMetadata(baseSector []byte) (sl SkyfileLayout, fanoutBytes []by
 	}
 	offset += metadataSize
 
+	// Skyfiles uploaded before the default path was normalized might contain
+	// a default path which only loosely matches a subfile. Replace it with the
+	// path of the matching subfile to keep them working.
+	if sm.DefaultPath != "" {
+		if defaultPath, ok := sm.Subfiles.matchDefaultPath(sm.DefaultPath); ok {
+			sm.DefaultPath = defaultPath
+		}
+	}
+
 	// In version 1, the base sector payload is nil unless there is no fanout.
 	if sl.FanoutSize == 0 {
 		// Check for out-of-bounds.
@@ -457,11 +541,6 @@ func ParseSkyfileMetadata(baseSector []byte) (sl SkyfileLayout, fanoutBytes []by
 		}
 		baseSectorPayload = baseSector[offset : offset+sl.Filesize]
 	}
-
-	// Make sure the returned metadata is valid.
-	if err := ValidateSkyfileMetadata(sm); err != nil {
-		return SkyfileLayout{}, nil, SkyfileMetadata{}, nil, nil, err
-	}
 	return sl, fanoutBytes, sm, rawSM, baseSectorPayload, nil
 }
 
@@ -500,6 +579,11 @@ func ValidateSkyfileMetadata(metadata SkyfileMetadata) error {
 
 			// note that we do not check the length property of a subfile as it
 			// is possible a user might have uploaded an empty part
+
+			// symlinks don't contain any data
+			if md.IsSymlink() && md.Len > 0 {
+				return fmt.Errorf("subfile '%v' is a symlink but has a length of %v", filename, md.Len)
+			}
 		}
 		legacyFile := len(metadata.Subfiles) > 0 && metadata.Length == 0
 		if !legacyFile && metadata.Length != totalLength {
@@ -507,6 +591,24 @@ func ValidateSkyfileMetadata(metadata SkyfileMetadata) error {
 		}
 	}
 
+	// check the modtime
+	if metadata.ModTime < 0 {
+		return fmt.Errorf("invalid modtime set on metadata - modtime: %v", metadata.ModTime)
+	}
+
+	// check the redirect, skyfiles which point to another skylink can't
+	// contain any data
+	if metadata.Redirect != "" {
+		var sl Skylink
+		err = sl.LoadString(metadata.Redirect)
+		if err != nil {
+			return errors.AddContext(err, "invalid redirect")
+		}
+		if metadata.Length > 0 || len(metadata.Subfiles) > 0 {
+			return errors.New("skyfiles with a redirect can't contain any data")
+		}
+	}
+
 	if metadata.DisableDefaultPath && metadata.DefaultPath != "" {
 		return errors.New("invalid defaultpath state - both defaultpath and disabledefaultpath are set, please specify a format if you want to download this skyfile")
 	}
@@ -567,8 +669,22 @@ func fileContentType(filename string, file io.Reader) (string, error) {
 	return http.DetectContentType(buffer), nil
 }
 
+// NormalizeDefaultPath returns the canonical form of a default path. The path
+// is URL-decoded, prefixed with a slash and cleaned, which removes trailing and
+// duplicate slashes. The case of the path is preserved.
+func NormalizeDefaultPath(defaultPath string) (string, error) {
+	if defaultPath == "" {
+		return "", nil
+	}
+	decoded, err := url.PathUnescape(defaultPath)
+	if err != nil {
+		return "", errors.AddContext(err, "failed to decode default path")
+	}
+	return path.Clean(EnsurePrefix(decoded, "/")), nil
+}
+
 // validateDefaultPath ensures the given default path makes sense in relation to
-// the subfiles being uploaded. It returns a potentially altered default path.
+// the subfiles being uploaded. It returns the normalized default path.
 func validateDefaultPath(defaultPath string, subfiles SkyfileSubfiles) (string, error) {
 	if defaultPath == "" {
 		return defaultPath, nil
@@ -577,15 +693,22 @@ func validateDefaultPath(defaultPath string, subfiles SkyfileSubfiles) (string,
 		return "", errors.New("defaultpath is not allowed on single files")
 	}
 
-	defaultPath = EnsurePrefix(defaultPath, "/")
+	defaultPath, err := NormalizeDefaultPath(defaultPath)
+	if err != nil {
+		return "", err
+	}
 
 	if strings.Count(defaultPath, "/") > 1 && len(subfiles) > 1 {
 		return "", fmt.Errorf("skyfile has invalid default path which refers to a non-root file")
 	}
 
-	// check if we have a subfile at the given default path.
+	// check if we have a subfile at the given default path. The match is case
+	// sensitive, but we point out a matching subfile with a different case.
 	_, found := subfiles[strings.TrimPrefix(defaultPath, "/")]
 	if !found {
+		if match, ok := subfiles.matchDefaultPath(defaultPath); ok {
+			return "", fmt.Errorf("no such path: %s, did you mean %s?", defaultPath, match)
+		}
 		return "", fmt.Errorf("no such path: %s", defaultPath)
 	}
 
@@ -492,6 +492,34 @@ func testValidateDefaultPath(t *testing.T) {
 			dpExpected: "/a.htm",
 			err:        "",
 		},
+		{
+			name:       "case mismatch",
+			subfiles:   subfiles("a.html"),
+			dpQuery:    "/A.html",
+			dpExpected: "",
+			err:        "did you mean /a.html?",
+		},
+		{
+			name:       "percent-encoded default path",
+			subfiles:   subfiles("a b.html"),
+			dpQuery:    "/a%20b.html",
+			dpExpected: "/a b.html",
+			err:        "",
+		},
+		{
+			name:       "trailing and duplicate slashes",
+			subfiles:   subfiles("a.html"),
+			dpQuery:    "//a.html/",
+			dpExpected: "/a.html",
+			err:        "",
+		},
+		{
+			name:       "invalid encoding",
+			subfiles:   subfiles("a.html"),
+			dpQuery:    "/a%zz.html",
+			dpExpected: "",
+			err:        "failed to decode default path",
+		},
 		{
 			name:       "default path not at root",
 			subfiles:   subfiles("a/b/c.html"),
@@ -570,6 +598,14 @@ func testValidateSkyfileMetadata(t *testing.T) {
 		t.Fatal("unexpected outcome")
 	}
 
+	// verify invalid modtime
+	invalid = metadata
+	invalid.ModTime = -1
+	err = ValidateSkyfileMetadata(invalid)
+	if err == nil || !strings.Contains(err.Error(), "invalid modtime") {
+		t.Fatal("unexpected outcome")
+	}
+
 	// verify invalid default path
 	invalid = metadata
 	invalid.DefaultPath = "foo/../bar"
@@ -676,6 +712,37 @@ func testValidateSkyfileMetadata(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "invalid length set on metadata - length: 1, totalLength: 10, subfiles: 1") {
 		t.Fatal("unexpected outcome")
 	}
+
+	// verify valid redirect
+	var sl Skylink
+	err = sl.LoadString("_B19BtlWtjjR7AD0DDzxYanvIhZ7cxXrva5tNNxDht1kaA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid = SkyfileMetadata{
+		Filename: "alias",
+		Redirect: sl.String(),
+	}
+	err = ValidateSkyfileMetadata(valid)
+	if err != nil {
+		t.Fatal("unexpected outcome", err)
+	}
+
+	// verify invalid redirect
+	invalid = valid
+	invalid.Redirect = "notaskylink"
+	err = ValidateSkyfileMetadata(invalid)
+	if err == nil || !strings.Contains(err.Error(), "invalid redirect") {
+		t.Fatal("unexpected outcome", err)
+	}
+
+	// verify redirect with data
+	invalid = valid
+	invalid.Length = 1
+	err = ValidateSkyfileMetadata(invalid)
+	if err == nil || !strings.Contains(err.Error(), "can't contain any data") {
+		t.Fatal("unexpected outcome", err)
+	}
 }
 
 // testEnsurePrefix ensures EnsurePrefix is properly adding prefixes.
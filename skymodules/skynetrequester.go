@@ -0,0 +1,24 @@
+package skymodules
+
+import "context"
+
+// requesterKey is the type of the context key for the requester of a skynet
+// request.
+type requesterKey struct{}
+
+// ContextWithRequester returns a copy of ctx which carries the address of the
+// client a request was sent by. If the requester is empty, ctx is returned
+// unchanged.
+func ContextWithRequester(ctx context.Context, requester string) context.Context {
+	if requester == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requesterKey{}, requester)
+}
+
+// RequesterFromContext returns the requester attached to ctx or an empty
+// string if there is none.
+func RequesterFromContext(ctx context.Context) string {
+	requester, _ := ctx.Value(requesterKey{}).(string)
+	return requester
+}
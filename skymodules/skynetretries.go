@@ -0,0 +1,44 @@
+package skymodules
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type (
+	// downloadRetriesKey is the type of the context key for download retry
+	// counters.
+	downloadRetriesKey struct{}
+
+	// DownloadRetries counts the internal retries the renter needed to
+	// complete a download after transient worker errors.
+	DownloadRetries struct {
+		atomicRetries uint64
+	}
+)
+
+// Add increments the number of retries by one.
+func (dr *DownloadRetries) Add() {
+	atomic.AddUint64(&dr.atomicRetries, 1)
+}
+
+// Load returns the number of retries.
+func (dr *DownloadRetries) Load() uint64 {
+	return atomic.LoadUint64(&dr.atomicRetries)
+}
+
+// ContextWithDownloadRetries returns a copy of ctx which carries the given
+// retry counter. If the counter is nil, ctx is returned unchanged.
+func ContextWithDownloadRetries(ctx context.Context, dr *DownloadRetries) context.Context {
+	if dr == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, downloadRetriesKey{}, dr)
+}
+
+// DownloadRetriesFromContext returns the retry counter attached to ctx or nil
+// if there is none.
+func DownloadRetriesFromContext(ctx context.Context) *DownloadRetries {
+	dr, _ := ctx.Value(downloadRetriesKey{}).(*DownloadRetries)
+	return dr
+}
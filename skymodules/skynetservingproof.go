@@ -0,0 +1,49 @@
+package skymodules
+
+import (
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// ErrInvalidSkynetServingProof is returned when the signature of a
+	// serving proof doesn't match the portal's public key.
+	ErrInvalidSkynetServingProof = errors.New("invalid serving proof signature")
+)
+
+type (
+	// SkynetServingProof is a statement signed by a portal that it served the
+	// content of a skylink at a certain time. The content hash is either the
+	// blake2b hash of the served bytes or the Merkle root of the skyfile's
+	// base sector if the served bytes weren't hashed.
+	SkynetServingProof struct {
+		Skylink     string           `json:"skylink"`
+		ContentHash crypto.Hash      `json:"contenthash"`
+		Timestamp   int64            `json:"timestamp"`
+		BytesServed uint64           `json:"bytesserved"`
+		Signature   crypto.Signature `json:"signature"`
+	}
+)
+
+// SigHash returns the hash which is signed by the portal.
+func (p SkynetServingProof) SigHash() crypto.Hash {
+	return crypto.HashAll(p.Skylink, p.ContentHash, p.Timestamp, p.BytesServed)
+}
+
+// Verify checks the proof's signature against the given portal key.
+func (p SkynetServingProof) Verify(portalKey types.SiaPublicKey) error {
+	if portalKey.Algorithm != types.SignatureEd25519 {
+		return errors.AddContext(ErrInvalidSkynetServingProof, "portal key is not an ed25519 key")
+	}
+	var pk crypto.PublicKey
+	if len(portalKey.Key) != len(pk) {
+		return errors.AddContext(ErrInvalidSkynetServingProof, "portal key has an invalid length")
+	}
+	copy(pk[:], portalKey.Key)
+	err := crypto.VerifyHash(p.SigHash(), pk, p.Signature)
+	if err != nil {
+		return errors.Compose(ErrInvalidSkynetServingProof, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package skymodules
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// TestSkynetServingProof verifies that serving proofs can be verified against
+// the key they were signed with and that tampering with them is detected.
+func TestSkynetServingProof(t *testing.T) {
+	t.Parallel()
+
+	sk, pk := crypto.GenerateKeyPair()
+	portalKey := types.Ed25519PublicKey(pk)
+	proof := SkynetServingProof{
+		Skylink:     "AACFSvArqvtZKNoiI1c8OWXasUS351_ZPvsqmn2tPq-0yQ",
+		ContentHash: crypto.HashBytes([]byte("data")),
+		Timestamp:   1234,
+		BytesServed: 4,
+	}
+	proof.Signature = crypto.SignHash(proof.SigHash(), sk)
+	if err := proof.Verify(portalKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every signed field should be covered by the signature.
+	tampered := []SkynetServingProof{proof, proof, proof, proof}
+	tampered[0].Skylink = "AABFSvArqvtZKNoiI1c8OWXasUS351_ZPvsqmn2tPq-0yQ"
+	tampered[1].ContentHash = crypto.HashBytes([]byte("other data"))
+	tampered[2].Timestamp++
+	tampered[3].BytesServed++
+	for i, p := range tampered {
+		if err := p.Verify(portalKey); !errors.Contains(err, ErrInvalidSkynetServingProof) {
+			t.Fatal("tampered proof should be invalid", i, err)
+		}
+	}
+
+	// Another key shouldn't verify the proof.
+	_, otherPK := crypto.GenerateKeyPair()
+	if err := proof.Verify(types.Ed25519PublicKey(otherPK)); !errors.Contains(err, ErrInvalidSkynetServingProof) {
+		t.Fatal("proof should be invalid for another key", err)
+	}
+	if err := proof.Verify(types.SiaPublicKey{Algorithm: types.SignatureEd25519}); !errors.Contains(err, ErrInvalidSkynetServingProof) {
+		t.Fatal("proof should be invalid for an empty key", err)
+	}
+}
@@ -0,0 +1,65 @@
+package skymodules
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+)
+
+// MaxTraceIDLength is the maximum length of a trace ID provided by a caller.
+const MaxTraceIDLength = 64
+
+type (
+	// traceIDKey is the type of the context key for trace IDs.
+	traceIDKey struct{}
+
+	// SkynetTraceEntry is a log line of a skynet operation which was tagged
+	// with a trace ID.
+	SkynetTraceEntry struct {
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+	}
+)
+
+// NewTraceID returns a new random trace ID.
+func NewTraceID() string {
+	return hex.EncodeToString(fastrand.Bytes(16))
+}
+
+// ValidateTraceID checks that a trace ID provided by a caller is not empty,
+// not too long and only consists of alphanumeric characters, dashes and
+// underscores. That way it can be safely written to the logs.
+func ValidateTraceID(id string) error {
+	if id == "" {
+		return fmt.Errorf("trace ID can't be empty")
+	}
+	if len(id) > MaxTraceIDLength {
+		return fmt.Errorf("trace ID can't be longer than %v characters", MaxTraceIDLength)
+	}
+	for _, c := range id {
+		isAlphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlphanumeric && c != '-' && c != '_' {
+			return fmt.Errorf("trace ID contains invalid character '%c'", c)
+		}
+	}
+	return nil
+}
+
+// ContextWithTraceID returns a copy of ctx which carries the given trace ID.
+// If the ID is empty, ctx is returned unchanged.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace ID attached to ctx or an empty string
+// if there is none.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
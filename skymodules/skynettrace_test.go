@@ -0,0 +1,54 @@
+package skymodules
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestValidateTraceID is a unit test for ValidateTraceID.
+func TestValidateTraceID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"", false},
+		{NewTraceID(), true},
+		{"my-trace_ID-1", true},
+		{strings.Repeat("a", MaxTraceIDLength), true},
+		{strings.Repeat("a", MaxTraceIDLength+1), false},
+		{"with space", false},
+		{"new\nline", false},
+		{"ü", false},
+	}
+	for i, test := range tests {
+		err := ValidateTraceID(test.id)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: expected valid %v but got %v", i, test.valid, err)
+		}
+	}
+}
+
+// TestContextWithTraceID is a unit test for ContextWithTraceID and
+// TraceIDFromContext.
+func TestContextWithTraceID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	if id := TraceIDFromContext(ctx); id != "" {
+		t.Fatal("unexpected id", id)
+	}
+	if ContextWithTraceID(ctx, "") != ctx {
+		t.Fatal("empty id shouldn't change the context")
+	}
+	ctx = ContextWithTraceID(ctx, "foo")
+	if id := TraceIDFromContext(ctx); id != "foo" {
+		t.Fatal("unexpected id", id)
+	}
+	ctx = ContextWithTraceID(ctx, "bar")
+	if id := TraceIDFromContext(ctx); id != "bar" {
+		t.Fatal("unexpected id", id)
+	}
+}
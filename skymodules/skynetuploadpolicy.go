@@ -0,0 +1,203 @@
+package skymodules
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// sniffLen is the number of bytes used by http.DetectContentType to sniff the
+// content type of a file.
+const sniffLen = 512
+
+var (
+	// ErrUploadPolicyViolation is returned when an upload contains a file
+	// which is not allowed by the portal's upload policy.
+	ErrUploadPolicyViolation = errors.New("upload violates the upload policy")
+
+	// ErrTooManySubfiles is returned when a multipart upload contains more
+	// files than the upload policy allows. It is always returned together
+	// with ErrUploadPolicyViolation.
+	ErrTooManySubfiles = errors.New("upload contains too many subfiles")
+
+	// ErrSubfileNameTooLong is returned when a multipart upload contains a
+	// file with a name exceeding the multipart limits.
+	ErrSubfileNameTooLong = errors.New("subfile name is too long")
+
+	// ErrMultipartHeadersTooLarge is returned when the headers of the parts of
+	// a multipart upload exceed the multipart limits.
+	ErrMultipartHeadersTooLarge = errors.New("multipart headers are too large")
+)
+
+var (
+	// DefaultSkynetMultipartLimits are the limits applied to multipart
+	// uploads if the renter's settings don't specify them.
+	DefaultSkynetMultipartLimits = SkynetMultipartLimits{
+		MaxFilenameLength: 4096,
+		MaxHeaderBytes:    1 << 22, // 4 MiB
+	}
+)
+
+// SkynetUploadPolicy restricts the content that can be uploaded to the
+// portal. The zero value allows everything.
+type SkynetUploadPolicy struct {
+	// AllowedMIMEPrefixes restricts the content types of uploaded files to
+	// those starting with one of the prefixes. If empty, all content types
+	// are allowed unless they are blocked.
+	AllowedMIMEPrefixes []string `json:"allowedmimeprefixes"`
+
+	// BlockedMIMEPrefixes contains the prefixes of content types which can't
+	// be uploaded.
+	BlockedMIMEPrefixes []string `json:"blockedmimeprefixes"`
+
+	// BlockedExtensions contains the filename extensions, e.g. ".exe", which
+	// can't be uploaded.
+	BlockedExtensions []string `json:"blockedextensions"`
+
+	// MaxSubfiles is the maximum number of files a multipart upload can
+	// contain. 0 means unlimited.
+	MaxSubfiles uint64 `json:"maxsubfiles"`
+}
+
+// CheckFile checks whether a file with the given filename and content type
+// can be uploaded.
+func (p SkynetUploadPolicy) CheckFile(filename, contentType string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, blocked := range p.BlockedExtensions {
+		if ext != "" && ext == normalizeExtension(blocked) {
+			return errors.AddContext(ErrUploadPolicyViolation, fmt.Sprintf("file '%v' has the blocked extension '%v'", filename, ext))
+		}
+	}
+
+	// Only check the content type if there are any MIME rules since parsing
+	// it might fail.
+	if !p.HasMIMERules() {
+		return nil
+	}
+	mediaType := "application/octet-stream"
+	if contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return errors.AddContext(ErrUploadPolicyViolation, fmt.Sprintf("file '%v' has the invalid content type '%v'", filename, contentType))
+		}
+		mediaType = mt
+	}
+	for _, blocked := range p.BlockedMIMEPrefixes {
+		if strings.HasPrefix(mediaType, strings.ToLower(blocked)) {
+			return errors.AddContext(ErrUploadPolicyViolation, fmt.Sprintf("file '%v' has the blocked content type '%v'", filename, mediaType))
+		}
+	}
+	if len(p.AllowedMIMEPrefixes) == 0 {
+		return nil
+	}
+	for _, allowed := range p.AllowedMIMEPrefixes {
+		if strings.HasPrefix(mediaType, strings.ToLower(allowed)) {
+			return nil
+		}
+	}
+	return errors.AddContext(ErrUploadPolicyViolation, fmt.Sprintf("file '%v' has the content type '%v' which is not allowed", filename, mediaType))
+}
+
+// CheckSubfiles checks whether an upload can contain the given number of
+// files.
+func (p SkynetUploadPolicy) CheckSubfiles(numSubfiles uint64) error {
+	if p.MaxSubfiles > 0 && numSubfiles > p.MaxSubfiles {
+		return errors.AddContext(errors.Compose(ErrTooManySubfiles, ErrUploadPolicyViolation), fmt.Sprintf("upload contains more than %v files", p.MaxSubfiles))
+	}
+	return nil
+}
+
+// HasMIMERules returns true if the policy restricts the content types of
+// uploaded files.
+func (p SkynetUploadPolicy) HasMIMERules() bool {
+	return len(p.AllowedMIMEPrefixes) > 0 || len(p.BlockedMIMEPrefixes) > 0
+}
+
+// Validate checks the policy for empty rules.
+func (p SkynetUploadPolicy) Validate() error {
+	for _, rules := range [][]string{p.AllowedMIMEPrefixes, p.BlockedMIMEPrefixes, p.BlockedExtensions} {
+		for _, rule := range rules {
+			if strings.TrimSpace(rule) == "" {
+				return errors.New("upload policy can't contain empty rules")
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeExtension returns the lowercase extension with a leading dot.
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// SkynetUploadRateLimit limits the uploads a single IP can make within a
+// sliding window. The zero value disables the limit.
+type SkynetUploadRateLimit struct {
+	// MaxUploads is the maximum number of uploads per window. 0 means
+	// unlimited.
+	MaxUploads uint64 `json:"maxuploads"`
+
+	// MaxBytes is the maximum number of bytes that can be uploaded per
+	// window. 0 means unlimited.
+	MaxBytes uint64 `json:"maxbytes"`
+
+	// WindowSeconds is the length of the sliding window in seconds.
+	WindowSeconds uint64 `json:"windowseconds"`
+
+	// TrustForwardedFor makes the limit use the last IP of the
+	// X-Forwarded-For header instead of the IP of the connection. It must
+	// only be set if the node is exclusively reachable through a reverse
+	// proxy which appends the IP of the client to the header.
+	TrustForwardedFor bool `json:"trustforwardedfor"`
+}
+
+// Enabled returns true if the rate limit restricts uploads.
+func (l SkynetUploadRateLimit) Enabled() bool {
+	return l.WindowSeconds > 0 && (l.MaxUploads > 0 || l.MaxBytes > 0)
+}
+
+// Window returns the length of the sliding window.
+func (l SkynetUploadRateLimit) Window() time.Duration {
+	return time.Duration(l.WindowSeconds) * time.Second
+}
+
+// Validate checks that a window is set if uploads are limited.
+func (l SkynetUploadRateLimit) Validate() error {
+	if (l.MaxUploads > 0 || l.MaxBytes > 0) && l.WindowSeconds == 0 {
+		return errors.New("upload rate limit requires a window")
+	}
+	return nil
+}
+
+// SkynetMultipartLimits limits the structure of multipart uploads to protect
+// the portal from pathological uploads. A limit of 0 falls back to the
+// default. The number of files is limited by the SkynetUploadPolicy instead.
+type SkynetMultipartLimits struct {
+	// MaxFilenameLength is the maximum length of the name of a file in
+	// bytes.
+	MaxFilenameLength uint64 `json:"maxfilenamelength"`
+
+	// MaxHeaderBytes is the maximum number of bytes the headers of all parts
+	// of an upload can contain combined.
+	MaxHeaderBytes uint64 `json:"maxheaderbytes"`
+}
+
+// WithDefaults returns the limits with every unset limit replaced by its
+// default.
+func (l SkynetMultipartLimits) WithDefaults() SkynetMultipartLimits {
+	if l.MaxFilenameLength == 0 {
+		l.MaxFilenameLength = DefaultSkynetMultipartLimits.MaxFilenameLength
+	}
+	if l.MaxHeaderBytes == 0 {
+		l.MaxHeaderBytes = DefaultSkynetMultipartLimits.MaxHeaderBytes
+	}
+	return l
+}
@@ -0,0 +1,69 @@
+package skymodules
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+// TestSkynetUploadPolicy verifies the checks of the SkynetUploadPolicy.
+func TestSkynetUploadPolicy(t *testing.T) {
+	t.Parallel()
+
+	// the zero value allows everything
+	var policy SkynetUploadPolicy
+	if err := policy.CheckFile("file.exe", "application/x-msdownload"); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.CheckSubfiles(1e6); err != nil {
+		t.Fatal(err)
+	}
+
+	policy = SkynetUploadPolicy{
+		AllowedMIMEPrefixes: []string{"text/", "image/"},
+		BlockedMIMEPrefixes: []string{"text/html"},
+		BlockedExtensions:   []string{"EXE", ".bat"},
+		MaxSubfiles:         2,
+	}
+	tests := []struct {
+		filename    string
+		contentType string
+		allowed     bool
+	}{
+		{"file.txt", "text/plain; charset=utf-8", true},
+		{"image.png", "image/png", true},
+		{"file.html", "text/html", false},
+		{"file.HTML", "TEXT/HTML; charset=utf-8", false},
+		{"file.exe", "text/plain", false},
+		{"dir/file.Bat", "text/plain", false},
+		{"file.json", "application/json", false},
+		{"file", "", false},
+		{"file", "invalid;;", false},
+	}
+	for _, test := range tests {
+		err := policy.CheckFile(test.filename, test.contentType)
+		if test.allowed && err != nil {
+			t.Errorf("%v %v: unexpected error %v", test.filename, test.contentType, err)
+		}
+		if !test.allowed && !errors.Contains(err, ErrUploadPolicyViolation) {
+			t.Errorf("%v %v: expected ErrUploadPolicyViolation, got %v", test.filename, test.contentType, err)
+		}
+	}
+
+	// check the subfile limit
+	if err := policy.CheckSubfiles(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.CheckSubfiles(3); !errors.Contains(err, ErrUploadPolicyViolation) || !errors.Contains(err, ErrTooManySubfiles) {
+		t.Fatal("expected ErrUploadPolicyViolation and ErrTooManySubfiles", err)
+	}
+
+	// check validation
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	policy.BlockedExtensions = append(policy.BlockedExtensions, " ")
+	if err := policy.Validate(); err == nil {
+		t.Fatal("expected policy with empty rule to be invalid")
+	}
+}